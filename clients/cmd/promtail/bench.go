@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime/pprof"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/clients/pkg/logentry/stages"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/config"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+const benchPipelineUsage = `Usage: promtail bench-pipeline [flags] <config file>
+
+Runs the pipeline stages of a scrape config of a Promtail config file against a corpus of sample
+log lines, and reports the time taken and the memory allocated per entry by every stage, so that
+expensive stages are caught before rollout. The entries sent by the pipeline can be written as
+fixtures, and compared with fixtures written before to test the changes of the pipeline.
+
+Flags:
+`
+
+// fixture is an entry sent by the pipeline, in the format of the ndjson records read by -stdin.
+type fixture struct {
+	Line      string            `json:"line"`
+	Timestamp time.Time         `json:"timestamp"`
+	Labels    map[string]string `json:"labels"`
+}
+
+// runBenchPipeline runs the bench-pipeline subcommand.
+func runBenchPipeline(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("promtail bench-pipeline", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.Usage = func() {
+		fmt.Fprint(stdout, benchPipelineUsage)
+		fs.PrintDefaults()
+	}
+	corpus := fs.String("corpus", "-", "File of sample log lines, - reads the standard input.")
+	format := fs.String("corpus.format", "raw", "Format of the corpus, either raw lines or ndjson records with line, timestamp and labels fields.")
+	job := fs.String("job", "", "Job name of the scrape config to run the pipeline of. Defaults to the first scrape config.")
+	iterations := fs.Int("iterations", 10, "Number of times the corpus is run through every stage.")
+	maxLatency := fs.Duration("max-stage-latency", 0, "Fail when a stage takes longer than this per entry. 0 disables the check.")
+	fixtures := fs.String("fixtures", "", "File to write the entries sent by the pipeline to, as ndjson records.")
+	expect := fs.String("expect", "", "File of fixtures the entries sent by the pipeline must match.")
+	cpuProfile := fs.String("cpuprofile", "", "File to write a CPU profile of the benchmark to.")
+	memProfile := fs.String("memprofile", "", "File to write an allocation profile of the benchmark to.")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one config file, got %d", fs.NArg())
+	}
+
+	scrapeConfig, err := loadScrapeConfig(fs.Arg(0), *job)
+	if err != nil {
+		return err
+	}
+	pipeline, err := stages.NewPipeline(log.NewNopLogger(), scrapeConfig.PipelineStages, &scrapeConfig.JobName, prometheus.NewRegistry())
+	if err != nil {
+		return err
+	}
+
+	in := stdin
+	if *corpus != "-" {
+		f, err := os.Open(*corpus)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	entries, err := readCorpus(in, *format, staticLabels(scrapeConfig))
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("the corpus is empty")
+	}
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+	}
+	res, out := pipeline.Bench(entries, *iterations)
+	if *cpuProfile != "" {
+		pprof.StopCPUProfile()
+	}
+	if *memProfile != "" {
+		if err := writeProfile(*memProfile, "allocs"); err != nil {
+			return err
+		}
+	}
+
+	printBench(stdout, res, len(entries), len(out))
+
+	if *fixtures != "" {
+		if err := writeFixtures(*fixtures, out); err != nil {
+			return err
+		}
+	}
+	if *expect != "" {
+		if err := compareFixtures(*expect, out); err != nil {
+			return err
+		}
+	}
+	if *maxLatency > 0 {
+		for _, r := range res {
+			if perEntry := perEntry(r.Duration, r.Received); perEntry > *maxLatency {
+				return fmt.Errorf("the %s stage takes %s per entry, more than the max stage latency of %s", r.Name, perEntry, *maxLatency)
+			}
+		}
+	}
+	return nil
+}
+
+// loadScrapeConfig returns the scrape config of the job, or the first one.
+func loadScrapeConfig(file, job string) (scrapeconfig.Config, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return scrapeconfig.Config{}, err
+	}
+	var cfg config.Config
+	if err := yaml.Unmarshal(buf, &cfg); err != nil {
+		return scrapeconfig.Config{}, fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+	if len(cfg.ScrapeConfig) == 0 {
+		return scrapeconfig.Config{}, fmt.Errorf("no scrape config in %s", file)
+	}
+	if job == "" {
+		return cfg.ScrapeConfig[0], nil
+	}
+	for _, sc := range cfg.ScrapeConfig {
+		if sc.JobName == job {
+			return sc, nil
+		}
+	}
+	return scrapeconfig.Config{}, fmt.Errorf("no scrape config with job name %q in %s", job, file)
+}
+
+// staticLabels returns the labels of the static configs of the scrape config.
+func staticLabels(cfg scrapeconfig.Config) model.LabelSet {
+	lbs := model.LabelSet{}
+	for _, static := range cfg.ServiceDiscoveryConfig.StaticConfigs {
+		if static != nil {
+			lbs = lbs.Merge(static.Labels)
+		}
+	}
+	delete(lbs, "__path__")
+	return lbs
+}
+
+// readCorpus reads the entries of the corpus. The entries without timestamp get the Unix epoch, so
+// that the fixtures written from them don't change between runs.
+func readCorpus(r io.Reader, format string, lbs model.LabelSet) ([]stages.Entry, error) {
+	if format != "raw" && format != "ndjson" {
+		return nil, fmt.Errorf("unknown corpus format %q, must be raw or ndjson", format)
+	}
+	var (
+		entries []stages.Entry
+		epoch   = time.Unix(0, 0).UTC()
+		scanner = bufio.NewScanner(r)
+		n       int
+	)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		n++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		entry := api.Entry{
+			Labels: lbs.Clone(),
+			Entry:  logproto.Entry{Timestamp: epoch, Line: line},
+		}
+		if format == "ndjson" {
+			var f fixture
+			if err := json.Unmarshal([]byte(line), &f); err != nil {
+				return nil, fmt.Errorf("invalid ndjson record on line %d: %w", n, err)
+			}
+			entry.Line = f.Line
+			if !f.Timestamp.IsZero() {
+				entry.Timestamp = f.Timestamp
+			}
+			for name, value := range f.Labels {
+				entry.Labels[model.LabelName(name)] = model.LabelValue(value)
+			}
+		}
+		entries = append(entries, stages.Entry{Extracted: map[string]interface{}{}, Entry: entry})
+	}
+	return entries, scanner.Err()
+}
+
+func printBench(w io.Writer, res []stages.StageBenchmark, received, sent int) {
+	var total time.Duration
+	for _, r := range res {
+		total += r.Duration
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tSTAGE\tIN\tOUT\tTIME/ENTRY\tALLOCS/ENTRY\tBYTES/ENTRY\tTIME %")
+	for i, r := range res {
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(r.Duration) / float64(total)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%s\t%d\t%s\t%.1f%%\n",
+			i+1, r.Name, r.Received, r.Sent,
+			perEntry(r.Duration, r.Received),
+			perEntryCount(r.Allocs, r.Received),
+			humanize.IBytes(perEntryCount(r.AllocBytes, r.Received)),
+			share,
+		)
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "%d entries in, %d entries out per iteration\n", received, sent)
+}
+
+func perEntry(d time.Duration, entries int) time.Duration {
+	if entries == 0 {
+		return 0
+	}
+	return d / time.Duration(entries)
+}
+
+func perEntryCount(c uint64, entries int) uint64 {
+	if entries == 0 {
+		return 0
+	}
+	return c / uint64(entries)
+}
+
+func writeProfile(file, name string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func toFixture(e stages.Entry) fixture {
+	lbs := make(map[string]string, len(e.Labels))
+	for name, value := range e.Labels {
+		lbs[string(name)] = string(value)
+	}
+	return fixture{Line: e.Line, Timestamp: e.Timestamp, Labels: lbs}
+}
+
+func writeFixtures(file string, entries []stages.Entry) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(toFixture(e)); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// compareFixtures returns an error describing the first entry not matching its fixture.
+func compareFixtures(file string, entries []stages.Entry) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	expected, err := readCorpus(f, "ndjson", model.LabelSet{})
+	if err != nil {
+		return err
+	}
+	if len(expected) != len(entries) {
+		return fmt.Errorf("the pipeline sent %d entries, %d expected by %s", len(entries), len(expected), file)
+	}
+	for i := range entries {
+		exp, got := toFixture(expected[i]), toFixture(entries[i])
+		if exp.Line != got.Line || !exp.Timestamp.Equal(got.Timestamp) || !expected[i].Labels.Equal(entries[i].Labels) {
+			expBuf, _ := json.Marshal(exp)
+			gotBuf, _ := json.Marshal(got)
+			return fmt.Errorf("entry %d doesn't match %s:\n  exp: %s\n  got: %s", i+1, file, expBuf, gotBuf)
+		}
+	}
+	return nil
+}
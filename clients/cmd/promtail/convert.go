@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/grafana/dskit/flagext"
+
+	"github.com/grafana/loki/clients/pkg/promtail/convert"
+)
+
+const convertUsage = `Usage: promtail convert [flags] <config file>
+
+Converts the inputs of a Filebeat config file, or of a Fluent Bit config file in the classic
+format, into Promtail scrape configs. The settings which can't be converted are printed as
+comments. The output is a starting point for a migration, review it before use.
+
+Flags:
+`
+
+// runConvert runs the convert subcommand, which translates the configs of other log shippers into scrape configs.
+func runConvert(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("promtail convert", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.Usage = func() {
+		fmt.Fprint(stdout, convertUsage)
+		fs.PrintDefaults()
+	}
+	from := fs.String("from", "", "Format of the config file, filebeat or fluentbit. Defaults to filebeat for .yml and .yaml files, and to fluentbit otherwise.")
+	output := fs.String("o", "", "File to write the scrape configs to, instead of the standard output.")
+	var parsers flagext.StringSlice
+	fs.Var(&parsers, "parsers", "Fluent Bit parsers file, in addition to the Parsers_File of the config. Can be repeated.")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one config file, got %d", fs.NArg())
+	}
+	file := fs.Arg(0)
+
+	format := *from
+	if format == "" {
+		format = "fluentbit"
+		switch filepath.Ext(file) {
+		case ".yml", ".yaml":
+			format = "filebeat"
+		}
+	}
+	var (
+		res *convert.Result
+		err error
+	)
+	switch format {
+	case "filebeat":
+		var b []byte
+		b, err = ioutil.ReadFile(file)
+		if err == nil {
+			res, err = convert.Filebeat(b)
+		}
+	case "fluentbit":
+		res, err = convert.FluentBit(file, parsers)
+	default:
+		return fmt.Errorf("unknown format %q, expected filebeat or fluentbit", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := res.Marshal()
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		return ioutil.WriteFile(*output, out, 0644)
+	}
+	_, err = stdout.Write(out)
+	return err
+}
@@ -62,7 +62,31 @@ func (c *Config) Clone() flagext.Registerer {
 	}(*c)
 }
 
+// reloadConfig loads the config again from the config file and the flags, on reload.
+func reloadConfig() (*config.Config, error) {
+	var c Config
+	if err := cfg.DefaultUnmarshal(&c, os.Args[1:], flag.NewFlagSet(os.Args[0], flag.ContinueOnError)); err != nil {
+		return nil, err
+	}
+	return &c.Config, nil
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		if err := runConvert(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench-pipeline" {
+		if err := runBenchPipeline(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Load config, merging config file and CLI flags
 	var config Config
 	if err := cfg.DefaultUnmarshal(&config, os.Args[1:], flag.CommandLine); err != nil {
@@ -110,7 +134,7 @@ func main() {
 		}
 	}
 
-	p, err := promtail.New(config.Config, config.dryRun)
+	p, err := promtail.New(config.Config, config.dryRun, promtail.WithNewConfig(reloadConfig))
 	if err != nil {
 		level.Error(util_log.Logger).Log("msg", "error creating promtail", "error", err)
 		os.Exit(1)
@@ -1,19 +1,65 @@
 package metric
 
 import (
+	"sort"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 )
 
+const (
+	ErrHistogramMultipleBuckets   = "only one of buckets, linear_buckets or exponential_buckets can be set"
+	ErrHistogramUnsortedBuckets   = "histogram buckets must be in increasing order"
+	ErrHistogramInvalidBucketsCfg = "invalid %s: %s"
+)
+
 type HistogramConfig struct {
-	Value   *string   `mapstructure:"value"`
-	Buckets []float64 `mapstructure:"buckets"`
+	Value              *string        `mapstructure:"value"`
+	Buckets            []float64      `mapstructure:"buckets"`
+	LinearBuckets      *BucketsConfig `mapstructure:"linear_buckets"`
+	ExponentialBuckets *BucketsConfig `mapstructure:"exponential_buckets"`
+}
+
+// BucketsConfig generates Count buckets, starting at Start and then
+// incremented by Width for linear buckets or multiplied by Factor for
+// exponential buckets.
+type BucketsConfig struct {
+	Start  float64 `mapstructure:"start"`
+	Width  float64 `mapstructure:"width"`
+	Factor float64 `mapstructure:"factor"`
+	Count  int     `mapstructure:"count"`
 }
 
 func validateHistogramConfig(config *HistogramConfig) error {
+	set := 0
+	if config.Buckets != nil {
+		set++
+	}
+	if config.LinearBuckets != nil {
+		set++
+		b := config.LinearBuckets
+		if b.Count < 1 || b.Width <= 0 {
+			return errors.Errorf(ErrHistogramInvalidBucketsCfg, "linear_buckets", "count must be positive and width greater than 0")
+		}
+		config.Buckets = prometheus.LinearBuckets(b.Start, b.Width, b.Count)
+	}
+	if config.ExponentialBuckets != nil {
+		set++
+		b := config.ExponentialBuckets
+		if b.Count < 1 || b.Start <= 0 || b.Factor <= 1 {
+			return errors.Errorf(ErrHistogramInvalidBucketsCfg, "exponential_buckets", "count must be positive, start greater than 0 and factor greater than 1")
+		}
+		config.Buckets = prometheus.ExponentialBuckets(b.Start, b.Factor, b.Count)
+	}
+	if set > 1 {
+		return errors.New(ErrHistogramMultipleBuckets)
+	}
+	if !sort.Float64sAreSorted(config.Buckets) {
+		return errors.New(ErrHistogramUnsortedBuckets)
+	}
 	return nil
 }
 
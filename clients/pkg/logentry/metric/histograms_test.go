@@ -4,10 +4,65 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 )
 
+func Test_validateHistogramConfig(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		config  HistogramConfig
+		buckets []float64
+		err     error
+	}{
+		{"explicit buckets",
+			HistogramConfig{Buckets: []float64{1, 2, 5}},
+			[]float64{1, 2, 5},
+			nil,
+		},
+		{"unsorted buckets",
+			HistogramConfig{Buckets: []float64{5, 1}},
+			nil,
+			errors.New(ErrHistogramUnsortedBuckets),
+		},
+		{"linear buckets",
+			HistogramConfig{LinearBuckets: &BucketsConfig{Start: 10, Width: 5, Count: 3}},
+			[]float64{10, 15, 20},
+			nil,
+		},
+		{"exponential buckets",
+			HistogramConfig{ExponentialBuckets: &BucketsConfig{Start: 1, Factor: 2, Count: 4}},
+			[]float64{1, 2, 4, 8},
+			nil,
+		},
+		{"invalid exponential buckets",
+			HistogramConfig{ExponentialBuckets: &BucketsConfig{Start: 0, Factor: 2, Count: 4}},
+			nil,
+			errors.Errorf(ErrHistogramInvalidBucketsCfg, "exponential_buckets", "count must be positive, start greater than 0 and factor greater than 1"),
+		},
+		{"multiple buckets",
+			HistogramConfig{Buckets: []float64{1}, LinearBuckets: &BucketsConfig{Start: 1, Width: 1, Count: 1}},
+			nil,
+			errors.New(ErrHistogramMultipleBuckets),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateHistogramConfig(&tt.config)
+			if tt.err != nil {
+				assert.EqualError(t, err, tt.err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.buckets, tt.config.Buckets)
+		})
+	}
+}
+
 func TestHistogramExpiration(t *testing.T) {
 	t.Parallel()
 	cfg := HistogramConfig{}
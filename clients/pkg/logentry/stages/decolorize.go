@@ -0,0 +1,32 @@
+package stages
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// ansiEscapeRegex matches the ANSI escape sequences: the control sequences (CSI), like the SGR ones
+// setting the colors, the operating system commands (OSC) and the two character escape sequences.
+var ansiEscapeRegex = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+func newDecolorizeStage(_ interface{}) (Stage, error) {
+	return toStage(&decolorizeStage{}), nil
+}
+
+// decolorizeStage removes the ANSI escape sequences, like the colors, from the log line.
+type decolorizeStage struct{}
+
+// Process implements Processor
+func (m *decolorizeStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+	if entry == nil {
+		return
+	}
+	*entry = ansiEscapeRegex.ReplaceAllString(*entry, "")
+}
+
+// Name implements Stage
+func (m *decolorizeStage) Name() string {
+	return StageTypeDecolorize
+}
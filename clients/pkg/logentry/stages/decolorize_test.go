@@ -0,0 +1,53 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+var testDecolorizeYaml = `
+pipeline_stages:
+- decolorize:
+- regex:
+    expression: '^level=(?P<level>\w+)'
+- labels:
+    level:
+`
+
+// TestDecolorizePipeline is used to verify we properly parse the yaml config and create a working pipeline
+func TestDecolorizePipeline(t *testing.T) {
+	plName := "test_pipeline"
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testDecolorizeYaml), &plName, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+	out := processEntries(pl, newEntry(nil, nil, "level=\x1b[31merror\x1b[0m msg=failed", time.Now()))[0]
+	require.Equal(t, "level=error msg=failed", out.Line)
+	require.Equal(t, "error", string(out.Labels["level"]))
+}
+
+func Test_decolorizeStage(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		line string
+		want string
+	}{
+		{"no colors", "plain line", "plain line"},
+		{"colors", "\x1b[1;32mINFO\x1b[0m started", "INFO started"},
+		{"256 colors", "\x1b[38;5;196mred\x1b[m", "red"},
+		{"cursor and erase", "\x1b[2K\x1b[1Gprogress 100%", "progress 100%"},
+		{"window title", "\x1b]0;title\x07line", "line"},
+		{"unicode", "\x1b[33mwarnung: größe\x1b[0m", "warnung: größe"},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newDecolorizeStage(nil)
+			require.NoError(t, err)
+			out := processEntries(s, newEntry(nil, nil, tt.line, time.Now()))
+			require.Len(t, out, 1)
+			require.Equal(t, tt.want, out[0].Line)
+		})
+	}
+}
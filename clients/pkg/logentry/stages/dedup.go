@@ -0,0 +1,280 @@
+package stages
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+const (
+	ErrDedupStageInvalidWindow = "dedup stage window must be a positive duration: %v"
+	ErrDedupStageInvalidMaxKey = "dedup stage max_keys must be at least 1, got %v"
+
+	defaultDedupWindow  = 10 * time.Second
+	defaultDedupMaxKeys = 10000
+
+	dedupSummaryFormat = "message repeated %d times: [%s]"
+)
+
+var (
+	defaultDedupReason = "dedup_stage"
+)
+
+// DedupConfig contains the configuration for a dedupStage
+type DedupConfig struct {
+	Window      string   `mapstructure:"window"`
+	Consecutive bool     `mapstructure:"consecutive"`
+	Fields      []string `mapstructure:"fields"`
+	MaxKeys     *int     `mapstructure:"max_keys"`
+	DropReason  *string  `mapstructure:"drop_counter_reason"`
+
+	window time.Duration
+}
+
+// validateDedupConfig validates the DedupConfig for the dedupStage
+func validateDedupConfig(cfg *DedupConfig) error {
+	cfg.window = defaultDedupWindow
+	if cfg.Window != "" {
+		window, err := time.ParseDuration(cfg.Window)
+		if err != nil {
+			return errors.Errorf(ErrDedupStageInvalidWindow, err)
+		}
+		if window <= 0 {
+			return errors.Errorf(ErrDedupStageInvalidWindow, window)
+		}
+		cfg.window = window
+	}
+	if cfg.MaxKeys == nil {
+		maxKeys := defaultDedupMaxKeys
+		cfg.MaxKeys = &maxKeys
+	}
+	if *cfg.MaxKeys < 1 {
+		return errors.Errorf(ErrDedupStageInvalidMaxKey, *cfg.MaxKeys)
+	}
+	if cfg.DropReason == nil || *cfg.DropReason == "" {
+		cfg.DropReason = &defaultDedupReason
+	}
+	return nil
+}
+
+// newDedupStage creates a dedupStage from config
+func newDedupStage(logger log.Logger, config interface{}, registerer prometheus.Registerer) (Stage, error) {
+	cfg := &DedupConfig{}
+	if config != nil {
+		err := mapstructure.WeakDecode(config, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := validateDedupConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &dedupStage{
+		logger:    log.With(logger, "component", "stage", "type", "dedup"),
+		cfg:       cfg,
+		dropCount: getDropCountMetric(registerer),
+		streams:   map[string]*dedupStream{},
+		now:       time.Now,
+	}, nil
+}
+
+// dedupStage drops the entries of a stream identical to an entry sent less than a window ago,
+// and sends a summary entry with the number of dropped entries once the window ends.
+// Entries are identical when they have the same line, or the same values of the configured
+// extracted fields. In consecutive mode, an entry different from the previous one of its
+// stream also ends the window.
+type dedupStage struct {
+	logger    log.Logger
+	cfg       *DedupConfig
+	dropCount *prometheus.CounterVec
+	streams   map[string]*dedupStream
+	// keys is the number of open windows, of all the streams.
+	keys int
+	now  func() time.Time
+}
+
+type dedupStream struct {
+	windows map[uint64]*dedupWindow
+	// current is the key of the last entry of the stream, for the consecutive mode.
+	current uint64
+	// lastTimestamp is the timestamp of the most recent entry of the stream, given to the
+	// summaries so that they are not out of order.
+	lastTimestamp time.Time
+}
+
+// dedupWindow holds the first entry of a window, which has been sent, and the dropped duplicates.
+type dedupWindow struct {
+	start     time.Time
+	labels    model.LabelSet
+	extracted map[string]interface{}
+	line      string
+	count     int
+	acks      []api.AckFunc
+}
+
+// Run implements Stage
+func (m *dedupStage) Run(in chan Entry) chan Entry {
+	out := make(chan Entry)
+	go func() {
+		defer close(out)
+		tick := m.cfg.window
+		if tick > time.Second {
+			tick = time.Second
+		}
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case e, ok := <-in:
+				if !ok {
+					for _, s := range m.endAll() {
+						out <- s
+					}
+					return
+				}
+				for _, s := range m.process(e) {
+					out <- s
+				}
+			case <-ticker.C:
+				for _, s := range m.expire(m.now()) {
+					out <- s
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// process returns the entries to send for e: the summaries of the windows it ends, followed
+// by e unless it is a duplicate.
+func (m *dedupStage) process(e Entry) []Entry {
+	now := m.now()
+	streamKey := e.Labels.String()
+	s, ok := m.streams[streamKey]
+	if !ok {
+		s = &dedupStream{windows: map[uint64]*dedupWindow{}}
+	}
+	if e.Timestamp.After(s.lastTimestamp) {
+		s.lastTimestamp = e.Timestamp
+	}
+	key := m.key(e)
+
+	var out []Entry
+	if m.cfg.Consecutive && s.current != key {
+		if w, ok := s.windows[s.current]; ok {
+			out = append(out, m.end(streamKey, s, s.current, w)...)
+		}
+	}
+	s.current = key
+
+	if w, ok := s.windows[key]; ok {
+		if now.Sub(w.start) < m.cfg.window {
+			w.count++
+			w.acks = append(w.acks, e.Ack)
+			m.dropCount.WithLabelValues(*m.cfg.DropReason).Inc()
+			return out
+		}
+		out = append(out, m.end(streamKey, s, key, w)...)
+	}
+
+	if m.keys >= *m.cfg.MaxKeys {
+		if Debug {
+			level.Debug(m.logger).Log("msg", "too many lines are deduplicated, ending all the windows", "max_keys", *m.cfg.MaxKeys)
+		}
+		out = append(out, m.endAll()...)
+	}
+	extracted := make(map[string]interface{}, len(e.Extracted))
+	for k, v := range e.Extracted {
+		extracted[k] = v
+	}
+	s.windows[key] = &dedupWindow{
+		start:     now,
+		labels:    e.Labels.Clone(),
+		extracted: extracted,
+		line:      e.Line,
+	}
+	m.streams[streamKey] = s
+	m.keys++
+	return append(out, e)
+}
+
+// key returns the hash of the line, or of the values of the configured fields.
+func (m *dedupStage) key(e Entry) uint64 {
+	if len(m.cfg.Fields) == 0 {
+		return xxhash.Sum64String(e.Line)
+	}
+	values := make([]string, 0, len(m.cfg.Fields))
+	for _, f := range m.cfg.Fields {
+		var s string
+		if v, ok := e.Extracted[f]; ok {
+			s, _ = getString(v)
+		}
+		values = append(values, s)
+	}
+	return xxhash.Sum64String(strings.Join(values, "\xff"))
+}
+
+// expire ends the windows older than the configured window.
+func (m *dedupStage) expire(now time.Time) []Entry {
+	var out []Entry
+	for streamKey, s := range m.streams {
+		for key, w := range s.windows {
+			if now.Sub(w.start) >= m.cfg.window {
+				out = append(out, m.end(streamKey, s, key, w)...)
+			}
+		}
+	}
+	return out
+}
+
+// endAll ends all the windows.
+func (m *dedupStage) endAll() []Entry {
+	var out []Entry
+	for streamKey, s := range m.streams {
+		for key, w := range s.windows {
+			out = append(out, m.end(streamKey, s, key, w)...)
+		}
+	}
+	return out
+}
+
+// end ends a window and returns its summary, if it dropped duplicates.
+func (m *dedupStage) end(streamKey string, s *dedupStream, key uint64, w *dedupWindow) []Entry {
+	delete(s.windows, key)
+	m.keys--
+	if len(s.windows) == 0 {
+		delete(m.streams, streamKey)
+	}
+	if w.count == 0 {
+		return nil
+	}
+	return []Entry{{
+		Extracted: w.extracted,
+		Entry: api.Entry{
+			Labels: w.labels,
+			Entry: logproto.Entry{
+				Timestamp: s.lastTimestamp,
+				Line:      fmt.Sprintf(dedupSummaryFormat, w.count, w.line),
+			},
+			Ack: api.JoinAcks(w.acks),
+		},
+	}}
+}
+
+// Name implements Stage
+func (m *dedupStage) Name() string {
+	return StageTypeDedup
+}
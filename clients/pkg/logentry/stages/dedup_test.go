@@ -0,0 +1,157 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testDedupYaml = `
+pipeline_stages:
+- dedup:
+    window: 1m
+    drop_counter_reason: duplicate
+`
+
+// TestDedupPipeline is used to verify we properly parse the yaml config and create a working pipeline
+func TestDedupPipeline(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	plName := "test_pipeline"
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testDedupYaml), &plName, registry)
+	require.NoError(t, err)
+
+	ts := time.Unix(1, 0)
+	out := processEntries(pl,
+		newEntry(nil, model.LabelSet{"app": "kernel"}, "link down", ts),
+		newEntry(nil, model.LabelSet{"app": "kernel"}, "link down", ts.Add(time.Second)),
+		newEntry(nil, model.LabelSet{"app": "kernel"}, "link up", ts.Add(2*time.Second)),
+		newEntry(nil, model.LabelSet{"app": "kernel"}, "link down", ts.Add(3*time.Second)),
+		newEntry(nil, model.LabelSet{"app": "auth"}, "link down", ts.Add(4*time.Second)),
+	)
+
+	// The pending summary is sent when the pipeline stops.
+	require.Len(t, out, 4)
+	require.Equal(t, "link down", out[0].Line)
+	require.Equal(t, "link up", out[1].Line)
+	require.Equal(t, "link down", out[2].Line)
+	require.Equal(t, model.LabelValue("auth"), out[2].Labels["app"])
+	require.Equal(t, "message repeated 2 times: [link down]", out[3].Line)
+	require.Equal(t, model.LabelSet{"app": "kernel"}, out[3].Labels)
+	require.Equal(t, ts.Add(3*time.Second), out[3].Timestamp)
+	require.Equal(t, float64(2), testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues("duplicate")))
+}
+
+func newTestDedupStage(t *testing.T, cfg *DedupConfig) (*dedupStage, *time.Time) {
+	s, err := newDedupStage(util_log.Logger, cfg, prometheus.NewRegistry())
+	require.NoError(t, err)
+	now := time.Unix(0, 0)
+	st := s.(*dedupStage)
+	st.now = func() time.Time { return now }
+	return st, &now
+}
+
+func lines(entries []Entry) []string {
+	res := make([]string, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.Line)
+	}
+	return res
+}
+
+func Test_dedupStage_Window(t *testing.T) {
+	st, now := newTestDedupStage(t, &DedupConfig{Window: "10s"})
+	entry := func(line string) Entry {
+		return newEntry(nil, model.LabelSet{"app": "a"}, line, *now)
+	}
+
+	require.Equal(t, []string{"a"}, lines(st.process(entry("a"))))
+	require.Empty(t, st.process(entry("a")))
+	require.Equal(t, []string{"b"}, lines(st.process(entry("b"))))
+	require.Empty(t, st.process(entry("a")))
+	require.Empty(t, st.expire(*now))
+
+	// The window of a ends first, the one of b had no duplicates.
+	*now = now.Add(10 * time.Second)
+	require.Equal(t, []string{"message repeated 2 times: [a]"}, lines(st.expire(*now)))
+	require.Empty(t, st.streams)
+	require.Equal(t, 0, st.keys)
+
+	// A new window starts.
+	require.Equal(t, []string{"a"}, lines(st.process(entry("a"))))
+}
+
+func Test_dedupStage_Consecutive(t *testing.T) {
+	st, now := newTestDedupStage(t, &DedupConfig{Window: "10s", Consecutive: true})
+	entry := func(line string) Entry {
+		return newEntry(nil, model.LabelSet{"app": "a"}, line, *now)
+	}
+
+	require.Equal(t, []string{"a"}, lines(st.process(entry("a"))))
+	require.Empty(t, st.process(entry("a")))
+	require.Empty(t, st.process(entry("a")))
+	require.Equal(t, []string{"message repeated 2 times: [a]", "b"}, lines(st.process(entry("b"))))
+	require.Equal(t, []string{"a"}, lines(st.process(entry("a"))))
+	require.Equal(t, 1, st.keys)
+}
+
+func Test_dedupStage_Fields(t *testing.T) {
+	st, _ := newTestDedupStage(t, &DedupConfig{Fields: []string{"msg", "user"}})
+	entry := func(line, msg string) Entry {
+		return newEntry(map[string]interface{}{"msg": msg, "user": "jane"}, model.LabelSet{"app": "sshd"}, line, time.Now())
+	}
+
+	require.Equal(t, []string{"1 failed login"}, lines(st.process(entry("1 failed login", "failed login"))))
+	require.Empty(t, st.process(entry("2 failed login", "failed login")))
+	require.Equal(t, []string{"3 login"}, lines(st.process(entry("3 login", "login"))))
+	require.Equal(t, []string{"message repeated 1 times: [1 failed login]"}, lines(st.endAll()))
+}
+
+func Test_dedupStage_Acks(t *testing.T) {
+	st, _ := newTestDedupStage(t, &DedupConfig{})
+	var acked int
+	entry := newEntry(nil, model.LabelSet{"app": "a"}, "a", time.Now())
+	entry.Ack = func(error) { acked++ }
+
+	require.Len(t, st.process(entry), 1)
+	require.Empty(t, st.process(entry))
+	require.Empty(t, st.process(entry))
+	require.Equal(t, 0, acked)
+
+	// The duplicates are acknowledged with their summary.
+	summaries := st.endAll()
+	require.Len(t, summaries, 1)
+	summaries[0].Ack.Done(nil)
+	require.Equal(t, 2, acked)
+}
+
+func Test_dedupStage_MaxKeys(t *testing.T) {
+	maxKeys := 2
+	st, _ := newTestDedupStage(t, &DedupConfig{MaxKeys: &maxKeys})
+	entry := func(line string) Entry {
+		return newEntry(nil, model.LabelSet{"app": "a"}, line, time.Now())
+	}
+
+	st.process(entry("a"))
+	st.process(entry("a"))
+	st.process(entry("b"))
+	require.Equal(t, []string{"message repeated 1 times: [a]", "c"}, lines(st.process(entry("c"))))
+	require.Equal(t, 1, st.keys)
+}
+
+func Test_validateDedupConfig(t *testing.T) {
+	cfg := &DedupConfig{}
+	require.NoError(t, validateDedupConfig(cfg))
+	require.Equal(t, defaultDedupWindow, cfg.window)
+	require.Equal(t, defaultDedupMaxKeys, *cfg.MaxKeys)
+	require.Equal(t, defaultDedupReason, *cfg.DropReason)
+
+	require.Error(t, validateDedupConfig(&DedupConfig{Window: "soon"}))
+	require.Error(t, validateDedupConfig(&DedupConfig{Window: "-1s"}))
+	maxKeys := 0
+	require.Error(t, validateDedupConfig(&DedupConfig{MaxKeys: &maxKeys}))
+}
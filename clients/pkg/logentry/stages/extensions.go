@@ -29,7 +29,7 @@ func NewDocker(logger log.Logger, registerer prometheus.Registerer) (Stage, erro
 			}},
 		PipelineStage{
 			StageTypeOutput: OutputConfig{
-				"output",
+				Source: "output",
 			},
 		}}
 	return NewPipeline(logger, stages, nil, registerer)
@@ -56,7 +56,7 @@ func NewCRI(logger log.Logger, registerer prometheus.Registerer) (Stage, error)
 		},
 		PipelineStage{
 			StageTypeOutput: OutputConfig{
-				"content",
+				Source: "content",
 			},
 		},
 	}
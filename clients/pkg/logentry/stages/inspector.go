@@ -3,7 +3,9 @@ package stages
 import (
 	"fmt"
 	"io"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/google/go-cmp/cmp"
@@ -63,7 +65,51 @@ func (i inspector) inspect(stageName string, before *Entry, after Entry) {
 		diff = i.formatter.red.Sprintf("none")
 	}
 
-	fmt.Fprintf(i.writer, "[inspect: %s stage]: %s\n", i.formatter.bold.Sprintf("%s", stageName), diff)
+	fmt.Fprintf(i.writer, "[inspect: %s stage]: %s\n%s", i.formatter.bold.Sprintf("%s", stageName), diff, i.state(after))
+}
+
+// inspectEntry prints the entry sent by a stage which doesn't process the entries one by one,
+// like the multiline stage, so that there is no entry to compare it with.
+func (i inspector) inspectEntry(stageName string, e Entry) {
+	fmt.Fprintf(i.writer, "[inspect: %s stage]:\n%s", i.formatter.bold.Sprintf("%s", stageName), i.state(e))
+}
+
+// state formats the labels, extracted data and line of the entry.
+func (i inspector) state(e Entry) string {
+	keys := make([]string, 0, len(e.Extracted))
+	for k := range e.Extracted {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	extracted := make([]string, 0, len(keys))
+	for _, k := range keys {
+		extracted = append(extracted, fmt.Sprintf("%s=%q", k, fmt.Sprint(e.Extracted[k])))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\t%s %s\n", i.formatter.bold.Sprint("labels:"), e.Labels.String())
+	fmt.Fprintf(&sb, "\t%s {%s}\n", i.formatter.bold.Sprint("extracted:"), strings.Join(extracted, ", "))
+	fmt.Fprintf(&sb, "\t%s %s\n", i.formatter.bold.Sprint("timestamp:"), e.Timestamp.Format(time.RFC3339Nano))
+	fmt.Fprintf(&sb, "\t%s %s\n", i.formatter.bold.Sprint("line:"), e.Line)
+	return sb.String()
+}
+
+// inspectingStage prints the entries sent by a stage which isn't a Processor.
+type inspectingStage struct {
+	Stage
+	inspector *inspector
+}
+
+func (s *inspectingStage) Run(in chan Entry) chan Entry {
+	out := make(chan Entry)
+	go func() {
+		defer close(out)
+		for e := range s.Stage.Run(in) {
+			s.inspector.inspectEntry(s.Name(), e)
+			out <- e
+		}
+	}()
+	return out
 }
 
 // diffReporter is a simple custom reporter that only records differences
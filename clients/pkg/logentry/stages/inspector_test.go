@@ -0,0 +1,80 @@
+package stages
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func TestInspector(t *testing.T) {
+	var out bytes.Buffer
+	i := newInspector(&out, true)
+	ts := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	before := Entry{
+		Extracted: map[string]interface{}{},
+		Entry: api.Entry{
+			Labels: model.LabelSet{"job": "app"},
+			Entry:  logproto.Entry{Timestamp: ts, Line: "level=error msg=failed"},
+		},
+	}
+	after := *before.copy()
+	after.Extracted = map[string]interface{}{"msg": "failed", "level": "error"}
+	after.Labels["level"] = "error"
+	i.inspect("logfmt", &before, after)
+
+	output := out.String()
+	require.Contains(t, output, "[inspect: logfmt stage]:")
+	require.Contains(t, output, "\tlabels: {job=\"app\", level=\"error\"}\n")
+	require.Contains(t, output, "\textracted: {level=\"error\", msg=\"failed\"}\n")
+	require.Contains(t, output, "\ttimestamp: 2021-10-01T12:00:00Z\n")
+	require.Contains(t, output, "\tline: level=error msg=failed\n")
+}
+
+func TestInspectingStage(t *testing.T) {
+	Inspect = true
+	defer func() { Inspect = false }()
+
+	s, err := New(util_log.Logger, nil, StageTypeMultiline, map[string]interface{}{"firstline": "^start"}, nil)
+	require.NoError(t, err)
+	inspecting, ok := s.(*inspectingStage)
+	require.True(t, ok)
+	var out bytes.Buffer
+	inspecting.inspector = newInspector(&out, true)
+
+	in := make(chan Entry)
+	res := s.Run(in)
+	go func() {
+		for _, line := range []string{"start 1", "  continued", "start 2"} {
+			in <- Entry{
+				Extracted: map[string]interface{}{},
+				Entry: api.Entry{
+					Labels: model.LabelSet{"job": "app"},
+					Entry:  logproto.Entry{Timestamp: time.Now(), Line: line},
+				},
+			}
+		}
+		close(in)
+	}()
+	var lines []string
+	for e := range res {
+		lines = append(lines, e.Line)
+	}
+	require.Equal(t, []string{"start 1\n  continued", "start 2"}, lines)
+	require.Contains(t, out.String(), "[inspect: multiline stage]:\n\tlabels: {job=\"app\"}\n")
+	require.Contains(t, out.String(), "\tline: start 1\n  continued\n")
+
+	// the processors keep printing their changes.
+	s, err = New(util_log.Logger, nil, StageTypeRegex, map[string]interface{}{"expression": "^(?P<word>\\w+)"}, nil)
+	require.NoError(t, err)
+	_, ok = s.(*stageProcessor)
+	require.True(t, ok)
+}
@@ -0,0 +1,165 @@
+package stages
+
+import (
+	"math"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ErrLimitStageEmptyConfig   = "limit stage config must contain a `rate`"
+	ErrLimitStageInvalidRate   = "limit stage rate must be greater than 0, got %v"
+	ErrLimitStageInvalidBurst  = "limit stage burst must be at least 1, got %v"
+	ErrLimitStageInvalidMaxKey = "limit stage max_streams must be at least 1, got %v"
+
+	defaultLimitMaxStreams = 10000
+)
+
+var (
+	defaultLimitReason = "limit_stage"
+)
+
+// LimitConfig contains the configuration for a limitStage
+type LimitConfig struct {
+	DropReason  *string  `mapstructure:"drop_counter_reason"`
+	Rate        *float64 `mapstructure:"rate"`
+	Burst       *int     `mapstructure:"burst"`
+	ByLabelName string   `mapstructure:"by_label_name"`
+	MaxStreams  *int     `mapstructure:"max_streams"`
+}
+
+// validateLimitConfig validates the LimitConfig for the limitStage
+func validateLimitConfig(cfg *LimitConfig) error {
+	if cfg == nil || cfg.Rate == nil {
+		return errors.New(ErrLimitStageEmptyConfig)
+	}
+	if *cfg.Rate <= 0 {
+		return errors.Errorf(ErrLimitStageInvalidRate, *cfg.Rate)
+	}
+	if cfg.DropReason == nil || *cfg.DropReason == "" {
+		cfg.DropReason = &defaultLimitReason
+	}
+	if cfg.Burst == nil {
+		// Allow at least one line through, even when less than a line per second is kept.
+		burst := int(math.Max(1, math.Ceil(*cfg.Rate)))
+		cfg.Burst = &burst
+	}
+	if *cfg.Burst < 1 {
+		return errors.Errorf(ErrLimitStageInvalidBurst, *cfg.Burst)
+	}
+	if cfg.MaxStreams == nil {
+		maxStreams := defaultLimitMaxStreams
+		cfg.MaxStreams = &maxStreams
+	}
+	if *cfg.MaxStreams < 1 {
+		return errors.Errorf(ErrLimitStageInvalidMaxKey, *cfg.MaxStreams)
+	}
+	return nil
+}
+
+// newLimitStage creates a limitStage from config
+func newLimitStage(logger log.Logger, config interface{}, registerer prometheus.Registerer) (Stage, error) {
+	cfg := &LimitConfig{}
+	err := mapstructure.WeakDecode(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = validateLimitConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &limitStage{
+		logger:    log.With(logger, "component", "stage", "type", "limit"),
+		cfg:       cfg,
+		dropCount: getDropCountMetric(registerer),
+		limiters:  map[string]*streamLimiter{},
+		now:       time.Now,
+	}, nil
+}
+
+// limitStage drops the log lines of a stream, or of the entries sharing the value of a label,
+// above a rate. Every stream, or label value, has its own limit so that a noisy one doesn't
+// affect the others.
+type limitStage struct {
+	logger    log.Logger
+	cfg       *LimitConfig
+	dropCount *prometheus.CounterVec
+	limiters  map[string]*streamLimiter
+	now       func() time.Time
+}
+
+type streamLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+// Run implements Stage
+func (m *limitStage) Run(in chan Entry) chan Entry {
+	out := make(chan Entry)
+	go func() {
+		defer close(out)
+		for e := range in {
+			if m.allow(e) {
+				out <- e
+				continue
+			}
+			m.dropCount.WithLabelValues(*m.cfg.DropReason).Inc()
+			e.Ack.Done(nil)
+		}
+	}()
+	return out
+}
+
+func (m *limitStage) allow(e Entry) bool {
+	now := m.now()
+	key := m.key(e)
+	l, ok := m.limiters[key]
+	if !ok {
+		if len(m.limiters) >= *m.cfg.MaxStreams {
+			m.forgetLimiters(now)
+		}
+		l = &streamLimiter{Limiter: rate.NewLimiter(rate.Limit(*m.cfg.Rate), *m.cfg.Burst)}
+		m.limiters[key] = l
+	}
+	l.lastSeen = now
+	return l.AllowN(now, 1)
+}
+
+// key returns the value of the by_label_name label, or the stream when the entry doesn't have it.
+func (m *limitStage) key(e Entry) string {
+	if m.cfg.ByLabelName != "" {
+		if v, ok := e.Labels[model.LabelName(m.cfg.ByLabelName)]; ok {
+			return string(v)
+		}
+	}
+	return e.Labels.String()
+}
+
+// forgetLimiters forgets the limiters which had the time to refill their burst, forgetting them
+// doesn't change the limits. If there are still too many, all the limiters are forgotten.
+func (m *limitStage) forgetLimiters(now time.Time) {
+	refill := time.Duration(float64(*m.cfg.Burst) / *m.cfg.Rate * float64(time.Second))
+	for key, l := range m.limiters {
+		if now.Sub(l.lastSeen) >= refill {
+			delete(m.limiters, key)
+		}
+	}
+	if len(m.limiters) >= *m.cfg.MaxStreams {
+		if Debug {
+			level.Debug(m.logger).Log("msg", "too many streams are rate limited, resetting the limits", "max_streams", *m.cfg.MaxStreams)
+		}
+		m.limiters = map[string]*streamLimiter{}
+	}
+}
+
+// Name implements Stage
+func (m *limitStage) Name() string {
+	return StageTypeLimit
+}
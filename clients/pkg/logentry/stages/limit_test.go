@@ -0,0 +1,128 @@
+package stages
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testLimitYaml = `
+pipeline_stages:
+- limit:
+    rate: 0.1
+    burst: 2
+    by_label_name: pod
+    drop_counter_reason: noisy_pod
+`
+
+// TestLimitPipeline is used to verify we properly parse the yaml config and create a working pipeline
+func TestLimitPipeline(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	plName := "test_pipeline"
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testLimitYaml), &plName, registry)
+	require.NoError(t, err)
+
+	var entries []Entry
+	for i := 0; i < 5; i++ {
+		entries = append(entries,
+			newEntry(nil, model.LabelSet{"pod": "noisy", "stream": "stdout"}, "line", time.Now()),
+			newEntry(nil, model.LabelSet{"pod": "noisy", "stream": "stderr"}, "line", time.Now()),
+		)
+	}
+	entries = append(entries, newEntry(nil, model.LabelSet{"pod": "quiet"}, "line", time.Now()))
+	out := processEntries(pl, entries...)
+
+	// The streams of the noisy pod share their limit, the quiet pod is not affected.
+	require.Len(t, out, 3)
+	require.Equal(t, model.LabelValue("quiet"), out[2].Labels["pod"])
+	require.Equal(t, float64(8), testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues("noisy_pod")))
+}
+
+func Test_limitStage(t *testing.T) {
+	r, burst := 2.0, 3
+	s, err := newLimitStage(util_log.Logger, &LimitConfig{Rate: &r, Burst: &burst}, prometheus.NewRegistry())
+	require.NoError(t, err)
+	now := time.Unix(0, 0)
+	st := s.(*limitStage)
+	st.now = func() time.Time { return now }
+
+	a := newEntry(nil, model.LabelSet{"app": "a"}, "line", now)
+	b := newEntry(nil, model.LabelSet{"app": "b"}, "line", now)
+	kept := func(e Entry, n int) int {
+		var k int
+		for i := 0; i < n; i++ {
+			if st.allow(e) {
+				k++
+			}
+		}
+		return k
+	}
+
+	// The burst is allowed per stream.
+	require.Equal(t, 3, kept(a, 10))
+	require.Equal(t, 3, kept(b, 10))
+	// Then the rate.
+	now = now.Add(time.Second)
+	require.Equal(t, 2, kept(a, 10))
+}
+
+func Test_limitStage_MaxStreams(t *testing.T) {
+	r, burst, maxStreams := 1.0, 1, 2
+	s, err := newLimitStage(util_log.Logger, &LimitConfig{Rate: &r, Burst: &burst, MaxStreams: &maxStreams}, prometheus.NewRegistry())
+	require.NoError(t, err)
+	now := time.Unix(0, 0)
+	st := s.(*limitStage)
+	st.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		require.True(t, st.allow(newEntry(nil, model.LabelSet{"app": model.LabelValue(fmt.Sprint(i))}, "line", now)))
+	}
+	now = now.Add(time.Second / 2)
+	require.False(t, st.allow(newEntry(nil, model.LabelSet{"app": "1"}, "line", now)))
+
+	// The limiters which refilled their burst are forgotten first.
+	now = now.Add(time.Second / 2)
+	require.True(t, st.allow(newEntry(nil, model.LabelSet{"app": "2"}, "line", now)))
+	require.Len(t, st.limiters, 2)
+	require.NotContains(t, st.limiters, model.LabelSet{"app": "0"}.String())
+
+	// Then all of them when none refilled.
+	require.True(t, st.allow(newEntry(nil, model.LabelSet{"app": "3"}, "line", now)))
+	require.Len(t, st.limiters, 1)
+}
+
+func Test_validateLimitConfig(t *testing.T) {
+	r, zero := 0.5, 0
+	neg := -1.0
+	for _, tt := range []struct {
+		name string
+		cfg  *LimitConfig
+		err  error
+	}{
+		{"empty", &LimitConfig{}, errors.New(ErrLimitStageEmptyConfig)},
+		{"invalid rate", &LimitConfig{Rate: &neg}, fmt.Errorf(ErrLimitStageInvalidRate, neg)},
+		{"invalid burst", &LimitConfig{Rate: &r, Burst: &zero}, fmt.Errorf(ErrLimitStageInvalidBurst, zero)},
+		{"invalid max streams", &LimitConfig{Rate: &r, MaxStreams: &zero}, fmt.Errorf(ErrLimitStageInvalidMaxKey, zero)},
+		{"valid", &LimitConfig{Rate: &r}, nil},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLimitConfig(tt.cfg)
+			if tt.err != nil {
+				require.EqualError(t, err, tt.err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 1, *tt.cfg.Burst)
+			require.Equal(t, defaultLimitMaxStreams, *tt.cfg.MaxStreams)
+			require.Equal(t, defaultLimitReason, *tt.cfg.DropReason)
+		})
+	}
+}
@@ -185,6 +185,7 @@ func (m *matcherStage) runDrop(in chan Entry) chan Entry {
 				continue
 			}
 			m.dropCount.WithLabelValues(m.dropReason).Inc()
+			e.Ack.Done(nil)
 		}
 	}()
 	return out
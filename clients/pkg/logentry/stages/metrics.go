@@ -27,6 +27,7 @@ const (
 	ErrMetricsStageInvalidType = "invalid metric type '%s', metric type must be one of 'counter', 'gauge', or 'histogram'"
 	ErrInvalidIdleDur          = "max_idle_duration could not be parsed as a time.Duration: '%s'"
 	ErrSubSecIdleDur           = "max_idle_duration less than 1s not allowed"
+	ErrInvalidAllowlistLabel   = "invalid label_allowlist label name '%s'"
 )
 
 // MetricConfig is a single metrics configuration.
@@ -37,7 +38,9 @@ type MetricConfig struct {
 	Prefix       string  `mapstructure:"prefix"`
 	IdleDuration *string `mapstructure:"max_idle_duration"`
 	maxIdleSec   int64
-	Config       interface{} `mapstructure:"config"`
+	// LabelAllowlist restricts the labels of the metric series to the listed ones when set.
+	LabelAllowlist []string    `mapstructure:"label_allowlist"`
+	Config         interface{} `mapstructure:"config"`
 }
 
 // MetricsConfig is a set of configured metrics.
@@ -80,6 +83,12 @@ func validateMetricsConfig(cfg MetricsConfig) error {
 			cp.maxIdleSec = int64(5 * time.Minute.Seconds())
 			cfg[name] = cp
 		}
+
+		for _, l := range config.LabelAllowlist {
+			if !model.LabelName(l).IsValid() {
+				return errors.Errorf(ErrInvalidAllowlistLabel, l)
+			}
+		}
 	}
 	return nil
 }
@@ -96,6 +105,7 @@ func newMetricStage(logger log.Logger, config interface{}, registry prometheus.R
 		return nil, err
 	}
 	metrics := map[string]prometheus.Collector{}
+	allowlists := map[string]map[model.LabelName]struct{}{}
 	for name, cfg := range *cfgs {
 		var collector prometheus.Collector
 
@@ -127,24 +137,42 @@ func newMetricStage(logger log.Logger, config interface{}, registry prometheus.R
 			registry.MustRegister(collector)
 			metrics[name] = collector
 		}
+		if cfg.LabelAllowlist != nil {
+			allowlist := map[model.LabelName]struct{}{}
+			for _, l := range cfg.LabelAllowlist {
+				allowlist[model.LabelName(l)] = struct{}{}
+			}
+			allowlists[name] = allowlist
+		}
 	}
 	return toStage(&metricStage{
-		logger:  logger,
-		cfg:     *cfgs,
-		metrics: metrics,
+		logger:     logger,
+		cfg:        *cfgs,
+		metrics:    metrics,
+		allowlists: allowlists,
 	}), nil
 }
 
 // metricStage creates and updates prometheus metrics based on extracted pipeline data
 type metricStage struct {
-	logger  log.Logger
-	cfg     MetricsConfig
-	metrics map[string]prometheus.Collector
+	logger     log.Logger
+	cfg        MetricsConfig
+	metrics    map[string]prometheus.Collector
+	allowlists map[string]map[model.LabelName]struct{}
 }
 
 // Process implements Stage
-func (m *metricStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+func (m *metricStage) Process(entryLabels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
 	for name, collector := range m.metrics {
+		labels := entryLabels
+		if allowlist, ok := m.allowlists[name]; ok {
+			labels = make(model.LabelSet, len(allowlist))
+			for l, v := range entryLabels {
+				if _, ok := allowlist[l]; ok {
+					labels[l] = v
+				}
+			}
+		}
 		// There is a special case for counters where we count even if there is no match in the extracted map.
 		if c, ok := collector.(*metric.Counters); ok {
 			if c != nil && c.Cfg.MatchAll != nil && *c.Cfg.MatchAll {
@@ -161,6 +161,49 @@ promtail_custom_longitude{test="app",vehicle="1"} -10.1234
 	}
 }
 
+func TestMetricsLabelAllowlistAndBuckets(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	testConfig := `
+pipeline_stages:
+- regex:
+    expression: 'user=(?P<user>\w+) duration=(?P<duration>\d+)'
+- labels:
+    user:
+- metrics:
+    request_duration:
+        type: Histogram
+        description: "request duration"
+        source: duration
+        label_allowlist: [app]
+        config:
+          linear_buckets:
+            start: 10
+            width: 10
+            count: 2
+`
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testConfig), nil, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-pl.Run(withInboundEntries(
+		newEntry(nil, model.LabelSet{"app": "api", "pod": "a"}, `user=alice duration=5`, time.Now()),
+		newEntry(nil, model.LabelSet{"app": "api", "pod": "b"}, `user=bob duration=15`, time.Now()),
+	))
+	if err := testutil.GatherAndCompare(registry,
+		strings.NewReader(`
+# HELP promtail_custom_request_duration request duration
+# TYPE promtail_custom_request_duration histogram
+promtail_custom_request_duration_bucket{app="api",le="10"} 1
+promtail_custom_request_duration_bucket{app="api",le="20"} 2
+promtail_custom_request_duration_bucket{app="api",le="+Inf"} 2
+promtail_custom_request_duration_sum{app="api"} 20
+promtail_custom_request_duration_count{app="api"} 2
+`)); err != nil {
+		t.Fatalf("mismatch metrics: %v", err)
+	}
+}
+
 func TestPipelineWithMissingKey_Metrics(t *testing.T) {
 	var buf bytes.Buffer
 	w := log.NewSyncWriter(&buf)
@@ -258,6 +301,15 @@ func TestValidateMetricsConfig(t *testing.T) {
 			},
 			errors.Errorf(ErrInvalidIdleDur, `time: unknown unit "f" in duration "10f"`),
 		},
+		"invalid label allowlist": {
+			MetricsConfig{
+				"metric1": MetricConfig{
+					MetricType:     "Counter",
+					LabelAllowlist: []string{"app", "0pod"},
+				},
+			},
+			errors.Errorf(ErrInvalidAllowlistLabel, "0pod"),
+		},
 		"valid": {
 			MetricsConfig{
 				"metric1": MetricConfig{
@@ -78,6 +78,7 @@ type multilineState struct {
 	buffer         *bytes.Buffer // The lines of the current multiline block.
 	startLineEntry Entry         // The entry of the start line of a multiline block.
 	currentLines   uint64        // The number of lines of the current multiline block.
+	acks           []api.AckFunc // The acknowledgements of the lines of the current multiline block.
 }
 
 // newMulitlineStage creates a MulitlineStage from config
@@ -189,6 +190,7 @@ func (m *multilineStage) runMultiline(in chan Entry, out chan Entry, wg *sync.Wa
 			}
 			state.buffer.WriteString(e.Line)
 			state.currentLines++
+			state.acks = append(state.acks, e.Ack)
 
 			if state.currentLines == *m.cfg.MaxLines {
 				m.flush(out, state)
@@ -217,10 +219,12 @@ func (m *multilineStage) flush(out chan Entry, s *multilineState) {
 				Timestamp: s.startLineEntry.Entry.Entry.Timestamp,
 				Line:      s.buffer.String(),
 			},
+			Ack: api.JoinAcks(s.acks),
 		},
 	}
 	s.buffer.Reset()
 	s.currentLines = 0
+	s.acks = s.acks[:0]
 
 	out <- collapsed
 }
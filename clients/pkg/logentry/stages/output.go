@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/mitchellh/mapstructure"
 	"github.com/prometheus/common/model"
 )
@@ -15,11 +16,24 @@ import (
 const (
 	ErrEmptyOutputStageConfig = "output stage config cannot be empty"
 	ErrOutputSourceRequired   = "output source value is required if output is specified"
+	ErrOutputFieldsRequired   = "output fields are required in structured mode"
+	ErrOutputInvalidMode      = "output mode must be empty or structured"
 )
 
+// OutputModeStructured packs extracted fields into a JSON line.
+const OutputModeStructured = "structured"
+
+// structuredJSON sorts the keys of the JSON objects so the lines of the structured mode are canonical.
+var structuredJSON = jsoniter.Config{SortMapKeys: true, EscapeHTML: false}.Froze()
+
 // OutputConfig configures output value extraction
 type OutputConfig struct {
 	Source string `mapstructure:"source"`
+
+	// Mode is empty to use the source value as the line, or structured to pack the fields into a JSON line.
+	Mode string `mapstructure:"mode"`
+	// Fields maps the keys of the JSON line to the names in the extracted data, the key is used when the name is empty.
+	Fields map[string]*string `mapstructure:"fields"`
 }
 
 // validateOutput validates the outputStage config
@@ -27,8 +41,24 @@ func validateOutputConfig(cfg *OutputConfig) error {
 	if cfg == nil {
 		return errors.New(ErrEmptyOutputStageConfig)
 	}
-	if cfg.Source == "" {
-		return errors.New(ErrOutputSourceRequired)
+	switch cfg.Mode {
+	case "":
+		if cfg.Source == "" {
+			return errors.New(ErrOutputSourceRequired)
+		}
+	case OutputModeStructured:
+		if len(cfg.Fields) == 0 {
+			return errors.New(ErrOutputFieldsRequired)
+		}
+		for key, src := range cfg.Fields {
+			// If no field source was specified, use the key name
+			if src == nil || *src == "" {
+				k := key
+				cfg.Fields[key] = &k
+			}
+		}
+	default:
+		return errors.New(ErrOutputInvalidMode)
 	}
 	return nil
 }
@@ -61,6 +91,10 @@ func (o *outputStage) Process(labels model.LabelSet, extracted map[string]interf
 	if o.cfgs == nil {
 		return
 	}
+	if o.cfgs.Mode == OutputModeStructured {
+		o.processStructured(extracted, entry)
+		return
+	}
 	if v, ok := extracted[o.cfgs.Source]; ok {
 		s, err := getString(v)
 		if err != nil {
@@ -77,6 +111,24 @@ func (o *outputStage) Process(labels model.LabelSet, extracted map[string]interf
 	}
 }
 
+// processStructured sets the entry to a JSON object of the fields found in the extracted data.
+func (o *outputStage) processStructured(extracted map[string]interface{}, entry *string) {
+	fields := make(map[string]interface{}, len(o.cfgs.Fields))
+	for key, src := range o.cfgs.Fields {
+		if v, ok := extracted[*src]; ok && v != nil {
+			fields[key] = v
+		}
+	}
+	line, err := structuredJSON.MarshalToString(fields)
+	if err != nil {
+		if Debug {
+			level.Debug(o.logger).Log("msg", "extracted fields could not be converted to JSON", "err", err)
+		}
+		return
+	}
+	*entry = line
+}
+
 // Name implements Stage
 func (o *outputStage) Name() string {
 	return StageTypeOutput
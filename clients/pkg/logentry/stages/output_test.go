@@ -52,6 +52,41 @@ func TestPipeline_Output(t *testing.T) {
 	assert.Equal(t, "this is a log line", out.Line)
 }
 
+var testStructuredOutputYaml = `
+pipeline_stages:
+- json:
+    expressions:
+      level: level
+      message: msg
+      ts: time
+- logfmt:
+    mapping:
+      level: lvl
+      message:
+      ts:
+- output:
+    mode: structured
+    fields:
+      level:
+      message:
+      ts:
+`
+
+func TestPipeline_StructuredOutput(t *testing.T) {
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testStructuredOutputYaml), nil, prometheus.DefaultRegisterer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// lines of both formats are stored the same way.
+	out := processEntries(pl,
+		newEntry(nil, nil, `{"time":"2012-11-01T22:08:41Z","msg":"disk \"almost\" full","level":"warn","app":"loki"}`, time.Now()),
+		newEntry(nil, nil, `message="disk \"almost\" full" ts=2012-11-01T22:08:41Z lvl=warn`, time.Now()),
+	)
+	expected := `{"level":"warn","message":"disk \"almost\" full","ts":"2012-11-01T22:08:41Z"}`
+	assert.Equal(t, expected, out[0].Line)
+	assert.Equal(t, expected, out[1].Line)
+}
+
 func TestPipelineWithMissingKey_Output(t *testing.T) {
 	var buf bytes.Buffer
 	w := log.NewSyncWriter(&buf)
@@ -83,6 +118,19 @@ func TestOutputValidation(t *testing.T) {
 			},
 			err: errors.New(ErrOutputSourceRequired),
 		},
+		"missing fields": {
+			config: &OutputConfig{
+				Mode: OutputModeStructured,
+			},
+			err: errors.New(ErrOutputFieldsRequired),
+		},
+		"invalid mode": {
+			config: &OutputConfig{
+				Mode:   "xml",
+				Source: "out",
+			},
+			err: errors.New(ErrOutputInvalidMode),
+		},
 	}
 	for name, test := range tests {
 		test := test
@@ -117,6 +165,26 @@ func TestOutputStage_Process(t *testing.T) {
 			},
 			"outmessage",
 		},
+		"packs fields": {
+			OutputConfig{
+				Mode: OutputModeStructured,
+				Fields: map[string]*string{
+					"msg":     ptrFromString("message"),
+					"level":   nil,
+					"nested":  nil,
+					"missing": nil,
+					"null":    nil,
+				},
+			},
+			map[string]interface{}{
+				"message": "GET /<id> & more",
+				"level":   "info",
+				"nested":  map[string]interface{}{"z": 1.5, "a": []interface{}{"x", true}},
+				"null":    nil,
+				"other":   "dropped",
+			},
+			`{"level":"info","msg":"GET /<id> & more","nested":{"a":["x",true],"z":1.5}}`,
+		},
 	}
 	for name, test := range tests {
 		test := test
@@ -0,0 +1,98 @@
+package stages
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+// StageBenchmark measures a stage of a pipeline run against a corpus of entries.
+type StageBenchmark struct {
+	Name string
+	// Received and Sent count the entries received and sent by the stage over all iterations.
+	Received int
+	Sent     int
+	Duration time.Duration
+	// Allocs and AllocBytes count the allocations of the stage over all iterations.
+	Allocs     uint64
+	AllocBytes uint64
+}
+
+// Bench runs the entries through the stages of the pipeline, one stage after the other, iterations
+// times each, and measures the time taken and the memory allocated by every stage. It returns the
+// entries sent by the last stage.
+func (p *Pipeline) Bench(entries []Entry, iterations int) ([]StageBenchmark, []Entry) {
+	if iterations < 1 {
+		iterations = 1
+	}
+	current := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		e = cloneEntry(e)
+		// like Run, the extracted map starts with the labels of the entry.
+		for labelName, labelValue := range e.Labels {
+			e.Extracted[string(labelName)] = string(labelValue)
+		}
+		current = append(current, e)
+	}
+
+	res := make([]StageBenchmark, 0, len(p.stages))
+	for _, s := range p.stages {
+		bench := StageBenchmark{Name: s.Name()}
+		var sent []Entry
+		for i := 0; i < iterations; i++ {
+			// the stages change the entries, every iteration gets its own copy.
+			in := make([]Entry, 0, len(current))
+			for _, e := range current {
+				in = append(in, cloneEntry(e))
+			}
+			var out []Entry
+			out, duration, allocs, bytes := benchStage(s, in)
+			bench.Received += len(in)
+			bench.Sent += len(out)
+			bench.Duration += duration
+			bench.Allocs += allocs
+			bench.AllocBytes += bytes
+			sent = out
+		}
+		res = append(res, bench)
+		current = sent
+	}
+	return res, current
+}
+
+func benchStage(s Stage, entries []Entry) (out []Entry, duration time.Duration, allocs, bytes uint64) {
+	var before, after runtime.MemStats
+	out = make([]Entry, 0, len(entries))
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	in := make(chan Entry)
+	sent := s.Run(in)
+	go func() {
+		defer close(in)
+		for _, e := range entries {
+			in <- e
+		}
+	}()
+	for e := range sent {
+		out = append(out, e)
+	}
+
+	duration = time.Since(start)
+	runtime.ReadMemStats(&after)
+	return out, duration, after.Mallocs - before.Mallocs, after.TotalAlloc - before.TotalAlloc
+}
+
+func cloneEntry(e Entry) Entry {
+	extracted := make(map[string]interface{}, len(e.Extracted))
+	for k, v := range e.Extracted {
+		extracted[k] = v
+	}
+	e.Extracted = extracted
+	e.Labels = e.Labels.Clone()
+	if e.StructuredMetadata != nil {
+		e.StructuredMetadata = append([]logproto.LabelPair(nil), e.StructuredMetadata...)
+	}
+	return e
+}
@@ -0,0 +1,58 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testBenchYaml = `
+pipeline_stages:
+- json:
+    expressions:
+      app:
+      level:
+- drop:
+    source: level
+    value: debug
+- labels:
+    app:
+`
+
+func TestPipeline_Bench(t *testing.T) {
+	p, err := NewPipeline(util_log.Logger, loadConfig(testBenchYaml), nil, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	ts := time.Now()
+	entries := []Entry{
+		newEntry(map[string]interface{}{}, model.LabelSet{"job": "app"}, `{"app":"foo","level":"info"}`, ts),
+		newEntry(map[string]interface{}{}, model.LabelSet{"job": "app"}, `{"app":"bar","level":"debug"}`, ts),
+		newEntry(map[string]interface{}{}, model.LabelSet{"job": "app"}, `{"app":"baz","level":"error"}`, ts),
+	}
+	res, out := p.Bench(entries, 5)
+
+	require.Len(t, res, 3)
+	for i, name := range []string{StageTypeJSON, StageTypeDrop, StageTypeLabel} {
+		require.Equal(t, name, res[i].Name)
+		require.Greater(t, int64(res[i].Duration), int64(0))
+	}
+	require.Equal(t, 15, res[0].Received)
+	require.Equal(t, 15, res[0].Sent)
+	require.Equal(t, 15, res[1].Received)
+	require.Equal(t, 10, res[1].Sent)
+	require.Equal(t, 10, res[2].Sent)
+	require.NotZero(t, res[0].Allocs)
+
+	require.Len(t, out, 2)
+	require.Equal(t, model.LabelSet{"job": "app", "app": "foo"}, out[0].Labels)
+	require.Equal(t, "app", out[1].Extracted["job"])
+	require.Equal(t, model.LabelSet{"job": "app", "app": "baz"}, out[1].Labels)
+
+	// the entries aren't changed by the benchmark.
+	require.Equal(t, model.LabelSet{"job": "app"}, entries[0].Labels)
+	require.Empty(t, entries[0].Extracted)
+}
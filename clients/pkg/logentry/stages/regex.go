@@ -19,12 +19,14 @@ const (
 	ErrCouldNotCompileRegex  = "could not compile regular expression"
 	ErrEmptyRegexStageConfig = "empty regex stage configuration"
 	ErrEmptyRegexStageSource = "empty source"
+	ErrRegexSourceAndLabel   = "source and source_label can't both be set"
 )
 
 // RegexConfig contains a regexStage configuration
 type RegexConfig struct {
-	Expression string  `mapstructure:"expression"`
-	Source     *string `mapstructure:"source"`
+	Expression  string  `mapstructure:"expression"`
+	Source      *string `mapstructure:"source"`
+	SourceLabel string  `mapstructure:"source_label"`
 }
 
 // validateRegexConfig validates the config and return a regex
@@ -41,6 +43,10 @@ func validateRegexConfig(c *RegexConfig) (*regexp.Regexp, error) {
 		return nil, errors.New(ErrEmptyRegexStageSource)
 	}
 
+	if c.Source != nil && c.SourceLabel != "" {
+		return nil, errors.New(ErrRegexSourceAndLabel)
+	}
+
 	expr, err := regexp.Compile(c.Expression)
 	if err != nil {
 		return nil, errors.Wrap(err, ErrCouldNotCompileRegex)
@@ -86,9 +92,22 @@ func parseRegexConfig(config interface{}) (*RegexConfig, error) {
 // Process implements Stage
 func (r *regexStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
 	// If a source key is provided, the regex stage should process it
-	// from the extracted map, otherwise should fallback to the entry
+	// from the extracted map, or from the labels with a source label,
+	// otherwise should fallback to the entry
 	input := entry
 
+	if r.cfg.SourceLabel != "" {
+		value, ok := labels[model.LabelName(r.cfg.SourceLabel)]
+		if !ok {
+			if Debug {
+				level.Debug(r.logger).Log("msg", "source label does not exist", "source_label", r.cfg.SourceLabel)
+			}
+			return
+		}
+		s := string(value)
+		input = &s
+	}
+
 	if r.cfg.Source != nil {
 		if _, ok := extracted[*r.cfg.Source]; !ok {
 			if Debug {
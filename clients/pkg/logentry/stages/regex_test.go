@@ -13,6 +13,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 )
 
@@ -222,6 +223,29 @@ func TestRegexConfig_validate(t *testing.T) {
 	}
 }
 
+func TestRegexParser_SourceLabel(t *testing.T) {
+	t.Parallel()
+	p, err := New(util_log.Logger, nil, StageTypeRegex, map[string]interface{}{
+		"expression":   "^/var/log/(?P<app>[^/]+)/",
+		"source_label": "filename",
+	}, nil)
+	require.NoError(t, err)
+
+	out := processEntries(p,
+		newEntry(nil, model.LabelSet{"filename": "/var/log/nginx/access.log"}, "line", time.Now()),
+		newEntry(nil, model.LabelSet{"job": "varlogs"}, "line", time.Now()),
+	)
+	assert.Equal(t, map[string]interface{}{"app": "nginx"}, out[0].Extracted)
+	assert.Equal(t, map[string]interface{}{}, out[1].Extracted)
+
+	_, err = New(util_log.Logger, nil, StageTypeRegex, map[string]interface{}{
+		"expression":   "^/var/log/(?P<app>[^/]+)/",
+		"source":       "path",
+		"source_label": "filename",
+	}, nil)
+	require.EqualError(t, err, ErrRegexSourceAndLabel)
+}
+
 var regexLogFixture = `11.11.11.11 - frank [25/Jan/2000:14:00:01 -0500] "GET /1986.js HTTP/1.1" 200 932 "-" "Mozilla/5.0 (Windows; U; Windows NT 5.1; de; rv:1.9.1.7) Gecko/20091221 Firefox/3.5.7 GTB6"`
 
 func TestRegexParser_Parse(t *testing.T) {
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
@@ -19,13 +21,24 @@ import (
 const (
 	ErrEmptyReplaceStageConfig = "empty replace stage configuration"
 	ErrEmptyReplaceStageSource = "empty source in replace stage"
+	ErrEmptyReplaceStageTarget = "empty target in replace stage"
+	ErrInvalidReplaceTemplate  = "invalid replace template"
+)
+
+var (
+	// referenceRegexp matches the capture group references of a replace value: $1, $name, ${1}, ${name} and $$.
+	referenceRegexp = regexp.MustCompile(`\$(\$|\{(\w+)\}|(\w+))`)
+	// actionRegexp matches the template actions of a replace value, where $ starts a template variable.
+	actionRegexp = regexp.MustCompile(`(?s)\{\{.*?\}\}`)
 )
 
 // ReplaceConfig contains a regexStage configuration
 type ReplaceConfig struct {
 	Expression string  `mapstructure:"expression"`
 	Source     *string `mapstructure:"source"`
+	Target     *string `mapstructure:"target"`
 	Replace    string  `mapstructure:"replace"`
+	Expand     bool    `mapstructure:"expand"`
 }
 
 // validateReplaceConfig validates the config and return a regex
@@ -42,6 +55,10 @@ func validateReplaceConfig(c *ReplaceConfig) (*regexp.Regexp, error) {
 		return nil, errors.New(ErrEmptyReplaceStageSource)
 	}
 
+	if c.Target != nil && *c.Target == "" {
+		return nil, errors.New(ErrEmptyReplaceStageTarget)
+	}
+
 	expr, err := regexp.Compile(c.Expression)
 	if err != nil {
 		return nil, errors.Wrap(err, ErrCouldNotCompileRegex)
@@ -53,6 +70,9 @@ func validateReplaceConfig(c *ReplaceConfig) (*regexp.Regexp, error) {
 type replaceStage struct {
 	cfg        *ReplaceConfig
 	expression *regexp.Regexp
+	template   *template.Template
+	// wholeMatch replaces the whole match instead of each capture group.
+	wholeMatch bool
 	logger     log.Logger
 }
 
@@ -66,14 +86,68 @@ func newReplaceStage(logger log.Logger, config interface{}) (Stage, error) {
 	if err != nil {
 		return nil, err
 	}
+	replace := cfg.Replace
+	if cfg.Expand {
+		replace = replaceReferences(replace)
+	}
+	templ, err := template.New("pipeline_template").Funcs(functionMap).Parse(replace)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrInvalidReplaceTemplate)
+	}
 
 	return toStage(&replaceStage{
 		cfg:        cfg,
 		expression: expression,
+		template:   templ,
+		wholeMatch: expression.NumSubexp() == 0 || (cfg.Expand && hasReferences(cfg.Replace)),
 		logger:     log.With(logger, "component", "stage", "type", "replace"),
 	}), nil
 }
 
+func hasReferences(replace string) bool {
+	found := false
+	mapText(replace, func(text string) string {
+		for _, ref := range referenceRegexp.FindAllString(text, -1) {
+			if ref != "$$" {
+				found = true
+			}
+		}
+		return text
+	})
+	return found
+}
+
+// replaceReferences rewrites the capture group references of the replace value into template actions,
+// so that $1 or ${name} are replaced by the value of the group in the current match.
+func replaceReferences(replace string) string {
+	return mapText(replace, func(text string) string {
+		return referenceRegexp.ReplaceAllStringFunc(text, func(ref string) string {
+			if ref == "$$" {
+				return "$"
+			}
+			name := ref[1:]
+			if name[0] == '{' {
+				name = name[1 : len(name)-1]
+			}
+			return fmt.Sprintf("{{ index . %q }}", "$"+name)
+		})
+	})
+}
+
+// mapText applies f to the text of the replace value outside of its template actions, which are
+// kept as is.
+func mapText(replace string, f func(string) string) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range actionRegexp.FindAllStringIndex(replace, -1) {
+		sb.WriteString(f(replace[last:loc[0]]))
+		sb.WriteString(replace[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	sb.WriteString(f(replace[last:]))
+	return sb.String()
+}
+
 // parseReplaceConfig processes an incoming configuration into a ReplaceConfig
 func parseReplaceConfig(config interface{}) (*ReplaceConfig, error) {
 	cfg := &ReplaceConfig{}
@@ -130,16 +204,7 @@ func (r *replaceStage) Process(labels model.LabelSet, extracted map[string]inter
 	// All extracted values will be available for templating
 	td := r.getTemplateData(extracted)
 
-	// Initialize the template with the "replace" string defined by user
-	templ, err := template.New("pipeline_template").Funcs(functionMap).Parse(r.cfg.Replace)
-	if err != nil {
-		if Debug {
-			level.Debug(r.logger).Log("msg", "template initialization error", "err", err)
-		}
-		return
-	}
-
-	result, capturedMap, err := r.getReplacedEntry(matchAllIndex, *input, td, templ)
+	result, capturedMap, err := r.getReplacedEntry(matchAllIndex, *input, td, r.template)
 	if err != nil {
 		if Debug {
 			level.Debug(r.logger).Log("msg", "failed to execute template on extracted value", "err", err)
@@ -147,7 +212,9 @@ func (r *replaceStage) Process(labels model.LabelSet, extracted map[string]inter
 		return
 	}
 
-	if r.cfg.Source != nil {
+	if r.cfg.Target != nil {
+		extracted[*r.cfg.Target] = result
+	} else if r.cfg.Source != nil {
 		extracted[*r.cfg.Source] = result
 	} else {
 		*entry = result
@@ -156,7 +223,9 @@ func (r *replaceStage) Process(labels model.LabelSet, extracted map[string]inter
 	// All the named captured group will be extracted
 	for i, name := range r.expression.SubexpNames() {
 		if i != 0 && name != "" {
-			if v, ok := capturedMap[match[i]]; ok {
+			if r.wholeMatch {
+				extracted[name] = match[i]
+			} else if v, ok := capturedMap[match[i]]; ok {
 				extracted[name] = v
 			}
 		}
@@ -178,11 +247,16 @@ func (r *replaceStage) getReplacedEntry(matchAllIndex [][]int, input string, td
 	// captured group. Here 0-19 is "11.11.11.11 - frank",  0-11 is "11.11.11.11" and
 	// 14-19 is "frank". So, we advance by 2 index to get the next match
 	for _, matchIndex := range matchAllIndex {
-		for i := 2; i < len(matchIndex); i += 2 {
-			if matchIndex[i] == -1 {
+		r.setReferences(td, input, matchIndex)
+		groups := matchIndex[2:]
+		if r.wholeMatch {
+			groups = matchIndex[:2]
+		}
+		for i := 0; i < len(groups); i += 2 {
+			if groups[i] == -1 {
 				continue
 			}
-			capturedString := input[matchIndex[i]:matchIndex[i+1]]
+			capturedString := input[groups[i]:groups[i+1]]
 			buf := &bytes.Buffer{}
 			td["Value"] = capturedString
 			err := templ.Execute(buf, td)
@@ -190,9 +264,9 @@ func (r *replaceStage) getReplacedEntry(matchAllIndex [][]int, input string, td
 				return "", nil, err
 			}
 			st := buf.String()
-			if previousInputEndIndex == 0 || previousInputEndIndex < matchIndex[i] {
-				result += input[previousInputEndIndex:matchIndex[i]] + st
-				previousInputEndIndex = matchIndex[i+1]
+			if previousInputEndIndex <= groups[i] {
+				result += input[previousInputEndIndex:groups[i]] + st
+				previousInputEndIndex = groups[i+1]
 			}
 			capturedMap[capturedString] = st
 		}
@@ -200,6 +274,21 @@ func (r *replaceStage) getReplacedEntry(matchAllIndex [][]int, input string, td
 	return result + input[previousInputEndIndex:], capturedMap, nil
 }
 
+// setReferences sets the values of the capture group references of the match in the template data.
+func (r *replaceStage) setReferences(td map[string]string, input string, matchIndex []int) {
+	names := r.expression.SubexpNames()
+	for i := 0; i < len(matchIndex); i += 2 {
+		var value string
+		if matchIndex[i] >= 0 {
+			value = input[matchIndex[i]:matchIndex[i+1]]
+		}
+		td["$"+strconv.Itoa(i/2)] = value
+		if names[i/2] != "" {
+			td["$"+names[i/2]] = value
+		}
+	}
+}
+
 func (r *replaceStage) getTemplateData(extracted map[string]interface{}) map[string]string {
 	td := make(map[string]string)
 	for k, v := range extracted {
@@ -66,6 +66,63 @@ pipeline_stages:
       replace: ''
 `
 
+var testReplaceYamlWithoutCaptureGroups = `
+---
+pipeline_stages:
+  -
+    replace:
+      expression: '[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|\b\d+\b'
+      replace: '<{{ if contains "-" .Value }}uuid{{ else }}num{{ end }}>'
+`
+
+var testReplaceYamlWithReferences = `
+---
+pipeline_stages:
+  -
+    replace:
+      expression: '(?P<key>\w+)=(\d+)ms'
+      replace: '${key}_seconds=$2$$'
+      expand: true
+`
+
+var testReplaceYamlWithoutExpand = `
+---
+pipeline_stages:
+  -
+    replace:
+      expression: 'password=(\S+)'
+      replace: '$secret'
+`
+
+var testReplaceYamlWithTemplateVariables = `
+---
+pipeline_stages:
+  -
+    replace:
+      expression: '(\w+)=(\d+)ms'
+      replace: '$1={{ $n := index . "$2" }}{{ $n }}s'
+      expand: true
+  -
+    replace:
+      expression: '(wait)'
+      replace: '{{ $v := .Value }}{{ $v | ToUpper }}'
+`
+
+var testReplaceYamlWithTarget = `
+---
+pipeline_stages:
+  -
+    json:
+      expressions:
+        msg:
+  -
+    replace:
+      expression: '\d+'
+      source: msg
+      target: msg_pattern
+      replace: '<num>'
+`
+
 var testReplaceLogLine = `11.11.11.11 - frank [25/Jan/2000:14:00:01 -0500] "GET /1986.js HTTP/1.1" 200 932 "-" "Mozilla/5.0 (Windows; U; Windows NT 5.1; de; rv:1.9.1.7) Gecko/20091221 Firefox/3.5.7 GTB6"`
 var testReplaceLogJSONLine = `{"time":"2019-01-01T01:00:00.000000001Z", "level": "info", "msg": "11.11.11.11 - \"POST /loki/api/push/ HTTP/1.1\" 200 932 \"-\" \"Mozilla/5.0 (Windows; U; Windows NT 5.1; de; rv:1.9.1.7) Gecko/20091221 Firefox/3.5.7 GTB6\""}`
 
@@ -135,6 +192,41 @@ func TestPipeline_Replace(t *testing.T) {
 			map[string]interface{}{},
 			`11.11.11.11 - FRANK [25/JAN/2000:14:00:01 -0500] "GET /1986.JS HTTP/1.1" HttpStatusOk 932 "-" "MOZILLA/5.0 (WINDOWS; U; WINDOWS NT 5.1; DE; RV:1.9.1.7) GECKO/20091221 FIREFOX/3.5.7 GTB6"`,
 		},
+		"successfully run a pipeline without capture groups": {
+			testReplaceYamlWithoutCaptureGroups,
+			`request 1b4e28ba-2fa1-11d2-883f-0016d3cca427 took 12 ms for user 42`,
+			map[string]interface{}{},
+			`request <uuid> took <num> ms for user <num>`,
+		},
+		"successfully run a pipeline with capture group references": {
+			testReplaceYamlWithReferences,
+			`latency=12ms wait=3ms`,
+			map[string]interface{}{
+				"key": "latency",
+			},
+			`latency_seconds=12$ wait_seconds=3$`,
+		},
+		"successfully run a pipeline with a literal $ without expand": {
+			testReplaceYamlWithoutExpand,
+			`user=frank password=hunter2`,
+			map[string]interface{}{},
+			`user=frank password=$secret`,
+		},
+		"successfully run a pipeline with template variables": {
+			testReplaceYamlWithTemplateVariables,
+			`latency=12ms wait=3ms`,
+			map[string]interface{}{},
+			`latency=12s WAIT=3s`,
+		},
+		"successfully run a pipeline with target": {
+			testReplaceYamlWithTarget,
+			`{"msg":"user 42 logged in 3 times"}`,
+			map[string]interface{}{
+				"msg":         "user 42 logged in 3 times",
+				"msg_pattern": "user <num> logged in <num> times",
+			},
+			`{"msg":"user 42 logged in 3 times"}`,
+		},
 		"successfully run a pipeline with empty replace value": {
 			testReplaceYamlWithEmptyReplace,
 			testReplaceLogLine,
@@ -225,6 +317,13 @@ func TestReplaceConfig_validate(t *testing.T) {
 			},
 			nil,
 		},
+		"empty target": {
+			map[string]interface{}{
+				"expression": "(?P<ts>[0-9]+).*",
+				"target":     "",
+			},
+			errors.New(ErrEmptyReplaceStageTarget),
+		},
 		"valid with source": {
 			map[string]interface{}{
 				"expression": "(?P<ts>[0-9]+).*",
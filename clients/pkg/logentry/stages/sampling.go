@@ -0,0 +1,178 @@
+package stages
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ErrSamplingStageEmptyConfig     = "sampling stage config must contain one of `rate` or `per_second`"
+	ErrSamplingStageInvalidConfig   = "sampling stage config error, `rate` and `per_second` cannot both be defined at the same time"
+	ErrSamplingStageInvalidRate     = "sampling stage rate must be between 0 and 1, got %v"
+	ErrSamplingStageInvalidPerSec   = "sampling stage per_second must be greater than 0, got %v"
+	ErrSamplingStageInvalidBurst    = "sampling stage burst must be at least 1, got %v"
+	ErrSamplingStageInvalidHashBy   = "sampling stage hash_by can only be used with `rate`"
+	ErrSamplingStageInvalidBurstCfg = "sampling stage burst can only be used with `per_second`"
+)
+
+var (
+	defaultSamplingReason = "sampling_stage"
+)
+
+// SamplingConfig contains the configuration for a samplingStage
+type SamplingConfig struct {
+	DropReason *string  `mapstructure:"drop_counter_reason"`
+	Rate       *float64 `mapstructure:"rate"`
+	PerSecond  *float64 `mapstructure:"per_second"`
+	Burst      *int     `mapstructure:"burst"`
+	HashBy     []string `mapstructure:"hash_by"`
+}
+
+// validateSamplingConfig validates the SamplingConfig for the samplingStage
+func validateSamplingConfig(cfg *SamplingConfig) error {
+	if cfg == nil || (cfg.Rate == nil && cfg.PerSecond == nil) {
+		return errors.New(ErrSamplingStageEmptyConfig)
+	}
+	if cfg.Rate != nil && cfg.PerSecond != nil {
+		return errors.New(ErrSamplingStageInvalidConfig)
+	}
+	if cfg.DropReason == nil || *cfg.DropReason == "" {
+		cfg.DropReason = &defaultSamplingReason
+	}
+	if cfg.Rate != nil {
+		if *cfg.Rate < 0 || *cfg.Rate > 1 {
+			return errors.Errorf(ErrSamplingStageInvalidRate, *cfg.Rate)
+		}
+		if cfg.Burst != nil {
+			return errors.New(ErrSamplingStageInvalidBurstCfg)
+		}
+		return nil
+	}
+	if *cfg.PerSecond <= 0 {
+		return errors.Errorf(ErrSamplingStageInvalidPerSec, *cfg.PerSecond)
+	}
+	if len(cfg.HashBy) > 0 {
+		return errors.New(ErrSamplingStageInvalidHashBy)
+	}
+	if cfg.Burst == nil {
+		// Allow at least one line through, even when less than a line per second is kept.
+		burst := int(math.Max(1, math.Ceil(*cfg.PerSecond)))
+		cfg.Burst = &burst
+	}
+	if *cfg.Burst < 1 {
+		return errors.Errorf(ErrSamplingStageInvalidBurst, *cfg.Burst)
+	}
+	return nil
+}
+
+// newSamplingStage creates a samplingStage from config
+func newSamplingStage(logger log.Logger, config interface{}, registerer prometheus.Registerer) (Stage, error) {
+	cfg := &SamplingConfig{}
+	err := mapstructure.WeakDecode(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = validateSamplingConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &samplingStage{
+		logger:    log.With(logger, "component", "stage", "type", "sampling"),
+		cfg:       cfg,
+		dropCount: getDropCountMetric(registerer),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if cfg.PerSecond != nil {
+		s.limiter = rate.NewLimiter(rate.Limit(*cfg.PerSecond), *cfg.Burst)
+	}
+	return s, nil
+}
+
+// samplingStage keeps a fraction, or a number per second, of the log lines and drops the others.
+type samplingStage struct {
+	logger    log.Logger
+	cfg       *SamplingConfig
+	dropCount *prometheus.CounterVec
+	rand      *rand.Rand
+	limiter   *rate.Limiter
+}
+
+// Run implements Stage
+func (m *samplingStage) Run(in chan Entry) chan Entry {
+	out := make(chan Entry)
+	go func() {
+		defer close(out)
+		for e := range in {
+			if m.shouldKeep(e) {
+				out <- e
+				continue
+			}
+			m.dropCount.WithLabelValues(*m.cfg.DropReason).Inc()
+			e.Ack.Done(nil)
+		}
+	}()
+	return out
+}
+
+func (m *samplingStage) shouldKeep(e Entry) bool {
+	if m.limiter != nil {
+		return m.limiter.Allow()
+	}
+	switch *m.cfg.Rate {
+	case 0:
+		return false
+	case 1:
+		return true
+	}
+	if key, ok := m.hashKey(e); ok {
+		// Map the hash onto [0, 1) so that every promtail makes the same decision for the same values.
+		return float64(xxhash.Sum64String(key))/math.Pow(2, 64) < *m.cfg.Rate
+	}
+	return m.rand.Float64() < *m.cfg.Rate
+}
+
+// hashKey builds the key to hash from the values of the hash_by labels, or extracted values.
+// It returns false if none of them are set on the entry, in which case the line is sampled randomly.
+func (m *samplingStage) hashKey(e Entry) (string, bool) {
+	if len(m.cfg.HashBy) == 0 {
+		return "", false
+	}
+	var (
+		key   []byte
+		found bool
+	)
+	for _, name := range m.cfg.HashBy {
+		if v, ok := e.Labels[model.LabelName(name)]; ok {
+			key = append(key, v...)
+			found = true
+		} else if v, ok := e.Extracted[name]; ok {
+			s, err := getString(v)
+			if err != nil {
+				if Debug {
+					level.Debug(m.logger).Log("msg", "failed to convert extracted value to string", "key", name, "err", err)
+				}
+				continue
+			}
+			key = append(key, s...)
+			found = true
+		}
+		key = append(key, '\xff')
+	}
+	return string(key), found
+}
+
+// Name implements Stage
+func (m *samplingStage) Name() string {
+	return StageTypeSampling
+}
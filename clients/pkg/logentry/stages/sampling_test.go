@@ -0,0 +1,183 @@
+package stages
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testSamplingYaml = `
+pipeline_stages:
+- json:
+    expressions:
+      level:
+      trace_id:
+- match:
+    selector: '{app="loki"}'
+    stages:
+    - sampling:
+        rate: 0.25
+        hash_by: [trace_id]
+- sampling:
+    per_second: 100
+    burst: 200
+    drop_counter_reason: too_chatty
+`
+
+// TestSamplingPipeline is used to verify we properly parse the yaml config and create a working pipeline
+func TestSamplingPipeline(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	plName := "test_pipeline"
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testSamplingYaml), &plName, registry)
+	require.NoError(t, err)
+
+	var entries []Entry
+	for i := 0; i < 100; i++ {
+		line := fmt.Sprintf(`{"level":"debug","trace_id":"%d"}`, i%10)
+		entries = append(entries,
+			newEntry(nil, model.LabelSet{"app": "loki"}, line, time.Now()),
+			newEntry(nil, model.LabelSet{"app": "other"}, line, time.Now()),
+		)
+	}
+	out := processEntries(pl, entries...)
+
+	// All the lines of a trace are either kept or dropped, the lines of the other app are all kept.
+	kept := map[string]int{}
+	for _, e := range out {
+		if e.Labels["app"] == "loki" {
+			kept[e.Extracted["trace_id"].(string)]++
+		}
+	}
+	require.NotEmpty(t, kept)
+	require.Less(t, len(kept), 10)
+	for _, n := range kept {
+		require.Equal(t, 10, n)
+	}
+	require.Len(t, out, 100+10*len(kept))
+	require.Equal(t, float64(200-len(out)), testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues(defaultSamplingReason)))
+}
+
+func Test_samplingStage_Rate(t *testing.T) {
+	for _, tt := range []struct {
+		rate     float64
+		min, max int
+	}{
+		{rate: 0, min: 0, max: 0},
+		{rate: 0.1, min: 700, max: 1300},
+		{rate: 0.5, min: 4500, max: 5500},
+		{rate: 1, min: 10000, max: 10000},
+	} {
+		tt := tt
+		t.Run(fmt.Sprint(tt.rate), func(t *testing.T) {
+			s, err := newSamplingStage(util_log.Logger, &SamplingConfig{Rate: &tt.rate}, prometheus.NewRegistry())
+			require.NoError(t, err)
+			entries := make([]Entry, 10000)
+			for i := range entries {
+				entries[i] = newEntry(nil, model.LabelSet{}, "line", time.Now())
+			}
+			out := processEntries(s, entries...)
+			require.GreaterOrEqual(t, len(out), tt.min)
+			require.LessOrEqual(t, len(out), tt.max)
+		})
+	}
+}
+
+func Test_samplingStage_PerSecond(t *testing.T) {
+	perSecond := 0.1
+	registry := prometheus.NewRegistry()
+	s, err := newSamplingStage(util_log.Logger, &SamplingConfig{PerSecond: &perSecond}, registry)
+	require.NoError(t, err)
+	entries := make([]Entry, 100)
+	for i := range entries {
+		entries[i] = newEntry(nil, model.LabelSet{}, "line", time.Now())
+	}
+
+	// Only the burst of a single line goes through.
+	out := processEntries(s, entries...)
+	require.Len(t, out, 1)
+	require.Equal(t, float64(99), testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues(defaultSamplingReason)))
+}
+
+func Test_samplingStage_HashByFallback(t *testing.T) {
+	rate := 0.5
+	s, err := newSamplingStage(util_log.Logger, &SamplingConfig{Rate: &rate, HashBy: []string{"trace_id"}}, prometheus.NewRegistry())
+	require.NoError(t, err)
+	entries := make([]Entry, 1000)
+	for i := range entries {
+		entries[i] = newEntry(nil, model.LabelSet{}, "line", time.Now())
+	}
+
+	// Lines without the hash_by values are sampled randomly rather than all kept or all dropped.
+	out := processEntries(s, entries...)
+	require.Greater(t, len(out), 0)
+	require.Less(t, len(out), 1000)
+}
+
+func Test_validateSamplingConfig(t *testing.T) {
+	var (
+		invalidRate  = 1.5
+		validRate    = 0.5
+		perSecond    = 10.0
+		negPerSecond = -1.0
+		zeroBurst    = 0
+	)
+	tests := []struct {
+		name    string
+		config  *SamplingConfig
+		wantErr error
+	}{
+		{
+			name:    "ErrEmpty",
+			config:  &SamplingConfig{},
+			wantErr: errors.New(ErrSamplingStageEmptyConfig),
+		},
+		{
+			name:    "Both rate and per_second",
+			config:  &SamplingConfig{Rate: &validRate, PerSecond: &perSecond},
+			wantErr: errors.New(ErrSamplingStageInvalidConfig),
+		},
+		{
+			name:    "Invalid rate",
+			config:  &SamplingConfig{Rate: &invalidRate},
+			wantErr: fmt.Errorf(ErrSamplingStageInvalidRate, invalidRate),
+		},
+		{
+			name:    "Invalid per_second",
+			config:  &SamplingConfig{PerSecond: &negPerSecond},
+			wantErr: fmt.Errorf(ErrSamplingStageInvalidPerSec, negPerSecond),
+		},
+		{
+			name:    "Invalid burst",
+			config:  &SamplingConfig{PerSecond: &perSecond, Burst: &zeroBurst},
+			wantErr: fmt.Errorf(ErrSamplingStageInvalidBurst, zeroBurst),
+		},
+		{
+			name:    "Burst with rate",
+			config:  &SamplingConfig{Rate: &validRate, Burst: &zeroBurst},
+			wantErr: errors.New(ErrSamplingStageInvalidBurstCfg),
+		},
+		{
+			name:    "hash_by with per_second",
+			config:  &SamplingConfig{PerSecond: &perSecond, HashBy: []string{"trace_id"}},
+			wantErr: errors.New(ErrSamplingStageInvalidHashBy),
+		},
+		{
+			name:   "Valid",
+			config: &SamplingConfig{Rate: &validRate, HashBy: []string{"trace_id"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSamplingConfig(tt.config); ((err != nil) && (err.Error() != tt.wantErr.Error())) || (err == nil && tt.wantErr != nil) {
+				t.Errorf("validateSamplingConfig() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package stages
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+const (
+	ErrScriptStageEmptyConfig = "script stage config must contain a script"
+	ErrScriptStageInvalid     = "script stage could not compile the script: %v"
+	ErrScriptStageTimeout     = "script stage timeout could not be parsed as a time.Duration: %v"
+
+	defaultScriptTimeout = 100 * time.Millisecond
+)
+
+var (
+	defaultScriptReason = "script_stage"
+)
+
+// ScriptConfig contains the configuration for a scriptStage
+type ScriptConfig struct {
+	Script     string  `mapstructure:"script"`
+	DropReason *string `mapstructure:"drop_counter_reason"`
+	Timeout    *string `mapstructure:"timeout"`
+	timeout    time.Duration
+}
+
+// validateScriptConfig validates the ScriptConfig and compiles its script.
+func validateScriptConfig(cfg *ScriptConfig) (*lua.FunctionProto, error) {
+	if cfg == nil || strings.TrimSpace(cfg.Script) == "" {
+		return nil, errors.New(ErrScriptStageEmptyConfig)
+	}
+	if cfg.DropReason == nil || *cfg.DropReason == "" {
+		cfg.DropReason = &defaultScriptReason
+	}
+	cfg.timeout = defaultScriptTimeout
+	if cfg.Timeout != nil {
+		timeout, err := time.ParseDuration(*cfg.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, errors.Errorf(ErrScriptStageTimeout, *cfg.Timeout)
+		}
+		cfg.timeout = timeout
+	}
+	chunk, err := parse.Parse(strings.NewReader(cfg.Script), StageTypeScript)
+	if err != nil {
+		return nil, errors.Errorf(ErrScriptStageInvalid, err)
+	}
+	proto, err := lua.Compile(chunk, StageTypeScript)
+	if err != nil {
+		return nil, errors.Errorf(ErrScriptStageInvalid, err)
+	}
+	return proto, nil
+}
+
+// newScriptStage creates a scriptStage from config
+func newScriptStage(logger log.Logger, config interface{}, registerer prometheus.Registerer) (Stage, error) {
+	cfg := &ScriptConfig{}
+	err := mapstructure.WeakDecode(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	proto, err := validateScriptConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &scriptStage{
+		logger:    log.With(logger, "component", "stage", "type", "script"),
+		cfg:       cfg,
+		proto:     proto,
+		dropCount: getDropCountMetric(registerer),
+	}, nil
+}
+
+// scriptStage runs a Lua script on every entry. The script reads the line, the labels
+// and the extracted map from the `line`, `labels` and `extracted` globals, it can
+// rewrite the line and the extracted map and drop the entry by calling `drop()`.
+// Each entry gets its own globals, and the script is interrupted after the timeout.
+type scriptStage struct {
+	logger    log.Logger
+	cfg       *ScriptConfig
+	proto     *lua.FunctionProto
+	dropCount *prometheus.CounterVec
+}
+
+// Run implements Stage
+func (m *scriptStage) Run(in chan Entry) chan Entry {
+	out := make(chan Entry)
+	go func() {
+		defer close(out)
+		// The state is only used by this goroutine.
+		L := newScriptState()
+		defer L.Close()
+		fn := L.NewFunctionFromProto(m.proto)
+		// The globals of each entry fall back to the libraries.
+		libs := L.NewTable()
+		libs.RawSetString("__index", L.G.Global)
+		for e := range in {
+			dropped, err := m.run(L, fn, libs, &e)
+			if err != nil {
+				if Debug {
+					level.Debug(m.logger).Log("msg", "failed to run the script, the entry is not modified", "err", err)
+				}
+				out <- e
+				continue
+			}
+			if !dropped {
+				out <- e
+				continue
+			}
+			m.dropCount.WithLabelValues(*m.cfg.DropReason).Inc()
+			e.Ack.Done(nil)
+		}
+	}()
+	return out
+}
+
+// run runs the script on the entry and updates its line and extracted map.
+// It returns true if the script dropped the entry.
+func (m *scriptStage) run(L *lua.LState, fn *lua.LFunction, libs *lua.LTable, e *Entry) (bool, error) {
+	// The script runs with new globals, so that the globals it sets are not kept between entries.
+	env := L.NewTable()
+	L.SetMetatable(env, libs)
+	env.RawSetString("_G", env)
+	fn.Env = env
+
+	var dropped bool
+	env.RawSetString("drop", L.NewFunction(func(*lua.LState) int {
+		dropped = true
+		return 0
+	}))
+	env.RawSetString("line", lua.LString(e.Line))
+	labels := L.NewTable()
+	for k, v := range e.Labels {
+		labels.RawSetString(string(k), lua.LString(v))
+	}
+	env.RawSetString("labels", labels)
+	extracted := L.NewTable()
+	for k, v := range e.Extracted {
+		if lv, ok := toLuaValue(v); ok {
+			extracted.RawSetString(k, lv)
+		}
+	}
+	env.RawSetString("extracted", extracted)
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.timeout)
+	defer cancel()
+	L.SetContext(ctx)
+	L.Push(fn)
+	err := L.PCall(0, 0, nil)
+	L.RemoveContext()
+	if err != nil {
+		return false, err
+	}
+
+	line, ok := env.RawGetString("line").(lua.LString)
+	if !ok {
+		return false, errors.Errorf("line must be a string, got a %s", env.RawGetString("line").Type())
+	}
+	result, ok := env.RawGetString("extracted").(*lua.LTable)
+	if !ok {
+		return false, errors.Errorf("extracted must be a table, got a %s", env.RawGetString("extracted").Type())
+	}
+	if e.Extracted == nil {
+		e.Extracted = map[string]interface{}{}
+	}
+	// Only the values which can be converted to Lua are removed when they are not set anymore.
+	for k, v := range e.Extracted {
+		if _, ok := toLuaValue(v); ok && result.RawGetString(k) == lua.LNil {
+			delete(e.Extracted, k)
+		}
+	}
+	result.ForEach(func(k, v lua.LValue) {
+		key, ok := k.(lua.LString)
+		if !ok {
+			return
+		}
+		switch v := v.(type) {
+		case lua.LString:
+			e.Extracted[string(key)] = string(v)
+		case lua.LNumber:
+			e.Extracted[string(key)] = fromLuaNumber(v, e.Extracted[string(key)])
+		case lua.LBool:
+			e.Extracted[string(key)] = bool(v)
+		}
+	})
+	e.Line = string(line)
+	return dropped, nil
+}
+
+// Name implements Stage
+func (m *scriptStage) Name() string {
+	return StageTypeScript
+}
+
+// newScriptState creates a Lua state with the base, string, table and math libraries only,
+// so that scripts can't access files or run commands.
+func newScriptState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.StringLibName, lua.OpenString},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.fn))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, fn := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module"} {
+		L.SetGlobal(fn, lua.LNil)
+	}
+	return L
+}
+
+// fromLuaNumber converts a Lua number to an int64 when it's integral, unless the value
+// it replaces was a float.
+func fromLuaNumber(n lua.LNumber, previous interface{}) interface{} {
+	f := float64(n)
+	switch previous.(type) {
+	case float64, float32:
+		return f
+	}
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return int64(f)
+	}
+	return f
+}
+
+// toLuaValue converts a value of the extracted map to a Lua value.
+func toLuaValue(v interface{}) (lua.LValue, bool) {
+	switch v := v.(type) {
+	case bool:
+		return lua.LBool(v), true
+	case string:
+		return lua.LString(v), true
+	case float64:
+		return lua.LNumber(v), true
+	case float32:
+		return lua.LNumber(v), true
+	case int:
+		return lua.LNumber(v), true
+	case int32:
+		return lua.LNumber(v), true
+	case int64:
+		return lua.LNumber(v), true
+	case uint:
+		return lua.LNumber(v), true
+	case uint32:
+		return lua.LNumber(v), true
+	case uint64:
+		return lua.LNumber(v), true
+	}
+	return lua.LNil, false
+}
@@ -0,0 +1,175 @@
+package stages
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testScriptYaml = `
+pipeline_stages:
+- json:
+    expressions:
+      level:
+      status:
+      user:
+- script:
+    script: |
+      if extracted.level == "debug" and string.find(line, "healthcheck", 1, true) then
+        drop()
+        return
+      end
+      if extracted.status >= 500 then
+        extracted.level = "error"
+      end
+      if extracted.user ~= nil then
+        line = string.gsub(line, extracted.user, "<redacted>")
+        extracted.user = nil
+      end
+- labels:
+    level:
+`
+
+// TestScriptPipeline is used to verify we properly parse the yaml config and create a working pipeline
+func TestScriptPipeline(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	plName := "test_pipeline"
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testScriptYaml), &plName, registry)
+	require.NoError(t, err)
+	out := processEntries(pl,
+		newEntry(nil, nil, `{"level":"debug","status":200,"msg":"GET /healthcheck"}`, time.Now()),
+		newEntry(nil, nil, `{"level":"info","status":503,"user":"jane","msg":"GET /api by jane"}`, time.Now()),
+		newEntry(nil, nil, `{"level":"info","status":200,"msg":"GET /api"}`, time.Now()),
+	)
+
+	require.Len(t, out, 2)
+	require.Equal(t, `{"level":"info","status":503,"user":"<redacted>","msg":"GET /api by <redacted>"}`, out[0].Line)
+	require.Equal(t, model.LabelValue("error"), out[0].Labels["level"])
+	require.NotContains(t, out[0].Extracted, "user")
+	require.Equal(t, model.LabelValue("info"), out[1].Labels["level"])
+	require.Equal(t, float64(1), testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues(defaultScriptReason)))
+}
+
+func Test_scriptStage(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		script        string
+		labels        model.LabelSet
+		extracted     map[string]interface{}
+		line          string
+		wantLine      string
+		wantExtracted map[string]interface{}
+		wantDropped   bool
+	}{
+		{
+			name:          "labels are readable",
+			script:        `line = labels.app .. ": " .. line`,
+			labels:        model.LabelSet{"app": "loki"},
+			extracted:     map[string]interface{}{},
+			line:          "msg",
+			wantLine:      "loki: msg",
+			wantExtracted: map[string]interface{}{},
+		},
+		{
+			name:          "extracted values are converted",
+			script:        `extracted.total = extracted.count * 2; extracted.ok = not extracted.failed`,
+			extracted:     map[string]interface{}{"count": 21, "failed": false, "object": map[string]interface{}{"a": "b"}},
+			line:          "msg",
+			wantLine:      "msg",
+			wantExtracted: map[string]interface{}{"count": int64(21), "failed": false, "total": int64(42), "ok": true, "object": map[string]interface{}{"a": "b"}},
+		},
+		{
+			name:          "decimal values are kept",
+			script:        `extracted.ratio = extracted.ratio * 2; extracted.half = extracted.ratio / 4`,
+			extracted:     map[string]interface{}{"ratio": 1.5},
+			line:          "msg",
+			wantLine:      "msg",
+			wantExtracted: map[string]interface{}{"ratio": float64(3), "half": 0.75},
+		},
+		{
+			name:          "scripts running too long are interrupted",
+			script:        `line = "changed"; while true do end`,
+			extracted:     map[string]interface{}{},
+			line:          "msg",
+			wantLine:      "msg",
+			wantExtracted: map[string]interface{}{},
+		},
+		{
+			name:          "drop",
+			script:        `if line == "noise" then drop() end`,
+			extracted:     map[string]interface{}{},
+			line:          "noise",
+			wantDropped:   true,
+			wantExtracted: map[string]interface{}{},
+		},
+		{
+			name:          "runtime errors leave the entry unchanged",
+			script:        `extracted.a = "b"; line = nil .. "x"`,
+			extracted:     map[string]interface{}{},
+			line:          "msg",
+			wantLine:      "msg",
+			wantExtracted: map[string]interface{}{},
+		},
+		{
+			name:          "files can't be read",
+			script:        `line = io.open("/etc/passwd"):read("*a")`,
+			extracted:     map[string]interface{}{},
+			line:          "msg",
+			wantLine:      "msg",
+			wantExtracted: map[string]interface{}{},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newScriptStage(util_log.Logger, map[string]interface{}{"script": tt.script, "timeout": "50ms"}, prometheus.NewRegistry())
+			require.NoError(t, err)
+			out := processEntries(s, newEntry(tt.extracted, tt.labels, tt.line, time.Now()))
+			if tt.wantDropped {
+				require.Len(t, out, 0)
+				return
+			}
+			require.Len(t, out, 1)
+			require.Equal(t, tt.wantLine, out[0].Line)
+			require.Equal(t, tt.wantExtracted, out[0].Extracted)
+		})
+	}
+}
+
+func Test_scriptStageGlobals(t *testing.T) {
+	s, err := newScriptStage(util_log.Logger, map[string]interface{}{"script": `
+count = (count or 0) + 1
+_G.seen = (_G.seen or 0) + 1
+line = line .. " " .. count .. " " .. seen`}, prometheus.NewRegistry())
+	require.NoError(t, err)
+	out := processEntries(s,
+		newEntry(map[string]interface{}{}, nil, "a", time.Now()),
+		newEntry(map[string]interface{}{}, nil, "b", time.Now()),
+	)
+	require.Len(t, out, 2)
+	require.Equal(t, "a 1 1", out[0].Line)
+	require.Equal(t, "b 1 1", out[1].Line)
+}
+
+func Test_validateScriptConfig(t *testing.T) {
+	_, err := validateScriptConfig(&ScriptConfig{})
+	require.EqualError(t, err, ErrScriptStageEmptyConfig)
+
+	_, err = validateScriptConfig(&ScriptConfig{Script: "if then"})
+	require.EqualError(t, err, fmt.Sprintf(ErrScriptStageInvalid, "script line:1(column:7) near 'then':   syntax error\n"))
+
+	cfg := &ScriptConfig{Script: `line = "x"`}
+	_, err = validateScriptConfig(cfg)
+	require.NoError(t, err)
+	require.Equal(t, defaultScriptReason, *cfg.DropReason)
+	require.Equal(t, defaultScriptTimeout, cfg.timeout)
+
+	timeout := "1h-"
+	_, err = validateScriptConfig(&ScriptConfig{Script: `line = "x"`, Timeout: &timeout})
+	require.EqualError(t, err, fmt.Sprintf(ErrScriptStageTimeout, timeout))
+}
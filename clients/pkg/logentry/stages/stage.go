@@ -15,26 +15,33 @@ import (
 )
 
 const (
-	StageTypeJSON         = "json"
-	StageTypeLogfmt       = "logfmt"
-	StageTypeRegex        = "regex"
-	StageTypeReplace      = "replace"
-	StageTypeMetric       = "metrics"
-	StageTypeLabel        = "labels"
-	StageTypeLabelDrop    = "labeldrop"
-	StageTypeTimestamp    = "timestamp"
-	StageTypeOutput       = "output"
-	StageTypeDocker       = "docker"
-	StageTypeCRI          = "cri"
-	StageTypeMatch        = "match"
-	StageTypeTemplate     = "template"
-	StageTypePipeline     = "pipeline"
-	StageTypeTenant       = "tenant"
-	StageTypeDrop         = "drop"
-	StageTypeMultiline    = "multiline"
-	StageTypePack         = "pack"
-	StageTypeLabelAllow   = "labelallow"
-	StageTypeStaticLabels = "static_labels"
+	StageTypeJSON               = "json"
+	StageTypeLogfmt             = "logfmt"
+	StageTypeRegex              = "regex"
+	StageTypeReplace            = "replace"
+	StageTypeMetric             = "metrics"
+	StageTypeLabel              = "labels"
+	StageTypeLabelDrop          = "labeldrop"
+	StageTypeTimestamp          = "timestamp"
+	StageTypeOutput             = "output"
+	StageTypeDocker             = "docker"
+	StageTypeCRI                = "cri"
+	StageTypeMatch              = "match"
+	StageTypeTemplate           = "template"
+	StageTypePipeline           = "pipeline"
+	StageTypeTenant             = "tenant"
+	StageTypeDrop               = "drop"
+	StageTypeMultiline          = "multiline"
+	StageTypePack               = "pack"
+	StageTypeLabelAllow         = "labelallow"
+	StageTypeStaticLabels       = "static_labels"
+	StageTypeSampling           = "sampling"
+	StageTypeScript             = "script"
+	StageTypeDecolorize         = "decolorize"
+	StageTypeLimit              = "limit"
+	StageTypeStructuredMetadata = "structured_metadata"
+	StageTypeXML                = "xml"
+	StageTypeDedup              = "dedup"
 )
 
 // Processor takes an existing set of labels, timestamp and log entry and returns either a possibly mutated
@@ -203,8 +210,50 @@ func New(logger log.Logger, jobName *string, stageType string,
 		if err != nil {
 			return nil, err
 		}
+	case StageTypeSampling:
+		s, err = newSamplingStage(logger, cfg, registerer)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeScript:
+		s, err = newScriptStage(logger, cfg, registerer)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeDecolorize:
+		s, err = newDecolorizeStage(cfg)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeLimit:
+		s, err = newLimitStage(logger, cfg, registerer)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeStructuredMetadata:
+		s, err = newStructuredMetadataStage(logger, cfg)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeXML:
+		s, err = newXMLStage(logger, cfg)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeDedup:
+		s, err = newDedupStage(logger, cfg, registerer)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, errors.Errorf("Unknown stage type: %s", stageType)
 	}
+	// the processors are inspected entry by entry, the other stages only when they send an entry.
+	if _, ok := s.(*stageProcessor); Inspect && !ok {
+		s = &inspectingStage{
+			Stage:     s,
+			inspector: newInspector(os.Stderr, runtime.GOOS == "windows"),
+		}
+	}
 	return s, nil
 }
@@ -0,0 +1,110 @@
+package stages
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+const (
+	ErrEmptyStructuredMetadataStageConfig = "structured_metadata stage config cannot be empty"
+	ErrInvalidStructuredMetadataName      = "invalid structured metadata name: %s"
+)
+
+// StructuredMetadataConfig is a set of structured metadata to be set from extracted data.
+// Like with the labels stage, the source defaults to the name of the metadata.
+type StructuredMetadataConfig map[string]*string
+
+// validateStructuredMetadataConfig validates the structured_metadata stage configuration
+func validateStructuredMetadataConfig(c StructuredMetadataConfig) error {
+	if len(c) == 0 {
+		return errors.New(ErrEmptyStructuredMetadataStageConfig)
+	}
+	for name, src := range c {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf(ErrInvalidStructuredMetadataName, name)
+		}
+		if src == nil || *src == "" {
+			n := name
+			c[name] = &n
+		}
+	}
+	return nil
+}
+
+// newStructuredMetadataStage creates a new structured_metadata stage
+func newStructuredMetadataStage(logger log.Logger, configs interface{}) (Stage, error) {
+	cfgs := &StructuredMetadataConfig{}
+	err := mapstructure.Decode(configs, cfgs)
+	if err != nil {
+		return nil, err
+	}
+	err = validateStructuredMetadataConfig(*cfgs)
+	if err != nil {
+		return nil, err
+	}
+	return &structuredMetadataStage{
+		cfgs:   *cfgs,
+		logger: logger,
+	}, nil
+}
+
+// structuredMetadataStage attaches extracted data to the entries as structured metadata.
+// Unlike labels, structured metadata is sent with each entry and doesn't create new streams,
+// which makes it fit for high cardinality values like trace IDs.
+type structuredMetadataStage struct {
+	cfgs   StructuredMetadataConfig
+	logger log.Logger
+}
+
+// Run implements Stage
+func (s *structuredMetadataStage) Run(in chan Entry) chan Entry {
+	return RunWith(in, func(e Entry) Entry {
+		s.process(&e)
+		return e
+	})
+}
+
+func (s *structuredMetadataStage) process(e *Entry) {
+	metadata := make(map[string]string, len(e.StructuredMetadata)+len(s.cfgs))
+	for _, p := range e.StructuredMetadata {
+		metadata[p.Name] = p.Value
+	}
+	for name, src := range s.cfgs {
+		v, ok := e.Extracted[*src]
+		if !ok {
+			continue
+		}
+		value, err := getString(v)
+		if err != nil {
+			if Debug {
+				level.Debug(s.logger).Log("msg", "failed to convert extracted value to string", "err", err, "type", reflect.TypeOf(v))
+			}
+			continue
+		}
+		metadata[name] = value
+	}
+	if len(metadata) == 0 {
+		return
+	}
+	// The pairs are rebuilt rather than updated in place, entries may share them.
+	pairs := make([]logproto.LabelPair, 0, len(metadata))
+	for name, value := range metadata {
+		pairs = append(pairs, logproto.LabelPair{Name: name, Value: value})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	e.StructuredMetadata = pairs
+}
+
+// Name implements Stage
+func (s *structuredMetadataStage) Name() string {
+	return StageTypeStructuredMetadata
+}
@@ -0,0 +1,70 @@
+package stages
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+var testStructuredMetadataYaml = `
+pipeline_stages:
+- json:
+    expressions:
+      level:
+      trace_id: traceID
+      pod_uid:
+- labels:
+    level:
+- structured_metadata:
+    trace_id:
+    pod: pod_uid
+`
+
+// TestStructuredMetadataPipeline is used to verify we properly parse the yaml config and create a working pipeline
+func TestStructuredMetadataPipeline(t *testing.T) {
+	plName := "test_pipeline"
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testStructuredMetadataYaml), &plName, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+	out := processEntries(pl,
+		newEntry(nil, model.LabelSet{"app": "loki"}, `{"level":"info","traceID":"7a1e5c","pod_uid":"87c6b7f8"}`, time.Now()),
+		newEntry(nil, model.LabelSet{"app": "loki"}, `{"level":"debug"}`, time.Now()),
+	)
+	require.Len(t, out, 2)
+	require.Equal(t, model.LabelSet{"app": "loki", "level": "info"}, out[0].Labels)
+	require.Equal(t, []logproto.LabelPair{{Name: "pod", Value: "87c6b7f8"}, {Name: "trace_id", Value: "7a1e5c"}}, out[0].StructuredMetadata)
+	require.Nil(t, out[1].StructuredMetadata)
+}
+
+func Test_structuredMetadataStage(t *testing.T) {
+	s, err := newStructuredMetadataStage(util_log.Logger, StructuredMetadataConfig{"trace_id": nil, "status": nil})
+	require.NoError(t, err)
+
+	in := newEntry(map[string]interface{}{"trace_id": "new", "status": 200, "ignored": []string{}}, nil, "line", time.Now())
+	in.StructuredMetadata = []logproto.LabelPair{{Name: "trace_id", Value: "old"}, {Name: "user", Value: "jane"}}
+	existing := in.StructuredMetadata
+	out := processEntries(s, in)
+	require.Len(t, out, 1)
+	require.Equal(t, []logproto.LabelPair{
+		{Name: "status", Value: "200"},
+		{Name: "trace_id", Value: "new"},
+		{Name: "user", Value: "jane"},
+	}, out[0].StructuredMetadata)
+	// The metadata of the incoming entry is left untouched.
+	require.Equal(t, []logproto.LabelPair{{Name: "trace_id", Value: "old"}, {Name: "user", Value: "jane"}}, existing)
+}
+
+func Test_validateStructuredMetadataConfig(t *testing.T) {
+	require.EqualError(t, validateStructuredMetadataConfig(nil), ErrEmptyStructuredMetadataStageConfig)
+	require.EqualError(t, validateStructuredMetadataConfig(StructuredMetadataConfig{"trace-id": nil}), fmt.Sprintf(ErrInvalidStructuredMetadataName, "trace-id"))
+
+	cfg := StructuredMetadataConfig{"trace_id": nil}
+	require.NoError(t, validateStructuredMetadataConfig(cfg))
+	require.Equal(t, "trace_id", *cfg["trace_id"])
+}
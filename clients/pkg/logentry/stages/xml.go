@@ -0,0 +1,370 @@
+package stages
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+// Config Errors
+const (
+	ErrXMLExpressionsRequired = "xml expression is required"
+	ErrCouldNotCompileXPath   = "could not compile xml expression %q: %s"
+	ErrEmptyXMLStageConfig    = "empty xml stage configuration"
+	ErrEmptyXMLStageSource    = "empty source"
+)
+
+// XMLConfig represents a XML Stage configuration
+type XMLConfig struct {
+	Expressions map[string]string `mapstructure:"expressions"`
+	Source      *string           `mapstructure:"source"`
+}
+
+// validateXMLConfig validates a xml config and returns the compiled expressions.
+func validateXMLConfig(c *XMLConfig) (map[string]*xpath, error) {
+	if c == nil {
+		return nil, errors.New(ErrEmptyXMLStageConfig)
+	}
+
+	if len(c.Expressions) == 0 {
+		return nil, errors.New(ErrXMLExpressionsRequired)
+	}
+
+	if c.Source != nil && *c.Source == "" {
+		return nil, errors.New(ErrEmptyXMLStageSource)
+	}
+
+	expressions := map[string]*xpath{}
+	for n, e := range c.Expressions {
+		// If there is no expression, use the first element with the name anywhere in the document.
+		if e == "" {
+			e = "//" + n
+		}
+		x, err := compileXPath(e)
+		if err != nil {
+			return nil, fmt.Errorf(ErrCouldNotCompileXPath, e, err)
+		}
+		expressions[n] = x
+	}
+	return expressions, nil
+}
+
+// xmlStage sets extracted data using XPath expressions
+type xmlStage struct {
+	cfg         *XMLConfig
+	expressions map[string]*xpath
+	logger      log.Logger
+}
+
+// newXMLStage creates a new xml pipeline stage from a config.
+func newXMLStage(logger log.Logger, config interface{}) (Stage, error) {
+	cfg := &XMLConfig{}
+	err := mapstructure.Decode(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	expressions, err := validateXMLConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return toStage(&xmlStage{
+		cfg:         cfg,
+		expressions: expressions,
+		logger:      log.With(logger, "component", "stage", "type", "xml"),
+	}), nil
+}
+
+// Process implements Stage
+func (x *xmlStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+	// If a source key is provided, the xml stage should process it
+	// from the extracted map, otherwise should fallback to the entry
+	input := entry
+
+	if x.cfg.Source != nil {
+		if _, ok := extracted[*x.cfg.Source]; !ok {
+			if Debug {
+				level.Debug(x.logger).Log("msg", "source does not exist in the set of extracted values", "source", *x.cfg.Source)
+			}
+			return
+		}
+
+		value, err := getString(extracted[*x.cfg.Source])
+		if err != nil {
+			if Debug {
+				level.Debug(x.logger).Log("msg", "failed to convert source value to string", "source", *x.cfg.Source, "err", err, "type", reflect.TypeOf(extracted[*x.cfg.Source]))
+			}
+			return
+		}
+
+		input = &value
+	}
+
+	if input == nil {
+		if Debug {
+			level.Debug(x.logger).Log("msg", "cannot parse a nil entry")
+		}
+		return
+	}
+
+	doc, err := parseXMLDocument(*input)
+	if err != nil {
+		if Debug {
+			level.Debug(x.logger).Log("msg", "failed to parse xml", "err", err)
+		}
+		return
+	}
+
+	for n, e := range x.expressions {
+		if v, ok := e.eval(doc); ok {
+			extracted[n] = v
+		}
+	}
+	if Debug {
+		level.Debug(x.logger).Log("msg", "extracted data debug in xml stage", "extracted data", fmt.Sprintf("%v", extracted))
+	}
+}
+
+// Name implements Stage
+func (x *xmlStage) Name() string {
+	return StageTypeXML
+}
+
+// xmlNode is an element of a parsed XML document. Names are local names, without their namespace.
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	// text holds the character data of the element and of its descendants, in document order.
+	text strings.Builder
+}
+
+// value returns the string value of the element, its trimmed text.
+func (n *xmlNode) value() string {
+	return strings.TrimSpace(n.text.String())
+}
+
+// parseXMLDocument parses a XML document and returns its document node, the parent of its root element.
+func parseXMLDocument(s string) (*xmlNode, error) {
+	doc := &xmlNode{}
+	stack := []*xmlNode{doc}
+	d := xml.NewDecoder(strings.NewReader(s))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: tok.Name.Local, attrs: make(map[string]string, len(tok.Attr))}
+			for _, a := range tok.Attr {
+				n.attrs[a.Name.Local] = a.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			for _, n := range stack[1:] {
+				n.text.Write(tok)
+			}
+		}
+	}
+	if len(doc.children) == 0 {
+		return nil, errors.New("no root element")
+	}
+	return doc, nil
+}
+
+// xpath is a compiled expression of the subset of XPath supported by the xml stage:
+// absolute (/) and descendant (//) location steps of element names or *, followed by
+// predicates on the position ([1]) or on attributes ([@name] and [@name='value']),
+// and optionally ending with an attribute (/@name) or text().
+type xpath struct {
+	steps []xpathStep
+	// attr is the attribute to select from the matched elements, if any.
+	attr string
+}
+
+type xpathStep struct {
+	descendant bool
+	name       string
+	predicates []xpathPredicate
+}
+
+type xpathPredicate struct {
+	position int
+	attr     string
+	value    *string
+}
+
+// compileXPath compiles an expression. Expressions not starting with a slash are relative to the document node.
+func compileXPath(s string) (*xpath, error) {
+	x := &xpath{}
+	rest := strings.TrimSpace(s)
+	if rest == "" {
+		return nil, errors.New("empty expression")
+	}
+	if !strings.HasPrefix(rest, "/") {
+		rest = "/" + rest
+	}
+	for rest != "" {
+		var step xpathStep
+		switch {
+		case strings.HasPrefix(rest, "//"):
+			step.descendant = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "/"):
+			rest = rest[1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+		end := strings.IndexAny(rest, "/[")
+		if end < 0 {
+			end = len(rest)
+		}
+		name := strings.TrimSpace(rest[:end])
+		rest = rest[end:]
+		switch {
+		case name == "":
+			return nil, errors.New("missing element name")
+		case name == "text()" || strings.HasPrefix(name, "@"):
+			if rest != "" || step.descendant || len(x.steps) == 0 {
+				return nil, fmt.Errorf("%s must be the last step of an element path", name)
+			}
+			x.attr = strings.TrimPrefix(name, "@")
+			if name == "text()" {
+				x.attr = ""
+			}
+			return x, nil
+		}
+		step.name = localName(name)
+		for strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, errors.New("unclosed predicate")
+			}
+			p, err := compileXPathPredicate(strings.TrimSpace(rest[1:end]))
+			if err != nil {
+				return nil, err
+			}
+			step.predicates = append(step.predicates, p)
+			rest = rest[end+1:]
+		}
+		x.steps = append(x.steps, step)
+	}
+	return x, nil
+}
+
+func compileXPathPredicate(s string) (xpathPredicate, error) {
+	if i, err := strconv.Atoi(s); err == nil {
+		if i < 1 {
+			return xpathPredicate{}, fmt.Errorf("invalid position %d", i)
+		}
+		return xpathPredicate{position: i}, nil
+	}
+	if !strings.HasPrefix(s, "@") {
+		return xpathPredicate{}, fmt.Errorf("unsupported predicate %q", s)
+	}
+	name, value, hasValue := s[1:], "", false
+	if i := strings.Index(name, "="); i >= 0 {
+		name, value, hasValue = name[:i], strings.TrimSpace(name[i+1:]), true
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return xpathPredicate{}, fmt.Errorf("missing attribute name in predicate %q", s)
+	}
+	p := xpathPredicate{attr: localName(name)}
+	if hasValue {
+		unquoted, ok := unquoteXPath(value)
+		if !ok {
+			return xpathPredicate{}, fmt.Errorf("attribute value must be quoted in predicate %q", s)
+		}
+		p.value = &unquoted
+	}
+	return p, nil
+}
+
+func unquoteXPath(s string) (string, bool) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], true
+	}
+	return "", false
+}
+
+// localName strips the namespace prefix of a name, namespaces are ignored when matching.
+func localName(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// eval returns the value of the first match of the expression in the document.
+func (x *xpath) eval(doc *xmlNode) (string, bool) {
+	nodes := []*xmlNode{doc}
+	for _, step := range x.steps {
+		var next []*xmlNode
+		for _, n := range nodes {
+			next = append(next, step.match(n)...)
+		}
+		nodes = next
+	}
+	for _, n := range nodes {
+		if x.attr == "" {
+			return n.value(), true
+		}
+		if v, ok := n.attrs[x.attr]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// match returns the elements matching the step from the context node.
+func (s xpathStep) match(n *xmlNode) []*xmlNode {
+	var candidates []*xmlNode
+	var walk func(n *xmlNode)
+	walk = func(n *xmlNode) {
+		for _, c := range n.children {
+			if s.name == "*" || c.name == s.name {
+				candidates = append(candidates, c)
+			}
+			if s.descendant {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	for _, p := range s.predicates {
+		var matched []*xmlNode
+		for i, c := range candidates {
+			if p.matches(i+1, c) {
+				matched = append(matched, c)
+			}
+		}
+		candidates = matched
+	}
+	return candidates
+}
+
+func (p xpathPredicate) matches(position int, n *xmlNode) bool {
+	if p.position > 0 {
+		return position == p.position
+	}
+	v, ok := n.attrs[p.attr]
+	return ok && (p.value == nil || v == *p.value)
+}
@@ -0,0 +1,120 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+var testXMLYaml = `
+pipeline_stages:
+- xml:
+    expressions:
+      provider: /Event/System/Provider/@Name
+      event_id: /Event/System/EventID
+      user: /Event/EventData/Data[@Name='TargetUserName']
+      Computer:
+- labels:
+    provider:
+`
+
+var testXMLLogLine = `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'>
+  <System>
+    <Provider Name='Microsoft-Windows-Security-Auditing' Guid='{54849625-5478-4994-a5ba-3e3b0328c30d}'/>
+    <EventID>4624</EventID>
+    <Computer>dc01.example.com</Computer>
+  </System>
+  <EventData>
+    <Data Name='SubjectUserName'>DC01$</Data>
+    <Data Name='TargetUserName'>jane</Data>
+  </EventData>
+</Event>`
+
+// TestXMLPipeline is used to verify we properly parse the yaml config and create a working pipeline
+func TestXMLPipeline(t *testing.T) {
+	plName := "test_pipeline"
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testXMLYaml), &plName, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+	out := processEntries(pl, newEntry(nil, nil, testXMLLogLine, time.Now()))[0]
+	require.Equal(t, map[string]interface{}{
+		"provider": "Microsoft-Windows-Security-Auditing",
+		"event_id": "4624",
+		"user":     "jane",
+		"Computer": "dc01.example.com",
+	}, out.Extracted)
+	require.Equal(t, "Microsoft-Windows-Security-Auditing", string(out.Labels["provider"]))
+}
+
+func TestXMLStage_Expressions(t *testing.T) {
+	const line = `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope">
+<soap:Body><m:Order xmlns:m="urn:orders" id="42"><m:Item sku="a">apple</m:Item><m:Item sku="b">banana <b>split</b></m:Item></m:Order></soap:Body>
+</soap:Envelope>`
+	for _, tt := range []struct {
+		expression string
+		want       string
+		found      bool
+	}{
+		{"/Envelope/Body/Order/@id", "42", true},
+		{"/soap:Envelope/soap:Body/m:Order/@id", "42", true},
+		{"Envelope/Body/Order/@id", "42", true},
+		{"//Item", "apple", true},
+		{"//Item[2]", "banana split", true},
+		{"//Item[@sku='b']/text()", "banana split", true},
+		{"//Item[@sku]/@sku", "a", true},
+		{"//Order/*[2]/b", "split", true},
+		{"//Item[3]", "", false},
+		{"//Item[@sku='c']", "", false},
+		{"/Body", "", false},
+		{"//Order/@missing", "", false},
+	} {
+		x, err := compileXPath(tt.expression)
+		require.NoError(t, err, tt.expression)
+		doc, err := parseXMLDocument(line)
+		require.NoError(t, err)
+		got, found := x.eval(doc)
+		require.Equal(t, tt.found, found, tt.expression)
+		require.Equal(t, tt.want, got, tt.expression)
+	}
+}
+
+func TestXMLStage_InvalidExpressions(t *testing.T) {
+	for _, e := range []string{
+		"/a/[1]",
+		"/a[b]",
+		"/a[0]",
+		"/a[@b=c]",
+		"/a[@b",
+		"@b",
+		"/a/@b/c",
+		"/a//@b",
+	} {
+		_, err := compileXPath(e)
+		require.Error(t, err, e)
+	}
+}
+
+func TestXMLStage_Config(t *testing.T) {
+	_, err := validateXMLConfig(nil)
+	require.EqualError(t, err, ErrEmptyXMLStageConfig)
+	_, err = validateXMLConfig(&XMLConfig{})
+	require.EqualError(t, err, ErrXMLExpressionsRequired)
+	empty := ""
+	_, err = validateXMLConfig(&XMLConfig{Expressions: map[string]string{"a": ""}, Source: &empty})
+	require.EqualError(t, err, ErrEmptyXMLStageSource)
+	_, err = validateXMLConfig(&XMLConfig{Expressions: map[string]string{"a": "/a[b]"}})
+	require.Error(t, err)
+}
+
+func TestXMLStage_InvalidDocument(t *testing.T) {
+	s, err := newXMLStage(util_log.Logger, map[string]interface{}{
+		"expressions": map[string]interface{}{"a": ""},
+	})
+	require.NoError(t, err)
+	out := processEntries(s, newEntry(nil, nil, `<a>unclosed`, time.Now()), newEntry(nil, nil, `not xml`, time.Now()))
+	require.Len(t, out, 2)
+	require.Empty(t, out[0].Extracted)
+	require.Empty(t, out[1].Extracted)
+}
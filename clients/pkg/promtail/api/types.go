@@ -12,6 +12,67 @@ import (
 type Entry struct {
 	Labels model.LabelSet
 	logproto.Entry
+	// Ack, if set, is called once the entry has been handled. See AckFunc.
+	Ack AckFunc `yaml:"-" json:"-"`
+}
+
+// AckFunc acknowledges an entry. It is called with a nil error once the entry has been
+// pushed to Loki, rejected by Loki, or dropped by a pipeline stage, and with the error
+// when the entry could not be pushed and could be sent again, e.g. when Loki was
+// unavailable until all retries were exhausted.
+//
+// Handlers dropping or merging entries must acknowledge them, handlers forwarding an
+// entry to several others must acknowledge it once all of them did.
+type AckFunc func(err error)
+
+// Done acknowledges the entry, if f is set.
+func (f AckFunc) Done(err error) {
+	if f != nil {
+		f(err)
+	}
+}
+
+// Split returns an AckFunc to call once for each of n copies of an entry. f is called
+// once all of them were acknowledged, with the first error if any.
+func (f AckFunc) Split(n int) AckFunc {
+	if f == nil || n == 1 {
+		return f
+	}
+	var (
+		mtx       sync.Mutex
+		remaining = n
+		firstErr  error
+	)
+	return func(err error) {
+		mtx.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		remaining--
+		done := remaining == 0
+		mtx.Unlock()
+		if done {
+			f(firstErr)
+		}
+	}
+}
+
+// JoinAcks returns an AckFunc acknowledging all the acks, nil if none is set.
+func JoinAcks(acks []AckFunc) AckFunc {
+	var set []AckFunc
+	for _, ack := range acks {
+		if ack != nil {
+			set = append(set, ack)
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return func(err error) {
+		for _, ack := range set {
+			ack(err)
+		}
+	}
 }
 
 type InstrumentedEntryHandler interface {
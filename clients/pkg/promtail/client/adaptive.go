@@ -0,0 +1,88 @@
+package client
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"time"
+)
+
+const (
+	// AdaptiveBatchingMaxFactor is the default factor the batch size and wait grow up to.
+	AdaptiveBatchingMaxFactor = 8
+	// AdaptiveBatchingMaxBatchSize is the default size the batches grow up to, under the 6MB
+	// ingestion burst size of Loki by default. Loki rejects the batches larger than the burst.
+	AdaptiveBatchingMaxBatchSize = 4 << 20
+)
+
+// AdaptiveBatchingConfig configures the batch size and batch wait adapting to the throughput
+// of the entries and the latency of the push requests. They grow, up to MaxFactor times the
+// configured ones and MaxBatchSize, when the requests are slow or the batches fill up before the
+// batch wait, sending fewer and larger batches which compress better. They shrink back once
+// the requests are fast again or are rate limited.
+type AdaptiveBatchingConfig struct {
+	Enabled   bool    `yaml:"enabled"`
+	MaxFactor float64 `yaml:"max_factor"`
+	// MaxBatchSize is the size in bytes the batches grow up to, it must remain under the
+	// ingestion burst size of the tenant in Loki.
+	MaxBatchSize int `yaml:"max_batch_size"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by prefix.
+func (c *AdaptiveBatchingConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&c.Enabled, prefix+"client.adaptive-batching.enabled", false, "Adapt the batch size and wait to the throughput of the entries and the latency of the requests.")
+	f.Float64Var(&c.MaxFactor, prefix+"client.adaptive-batching.max-factor", AdaptiveBatchingMaxFactor, "Maximum factor the batch size and wait are multiplied by.")
+	f.IntVar(&c.MaxBatchSize, prefix+"client.adaptive-batching.max-batch-size", AdaptiveBatchingMaxBatchSize, "Maximum size in bytes the batches grow up to, it must remain under the ingestion burst size of the tenant.")
+}
+
+func (c *AdaptiveBatchingConfig) validate() error {
+	if c.Enabled && c.MaxFactor < 1 {
+		return errors.New("the adaptive batching max factor must be greater than or equal to 1")
+	}
+	return nil
+}
+
+// adaptiveBatching computes the factor the batch size and wait are multiplied by. It is only
+// used by the goroutine sending the batches.
+type adaptiveBatching struct {
+	maxFactor float64
+	factor    float64
+}
+
+func newAdaptiveBatching(cfg AdaptiveBatchingConfig) *adaptiveBatching {
+	maxFactor := cfg.MaxFactor
+	if !cfg.Enabled {
+		maxFactor = 1
+	}
+	return &adaptiveBatching{maxFactor: maxFactor, factor: 1}
+}
+
+func (a *adaptiveBatching) scale(f float64) {
+	a.factor *= f
+	if a.factor > a.maxFactor {
+		a.factor = a.maxFactor
+	}
+	if a.factor < 1 {
+		a.factor = 1
+	}
+}
+
+// filled grows the batches when one was sent because it was full.
+func (a *adaptiveBatching) filled() {
+	a.scale(1.5)
+}
+
+// observe adapts the batches to the first request sending a batch, with the given status,
+// which took d while the batch wait is wait. Rate limited requests shrink the batches, which
+// may exceed the ingestion burst size, and failed requests keep them.
+func (a *adaptiveBatching) observe(d, wait time.Duration, status int) {
+	switch {
+	case status == http.StatusTooManyRequests:
+		a.scale(0.5)
+	case status <= 0 || status/100 == 5:
+	case d > wait/2:
+		a.scale(2)
+	case d < wait/4:
+		a.scale(0.8)
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_adaptiveBatching(t *testing.T) {
+	a := newAdaptiveBatching(AdaptiveBatchingConfig{Enabled: true, MaxFactor: 4})
+	wait := time.Second
+
+	// slow requests grow the batches, up to the max factor.
+	a.observe(600*time.Millisecond, wait, http.StatusNoContent)
+	require.Equal(t, 2.0, a.factor)
+	a.observe(600*time.Millisecond, wait, http.StatusNoContent)
+	require.Equal(t, 4.0, a.factor)
+	a.observe(600*time.Millisecond, wait, http.StatusNoContent)
+	require.Equal(t, 4.0, a.factor)
+
+	// failed requests keep them.
+	a.observe(10*time.Millisecond, wait, -1)
+	require.Equal(t, 4.0, a.factor)
+	a.observe(time.Second, wait, http.StatusServiceUnavailable)
+	require.Equal(t, 4.0, a.factor)
+
+	// rate limited requests shrink them, the batches may exceed the ingestion burst size.
+	a.observe(10*time.Millisecond, wait, http.StatusTooManyRequests)
+	require.Equal(t, 2.0, a.factor)
+	a.observe(600*time.Millisecond, wait, http.StatusNoContent)
+	require.Equal(t, 4.0, a.factor)
+
+	// requests neither slow nor fast keep the batches.
+	a.observe(300*time.Millisecond, wait, http.StatusNoContent)
+	require.Equal(t, 4.0, a.factor)
+
+	// fast requests shrink them back, down to the configured ones.
+	a.observe(10*time.Millisecond, wait, http.StatusNoContent)
+	require.InDelta(t, 3.2, a.factor, 0.001)
+	for i := 0; i < 10; i++ {
+		a.observe(10*time.Millisecond, wait, http.StatusNoContent)
+	}
+	require.Equal(t, 1.0, a.factor)
+
+	// full batches grow them.
+	a.filled()
+	require.Equal(t, 1.5, a.factor)
+
+	// without adaptive batching the factor stays 1.
+	a = newAdaptiveBatching(AdaptiveBatchingConfig{MaxFactor: 4})
+	a.observe(time.Minute, wait, http.StatusNoContent)
+	a.filled()
+	require.Equal(t, 1.0, a.factor)
+}
+
+func Test_batchSize(t *testing.T) {
+	c := &client{
+		cfg: Config{
+			BatchSize:        1 << 20,
+			AdaptiveBatching: AdaptiveBatchingConfig{Enabled: true, MaxFactor: 8, MaxBatchSize: 4 << 20},
+		},
+		adaptive: newAdaptiveBatching(AdaptiveBatchingConfig{Enabled: true, MaxFactor: 8}),
+	}
+	c.adaptive.factor = 2
+	require.Equal(t, 2<<20, c.batchSize())
+	// the batches never grow over the max batch size.
+	c.adaptive.factor = 8
+	require.Equal(t, 4<<20, c.batchSize())
+	// nor shrink under the configured batch size.
+	c.cfg.AdaptiveBatching.MaxBatchSize = 1 << 10
+	require.Equal(t, 1<<20, c.batchSize())
+}
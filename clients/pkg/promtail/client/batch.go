@@ -21,6 +21,7 @@ import (
 // streams for each tenant are stored in a dedicated batch.
 type batch struct {
 	streams   map[string]*logproto.Stream
+	acks      []api.AckFunc
 	bytes     int
 	createdAt time.Time
 }
@@ -42,7 +43,10 @@ func newBatch(entries ...api.Entry) *batch {
 
 // add an entry to the batch
 func (b *batch) add(entry api.Entry) {
-	b.bytes += len(entry.Line)
+	b.bytes += entrySize(entry.Entry)
+	if entry.Ack != nil {
+		b.acks = append(b.acks, entry.Ack)
+	}
 
 	// Append the entry to an already existing stream (if any)
 	labels := labelsMapToString(entry.Labels, ReservedLabelTenantID)
@@ -74,6 +78,13 @@ Outer:
 	return fmt.Sprintf("{%s}", strings.Join(lstrs, ", "))
 }
 
+// ack acknowledges the entries of the batch once it has been sent.
+func (b *batch) ack(err error) {
+	for _, ack := range b.acks {
+		ack(err)
+	}
+}
+
 // sizeBytes returns the current batch size in bytes
 func (b *batch) sizeBytes() int {
 	return b.bytes
@@ -82,7 +93,16 @@ func (b *batch) sizeBytes() int {
 // sizeBytesAfter returns the size of the batch after the input entry
 // will be added to the batch itself
 func (b *batch) sizeBytesAfter(entry api.Entry) int {
-	return b.bytes + len(entry.Line)
+	return b.bytes + entrySize(entry.Entry)
+}
+
+// entrySize returns the size of the line of an entry and of its structured metadata.
+func entrySize(entry logproto.Entry) int {
+	size := len(entry.Line)
+	for _, m := range entry.StructuredMetadata {
+		size += len(m.Name) + len(m.Value)
+	}
+	return size
 }
 
 // age of the batch since its creation
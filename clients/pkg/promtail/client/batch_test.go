@@ -46,6 +46,16 @@ func TestBatch_add(t *testing.T) {
 			},
 			expectedSizeBytes: len(logEntries[0].Entry.Line) + len(logEntries[1].Entry.Line) + len(logEntries[2].Entry.Line),
 		},
+		"single stream with structured metadata": {
+			inputEntries: []api.Entry{
+				{Labels: model.LabelSet{}, Entry: logproto.Entry{
+					Timestamp:          time.Unix(1, 0).UTC(),
+					Line:               "line1",
+					StructuredMetadata: []logproto.LabelPair{{Name: "trace_id", Value: "7a1e5c"}},
+				}},
+			},
+			expectedSizeBytes: len("line1") + len("trace_id") + len("7a1e5c"),
+		},
 	}
 
 	for testName, testData := range tests {
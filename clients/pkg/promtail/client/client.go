@@ -7,21 +7,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/backoff"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/promql/parser"
+	"google.golang.org/grpc"
 
 	"github.com/grafana/loki/clients/pkg/logentry/metric"
 	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/tlspolicy"
 
 	lokiutil "github.com/grafana/loki/pkg/util"
 	"github.com/grafana/loki/pkg/util/build"
@@ -41,16 +44,27 @@ const (
 
 var UserAgent = fmt.Sprintf("promtail/%s", build.Version)
 
+// reservedHeaders are set by the client on every request, they can't be configured.
+var reservedHeaders = map[string]struct{}{
+	"Content-Type":                           {},
+	"User-Agent":                             {},
+	http.CanonicalHeaderKey("X-Scope-OrgID"): {},
+}
+
 type metrics struct {
-	encodedBytes     *prometheus.CounterVec
-	sentBytes        *prometheus.CounterVec
-	droppedBytes     *prometheus.CounterVec
-	sentEntries      *prometheus.CounterVec
-	droppedEntries   *prometheus.CounterVec
-	requestDuration  *prometheus.HistogramVec
-	batchRetries     *prometheus.CounterVec
-	streamLag        *metric.Gauges
-	countersWithHost []*prometheus.CounterVec
+	encodedBytes      *prometheus.CounterVec
+	sentBytes         *prometheus.CounterVec
+	droppedBytes      *prometheus.CounterVec
+	sentEntries       *prometheus.CounterVec
+	droppedEntries    *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+	batchRetries      *prometheus.CounterVec
+	spooledBytes      *prometheus.CounterVec
+	spoolEvictedBytes *prometheus.CounterVec
+	spoolSize         *prometheus.GaugeVec
+	batchFactor       *prometheus.GaugeVec
+	streamLag         *metric.Gauges
+	countersWithHost  []*prometheus.CounterVec
 }
 
 func newMetrics(reg prometheus.Registerer) *metrics {
@@ -92,6 +106,27 @@ func newMetrics(reg prometheus.Registerer) *metrics {
 		Help:      "Number of times batches has had to be retried.",
 	}, []string{HostLabel})
 
+	m.spooledBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "spooled_bytes_total",
+		Help:      "Number of bytes persisted to the spool because failed to be sent to the ingester after all retries.",
+	}, []string{HostLabel})
+	m.spoolEvictedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "spool_evicted_bytes_total",
+		Help:      "Number of spooled bytes dropped because the spool was full or they were older than the spool max age.",
+	}, []string{HostLabel})
+	m.spoolSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "spool_size_bytes",
+		Help:      "Size of the batches waiting in the spool.",
+	}, []string{HostLabel})
+	m.batchFactor = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "batch_factor",
+		Help:      "Factor the batch size and wait are multiplied by, adapting to the throughput and the latency of the requests.",
+	}, []string{HostLabel})
+
 	var err error
 	m.streamLag, err = metric.NewGauges("promtail_stream_lag_seconds",
 		"Difference between current time and last batch timestamp for successful sends",
@@ -103,7 +138,7 @@ func newMetrics(reg prometheus.Registerer) *metrics {
 	}
 
 	m.countersWithHost = []*prometheus.CounterVec{
-		m.encodedBytes, m.sentBytes, m.droppedBytes, m.sentEntries, m.droppedEntries,
+		m.encodedBytes, m.sentBytes, m.droppedBytes, m.sentEntries, m.droppedEntries, m.spooledBytes, m.spoolEvictedBytes,
 	}
 
 	if reg != nil {
@@ -114,6 +149,10 @@ func newMetrics(reg prometheus.Registerer) *metrics {
 		m.droppedEntries = mustRegisterOrGet(reg, m.droppedEntries).(*prometheus.CounterVec)
 		m.requestDuration = mustRegisterOrGet(reg, m.requestDuration).(*prometheus.HistogramVec)
 		m.batchRetries = mustRegisterOrGet(reg, m.batchRetries).(*prometheus.CounterVec)
+		m.spooledBytes = mustRegisterOrGet(reg, m.spooledBytes).(*prometheus.CounterVec)
+		m.spoolEvictedBytes = mustRegisterOrGet(reg, m.spoolEvictedBytes).(*prometheus.CounterVec)
+		m.spoolSize = mustRegisterOrGet(reg, m.spoolSize).(*prometheus.GaugeVec)
+		m.batchFactor = mustRegisterOrGet(reg, m.batchFactor).(*prometheus.GaugeVec)
 		m.streamLag = mustRegisterOrGet(reg, m.streamLag).(*metric.Gauges)
 	}
 
@@ -143,13 +182,26 @@ type client struct {
 	logger  log.Logger
 	cfg     Config
 	client  *http.Client
+	// conn is the connection to the OTLP gRPC endpoint, nil with the other protocols.
+	conn    *grpc.ClientConn
 	entries chan api.Entry
 
 	once sync.Once
 	wg   sync.WaitGroup
+	quit chan struct{}
 
 	externalLabels model.LabelSet
 
+	// spool is nil when disabled.
+	spool *spool
+
+	// batchWait is the current batch wait in nanoseconds, it is stretched to shed load.
+	batchWait int64
+	// adaptive scales the batch size and wait, it is only used by run.
+	adaptive *adaptiveBatching
+	// compressor is nil with the OTLP protocols.
+	compressor *compressor
+
 	// ctx is used in any upstream calls from the `client`.
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -174,9 +226,11 @@ func newClient(reg prometheus.Registerer, cfg Config, logger log.Logger) (*clien
 		logger:  log.With(logger, "component", "client", "host", cfg.URL.Host),
 		cfg:     cfg,
 		entries: make(chan api.Entry),
+		quit:    make(chan struct{}),
 		metrics: newMetrics(reg),
 
 		externalLabels: cfg.ExternalLabels.LabelSet,
+		batchWait:      int64(cfg.BatchWait),
 		ctx:            ctx,
 		cancel:         cancel,
 	}
@@ -185,20 +239,66 @@ func newClient(reg prometheus.Registerer, cfg Config, logger log.Logger) (*clien
 	if err != nil {
 		return nil, err
 	}
+	for name := range cfg.Headers {
+		if _, ok := reservedHeaders[http.CanonicalHeaderKey(name)]; ok {
+			return nil, fmt.Errorf("header %s is set by the client and can't be configured", name)
+		}
+	}
+	if err := validateProtocol(cfg); err != nil {
+		return nil, err
+	}
+	if err := validateCompression(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.AdaptiveBatching.validate(); err != nil {
+		return nil, err
+	}
+	c.adaptive = newAdaptiveBatching(cfg.AdaptiveBatching)
+	if cfg.Protocol == "" || cfg.Protocol == ProtocolLoki {
+		if c.compressor, err = newCompressor(cfg.Compression); err != nil {
+			return nil, err
+		}
+	}
 
-	c.client, err = config.NewClientFromConfig(cfg.Client, "promtail", config.WithHTTP2Disabled())
+	opts := tlspolicy.HTTPClientOptions{HTTP2Disabled: !cfg.EnableHTTP2}
+	if cfg.UnixSocket != "" {
+		var d net.Dialer
+		opts.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "unix", cfg.UnixSocket)
+		}
+	}
+	c.client, err = tlspolicy.NewHTTPClient(cfg.Client, "promtail", opts)
 	if err != nil {
 		return nil, err
 	}
 
 	c.client.Timeout = cfg.Timeout
 
+	if cfg.Protocol == ProtocolOTLPGRPC {
+		c.conn, err = dialOTLP(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Initialize counters to 0 so the metrics are exported before the first
 	// occurrence of incrementing to avoid missing metrics.
 	for _, counter := range c.metrics.countersWithHost {
 		counter.WithLabelValues(c.cfg.URL.Host).Add(0)
 	}
 
+	if err := cfg.Spool.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Spool.Dir != "" {
+		c.spool, err = newSpool(cfg.Spool, c.metrics, c.cfg.URL.Host, c.logger)
+		if err != nil {
+			return nil, fmt.Errorf("opening the spool: %w", err)
+		}
+		c.wg.Add(1)
+		go c.runSpool()
+	}
+
 	c.wg.Add(1)
 	go c.run()
 	return c, nil
@@ -262,8 +362,10 @@ func (c *client) run() {
 
 			// If adding the entry to the batch will increase the size over the max
 			// size allowed, we do send the current batch and then create a new one
-			if batch.sizeBytesAfter(e) > c.cfg.BatchSize {
+			if batch.sizeBytesAfter(e) > c.batchSize() {
 				c.sendBatch(tenantID, batch)
+				c.adaptive.filled()
+				c.metrics.batchFactor.WithLabelValues(c.cfg.URL.Host).Set(c.adaptive.factor)
 
 				batches[tenantID] = newBatch(e)
 				break
@@ -275,7 +377,7 @@ func (c *client) run() {
 		case <-maxWaitCheck.C:
 			// Send all batches whose max wait time has been reached
 			for tenantID, batch := range batches {
-				if batch.age() < c.cfg.BatchWait {
+				if batch.age() < c.currentBatchWait() {
 					continue
 				}
 
@@ -286,29 +388,76 @@ func (c *client) run() {
 	}
 }
 
+// SetBatchWaitFactor sets the batch wait to the configured one multiplied by factor,
+// sending fewer and larger batches when factor is greater than 1.
+func (c *client) SetBatchWaitFactor(factor float64) {
+	atomic.StoreInt64(&c.batchWait, int64(float64(c.cfg.BatchWait)*factor))
+}
+
+// batchSize returns the current max size of the batches. The adaptive batching never grows them
+// over its max batch size, not to exceed the ingestion burst size of Loki.
+func (c *client) batchSize() int {
+	size := int(float64(c.cfg.BatchSize) * c.adaptive.factor)
+	if max := c.cfg.AdaptiveBatching.MaxBatchSize; max > 0 && size > max {
+		size = max
+	}
+	if size < c.cfg.BatchSize {
+		size = c.cfg.BatchSize
+	}
+	return size
+}
+
+// currentBatchWait returns the current max wait of the batches.
+func (c *client) currentBatchWait() time.Duration {
+	return time.Duration(float64(atomic.LoadInt64(&c.batchWait)) * c.adaptive.factor)
+}
+
 func (c *client) Chan() chan<- api.Entry {
 	return c.entries
 }
 
+// encode encodes the batch for the protocol of the client.
+func (c *client) encode(batch *batch) ([]byte, int, error) {
+	switch c.cfg.Protocol {
+	case ProtocolOTLPHTTP, ProtocolOTLPGRPC:
+		return batch.encodeOTLP()
+	default:
+		return batch.encode()
+	}
+}
+
 func (c *client) sendBatch(tenantID string, batch *batch) {
-	buf, entriesCount, err := batch.encode()
+	buf, entriesCount, err := c.encode(batch)
 	if err != nil {
 		level.Error(c.logger).Log("msg", "error encoding batch", "error", err)
+		batch.ack(nil)
 		return
 	}
 	bufBytes := float64(len(buf))
 	c.metrics.encodedBytes.WithLabelValues(c.cfg.URL.Host).Add(bufBytes)
 
+	if c.spool != nil && c.spool.pending() {
+		// The batch is sent after the spooled ones, to keep the entries in order.
+		c.spoolBatch(tenantID, batch, buf, entriesCount)
+		return
+	}
+
 	backoff := backoff.New(c.ctx, c.cfg.BackoffConfig)
 	var status int
-	for {
+	for attempt := 0; ; attempt++ {
 		start := time.Now()
 		// send uses `timeout` internally, so `context.Background` is good enough.
 		status, err = c.send(context.Background(), tenantID, buf)
 
-		c.metrics.requestDuration.WithLabelValues(strconv.Itoa(status), c.cfg.URL.Host).Observe(time.Since(start).Seconds())
+		duration := time.Since(start)
+		c.metrics.requestDuration.WithLabelValues(strconv.Itoa(status), c.cfg.URL.Host).Observe(duration.Seconds())
+		if attempt == 0 {
+			c.adaptive.observe(duration, c.currentBatchWait(), status)
+			c.metrics.batchFactor.WithLabelValues(c.cfg.URL.Host).Set(c.adaptive.factor)
+		}
 
 		if err == nil {
+			batch.ack(nil)
 			c.metrics.sentBytes.WithLabelValues(c.cfg.URL.Host).Add(bufBytes)
 			c.metrics.sentEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(entriesCount))
 			for _, s := range batch.streams {
@@ -343,6 +492,8 @@ func (c *client) sendBatch(tenantID string, batch *batch) {
 
 		// Only retry 429s, 500s and connection-level errors.
 		if status > 0 && status != 429 && status/100 != 5 {
+			// Entries rejected by Loki would be rejected all the same if sent again.
+			batch.ack(nil)
 			break
 		}
 
@@ -352,6 +503,12 @@ func (c *client) sendBatch(tenantID string, batch *batch) {
 
 		// Make sure it sends at least once before checking for retry.
 		if !backoff.Ongoing() {
+			if c.spool != nil {
+				level.Warn(c.logger).Log("msg", "error sending batch, spooling it", "status", status, "error", err)
+				c.spoolBatch(tenantID, batch, buf, entriesCount)
+				return
+			}
+			batch.ack(err)
 			break
 		}
 	}
@@ -363,15 +520,101 @@ func (c *client) sendBatch(tenantID string, batch *batch) {
 	}
 }
 
+// spoolBatch persists a batch to be sent later by runSpool. The batch is acknowledged once persisted.
+func (c *client) spoolBatch(tenantID string, batch *batch, buf []byte, entriesCount int) {
+	if err := c.spool.append(tenantID, entriesCount, buf); err != nil {
+		level.Error(c.logger).Log("msg", "error spooling batch", "error", err)
+		batch.ack(err)
+		c.metrics.droppedBytes.WithLabelValues(c.cfg.URL.Host).Add(float64(len(buf)))
+		c.metrics.droppedEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(entriesCount))
+		return
+	}
+	batch.ack(nil)
+	c.metrics.spooledBytes.WithLabelValues(c.cfg.URL.Host).Add(float64(len(buf)))
+}
+
+// runSpool sends the spooled batches at startup, then every retry interval.
+func (c *client) runSpool() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.cfg.Spool.RetryInterval)
+	defer ticker.Stop()
+	for {
+		c.sendSpooled()
+		select {
+		case <-c.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendSpooled sends the spooled batches, oldest first, until one fails.
+func (c *client) sendSpooled() {
+	for {
+		select {
+		case <-c.quit:
+			return
+		default:
+		}
+
+		batch, err := c.spool.oldest()
+		if err != nil {
+			level.Error(c.logger).Log("msg", "error reading spooled batch", "error", err)
+			if batch != nil {
+				c.spool.remove(batch.file)
+				continue
+			}
+			return
+		}
+		if batch == nil {
+			return
+		}
+
+		start := time.Now()
+		status, err := c.send(c.ctx, batch.tenantID, batch.buf)
+		c.metrics.requestDuration.WithLabelValues(strconv.Itoa(status), c.cfg.URL.Host).Observe(time.Since(start).Seconds())
+		if err != nil && (status <= 0 || status == 429 || status/100 == 5) {
+			level.Warn(c.logger).Log("msg", "error sending spooled batch, will retry", "status", status, "error", err)
+			return
+		}
+		if err != nil {
+			level.Error(c.logger).Log("msg", "spooled batch rejected", "status", status, "error", err)
+			c.metrics.droppedBytes.WithLabelValues(c.cfg.URL.Host).Add(float64(len(batch.buf)))
+			c.metrics.droppedEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(batch.entries))
+		} else {
+			c.metrics.sentBytes.WithLabelValues(c.cfg.URL.Host).Add(float64(len(batch.buf)))
+			c.metrics.sentEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(batch.entries))
+		}
+		c.spool.remove(batch.file)
+	}
+}
+
 func (c *client) send(ctx context.Context, tenantID string, buf []byte) (int, error) {
+	if c.conn != nil {
+		return c.sendGRPC(ctx, tenantID, buf)
+	}
+	body, encoding := buf, ""
+	if c.compressor != nil {
+		var err error
+		if body, encoding, err = c.compressor.compress(buf); err != nil {
+			return -1, err
+		}
+	}
 	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
 	defer cancel()
-	req, err := http.NewRequest("POST", c.cfg.URL.String(), bytes.NewReader(buf))
+	req, err := http.NewRequest("POST", c.cfg.URL.String(), bytes.NewReader(body))
 	if err != nil {
 		return -1, err
 	}
 	req = req.WithContext(ctx)
+	for name, value := range c.cfg.Headers {
+		req.Header.Set(name, value)
+	}
 	req.Header.Set("Content-Type", contentType)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
 	req.Header.Set("User-Agent", UserAgent)
 
 	// If the tenant ID is not empty promtail is running in multi-tenant mode, so
@@ -394,6 +637,10 @@ func (c *client) send(ctx context.Context, tenantID string, buf []byte) (int, er
 		}
 		err = fmt.Errorf("server returned HTTP status %s (%d): %s", resp.Status, resp.StatusCode, line)
 	}
+	if c.compressor != nil && c.compressor.negotiate(resp, err) {
+		level.Warn(c.logger).Log("msg", "the server doesn't support the compression, falling back to snappy", "compression", encoding, "error", err)
+		return c.send(ctx, tenantID, buf)
+	}
 	return resp.StatusCode, err
 }
 
@@ -403,6 +650,13 @@ func (c *client) getTenantID(labels model.LabelSet) string {
 		return string(value)
 	}
 
+	// Check if the entry has the label routing it to a tenant
+	if c.cfg.TenantLabel != "" {
+		if value := labels[model.LabelName(c.cfg.TenantLabel)]; value != "" {
+			return string(value)
+		}
+	}
+
 	// Check if has been specified in the config
 	if c.cfg.TenantID != "" {
 		return c.cfg.TenantID
@@ -415,8 +669,14 @@ func (c *client) getTenantID(labels model.LabelSet) string {
 
 // Stop the client.
 func (c *client) Stop() {
-	c.once.Do(func() { close(c.entries) })
+	c.once.Do(func() {
+		close(c.entries)
+		close(c.quit)
+	})
 	c.wg.Wait()
+	if c.conn != nil {
+		lokiutil.LogError("closing the OTLP connection", c.conn.Close)
+	}
 }
 
 // StopNow stops the client without retries
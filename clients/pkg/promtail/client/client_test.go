@@ -3,9 +3,12 @@ package client
 import (
 	"io"
 	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -47,6 +50,7 @@ func TestClient_Handle(t *testing.T) {
 		clientBatchWait      time.Duration
 		clientMaxRetries     int
 		clientTenantID       string
+		clientTenantLabel    string
 		serverResponseStatus int
 		inputEntries         []api.Entry
 		inputDelay           time.Duration
@@ -235,6 +239,47 @@ func TestClient_Handle(t *testing.T) {
 				promtail_dropped_entries_total{host="__HOST__"} 0
 			`,
 		},
+		"batch log entries together honoring the tenant ID of the tenant label": {
+			clientBatchSize:      100,
+			clientBatchWait:      100 * time.Millisecond,
+			clientMaxRetries:     3,
+			clientTenantID:       "tenant-default",
+			clientTenantLabel:    "namespace",
+			serverResponseStatus: 200,
+			inputEntries: []api.Entry{
+				logEntries[0],
+				{Labels: model.LabelSet{"namespace": "ns-1"}, Entry: logEntries[1].Entry},
+				{Labels: model.LabelSet{"namespace": "ns-2"}, Entry: logEntries[2].Entry},
+				{Labels: model.LabelSet{"namespace": "ns-1", "__tenant_id__": "tenant-1"}, Entry: logEntries[3].Entry},
+				{Labels: model.LabelSet{"namespace": "ns-1"}, Entry: logEntries[4].Entry},
+			},
+			expectedReqs: []receivedReq{
+				{
+					tenantID: "tenant-default",
+					pushReq:  logproto.PushRequest{Streams: []logproto.Stream{{Labels: "{}", Entries: []logproto.Entry{logEntries[0].Entry}}}},
+				},
+				{
+					tenantID: "ns-1",
+					pushReq:  logproto.PushRequest{Streams: []logproto.Stream{{Labels: `{namespace="ns-1"}`, Entries: []logproto.Entry{logEntries[1].Entry, logEntries[4].Entry}}}},
+				},
+				{
+					tenantID: "ns-2",
+					pushReq:  logproto.PushRequest{Streams: []logproto.Stream{{Labels: `{namespace="ns-2"}`, Entries: []logproto.Entry{logEntries[2].Entry}}}},
+				},
+				{
+					tenantID: "tenant-1",
+					pushReq:  logproto.PushRequest{Streams: []logproto.Stream{{Labels: `{namespace="ns-1"}`, Entries: []logproto.Entry{logEntries[3].Entry}}}},
+				},
+			},
+			expectedMetrics: `
+				# HELP promtail_sent_entries_total Number of log entries sent to the ingester.
+				# TYPE promtail_sent_entries_total counter
+				promtail_sent_entries_total{host="__HOST__"} 5.0
+				# HELP promtail_dropped_entries_total Number of log entries dropped because failed to be sent to the ingester after all retries.
+				# TYPE promtail_dropped_entries_total counter
+				promtail_dropped_entries_total{host="__HOST__"} 0
+			`,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -264,6 +309,7 @@ func TestClient_Handle(t *testing.T) {
 				ExternalLabels: lokiflag.LabelSet{},
 				Timeout:        1 * time.Second,
 				TenantID:       testData.clientTenantID,
+				TenantLabel:    testData.clientTenantLabel,
 			}
 
 			c, err := New(reg, cfg, log.NewNopLogger())
@@ -493,3 +539,49 @@ func Test_Tripperware(t *testing.T) {
 	c.Stop()
 	require.True(t, called)
 }
+
+func Test_UnixSocketAndHeaders(t *testing.T) {
+	// Unix socket paths are limited to about 100 characters, t.TempDir() may be too long.
+	dir, err := os.MkdirTemp("", "promtail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "loki.sock")
+
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	received := make(chan http.Header, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		received <- req.Header
+	}))
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+
+	u, err := url.Parse("http://loki/loki/api/v1/push")
+	require.NoError(t, err)
+	c, err := New(nil, Config{
+		URL:        flagext.URLValue{URL: u},
+		BatchWait:  10 * time.Millisecond,
+		BatchSize:  10,
+		Timeout:    time.Second,
+		UnixSocket: socket,
+		Headers:    map[string]string{"X-Gateway-Token": "secret"},
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	c.Chan() <- logEntries[0]
+	c.Stop()
+	header := <-received
+	require.Equal(t, "secret", header.Get("X-Gateway-Token"))
+	require.Equal(t, UserAgent, header.Get("User-Agent"))
+}
+
+func Test_ReservedHeaders(t *testing.T) {
+	u, err := url.Parse("http://loki/loki/api/v1/push")
+	require.NoError(t, err)
+	_, err = New(nil, Config{
+		URL:     flagext.URLValue{URL: u},
+		Headers: map[string]string{"x-scope-orgid": "tenant"},
+	}, log.NewNopLogger())
+	require.EqualError(t, err, "header x-scope-orgid is set by the client and can't be configured")
+}
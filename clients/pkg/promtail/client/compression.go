@@ -0,0 +1,138 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/atomic"
+)
+
+// Compression codecs of the push requests to Loki.
+const (
+	// CompressionSnappy sends the snappy compressed protobuf, supported by every Loki.
+	CompressionSnappy = "snappy"
+	// CompressionGzip additionally gzips the snappy compressed protobuf.
+	CompressionGzip = "gzip"
+	// CompressionZstd sends the zstd compressed protobuf.
+	CompressionZstd = "zstd"
+	// CompressionAuto uses the best codec Loki advertises in the Accept-Encoding header of its responses.
+	CompressionAuto = "auto"
+)
+
+// autoCompressions are the codecs CompressionAuto picks from, by preference.
+var autoCompressions = []string{CompressionZstd, CompressionGzip}
+
+func validateCompression(cfg Config) error {
+	switch cfg.Compression {
+	case "", CompressionSnappy:
+		return nil
+	case CompressionGzip, CompressionZstd, CompressionAuto:
+		if cfg.Protocol != "" && cfg.Protocol != ProtocolLoki {
+			return fmt.Errorf("compression %s is only supported with the %s protocol", cfg.Compression, ProtocolLoki)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown compression %q, must be one of %s, %s, %s or %s", cfg.Compression, CompressionSnappy, CompressionGzip, CompressionZstd, CompressionAuto)
+	}
+}
+
+// compressor compresses the snappy encoded batches with the codec negotiated with Loki.
+// It is safe for concurrent use.
+type compressor struct {
+	configured string
+	// current is the codec of the next requests.
+	current atomic.String
+
+	gzipWriters sync.Pool
+	zstd        *zstd.Encoder
+}
+
+func newCompressor(compression string) (*compressor, error) {
+	c := &compressor{configured: compression}
+	c.current.Store(compression)
+	if compression == "" || compression == CompressionAuto {
+		// Loki is asked for the codecs it supports by the first request.
+		c.current.Store(CompressionSnappy)
+	}
+	if compression == CompressionZstd || compression == CompressionAuto {
+		var err error
+		if c.zstd, err = zstd.NewWriter(nil); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// compress returns the body of the batch encoded by batch.encode, and its Content-Encoding.
+func (c *compressor) compress(buf []byte) ([]byte, string, error) {
+	switch codec := c.current.Load(); codec {
+	case CompressionGzip:
+		var b bytes.Buffer
+		w, ok := c.gzipWriters.Get().(*gzip.Writer)
+		if !ok {
+			w = gzip.NewWriter(&b)
+		} else {
+			w.Reset(&b)
+		}
+		defer c.gzipWriters.Put(w)
+		if _, err := w.Write(buf); err != nil {
+			return nil, "", err
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", err
+		}
+		return b.Bytes(), codec, nil
+	case CompressionZstd:
+		raw, err := snappy.Decode(nil, buf)
+		if err != nil {
+			return nil, "", err
+		}
+		return c.zstd.EncodeAll(raw, nil), codec, nil
+	default:
+		return buf, "", nil
+	}
+}
+
+// negotiate updates the codec from the response of Loki. It returns true when the request
+// was rejected because of its codec, it is then to be sent again with snappy.
+func (c *compressor) negotiate(resp *http.Response, err error) bool {
+	current := c.current.Load()
+	if current != CompressionSnappy && (resp.StatusCode == http.StatusUnsupportedMediaType ||
+		// Loki versions without zstd reject it as a bad request.
+		resp.StatusCode == http.StatusBadRequest && err != nil && strings.Contains(err.Error(), "Content-Encoding")) {
+		c.current.Store(CompressionSnappy)
+		return true
+	}
+
+	accepted := resp.Header.Get("Accept-Encoding")
+	if c.configured != CompressionAuto || accepted == "" {
+		return false
+	}
+	codec := CompressionSnappy
+	for _, compression := range autoCompressions {
+		if acceptsEncoding(accepted, compression) {
+			codec = compression
+			break
+		}
+	}
+	c.current.Store(codec)
+	return false
+}
+
+func acceptsEncoding(accepted, encoding string) bool {
+	for _, e := range strings.Split(accepted, ",") {
+		if i := strings.Index(e, ";"); i >= 0 {
+			e = e[:i]
+		}
+		if strings.TrimSpace(e) == encoding {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/loghttp/push"
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func Test_compressor(t *testing.T) {
+	buf, _, err := newBatch(logEntries[:3]...).encode()
+	require.NoError(t, err)
+
+	for _, compression := range []string{CompressionSnappy, CompressionGzip, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			c, err := newCompressor(compression)
+			require.NoError(t, err)
+			body, encoding, err := c.compress(buf)
+			require.NoError(t, err)
+
+			// the request is decoded the same way Loki does.
+			req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", bytes.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Content-Encoding", encoding)
+			pushReq, err := push.ParseRequest(log.NewNopLogger(), "", req, nil, push.BodyLimits{})
+			require.NoError(t, err)
+			require.Len(t, pushReq.Streams, 1)
+			require.Equal(t, []logproto.Entry{logEntries[0].Entry, logEntries[1].Entry, logEntries[2].Entry}, pushReq.Streams[0].Entries)
+		})
+	}
+}
+
+func Test_compressorNegotiate(t *testing.T) {
+	resp := func(status int, accepted string) *http.Response {
+		r := &http.Response{StatusCode: status, Header: http.Header{}}
+		if accepted != "" {
+			r.Header.Set("Accept-Encoding", accepted)
+		}
+		return r
+	}
+
+	c, err := newCompressor(CompressionAuto)
+	require.NoError(t, err)
+	require.Equal(t, CompressionSnappy, c.current.Load())
+	require.False(t, c.negotiate(resp(http.StatusNoContent, ""), nil))
+	require.Equal(t, CompressionSnappy, c.current.Load())
+	require.False(t, c.negotiate(resp(http.StatusNoContent, "snappy, gzip;q=0.5"), nil))
+	require.Equal(t, CompressionGzip, c.current.Load())
+	require.False(t, c.negotiate(resp(http.StatusNoContent, push.SupportedEncodings), nil))
+	require.Equal(t, CompressionZstd, c.current.Load())
+
+	// a codec rejected by the server is not used anymore.
+	c, err = newCompressor(CompressionZstd)
+	require.NoError(t, err)
+	require.False(t, c.negotiate(resp(http.StatusBadRequest, ""), nil))
+	require.True(t, c.negotiate(resp(http.StatusUnsupportedMediaType, ""), nil))
+	require.Equal(t, CompressionSnappy, c.current.Load())
+	require.False(t, c.negotiate(resp(http.StatusUnsupportedMediaType, ""), nil))
+}
+
+func TestClient_Compression(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		compression string
+		accepted    string
+		expected    []string
+	}{
+		{
+			name:        "auto",
+			compression: CompressionAuto,
+			accepted:    push.SupportedEncodings,
+			expected:    []string{"", "zstd"},
+		},
+		{
+			name:        "auto without support",
+			compression: CompressionAuto,
+			expected:    []string{"", ""},
+		},
+		{
+			name:        "fallback",
+			compression: CompressionZstd,
+			accepted:    "snappy",
+			expected:    []string{"zstd", "", ""},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			encodings := make(chan string, 10)
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				encoding := req.Header.Get("Content-Encoding")
+				encodings <- encoding
+				if tc.accepted != "" {
+					rw.Header().Set("Accept-Encoding", tc.accepted)
+				}
+				if encoding != "" && !acceptsEncoding(tc.accepted, encoding) {
+					rw.WriteHeader(http.StatusUnsupportedMediaType)
+					return
+				}
+				_, err := push.ParseRequest(log.NewNopLogger(), "", req, nil, push.BodyLimits{})
+				require.NoError(t, err)
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			serverURL := flagext.URLValue{}
+			require.NoError(t, serverURL.Set(server.URL))
+			c, err := New(prometheus.NewRegistry(), Config{
+				URL:           serverURL,
+				BatchWait:     10 * time.Millisecond,
+				BatchSize:     1 << 20,
+				BackoffConfig: backoff.Config{MinBackoff: 1 * time.Millisecond, MaxBackoff: 2 * time.Millisecond, MaxRetries: 1},
+				Timeout:       1 * time.Second,
+				Compression:   tc.compression,
+			}, log.NewNopLogger())
+			require.NoError(t, err)
+
+			// the batches are sent one at a time.
+			for _, e := range logEntries[:2] {
+				c.Chan() <- e
+				require.Eventually(t, func() bool { return len(encodings) > 0 }, time.Second, time.Millisecond)
+				time.Sleep(20 * time.Millisecond)
+			}
+			c.Stop()
+			close(encodings)
+
+			var actual []string
+			for e := range encodings {
+				actual = append(actual, e)
+			}
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestClient_InvalidCompression(t *testing.T) {
+	cfg := testOTLPConfig(t, "http://localhost:3100", ProtocolLoki)
+	cfg.Compression = "lz4"
+	_, err := New(prometheus.NewRegistry(), cfg, log.NewNopLogger())
+	require.EqualError(t, err, `unknown compression "lz4", must be one of snappy, gzip, zstd or auto`)
+
+	cfg.Compression = CompressionZstd
+	cfg.Protocol = ProtocolOTLPHTTP
+	_, err = New(prometheus.NewRegistry(), cfg, log.NewNopLogger())
+	require.EqualError(t, err, "compression zstd is only supported with the loki protocol")
+}
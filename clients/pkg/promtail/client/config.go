@@ -37,8 +37,29 @@ type Config struct {
 	// The tenant ID to use when pushing logs to Loki (empty string means
 	// single tenant mode)
 	TenantID string `yaml:"tenant_id"`
+	// TenantLabel is the label whose value is used as the tenant ID of each entry, so that
+	// a single client pushes the logs of multiple tenants.
+	TenantLabel string `yaml:"tenant_label"`
 
 	StreamLagLabels flagext.StringSliceCSV `yaml:"stream_lag_labels"`
+
+	// Headers are added to every push request, for gateways in front of Loki.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// UnixSocket is the path of the Unix domain socket to connect to, instead of the host of the URL.
+	UnixSocket string `yaml:"unix_socket,omitempty"`
+	// EnableHTTP2 allows the client to negotiate HTTP/2 with TLS endpoints.
+	EnableHTTP2 bool `yaml:"enable_http2"`
+
+	// Protocol is the protocol used to push the logs: loki, otlp_http or otlp_grpc.
+	Protocol string `yaml:"protocol"`
+
+	// Compression is the codec of the push requests to Loki: snappy, gzip, zstd or auto.
+	Compression string `yaml:"compression"`
+	// AdaptiveBatching adapts the batch size and wait to the throughput and the latency.
+	AdaptiveBatching AdaptiveBatchingConfig `yaml:"adaptive_batching"`
+
+	// Spool persists on disk the batches which couldn't be sent after all retries.
+	Spool SpoolConfig `yaml:"spool"`
 }
 
 // RegisterFlags with prefix registers flags where every name is prefixed by
@@ -55,9 +76,17 @@ func (c *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.Var(&c.ExternalLabels, prefix+"client.external-labels", "list of external labels to add to each log (e.g: --client.external-labels=lb1=v1,lb2=v2)")
 
 	f.StringVar(&c.TenantID, prefix+"client.tenant-id", "", "Tenant ID to use when pushing logs to Loki.")
+	f.StringVar(&c.TenantLabel, prefix+"client.tenant-label", "", "Label whose value is used as the tenant ID of each entry pushed to Loki, the tenant ID is used for the entries without this label.")
 
 	c.StreamLagLabels = []string{"filename"}
 	f.Var(&c.StreamLagLabels, prefix+"client.stream-lag-labels", "Comma-separated list of labels to use when calculating stream lag")
+
+	f.StringVar(&c.UnixSocket, prefix+"client.unix-socket", "", "Path of the Unix domain socket to connect to instead of the host of the URL.")
+	f.BoolVar(&c.EnableHTTP2, prefix+"client.enable-http2", false, "Allow HTTP/2 when the server supports it over TLS.")
+	f.StringVar(&c.Protocol, prefix+"client.protocol", ProtocolLoki, "Protocol used to push the logs: loki to push them to Loki, otlp_http or otlp_grpc to export them as OpenTelemetry log records.")
+	f.StringVar(&c.Compression, prefix+"client.compression", CompressionSnappy, "Compression of the push requests to Loki: snappy, gzip, zstd, or auto to use the best one Loki supports.")
+	c.AdaptiveBatching.RegisterFlagsWithPrefix(prefix, f)
+	c.Spool.RegisterFlagsWithPrefix(prefix, f)
 }
 
 // RegisterFlags registers flags.
@@ -84,6 +113,17 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			BatchWait:       BatchWait,
 			Timeout:         Timeout,
 			StreamLagLabels: []string{"filename"},
+			Protocol:        ProtocolLoki,
+			Compression:     CompressionSnappy,
+			AdaptiveBatching: AdaptiveBatchingConfig{
+				MaxFactor:    AdaptiveBatchingMaxFactor,
+				MaxBatchSize: AdaptiveBatchingMaxBatchSize,
+			},
+			Spool: SpoolConfig{
+				MaxSize:       SpoolMaxSize,
+				MaxAge:        SpoolMaxAge,
+				RetryInterval: SpoolRetryInterval,
+			},
 		}
 	}
 
@@ -28,6 +28,12 @@ backoff_config:
 batchwait: 5s
 batchsize: 204800
 timeout: 5s
+compression: auto
+adaptive_batching:
+  enabled: true
+spool:
+  dir: /var/lib/promtail/spool
+  max_size: 100MB
 `
 
 func Test_Config(t *testing.T) {
@@ -52,6 +58,17 @@ func Test_Config(t *testing.T) {
 				BatchWait:       BatchWait,
 				Timeout:         Timeout,
 				StreamLagLabels: []string{"filename"},
+				Protocol:        ProtocolLoki,
+				Compression:     CompressionSnappy,
+				AdaptiveBatching: AdaptiveBatchingConfig{
+					MaxFactor:    AdaptiveBatchingMaxFactor,
+					MaxBatchSize: AdaptiveBatchingMaxBatchSize,
+				},
+				Spool: SpoolConfig{
+					MaxSize:       SpoolMaxSize,
+					MaxAge:        SpoolMaxAge,
+					RetryInterval: SpoolRetryInterval,
+				},
 			},
 		},
 		{
@@ -69,6 +86,19 @@ func Test_Config(t *testing.T) {
 				BatchWait:       5 * time.Second,
 				Timeout:         5 * time.Second,
 				StreamLagLabels: []string{"filename"},
+				Protocol:        ProtocolLoki,
+				Compression:     CompressionAuto,
+				AdaptiveBatching: AdaptiveBatchingConfig{
+					Enabled:      true,
+					MaxFactor:    AdaptiveBatchingMaxFactor,
+					MaxBatchSize: AdaptiveBatchingMaxBatchSize,
+				},
+				Spool: SpoolConfig{
+					Dir:           "/var/lib/promtail/spool",
+					MaxSize:       100 << 20,
+					MaxAge:        SpoolMaxAge,
+					RetryInterval: SpoolRetryInterval,
+				},
 			},
 		},
 	}
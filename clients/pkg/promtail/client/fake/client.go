@@ -25,6 +25,8 @@ func New(stop func()) *Client {
 	go func() {
 		defer c.wg.Done()
 		for e := range c.entries {
+			e.Ack.Done(nil)
+			e.Ack = nil
 			c.mtx.Lock()
 			c.received = append(c.received, e)
 			c.mtx.Unlock()
@@ -36,22 +36,25 @@ type logger struct {
 }
 
 // NewLogger creates a new client logger that logs entries instead of sending them.
+// No client needs to be configured, to try pipelines without Loki.
 func NewLogger(reg prometheus.Registerer, log log.Logger, cfgs ...Config) (Client, error) {
-	// make sure the clients config is valid
-	c, err := NewMulti(reg, log, cfgs...)
-	if err != nil {
-		return nil, err
-	}
-	c.Stop()
-
-	fmt.Println(yellow.Sprint("Clients configured:"))
-	for _, cfg := range cfgs {
-		yaml, err := yaml.Marshal(cfg)
+	if len(cfgs) > 0 {
+		// make sure the clients config is valid
+		c, err := NewMulti(reg, log, cfgs...)
 		if err != nil {
 			return nil, err
 		}
-		fmt.Println("----------------------")
-		fmt.Println(string(yaml))
+		c.Stop()
+
+		fmt.Println(yellow.Sprint("Clients configured:"))
+		for _, cfg := range cfgs {
+			yaml, err := yaml.Marshal(cfg)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Println("----------------------")
+			fmt.Println(string(yaml))
+		}
 	}
 	entries := make(chan api.Entry)
 	l := &logger{
@@ -77,8 +80,13 @@ func (l *logger) run() {
 		fmt.Fprint(l.Writer, yellow.Sprint(e.Labels.String()))
 		fmt.Fprint(l.Writer, "\t")
 		fmt.Fprint(l.Writer, e.Line)
+		for _, m := range e.StructuredMetadata {
+			fmt.Fprint(l.Writer, "\t")
+			fmt.Fprint(l.Writer, yellow.Sprintf("%s=%q", m.Name, m.Value))
+		}
 		fmt.Fprint(l.Writer, "\n")
 		l.Flush()
+		e.Ack.Done(nil)
 	}
 }
 func (l *logger) StopNow() { l.Stop() }
@@ -16,10 +16,16 @@ import (
 )
 
 func TestNewLogger(t *testing.T) {
-	_, err := NewLogger(nil, util_log.Logger, []Config{}...)
+	// no client is needed to print the entries.
+	l, err := NewLogger(nil, util_log.Logger, []Config{}...)
+	require.NoError(t, err)
+	l.Chan() <- api.Entry{Labels: model.LabelSet{"foo": "bar"}, Entry: logproto.Entry{Timestamp: time.Now(), Line: "entry"}}
+	l.Stop()
+
+	_, err = NewLogger(nil, util_log.Logger, []Config{{}}...)
 	require.Error(t, err)
 
-	l, err := NewLogger(nil, util_log.Logger, []Config{{URL: cortexflag.URLValue{URL: &url.URL{Host: "string"}}}}...)
+	l, err = NewLogger(nil, util_log.Logger, []Config{{URL: cortexflag.URLValue{URL: &url.URL{Host: "string"}}}}...)
 	require.NoError(t, err)
 	l.Chan() <- api.Entry{Labels: model.LabelSet{"foo": "bar"}, Entry: logproto.Entry{Timestamp: time.Now(), Line: "entry"}}
 	l.Stop()
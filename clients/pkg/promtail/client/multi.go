@@ -2,6 +2,8 @@ package client
 
 import (
 	"errors"
+	"fmt"
+	"path/filepath"
 	"sync"
 
 	"github.com/go-kit/log"
@@ -25,6 +27,18 @@ func NewMulti(reg prometheus.Registerer, logger log.Logger, cfgs ...Config) (Cli
 		return nil, errors.New("at least one client config should be provided")
 	}
 
+	spoolDirs := map[string]struct{}{}
+	for _, cfg := range cfgs {
+		if cfg.Spool.Dir == "" {
+			continue
+		}
+		dir := filepath.Clean(cfg.Spool.Dir)
+		if _, ok := spoolDirs[dir]; ok {
+			return nil, fmt.Errorf("the spool directory %s is used by several clients", cfg.Spool.Dir)
+		}
+		spoolDirs[dir] = struct{}{}
+	}
+
 	clients := make([]Client, 0, len(cfgs))
 	for _, cfg := range cfgs {
 		client, err := New(reg, cfg, logger)
@@ -46,6 +60,8 @@ func (m *MultiClient) start() {
 	go func() {
 		defer m.wg.Done()
 		for e := range m.entries {
+			// Entries are acknowledged once every client did.
+			e.Ack = e.Ack.Split(len(m.clients))
 			for _, c := range m.clients {
 				c.Chan() <- e
 			}
@@ -53,6 +69,15 @@ func (m *MultiClient) start() {
 	}()
 }
 
+// SetBatchWaitFactor multiplies the batch wait of every client by factor.
+func (m *MultiClient) SetBatchWaitFactor(factor float64) {
+	for _, c := range m.clients {
+		if c, ok := c.(interface{ SetBatchWaitFactor(float64) }); ok {
+			c.SetBatchWaitFactor(factor)
+		}
+	}
+}
+
 func (m *MultiClient) Chan() chan<- api.Entry {
 	return m.entries
 }
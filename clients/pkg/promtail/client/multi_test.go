@@ -101,6 +101,28 @@ func TestMultiClient_Handle(t *testing.T) {
 	}
 }
 
+func TestMultiClient_Handle_Ack(t *testing.T) {
+	f := fake.New(func() {})
+	clients := []Client{f, f, f}
+	m := &MultiClient{
+		clients: clients,
+		entries: make(chan api.Entry),
+	}
+	m.start()
+
+	acks := 0
+	m.Chan() <- api.Entry{
+		Labels: model.LabelSet{"foo": "bar"},
+		Entry:  logproto.Entry{Line: "foo"},
+		Ack:    func(err error) { acks++ },
+	}
+
+	m.Stop()
+
+	require.Len(t, f.Received(), len(clients))
+	require.Equal(t, 1, acks, "the entry must be acknowledged once by all clients")
+}
+
 func TestMultiClient_Handle_Race(t *testing.T) {
 	u := flagext.URLValue{}
 	require.NoError(t, u.Set("http://localhost"))
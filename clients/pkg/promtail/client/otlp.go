@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/prometheus/promql/parser"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/grafana/loki/clients/pkg/promtail/tlspolicy"
+
+	"github.com/grafana/loki/pkg/util/build"
+)
+
+const (
+	// ProtocolLoki pushes the logs to the push API of Loki.
+	ProtocolLoki = "loki"
+	// ProtocolOTLPHTTP exports the logs as OTLP log records over HTTP.
+	ProtocolOTLPHTTP = "otlp_http"
+	// ProtocolOTLPGRPC exports the logs as OTLP log records over gRPC.
+	ProtocolOTLPGRPC = "otlp_grpc"
+
+	otlpExportMethod = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+	otlpScopeName    = "promtail"
+)
+
+// Field numbers of the OTLP logs protobuf messages.
+const (
+	exportLogsRequestResourceLogs = 1
+	resourceLogsResource          = 1
+	resourceLogsScopeLogs         = 2
+	resourceAttributes            = 1
+	scopeLogsScope                = 1
+	scopeLogsLogRecords           = 2
+	scopeName                     = 1
+	scopeVersion                  = 2
+	logRecordTimeUnixNano         = 1
+	logRecordBody                 = 5
+	logRecordAttributes           = 6
+	logRecordObservedTimeUnixNano = 11
+	keyValueKey                   = 1
+	keyValueValue                 = 2
+	anyValueStringValue           = 1
+)
+
+func validateProtocol(cfg Config) error {
+	switch cfg.Protocol {
+	case "", ProtocolLoki, ProtocolOTLPHTTP:
+		return nil
+	case ProtocolOTLPGRPC:
+		if cfg.Client.BasicAuth != nil || cfg.Client.Authorization != nil || cfg.Client.OAuth2 != nil || cfg.Client.BearerToken != "" || cfg.Client.BearerTokenFile != "" {
+			return errors.New("the authentication of the HTTP client is not supported with the otlp_grpc protocol, set the authorization header in headers instead")
+		}
+		if cfg.Client.ProxyURL.URL != nil {
+			return errors.New("proxy_url is not supported with the otlp_grpc protocol")
+		}
+		if cfg.UnixSocket != "" {
+			return errors.New("unix_socket is not supported with the otlp_grpc protocol")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown protocol %q, must be one of %s, %s or %s", cfg.Protocol, ProtocolLoki, ProtocolOTLPHTTP, ProtocolOTLPGRPC)
+	}
+}
+
+// encodeOTLP encodes the batch as an OTLP ExportLogsServiceRequest, and returns
+// the encoded bytes and the number of encoded entries. The labels of each stream
+// are the attributes of its resource, the structured metadata of each entry the
+// attributes of its log record.
+func (b *batch) encodeOTLP() ([]byte, int, error) {
+	var (
+		buf          []byte
+		entriesCount int
+		resource     []byte
+		scopeLogs    []byte
+		records      []byte
+		record       []byte
+	)
+
+	// The streams are encoded in order so that the same batch is always encoded the same.
+	keys := make([]string, 0, len(b.streams))
+	for k := range b.streams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		stream := b.streams[k]
+		lbls, err := parser.ParseMetric(stream.Labels)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		resource = resource[:0]
+		for _, l := range lbls {
+			resource = appendOTLPAttribute(resource, resourceAttributes, l.Name, l.Value)
+		}
+
+		records = records[:0]
+		for _, e := range stream.Entries {
+			record = record[:0]
+			record = protowire.AppendTag(record, logRecordTimeUnixNano, protowire.Fixed64Type)
+			record = protowire.AppendFixed64(record, uint64(e.Timestamp.UnixNano()))
+			record = protowire.AppendTag(record, logRecordBody, protowire.BytesType)
+			record = protowire.AppendVarint(record, uint64(protowire.SizeTag(anyValueStringValue)+protowire.SizeBytes(len(e.Line))))
+			record = protowire.AppendTag(record, anyValueStringValue, protowire.BytesType)
+			record = protowire.AppendString(record, e.Line)
+			for _, m := range e.StructuredMetadata {
+				record = appendOTLPAttribute(record, logRecordAttributes, m.Name, m.Value)
+			}
+			record = protowire.AppendTag(record, logRecordObservedTimeUnixNano, protowire.Fixed64Type)
+			record = protowire.AppendFixed64(record, uint64(e.Timestamp.UnixNano()))
+
+			records = protowire.AppendTag(records, scopeLogsLogRecords, protowire.BytesType)
+			records = protowire.AppendBytes(records, record)
+			entriesCount++
+		}
+
+		scopeLogs = scopeLogs[:0]
+		scopeLogs = protowire.AppendTag(scopeLogs, scopeLogsScope, protowire.BytesType)
+		scopeLogs = protowire.AppendBytes(scopeLogs, appendOTLPScope(nil))
+		scopeLogs = append(scopeLogs, records...)
+
+		size := protowire.SizeTag(resourceLogsResource) + protowire.SizeBytes(len(resource)) +
+			protowire.SizeTag(resourceLogsScopeLogs) + protowire.SizeBytes(len(scopeLogs))
+		buf = protowire.AppendTag(buf, exportLogsRequestResourceLogs, protowire.BytesType)
+		buf = protowire.AppendVarint(buf, uint64(size))
+		buf = protowire.AppendTag(buf, resourceLogsResource, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, resource)
+		buf = protowire.AppendTag(buf, resourceLogsScopeLogs, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, scopeLogs)
+	}
+	return buf, entriesCount, nil
+}
+
+// appendOTLPAttribute appends a KeyValue with a string value as the field num.
+func appendOTLPAttribute(b []byte, num protowire.Number, key, value string) []byte {
+	valueSize := protowire.SizeTag(anyValueStringValue) + protowire.SizeBytes(len(value))
+	size := protowire.SizeTag(keyValueKey) + protowire.SizeBytes(len(key)) +
+		protowire.SizeTag(keyValueValue) + protowire.SizeBytes(valueSize)
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	b = protowire.AppendVarint(b, uint64(size))
+	b = protowire.AppendTag(b, keyValueKey, protowire.BytesType)
+	b = protowire.AppendString(b, key)
+	b = protowire.AppendTag(b, keyValueValue, protowire.BytesType)
+	b = protowire.AppendVarint(b, uint64(valueSize))
+	b = protowire.AppendTag(b, anyValueStringValue, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// appendOTLPScope appends the InstrumentationScope identifying Promtail.
+func appendOTLPScope(b []byte) []byte {
+	b = protowire.AppendTag(b, scopeName, protowire.BytesType)
+	b = protowire.AppendString(b, otlpScopeName)
+	b = protowire.AppendTag(b, scopeVersion, protowire.BytesType)
+	return protowire.AppendString(b, build.Version)
+}
+
+// rawCodec sends and receives messages already encoded.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+// dialOTLP connects to the OTLP gRPC endpoint of the URL, with TLS when its scheme is https.
+func dialOTLP(cfg Config) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{
+		grpc.WithUserAgent(UserAgent),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})),
+	}
+	if cfg.URL.Scheme == "https" {
+		tlsConfig, err := config.NewTLSConfig(&cfg.Client.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+		tlspolicy.Apply(tlsConfig)
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	return grpc.Dial(cfg.URL.Host, opts...)
+}
+
+// sendGRPC exports the encoded batch with the OTLP gRPC endpoint. The gRPC status is
+// converted to the equivalent HTTP status, for the errors to be retried the same.
+func (c *client) sendGRPC(ctx context.Context, tenantID string, buf []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	md := metadata.MD{}
+	for name, value := range c.cfg.Headers {
+		md.Set(name, value)
+	}
+	if tenantID != "" {
+		md.Set("X-Scope-OrgID", tenantID)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	var resp []byte
+	err := c.conn.Invoke(ctx, otlpExportMethod, &buf, &resp)
+	if err == nil {
+		return http.StatusOK, nil
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.Canceled:
+		return -1, err
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests, err
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		return http.StatusInternalServerError, err
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized, err
+	case codes.PermissionDenied:
+		return http.StatusForbidden, err
+	default:
+		return http.StatusBadRequest, err
+	}
+}
@@ -0,0 +1,215 @@
+package client
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+type otlpRecord struct {
+	timestamp  time.Time
+	body       string
+	attributes map[string]string
+}
+
+type otlpResourceLogs struct {
+	attributes map[string]string
+	scope      string
+	records    []otlpRecord
+}
+
+// readOTLPFields returns the bytes and fixed64 fields of a message by field number.
+func readOTLPFields(t *testing.T, b []byte) map[protowire.Number][][]byte {
+	t.Helper()
+	fields := map[protowire.Number][][]byte{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.True(t, n > 0)
+		b = b[n:]
+		var v []byte
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.Fixed64Type:
+			v, n = b[:8], 8
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		require.True(t, n > 0)
+		b = b[n:]
+		fields[num] = append(fields[num], v)
+	}
+	return fields
+}
+
+func decodeOTLPAttributes(t *testing.T, kvs [][]byte) map[string]string {
+	attributes := map[string]string{}
+	for _, kv := range kvs {
+		fields := readOTLPFields(t, kv)
+		value := readOTLPFields(t, fields[keyValueValue][0])
+		attributes[string(fields[keyValueKey][0])] = string(value[anyValueStringValue][0])
+	}
+	return attributes
+}
+
+func decodeOTLP(t *testing.T, b []byte) []otlpResourceLogs {
+	var res []otlpResourceLogs
+	for _, rl := range readOTLPFields(t, b)[exportLogsRequestResourceLogs] {
+		fields := readOTLPFields(t, rl)
+		resource := readOTLPFields(t, fields[resourceLogsResource][0])
+		scopeLogs := readOTLPFields(t, fields[resourceLogsScopeLogs][0])
+		scope := readOTLPFields(t, scopeLogs[scopeLogsScope][0])
+		logs := otlpResourceLogs{
+			attributes: decodeOTLPAttributes(t, resource[resourceAttributes]),
+			scope:      string(scope[scopeName][0]),
+		}
+		for _, r := range scopeLogs[scopeLogsLogRecords] {
+			record := readOTLPFields(t, r)
+			ts, _ := protowire.ConsumeFixed64(record[logRecordTimeUnixNano][0])
+			body := readOTLPFields(t, record[logRecordBody][0])
+			logs.records = append(logs.records, otlpRecord{
+				timestamp:  time.Unix(0, int64(ts)).UTC(),
+				body:       string(body[anyValueStringValue][0]),
+				attributes: decodeOTLPAttributes(t, record[logRecordAttributes]),
+			})
+		}
+		res = append(res, logs)
+	}
+	return res
+}
+
+var otlpEntries = []api.Entry{
+	{Labels: model.LabelSet{"job": "app"}, Entry: logproto.Entry{Timestamp: time.Unix(1, 0).UTC(), Line: "line1"}},
+	{Labels: model.LabelSet{"job": "app"}, Entry: logproto.Entry{Timestamp: time.Unix(2, 0).UTC(), Line: "line2", StructuredMetadata: []logproto.LabelPair{{Name: "trace_id", Value: "1234"}}}},
+	{Labels: model.LabelSet{"job": "db", "env": "prod"}, Entry: logproto.Entry{Timestamp: time.Unix(3, 0).UTC(), Line: "line3"}},
+}
+
+// expectedOTLPLogs are the otlpEntries encoded, the streams are sorted by labels.
+var expectedOTLPLogs = []otlpResourceLogs{
+	{
+		attributes: map[string]string{"job": "db", "env": "prod"},
+		scope:      "promtail",
+		records: []otlpRecord{
+			{timestamp: time.Unix(3, 0).UTC(), body: "line3", attributes: map[string]string{}},
+		},
+	},
+	{
+		attributes: map[string]string{"job": "app"},
+		scope:      "promtail",
+		records: []otlpRecord{
+			{timestamp: time.Unix(1, 0).UTC(), body: "line1", attributes: map[string]string{}},
+			{timestamp: time.Unix(2, 0).UTC(), body: "line2", attributes: map[string]string{"trace_id": "1234"}},
+		},
+	},
+}
+
+func Test_batch_encodeOTLP(t *testing.T) {
+	b := newBatch(otlpEntries...)
+	buf, entries, err := b.encodeOTLP()
+	require.NoError(t, err)
+	require.Equal(t, 3, entries)
+	require.Equal(t, expectedOTLPLogs, decodeOTLP(t, buf))
+}
+
+func testOTLPConfig(t *testing.T, rawURL, protocol string) Config {
+	serverURL := flagext.URLValue{}
+	require.NoError(t, serverURL.Set(rawURL))
+	return Config{
+		URL:           serverURL,
+		BatchWait:     10 * time.Millisecond,
+		BatchSize:     1 << 20,
+		BackoffConfig: backoff.Config{MinBackoff: 1 * time.Millisecond, MaxBackoff: 2 * time.Millisecond, MaxRetries: 1},
+		Timeout:       1 * time.Second,
+		TenantID:      "tenant",
+		Protocol:      protocol,
+	}
+}
+
+func TestClient_OTLPHTTP(t *testing.T) {
+	type request struct {
+		path, contentType, tenantID string
+		body                        []byte
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		requests <- request{req.URL.Path, req.Header.Get("Content-Type"), req.Header.Get("X-Scope-OrgID"), body}
+	}))
+	defer server.Close()
+
+	c, err := New(prometheus.NewRegistry(), testOTLPConfig(t, server.URL+"/v1/logs", ProtocolOTLPHTTP), log.NewNopLogger())
+	require.NoError(t, err)
+	for _, e := range otlpEntries {
+		c.Chan() <- e
+	}
+	c.Stop()
+
+	req := <-requests
+	require.Equal(t, "/v1/logs", req.path)
+	require.Equal(t, "application/x-protobuf", req.contentType)
+	require.Equal(t, "tenant", req.tenantID)
+	require.Equal(t, expectedOTLPLogs, decodeOTLP(t, req.body))
+}
+
+func TestClient_OTLPGRPC(t *testing.T) {
+	type request struct {
+		method, tenantID string
+		body             []byte
+	}
+	requests := make(chan request, 1)
+	server := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}), grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		var body []byte
+		if err := stream.RecvMsg(&body); err != nil {
+			return err
+		}
+		requests <- request{method, md.Get("X-Scope-OrgID")[0], body}
+		return stream.SendMsg(&[]byte{})
+	}))
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	c, err := New(prometheus.NewRegistry(), testOTLPConfig(t, "http://"+lis.Addr().String(), ProtocolOTLPGRPC), log.NewNopLogger())
+	require.NoError(t, err)
+	for _, e := range otlpEntries {
+		c.Chan() <- e
+	}
+	c.Stop()
+
+	req := <-requests
+	require.Equal(t, otlpExportMethod, req.method)
+	require.Equal(t, "tenant", req.tenantID)
+	require.Equal(t, expectedOTLPLogs, decodeOTLP(t, req.body))
+}
+
+func TestClient_InvalidProtocol(t *testing.T) {
+	cfg := testOTLPConfig(t, "http://localhost:3100", "otlp")
+	_, err := New(prometheus.NewRegistry(), cfg, log.NewNopLogger())
+	require.EqualError(t, err, `unknown protocol "otlp", must be one of loki, otlp_http or otlp_grpc`)
+
+	cfg.Protocol = ProtocolOTLPGRPC
+	cfg.UnixSocket = "/tmp/collector.sock"
+	_, err = New(prometheus.NewRegistry(), cfg, log.NewNopLogger())
+	require.EqualError(t, err, "unix_socket is not supported with the otlp_grpc protocol")
+}
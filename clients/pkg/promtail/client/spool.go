@@ -0,0 +1,277 @@
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	lokiflag "github.com/grafana/loki/pkg/util/flagext"
+)
+
+const (
+	spoolFileExt = ".batch"
+	spoolTmpExt  = ".tmp"
+
+	SpoolMaxSize       = 1 << 30
+	SpoolMaxAge        = 24 * time.Hour
+	SpoolRetryInterval = 10 * time.Second
+)
+
+// SpoolConfig configures the spool persisting on disk the batches which couldn't be sent
+// after all retries, to send them again later, including after a restart.
+type SpoolConfig struct {
+	// Dir is the directory of the spool, the spool is disabled when empty.
+	Dir string `yaml:"dir"`
+	// MaxSize is the maximum size of the spool, the oldest batches are evicted beyond.
+	MaxSize lokiflag.ByteSize `yaml:"max_size"`
+	// MaxAge is the maximum age of a spooled batch, older batches are evicted.
+	MaxAge        time.Duration `yaml:"max_age"`
+	RetryInterval time.Duration `yaml:"retry_interval"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by prefix.
+func (c *SpoolConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&c.Dir, prefix+"client.spool.dir", "", "Directory where the batches which couldn't be sent after all retries are persisted to be sent again later. Disabled when empty.")
+	c.MaxSize = SpoolMaxSize
+	f.Var(&c.MaxSize, prefix+"client.spool.max-size", "Maximum size of the spool on disk, the oldest batches are evicted beyond.")
+	f.DurationVar(&c.MaxAge, prefix+"client.spool.max-age", SpoolMaxAge, "Maximum age of a spooled batch, older batches are evicted.")
+	f.DurationVar(&c.RetryInterval, prefix+"client.spool.retry-interval", SpoolRetryInterval, "Interval at which the spooled batches are sent again.")
+}
+
+func (c *SpoolConfig) validate() error {
+	if c.Dir == "" {
+		return nil
+	}
+	if c.MaxSize == 0 {
+		return errors.New("the spool max size must be greater than 0")
+	}
+	if c.MaxAge <= 0 {
+		return errors.New("the spool max age must be greater than 0")
+	}
+	if c.RetryInterval <= 0 {
+		return errors.New("the spool retry interval must be greater than 0")
+	}
+	return nil
+}
+
+type spoolFile struct {
+	name      string
+	size      int64
+	createdAt time.Time
+}
+
+// spooledBatch is a batch read back from the spool.
+type spooledBatch struct {
+	file     spoolFile
+	tenantID string
+	entries  int
+	buf      []byte
+}
+
+// spool persists encoded batches in a directory, one file per batch named after its creation
+// time, so that they are read back in the order they were written.
+type spool struct {
+	cfg     SpoolConfig
+	logger  log.Logger
+	metrics *metrics
+	host    string
+	now     func() time.Time
+
+	mtx   sync.Mutex
+	files []spoolFile
+	size  int64
+	last  int64
+}
+
+func newSpool(cfg SpoolConfig, m *metrics, host string, logger log.Logger) (*spool, error) {
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{
+		cfg:     cfg,
+		logger:  logger,
+		metrics: m,
+		host:    host,
+		now:     time.Now,
+	}
+	for _, info := range infos {
+		name := info.Name()
+		switch {
+		case strings.HasSuffix(name, spoolTmpExt):
+			// A batch which was being written when Promtail stopped.
+			if err := os.Remove(filepath.Join(cfg.Dir, name)); err != nil {
+				return nil, err
+			}
+		case strings.HasSuffix(name, spoolFileExt):
+			ts, err := strconv.ParseInt(strings.TrimSuffix(name, spoolFileExt), 10, 64)
+			if err != nil {
+				level.Warn(logger).Log("msg", "ignoring unknown file in the spool directory", "file", name)
+				continue
+			}
+			s.files = append(s.files, spoolFile{name: name, size: info.Size(), createdAt: time.Unix(0, ts)})
+			s.size += info.Size()
+			if ts > s.last {
+				s.last = ts
+			}
+		}
+	}
+	sort.Slice(s.files, func(i, j int) bool { return s.files[i].createdAt.Before(s.files[j].createdAt) })
+	s.updateSize()
+	return s, nil
+}
+
+// pending returns whether batches are waiting in the spool.
+func (s *spool) pending() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.files) > 0
+}
+
+// append persists a batch, evicting the oldest batches if the spool is full.
+func (s *spool) append(tenantID string, entries int, buf []byte) error {
+	header := make([]byte, 0, 2*binary.MaxVarintLen64+len(tenantID))
+	header = appendUvarint(header, uint64(len(tenantID)))
+	header = append(header, tenantID...)
+	header = appendUvarint(header, uint64(entries))
+	size := int64(len(header) + len(buf))
+	if size > int64(s.cfg.MaxSize) {
+		return fmt.Errorf("batch of %d bytes is larger than the spool", size)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// The names must increase for the batches to be read in order.
+	ts := s.now().UnixNano()
+	if ts <= s.last {
+		ts = s.last + 1
+	}
+	file := spoolFile{name: fmt.Sprintf("%020d%s", ts, spoolFileExt), size: size, createdAt: time.Unix(0, ts)}
+	if err := writeFileSync(filepath.Join(s.cfg.Dir, file.name), header, buf); err != nil {
+		return err
+	}
+	s.last = ts
+
+	for len(s.files) > 0 && s.size+size > int64(s.cfg.MaxSize) {
+		level.Warn(s.logger).Log("msg", "spool is full, evicting the oldest batch", "file", s.files[0].name)
+		s.evictOldest()
+	}
+	s.files = append(s.files, file)
+	s.size += size
+	s.updateSize()
+	return nil
+}
+
+// oldest returns the oldest batch of the spool, nil if the spool is empty.
+// Batches older than the max age are evicted.
+func (s *spool) oldest() (*spooledBatch, error) {
+	s.mtx.Lock()
+	for len(s.files) > 0 && s.now().Sub(s.files[0].createdAt) > s.cfg.MaxAge {
+		level.Warn(s.logger).Log("msg", "evicting a batch older than the spool max age", "file", s.files[0].name)
+		s.evictOldest()
+	}
+	s.updateSize()
+	if len(s.files) == 0 {
+		s.mtx.Unlock()
+		return nil, nil
+	}
+	file := s.files[0]
+	s.mtx.Unlock()
+
+	b, err := ioutil.ReadFile(filepath.Join(s.cfg.Dir, file.name))
+	if err != nil {
+		return nil, err
+	}
+	batch := &spooledBatch{file: file}
+	tenantLen, n := binary.Uvarint(b)
+	if n <= 0 || uint64(len(b)-n) < tenantLen {
+		return batch, fmt.Errorf("corrupted spool file %s", file.name)
+	}
+	b = b[n:]
+	batch.tenantID, b = string(b[:tenantLen]), b[tenantLen:]
+	entries, n := binary.Uvarint(b)
+	if n <= 0 {
+		return batch, fmt.Errorf("corrupted spool file %s", file.name)
+	}
+	batch.entries, batch.buf = int(entries), b[n:]
+	return batch, nil
+}
+
+// remove removes a batch returned by oldest, once sent.
+func (s *spool) remove(file spoolFile) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for i, f := range s.files {
+		if f.name != file.name {
+			continue
+		}
+		s.files = append(s.files[:i], s.files[i+1:]...)
+		s.size -= f.size
+		s.updateSize()
+		if err := os.Remove(filepath.Join(s.cfg.Dir, f.name)); err != nil {
+			level.Error(s.logger).Log("msg", "error removing a spooled batch", "file", f.name, "error", err)
+		}
+		return
+	}
+}
+
+// evictOldest must be called with the lock held.
+func (s *spool) evictOldest() {
+	f := s.files[0]
+	s.files = s.files[1:]
+	s.size -= f.size
+	s.metrics.spoolEvictedBytes.WithLabelValues(s.host).Add(float64(f.size))
+	if err := os.Remove(filepath.Join(s.cfg.Dir, f.name)); err != nil {
+		level.Error(s.logger).Log("msg", "error removing an evicted batch", "file", f.name, "error", err)
+	}
+}
+
+// updateSize must be called with the lock held.
+func (s *spool) updateSize() {
+	s.metrics.spoolSize.WithLabelValues(s.host).Set(float64(s.size))
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+// writeFileSync writes a file durably: it's written to a temporary file first, then renamed.
+func writeFileSync(name string, data ...[]byte) error {
+	tmp := strings.TrimSuffix(name, spoolFileExt) + spoolTmpExt
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	for _, d := range data {
+		if _, err := f.Write(d); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+
+	lokiflag "github.com/grafana/loki/pkg/util/flagext"
+)
+
+func newTestSpool(t *testing.T, dir string, maxSize int) (*spool, *time.Time) {
+	t.Helper()
+	s, err := newSpool(SpoolConfig{Dir: dir, MaxSize: lokiflag.ByteSize(maxSize), MaxAge: time.Hour}, newMetrics(nil), "host", log.NewNopLogger())
+	require.NoError(t, err)
+	now := time.Unix(1000, 0)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func Test_spool(t *testing.T) {
+	dir := t.TempDir()
+	s, now := newTestSpool(t, dir, 100)
+	require.False(t, s.pending())
+
+	require.NoError(t, s.append("tenant-1", 2, []byte("batch1")))
+	require.NoError(t, s.append("", 1, []byte("batch2")))
+	require.True(t, s.pending())
+
+	// a batch being written when Promtail stopped is discarded at startup.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "1.tmp"), []byte("partial"), 0600))
+
+	// batches are read back in order, after a restart too.
+	s, _ = newTestSpool(t, dir, 100)
+	*now = time.Unix(1000, 0)
+	b, err := s.oldest()
+	require.NoError(t, err)
+	require.Equal(t, "tenant-1", b.tenantID)
+	require.Equal(t, 2, b.entries)
+	require.Equal(t, []byte("batch1"), b.buf)
+	s.remove(b.file)
+
+	b, err = s.oldest()
+	require.NoError(t, err)
+	require.Equal(t, "", b.tenantID)
+	require.Equal(t, []byte("batch2"), b.buf)
+	s.remove(b.file)
+
+	b, err = s.oldest()
+	require.NoError(t, err)
+	require.Nil(t, b)
+	require.False(t, s.pending())
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func Test_spoolEviction(t *testing.T) {
+	s, now := newTestSpool(t, t.TempDir(), 30)
+
+	// each file is 10 bytes of batch and 2 bytes of header.
+	for _, buf := range []string{"batch00001", "batch00002", "batch00003"} {
+		require.NoError(t, s.append("", 1, []byte(buf)))
+	}
+	b, err := s.oldest()
+	require.NoError(t, err)
+	require.Equal(t, "batch00002", string(b.buf))
+	require.Equal(t, int64(24), s.size)
+
+	require.Error(t, s.append("", 1, make([]byte, 30)))
+
+	*now = now.Add(2 * time.Hour)
+	require.NoError(t, s.append("", 1, []byte("batch00004")))
+	b, err = s.oldest()
+	require.NoError(t, err)
+	require.Equal(t, "batch00004", string(b.buf))
+	require.Equal(t, int64(12), s.size)
+}
+
+func TestClient_Spool(t *testing.T) {
+	receivedReqsChan := make(chan receivedReq, 10)
+	var status int32 = http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		code := int(atomic.LoadInt32(&status))
+		if code == http.StatusOK {
+			createServerHandler(receivedReqsChan, code)(rw, req)
+			return
+		}
+		rw.WriteHeader(code)
+	}))
+	defer server.Close()
+
+	serverURL := flagext.URLValue{}
+	require.NoError(t, serverURL.Set(server.URL))
+
+	dir := t.TempDir()
+	cfg := Config{
+		URL:           serverURL,
+		BatchWait:     10 * time.Millisecond,
+		BatchSize:     10,
+		BackoffConfig: backoff.Config{MinBackoff: 1 * time.Millisecond, MaxBackoff: 2 * time.Millisecond, MaxRetries: 1},
+		Timeout:       1 * time.Second,
+		Spool:         SpoolConfig{Dir: dir, MaxSize: 1 << 20, MaxAge: time.Hour, RetryInterval: 10 * time.Millisecond},
+	}
+
+	// entries which can't be sent are acknowledged once spooled.
+	c, err := New(prometheus.NewRegistry(), cfg, log.NewNopLogger())
+	require.NoError(t, err)
+	acked := make(chan error, 3)
+	for _, e := range logEntries[:3] {
+		c.Chan() <- api.Entry{Labels: e.Labels, Entry: e.Entry, Ack: func(err error) { acked <- err }}
+		time.Sleep(20 * time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, <-acked)
+	}
+	c.Stop()
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	// the spooled batches are sent after a restart, in order.
+	atomic.StoreInt32(&status, http.StatusOK)
+	c, err = New(prometheus.NewRegistry(), cfg, log.NewNopLogger())
+	require.NoError(t, err)
+	c.Chan() <- logEntries[3]
+
+	var lines []string
+	require.Eventually(t, func() bool {
+		for {
+			select {
+			case req := <-receivedReqsChan:
+				for _, s := range req.pushReq.Streams {
+					for _, e := range s.Entries {
+						lines = append(lines, e.Line)
+					}
+				}
+			default:
+				return len(lines) == 4
+			}
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+	c.Stop()
+	require.Equal(t, []string{"line1", "line2", "line3", "line4"}, lines)
+
+	files, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
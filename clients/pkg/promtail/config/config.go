@@ -7,10 +7,12 @@ import (
 	yaml "gopkg.in/yaml.v2"
 
 	"github.com/grafana/loki/clients/pkg/promtail/client"
+	"github.com/grafana/loki/clients/pkg/promtail/limits"
 	"github.com/grafana/loki/clients/pkg/promtail/positions"
 	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
 	"github.com/grafana/loki/clients/pkg/promtail/server"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/file"
+	"github.com/grafana/loki/clients/pkg/promtail/tlspolicy"
 
 	"github.com/grafana/loki/pkg/util/flagext"
 )
@@ -24,6 +26,10 @@ type Config struct {
 	PositionsConfig positions.Config      `yaml:"positions,omitempty"`
 	ScrapeConfig    []scrapeconfig.Config `yaml:"scrape_configs,omitempty"`
 	TargetConfig    file.Config           `yaml:"target_config,omitempty"`
+	LimitsConfig    limits.Config         `yaml:"resource_limits,omitempty"`
+	TLSPolicy       tlspolicy.Config      `yaml:"tls_policy,omitempty"`
+	// Presets expand to a maintained set of scrape configs, see ExpandPresets.
+	Presets []string `yaml:"presets,omitempty"`
 }
 
 // RegisterFlags with prefix registers flags where every name is prefixed by
@@ -33,6 +39,8 @@ func (c *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	c.ClientConfig.RegisterFlagsWithPrefix(prefix, f)
 	c.PositionsConfig.RegisterFlagsWithPrefix(prefix, f)
 	c.TargetConfig.RegisterFlagsWithPrefix(prefix, f)
+	c.LimitsConfig.RegisterFlagsWithPrefix(prefix, f)
+	c.TLSPolicy.RegisterFlagsWithPrefix(prefix, f)
 }
 
 // RegisterFlags registers flags.
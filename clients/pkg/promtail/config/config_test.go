@@ -4,14 +4,20 @@ import (
 	"fmt"
 	"net/url"
 	"testing"
+	"time"
 
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	dskitflagext "github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 
+	"github.com/grafana/loki/clients/pkg/logentry/stages"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
 	"github.com/grafana/loki/clients/pkg/promtail/client"
 
+	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/util/flagext"
 )
 
@@ -124,3 +130,68 @@ func mustURL(u string) *url.URL {
 	}
 	return res
 }
+
+func TestConfig_ExpandPresets(t *testing.T) {
+	for name := range presets {
+		cfg := Config{Presets: []string{name}}
+		require.NoError(t, cfg.ExpandPresets(), name)
+		require.NotEmpty(t, cfg.ScrapeConfig, name)
+	}
+
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+presets: [linux_host, kubernetes_node]
+scrape_configs:
+  - job_name: varlogs
+    static_configs:
+    - targets:
+      - localhost
+      labels:
+        __path__: /var/log/syslog
+`), &cfg))
+	require.NoError(t, cfg.ExpandPresets())
+	require.Nil(t, cfg.Presets)
+
+	var jobs []string
+	for _, sc := range cfg.ScrapeConfig {
+		jobs = append(jobs, sc.JobName)
+	}
+	require.Equal(t, []string{"varlogs", "journal", "kubernetes-pods"}, jobs)
+	require.Equal(t, model.LabelValue("/var/log/syslog"), cfg.ScrapeConfig[0].ServiceDiscoveryConfig.StaticConfigs[0].Labels["__path__"])
+	require.Equal(t, "12h", cfg.ScrapeConfig[1].JournalConfig.MaxAge)
+
+	cfg = Config{Presets: []string{"windows_host"}}
+	require.EqualError(t, cfg.ExpandPresets(), `unknown preset "windows_host", valid presets are docker_host, kubernetes_node, linux_host`)
+}
+
+func TestConfig_DockerHostPreset(t *testing.T) {
+	cfg := Config{Presets: []string{"docker_host"}}
+	require.NoError(t, cfg.ExpandPresets())
+	require.Len(t, cfg.ScrapeConfig, 1)
+
+	sc := cfg.ScrapeConfig[0]
+	p, err := stages.NewPipeline(util_log.Logger, sc.PipelineStages, &sc.JobName, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	in := make(chan stages.Entry, 1)
+	out := p.Run(in)
+	in <- stages.Entry{
+		Extracted: map[string]interface{}{},
+		Entry: api.Entry{
+			Labels: model.LabelSet{
+				"job":      "docker",
+				"filename": "/var/lib/docker/containers/3f4e5a/3f4e5a-json.log",
+			},
+			Entry: logproto.Entry{
+				Timestamp: time.Now(),
+				Line:      `{"log":"hello\n","stream":"stdout","time":"2021-11-02T10:00:00.000000000Z"}`,
+			},
+		},
+	}
+	close(in)
+	e := <-out
+
+	require.Equal(t, "hello\n", e.Line)
+	require.Equal(t, model.LabelValue("3f4e5a"), e.Labels["container_id"])
+	require.Equal(t, model.LabelValue("stdout"), e.Labels["stream"])
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+const journalPreset = `
+- job_name: journal
+  journal:
+    max_age: 12h
+    labels:
+      job: systemd-journal
+  relabel_configs:
+    - source_labels: [__journal__systemd_unit]
+      target_label: unit
+    - source_labels: [__journal__hostname]
+      target_label: hostname
+`
+
+// presets maps the name of a preset to the scrape configs it expands to.
+var presets = map[string]string{
+	"linux_host": journalPreset + `
+- job_name: varlogs
+  static_configs:
+    - targets: [localhost]
+      labels:
+        job: varlogs
+        __path__: /var/log/*log
+`,
+	"docker_host": `
+- job_name: docker
+  pipeline_stages:
+    - docker: {}
+    - regex:
+        source_label: filename
+        expression: ^/var/lib/docker/containers/(?P<container_id>[^/]+)/
+    - labels:
+        container_id:
+  static_configs:
+    - targets: [localhost]
+      labels:
+        job: docker
+        __path__: /var/lib/docker/containers/*/*-json.log
+`,
+	"kubernetes_node": journalPreset + `
+- job_name: kubernetes-pods
+  pipeline_stages:
+    - cri: {}
+  kubernetes_sd_configs:
+    - role: pod
+  relabel_configs:
+    - source_labels: [__meta_kubernetes_pod_controller_name]
+      regex: ([0-9a-z-.]+?)(-[0-9a-f]{8,10})?
+      target_label: __tmp_controller_name
+    - source_labels:
+        - __meta_kubernetes_pod_label_app_kubernetes_io_name
+        - __meta_kubernetes_pod_label_app
+        - __tmp_controller_name
+        - __meta_kubernetes_pod_name
+      regex: ^;*([^;]+)(;.*)?$
+      target_label: app
+    - source_labels: [__meta_kubernetes_namespace]
+      target_label: namespace
+    - source_labels: [__meta_kubernetes_pod_name]
+      target_label: pod
+    - source_labels: [__meta_kubernetes_pod_container_name]
+      target_label: container
+    - source_labels: [__meta_kubernetes_pod_node_name]
+      target_label: node_name
+    - source_labels: [__meta_kubernetes_pod_node_name]
+      target_label: __host__
+    - source_labels: [__meta_kubernetes_pod_uid, __meta_kubernetes_pod_container_name]
+      separator: /
+      replacement: /var/log/pods/*$1/*.log
+      target_label: __path__
+`,
+}
+
+// ExpandPresets appends the scrape configs of the configured presets to the scrape configs.
+// Jobs already defined in scrape_configs or by a previous preset are not added again,
+// which allows overriding a preset job by defining a job with the same name.
+func (c *Config) ExpandPresets() error {
+	jobs := make(map[string]struct{}, len(c.ScrapeConfig))
+	for _, sc := range c.ScrapeConfig {
+		jobs[sc.JobName] = struct{}{}
+	}
+	for _, name := range c.Presets {
+		preset, ok := presets[name]
+		if !ok {
+			return fmt.Errorf("unknown preset %q, valid presets are %s", name, strings.Join(presetNames(), ", "))
+		}
+		var scrapeConfigs []scrapeconfig.Config
+		if err := yaml.UnmarshalStrict([]byte(preset), &scrapeConfigs); err != nil {
+			return fmt.Errorf("invalid preset %q: %w", name, err)
+		}
+		for _, sc := range scrapeConfigs {
+			if _, ok := jobs[sc.JobName]; ok {
+				continue
+			}
+			jobs[sc.JobName] = struct{}{}
+			c.ScrapeConfig = append(c.ScrapeConfig, sc)
+		}
+	}
+	c.Presets = nil
+	return nil
+}
+
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,257 @@
+// Package convert translates the configurations of other log shippers, Filebeat and Fluent Bit,
+// into Promtail scrape configs. The conversion is a starting point for a migration: the
+// settings which can't be translated are reported as warnings.
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Result is the output of a conversion.
+type Result struct {
+	// ScrapeConfigs are the converted scrape configs, in the format of the Promtail config file.
+	ScrapeConfigs []yaml.MapSlice
+	// Warnings describe the settings which were not converted, or only partially.
+	Warnings []string
+}
+
+func (r *Result) warnf(format string, args ...interface{}) {
+	w := fmt.Sprintf(format, args...)
+	for _, existing := range r.Warnings {
+		if existing == w {
+			return
+		}
+	}
+	r.Warnings = append(r.Warnings, w)
+}
+
+// Marshal returns the scrape configs as a Promtail config file, with the warnings as comments.
+func (r *Result) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, w := range r.Warnings {
+		fmt.Fprintf(&buf, "# WARNING: %s\n", w)
+	}
+	if len(r.Warnings) > 0 {
+		buf.WriteString("\n")
+	}
+	scrapeConfigs := r.ScrapeConfigs
+	if scrapeConfigs == nil {
+		scrapeConfigs = []yaml.MapSlice{}
+	}
+	b, err := yaml.Marshal(yaml.MapSlice{{Key: "scrape_configs", Value: scrapeConfigs}})
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(b)
+	return buf.Bytes(), nil
+}
+
+// job is a scrape config being converted.
+type job struct {
+	name string
+	// paths are the globs of the files to read, for file jobs.
+	paths   []string
+	exclude []string
+	// target is the name of the target config, for the jobs not reading files, such as journal or syslog.
+	// The static labels are added to its config.
+	target       string
+	targetConfig yaml.MapSlice
+	labels       map[string]string
+	// relabelConfigs keep the labels of the target, such as the systemd unit.
+	relabelConfigs []yaml.MapSlice
+	stages         []yaml.MapSlice
+}
+
+func newJob(name string) *job {
+	return &job{name: name, labels: map[string]string{}}
+}
+
+func (j *job) addStage(name string, config interface{}) {
+	j.stages = append(j.stages, yaml.MapSlice{{Key: name, Value: config}})
+}
+
+// addLabel adds a static label, its name is sanitized to be a valid label name.
+func (j *job) addLabel(name, value string) {
+	j.labels[labelName(name)] = value
+}
+
+// relabel keeps the value of a label of the target, which would otherwise be dropped.
+func (j *job) relabel(source, target string) {
+	j.relabelConfigs = append(j.relabelConfigs, yaml.MapSlice{
+		{Key: "source_labels", Value: []string{source}},
+		{Key: "target_label", Value: target},
+	})
+}
+
+// labelSet returns the static labels with the job label first and the other labels sorted.
+func (j *job) labelSet() yaml.MapSlice {
+	labels := yaml.MapSlice{{Key: "job", Value: j.name}}
+	names := make([]string, 0, len(j.labels))
+	for n := range j.labels {
+		if n != "job" {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		labels = append(labels, yaml.MapItem{Key: n, Value: j.labels[n]})
+	}
+	return labels
+}
+
+func (j *job) scrapeConfig() yaml.MapSlice {
+	sc := yaml.MapSlice{{Key: "job_name", Value: j.name}}
+	if len(j.stages) > 0 {
+		sc = append(sc, yaml.MapItem{Key: "pipeline_stages", Value: j.stages})
+	}
+	if j.target != "" {
+		cfg := append(j.targetConfig, yaml.MapItem{Key: "labels", Value: j.labelSet()})
+		sc = append(sc, yaml.MapItem{Key: j.target, Value: cfg})
+	}
+	if len(j.relabelConfigs) > 0 {
+		sc = append(sc, yaml.MapItem{Key: "relabel_configs", Value: j.relabelConfigs})
+	}
+	if len(j.paths) == 0 {
+		return sc
+	}
+	var exclude string
+	switch len(j.exclude) {
+	case 0:
+	case 1:
+		exclude = j.exclude[0]
+	default:
+		exclude = "{" + strings.Join(j.exclude, ",") + "}"
+	}
+	staticConfigs := make([]yaml.MapSlice, 0, len(j.paths))
+	for _, p := range j.paths {
+		labels := append(j.labelSet(), yaml.MapItem{Key: "__path__", Value: p})
+		if exclude != "" {
+			labels = append(labels, yaml.MapItem{Key: "__path_exclude__", Value: exclude})
+		}
+		staticConfigs = append(staticConfigs, yaml.MapSlice{
+			{Key: "targets", Value: []string{"localhost"}},
+			{Key: "labels", Value: labels},
+		})
+	}
+	return append(sc, yaml.MapItem{Key: "static_configs", Value: staticConfigs})
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// labelName sanitizes a field name into a valid label name.
+func labelName(s string) string {
+	s = invalidLabelChars.ReplaceAllString(s, "_")
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+	return s
+}
+
+// keepLines adds a stage dropping the lines which don't match any of the expressions.
+func (j *job) keepLines(expressions []string) {
+	j.addStage("match", yaml.MapSlice{
+		{Key: "selector", Value: fmt.Sprintf(`{job=%s} !~ %s`, strconv.Quote(j.name), strconv.Quote(anyOf(expressions)))},
+		{Key: "action", Value: "drop"},
+	})
+}
+
+// dropLines adds a stage dropping the lines, or the values of an extracted field when
+// source isn't empty, matching any of the expressions.
+func (j *job) dropLines(source string, expressions []string) {
+	cfg := yaml.MapSlice{}
+	if source != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "source", Value: source})
+	}
+	j.addStage("drop", append(cfg, yaml.MapItem{Key: "expression", Value: anyOf(expressions)}))
+}
+
+// anyOf returns an expression matching any of the expressions.
+func anyOf(expressions []string) string {
+	if len(expressions) == 1 {
+		return expressions[0]
+	}
+	groups := make([]string, 0, len(expressions))
+	for _, e := range expressions {
+		groups = append(groups, "(?:"+e+")")
+	}
+	return strings.Join(groups, "|")
+}
+
+// strftimeLayouts are the Go layouts of the strftime directives.
+var strftimeLayouts = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'L': "999999999",
+	'f': "999999999",
+	'b': "Jan",
+	'h': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'p': "PM",
+	'z': "-0700",
+	'Z': "MST",
+	'j': "002",
+	'T': "15:04:05",
+	'D': "01/02/06",
+	'F': "2006-01-02",
+	'R': "15:04",
+	'%': "%",
+}
+
+// strftimeToLayout converts a strftime format into a format of the timestamp stage.
+// The fractional seconds of %L are parsed whatever their number of digits.
+func strftimeToLayout(format string) (string, error) {
+	switch format {
+	case "%s", "%s.%L":
+		return "Unix", nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i == len(format) {
+			return "", fmt.Errorf("time format %q ends with %%", format)
+		}
+		layout, ok := strftimeLayouts[format[i]]
+		if !ok {
+			return "", fmt.Errorf("time format %q: unsupported directive %%%c", format, format[i])
+		}
+		b.WriteString(layout)
+	}
+	return b.String(), nil
+}
+
+// timestampStage adds a timestamp stage parsing the extracted field with the strftime format.
+func (r *Result) timestampStage(j *job, source, format string) {
+	if format == "" {
+		r.warnf("job %s: no time format for the time field %q, the timestamp stage is not added", j.name, source)
+		return
+	}
+	layout, err := strftimeToLayout(format)
+	if err != nil {
+		r.warnf("job %s: %v, the timestamp stage is not added", j.name, err)
+		return
+	}
+	j.addStage("timestamp", yaml.MapSlice{
+		{Key: "source", Value: source},
+		{Key: "format", Value: layout},
+	})
+}
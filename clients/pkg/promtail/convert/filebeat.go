@@ -0,0 +1,467 @@
+package convert
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// fbMap is a Filebeat setting object, with its dotted keys expanded into nested objects.
+type fbMap map[string]interface{}
+
+// expandDotted converts the objects decoded from YAML into fbMaps, Filebeat allows to write
+// `multiline.pattern: x` as well as `multiline: {pattern: x}`.
+func expandDotted(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := fbMap{}
+		for k, v := range v {
+			m.set(strings.Split(fmt.Sprint(k), "."), expandDotted(v))
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = expandDotted(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func (m fbMap) set(path []string, v interface{}) {
+	if len(path) == 1 {
+		if existing, ok := m[path[0]].(fbMap); ok {
+			if nested, ok := v.(fbMap); ok {
+				for k, v := range nested {
+					existing.set([]string{k}, v)
+				}
+				return
+			}
+		}
+		m[path[0]] = v
+		return
+	}
+	nested, ok := m[path[0]].(fbMap)
+	if !ok {
+		nested = fbMap{}
+		m[path[0]] = nested
+	}
+	nested.set(path[1:], v)
+}
+
+func (m fbMap) get(path ...string) interface{} {
+	var v interface{} = m
+	for _, k := range path {
+		nested, ok := v.(fbMap)
+		if !ok {
+			return nil
+		}
+		v = nested[k]
+	}
+	return v
+}
+
+func (m fbMap) has(path ...string) bool {
+	return m.get(path...) != nil
+}
+
+func (m fbMap) string(path ...string) string {
+	v := m.get(path...)
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func (m fbMap) bool(path ...string) bool {
+	b, _ := m.get(path...).(bool)
+	return b
+}
+
+func (m fbMap) strings(path ...string) []string {
+	switch v := m.get(path...).(type) {
+	case []interface{}:
+		res := make([]string, 0, len(v))
+		for _, s := range v {
+			res = append(res, fmt.Sprint(s))
+		}
+		return res
+	case nil, fbMap:
+		return nil
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+func (m fbMap) maps(path ...string) []fbMap {
+	items, _ := m.get(path...).([]interface{})
+	res := make([]fbMap, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(fbMap); ok {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+func (m fbMap) keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flatten returns the leaf values of the object, with their path joined with underscores.
+func (m fbMap) flatten(prefix string, res map[string]string) {
+	for _, k := range m.keys() {
+		name := k
+		if prefix != "" {
+			name = prefix + "_" + k
+		}
+		if nested, ok := m[k].(fbMap); ok {
+			nested.flatten(name, res)
+			continue
+		}
+		res[name] = fmt.Sprint(m[k])
+	}
+}
+
+// Filebeat converts the inputs of a Filebeat config file into scrape configs.
+// The log, filestream, container, docker, journald and syslog inputs are supported, with
+// their multiline, JSON and line filtering settings, and the add_fields, add_labels,
+// drop_event, dissect and timestamp processors.
+func Filebeat(config []byte) (*Result, error) {
+	var raw map[interface{}]interface{}
+	if err := yaml.Unmarshal(config, &raw); err != nil {
+		return nil, errors.Wrap(err, "parsing the Filebeat config")
+	}
+	cfg, _ := expandDotted(raw).(fbMap)
+	inputs := cfg.maps("filebeat", "inputs")
+	if len(inputs) == 0 {
+		return nil, errors.New("no filebeat.inputs in the Filebeat config")
+	}
+	r := &Result{}
+	if cfg.has("filebeat", "modules") || cfg.has("filebeat", "config", "modules") {
+		r.warnf("Filebeat modules are not converted")
+	}
+	names := map[string]int{}
+	for i, in := range inputs {
+		typ := in.string("type")
+		if typ == "" {
+			typ = "log"
+		}
+		name := in.string("id")
+		if name == "" {
+			name = fmt.Sprintf("filebeat-%s-%d", typ, i+1)
+		}
+		if names[name]++; names[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, names[name])
+		}
+		if in.has("enabled") && !in.bool("enabled") {
+			r.warnf("input %s is disabled, it is not converted", name)
+			continue
+		}
+		j := newJob(name)
+		if !r.filebeatTarget(j, typ, in) {
+			continue
+		}
+		r.filebeatParsers(j, typ, in)
+		if lines := in.strings("include_lines"); len(lines) > 0 {
+			j.keepLines(lines)
+		}
+		if lines := in.strings("exclude_lines"); len(lines) > 0 {
+			j.dropLines("", lines)
+		}
+		if fields, ok := in.get("fields").(fbMap); ok {
+			labels := map[string]string{}
+			fields.flatten("", labels)
+			for k, v := range labels {
+				j.addLabel(k, v)
+			}
+		}
+		if tags := in.strings("tags"); len(tags) > 0 {
+			r.warnf("job %s: tags %v are not converted, add them as labels if needed", name, tags)
+		}
+		for _, p := range in.maps("processors") {
+			r.filebeatProcessor(j, "job "+name, p)
+		}
+		for _, p := range cfg.maps("processors") {
+			r.filebeatProcessor(j, "processors", p)
+		}
+		r.ScrapeConfigs = append(r.ScrapeConfigs, j.scrapeConfig())
+	}
+	return r, nil
+}
+
+// filebeatTarget converts the target of an input, it returns false when the input type isn't supported.
+func (r *Result) filebeatTarget(j *job, typ string, in fbMap) bool {
+	switch typ {
+	case "log", "filestream":
+		j.paths = in.strings("paths")
+		if len(j.paths) == 0 {
+			r.warnf("job %s: no paths, the input is not converted", j.name)
+			return false
+		}
+		if exclude := in.strings("exclude_files"); len(exclude) > 0 {
+			r.warnf("job %s: exclude_files %v are regular expressions, convert them to the glob of the __path_exclude__ label", j.name, exclude)
+		}
+	case "container", "docker":
+		j.paths = in.strings("paths")
+		if len(j.paths) == 0 {
+			ids := in.strings("containers", "ids")
+			if len(ids) == 0 {
+				ids = []string{"*"}
+			}
+			for _, id := range ids {
+				j.paths = append(j.paths, "/var/lib/docker/containers/"+id+"/*.log")
+			}
+		}
+		format := in.string("format")
+		if typ == "docker" {
+			format = "docker"
+		}
+		switch format {
+		case "docker":
+			j.addStage("docker", yaml.MapSlice{})
+		case "cri":
+			j.addStage("cri", yaml.MapSlice{})
+		default:
+			r.warnf("job %s: the container format is detected by Filebeat, the cri stage is used, replace it with the docker stage for Docker logs", j.name)
+			j.addStage("cri", yaml.MapSlice{})
+		}
+	case "journald":
+		journal := yaml.MapSlice{}
+		if paths := in.strings("paths"); len(paths) > 0 {
+			journal = append(journal, yaml.MapItem{Key: "path", Value: paths[0]})
+			if len(paths) > 1 {
+				r.warnf("job %s: only the first journal path %s is read", j.name, paths[0])
+			}
+		}
+		matches := in.strings("include_matches")
+		if len(matches) == 0 {
+			matches = in.strings("include_matches", "match")
+		}
+		if len(matches) > 0 {
+			journal = append(journal, yaml.MapItem{Key: "matches", Value: matches})
+		}
+		j.target, j.targetConfig = "journal", journal
+		j.relabel("__journal__systemd_unit", "unit")
+	case "syslog":
+		syslog := yaml.MapSlice{}
+		switch {
+		case in.has("protocol", "udp"):
+			syslog = append(syslog,
+				yaml.MapItem{Key: "listen_address", Value: in.string("protocol", "udp", "host")},
+				yaml.MapItem{Key: "listen_protocol", Value: "udp"},
+			)
+		case in.has("protocol", "tcp"):
+			syslog = append(syslog, yaml.MapItem{Key: "listen_address", Value: in.string("protocol", "tcp", "host")})
+		default:
+			r.warnf("job %s: only the tcp and udp syslog protocols are supported, the input is not converted", j.name)
+			return false
+		}
+		switch format := in.string("format"); format {
+		case "rfc3164":
+			syslog = append(syslog, yaml.MapItem{Key: "syslog_format", Value: "rfc3164"})
+		case "", "auto", "rfc5424":
+		default:
+			r.warnf("job %s: unsupported syslog format %q", j.name, format)
+		}
+		j.target, j.targetConfig = "syslog", syslog
+		j.relabel("__syslog_message_hostname", "host")
+	default:
+		r.warnf("job %s: the %s input type is not supported, it is not converted", j.name, typ)
+		return false
+	}
+	return true
+}
+
+// filebeatParsers converts the settings decoding the lines: the parsers of the filestream input
+// and the multiline and json settings of the log input.
+func (r *Result) filebeatParsers(j *job, typ string, in fbMap) {
+	if typ == "filestream" {
+		for _, p := range in.maps("parsers") {
+			switch {
+			case p.has("multiline"):
+				r.filebeatMultiline(j, p.get("multiline"))
+			case p.has("ndjson"):
+				r.filebeatJSON(j, p.get("ndjson"))
+			case p.has("container"):
+				switch p.string("container", "format") {
+				case "docker":
+					j.addStage("docker", yaml.MapSlice{})
+				default:
+					j.addStage("cri", yaml.MapSlice{})
+				}
+			default:
+				r.warnf("job %s: unsupported parser %v", j.name, p.keys())
+			}
+		}
+		return
+	}
+	if in.has("multiline") {
+		r.filebeatMultiline(j, in.get("multiline"))
+	}
+	if in.has("json") {
+		r.filebeatJSON(j, in.get("json"))
+	}
+}
+
+func (r *Result) filebeatMultiline(j *job, v interface{}) {
+	m, _ := v.(fbMap)
+	if typ := m.string("type"); typ != "" && typ != "pattern" {
+		r.warnf("job %s: the %s multiline type is not supported", j.name, typ)
+		return
+	}
+	pattern := m.string("pattern")
+	// Promtail only supports matching the first line of the blocks: the lines not matching the pattern are appended to the previous line.
+	if pattern == "" || !m.bool("negate") || m.string("match") != "after" {
+		r.warnf("job %s: only the multiline settings with negate: true and match: after are supported, the multiline stage is not added", j.name)
+		return
+	}
+	cfg := yaml.MapSlice{{Key: "firstline", Value: pattern}}
+	if m.has("max_lines") {
+		cfg = append(cfg, yaml.MapItem{Key: "max_lines", Value: m.get("max_lines")})
+	}
+	if m.has("timeout") {
+		cfg = append(cfg, yaml.MapItem{Key: "max_wait_time", Value: m.string("timeout")})
+	}
+	j.addStage("multiline", cfg)
+}
+
+func (r *Result) filebeatJSON(j *job, v interface{}) {
+	m, _ := v.(fbMap)
+	key := m.string("message_key")
+	if key == "" {
+		r.warnf("job %s: the fields of the JSON lines must be listed in the expressions of a json stage", j.name)
+		return
+	}
+	j.addStage("json", yaml.MapSlice{{Key: "expressions", Value: yaml.MapSlice{{Key: key, Value: nil}}}})
+	j.addStage("output", yaml.MapSlice{{Key: "source", Value: key}})
+}
+
+// filebeatProcessor converts a processor of an input, or of the global processors which apply to all the inputs.
+// The warnings are about the scope of the processor, so that they are not repeated for every input.
+func (r *Result) filebeatProcessor(j *job, scope string, p fbMap) {
+	for _, name := range p.keys() {
+		cfg, _ := p.get(name).(fbMap)
+		switch name {
+		case "add_fields":
+			fields, _ := cfg.get("fields").(fbMap)
+			target := cfg.string("target")
+			if !cfg.has("target") {
+				target = "fields"
+			}
+			if target == "fields" {
+				target = ""
+			}
+			labels := map[string]string{}
+			fields.flatten(target, labels)
+			for k, v := range labels {
+				j.addLabel(k, v)
+			}
+		case "add_labels":
+			labels := map[string]string{}
+			if fields, ok := cfg.get("labels").(fbMap); ok {
+				fields.flatten("", labels)
+			}
+			for k, v := range labels {
+				j.addLabel(k, v)
+			}
+		case "drop_event":
+			r.filebeatDropEvent(j, scope, cfg)
+		case "dissect":
+			r.filebeatDissect(j, scope, cfg)
+		case "timestamp":
+			layouts := cfg.strings("layouts")
+			if len(layouts) == 0 {
+				r.warnf("%s: timestamp processor without layouts", scope)
+				continue
+			}
+			ts := yaml.MapSlice{
+				{Key: "source", Value: labelName(cfg.string("field"))},
+				{Key: "format", Value: layouts[0]},
+			}
+			if len(layouts) > 1 {
+				ts = append(ts, yaml.MapItem{Key: "fallback_formats", Value: layouts[1:]})
+			}
+			if tz := cfg.string("timezone"); tz != "" {
+				ts = append(ts, yaml.MapItem{Key: "location", Value: tz})
+			}
+			j.addStage("timestamp", ts)
+		default:
+			r.warnf("%s: the %s processor is not supported", scope, name)
+		}
+	}
+}
+
+// filebeatDropEvent converts the drop_event processors whose condition is on the message.
+func (r *Result) filebeatDropEvent(j *job, scope string, cfg fbMap) {
+	when, _ := cfg.get("when").(fbMap)
+	for _, cond := range when.keys() {
+		fields, _ := when.get(cond).(fbMap)
+		value := fields.string("message")
+		if len(fields) != 1 || value == "" {
+			r.warnf("%s: only the drop_event conditions on the message field are supported", scope)
+			return
+		}
+		switch cond {
+		case "regexp":
+		case "contains":
+			value = regexp.QuoteMeta(value)
+		case "equals":
+			value = "^" + regexp.QuoteMeta(value) + "$"
+		default:
+			r.warnf("%s: the %s condition of drop_event is not supported", scope, cond)
+			return
+		}
+		j.dropLines("", []string{value})
+	}
+}
+
+var dissectKey = regexp.MustCompile(`%\{([^}]*)\}`)
+
+// filebeatDissect converts a dissect tokenizer into a regex stage.
+func (r *Result) filebeatDissect(j *job, scope string, cfg fbMap) {
+	tokenizer := cfg.string("tokenizer")
+	if field := cfg.string("field"); field != "" && field != "message" {
+		r.warnf("%s: only the dissect processors of the message field are supported", scope)
+		return
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	last := 0
+	matches := dissectKey.FindAllStringSubmatchIndex(tokenizer, -1)
+	for i, m := range matches {
+		expr.WriteString(regexp.QuoteMeta(tokenizer[last:m[0]]))
+		last = m[1]
+		key := tokenizer[m[2]:m[3]]
+		padding := strings.HasSuffix(key, "->")
+		key = strings.TrimSuffix(key, "->")
+		value := "(.*?)"
+		if i == len(matches)-1 && last == len(tokenizer) {
+			value = "(.*)"
+		}
+		switch {
+		case key == "" || strings.HasPrefix(key, "?"):
+		case strings.ContainsAny(key[:1], "+&*"):
+			r.warnf("%s: the dissect modifier of %%{%s} is not supported, the value is not extracted", scope, key)
+		default:
+			value = "(?P<" + labelName(key) + ">" + value[1:]
+		}
+		expr.WriteString(value)
+		if padding {
+			expr.WriteString(" *")
+		}
+	}
+	expr.WriteString(regexp.QuoteMeta(tokenizer[last:]))
+	expr.WriteString("$")
+	j.addStage("regex", yaml.MapSlice{{Key: "expression", Value: expr.String()}})
+}
@@ -0,0 +1,191 @@
+package convert
+
+import (
+	"testing"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/clients/pkg/logentry/stages"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+// requireValid checks that the converted config is a valid Promtail config, and returns its scrape configs.
+func requireValid(t *testing.T, res *Result) []scrapeconfig.Config {
+	t.Helper()
+	b, err := res.Marshal()
+	require.NoError(t, err)
+	var cfg struct {
+		ScrapeConfigs []scrapeconfig.Config `yaml:"scrape_configs"`
+	}
+	require.NoError(t, yaml.UnmarshalStrict(b, &cfg), string(b))
+	for _, sc := range cfg.ScrapeConfigs {
+		_, err := stages.NewPipeline(util_log.Logger, sc.PipelineStages, &sc.JobName, prometheus.NewRegistry())
+		require.NoError(t, err, string(b))
+	}
+	return cfg.ScrapeConfigs
+}
+
+const testFilebeatConfig = `
+filebeat.inputs:
+- type: log
+  id: nginx
+  paths:
+    - /var/log/nginx/access.log
+    - /var/log/nginx/error.log
+  exclude_lines: ['^DBG', 'healthcheck']
+  fields:
+    env: prod
+    service.name: nginx
+  multiline.pattern: '^\['
+  multiline.negate: true
+  multiline.match: after
+  multiline.max_lines: 50
+  processors:
+    - dissect:
+        tokenizer: '%{client} - %{?ident} [%{time}] "%{request}"'
+    - timestamp:
+        field: time
+        layouts: ['02/Jan/2006:15:04:05 -0700']
+- type: filestream
+  id: app
+  paths: [/var/log/app/*.json]
+  include_lines: ['ERROR', 'WARN']
+  parsers:
+    - ndjson:
+        message_key: msg
+- type: container
+  format: docker
+- type: journald
+  include_matches.match: [_SYSTEMD_UNIT=sshd.service]
+- type: syslog
+  protocol.udp:
+    host: "0.0.0.0:514"
+- type: kafka
+- type: log
+  enabled: false
+  paths: [/tmp/*.log]
+processors:
+  - add_fields:
+      target: project
+      fields:
+        name: loki
+  - drop_event:
+      when:
+        contains:
+          message: "DEBUG"
+  - drop_fields:
+      fields: [agent]
+`
+
+func TestFilebeat(t *testing.T) {
+	res, err := Filebeat([]byte(testFilebeatConfig))
+	require.NoError(t, err)
+	scs := requireValid(t, res)
+	require.Len(t, scs, 5)
+
+	nginx := res.ScrapeConfigs[0]
+	var out []byte
+	out, err = yaml.Marshal(nginx)
+	require.NoError(t, err)
+	require.Equal(t, `job_name: nginx
+pipeline_stages:
+- multiline:
+    firstline: ^\[
+    max_lines: 50
+- drop:
+    expression: (?:^DBG)|(?:healthcheck)
+- regex:
+    expression: ^(?P<client>.*?) - (.*?) \[(?P<time>.*?)\] "(?P<request>.*?)"$
+- timestamp:
+    source: time
+    format: 02/Jan/2006:15:04:05 -0700
+- drop:
+    expression: DEBUG
+static_configs:
+- targets:
+  - localhost
+  labels:
+    job: nginx
+    env: prod
+    project_name: loki
+    service_name: nginx
+    __path__: /var/log/nginx/access.log
+- targets:
+  - localhost
+  labels:
+    job: nginx
+    env: prod
+    project_name: loki
+    service_name: nginx
+    __path__: /var/log/nginx/error.log
+`, string(out))
+
+	app := scs[1]
+	require.Equal(t, "app", app.JobName)
+	require.Equal(t, stages.PipelineStages{
+		map[interface{}]interface{}{"json": map[interface{}]interface{}{"expressions": map[interface{}]interface{}{"msg": nil}}},
+		map[interface{}]interface{}{"output": map[interface{}]interface{}{"source": "msg"}},
+		map[interface{}]interface{}{"match": map[interface{}]interface{}{"selector": `{job="app"} !~ "(?:ERROR)|(?:WARN)"`, "action": "drop"}},
+		map[interface{}]interface{}{"drop": map[interface{}]interface{}{"expression": "DEBUG"}},
+	}, app.PipelineStages)
+
+	container := scs[2]
+	require.Equal(t, "filebeat-container-3", container.JobName)
+	require.Equal(t, map[interface{}]interface{}{"docker": map[interface{}]interface{}{}}, container.PipelineStages[0])
+	require.Equal(t, "/var/lib/docker/containers/*/*.log", string(container.ServiceDiscoveryConfig.StaticConfigs[0].Labels["__path__"]))
+
+	journal := scs[3]
+	require.Equal(t, []string{"_SYSTEMD_UNIT=sshd.service"}, journal.JournalConfig.Matches)
+	require.Equal(t, "loki", string(journal.JournalConfig.Labels["project_name"]))
+	require.Equal(t, "unit", journal.RelabelConfigs[0].TargetLabel)
+
+	syslog := scs[4]
+	require.Equal(t, "0.0.0.0:514", syslog.SyslogConfig.ListenAddress)
+	require.Equal(t, "udp", syslog.SyslogConfig.ListenProtocol)
+
+	require.Equal(t, []string{
+		"processors: the drop_fields processor is not supported",
+		"job filebeat-kafka-6: the kafka input type is not supported, it is not converted",
+		"input filebeat-log-7 is disabled, it is not converted",
+	}, res.Warnings)
+}
+
+func TestFilebeat_Multiline(t *testing.T) {
+	res, err := Filebeat([]byte(`
+filebeat:
+  inputs:
+  - paths: [/var/log/app.log]
+    multiline:
+      pattern: '^\s'
+      negate: false
+      match: after
+`))
+	require.NoError(t, err)
+	requireValid(t, res)
+	require.Equal(t, []string{"job filebeat-log-1: only the multiline settings with negate: true and match: after are supported, the multiline stage is not added"}, res.Warnings)
+}
+
+func TestFilebeat_Errors(t *testing.T) {
+	_, err := Filebeat([]byte("filebeat.inputs: ["))
+	require.Error(t, err)
+	_, err = Filebeat([]byte("output.elasticsearch: {}"))
+	require.EqualError(t, err, "no filebeat.inputs in the Filebeat config")
+}
+
+func Test_strftimeToLayout(t *testing.T) {
+	for format, want := range map[string]string{
+		"%d/%b/%Y:%H:%M:%S %z":   "02/Jan/2006:15:04:05 -0700",
+		"%Y-%m-%dT%H:%M:%S.%L%z": "2006-01-02T15:04:05.999999999-0700",
+		"%b %e %T":               "Jan _2 15:04:05",
+		"%s":                     "Unix",
+	} {
+		got, err := strftimeToLayout(format)
+		require.NoError(t, err)
+		require.Equal(t, want, got, format)
+	}
+	_, err := strftimeToLayout("%Y %Q")
+	require.Error(t, err)
+}
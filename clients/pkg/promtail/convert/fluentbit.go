@@ -0,0 +1,486 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// fluentBitSection is a section of a Fluent Bit config file in the classic format, such as [INPUT].
+// A key can be set several times, for example the Regex rules of the grep filter.
+type fluentBitSection struct {
+	name    string
+	entries [][2]string
+}
+
+// get returns the first value of the key, keys are case insensitive.
+func (s *fluentBitSection) get(key string) string {
+	for _, e := range s.entries {
+		if strings.EqualFold(e[0], key) {
+			return e[1]
+		}
+	}
+	return ""
+}
+
+func (s *fluentBitSection) all(key string) []string {
+	var values []string
+	for _, e := range s.entries {
+		if strings.EqualFold(e[0], key) {
+			values = append(values, e[1])
+		}
+	}
+	return values
+}
+
+// list returns the comma separated values of the key.
+func (s *fluentBitSection) list(key string) []string {
+	var values []string
+	for _, v := range strings.Split(s.get(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func (s *fluentBitSection) on(key string) bool {
+	switch strings.ToLower(s.get(key)) {
+	case "on", "true", "yes", "1":
+		return true
+	}
+	return false
+}
+
+// fluentBitConfig is a Fluent Bit config file with its included files.
+type fluentBitConfig struct {
+	sections []*fluentBitSection
+	vars     map[string]string
+}
+
+const fluentBitMaxIncludeDepth = 10
+
+// load reads a config file in the classic format, @INCLUDE paths are relative to the directory of the file.
+func (c *fluentBitConfig) load(path string, depth int) error {
+	if depth > fluentBitMaxIncludeDepth {
+		return fmt.Errorf("%s: too many nested @INCLUDE", path)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	var section *fluentBitSection
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = fluentBitVariable.ReplaceAllStringFunc(line, c.expand)
+		key, value := splitFluentBitEntry(line)
+		switch {
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return fmt.Errorf("%s:%d: invalid section %q", path, n, line)
+			}
+			section = &fluentBitSection{name: strings.ToUpper(strings.TrimSpace(line[1 : len(line)-1]))}
+			c.sections = append(c.sections, section)
+		case strings.EqualFold(key, "@INCLUDE"):
+			if !filepath.IsAbs(value) {
+				value = filepath.Join(dir, value)
+			}
+			files, err := filepath.Glob(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("%s:%d: no file matches @INCLUDE %s", path, n, value)
+			}
+			for _, f := range files {
+				if err := c.load(f, depth+1); err != nil {
+					return err
+				}
+			}
+			section = nil
+		case strings.EqualFold(key, "@SET"):
+			kv := strings.SplitN(value, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("%s:%d: invalid @SET %q", path, n, value)
+			}
+			c.vars[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		case section == nil:
+			return fmt.Errorf("%s:%d: %q is not in a section", path, n, line)
+		default:
+			section.entries = append(section.entries, [2]string{key, value})
+		}
+	}
+	return scanner.Err()
+}
+
+var fluentBitVariable = regexp.MustCompile(`\$\{[^}]*\}`)
+
+// expand returns the value of a ${VAR} variable, set with @SET or in the environment.
+func (c *fluentBitConfig) expand(v string) string {
+	name := v[2 : len(v)-1]
+	if value, ok := c.vars[name]; ok {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+func splitFluentBitEntry(line string) (string, string) {
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimSpace(line[i:])
+}
+
+func (c *fluentBitConfig) named(name string) []*fluentBitSection {
+	var sections []*fluentBitSection
+	for _, s := range c.sections {
+		if s.name == name {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// FluentBit converts the inputs of a Fluent Bit config file in the classic format into scrape configs.
+// The parsers are read from the Parsers_File of the [SERVICE] section, and from the given parsers files.
+// The tail, systemd and syslog inputs are supported, with their parsers and the parser, grep, modify,
+// record_modifier and multiline filters matching their tag.
+func FluentBit(configFile string, parsersFiles []string) (*Result, error) {
+	cfg := &fluentBitConfig{vars: map[string]string{}}
+	if err := cfg.load(configFile, 0); err != nil {
+		return nil, errors.Wrap(err, "reading the Fluent Bit config")
+	}
+	for _, s := range cfg.named("SERVICE") {
+		for _, f := range s.all("Parsers_File") {
+			if !filepath.IsAbs(f) {
+				f = filepath.Join(filepath.Dir(configFile), f)
+			}
+			parsersFiles = append(parsersFiles, f)
+		}
+	}
+	parsers := &fluentBitConfig{vars: cfg.vars}
+	for _, f := range parsersFiles {
+		if err := parsers.load(f, 0); err != nil {
+			return nil, errors.Wrap(err, "reading the Fluent Bit parsers")
+		}
+	}
+	inputs := cfg.named("INPUT")
+	if len(inputs) == 0 {
+		return nil, errors.New("no [INPUT] in the Fluent Bit config")
+	}
+
+	c := &fluentBitConverter{
+		Result:           &Result{},
+		parsers:          map[string]*fluentBitSection{},
+		multilineParsers: map[string]*fluentBitSection{},
+	}
+	for _, s := range parsers.named("PARSER") {
+		c.parsers[s.get("Name")] = s
+	}
+	for _, s := range append(parsers.named("MULTILINE_PARSER"), cfg.named("MULTILINE_PARSER")...) {
+		c.multilineParsers[s.get("Name")] = s
+	}
+	filters := cfg.named("FILTER")
+	names := map[string]int{}
+	for i, in := range inputs {
+		plugin := strings.ToLower(in.get("Name"))
+		tag := in.get("Tag")
+		if tag == "" {
+			tag = fmt.Sprintf("%s.%d", plugin, i)
+		}
+		name := in.get("Alias")
+		if name == "" {
+			name = strings.Trim(invalidJobChars.ReplaceAllString(tag, ""), ".")
+		}
+		if name == "" {
+			name = fmt.Sprintf("fluentbit-%s-%d", plugin, i+1)
+		}
+		if names[name]++; names[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, names[name])
+		}
+		j := newJob(name)
+		if !c.input(j, plugin, in) {
+			continue
+		}
+		for _, f := range filters {
+			if matchesTag(f, tag) {
+				c.filter(j, f)
+			}
+		}
+		c.ScrapeConfigs = append(c.ScrapeConfigs, j.scrapeConfig())
+	}
+	return c.Result, nil
+}
+
+var invalidJobChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// matchesTag returns true if the Match or Match_Regex of the filter matches the tag.
+func matchesTag(filter *fluentBitSection, tag string) bool {
+	if pattern := filter.get("Match_Regex"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		return err == nil && re.MatchString(tag)
+	}
+	pattern := filter.get("Match")
+	if pattern == "" {
+		return false
+	}
+	re := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	return regexp.MustCompile(re).MatchString(tag)
+}
+
+type fluentBitConverter struct {
+	*Result
+	parsers          map[string]*fluentBitSection
+	multilineParsers map[string]*fluentBitSection
+}
+
+// input converts the target of an input, it returns false when the input plugin isn't supported.
+func (c *fluentBitConverter) input(j *job, plugin string, in *fluentBitSection) bool {
+	switch plugin {
+	case "tail":
+		j.paths = in.list("Path")
+		if len(j.paths) == 0 {
+			c.warnf("job %s: no Path, the input is not converted", j.name)
+			return false
+		}
+		j.exclude = in.list("Exclude_Path")
+		if parsers := in.list("multiline.parser"); len(parsers) > 0 {
+			c.multiline(j, parsers)
+		} else if in.on("Multiline") {
+			c.firstline(j, in.get("Parser_Firstline"))
+			for _, p := range in.all("Parser_N") {
+				c.warnf("job %s: Parser_N %s is not converted, the multiline blocks are only parsed with Parser_Firstline", j.name, p)
+			}
+		}
+		if in.on("Docker_Mode") {
+			c.warnf("job %s: Docker_Mode is not converted, use a multiline stage to join the lines split by Docker", j.name)
+		}
+		// The Parser is ignored by Fluent Bit when Multiline is on.
+		if p := in.get("Parser"); p != "" && !in.on("Multiline") {
+			c.parser(j, p, "")
+		}
+	case "systemd":
+		journal := yaml.MapSlice{}
+		if path := in.get("Path"); path != "" {
+			journal = append(journal, yaml.MapItem{Key: "path", Value: path})
+		}
+		if matches := in.all("Systemd_Filter"); len(matches) > 0 {
+			journal = append(journal, yaml.MapItem{Key: "matches", Value: matches})
+		}
+		j.target, j.targetConfig = "journal", journal
+		j.relabel("__journal__systemd_unit", "unit")
+	case "syslog":
+		syslog := yaml.MapSlice{}
+		listen, port := in.get("Listen"), in.get("Port")
+		if listen == "" {
+			listen = "0.0.0.0"
+		}
+		if port == "" {
+			port = "5140"
+		}
+		switch mode := strings.ToLower(in.get("Mode")); mode {
+		case "tcp":
+			syslog = append(syslog, yaml.MapItem{Key: "listen_address", Value: listen + ":" + port})
+		case "udp":
+			syslog = append(syslog,
+				yaml.MapItem{Key: "listen_address", Value: listen + ":" + port},
+				yaml.MapItem{Key: "listen_protocol", Value: "udp"},
+			)
+		default:
+			c.warnf("job %s: only the tcp and udp syslog modes are supported, the input is not converted", j.name)
+			return false
+		}
+		if strings.Contains(in.get("Parser"), "rfc3164") {
+			syslog = append(syslog, yaml.MapItem{Key: "syslog_format", Value: "rfc3164"})
+		}
+		j.target, j.targetConfig = "syslog", syslog
+		j.relabel("__syslog_message_hostname", "host")
+	default:
+		c.warnf("job %s: the %s input plugin is not supported, it is not converted", j.name, plugin)
+		return false
+	}
+	return true
+}
+
+func (c *fluentBitConverter) filter(j *job, f *fluentBitSection) {
+	switch plugin := strings.ToLower(f.get("Name")); plugin {
+	case "parser":
+		parsers := f.all("Parser")
+		if len(parsers) == 0 {
+			return
+		}
+		if len(parsers) > 1 {
+			c.warnf("job %s: only the first parser %s of the parser filter is converted", j.name, parsers[0])
+		}
+		c.parser(j, parsers[0], fluentBitSource(f.get("Key_Name")))
+	case "grep":
+		for _, rule := range f.all("Regex") {
+			key, expr := splitFluentBitEntry(rule)
+			if source := fluentBitSource(key); source != "" {
+				c.warnf("job %s: the grep Regex rule on the %s key is not converted, only the rules on the log line are supported", j.name, key)
+				continue
+			}
+			if re, ok := c.regex(j, expr); ok {
+				j.keepLines([]string{re})
+			}
+		}
+		for _, rule := range f.all("Exclude") {
+			key, expr := splitFluentBitEntry(rule)
+			if re, ok := c.regex(j, expr); ok {
+				j.dropLines(fluentBitSource(key), []string{re})
+			}
+		}
+	case "modify":
+		for _, e := range f.entries {
+			switch strings.ToLower(e[0]) {
+			case "name", "match", "match_regex", "alias":
+			case "add", "set":
+				k, v := splitFluentBitEntry(e[1])
+				j.addLabel(k, v)
+			default:
+				c.warnf("job %s: the %s rule of the modify filter is not converted", j.name, e[0])
+			}
+		}
+	case "record_modifier":
+		for _, record := range f.all("Record") {
+			k, v := splitFluentBitEntry(record)
+			j.addLabel(k, v)
+		}
+	case "multiline":
+		c.multiline(j, f.list("multiline.parser"))
+	case "kubernetes":
+		c.warnf("job %s: the kubernetes filter is not converted, use kubernetes_sd_configs to discover the pods and their labels", j.name)
+	default:
+		c.warnf("job %s: the %s filter is not supported", j.name, plugin)
+	}
+}
+
+// fluentBitSource returns the source of the stages parsing a key of the records, empty for the log line.
+func fluentBitSource(key string) string {
+	switch key {
+	case "", "log", "message":
+		return ""
+	}
+	return labelName(key)
+}
+
+// multiline converts the multiline parsers of the tail input or the multiline filter.
+func (c *fluentBitConverter) multiline(j *job, parsers []string) {
+	for _, p := range parsers {
+		switch p {
+		case "docker":
+			j.addStage("docker", yaml.MapSlice{})
+		case "cri":
+			j.addStage("cri", yaml.MapSlice{})
+		default:
+			mp, ok := c.multilineParsers[p]
+			if !ok {
+				c.warnf("job %s: the multiline parser %s is not converted", j.name, p)
+				continue
+			}
+			var firstline string
+			for _, rule := range mp.all("rule") {
+				fields := fluentBitQuoted.FindAllStringSubmatch(rule, -1)
+				if len(fields) == 3 && fields[0][1] == "start_state" {
+					firstline = strings.TrimSuffix(strings.TrimPrefix(fields[1][1], "/"), "/")
+				}
+			}
+			if firstline == "" {
+				c.warnf("job %s: the multiline parser %s has no start_state rule, it is not converted", j.name, p)
+				continue
+			}
+			if re, ok := c.regex(j, firstline); ok {
+				j.addStage("multiline", yaml.MapSlice{{Key: "firstline", Value: re}})
+			}
+		}
+	}
+}
+
+var fluentBitQuoted = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+
+// firstline converts the Parser_Firstline of the old multiline settings of the tail input.
+func (c *fluentBitConverter) firstline(j *job, parser string) {
+	p, ok := c.parsers[parser]
+	if !ok || p.get("Regex") == "" {
+		c.warnf("job %s: Parser_Firstline %s is not a regex parser, the multiline stage is not added", j.name, parser)
+		return
+	}
+	if re, ok := c.regex(j, p.get("Regex")); ok {
+		j.addStage("multiline", yaml.MapSlice{{Key: "firstline", Value: re}})
+	}
+	c.parser(j, parser, "")
+}
+
+// parser adds the stages of a parser, reading the extracted field source or the log line when it's empty.
+func (c *fluentBitConverter) parser(j *job, name, source string) {
+	switch name {
+	case "docker", "cri":
+		if source == "" {
+			j.addStage(name, yaml.MapSlice{})
+			return
+		}
+	}
+	p, ok := c.parsers[name]
+	if !ok {
+		c.warnf("job %s: the parser %s is not defined in the parsers files", j.name, name)
+		return
+	}
+	cfg := yaml.MapSlice{}
+	if source != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "source", Value: source})
+	}
+	timeKey := p.get("Time_Key")
+	switch format := strings.ToLower(p.get("Format")); format {
+	case "regex":
+		re, ok := c.regex(j, p.get("Regex"))
+		if !ok {
+			return
+		}
+		j.addStage("regex", append(yaml.MapSlice{{Key: "expression", Value: re}}, cfg...))
+	case "json", "logfmt":
+		if timeKey == "" {
+			c.warnf("job %s: the fields of the %s parser %s must be listed in a %s stage", j.name, format, name, format)
+			return
+		}
+		key := "expressions"
+		if format == "logfmt" {
+			key = "mapping"
+		}
+		j.addStage(format, append(yaml.MapSlice{{Key: key, Value: yaml.MapSlice{{Key: timeKey, Value: nil}}}}, cfg...))
+	default:
+		c.warnf("job %s: the %s format of the parser %s is not supported", j.name, format, name)
+		return
+	}
+	if timeKey != "" {
+		c.timestampStage(j, timeKey, p.get("Time_Format"))
+	}
+	if p.get("Time_Offset") != "" {
+		c.warnf("job %s: the Time_Offset of the parser %s is not converted, set the location of the timestamp stage", j.name, name)
+	}
+}
+
+var onigmoNamedGroup = regexp.MustCompile(`\(\?<([a-zA-Z_][a-zA-Z0-9_]*)>`)
+
+// regex converts a regular expression of Fluent Bit, which uses the Ruby syntax, into a Go one.
+func (c *fluentBitConverter) regex(j *job, expr string) (string, bool) {
+	re := onigmoNamedGroup.ReplaceAllString(expr, "(?P<$1>")
+	if _, err := regexp.Compile(re); err != nil {
+		c.warnf("job %s: the regular expression %q is not supported: %v", j.name, expr, err)
+		return "", false
+	}
+	return re, true
+}
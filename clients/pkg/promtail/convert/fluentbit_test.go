@@ -0,0 +1,178 @@
+package convert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/clients/pkg/logentry/stages"
+)
+
+const testFluentBitConfig = `
+[SERVICE]
+    Flush        5
+    Parsers_File parsers.conf
+
+@SET log_dir=/var/log
+
+@INCLUDE inputs/*.conf
+
+[FILTER]
+    Name    parser
+    Match   nginx
+    Key_Name log
+    Parser  nginx
+
+[FILTER]
+    Name    grep
+    Match   *
+    Exclude log ^DEBUG
+    Regex   log (?<level>ERROR|WARN)
+
+[FILTER]
+    Name    modify
+    Match   nginx
+    Add     env prod
+    Rename  a b
+
+[FILTER]
+    Name    kubernetes
+    Match   kube.*
+`
+
+const testFluentBitInputs = `
+[INPUT]
+    Name         tail
+    Tag          nginx
+    Path         ${log_dir}/nginx/access.log, ${log_dir}/nginx/error.log
+    Exclude_Path *.gz
+
+[INPUT]
+    Name              tail
+    Tag               kube.*
+    Path              /var/log/containers/*.log
+    multiline.parser  cri, java_trace
+
+[INPUT]
+    Name           systemd
+    Systemd_Filter _SYSTEMD_UNIT=sshd.service
+
+[INPUT]
+    Name   syslog
+    Mode   udp
+    Port   514
+    Parser syslog-rfc3164
+
+[INPUT]
+    Name   cpu
+`
+
+const testFluentBitParsers = `
+[PARSER]
+    Name        nginx
+    Format      regex
+    Regex       ^(?<remote>[^ ]*) \[(?<time>[^\]]*)\] "(?<method>\S+)"$
+    Time_Key    time
+    Time_Format %d/%b/%Y:%H:%M:%S %z
+
+[PARSER]
+    Name        json_app
+    Format      json
+
+[MULTILINE_PARSER]
+    name          java_trace
+    type          regex
+    rule          "start_state"  "/^\d{4}-\d{2}-\d{2}/"  "cont"
+    rule          "cont"         "/^\s+at /"             "cont"
+`
+
+func writeFluentBitConfig(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "fluent-bit.conf"), []byte(testFluentBitConfig), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "parsers.conf"), []byte(testFluentBitParsers), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "inputs"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "inputs", "inputs.conf"), []byte(testFluentBitInputs), 0600))
+	return filepath.Join(dir, "fluent-bit.conf")
+}
+
+func TestFluentBit(t *testing.T) {
+	res, err := FluentBit(writeFluentBitConfig(t), nil)
+	require.NoError(t, err)
+	scs := requireValid(t, res)
+	require.Len(t, scs, 4)
+
+	out, err := yaml.Marshal(res.ScrapeConfigs[0])
+	require.NoError(t, err)
+	require.Equal(t, `job_name: nginx
+pipeline_stages:
+- regex:
+    expression: ^(?P<remote>[^ ]*) \[(?P<time>[^\]]*)\] "(?P<method>\S+)"$
+- timestamp:
+    source: time
+    format: 02/Jan/2006:15:04:05 -0700
+- match:
+    selector: '{job="nginx"} !~ "(?P<level>ERROR|WARN)"'
+    action: drop
+- drop:
+    expression: ^DEBUG
+static_configs:
+- targets:
+  - localhost
+  labels:
+    job: nginx
+    env: prod
+    __path__: /var/log/nginx/access.log
+    __path_exclude__: '*.gz'
+- targets:
+  - localhost
+  labels:
+    job: nginx
+    env: prod
+    __path__: /var/log/nginx/error.log
+    __path_exclude__: '*.gz'
+`, string(out))
+
+	kube := scs[1]
+	require.Equal(t, "kube", kube.JobName)
+	require.Equal(t, stages.PipelineStages{
+		map[interface{}]interface{}{"cri": map[interface{}]interface{}{}},
+		map[interface{}]interface{}{"multiline": map[interface{}]interface{}{"firstline": `^\d{4}-\d{2}-\d{2}`}},
+		map[interface{}]interface{}{"match": map[interface{}]interface{}{"selector": `{job="kube"} !~ "(?P<level>ERROR|WARN)"`, "action": "drop"}},
+		map[interface{}]interface{}{"drop": map[interface{}]interface{}{"expression": "^DEBUG"}},
+	}, kube.PipelineStages)
+
+	journal := scs[2]
+	require.Equal(t, "systemd.2", journal.JobName)
+	require.Equal(t, []string{"_SYSTEMD_UNIT=sshd.service"}, journal.JournalConfig.Matches)
+
+	syslog := scs[3]
+	require.Equal(t, "0.0.0.0:514", syslog.SyslogConfig.ListenAddress)
+	require.Equal(t, "udp", syslog.SyslogConfig.ListenProtocol)
+	require.Equal(t, "rfc3164", syslog.SyslogConfig.SyslogFormat)
+
+	require.Equal(t, []string{
+		"job nginx: the Rename rule of the modify filter is not converted",
+		"job kube: the kubernetes filter is not converted, use kubernetes_sd_configs to discover the pods and their labels",
+		"job cpu.4: the cpu input plugin is not supported, it is not converted",
+	}, res.Warnings)
+}
+
+func TestFluentBit_Errors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "fluent-bit.conf")
+
+	_, err := FluentBit(file, nil)
+	require.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("Name tail\n"), 0600))
+	_, err = FluentBit(file, nil)
+	require.EqualError(t, err, `reading the Fluent Bit config: `+file+`:1: "Name tail" is not in a section`)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("[OUTPUT]\n  Name loki\n"), 0600))
+	_, err = FluentBit(file, nil)
+	require.EqualError(t, err, "no [INPUT] in the Fluent Bit config")
+}
@@ -27,6 +27,8 @@ import (
 	"github.com/prometheus/prometheus/discovery"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/util/strutil"
+
+	"github.com/grafana/loki/clients/pkg/promtail/tlspolicy"
 )
 
 const (
@@ -182,6 +184,7 @@ func NewDiscovery(conf *SDConfig, logger log.Logger) (*Discovery, error) {
 	if err != nil {
 		return nil, err
 	}
+	tlspolicy.Apply(tls)
 	transport := &http.Transport{
 		IdleConnTimeout: 2 * watchTimeout,
 		TLSClientConfig: tls,
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestHTTPValidRefresh(t *testing.T) {
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "60", r.Header.Get("X-Promtail-Refresh-Interval-Seconds"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	defer ts.Close()
+
+	cfg := DefaultSDConfig
+	cfg.URL = ts.URL
+	d, err := NewDiscovery(&cfg, nil)
+	require.NoError(t, err)
+
+	body = `[
+		{"targets": ["localhost"], "labels": {"__path__": "/var/log/app/*.log", "app": "app"}},
+		{"targets": ["localhost"], "labels": {"__path__": "/var/log/db/*.log", "app": "db"}}
+	]`
+	tgs, err := d.refresh(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []*targetgroup.Group{
+		{
+			Targets: []model.LabelSet{{model.AddressLabel: "localhost"}},
+			Labels:  model.LabelSet{"__path__": "/var/log/app/*.log", "app": "app", urlLabel: model.LabelValue(ts.URL)},
+			Source:  urlSource(ts.URL, 0),
+		},
+		{
+			Targets: []model.LabelSet{{model.AddressLabel: "localhost"}},
+			Labels:  model.LabelSet{"__path__": "/var/log/db/*.log", "app": "db", urlLabel: model.LabelValue(ts.URL)},
+			Source:  urlSource(ts.URL, 1),
+		},
+	}, tgs)
+
+	// the target groups which disappeared are sent empty.
+	body = `[{"targets": ["localhost"], "labels": {"__path__": "/var/log/app/*.log"}}]`
+	tgs, err = d.refresh(context.Background())
+	require.NoError(t, err)
+	require.Len(t, tgs, 2)
+	require.Equal(t, &targetgroup.Group{Source: urlSource(ts.URL, 1)}, tgs[1])
+}
+
+func TestHTTPInvalidResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		code        int
+		contentType string
+		body        string
+	}{
+		{name: "status", code: http.StatusInternalServerError, contentType: "application/json", body: "[]"},
+		{name: "content type", code: http.StatusOK, contentType: "text/plain", body: "[]"},
+		{name: "nil group", code: http.StatusOK, contentType: "application/json", body: "[null]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.WriteHeader(tc.code)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer ts.Close()
+
+			cfg := DefaultSDConfig
+			cfg.URL = ts.URL
+			d, err := NewDiscovery(&cfg, nil)
+			require.NoError(t, err)
+			_, err = d.refresh(context.Background())
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSDConfigUnmarshalYAML(t *testing.T) {
+	var cfg SDConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`url: http://sd.example.com/targets`), &cfg))
+	require.Equal(t, model.Duration(time.Minute), cfg.RefreshInterval)
+
+	require.EqualError(t, yaml.Unmarshal([]byte(`refresh_interval: 10s`), &cfg), "http SD configuration requires a URL")
+	require.EqualError(t, yaml.Unmarshal([]byte(`url: ftp://sd.example.com`), &cfg), "http SD URL must be http or https")
+}
@@ -0,0 +1,35 @@
+package limits
+
+import (
+	"flag"
+	"time"
+
+	"github.com/grafana/loki/pkg/util/flagext"
+)
+
+// Config describes the resources promtail limits itself to.
+type Config struct {
+	MaxCPU          float64          `yaml:"max_cpu"`
+	MaxMemory       flagext.ByteSize `yaml:"max_memory"`
+	CheckInterval   time.Duration    `yaml:"check_interval"`
+	BatchWaitFactor float64          `yaml:"batch_wait_factor"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by
+// prefix. If prefix is a non-empty string, prefix should end with a period.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.Float64Var(&cfg.MaxCPU, prefix+"resource-limits.max-cpu", 0, "CPU cores promtail should stay under, 0 disables the limit.")
+	f.Var(&cfg.MaxMemory, prefix+"resource-limits.max-memory", "Resident memory promtail should stay under, 0 disables the limit.")
+	f.DurationVar(&cfg.CheckInterval, prefix+"resource-limits.check-interval", 10*time.Second, "How often the resource usage is checked against the limits.")
+	f.Float64Var(&cfg.BatchWaitFactor, prefix+"resource-limits.batch-wait-factor", 4, "Factor the batch wait of the clients is multiplied by once a limit is exceeded.")
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("", f)
+}
+
+// Enabled returns whether any limit is set.
+func (cfg *Config) Enabled() bool {
+	return cfg.MaxCPU > 0 || cfg.MaxMemory > 0
+}
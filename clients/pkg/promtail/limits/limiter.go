@@ -0,0 +1,264 @@
+package limits
+
+import (
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/procfs"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+)
+
+// recoveryRatio is the share of the limits the usage must fall under before the
+// degradation level is lowered again, so that promtail doesn't flap around a limit.
+const recoveryRatio = 0.8
+
+// BatchWaiter is implemented by clients whose batch wait can be stretched to shed load.
+type BatchWaiter interface {
+	SetBatchWaitFactor(factor float64)
+}
+
+// usageFunc returns the CPU seconds consumed so far and the resident memory in bytes.
+type usageFunc func() (cpuSeconds float64, rss int64, err error)
+
+// processUsage reads the usage of the promtail process from procfs.
+func processUsage() (float64, int64, error) {
+	p, err := procfs.Self()
+	if err != nil {
+		return 0, 0, err
+	}
+	stat, err := p.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	return stat.CPUTime(), int64(stat.ResidentMemory()), nil
+}
+
+// Limiter keeps promtail within its CPU and memory limits by degrading progressively:
+// the first level stretches the batch wait of the clients, every further level pauses
+// the targets of the next lowest priority. The targets of the highest priority are
+// never paused.
+type Limiter struct {
+	cfg     Config
+	logger  log.Logger
+	metrics *Metrics
+	client  BatchWaiter
+	usage   usageFunc
+
+	mtx        sync.Mutex
+	level      int
+	priorities []int
+	gates      map[int]chan struct{}
+
+	lastCPU   float64
+	lastCheck time.Time
+
+	quit     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New makes a new Limiter and starts checking the usage of the process. client, if it
+// implements BatchWaiter, has its batch wait stretched on the first degradation level.
+// An error is returned if the usage of the process can't be measured on this platform.
+func New(cfg Config, client api.EntryHandler, metrics *Metrics, logger log.Logger) (*Limiter, error) {
+	l := newLimiter(cfg, client, metrics, logger, processUsage)
+	if _, _, err := l.usage(); err != nil {
+		return nil, err
+	}
+	go l.run()
+	return l, nil
+}
+
+func newLimiter(cfg Config, client api.EntryHandler, metrics *Metrics, logger log.Logger, usage usageFunc) *Limiter {
+	l := &Limiter{
+		cfg:     cfg,
+		logger:  log.With(logger, "component", "resource_limits"),
+		metrics: metrics,
+		usage:   usage,
+		gates:   map[int]chan struct{}{},
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if bw, ok := client.(BatchWaiter); ok {
+		l.client = bw
+	}
+	return l
+}
+
+func (l *Limiter) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.cfg.CheckInterval)
+	defer ticker.Stop()
+	l.check(time.Now())
+	for {
+		select {
+		case now := <-ticker.C:
+			l.check(now)
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+// check measures the usage and raises or lowers the degradation level by one step.
+func (l *Limiter) check(now time.Time) {
+	cpuSeconds, rss, err := l.usage()
+	if err != nil {
+		level.Warn(l.logger).Log("msg", "failed to measure resource usage", "err", err)
+		return
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	var cpu float64
+	if !l.lastCheck.IsZero() {
+		cpu = (cpuSeconds - l.lastCPU) / now.Sub(l.lastCheck).Seconds()
+	}
+	l.lastCPU, l.lastCheck = cpuSeconds, now
+
+	maxMemory := float64(l.cfg.MaxMemory)
+	cpuOver := l.cfg.MaxCPU > 0 && cpu > l.cfg.MaxCPU
+	memoryOver := maxMemory > 0 && float64(rss) > maxMemory
+	recovered := (l.cfg.MaxCPU == 0 || cpu < l.cfg.MaxCPU*recoveryRatio) &&
+		(maxMemory == 0 || float64(rss) < maxMemory*recoveryRatio)
+
+	if memoryOver {
+		debug.FreeOSMemory()
+	}
+	switch {
+	case cpuOver || memoryOver:
+		if l.setLevel(l.level + 1) {
+			l.metrics.degradations.Inc()
+			level.Warn(l.logger).Log("msg", "resource limit exceeded, shedding load", "level", l.level, "cpu", cpu, "rss", rss)
+		}
+	case recovered && l.level > 0:
+		l.setLevel(l.level - 1)
+		level.Info(l.logger).Log("msg", "resource usage recovered, restoring load", "level", l.level, "cpu", cpu, "rss", rss)
+	}
+}
+
+// setLevel sets the degradation level, capped to the levels available, and reports
+// whether it changed. l.mtx must be held.
+func (l *Limiter) setLevel(lvl int) bool {
+	maxLevel := len(l.priorities)
+	if maxLevel == 0 {
+		maxLevel = 1
+	}
+	if lvl > maxLevel {
+		lvl = maxLevel
+	}
+	if lvl < 0 {
+		lvl = 0
+	}
+	if lvl == l.level {
+		return false
+	}
+	l.level = lvl
+	l.apply()
+	return true
+}
+
+// apply applies the current degradation level. l.mtx must be held.
+func (l *Limiter) apply() {
+	l.metrics.level.Set(float64(l.level))
+	if l.client != nil {
+		factor := 1.0
+		if l.level > 0 && l.cfg.BatchWaitFactor > 0 {
+			factor = l.cfg.BatchWaitFactor
+		}
+		l.client.SetBatchWaitFactor(factor)
+	}
+	for i, priority := range l.priorities {
+		l.setPaused(priority, i < l.level-1)
+	}
+}
+
+// setPaused pauses or resumes the targets of a priority. l.mtx must be held.
+func (l *Limiter) setPaused(priority int, paused bool) {
+	gate := l.gates[priority]
+	open := isClosed(gate)
+	switch {
+	case paused && open:
+		l.gates[priority] = make(chan struct{})
+	case !paused && !open:
+		close(gate)
+	}
+	v := 0.0
+	if paused {
+		v = 1
+	}
+	l.metrics.paused.WithLabelValues(strconv.Itoa(priority)).Set(v)
+}
+
+// Level returns the current degradation level.
+func (l *Limiter) Level() int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.level
+}
+
+// Handler returns an EntryHandler forwarding entries of targets of the given priority,
+// blocking while the priority is paused. The handler must be stopped independently
+// of next.
+func (l *Limiter) Handler(priority int, next api.EntryHandler) api.EntryHandler {
+	l.mtx.Lock()
+	if _, ok := l.gates[priority]; !ok {
+		gate := make(chan struct{})
+		close(gate)
+		l.gates[priority] = gate
+		l.priorities = append(l.priorities, priority)
+		sort.Ints(l.priorities)
+		l.apply()
+	}
+	l.mtx.Unlock()
+
+	in, wg, once := make(chan api.Entry), sync.WaitGroup{}, sync.Once{}
+	nextChan := next.Chan()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for e := range in {
+			l.wait(priority)
+			nextChan <- e
+		}
+	}()
+	return api.NewEntryHandler(in, func() {
+		once.Do(func() { close(in) })
+		wg.Wait()
+	})
+}
+
+// wait blocks while the priority is paused.
+func (l *Limiter) wait(priority int) {
+	l.mtx.Lock()
+	gate := l.gates[priority]
+	l.mtx.Unlock()
+	<-gate
+}
+
+// Stop stops checking the usage and restores the normal level, resuming all targets.
+func (l *Limiter) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.quit)
+		<-l.done
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+		l.setLevel(0)
+	})
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
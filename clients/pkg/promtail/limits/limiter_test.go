@@ -0,0 +1,109 @@
+package limits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/client/fake"
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+type batchWaitClient struct {
+	*fake.Client
+	factor float64
+}
+
+func (c *batchWaitClient) SetBatchWaitFactor(factor float64) {
+	c.factor = factor
+}
+
+type usage struct {
+	cpuSeconds float64
+	rss        int64
+}
+
+func (u *usage) get() (float64, int64, error) {
+	return u.cpuSeconds, u.rss, nil
+}
+
+func entry(line string) api.Entry {
+	return api.Entry{Labels: model.LabelSet{"job": "test"}, Entry: logproto.Entry{Timestamp: time.Now(), Line: line}}
+}
+
+func TestLimiter_Degradation(t *testing.T) {
+	client := &batchWaitClient{Client: fake.New(func() {}), factor: 1}
+	u := &usage{}
+	metrics := NewMetrics(prometheus.NewRegistry())
+	l := newLimiter(Config{MaxMemory: 100, BatchWaitFactor: 4}, client, metrics, log.NewNopLogger(), u.get)
+
+	low := l.Handler(0, client)
+	defer low.Stop()
+	high := l.Handler(10, client)
+	defer high.Stop()
+
+	now := time.Now()
+	l.check(now)
+	require.Equal(t, 0, l.Level())
+
+	// over the limit: stretch the batch wait first.
+	u.rss = 150
+	l.check(now.Add(time.Second))
+	require.Equal(t, 1, l.Level())
+	require.Equal(t, 4.0, client.factor)
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.paused.WithLabelValues("0")))
+
+	// then pause the lowest priority, but never the highest.
+	l.check(now.Add(2 * time.Second))
+	l.check(now.Add(3 * time.Second))
+	require.Equal(t, 2, l.Level())
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.paused.WithLabelValues("0")))
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.paused.WithLabelValues("10")))
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.degradations))
+
+	low.Chan() <- entry("low")
+	high.Chan() <- entry("high")
+	require.Eventually(t, func() bool { return len(client.Received()) == 1 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, "high", client.Received()[0].Line)
+
+	// under the limit but within the recovery margin: keep the level.
+	u.rss = 90
+	l.check(now.Add(4 * time.Second))
+	require.Equal(t, 2, l.Level())
+
+	u.rss = 50
+	l.check(now.Add(5 * time.Second))
+	require.Equal(t, 1, l.Level())
+	require.Eventually(t, func() bool { return len(client.Received()) == 2 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, "low", client.Received()[1].Line)
+
+	l.check(now.Add(6 * time.Second))
+	require.Equal(t, 0, l.Level())
+	require.Equal(t, 1.0, client.factor)
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.level))
+}
+
+func TestLimiter_CPU(t *testing.T) {
+	u := &usage{}
+	l := newLimiter(Config{MaxCPU: 0.5}, fake.New(func() {}), NewMetrics(nil), log.NewNopLogger(), u.get)
+
+	now := time.Now()
+	l.check(now)
+	u.cpuSeconds = 3
+	l.check(now.Add(5 * time.Second))
+	require.Equal(t, 1, l.Level())
+
+	u.cpuSeconds = 5
+	l.check(now.Add(10 * time.Second))
+	require.Equal(t, 1, l.Level())
+
+	u.cpuSeconds = 5.5
+	l.check(now.Add(15 * time.Second))
+	require.Equal(t, 0, l.Level())
+}
@@ -0,0 +1,40 @@
+package limits
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds the metrics of the resource limiter.
+type Metrics struct {
+	// reg is the Registerer used to create this set of metrics.
+	reg prometheus.Registerer
+
+	level        prometheus.Gauge
+	paused       *prometheus.GaugeVec
+	degradations prometheus.Counter
+}
+
+// NewMetrics creates a new set of metrics. Metrics will be registered to reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	var m Metrics
+	m.reg = reg
+
+	m.level = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "resource_limits_degradation_level",
+		Help:      "Current degradation level, 0 when promtail runs within its resource limits.",
+	})
+	m.paused = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "resource_limits_paused",
+		Help:      "Whether the targets of a priority are paused to shed load, by priority.",
+	}, []string{"priority"})
+	m.degradations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "resource_limits_degradations_total",
+		Help:      "Total number of times the degradation level was raised because a resource limit was exceeded.",
+	})
+
+	if reg != nil {
+		reg.MustRegister(m.level, m.paused, m.degradations)
+	}
+	return &m
+}
@@ -0,0 +1,189 @@
+package positions
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// KubernetesConfig describes the Kubernetes ConfigMap positions are kept in, instead of the
+// positions file, when Promtail runs on nodes without persistent disk, like spot nodes.
+type KubernetesConfig struct {
+	// APIServer is the address of the Kubernetes API server, the in-cluster configuration
+	// is used when both it and KubeConfig are empty.
+	APIServer string `yaml:"api_server"`
+	// KubeConfig is the path of a kubeconfig file to connect to the API server with.
+	KubeConfig string `yaml:"kubeconfig_file"`
+	// Namespace of the ConfigMap and the Lease, the namespace of Promtail when empty.
+	Namespace string `yaml:"namespace"`
+
+	// ConfigMap is the name of the ConfigMap holding the positions. Positions are kept in
+	// Kubernetes only when it is set.
+	ConfigMap string `yaml:"config_map"`
+	// Key of the positions in the data of the ConfigMap.
+	Key string `yaml:"key"`
+
+	// Lease is the name of a Lease Promtail must hold to write the positions, so that a
+	// Promtail rescheduled while the previous one is still running doesn't overwrite its
+	// positions. The positions are written without lease when it is empty.
+	Lease string `yaml:"lease"`
+	// LeaseDuration is how long the lease is held after the last write of the positions.
+	LeaseDuration time.Duration `yaml:"lease_duration"`
+	// Identity of the holder of the lease, the hostname when empty.
+	Identity string `yaml:"identity"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by prefix.
+func (cfg *KubernetesConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.APIServer, prefix+"api-server", "", "Address of the Kubernetes API server. The in-cluster configuration is used when both it and the kubeconfig file are empty.")
+	f.StringVar(&cfg.KubeConfig, prefix+"kubeconfig-file", "", "Path of a kubeconfig file to connect to the Kubernetes API server with.")
+	f.StringVar(&cfg.Namespace, prefix+"namespace", "", "Namespace of the ConfigMap and the Lease. Defaults to the namespace of Promtail.")
+	f.StringVar(&cfg.ConfigMap, prefix+"config-map", "", "Name of the ConfigMap to read/write positions from, instead of the positions file.")
+	f.StringVar(&cfg.Key, prefix+"key", "positions.yaml", "Key of the positions in the data of the ConfigMap.")
+	f.StringVar(&cfg.Lease, prefix+"lease", "", "Name of a Lease Promtail must hold to write the positions.")
+	f.DurationVar(&cfg.LeaseDuration, prefix+"lease-duration", time.Minute, "How long the lease is held after the last write of the positions. Must be longer than the sync period.")
+	f.StringVar(&cfg.Identity, prefix+"identity", "", "Identity of the holder of the lease. Defaults to the hostname.")
+}
+
+// Enabled returns true if positions are kept in Kubernetes.
+func (cfg *KubernetesConfig) Enabled() bool {
+	return cfg.ConfigMap != ""
+}
+
+// kubernetesStore keeps the positions in a key of a ConfigMap.
+type kubernetesStore struct {
+	cfg    KubernetesConfig
+	client kubernetes.Interface
+	now    func() time.Time
+}
+
+func newKubernetesStore(cfg KubernetesConfig) (*kubernetesStore, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags(cfg.APIServer, cfg.KubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error loading Kubernetes client configuration: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Kubernetes client: %w", err)
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = "default"
+		if buf, err := ioutil.ReadFile(serviceAccountNamespace); err == nil {
+			cfg.Namespace = strings.TrimSpace(string(buf))
+		}
+	}
+	if cfg.Identity == "" {
+		if cfg.Identity, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+	return &kubernetesStore{cfg: cfg, client: client, now: time.Now}, nil
+}
+
+func (s *kubernetesStore) Read(ctx context.Context) ([]byte, error) {
+	cm, err := s.client.CoreV1().ConfigMaps(s.cfg.Namespace).Get(ctx, s.cfg.ConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[s.cfg.Key]
+	if !ok {
+		return nil, nil
+	}
+	return []byte(data), nil
+}
+
+func (s *kubernetesStore) Write(ctx context.Context, buf []byte) error {
+	if s.cfg.Lease != "" {
+		if err := s.acquireLease(ctx); err != nil {
+			return err
+		}
+	}
+
+	configMaps := s.client.CoreV1().ConfigMaps(s.cfg.Namespace)
+	cm, err := configMaps.Get(ctx, s.cfg.ConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: s.cfg.ConfigMap, Namespace: s.cfg.Namespace},
+			Data:       map[string]string{s.cfg.Key: string(buf)},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[s.cfg.Key] = string(buf)
+	// the update fails if the ConfigMap changed since it was read.
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// acquireLease acquires or renews the lease, unless another holder renewed it within its
+// duration.
+func (s *kubernetesStore) acquireLease(ctx context.Context) error {
+	leases := s.client.CoordinationV1().Leases(s.cfg.Namespace)
+	now := metav1.NewMicroTime(s.now())
+	seconds := int32(s.cfg.LeaseDuration.Seconds())
+	identity := s.cfg.Identity
+
+	lease, err := leases.Get(ctx, s.cfg.Lease, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: s.cfg.Lease, Namespace: s.cfg.Namespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	spec := &lease.Spec
+	if spec.HolderIdentity != nil && *spec.HolderIdentity != identity {
+		if spec.RenewTime != nil && spec.LeaseDurationSeconds != nil &&
+			now.Time.Before(spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds)*time.Second)) {
+			return fmt.Errorf("lease %s/%s is held by %s", s.cfg.Namespace, s.cfg.Lease, *spec.HolderIdentity)
+		}
+	}
+	if spec.HolderIdentity == nil || *spec.HolderIdentity != identity {
+		spec.AcquireTime = &now
+		transitions := int32(1)
+		if spec.LeaseTransitions != nil {
+			transitions = *spec.LeaseTransitions + 1
+		}
+		spec.LeaseTransitions = &transitions
+	}
+	spec.HolderIdentity = &identity
+	spec.LeaseDurationSeconds = &seconds
+	spec.RenewTime = &now
+	// the update fails if another holder acquired the lease since it was read.
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+func (s *kubernetesStore) String() string {
+	return fmt.Sprintf("configmap [%s/%s]", s.cfg.Namespace, s.cfg.ConfigMap)
+}
@@ -1,11 +1,12 @@
 package positions
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,7 +17,11 @@ import (
 	yaml "gopkg.in/yaml.v2"
 )
 
-const positionFileMode = 0600
+const (
+	positionFileMode = 0600
+
+	storeTimeout = time.Minute
+)
 
 // Config describes where to get position information from.
 type Config struct {
@@ -24,6 +29,10 @@ type Config struct {
 	PositionsFile     string        `yaml:"filename"`
 	IgnoreInvalidYaml bool          `yaml:"ignore_invalid_yaml"`
 	ReadOnly          bool          `yaml:"-"`
+
+	ObjectStore ObjectStoreConfig `yaml:"object_store,omitempty"`
+	Redis       RedisConfig       `yaml:"redis,omitempty"`
+	Kubernetes  KubernetesConfig  `yaml:"kubernetes,omitempty"`
 }
 
 // RegisterFlags with prefix registers flags where every name is prefixed by
@@ -32,6 +41,22 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.DurationVar(&cfg.SyncPeriod, prefix+"positions.sync-period", 10*time.Second, "Period with this to sync the position file.")
 	f.StringVar(&cfg.PositionsFile, prefix+"positions.file", "/var/log/positions.yaml", "Location to read/write positions from.")
 	f.BoolVar(&cfg.IgnoreInvalidYaml, prefix+"positions.ignore-invalid-yaml", false, "whether to ignore & later overwrite positions files that are corrupted")
+	cfg.ObjectStore.RegisterFlagsWithPrefix(prefix+"positions.object-store.", f)
+	cfg.Redis.RegisterFlagsWithPrefix(prefix+"positions.redis.", f)
+	cfg.Kubernetes.RegisterFlagsWithPrefix(prefix+"positions.kubernetes.", f)
+}
+
+func (cfg *Config) validate() error {
+	enabled := 0
+	for _, e := range []bool{cfg.ObjectStore.Enabled(), cfg.Redis.Enabled(), cfg.Kubernetes.Enabled()} {
+		if e {
+			enabled++
+		}
+	}
+	if enabled > 1 {
+		return errors.New("the positions can only be kept in one of object_store, redis or kubernetes")
+	}
+	return nil
 }
 
 // RegisterFlags register flags.
@@ -45,6 +70,7 @@ type positions struct {
 	cfg       Config
 	mtx       sync.Mutex
 	positions map[string]string
+	store     Store
 	quit      chan struct{}
 	done      chan struct{}
 }
@@ -77,17 +103,31 @@ type Positions interface {
 	Stop()
 }
 
-// New makes a new Positions.
+// New makes a new Positions, kept in the store of the config.
 func New(logger log.Logger, cfg Config) (Positions, error) {
-	positionData, err := readPositionsFile(cfg, logger)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	store, err := newStore(cfg, logger)
 	if err != nil {
 		return nil, err
 	}
+	return NewWithStore(logger, cfg, store)
+}
+
+// NewWithStore makes a new Positions kept in store, instead of the store of the config.
+func NewWithStore(logger log.Logger, cfg Config, store Store) (Positions, error) {
+	positionData, err := readPositions(cfg, store, logger)
+	if err != nil {
+		closeStore(store)
+		return nil, err
+	}
 
 	p := &positions{
 		logger:    logger,
 		cfg:       cfg,
 		positions: positionData,
+		store:     store,
 		quit:      make(chan struct{}),
 		done:      make(chan struct{}),
 	}
@@ -145,6 +185,7 @@ func (p *positions) run() {
 	defer func() {
 		p.save()
 		level.Debug(p.logger).Log("msg", "positions saved")
+		closeStore(p.store)
 		close(p.done)
 	}()
 
@@ -171,8 +212,8 @@ func (p *positions) save() {
 	}
 	p.mtx.Unlock()
 
-	if err := writePositionFile(p.cfg.PositionsFile, positions); err != nil {
-		level.Error(p.logger).Log("msg", "error writing positions file", "error", err)
+	if err := writePositions(p.store, positions); err != nil {
+		level.Error(p.logger).Log("msg", "error writing positions", "store", p.store, "error", err)
 	}
 }
 
@@ -181,9 +222,12 @@ func (p *positions) cleanup() {
 	defer p.mtx.Unlock()
 	toRemove := []string{}
 	for k := range p.positions {
-		// If the position file is prefixed with journal, it's a
-		// JournalTarget cursor and not a file on disk.
-		if strings.HasPrefix(k, "journal-") {
+		// If the position file is prefixed with journal, kinesis, docker, kubernetes-events or windows,
+		// it's a JournalTarget cursor, a Kinesis sequence number, the timestamp of a container
+		// log, the resource version of Kubernetes events or the bookmark of Windows events and
+		// not a file on disk.
+		if strings.HasPrefix(k, "journal-") || strings.HasPrefix(k, "kinesis-") || strings.HasPrefix(k, "docker-") ||
+			strings.HasPrefix(k, "kubernetes-events-") || strings.HasPrefix(k, "windows-") {
 			continue
 		}
 
@@ -204,26 +248,31 @@ func (p *positions) cleanup() {
 }
 
 func readPositionsFile(cfg Config, logger log.Logger) (map[string]string, error) {
+	return readPositions(cfg, fileStore{filename: cfg.PositionsFile}, logger)
+}
 
-	cleanfn := filepath.Clean(cfg.PositionsFile)
-	buf, err := ioutil.ReadFile(cleanfn)
+// readPositions reads the positions from the store, empty when they were never saved.
+func readPositions(cfg Config, store Store, logger log.Logger) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	buf, err := store.Read(ctx)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return map[string]string{}, nil
-		}
 		return nil, err
 	}
+	if buf == nil {
+		return map[string]string{}, nil
+	}
 
 	var p File
 	err = yaml.UnmarshalStrict(buf, &p)
 	if err != nil {
 		// return empty if cfg option enabled
 		if cfg.IgnoreInvalidYaml {
-			level.Debug(logger).Log("msg", "ignoring invalid positions file", "file", cleanfn, "error", err)
+			level.Debug(logger).Log("msg", "ignoring invalid positions", "store", store, "error", err)
 			return map[string]string{}, nil
 		}
 
-		return nil, fmt.Errorf("invalid yaml positions file [%s]: %v", cleanfn, err)
+		return nil, fmt.Errorf("invalid yaml positions %s: %v", store, err)
 	}
 
 	// p.Positions will be nil if the file exists but is empty
@@ -234,7 +283,7 @@ func readPositionsFile(cfg Config, logger log.Logger) (map[string]string, error)
 	return p.Positions, nil
 }
 
-func writePositionFile(filename string, positions map[string]string) error {
+func writePositions(store Store, positions map[string]string) error {
 	buf, err := yaml.Marshal(File{
 		Positions: positions,
 	})
@@ -242,13 +291,13 @@ func writePositionFile(filename string, positions map[string]string) error {
 		return err
 	}
 
-	target := filepath.Clean(filename)
-	temp := target + "-new"
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	return store.Write(ctx, buf)
+}
 
-	err = ioutil.WriteFile(temp, buf, os.FileMode(positionFileMode))
-	if err != nil {
-		return err
+func closeStore(store Store) {
+	if c, ok := store.(io.Closer); ok {
+		_ = c.Close()
 	}
-
-	return os.Rename(temp, target)
 }
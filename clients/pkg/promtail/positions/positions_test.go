@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/go-kit/log"
 	"github.com/stretchr/testify/require"
@@ -180,3 +181,28 @@ func Test_ReadOnly(t *testing.T) {
 	}, out)
 
 }
+
+func TestPositionsObjectStore(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		SyncPeriod:    time.Hour,
+		PositionsFile: dir + "/positions.yaml",
+	}
+	cfg.ObjectStore.Backend = bucket.Filesystem
+	cfg.ObjectStore.Filesystem.Directory = dir + "/bucket"
+	cfg.ObjectStore.Object = "promtail/positions.yaml"
+
+	// The object doesn't exist on the first start.
+	p, err := New(util_log.Logger, cfg)
+	require.NoError(t, err)
+	p.PutString("journal-foo", "cursor")
+	p.Stop()
+
+	// The positions are saved on stop, in the object storage only.
+	_, err = os.Stat(cfg.PositionsFile)
+	require.True(t, os.IsNotExist(err))
+	p, err = New(util_log.Logger, cfg)
+	require.NoError(t, err)
+	defer p.Stop()
+	require.Equal(t, "cursor", p.GetString("journal-foo"))
+}
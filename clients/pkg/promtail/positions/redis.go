@@ -0,0 +1,102 @@
+package positions
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/grafana/dskit/flagext"
+
+	"github.com/grafana/loki/clients/pkg/promtail/tlspolicy"
+)
+
+// RedisConfig describes the Redis positions are kept in, instead of the positions file.
+type RedisConfig struct {
+	Endpoint           string         `yaml:"endpoint"`
+	MasterName         string         `yaml:"master_name"`
+	DB                 int            `yaml:"db"`
+	Password           flagext.Secret `yaml:"password"`
+	Timeout            time.Duration  `yaml:"timeout"`
+	EnableTLS          bool           `yaml:"tls_enabled"`
+	InsecureSkipVerify bool           `yaml:"tls_insecure_skip_verify"`
+
+	// Key is the key holding the positions. Positions are kept in Redis only when it is set.
+	Key string `yaml:"key"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by prefix.
+func (cfg *RedisConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Endpoint, prefix+"endpoint", "", "Redis endpoint to keep the positions in. A comma-separated list of endpoints for Redis Cluster or Redis Sentinel.")
+	f.StringVar(&cfg.MasterName, prefix+"master-name", "", "Redis Sentinel master name. An empty string for Redis Server or Redis Cluster.")
+	f.IntVar(&cfg.DB, prefix+"db", 0, "Redis database index.")
+	f.Var(&cfg.Password, prefix+"password", "Password to use when connecting to Redis.")
+	f.DurationVar(&cfg.Timeout, prefix+"timeout", 5*time.Second, "Maximum time to wait for Redis requests.")
+	f.BoolVar(&cfg.EnableTLS, prefix+"tls-enabled", false, "Enable connecting to Redis with TLS.")
+	f.BoolVar(&cfg.InsecureSkipVerify, prefix+"tls-insecure-skip-verify", false, "Skip validating the certificate of Redis.")
+	f.StringVar(&cfg.Key, prefix+"key", "", "Key to read/write positions from in Redis, instead of the positions file.")
+}
+
+// Enabled returns true if positions are kept in Redis.
+func (cfg *RedisConfig) Enabled() bool {
+	return cfg.Key != ""
+}
+
+// redisStore keeps the positions in a key of Redis.
+type redisStore struct {
+	rdb     redis.UniversalClient
+	key     string
+	timeout time.Duration
+}
+
+func newRedisStore(cfg RedisConfig) *redisStore {
+	opt := &redis.UniversalOptions{
+		Addrs:      strings.Split(cfg.Endpoint, ","),
+		MasterName: cfg.MasterName,
+		Password:   cfg.Password.Value,
+		DB:         cfg.DB,
+	}
+	if cfg.EnableTLS {
+		opt.TLSConfig = &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+		tlspolicy.Apply(opt.TLSConfig)
+	}
+	return &redisStore{
+		rdb:     redis.NewUniversalClient(opt),
+		key:     cfg.Key,
+		timeout: cfg.Timeout,
+	}
+}
+
+func (s *redisStore) Read(ctx context.Context) ([]byte, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	buf, err := s.rdb.Get(ctx, s.key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return buf, err
+}
+
+func (s *redisStore) Write(ctx context.Context, buf []byte) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.rdb.Set(ctx, s.key, buf, 0).Err()
+}
+
+func (s *redisStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout > 0 {
+		return context.WithTimeout(ctx, s.timeout)
+	}
+	return ctx, func() {}
+}
+
+func (s *redisStore) Close() error {
+	return s.rdb.Close()
+}
+
+func (s *redisStore) String() string {
+	return fmt.Sprintf("redis key [%s]", s.key)
+}
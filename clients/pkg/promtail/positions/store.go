@@ -0,0 +1,127 @@
+package positions
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/go-kit/log"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+// Store is where the positions are saved, the positions file unless the config sets another
+// store. Stores implementing io.Closer are closed when the positions are stopped.
+type Store interface {
+	// Read returns the saved positions, nil when they were never saved.
+	Read(ctx context.Context) ([]byte, error)
+	// Write saves the positions.
+	Write(ctx context.Context, buf []byte) error
+	// String describes the store in logs and errors.
+	String() string
+}
+
+func newStore(cfg Config, logger log.Logger) (Store, error) {
+	switch {
+	case cfg.ObjectStore.Enabled():
+		return newObjectStore(cfg.ObjectStore, logger)
+	case cfg.Redis.Enabled():
+		return newRedisStore(cfg.Redis), nil
+	case cfg.Kubernetes.Enabled():
+		return newKubernetesStore(cfg.Kubernetes)
+	default:
+		return fileStore{filename: cfg.PositionsFile}, nil
+	}
+}
+
+// fileStore keeps the positions in a local file.
+type fileStore struct {
+	filename string
+}
+
+func (s fileStore) Read(context.Context) ([]byte, error) {
+	buf, err := ioutil.ReadFile(filepath.Clean(s.filename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return buf, err
+}
+
+func (s fileStore) Write(_ context.Context, buf []byte) error {
+	target := filepath.Clean(s.filename)
+	temp := target + "-new"
+
+	err := ioutil.WriteFile(temp, buf, os.FileMode(positionFileMode))
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temp, target)
+}
+
+func (s fileStore) String() string {
+	return fmt.Sprintf("file [%s]", filepath.Clean(s.filename))
+}
+
+// ObjectStoreConfig describes the object storage positions are kept in, instead of the positions file,
+// when Promtail has no persistent disk, like on the platforms scaling it to zero.
+type ObjectStoreConfig struct {
+	bucket.Config `yaml:",inline"`
+
+	// Object is the name of the object holding the positions. Positions are kept in the object
+	// storage only when it is set.
+	Object string `yaml:"object"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by prefix.
+func (cfg *ObjectStoreConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	cfg.Config.RegisterFlagsWithPrefix(prefix, f)
+	f.StringVar(&cfg.Object, prefix+"object", "", "Name of the object to read/write positions from in the object storage, instead of the positions file.")
+}
+
+// Enabled returns true if positions are kept in the object storage.
+func (cfg *ObjectStoreConfig) Enabled() bool {
+	return cfg.Object != ""
+}
+
+// objectStore keeps the positions in an object of an object storage.
+type objectStore struct {
+	bucket objstore.Bucket
+	object string
+}
+
+func newObjectStore(cfg ObjectStoreConfig, logger log.Logger) (*objectStore, error) {
+	bkt, err := bucket.NewClient(context.Background(), cfg.Config, "promtail-positions", logger, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &objectStore{bucket: bkt, object: cfg.Object}, nil
+}
+
+func (s *objectStore) Read(ctx context.Context) ([]byte, error) {
+	r, err := s.bucket.Get(ctx, s.object)
+	if err != nil {
+		if s.bucket.IsObjNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (s *objectStore) Write(ctx context.Context, buf []byte) error {
+	return s.bucket.Upload(ctx, s.object, bytes.NewReader(buf))
+}
+
+func (s *objectStore) Close() error {
+	return s.bucket.Close()
+}
+
+func (s *objectStore) String() string {
+	return fmt.Sprintf("object [%s]", s.object)
+}
@@ -0,0 +1,171 @@
+package positions
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPositionsRedis(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	cfg := Config{SyncPeriod: time.Hour}
+	cfg.Redis.Endpoint = mr.Addr()
+	cfg.Redis.Key = "promtail/positions"
+
+	// The key doesn't exist on the first start.
+	p, err := New(util_log.Logger, cfg)
+	require.NoError(t, err)
+	p.PutString("journal-foo", "cursor")
+	p.Stop()
+
+	saved, err := mr.Get(cfg.Redis.Key)
+	require.NoError(t, err)
+	require.Equal(t, "positions:\n  journal-foo: cursor\n", saved)
+	p, err = New(util_log.Logger, cfg)
+	require.NoError(t, err)
+	defer p.Stop()
+	require.Equal(t, "cursor", p.GetString("journal-foo"))
+}
+
+func TestPositionsStoresExclusive(t *testing.T) {
+	cfg := Config{SyncPeriod: time.Hour}
+	cfg.Redis.Key = "promtail/positions"
+	cfg.Kubernetes.ConfigMap = "promtail-positions"
+	_, err := New(util_log.Logger, cfg)
+	require.EqualError(t, err, "the positions can only be kept in one of object_store, redis or kubernetes")
+}
+
+// fakeAPIServer stores the Kubernetes objects written to it, rejecting the updates of objects
+// changed since they were read.
+type fakeAPIServer struct {
+	mtx     sync.Mutex
+	objects map[string]map[string]interface{}
+	version int
+}
+
+func (s *fakeAPIServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var kind, apiVersion string
+	switch {
+	case strings.Contains(req.URL.Path, "/configmaps"):
+		kind, apiVersion = "ConfigMap", "v1"
+	case strings.Contains(req.URL.Path, "/leases"):
+		kind, apiVersion = "Lease", "coordination.k8s.io/v1"
+	default:
+		http.NotFound(rw, req)
+		return
+	}
+
+	path := req.URL.Path
+	var obj map[string]interface{}
+	if req.Method == http.MethodPost || req.Method == http.MethodPut {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := json.Unmarshal(body, &obj); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		meta := obj["metadata"].(map[string]interface{})
+		if req.Method == http.MethodPost {
+			path += "/" + meta["name"].(string)
+			if _, ok := s.objects[path]; ok {
+				rw.WriteHeader(http.StatusConflict)
+				return
+			}
+		} else {
+			current, ok := s.objects[path]
+			if !ok {
+				http.NotFound(rw, req)
+				return
+			}
+			if meta["resourceVersion"] != current["metadata"].(map[string]interface{})["resourceVersion"] {
+				rw.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+		s.version++
+		meta["resourceVersion"] = strconv.Itoa(s.version)
+		obj["kind"], obj["apiVersion"] = kind, apiVersion
+		s.objects[path] = obj
+	} else {
+		var ok bool
+		if obj, ok = s.objects[path]; !ok {
+			http.NotFound(rw, req)
+			return
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(obj)
+}
+
+func TestPositionsKubernetes(t *testing.T) {
+	server := &fakeAPIServer{objects: map[string]map[string]interface{}{}}
+	apiServer := httptest.NewServer(server)
+	defer apiServer.Close()
+
+	cfg := Config{SyncPeriod: time.Hour}
+	cfg.Kubernetes = KubernetesConfig{
+		APIServer:     apiServer.URL,
+		Namespace:     "logging",
+		ConfigMap:     "promtail-positions",
+		Key:           "positions.yaml",
+		Lease:         "promtail-positions",
+		LeaseDuration: time.Minute,
+		Identity:      "promtail-0",
+	}
+
+	// The ConfigMap doesn't exist on the first start.
+	p, err := New(util_log.Logger, cfg)
+	require.NoError(t, err)
+	p.PutString("journal-foo", "cursor")
+	p.Stop()
+
+	cm := server.objects["/api/v1/namespaces/logging/configmaps/promtail-positions"]
+	require.Equal(t, map[string]interface{}{"positions.yaml": "positions:\n  journal-foo: cursor\n"}, cm["data"])
+	lease := server.objects["/apis/coordination.k8s.io/v1/namespaces/logging/leases/promtail-positions"]
+	require.Equal(t, "promtail-0", lease["spec"].(map[string]interface{})["holderIdentity"])
+
+	// Another Promtail reads the positions, but can't write them while the lease is held.
+	cfg.Kubernetes.Identity = "promtail-1"
+	store, err := newKubernetesStore(cfg.Kubernetes)
+	require.NoError(t, err)
+	p, err = NewWithStore(util_log.Logger, cfg, store)
+	require.NoError(t, err)
+	require.Equal(t, "cursor", p.GetString("journal-foo"))
+	p.PutString("journal-foo", "next")
+	err = store.Write(context.Background(), []byte("positions: {}\n"))
+	require.EqualError(t, err, "lease logging/promtail-positions is held by promtail-0")
+	buf, err := store.Read(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "positions:\n  journal-foo: cursor\n", string(buf))
+
+	// The lease is acquired once it expires.
+	store.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	p.Stop()
+	buf, err = store.Read(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "positions:\n  journal-foo: next\n", string(buf))
+	lease = server.objects["/apis/coordination.k8s.io/v1/namespaces/logging/leases/promtail-positions"]
+	require.Equal(t, "promtail-1", lease["spec"].(map[string]interface{})["holderIdentity"])
+	require.Equal(t, float64(1), lease["spec"].(map[string]interface{})["leaseTransitions"])
+}
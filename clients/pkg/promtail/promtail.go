@@ -1,16 +1,25 @@
 package promtail
 
 import (
+	"errors"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/grafana/loki/clients/pkg/promtail/client"
 	"github.com/grafana/loki/clients/pkg/promtail/config"
+	"github.com/grafana/loki/clients/pkg/promtail/limits"
 	"github.com/grafana/loki/clients/pkg/promtail/server"
 	"github.com/grafana/loki/clients/pkg/promtail/targets"
+	"github.com/grafana/loki/clients/pkg/promtail/tlspolicy"
+
+	"github.com/grafana/loki/pkg/ruler/storage/util"
 )
 
 // Option is a function that can be passed to the New method of Promtail and
@@ -31,15 +40,33 @@ func WithRegisterer(reg prometheus.Registerer) Option {
 	}
 }
 
+// WithNewConfig enables reloading the config on SIGHUP, or with the /reload endpoint of the
+// server, from the config returned by newConfig.
+func WithNewConfig(newConfig func() (*config.Config, error)) Option {
+	return func(p *Promtail) {
+		p.newConfig = newConfig
+	}
+}
+
 // Promtail is the root struct for Promtail.
 type Promtail struct {
 	client         client.Client
+	limiter        *limits.Limiter
 	targetManagers *targets.TargetManagers
 	server         server.Server
 	logger         log.Logger
 	reg            prometheus.Registerer
 
+	dryRun        bool
+	newConfig     func() (*config.Config, error)
+	configLoaded  config.Config
+	limitsMetrics *limits.Metrics
+	// targetsReg registers the metrics of the targets, which are unregistered when they are
+	// recreated by a reload.
+	targetsReg *util.Unregisterer
+
 	stopped bool
+	quit    chan struct{}
 	mtx     sync.Mutex
 }
 
@@ -55,33 +82,151 @@ func New(cfg config.Config, dryRun bool, opts ...Option) (*Promtail, error) {
 		o(promtail)
 	}
 
+	if err := cfg.ExpandPresets(); err != nil {
+		return nil, err
+	}
 	cfg.Setup()
 
+	// The TLS policy is set before any client or target is created.
+	if err := tlspolicy.Set(cfg.TLSPolicy); err != nil {
+		return nil, err
+	}
+	if tlspolicy.HostFIPSEnabled() && !tlspolicy.FIPSBuild {
+		level.Warn(promtail.logger).Log("msg", "the host runs in FIPS mode but Promtail is not a FIPS build, its TLS connections may use settings which are not FIPS-approved")
+	}
+
+	promtail.dryRun = dryRun
+	promtail.quit = make(chan struct{})
+	if err := promtail.start(cfg); err != nil {
+		return nil, err
+	}
+	server, err := server.New(cfg.ServerConfig, promtail.logger, promtail.targetManagers, cfg.String(), cfg.ScrapeConfig, promtail.Reload)
+	if err != nil {
+		promtail.stop()
+		return nil, err
+	}
+	promtail.server = server
+	return promtail, nil
+}
+
+// start creates the client and the targets of the config.
+func (p *Promtail) start(cfg config.Config) error {
+	loaded := cfg
 	var err error
-	if dryRun {
-		promtail.client, err = client.NewLogger(prometheus.DefaultRegisterer, promtail.logger, cfg.ClientConfigs...)
+	if p.dryRun {
+		p.client, err = client.NewLogger(prometheus.DefaultRegisterer, p.logger, cfg.ClientConfigs...)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		cfg.PositionsConfig.ReadOnly = true
 	} else {
-		promtail.client, err = client.NewMulti(prometheus.DefaultRegisterer, promtail.logger, cfg.ClientConfigs...)
+		p.client, err = client.NewMulti(prometheus.DefaultRegisterer, p.logger, cfg.ClientConfigs...)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.limiter = nil
+	if cfg.LimitsConfig.Enabled() {
+		// the metrics outlive the limiters recreated by a reload.
+		if p.limitsMetrics == nil {
+			p.limitsMetrics = limits.NewMetrics(p.reg)
+		}
+		p.limiter, err = limits.New(cfg.LimitsConfig, p.client, p.limitsMetrics, p.logger)
 		if err != nil {
-			return nil, err
+			level.Warn(p.logger).Log("msg", "resource limits are disabled, failed to measure resource usage", "err", err)
 		}
 	}
 
-	tms, err := targets.NewTargetManagers(promtail, promtail.reg, promtail.logger, cfg.PositionsConfig, promtail.client, cfg.ScrapeConfig, &cfg.TargetConfig)
+	p.targetsReg = util.WrapWithUnregisterer(p.reg)
+	p.targetManagers, err = targets.NewTargetManagers(p, p.targetsReg, p.logger, cfg.PositionsConfig, p.client, p.limiter, cfg.ScrapeConfig, &cfg.TargetConfig)
 	if err != nil {
-		return nil, err
+		p.stop()
+		return err
+	}
+	p.configLoaded = loaded
+	return nil
+}
+
+// stop stops the targets, then the client which sends the batches in flight.
+func (p *Promtail) stop() {
+	// resume the paused targets so they can be stopped.
+	if p.limiter != nil {
+		p.limiter.Stop()
+	}
+	if p.targetManagers != nil {
+		p.targetManagers.Stop()
+		p.targetManagers = nil
+	}
+	if p.targetsReg != nil {
+		p.targetsReg.UnregisterAll()
 	}
-	promtail.targetManagers = tms
-	server, err := server.New(cfg.ServerConfig, promtail.logger, tms, cfg.String())
+	// todo work out the stop.
+	if p.client != nil {
+		p.client.Stop()
+		p.client = nil
+	}
+}
+
+// Reload loads the config again and replaces the client and the targets with the ones of the
+// new config. The targets resume from the positions saved when they stopped,
+// and the batches in flight are sent before the client stops. When the new config can't be
+// applied, the previous one is restored.
+func (p *Promtail) Reload() error {
+	if p.newConfig == nil {
+		return errors.New("reloading the config is not supported")
+	}
+	cfg, err := p.newConfig()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err := cfg.ExpandPresets(); err != nil {
+		return err
+	}
+	cfg.Setup()
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.stopped {
+		return errors.New("promtail is stopped")
+	}
+	if err := tlspolicy.Set(cfg.TLSPolicy); err != nil {
+		return err
+	}
+
+	previous := p.configLoaded
+	p.stop()
+	if err := p.start(*cfg); err != nil {
+		level.Error(p.logger).Log("msg", "failed to apply the new config, restoring the previous one", "err", err)
+		if err := tlspolicy.Set(previous.TLSPolicy); err != nil {
+			return err
+		}
+		if err := p.start(previous); err != nil {
+			level.Error(p.logger).Log("msg", "failed to restore the previous config", "err", err)
+		}
+		p.server.Update(p.targetManagers, previous.String(), previous.ScrapeConfig)
+		return err
+	}
+	p.server.Update(p.targetManagers, cfg.String(), cfg.ScrapeConfig)
+	level.Info(p.logger).Log("msg", "config reloaded")
+	return nil
+}
+
+// watchReload reloads the config on SIGHUP until Promtail is shut down.
+func (p *Promtail) watchReload() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-hup:
+			if err := p.Reload(); err != nil {
+				level.Error(p.logger).Log("msg", "failed to reload config", "err", err)
+			}
+		case <-p.quit:
+			return
+		}
 	}
-	promtail.server = server
-	return promtail, nil
 }
 
 // Run the promtail; will block until a signal is received.
@@ -93,11 +238,16 @@ func (p *Promtail) Run() error {
 		return nil
 	}
 	p.mtx.Unlock() // unlock before blocking
+	if p.newConfig != nil {
+		go p.watchReload()
+	}
 	return p.server.Run()
 }
 
 // Client returns the underlying client Promtail uses to write to Loki.
 func (p *Promtail) Client() client.Client {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
 	return p.client
 }
 
@@ -105,13 +255,13 @@ func (p *Promtail) Client() client.Client {
 func (p *Promtail) Shutdown() {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
+	if p.stopped {
+		return
+	}
 	p.stopped = true
+	close(p.quit)
 	if p.server != nil {
 		p.server.Shutdown()
 	}
-	if p.targetManagers != nil {
-		p.targetManagers.Stop()
-	}
-	// todo work out the stop.
-	p.client.Stop()
+	p.stop()
 }
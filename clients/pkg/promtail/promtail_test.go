@@ -2,12 +2,14 @@ package promtail
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -40,6 +42,7 @@ import (
 	"github.com/grafana/loki/clients/pkg/promtail/server"
 	file2 "github.com/grafana/loki/clients/pkg/promtail/targets/file"
 
+	"github.com/grafana/loki/pkg/loghttp/push"
 	"github.com/grafana/loki/pkg/logproto"
 )
 
@@ -646,9 +649,6 @@ func Test_DryRun(t *testing.T) {
 	require.NoError(t, err)
 	defer os.Remove(f.Name())
 
-	_, err = New(config.Config{}, true)
-	require.Error(t, err)
-
 	// Set the minimum config needed to start a server. We need to do this since we
 	// aren't doing any CLI parsing ala RegisterFlags and thus don't get the defaults.
 	// Required because a hardcoded value became a configuration setting in this commit
@@ -660,6 +660,20 @@ func Test_DryRun(t *testing.T) {
 		},
 	}
 
+	// Dry run needs no client, the entries are printed instead of sent.
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	p, err := New(config.Config{
+		ServerConfig: serverCfg,
+		PositionsConfig: positions.Config{
+			PositionsFile: f.Name(),
+			SyncPeriod:    time.Second,
+		},
+	}, true)
+	require.NoError(t, err)
+	_, isMulti := p.client.(*client.MultiClient)
+	require.False(t, isMulti)
+	p.Shutdown()
+
 	prometheus.DefaultRegisterer = prometheus.NewRegistry() // reset registry, otherwise you can't create 2 weavework server.
 	_, err = New(config.Config{
 		ServerConfig: serverCfg,
@@ -673,7 +687,7 @@ func Test_DryRun(t *testing.T) {
 
 	prometheus.DefaultRegisterer = prometheus.NewRegistry()
 
-	p, err := New(config.Config{
+	p, err = New(config.Config{
 		ServerConfig: serverCfg,
 		ClientConfig: client.Config{URL: flagext.URLValue{URL: &url.URL{Host: "string"}}},
 		PositionsConfig: positions.Config{
@@ -684,3 +698,98 @@ func Test_DryRun(t *testing.T) {
 	require.NoError(t, err)
 	require.IsType(t, &client.MultiClient{}, p.client)
 }
+
+func TestPromtail_Reload(t *testing.T) {
+	received := make(chan string, 100)
+	lokiServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		pushReq, err := push.ParseRequest(log.NewNopLogger(), "", req, nil, push.BodyLimits{})
+		require.NoError(t, err)
+		for _, s := range pushReq.Streams {
+			for _, e := range s.Entries {
+				received <- e.Line
+			}
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer lokiServer.Close()
+
+	dir := t.TempDir()
+	newConfig := func(path string) config.Config {
+		cfg := config.Config{}
+		cfg.RegisterFlags(flag.NewFlagSet("reload", flag.PanicOnError))
+		cfg.ServerConfig.Disable = true
+		require.NoError(t, cfg.ClientConfig.URL.Set(lokiServer.URL))
+		cfg.ClientConfig.BatchWait = 10 * time.Millisecond
+		cfg.PositionsConfig.PositionsFile = filepath.Join(dir, "positions.yaml")
+		cfg.TargetConfig.SyncPeriod = 100 * time.Millisecond
+		cfg.ScrapeConfig = []scrapeconfig.Config{{
+			JobName: "reload",
+			ServiceDiscoveryConfig: scrapeconfig.ServiceDiscoveryConfig{
+				StaticConfigs: discovery.StaticConfig{{
+					Targets: []model.LabelSet{{"__path__": model.LabelValue(filepath.Join(dir, path)), "job": "reload"}},
+				}},
+			},
+		}}
+		return cfg
+	}
+	appendLine := func(name, line string) {
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		require.NoError(t, err)
+		_, err = f.WriteString(line + "\n")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+	// the discovered targets are only synced every 5s.
+	expect := func(lines ...string) {
+		t.Helper()
+		for _, line := range lines {
+			select {
+			case l := <-received:
+				require.Equal(t, line, l)
+			case <-time.After(15 * time.Second):
+				t.Fatalf("timed out waiting for %q", line)
+			}
+		}
+		select {
+		case l := <-received:
+			t.Fatalf("unexpected line %q", l)
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+
+	next := newConfig("a.log")
+	p, err := New(next, false, WithRegisterer(prometheus.NewRegistry()), WithNewConfig(func() (*config.Config, error) {
+		cfg := next
+		return &cfg, nil
+	}))
+	require.NoError(t, err)
+	defer p.Shutdown()
+
+	appendLine("a.log", "a1")
+	expect("a1")
+
+	tms := p.targetManagers
+	// the targets of the new config replace the previous ones, the lines written in between
+	// are read from the saved positions.
+	next = newConfig("*.log")
+	appendLine("a.log", "a2")
+	require.NoError(t, p.Reload())
+	require.NotSame(t, tms, p.targetManagers)
+	expect("a2")
+	appendLine("b.log", "b1")
+	expect("b1")
+
+	// a config which can't be applied keeps the previous one.
+	invalid := newConfig("b.log")
+	invalid.ClientConfig.URL = flagext.URLValue{}
+	next = invalid
+	require.Error(t, p.Reload())
+	appendLine("a.log", "a3")
+	expect("a3")
+
+	next = newConfig("b.log")
+	require.NoError(t, p.Reload())
+	appendLine("a.log", "a4")
+	appendLine("b.log", "b2")
+	expect("b2")
+}
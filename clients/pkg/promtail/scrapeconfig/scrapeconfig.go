@@ -29,23 +29,46 @@ import (
 
 	"github.com/grafana/loki/clients/pkg/logentry/stages"
 	"github.com/grafana/loki/clients/pkg/promtail/discovery/consulagent"
+	"github.com/grafana/loki/clients/pkg/promtail/discovery/http"
+	lokiflag "github.com/grafana/loki/pkg/util/flagext"
 )
 
 // Config describes a job to scrape.
 type Config struct {
-	JobName                string                     `yaml:"job_name,omitempty"`
-	PipelineStages         stages.PipelineStages      `yaml:"pipeline_stages,omitempty"`
-	JournalConfig          *JournalTargetConfig       `yaml:"journal,omitempty"`
-	SyslogConfig           *SyslogTargetConfig        `yaml:"syslog,omitempty"`
-	GcplogConfig           *GcplogTargetConfig        `yaml:"gcplog,omitempty"`
-	PushConfig             *PushTargetConfig          `yaml:"loki_push_api,omitempty"`
-	WindowsConfig          *WindowsEventsTargetConfig `yaml:"windows_events,omitempty"`
-	KafkaConfig            *KafkaTargetConfig         `yaml:"kafka,omitempty"`
-	GelfConfig             *GelfTargetConfig          `yaml:"gelf,omitempty"`
-	RelabelConfigs         []*relabel.Config          `yaml:"relabel_configs,omitempty"`
-	ServiceDiscoveryConfig ServiceDiscoveryConfig     `yaml:",inline"`
+	JobName                string                           `yaml:"job_name,omitempty"`
+	Priority               int                              `yaml:"priority,omitempty"`
+	PipelineStages         stages.PipelineStages            `yaml:"pipeline_stages,omitempty"`
+	NamedPipelineStages    map[string]stages.PipelineStages `yaml:"named_pipeline_stages,omitempty"`
+	JournalConfig          *JournalTargetConfig             `yaml:"journal,omitempty"`
+	SyslogConfig           *SyslogTargetConfig              `yaml:"syslog,omitempty"`
+	GcplogConfig           *GcplogTargetConfig              `yaml:"gcplog,omitempty"`
+	PushConfig             *PushTargetConfig                `yaml:"loki_push_api,omitempty"`
+	WindowsConfig          *WindowsEventsTargetConfig       `yaml:"windows_events,omitempty"`
+	KafkaConfig            *KafkaTargetConfig               `yaml:"kafka,omitempty"`
+	GelfConfig             *GelfTargetConfig                `yaml:"gelf,omitempty"`
+	KinesisConfig          *KinesisTargetConfig             `yaml:"kinesis,omitempty"`
+	S3Config               *S3TargetConfig                  `yaml:"s3,omitempty"`
+	MQTTConfig             *MQTTTargetConfig                `yaml:"mqtt,omitempty"`
+	AMQPConfig             *AMQPTargetConfig                `yaml:"amqp,omitempty"`
+	DockerConfig           *DockerTargetConfig              `yaml:"docker,omitempty"`
+	KubernetesEventsConfig *KubernetesEventsTargetConfig    `yaml:"kubernetes_events,omitempty"`
+	RelabelConfigs         []*relabel.Config                `yaml:"relabel_configs,omitempty"`
+	// StartPosition is where the file and journal targets start reading the sources without a
+	// saved position.
+	StartPosition          string                 `yaml:"start_position,omitempty"`
+	ServiceDiscoveryConfig ServiceDiscoveryConfig `yaml:",inline"`
 }
 
+const (
+	// StartPositionPositions reads the sources without a saved position from the default start
+	// of their target: the beginning of the files, and max_age ago in the journal.
+	StartPositionPositions = "positions"
+	// StartPositionBeginning reads the sources without a saved position from their beginning.
+	StartPositionBeginning = "beginning"
+	// StartPositionEnd reads the sources without a saved position from their end.
+	StartPositionEnd = "end"
+)
+
 type ServiceDiscoveryConfig struct {
 	// List of labeled target groups for this job.
 	StaticConfigs discovery.StaticConfig `yaml:"static_configs"`
@@ -61,6 +84,8 @@ type ServiceDiscoveryConfig struct {
 	DigitalOceanSDConfigs []*digitalocean.SDConfig `yaml:"digitalocean_sd_configs,omitempty"`
 	// List of Docker Swarm service discovery configurations.
 	DockerSwarmSDConfigs []*moby.DockerSwarmSDConfig `yaml:"dockerswarm_sd_configs,omitempty"`
+	// List of HTTP service discovery configurations.
+	HTTPSDConfigs []*http.SDConfig `yaml:"http_sd_configs,omitempty"`
 	// List of Serverset service discovery configurations.
 	ServersetSDConfigs []*zookeeper.ServersetSDConfig `yaml:"serverset_sd_configs,omitempty"`
 	// NerveSDConfigs is a list of Nerve service discovery configurations.
@@ -103,6 +128,9 @@ func (cfg ServiceDiscoveryConfig) Configs() (res discovery.Configs) {
 	for _, x := range cfg.DockerSwarmSDConfigs {
 		res = append(res, x)
 	}
+	for _, x := range cfg.HTTPSDConfigs {
+		res = append(res, x)
+	}
 	for _, x := range cfg.ServersetSDConfigs {
 		res = append(res, x)
 	}
@@ -155,6 +183,11 @@ type JournalTargetConfig struct {
 	// Path to a directory to read journal entries from. Defaults to system path
 	// if empty.
 	Path string `yaml:"path"`
+
+	// Matches filters the entries read from the journal with FIELD=VALUE expressions,
+	// or comparisons of PRIORITY such as PRIORITY<=3. Matches of the same field are
+	// combined with OR, matches of different fields with AND.
+	Matches []string `yaml:"matches"`
 }
 
 // SyslogTargetConfig describes a scrape config that listens for log lines over syslog.
@@ -162,6 +195,12 @@ type SyslogTargetConfig struct {
 	// ListenAddress is the address to listen on for syslog messages.
 	ListenAddress string `yaml:"listen_address"`
 
+	// ListenProtocol is the protocol to listen on for syslog messages: tcp (default) or udp.
+	ListenProtocol string `yaml:"listen_protocol"`
+
+	// SyslogFormat is the format of the syslog messages: rfc5424 (default) or rfc3164.
+	SyslogFormat string `yaml:"syslog_format"`
+
 	// IdleTimeout is the idle timeout for tcp connections.
 	IdleTimeout time.Duration `yaml:"idle_timeout"`
 
@@ -181,6 +220,29 @@ type SyslogTargetConfig struct {
 	MaxMessageLength int `yaml:"max_message_length"`
 
 	TLSConfig promconfig.TLSConfig `yaml:"tls_config,omitempty"`
+
+	// AllowedClientCNs restricts the client certificates verified with the CA of TLSConfig
+	// to the ones with one of these common names, or one of AllowedClientSANs.
+	AllowedClientCNs []string `yaml:"allowed_client_cns"`
+
+	// AllowedClientSANs restricts the client certificates verified with the CA of TLSConfig
+	// to the ones with one of these DNS names, email addresses, IP addresses or URIs as subject
+	// alternative name, or one of AllowedClientCNs.
+	AllowedClientSANs []string `yaml:"allowed_client_sans"`
+
+	// DiskBuffer optionally buffers the messages on disk while they can't be sent to Loki.
+	DiskBuffer *DiskBufferConfig `yaml:"disk_buffer,omitempty"`
+}
+
+// DiskBufferConfig configures a buffer on disk holding the entries of a target which can't be
+// sent to Loki, e.g. during short Loki outages.
+type DiskBufferConfig struct {
+	// Directory holding the buffer, it must not be shared with other targets (Required).
+	Directory string `yaml:"directory"`
+
+	// MaxSize is the maximum size of the buffer, the oldest entries are evicted once
+	// it's reached. (Default to 100MB)
+	MaxSize lokiflag.ByteSize `yaml:"max_size"`
 }
 
 // WindowsEventsTargetConfig describes a scrape config that listen for windows event logs.
@@ -222,10 +284,34 @@ type WindowsEventsTargetConfig struct {
 	// ExcludeUserData allows to exclude the user data of each windows event.
 	ExcludeUserData bool `yaml:"exclude_user_data"`
 
+	// EvtxFiles lists glob patterns of archived .evtx files to replay instead of reading the
+	// event log of the local computer. The patterns are matched again every poll interval and
+	// the record id of the last event read from each file is saved in the positions file.
+	EvtxFiles []string `yaml:"evtx_files"`
+
+	// Remote subscribes to the event logs of remote computers instead of the local computer.
+	Remote *WindowsEventsRemoteConfig `yaml:"remote"`
+
 	// Labels optionally holds labels to associate with each log line.
 	Labels model.LabelSet `yaml:"labels"`
 }
 
+// WindowsEventsRemoteConfig describes the remote computers a windows events target subscribes to.
+// The bookmark of each subscription is saved in the positions file.
+type WindowsEventsRemoteConfig struct {
+	// Servers are the names or addresses of the remote computers.
+	Servers []string `yaml:"servers"`
+
+	// Domain, Username and Password are the credentials used to connect to the remote
+	// computers, the credentials of the user running Promtail are used when empty.
+	Domain   string         `yaml:"domain"`
+	Username string         `yaml:"username"`
+	Password flagext.Secret `yaml:"password"`
+
+	// Authentication is the authentication method: default, negotiate, kerberos or ntlm.
+	Authentication string `yaml:"authentication"`
+}
+
 type KafkaTargetConfig struct {
 	// Labels optionally holds labels to associate with each log line.
 	Labels model.LabelSet `yaml:"labels"`
@@ -243,6 +329,9 @@ type KafkaTargetConfig struct {
 	// Kafka Topics to consume (Required).
 	Topics []string `yaml:"topics"`
 
+	// Kafka Topics never consumed, even when matched by Topics.
+	ExcludeTopics []string `yaml:"exclude_topics"`
+
 	// Kafka version. Default to 2.2.1
 	Version string `yaml:"version"`
 
@@ -251,6 +340,123 @@ type KafkaTargetConfig struct {
 
 	// Authentication strategy with Kafka brokers
 	Authentication KafkaAuthentication `yaml:"authentication"`
+
+	// CommitStrategy controls when consumed offsets are committed to Kafka.
+	// Possible values: periodic, per_message and per_batch (defaults to periodic).
+	CommitStrategy KafkaCommitStrategy `yaml:"commit_strategy"`
+
+	// CommitInterval is the interval at which offsets are committed when using
+	// the periodic strategy. (Default to 1s)
+	// It can't be set with any other strategy.
+	CommitInterval time.Duration `yaml:"commit_interval"`
+
+	// MaxUncommittedMessages forces a commit once this many messages have been
+	// consumed since the last commit, whatever the strategy. 0 means no limit.
+	MaxUncommittedMessages int `yaml:"max_uncommitted_messages"`
+
+	// MaxInflightMessages is the maximum number of messages of a claimed partition
+	// sent to the clients and waiting for their acknowledgement. Offsets are only
+	// committed once the messages have been pushed to Loki. (Default to 1000)
+	MaxInflightMessages int `yaml:"max_inflight_messages"`
+
+	// MaxMessagesPerSecond limits the rate of messages consumed by all the claimed
+	// partitions of the target. 0 means no limit.
+	MaxMessagesPerSecond float64 `yaml:"max_messages_per_second"`
+
+	// MaxBytesPerSecond limits the rate of message value bytes consumed by all the
+	// claimed partitions of the target. 0 means no limit.
+	MaxBytesPerSecond lokiflag.ByteSize `yaml:"max_bytes_per_second"`
+
+	// Decoder decodes the message values into log lines.
+	Decoder KafkaDecoder `yaml:"decoder"`
+
+	// InitialOffset is where the consumption of the partitions without a committed
+	// offset starts: oldest, newest or a RFC3339 timestamp. (Default to oldest)
+	InitialOffset string `yaml:"initial_offset"`
+
+	// TopicInitialOffsets overrides InitialOffset for the topics with the given names.
+	TopicInitialOffsets map[string]string `yaml:"topic_initial_offsets"`
+
+	// Workers is the number of goroutines running the pipeline stages for each
+	// claimed partition. Messages with the same key are always processed by the
+	// same worker. (Default to 1)
+	Workers int `yaml:"workers"`
+
+	// IsolationLevel controls whether the messages of transactions not committed yet
+	// or aborted are consumed: read_uncommitted or read_committed. (Default to read_uncommitted)
+	IsolationLevel KafkaIsolationLevel `yaml:"isolation_level"`
+
+	// DiskBuffer optionally buffers the messages on disk while they can't be sent to Loki.
+	// Offsets are committed once the messages are written to disk.
+	DiskBuffer *DiskBufferConfig `yaml:"disk_buffer,omitempty"`
+}
+
+// KafkaIsolationLevel specifies which messages of transactional producers are consumed.
+type KafkaIsolationLevel string
+
+const (
+	// KafkaIsolationLevelReadUncommitted consumes every message, including the messages of aborted transactions.
+	KafkaIsolationLevelReadUncommitted = "read_uncommitted"
+	// KafkaIsolationLevelReadCommitted only consumes the messages of committed transactions, and
+	// of non-transactional producers.
+	KafkaIsolationLevelReadCommitted = "read_committed"
+)
+
+// KafkaCommitStrategy specifies when consumed offsets are committed to Kafka.
+type KafkaCommitStrategy string
+
+const (
+	// KafkaCommitStrategyPeriodic commits offsets in the background at a fixed interval.
+	KafkaCommitStrategyPeriodic KafkaCommitStrategy = "periodic"
+	// KafkaCommitStrategyPerMessage commits the offset of every message once it has been processed.
+	KafkaCommitStrategyPerMessage KafkaCommitStrategy = "per_message"
+	// KafkaCommitStrategyPerBatch commits offsets once per batch of messages, whenever the consumer
+	// buffer of a claim has been drained.
+	KafkaCommitStrategyPerBatch KafkaCommitStrategy = "per_batch"
+)
+
+const (
+	// KafkaInitialOffsetOldest starts consuming partitions from their oldest message.
+	KafkaInitialOffsetOldest = "oldest"
+	// KafkaInitialOffsetNewest starts consuming partitions from the messages produced after the consumer starts.
+	KafkaInitialOffsetNewest = "newest"
+)
+
+// KafkaDecoderType specifies how message values are decoded.
+type KafkaDecoderType string
+
+const (
+	// KafkaDecoderPlaintext uses message values as log lines.
+	KafkaDecoderPlaintext = "plaintext"
+	// KafkaDecoderJSON uses JSON message values as log lines.
+	KafkaDecoderJSON = "json"
+	// KafkaDecoderAvro decodes Avro message values into JSON log lines.
+	KafkaDecoderAvro = "avro"
+	// KafkaDecoderProtobuf decodes protobuf message values into JSON log lines.
+	KafkaDecoderProtobuf = "protobuf"
+)
+
+// KafkaDecoder describes how message values are decoded.
+type KafkaDecoder struct {
+	// Type is the decoder type
+	// Possible values: plaintext, json, avro and protobuf (defaults to plaintext).
+	Type KafkaDecoderType `yaml:"type"`
+
+	// SchemaRegistry resolves the schemas of avro and protobuf message values.
+	SchemaRegistry KafkaSchemaRegistry `yaml:"schema_registry,omitempty"`
+}
+
+// KafkaSchemaRegistry describes the Confluent Schema Registry holding the schemas of message values.
+type KafkaSchemaRegistry struct {
+	// URL of the schema registry.
+	URL string `yaml:"url"`
+
+	// Username and Password for basic authentication with the schema registry.
+	Username string         `yaml:"username"`
+	Password flagext.Secret `yaml:"password"`
+
+	// TLSConfig is used to connect to the schema registry.
+	TLSConfig promconfig.TLSConfig `yaml:"tls_config,omitempty"`
 }
 
 // KafkaAuthenticationType specifies method to authenticate with Kafka brokers
@@ -280,7 +486,7 @@ type KafkaAuthentication struct {
 
 // KafkaSASLConfig describe the SASL configuration for authentication with Kafka brokers
 type KafkaSASLConfig struct {
-	// SASL mechanism. Supports PLAIN, SCRAM-SHA-256 and SCRAM-SHA-512
+	// SASL mechanism. Supports PLAIN, SCRAM-SHA-256, SCRAM-SHA-512, OAUTHBEARER and GSSAPI
 	Mechanism sarama.SASLMechanism `yaml:"mechanism"`
 
 	// SASL Username
@@ -294,6 +500,53 @@ type KafkaSASLConfig struct {
 
 	// TLSConfig is used for SASL over TLS. It is used only when UseTLS is true
 	TLSConfig promconfig.TLSConfig `yaml:",inline"`
+
+	// OAuthConfig is used to get the tokens of the OAUTHBEARER mechanism.
+	OAuthConfig KafkaSASLOAuthConfig `yaml:"oauth_config,omitempty"`
+
+	// GSSAPIConfig is used to authenticate with Kerberos using the GSSAPI mechanism.
+	GSSAPIConfig KafkaSASLGSSAPIConfig `yaml:"gssapi_config,omitempty"`
+}
+
+// KafkaSASLGSSAPIConfig describe the Kerberos configuration of the SASL GSSAPI mechanism.
+// The principal is the SASL user, it authenticates with the keytab if set, with the SASL password otherwise.
+type KafkaSASLGSSAPIConfig struct {
+	// ServiceName is the Kerberos service name of the brokers. (Default to `kafka`)
+	ServiceName string `yaml:"service_name"`
+
+	// Realm of the principal.
+	Realm string `yaml:"realm"`
+
+	// KeytabPath is the path to the keytab of the principal.
+	KeytabPath string `yaml:"keytab_path"`
+
+	// KerberosConfigPath is the path to the krb5.conf file. (Default to `/etc/krb5.conf`)
+	KerberosConfigPath string `yaml:"kerberos_config_path"`
+
+	// DisablePAFXFAST disables the PA-FX-FAST pre-authentication, required by Active Directory.
+	DisablePAFXFAST bool `yaml:"disable_pa_fx_fast"`
+}
+
+// KafkaSASLOAuthConfig describe how to get the OAuth tokens used by the SASL OAUTHBEARER mechanism.
+// Tokens are either fetched with the client credentials flow or read from a file refreshed by another process.
+type KafkaSASLOAuthConfig struct {
+	// TokenURL is the endpoint tokens are requested from with the client credentials flow.
+	TokenURL string `yaml:"token_url"`
+
+	// ClientID used for the client credentials flow.
+	ClientID string `yaml:"client_id"`
+
+	// ClientSecret used for the client credentials flow.
+	ClientSecret flagext.Secret `yaml:"client_secret"`
+
+	// Scopes requested with the client credentials flow.
+	Scopes []string `yaml:"scopes"`
+
+	// TokenFile is read every time a token is needed, instead of using the client credentials flow.
+	TokenFile string `yaml:"token_file"`
+
+	// Extensions sent to the broker with the token, e.g. the logical cluster of Confluent Cloud.
+	Extensions map[string]string `yaml:"extensions"`
 }
 
 // GelfTargetConfig describes a scrape config that read GELF messages on UDP.
@@ -309,6 +562,258 @@ type GelfTargetConfig struct {
 	UseIncomingTimestamp bool `yaml:"use_incoming_timestamp"`
 }
 
+// KinesisTargetConfig describes a scrape config that consumes the records of an AWS Kinesis data stream.
+type KinesisTargetConfig struct {
+	// StreamName is the name of the stream to consume (Required).
+	StreamName string `yaml:"stream_name"`
+
+	// Region is the AWS region of the stream. Default to the region of the environment.
+	Region string `yaml:"region"`
+
+	// Endpoint overrides the Kinesis endpoint of the region, e.g. to use a VPC endpoint.
+	Endpoint string `yaml:"endpoint"`
+
+	// AccessKeyID and SecretAccessKey are static credentials, the default credentials
+	// chain of the environment is used when they are not set.
+	AccessKeyID     string         `yaml:"access_key_id"`
+	SecretAccessKey flagext.Secret `yaml:"secret_access_key"`
+
+	// Labels optionally holds labels to associate with each record.
+	Labels model.LabelSet `yaml:"labels"`
+
+	// UseIncomingTimestamp sets the timestamp to the approximate arrival time of the records.
+	UseIncomingTimestamp bool `yaml:"use_incoming_timestamp"`
+
+	// InitialPosition is where the consumption of the shards without a checkpoint
+	// starts: trim_horizon, latest or a RFC3339 timestamp. (Default to trim_horizon)
+	InitialPosition string `yaml:"initial_position"`
+
+	// PollInterval is how long to wait between two reads of a shard once all of its
+	// records have been read. (Default to 1s)
+	PollInterval time.Duration `yaml:"poll_interval"`
+
+	// MaxRecords is the maximum number of records of a read. (Default to 10000)
+	MaxRecords int `yaml:"max_records"`
+
+	// ShardSyncInterval is the interval at which shards are listed to follow the
+	// resharding of the stream. (Default to 1m)
+	ShardSyncInterval time.Duration `yaml:"shard_sync_interval"`
+
+	// EnhancedFanOut subscribes to the shards with a dedicated throughput instead of polling them.
+	EnhancedFanOut KinesisEnhancedFanOut `yaml:"enhanced_fan_out"`
+
+	// Checkpoint describes where the sequence numbers of the consumed records are stored.
+	Checkpoint KinesisCheckpoint `yaml:"checkpoint"`
+}
+
+// KinesisEnhancedFanOut describes the stream consumer used to subscribe to shards.
+type KinesisEnhancedFanOut struct {
+	// ConsumerName is the name of the stream consumer, registered if it doesn't exist.
+	// Enhanced fan-out is enabled when it is set.
+	ConsumerName string `yaml:"consumer_name"`
+}
+
+// KinesisCheckpointType specifies where the sequence numbers of consumed records are stored.
+type KinesisCheckpointType string
+
+const (
+	// KinesisCheckpointPositions stores sequence numbers in the positions file.
+	KinesisCheckpointPositions = "positions"
+	// KinesisCheckpointDynamoDB stores sequence numbers in a DynamoDB table.
+	KinesisCheckpointDynamoDB = "dynamodb"
+)
+
+// KinesisCheckpoint describes where the sequence numbers of consumed records are stored.
+type KinesisCheckpoint struct {
+	// Type is where sequence numbers are stored: positions or dynamodb. (Default to positions)
+	Type KinesisCheckpointType `yaml:"type"`
+
+	// DynamoDBTable is the table storing sequence numbers, with a string `shard` hash key.
+	DynamoDBTable string `yaml:"dynamodb_table"`
+}
+
+// S3TargetConfig describes a scrape config that reads the objects created in S3 buckets,
+// as notified by the S3 event notifications of an SQS queue.
+type S3TargetConfig struct {
+	// QueueURL is the URL of the SQS queue receiving the event notifications (Required).
+	QueueURL string `yaml:"queue_url"`
+
+	// Region is the AWS region of the queue and the buckets. Default to the region of the environment.
+	Region string `yaml:"region"`
+
+	// SQSEndpoint and S3Endpoint override the endpoints of the region, e.g. to use VPC endpoints.
+	SQSEndpoint string `yaml:"sqs_endpoint"`
+	S3Endpoint  string `yaml:"s3_endpoint"`
+
+	// S3ForcePathStyle addresses buckets in the path of the URL instead of the host,
+	// as required by some S3 compatible stores.
+	S3ForcePathStyle bool `yaml:"s3_force_path_style"`
+
+	// AccessKeyID and SecretAccessKey are static credentials, the default credentials
+	// chain of the environment is used when they are not set.
+	AccessKeyID     string         `yaml:"access_key_id"`
+	SecretAccessKey flagext.Secret `yaml:"secret_access_key"`
+
+	// Labels optionally holds labels to associate with each line.
+	Labels model.LabelSet `yaml:"labels"`
+
+	// UseIncomingTimestamp sets the timestamp of the lines to the time of the event
+	// notification of their object.
+	UseIncomingTimestamp bool `yaml:"use_incoming_timestamp"`
+
+	// MaxMessages is the maximum number of notifications received at once, between 1 and 10. (Default to 10)
+	MaxMessages int `yaml:"max_messages"`
+
+	// WaitTime is how long a receive waits for notifications, up to 20s. (Default to 20s)
+	WaitTime time.Duration `yaml:"wait_time"`
+
+	// VisibilityTimeout is how long received notifications are hidden from other
+	// receivers while their objects are read. Default to the timeout of the queue.
+	VisibilityTimeout time.Duration `yaml:"visibility_timeout"`
+}
+
+// MQTTTargetConfig describes a scrape config that subscribes to the topics of an MQTT broker.
+type MQTTTargetConfig struct {
+	// Broker is the address of the broker, as tcp://host:port, or ssl://host:port to connect with TLS (Required).
+	Broker string `yaml:"broker"`
+
+	// ProtocolVersion is the version of MQTT spoken with the broker, 3.1.1 or 5. (Default to 3.1.1)
+	ProtocolVersion string `yaml:"protocol_version"`
+
+	// Topics are the topic filters to subscribe to, they may contain the + and # wildcards (Required).
+	Topics []string `yaml:"topics"`
+
+	// QoS is the quality of service of the subscriptions, 0 or 1. (Default to 1)
+	QoS *int `yaml:"qos"`
+
+	// ClientID identifies the session of Promtail on the broker, required to keep the
+	// session. Default to a random identifier.
+	ClientID string `yaml:"client_id"`
+
+	// CleanSession starts a new session on each connection. When false, the broker keeps
+	// the subscriptions and the messages published while Promtail is disconnected. (Default to true)
+	CleanSession *bool `yaml:"clean_session"`
+
+	// SessionExpiry is how long an MQTT 5 broker keeps the session after Promtail disconnects,
+	// when the session is kept. (Default to 1h)
+	SessionExpiry time.Duration `yaml:"session_expiry"`
+
+	// Username and Password authenticate Promtail to the broker.
+	Username string         `yaml:"username"`
+	Password flagext.Secret `yaml:"password"`
+
+	// KeepAlive is the maximum interval between two packets sent to the broker. (Default to 30s)
+	KeepAlive time.Duration `yaml:"keep_alive"`
+
+	// ConnectTimeout is how long connecting and subscribing may take. (Default to 10s)
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+
+	// MaxInflightMessages is the maximum number of QoS 1 messages waiting to be
+	// pushed to Loki before they are acknowledged to the broker. (Default to 100)
+	MaxInflightMessages int `yaml:"max_inflight_messages"`
+
+	// TLSConfig is used to connect to ssl:// brokers, it holds the client certificate.
+	TLSConfig promconfig.TLSConfig `yaml:"tls_config,omitempty"`
+
+	// Labels optionally holds labels to associate with each message.
+	Labels model.LabelSet `yaml:"labels"`
+}
+
+// AMQPTargetConfig describes a scrape config that consumes the messages of an AMQP 0.9.1 queue,
+// such as a RabbitMQ queue.
+type AMQPTargetConfig struct {
+	// URL is the address of the broker, as amqp://host:port/vhost, or amqps://host:port/vhost
+	// to connect with TLS. It may hold the credentials of Promtail (Required).
+	URL string `yaml:"url"`
+
+	// Username and Password authenticate Promtail to the broker, they override the
+	// credentials of the URL. (Default to guest)
+	Username string         `yaml:"username"`
+	Password flagext.Secret `yaml:"password"`
+
+	// Queue is the name of the queue to consume (Required).
+	Queue string `yaml:"queue"`
+
+	// DeclareQueue declares the queue as a durable queue when it doesn't exist.
+	DeclareQueue bool `yaml:"declare_queue"`
+
+	// Exchange, when set, is the exchange the queue is bound to with the BindingKeys.
+	Exchange string `yaml:"exchange"`
+
+	// BindingKeys are the routing keys binding the queue to the exchange. (Default to #)
+	BindingKeys []string `yaml:"binding_keys"`
+
+	// ConsumerTag identifies the consumer of Promtail on the queue. Default to a tag
+	// generated by the broker.
+	ConsumerTag string `yaml:"consumer_tag"`
+
+	// PrefetchCount is the maximum number of messages waiting to be pushed to Loki
+	// before they are acknowledged to the broker. (Default to 100)
+	PrefetchCount int `yaml:"prefetch_count"`
+
+	// Heartbeat is the interval of the heartbeats detecting dead connections. (Default to 30s)
+	Heartbeat time.Duration `yaml:"heartbeat"`
+
+	// ConnectTimeout is how long connecting and starting to consume may take. (Default to 10s)
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+
+	// TLSConfig is used to connect to amqps:// brokers, it holds the client certificate.
+	TLSConfig promconfig.TLSConfig `yaml:"tls_config,omitempty"`
+
+	// Labels optionally holds labels to associate with each message.
+	Labels model.LabelSet `yaml:"labels"`
+
+	// UseIncomingTimestamp sets the timestamp of the log lines to the timestamp property
+	// of their message, when it is set.
+	UseIncomingTimestamp bool `yaml:"use_incoming_timestamp"`
+}
+
+// DockerTargetConfig describes a scrape config that discovers the running containers of
+// a Docker daemon and reads their logs through the Docker Engine API.
+type DockerTargetConfig struct {
+	// Host is the address of the Docker daemon. (Default to unix:///var/run/docker.sock)
+	Host string `yaml:"host"`
+
+	// RefreshInterval is how often the containers are listed. (Default to 10s)
+	RefreshInterval model.Duration `yaml:"refresh_interval"`
+
+	// Filters select the containers to read, as the filters of docker ps.
+	Filters []DockerFilter `yaml:"filters"`
+
+	// Labels optionally holds labels to associate with each log line.
+	Labels model.LabelSet `yaml:"labels"`
+}
+
+// DockerFilter is a filter of the containers of a Docker daemon, such as
+// label=com.example.app or status=running.
+type DockerFilter struct {
+	Name   string   `yaml:"name"`
+	Values []string `yaml:"values"`
+}
+
+// KubernetesEventsTargetConfig describes a scrape config that watches the events of a
+// Kubernetes cluster and pushes each event as a log line.
+type KubernetesEventsTargetConfig struct {
+	// APIServer is the address of the Kubernetes API server, the in-cluster configuration
+	// is used when both it and KubeConfig are empty.
+	APIServer string `yaml:"api_server"`
+
+	// KubeConfig is the path of a kubeconfig file to connect to the API server with.
+	KubeConfig string `yaml:"kubeconfig_file"`
+
+	// Namespaces are the namespaces to watch the events of. (Default to all namespaces)
+	Namespaces []string `yaml:"namespaces"`
+
+	// FieldSelector selects the events to watch by their fields, such as type=Warning.
+	FieldSelector string `yaml:"field_selector"`
+
+	// LabelSelector selects the events to watch by their labels.
+	LabelSelector string `yaml:"label_selector"`
+
+	// Labels optionally holds labels to associate with each event.
+	Labels model.LabelSet `yaml:"labels"`
+}
+
 // GcplogTargetConfig describes a scrape config to pull logs from any pubsub topic.
 type GcplogTargetConfig struct {
 	// ProjectID is the Cloud project id
@@ -336,6 +841,10 @@ type PushTargetConfig struct {
 
 	// If promtail should maintain the incoming log timestamp or replace it with the current time.
 	KeepTimestamp bool `yaml:"use_incoming_timestamp"`
+
+	// RawFormat is the format of the bodies of the plaintext endpoint: lines, each non empty line
+	// being a log line, or json, each JSON document being a log line. (Default to lines)
+	RawFormat string `yaml:"raw_format"`
 }
 
 // DefaultScrapeConfig is the default Config.
@@ -362,5 +871,11 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return fmt.Errorf("job_name is empty")
 	}
 
+	switch c.StartPosition {
+	case "", StartPositionPositions, StartPositionBeginning, StartPositionEnd:
+	default:
+		return fmt.Errorf("invalid start_position %q of job %s, must be one of %s, %s or %s", c.StartPosition, c.JobName, StartPositionPositions, StartPositionBeginning, StartPositionEnd)
+	}
+
 	return nil
 }
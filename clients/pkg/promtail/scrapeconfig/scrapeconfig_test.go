@@ -141,3 +141,12 @@ func TestLoadConfig(t *testing.T) {
 		panic(err)
 	}
 }
+
+func TestStartPositionConfig(t *testing.T) {
+	var config Config
+	require.NoError(t, yaml.Unmarshal([]byte("job_name: audit\nstart_position: beginning\n"), &config))
+	require.Equal(t, StartPositionBeginning, config.StartPosition)
+
+	err := yaml.Unmarshal([]byte("job_name: audit\nstart_position: tail\n"), &config)
+	require.EqualError(t, err, `invalid start_position "tail" of job audit, must be one of positions, beginning or end`)
+}
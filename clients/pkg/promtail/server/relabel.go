@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/pkg/util"
+)
+
+// relabelRequest is the body of a relabel request.
+// It is decoded as YAML, which makes JSON bodies valid too.
+type relabelRequest struct {
+	// JobName is the scrape config whose relabel_configs and discovered targets are used by default.
+	JobName string `yaml:"job_name"`
+	// RelabelConfigs overrides the relabel_configs of the job.
+	RelabelConfigs []*relabel.Config `yaml:"relabel_configs"`
+	// Targets overrides the discovered labels of the targets of the job.
+	Targets []model.LabelSet `yaml:"targets"`
+}
+
+type relabelResponse struct {
+	Results []relabelResult `json:"results"`
+}
+
+type relabelResult struct {
+	DiscoveredLabels model.LabelSet `json:"discovered_labels"`
+	Steps            []relabelStep  `json:"steps"`
+	// Labels is null when the target is dropped.
+	Labels  model.LabelSet `json:"labels"`
+	Dropped bool           `json:"dropped"`
+}
+
+type relabelStep struct {
+	Rule   int            `json:"rule"`
+	Action relabel.Action `json:"action"`
+	Labels model.LabelSet `json:"labels"`
+}
+
+// relabel serves the relabel testing endpoint. It applies relabel configs to discovered
+// label sets and returns the labels after every rule.
+//
+// GET requests use the relabel_configs and the discovered targets of the job given by the
+// job query parameter, POST requests can also provide their own relabel_configs and targets.
+func (s *server) relabel(rw http.ResponseWriter, req *http.Request) {
+	var r relabelRequest
+	switch req.Method {
+	case http.MethodGet:
+		r.JobName = req.URL.Query().Get("job")
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := yaml.UnmarshalStrict(body, &r); err != nil {
+			http.Error(rw, fmt.Sprintf("invalid relabel request: %v", err), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.JobName != "" {
+		s.mtx.RLock()
+		cfgs, ok := s.relabelConfigs[r.JobName]
+		tms := s.tms
+		s.mtx.RUnlock()
+		if !ok {
+			http.Error(rw, fmt.Sprintf("unknown job %q", r.JobName), http.StatusNotFound)
+			return
+		}
+		if r.RelabelConfigs == nil {
+			r.RelabelConfigs = cfgs
+		}
+		if r.Targets == nil && tms != nil {
+			for _, t := range tms.AllTargets()[r.JobName] {
+				r.Targets = append(r.Targets, t.DiscoveredLabels())
+			}
+		}
+	} else if r.RelabelConfigs == nil || r.Targets == nil {
+		http.Error(rw, "a job_name, or relabel_configs and targets, must be provided", http.StatusBadRequest)
+		return
+	}
+
+	res := relabelResponse{Results: make([]relabelResult, 0, len(r.Targets))}
+	for _, t := range r.Targets {
+		res.Results = append(res.Results, relabelTarget(t, r.RelabelConfigs))
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(res); err != nil {
+		level.Error(s.log).Log("msg", "error writing relabel response", "error", err)
+	}
+}
+
+// relabelTarget applies the relabel configs one by one to the discovered labels,
+// recording the labels after each of them until the target is dropped.
+func relabelTarget(discovered model.LabelSet, cfgs []*relabel.Config) relabelResult {
+	res := relabelResult{
+		DiscoveredLabels: discovered,
+		Steps:            make([]relabelStep, 0, len(cfgs)),
+	}
+	lbls := labels.FromMap(util.ModelLabelSetToMap(discovered))
+	for i, cfg := range cfgs {
+		lbls = relabel.Process(lbls, cfg)
+		step := relabelStep{Rule: i, Action: cfg.Action}
+		if lbls == nil {
+			res.Steps = append(res.Steps, step)
+			res.Dropped = true
+			return res
+		}
+		step.Labels = util.MapToModelLabelSet(lbls.Map())
+		res.Steps = append(res.Steps, step)
+	}
+	res.Labels = util.MapToModelLabelSet(lbls.Map())
+	return res
+}
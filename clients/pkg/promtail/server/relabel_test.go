@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestRelabel(t *testing.T) {
+	var cfgs []*relabel.Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- source_labels: [__meta_kubernetes_pod_label_app]
+  target_label: app
+- source_labels: [app]
+  regex: skip
+  action: drop
+`), &cfgs))
+	s := &server{
+		log:            log.NewNopLogger(),
+		relabelConfigs: map[string][]*relabel.Config{"kubernetes": cfgs},
+	}
+
+	do := func(method, url, body string) (int, relabelResponse) {
+		rec := httptest.NewRecorder()
+		s.relabel(rec, httptest.NewRequest(method, url, strings.NewReader(body)))
+		var res relabelResponse
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		}
+		return rec.Code, res
+	}
+
+	code, res := do(http.MethodPost, "/relabel", `{"job_name": "kubernetes", "targets": [{"__meta_kubernetes_pod_label_app": "foo"}, {"__meta_kubernetes_pod_label_app": "skip"}]}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []relabelResult{
+		{
+			DiscoveredLabels: model.LabelSet{"__meta_kubernetes_pod_label_app": "foo"},
+			Steps: []relabelStep{
+				{Rule: 0, Action: relabel.Replace, Labels: model.LabelSet{"__meta_kubernetes_pod_label_app": "foo", "app": "foo"}},
+				{Rule: 1, Action: relabel.Drop, Labels: model.LabelSet{"__meta_kubernetes_pod_label_app": "foo", "app": "foo"}},
+			},
+			Labels: model.LabelSet{"__meta_kubernetes_pod_label_app": "foo", "app": "foo"},
+		},
+		{
+			DiscoveredLabels: model.LabelSet{"__meta_kubernetes_pod_label_app": "skip"},
+			Steps: []relabelStep{
+				{Rule: 0, Action: relabel.Replace, Labels: model.LabelSet{"__meta_kubernetes_pod_label_app": "skip", "app": "skip"}},
+				{Rule: 1, Action: relabel.Drop},
+			},
+			Dropped: true,
+		},
+	}, res.Results)
+
+	// The relabel configs of the request override the ones of the job.
+	code, res = do(http.MethodPost, "/relabel", `
+relabel_configs:
+- action: labeldrop
+  regex: __meta_.*
+targets:
+- __meta_kubernetes_pod_label_app: foo
+  job: bar
+`)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, res.Results, 1)
+	require.Equal(t, model.LabelSet{"job": "bar"}, res.Results[0].Labels)
+
+	code, _ = do(http.MethodGet, "/relabel?job=unknown", "")
+	require.Equal(t, http.StatusNotFound, code)
+	code, _ = do(http.MethodPost, "/relabel", `{"targets": [{"job": "bar"}]}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(http.MethodPost, "/relabel", `{"relabel_configs": [{"action": "unknown"}], "targets": [{"job": "bar"}]}`)
+	require.Equal(t, http.StatusBadRequest, code)
+}
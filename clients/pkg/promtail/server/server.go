@@ -10,6 +10,7 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"text/template"
 
@@ -18,8 +19,10 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/version"
+	"github.com/prometheus/prometheus/pkg/relabel"
 	serverww "github.com/weaveworks/common/server"
 
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
 	"github.com/grafana/loki/clients/pkg/promtail/server/ui"
 	"github.com/grafana/loki/clients/pkg/promtail/targets"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
@@ -33,24 +36,33 @@ var (
 type Server interface {
 	Shutdown()
 	Run() error
+	// Update replaces the targets and the config served once Promtail reloaded its config.
+	Update(tms *targets.TargetManagers, promtailCfg string, scrapeConfigs []scrapeconfig.Config)
 }
 
 // Server embed weaveworks server with static file and templating capability
 type server struct {
 	*serverww.Server
 	log               log.Logger
-	tms               *targets.TargetManagers
 	externalURL       *url.URL
 	healthCheckTarget bool
-	promtailCfg       string
+	reload            func() error
+	enableReload      bool
+
+	// mtx guards the fields replaced by Update.
+	mtx            sync.RWMutex
+	tms            *targets.TargetManagers
+	promtailCfg    string
+	relabelConfigs map[string][]*relabel.Config
 }
 
 // Config extends weaveworks server config
 type Config struct {
-	serverww.Config   `yaml:",inline"`
-	ExternalURL       string `yaml:"external_url"`
-	HealthCheckTarget *bool  `yaml:"health_check_target"`
-	Disable           bool   `yaml:"disable"`
+	serverww.Config     `yaml:",inline"`
+	ExternalURL         string `yaml:"external_url"`
+	HealthCheckTarget   *bool  `yaml:"health_check_target"`
+	Disable             bool   `yaml:"disable"`
+	EnableRuntimeReload bool   `yaml:"enable_runtime_reload"`
 }
 
 // RegisterFlags with prefix registers flags where every name is prefixed by
@@ -60,6 +72,7 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	cfg.Config.RegisterFlags(f)
 
 	f.BoolVar(&cfg.Disable, prefix+"server.disable", false, "Disable the http and grpc server.")
+	f.BoolVar(&cfg.EnableRuntimeReload, prefix+"server.enable-runtime-reload", false, "Enable reloading the config with a POST request to /reload.")
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet
@@ -67,8 +80,9 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.RegisterFlagsWithPrefix("", f)
 }
 
-// New makes a new Server
-func New(cfg Config, log log.Logger, tms *targets.TargetManagers, promtailCfg string) (Server, error) {
+// New makes a new Server. reload reloads the config of Promtail, it is called by the /reload
+// endpoint when the runtime reload is enabled.
+func New(cfg Config, log log.Logger, tms *targets.TargetManagers, promtailCfg string, scrapeConfigs []scrapeconfig.Config, reload func() error) (Server, error) {
 	if cfg.Disable {
 		return newNoopServer(log), nil
 	}
@@ -91,30 +105,57 @@ func New(cfg Config, log log.Logger, tms *targets.TargetManagers, promtailCfg st
 	serv := &server{
 		Server:            wws,
 		log:               log,
-		tms:               tms,
 		externalURL:       externalURL,
 		healthCheckTarget: healthCheckTargetFlag,
-		promtailCfg:       promtailCfg,
+		reload:            reload,
+		enableReload:      cfg.EnableRuntimeReload,
 	}
+	serv.Update(tms, promtailCfg, scrapeConfigs)
 
 	serv.HTTP.Path("/").Handler(http.RedirectHandler(path.Join(serv.externalURL.Path, "/targets"), 303))
 	serv.HTTP.Path("/ready").Handler(http.HandlerFunc(serv.ready))
 	serv.HTTP.PathPrefix("/static/").Handler(http.FileServer(ui.Assets))
 	serv.HTTP.Path("/service-discovery").Handler(http.HandlerFunc(serv.serviceDiscovery))
 	serv.HTTP.Path("/targets").Handler(http.HandlerFunc(serv.targets))
+	serv.HTTP.Path("/api/v1/targets").Handler(http.HandlerFunc(serv.targetsAPI))
 	serv.HTTP.Path("/config").Handler(http.HandlerFunc(serv.config))
+	serv.HTTP.Path("/relabel").Handler(http.HandlerFunc(serv.relabel))
+	serv.HTTP.Path("/reload").Handler(http.HandlerFunc(serv.reloadHandler))
 	serv.HTTP.Path("/debug/fgprof").Handler(fgprof.Handler())
 	return serv, nil
 }
 
+// Update replaces the targets and the config served.
+func (s *server) Update(tms *targets.TargetManagers, promtailCfg string, scrapeConfigs []scrapeconfig.Config) {
+	relabelConfigs := make(map[string][]*relabel.Config, len(scrapeConfigs))
+	for _, sc := range scrapeConfigs {
+		relabelConfigs[sc.JobName] = sc.RelabelConfigs
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.tms = tms
+	s.promtailCfg = promtailCfg
+	s.relabelConfigs = relabelConfigs
+}
+
+func (s *server) targetManagers() *targets.TargetManagers {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.tms
+}
+
 // serviceDiscovery serves the service discovery page.
 func (s *server) serviceDiscovery(rw http.ResponseWriter, req *http.Request) {
 	var index []string
-	allTarget := s.tms.AllTargets()
+	allTarget := s.targetManagers().AllTargets()
 	for job := range allTarget {
 		index = append(index, job)
 	}
 	sort.Strings(index)
+	s.mtx.RLock()
+	relabelConfigs := s.relabelConfigs
+	s.mtx.RUnlock()
 	scrapeConfigData := struct {
 		Index   []string
 		Targets map[string][]target.Target
@@ -163,6 +204,12 @@ func (s *server) serviceDiscovery(rw http.ResponseWriter, req *http.Request) {
 				}
 				return ""
 			},
+			"droppedBy": func(job string, t target.Target) string {
+				if d := newDroppedTarget(job, t, relabelConfigs[job]); d.DroppedBy != nil {
+					return fmt.Sprintf("relabel rule %d (%s)", d.DroppedBy.Rule, d.DroppedBy.Action)
+				}
+				return ""
+			},
 			"numReady": func(ts []target.Target) (readies int) {
 				for _, t := range ts {
 					if t.Ready() {
@@ -176,8 +223,11 @@ func (s *server) serviceDiscovery(rw http.ResponseWriter, req *http.Request) {
 }
 
 func (s *server) config(rw http.ResponseWriter, req *http.Request) {
+	s.mtx.RLock()
+	promtailCfg := s.promtailCfg
+	s.mtx.RUnlock()
 	executeTemplate(req.Context(), rw, templateOptions{
-		Data:         s.promtailCfg,
+		Data:         promtailCfg,
 		BuildVersion: version.Info(),
 		Name:         "config.html",
 		PageTitle:    "Config",
@@ -191,7 +241,7 @@ func (s *server) targets(rw http.ResponseWriter, req *http.Request) {
 		Data: struct {
 			TargetPools map[string][]target.Target
 		}{
-			TargetPools: s.tms.ActiveTargets(),
+			TargetPools: s.targetManagers().ActiveTargets(),
 		},
 		BuildVersion: version.Info(),
 		Name:         "targets.html",
@@ -220,7 +270,7 @@ func (s *server) targets(rw http.ResponseWriter, req *http.Request) {
 
 // ready serves the ready endpoint
 func (s *server) ready(rw http.ResponseWriter, _ *http.Request) {
-	if s.healthCheckTarget && !s.tms.Ready() {
+	if s.healthCheckTarget && !s.targetManagers().Ready() {
 		http.Error(rw, readinessProbeFailure, http.StatusInternalServerError)
 		return
 	}
@@ -231,6 +281,23 @@ func (s *server) ready(rw http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// reloadHandler reloads the config of Promtail.
+func (s *server) reloadHandler(rw http.ResponseWriter, req *http.Request) {
+	if !s.enableReload {
+		http.Error(rw, "runtime reload is disabled, enable it with -server.enable-runtime-reload", http.StatusForbidden)
+		return
+	}
+	if req.Method != http.MethodPost && req.Method != http.MethodPut {
+		http.Error(rw, "only POST and PUT requests are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.reload(); err != nil {
+		http.Error(rw, fmt.Sprintf("failed to reload config: %v", err), http.StatusInternalServerError)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
 // computeExternalURL computes a sanitized external URL from a raw input. It infers unset
 // URL parts from the OS and the given listen address.
 func computeExternalURL(u string, port int) (*url.URL, error) {
@@ -279,3 +346,5 @@ func (s *noopServer) Run() error {
 func (s *noopServer) Shutdown() {
 	s.sigs <- syscall.SIGTERM
 }
+
+func (s *noopServer) Update(*targets.TargetManagers, string, []scrapeconfig.Config) {}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+)
+
+// targetsResponse is the response of the targets API, shaped like the one of Prometheus.
+type targetsResponse struct {
+	Status string      `json:"status"`
+	Data   targetsData `json:"data"`
+}
+
+type targetsData struct {
+	ActiveTargets  []activeTarget  `json:"activeTargets"`
+	DroppedTargets []droppedTarget `json:"droppedTargets"`
+}
+
+type activeTarget struct {
+	Job              string            `json:"job"`
+	Type             target.TargetType `json:"type"`
+	DiscoveredLabels model.LabelSet    `json:"discoveredLabels"`
+	Labels           model.LabelSet    `json:"labels"`
+	// Health is up when the target is ready.
+	Health    string           `json:"health"`
+	LastError string           `json:"lastError"`
+	Details   interface{}      `json:"details"`
+	Lag       map[string]int64 `json:"lag,omitempty"`
+}
+
+type droppedTarget struct {
+	Job              string         `json:"job"`
+	DiscoveredLabels model.LabelSet `json:"discoveredLabels"`
+	Reason           string         `json:"reason"`
+	// DroppedBy is the relabel rule of the job dropping the target, if it is dropped by relabeling.
+	DroppedBy *relabelStep `json:"droppedBy,omitempty"`
+}
+
+// targetsAPI serves the active and dropped targets of all the jobs. The state query parameter
+// selects the active or the dropped targets only, and job the targets of a job only.
+func (s *server) targetsAPI(rw http.ResponseWriter, req *http.Request) {
+	state := req.URL.Query().Get("state")
+	switch state {
+	case "", "any", "active", "dropped":
+	default:
+		http.Error(rw, fmt.Sprintf("invalid state %q, must be one of any, active or dropped", state), http.StatusBadRequest)
+		return
+	}
+	job := req.URL.Query().Get("job")
+
+	s.mtx.RLock()
+	tms := s.tms
+	relabelConfigs := s.relabelConfigs
+	s.mtx.RUnlock()
+
+	var allTargets map[string][]target.Target
+	if tms != nil {
+		allTargets = tms.AllTargets()
+	}
+	data := collectTargets(allTargets, relabelConfigs, job, state)
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(targetsResponse{Status: "success", Data: data}); err != nil {
+		level.Error(s.log).Log("msg", "error writing targets response", "error", err)
+	}
+}
+
+// collectTargets returns the targets of the job, or all of them, in the given state.
+func collectTargets(allTargets map[string][]target.Target, relabelConfigs map[string][]*relabel.Config, job, state string) targetsData {
+	data := targetsData{
+		ActiveTargets:  []activeTarget{},
+		DroppedTargets: []droppedTarget{},
+	}
+	for _, jobName := range sortedJobs(allTargets) {
+		if job != "" && jobName != job {
+			continue
+		}
+		for _, t := range allTargets[jobName] {
+			if target.IsDropped(t) {
+				if state != "active" {
+					data.DroppedTargets = append(data.DroppedTargets, newDroppedTarget(jobName, t, relabelConfigs[jobName]))
+				}
+				continue
+			}
+			if state != "dropped" {
+				data.ActiveTargets = append(data.ActiveTargets, newActiveTarget(jobName, t))
+			}
+		}
+	}
+	return data
+}
+
+func sortedJobs(allTargets map[string][]target.Target) []string {
+	jobs := make([]string, 0, len(allTargets))
+	for job := range allTargets {
+		jobs = append(jobs, job)
+	}
+	sort.Strings(jobs)
+	return jobs
+}
+
+func newActiveTarget(job string, t target.Target) activeTarget {
+	res := activeTarget{
+		Job:              job,
+		Type:             t.Type(),
+		DiscoveredLabels: t.DiscoveredLabels(),
+		Labels:           t.Labels(),
+		Health:           "down",
+		Details:          t.Details(),
+	}
+	if t.Ready() {
+		res.Health = "up"
+	}
+	// the targets keeping track of their errors report the last one in their details.
+	if details, ok := res.Details.(map[string]string); ok {
+		res.LastError = details["error"]
+	}
+	if lt, ok := t.(target.LagTarget); ok {
+		res.Lag = lt.Lag()
+	}
+	return res
+}
+
+func newDroppedTarget(job string, t target.Target, cfgs []*relabel.Config) droppedTarget {
+	res := droppedTarget{
+		Job:              job,
+		DiscoveredLabels: t.DiscoveredLabels(),
+	}
+	res.Reason, _ = t.Details().(string)
+	if relabeled := relabelTarget(t.DiscoveredLabels(), cfgs); relabeled.Dropped {
+		res.DroppedBy = &relabeled.Steps[len(relabeled.Steps)-1]
+	}
+	return res
+}
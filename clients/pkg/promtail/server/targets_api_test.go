@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/clients/pkg/promtail/server/ui"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+)
+
+type fakeTarget struct {
+	labels  model.LabelSet
+	ready   bool
+	details map[string]string
+}
+
+func (t fakeTarget) Type() target.TargetType          { return target.DockerTargetType }
+func (t fakeTarget) DiscoveredLabels() model.LabelSet { return model.LabelSet{"__meta_id": "1"} }
+func (t fakeTarget) Labels() model.LabelSet           { return t.labels }
+func (t fakeTarget) Ready() bool                      { return t.ready }
+func (t fakeTarget) Details() interface{}             { return t.details }
+func (t fakeTarget) Lag() map[string]int64            { return map[string]int64{"/var/log/foo.log": 42} }
+
+func TestCollectTargets(t *testing.T) {
+	var cfgs []*relabel.Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- source_labels: [__meta_app]
+  regex: skip
+  action: drop
+`), &cfgs))
+	allTargets := map[string][]target.Target{
+		"docker": {
+			fakeTarget{labels: model.LabelSet{"app": "foo"}, ready: true, details: map[string]string{"position": "1"}},
+			fakeTarget{labels: model.LabelSet{"app": "bar"}, details: map[string]string{"error": "connection refused"}},
+			target.NewDroppedTarget("dropping target, no labels", model.LabelSet{"__meta_app": "skip"}),
+		},
+		"varlogs": {
+			target.NewDroppedTarget("ignoring target, already exists", model.LabelSet{"__meta_app": "foo"}),
+		},
+	}
+	relabelConfigs := map[string][]*relabel.Config{"docker": cfgs, "varlogs": cfgs}
+
+	data := collectTargets(allTargets, relabelConfigs, "", "")
+	require.Len(t, data.ActiveTargets, 2)
+	require.Equal(t, activeTarget{
+		Job:              "docker",
+		Type:             target.DockerTargetType,
+		DiscoveredLabels: model.LabelSet{"__meta_id": "1"},
+		Labels:           model.LabelSet{"app": "foo"},
+		Health:           "up",
+		Details:          map[string]string{"position": "1"},
+		Lag:              map[string]int64{"/var/log/foo.log": 42},
+	}, data.ActiveTargets[0])
+	require.Equal(t, "down", data.ActiveTargets[1].Health)
+	require.Equal(t, "connection refused", data.ActiveTargets[1].LastError)
+	require.Equal(t, []droppedTarget{
+		{
+			Job:              "docker",
+			DiscoveredLabels: model.LabelSet{"__meta_app": "skip"},
+			Reason:           "dropping target, no labels",
+			DroppedBy:        &relabelStep{Rule: 0, Action: relabel.Drop},
+		},
+		{
+			Job:              "varlogs",
+			DiscoveredLabels: model.LabelSet{"__meta_app": "foo"},
+			Reason:           "ignoring target, already exists",
+		},
+	}, data.DroppedTargets)
+
+	data = collectTargets(allTargets, relabelConfigs, "varlogs", "")
+	require.Empty(t, data.ActiveTargets)
+	require.Len(t, data.DroppedTargets, 1)
+	data = collectTargets(allTargets, relabelConfigs, "", "active")
+	require.Len(t, data.ActiveTargets, 2)
+	require.Empty(t, data.DroppedTargets)
+}
+
+func TestTargetsAPI(t *testing.T) {
+	s := &server{log: log.NewNopLogger()}
+
+	rec := httptest.NewRecorder()
+	s.targetsAPI(rec, httptest.NewRequest(http.MethodGet, "/api/v1/targets", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var res targetsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Equal(t, "success", res.Status)
+
+	rec = httptest.NewRecorder()
+	s.targetsAPI(rec, httptest.NewRequest(http.MethodGet, "/api/v1/targets?state=unknown", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServiceDiscoveryTemplate(t *testing.T) {
+	f, err := ui.Assets.Open("/templates/service-discovery.html")
+	require.NoError(t, err)
+	defer f.Close()
+	buf, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	require.Contains(t, string(buf), "droppedBy $job")
+}
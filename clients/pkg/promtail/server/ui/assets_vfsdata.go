@@ -479,9 +479,9 @@ var Assets = func() http.FileSystem {
 		"/templates/service-discovery.html": &vfsgen۰CompressedFileInfo{
 			name:             "service-discovery.html",
 			modTime:          time.Date(1970, 1, 1, 0, 0, 1, 0, time.UTC),
-			uncompressedSize: 2774,
+			uncompressedSize: 2822,
 
-			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xec\x56\x41\xab\xe3\x36\x10\xbe\xfb\x57\x0c\x6a\x0e\x6d\x79\x89\xf7\x2d\xb4\x87\x54\x71\x29\xbc\x4b\xa1\x87\xd2\x2e\xbd\x94\x52\x64\x6b\x12\xcf\x5b\x45\x32\x92\x92\x4d\x30\xfe\xef\x45\x92\xed\x28\x69\xb2\x85\xb7\xd7\x3e\x78\x41\x1a\x49\x33\xdf\x7c\xfa\x66\xe4\xbe\x97\xb8\x25\x8d\xc0\x5a\x14\x92\x0d\x43\xc1\x15\xe9\x8f\xe0\xcf\x1d\x6e\x98\xc7\x93\x2f\x1b\xe7\x18\x58\x54\x1b\xe6\xfc\x59\xa1\x6b\x11\x3d\x83\xd6\xe2\x76\xc3\xfa\x1e\x3a\xe1\xdb\x5f\x2d\x6e\xe9\x04\xc3\x50\x3a\x2f\x3c\x35\xe1\x4c\xe9\x85\xdd\xa1\x77\xab\xc6\xb9\x1f\x8f\x9b\xbe\x87\xfa\x40\x4a\xfe\x81\xd6\x91\xd1\x30\x0c\xac\x2a\xb8\x6b\x2c\x75\x1e\x9c\x6d\x1e\xfb\x7a\xbd\xb8\x7a\x7d\xe4\x89\x97\xc9\x53\x55\xf4\x3d\x6a\x39\x0c\x45\xc1\x23\xda\xaa\x00\xf8\xf6\x4f\x92\x7f\xad\x6b\xdc\x1a\x8b\xd0\x17\x00\x00\x92\x5c\xa7\xc4\x79\x0d\xb5\x32\xcd\xc7\x1f\xa2\xad\x31\xda\xa3\xf6\x6b\x60\xc0\x92\x65\x2f\xec\x8e\xf4\xd2\x9b\x6e\x0d\xcb\xef\xbf\xeb\x4e\xc9\xdc\x22\xed\x5a\xbf\x86\x8b\xe5\x48\x8e\x6a\x52\xe4\xcf\x6b\x68\x49\x4a\xd4\xc1\x3e\x14\xbc\x1c\x41\x14\x17\x9e\xc7\x30\x81\x6a\x00\x2e\xe9\x08\x8d\x12\xce\x6d\xe2\x82\x20\x8d\x76\xb9\x55\x07\x92\xac\x2a\xa2\x6f\xde\x3e\x57\xbf\xa3\x3d\x52\x83\xf0\x42\xae\x31\x47\xb4\x67\x5e\xb6\xcf\x55\x5a\x96\x74\x4c\x23\x00\x7e\x50\xd3\x10\xa0\xef\xad\xd0\x3b\x84\x05\x3d\xc1\xe2\xd5\xd4\xb0\xde\xc0\xea\x67\x2d\xf1\x14\x23\x4f\x7f\x5c\x51\x95\x4d\x01\xb8\x18\xef\xf6\xab\x57\x53\x2f\xfb\x3e\x9c\x0d\x14\x4f\x23\x5e\x8a\x0a\xbe\xee\x7b\xa0\xe0\x0b\x16\xab\x9f\x1a\x4f\xc7\x10\x27\xdc\x59\x66\xff\x60\xbc\x50\xc9\x0c\x22\xed\x19\xef\xf1\x9b\x3c\x7e\x99\x03\x98\x6e\x6f\x5a\x9b\x12\xe2\x65\x4c\xb3\x48\x7b\x16\xa3\x9f\x98\xd2\x87\x34\x1e\x0f\x7d\x36\xeb\x99\xb0\x89\x72\x2f\x6a\x85\xcb\x99\x78\x36\x13\xd9\xbe\x9f\xb6\xbc\x9a\xfa\xef\x50\x1a\x68\x19\x90\x8c\xf3\x8c\x94\x0c\x78\x32\xcd\x06\x5e\x1f\xbc\x37\x7a\x2c\xa4\x34\x61\x97\xb8\x09\x7f\x63\x94\x12\x9d\x43\xb9\x8c\x48\xa0\xf6\x3a\xfc\x2f\x3b\x4b\x7b\x61\xcf\xac\x72\xad\xf9\x04\x7b\x63\x91\x97\xc9\xc5\x8c\xb0\x6c\xdf\x4f\xe3\xbe\xff\x44\xbe\x9d\x79\x7f\xb1\xa6\xeb\x50\xc2\x82\x66\x38\x7d\x4f\x5b\xd8\x79\x58\xc1\xf3\xbb\x77\x70\xe1\xf7\x4a\x7d\x13\x14\x54\xb8\x0f\x02\x85\xa8\xdd\x0d\x9b\x6a\x45\x1b\x8d\x57\x19\xc3\x2a\x5c\xed\x94\x4b\x2b\x8e\x08\x35\xa2\x06\x99\x00\x3c\x41\x40\x4f\x7a\x07\x46\xab\x33\xf8\x16\x61\x4b\xd6\xf9\x88\x61\xdc\x33\x9f\x16\x0e\xf0\x24\xf6\x9d\x42\xb7\x9a\x53\xcc\xa4\x7d\x2d\x8c\x1b\x99\x24\xf2\xf2\x3b\x85\x74\xb3\x6e\x3f\x0e\x6a\x63\x25\xda\x18\x2f\xda\xbd\xa5\x6e\x9e\xb5\xa1\xa4\xfe\x2b\x5d\xee\x83\x0a\x72\x55\x48\xf4\x82\x94\x63\x79\xf9\x70\x6f\x6f\xaa\xc9\xb7\xd5\x54\xb5\x28\xe1\x17\x51\xa3\x72\xbc\xf4\xed\xbf\xb7\x25\x21\x3f\xd8\xc2\xcb\xdc\x73\x58\x45\x21\x73\x74\xb5\x91\xe7\xb1\x40\xf2\x3a\x18\x45\x31\xd1\x7c\x23\xd2\xbb\x80\xe7\x24\x55\x44\xc2\xae\xd7\x47\xb1\xa7\xb5\x58\x5d\x97\xf4\x12\x74\xb8\x0a\x30\x77\xa6\xd9\x2b\x39\xbf\x24\xad\x48\xe3\xcc\x79\xb4\xc5\xf1\x32\xd4\xcb\x2d\xf7\x79\xe4\xb1\xbc\x63\xfc\x27\x58\x1c\x85\x3a\x60\x80\x31\x22\xba\x13\xfb\x6e\x97\xcb\x96\x5c\x27\xf4\x04\xae\x16\x72\x87\x10\x7f\x2f\x45\x38\xa5\x3b\x0c\xe1\x91\x4a\x21\x87\x81\xf1\x32\x9c\xbc\xef\xf6\xba\xab\xe5\xf8\x51\x39\xbc\x0b\xf2\x21\xc4\x87\x00\x25\x6e\xc5\x41\x79\x56\x05\xba\x1e\xa3\xf9\x0c\x96\xac\x88\xf2\xfd\xf9\x33\x32\xca\x4d\x7e\xa9\x4a\xfe\xd7\xc6\x17\x68\xe3\x6d\x20\x67\x7d\x8c\x2f\xc2\x3a\xf4\xec\xd0\x78\x7f\x43\xe1\x8c\x86\xd5\x4b\xea\x60\x10\x1e\xf5\x37\xe1\x7d\xa3\x7e\xae\x9b\xd9\xad\x1f\x5e\x8e\xcd\x6c\x9e\x86\x2e\x7d\xf5\x1d\x30\xbe\x3f\xa8\x65\x12\xd4\xf4\x79\x30\xb9\xfa\x27\x00\x00\xff\xff\x92\xdb\x08\x66\xd6\x0a\x00\x00"),
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xec\x56\xc1\x8e\xe3\x36\x0f\xbe\xfb\x29\x08\xfd\x39\xfc\x2d\x26\xf1\xce\x02\xed\x21\x55\x5c\xb4\x98\x4b\x81\x1e\x8a\x76\xd1\x4b\x51\x14\xb2\xc5\xc4\x9c\x55\x24\x43\x52\xb2\x31\x04\xbf\x7b\x21\xcb\x76\xec\x34\xd9\x02\xb3\xd7\x0e\x30\x81\x44\x51\xe4\x47\xf2\x23\xe5\x10\x24\xee\x49\x23\xb0\x1a\x85\x64\x5d\x97\x71\x45\xfa\x23\xf8\xb6\xc1\x1d\xf3\x78\xf1\x79\xe5\x1c\x03\x8b\x6a\xc7\x9c\x6f\x15\xba\x1a\xd1\x33\xa8\x2d\xee\x77\x2c\x04\x68\x84\xaf\x7f\xb1\xb8\xa7\x0b\x74\x5d\xee\xbc\xf0\x54\xc5\x3b\xb9\x17\xf6\x80\xde\x6d\x2a\xe7\xbe\x3f\xef\x42\x80\xf2\x44\x4a\xfe\x8e\xd6\x91\xd1\xd0\x75\xac\xc8\xb8\xab\x2c\x35\x1e\x9c\xad\x1e\xdb\x7a\xbd\x9a\x7a\x7d\x64\x89\xe7\xc9\x52\x91\x85\x80\x5a\x76\x5d\x96\xf1\x1e\x6d\x91\x01\x7c\xfd\x07\xc9\x3f\xb7\x25\xee\x8d\x45\x08\x19\x00\x80\x24\xd7\x28\xd1\x6e\xa1\x54\xa6\xfa\xf8\x5d\x2f\xab\x8c\xf6\xa8\xfd\x16\x18\xb0\x24\x39\x0a\x7b\x20\xbd\xf6\xa6\xd9\xc2\xfa\xdb\x6f\x9a\x4b\x12\xd7\x48\x87\xda\x6f\xe1\x2a\x39\x93\xa3\x92\x14\xf9\x76\x0b\x35\x49\x89\x3a\xca\xbb\x8c\xe7\x03\x88\xec\x9a\xe7\xc1\x4d\x4c\x35\x00\x97\x74\x86\x4a\x09\xe7\x76\xfd\x81\x20\x8d\x76\xbd\x57\x27\x92\xac\xc8\x7a\xdb\xbc\x7e\x2e\x7e\x43\x7b\xa6\x0a\xe1\x85\x5c\x65\xce\x68\x5b\x9e\xd7\xcf\x45\x3a\x96\x74\x4e\x2b\x00\x7e\x52\xe3\x12\x20\x04\x2b\xf4\x01\x61\x45\x4f\xb0\x7a\x35\x25\x6c\x77\xb0\xf9\x49\x4b\xbc\xf4\x9e\xc7\x3f\xae\xa8\x98\x6d\x01\xb8\x18\x6a\xfb\xbf\x57\x53\xae\x43\x88\x77\x63\x8a\xc7\x15\xcf\x45\x01\xff\x0f\x01\x28\xda\x82\xd5\xe6\x87\xca\xd3\x39\xfa\x89\x35\x9b\xc9\x3f\x18\x2f\x54\x12\x83\x48\x3a\x43\x1d\xbf\x9a\xfb\xcf\xe7\x00\xc6\xea\x8d\x67\x63\x40\x3c\xef\xc3\xcc\x92\xce\x6a\xb0\xd3\x87\xf4\x21\xad\x87\x4b\x9f\x8d\x7a\x4a\xd8\x98\x72\x2f\x4a\x85\xeb\x29\xf1\x6c\x4a\x64\xfd\x7e\x54\x79\x35\xe5\x5f\xb1\x35\xd0\x32\x20\xb9\x63\xcb\xa4\xcc\x80\x27\xd1\x24\xe0\xe5\xc9\x7b\xa3\x87\x46\x4a\x1b\x76\xf5\x9b\xf0\x57\x46\x29\xd1\x38\x94\xeb\x1e\x09\x94\x5e\xc7\xff\x75\x63\xe9\x28\x6c\xcb\x0a\x57\x9b\x4f\x70\x34\x16\x79\x9e\x4c\x4c\x08\xf3\xfa\xfd\xb8\x0e\xe1\x13\xf9\x7a\xca\xfb\x8b\x35\x4d\x83\x12\x56\x34\xc1\x09\x81\xf6\x70\xf0\xb0\x81\xe7\x77\xef\xe0\x9a\xdf\x05\xfb\x46\x28\xa8\xf0\x18\x09\x0a\x3d\x77\x77\x6c\xec\x15\x6d\x34\x2e\x22\x86\x0d\x74\xdd\x58\x53\xa8\xc5\x19\xa1\x44\xd4\x20\x13\x80\x27\x88\xe8\x49\x1f\xc0\x68\xd5\x82\xaf\x11\xf6\x64\x9d\xef\x31\x0c\x3a\xd3\x6d\xe1\x00\x2f\xe2\xd8\x28\x74\x9b\x29\xc4\x19\xb5\x97\xc4\x58\xee\x78\x4a\xde\xbc\xa6\x90\x2a\xeb\x8e\xc3\xa2\x34\x56\xa2\x45\x39\x6c\x9d\xb7\xd4\x4c\xbb\x3a\xb6\xd4\xbf\x85\xcb\x7d\x64\xc1\x9c\x15\x12\xbd\x20\xe5\x66\x3a\x51\xcb\xde\x74\x93\xaf\x8b\xb1\x6b\x51\xc2\xcf\xa2\x44\xe5\x78\xee\xeb\x7f\xaa\x25\x22\x3f\x50\xe1\xf9\xdc\x72\x3c\x45\x21\xe7\xe8\x4a\x23\xdb\x22\x9b\x04\x63\x1f\x0c\xa4\x18\xd3\x7c\x43\xd2\xbb\x80\xa7\x20\x55\x8f\x84\x2d\xcf\x07\xb2\xa7\xb3\xbe\xbb\xae\xe1\x25\xe8\xb0\x70\x30\x4d\xa6\xc9\x2a\x39\xbf\x26\xad\x48\xe3\x94\xf3\x5e\xd6\xaf\xd7\xb1\x5f\x6e\x73\x0f\x70\x1b\x56\xf2\xff\x04\xab\xb3\x50\x27\x8c\x30\x06\x44\x77\x7c\xdf\x9d\x72\xb3\x23\xd7\x08\x3d\x82\x2b\x85\x3c\x20\xf4\xbf\xd7\x26\x1c\xc3\xed\xba\xf8\x48\x25\x97\x5d\xc7\x78\x1e\x6f\xde\x37\xbb\x9c\x6a\x73\xfc\xa8\x1c\xde\x05\xf9\x10\xe2\x43\x80\x12\xf7\xe2\xa4\x3c\x2b\x62\xba\x1e\xa3\xf9\x0c\x96\x59\x13\xcd\xf5\xe7\xcf\x48\x92\x78\xf9\xa5\x2c\xf9\x8f\x1b\x5f\xc0\x8d\xb7\x81\x9c\xf8\x31\xbc\x08\x5b\x08\xa1\x1f\xbc\xbf\xa2\x70\x46\xc3\xe6\x25\x4d\x30\xe8\xba\x10\xa0\x7f\x43\x86\xb1\xfc\x63\x9b\x1e\xcf\x38\xe0\x9f\xa0\x6c\xc7\x61\x1f\x02\xa0\x96\x10\x3f\x02\xde\x14\xdf\x1b\xf9\xb6\x1c\x7e\xb7\x76\x78\x3e\x0c\xbf\x69\x1b\xa7\xfa\xe2\xbb\x21\x5d\x1b\xb0\x67\x93\x78\xfa\x58\xfc\x7b\x00\xca\xe1\x7f\xad\x06\x0b\x00\x00"),
 		},
 		"/templates/targets.html": &vfsgen۰CompressedFileInfo{
 			name:             "targets.html",
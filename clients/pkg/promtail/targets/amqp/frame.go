@@ -0,0 +1,445 @@
+package amqp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// The target speaks the subset of AMQP 0.9.1 a consumer needs instead of using a client
+// library: deliveries are acknowledged only once their entries are pushed, the prefetch
+// bounds the unacknowledged deliveries, and no client is added to the vendored
+// dependencies for the few methods used.
+
+// protocolHeader starts a connection speaking AMQP 0.9.1.
+var protocolHeader = []byte("AMQP\x00\x00\x09\x01")
+
+// Frame types.
+const (
+	frameMethod    = 1
+	frameHeader    = 2
+	frameBody      = 3
+	frameHeartbeat = 8
+
+	frameEnd = 0xCE
+)
+
+// maxFrameSize is the largest frame accepted from the broker, it is negotiated when
+// connecting. Messages larger than a frame are split into several body frames.
+const maxFrameSize = 128 << 10
+
+// maxBodySize bounds the size of the messages read from the broker.
+const maxBodySize = 256 << 20
+
+var errMalformedFrame = errors.New("malformed frame")
+
+// methodID identifies a method by its class and method identifiers.
+type methodID struct {
+	class, method uint16
+}
+
+func (m methodID) String() string {
+	if name, ok := methodNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("method %d.%d", m.class, m.method)
+}
+
+// Methods used by the target.
+var (
+	connectionStart   = methodID{10, 10}
+	connectionStartOk = methodID{10, 11}
+	connectionTune    = methodID{10, 30}
+	connectionTuneOk  = methodID{10, 31}
+	connectionOpen    = methodID{10, 40}
+	connectionOpenOk  = methodID{10, 41}
+	connectionClose   = methodID{10, 50}
+	connectionCloseOk = methodID{10, 51}
+	channelOpen       = methodID{20, 10}
+	channelOpenOk     = methodID{20, 11}
+	channelClose      = methodID{20, 40}
+	channelCloseOk    = methodID{20, 41}
+	queueDeclare      = methodID{50, 10}
+	queueDeclareOk    = methodID{50, 11}
+	queueBind         = methodID{50, 20}
+	queueBindOk       = methodID{50, 21}
+	basicQos          = methodID{60, 10}
+	basicQosOk        = methodID{60, 11}
+	basicConsume      = methodID{60, 20}
+	basicConsumeOk    = methodID{60, 21}
+	basicCancel       = methodID{60, 30}
+	basicDeliver      = methodID{60, 60}
+	basicAck          = methodID{60, 80}
+	basicNack         = methodID{60, 120}
+)
+
+var methodNames = map[methodID]string{
+	connectionStart: "connection.start", connectionStartOk: "connection.start-ok",
+	connectionTune: "connection.tune", connectionTuneOk: "connection.tune-ok",
+	connectionOpen: "connection.open", connectionOpenOk: "connection.open-ok",
+	connectionClose: "connection.close", connectionCloseOk: "connection.close-ok",
+	channelOpen: "channel.open", channelOpenOk: "channel.open-ok",
+	channelClose: "channel.close", channelCloseOk: "channel.close-ok",
+	queueDeclare: "queue.declare", queueDeclareOk: "queue.declare-ok",
+	queueBind: "queue.bind", queueBindOk: "queue.bind-ok",
+	basicQos: "basic.qos", basicQosOk: "basic.qos-ok",
+	basicConsume: "basic.consume", basicConsumeOk: "basic.consume-ok",
+	basicCancel: "basic.cancel", basicDeliver: "basic.deliver",
+	basicAck: "basic.ack", basicNack: "basic.nack",
+}
+
+// frame is a frame read from the broker.
+type frame struct {
+	typ     byte
+	channel uint16
+	payload []byte
+}
+
+func readFrame(r *bufio.Reader) (frame, error) {
+	var header [7]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return frame{}, err
+	}
+	if string(header[:4]) == string(protocolHeader[:4]) {
+		// The broker answers the protocol header with the protocol it supports.
+		return frame{}, errors.New("the broker doesn't support AMQP 0.9.1")
+	}
+	size := binary.BigEndian.Uint32(header[3:])
+	if size > maxFrameSize {
+		return frame{}, fmt.Errorf("frame of %d bytes exceeds the maximum size", size)
+	}
+	payload := make([]byte, size+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return frame{}, err
+	}
+	if payload[size] != frameEnd {
+		return frame{}, errMalformedFrame
+	}
+	return frame{
+		typ:     header[0],
+		channel: binary.BigEndian.Uint16(header[1:]),
+		payload: payload[:size],
+	}, nil
+}
+
+// encodeFrame returns a frame with its header and end marker.
+func encodeFrame(typ byte, channel uint16, payload []byte) []byte {
+	var e encoder
+	e.octet(typ)
+	e.short(channel)
+	e.long(uint32(len(payload)))
+	e.buf = append(e.buf, payload...)
+	e.octet(frameEnd)
+	return e.buf
+}
+
+// encoder builds the payload of a frame.
+type encoder struct {
+	buf []byte
+}
+
+func newMethod(id methodID) *encoder {
+	e := &encoder{}
+	e.short(id.class)
+	e.short(id.method)
+	return e
+}
+
+func (e *encoder) octet(b byte) { e.buf = append(e.buf, b) }
+
+func (e *encoder) short(v uint16) { e.buf = append(e.buf, byte(v>>8), byte(v)) }
+
+func (e *encoder) long(v uint32) {
+	e.buf = append(e.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) longlong(v uint64) {
+	e.long(uint32(v >> 32))
+	e.long(uint32(v))
+}
+
+func (e *encoder) shortstr(s string) {
+	e.octet(byte(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) longstr(s string) {
+	e.long(uint32(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// table encodes a field table. Only the value types sent by the target are supported.
+func (e *encoder) table(t map[string]interface{}) {
+	var fields encoder
+	for name, value := range t {
+		fields.shortstr(name)
+		switch v := value.(type) {
+		case bool:
+			fields.octet('t')
+			if v {
+				fields.octet(1)
+			} else {
+				fields.octet(0)
+			}
+		case string:
+			fields.octet('S')
+			fields.longstr(v)
+		case map[string]interface{}:
+			fields.octet('F')
+			fields.table(v)
+		default:
+			panic(fmt.Sprintf("unsupported field table value %T", value))
+		}
+	}
+	e.long(uint32(len(fields.buf)))
+	e.buf = append(e.buf, fields.buf...)
+}
+
+// decoder reads the payload of a frame.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = errMalformedFrame
+		return nil
+	}
+	out := d.buf[:n]
+	d.buf = d.buf[n:]
+	return out
+}
+
+func (d *decoder) octet() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) short() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) long() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) longlong() uint64 {
+	if b := d.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) shortstr() string {
+	return string(d.next(int(d.octet())))
+}
+
+func (d *decoder) longstr() string {
+	return string(d.next(int(d.long())))
+}
+
+func (d *decoder) method() methodID {
+	return methodID{class: d.short(), method: d.short()}
+}
+
+func (d *decoder) table() map[string]interface{} {
+	sub := decoder{buf: d.next(int(d.long())), err: d.err}
+	t := map[string]interface{}{}
+	for sub.err == nil && len(sub.buf) > 0 {
+		name := sub.shortstr()
+		t[name] = sub.value()
+	}
+	if d.err == nil {
+		d.err = sub.err
+	}
+	return t
+}
+
+// value decodes a field value, with the types of RabbitMQ.
+func (d *decoder) value() interface{} {
+	switch typ := d.octet(); typ {
+	case 't':
+		return d.octet() != 0
+	case 'b':
+		return int8(d.octet())
+	case 'B':
+		return d.octet()
+	case 's':
+		return int16(d.short())
+	case 'u':
+		return d.short()
+	case 'I':
+		return int32(d.long())
+	case 'i':
+		return d.long()
+	case 'l':
+		return int64(d.longlong())
+	case 'f':
+		return math.Float32frombits(d.long())
+	case 'd':
+		return math.Float64frombits(d.longlong())
+	case 'D':
+		scale := d.octet()
+		return float64(int32(d.long())) / math.Pow10(int(scale))
+	case 'S':
+		return d.longstr()
+	case 'x':
+		return d.next(int(d.long()))
+	case 'T':
+		return time.Unix(int64(d.longlong()), 0).UTC()
+	case 'A':
+		sub := decoder{buf: d.next(int(d.long())), err: d.err}
+		var values []interface{}
+		for sub.err == nil && len(sub.buf) > 0 {
+			values = append(values, sub.value())
+		}
+		if d.err == nil {
+			d.err = sub.err
+		}
+		return values
+	case 'F':
+		return d.table()
+	case 'V':
+		return nil
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("unknown field type %q: %w", typ, errMalformedFrame)
+		}
+		return nil
+	}
+}
+
+// closeReason is the reason of a connection.close or channel.close method.
+type closeReason struct {
+	code   uint16
+	text   string
+	method methodID
+}
+
+func decodeClose(d *decoder) closeReason {
+	return closeReason{code: d.short(), text: d.shortstr(), method: d.method()}
+}
+
+func (r closeReason) Error() string {
+	if r.method.class == 0 {
+		return fmt.Sprintf("%s (%d)", r.text, r.code)
+	}
+	return fmt.Sprintf("%s (%d) in reply to %s", r.text, r.code, r.method)
+}
+
+// properties are the properties of the basic class, sent in the content header of a message.
+type properties struct {
+	contentType     string
+	contentEncoding string
+	headers         map[string]interface{}
+	deliveryMode    byte
+	priority        byte
+	correlationID   string
+	replyTo         string
+	expiration      string
+	messageID       string
+	timestamp       time.Time
+	typ             string
+	userID          string
+	appID           string
+}
+
+// contentHeader is the header frame following a basic.deliver method.
+type contentHeader struct {
+	bodySize   uint64
+	properties properties
+}
+
+func decodeContentHeader(payload []byte) (contentHeader, error) {
+	d := decoder{buf: payload}
+	d.short() // class
+	d.short() // weight
+	h := contentHeader{bodySize: d.longlong()}
+	flags := d.short()
+	p := &h.properties
+	if flags&(1<<15) != 0 {
+		p.contentType = d.shortstr()
+	}
+	if flags&(1<<14) != 0 {
+		p.contentEncoding = d.shortstr()
+	}
+	if flags&(1<<13) != 0 {
+		p.headers = d.table()
+	}
+	if flags&(1<<12) != 0 {
+		p.deliveryMode = d.octet()
+	}
+	if flags&(1<<11) != 0 {
+		p.priority = d.octet()
+	}
+	if flags&(1<<10) != 0 {
+		p.correlationID = d.shortstr()
+	}
+	if flags&(1<<9) != 0 {
+		p.replyTo = d.shortstr()
+	}
+	if flags&(1<<8) != 0 {
+		p.expiration = d.shortstr()
+	}
+	if flags&(1<<7) != 0 {
+		p.messageID = d.shortstr()
+	}
+	if flags&(1<<6) != 0 {
+		p.timestamp = time.Unix(int64(d.longlong()), 0)
+	}
+	if flags&(1<<5) != 0 {
+		p.typ = d.shortstr()
+	}
+	if flags&(1<<4) != 0 {
+		p.userID = d.shortstr()
+	}
+	if flags&(1<<3) != 0 {
+		p.appID = d.shortstr()
+	}
+	if flags&(1<<2) != 0 {
+		d.shortstr() // cluster-id, deprecated
+	}
+	if d.err != nil {
+		return contentHeader{}, d.err
+	}
+	if h.bodySize > maxBodySize {
+		return contentHeader{}, fmt.Errorf("message of %d bytes exceeds the maximum size", h.bodySize)
+	}
+	return h, nil
+}
+
+// delivery is a message delivered by a basic.deliver method.
+type delivery struct {
+	deliveryTag uint64
+	redelivered bool
+	exchange    string
+	routingKey  string
+	properties  properties
+	body        []byte
+}
+
+func decodeDeliver(d *decoder) delivery {
+	d.shortstr() // consumer-tag
+	return delivery{
+		deliveryTag: d.longlong(),
+		redelivered: d.octet()&0x01 != 0,
+		exchange:    d.shortstr(),
+		routingKey:  d.shortstr(),
+	}
+}
@@ -0,0 +1,107 @@
+package amqp
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ReadFrame(t *testing.T) {
+	raw := encodeFrame(frameBody, 1, []byte("payload"))
+	f, err := readFrame(bufio.NewReader(bytes.NewReader(raw)))
+	require.NoError(t, err)
+	require.Equal(t, frame{typ: frameBody, channel: 1, payload: []byte("payload")}, f)
+
+	raw[len(raw)-1] = 0
+	_, err = readFrame(bufio.NewReader(bytes.NewReader(raw)))
+	require.Equal(t, errMalformedFrame, err)
+
+	_, err = readFrame(bufio.NewReader(bytes.NewReader([]byte("AMQP\x00\x01\x00\x00"))))
+	require.EqualError(t, err, "the broker doesn't support AMQP 0.9.1")
+}
+
+func Test_DecodeTable(t *testing.T) {
+	var e encoder
+	e.long(0) // size, set below
+	e.shortstr("string")
+	e.octet('S')
+	e.longstr("value")
+	e.shortstr("bool")
+	e.octet('t')
+	e.octet(1)
+	e.shortstr("int")
+	e.octet('I')
+	e.long(uint32(0xFFFFFFFF))
+	e.shortstr("long")
+	e.octet('l')
+	e.longlong(42)
+	e.shortstr("decimal")
+	e.octet('D')
+	e.octet(2)
+	e.long(1234)
+	e.shortstr("timestamp")
+	e.octet('T')
+	e.longlong(1600000000)
+	e.shortstr("array")
+	e.octet('A')
+	e.long(3)
+	e.octet('B')
+	e.octet(7)
+	e.octet('V')
+	e.shortstr("void")
+	e.octet('V')
+	size := uint32(len(e.buf) - 4)
+	e.buf[0], e.buf[1], e.buf[2], e.buf[3] = byte(size>>24), byte(size>>16), byte(size>>8), byte(size)
+
+	d := decoder{buf: e.buf}
+	table := d.table()
+	require.NoError(t, d.err)
+	require.Equal(t, map[string]interface{}{
+		"string":    "value",
+		"bool":      true,
+		"int":       int32(-1),
+		"long":      int64(42),
+		"decimal":   12.34,
+		"timestamp": time.Unix(1600000000, 0).UTC(),
+		"array":     []interface{}{byte(7), nil},
+		"void":      nil,
+	}, table)
+
+	d = decoder{buf: []byte{0, 0, 0, 3, 1, 'a', 'Z'}}
+	d.table()
+	require.Error(t, d.err)
+}
+
+func Test_DecodeContentHeader(t *testing.T) {
+	var e encoder
+	e.short(60)
+	e.short(0)
+	e.longlong(11)
+	e.short(1<<15 | 1<<12 | 1<<7 | 1<<3)
+	e.shortstr("application/json")
+	e.octet(2)
+	e.shortstr("id-1")
+	e.shortstr("billing")
+
+	h, err := decodeContentHeader(e.buf)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), h.bodySize)
+	require.Equal(t, properties{contentType: "application/json", deliveryMode: 2, messageID: "id-1", appID: "billing"}, h.properties)
+
+	_, err = decodeContentHeader(e.buf[:len(e.buf)-1])
+	require.Error(t, err)
+}
+
+func Test_CloseReason(t *testing.T) {
+	m := newMethod(connectionClose)
+	m.short(320)
+	m.shortstr("CONNECTION_FORCED - broker forced connection closure with reason 'shutdown'")
+	m.short(0)
+	m.short(0)
+	d := decoder{buf: m.buf}
+	require.Equal(t, connectionClose, d.method())
+	require.EqualError(t, decodeClose(&d), "CONNECTION_FORCED - broker forced connection closure with reason 'shutdown' (320)")
+}
@@ -0,0 +1,58 @@
+package amqp
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds the metrics of the AMQP targets.
+type Metrics struct {
+	// reg is the Registerer used to create this set of metrics.
+	reg prometheus.Registerer
+
+	messages      *prometheus.CounterVec
+	messageBytes  *prometheus.CounterVec
+	requeued      *prometheus.CounterVec
+	connected     *prometheus.GaugeVec
+	reconnections *prometheus.CounterVec
+	errors        *prometheus.CounterVec
+}
+
+// NewMetrics creates a new set of metrics. Metrics will be registered to reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	var m Metrics
+	m.reg = reg
+
+	m.messages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "amqp_target_messages_total",
+		Help:      "Total number of messages received from AMQP queues.",
+	}, []string{"queue"})
+	m.messageBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "amqp_target_message_bytes_total",
+		Help:      "Total number of bytes of the bodies of the messages received from AMQP queues.",
+	}, []string{"queue"})
+	m.requeued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "amqp_target_requeued_messages_total",
+		Help:      "Total number of messages returned to AMQP queues because they could not be pushed.",
+	}, []string{"queue"})
+	m.connected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "amqp_target_connected",
+		Help:      "Whether the target is connected to the AMQP broker and consuming its queue.",
+	}, []string{"queue"})
+	m.reconnections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "amqp_target_reconnections_total",
+		Help:      "Total number of connections to AMQP brokers after a connection was lost.",
+	}, []string{"queue"})
+	m.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "amqp_target_errors_total",
+		Help:      "Total number of errors connecting to AMQP brokers or consuming their queues.",
+	}, []string{"queue"})
+
+	if reg != nil {
+		reg.MustRegister(m.messages, m.messageBytes, m.requeued, m.connected, m.reconnections, m.errors)
+	}
+	return &m
+}
@@ -0,0 +1,809 @@
+package amqp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
+	promconfig "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/prometheus/prometheus/util/strutil"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+	"github.com/grafana/loki/clients/pkg/promtail/tlspolicy"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/util"
+)
+
+const (
+	labelKeyAMQPQueue        = "__meta_amqp_queue"
+	labelKeyAMQPExchange     = "__meta_amqp_exchange"
+	labelKeyAMQPRoutingKey   = "__meta_amqp_routing_key"
+	labelKeyAMQPRedelivered  = "__meta_amqp_redelivered"
+	labelKeyAMQPContentType  = "__meta_amqp_content_type"
+	labelKeyAMQPAppID        = "__meta_amqp_app_id"
+	labelKeyAMQPType         = "__meta_amqp_type"
+	labelKeyAMQPHeaderPrefix = "__meta_amqp_header_"
+
+	defaultBindingKey     = "#"
+	defaultPrefetchCount  = 100
+	defaultHeartbeat      = 30 * time.Second
+	defaultConnectTimeout = 10 * time.Second
+
+	// channelID is the only channel opened by the target.
+	channelID = 1
+)
+
+var reconnectBackoff = backoff.Config{
+	MinBackoff: 1 * time.Second,
+	MaxBackoff: 60 * time.Second,
+}
+
+// Target consumes the messages of an AMQP 0.9.1 queue and pushes the body of each
+// message as a log line. Messages are acknowledged to the broker once their entry has
+// been pushed to Loki, and returned to the queue when it could not be pushed.
+type Target struct {
+	logger        log.Logger
+	metrics       *Metrics
+	handler       api.EntryHandler
+	config        *scrapeconfig.AMQPTargetConfig
+	relabelConfig []*relabel.Config
+
+	address   string
+	tlsConfig *tls.Config
+	vhost     string
+	username  string
+	password  string
+
+	mtx         sync.Mutex
+	connected   bool
+	consumerTag string
+	messages    int
+	lastError   error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTarget creates a Target consuming the queue of a scrape config.
+func NewTarget(
+	metrics *Metrics,
+	logger log.Logger,
+	handler api.EntryHandler,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.AMQPTargetConfig,
+) (*Target, error) {
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+	u, err := parseURL(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	var tlsConfig *tls.Config
+	if u.useTLS {
+		tlsConfig, err = promconfig.NewTLSConfig(&config.TLSConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error creating TLS config: %w", err)
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(u.address)
+		}
+		tlspolicy.Apply(tlsConfig)
+	}
+	username, password := u.username, u.password
+	if config.Username != "" {
+		username, password = config.Username, config.Password.Value
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Target{
+		logger:        log.With(logger, "queue", config.Queue),
+		metrics:       metrics,
+		handler:       handler,
+		config:        config,
+		relabelConfig: relabelConfig,
+		address:       u.address,
+		tlsConfig:     tlsConfig,
+		vhost:         u.vhost,
+		username:      username,
+		password:      password,
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	go t.run()
+	return t, nil
+}
+
+func validateConfig(cfg *scrapeconfig.AMQPTargetConfig) error {
+	if cfg.URL == "" {
+		return errors.New("no AMQP broker URL given")
+	}
+	if cfg.Queue == "" {
+		return errors.New("no AMQP queue given to consume")
+	}
+	if cfg.Exchange == "" && len(cfg.BindingKeys) > 0 {
+		return errors.New("binding keys require an exchange to bind the queue to")
+	}
+	if cfg.Exchange != "" && len(cfg.BindingKeys) == 0 {
+		cfg.BindingKeys = []string{defaultBindingKey}
+	}
+	if cfg.PrefetchCount == 0 {
+		cfg.PrefetchCount = defaultPrefetchCount
+	}
+	if cfg.PrefetchCount < 0 || cfg.PrefetchCount > 65535 {
+		return errors.New("prefetch count must be between 1 and 65535")
+	}
+	if cfg.Heartbeat == 0 {
+		cfg.Heartbeat = defaultHeartbeat
+	}
+	if cfg.Heartbeat < time.Second || cfg.Heartbeat > 65535*time.Second {
+		return errors.New("heartbeat must be between 1s and 65535s")
+	}
+	if cfg.ConnectTimeout == 0 {
+		cfg.ConnectTimeout = defaultConnectTimeout
+	}
+	return nil
+}
+
+// brokerURL is a parsed AMQP URL.
+type brokerURL struct {
+	address  string
+	useTLS   bool
+	vhost    string
+	username string
+	password string
+}
+
+func parseURL(raw string) (brokerURL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return brokerURL{}, fmt.Errorf("invalid AMQP URL: %w", err)
+	}
+	b := brokerURL{vhost: "/", username: "guest", password: "guest"}
+	port := "5672"
+	switch u.Scheme {
+	case "amqp":
+	case "amqps":
+		b.useTLS = true
+		port = "5671"
+	default:
+		return brokerURL{}, fmt.Errorf("unsupported scheme %s for AMQP URL, must be amqp or amqps", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return brokerURL{}, errors.New("no host in AMQP URL")
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	b.address = net.JoinHostPort(u.Hostname(), port)
+	if u.Path != "" && u.Path != "/" {
+		b.vhost = u.Path[1:]
+	}
+	if u.User != nil {
+		b.username = u.User.Username()
+		b.password, _ = u.User.Password()
+	}
+	return b, nil
+}
+
+func (t *Target) run() {
+	defer close(t.done)
+	b := backoff.New(t.ctx, reconnectBackoff)
+	var reconnect bool
+	for t.ctx.Err() == nil {
+		err := t.session(b, reconnect)
+		if t.ctx.Err() != nil {
+			return
+		}
+		reconnect = true
+		level.Warn(t.logger).Log("msg", "AMQP connection failed, reconnecting", "err", err)
+		t.setError(err)
+		b.Wait()
+	}
+}
+
+// session connects to the broker and consumes the queue until the connection fails
+// or the target is stopped.
+func (t *Target) session(b *backoff.Backoff, reconnect bool) error {
+	c, err := t.connect()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	b.Reset()
+	if reconnect {
+		t.metrics.reconnections.WithLabelValues(t.config.Queue).Inc()
+	}
+	level.Info(t.logger).Log("msg", "consuming AMQP queue", "consumer_tag", c.consumerTag)
+	t.mtx.Lock()
+	t.consumerTag = c.consumerTag
+	t.mtx.Unlock()
+	t.setConnected(true)
+	defer t.setConnected(false)
+	return t.consume(c)
+}
+
+// conn is a connection to a broker.
+type conn struct {
+	net.Conn
+	reader      *bufio.Reader
+	heartbeat   time.Duration
+	consumerTag string
+}
+
+func (c *conn) write(p []byte) error {
+	if err := c.SetWriteDeadline(time.Now().Add(c.heartbeat)); err != nil {
+		return err
+	}
+	_, err := c.Write(p)
+	return err
+}
+
+func (c *conn) writeMethod(channel uint16, m *encoder) error {
+	return c.write(encodeFrame(frameMethod, channel, m.buf))
+}
+
+// readMethod reads the next method, which must be the expected one, and returns a
+// decoder of its arguments.
+func (c *conn) readMethod(expected methodID) (*decoder, error) {
+	for {
+		f, err := readFrame(c.reader)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", expected, err)
+		}
+		if f.typ == frameHeartbeat {
+			continue
+		}
+		if f.typ != frameMethod {
+			return nil, fmt.Errorf("expected %s, got frame type %d", expected, f.typ)
+		}
+		d := &decoder{buf: f.payload}
+		id := d.method()
+		if d.err != nil {
+			return nil, d.err
+		}
+		switch id {
+		case expected:
+			return d, nil
+		case connectionClose, channelClose:
+			err := decodeBrokerClose(f.channel, d)
+			_ = c.writeMethod(err.channel, newMethod(err.reply()))
+			return nil, err
+		default:
+			return nil, fmt.Errorf("expected %s, got %s", expected, id)
+		}
+	}
+}
+
+// close closes the connection gracefully.
+func (c *conn) close() {
+	m := newMethod(connectionClose)
+	m.short(200)
+	m.shortstr("promtail stopping")
+	m.short(0)
+	m.short(0)
+	_ = c.writeMethod(0, m)
+	_ = c.Close()
+}
+
+// brokerCloseError is returned when the broker closes the connection or the channel.
+type brokerCloseError struct {
+	closeReason
+	channel uint16
+}
+
+func decodeBrokerClose(channel uint16, d *decoder) *brokerCloseError {
+	return &brokerCloseError{closeReason: decodeClose(d), channel: channel}
+}
+
+// reply returns the method acknowledging the close.
+func (e *brokerCloseError) reply() methodID {
+	if e.channel == 0 {
+		return connectionCloseOk
+	}
+	return channelCloseOk
+}
+
+func (e *brokerCloseError) Error() string {
+	if e.channel == 0 {
+		return "connection closed by the broker: " + e.closeReason.Error()
+	}
+	return "channel closed by the broker: " + e.closeReason.Error()
+}
+
+// connect opens a connection and starts consuming the queue.
+func (t *Target) connect() (*conn, error) {
+	deadline := time.Now().Add(t.config.ConnectTimeout)
+	dialer := &net.Dialer{Deadline: deadline}
+	var (
+		nc  net.Conn
+		err error
+	)
+	if t.tlsConfig != nil {
+		nc, err = tls.DialWithDialer(dialer, "tcp", t.address, t.tlsConfig)
+	} else {
+		nc, err = dialer.DialContext(t.ctx, "tcp", t.address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{Conn: nc, reader: bufio.NewReader(nc), heartbeat: t.config.Heartbeat}
+	if err := t.handshake(c, deadline); err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (t *Target) handshake(c *conn, deadline time.Time) error {
+	if err := c.SetDeadline(deadline); err != nil {
+		return err
+	}
+	if _, err := c.Write(protocolHeader); err != nil {
+		return err
+	}
+
+	d, err := c.readMethod(connectionStart)
+	if err != nil {
+		return err
+	}
+	d.octet() // version-major
+	d.octet() // version-minor
+	d.table() // server-properties
+	mechanisms := d.longstr()
+	if d.err != nil {
+		return d.err
+	}
+	if !contains(strings.Fields(mechanisms), "PLAIN") {
+		return fmt.Errorf("the broker doesn't support the PLAIN authentication mechanism, only %s", mechanisms)
+	}
+	m := newMethod(connectionStartOk)
+	m.table(map[string]interface{}{
+		"product":  "promtail",
+		"platform": "Go",
+		"capabilities": map[string]interface{}{
+			"consumer_cancel_notify": true,
+			"basic.nack":             true,
+		},
+	})
+	m.shortstr("PLAIN")
+	m.longstr("\x00" + t.username + "\x00" + t.password)
+	m.shortstr("en_US")
+	if err := c.writeMethod(0, m); err != nil {
+		return err
+	}
+
+	d, err = c.readMethod(connectionTune)
+	if err != nil {
+		return err
+	}
+	d.short() // channel-max
+	frameMax := d.long()
+	heartbeat := time.Duration(d.short()) * time.Second
+	if d.err != nil {
+		return d.err
+	}
+	if frameMax == 0 || frameMax > maxFrameSize {
+		frameMax = maxFrameSize
+	}
+	if heartbeat > 0 && heartbeat < c.heartbeat {
+		c.heartbeat = heartbeat
+	}
+	m = newMethod(connectionTuneOk)
+	m.short(channelID)
+	m.long(frameMax)
+	m.short(uint16(c.heartbeat / time.Second))
+	if err := c.writeMethod(0, m); err != nil {
+		return err
+	}
+
+	m = newMethod(connectionOpen)
+	m.shortstr(t.vhost)
+	m.shortstr("") // reserved
+	m.octet(0)     // reserved
+	if err := c.writeMethod(0, m); err != nil {
+		return err
+	}
+	if _, err := c.readMethod(connectionOpenOk); err != nil {
+		return err
+	}
+
+	m = newMethod(channelOpen)
+	m.shortstr("") // reserved
+	if err := c.writeMethod(channelID, m); err != nil {
+		return err
+	}
+	if _, err := c.readMethod(channelOpenOk); err != nil {
+		return err
+	}
+
+	m = newMethod(basicQos)
+	m.long(0) // prefetch-size
+	m.short(uint16(t.config.PrefetchCount))
+	m.octet(0) // global
+	if err := c.writeMethod(channelID, m); err != nil {
+		return err
+	}
+	if _, err := c.readMethod(basicQosOk); err != nil {
+		return err
+	}
+
+	if t.config.DeclareQueue {
+		m = newMethod(queueDeclare)
+		m.short(0) // reserved
+		m.shortstr(t.config.Queue)
+		m.octet(1 << 1) // durable
+		m.table(nil)
+		if err := c.writeMethod(channelID, m); err != nil {
+			return err
+		}
+		if _, err := c.readMethod(queueDeclareOk); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range t.config.BindingKeys {
+		m = newMethod(queueBind)
+		m.short(0) // reserved
+		m.shortstr(t.config.Queue)
+		m.shortstr(t.config.Exchange)
+		m.shortstr(key)
+		m.octet(0) // no-wait
+		m.table(nil)
+		if err := c.writeMethod(channelID, m); err != nil {
+			return err
+		}
+		if _, err := c.readMethod(queueBindOk); err != nil {
+			return err
+		}
+	}
+
+	m = newMethod(basicConsume)
+	m.short(0) // reserved
+	m.shortstr(t.config.Queue)
+	m.shortstr(t.config.ConsumerTag)
+	m.octet(0) // no-local, no-ack, exclusive and no-wait
+	m.table(nil)
+	if err := c.writeMethod(channelID, m); err != nil {
+		return err
+	}
+	d, err = c.readMethod(basicConsumeOk)
+	if err != nil {
+		return err
+	}
+	c.consumerTag = d.shortstr()
+	if d.err != nil {
+		return d.err
+	}
+	return c.SetDeadline(time.Time{})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type deliveryAck struct {
+	deliveryTag uint64
+	err         error
+}
+
+// consume sends the messages delivered by the broker to the handler, and acknowledges
+// them once their entry was pushed. The broker delivers at most PrefetchCount messages
+// not yet acknowledged.
+func (t *Target) consume(c *conn) error {
+	var (
+		deliveries = make(chan delivery)
+		readErr    = make(chan error, 1)
+		stopped    = make(chan struct{})
+		pending    []api.Entry
+		// acks is buffered to never block the acknowledgements once the session ended.
+		acks = make(chan deliveryAck, t.config.PrefetchCount)
+	)
+	go func() {
+		for {
+			d, err := t.readDelivery(c)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case deliveries <- d:
+			case <-stopped:
+				return
+			}
+		}
+	}()
+	defer close(stopped)
+
+	heartbeat := time.NewTicker(c.heartbeat / 2)
+	defer heartbeat.Stop()
+
+	for {
+		// Entries are sent to the handler without blocking the acknowledgements and the
+		// heartbeats, the broker stops delivering once PrefetchCount messages are pending.
+		var (
+			out  chan<- api.Entry
+			next api.Entry
+		)
+		if len(pending) > 0 {
+			out, next = t.handler.Chan(), pending[0]
+		}
+		select {
+		case d := <-deliveries:
+			entry := t.entry(d)
+			tag := d.deliveryTag
+			entry.Ack = func(err error) { acks <- deliveryAck{deliveryTag: tag, err: err} }
+			pending = append(pending, entry)
+		case out <- next:
+			pending = pending[1:]
+		case a := <-acks:
+			var m *encoder
+			if a.err == nil {
+				m = newMethod(basicAck)
+				m.longlong(a.deliveryTag)
+				m.octet(0) // multiple
+			} else {
+				level.Warn(t.logger).Log("msg", "returning message to the queue, it could not be pushed", "err", a.err)
+				t.metrics.requeued.WithLabelValues(t.config.Queue).Inc()
+				m = newMethod(basicNack)
+				m.longlong(a.deliveryTag)
+				m.octet(1 << 1) // requeue
+			}
+			if err := c.writeMethod(channelID, m); err != nil {
+				return err
+			}
+		case <-heartbeat.C:
+			if err := c.write(encodeFrame(frameHeartbeat, 0, nil)); err != nil {
+				return err
+			}
+		case err := <-readErr:
+			var closeErr *brokerCloseError
+			if errors.As(err, &closeErr) {
+				_ = c.writeMethod(closeErr.channel, newMethod(closeErr.reply()))
+			}
+			return err
+		case <-t.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// readDelivery reads the frames of the connection until a message is delivered.
+// A broker not sending any frame during two heartbeats is considered dead.
+func (t *Target) readDelivery(c *conn) (delivery, error) {
+	for {
+		f, err := t.readFrame(c)
+		if err != nil {
+			return delivery{}, err
+		}
+		if f.typ != frameMethod {
+			continue
+		}
+		d := &decoder{buf: f.payload}
+		switch id := d.method(); id {
+		case basicDeliver:
+			dl := decodeDeliver(d)
+			if d.err != nil {
+				return delivery{}, fmt.Errorf("error decoding %s: %w", id, d.err)
+			}
+			return dl, t.readContent(c, &dl)
+		case connectionClose, channelClose:
+			return delivery{}, decodeBrokerClose(f.channel, d)
+		case basicCancel:
+			return delivery{}, errors.New("consumer cancelled by the broker, the queue may have been deleted")
+		}
+	}
+}
+
+func (t *Target) readFrame(c *conn) (frame, error) {
+	for {
+		if err := c.SetReadDeadline(time.Now().Add(2 * c.heartbeat)); err != nil {
+			return frame{}, err
+		}
+		f, err := readFrame(c.reader)
+		if err != nil || f.typ != frameHeartbeat {
+			return f, err
+		}
+	}
+}
+
+// readContent reads the content header and the body frames of a delivery.
+func (t *Target) readContent(c *conn, dl *delivery) error {
+	f, err := t.readFrame(c)
+	if err != nil {
+		return err
+	}
+	if f.typ != frameHeader {
+		return fmt.Errorf("expected a content header, got frame type %d", f.typ)
+	}
+	h, err := decodeContentHeader(f.payload)
+	if err != nil {
+		return fmt.Errorf("error decoding content header: %w", err)
+	}
+	dl.properties = h.properties
+	dl.body = make([]byte, 0, h.bodySize)
+	for uint64(len(dl.body)) < h.bodySize {
+		f, err := t.readFrame(c)
+		if err != nil {
+			return err
+		}
+		if f.typ != frameBody {
+			return fmt.Errorf("expected a content body, got frame type %d", f.typ)
+		}
+		dl.body = append(dl.body, f.payload...)
+	}
+	if uint64(len(dl.body)) != h.bodySize {
+		return errMalformedFrame
+	}
+	return nil
+}
+
+// entry returns the entry of a delivery.
+func (t *Target) entry(d delivery) api.Entry {
+	t.metrics.messages.WithLabelValues(t.config.Queue).Inc()
+	t.metrics.messageBytes.WithLabelValues(t.config.Queue).Add(float64(len(d.body)))
+	t.mtx.Lock()
+	t.messages++
+	t.lastError = nil
+	t.mtx.Unlock()
+
+	ts := time.Now()
+	if t.config.UseIncomingTimestamp && !d.properties.timestamp.IsZero() {
+		ts = d.properties.timestamp
+	}
+	return api.Entry{
+		Labels: t.labels(d),
+		Entry:  logproto.Entry{Timestamp: ts, Line: string(d.body)},
+	}
+}
+
+func (t *Target) labels(d delivery) model.LabelSet {
+	lbs := labels.Labels{
+		{Name: labelKeyAMQPQueue, Value: t.config.Queue},
+		{Name: labelKeyAMQPExchange, Value: d.exchange},
+		{Name: labelKeyAMQPRoutingKey, Value: d.routingKey},
+		{Name: labelKeyAMQPRedelivered, Value: strconv.FormatBool(d.redelivered)},
+	}
+	if d.properties.contentType != "" {
+		lbs = append(lbs, labels.Label{Name: labelKeyAMQPContentType, Value: d.properties.contentType})
+	}
+	if d.properties.appID != "" {
+		lbs = append(lbs, labels.Label{Name: labelKeyAMQPAppID, Value: d.properties.appID})
+	}
+	if d.properties.typ != "" {
+		lbs = append(lbs, labels.Label{Name: labelKeyAMQPType, Value: d.properties.typ})
+	}
+	names := make([]string, 0, len(d.properties.headers))
+	for name := range d.properties.headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value, ok := headerValue(d.properties.headers[name]); ok {
+			lbs = append(lbs, labels.Label{Name: labelKeyAMQPHeaderPrefix + strutil.SanitizeLabelName(name), Value: value})
+		}
+	}
+	out := t.config.Labels.Clone()
+	if processed := format(lbs, t.relabelConfig); len(processed) > 0 {
+		out = out.Merge(processed)
+	}
+	return out
+}
+
+// headerValue formats the value of a header, headers holding tables and arrays are skipped.
+func headerValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return "", false
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case time.Time:
+		return v.Format(time.RFC3339), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+func format(lbs labels.Labels, cfg []*relabel.Config) model.LabelSet {
+	processed := relabel.Process(labels.New(lbs...), cfg...)
+	labelOut := model.LabelSet(util.LabelsToMetric(processed))
+	for k := range labelOut {
+		if strings.HasPrefix(string(k), "__") {
+			delete(labelOut, k)
+		}
+	}
+	return labelOut
+}
+
+func (t *Target) setConnected(connected bool) {
+	v := 0.
+	if connected {
+		v = 1
+	}
+	t.metrics.connected.WithLabelValues(t.config.Queue).Set(v)
+	t.mtx.Lock()
+	t.connected = connected
+	t.mtx.Unlock()
+}
+
+func (t *Target) setError(err error) {
+	t.metrics.errors.WithLabelValues(t.config.Queue).Inc()
+	t.mtx.Lock()
+	t.lastError = err
+	t.mtx.Unlock()
+}
+
+// Stop disconnects from the broker. The messages not yet acknowledged are returned to
+// the queue by the broker.
+func (t *Target) Stop() {
+	t.cancel()
+	<-t.done
+	t.handler.Stop()
+}
+
+// Type implements target.Target.
+func (t *Target) Type() target.TargetType {
+	return target.AMQPTargetType
+}
+
+// Ready implements target.Target.
+func (t *Target) Ready() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.connected
+}
+
+// DiscoveredLabels implements target.Target.
+func (t *Target) DiscoveredLabels() model.LabelSet {
+	return nil
+}
+
+// Labels implements target.Target.
+func (t *Target) Labels() model.LabelSet {
+	return t.config.Labels
+}
+
+// Details implements target.Target.
+func (t *Target) Details() interface{} {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	details := map[string]string{
+		"address":      t.address,
+		"vhost":        t.vhost,
+		"queue":        t.config.Queue,
+		"consumer_tag": t.consumerTag,
+		"connected":    strconv.FormatBool(t.connected),
+		"messages":     fmt.Sprint(t.messages),
+	}
+	if t.lastError != nil {
+		details["error"] = t.lastError.Error()
+	}
+	return details
+}
@@ -0,0 +1,275 @@
+package diskbuffer
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+const (
+	defaultMaxSize = 100 << 20
+	// maxSegmentSize caps the size of the segments, which are evicted as a whole.
+	maxSegmentSize = 16 << 20
+	// defaultMaxMemoryEntries is the number of entries queued in memory before they are
+	// written to disk.
+	defaultMaxMemoryEntries = 1000
+)
+
+var errBufferClosed = errors.New("disk buffer closed")
+
+// record is the encoding of an entry on disk.
+type record struct {
+	Labels             model.LabelSet       `json:"labels"`
+	Timestamp          time.Time            `json:"timestamp"`
+	Line               string               `json:"line"`
+	StructuredMetadata []logproto.LabelPair `json:"structured_metadata,omitempty"`
+}
+
+// Buffer is an EntryHandler buffering on disk the entries of a target which can't be sent
+// to next, for receivers which can't apply backpressure upstream such as syslog over UDP.
+//
+// Entries are queued in memory while next accepts them, and written to disk once the memory
+// queue is full, e.g. while the clients retry pushing to an unavailable Loki. Once entries are
+// on disk, the new entries are written after them until they are all sent, to keep their order.
+// Entries the clients could not push are written to disk again. When the buffer is full, its
+// oldest entries are evicted.
+//
+// Entries are acknowledged once acknowledged by next, or once written to disk. The buffer
+// must be stopped independently from next, the entries still queued in memory are written to
+// disk and sent once the target is started again.
+type Buffer struct {
+	job              string
+	next             chan<- api.Entry
+	metrics          *Metrics
+	logger           log.Logger
+	maxMemoryEntries int
+
+	in chan api.Entry
+
+	mtx    sync.Mutex
+	memory []api.Entry
+	queue  *queue
+	closed bool
+	// notify wakes the sender up once entries are added.
+	notify chan struct{}
+
+	done     chan struct{}
+	receiver sync.WaitGroup
+	sender   sync.WaitGroup
+	once     sync.Once
+}
+
+// New creates a Buffer for the target of the given job, sending the entries to next.
+func New(cfg scrapeconfig.DiskBufferConfig, job string, next api.EntryHandler, metrics *Metrics, logger log.Logger) (*Buffer, error) {
+	if cfg.Directory == "" {
+		return nil, errors.New("the disk buffer directory is required")
+	}
+	maxSize := int64(cfg.MaxSize.Val())
+	if maxSize == 0 {
+		maxSize = defaultMaxSize
+	}
+	segmentSize := maxSize / 8
+	if segmentSize > maxSegmentSize {
+		segmentSize = maxSegmentSize
+	}
+	q, err := openQueue(cfg.Directory, maxSize, segmentSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening the disk buffer")
+	}
+
+	b := &Buffer{
+		job:              job,
+		next:             next.Chan(),
+		metrics:          metrics,
+		logger:           log.With(logger, "component", "disk_buffer", "job", job),
+		maxMemoryEntries: defaultMaxMemoryEntries,
+		in:               make(chan api.Entry),
+		queue:            q,
+		notify:           make(chan struct{}, 1),
+		done:             make(chan struct{}),
+	}
+	if n := q.len(); n > 0 {
+		level.Info(b.logger).Log("msg", "sending the entries left in the disk buffer", "entries", n, "directory", cfg.Directory)
+	}
+	b.updateGauges()
+
+	b.receiver.Add(1)
+	go b.receive()
+	b.sender.Add(1)
+	go b.send()
+	return b, nil
+}
+
+// Chan implements api.EntryHandler.
+func (b *Buffer) Chan() chan<- api.Entry {
+	return b.in
+}
+
+// Stop stops the buffer, writing the entries queued in memory to disk.
+func (b *Buffer) Stop() {
+	b.once.Do(func() {
+		close(b.in)
+		b.receiver.Wait()
+		close(b.done)
+		b.sender.Wait()
+
+		b.mtx.Lock()
+		acks := make([]api.AckFunc, 0, len(b.memory))
+		errs := make([]error, 0, len(b.memory))
+		for _, e := range b.memory {
+			acks = append(acks, e.Ack)
+			errs = append(errs, b.spillLocked(e))
+		}
+		b.memory = nil
+		b.closed = true
+		b.queue.close()
+		b.mtx.Unlock()
+
+		for i, ack := range acks {
+			ack.Done(errs[i])
+		}
+	})
+}
+
+// receive queues the received entries, in memory while there is room for them and no
+// entry is waiting on disk, on disk otherwise. It never blocks on next.
+func (b *Buffer) receive() {
+	defer b.receiver.Done()
+	for e := range b.in {
+		b.mtx.Lock()
+		if b.queue.empty() && len(b.memory) < b.maxMemoryEntries {
+			b.memory = append(b.memory, e)
+			b.mtx.Unlock()
+		} else {
+			err := b.spillLocked(e)
+			b.mtx.Unlock()
+			e.Ack.Done(err)
+		}
+		b.wake()
+	}
+}
+
+// send sends the queued entries to next, the ones in memory first as they are older.
+func (b *Buffer) send() {
+	defer b.sender.Done()
+	for {
+		e, ok := b.pop()
+		if !ok {
+			select {
+			case <-b.notify:
+				continue
+			case <-b.done:
+				return
+			}
+		}
+		out := e
+		out.Ack = b.ackFunc(e)
+		select {
+		case b.next <- out:
+		case <-b.done:
+			b.mtx.Lock()
+			b.memory = append([]api.Entry{e}, b.memory...)
+			b.mtx.Unlock()
+			return
+		}
+	}
+}
+
+// ackFunc returns the AckFunc of an entry sent to next. Entries which could not be pushed
+// are written to disk to be sent again.
+func (b *Buffer) ackFunc(e api.Entry) api.AckFunc {
+	return func(err error) {
+		if err != nil {
+			b.mtx.Lock()
+			err = b.spillLocked(e)
+			b.mtx.Unlock()
+			b.wake()
+		}
+		e.Ack.Done(err)
+	}
+}
+
+// pop removes and returns the oldest entry, false if there is none.
+func (b *Buffer) pop() (api.Entry, bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if len(b.memory) > 0 {
+		e := b.memory[0]
+		b.memory[0] = api.Entry{}
+		b.memory = b.memory[1:]
+		return e, true
+	}
+	for {
+		data, ok, lost, err := b.queue.pop()
+		if err != nil {
+			b.metrics.droppedEntries.WithLabelValues(b.job).Add(float64(lost))
+			level.Error(b.logger).Log("msg", "dropping the entries of a disk buffer segment which can't be read", "entries", lost, "err", err)
+			continue
+		}
+		if !ok {
+			b.updateGauges()
+			return api.Entry{}, false
+		}
+		var r record
+		if err := json.Unmarshal(data, &r); err != nil {
+			b.metrics.droppedEntries.WithLabelValues(b.job).Inc()
+			level.Error(b.logger).Log("msg", "dropping an entry of the disk buffer which can't be decoded", "err", err)
+			continue
+		}
+		b.updateGauges()
+		return api.Entry{
+			Labels: r.Labels,
+			Entry:  logproto.Entry{Timestamp: r.Timestamp, Line: r.Line, StructuredMetadata: r.StructuredMetadata},
+		}, true
+	}
+}
+
+// spillLocked writes an entry to disk, the oldest entries are evicted when the buffer is full.
+// It must be called with mtx held.
+func (b *Buffer) spillLocked(e api.Entry) error {
+	if b.closed {
+		return errBufferClosed
+	}
+	data, err := json.Marshal(record{Labels: e.Labels, Timestamp: e.Timestamp, Line: e.Line, StructuredMetadata: e.StructuredMetadata})
+	evicted := 0
+	if err == nil {
+		evicted, err = b.queue.push(data)
+	}
+	if evicted > 0 {
+		b.metrics.evictedEntries.WithLabelValues(b.job).Add(float64(evicted))
+		level.Warn(b.logger).Log("msg", "disk buffer full, evicted the oldest entries", "entries", evicted)
+	}
+	if err != nil {
+		b.metrics.droppedEntries.WithLabelValues(b.job).Inc()
+		level.Error(b.logger).Log("msg", "error writing entry to the disk buffer", "err", err)
+		b.updateGauges()
+		return err
+	}
+	b.metrics.spilledEntries.WithLabelValues(b.job).Inc()
+	b.updateGauges()
+	return nil
+}
+
+func (b *Buffer) updateGauges() {
+	b.metrics.entries.WithLabelValues(b.job).Set(float64(b.queue.len()))
+	b.metrics.bytes.WithLabelValues(b.job).Set(float64(b.queue.size))
+}
+
+// wake wakes the sender up.
+func (b *Buffer) wake() {
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
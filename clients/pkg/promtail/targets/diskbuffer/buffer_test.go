@@ -0,0 +1,188 @@
+package diskbuffer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func newTestBuffer(t *testing.T, dir string, maxSize int, maxMemoryEntries int) (*Buffer, chan api.Entry, *Metrics) {
+	t.Helper()
+	next := make(chan api.Entry)
+	metrics := NewMetrics(prometheus.NewRegistry())
+	cfg := scrapeconfig.DiskBufferConfig{Directory: dir}
+	require.NoError(t, cfg.MaxSize.Set(fmt.Sprintf("%dB", maxSize)))
+	b, err := New(cfg, "test", api.NewEntryHandler(next, func() {}), metrics, log.NewNopLogger())
+	require.NoError(t, err)
+	b.mtx.Lock()
+	b.maxMemoryEntries = maxMemoryEntries
+	b.mtx.Unlock()
+	return b, next, metrics
+}
+
+func testEntry(i int, acked *atomic.Int32) api.Entry {
+	return api.Entry{
+		Labels: model.LabelSet{"job": "test"},
+		Entry:  logproto.Entry{Timestamp: time.Unix(int64(i), 0).UTC(), Line: fmt.Sprintf("line %03d", i)},
+		Ack: func(err error) {
+			if err == nil {
+				acked.Inc()
+			}
+		},
+	}
+}
+
+func receive(t *testing.T, next chan api.Entry, n int) []string {
+	t.Helper()
+	var lines []string
+	for i := 0; i < n; i++ {
+		select {
+		case e := <-next:
+			e.Ack.Done(nil)
+			lines = append(lines, e.Line)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for entry %d", i)
+		}
+	}
+	return lines
+}
+
+func expectedLines(from, to int) []string {
+	var lines []string
+	for i := from; i < to; i++ {
+		lines = append(lines, fmt.Sprintf("line %03d", i))
+	}
+	return lines
+}
+
+func TestBuffer_SpillsWhileNextIsBlocked(t *testing.T) {
+	b, next, metrics := newTestBuffer(t, t.TempDir(), 1<<20, 2)
+	defer b.Stop()
+
+	acked := atomic.NewInt32(0)
+	// nothing reads next, sending must not block.
+	for i := 0; i < 10; i++ {
+		b.Chan() <- testEntry(i, acked)
+	}
+	// the entries written to disk are acknowledged.
+	require.Eventually(t, func() bool {
+		return acked.Load() >= 7
+	}, 5*time.Second, 10*time.Millisecond)
+	require.GreaterOrEqual(t, testutil.ToFloat64(metrics.spilledEntries.WithLabelValues("test")), 7.0)
+
+	require.Equal(t, expectedLines(0, 10), receive(t, next, 10))
+	require.Eventually(t, func() bool {
+		return acked.Load() == 10
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.entries.WithLabelValues("test")))
+}
+
+func TestBuffer_EvictsOldestEntries(t *testing.T) {
+	dir := t.TempDir()
+	b, next, metrics := newTestBuffer(t, dir, 1024, 0)
+	defer b.Stop()
+
+	acked := atomic.NewInt32(0)
+	for i := 0; i < 100; i++ {
+		b.Chan() <- testEntry(i, acked)
+	}
+	// the sender holds the first entry, the buffer keeps the newest ones.
+	var lines []string
+	for done := false; !done; {
+		select {
+		case e := <-next:
+			e.Ack.Done(nil)
+			lines = append(lines, e.Line)
+		case <-time.After(200 * time.Millisecond):
+			done = true
+		}
+	}
+	evicted := int(testutil.ToFloat64(metrics.evictedEntries.WithLabelValues("test")))
+	require.Greater(t, evicted, 0)
+	require.Equal(t, 100, len(lines)+evicted)
+	require.Equal(t, "line 099", lines[len(lines)-1])
+	for i := 1; i < len(lines); i++ {
+		require.Less(t, lines[i-1], lines[i])
+	}
+	require.LessOrEqual(t, testutil.ToFloat64(metrics.bytes.WithLabelValues("test")), 1024.0)
+}
+
+func TestBuffer_ResendsEntriesNotPushed(t *testing.T) {
+	b, next, _ := newTestBuffer(t, t.TempDir(), 1<<20, 10)
+	defer b.Stop()
+
+	acked := atomic.NewInt32(0)
+	b.Chan() <- testEntry(0, acked)
+
+	e := <-next
+	e.Ack.Done(errors.New("server returned HTTP status 503 Service Unavailable"))
+	require.Equal(t, int32(1), acked.Load(), "the entry is acknowledged once written to disk")
+
+	require.Equal(t, []string{"line 000"}, receive(t, next, 1))
+}
+
+func TestBuffer_KeepsEntriesAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	b, _, _ := newTestBuffer(t, dir, 1<<20, 2)
+
+	acked := atomic.NewInt32(0)
+	for i := 0; i < 5; i++ {
+		b.Chan() <- testEntry(i, acked)
+	}
+	b.Stop()
+	require.Equal(t, int32(5), acked.Load())
+
+	b, next, metrics := newTestBuffer(t, dir, 1<<20, 2)
+	defer b.Stop()
+	require.ElementsMatch(t, expectedLines(0, 5), receive(t, next, 5))
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(metrics.entries.WithLabelValues("test")) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestQueue_TruncatesPartialRecords(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openQueue(dir, 1<<20, 1<<10)
+	require.NoError(t, err)
+	for _, r := range []string{"a", "b"} {
+		_, err := q.push([]byte(r))
+		require.NoError(t, err)
+	}
+	path := q.last().path
+	q.close()
+
+	// a record interrupted while being written.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 10, 'c'})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	q, err = openQueue(dir, 1<<20, 1<<10)
+	require.NoError(t, err)
+	defer q.close()
+	require.Equal(t, 2, q.len())
+	for _, expected := range []string{"a", "b"} {
+		r, ok, _, err := q.pop()
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, expected, string(r))
+	}
+	_, ok, _, err := q.pop()
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,64 @@
+package diskbuffer
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds the metrics of the disk buffers, labeled by the job of their target.
+type Metrics struct {
+	entries        *prometheus.GaugeVec
+	bytes          *prometheus.GaugeVec
+	spilledEntries *prometheus.CounterVec
+	evictedEntries *prometheus.CounterVec
+	droppedEntries *prometheus.CounterVec
+}
+
+// NewMetrics creates a new set of disk buffer metrics. If reg is non-nil, the metrics
+// are registered, or the ones already registered by another target are reused.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	var m Metrics
+
+	m.entries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "disk_buffer_entries",
+		Help:      "Number of entries waiting in the disk buffer of a target.",
+	}, []string{"job"})
+	m.bytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "disk_buffer_bytes",
+		Help:      "Size of the segments of the disk buffer of a target.",
+	}, []string{"job"})
+	m.spilledEntries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "disk_buffer_spilled_entries_total",
+		Help:      "Total number of entries written to the disk buffer of a target.",
+	}, []string{"job"})
+	m.evictedEntries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "disk_buffer_evicted_entries_total",
+		Help:      "Total number of entries evicted from the disk buffer of a target before being sent, as it was full.",
+	}, []string{"job"})
+	m.droppedEntries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "disk_buffer_dropped_entries_total",
+		Help:      "Total number of entries which could not be written to the disk buffer of a target.",
+	}, []string{"job"})
+
+	if reg != nil {
+		m.entries = mustRegisterOrGet(reg, m.entries).(*prometheus.GaugeVec)
+		m.bytes = mustRegisterOrGet(reg, m.bytes).(*prometheus.GaugeVec)
+		m.spilledEntries = mustRegisterOrGet(reg, m.spilledEntries).(*prometheus.CounterVec)
+		m.evictedEntries = mustRegisterOrGet(reg, m.evictedEntries).(*prometheus.CounterVec)
+		m.droppedEntries = mustRegisterOrGet(reg, m.droppedEntries).(*prometheus.CounterVec)
+	}
+
+	return &m
+}
+
+func mustRegisterOrGet(reg prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
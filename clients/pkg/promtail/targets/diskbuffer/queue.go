@@ -0,0 +1,271 @@
+package diskbuffer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	segmentSuffix = ".segment"
+	// recordHeaderSize is the size of the length prefixing every record.
+	recordHeaderSize = 4
+)
+
+var errRecordTooLarge = errors.New("entry larger than the disk buffer")
+
+// segment is a file holding length prefixed records.
+type segment struct {
+	id   uint64
+	path string
+	// file appends records to the last segment of the queue, reader reads the records
+	// of its first one. They are only open while needed.
+	file   *os.File
+	reader *os.File
+
+	size    int64
+	records int
+	// offset is the offset of the next record to read, read the number of records read.
+	offset int64
+	read   int
+}
+
+func (s *segment) unread() int {
+	return s.records - s.read
+}
+
+func (s *segment) close() {
+	if s.file != nil {
+		_ = s.file.Close()
+		s.file = nil
+	}
+	if s.reader != nil {
+		_ = s.reader.Close()
+		s.reader = nil
+	}
+}
+
+// queue is a FIFO queue of records written in segment files named after their sequence
+// number. Records are appended to the last segment and read from the first one, which is
+// deleted once read. When the queue is full, its oldest segments are evicted.
+//
+// Read positions are kept in memory only: the records of the first segment read before
+// a restart are read again.
+type queue struct {
+	dir         string
+	maxSize     int64
+	segmentSize int64
+
+	segments []*segment
+	size     int64
+	nextID   uint64
+}
+
+// openQueue opens the queue in dir, loading the segments left by a previous run. Records
+// partially written, e.g. when the process was killed, are truncated.
+func openQueue(dir string, maxSize, segmentSize int64) (*queue, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	q := &queue{dir: dir, maxSize: maxSize, segmentSize: segmentSize}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		s, err := loadSegment(filepath.Join(dir, f.Name()), id)
+		if err != nil {
+			return nil, fmt.Errorf("error loading disk buffer segment %s: %w", f.Name(), err)
+		}
+		if s.records == 0 {
+			_ = os.Remove(s.path)
+			continue
+		}
+		q.segments = append(q.segments, s)
+		q.size += s.size
+		if id >= q.nextID {
+			q.nextID = id + 1
+		}
+	}
+	sort.Slice(q.segments, func(i, j int) bool {
+		return q.segments[i].id < q.segments[j].id
+	})
+	return q, nil
+}
+
+func loadSegment(path string, id uint64) (*segment, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &segment{id: id, path: path}
+	header := make([]byte, recordHeaderSize)
+	for s.size+recordHeaderSize <= info.Size() {
+		if _, err := f.ReadAt(header, s.size); err != nil {
+			return nil, err
+		}
+		end := s.size + recordHeaderSize + int64(binary.BigEndian.Uint32(header))
+		if end > info.Size() {
+			break
+		}
+		s.size = end
+		s.records++
+	}
+	if s.size < info.Size() {
+		if err := f.Truncate(s.size); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// push appends a record to the queue, evicting the oldest segments if the queue would
+// exceed its maximum size. It returns the number of records evicted.
+func (q *queue) push(record []byte) (int, error) {
+	size := int64(recordHeaderSize + len(record))
+	if size > q.segmentSize {
+		return 0, errRecordTooLarge
+	}
+
+	last := q.last()
+	if last == nil || last.size+size > q.segmentSize {
+		if last != nil {
+			last.close()
+		}
+		last = &segment{id: q.nextID, path: filepath.Join(q.dir, fmt.Sprintf("%020d%s", q.nextID, segmentSuffix))}
+		q.nextID++
+		q.segments = append(q.segments, last)
+	}
+
+	evicted := 0
+	for q.size+size > q.maxSize && len(q.segments) > 1 {
+		evicted += q.segments[0].unread()
+		q.removeFirst()
+	}
+
+	if last.file == nil {
+		f, err := os.OpenFile(last.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			return evicted, err
+		}
+		last.file = f
+	}
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint32(buf, uint32(len(record)))
+	copy(buf[recordHeaderSize:], record)
+	if _, err := last.file.Write(buf); err != nil {
+		// drop what could have been written, for the segment to stay readable.
+		_ = last.file.Truncate(last.size)
+		return evicted, err
+	}
+	last.size += size
+	last.records++
+	q.size += size
+	return evicted, nil
+}
+
+// pop removes and returns the oldest record, false if the queue is empty. A segment which
+// can't be read is dropped with its remaining records, which are returned.
+func (q *queue) pop() ([]byte, bool, int, error) {
+	for len(q.segments) > 0 {
+		first := q.segments[0]
+		if first.unread() == 0 {
+			q.removeFirst()
+			continue
+		}
+		record, err := first.readNext()
+		if err != nil {
+			lost := first.unread()
+			q.removeFirst()
+			return nil, false, lost, fmt.Errorf("error reading disk buffer segment %s: %w", first.path, err)
+		}
+		if first.unread() == 0 && len(q.segments) > 1 {
+			q.removeFirst()
+		}
+		return record, true, 0, nil
+	}
+	return nil, false, 0, nil
+}
+
+func (s *segment) readNext() ([]byte, error) {
+	if s.reader == nil {
+		f, err := os.Open(s.path)
+		if err != nil {
+			return nil, err
+		}
+		s.reader = f
+	}
+	header := make([]byte, recordHeaderSize)
+	if _, err := s.reader.ReadAt(header, s.offset); err != nil {
+		return nil, err
+	}
+	record := make([]byte, binary.BigEndian.Uint32(header))
+	if n, err := s.reader.ReadAt(record, s.offset+recordHeaderSize); n < len(record) {
+		return nil, err
+	}
+	s.offset += recordHeaderSize + int64(len(record))
+	s.read++
+	return record, nil
+}
+
+// removeFirst deletes the first segment.
+func (q *queue) removeFirst() {
+	first := q.segments[0]
+	first.close()
+	_ = os.Remove(first.path)
+	q.size -= first.size
+	q.segments[0] = nil
+	q.segments = q.segments[1:]
+}
+
+func (q *queue) last() *segment {
+	if len(q.segments) == 0 {
+		return nil
+	}
+	return q.segments[len(q.segments)-1]
+}
+
+// empty returns whether all the records of the queue have been read.
+func (q *queue) empty() bool {
+	return q.len() == 0
+}
+
+// len returns the number of records not read yet.
+func (q *queue) len() int {
+	n := 0
+	for _, s := range q.segments {
+		n += s.unread()
+	}
+	return n
+}
+
+// close closes the files of the segments, the ones with records left are kept for the next run.
+func (q *queue) close() {
+	for _, s := range q.segments {
+		s.close()
+		if s.unread() == 0 {
+			_ = os.Remove(s.path)
+		}
+	}
+	q.segments = nil
+	q.size = 0
+}
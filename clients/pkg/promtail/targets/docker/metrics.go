@@ -0,0 +1,39 @@
+package docker
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds the metrics of the Docker targets.
+type Metrics struct {
+	// reg is the Registerer used to create this set of metrics.
+	reg prometheus.Registerer
+
+	entries *prometheus.CounterVec
+	errors  *prometheus.CounterVec
+}
+
+// NewMetrics creates a new set of metrics. Metrics will be registered to reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	var m Metrics
+	m.reg = reg
+
+	m.entries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "docker_target_entries_total",
+		Help:      "Total number of log lines read from Docker containers.",
+	}, []string{"container"})
+	m.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "docker_target_errors_total",
+		Help:      "Total number of errors while reading the logs of Docker containers.",
+	}, []string{"container"})
+
+	if reg != nil {
+		reg.MustRegister(m.entries, m.errors)
+	}
+	return &m
+}
+
+func (m *Metrics) deleteContainer(container string) {
+	m.entries.DeleteLabelValues(container)
+	m.errors.DeleteLabelValues(container)
+}
@@ -0,0 +1,82 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+
+	// frameHeaderSize is the size of the header of the frames of a multiplexed stream:
+	// the stream type, 3 zero bytes and the big endian size of the payload.
+	frameHeaderSize = 8
+)
+
+// readLines reads the log lines of a container and calls fn with the stream of each.
+// The logs of containers without a TTY are multiplexed, stdout and stderr frames are
+// interleaved. The logs of containers with a TTY are raw stdout lines.
+func readLines(r io.Reader, tty bool, fn func(stream string, line []byte) error) error {
+	if tty {
+		return scanLines(r, func(line []byte) error { return fn(streamStdout, line) })
+	}
+
+	br := bufio.NewReader(r)
+	header := make([]byte, frameHeaderSize)
+	var payload []byte
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var stream string
+		switch header[0] {
+		case 1:
+			stream = streamStdout
+		case 2:
+			stream = streamStderr
+		default:
+			return fmt.Errorf("unexpected stream type %d in multiplexed logs", header[0])
+		}
+		size := int(binary.BigEndian.Uint32(header[4:]))
+		if cap(payload) < size {
+			payload = make([]byte, size)
+		}
+		payload = payload[:size]
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return err
+		}
+		// a frame holds a single message, split it still in case it holds several lines.
+		for _, line := range bytes.Split(bytes.TrimSuffix(payload, []byte{'\n'}), []byte{'\n'}) {
+			if err := fn(stream, line); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func scanLines(r io.Reader, fn func(line []byte) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			// the lines of a TTY end with \r\n.
+			line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/prometheus/prometheus/util/strutil"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/util"
+)
+
+const (
+	labelKeyDockerContainerID          = "__meta_docker_container_id"
+	labelKeyDockerContainerName        = "__meta_docker_container_name"
+	labelKeyDockerContainerImage       = "__meta_docker_container_image"
+	labelKeyDockerContainerLabelPrefix = "__meta_docker_container_label_"
+	labelKeyDockerContainerLogStream   = "__meta_docker_container_log_stream"
+)
+
+// Target reads the stdout and stderr logs of a container through the Docker Engine API,
+// and stores the timestamp of the last line read in the positions file.
+type Target struct {
+	logger    log.Logger
+	metrics   *Metrics
+	handler   api.EntryHandler
+	client    dockerAPI
+	positions positions.Positions
+	config    *scrapeconfig.DockerTargetConfig
+
+	id          string
+	name        string
+	positionKey string
+
+	discoveredLabels model.LabelSet
+	// streamLabels are the labels of the entries of each stream, a stream dropped by the
+	// relabeling is missing.
+	streamLabels map[string]model.LabelSet
+
+	mtx       sync.Mutex
+	position  time.Time
+	lastError error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newTarget(
+	logger log.Logger,
+	metrics *Metrics,
+	handler api.EntryHandler,
+	client dockerAPI,
+	positions positions.Positions,
+	config *scrapeconfig.DockerTargetConfig,
+	jobName string,
+	container types.Container,
+	discoveredLabels model.LabelSet,
+	streamLabels map[string]model.LabelSet,
+) *Target {
+	ctx, cancel := context.WithCancel(context.Background())
+	name := containerName(container)
+	t := &Target{
+		logger:           log.With(logger, "container", name),
+		metrics:          metrics,
+		handler:          handler,
+		client:           client,
+		positions:        positions,
+		config:           config,
+		id:               container.ID,
+		name:             name,
+		positionKey:      positionKey(jobName, container.ID),
+		discoveredLabels: discoveredLabels,
+		streamLabels:     streamLabels,
+		ctx:              ctx,
+		cancel:           cancel,
+		done:             make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// positionKey is the key of the position of a container in the positions file.
+func positionKey(jobName, id string) string {
+	return fmt.Sprintf("docker-%s-%s", jobName, id)
+}
+
+func containerName(c types.Container) string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// containerLabels returns the labels discovered for a container.
+func containerLabels(c types.Container) model.LabelSet {
+	lbs := model.LabelSet{
+		labelKeyDockerContainerID:    model.LabelValue(c.ID),
+		labelKeyDockerContainerName:  model.LabelValue(containerName(c)),
+		labelKeyDockerContainerImage: model.LabelValue(c.Image),
+	}
+	for name, value := range c.Labels {
+		lbs[model.LabelName(labelKeyDockerContainerLabelPrefix+strutil.SanitizeLabelName(name))] = model.LabelValue(value)
+	}
+	return lbs
+}
+
+// streamLabels returns the labels of the entries of each stream of a container, a stream
+// dropped by the relabeling is missing.
+func streamLabels(discovered model.LabelSet, config *scrapeconfig.DockerTargetConfig, relabelConfig []*relabel.Config) map[string]model.LabelSet {
+	result := make(map[string]model.LabelSet, 2)
+	for _, stream := range []string{streamStdout, streamStderr} {
+		builder := labels.NewBuilder(nil)
+		for name, value := range discovered {
+			builder.Set(string(name), string(value))
+		}
+		builder.Set(labelKeyDockerContainerLogStream, stream)
+		for name, value := range config.Labels {
+			builder.Set(string(name), string(value))
+		}
+		processed := relabel.Process(builder.Labels(), relabelConfig...)
+		if processed == nil {
+			continue
+		}
+		out := model.LabelSet(util.LabelsToMetric(processed))
+		for name := range out {
+			if strings.HasPrefix(string(name), "__") {
+				delete(out, name)
+			}
+		}
+		if len(out) == 0 {
+			continue
+		}
+		result[stream] = out
+	}
+	return result
+}
+
+// run reads the logs of the container until the target is stopped. Reads are started
+// again from the last position once the logs end or on errors.
+func (t *Target) run() {
+	defer close(t.done)
+	for t.ctx.Err() == nil {
+		err := t.read()
+		if t.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			level.Warn(t.logger).Log("msg", "error reading container logs, starting again from the last position", "err", err)
+			t.metrics.errors.WithLabelValues(t.name).Inc()
+			t.mtx.Lock()
+			t.lastError = err
+			t.mtx.Unlock()
+		}
+		t.wait(time.Duration(t.config.RefreshInterval))
+	}
+}
+
+func (t *Target) read() error {
+	info, err := t.client.ContainerInspect(t.ctx, t.id)
+	if err != nil {
+		return err
+	}
+	tty := info.Config != nil && info.Config.Tty
+
+	opts := types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Timestamps: true,
+	}
+	pos, err := t.positions.Get(t.positionKey)
+	if err != nil {
+		return err
+	}
+	if pos > 0 {
+		// since is inclusive, start right after the last line read.
+		pos++
+		opts.Since = fmt.Sprintf("%d.%09d", pos/int64(time.Second), pos%int64(time.Second))
+		t.mtx.Lock()
+		t.position = time.Unix(0, pos)
+		t.mtx.Unlock()
+	}
+
+	logs, err := t.client.ContainerLogs(t.ctx, t.id, opts)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	return readLines(logs, tty, t.handle)
+}
+
+// handle pushes a line prefixed by its timestamp.
+func (t *Target) handle(stream string, line []byte) error {
+	ts := time.Now()
+	if i := bytes.IndexByte(line, ' '); i > 0 {
+		if parsed, err := time.Parse(time.RFC3339Nano, string(line[:i])); err == nil {
+			ts, line = parsed, line[i+1:]
+		}
+	}
+	lbs, ok := t.streamLabels[stream]
+	if ok {
+		entry := api.Entry{Labels: lbs.Clone(), Entry: logproto.Entry{Timestamp: ts, Line: string(line)}}
+		select {
+		case t.handler.Chan() <- entry:
+		case <-t.ctx.Done():
+			return t.ctx.Err()
+		}
+		t.metrics.entries.WithLabelValues(t.name).Inc()
+	}
+	t.positions.Put(t.positionKey, ts.UnixNano())
+	t.mtx.Lock()
+	t.position = ts
+	t.lastError = nil
+	t.mtx.Unlock()
+	return nil
+}
+
+// wait waits for d, it returns false if the target has been stopped meanwhile.
+func (t *Target) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-t.ctx.Done():
+		return false
+	}
+}
+
+// Stop stops reading the logs of the container.
+func (t *Target) Stop() {
+	t.cancel()
+	<-t.done
+	t.metrics.deleteContainer(t.name)
+}
+
+// Type implements target.Target.
+func (t *Target) Type() target.TargetType {
+	return target.DockerTargetType
+}
+
+// Ready implements target.Target.
+func (t *Target) Ready() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.lastError == nil
+}
+
+// DiscoveredLabels implements target.Target.
+func (t *Target) DiscoveredLabels() model.LabelSet {
+	return t.discoveredLabels
+}
+
+// Labels implements target.Target.
+func (t *Target) Labels() model.LabelSet {
+	return t.config.Labels
+}
+
+// Details implements target.Target.
+func (t *Target) Details() interface{} {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	details := map[string]string{
+		"id":   t.id,
+		"name": t.name,
+	}
+	if !t.position.IsZero() {
+		details["position"] = t.position.Format(time.RFC3339Nano)
+	}
+	if t.lastError != nil {
+		details["error"] = t.lastError.Error()
+	}
+	return details
+}
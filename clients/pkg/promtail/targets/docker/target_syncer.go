@@ -0,0 +1,245 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/clients/pkg/logentry/stages"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+)
+
+const (
+	defaultHost            = "unix:///var/run/docker.sock"
+	defaultRefreshInterval = 10 * time.Second
+)
+
+// dockerAPI is the part of the Docker Engine API used by the targets.
+type dockerAPI interface {
+	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	ContainerLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error)
+}
+
+// TargetSyncer reads the logs of the running containers of a Docker daemon with a target
+// per container. Containers are listed periodically.
+type TargetSyncer struct {
+	logger    log.Logger
+	metrics   *Metrics
+	cfg       scrapeconfig.Config
+	handler   api.EntryHandler
+	client    dockerAPI
+	positions positions.Positions
+	filters   filters.Args
+
+	mtx     sync.Mutex
+	targets map[string]*Target
+	dropped map[string]target.Target
+	// stopped are the containers whose logs were read and which stopped, their position
+	// is removed once they are removed.
+	stopped map[string]struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewSyncer creates a TargetSyncer reading the containers of a scrape config.
+func NewSyncer(
+	metrics *Metrics,
+	logger log.Logger,
+	positions positions.Positions,
+	cfg scrapeconfig.Config,
+	pushClient api.EntryHandler,
+) (*TargetSyncer, error) {
+	if err := validateConfig(&cfg); err != nil {
+		return nil, err
+	}
+	c, err := client.NewClientWithOpts(client.WithHost(cfg.DockerConfig.Host), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("error creating Docker client: %w", err)
+	}
+	return newSyncer(metrics, logger, positions, cfg, pushClient, c)
+}
+
+func newSyncer(
+	metrics *Metrics,
+	logger log.Logger,
+	positions positions.Positions,
+	cfg scrapeconfig.Config,
+	pushClient api.EntryHandler,
+	client dockerAPI,
+) (*TargetSyncer, error) {
+	logger = log.With(logger, "host", cfg.DockerConfig.Host)
+	pipeline, err := stages.NewPipeline(log.With(logger, "component", "docker_pipeline"), cfg.PipelineStages, &cfg.JobName, metrics.reg)
+	if err != nil {
+		return nil, err
+	}
+	args := filters.NewArgs()
+	for _, f := range cfg.DockerConfig.Filters {
+		for _, v := range f.Values {
+			args.Add(f.Name, v)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := &TargetSyncer{
+		logger:    logger,
+		metrics:   metrics,
+		cfg:       cfg,
+		handler:   pipeline.Wrap(pushClient),
+		client:    client,
+		positions: positions,
+		filters:   args,
+		targets:   make(map[string]*Target),
+		dropped:   make(map[string]target.Target),
+		stopped:   make(map[string]struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	ts.wg.Add(1)
+	go ts.loop()
+	return ts, nil
+}
+
+func validateConfig(cfg *scrapeconfig.Config) error {
+	if cfg.DockerConfig == nil {
+		return errors.New("Docker configuration is empty")
+	}
+	if cfg.DockerConfig.Host == "" {
+		cfg.DockerConfig.Host = defaultHost
+	}
+	if cfg.DockerConfig.RefreshInterval < 0 {
+		return errors.New("refresh interval must not be negative")
+	}
+	if cfg.DockerConfig.RefreshInterval == 0 {
+		cfg.DockerConfig.RefreshInterval = model.Duration(defaultRefreshInterval)
+	}
+	for _, f := range cfg.DockerConfig.Filters {
+		if f.Name == "" {
+			return errors.New("Docker filters must have a name")
+		}
+	}
+	return nil
+}
+
+func (ts *TargetSyncer) loop() {
+	defer ts.wg.Done()
+	ticker := time.NewTicker(time.Duration(ts.cfg.DockerConfig.RefreshInterval))
+	defer ticker.Stop()
+	for {
+		if err := ts.sync(); err != nil && ts.ctx.Err() == nil {
+			level.Warn(ts.logger).Log("msg", "error listing containers", "err", err)
+		}
+		select {
+		case <-ts.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync starts the targets of the containers which started and stops the targets of
+// the containers which stopped.
+func (ts *TargetSyncer) sync() error {
+	containers, err := ts.client.ContainerList(ts.ctx, types.ContainerListOptions{All: true, Filters: ts.filters})
+	if err != nil {
+		return err
+	}
+	listed := make(map[string]struct{}, len(containers))
+	running := make(map[string]struct{}, len(containers))
+
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	for _, c := range containers {
+		listed[c.ID] = struct{}{}
+		if c.State != "running" {
+			continue
+		}
+		running[c.ID] = struct{}{}
+		if _, ok := ts.targets[c.ID]; ok {
+			continue
+		}
+		if _, ok := ts.dropped[c.ID]; ok {
+			continue
+		}
+		discovered := containerLabels(c)
+		lbs := streamLabels(discovered, ts.cfg.DockerConfig, ts.cfg.RelabelConfigs)
+		if len(lbs) == 0 {
+			ts.dropped[c.ID] = target.NewDroppedTarget("dropping target, no labels", discovered)
+			continue
+		}
+		level.Info(ts.logger).Log("msg", "reading container logs", "container", containerName(c))
+		ts.targets[c.ID] = newTarget(ts.logger, ts.metrics, ts.handler, ts.client, ts.positions, ts.cfg.DockerConfig, ts.cfg.JobName, c, discovered, lbs)
+		delete(ts.stopped, c.ID)
+	}
+	for id, t := range ts.targets {
+		if _, ok := running[id]; ok {
+			continue
+		}
+		level.Info(ts.logger).Log("msg", "container has stopped", "container", t.name)
+		t.Stop()
+		delete(ts.targets, id)
+		ts.stopped[id] = struct{}{}
+	}
+	for id := range ts.dropped {
+		if _, ok := running[id]; !ok {
+			delete(ts.dropped, id)
+		}
+	}
+	for id := range ts.stopped {
+		if _, ok := listed[id]; !ok {
+			ts.positions.Remove(positionKey(ts.cfg.JobName, id))
+			delete(ts.stopped, id)
+		}
+	}
+	return nil
+}
+
+func (ts *TargetSyncer) getActiveTargets() []target.Target {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	result := make([]target.Target, 0, len(ts.targets))
+	for _, t := range ts.targets {
+		result = append(result, t)
+	}
+	return result
+}
+
+func (ts *TargetSyncer) getAllTargets() []target.Target {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	result := make([]target.Target, 0, len(ts.targets)+len(ts.dropped))
+	for _, t := range ts.targets {
+		result = append(result, t)
+	}
+	for _, t := range ts.dropped {
+		result = append(result, t)
+	}
+	return result
+}
+
+// Stop stops reading the logs of the containers.
+func (ts *TargetSyncer) Stop() {
+	ts.cancel()
+	ts.wg.Wait()
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	for id, t := range ts.targets {
+		t.Stop()
+		delete(ts.targets, id)
+	}
+	ts.handler.Stop()
+}
@@ -0,0 +1,204 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/clients/pkg/promtail/client/fake"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+type logLine struct {
+	stream byte
+	ts     time.Time
+	line   string
+}
+
+// fakeDocker is an in-memory Docker daemon, its logs end instead of being followed.
+type fakeDocker struct {
+	mtx        sync.Mutex
+	containers []types.Container
+	logs       map[string][]logLine
+	sinces     []string
+}
+
+func (f *fakeDocker) setContainers(containers ...types.Container) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.containers = containers
+}
+
+func (f *fakeDocker) log(id string, stream byte, ts time.Time, line string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.logs[id] = append(f.logs[id], logLine{stream: stream, ts: ts, line: line})
+}
+
+func (f *fakeDocker) ContainerList(_ context.Context, _ types.ContainerListOptions) ([]types.Container, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return append([]types.Container(nil), f.containers...), nil
+}
+
+func (f *fakeDocker) ContainerInspect(_ context.Context, id string) (types.ContainerJSON, error) {
+	return types.ContainerJSON{Config: &container.Config{}}, nil
+}
+
+func (f *fakeDocker) ContainerLogs(_ context.Context, id string, opts types.ContainerLogsOptions) (io.ReadCloser, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	var since time.Time
+	if opts.Since != "" {
+		f.sinces = append(f.sinces, opts.Since)
+		parts := strings.SplitN(opts.Since, ".", 2)
+		sec, _ := strconv.ParseInt(parts[0], 10, 64)
+		nsec, _ := strconv.ParseInt(parts[1], 10, 64)
+		since = time.Unix(sec, nsec)
+	}
+	var buf bytes.Buffer
+	for _, l := range f.logs[id] {
+		if l.ts.Before(since) {
+			continue
+		}
+		writeFrame(&buf, l.stream, l.ts.Format(time.RFC3339Nano)+" "+l.line+"\n")
+	}
+	return io.NopCloser(&buf), nil
+}
+
+func writeFrame(w io.Writer, stream byte, payload string) {
+	header := make([]byte, frameHeaderSize)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	_, _ = w.Write(header)
+	_, _ = io.WriteString(w, payload)
+}
+
+func TestReadLines(t *testing.T) {
+	var buf bytes.Buffer
+	writeFrame(&buf, 1, "first\n")
+	writeFrame(&buf, 2, "oops\n")
+	writeFrame(&buf, 1, "partial")
+	var got []string
+	err := readLines(&buf, false, func(stream string, line []byte) error {
+		got = append(got, stream+":"+string(line))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"stdout:first", "stderr:oops", "stdout:partial"}, got)
+
+	got = nil
+	err = readLines(strings.NewReader("one\r\ntwo\r\nthree"), true, func(stream string, line []byte) error {
+		got = append(got, stream+":"+string(line))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"stdout:one", "stdout:two", "stdout:three"}, got)
+
+	buf.Reset()
+	writeFrame(&buf, 1, "ok\n")
+	buf.WriteString("\x01\x00\x00")
+	require.Error(t, readLines(&buf, false, func(string, []byte) error { return nil }))
+}
+
+func TestTargetSyncer(t *testing.T) {
+	ps, err := positions.New(log.NewNopLogger(), positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: filepath.Join(t.TempDir(), "positions.yml"),
+	})
+	require.NoError(t, err)
+	defer ps.Stop()
+
+	api := &fakeDocker{logs: map[string][]logLine{}}
+	web := types.Container{ID: "abc", Names: []string{"/web"}, Image: "nginx:1.21", State: "running", Labels: map[string]string{"com.example.team": "edge"}}
+	db := types.Container{ID: "def", Names: []string{"/db"}, Image: "postgres:14", State: "running", Labels: map[string]string{"promtail.ignore": "true"}}
+	api.setContainers(web, db)
+	start := time.Unix(1600000000, 0)
+	api.log("abc", 1, start, "GET / 200")
+	api.log("abc", 2, start.Add(time.Second), "upstream timed out")
+	api.log("def", 1, start, "checkpoint complete")
+
+	client := fake.New(func() {})
+	defer client.Stop()
+	cfg := scrapeconfig.Config{
+		JobName: "docker",
+		DockerConfig: &scrapeconfig.DockerTargetConfig{
+			RefreshInterval: model.Duration(10 * time.Millisecond),
+			Labels:          model.LabelSet{"job": "docker"},
+		},
+		RelabelConfigs: []*relabel.Config{
+			{
+				SourceLabels: model.LabelNames{labelKeyDockerContainerLabelPrefix + "promtail_ignore"},
+				Regex:        relabel.MustNewRegexp("true"),
+				Action:       relabel.Drop,
+			},
+			{
+				SourceLabels: model.LabelNames{labelKeyDockerContainerName},
+				TargetLabel:  "container",
+				Action:       relabel.Replace,
+				Regex:        relabel.MustNewRegexp("(.*)"),
+				Replacement:  "$1",
+			},
+			{
+				SourceLabels: model.LabelNames{labelKeyDockerContainerLogStream},
+				TargetLabel:  "stream",
+				Action:       relabel.Replace,
+				Regex:        relabel.MustNewRegexp("(.*)"),
+				Replacement:  "$1",
+			},
+		},
+	}
+	ts, err := newSyncer(NewMetrics(prometheus.NewRegistry()), log.NewNopLogger(), ps, cfg, client, api)
+	require.NoError(t, err)
+	defer ts.Stop()
+
+	require.Eventually(t, func() bool { return len(client.Received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	received := client.Received()
+	sort.Slice(received, func(i, j int) bool { return received[i].Timestamp.Before(received[j].Timestamp) })
+	require.Equal(t, "GET / 200", received[0].Line)
+	require.True(t, start.Equal(received[0].Timestamp))
+	require.Equal(t, model.LabelSet{"job": "docker", "container": "web", "stream": "stdout"}, received[0].Labels)
+	require.Equal(t, "upstream timed out", received[1].Line)
+	require.Equal(t, model.LabelSet{"job": "docker", "container": "web", "stream": "stderr"}, received[1].Labels)
+
+	require.Len(t, ts.getActiveTargets(), 1)
+	require.Len(t, ts.getAllTargets(), 2)
+	require.Eventually(t, func() bool {
+		pos, err := ps.Get(positionKey("docker", "abc"))
+		return err == nil && pos == start.Add(time.Second).UnixNano()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// reads start again right after the last line read.
+	api.log("abc", 1, start.Add(2*time.Second), "GET /health 200")
+	require.Eventually(t, func() bool { return len(client.Received()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Len(t, client.Received(), 3)
+	api.mtx.Lock()
+	require.Contains(t, api.sinces, "1600000001.000000001")
+	api.mtx.Unlock()
+
+	// stopped containers aren't read anymore, their position is removed once they are removed.
+	web.State = "exited"
+	api.setContainers(web, db)
+	require.Eventually(t, func() bool { return len(ts.getActiveTargets()) == 0 }, 5*time.Second, 10*time.Millisecond)
+	require.NotZero(t, ps.GetString(positionKey("docker", "abc")))
+	api.setContainers(db)
+	require.Eventually(t, func() bool { return ps.GetString(positionKey("docker", "abc")) == "" }, 5*time.Second, 10*time.Millisecond)
+}
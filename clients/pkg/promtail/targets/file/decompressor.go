@@ -0,0 +1,259 @@
+package file
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+// Compression formats of the files read by a decompressor.
+const (
+	compressionGzip  = "gz"
+	compressionBzip2 = "bz2"
+	compressionZstd  = "zst"
+)
+
+// compressionFormat returns the compression format of a file from its extension, or an
+// empty string if the file is not compressed.
+func compressionFormat(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return compressionGzip
+	case strings.HasSuffix(path, ".bz2"):
+		return compressionBzip2
+	case strings.HasSuffix(path, ".zst"):
+		return compressionZstd
+	default:
+		return ""
+	}
+}
+
+// decompressor reads the lines of a compressed file once, from the uncompressed offset saved
+// in the positions file. Compressed files are rotated or backfilled logs which are complete,
+// so they are not followed: once the whole file is read the decompressor idles until it is
+// stopped.
+type decompressor struct {
+	metrics   *Metrics
+	logger    log.Logger
+	handler   api.EntryHandler
+	positions positions.Positions
+
+	path     string
+	format   string
+	position *atomic.Int64
+
+	stopOnce sync.Once
+
+	running *atomic.Bool
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+func newDecompressor(metrics *Metrics, logger log.Logger, handler api.EntryHandler, positions positions.Positions, path string, format string) (*decompressor, error) {
+	pos, err := positions.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	logger = log.With(logger, "component", "decompressor")
+	d := &decompressor{
+		metrics:   metrics,
+		logger:    logger,
+		handler:   api.AddLabelsMiddleware(model.LabelSet{FilenameLabel: model.LabelValue(path)}).Wrap(handler),
+		positions: positions,
+		path:      path,
+		format:    format,
+		position:  atomic.NewInt64(pos),
+		running:   atomic.NewBool(true),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go d.readLines()
+	metrics.filesActive.Add(1.)
+	return d, nil
+}
+
+// readLines runs in a goroutine and sends the lines of the file. If the file can't be read,
+// for instance because it is still being compressed, it exits: the decompressor is started
+// again by the next sync of the filetarget, from the last line sent.
+func (d *decompressor) readLines() {
+	level.Info(d.logger).Log("msg", "decompress routine: started", "path", d.path, "format", d.format, "position", d.position.Load())
+
+	defer func() {
+		d.savePosition()
+		d.cleanupMetrics()
+		d.running.Store(false)
+		level.Info(d.logger).Log("msg", "decompress routine: exited", "path", d.path)
+		close(d.done)
+	}()
+
+	err := d.readFile()
+	if err != nil {
+		level.Error(d.logger).Log("msg", "decompress routine: error reading file", "path", d.path, "error", err)
+		return
+	}
+	if err := d.markPositionAndSize(); err != nil {
+		level.Error(d.logger).Log("msg", "decompress routine: error marking file position", "path", d.path, "error", err)
+	}
+	level.Info(d.logger).Log("msg", "decompress routine: file read", "path", d.path, "position", d.position.Load())
+	<-d.quit
+}
+
+// readFile sends the lines of the file from the current position. It returns nil once the
+// whole file is read or the decompressor is stopped.
+func (d *decompressor) readFile() error {
+	f, err := os.Open(d.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := d.open(f)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	pos := d.position.Load()
+	skipped, err := io.CopyN(ioutil.Discard, r, pos)
+	if err == io.EOF {
+		// The position is past the end of the file, which was replaced: read it from the start.
+		level.Info(d.logger).Log("msg", "position is past the end of the file, reading it from the start", "path", d.path, "position", pos, "size", skipped)
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if r, err = d.open(f); err != nil {
+			return err
+		}
+		defer r.Close()
+		d.position.Store(0)
+	} else if err != nil {
+		return errors.Wrap(err, "skipping to the position")
+	}
+
+	entries := d.handler.Chan()
+	br := bufio.NewReader(r)
+	for {
+		text, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			// The line may be incomplete, it is read again from its start.
+			return err
+		}
+		if text == "" {
+			return nil
+		}
+
+		line := strings.TrimRight(text, "\r\n")
+		d.metrics.readLines.WithLabelValues(d.path).Inc()
+		d.metrics.logLengthHistogram.WithLabelValues(d.path).Observe(float64(len(line)))
+		select {
+		case entries <- api.Entry{
+			Labels: model.LabelSet{},
+			Entry: logproto.Entry{
+				Timestamp: time.Now(),
+				Line:      line,
+			},
+		}:
+		case <-d.quit:
+			return nil
+		}
+		d.position.Add(int64(len(text)))
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// open returns the reader decompressing the file.
+func (d *decompressor) open(f io.Reader) (io.ReadCloser, error) {
+	switch d.format {
+	case compressionGzip:
+		return gzip.NewReader(f)
+	case compressionBzip2:
+		return ioutil.NopCloser(bzip2.NewReader(f)), nil
+	case compressionZstd:
+		zr, err := zstd.NewReader(f, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, errors.Errorf("unsupported compression format %q", d.format)
+	}
+}
+
+// markPositionAndSize saves the uncompressed position of the last line sent. The size is the
+// size of the compressed file on disk.
+func (d *decompressor) markPositionAndSize() error {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		// If the file no longer exists, no need to save position information
+		if os.IsNotExist(err) {
+			level.Info(d.logger).Log("msg", "skipping update of position for a file which does not currently exist", "path", d.path)
+			return nil
+		}
+		return err
+	}
+	d.metrics.totalBytes.WithLabelValues(d.path).Set(float64(fi.Size()))
+
+	pos := d.position.Load()
+	d.metrics.readBytes.WithLabelValues(d.path).Set(float64(pos))
+	d.positions.Put(d.path, pos)
+	return nil
+}
+
+// savePosition saves the uncompressed position of the last line sent, unless the file no
+// longer exists.
+func (d *decompressor) savePosition() {
+	if _, err := os.Stat(d.path); os.IsNotExist(err) {
+		return
+	}
+	d.positions.Put(d.path, d.position.Load())
+}
+
+func (d *decompressor) stop() {
+	d.stopOnce.Do(func() {
+		// readLines saves the current position when exiting
+		close(d.quit)
+		<-d.done
+		level.Info(d.logger).Log("msg", "stopped decompressing file", "path", d.path)
+		d.handler.Stop()
+	})
+}
+
+func (d *decompressor) isRunning() bool {
+	return d.running.Load()
+}
+
+// watchLimitReached is always false, compressed files are not watched.
+func (d *decompressor) watchLimitReached() bool {
+	return false
+}
+
+// cleanupMetrics removes all metrics exported by this decompressor
+func (d *decompressor) cleanupMetrics() {
+	d.metrics.filesActive.Add(-1.)
+	d.metrics.readLines.DeleteLabelValues(d.path)
+	d.metrics.readBytes.DeleteLabelValues(d.path)
+	d.metrics.totalBytes.DeleteLabelValues(d.path)
+	d.metrics.logLengthHistogram.DeleteLabelValues(d.path)
+}
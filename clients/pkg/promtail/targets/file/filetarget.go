@@ -16,24 +16,50 @@ import (
 	"github.com/grafana/loki/clients/pkg/promtail/api"
 	"github.com/grafana/loki/clients/pkg/promtail/client"
 	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+
+	lokiflag "github.com/grafana/loki/pkg/util/flagext"
 )
 
 const (
 	FilenameLabel = "filename"
 )
 
+// WatchMethod values, how the changes of the tailed files are detected.
+const (
+	// WatchMethodPoll checks the size and modification time of tailed files at a short interval.
+	WatchMethodPoll = "poll"
+	// WatchMethodNotify waits for the change notifications of the operating system: inotify,
+	// kqueue or ReadDirectoryChangesW.
+	WatchMethodNotify = "notify"
+)
+
 // Config describes behavior for Target
 type Config struct {
-	SyncPeriod time.Duration `yaml:"sync_period"`
-	Stdin      bool          `yaml:"stdin"`
+	SyncPeriod     time.Duration     `yaml:"sync_period"`
+	WatchMethod    string            `yaml:"watch_method"`
+	Stdin          bool              `yaml:"stdin"`
+	StdinFormat    string            `yaml:"stdin_format"`
+	StdinLabels    lokiflag.LabelSet `yaml:"stdin_labels"`
+	StdinKeepAlive bool              `yaml:"stdin_keep_alive"`
+	FollowSymlinks bool              `yaml:"follow_symlinks"`
+	// StartPosition is where the files without a saved position are read from, the start_position
+	// of a scrape config overrides it.
+	StartPosition string `yaml:"start_position"`
 }
 
 // RegisterFlags with prefix registers flags where every name is prefixed by
 // prefix. If prefix is a non-empty string, prefix should end with a period.
 func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.DurationVar(&cfg.SyncPeriod, prefix+"target.sync-period", 10*time.Second, "Period to resync directories being watched and files being tailed.")
+	f.StringVar(&cfg.WatchMethod, prefix+"target.watch-method", defaultWatchMethod, "How changes of the files being tailed are detected: by polling them (poll) or with the change notifications of the operating system (notify). Defaults to notify on Windows and poll elsewhere.")
 	f.BoolVar(&cfg.Stdin, prefix+"stdin", false, "Set to true to pipe logs to promtail.")
+	f.StringVar(&cfg.StdinFormat, prefix+"stdin.format", "raw", "Format of the logs piped to promtail, either raw lines or ndjson records with line, timestamp and labels fields.")
+	f.Var(&cfg.StdinLabels, prefix+"stdin.labels", "list of labels to add to each log piped to promtail (e.g: --stdin.labels=lb1=v1,lb2=v2)")
+	f.BoolVar(&cfg.StdinKeepAlive, prefix+"stdin.keep-alive", false, "Keep promtail running once all logs piped to it have been read, instead of shutting down.")
+	f.BoolVar(&cfg.FollowSymlinks, prefix+"target.follow-symlinks", true, "Follow symlinks to files and directories when matching __path__. Symlinks leading back to a directory being walked are never followed.")
+	f.StringVar(&cfg.StartPosition, prefix+"target.start-position", scrapeconfig.StartPositionPositions, "Where the files without a saved position are read from: their beginning (beginning, positions) or, for the files found when their target starts, their end (end).")
 }
 
 // RegisterFlags register flags.
@@ -53,6 +79,15 @@ type fileTargetEvent struct {
 	eventType fileTargetEventType
 }
 
+// reader reads the lines of a file matched by the target: a tailer follows a plain file, a
+// decompressor reads a compressed file once.
+type reader interface {
+	stop()
+	isRunning() bool
+	watchLimitReached() bool
+	markPositionAndSize() error
+}
+
 // FileTarget describes a particular set of logs.
 // nolint:revive
 type FileTarget struct {
@@ -68,12 +103,19 @@ type FileTarget struct {
 	targetEventHandler chan fileTargetEvent
 	watches            map[string]struct{}
 	path               string
+	pathExclude        string
+	globber            globber
 	quit               chan struct{}
 	done               chan struct{}
 
-	tails map[string]*tailer
+	tails map[string]reader
+	// polled are the files polled because they could not be watched, the inotify
+	// watches being exhausted.
+	polled map[string]struct{}
 
 	targetConfig *Config
+	// synced is set once the files found when the target starts are tailed.
+	synced bool
 }
 
 // NewFileTarget create a new FileTarget.
@@ -83,6 +125,7 @@ func NewFileTarget(
 	handler api.EntryHandler,
 	positions positions.Positions,
 	path string,
+	pathExclude string,
 	labels model.LabelSet,
 	discoveredLabels model.LabelSet,
 	targetConfig *Config,
@@ -93,22 +136,31 @@ func NewFileTarget(
 		logger:             logger,
 		metrics:            metrics,
 		path:               path,
+		pathExclude:        pathExclude,
 		labels:             labels,
 		discoveredLabels:   discoveredLabels,
 		handler:            api.AddLabelsMiddleware(labels).Wrap(handler),
 		positions:          positions,
 		quit:               make(chan struct{}),
 		done:               make(chan struct{}),
-		tails:              map[string]*tailer{},
+		tails:              map[string]reader{},
+		polled:             map[string]struct{}{},
 		targetConfig:       targetConfig,
 		fileEventWatcher:   fileEventWatcher,
 		targetEventHandler: targetEventHandler,
 	}
+	t.globber = globber{
+		followSymlinks: targetConfig.FollowSymlinks,
+		onLoop: func(path string) {
+			level.Debug(logger).Log("msg", "not following symlink to a parent directory", "path", path)
+		},
+	}
 
 	err := t.sync()
 	if err != nil {
 		return nil, errors.Wrap(err, "filetarget.sync")
 	}
+	t.synced = true
 
 	go t.run()
 	return t, nil
@@ -150,11 +202,30 @@ func (t *FileTarget) Details() interface{} {
 	return files
 }
 
+// Lag implements target.LagTarget, it returns the bytes of the files not read yet.
+func (t *FileTarget) Lag() map[string]int64 {
+	files := map[string]int64{}
+	for fileName := range t.tails {
+		fi, err := os.Stat(fileName)
+		if err != nil {
+			continue
+		}
+		pos, _ := t.positions.Get(fileName)
+		if lag := fi.Size() - pos; lag > 0 {
+			files[fileName] = lag
+		} else {
+			files[fileName] = 0
+		}
+	}
+	return files
+}
+
 func (t *FileTarget) run() {
 	defer func() {
 		for _, v := range t.tails {
 			v.stop()
 		}
+		t.metrics.filesPolled.Sub(float64(len(t.polled)))
 		level.Info(t.logger).Log("msg", "filetarget: watcher closed, tailer stopped, positions saved", "path", t.path)
 		close(t.done)
 	}()
@@ -166,7 +237,12 @@ func (t *FileTarget) run() {
 		case event := <-t.fileEventWatcher:
 			switch event.Op {
 			case fsnotify.Create:
-				t.startTailing([]string{event.Name})
+				matches, err := t.filter([]string{event.Name})
+				if err != nil {
+					level.Error(t.logger).Log("msg", "failed to filter new file", "error", err, "filename", event.Name)
+					continue
+				}
+				t.startTailing(matches)
 			default:
 				// No-op we only care about Create events
 			}
@@ -184,7 +260,7 @@ func (t *FileTarget) run() {
 func (t *FileTarget) sync() error {
 
 	// Gets current list of files to tail.
-	matches, err := doublestar.Glob(t.path)
+	matches, err := t.globber.glob(t.path)
 	if err != nil {
 		return errors.Wrap(err, "filetarget.sync.filepath.Glob")
 	}
@@ -204,6 +280,11 @@ func (t *FileTarget) sync() error {
 		}
 	}
 
+	matches, err = t.filter(matches)
+	if err != nil {
+		return errors.Wrap(err, "filetarget.sync.filter")
+	}
+
 	// Record the size of all the files matched by the Glob pattern.
 	t.reportSize(matches)
 
@@ -238,6 +319,31 @@ func (t *FileTarget) sync() error {
 	return nil
 }
 
+// filter removes the files matching the exclude pattern of the target,
+// and the symlinks if they must not be followed.
+func (t *FileTarget) filter(ps []string) ([]string, error) {
+	filtered := ps[:0]
+	for _, p := range ps {
+		if t.pathExclude != "" {
+			excluded, err := doublestar.PathMatch(t.pathExclude, p)
+			if err != nil {
+				return nil, err
+			}
+			if excluded {
+				continue
+			}
+		}
+		if !t.targetConfig.FollowSymlinks {
+			fi, err := os.Lstat(p)
+			if err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				continue
+			}
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered, nil
+}
+
 func (t *FileTarget) startWatching(dirs map[string]struct{}) {
 	for dir := range dirs {
 		if _, ok := t.watches[dir]; ok {
@@ -278,25 +384,46 @@ func (t *FileTarget) startTailing(ps []string) {
 			level.Error(t.logger).Log("msg", "failed to tail file", "error", "file is a directory", "filename", p)
 			continue
 		}
-		level.Debug(t.logger).Log("msg", "tailing new file", "filename", p)
-		tailer, err := newTailer(t.metrics, t.logger, t.handler, t.positions, p)
+		watchMethod := t.targetConfig.WatchMethod
+		if _, ok := t.polled[p]; ok {
+			watchMethod = WatchMethodPoll
+		}
+		var r reader
+		if format := compressionFormat(p); format != "" {
+			level.Debug(t.logger).Log("msg", "decompressing new file", "filename", p, "format", format)
+			r, err = newDecompressor(t.metrics, t.logger, t.handler, t.positions, p, format)
+		} else {
+			level.Debug(t.logger).Log("msg", "tailing new file", "filename", p)
+			r, err = newTailer(t.metrics, t.logger, t.handler, t.positions, p, watchMethod, t.fromEnd(p))
+		}
 		if err != nil {
 			level.Error(t.logger).Log("msg", "failed to start tailer", "error", err, "filename", p)
 			continue
 		}
-		t.tails[p] = tailer
+		t.tails[p] = r
 	}
 }
 
+// fromEnd returns whether the file is read from its end: only when the target starts at the end
+// and the file, without a saved position, is found when the target starts. The files created
+// afterwards are read from their beginning not to miss the lines written before they are tailed.
+func (t *FileTarget) fromEnd(path string) bool {
+	return t.targetConfig.StartPosition == scrapeconfig.StartPositionEnd && !t.synced && t.positions.GetString(path) == ""
+}
+
 // stopTailingAndRemovePosition will stop the tailer and remove the positions entry.
 // Call this when a file no longer exists and you want to remove all traces of it.
 func (t *FileTarget) stopTailingAndRemovePosition(ps []string) {
 	for _, p := range ps {
 		if tailer, ok := t.tails[p]; ok {
 			tailer.stop()
-			t.positions.Remove(tailer.path)
+			t.positions.Remove(p)
 			delete(t.tails, p)
 		}
+		if _, ok := t.polled[p]; ok {
+			delete(t.polled, p)
+			t.metrics.filesPolled.Dec()
+		}
 		if h, ok := t.handler.(api.InstrumentedEntryHandler); ok {
 			h.UnregisterLatencyMetric(model.LabelSet{model.LabelName(client.LatencyLabel): model.LabelValue(p)})
 		}
@@ -307,8 +434,15 @@ func (t *FileTarget) stopTailingAndRemovePosition(ps []string) {
 // the list of active tailers. This allows them to be restarted if there were errors.
 func (t *FileTarget) pruneStoppedTailers() {
 	toRemove := make([]string, 0, len(t.tails))
-	for k, t := range t.tails {
-		if !t.isRunning() {
+	for k, tailer := range t.tails {
+		if !tailer.isRunning() {
+			if tailer.watchLimitReached() {
+				// The file is tailed again from its position at the next sync, polling it.
+				level.Warn(t.logger).Log("msg", "inotify watches exhausted, polling the file instead, raise fs.inotify.max_user_watches to watch it", "filename", k)
+				t.metrics.watchLimitReached.WithLabelValues("file").Inc()
+				t.polled[k] = struct{}{}
+				t.metrics.filesPolled.Inc()
+			}
 			toRemove = append(toRemove, k)
 		}
 	}
@@ -317,7 +451,7 @@ func (t *FileTarget) pruneStoppedTailers() {
 	}
 }
 
-func toStopTailing(nt []string, et map[string]*tailer) []string {
+func toStopTailing(nt []string, et map[string]reader) []string {
 	// Make a set of all existing tails
 	existingTails := make(map[string]struct{}, len(et))
 	for file := range et {
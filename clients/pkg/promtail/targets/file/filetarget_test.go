@@ -1,12 +1,16 @@
 package file
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"syscall"
 	"testing"
 	"time"
 
@@ -14,10 +18,14 @@ import (
 	"gopkg.in/fsnotify.v1"
 
 	"github.com/go-kit/log"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 
 	"github.com/grafana/loki/clients/pkg/promtail/client/fake"
 	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/testutils"
 )
 
@@ -98,7 +106,7 @@ func TestLongPositionsSyncDelayStillSavesCorrectPosition(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	target, err := NewFileTarget(metrics, logger, client, ps, logFile, nil, nil, &Config{
+	target, err := NewFileTarget(metrics, logger, client, ps, logFile, "", nil, nil, &Config{
 		SyncPeriod: 10 * time.Second,
 	}, fileWatcher, eventHandler)
 	if err != nil {
@@ -197,7 +205,7 @@ func TestWatchEntireDirectory(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	target, err := NewFileTarget(metrics, logger, client, ps, path, nil, nil, &Config{
+	target, err := NewFileTarget(metrics, logger, client, ps, path, "", nil, nil, &Config{
 		SyncPeriod: 10 * time.Second,
 	}, fileWatcher, eventHandler)
 	if err != nil {
@@ -252,6 +260,14 @@ func TestWatchEntireDirectory(t *testing.T) {
 }
 
 func TestFileRolls(t *testing.T) {
+	for _, watchMethod := range []string{WatchMethodPoll, WatchMethodNotify} {
+		t.Run(watchMethod, func(t *testing.T) {
+			testFileRolls(t, watchMethod)
+		})
+	}
+}
+
+func testFileRolls(t *testing.T, watchMethod string) {
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
 
@@ -292,8 +308,9 @@ func TestFileRolls(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	target, err := NewFileTarget(metrics, logger, client, positions, path, nil, nil, &Config{
-		SyncPeriod: 10 * time.Second,
+	target, err := NewFileTarget(metrics, logger, client, positions, path, "", nil, nil, &Config{
+		SyncPeriod:  10 * time.Second,
+		WatchMethod: watchMethod,
 	}, fileWatcher, eventHandler)
 	if err != nil {
 		t.Fatal(err)
@@ -396,7 +413,7 @@ func TestResumesWhereLeftOff(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	target, err := NewFileTarget(metrics, logger, client, ps, path, nil, nil, &Config{
+	target, err := NewFileTarget(metrics, logger, client, ps, path, "", nil, nil, &Config{
 		SyncPeriod: 10 * time.Second,
 	}, fileWatcher, eventHandler)
 	if err != nil {
@@ -430,7 +447,7 @@ func TestResumesWhereLeftOff(t *testing.T) {
 	}
 
 	// Create a new target, keep the same client so we can track what was sent through the handler.
-	target2, err := NewFileTarget(metrics, logger, client, ps2, dirName+"/*.log", nil, nil, &Config{
+	target2, err := NewFileTarget(metrics, logger, client, ps2, dirName+"/*.log", "", nil, nil, &Config{
 		SyncPeriod: 10 * time.Second,
 	}, fileWatcher, eventHandler)
 	if err != nil {
@@ -511,7 +528,7 @@ func TestGlobWithMultipleFiles(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	target, err := NewFileTarget(metrics, logger, client, ps, path, nil, nil, &Config{
+	target, err := NewFileTarget(metrics, logger, client, ps, path, "", nil, nil, &Config{
 		SyncPeriod: 10 * time.Second,
 	}, fileWatcher, eventHandler)
 	if err != nil {
@@ -615,7 +632,7 @@ func TestFileTargetSync(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	target, err := NewFileTarget(metrics, logger, client, ps, path, nil, nil, &Config{
+	target, err := NewFileTarget(metrics, logger, client, ps, path, "", nil, nil, &Config{
 		SyncPeriod: 10 * time.Second,
 	}, fileWatcher, eventHandler)
 	if err != nil {
@@ -707,9 +724,195 @@ func TestFileTargetSync(t *testing.T) {
 
 }
 
+func TestFileTargetWatchLimitFallback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("inotify watches are only exhausted on Linux")
+	}
+	logger := log.NewNopLogger()
+	dirName := t.TempDir()
+	logFile := dirName + "/test.log"
+	require.NoError(t, ioutil.WriteFile(logFile, nil, 0600))
+
+	ps, err := positions.New(logger, positions.Config{
+		SyncPeriod:    10 * time.Minute,
+		PositionsFile: dirName + "/positions.yml",
+	})
+	require.NoError(t, err)
+	defer ps.Stop()
+	client := fake.New(func() {})
+	defer client.Stop()
+
+	metrics := NewMetrics(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fileWatcher, eventHandler, err := createWatchers(ctx, logFile)
+	require.NoError(t, err)
+	target, err := NewFileTarget(metrics, logger, client, ps, logFile, "", nil, nil, &Config{
+		SyncPeriod:  10 * time.Minute,
+		WatchMethod: WatchMethodNotify,
+	}, fileWatcher, eventHandler)
+	require.NoError(t, err)
+	defer target.Stop()
+
+	// The tailer stops when its file can't be watched.
+	tailer := target.tails[logFile].(*tailer)
+	tailer.tail.Kill(syscall.ENOSPC)
+	require.Eventually(t, func() bool { return !tailer.isRunning() }, 5*time.Second, 10*time.Millisecond)
+
+	// The file is then polled.
+	require.NoError(t, target.sync())
+	require.Contains(t, target.polled, logFile)
+	require.Eventually(t, func() bool { return target.tails[logFile].isRunning() }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.filesPolled))
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.watchLimitReached.WithLabelValues("file")))
+
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString("polled\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Eventually(t, func() bool { return len(client.Received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.Remove(logFile))
+	require.NoError(t, target.sync())
+	require.Empty(t, target.polled)
+	require.Equal(t, float64(0), testutil.ToFloat64(metrics.filesPolled))
+}
+
+func TestFileTargetCompressedFiles(t *testing.T) {
+	logger := log.NewNopLogger()
+	dirName := t.TempDir()
+	logDir := dirName + "/logs"
+	require.NoError(t, os.MkdirAll(logDir, 0750))
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err := gw.Write([]byte("line 1\nline 2\r\nline 3"))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	require.NoError(t, ioutil.WriteFile(logDir+"/test.log.gz", buf.Bytes(), 0600))
+
+	zw, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(logDir+"/test.log.zst", zw.EncodeAll([]byte("line 1\nline 2\nline 3\n"), nil), 0600))
+
+	bz, err := ioutil.ReadFile("testdata/test.log.bz2")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(logDir+"/test.log.bz2", bz, 0600))
+
+	newTarget := func(ps positions.Positions, client *fake.Client) *FileTarget {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		fileWatcher, eventHandler, err := createWatchers(ctx, logDir+"/*")
+		require.NoError(t, err)
+		target, err := NewFileTarget(NewMetrics(nil), logger, client, ps, logDir+"/*", "", nil, nil, &Config{
+			SyncPeriod: 10 * time.Minute,
+		}, fileWatcher, eventHandler)
+		require.NoError(t, err)
+		return target
+	}
+	received := func(client *fake.Client) map[string][]string {
+		lines := map[string][]string{}
+		for _, e := range client.Received() {
+			filename := filepath.Base(string(e.Labels[FilenameLabel]))
+			lines[filename] = append(lines[filename], e.Line)
+		}
+		return lines
+	}
+
+	ps, err := positions.New(logger, positions.Config{
+		SyncPeriod:    10 * time.Minute,
+		PositionsFile: dirName + "/positions.yml",
+	})
+	require.NoError(t, err)
+	// The zstd file was partially read.
+	ps.Put(logDir+"/test.log.zst", 7)
+	client := fake.New(func() {})
+	defer client.Stop()
+
+	target := newTarget(ps, client)
+	require.Eventually(t, func() bool { return len(client.Received()) == 8 }, 5*time.Second, 10*time.Millisecond)
+	target.Stop()
+	require.Equal(t, map[string][]string{
+		"test.log.gz":  {"line 1", "line 2", "line 3"},
+		"test.log.bz2": {"line 1", "line 2", "line 3"},
+		"test.log.zst": {"line 2", "line 3"},
+	}, received(client))
+
+	// The positions are the uncompressed offsets.
+	for file, expected := range map[string]int64{"test.log.gz": 21, "test.log.bz2": 21, "test.log.zst": 21} {
+		pos, err := ps.Get(logDir + "/" + file)
+		require.NoError(t, err)
+		require.Equal(t, expected, pos, file)
+	}
+
+	// The files are not read again.
+	client2 := fake.New(func() {})
+	defer client2.Stop()
+	target = newTarget(ps, client2)
+	time.Sleep(100 * time.Millisecond)
+	target.Stop()
+	require.Empty(t, client2.Received())
+	ps.Stop()
+}
+
+func TestFileTargetStartPositionEnd(t *testing.T) {
+	logger := log.NewNopLogger()
+	dirName := t.TempDir()
+	logDir := dirName + "/logs"
+	require.NoError(t, os.MkdirAll(logDir, 0750))
+	require.NoError(t, ioutil.WriteFile(logDir+"/old.log", []byte("old 1\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(logDir+"/saved.log", []byte("saved 1\nsaved 2\n"), 0600))
+
+	ps, err := positions.New(logger, positions.Config{
+		SyncPeriod:    10 * time.Minute,
+		PositionsFile: dirName + "/positions.yml",
+	})
+	require.NoError(t, err)
+	defer ps.Stop()
+	// The saved position is used whatever the start position.
+	ps.Put(logDir+"/saved.log", 8)
+	client := fake.New(func() {})
+	defer client.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fileWatcher, eventHandler, err := createWatchers(ctx, logDir+"/*.log")
+	require.NoError(t, err)
+	target, err := NewFileTarget(NewMetrics(nil), logger, client, ps, logDir+"/*.log", "", nil, nil, &Config{
+		SyncPeriod:    10 * time.Minute,
+		StartPosition: scrapeconfig.StartPositionEnd,
+	}, fileWatcher, eventHandler)
+	require.NoError(t, err)
+	defer target.Stop()
+	require.Eventually(t, func() bool { return len(client.Received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	// Give the tailers time to seek to the end of the files.
+	time.Sleep(100 * time.Millisecond)
+
+	f, err := os.OpenFile(logDir+"/old.log", os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString("old 2\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	// The files created once the target started are read from their beginning.
+	require.NoError(t, ioutil.WriteFile(logDir+"/new.log", []byte("new 1\n"), 0600))
+
+	require.Eventually(t, func() bool { return len(client.Received()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	lines := map[string][]string{}
+	for _, e := range client.Received() {
+		filename := filepath.Base(string(e.Labels[FilenameLabel]))
+		lines[filename] = append(lines[filename], e.Line)
+	}
+	require.Equal(t, map[string][]string{
+		"saved.log": {"saved 2"},
+		"old.log":   {"old 2"},
+		"new.log":   {"new 1"},
+	}, lines)
+}
+
 func TestToStopTailing(t *testing.T) {
 	nt := []string{"file1", "file2", "file3", "file4", "file5", "file6", "file7", "file11", "file12", "file15"}
-	et := make(map[string]*tailer, 15)
+	et := make(map[string]reader, 15)
 	for i := 1; i <= 15; i++ {
 		et[fmt.Sprintf("file%d", i)] = nil
 	}
@@ -729,7 +932,7 @@ func TestToStopTailing(t *testing.T) {
 
 func BenchmarkToStopTailing(b *testing.B) {
 	nt := []string{"file1", "file2", "file3", "file4", "file5", "file6", "file7", "file11", "file12", "file15"}
-	et := make(map[string]*tailer, 15)
+	et := make(map[string]reader, 15)
 	for i := 1; i <= 15; i++ {
 		et[fmt.Sprintf("file%d", i)] = nil
 	}
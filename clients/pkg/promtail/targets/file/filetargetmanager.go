@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -31,20 +32,30 @@ import (
 
 const (
 	pathLabel              = "__path__"
+	pathExcludeLabel       = "__path_exclude__"
 	hostLabel              = "__host__"
 	kubernetesPodNodeField = "spec.nodeName"
+
+	// Pods annotated with promtail.io/exclude: "true" are not scraped, and pods annotated
+	// with promtail.io/pipeline: <name> are scraped with the named pipeline.
+	excludeAnnotationLabel  = "__meta_kubernetes_pod_annotation_promtail_io_exclude"
+	pipelineAnnotationLabel = "__meta_kubernetes_pod_annotation_promtail_io_pipeline"
 )
 
 // FileTargetManager manages a set of targets.
 // nolint:revive
 type FileTargetManager struct {
 	log     log.Logger
+	metrics *Metrics
 	quit    context.CancelFunc
 	syncers map[string]*targetSyncer
 	manager *discovery.Manager
 
 	watcher            *fsnotify.Watcher
 	targetEventHandler chan fileTargetEvent
+	// unwatched are the directories which could not be watched, the inotify watches
+	// being exhausted.
+	unwatched map[string]struct{}
 }
 
 // NewFileTargetManager creates a new TargetManager.
@@ -60,6 +71,16 @@ func NewFileTargetManager(
 	if reg == nil {
 		reg = prometheus.DefaultRegisterer
 	}
+	switch targetConfig.WatchMethod {
+	case "", WatchMethodPoll, WatchMethodNotify:
+	default:
+		return nil, fmt.Errorf("unknown watch method %q, must be %s or %s", targetConfig.WatchMethod, WatchMethodPoll, WatchMethodNotify)
+	}
+	switch targetConfig.StartPosition {
+	case "", scrapeconfig.StartPositionPositions, scrapeconfig.StartPositionBeginning, scrapeconfig.StartPositionEnd:
+	default:
+		return nil, fmt.Errorf("unknown start position %q, must be %s, %s or %s", targetConfig.StartPosition, scrapeconfig.StartPositionPositions, scrapeconfig.StartPositionBeginning, scrapeconfig.StartPositionEnd)
+	}
 
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -68,9 +89,11 @@ func NewFileTargetManager(
 	ctx, quit := context.WithCancel(context.Background())
 	tm := &FileTargetManager{
 		log:                logger,
+		metrics:            metrics,
 		quit:               quit,
 		watcher:            watcher,
 		targetEventHandler: make(chan fileTargetEvent),
+		unwatched:          map[string]struct{}{},
 		syncers:            map[string]*targetSyncer{},
 		manager:            discovery.NewManager(ctx, log.With(logger, "component", "discovery")),
 	}
@@ -90,6 +113,14 @@ func NewFileTargetManager(
 		if err != nil {
 			return nil, err
 		}
+		namedEntryHandlers := make(map[string]api.EntryHandler, len(cfg.NamedPipelineStages))
+		for name, pipelineStages := range cfg.NamedPipelineStages {
+			namedPipeline, err := stages.NewPipeline(log.With(logger, "component", "file_pipeline", "pipeline", name), pipelineStages, &cfg.JobName, reg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pipeline %s: %w", name, err)
+			}
+			namedEntryHandlers[name] = namedPipeline.Wrap(client)
+		}
 
 		// Add Source value to the static config target groups for unique identification
 		// within scrape pool. Also, default target label to localhost if target is not
@@ -117,16 +148,17 @@ func NewFileTargetManager(
 		}
 
 		s := &targetSyncer{
-			metrics:           metrics,
-			log:               logger,
-			positions:         positions,
-			relabelConfig:     cfg.RelabelConfigs,
-			targets:           map[string]*FileTarget{},
-			droppedTargets:    []target.Target{},
-			hostname:          hostname,
-			entryHandler:      pipeline.Wrap(client),
-			targetConfig:      targetConfig,
-			fileEventWatchers: map[string]chan fsnotify.Event{},
+			metrics:            metrics,
+			log:                logger,
+			positions:          positions,
+			relabelConfig:      cfg.RelabelConfigs,
+			targets:            map[string]*FileTarget{},
+			droppedTargets:     []target.Target{},
+			hostname:           hostname,
+			entryHandler:       pipeline.Wrap(client),
+			namedEntryHandlers: namedEntryHandlers,
+			targetConfig:       jobTargetConfig(targetConfig, cfg),
+			fileEventWatchers:  map[string]chan fsnotify.Event{},
 		}
 		tm.syncers[cfg.JobName] = s
 		configs[cfg.JobName] = cfg.ServiceDiscoveryConfig.Configs()
@@ -146,9 +178,20 @@ func (tm *FileTargetManager) watch(ctx context.Context) {
 			switch event.eventType {
 			case fileTargetEventWatchStart:
 				if err := tm.watcher.Add(event.path); err != nil {
+					if isWatchLimitError(err) {
+						// The files created in the directory are still found by the sync of the targets.
+						level.Warn(tm.log).Log("msg", "inotify watches exhausted, new files of the directory are only found every sync period, raise fs.inotify.max_user_watches to watch it", "directory", event.path)
+						tm.metrics.watchLimitReached.WithLabelValues("directory").Inc()
+						tm.unwatched[event.path] = struct{}{}
+						continue
+					}
 					level.Error(tm.log).Log("msg", "error adding directory to watcher", "error", err)
 				}
 			case fileTargetEventWatchStop:
+				if _, ok := tm.unwatched[event.path]; ok {
+					delete(tm.unwatched, event.path)
+					continue
+				}
 				if err := tm.watcher.Remove(event.path); err != nil {
 					level.Error(tm.log).Log("msg", " failed to remove directory from watcher", "error", err)
 				}
@@ -223,6 +266,9 @@ type targetSyncer struct {
 	entryHandler api.EntryHandler
 	hostname     string
 
+	// namedEntryHandlers run the named pipelines selected by the targets.
+	namedEntryHandlers map[string]api.EntryHandler
+
 	fileEventWatchers map[string]chan fsnotify.Event
 
 	droppedTargets []target.Target
@@ -233,6 +279,16 @@ type targetSyncer struct {
 	targetConfig  *Config
 }
 
+// jobTargetConfig returns the target config of the job, with its own start position if it has one.
+func jobTargetConfig(targetConfig *Config, cfg scrapeconfig.Config) *Config {
+	if cfg.StartPosition == "" {
+		return targetConfig
+	}
+	jobConfig := *targetConfig
+	jobConfig.StartPosition = cfg.StartPosition
+	return &jobConfig
+}
+
 // sync synchronize target based on received target groups received by service discovery
 func (s *targetSyncer) sync(groups []*targetgroup.Group, targetEventHandler chan fileTargetEvent) {
 	s.mtx.Lock()
@@ -246,6 +302,13 @@ func (s *targetSyncer) sync(groups []*targetgroup.Group, targetEventHandler chan
 			level.Debug(s.log).Log("msg", "new target", "labels", t)
 
 			discoveredLabels := group.Labels.Merge(t)
+			if exclude, _ := strconv.ParseBool(string(discoveredLabels[excludeAnnotationLabel])); exclude {
+				dropped = append(dropped, target.NewDroppedTarget("ignoring target, excluded by annotation", discoveredLabels))
+				level.Debug(s.log).Log("msg", "ignoring target, excluded by annotation", "labels", discoveredLabels.String())
+				continue
+			}
+			pipelineName := string(discoveredLabels[pipelineAnnotationLabel])
+
 			var labelMap = make(map[string]string)
 			for k, v := range discoveredLabels.Clone() {
 				labelMap[string(k)] = string(v)
@@ -282,6 +345,8 @@ func (s *targetSyncer) sync(groups []*targetgroup.Group, targetEventHandler chan
 				continue
 			}
 
+			pathExclude := labels[pathExcludeLabel]
+
 			for k := range labels {
 				if strings.HasPrefix(string(k), "__") {
 					delete(labels, k)
@@ -289,6 +354,12 @@ func (s *targetSyncer) sync(groups []*targetgroup.Group, targetEventHandler chan
 			}
 
 			key := fmt.Sprintf("%s:%s", path, labels.String())
+			if pathExclude != "" {
+				key = fmt.Sprintf("%s:%s", key, pathExclude)
+			}
+			if pipelineName != "" {
+				key = fmt.Sprintf("%s:%s", key, pipelineName)
+			}
 			targets[key] = struct{}{}
 			if _, ok := s.targets[key]; ok {
 				dropped = append(dropped, target.NewDroppedTarget("ignoring target, already exists", discoveredLabels))
@@ -300,7 +371,7 @@ func (s *targetSyncer) sync(groups []*targetgroup.Group, targetEventHandler chan
 			level.Info(s.log).Log("msg", "Adding target", "key", key)
 			watcher := make(chan fsnotify.Event)
 			s.fileEventWatchers[string(path)] = watcher
-			t, err := s.newTarget(string(path), labels, discoveredLabels, watcher, targetEventHandler)
+			t, err := s.newTarget(string(path), string(pathExclude), pipelineName, labels, discoveredLabels, watcher, targetEventHandler)
 			if err != nil {
 				dropped = append(dropped, target.NewDroppedTarget(fmt.Sprintf("Failed to create target: %s", err.Error()), discoveredLabels))
 				level.Error(s.log).Log("msg", "Failed to create target", "key", key, "error", err)
@@ -340,8 +411,22 @@ func (s *targetSyncer) sendFileCreateEvent(event fsnotify.Event) {
 	}
 }
 
-func (s *targetSyncer) newTarget(path string, labels model.LabelSet, discoveredLabels model.LabelSet, fileEventWatcher chan fsnotify.Event, targetEventHandler chan fileTargetEvent) (*FileTarget, error) {
-	return NewFileTarget(s.metrics, s.log, s.entryHandler, s.positions, path, labels, discoveredLabels, s.targetConfig, fileEventWatcher, targetEventHandler)
+func (s *targetSyncer) newTarget(path, pathExclude, pipelineName string, labels model.LabelSet, discoveredLabels model.LabelSet, fileEventWatcher chan fsnotify.Event, targetEventHandler chan fileTargetEvent) (*FileTarget, error) {
+	return NewFileTarget(s.metrics, s.log, s.targetEntryHandler(pipelineName), s.positions, path, pathExclude, labels, discoveredLabels, s.targetConfig, fileEventWatcher, targetEventHandler)
+}
+
+// targetEntryHandler returns the handler running the named pipeline selected by a target,
+// falling back to the pipeline of the scrape config if none or an unknown one is selected.
+func (s *targetSyncer) targetEntryHandler(pipelineName string) api.EntryHandler {
+	if pipelineName == "" {
+		return s.entryHandler
+	}
+	handler, ok := s.namedEntryHandlers[pipelineName]
+	if !ok {
+		level.Warn(s.log).Log("msg", "unknown pipeline selected by target, using the pipeline stages of the scrape config", "pipeline", pipelineName)
+		return s.entryHandler
+	}
+	return handler
 }
 
 func (s *targetSyncer) DroppedTargets() []target.Target {
@@ -385,6 +470,9 @@ func (s *targetSyncer) stop() {
 		delete(s.fileEventWatchers, key)
 	}
 	s.entryHandler.Stop()
+	for _, handler := range s.namedEntryHandlers {
+		handler.Stop()
+	}
 }
 
 func hostname() (string, error) {
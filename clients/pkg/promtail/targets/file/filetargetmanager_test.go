@@ -0,0 +1,79 @@
+package file
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/fsnotify.v1"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/client/fake"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/testutils"
+)
+
+func TestTargetSyncer_Annotations(t *testing.T) {
+	testutils.InitRandom()
+	dirName := "/tmp/" + testutils.RandName()
+	require.NoError(t, os.MkdirAll(dirName, 0750))
+	defer func() { _ = os.RemoveAll(dirName) }()
+
+	logger := log.NewNopLogger()
+	ps, err := positions.New(logger, positions.Config{
+		SyncPeriod:    10 * time.Minute,
+		PositionsFile: dirName + "/positions.yml",
+	})
+	require.NoError(t, err)
+	defer ps.Stop()
+
+	defaultClient, nginxClient := fake.New(func() {}), fake.New(func() {})
+	s := &targetSyncer{
+		metrics:            NewMetrics(nil),
+		log:                logger,
+		positions:          ps,
+		entryHandler:       defaultClient,
+		namedEntryHandlers: map[string]api.EntryHandler{"nginx": nginxClient},
+		targets:            map[string]*FileTarget{},
+		droppedTargets:     []target.Target{},
+		targetConfig:       &Config{SyncPeriod: 10 * time.Second},
+		fileEventWatchers:  map[string]chan fsnotify.Event{},
+	}
+	defer s.stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, eventHandler, err := createWatchers(ctx, dirName+"/*.log")
+	require.NoError(t, err)
+
+	s.sync([]*targetgroup.Group{{
+		Targets: []model.LabelSet{
+			{
+				pathLabel:               model.LabelValue(dirName + "/nginx/*.log"),
+				"app":                   "nginx",
+				pipelineAnnotationLabel: "nginx",
+			},
+			{
+				pathLabel:              model.LabelValue(dirName + "/batch/*.log"),
+				"app":                  "batch",
+				excludeAnnotationLabel: "true",
+			},
+		},
+	}}, eventHandler)
+
+	require.Len(t, s.ActiveTargets(), 1)
+	require.Contains(t, s.targets, dirName+`/nginx/*.log:{app="nginx"}:nginx`)
+	dropped := s.DroppedTargets()
+	require.Len(t, dropped, 1)
+	require.Equal(t, "ignoring target, excluded by annotation", dropped[0].Details())
+
+	require.Equal(t, api.EntryHandler(nginxClient), s.targetEntryHandler("nginx"))
+	require.Equal(t, api.EntryHandler(defaultClient), s.targetEntryHandler(""))
+	require.Equal(t, api.EntryHandler(defaultClient), s.targetEntryHandler("unknown"))
+}
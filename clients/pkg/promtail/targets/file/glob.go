@@ -0,0 +1,216 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar"
+)
+
+// globber finds the files matching a doublestar pattern. Unlike doublestar.Glob,
+// it can ignore symlinks, and it prunes the symlinks while walking: it never follows
+// a symlink back into one of the directories it is walking, which would otherwise
+// recurse until the path is too long to resolve, nor into a directory already walked
+// through another symlink, as every pair of such symlinks doubles the paths to walk.
+type globber struct {
+	followSymlinks bool
+	// onLoop is called with every symlink skipped because it leads to a loop.
+	onLoop func(path string)
+}
+
+// glob returns the paths matching pattern.
+func (g globber) glob(pattern string) ([]string, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	// Alternatives can span several path components, or even mix relative and absolute
+	// paths, so every alternative is globbed as a pattern of its own.
+	if i := indexUnescaped(pattern, '{'); i != -1 {
+		options, end := splitAlternatives(pattern[i:])
+		if end == -1 {
+			return nil, doublestar.ErrBadPattern
+		}
+		var matches []string
+		for _, o := range options {
+			m, err := g.glob(pattern[:i] + o + pattern[i+end+1:])
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, m...)
+		}
+		return matches, nil
+	}
+
+	pattern = filepath.Clean(pattern)
+	base, rest := splitPattern(pattern)
+	if rest == "" {
+		// Nothing to glob, the pattern is a path.
+		if _, err := os.Lstat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	fi, err := os.Stat(base)
+	if err != nil || !fi.IsDir() {
+		return nil, nil
+	}
+	w := &walker{
+		globber:    g,
+		pattern:    pattern,
+		base:       base,
+		components: strings.Split(rest, "/"),
+	}
+	return w.walk(base, 0, []os.FileInfo{fi}, nil)
+}
+
+type walker struct {
+	globber
+	pattern    string
+	base       string
+	components []string
+	// linked are the directories walked through a symlink so far.
+	linked []os.FileInfo
+}
+
+// walk appends the matches found below dir, which is depth levels below the
+// base directory of the pattern. ancestors holds the directories being walked.
+func (w *walker) walk(dir string, depth int, ancestors []os.FileInfo, matches []string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		// Like doublestar.Glob, ignore file system errors.
+		return matches, nil
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		symlink := fi.Mode()&os.ModeSymlink != 0
+		if symlink {
+			if !w.followSymlinks {
+				continue
+			}
+			if fi, err = os.Stat(path); err != nil {
+				continue
+			}
+			if fi.IsDir() && sameFileIn(fi, ancestors) {
+				if w.onLoop != nil {
+					w.onLoop(path)
+				}
+				continue
+			}
+		}
+
+		matched, err := doublestar.PathMatch(w.pattern, path)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			matches = append(matches, path)
+		}
+
+		if !fi.IsDir() || !w.descend(path, depth+1) {
+			continue
+		}
+		if symlink {
+			if sameFileIn(fi, w.linked) {
+				continue
+			}
+			w.linked = append(w.linked, fi)
+		}
+		if matches, err = w.walk(path, depth+1, append(ancestors, fi), matches); err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// descend returns whether files below dir, which is depth levels below the
+// base directory of the pattern, can match it.
+func (w *walker) descend(dir string, depth int) bool {
+	for i := 0; i < depth && i < len(w.components); i++ {
+		if w.components[i] == "**" {
+			return true
+		}
+	}
+	if depth >= len(w.components) {
+		return false
+	}
+	// The directory must match the pattern up to its depth.
+	matched, err := doublestar.PathMatch(filepath.Join(w.base, filepath.FromSlash(strings.Join(w.components[:depth], "/"))), dir)
+	return err != nil || matched
+}
+
+// sameFileIn returns whether fi is one of files.
+func sameFileIn(fi os.FileInfo, files []os.FileInfo) bool {
+	for _, f := range files {
+		if os.SameFile(fi, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPattern splits pattern into the directory holding its first glob
+// component and the remaining components.
+func splitPattern(pattern string) (base, rest string) {
+	components := strings.Split(filepath.ToSlash(pattern), "/")
+	for i, c := range components {
+		if strings.ContainsAny(c, "*?[\\") {
+			base = filepath.FromSlash(strings.Join(components[:i], "/"))
+			switch {
+			case i == 1 && components[0] == "":
+				base = string(filepath.Separator)
+			case i == 0:
+				base = "."
+			}
+			return base, strings.Join(components[i:], "/")
+		}
+	}
+	return pattern, ""
+}
+
+func indexUnescaped(pattern string, c byte) int {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+	return -1
+}
+
+// splitAlternatives returns the alternatives of the {...} group starting
+// pattern and the index of its closing brace, or -1 if it is not closed.
+func splitAlternatives(pattern string) ([]string, int) {
+	var (
+		options []string
+		nesting int
+		start   = 1
+	)
+	for i := 1; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			nesting++
+		case ',':
+			if nesting == 0 {
+				options = append(options, pattern[start:i])
+				start = i + 1
+			}
+		case '}':
+			if nesting == 0 {
+				return append(options, pattern[start:i]), i
+			}
+			nesting--
+		}
+	}
+	return nil, -1
+}
@@ -0,0 +1,133 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobber(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"a.log", "a.log.gz", "sub/b.log", "sub/deep/c.log"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, f)), 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, f), nil, 0600))
+	}
+	require.NoError(t, os.Symlink(filepath.Join(dir, "a.log"), filepath.Join(dir, "linked.log")))
+	// A symlink back to the parent directory.
+	require.NoError(t, os.Symlink(dir, filepath.Join(dir, "sub", "loop")))
+
+	for _, tc := range []struct {
+		name           string
+		pattern        string
+		followSymlinks bool
+		expected       []string
+	}{
+		{"no glob", "a.log", true, []string{"a.log"}},
+		{"missing", "missing.log", true, nil},
+		{"star", "*.log", true, []string{"a.log", "linked.log"}},
+		{"star without symlinks", "*.log", false, []string{"a.log"}},
+		{"doublestar", "**/*.log", true, []string{"a.log", "linked.log", "sub/b.log", "sub/deep/c.log"}},
+		{"doublestar without symlinks", "**/*.log", false, []string{"a.log", "sub/b.log", "sub/deep/c.log"}},
+		{"nested", "sub/*/*.log", true, []string{"sub/deep/c.log", "sub/loop/a.log", "sub/loop/linked.log"}},
+		{"nested without symlinks", "sub/*/*.log", false, []string{"sub/deep/c.log"}},
+		{"alternatives", "{a,sub/b}.log", true, []string{"a.log", "sub/b.log"}},
+		{"alternatives in directories", "{sub,sub/deep}/*.log", true, []string{"sub/b.log", "sub/deep/c.log"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var loops []string
+			g := globber{
+				followSymlinks: tc.followSymlinks,
+				onLoop:         func(path string) { loops = append(loops, path) },
+			}
+			matches, err := g.glob(filepath.Join(dir, tc.pattern))
+			require.NoError(t, err)
+
+			var expected []string
+			for _, e := range tc.expected {
+				expected = append(expected, filepath.Join(dir, e))
+			}
+			sort.Strings(matches)
+			require.Equal(t, expected, matches)
+			if tc.followSymlinks && tc.name == "doublestar" {
+				require.Equal(t, []string{filepath.Join(dir, "sub", "loop")}, loops)
+			}
+		})
+	}
+}
+
+func TestFileTargetFilter(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"), nil, 0600))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "a.log"), filepath.Join(dir, "linked.log")))
+
+	files := func() []string {
+		return []string{filepath.Join(dir, "a.log"), filepath.Join(dir, "a.log.1.gz"), filepath.Join(dir, "linked.log")}
+	}
+
+	target := &FileTarget{pathExclude: dir + "/*.gz", targetConfig: &Config{FollowSymlinks: true}}
+	filtered, err := target.filter(files())
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "a.log"), filepath.Join(dir, "linked.log")}, filtered)
+
+	target = &FileTarget{targetConfig: &Config{FollowSymlinks: false}}
+	filtered, err = target.filter(files())
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "a.log"), filepath.Join(dir, "a.log.1.gz")}, filtered)
+}
+
+func TestGlobberAlternativePaths(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	for _, dir := range dirs {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"), nil, 0600))
+	}
+
+	matches, err := globber{}.glob("{" + dirs[0] + "," + dirs[1] + "}/*.log")
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dirs[0], "a.log"), filepath.Join(dirs[1], "a.log")}, matches)
+}
+
+func TestGlobberSymlinkLoops(t *testing.T) {
+	dir := t.TempDir()
+	d := filepath.Join(dir, "d")
+	require.NoError(t, os.MkdirAll(filepath.Join(d, "a"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(d, "a", "a.log"), nil, 0600))
+	// Every symlink back to d doubles the paths doublestar.Glob walks.
+	require.NoError(t, os.Symlink(d, filepath.Join(d, "a", "l1")))
+	require.NoError(t, os.Symlink(d, filepath.Join(d, "a", "l2")))
+	// Two directories linked to each other, and to d.
+	require.NoError(t, os.MkdirAll(filepath.Join(d, "b"), 0750))
+	require.NoError(t, os.Symlink(filepath.Join(d, "b"), filepath.Join(d, "a", "to-b")))
+	require.NoError(t, os.Symlink(filepath.Join(d, "a"), filepath.Join(d, "b", "to-a")))
+
+	type result struct {
+		matches, loops []string
+		err            error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		g := globber{followSymlinks: true, onLoop: func(path string) { r.loops = append(r.loops, path) }}
+		r.matches, r.err = g.glob(filepath.Join(d, "**", "*.log"))
+		done <- r
+	}()
+
+	select {
+	case r := <-done:
+		require.NoError(t, r.err)
+		require.Equal(t, []string{filepath.Join(d, "a", "a.log"), filepath.Join(d, "b", "to-a", "a.log")}, r.matches)
+		require.Equal(t, []string{
+			filepath.Join(d, "a", "l1"),
+			filepath.Join(d, "a", "l2"),
+			filepath.Join(d, "a", "to-b", "to-a"),
+			filepath.Join(d, "b", "to-a", "l1"),
+			filepath.Join(d, "b", "to-a", "l2"),
+			filepath.Join(d, "b", "to-a", "to-b"),
+		}, r.loops)
+	case <-time.After(10 * time.Second):
+		t.Fatal("glob did not return within 10s")
+	}
+}
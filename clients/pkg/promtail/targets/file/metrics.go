@@ -13,6 +13,8 @@ type Metrics struct {
 	readLines          *prometheus.CounterVec
 	filesActive        prometheus.Gauge
 	logLengthHistogram *prometheus.HistogramVec
+	watchLimitReached  *prometheus.CounterVec
+	filesPolled        prometheus.Gauge
 
 	// Manager metrics
 	failedTargets *prometheus.CounterVec
@@ -51,6 +53,16 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 		Help:      "the total count of bytes",
 		Buckets:   prometheus.ExponentialBuckets(16, 2, 8),
 	}, []string{"path"})
+	m.watchLimitReached = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "file_watch_limit_reached_total",
+		Help:      "Number of directories and files which could not be watched because the inotify watches were exhausted.",
+	}, []string{"watch"})
+	m.filesPolled = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "files_polled_fallback",
+		Help:      "Number of active files polled instead of watched because the inotify watches were exhausted.",
+	})
 
 	m.failedTargets = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "promtail",
@@ -70,6 +82,8 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 			m.readLines,
 			m.filesActive,
 			m.logLengthHistogram,
+			m.watchLimitReached,
+			m.filesPolled,
 			m.failedTargets,
 			m.targetsActive,
 		)
@@ -1,6 +1,7 @@
 package file
 
 import (
+	"io"
 	"os"
 	"sync"
 	"time"
@@ -36,7 +37,7 @@ type tailer struct {
 	done    chan struct{}
 }
 
-func newTailer(metrics *Metrics, logger log.Logger, handler api.EntryHandler, positions positions.Positions, path string) (*tailer, error) {
+func newTailer(metrics *Metrics, logger log.Logger, handler api.EntryHandler, positions positions.Positions, path string, watchMethod string, fromEnd bool) (*tailer, error) {
 	// Simple check to make sure the file we are tailing doesn't
 	// have a position already saved which is past the end of the file.
 	fi, err := os.Stat(path)
@@ -52,16 +53,24 @@ func newTailer(metrics *Metrics, logger log.Logger, handler api.EntryHandler, po
 		positions.Remove(path)
 	}
 
+	location := &tail.SeekInfo{
+		Offset: pos,
+		Whence: io.SeekStart,
+	}
+	if fromEnd {
+		location = &tail.SeekInfo{
+			Offset: 0,
+			Whence: io.SeekEnd,
+		}
+	}
+
 	tail, err := tail.TailFile(path, tail.Config{
 		Follow:    true,
-		Poll:      true,
+		Poll:      watchMethod != WatchMethodNotify,
 		ReOpen:    true,
 		MustExist: true,
-		Location: &tail.SeekInfo{
-			Offset: pos,
-			Whence: 0,
-		},
-		Logger: util.NewLogAdapter(logger),
+		Location:  location,
+		Logger:    util.NewLogAdapter(logger),
 	})
 	if err != nil {
 		return nil, err
@@ -218,6 +227,12 @@ func (t *tailer) isRunning() bool {
 	return t.running.Load()
 }
 
+// watchLimitReached returns true if the tailer stopped because the file could not be
+// watched, the inotify watches being exhausted.
+func (t *tailer) watchLimitReached() bool {
+	return !t.isRunning() && isWatchLimitError(t.tail.Tomb.Err())
+}
+
 // cleanupMetrics removes all metrics exported by this tailer
 func (t *tailer) cleanupMetrics() {
 	// When we stop tailing the file, also un-export metrics related to the file
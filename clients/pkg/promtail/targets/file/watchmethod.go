@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package file
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Polling is the default as every notify watch takes one of the few inotify
+// watches available to a user.
+const defaultWatchMethod = WatchMethodPoll
+
+// isWatchLimitError returns true if err is returned by inotify when the watches of the
+// user are exhausted, see fs.inotify.max_user_watches.
+func isWatchLimitError(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
@@ -0,0 +1,13 @@
+//go:build windows
+// +build windows
+
+package file
+
+// Change notifications are the default on Windows, where the tailed files are watched
+// with ReadDirectoryChangesW instead of being polled.
+const defaultWatchMethod = WatchMethodNotify
+
+// isWatchLimitError returns false, ReadDirectoryChangesW has no watch limit.
+func isWatchLimitError(err error) bool {
+	return false
+}
@@ -110,6 +110,7 @@ func NewJournalTarget(
 	jobName string,
 	relabelConfig []*relabel.Config,
 	targetConfig *scrapeconfig.JournalTargetConfig,
+	startPosition string,
 ) (*JournalTarget, error) {
 
 	return journalTargetWithReader(
@@ -119,6 +120,7 @@ func NewJournalTarget(
 		jobName,
 		relabelConfig,
 		targetConfig,
+		startPosition,
 		defaultJournalReaderFunc,
 		defaultJournalEntryFunc,
 	)
@@ -131,6 +133,7 @@ func journalTargetWithReader(
 	jobName string,
 	relabelConfig []*relabel.Config,
 	targetConfig *scrapeconfig.JournalTargetConfig,
+	startPosition string,
 	readerFunc journalReaderFunc,
 	entryFunc journalEntryFunc,
 ) (*JournalTarget, error) {
@@ -169,12 +172,22 @@ func journalTargetWithReader(
 		return nil, errors.Wrap(err, "parsing journal reader 'max_age' config value")
 	}
 
+	matches, err := parseMatches(targetConfig.Matches)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing journal reader 'matches' config value")
+	}
+
 	cfg := t.generateJournalConfig(journalConfigBuilder{
-		JournalPath: targetConfig.Path,
-		Position:    position,
-		MaxAge:      maxAge,
-		EntryFunc:   entryFunc,
+		JournalPath:   targetConfig.Path,
+		Position:      position,
+		StartPosition: startPosition,
+		MaxAge:        maxAge,
+		EntryFunc:     entryFunc,
 	})
+	// matches filter the entries in the journal, before they are formatted.
+	for _, m := range matches {
+		cfg.Matches = append(cfg.Matches, sdjournal.Match{Field: m.field, Value: m.value})
+	}
 	t.r, err = readerFunc(cfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating journal reader")
@@ -203,8 +216,10 @@ func journalTargetWithReader(
 type journalConfigBuilder struct {
 	JournalPath string
 	Position    string
-	MaxAge      time.Duration
-	EntryFunc   journalEntryFunc
+	// StartPosition is where the journal is read from without a saved position.
+	StartPosition string
+	MaxAge        time.Duration
+	EntryFunc     journalEntryFunc
 }
 
 // generateJournalConfig generates a journal config by trying to intelligently
@@ -229,7 +244,15 @@ func (t *JournalTarget) generateJournalConfig(
 	// ever set one and not both here.
 
 	if cb.Position == "" {
-		cfg.Since = -1 * cb.MaxAge
+		switch cb.StartPosition {
+		case scrapeconfig.StartPositionBeginning:
+			// Without Since nor Cursor the reader starts at the head of the journal.
+		case scrapeconfig.StartPositionEnd:
+			// Since can't be zero, the entries are read from now on.
+			cfg.Since = -time.Nanosecond
+		default:
+			cfg.Since = -1 * cb.MaxAge
+		}
 		return cfg
 	}
 
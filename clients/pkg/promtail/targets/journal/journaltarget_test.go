@@ -96,7 +96,7 @@ func TestJournalTarget(t *testing.T) {
 	require.NoError(t, err)
 
 	jt, err := journalTargetWithReader(logger, client, ps, "test", relabels,
-		&scrapeconfig.JournalTargetConfig{}, newMockJournalReader, newMockJournalEntry(nil))
+		&scrapeconfig.JournalTargetConfig{}, "", newMockJournalReader, newMockJournalEntry(nil))
 	require.NoError(t, err)
 
 	r := jt.r.(*mockJournalReader)
@@ -148,7 +148,7 @@ func TestJournalTarget_JSON(t *testing.T) {
 	cfg := &scrapeconfig.JournalTargetConfig{JSON: true}
 
 	jt, err := journalTargetWithReader(logger, client, ps, "test", relabels,
-		cfg, newMockJournalReader, newMockJournalEntry(nil))
+		cfg, "", newMockJournalReader, newMockJournalEntry(nil))
 	require.NoError(t, err)
 
 	r := jt.r.(*mockJournalReader)
@@ -199,7 +199,7 @@ func TestJournalTarget_Since(t *testing.T) {
 	}
 
 	jt, err := journalTargetWithReader(logger, client, ps, "test", nil,
-		&cfg, newMockJournalReader, newMockJournalEntry(nil))
+		&cfg, "", newMockJournalReader, newMockJournalEntry(nil))
 	require.NoError(t, err)
 
 	r := jt.r.(*mockJournalReader)
@@ -207,6 +207,94 @@ func TestJournalTarget_Since(t *testing.T) {
 	client.Stop()
 }
 
+func TestJournalTarget_StartPosition(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	for _, tc := range []struct {
+		startPosition string
+		saved         string
+		since         time.Duration
+		cursor        string
+	}{
+		{startPosition: "", since: -4 * time.Hour},
+		{startPosition: scrapeconfig.StartPositionPositions, since: -4 * time.Hour},
+		{startPosition: scrapeconfig.StartPositionBeginning},
+		{startPosition: scrapeconfig.StartPositionEnd, since: -time.Nanosecond},
+		// the saved position is used whatever the start position.
+		{startPosition: scrapeconfig.StartPositionEnd, saved: "foobar", cursor: "foobar"},
+	} {
+		t.Run(tc.startPosition+"/"+tc.saved, func(t *testing.T) {
+			testutils.InitRandom()
+			dirName := "/tmp/" + testutils.RandName()
+			ps, err := positions.New(logger, positions.Config{
+				SyncPeriod:    10 * time.Second,
+				PositionsFile: dirName + "/positions.yml",
+			})
+			require.NoError(t, err)
+			if tc.saved != "" {
+				ps.PutString("journal-test", tc.saved)
+			}
+
+			client := fake.New(func() {})
+			defer client.Stop()
+
+			journalEntry := newMockJournalEntry(&sdjournal.JournalEntry{
+				Cursor:            tc.saved,
+				RealtimeTimestamp: uint64(time.Now().Add(-time.Hour).UnixNano() / int64(time.Microsecond)),
+			})
+			jt, err := journalTargetWithReader(logger, client, ps, "test", nil,
+				&scrapeconfig.JournalTargetConfig{MaxAge: "4h"}, tc.startPosition, newMockJournalReader, journalEntry)
+			require.NoError(t, err)
+
+			r := jt.r.(*mockJournalReader)
+			require.Equal(t, tc.since, r.config.Since)
+			require.Equal(t, tc.cursor, r.config.Cursor)
+		})
+	}
+}
+
+func TestJournalTarget_Matches(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	testutils.InitRandom()
+	dirName := "/tmp/" + testutils.RandName()
+	positionsFileName := dirName + "/positions.yml"
+
+	ps, err := positions.New(logger, positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: positionsFileName,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.New(func() {})
+
+	cfg := scrapeconfig.JournalTargetConfig{
+		Matches: []string{"_SYSTEMD_UNIT=nginx.service", "PRIORITY<=err"},
+	}
+
+	jt, err := journalTargetWithReader(logger, client, ps, "test", nil,
+		&cfg, "", newMockJournalReader, newMockJournalEntry(nil))
+	require.NoError(t, err)
+
+	r := jt.r.(*mockJournalReader)
+	require.Equal(t, []sdjournal.Match{
+		{Field: "_SYSTEMD_UNIT", Value: "nginx.service"},
+		{Field: "PRIORITY", Value: "0"},
+		{Field: "PRIORITY", Value: "1"},
+		{Field: "PRIORITY", Value: "2"},
+		{Field: "PRIORITY", Value: "3"},
+	}, r.config.Matches)
+	client.Stop()
+
+	_, err = journalTargetWithReader(logger, client, ps, "test", nil,
+		&scrapeconfig.JournalTargetConfig{Matches: []string{"PRIORITY<=loud"}}, "", newMockJournalReader, newMockJournalEntry(nil))
+	require.Error(t, err)
+}
+
 func TestJournalTarget_Cursor_TooOld(t *testing.T) {
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
@@ -239,7 +327,7 @@ func TestJournalTarget_Cursor_TooOld(t *testing.T) {
 	})
 
 	jt, err := journalTargetWithReader(logger, client, ps, "test", nil,
-		&cfg, newMockJournalReader, journalEntry)
+		&cfg, "", newMockJournalReader, journalEntry)
 	require.NoError(t, err)
 
 	r := jt.r.(*mockJournalReader)
@@ -279,7 +367,7 @@ func TestJournalTarget_Cursor_NotTooOld(t *testing.T) {
 	})
 
 	jt, err := journalTargetWithReader(logger, client, ps, "test", nil,
-		&cfg, newMockJournalReader, journalEntry)
+		&cfg, "", newMockJournalReader, journalEntry)
 	require.NoError(t, err)
 
 	r := jt.r.(*mockJournalReader)
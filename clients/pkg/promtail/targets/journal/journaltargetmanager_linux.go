@@ -52,6 +52,7 @@ func NewJournalTargetManager(
 			cfg.JobName,
 			cfg.RelabelConfigs,
 			cfg.JournalConfig,
+			cfg.StartPosition,
 		)
 		if err != nil {
 			return nil, err
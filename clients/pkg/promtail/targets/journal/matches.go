@@ -0,0 +1,98 @@
+package journal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const priorityField = "PRIORITY"
+
+// journalPriorities maps the priority keywords to their value.
+var journalPriorities = map[string]int{
+	"emerg":   0,
+	"alert":   1,
+	"crit":    2,
+	"err":     3,
+	"error":   3,
+	"warning": 4,
+	"warn":    4,
+	"notice":  5,
+	"info":    6,
+	"debug":   7,
+}
+
+// journalMatch is a FIELD=VALUE match added to the journal reader.
+type journalMatch struct {
+	field string
+	value string
+}
+
+// parseMatches parses the match expressions of the journal config. An expression is either
+// FIELD=VALUE, or a comparison of PRIORITY with a priority value or keyword, such as
+// PRIORITY<=3 or PRIORITY<=err, which is expanded to a match for each priority it includes.
+// The journal combines the matches of the same field with OR, and of different fields with AND.
+func parseMatches(exprs []string) ([]journalMatch, error) {
+	var matches []journalMatch
+	for _, expr := range exprs {
+		field, op, value, err := splitMatch(expr)
+		if err != nil {
+			return nil, err
+		}
+		if op == "=" {
+			matches = append(matches, journalMatch{field: field, value: value})
+			continue
+		}
+
+		if field != priorityField {
+			return nil, fmt.Errorf("invalid match %q: only %s can be compared with %s", expr, priorityField, op)
+		}
+		priority, err := parsePriority(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match %q: %w", expr, err)
+		}
+		var included []int
+		for p := 0; p <= 7; p++ {
+			if (op == "<=" && p <= priority) || (op == "<" && p < priority) || (op == ">=" && p >= priority) || (op == ">" && p > priority) {
+				included = append(included, p)
+			}
+		}
+		if len(included) == 0 {
+			return nil, fmt.Errorf("invalid match %q: no priority matches", expr)
+		}
+		for _, p := range included {
+			matches = append(matches, journalMatch{field: priorityField, value: strconv.Itoa(p)})
+		}
+	}
+	return matches, nil
+}
+
+// splitMatch splits an expression into its field, operator and value. Journal field names
+// are made of uppercase letters, digits and underscores.
+func splitMatch(expr string) (string, string, string, error) {
+	i := 0
+	for i < len(expr) && (expr[i] >= 'A' && expr[i] <= 'Z' || expr[i] >= '0' && expr[i] <= '9' || expr[i] == '_') {
+		i++
+	}
+	field, rest := expr[:i], expr[i:]
+	if field == "" {
+		return "", "", "", fmt.Errorf("invalid match %q: expected an uppercase journal field name", expr)
+	}
+	for _, op := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(rest, op) {
+			return field, op, rest[len(op):], nil
+		}
+	}
+	return "", "", "", fmt.Errorf("invalid match %q: expected FIELD=VALUE", expr)
+}
+
+func parsePriority(value string) (int, error) {
+	if p, ok := journalPriorities[strings.ToLower(value)]; ok {
+		return p, nil
+	}
+	p, err := strconv.Atoi(value)
+	if err != nil || p < 0 || p > 7 {
+		return 0, fmt.Errorf("unknown priority %q", value)
+	}
+	return p, nil
+}
@@ -0,0 +1,59 @@
+package journal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMatches(t *testing.T) {
+	for name, tc := range map[string]struct {
+		exprs    []string
+		expected []journalMatch
+		err      bool
+	}{
+		"fields": {
+			exprs: []string{"_SYSTEMD_UNIT=nginx.service", "SYSLOG_IDENTIFIER=sshd"},
+			expected: []journalMatch{
+				{field: "_SYSTEMD_UNIT", value: "nginx.service"},
+				{field: "SYSLOG_IDENTIFIER", value: "sshd"},
+			},
+		},
+		"value with operators": {
+			exprs:    []string{"MESSAGE=a<=b=c"},
+			expected: []journalMatch{{field: "MESSAGE", value: "a<=b=c"}},
+		},
+		"priority at most": {
+			exprs: []string{"PRIORITY<=3"},
+			expected: []journalMatch{
+				{field: "PRIORITY", value: "0"},
+				{field: "PRIORITY", value: "1"},
+				{field: "PRIORITY", value: "2"},
+				{field: "PRIORITY", value: "3"},
+			},
+		},
+		"priority keyword": {
+			exprs: []string{"PRIORITY>warning"},
+			expected: []journalMatch{
+				{field: "PRIORITY", value: "5"},
+				{field: "PRIORITY", value: "6"},
+				{field: "PRIORITY", value: "7"},
+			},
+		},
+		"priority below emerg":    {exprs: []string{"PRIORITY<0"}, err: true},
+		"unknown priority":        {exprs: []string{"PRIORITY<=loud"}, err: true},
+		"comparing another field": {exprs: []string{"_PID<=100"}, err: true},
+		"lowercase field":         {exprs: []string{"_systemd_unit=nginx.service"}, err: true},
+		"no operator":             {exprs: []string{"_SYSTEMD_UNIT"}, err: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			matches, err := parseMatches(tc.exprs)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, matches)
+		})
+	}
+}
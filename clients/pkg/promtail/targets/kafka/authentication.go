@@ -1,14 +1,24 @@
 package kafka
 
 import (
+	"context"
 	"crypto/sha256"
 	"crypto/sha512"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 
+	"github.com/Shopify/sarama"
 	promconfig "github.com/prometheus/common/config"
 	"github.com/xdg-go/scram"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/tlspolicy"
 )
 
 func createTLSConfig(cfg promconfig.TLSConfig) (*tls.Config, error) {
@@ -34,6 +44,7 @@ func createTLSConfig(cfg promconfig.TLSConfig) (*tls.Config, error) {
 		}
 		tc.Certificates = []tls.Certificate{cert}
 	}
+	tlspolicy.Apply(tc)
 	return tc, nil
 }
 
@@ -67,3 +78,72 @@ func (x *XDGSCRAMClient) Step(challenge string) (response string, err error) {
 func (x *XDGSCRAMClient) Done() bool {
 	return x.ClientConversation.Done()
 }
+
+// tokenProvider implements sarama.AccessTokenProvider for the OAUTHBEARER mechanism.
+type tokenProvider struct {
+	// source refreshes tokens with the client credentials flow, it is nil when tokens are read from file.
+	source     oauth2.TokenSource
+	file       string
+	extensions map[string]string
+}
+
+func newTokenProvider(cfg scrapeconfig.KafkaSASLOAuthConfig) (*tokenProvider, error) {
+	tp := &tokenProvider{file: cfg.TokenFile, extensions: cfg.Extensions}
+	switch {
+	case cfg.TokenFile != "" && cfg.TokenURL != "":
+		return nil, errors.New("only one of token_url and token_file can be set for the OAUTHBEARER sasl mechanism")
+	case cfg.TokenURL != "":
+		if cfg.ClientID == "" {
+			return nil, errors.New("client_id must be set to use token_url for the OAUTHBEARER sasl mechanism")
+		}
+		cc := &clientcredentials.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret.Value,
+			TokenURL:     cfg.TokenURL,
+			Scopes:       cfg.Scopes,
+		}
+		tp.source = cc.TokenSource(context.Background())
+	case cfg.TokenFile == "":
+		return nil, errors.New("token_url or token_file must be set for the OAUTHBEARER sasl mechanism")
+	}
+	return tp, nil
+}
+
+// Token returns a valid token, the client credentials token source refreshes it before it expires.
+func (t *tokenProvider) Token() (*sarama.AccessToken, error) {
+	if t.source == nil {
+		b, err := os.ReadFile(t.file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading OAUTHBEARER token file: %w", err)
+		}
+		return &sarama.AccessToken{Token: strings.TrimSpace(string(b)), Extensions: t.extensions}, nil
+	}
+	token, err := t.source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("error getting OAUTHBEARER token: %w", err)
+	}
+	return &sarama.AccessToken{Token: token.AccessToken, Extensions: t.extensions}, nil
+}
+
+func gssapiConfig(cfg scrapeconfig.KafkaSASLConfig) sarama.GSSAPIConfig {
+	gssapi := sarama.GSSAPIConfig{
+		AuthType:           sarama.KRB5_USER_AUTH,
+		KerberosConfigPath: cfg.GSSAPIConfig.KerberosConfigPath,
+		ServiceName:        cfg.GSSAPIConfig.ServiceName,
+		Username:           cfg.User,
+		Password:           cfg.Password.Value,
+		Realm:              cfg.GSSAPIConfig.Realm,
+		DisablePAFXFAST:    cfg.GSSAPIConfig.DisablePAFXFAST,
+	}
+	if cfg.GSSAPIConfig.KeytabPath != "" {
+		gssapi.AuthType = sarama.KRB5_KEYTAB_AUTH
+		gssapi.KeyTabPath = cfg.GSSAPIConfig.KeytabPath
+	}
+	if gssapi.ServiceName == "" {
+		gssapi.ServiceName = "kafka"
+	}
+	if gssapi.KerberosConfigPath == "" {
+		gssapi.KerberosConfigPath = "/etc/krb5.conf"
+	}
+	return gssapi
+}
@@ -0,0 +1,338 @@
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var errShortAvroValue = errors.New("avro value too short")
+
+// avroSchema is a parsed Avro schema. Named types are shared between the schemas
+// referencing them, recursive records reference themselves.
+type avroSchema struct {
+	typ         string
+	name        string
+	logicalType string
+	fields      []avroField
+	symbols     []string
+	size        int
+	items       *avroSchema
+	values      *avroSchema
+	branches    []*avroSchema
+}
+
+type avroField struct {
+	name   string
+	schema *avroSchema
+}
+
+// avroNames holds the named types, records, enums and fixed, by full name.
+type avroNames map[string]*avroSchema
+
+// parseAvroSchema parses the JSON of an Avro schema. The named types it defines are
+// added to names, which holds the types defined by the schemas it references.
+func parseAvroSchema(schema string, names avroNames) (*avroSchema, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(schema), &v); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %w", err)
+	}
+	return names.parse(v, "")
+}
+
+func (n avroNames) parse(v interface{}, namespace string) (*avroSchema, error) {
+	switch s := v.(type) {
+	case string:
+		return n.parseName(s, namespace)
+	case []interface{}:
+		union := &avroSchema{typ: "union"}
+		for _, b := range s {
+			branch, err := n.parse(b, namespace)
+			if err != nil {
+				return nil, err
+			}
+			union.branches = append(union.branches, branch)
+		}
+		return union, nil
+	case map[string]interface{}:
+		return n.parseComplex(s, namespace)
+	}
+	return nil, fmt.Errorf("invalid avro schema %v", v)
+}
+
+func (n avroNames) parseName(name, namespace string) (*avroSchema, error) {
+	switch name {
+	case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+		return &avroSchema{typ: name}, nil
+	}
+	if s, ok := n[fullAvroName(name, namespace)]; ok {
+		return s, nil
+	}
+	if s, ok := n[name]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown avro type %s", name)
+}
+
+func (n avroNames) parseComplex(m map[string]interface{}, namespace string) (*avroSchema, error) {
+	typ, _ := m["type"].(string)
+	logicalType, _ := m["logicalType"].(string)
+	switch typ {
+	case "record", "error", "enum", "fixed":
+	case "array":
+		items, err := n.parse(m["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroSchema{typ: typ, items: items}, nil
+	case "map":
+		values, err := n.parse(m["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroSchema{typ: typ, values: values}, nil
+	default:
+		// A primitive type, possibly annotated with a logical type.
+		s, err := n.parse(m["type"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		if logicalType == "" || s.name != "" {
+			return s, nil
+		}
+		annotated := *s
+		annotated.logicalType = logicalType
+		return &annotated, nil
+	}
+
+	name, _ := m["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("avro %s without name", typ)
+	}
+	if ns, ok := m["namespace"].(string); ok && !strings.Contains(name, ".") {
+		namespace = ns
+	}
+	s := &avroSchema{typ: typ, name: fullAvroName(name, namespace), logicalType: logicalType}
+	if i := strings.LastIndex(s.name, "."); i >= 0 {
+		namespace = s.name[:i]
+	}
+	// Registered before the fields are parsed, for recursive records.
+	n[s.name] = s
+
+	switch typ {
+	case "enum":
+		symbols, _ := m["symbols"].([]interface{})
+		for _, symbol := range symbols {
+			str, _ := symbol.(string)
+			s.symbols = append(s.symbols, str)
+		}
+	case "fixed":
+		size, _ := m["size"].(float64)
+		s.size = int(size)
+	default:
+		s.typ = "record"
+		fields, _ := m["fields"].([]interface{})
+		for _, f := range fields {
+			field, _ := f.(map[string]interface{})
+			fieldName, _ := field["name"].(string)
+			fieldSchema, err := n.parse(field["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("field %s of %s: %w", fieldName, s.name, err)
+			}
+			s.fields = append(s.fields, avroField{name: fieldName, schema: fieldSchema})
+		}
+	}
+	return s, nil
+}
+
+func fullAvroName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// decodeAvro writes the JSON of the Avro binary encoded value to the stream and
+// returns the bytes following it. Unions are written as the value of their branch,
+// bytes and fixed as base64 strings, timestamps and dates as RFC3339 strings.
+func decodeAvro(s *avroSchema, b []byte, stream *jsoniter.Stream) ([]byte, error) {
+	switch s.typ {
+	case "null":
+		stream.WriteNil()
+		return b, nil
+	case "boolean":
+		if len(b) < 1 {
+			return nil, errShortAvroValue
+		}
+		stream.WriteBool(b[0] != 0)
+		return b[1:], nil
+	case "int", "long":
+		v, rest, err := readAvroLong(b)
+		if err != nil {
+			return nil, err
+		}
+		switch s.logicalType {
+		case "date":
+			stream.WriteString(time.Unix(v*86400, 0).UTC().Format("2006-01-02"))
+		case "timestamp-millis":
+			stream.WriteString(time.Unix(0, v*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano))
+		case "timestamp-micros":
+			stream.WriteString(time.Unix(0, v*int64(time.Microsecond)).UTC().Format(time.RFC3339Nano))
+		default:
+			stream.WriteInt64(v)
+		}
+		return rest, nil
+	case "float":
+		if len(b) < 4 {
+			return nil, errShortAvroValue
+		}
+		writeFloat(stream, float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		return b[4:], nil
+	case "double":
+		if len(b) < 8 {
+			return nil, errShortAvroValue
+		}
+		writeFloat(stream, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		return b[8:], nil
+	case "bytes", "string":
+		v, rest, err := readAvroBytes(b)
+		if err != nil {
+			return nil, err
+		}
+		if s.typ == "string" {
+			stream.WriteString(string(v))
+		} else {
+			stream.WriteString(base64.StdEncoding.EncodeToString(v))
+		}
+		return rest, nil
+	case "fixed":
+		if len(b) < s.size {
+			return nil, errShortAvroValue
+		}
+		stream.WriteString(base64.StdEncoding.EncodeToString(b[:s.size]))
+		return b[s.size:], nil
+	case "enum":
+		i, rest, err := readAvroLong(b)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.symbols) {
+			return nil, fmt.Errorf("invalid symbol %d of enum %s", i, s.name)
+		}
+		stream.WriteString(s.symbols[i])
+		return rest, nil
+	case "union":
+		i, rest, err := readAvroLong(b)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.branches) {
+			return nil, fmt.Errorf("invalid union branch %d", i)
+		}
+		return decodeAvro(s.branches[i], rest, stream)
+	case "record":
+		var err error
+		stream.WriteObjectStart()
+		for i, f := range s.fields {
+			if i > 0 {
+				stream.WriteMore()
+			}
+			stream.WriteObjectField(f.name)
+			if b, err = decodeAvro(f.schema, b, stream); err != nil {
+				return nil, err
+			}
+		}
+		stream.WriteObjectEnd()
+		return b, nil
+	case "array":
+		stream.WriteArrayStart()
+		b, err := readAvroBlocks(b, func(i int, b []byte) ([]byte, error) {
+			if i > 0 {
+				stream.WriteMore()
+			}
+			return decodeAvro(s.items, b, stream)
+		})
+		stream.WriteArrayEnd()
+		return b, err
+	case "map":
+		stream.WriteObjectStart()
+		b, err := readAvroBlocks(b, func(i int, b []byte) ([]byte, error) {
+			key, rest, err := readAvroBytes(b)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				stream.WriteMore()
+			}
+			stream.WriteObjectField(string(key))
+			return decodeAvro(s.values, rest, stream)
+		})
+		stream.WriteObjectEnd()
+		return b, err
+	}
+	return nil, fmt.Errorf("unsupported avro type %s", s.typ)
+}
+
+// readAvroLong reads a zigzag varint, the encoding of Avro ints and longs.
+func readAvroLong(b []byte) (int64, []byte, error) {
+	v, n := binary.Varint(b)
+	if n <= 0 {
+		return 0, nil, errShortAvroValue
+	}
+	return v, b[n:], nil
+}
+
+func readAvroBytes(b []byte) ([]byte, []byte, error) {
+	n, rest, err := readAvroLong(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n < 0 || int64(len(rest)) < n {
+		return nil, nil, errShortAvroValue
+	}
+	return rest[:n], rest[n:], nil
+}
+
+// readAvroBlocks reads the blocks of items of arrays and maps, ended by an empty block.
+func readAvroBlocks(b []byte, readItem func(i int, b []byte) ([]byte, error)) ([]byte, error) {
+	var i int
+	for {
+		count, rest, err := readAvroLong(b)
+		if err != nil {
+			return nil, err
+		}
+		b = rest
+		if count == 0 {
+			return b, nil
+		}
+		if count < 0 {
+			// A negative count is followed by the size of the block in bytes.
+			count = -count
+			if _, b, err = readAvroLong(b); err != nil {
+				return nil, err
+			}
+		}
+		for ; count > 0; count-- {
+			if b, err = readItem(i, b); err != nil {
+				return nil, err
+			}
+			i++
+		}
+	}
+}
+
+// writeFloat writes the float, or null for the values JSON can't represent.
+func writeFloat(stream *jsoniter.Stream, f float64) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		stream.WriteNil()
+		return
+	}
+	stream.WriteFloat64(f)
+}
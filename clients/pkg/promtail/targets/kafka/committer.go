@@ -0,0 +1,55 @@
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+// committer marks processed messages and decides when offsets are committed to Kafka
+// based on the configured commit strategy.
+//
+// Commits are issued synchronously from the claim goroutine, sarama waits for every
+// ConsumeClaim to return before closing the session offset manager, so this stays valid
+// while a claim is being drained during a rebalance.
+type committer struct {
+	session        sarama.ConsumerGroupSession
+	strategy       scrapeconfig.KafkaCommitStrategy
+	maxUncommitted int
+
+	uncommitted int
+}
+
+func newCommitter(session sarama.ConsumerGroupSession, strategy scrapeconfig.KafkaCommitStrategy, maxUncommitted int) *committer {
+	return &committer{
+		session:        session,
+		strategy:       strategy,
+		maxUncommitted: maxUncommitted,
+	}
+}
+
+// mark marks the message as processed. drained should be true when no more messages
+// are waiting in the consumer buffer of the claim.
+//
+// With the per_batch strategy a batch ends whenever the buffer is drained, and the last
+// message of a claim always drains the buffer, so nothing is left uncommitted when the
+// claim ends. With the periodic strategy remaining marks are flushed by sarama when the
+// session is closed.
+func (c *committer) mark(message *sarama.ConsumerMessage, drained bool) {
+	c.session.MarkMessage(message, "")
+	c.uncommitted++
+
+	switch {
+	case c.strategy == scrapeconfig.KafkaCommitStrategyPerMessage:
+		c.commit()
+	case c.strategy == scrapeconfig.KafkaCommitStrategyPerBatch && drained:
+		c.commit()
+	case c.maxUncommitted > 0 && c.uncommitted >= c.maxUncommitted:
+		c.commit()
+	}
+}
+
+func (c *committer) commit() {
+	c.session.Commit()
+	c.uncommitted = 0
+}
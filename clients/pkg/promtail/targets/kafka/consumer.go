@@ -34,6 +34,8 @@ type consumer struct {
 	sarama.ConsumerGroup
 	discoverer TargetDiscoverer
 	logger     log.Logger
+	// initialOffsets moves the claimed partitions without a committed offset, when set.
+	initialOffsets *initialOffsets
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -102,6 +104,9 @@ func (c *consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim saram
 // Setup is run at the beginning of a new session, before ConsumeClaim
 func (c *consumer) Setup(session sarama.ConsumerGroupSession) error {
 	c.resetTargets()
+	if c.initialOffsets != nil {
+		return c.initialOffsets.apply(session)
+	}
 	return nil
 }
 
@@ -0,0 +1,158 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+// Decoder decodes the value of a message into a log line.
+type Decoder interface {
+	Decode(value []byte) (string, error)
+}
+
+// newDecoder returns the decoder of the configuration, plaintext by default.
+func newDecoder(cfg scrapeconfig.KafkaDecoder) (Decoder, error) {
+	switch cfg.Type {
+	case "", scrapeconfig.KafkaDecoderPlaintext:
+		return plaintextDecoder{}, nil
+	case scrapeconfig.KafkaDecoderJSON:
+		return jsonDecoder{}, nil
+	case scrapeconfig.KafkaDecoderAvro, scrapeconfig.KafkaDecoderProtobuf:
+		registry, err := newSchemaRegistry(cfg.SchemaRegistry)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Type == scrapeconfig.KafkaDecoderAvro {
+			return &avroDecoder{registry: registry}, nil
+		}
+		return &protobufDecoder{registry: registry}, nil
+	}
+	return nil, fmt.Errorf("unsupported decoder type %s", cfg.Type)
+}
+
+// plaintextDecoder uses the value as log line.
+type plaintextDecoder struct{}
+
+func (plaintextDecoder) Decode(value []byte) (string, error) {
+	return string(value), nil
+}
+
+// jsonDecoder uses JSON values as log line, without the schema ID prefix of the
+// Confluent wire format if any.
+type jsonDecoder struct{}
+
+func (jsonDecoder) Decode(value []byte) (string, error) {
+	if _, payload, err := splitSchemaID(value); err == nil {
+		value = payload
+	}
+	if !json.Valid(value) {
+		return "", errors.New("invalid json value")
+	}
+	return string(value), nil
+}
+
+// avroDecoder decodes Avro values of the Confluent wire format into JSON.
+type avroDecoder struct {
+	registry *schemaRegistry
+}
+
+func (d *avroDecoder) Decode(value []byte) (string, error) {
+	id, payload, err := splitSchemaID(value)
+	if err != nil {
+		return "", err
+	}
+	schema, err := d.registry.avroSchema(id)
+	if err != nil {
+		return "", err
+	}
+	return encodeJSON(func(stream *jsoniter.Stream) error {
+		_, err := decodeAvro(schema, payload, stream)
+		return err
+	})
+}
+
+// protobufDecoder decodes protobuf values of the Confluent wire format into JSON.
+type protobufDecoder struct {
+	registry *schemaRegistry
+}
+
+func (d *protobufDecoder) Decode(value []byte) (string, error) {
+	id, payload, err := splitSchemaID(value)
+	if err != nil {
+		return "", err
+	}
+	fd, err := d.registry.protobufSchema(id)
+	if err != nil {
+		return "", err
+	}
+	md, payload, err := protobufMessage(fd, payload)
+	if err != nil {
+		return "", err
+	}
+	return encodeJSON(func(stream *jsoniter.Stream) error {
+		return decodeProtobuf(md, payload, stream)
+	})
+}
+
+// splitSchemaID splits a value of the Confluent wire format into the ID of its schema
+// and its payload.
+func splitSchemaID(value []byte) (int, []byte, error) {
+	if len(value) < 5 || value[0] != 0 {
+		return 0, nil, errors.New("value without schema id")
+	}
+	return int(binary.BigEndian.Uint32(value[1:5])), value[5:], nil
+}
+
+// protobufMessage returns the descriptor of the message of the payload, given by the
+// indexes leading to it in the messages of the file and their nested messages, and
+// the payload following these indexes.
+func protobufMessage(fd protoreflect.FileDescriptor, payload []byte) (protoreflect.MessageDescriptor, []byte, error) {
+	count, n := protowire.ConsumeVarint(payload)
+	if n < 0 {
+		return nil, nil, protowire.ParseError(n)
+	}
+	payload = payload[n:]
+	indexes := []int64{0}
+	if count := protowire.DecodeZigZag(count); count > 0 {
+		indexes = indexes[:0]
+		for ; count > 0; count-- {
+			i, n := protowire.ConsumeVarint(payload)
+			if n < 0 {
+				return nil, nil, protowire.ParseError(n)
+			}
+			payload = payload[n:]
+			indexes = append(indexes, protowire.DecodeZigZag(i))
+		}
+	}
+
+	messages := fd.Messages()
+	var md protoreflect.MessageDescriptor
+	for _, i := range indexes {
+		if i < 0 || int(i) >= messages.Len() {
+			return nil, nil, fmt.Errorf("invalid message index %d in schema %s", i, fd.Path())
+		}
+		md = messages.Get(int(i))
+		messages = md.Messages()
+	}
+	return md, payload, nil
+}
+
+func encodeJSON(write func(*jsoniter.Stream) error) (string, error) {
+	stream := jsoniter.ConfigDefault.BorrowStream(nil)
+	defer jsoniter.ConfigDefault.ReturnStream(stream)
+	if err := write(stream); err != nil {
+		return "", err
+	}
+	if stream.Error != nil {
+		return "", stream.Error
+	}
+	return string(stream.Buffer()), nil
+}
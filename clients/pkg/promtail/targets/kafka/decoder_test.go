@@ -0,0 +1,284 @@
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+const testAvroSchema = `{
+	"type": "record",
+	"name": "Log",
+	"namespace": "com.example",
+	"fields": [
+		{"name": "ts", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "level", "type": {"type": "enum", "name": "Level", "symbols": ["DEBUG", "INFO", "ERROR"]}},
+		{"name": "msg", "type": ["null", "string"]},
+		{"name": "duration", "type": "double"},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "attrs", "type": {"type": "map", "values": "long"}},
+		{"name": "source", "type": "com.example.Source"}
+	]
+}`
+
+const testAvroReference = `{
+	"type": "record",
+	"name": "Source",
+	"namespace": "com.example",
+	"fields": [{"name": "host", "type": "string"}, {"name": "parent", "type": ["null", "Source"]}]
+}`
+
+func withSchemaID(id int, payload []byte) []byte {
+	b := []byte{0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(id))
+	return append(b, payload...)
+}
+
+func appendAvroLong(b []byte, v int64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutVarint(buf, v)]...)
+}
+
+func appendAvroString(b []byte, s string) []byte {
+	return append(appendAvroLong(b, int64(len(s))), s...)
+}
+
+func newTestSchemaRegistry(t *testing.T, schemas map[string]registrySchema) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := schemas[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(s))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func Test_AvroDecoder(t *testing.T) {
+	srv := newTestSchemaRegistry(t, map[string]registrySchema{
+		"/schemas/ids/7": {
+			Schema:     testAvroSchema,
+			References: []registryReference{{Name: "com.example.Source", Subject: "source", Version: 2}},
+		},
+		"/subjects/source/versions/2": {Schema: testAvroReference},
+	})
+	decoder, err := newDecoder(scrapeconfig.KafkaDecoder{
+		Type:           scrapeconfig.KafkaDecoderAvro,
+		SchemaRegistry: scrapeconfig.KafkaSchemaRegistry{URL: srv.URL},
+	})
+	require.NoError(t, err)
+
+	var b []byte
+	b = appendAvroLong(b, 1622548800123)
+	b = appendAvroLong(b, 2)
+	b = appendAvroString(appendAvroLong(b, 1), "boom")
+	b = append(b, make([]byte, 8)...)
+	binary.LittleEndian.PutUint64(b[len(b)-8:], math.Float64bits(1.5))
+	// An array of two items in a single block, and a map of one entry in a block with its size.
+	b = appendAvroLong(appendAvroString(appendAvroString(appendAvroLong(b, 2), "a"), "b"), 0)
+	b = appendAvroLong(appendAvroString(appendAvroLong(appendAvroLong(b, -1), 3), "n"), 42)
+	b = appendAvroLong(b, 0)
+	// A recursive record.
+	b = appendAvroString(b, "web-1")
+	b = appendAvroLong(appendAvroString(appendAvroLong(b, 1), "lb-1"), 0)
+
+	line, err := decoder.Decode(withSchemaID(7, b))
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"ts": "2021-06-01T12:00:00.123Z",
+		"level": "ERROR",
+		"msg": "boom",
+		"duration": 1.5,
+		"tags": ["a", "b"],
+		"attrs": {"n": 42},
+		"source": {"host": "web-1", "parent": {"host": "lb-1", "parent": null}}
+	}`, line)
+
+	_, err = decoder.Decode(withSchemaID(7, b[:5]))
+	require.Error(t, err)
+	_, err = decoder.Decode(withSchemaID(8, b))
+	require.Error(t, err)
+	_, err = decoder.Decode([]byte("plain"))
+	require.Error(t, err)
+}
+
+func Test_SchemaRegistryUnavailable(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	decoder, err := newDecoder(scrapeconfig.KafkaDecoder{
+		Type:           scrapeconfig.KafkaDecoderAvro,
+		SchemaRegistry: scrapeconfig.KafkaSchemaRegistry{URL: srv.URL},
+	})
+	require.NoError(t, err)
+
+	_, err = decoder.Decode(withSchemaID(7, nil))
+	require.ErrorIs(t, err, errRegistryUnavailable)
+
+	status = http.StatusTooManyRequests
+	_, err = decoder.Decode(withSchemaID(7, nil))
+	require.ErrorIs(t, err, errRegistryUnavailable)
+
+	// Unknown schemas are invalid values.
+	status = http.StatusNotFound
+	_, err = decoder.Decode(withSchemaID(7, nil))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, errRegistryUnavailable)
+
+	srv.Close()
+	_, err = decoder.Decode(withSchemaID(7, nil))
+	require.ErrorIs(t, err, errRegistryUnavailable)
+}
+
+func Test_ProtobufDecoder(t *testing.T) {
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("log.proto"),
+		Package:    proto.String("example"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Level"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("INFO"), Number: proto.Int32(0)},
+				{Name: proto.String("ERROR"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Unused")},
+			{
+				Name: proto.String("Log"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("ts", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp", false),
+					field("level", 2, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".example.Level", false),
+					field("message", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+					field("status_codes", 4, descriptorpb.FieldDescriptorProto_TYPE_SINT32, "", true),
+					field("attrs", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".example.Log.AttrsEntry", true),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name: proto.String("AttrsEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+						field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+			},
+		},
+	}
+	serialized, err := proto.Marshal(file)
+	require.NoError(t, err)
+	srv := newTestSchemaRegistry(t, map[string]registrySchema{
+		"/schemas/ids/3?format=serialized": {Schema: base64.StdEncoding.EncodeToString(serialized), SchemaType: "PROTOBUF"},
+	})
+	decoder, err := newDecoder(scrapeconfig.KafkaDecoder{
+		Type:           scrapeconfig.KafkaDecoderProtobuf,
+		SchemaRegistry: scrapeconfig.KafkaSchemaRegistry{URL: srv.URL},
+	})
+	require.NoError(t, err)
+
+	var ts []byte
+	ts = protowire.AppendVarint(protowire.AppendTag(ts, 1, protowire.VarintType), 1622548800)
+	ts = protowire.AppendVarint(protowire.AppendTag(ts, 2, protowire.VarintType), 5000000)
+	var packed []byte
+	packed = protowire.AppendVarint(packed, protowire.EncodeZigZag(200))
+	packed = protowire.AppendVarint(packed, protowire.EncodeZigZag(-1))
+	var entry []byte
+	entry = protowire.AppendString(protowire.AppendTag(entry, 1, protowire.BytesType), "app")
+	entry = protowire.AppendString(protowire.AppendTag(entry, 2, protowire.BytesType), "api")
+
+	var msg []byte
+	msg = protowire.AppendBytes(protowire.AppendTag(msg, 1, protowire.BytesType), ts)
+	msg = protowire.AppendVarint(protowire.AppendTag(msg, 2, protowire.VarintType), 1)
+	msg = protowire.AppendString(protowire.AppendTag(msg, 3, protowire.BytesType), "boom")
+	msg = protowire.AppendBytes(protowire.AppendTag(msg, 4, protowire.BytesType), packed)
+	msg = protowire.AppendBytes(protowire.AppendTag(msg, 5, protowire.BytesType), entry)
+	// An unknown field is skipped.
+	msg = protowire.AppendVarint(protowire.AppendTag(msg, 99, protowire.VarintType), 1)
+
+	// The message indexes [1] select the second message of the file.
+	indexes := protowire.AppendVarint(protowire.AppendVarint(nil, protowire.EncodeZigZag(1)), protowire.EncodeZigZag(1))
+	line, err := decoder.Decode(withSchemaID(3, append(indexes, msg...)))
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"ts": "2021-06-01T12:00:00.005Z",
+		"level": "ERROR",
+		"message": "boom",
+		"statusCodes": [200, -1],
+		"attrs": {"app": "api"}
+	}`, line)
+
+	// A single 0 selects the first message of the file.
+	line, err = decoder.Decode(withSchemaID(3, []byte{0}))
+	require.NoError(t, err)
+	require.Equal(t, `{}`, line)
+}
+
+func field(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(number),
+		Type:     typ.Enum(),
+		Label:    label.Enum(),
+		JsonName: proto.String(jsonName(name)),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+func jsonName(name string) string {
+	var out []byte
+	upper := false
+	for i := 0; i < len(name); i++ {
+		if name[i] == '_' {
+			upper = true
+			continue
+		}
+		c := name[i]
+		if upper && c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+func Test_JSONDecoder(t *testing.T) {
+	decoder, err := newDecoder(scrapeconfig.KafkaDecoder{Type: scrapeconfig.KafkaDecoderJSON})
+	require.NoError(t, err)
+
+	line, err := decoder.Decode([]byte(`{"msg":"hello"}`))
+	require.NoError(t, err)
+	require.Equal(t, `{"msg":"hello"}`, line)
+
+	line, err = decoder.Decode(withSchemaID(1, []byte(`{"msg":"hello"}`)))
+	require.NoError(t, err)
+	require.Equal(t, `{"msg":"hello"}`, line)
+
+	_, err = decoder.Decode([]byte(`hello`))
+	require.Error(t, err)
+
+	_, err = newDecoder(scrapeconfig.KafkaDecoder{Type: scrapeconfig.KafkaDecoderAvro})
+	require.Error(t, err, "the schema registry is required")
+}
@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+)
+
+// inflightMessage is a message sent to the clients.
+type inflightMessage struct {
+	message *sarama.ConsumerMessage
+	acked   bool
+}
+
+// ack is the acknowledgement of the entry of an in-flight message.
+type ack struct {
+	message *inflightMessage
+	err     error
+}
+
+// inflight holds the messages of a claim sent to the clients, in offset order, until
+// they and all the messages before them are acknowledged.
+//
+// Entries are acknowledged from the client goroutines, acknowledgements are queued and
+// handled by the claim goroutine, which is the only one marking offsets. The queue can
+// hold an acknowledgement for every in-flight message, so acknowledging never blocks
+// the clients, even once the claim has ended.
+type inflight struct {
+	messages []*inflightMessage
+	acks     chan ack
+}
+
+func newInflight(max int) *inflight {
+	return &inflight{
+		acks: make(chan ack, max),
+	}
+}
+
+// add tracks a message sent to the clients.
+func (f *inflight) add(message *sarama.ConsumerMessage) *inflightMessage {
+	m := &inflightMessage{message: message}
+	f.messages = append(f.messages, m)
+	return m
+}
+
+// ackFunc returns the AckFunc of the entry of the message. The message must be sent
+// again when its entry is acknowledged with an error, so it's acknowledged only once at
+// any time.
+func (f *inflight) ackFunc(m *inflightMessage) api.AckFunc {
+	return func(err error) {
+		f.acks <- ack{message: m, err: err}
+	}
+}
+
+// pop removes and returns the oldest message if it has been acknowledged, nil otherwise.
+func (f *inflight) pop() *sarama.ConsumerMessage {
+	if len(f.messages) == 0 || !f.messages[0].acked {
+		return nil
+	}
+	m := f.messages[0]
+	f.messages[0] = nil
+	f.messages = f.messages[1:]
+	return m.message
+}
+
+func (f *inflight) full() bool {
+	return len(f.messages) >= cap(f.acks)
+}
+
+func (f *inflight) empty() bool {
+	return len(f.messages) == 0
+}
@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"math"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"golang.org/x/time/rate"
+)
+
+// limiter limits the rate of messages and bytes consumed by all the claims of a
+// target. A zero limit means no limit.
+type limiter struct {
+	messages *rate.Limiter
+	bytes    *rate.Limiter
+}
+
+func newLimiter(messagesPerSecond float64, bytesPerSecond int) *limiter {
+	l := &limiter{
+		messages: rate.NewLimiter(rate.Inf, 0),
+		bytes:    rate.NewLimiter(rate.Inf, 0),
+	}
+	if messagesPerSecond > 0 {
+		l.messages = rate.NewLimiter(rate.Limit(messagesPerSecond), int(math.Ceil(messagesPerSecond)))
+	}
+	if bytesPerSecond > 0 {
+		l.bytes = rate.NewLimiter(rate.Limit(bytesPerSecond), bytesPerSecond)
+	}
+	return l
+}
+
+// reserve reserves the consumption of a message and returns how long to wait before
+// sending it. Messages larger than the bytes per second are limited to one per second.
+func (l *limiter) reserve(message *sarama.ConsumerMessage) time.Duration {
+	size := len(message.Value)
+	if burst := l.bytes.Burst(); size > burst {
+		size = burst
+	}
+	now := time.Now()
+	delay := l.messages.ReserveN(now, 1).DelayFrom(now)
+	if d := l.bytes.ReserveN(now, size).DelayFrom(now); d > delay {
+		delay = d
+	}
+	return delay
+}
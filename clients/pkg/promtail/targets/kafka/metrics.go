@@ -0,0 +1,28 @@
+package kafka
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds a set of kafka metrics.
+type Metrics struct {
+	messageDelay *prometheus.HistogramVec
+}
+
+// NewMetrics creates a new set of kafka metrics. If reg is non-nil, the
+// metrics will be registered.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	var m Metrics
+
+	m.messageDelay = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "promtail",
+		Name:      "kafka_message_delay_seconds",
+		Help:      "Delay between the timestamp of the Kafka records and their consumption.",
+		// From 100ms to about 7 hours.
+		Buckets: prometheus.ExponentialBuckets(0.1, 4, 10),
+	}, []string{"topic"})
+
+	if reg != nil {
+		reg.MustRegister(m.messageDelay)
+	}
+
+	return &m
+}
@@ -0,0 +1,160 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+// parseInitialOffset parses an initial offset setting into the time accepted by
+// sarama.Client.GetOffset: sarama.OffsetOldest, sarama.OffsetNewest or a timestamp
+// in milliseconds.
+func parseInitialOffset(s string) (int64, error) {
+	switch s {
+	case "", scrapeconfig.KafkaInitialOffsetOldest:
+		return sarama.OffsetOldest, nil
+	case scrapeconfig.KafkaInitialOffsetNewest:
+		return sarama.OffsetNewest, nil
+	}
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid initial offset %q, must be %s, %s or a RFC3339 timestamp", s, scrapeconfig.KafkaInitialOffsetOldest, scrapeconfig.KafkaInitialOffsetNewest)
+	}
+	return ts.UnixNano() / int64(time.Millisecond), nil
+}
+
+// offsetClient is the part of sarama.Client used to resolve initial offsets.
+type offsetClient interface {
+	GetOffset(topic string, partitionID int32, time int64) (int64, error)
+}
+
+// initialOffsets moves the partitions claimed without a committed offset to the
+// initial offset of their topic. The consumer group starts partitions without a
+// committed offset at the default initial offset when it is the oldest or newest
+// one: a session only needs moving for the other initial offsets.
+type initialOffsets struct {
+	client offsetClient
+	// committed returns the committed offsets of the partitions, -1 when none.
+	committed func(claims map[string][]int32) (map[string]map[int32]int64, error)
+
+	defaultOffset int64
+	topicOffsets  map[string]int64
+}
+
+// newInitialOffsets returns the initial offsets of the consumer group, or nil when
+// every partition starts at the default initial offset of the consumer group.
+func newInitialOffsets(client sarama.Client, groupID string, cfg *scrapeconfig.KafkaTargetConfig) (*initialOffsets, error) {
+	defaultOffset, err := parseInitialOffset(cfg.InitialOffset)
+	if err != nil {
+		return nil, err
+	}
+	topicOffsets := map[string]int64{}
+	for topic, s := range cfg.TopicInitialOffsets {
+		offset, err := parseInitialOffset(s)
+		if err != nil {
+			return nil, fmt.Errorf("topic %s: %w", topic, err)
+		}
+		if offset != defaultOffset {
+			topicOffsets[topic] = offset
+		}
+	}
+	if len(topicOffsets) == 0 && groupInitialOffset(defaultOffset) == defaultOffset {
+		return nil, nil
+	}
+	return &initialOffsets{
+		client: client,
+		committed: func(claims map[string][]int32) (map[string]map[int32]int64, error) {
+			return committedOffsets(client, groupID, claims)
+		},
+		defaultOffset: defaultOffset,
+		topicOffsets:  topicOffsets,
+	}, nil
+}
+
+func (o *initialOffsets) offsetFor(topic string) int64 {
+	if offset, ok := o.topicOffsets[topic]; ok {
+		return offset
+	}
+	return o.defaultOffset
+}
+
+// apply marks the initial offset of the claimed partitions without a committed offset.
+// It must run in the setup of the session, before the claims start consuming.
+func (o *initialOffsets) apply(session sarama.ConsumerGroupSession) error {
+	claims := map[string][]int32{}
+	for topic, partitions := range session.Claims() {
+		if o.offsetFor(topic) != groupInitialOffset(o.defaultOffset) {
+			claims[topic] = partitions
+		}
+	}
+	if len(claims) == 0 {
+		return nil
+	}
+	committed, err := o.committed(claims)
+	if err != nil {
+		return fmt.Errorf("error fetching committed offsets: %w", err)
+	}
+	for topic, partitions := range claims {
+		for _, partition := range partitions {
+			if offset, ok := committed[topic][partition]; ok && offset >= 0 {
+				continue
+			}
+			offset, err := o.client.GetOffset(topic, partition, o.offsetFor(topic))
+			if err != nil {
+				return fmt.Errorf("error getting initial offset of topic %s partition %d: %w", topic, partition, err)
+			}
+			if offset < 0 {
+				// No message was produced after the timestamp.
+				if offset, err = o.client.GetOffset(topic, partition, sarama.OffsetNewest); err != nil {
+					return fmt.Errorf("error getting newest offset of topic %s partition %d: %w", topic, partition, err)
+				}
+			}
+			// Partitions without a committed offset are at the default initial offset of
+			// the consumer group, a negative one, marking moves them forward.
+			session.MarkOffset(topic, partition, offset, "")
+		}
+	}
+	return nil
+}
+
+// groupInitialOffset returns the initial offset used by the consumer group for the
+// partitions without a committed offset: the default one, or the oldest one when the
+// default initial offset is a timestamp.
+func groupInitialOffset(defaultOffset int64) int64 {
+	if defaultOffset == sarama.OffsetNewest {
+		return sarama.OffsetNewest
+	}
+	return sarama.OffsetOldest
+}
+
+// committedOffsets fetches the offsets committed by the consumer group for the partitions.
+func committedOffsets(client sarama.Client, groupID string, claims map[string][]int32) (map[string]map[int32]int64, error) {
+	coordinator, err := client.Coordinator(groupID)
+	if err != nil {
+		return nil, err
+	}
+	req := &sarama.OffsetFetchRequest{Version: 1, ConsumerGroup: groupID}
+	for topic, partitions := range claims {
+		for _, partition := range partitions {
+			req.AddPartition(topic, partition)
+		}
+	}
+	resp, err := coordinator.FetchOffset(req)
+	if err != nil {
+		return nil, err
+	}
+	offsets := map[string]map[int32]int64{}
+	for topic, partitions := range resp.Blocks {
+		offsets[topic] = map[int32]int64{}
+		for partition, block := range partitions {
+			if block.Err != sarama.ErrNoError {
+				return nil, block.Err
+			}
+			offsets[topic][partition] = block.Offset
+		}
+	}
+	return offsets, nil
+}
@@ -0,0 +1,115 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+type claimsSession struct {
+	testSession
+	claims map[string][]int32
+	marked map[string]map[int32]int64
+}
+
+func (s *claimsSession) Claims() map[string][]int32 { return s.claims }
+func (s *claimsSession) MarkOffset(topic string, partition int32, offset int64, _ string) {
+	if s.marked[topic] == nil {
+		s.marked[topic] = map[int32]int64{}
+	}
+	s.marked[topic][partition] = offset
+}
+
+// fakeOffsetClient returns the offset of the time, or 100 for the newest offset.
+type fakeOffsetClient struct{}
+
+func (fakeOffsetClient) GetOffset(_ string, _ int32, t int64) (int64, error) {
+	switch t {
+	case sarama.OffsetNewest:
+		return 100, nil
+	case sarama.OffsetOldest:
+		return 0, nil
+	}
+	if t > 2000 {
+		return -1, nil
+	}
+	return t / 100, nil
+}
+
+func Test_parseInitialOffset(t *testing.T) {
+	for s, expected := range map[string]int64{
+		"":                     sarama.OffsetOldest,
+		"oldest":               sarama.OffsetOldest,
+		"newest":               sarama.OffsetNewest,
+		"1970-01-01T00:00:01Z": 1000,
+	} {
+		offset, err := parseInitialOffset(s)
+		require.NoError(t, err)
+		require.Equal(t, expected, offset, s)
+	}
+	_, err := parseInitialOffset("latest")
+	require.Error(t, err)
+}
+
+func Test_newInitialOffsets(t *testing.T) {
+	o, err := newInitialOffsets(nil, "group", &scrapeconfig.KafkaTargetConfig{InitialOffset: "newest"})
+	require.NoError(t, err)
+	require.Nil(t, o, "the consumer group starts partitions at the newest offset")
+
+	o, err = newInitialOffsets(nil, "group", &scrapeconfig.KafkaTargetConfig{
+		InitialOffset:       "newest",
+		TopicInitialOffsets: map[string]string{"foo": "newest", "bar": "oldest"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"bar": sarama.OffsetOldest}, o.topicOffsets)
+	require.Equal(t, sarama.OffsetNewest, groupInitialOffset(o.defaultOffset))
+
+	o, err = newInitialOffsets(nil, "group", &scrapeconfig.KafkaTargetConfig{InitialOffset: "1970-01-01T00:00:01Z"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), o.defaultOffset)
+	require.Equal(t, sarama.OffsetOldest, groupInitialOffset(o.defaultOffset))
+}
+
+func Test_initialOffsetsApply(t *testing.T) {
+	var fetched map[string][]int32
+	o := &initialOffsets{
+		client: fakeOffsetClient{},
+		committed: func(claims map[string][]int32) (map[string]map[int32]int64, error) {
+			fetched = claims
+			return map[string]map[int32]int64{
+				"foo": {0: 42, 1: -1},
+				"bar": {0: -1},
+			}, nil
+		},
+		defaultOffset: time.Unix(1, 0).UnixNano() / int64(time.Millisecond),
+		topicOffsets: map[string]int64{
+			"bar":    sarama.OffsetNewest,
+			"oldest": sarama.OffsetOldest,
+			"later":  3000,
+		},
+	}
+	session := &claimsSession{
+		claims: map[string][]int32{
+			"foo":    {0, 1},
+			"bar":    {0},
+			"oldest": {0},
+			"later":  {0},
+		},
+		marked: map[string]map[int32]int64{},
+	}
+	require.NoError(t, o.apply(session))
+
+	// Partitions starting at the oldest offset, where the consumer group starts them, aren't fetched.
+	require.NotContains(t, fetched, "oldest")
+	require.Equal(t, map[string]map[int32]int64{
+		// foo/0 has a committed offset.
+		"foo": {1: 10},
+		"bar": {0: 100},
+		// No message was produced after the timestamp.
+		"later": {0: 100},
+	}, session.marked)
+}
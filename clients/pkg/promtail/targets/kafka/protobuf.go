@@ -0,0 +1,270 @@
+package kafka
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var errInvalidProtobufValue = errors.New("invalid protobuf value")
+
+// protobufField is an occurrence of a field in an encoded message.
+type protobufField struct {
+	typ    protowire.Type
+	scalar uint64
+	bytes  []byte
+}
+
+// decodeProtobuf writes the JSON of the protobuf encoded message to the stream, with
+// the fields in declaration order and named after their JSON name. Fields with an
+// unknown number are skipped, 64-bit integers are written as numbers, bytes as base64
+// strings, enums as the name of their value and timestamps as RFC3339 strings.
+func decodeProtobuf(md protoreflect.MessageDescriptor, b []byte, stream *jsoniter.Stream) error {
+	if md.FullName() == "google.protobuf.Timestamp" {
+		return decodeProtobufTimestamp(md, b, stream)
+	}
+	fields, err := readProtobufFields(md, b)
+	if err != nil {
+		return err
+	}
+
+	stream.WriteObjectStart()
+	first := true
+	for i := 0; i < md.Fields().Len(); i++ {
+		fd := md.Fields().Get(i)
+		occurrences, ok := fields[fd.Number()]
+		if !ok {
+			continue
+		}
+		if !first {
+			stream.WriteMore()
+		}
+		first = false
+		stream.WriteObjectField(fd.JSONName())
+
+		switch {
+		case fd.IsMap():
+			err = decodeProtobufMap(fd, occurrences, stream)
+		case fd.IsList():
+			err = decodeProtobufList(fd, occurrences, stream)
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			// The occurrences of an embedded message are merged, as if concatenated.
+			var merged []byte
+			for _, o := range occurrences {
+				merged = append(merged, o.bytes...)
+			}
+			err = decodeProtobuf(fd.Message(), merged, stream)
+		default:
+			// The last occurrence of a scalar wins.
+			err = decodeProtobufScalar(fd, occurrences[len(occurrences)-1], stream)
+		}
+		if err != nil {
+			return fmt.Errorf("field %s: %w", fd.FullName(), err)
+		}
+	}
+	stream.WriteObjectEnd()
+	return nil
+}
+
+// readProtobufFields returns the occurrences of the known fields of the message.
+func readProtobufFields(md protoreflect.MessageDescriptor, b []byte) (map[protowire.Number][]protobufField, error) {
+	fields := map[protowire.Number][]protobufField{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var f protobufField
+		f.typ = typ
+		switch typ {
+		case protowire.VarintType:
+			f.scalar, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.scalar = uint64(v)
+		case protowire.Fixed64Type:
+			f.scalar, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		case protowire.StartGroupType:
+			f.bytes, n = protowire.ConsumeGroup(num, b)
+		default:
+			return nil, errInvalidProtobufValue
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if md.Fields().ByNumber(num) != nil {
+			fields[num] = append(fields[num], f)
+		}
+	}
+	return fields, nil
+}
+
+func decodeProtobufList(fd protoreflect.FieldDescriptor, occurrences []protobufField, stream *jsoniter.Stream) error {
+	stream.WriteArrayStart()
+	first := true
+	write := func(f protobufField) error {
+		if !first {
+			stream.WriteMore()
+		}
+		first = false
+		if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+			return decodeProtobuf(fd.Message(), f.bytes, stream)
+		}
+		return decodeProtobufScalar(fd, f, stream)
+	}
+	for _, o := range occurrences {
+		if o.typ != protowire.BytesType || fd.Kind() == protoreflect.StringKind || fd.Kind() == protoreflect.BytesKind || fd.Kind() == protoreflect.MessageKind {
+			if err := write(o); err != nil {
+				return err
+			}
+			continue
+		}
+		// Packed scalars.
+		for b := o.bytes; len(b) > 0; {
+			f, n := consumePackedScalar(fd.Kind(), b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if err := write(f); err != nil {
+				return err
+			}
+			b = b[n:]
+		}
+	}
+	stream.WriteArrayEnd()
+	return nil
+}
+
+func consumePackedScalar(kind protoreflect.Kind, b []byte) (protobufField, int) {
+	switch kind {
+	case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind, protoreflect.FloatKind:
+		v, n := protowire.ConsumeFixed32(b)
+		return protobufField{typ: protowire.Fixed32Type, scalar: uint64(v)}, n
+	case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind, protoreflect.DoubleKind:
+		v, n := protowire.ConsumeFixed64(b)
+		return protobufField{typ: protowire.Fixed64Type, scalar: v}, n
+	}
+	v, n := protowire.ConsumeVarint(b)
+	return protobufField{typ: protowire.VarintType, scalar: v}, n
+}
+
+// decodeProtobufMap writes the entries of a map field, messages with a key and a value field.
+func decodeProtobufMap(fd protoreflect.FieldDescriptor, occurrences []protobufField, stream *jsoniter.Stream) error {
+	entry := fd.Message()
+	keyField, valueField := fd.MapKey(), fd.MapValue()
+	stream.WriteObjectStart()
+	for i, o := range occurrences {
+		fields, err := readProtobufFields(entry, o.bytes)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			stream.WriteMore()
+		}
+		key := ""
+		if keys := fields[keyField.Number()]; len(keys) > 0 {
+			k := keys[len(keys)-1]
+			if keyField.Kind() == protoreflect.StringKind {
+				key = string(k.bytes)
+			} else {
+				key = protobufScalarString(keyField, k)
+			}
+		}
+		stream.WriteObjectField(key)
+
+		values := fields[valueField.Number()]
+		switch {
+		case valueField.Kind() == protoreflect.MessageKind:
+			var merged []byte
+			for _, v := range values {
+				merged = append(merged, v.bytes...)
+			}
+			err = decodeProtobuf(valueField.Message(), merged, stream)
+		case len(values) == 0:
+			// A missing value is the zero value.
+			err = decodeProtobufScalar(valueField, protobufField{}, stream)
+		default:
+			err = decodeProtobufScalar(valueField, values[len(values)-1], stream)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	stream.WriteObjectEnd()
+	return nil
+}
+
+// protobufScalarString returns the value of an integer or boolean map key as a string.
+func protobufScalarString(fd protoreflect.FieldDescriptor, f protobufField) string {
+	stream := jsoniter.NewStream(jsoniter.ConfigDefault, nil, 32)
+	if err := decodeProtobufScalar(fd, f, stream); err != nil {
+		return ""
+	}
+	return string(stream.Buffer())
+}
+
+func decodeProtobufScalar(fd protoreflect.FieldDescriptor, f protobufField, stream *jsoniter.Stream) error {
+	v := f.scalar
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		stream.WriteBool(protowire.DecodeBool(v))
+	case protoreflect.Int32Kind, protoreflect.Sfixed32Kind:
+		stream.WriteInt32(int32(v))
+	case protoreflect.Int64Kind, protoreflect.Sfixed64Kind:
+		stream.WriteInt64(int64(v))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		stream.WriteUint32(uint32(v))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		stream.WriteUint64(v)
+	case protoreflect.Sint32Kind:
+		stream.WriteInt32(int32(protowire.DecodeZigZag(v & math.MaxUint32)))
+	case protoreflect.Sint64Kind:
+		stream.WriteInt64(protowire.DecodeZigZag(v))
+	case protoreflect.FloatKind:
+		writeFloat(stream, float64(math.Float32frombits(uint32(v))))
+	case protoreflect.DoubleKind:
+		writeFloat(stream, math.Float64frombits(v))
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(protoreflect.EnumNumber(int32(v))); ev != nil {
+			stream.WriteString(string(ev.Name()))
+		} else {
+			stream.WriteInt32(int32(v))
+		}
+	case protoreflect.StringKind:
+		stream.WriteString(string(f.bytes))
+	case protoreflect.BytesKind:
+		stream.WriteString(base64.StdEncoding.EncodeToString(f.bytes))
+	default:
+		return fmt.Errorf("unsupported protobuf kind %s", fd.Kind())
+	}
+	return nil
+}
+
+// decodeProtobufTimestamp writes a google.protobuf.Timestamp as a RFC3339 string.
+func decodeProtobufTimestamp(md protoreflect.MessageDescriptor, b []byte, stream *jsoniter.Stream) error {
+	fields, err := readProtobufFields(md, b)
+	if err != nil {
+		return err
+	}
+	var seconds, nanos int64
+	if s := fields[1]; len(s) > 0 {
+		seconds = int64(s[len(s)-1].scalar)
+	}
+	if n := fields[2]; len(n) > 0 {
+		nanos = int64(int32(n[len(n)-1].scalar))
+	}
+	stream.WriteString(time.Unix(seconds, nanos).UTC().Format(time.RFC3339Nano))
+	return nil
+}
@@ -0,0 +1,250 @@
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	promconfig "github.com/prometheus/common/config"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	// Well known types imported by schemas.
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/tlspolicy"
+)
+
+// errRegistryUnavailable is returned when the schema registry can't be reached or fails
+// to answer. Unlike invalid values, the messages can be decoded once it's back.
+var errRegistryUnavailable = errors.New("schema registry unavailable")
+
+// registrySchema is a schema of the Confluent Schema Registry.
+type registrySchema struct {
+	Schema     string              `json:"schema"`
+	SchemaType string              `json:"schemaType"`
+	References []registryReference `json:"references"`
+}
+
+// registryReference references a schema defining types used by another one. Its name is
+// the full name of an Avro type, or the import path of a protobuf file.
+type registryReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// schemaRegistry fetches schemas by ID from a Confluent Schema Registry. Schemas are
+// immutable, they are cached forever once parsed.
+type schemaRegistry struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+
+	mtx      sync.Mutex
+	avro     map[int]*avroSchema
+	protobuf map[int]protoreflect.FileDescriptor
+}
+
+func newSchemaRegistry(cfg scrapeconfig.KafkaSchemaRegistry) (*schemaRegistry, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("schema registry url is required")
+	}
+	client, err := tlspolicy.NewHTTPClient(promconfig.HTTPClientConfig{TLSConfig: cfg.TLSConfig}, "promtail-kafka-schema-registry", tlspolicy.HTTPClientOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &schemaRegistry{
+		url:      strings.TrimSuffix(cfg.URL, "/"),
+		username: cfg.Username,
+		password: cfg.Password.Value,
+		client:   client,
+		avro:     map[int]*avroSchema{},
+		protobuf: map[int]protoreflect.FileDescriptor{},
+	}, nil
+}
+
+func (r *schemaRegistry) get(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, r.url+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRegistryUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5 {
+		return fmt.Errorf("%w: unexpected status %s fetching %s", errRegistryUnavailable, resp.Status, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s fetching %s from the schema registry", resp.Status, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (r *schemaRegistry) schemaByID(id int, serialized bool) (registrySchema, error) {
+	var s registrySchema
+	path := fmt.Sprintf("/schemas/ids/%d", id)
+	if serialized {
+		path += "?format=serialized"
+	}
+	return s, r.get(path, &s)
+}
+
+func (r *schemaRegistry) schemaByReference(ref registryReference, serialized bool) (registrySchema, error) {
+	var s registrySchema
+	path := fmt.Sprintf("/subjects/%s/versions/%d", url.PathEscape(ref.Subject), ref.Version)
+	if serialized {
+		path += "?format=serialized"
+	}
+	return s, r.get(path, &s)
+}
+
+// avroSchema returns the Avro schema with the ID, with the types of the schemas it references.
+func (r *schemaRegistry) avroSchema(id int) (*avroSchema, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if s, ok := r.avro[id]; ok {
+		return s, nil
+	}
+	s, err := r.schemaByID(id, false)
+	if err != nil {
+		return nil, err
+	}
+	if s.SchemaType != "" && s.SchemaType != "AVRO" {
+		return nil, fmt.Errorf("schema %d is a %s schema, not an avro one", id, s.SchemaType)
+	}
+	names := avroNames{}
+	if err := r.parseAvroReferences(s.References, names, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	schema, err := parseAvroSchema(s.Schema, names)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	r.avro[id] = schema
+	return schema, nil
+}
+
+func (r *schemaRegistry) parseAvroReferences(refs []registryReference, names avroNames, seen map[string]bool) error {
+	for _, ref := range refs {
+		key := fmt.Sprintf("%s/%d", ref.Subject, ref.Version)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		s, err := r.schemaByReference(ref, false)
+		if err != nil {
+			return err
+		}
+		if err := r.parseAvroReferences(s.References, names, seen); err != nil {
+			return err
+		}
+		if _, err := parseAvroSchema(s.Schema, names); err != nil {
+			return fmt.Errorf("referenced schema %s: %w", ref.Name, err)
+		}
+	}
+	return nil
+}
+
+// protobufSchema returns the protobuf file with the ID, built with the files it imports.
+// Files are fetched as serialized file descriptors, well known types are built in.
+func (r *schemaRegistry) protobufSchema(id int) (protoreflect.FileDescriptor, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if fd, ok := r.protobuf[id]; ok {
+		return fd, nil
+	}
+	s, err := r.schemaByID(id, true)
+	if err != nil {
+		return nil, err
+	}
+	if s.SchemaType != "PROTOBUF" {
+		return nil, fmt.Errorf("schema %d is not a protobuf schema", id)
+	}
+	files := &protoregistry.Files{}
+	if err := r.registerProtobufReferences(s.References, files); err != nil {
+		return nil, err
+	}
+	fd, err := newProtobufFile(s.Schema, files)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	r.protobuf[id] = fd
+	return fd, nil
+}
+
+func (r *schemaRegistry) registerProtobufReferences(refs []registryReference, files *protoregistry.Files) error {
+	for _, ref := range refs {
+		if _, err := files.FindFileByPath(ref.Name); err == nil {
+			continue
+		}
+		s, err := r.schemaByReference(ref, true)
+		if err != nil {
+			return err
+		}
+		if err := r.registerProtobufReferences(s.References, files); err != nil {
+			return err
+		}
+		fd, err := newProtobufFile(s.Schema, files)
+		if err != nil {
+			return fmt.Errorf("referenced schema %s: %w", ref.Name, err)
+		}
+		if err := files.RegisterFile(fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newProtobufFile builds a protobuf file from its base64 serialized descriptor.
+func newProtobufFile(serialized string, files *protoregistry.Files) (protoreflect.FileDescriptor, error) {
+	b, err := base64.StdEncoding.DecodeString(serialized)
+	if err != nil {
+		return nil, fmt.Errorf("invalid serialized protobuf schema: %w", err)
+	}
+	var fdp descriptorpb.FileDescriptorProto
+	if err := proto.Unmarshal(b, &fdp); err != nil {
+		return nil, fmt.Errorf("invalid serialized protobuf schema: %w", err)
+	}
+	return protodesc.NewFile(&fdp, protobufResolver{files})
+}
+
+// protobufResolver resolves the imports of protobuf files with the referenced files,
+// then with the well known types linked in the binary.
+type protobufResolver struct {
+	files *protoregistry.Files
+}
+
+func (r protobufResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.files.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r protobufResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := r.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
@@ -1,16 +1,25 @@
 package kafka
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/prometheus/prometheus/util/strutil"
 
 	"github.com/Shopify/sarama"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
 	"github.com/prometheus/common/model"
 
 	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
 
 	"github.com/grafana/loki/pkg/logproto"
@@ -31,9 +40,20 @@ type Target struct {
 	details              ConsumerDetails
 	claim                sarama.ConsumerGroupClaim
 	session              sarama.ConsumerGroupSession
-	client               api.EntryHandler
+	clients              []api.EntryHandler
 	relabelConfig        []*relabel.Config
 	useIncomingTimestamp bool
+	committer            *committer
+	inflight             *inflight
+	limiter              *limiter
+	decoder              Decoder
+	registryBackoff      *backoff.Backoff
+	metrics              *Metrics
+	logger               log.Logger
+
+	// drainTimeout is how long acknowledgements are awaited once the claim has ended.
+	drainTimeout time.Duration
+	next         int
 }
 
 func NewTarget(
@@ -41,8 +61,15 @@ func NewTarget(
 	claim sarama.ConsumerGroupClaim,
 	discoveredLabels, lbs model.LabelSet,
 	relabelConfig []*relabel.Config,
-	client api.EntryHandler,
+	clients []api.EntryHandler,
 	useIncomingTimestamp bool,
+	commitStrategy scrapeconfig.KafkaCommitStrategy,
+	maxUncommittedMessages int,
+	maxInflightMessages int,
+	limiter *limiter,
+	decoder Decoder,
+	metrics *Metrics,
+	logger log.Logger,
 ) *Target {
 	return &Target{
 		discoveredLabels:     discoveredLabels,
@@ -50,44 +77,231 @@ func NewTarget(
 		details:              newDetails(session, claim),
 		claim:                claim,
 		session:              session,
-		client:               client,
+		clients:              clients,
 		relabelConfig:        relabelConfig,
 		useIncomingTimestamp: useIncomingTimestamp,
+		committer:            newCommitter(session, commitStrategy, maxUncommittedMessages),
+		inflight:             newInflight(maxInflightMessages),
+		limiter:              limiter,
+		decoder:              decoder,
+		registryBackoff:      backoff.New(session.Context(), registryBackoff),
+		metrics:              metrics,
+		logger:               logger,
+		drainTimeout:         defaultDrainTimeout,
 	}
 }
 
 const (
-	defaultKafkaMessageKey  = "none"
-	labelKeyKafkaMessageKey = "__meta_kafka_message_key"
+	defaultKafkaMessageKey        = "none"
+	labelKeyKafkaMessageKey       = "__meta_kafka_message_key"
+	labelKeyKafkaMessageTimestamp = "__meta_kafka_message_timestamp"
+	labelPrefixKafkaHeader        = "__meta_kafka_header_"
+	labelPrefixKafkaMessage       = "__meta_kafka_"
+
+	defaultDrainTimeout = 30 * time.Second
 )
 
+// registryBackoff spaces the decoding attempts of the messages while the schema registry is unavailable.
+var registryBackoff = backoff.Config{
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 30 * time.Second,
+}
+
+// messageLabels returns the labels describing a message: its key, timestamp and headers.
+func messageLabels(message *sarama.ConsumerMessage) labels.Labels {
+	mk := string(message.Key)
+	if len(mk) == 0 {
+		mk = defaultKafkaMessageKey
+	}
+	lbs := labels.Labels{{Name: labelKeyKafkaMessageKey, Value: mk}}
+	// Timestamps are only set from Kafka 0.10.
+	if !message.Timestamp.IsZero() {
+		lbs = append(lbs, labels.Label{Name: labelKeyKafkaMessageTimestamp, Value: message.Timestamp.UTC().Format(time.RFC3339Nano)})
+	}
+	for _, h := range message.Headers {
+		if h == nil || len(h.Key) == 0 {
+			continue
+		}
+		lbs = append(lbs, labels.Label{Name: labelPrefixKafkaHeader + strutil.SanitizeLabelName(string(h.Key)), Value: string(h.Value)})
+	}
+	sort.Sort(lbs)
+	return lbs
+}
+
+// dropMessageLabels removes the message labels once the pipeline stages have run.
+func dropMessageLabels(e api.Entry) api.Entry {
+	lbs := make(model.LabelSet, len(e.Labels))
+	for name, value := range e.Labels {
+		if !strings.HasPrefix(string(name), labelPrefixKafkaMessage) {
+			lbs[name] = value
+		}
+	}
+	e.Labels = lbs
+	return e
+}
+
+// run sends the messages of the claim to the clients. Offsets are only marked once the
+// entries of the message and of all the messages before it have been acknowledged, at most
+// maxInflightMessages are waiting for their acknowledgement. Consuming the claim pauses
+// while the window is full or the rate limits are exceeded.
 func (t *Target) run() {
-	defer t.client.Stop()
-	for message := range t.claim.Messages() {
-		mk := string(message.Key)
-		if len(mk) == 0 {
-			mk = defaultKafkaMessageKey
+	defer func() {
+		for _, c := range t.clients {
+			c.Stop()
+		}
+	}()
+	var (
+		// limited is the message waiting for the rate limits, sent once ready fires.
+		limited *sarama.ConsumerMessage
+		ready   <-chan time.Time
+		timer   = time.NewTimer(0)
+	)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	messages := t.claim.Messages()
+	for messages != nil {
+		in := messages
+		if t.inflight.full() || limited != nil {
+			in = nil
+		}
+		select {
+		case message, ok := <-in:
+			if !ok {
+				messages = nil
+				break
+			}
+			t.observeDelay(message)
+			if delay := t.limiter.reserve(message); delay > 0 {
+				limited = message
+				timer.Reset(delay)
+				ready = timer.C
+				continue
+			}
+			t.send(t.inflight.add(message))
+		case <-ready:
+			t.send(t.inflight.add(limited))
+			limited, ready = nil, nil
+		case a := <-t.inflight.acks:
+			if a.err != nil {
+				if !errors.Is(a.err, errRegistryUnavailable) {
+					level.Warn(t.logger).Log("msg", "sending message again, it could not be pushed", "topic", a.message.message.Topic, "partition", a.message.message.Partition, "offset", a.message.message.Offset, "err", a.err)
+				}
+				t.send(a.message)
+				continue
+			}
+			t.acknowledge(a.message)
 		}
+	}
+	t.drain()
+}
 
-		// TODO: Possibly need to format after merging with discovered labels because we can specify multiple labels in source labels
-		// https://github.com/grafana/loki/pull/4745#discussion_r750022234
-		lbs := format([]labels.Label{{
-			Name:  labelKeyKafkaMessageKey,
-			Value: mk,
-		}}, t.relabelConfig)
+// observeDelay records the delay between the timestamp of the message and its consumption.
+// Timestamps are only set from Kafka 0.10.
+func (t *Target) observeDelay(message *sarama.ConsumerMessage) {
+	if message.Timestamp.IsZero() {
+		return
+	}
+	delay := time.Since(message.Timestamp)
+	if delay < 0 {
+		// The clocks of the producer and of promtail are not in sync.
+		delay = 0
+	}
+	t.metrics.messageDelay.WithLabelValues(message.Topic).Observe(delay.Seconds())
+}
 
-		out := t.lbs.Clone()
-		if len(lbs) > 0 {
-			out = out.Merge(lbs)
+// drain waits for the acknowledgement of the in-flight messages once the claim has ended.
+// The messages not acknowledged in time are consumed again by the next owner of the partition.
+func (t *Target) drain() {
+	timeout := time.NewTimer(t.drainTimeout)
+	defer timeout.Stop()
+	for !t.inflight.empty() {
+		select {
+		case a := <-t.inflight.acks:
+			if a.err != nil {
+				level.Warn(t.logger).Log("msg", "claim ended with messages that could not be pushed, they will be consumed again", "topic", t.claim.Topic(), "partition", t.claim.Partition(), "offset", a.message.message.Offset, "err", a.err)
+				return
+			}
+			t.acknowledge(a.message)
+		case <-timeout.C:
+			level.Warn(t.logger).Log("msg", "claim ended with unacknowledged messages, they will be consumed again", "topic", t.claim.Topic(), "partition", t.claim.Partition(), "count", len(t.inflight.messages))
+			return
 		}
-		t.client.Chan() <- api.Entry{
-			Entry: logproto.Entry{
-				Line:      string(message.Value),
-				Timestamp: timestamp(t.useIncomingTimestamp, message.Timestamp),
-			},
-			Labels: out,
+	}
+}
+
+// acknowledge marks the offsets of the messages acknowledged with all the messages before them.
+func (t *Target) acknowledge(m *inflightMessage) {
+	m.acked = true
+	for message := t.inflight.pop(); message != nil; message = t.inflight.pop() {
+		t.committer.mark(message, t.inflight.empty() && len(t.claim.Messages()) == 0)
+	}
+}
+
+// send sends the entry of an in-flight message to a client.
+func (t *Target) send(m *inflightMessage) {
+	message := m.message
+	line, err := t.decoder.Decode(message.Value)
+	if errors.Is(err, errRegistryUnavailable) {
+		// The message is acknowledged with the error after a delay to be decoded again, its
+		// offset is not marked meanwhile.
+		delay := t.registryBackoff.NextDelay()
+		level.Warn(t.logger).Log("msg", "message can't be decoded while the schema registry is unavailable, retrying", "topic", message.Topic, "partition", message.Partition, "offset", message.Offset, "retry_in", delay, "err", err)
+		ack, ctx := t.inflight.ackFunc(m), t.session.Context()
+		go func() {
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+			}
+			ack(err)
+		}()
+		return
+	}
+	t.registryBackoff.Reset()
+	if err != nil {
+		level.Warn(t.logger).Log("msg", "dropping message that can't be decoded", "topic", message.Topic, "partition", message.Partition, "offset", message.Offset, "err", err)
+		t.acknowledge(m)
+		return
+	}
+	messageLbs := messageLabels(message)
+
+	// TODO: Possibly need to format after merging with discovered labels because we can specify multiple labels in source labels
+	// https://github.com/grafana/loki/pull/4745#discussion_r750022234
+	lbs := format(messageLbs, t.relabelConfig)
+
+	out := t.lbs.Clone()
+	if len(lbs) > 0 {
+		out = out.Merge(lbs)
+	}
+	// The message labels are visible to the pipeline stages, they are dropped
+	// before the entry is sent.
+	for _, l := range messageLbs {
+		out[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	}
+	// Messages with the same key always go to the same client to keep their order,
+	// messages without a key are spread over all clients.
+	client := t.clients[0]
+	if n := len(t.clients); n > 1 {
+		if len(message.Key) == 0 {
+			client = t.clients[t.next%n]
+			t.next++
+		} else {
+			h := fnv.New32a()
+			_, _ = h.Write(message.Key)
+			client = t.clients[h.Sum32()%uint32(n)]
 		}
-		t.session.MarkMessage(message, "")
+	}
+	client.Chan() <- api.Entry{
+		Entry: logproto.Entry{
+			Line:      line,
+			Timestamp: timestamp(t.useIncomingTimestamp, message.Timestamp),
+		},
+		Labels: out,
+		Ack:    t.inflight.ackFunc(m),
 	}
 }
 
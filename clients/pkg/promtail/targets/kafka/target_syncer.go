@@ -13,6 +13,7 @@ import (
 	"github.com/grafana/loki/clients/pkg/logentry/stages"
 	"github.com/grafana/loki/clients/pkg/promtail/api"
 	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/diskbuffer"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
@@ -23,17 +24,47 @@ import (
 
 var TopicPollInterval = 30 * time.Second
 
+const defaultMaxInflightMessages = 1000
+
 type TopicManager interface {
 	Topics() ([]string, error)
+	// Partitions returns the address of the leader broker of each partition of the topic.
+	Partitions(topic string) (map[int32]string, error)
+}
+
+// topicMetadata is the leader of each partition of each consumed topic.
+type topicMetadata map[string]map[int32]string
+
+func (m topicMetadata) equal(other topicMetadata) bool {
+	if len(m) != len(other) {
+		return false
+	}
+	for topic, leaders := range m {
+		otherLeaders, ok := other[topic]
+		if !ok || len(leaders) != len(otherLeaders) {
+			return false
+		}
+		for p, leader := range leaders {
+			if otherLeaders[p] != leader {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 type TargetSyncer struct {
-	logger log.Logger
-	cfg    scrapeconfig.Config
-	reg    prometheus.Registerer
-	client api.EntryHandler
+	logger  log.Logger
+	cfg     scrapeconfig.Config
+	reg     prometheus.Registerer
+	metrics *Metrics
+	client  api.EntryHandler
+	// buffer is the disk buffer between the targets and the push client, if enabled.
+	buffer *diskbuffer.Buffer
 
 	topicManager TopicManager
+	decoder      Decoder
+	limiter      *limiter
 	consumer
 	close func() error
 
@@ -41,10 +72,14 @@ type TargetSyncer struct {
 	cancel         context.CancelFunc
 	wg             sync.WaitGroup
 	previousTopics []string
+
+	metadataMtx sync.RWMutex
+	metadata    topicMetadata
 }
 
 func NewSyncer(
 	reg prometheus.Registerer,
+	metrics *Metrics,
 	logger log.Logger,
 	cfg scrapeconfig.Config,
 	pushClient api.EntryHandler,
@@ -56,9 +91,24 @@ func NewSyncer(
 	if err != nil {
 		return nil, err
 	}
+	decoder, err := newDecoder(cfg.KafkaConfig.Decoder)
+	if err != nil {
+		return nil, fmt.Errorf("error creating message decoder: %w", err)
+	}
 	config := sarama.NewConfig()
 	config.Version = version
-	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	defaultOffset, err := parseInitialOffset(cfg.KafkaConfig.InitialOffset)
+	if err != nil {
+		return nil, err
+	}
+	config.Consumer.Offsets.Initial = groupInitialOffset(defaultOffset)
+	if cfg.KafkaConfig.IsolationLevel == scrapeconfig.KafkaIsolationLevelReadCommitted {
+		config.Consumer.IsolationLevel = sarama.ReadCommitted
+	}
+	config.Consumer.Offsets.AutoCommit.Enable = cfg.KafkaConfig.CommitStrategy == scrapeconfig.KafkaCommitStrategyPeriodic
+	if config.Consumer.Offsets.AutoCommit.Enable {
+		config.Consumer.Offsets.AutoCommit.Interval = cfg.KafkaConfig.CommitInterval
+	}
 
 	switch cfg.KafkaConfig.Assignor {
 	case sarama.StickyBalanceStrategyName:
@@ -82,10 +132,22 @@ func NewSyncer(
 	if err != nil {
 		return nil, fmt.Errorf("error creating consumer group client: %w", err)
 	}
-	topicManager, err := newTopicManager(client, cfg.KafkaConfig.Topics)
+	topicManager, err := newTopicManager(client, cfg.KafkaConfig.Topics, cfg.KafkaConfig.ExcludeTopics)
 	if err != nil {
 		return nil, fmt.Errorf("error creating topic manager: %w", err)
 	}
+	initialOffsets, err := newInitialOffsets(client, cfg.KafkaConfig.GroupID, cfg.KafkaConfig)
+	if err != nil {
+		return nil, err
+	}
+	var buffer *diskbuffer.Buffer
+	if cfg.KafkaConfig.DiskBuffer != nil {
+		buffer, err = diskbuffer.New(*cfg.KafkaConfig.DiskBuffer, cfg.JobName, pushClient, diskbuffer.NewMetrics(reg), logger)
+		if err != nil {
+			return nil, err
+		}
+		pushClient = buffer
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 
 	t := &TargetSyncer{
@@ -93,9 +155,13 @@ func NewSyncer(
 		ctx:          ctx,
 		cancel:       cancel,
 		topicManager: topicManager,
+		decoder:      decoder,
+		limiter:      newLimiter(cfg.KafkaConfig.MaxMessagesPerSecond, cfg.KafkaConfig.MaxBytesPerSecond.Val()),
 		cfg:          cfg,
 		reg:          reg,
+		metrics:      metrics,
 		client:       pushClient,
+		buffer:       buffer,
 		close: func() error {
 			if err := group.Close(); err != nil {
 				level.Warn(logger).Log("msg", "error while closing consumer group", "err", err)
@@ -103,10 +169,11 @@ func NewSyncer(
 			return client.Close()
 		},
 		consumer: consumer{
-			ctx:           context.Background(),
-			cancel:        func() {},
-			ConsumerGroup: group,
-			logger:        logger,
+			ctx:            context.Background(),
+			cancel:         func() {},
+			ConsumerGroup:  group,
+			logger:         logger,
+			initialOffsets: initialOffsets,
 		},
 	}
 	t.discoverer = t
@@ -152,6 +219,8 @@ func withSASLAuthentication(cfg sarama.Config, authCfg scrapeconfig.KafkaAuthent
 		sarama.SASLTypeSCRAMSHA512,
 		sarama.SASLTypeSCRAMSHA256,
 		sarama.SASLTypePlaintext,
+		sarama.SASLTypeOAuth,
+		sarama.SASLTypeGSSAPI,
 	}
 	if !util.StringSliceContains(supportedMechanism, string(authCfg.SASLConfig.Mechanism)) {
 		return nil, fmt.Errorf("error unsupported sasl mechanism: %s", authCfg.SASLConfig.Mechanism)
@@ -171,6 +240,16 @@ func withSASLAuthentication(cfg sarama.Config, authCfg scrapeconfig.KafkaAuthent
 			}
 		}
 	}
+	if cfg.Net.SASL.Mechanism == sarama.SASLTypeOAuth {
+		tp, err := newTokenProvider(authCfg.SASLConfig.OAuthConfig)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Net.SASL.TokenProvider = tp
+	}
+	if cfg.Net.SASL.Mechanism == sarama.SASLTypeGSSAPI {
+		cfg.Net.SASL.GSSAPI = gssapiConfig(authCfg.SASLConfig)
+	}
 	if authCfg.SASLConfig.UseTLS {
 		tc, err := createTLSConfig(authCfg.SASLConfig.TLSConfig)
 		if err != nil {
@@ -192,7 +271,7 @@ func (ts *TargetSyncer) loop() {
 			case <-ts.ctx.Done():
 				return
 			case topics := <-topicChanged:
-				level.Info(ts.logger).Log("msg", "new topics received", "topics", fmt.Sprintf("%+v", topics))
+				level.Info(ts.logger).Log("msg", "new topics or partitions received", "topics", fmt.Sprintf("%+v", topics))
 				ts.stop()
 				if len(topics) > 0 { // no topics we don't need to start.
 					ts.start(ts.ctx, topics)
@@ -230,29 +309,63 @@ func (ts *TargetSyncer) loop() {
 	}()
 }
 
-// fetchTopics fetches and return new topics, if there's a difference with previous found topics
-// it will return true as second return value.
+// fetchTopics fetches and return new topics, if there's a difference with previous found topics,
+// or with the partitions or partition leaders of the topics, it will return true as second return
+// value. The consumer is then restarted, which rebalances the partitions and refreshes the labels
+// of the targets.
 func (ts *TargetSyncer) fetchTopics() ([]string, bool, error) {
 	new, err := ts.topicManager.Topics()
 	if err != nil {
 		return nil, false, err
 	}
-	if len(ts.previousTopics) != len(new) {
-		ts.previousTopics = new
-		return new, true, nil
-	}
-	for i, v := range ts.previousTopics {
-		if v != new[i] {
-			ts.previousTopics = new
-			return new, true, nil
+	metadata := make(topicMetadata, len(new))
+	for _, topic := range new {
+		leaders, err := ts.topicManager.Partitions(topic)
+		if err != nil {
+			return nil, false, err
 		}
+		metadata[topic] = leaders
+	}
+
+	ts.metadataMtx.Lock()
+	defer ts.metadataMtx.Unlock()
+	changed := len(ts.previousTopics) != len(new)
+	for i := 0; !changed && i < len(new); i++ {
+		changed = ts.previousTopics[i] != new[i]
+	}
+	if !changed && !ts.metadata.equal(metadata) {
+		level.Info(ts.logger).Log("msg", "partitions or partition leaders of the topics changed", "topics", fmt.Sprintf("%+v", new))
+		changed = true
+	}
+	ts.metadata = metadata
+	if !changed {
+		return nil, false, nil
+	}
+	ts.previousTopics = new
+	return new, true, nil
+}
+
+// addMetadataLabels adds the partition count of the topic and the leader of the partition
+// to the labels, when they are known.
+func (ts *TargetSyncer) addMetadataLabels(lbs model.LabelSet, topic string, partition int32) {
+	ts.metadataMtx.RLock()
+	defer ts.metadataMtx.RUnlock()
+	leaders, ok := ts.metadata[topic]
+	if !ok {
+		return
+	}
+	lbs["__meta_kafka_topic_partitions"] = model.LabelValue(fmt.Sprintf("%d", len(leaders)))
+	if leader, ok := leaders[partition]; ok {
+		lbs["__meta_kafka_partition_leader"] = model.LabelValue(leader)
 	}
-	return nil, false, nil
 }
 
 func (ts *TargetSyncer) Stop() error {
 	ts.cancel()
 	ts.wg.Wait()
+	if ts.buffer != nil {
+		ts.buffer.Stop()
+	}
 	return ts.close()
 }
 
@@ -264,6 +377,7 @@ func (ts *TargetSyncer) NewTarget(session sarama.ConsumerGroupSession, claim sar
 		"__meta_kafka_member_id": model.LabelValue(session.MemberID()),
 		"__meta_kafka_group_id":  model.LabelValue(ts.cfg.KafkaConfig.GroupID),
 	}
+	ts.addMetadataLabels(discoveredLabels, claim.Topic(), claim.Partition())
 	details := newDetails(session, claim)
 	labelMap := make(map[string]string)
 	for k, v := range discoveredLabels.Clone().Merge(ts.cfg.KafkaConfig.Labels) {
@@ -285,6 +399,11 @@ func (ts *TargetSyncer) NewTarget(session sarama.ConsumerGroupSession, claim sar
 	if err != nil {
 		return nil, err
 	}
+	// Every worker runs the pipeline stages in its own goroutines.
+	clients := []api.EntryHandler{newWorker(pipeline, ts.client)}
+	for i := 1; i < ts.cfg.KafkaConfig.Workers; i++ {
+		clients = append(clients, newWorker(pipeline, ts.client))
+	}
 
 	t := NewTarget(
 		session,
@@ -292,13 +411,31 @@ func (ts *TargetSyncer) NewTarget(session sarama.ConsumerGroupSession, claim sar
 		discoveredLabels,
 		labelOut,
 		ts.cfg.RelabelConfigs,
-		pipeline.Wrap(ts.client),
+		clients,
 		ts.cfg.KafkaConfig.UseIncomingTimestamp,
+		ts.cfg.KafkaConfig.CommitStrategy,
+		ts.cfg.KafkaConfig.MaxUncommittedMessages,
+		ts.cfg.KafkaConfig.MaxInflightMessages,
+		ts.limiter,
+		ts.decoder,
+		ts.metrics,
+		log.With(ts.logger, "component", "kafka_target"),
 	)
 
 	return t, nil
 }
 
+// newWorker returns a handler running the pipeline stages on the entries of a
+// target, and dropping their message labels before sending them to next.
+func newWorker(pipeline *stages.Pipeline, next api.EntryHandler) api.EntryHandler {
+	dropper := api.NewEntryMutatorHandler(next, dropMessageLabels)
+	wrapped := pipeline.Wrap(dropper)
+	return api.NewEntryHandler(wrapped.Chan(), func() {
+		wrapped.Stop()
+		dropper.Stop()
+	})
+}
+
 func validateConfig(cfg *scrapeconfig.Config) error {
 	if cfg.KafkaConfig == nil {
 		return errors.New("Kafka configuration is empty")
@@ -317,5 +454,53 @@ func validateConfig(cfg *scrapeconfig.Config) error {
 	if cfg.KafkaConfig.GroupID == "" {
 		cfg.KafkaConfig.GroupID = "promtail"
 	}
+
+	switch cfg.KafkaConfig.CommitStrategy {
+	case "":
+		cfg.KafkaConfig.CommitStrategy = scrapeconfig.KafkaCommitStrategyPeriodic
+	case scrapeconfig.KafkaCommitStrategyPeriodic, scrapeconfig.KafkaCommitStrategyPerMessage, scrapeconfig.KafkaCommitStrategyPerBatch:
+	default:
+		return fmt.Errorf("unrecognized commit strategy: %s", cfg.KafkaConfig.CommitStrategy)
+	}
+	if cfg.KafkaConfig.CommitInterval < 0 {
+		return errors.New("commit interval must not be negative")
+	}
+	if cfg.KafkaConfig.CommitStrategy == scrapeconfig.KafkaCommitStrategyPeriodic {
+		if cfg.KafkaConfig.CommitInterval == 0 {
+			cfg.KafkaConfig.CommitInterval = time.Second
+		}
+	} else if cfg.KafkaConfig.CommitInterval != 0 {
+		return fmt.Errorf("commit interval is only supported with the %s commit strategy", scrapeconfig.KafkaCommitStrategyPeriodic)
+	}
+	if cfg.KafkaConfig.MaxUncommittedMessages < 0 {
+		return errors.New("max uncommitted messages must not be negative")
+	}
+	if cfg.KafkaConfig.MaxInflightMessages < 0 {
+		return errors.New("max inflight messages must not be negative")
+	}
+	if cfg.KafkaConfig.MaxInflightMessages == 0 {
+		cfg.KafkaConfig.MaxInflightMessages = defaultMaxInflightMessages
+	}
+	if cfg.KafkaConfig.MaxMessagesPerSecond < 0 {
+		return errors.New("max messages per second must not be negative")
+	}
+	if _, err := parseInitialOffset(cfg.KafkaConfig.InitialOffset); err != nil {
+		return err
+	}
+	for topic, offset := range cfg.KafkaConfig.TopicInitialOffsets {
+		if _, err := parseInitialOffset(offset); err != nil {
+			return fmt.Errorf("topic %s: %w", topic, err)
+		}
+	}
+	if cfg.KafkaConfig.Workers < 0 {
+		return errors.New("workers must not be negative")
+	}
+	switch cfg.KafkaConfig.IsolationLevel {
+	case "":
+		cfg.KafkaConfig.IsolationLevel = scrapeconfig.KafkaIsolationLevelReadUncommitted
+	case scrapeconfig.KafkaIsolationLevelReadUncommitted, scrapeconfig.KafkaIsolationLevelReadCommitted:
+	default:
+		return fmt.Errorf("unrecognized isolation level: %s", cfg.KafkaConfig.IsolationLevel)
+	}
 	return nil
 }
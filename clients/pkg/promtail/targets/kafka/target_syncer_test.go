@@ -3,6 +3,10 @@ package kafka
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -62,7 +66,7 @@ func Test_TopicDiscovery(t *testing.T) {
 		if !group.consuming.Load() {
 			return false
 		}
-		return assert.Equal(t, group.topics, []string{"topic1"})
+		return assert.ObjectsAreEqual(group.topics, []string{"topic1"})
 	}, 200*time.Millisecond, time.Millisecond)
 
 	client.topics = []string{"topic1", "topic2"} // introduce new topics
@@ -71,18 +75,64 @@ func Test_TopicDiscovery(t *testing.T) {
 		if !group.consuming.Load() {
 			return false
 		}
-		return assert.Equal(t, group.topics, []string{"topic1", "topic2"})
+		return assert.ObjectsAreEqual(group.topics, []string{"topic1", "topic2"})
 	}, 200*time.Millisecond, time.Millisecond)
 
 	require.NoError(t, ts.Stop())
 	require.True(t, closed)
 }
 
+func Test_fetchTopics(t *testing.T) {
+	client := &mockKafkaClient{
+		topics:  []string{"topic1"},
+		leaders: map[string]map[int32]string{"topic1": {0: "broker-1:9092"}},
+	}
+	ts := &TargetSyncer{
+		logger:       log.NewNopLogger(),
+		topicManager: mustNewTopicsManager(client, []string{"topic1", "topic2"}),
+	}
+	fetch := func(expectedChanged bool) {
+		t.Helper()
+		topics, changed, err := ts.fetchTopics()
+		require.NoError(t, err)
+		require.Equal(t, expectedChanged, changed)
+		if changed {
+			require.Equal(t, client.topics, topics)
+		}
+	}
+
+	fetch(true)
+	fetch(false)
+
+	// a partition is added.
+	client.leaders["topic1"][1] = "broker-2:9092"
+	fetch(true)
+	fetch(false)
+
+	// the leader of a partition changes.
+	client.leaders["topic1"][0] = "broker-2:9092"
+	fetch(true)
+	fetch(false)
+
+	client.topics = []string{"topic1", "topic2"}
+	fetch(true)
+	require.Equal(t, topicMetadata{"topic1": {0: "broker-2:9092", 1: "broker-2:9092"}, "topic2": {}}, ts.metadata)
+
+	// without a leader during an election, the metadata is fetched again at the next poll.
+	client.leaders["topic1"][1] = ""
+	_, _, err := ts.fetchTopics()
+	require.Error(t, err)
+	client.leaders["topic1"][1] = "broker-2:9092"
+	fetch(false)
+}
+
 func Test_NewTarget(t *testing.T) {
 	ts := &TargetSyncer{
-		logger: log.NewNopLogger(),
-		reg:    prometheus.DefaultRegisterer,
-		client: fake.New(func() {}),
+		logger:  log.NewNopLogger(),
+		reg:     prometheus.DefaultRegisterer,
+		metrics: NewMetrics(nil),
+		client:  fake.New(func() {}),
+		decoder: plaintextDecoder{},
 		cfg: scrapeconfig.Config{
 			JobName: "foo",
 			RelabelConfigs: []*relabel.Config{
@@ -114,6 +164,13 @@ func Test_NewTarget(t *testing.T) {
 	}, tg.Details())
 	require.Equal(t, model.LabelSet{"static": "static1", "topic": "foo"}, tg.Labels())
 	require.Equal(t, model.LabelSet{"__meta_kafka_member_id": "foo", "__meta_kafka_partition": "10", "__meta_kafka_topic": "foo", "__meta_kafka_group_id": "group_1"}, tg.DiscoveredLabels())
+
+	// the metadata of the topic is added once known.
+	ts.metadata = topicMetadata{"foo": {9: "broker-1:9092", 10: "broker-2:9092"}}
+	tg, err = ts.NewTarget(&testSession{}, newTestClaim("foo", 10, 1))
+	require.NoError(t, err)
+	require.Equal(t, model.LabelValue("2"), tg.DiscoveredLabels()["__meta_kafka_topic_partitions"])
+	require.Equal(t, model.LabelValue("broker-2:9092"), tg.DiscoveredLabels()["__meta_kafka_partition_leader"])
 }
 
 func Test_NewDroppedTarget(t *testing.T) {
@@ -189,12 +246,128 @@ func Test_validateConfig(t *testing.T) {
 			false,
 			&scrapeconfig.Config{
 				KafkaConfig: &scrapeconfig.KafkaTargetConfig{
-					Brokers: []string{"foo"},
-					Topics:  []string{"bar"},
-					GroupID: "promtail",
-					Version: "2.1.1",
+					Brokers:             []string{"foo"},
+					Topics:              []string{"bar"},
+					GroupID:             "promtail",
+					Version:             "2.1.1",
+					CommitStrategy:      scrapeconfig.KafkaCommitStrategyPeriodic,
+					CommitInterval:      time.Second,
+					MaxInflightMessages: defaultMaxInflightMessages,
+					IsolationLevel:      scrapeconfig.KafkaIsolationLevelReadUncommitted,
+				},
+			},
+		},
+		{
+			&scrapeconfig.Config{
+				KafkaConfig: &scrapeconfig.KafkaTargetConfig{
+					Brokers:        []string{"foo"},
+					Topics:         []string{"bar"},
+					CommitStrategy: "unknown",
 				},
 			},
+			true,
+			nil,
+		},
+		{
+			&scrapeconfig.Config{
+				KafkaConfig: &scrapeconfig.KafkaTargetConfig{
+					Brokers:        []string{"foo"},
+					Topics:         []string{"bar"},
+					CommitStrategy: scrapeconfig.KafkaCommitStrategyPerMessage,
+					CommitInterval: time.Second,
+				},
+			},
+			true,
+			nil,
+		},
+		{
+			&scrapeconfig.Config{
+				KafkaConfig: &scrapeconfig.KafkaTargetConfig{
+					Brokers:                []string{"foo"},
+					Topics:                 []string{"bar"},
+					MaxUncommittedMessages: -1,
+				},
+			},
+			true,
+			nil,
+		},
+		{
+			&scrapeconfig.Config{
+				KafkaConfig: &scrapeconfig.KafkaTargetConfig{
+					Brokers:             []string{"foo"},
+					Topics:              []string{"bar"},
+					MaxInflightMessages: -1,
+				},
+			},
+			true,
+			nil,
+		},
+		{
+			&scrapeconfig.Config{
+				KafkaConfig: &scrapeconfig.KafkaTargetConfig{
+					Brokers:              []string{"foo"},
+					Topics:               []string{"bar"},
+					MaxMessagesPerSecond: -1,
+				},
+			},
+			true,
+			nil,
+		},
+		{
+			&scrapeconfig.Config{
+				KafkaConfig: &scrapeconfig.KafkaTargetConfig{
+					Brokers:                []string{"foo"},
+					Topics:                 []string{"bar"},
+					CommitStrategy:         scrapeconfig.KafkaCommitStrategyPerBatch,
+					MaxUncommittedMessages: 100,
+				},
+			},
+			false,
+			&scrapeconfig.Config{
+				KafkaConfig: &scrapeconfig.KafkaTargetConfig{
+					Brokers:                []string{"foo"},
+					Topics:                 []string{"bar"},
+					GroupID:                "promtail",
+					Version:                "2.1.1",
+					CommitStrategy:         scrapeconfig.KafkaCommitStrategyPerBatch,
+					MaxUncommittedMessages: 100,
+					MaxInflightMessages:    defaultMaxInflightMessages,
+					IsolationLevel:         scrapeconfig.KafkaIsolationLevelReadUncommitted,
+				},
+			},
+		},
+		{
+			&scrapeconfig.Config{
+				KafkaConfig: &scrapeconfig.KafkaTargetConfig{
+					Brokers:        []string{"foo"},
+					Topics:         []string{"bar"},
+					IsolationLevel: scrapeconfig.KafkaIsolationLevelReadCommitted,
+				},
+			},
+			false,
+			&scrapeconfig.Config{
+				KafkaConfig: &scrapeconfig.KafkaTargetConfig{
+					Brokers:             []string{"foo"},
+					Topics:              []string{"bar"},
+					GroupID:             "promtail",
+					Version:             "2.1.1",
+					CommitStrategy:      scrapeconfig.KafkaCommitStrategyPeriodic,
+					CommitInterval:      time.Second,
+					MaxInflightMessages: defaultMaxInflightMessages,
+					IsolationLevel:      scrapeconfig.KafkaIsolationLevelReadCommitted,
+				},
+			},
+		},
+		{
+			&scrapeconfig.Config{
+				KafkaConfig: &scrapeconfig.KafkaTargetConfig{
+					Brokers:        []string{"foo"},
+					Topics:         []string{"bar"},
+					IsolationLevel: "serializable",
+				},
+			},
+			true,
+			nil,
 		},
 	}
 
@@ -315,11 +488,35 @@ func Test_withAuthentication(t *testing.T) {
 	assert.Equal(t, sarama.SASLTypeSCRAMSHA512, string(saslCfg.Net.SASL.Mechanism))
 	assert.NoError(t, saslCfg.Validate())
 
+	// SASL/GSSAPI
+	saslCfg, err = withAuthentication(*cfg, scrapeconfig.KafkaAuthentication{
+		Type: scrapeconfig.KafkaAuthenticationTypeSASL,
+		SASLConfig: scrapeconfig.KafkaSASLConfig{
+			Mechanism: sarama.SASLTypeGSSAPI,
+			User:      "promtail",
+			GSSAPIConfig: scrapeconfig.KafkaSASLGSSAPIConfig{
+				Realm:      "EXAMPLE.COM",
+				KeytabPath: "/etc/promtail.keytab",
+			},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, true, saslCfg.Net.SASL.Enable)
+	assert.Equal(t, sarama.GSSAPIConfig{
+		AuthType:           sarama.KRB5_KEYTAB_AUTH,
+		KeyTabPath:         "/etc/promtail.keytab",
+		KerberosConfigPath: "/etc/krb5.conf",
+		ServiceName:        "kafka",
+		Username:           "promtail",
+		Realm:              "EXAMPLE.COM",
+	}, saslCfg.Net.SASL.GSSAPI)
+	assert.NoError(t, saslCfg.Validate())
+
 	// SASL unsupported mechanism
 	_, err = withAuthentication(*cfg, scrapeconfig.KafkaAuthentication{
 		Type: scrapeconfig.KafkaAuthenticationTypeSASL,
 		SASLConfig: scrapeconfig.KafkaSASLConfig{
-			Mechanism: sarama.SASLTypeGSSAPI,
+			Mechanism: "AWS_MSK_IAM",
 			User:      "user",
 			Password: flagext.Secret{
 				Value: "pass",
@@ -327,7 +524,61 @@ func Test_withAuthentication(t *testing.T) {
 		},
 	})
 	assert.Error(t, err)
-	assert.Equal(t, err.Error(), "error unsupported sasl mechanism: GSSAPI")
+	assert.Equal(t, err.Error(), "error unsupported sasl mechanism: AWS_MSK_IAM")
+
+	// SASL/OAUTHBEARER
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token\n"), 0600))
+	saslCfg, err = withAuthentication(*cfg, scrapeconfig.KafkaAuthentication{
+		Type: scrapeconfig.KafkaAuthenticationTypeSASL,
+		SASLConfig: scrapeconfig.KafkaSASLConfig{
+			Mechanism: sarama.SASLTypeOAuth,
+			OAuthConfig: scrapeconfig.KafkaSASLOAuthConfig{
+				TokenFile:  tokenFile,
+				Extensions: map[string]string{"logicalCluster": "lkc-1"},
+			},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, true, saslCfg.Net.SASL.Enable)
+	assert.Equal(t, sarama.SASLTypeOAuth, string(saslCfg.Net.SASL.Mechanism))
+	token, err := saslCfg.Net.SASL.TokenProvider.Token()
+	assert.Nil(t, err)
+	assert.Equal(t, &sarama.AccessToken{Token: "token", Extensions: map[string]string{"logicalCluster": "lkc-1"}}, token)
+	assert.NoError(t, saslCfg.Validate())
+
+	// SASL/OAUTHBEARER with client credentials
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"fetched","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+	saslCfg, err = withAuthentication(*cfg, scrapeconfig.KafkaAuthentication{
+		Type: scrapeconfig.KafkaAuthenticationTypeSASL,
+		SASLConfig: scrapeconfig.KafkaSASLConfig{
+			Mechanism: sarama.SASLTypeOAuth,
+			OAuthConfig: scrapeconfig.KafkaSASLOAuthConfig{
+				TokenURL:     tokenServer.URL,
+				ClientID:     "promtail",
+				ClientSecret: flagext.Secret{Value: "secret"},
+			},
+		},
+	})
+	assert.Nil(t, err)
+	token, err = saslCfg.Net.SASL.TokenProvider.Token()
+	assert.Nil(t, err)
+	assert.Equal(t, "fetched", token.Token)
+
+	// SASL/OAUTHBEARER without token source
+	_, err = withAuthentication(*cfg, scrapeconfig.KafkaAuthentication{
+		Type: scrapeconfig.KafkaAuthenticationTypeSASL,
+		SASLConfig: scrapeconfig.KafkaSASLConfig{
+			Mechanism: sarama.SASLTypeOAuth,
+		},
+	})
+	assert.EqualError(t, err, "token_url or token_file must be set for the OAUTHBEARER sasl mechanism")
 
 	// SASL over TLS
 	saslCfg, err = withAuthentication(*cfg, scrapeconfig.KafkaAuthentication{
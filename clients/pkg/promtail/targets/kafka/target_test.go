@@ -2,13 +2,21 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/Shopify/sarama"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/log"
+	"github.com/grafana/loki/clients/pkg/logentry/stages"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
 	"github.com/grafana/loki/clients/pkg/promtail/client/fake"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/relabel"
 	"github.com/stretchr/testify/require"
@@ -49,13 +57,14 @@ func (c testConsumerGroupHandler) Close() error {
 
 type testSession struct {
 	markedMessage []*sarama.ConsumerMessage
+	commits       int
 }
 
 func (s *testSession) Claims() map[string][]int32                                               { return nil }
 func (s *testSession) MemberID() string                                                         { return "foo" }
 func (s *testSession) GenerationID() int32                                                      { return 10 }
 func (s *testSession) MarkOffset(topic string, partition int32, offset int64, metadata string)  {}
-func (s *testSession) Commit()                                                                  {}
+func (s *testSession) Commit()                                                                  { s.commits++ }
 func (s *testSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {}
 func (s *testSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
 	s.markedMessage = append(s.markedMessage, msg)
@@ -70,11 +79,15 @@ type testClaim struct {
 }
 
 func newTestClaim(topic string, partition int32, offset int64) *testClaim {
+	return newBufferedTestClaim(topic, partition, offset, 0)
+}
+
+func newBufferedTestClaim(topic string, partition int32, offset int64, size int) *testClaim {
 	return &testClaim{
 		topic:     topic,
 		partition: partition,
 		offset:    offset,
-		messages:  make(chan *sarama.ConsumerMessage),
+		messages:  make(chan *sarama.ConsumerMessage, size),
 	}
 }
 
@@ -140,6 +153,29 @@ func Test_TargetRun(t *testing.T) {
 			},
 			expectedLS: model.LabelSet{"buzz": "bazz", "message_key": "none"},
 		},
+		{
+			name:           "headers and timestamp with relabel config",
+			inMessageKey:   "foo",
+			inDiscoveredLS: model.LabelSet{"__meta_kafka_foo": "bar"},
+			inLS:           model.LabelSet{"buzz": "bazz"},
+			relabels: []*relabel.Config{
+				{
+					SourceLabels: model.LabelNames{"__meta_kafka_header_x_tenant"},
+					Regex:        relabel.MustNewRegexp("(.*)"),
+					TargetLabel:  "tenant",
+					Replacement:  "$1",
+					Action:       "replace",
+				},
+				{
+					SourceLabels: model.LabelNames{"__meta_kafka_message_timestamp"},
+					Regex:        relabel.MustNewRegexp("(\\d+)-.*"),
+					TargetLabel:  "year",
+					Replacement:  "$1",
+					Action:       "replace",
+				},
+			},
+			expectedLS: model.LabelSet{"buzz": "bazz", "tenant": "team-a", "year": "1970"},
+		},
 	}
 	for _, tt := range tc {
 		t.Run(tt.name, func(t *testing.T) {
@@ -150,7 +186,7 @@ func Test_TargetRun(t *testing.T) {
 					closed = true
 				},
 			)
-			tg := NewTarget(session, claim, tt.inDiscoveredLS, tt.inLS, tt.relabels, fc, true)
+			tg := NewTarget(session, claim, tt.inDiscoveredLS, tt.inLS, tt.relabels, []api.EntryHandler{fc}, true, scrapeconfig.KafkaCommitStrategyPeriodic, 0, defaultMaxInflightMessages, newLimiter(0, 0), plaintextDecoder{}, NewMetrics(nil), log.NewNopLogger())
 
 			var wg sync.WaitGroup
 			wg.Add(1)
@@ -164,6 +200,7 @@ func Test_TargetRun(t *testing.T) {
 					Timestamp: time.Unix(0, int64(i)),
 					Value:     []byte(fmt.Sprintf("%d", i)),
 					Key:       []byte(tt.inMessageKey),
+					Headers:   []*sarama.RecordHeader{{Key: []byte("x-tenant"), Value: []byte("team-a")}},
 				})
 			}
 			claim.Stop()
@@ -174,8 +211,317 @@ func Test_TargetRun(t *testing.T) {
 			require.Len(t, re, 10)
 			require.True(t, closed)
 			for _, e := range re {
-				require.Equal(t, tt.expectedLS.String(), e.Labels.String())
+				require.Equal(t, model.LabelValue("team-a"), e.Labels["__meta_kafka_header_x_tenant"])
+				require.Equal(t, tt.expectedLS.String(), dropMessageLabels(e).Labels.String())
 			}
 		})
 	}
 }
+
+func Test_WorkerDropsMessageLabels(t *testing.T) {
+	pipeline, err := stages.NewPipeline(util_log.Logger, stages.PipelineStages{
+		map[interface{}]interface{}{
+			"labels": map[interface{}]interface{}{
+				"level": "__meta_kafka_header_level",
+			},
+		},
+	}, nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+	fc := fake.New(func() {})
+	worker := newWorker(pipeline, fc)
+
+	worker.Chan() <- api.Entry{
+		Labels: model.LabelSet{"job": "kafka", "__meta_kafka_header_level": "error", "__meta_kafka_message_key": "none"},
+		Entry:  logproto.Entry{Timestamp: time.Now(), Line: "line"},
+	}
+	worker.Stop()
+
+	re := fc.Received()
+	require.Len(t, re, 1)
+	require.Equal(t, model.LabelSet{"job": "kafka", "level": "error"}, re[0].Labels)
+}
+
+func Test_TargetRunCommitStrategy(t *testing.T) {
+	tc := []struct {
+		name           string
+		strategy       scrapeconfig.KafkaCommitStrategy
+		maxUncommitted int
+		// buffered pre-fills the claim buffer with all messages before running the target.
+		buffered        bool
+		expectedCommits int
+	}{
+		// remaining marks are left to sarama which flushes them when closing the session.
+		{"periodic", scrapeconfig.KafkaCommitStrategyPeriodic, 0, false, 0},
+		{"periodic with max uncommitted", scrapeconfig.KafkaCommitStrategyPeriodic, 4, false, 2},
+		{"periodic with max uncommitted and buffered claim", scrapeconfig.KafkaCommitStrategyPeriodic, 4, true, 2},
+		{"per message", scrapeconfig.KafkaCommitStrategyPerMessage, 0, false, 10},
+		{"per message and buffered claim", scrapeconfig.KafkaCommitStrategyPerMessage, 0, true, 10},
+		// every message drains an unbuffered claim.
+		{"per batch", scrapeconfig.KafkaCommitStrategyPerBatch, 0, false, 10},
+		// the buffer is only drained by the last message, which is committed before the claim ends.
+		{"per batch and buffered claim", scrapeconfig.KafkaCommitStrategyPerBatch, 0, true, 1},
+		{"per batch with max uncommitted and buffered claim", scrapeconfig.KafkaCommitStrategyPerBatch, 4, true, 3},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			// A single message in flight, its acknowledgement is handled before the next
+			// message is read.
+			session, claim := &testSession{}, newTestClaim("footopic", 10, 12)
+			if tt.buffered {
+				claim = newBufferedTestClaim("footopic", 10, 12, 10)
+			}
+			tg := NewTarget(session, claim, model.LabelSet{}, model.LabelSet{"foo": "bar"}, nil, []api.EntryHandler{fake.New(func() {})}, false, tt.strategy, tt.maxUncommitted, 1, newLimiter(0, 0), plaintextDecoder{}, NewMetrics(nil), log.NewNopLogger())
+
+			send := func() {
+				for i := 0; i < 10; i++ {
+					claim.Send(&sarama.ConsumerMessage{Value: []byte(fmt.Sprintf("%d", i))})
+				}
+				claim.Stop()
+			}
+			if tt.buffered {
+				send()
+				tg.run()
+			} else {
+				var wg sync.WaitGroup
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					tg.run()
+				}()
+				send()
+				wg.Wait()
+			}
+
+			require.Len(t, session.markedMessage, 10)
+			require.Equal(t, tt.expectedCommits, session.commits)
+		})
+	}
+}
+
+func Test_TargetRunWorkers(t *testing.T) {
+	session, claim := &testSession{}, newTestClaim("footopic", 10, 12)
+	clients := []*fake.Client{fake.New(func() {}), fake.New(func() {}), fake.New(func() {})}
+	tg := NewTarget(session, claim, model.LabelSet{}, model.LabelSet{"foo": "bar"}, nil,
+		[]api.EntryHandler{clients[0], clients[1], clients[2]}, false, scrapeconfig.KafkaCommitStrategyPeriodic, 0, defaultMaxInflightMessages, newLimiter(0, 0), plaintextDecoder{}, NewMetrics(nil), log.NewNopLogger())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tg.run()
+	}()
+
+	keys := []string{"a", "b", "c", "d", ""}
+	for i := 0; i < 50; i++ {
+		key := keys[i%len(keys)]
+		claim.Send(&sarama.ConsumerMessage{
+			Value: []byte(fmt.Sprintf("%s-%d", key, i)),
+			Key:   []byte(key),
+		})
+	}
+	claim.Stop()
+	wg.Wait()
+
+	require.Len(t, session.markedMessage, 50)
+	total := 0
+	for _, key := range keys[:4] {
+		// all messages of a key are received in order by a single client.
+		var received []string
+		for _, c := range clients {
+			for _, e := range c.Received() {
+				if e.Line[0] == key[0] {
+					received = append(received, e.Line)
+				}
+			}
+		}
+		expected := []string{}
+		for i := 0; i < 50; i++ {
+			if keys[i%len(keys)] == key {
+				expected = append(expected, fmt.Sprintf("%s-%d", key, i))
+			}
+		}
+		require.Equal(t, expected, received)
+	}
+	for _, c := range clients {
+		total += len(c.Received())
+		// keyless messages are spread over every client.
+		keyless := 0
+		for _, e := range c.Received() {
+			if e.Line[0] == '-' {
+				keyless++
+			}
+		}
+		require.NotZero(t, keyless)
+	}
+	require.Equal(t, 50, total)
+}
+
+// ackClient acknowledges the entries it receives with the errors of ackErrs, in order,
+// then without error. Entries are only acknowledged once released.
+type ackClient struct {
+	entries  chan api.Entry
+	release  chan struct{}
+	ackErrs  []error
+	received []string
+	wg       sync.WaitGroup
+}
+
+func newAckClient(ackErrs ...error) *ackClient {
+	c := &ackClient{entries: make(chan api.Entry), release: make(chan struct{}), ackErrs: ackErrs}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		var pending []api.Entry
+		for {
+			select {
+			case e, ok := <-c.entries:
+				if !ok {
+					return
+				}
+				c.received = append(c.received, e.Line)
+				pending = append(pending, e)
+			case <-c.release:
+				for _, e := range pending {
+					var err error
+					if len(c.ackErrs) > 0 {
+						err, c.ackErrs = c.ackErrs[0], c.ackErrs[1:]
+					}
+					e.Ack.Done(err)
+				}
+				pending = nil
+			}
+		}
+	}()
+	return c
+}
+
+func (c *ackClient) Chan() chan<- api.Entry { return c.entries }
+
+func (c *ackClient) Stop() {
+	close(c.entries)
+	c.wg.Wait()
+}
+
+func Test_TargetRunAcknowledgements(t *testing.T) {
+	session, claim := &testSession{}, newBufferedTestClaim("footopic", 10, 12, 10)
+	client := newAckClient(nil, errors.New("loki is unavailable"))
+	tg := NewTarget(session, claim, model.LabelSet{}, model.LabelSet{"foo": "bar"}, nil, []api.EntryHandler{client}, false, scrapeconfig.KafkaCommitStrategyPerMessage, 0, 3, newLimiter(0, 0), plaintextDecoder{}, NewMetrics(nil), log.NewNopLogger())
+	for i := 0; i < 5; i++ {
+		claim.Send(&sarama.ConsumerMessage{Value: []byte(fmt.Sprintf("%d", i)), Offset: int64(i)})
+	}
+	claim.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tg.run()
+	}()
+
+	// Only 3 messages are in flight, and none is marked until acknowledged.
+	time.Sleep(50 * time.Millisecond)
+	require.Len(t, session.markedMessage, 0)
+	require.Len(t, claim.messages, 2)
+
+	// The second message fails to be pushed and is sent again, the messages after it are
+	// only marked once it has been acknowledged.
+	require.Eventually(t, func() bool {
+		select {
+		case client.release <- struct{}{}:
+			return false
+		case <-done:
+			return true
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.ElementsMatch(t, []string{"0", "1", "2", "1", "3", "4"}, client.received)
+	var offsets []int64
+	for _, m := range session.markedMessage {
+		offsets = append(offsets, m.Offset)
+	}
+	require.Equal(t, []int64{0, 1, 2, 3, 4}, offsets)
+	require.Equal(t, 5, session.commits)
+}
+
+// unavailableDecoder fails as if the schema registry was unavailable for the first decodings.
+type unavailableDecoder struct {
+	failures int
+}
+
+func (d *unavailableDecoder) Decode(value []byte) (string, error) {
+	if d.failures > 0 {
+		d.failures--
+		return "", fmt.Errorf("%w: connection refused", errRegistryUnavailable)
+	}
+	return string(value), nil
+}
+
+func Test_TargetRunRegistryUnavailable(t *testing.T) {
+	defer func(b time.Duration) { registryBackoff.MinBackoff = b }(registryBackoff.MinBackoff)
+	registryBackoff.MinBackoff = time.Millisecond
+
+	session, claim := &testSession{}, newBufferedTestClaim("footopic", 10, 12, 10)
+	client := fake.New(func() {})
+	decoder := &unavailableDecoder{failures: 3}
+	tg := NewTarget(session, claim, model.LabelSet{}, model.LabelSet{"foo": "bar"}, nil, []api.EntryHandler{client}, false, scrapeconfig.KafkaCommitStrategyPerMessage, 0, defaultMaxInflightMessages, newLimiter(0, 0), decoder, NewMetrics(nil), log.NewNopLogger())
+	for i := 0; i < 2; i++ {
+		claim.Send(&sarama.ConsumerMessage{Value: []byte(fmt.Sprintf("%d", i)), Offset: int64(i)})
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tg.run()
+	}()
+
+	// The messages are decoded again once the registry is back instead of being dropped.
+	require.Eventually(t, func() bool { return len(client.Received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	claim.Stop()
+	<-done
+	require.Equal(t, 0, decoder.failures)
+	var lines []string
+	for _, e := range client.Received() {
+		lines = append(lines, e.Line)
+	}
+	require.ElementsMatch(t, []string{"0", "1"}, lines)
+	require.Len(t, session.markedMessage, 2)
+}
+
+func Test_TargetRunRateLimit(t *testing.T) {
+	session, claim := &testSession{}, newBufferedTestClaim("footopic", 10, 12, 10)
+	client := fake.New(func() {})
+	// 100 bytes per second lets the first 2 messages of 50 bytes through, then one every half second.
+	tg := NewTarget(session, claim, model.LabelSet{}, model.LabelSet{"foo": "bar"}, nil, []api.EntryHandler{client}, false, scrapeconfig.KafkaCommitStrategyPerMessage, 0, defaultMaxInflightMessages, newLimiter(0, 100), plaintextDecoder{}, NewMetrics(nil), log.NewNopLogger())
+	for i := 0; i < 4; i++ {
+		claim.Send(&sarama.ConsumerMessage{Value: make([]byte, 50), Offset: int64(i)})
+	}
+	claim.Stop()
+
+	start := time.Now()
+	tg.run()
+	require.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+	require.Len(t, client.Received(), 4)
+	require.Len(t, session.markedMessage, 4)
+}
+
+func Test_TargetMessageDelay(t *testing.T) {
+	session, claim := &testSession{}, newBufferedTestClaim("footopic", 10, 12, 10)
+	reg := prometheus.NewRegistry()
+	tg := NewTarget(session, claim, model.LabelSet{}, model.LabelSet{"foo": "bar"}, nil, []api.EntryHandler{fake.New(func() {})}, false, scrapeconfig.KafkaCommitStrategyPerMessage, 0, defaultMaxInflightMessages, newLimiter(0, 0), plaintextDecoder{}, NewMetrics(reg), log.NewNopLogger())
+	claim.Send(&sarama.ConsumerMessage{Topic: "footopic", Value: []byte("old"), Timestamp: time.Now().Add(-time.Minute)})
+	claim.Send(&sarama.ConsumerMessage{Topic: "footopic", Value: []byte("future"), Timestamp: time.Now().Add(time.Minute)})
+	// Messages from Kafka before 0.10 have no timestamp.
+	claim.Send(&sarama.ConsumerMessage{Topic: "footopic", Value: []byte("none")})
+	claim.Stop()
+	tg.run()
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 1)
+	require.Equal(t, "promtail_kafka_message_delay_seconds", mfs[0].GetName())
+	require.Len(t, mfs[0].GetMetric(), 1)
+	require.Equal(t, "footopic", mfs[0].GetMetric()[0].GetLabel()[0].GetValue())
+	h := mfs[0].GetMetric()[0].GetHistogram()
+	require.Equal(t, uint64(2), h.GetSampleCount())
+	// The message from the future is counted with no delay.
+	require.Equal(t, uint64(1), h.GetBucket()[0].GetCumulativeCount())
+	require.InDelta(t, 60, h.GetSampleSum(), 1)
+}
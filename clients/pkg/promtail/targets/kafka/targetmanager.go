@@ -27,8 +27,9 @@ func NewTargetManager(
 		logger:        logger,
 		targetSyncers: make(map[string]*TargetSyncer),
 	}
+	metrics := NewMetrics(reg)
 	for _, cfg := range scrapeConfigs {
-		t, err := NewSyncer(reg, logger, cfg, pushClient)
+		t, err := NewSyncer(reg, metrics, logger, cfg, pushClient)
 		if err != nil {
 			return nil, err
 		}
@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+
+	"github.com/Shopify/sarama"
 )
 
 type topicClient interface {
 	RefreshMetadata(topics ...string) error
 	Topics() ([]string, error)
+	Partitions(topic string) ([]int32, error)
+	Leader(topic string, partitionID int32) (*sarama.Broker, error)
 }
 
 type topicManager struct {
@@ -17,33 +21,51 @@ type topicManager struct {
 
 	patterns []*regexp.Regexp
 	matches  []string
+
+	excludePatterns []*regexp.Regexp
+	excludeMatches  []string
 }
 
 // newTopicManager fetches topics and returns matchings one based on list of requested topics.
 // If a topic starts with a '^' it is treated as a regexp and can match multiple topics.
-func newTopicManager(client topicClient, topics []string) (*topicManager, error) {
+// Topics matching one of the excluded topics, given with the same syntax, are never returned.
+func newTopicManager(client topicClient, topics, excludeTopics []string) (*topicManager, error) {
+	matches, patterns, err := parseTopics(topics)
+	if err != nil {
+		return nil, err
+	}
+	excludeMatches, excludePatterns, err := parseTopics(excludeTopics)
+	if err != nil {
+		return nil, fmt.Errorf("invalid excluded topic: %w", err)
+	}
+	return &topicManager{
+		client:          client,
+		patterns:        patterns,
+		matches:         matches,
+		excludePatterns: excludePatterns,
+		excludeMatches:  excludeMatches,
+	}, nil
+}
+
+func parseTopics(topics []string) ([]string, []*regexp.Regexp, error) {
 	var (
 		patterns []*regexp.Regexp
 		matches  []string
 	)
 	for _, t := range topics {
 		if len(t) == 0 {
-			return nil, errors.New("invalid empty topic")
+			return nil, nil, errors.New("invalid empty topic")
 		}
 		if t[0] != '^' {
 			matches = append(matches, t)
 		}
 		re, err := regexp.Compile(t)
 		if err != nil {
-			return nil, fmt.Errorf("invalid topic pattern: %w", err)
+			return nil, nil, fmt.Errorf("invalid topic pattern: %w", err)
 		}
 		patterns = append(patterns, re)
 	}
-	return &topicManager{
-		client:   client,
-		patterns: patterns,
-		matches:  matches,
-	}, nil
+	return matches, patterns, nil
 }
 
 func (tm *topicManager) Topics() ([]string, error) {
@@ -57,22 +79,44 @@ func (tm *topicManager) Topics() ([]string, error) {
 
 	result := make([]string, 0, len(topics))
 
-Outer:
 	for _, topic := range topics {
-		for _, m := range tm.matches {
-			if m == topic {
-				result = append(result, topic)
-				continue Outer
-			}
-		}
-		for _, p := range tm.patterns {
-			if p.MatchString(topic) {
-				result = append(result, topic)
-				continue Outer
-			}
+		if matchTopic(topic, tm.matches, tm.patterns) && !matchTopic(topic, tm.excludeMatches, tm.excludePatterns) {
+			result = append(result, topic)
 		}
 	}
 
 	sort.Strings(result)
 	return result, nil
 }
+
+// Partitions returns the address of the leader broker of each partition of the topic, as of
+// the metadata fetched by the last call to Topics.
+func (tm *topicManager) Partitions(topic string) (map[int32]string, error) {
+	partitions, err := tm.client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+	leaders := make(map[int32]string, len(partitions))
+	for _, p := range partitions {
+		leader, err := tm.client.Leader(topic, p)
+		if err != nil {
+			return nil, fmt.Errorf("partition %d of topic %s: %w", p, topic, err)
+		}
+		leaders[p] = leader.Addr()
+	}
+	return leaders, nil
+}
+
+func matchTopic(topic string, matches []string, patterns []*regexp.Regexp) bool {
+	for _, m := range matches {
+		if m == topic {
+			return true
+		}
+	}
+	for _, p := range patterns {
+		if p.MatchString(topic) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,15 +2,19 @@ package kafka
 
 import (
 	"errors"
+	"sort"
 	"strings"
 	"testing"
 
+	"github.com/Shopify/sarama"
 	"github.com/stretchr/testify/require"
 )
 
 type mockKafkaClient struct {
 	topics []string
-	err    error
+	// leaders is the address of the leader of each partition, by topic.
+	leaders map[string]map[int32]string
+	err     error
 }
 
 func (m *mockKafkaClient) RefreshMetadata(topics ...string) error {
@@ -21,6 +25,23 @@ func (m *mockKafkaClient) Topics() ([]string, error) {
 	return m.topics, m.err
 }
 
+func (m *mockKafkaClient) Partitions(topic string) ([]int32, error) {
+	var partitions []int32
+	for p := range m.leaders[topic] {
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions, m.err
+}
+
+func (m *mockKafkaClient) Leader(topic string, partitionID int32) (*sarama.Broker, error) {
+	leader, ok := m.leaders[topic][partitionID]
+	if !ok || leader == "" {
+		return nil, sarama.ErrLeaderNotAvailable
+	}
+	return sarama.NewBroker(leader), nil
+}
+
 func Test_NewTopicManager(t *testing.T) {
 	t.Parallel()
 
@@ -48,7 +69,7 @@ func Test_NewTopicManager(t *testing.T) {
 		tt := tt
 		t.Run(strings.Join(tt.in, ","), func(t *testing.T) {
 			t.Parallel()
-			_, err := newTopicManager(&mockKafkaClient{}, tt.in)
+			_, err := newTopicManager(&mockKafkaClient{}, tt.in, nil)
 			if tt.expectedErr {
 				require.Error(t, err)
 				return
@@ -56,6 +77,9 @@ func Test_NewTopicManager(t *testing.T) {
 			require.NoError(t, err)
 		})
 	}
+
+	_, err := newTopicManager(&mockKafkaClient{}, []string{"^foo.*"}, []string{"^("})
+	require.Error(t, err)
 }
 
 func Test_Topics(t *testing.T) {
@@ -81,6 +105,11 @@ func Test_Topics(t *testing.T) {
 			[]string{"buzz", "foo", "foobar"},
 			false,
 		},
+		{
+			mustNewTopicsManager(&mockKafkaClient{topics: []string{"logs-api", "logs-api-dlq", "logs-web", "buzz"}}, []string{"^logs-.*", "buzz"}, "^.*-dlq$", "logs-web"),
+			[]string{"buzz", "logs-api"},
+			false,
+		},
 	} {
 		tt := tt
 		t.Run("", func(t *testing.T) {
@@ -97,8 +126,21 @@ func Test_Topics(t *testing.T) {
 	}
 }
 
-func mustNewTopicsManager(client topicClient, topics []string) *topicManager {
-	t, err := newTopicManager(client, topics)
+func Test_Partitions(t *testing.T) {
+	client := &mockKafkaClient{leaders: map[string]map[int32]string{"foo": {0: "broker-1:9092", 1: "broker-2:9092"}}}
+	manager := mustNewTopicsManager(client, []string{"foo"})
+
+	leaders, err := manager.Partitions("foo")
+	require.NoError(t, err)
+	require.Equal(t, map[int32]string{0: "broker-1:9092", 1: "broker-2:9092"}, leaders)
+
+	leaders, err = manager.Partitions("bar")
+	require.NoError(t, err)
+	require.Empty(t, leaders)
+}
+
+func mustNewTopicsManager(client topicClient, topics []string, excludeTopics ...string) *topicManager {
+	t, err := newTopicManager(client, topics, excludeTopics)
 	if err != nil {
 		panic(err)
 	}
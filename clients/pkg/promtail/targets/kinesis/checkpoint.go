@@ -0,0 +1,93 @@
+package kinesis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+)
+
+// shardEnd is the checkpoint of the shards entirely consumed.
+const shardEnd = "SHARD_END"
+
+// checkpointer stores the sequence number of the last record of each shard pushed to Loki.
+type checkpointer interface {
+	// get returns the checkpoint of a shard, empty if there's none.
+	get(ctx context.Context, shardID string) (string, error)
+	set(ctx context.Context, shardID, sequenceNumber string) error
+}
+
+// positionsCheckpointer stores checkpoints in the positions file.
+type positionsCheckpointer struct {
+	positions positions.Positions
+	prefix    string
+}
+
+func newPositionsCheckpointer(positions positions.Positions, jobName, streamName string) *positionsCheckpointer {
+	return &positionsCheckpointer{
+		positions: positions,
+		prefix:    fmt.Sprintf("kinesis-%s-%s-", jobName, streamName),
+	}
+}
+
+func (c *positionsCheckpointer) get(_ context.Context, shardID string) (string, error) {
+	return c.positions.GetString(c.prefix + shardID), nil
+}
+
+func (c *positionsCheckpointer) set(_ context.Context, shardID, sequenceNumber string) error {
+	c.positions.PutString(c.prefix+shardID, sequenceNumber)
+	return nil
+}
+
+const (
+	dynamoDBShardAttribute          = "shard"
+	dynamoDBSequenceNumberAttribute = "sequence_number"
+)
+
+// dynamoDBCheckpointer stores checkpoints in a DynamoDB table, keyed by job, stream and shard.
+type dynamoDBCheckpointer struct {
+	client dynamodbiface.DynamoDBAPI
+	table  string
+	prefix string
+}
+
+func newDynamoDBCheckpointer(client dynamodbiface.DynamoDBAPI, table, jobName, streamName string) *dynamoDBCheckpointer {
+	return &dynamoDBCheckpointer{
+		client: client,
+		table:  table,
+		prefix: fmt.Sprintf("%s/%s/", jobName, streamName),
+	}
+}
+
+func (c *dynamoDBCheckpointer) get(ctx context.Context, shardID string) (string, error) {
+	out, err := c.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(c.table),
+		Key:            map[string]*dynamodb.AttributeValue{dynamoDBShardAttribute: {S: aws.String(c.prefix + shardID)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting the checkpoint of shard %s: %w", shardID, err)
+	}
+	if v, ok := out.Item[dynamoDBSequenceNumberAttribute]; ok {
+		return aws.StringValue(v.S), nil
+	}
+	return "", nil
+}
+
+func (c *dynamoDBCheckpointer) set(ctx context.Context, shardID, sequenceNumber string) error {
+	_, err := c.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			dynamoDBShardAttribute:          {S: aws.String(c.prefix + shardID)},
+			dynamoDBSequenceNumberAttribute: {S: aws.String(sequenceNumber)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error setting the checkpoint of shard %s: %w", shardID, err)
+	}
+	return nil
+}
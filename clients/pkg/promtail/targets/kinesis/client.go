@@ -0,0 +1,330 @@
+package kinesis
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream"
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream/eventstreamapi"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+)
+
+// The Kinesis API client of the AWS SDK isn't a dependency of Loki, kinesisClient is a
+// client of the few operations used by the target, built on the SDK core the same way.
+// The shapes below only have the members needed by the target.
+
+const (
+	shardIteratorTypeAtTimestamp         = "AT_TIMESTAMP"
+	shardIteratorTypeTrimHorizon         = "TRIM_HORIZON"
+	shardIteratorTypeLatest              = "LATEST"
+	shardIteratorTypeAfterSequenceNumber = "AFTER_SEQUENCE_NUMBER"
+
+	consumerStatusActive = "ACTIVE"
+
+	errCodeResourceInUseException = "ResourceInUseException"
+)
+
+// kinesisAPI is the subset of the Kinesis API used by the target.
+type kinesisAPI interface {
+	ListShardsWithContext(ctx context.Context, input *listShardsInput) (*listShardsOutput, error)
+	GetShardIteratorWithContext(ctx context.Context, input *getShardIteratorInput) (*getShardIteratorOutput, error)
+	GetRecordsWithContext(ctx context.Context, input *getRecordsInput) (*getRecordsOutput, error)
+	DescribeStreamSummaryWithContext(ctx context.Context, input *describeStreamSummaryInput) (*describeStreamSummaryOutput, error)
+	RegisterStreamConsumerWithContext(ctx context.Context, input *registerStreamConsumerInput) (*registerStreamConsumerOutput, error)
+	DescribeStreamConsumerWithContext(ctx context.Context, input *describeStreamConsumerInput) (*describeStreamConsumerOutput, error)
+	SubscribeToShardWithContext(ctx context.Context, input *subscribeToShardInput) (subscription, error)
+}
+
+// subscription reads the events of a SubscribeToShard call.
+type subscription interface {
+	// Next returns the next event, io.EOF once the subscription has expired.
+	Next() (*subscribeToShardEvent, error)
+	Close() error
+}
+
+type kinesisClient struct {
+	*client.Client
+}
+
+func newKinesisClient(p client.ConfigProvider, cfgs ...*aws.Config) *kinesisClient {
+	c := p.ClientConfig("kinesis", cfgs...)
+	kc := &kinesisClient{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "kinesis",
+				ServiceID:     "Kinesis",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				PartitionID:   c.PartitionID,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2013-12-02",
+				JSONVersion:   "1.1",
+				TargetPrefix:  "Kinesis_20131202",
+			},
+			c.Handlers,
+		),
+	}
+	kc.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	kc.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	kc.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	kc.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	kc.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+	return kc
+}
+
+func (c *kinesisClient) send(ctx context.Context, name string, input, output interface{}, opts ...request.Option) error {
+	req := c.NewRequest(&request.Operation{Name: name, HTTPMethod: "POST", HTTPPath: "/"}, input, output)
+	req.SetContext(ctx)
+	req.ApplyOptions(opts...)
+	return req.Send()
+}
+
+type shard struct {
+	_ struct{} `type:"structure"`
+
+	ShardId               *string `type:"string"` //nolint:revive
+	ParentShardId         *string `type:"string"` //nolint:revive
+	AdjacentParentShardId *string `type:"string"` //nolint:revive
+}
+
+type listShardsInput struct {
+	_ struct{} `type:"structure"`
+
+	StreamName *string `type:"string"`
+	NextToken  *string `type:"string"`
+}
+
+type listShardsOutput struct {
+	_ struct{} `type:"structure"`
+
+	Shards    []*shard `type:"list"`
+	NextToken *string  `type:"string"`
+}
+
+func (c *kinesisClient) ListShardsWithContext(ctx context.Context, input *listShardsInput) (*listShardsOutput, error) {
+	output := &listShardsOutput{}
+	return output, c.send(ctx, "ListShards", input, output)
+}
+
+type getShardIteratorInput struct {
+	_ struct{} `type:"structure"`
+
+	StreamName             *string    `type:"string"`
+	ShardId                *string    `type:"string"` //nolint:revive
+	ShardIteratorType      *string    `type:"string"`
+	StartingSequenceNumber *string    `type:"string"`
+	Timestamp              *time.Time `type:"timestamp"`
+}
+
+type getShardIteratorOutput struct {
+	_ struct{} `type:"structure"`
+
+	ShardIterator *string `type:"string"`
+}
+
+func (c *kinesisClient) GetShardIteratorWithContext(ctx context.Context, input *getShardIteratorInput) (*getShardIteratorOutput, error) {
+	output := &getShardIteratorOutput{}
+	return output, c.send(ctx, "GetShardIterator", input, output)
+}
+
+type record struct {
+	_ struct{} `type:"structure"`
+
+	SequenceNumber              *string    `type:"string"`
+	ApproximateArrivalTimestamp *time.Time `type:"timestamp"`
+	Data                        []byte     `type:"blob"`
+	PartitionKey                *string    `type:"string"`
+}
+
+type getRecordsInput struct {
+	_ struct{} `type:"structure"`
+
+	ShardIterator *string `type:"string"`
+	Limit         *int64  `type:"integer"`
+}
+
+type getRecordsOutput struct {
+	_ struct{} `type:"structure"`
+
+	Records            []*record `type:"list"`
+	NextShardIterator  *string   `type:"string"`
+	MillisBehindLatest *int64    `type:"long"`
+}
+
+func (c *kinesisClient) GetRecordsWithContext(ctx context.Context, input *getRecordsInput) (*getRecordsOutput, error) {
+	output := &getRecordsOutput{}
+	// As done by the SDK, a read stalled for 5 seconds is retried.
+	return output, c.send(ctx, "GetRecords", input, output, request.WithResponseReadTimeout(5*time.Second))
+}
+
+type describeStreamSummaryInput struct {
+	_ struct{} `type:"structure"`
+
+	StreamName *string `type:"string"`
+}
+
+type streamDescriptionSummary struct {
+	_ struct{} `type:"structure"`
+
+	StreamARN *string `type:"string"`
+}
+
+type describeStreamSummaryOutput struct {
+	_ struct{} `type:"structure"`
+
+	StreamDescriptionSummary *streamDescriptionSummary `type:"structure"`
+}
+
+func (c *kinesisClient) DescribeStreamSummaryWithContext(ctx context.Context, input *describeStreamSummaryInput) (*describeStreamSummaryOutput, error) {
+	output := &describeStreamSummaryOutput{}
+	return output, c.send(ctx, "DescribeStreamSummary", input, output)
+}
+
+type streamConsumer struct {
+	_ struct{} `type:"structure"`
+
+	ConsumerARN    *string `type:"string"`
+	ConsumerStatus *string `type:"string"`
+}
+
+type registerStreamConsumerInput struct {
+	_ struct{} `type:"structure"`
+
+	StreamARN    *string `type:"string"`
+	ConsumerName *string `type:"string"`
+}
+
+type registerStreamConsumerOutput struct {
+	_ struct{} `type:"structure"`
+
+	Consumer *streamConsumer `type:"structure"`
+}
+
+func (c *kinesisClient) RegisterStreamConsumerWithContext(ctx context.Context, input *registerStreamConsumerInput) (*registerStreamConsumerOutput, error) {
+	output := &registerStreamConsumerOutput{}
+	return output, c.send(ctx, "RegisterStreamConsumer", input, output)
+}
+
+type describeStreamConsumerInput struct {
+	_ struct{} `type:"structure"`
+
+	StreamARN    *string `type:"string"`
+	ConsumerName *string `type:"string"`
+}
+
+type describeStreamConsumerOutput struct {
+	_ struct{} `type:"structure"`
+
+	ConsumerDescription *streamConsumer `type:"structure"`
+}
+
+func (c *kinesisClient) DescribeStreamConsumerWithContext(ctx context.Context, input *describeStreamConsumerInput) (*describeStreamConsumerOutput, error) {
+	output := &describeStreamConsumerOutput{}
+	return output, c.send(ctx, "DescribeStreamConsumer", input, output)
+}
+
+type startingPosition struct {
+	_ struct{} `type:"structure"`
+
+	Type           *string    `type:"string"`
+	SequenceNumber *string    `type:"string"`
+	Timestamp      *time.Time `type:"timestamp"`
+}
+
+type subscribeToShardInput struct {
+	_ struct{} `type:"structure"`
+
+	ConsumerARN      *string           `type:"string"`
+	ShardId          *string           `type:"string"` //nolint:revive
+	StartingPosition *startingPosition `type:"structure"`
+}
+
+type subscribeToShardEvent struct {
+	_ struct{} `type:"structure"`
+
+	Records []*record `type:"list"`
+	// ContinuationSequenceNumber is where to subscribe again after this event, it
+	// isn't set once the shard has been entirely read.
+	ContinuationSequenceNumber *string `type:"string"`
+	MillisBehindLatest         *int64  `type:"long"`
+}
+
+// SubscribeToShardWithContext subscribes to a shard. The events are streamed in the
+// response body until the subscription expires after 5 minutes.
+func (c *kinesisClient) SubscribeToShardWithContext(ctx context.Context, input *subscribeToShardInput) (subscription, error) {
+	s := &eventStream{}
+	req := c.NewRequest(&request.Operation{Name: "SubscribeToShard", HTTPMethod: "POST", HTTPPath: "/"}, input, nil)
+	req.SetContext(ctx)
+	req.Handlers.Unmarshal.Swap(jsonrpc.UnmarshalHandler.Name, request.NamedHandler{
+		Name: "kinesis.SubscribeToShardStream",
+		Fn: func(r *request.Request) {
+			s.body = r.HTTPResponse.Body
+			s.decoder = eventstream.NewDecoder(r.HTTPResponse.Body)
+		},
+	})
+	if err := req.Send(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type eventStream struct {
+	body    io.ReadCloser
+	decoder *eventstream.Decoder
+	buf     []byte
+}
+
+func (s *eventStream) Next() (*subscribeToShardEvent, error) {
+	for {
+		msg, err := s.decoder.Decode(s.buf)
+		if err != nil {
+			return nil, err
+		}
+		s.buf = msg.Payload[:0]
+
+		switch valueString(msg.Headers.Get(eventstreamapi.MessageTypeHeader)) {
+		case eventstreamapi.EventMessageType:
+		case eventstreamapi.ExceptionMessageType:
+			var exception struct {
+				Message string `json:"message"`
+			}
+			_ = jsonutil.UnmarshalJSONError(&exception, bytes.NewReader(msg.Payload))
+			return nil, awserr.New(valueString(msg.Headers.Get(eventstreamapi.ExceptionTypeHeader)), exception.Message, nil)
+		case eventstreamapi.ErrorMessageType:
+			return nil, awserr.New(valueString(msg.Headers.Get(eventstreamapi.ErrorCodeHeader)), valueString(msg.Headers.Get(eventstreamapi.ErrorMessageHeader)), nil)
+		default:
+			return nil, fmt.Errorf("unexpected event stream message without %s header", eventstreamapi.MessageTypeHeader)
+		}
+		if valueString(msg.Headers.Get(eventstreamapi.EventTypeHeader)) != "SubscribeToShardEvent" {
+			// e.g. the initial-response event.
+			continue
+		}
+		event := &subscribeToShardEvent{}
+		if err := jsonutil.UnmarshalJSON(event, bytes.NewReader(msg.Payload)); err != nil {
+			return nil, fmt.Errorf("error decoding SubscribeToShardEvent: %w", err)
+		}
+		return event, nil
+	}
+}
+
+func (s *eventStream) Close() error {
+	return s.body.Close()
+}
+
+func valueString(v eventstream.Value) string {
+	if v == nil {
+		return ""
+	}
+	return v.String()
+}
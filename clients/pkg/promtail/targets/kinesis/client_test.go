@@ -0,0 +1,116 @@
+package kinesis
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream"
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream/eventstreamapi"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *kinesisClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")).
+		WithMaxRetries(0))
+	require.NoError(t, err)
+	return newKinesisClient(sess, aws.NewConfig().WithEndpoint(server.URL))
+}
+
+func Test_kinesisClient_GetRecords(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Kinesis_20131202.GetRecords", r.Header.Get("X-Amz-Target"))
+		var in map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		require.Equal(t, map[string]interface{}{"ShardIterator": "iterator", "Limit": float64(10)}, in)
+		_, _ = io.WriteString(w, `{
+			"Records": [{"SequenceNumber": "1", "ApproximateArrivalTimestamp": 1.6e9, "Data": "bG9n", "PartitionKey": "key"}],
+			"NextShardIterator": "next",
+			"MillisBehindLatest": 42
+		}`)
+	})
+
+	out, err := c.GetRecordsWithContext(context.Background(), &getRecordsInput{ShardIterator: aws.String("iterator"), Limit: aws.Int64(10)})
+	require.NoError(t, err)
+	require.Equal(t, &getRecordsOutput{
+		Records: []*record{{
+			SequenceNumber:              aws.String("1"),
+			ApproximateArrivalTimestamp: aws.Time(time.Unix(1.6e9, 0).UTC()),
+			Data:                        []byte("log"),
+			PartitionKey:                aws.String("key"),
+		}},
+		NextShardIterator:  aws.String("next"),
+		MillisBehindLatest: aws.Int64(42),
+	}, out)
+}
+
+func Test_kinesisClient_Error(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"__type": "ResourceInUseException", "message": "consumer already exists"}`)
+	})
+
+	_, err := c.RegisterStreamConsumerWithContext(context.Background(), &registerStreamConsumerInput{StreamARN: aws.String("arn"), ConsumerName: aws.String("promtail")})
+	var aerr awserr.Error
+	require.ErrorAs(t, err, &aerr)
+	require.Equal(t, errCodeResourceInUseException, aerr.Code())
+}
+
+func Test_kinesisClient_SubscribeToShard(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Kinesis_20131202.SubscribeToShard", r.Header.Get("X-Amz-Target"))
+		enc := eventstream.NewEncoder(w)
+		event := func(eventType string, payload string) {
+			var headers eventstream.Headers
+			headers.Set(eventstreamapi.MessageTypeHeader, eventstream.StringValue(eventstreamapi.EventMessageType))
+			headers.Set(eventstreamapi.EventTypeHeader, eventstream.StringValue(eventType))
+			require.NoError(t, enc.Encode(eventstream.Message{Headers: headers, Payload: []byte(payload)}))
+		}
+		event("initial-response", `{}`)
+		event("SubscribeToShardEvent", `{"Records": [{"SequenceNumber": "1", "Data": "bG9n"}], "ContinuationSequenceNumber": "1", "MillisBehindLatest": 0}`)
+		event("SubscribeToShardEvent", `{"Records": [], "MillisBehindLatest": 0}`)
+
+		var headers eventstream.Headers
+		headers.Set(eventstreamapi.MessageTypeHeader, eventstream.StringValue(eventstreamapi.ExceptionMessageType))
+		headers.Set(eventstreamapi.ExceptionTypeHeader, eventstream.StringValue("ResourceNotFoundException"))
+		require.NoError(t, enc.Encode(eventstream.Message{Headers: headers, Payload: []byte(`{"message": "shard not found"}`)}))
+	})
+
+	sub, err := c.SubscribeToShardWithContext(context.Background(), &subscribeToShardInput{
+		ConsumerARN:      aws.String("arn"),
+		ShardId:          aws.String("shard-0"),
+		StartingPosition: &startingPosition{Type: aws.String(shardIteratorTypeTrimHorizon)},
+	})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	event, err := sub.Next()
+	require.NoError(t, err)
+	require.Equal(t, "1", aws.StringValue(event.ContinuationSequenceNumber))
+	require.Equal(t, []byte("log"), event.Records[0].Data)
+
+	event, err = sub.Next()
+	require.NoError(t, err)
+	require.Nil(t, event.ContinuationSequenceNumber)
+
+	_, err = sub.Next()
+	var aerr awserr.Error
+	require.ErrorAs(t, err, &aerr)
+	require.Equal(t, "ResourceNotFoundException", aerr.Code())
+	require.Equal(t, "shard not found", aerr.Message())
+
+	_, err = sub.Next()
+	require.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,46 @@
+package kinesis
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds the metrics of the Kinesis targets.
+type Metrics struct {
+	// reg is the Registerer used to create this set of metrics.
+	reg prometheus.Registerer
+
+	records            *prometheus.CounterVec
+	errors             *prometheus.CounterVec
+	millisBehindLatest *prometheus.GaugeVec
+}
+
+// NewMetrics creates a new set of metrics. Metrics will be registered to reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	var m Metrics
+	m.reg = reg
+
+	m.records = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "kinesis_target_records_total",
+		Help:      "Total number of records read from Kinesis shards.",
+	}, []string{"stream", "shard"})
+	m.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "kinesis_target_errors_total",
+		Help:      "Total number of errors while reading Kinesis shards.",
+	}, []string{"stream", "shard"})
+	m.millisBehindLatest = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "kinesis_target_millis_behind_latest",
+		Help:      "Milliseconds between the last read record of a Kinesis shard and its latest record.",
+	}, []string{"stream", "shard"})
+
+	if reg != nil {
+		reg.MustRegister(m.records, m.errors, m.millisBehindLatest)
+	}
+	return &m
+}
+
+func (m *Metrics) deleteShard(stream, shard string) {
+	m.records.DeleteLabelValues(stream, shard)
+	m.errors.DeleteLabelValues(stream, shard)
+	m.millisBehindLatest.DeleteLabelValues(stream, shard)
+}
@@ -0,0 +1,400 @@
+package kinesis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/util"
+)
+
+const (
+	labelKeyKinesisStream       = "__meta_kinesis_stream"
+	labelKeyKinesisShardID      = "__meta_kinesis_shard_id"
+	labelKeyKinesisPartitionKey = "__meta_kinesis_partition_key"
+)
+
+// errShardEnd is returned once a closed shard has been entirely consumed.
+var errShardEnd = errors.New("shard end")
+
+// Target consumes the records of a shard, and checkpoints their sequence numbers
+// once they have been pushed to Loki.
+type Target struct {
+	logger        log.Logger
+	metrics       *Metrics
+	handler       api.EntryHandler
+	client        kinesisAPI
+	checkpointer  checkpointer
+	config        *scrapeconfig.KinesisTargetConfig
+	relabelConfig []*relabel.Config
+	shardID       string
+	// consumerARN is the stream consumer subscribing to the shard with enhanced fan-out.
+	consumerARN string
+	// onEnd is called once the shard has been entirely consumed.
+	onEnd func()
+
+	discoveredLabels model.LabelSet
+
+	mtx       sync.Mutex
+	position  string
+	lastError error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	// ended is set once the shard has been entirely consumed.
+	ended bool
+}
+
+func newTarget(
+	logger log.Logger,
+	metrics *Metrics,
+	handler api.EntryHandler,
+	client kinesisAPI,
+	checkpointer checkpointer,
+	config *scrapeconfig.KinesisTargetConfig,
+	relabelConfig []*relabel.Config,
+	shardID string,
+	consumerARN string,
+	onEnd func(),
+) *Target {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Target{
+		logger:        log.With(logger, "shard", shardID),
+		metrics:       metrics,
+		handler:       handler,
+		client:        client,
+		checkpointer:  checkpointer,
+		config:        config,
+		relabelConfig: relabelConfig,
+		shardID:       shardID,
+		consumerARN:   consumerARN,
+		onEnd:         onEnd,
+		discoveredLabels: model.LabelSet{
+			labelKeyKinesisStream:  model.LabelValue(config.StreamName),
+			labelKeyKinesisShardID: model.LabelValue(shardID),
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// run consumes the shard until it ends or the target is stopped. Reads are started
+// again from the last checkpoint on errors.
+func (t *Target) run() {
+	defer close(t.done)
+	for t.ctx.Err() == nil {
+		err := t.consume()
+		if errors.Is(err, errShardEnd) {
+			level.Info(t.logger).Log("msg", "shard has been entirely consumed")
+			t.mtx.Lock()
+			t.ended = true
+			t.mtx.Unlock()
+			t.onEnd()
+			return
+		}
+		if err == nil || t.ctx.Err() != nil {
+			continue
+		}
+		level.Warn(t.logger).Log("msg", "error consuming shard, starting again from the last checkpoint", "err", err)
+		t.metrics.errors.WithLabelValues(t.config.StreamName, t.shardID).Inc()
+		t.mtx.Lock()
+		t.lastError = err
+		t.mtx.Unlock()
+		t.wait(t.config.PollInterval)
+	}
+}
+
+func (t *Target) consume() error {
+	checkpoint, err := t.checkpointer.get(t.ctx, t.shardID)
+	if err != nil {
+		return err
+	}
+	if checkpoint == shardEnd {
+		return errShardEnd
+	}
+	t.mtx.Lock()
+	t.position = checkpoint
+	t.mtx.Unlock()
+	iteratorType, timestamp, err := startingIterator(checkpoint, t.config.InitialPosition)
+	if err != nil {
+		return err
+	}
+	if t.consumerARN != "" {
+		return t.subscribe(iteratorType, checkpoint, timestamp)
+	}
+	return t.poll(iteratorType, checkpoint, timestamp)
+}
+
+// startingIterator returns where reads start: after the checkpoint if any, at the initial
+// position otherwise.
+func startingIterator(checkpoint string, initialPosition string) (iteratorType string, timestamp *time.Time, err error) {
+	if checkpoint != "" {
+		return shardIteratorTypeAfterSequenceNumber, nil, nil
+	}
+	switch initialPosition {
+	case "", "trim_horizon":
+		return shardIteratorTypeTrimHorizon, nil, nil
+	case "latest":
+		return shardIteratorTypeLatest, nil, nil
+	default:
+		ts, err := time.Parse(time.RFC3339, initialPosition)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid initial position %q, must be trim_horizon, latest or a RFC3339 timestamp", initialPosition)
+		}
+		return shardIteratorTypeAtTimestamp, &ts, nil
+	}
+}
+
+// poll reads the records of the shard with GetRecords.
+func (t *Target) poll(iteratorType, checkpoint string, timestamp *time.Time) error {
+	in := &getShardIteratorInput{
+		StreamName:        aws.String(t.config.StreamName),
+		ShardId:           aws.String(t.shardID),
+		ShardIteratorType: aws.String(iteratorType),
+		Timestamp:         timestamp,
+	}
+	if checkpoint != "" {
+		in.StartingSequenceNumber = aws.String(checkpoint)
+	}
+	out, err := t.client.GetShardIteratorWithContext(t.ctx, in)
+	if err != nil {
+		return err
+	}
+	iterator := out.ShardIterator
+	for iterator != nil {
+		out, err := t.client.GetRecordsWithContext(t.ctx, &getRecordsInput{
+			ShardIterator: iterator,
+			Limit:         aws.Int64(int64(t.config.MaxRecords)),
+		})
+		if err != nil {
+			return err
+		}
+		t.metrics.millisBehindLatest.WithLabelValues(t.config.StreamName, t.shardID).Set(float64(aws.Int64Value(out.MillisBehindLatest)))
+		if err := t.send(out.Records); err != nil {
+			return err
+		}
+		if len(out.Records) > 0 {
+			if err := t.checkpoint(aws.StringValue(out.Records[len(out.Records)-1].SequenceNumber)); err != nil {
+				return err
+			}
+		}
+		iterator = out.NextShardIterator
+		// Read again right away while behind.
+		if len(out.Records) == 0 || aws.Int64Value(out.MillisBehindLatest) == 0 {
+			if !t.wait(t.config.PollInterval) {
+				return nil
+			}
+		}
+	}
+	return t.end()
+}
+
+// subscribe reads the records of the shard with a SubscribeToShard subscription, until it expires.
+func (t *Target) subscribe(iteratorType, checkpoint string, timestamp *time.Time) error {
+	position := &startingPosition{
+		Type:      aws.String(iteratorType),
+		Timestamp: timestamp,
+	}
+	if checkpoint != "" {
+		position.SequenceNumber = aws.String(checkpoint)
+	}
+	sub, err := t.client.SubscribeToShardWithContext(t.ctx, &subscribeToShardInput{
+		ConsumerARN:      aws.String(t.consumerARN),
+		ShardId:          aws.String(t.shardID),
+		StartingPosition: position,
+	})
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+	for {
+		event, err := sub.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t.metrics.millisBehindLatest.WithLabelValues(t.config.StreamName, t.shardID).Set(float64(aws.Int64Value(event.MillisBehindLatest)))
+		if err := t.send(event.Records); err != nil {
+			return err
+		}
+		if event.ContinuationSequenceNumber == nil {
+			return t.end()
+		}
+		if continuation := aws.StringValue(event.ContinuationSequenceNumber); continuation != t.getPosition() {
+			if err := t.checkpoint(continuation); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// send sends the entries of the records and waits until they have been pushed to Loki.
+func (t *Target) send(records []*record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	acked := make(chan error, 1)
+	ack := api.AckFunc(func(err error) { acked <- err }).Split(len(records))
+	for _, r := range records {
+		e := t.entry(r)
+		e.Ack = ack
+		select {
+		case t.handler.Chan() <- e:
+		case <-t.ctx.Done():
+			return t.ctx.Err()
+		}
+	}
+	t.metrics.records.WithLabelValues(t.config.StreamName, t.shardID).Add(float64(len(records)))
+	select {
+	case err := <-acked:
+		if err != nil {
+			return fmt.Errorf("records could not be pushed: %w", err)
+		}
+		return nil
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	}
+}
+
+func (t *Target) entry(r *record) api.Entry {
+	lbs := make(labels.Labels, 0, len(t.discoveredLabels)+1)
+	for name, value := range t.discoveredLabels {
+		lbs = append(lbs, labels.Label{Name: string(name), Value: string(value)})
+	}
+	lbs = append(lbs, labels.Label{Name: labelKeyKinesisPartitionKey, Value: aws.StringValue(r.PartitionKey)})
+
+	out := t.config.Labels.Clone()
+	if processed := format(lbs, t.relabelConfig); len(processed) > 0 {
+		out = out.Merge(processed)
+	}
+	ts := time.Now()
+	if t.config.UseIncomingTimestamp && r.ApproximateArrivalTimestamp != nil {
+		ts = *r.ApproximateArrivalTimestamp
+	}
+	return api.Entry{
+		Labels: out,
+		Entry: logproto.Entry{
+			Timestamp: ts,
+			Line:      string(r.Data),
+		},
+	}
+}
+
+func format(lbs labels.Labels, cfg []*relabel.Config) model.LabelSet {
+	processed := relabel.Process(labels.New(lbs...), cfg...)
+	labelOut := model.LabelSet(util.LabelsToMetric(processed))
+	for k := range labelOut {
+		if strings.HasPrefix(string(k), "__") {
+			delete(labelOut, k)
+		}
+	}
+	return labelOut
+}
+
+func (t *Target) checkpoint(sequenceNumber string) error {
+	if err := t.checkpointer.set(t.ctx, t.shardID, sequenceNumber); err != nil {
+		return err
+	}
+	t.mtx.Lock()
+	t.position = sequenceNumber
+	t.lastError = nil
+	t.mtx.Unlock()
+	return nil
+}
+
+// end checkpoints that the shard has been entirely consumed, its child shards can then be consumed.
+func (t *Target) end() error {
+	if err := t.checkpoint(shardEnd); err != nil {
+		return err
+	}
+	return errShardEnd
+}
+
+func (t *Target) getPosition() string {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.position
+}
+
+// wait waits for d, it returns false if the target has been stopped meanwhile.
+func (t *Target) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-t.ctx.Done():
+		return false
+	}
+}
+
+// Ended returns true once the shard has been entirely consumed.
+func (t *Target) Ended() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.ended
+}
+
+// Stop stops consuming the shard.
+func (t *Target) Stop() {
+	t.cancel()
+	<-t.done
+	t.metrics.deleteShard(t.config.StreamName, t.shardID)
+}
+
+// Type implements target.Target.
+func (t *Target) Type() target.TargetType {
+	return target.KinesisTargetType
+}
+
+// Ready implements target.Target.
+func (t *Target) Ready() bool {
+	return !t.Ended()
+}
+
+// DiscoveredLabels implements target.Target.
+func (t *Target) DiscoveredLabels() model.LabelSet {
+	return t.discoveredLabels
+}
+
+// Labels implements target.Target.
+func (t *Target) Labels() model.LabelSet {
+	return t.config.Labels
+}
+
+// Details implements target.Target.
+func (t *Target) Details() interface{} {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	details := map[string]string{
+		"stream":   t.config.StreamName,
+		"shard":    t.shardID,
+		"position": t.position,
+	}
+	if t.lastError != nil {
+		details["error"] = t.lastError.Error()
+	}
+	return details
+}
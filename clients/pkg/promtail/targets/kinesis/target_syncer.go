@@ -0,0 +1,346 @@
+package kinesis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/grafana/loki/clients/pkg/logentry/stages"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+)
+
+const (
+	defaultPollInterval      = time.Second
+	defaultMaxRecords        = 10000
+	defaultShardSyncInterval = time.Minute
+)
+
+// consumerStatusInterval is the interval at which the status of a stream consumer is
+// checked while waiting for it to become active.
+var consumerStatusInterval = 5 * time.Second
+
+// TargetSyncer consumes the shards of a stream with a target per shard. Shards are
+// listed periodically, the child shards of a resharding are consumed once their
+// parents have been entirely consumed to keep the order of the records of a key.
+type TargetSyncer struct {
+	logger       log.Logger
+	metrics      *Metrics
+	cfg          scrapeconfig.Config
+	handler      api.EntryHandler
+	client       kinesisAPI
+	checkpointer checkpointer
+
+	consumerARN string
+
+	mtx     sync.Mutex
+	targets map[string]*Target
+
+	resync chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewSyncer creates a TargetSyncer consuming the stream of a scrape config.
+func NewSyncer(
+	metrics *Metrics,
+	logger log.Logger,
+	positions positions.Positions,
+	cfg scrapeconfig.Config,
+	pushClient api.EntryHandler,
+) (*TargetSyncer, error) {
+	if err := validateConfig(&cfg); err != nil {
+		return nil, err
+	}
+	awsCfg := aws.NewConfig()
+	if cfg.KinesisConfig.Region != "" {
+		awsCfg = awsCfg.WithRegion(cfg.KinesisConfig.Region)
+	}
+	if cfg.KinesisConfig.AccessKeyID != "" {
+		awsCfg = awsCfg.WithCredentials(credentials.NewStaticCredentials(cfg.KinesisConfig.AccessKeyID, cfg.KinesisConfig.SecretAccessKey.Value, ""))
+	}
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AWS session: %w", err)
+	}
+	kinesisCfg := aws.NewConfig()
+	if cfg.KinesisConfig.Endpoint != "" {
+		kinesisCfg = kinesisCfg.WithEndpoint(cfg.KinesisConfig.Endpoint)
+	}
+
+	var checkpointer checkpointer
+	switch cfg.KinesisConfig.Checkpoint.Type {
+	case scrapeconfig.KinesisCheckpointDynamoDB:
+		checkpointer = newDynamoDBCheckpointer(dynamodb.New(sess), cfg.KinesisConfig.Checkpoint.DynamoDBTable, cfg.JobName, cfg.KinesisConfig.StreamName)
+	default:
+		checkpointer = newPositionsCheckpointer(positions, cfg.JobName, cfg.KinesisConfig.StreamName)
+	}
+	return newSyncer(metrics, logger, cfg, pushClient, newKinesisClient(sess, kinesisCfg), checkpointer)
+}
+
+func newSyncer(
+	metrics *Metrics,
+	logger log.Logger,
+	cfg scrapeconfig.Config,
+	pushClient api.EntryHandler,
+	client kinesisAPI,
+	checkpointer checkpointer,
+) (*TargetSyncer, error) {
+	logger = log.With(logger, "stream", cfg.KinesisConfig.StreamName)
+	pipeline, err := stages.NewPipeline(log.With(logger, "component", "kinesis_pipeline"), cfg.PipelineStages, &cfg.JobName, metrics.reg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := &TargetSyncer{
+		logger:       logger,
+		metrics:      metrics,
+		cfg:          cfg,
+		handler:      pipeline.Wrap(pushClient),
+		client:       client,
+		checkpointer: checkpointer,
+		targets:      make(map[string]*Target),
+		resync:       make(chan struct{}, 1),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	ts.wg.Add(1)
+	go ts.loop()
+	return ts, nil
+}
+
+func validateConfig(cfg *scrapeconfig.Config) error {
+	if cfg.KinesisConfig == nil {
+		return errors.New("Kinesis configuration is empty")
+	}
+	if cfg.KinesisConfig.StreamName == "" {
+		return errors.New("no Kinesis stream name given to be consumed")
+	}
+	if _, _, err := startingIterator("", cfg.KinesisConfig.InitialPosition); err != nil {
+		return err
+	}
+	if cfg.KinesisConfig.PollInterval < 0 {
+		return errors.New("poll interval must not be negative")
+	}
+	if cfg.KinesisConfig.PollInterval == 0 {
+		cfg.KinesisConfig.PollInterval = defaultPollInterval
+	}
+	if cfg.KinesisConfig.MaxRecords < 0 || cfg.KinesisConfig.MaxRecords > defaultMaxRecords {
+		return fmt.Errorf("max records must be between 1 and %d", defaultMaxRecords)
+	}
+	if cfg.KinesisConfig.MaxRecords == 0 {
+		cfg.KinesisConfig.MaxRecords = defaultMaxRecords
+	}
+	if cfg.KinesisConfig.ShardSyncInterval < 0 {
+		return errors.New("shard sync interval must not be negative")
+	}
+	if cfg.KinesisConfig.ShardSyncInterval == 0 {
+		cfg.KinesisConfig.ShardSyncInterval = defaultShardSyncInterval
+	}
+	switch cfg.KinesisConfig.Checkpoint.Type {
+	case "":
+		cfg.KinesisConfig.Checkpoint.Type = scrapeconfig.KinesisCheckpointPositions
+	case scrapeconfig.KinesisCheckpointPositions:
+	case scrapeconfig.KinesisCheckpointDynamoDB:
+		if cfg.KinesisConfig.Checkpoint.DynamoDBTable == "" {
+			return errors.New("no DynamoDB table given to store checkpoints")
+		}
+	default:
+		return fmt.Errorf("unrecognized checkpoint type: %s", cfg.KinesisConfig.Checkpoint.Type)
+	}
+	return nil
+}
+
+func (ts *TargetSyncer) loop() {
+	defer ts.wg.Done()
+	ticker := time.NewTicker(ts.cfg.KinesisConfig.ShardSyncInterval)
+	defer ticker.Stop()
+	for {
+		if err := ts.sync(); err != nil && ts.ctx.Err() == nil {
+			level.Warn(ts.logger).Log("msg", "error syncing shards", "err", err)
+		}
+		select {
+		case <-ts.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-ts.resync:
+		}
+	}
+}
+
+// sync starts the targets of the shards whose parents have been entirely consumed and
+// stops the targets of the shards which have expired.
+func (ts *TargetSyncer) sync() error {
+	if ts.cfg.KinesisConfig.EnhancedFanOut.ConsumerName != "" && ts.consumerARN == "" {
+		consumerARN, err := ts.registerConsumer()
+		if err != nil {
+			return fmt.Errorf("error registering stream consumer: %w", err)
+		}
+		ts.consumerARN = consumerARN
+	}
+	shards, err := ts.listShards()
+	if err != nil {
+		return fmt.Errorf("error listing shards: %w", err)
+	}
+	listed := make(map[string]struct{}, len(shards))
+	for _, s := range shards {
+		listed[aws.StringValue(s.ShardId)] = struct{}{}
+	}
+
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	for _, s := range shards {
+		id := aws.StringValue(s.ShardId)
+		if _, ok := ts.targets[id]; ok {
+			continue
+		}
+		if !ts.parentConsumed(listed, s.ParentShardId) || !ts.parentConsumed(listed, s.AdjacentParentShardId) {
+			continue
+		}
+		level.Info(ts.logger).Log("msg", "consuming shard", "shard", id)
+		ts.targets[id] = newTarget(ts.logger, ts.metrics, ts.handler, ts.client, ts.checkpointer, ts.cfg.KinesisConfig, ts.cfg.RelabelConfigs, id, ts.consumerARN, ts.triggerResync)
+	}
+	for id, t := range ts.targets {
+		if _, ok := listed[id]; ok {
+			continue
+		}
+		level.Info(ts.logger).Log("msg", "shard has expired", "shard", id)
+		t.Stop()
+		delete(ts.targets, id)
+	}
+	return nil
+}
+
+// parentConsumed returns true if the parent shard has been entirely consumed, or if it
+// has expired and its records can't be consumed anymore.
+func (ts *TargetSyncer) parentConsumed(listed map[string]struct{}, parent *string) bool {
+	if parent == nil {
+		return true
+	}
+	if _, ok := listed[*parent]; !ok {
+		return true
+	}
+	t, ok := ts.targets[*parent]
+	return ok && t.Ended()
+}
+
+func (ts *TargetSyncer) triggerResync() {
+	select {
+	case ts.resync <- struct{}{}:
+	default:
+	}
+}
+
+func (ts *TargetSyncer) listShards() ([]*shard, error) {
+	var shards []*shard
+	in := &listShardsInput{StreamName: aws.String(ts.cfg.KinesisConfig.StreamName)}
+	for {
+		out, err := ts.client.ListShardsWithContext(ts.ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, out.Shards...)
+		if out.NextToken == nil {
+			return shards, nil
+		}
+		// The stream name must not be set along with a next token.
+		in = &listShardsInput{NextToken: out.NextToken}
+	}
+}
+
+// registerConsumer registers the stream consumer of enhanced fan-out if it doesn't
+// exist yet, and waits until it is active.
+func (ts *TargetSyncer) registerConsumer() (string, error) {
+	summary, err := ts.client.DescribeStreamSummaryWithContext(ts.ctx, &describeStreamSummaryInput{
+		StreamName: aws.String(ts.cfg.KinesisConfig.StreamName),
+	})
+	if err != nil {
+		return "", err
+	}
+	streamARN := summary.StreamDescriptionSummary.StreamARN
+	consumerName := aws.String(ts.cfg.KinesisConfig.EnhancedFanOut.ConsumerName)
+
+	var consumer *streamConsumer
+	registered, err := ts.client.RegisterStreamConsumerWithContext(ts.ctx, &registerStreamConsumerInput{
+		StreamARN:    streamARN,
+		ConsumerName: consumerName,
+	})
+	var aerr awserr.Error
+	switch {
+	case err == nil:
+		consumer = registered.Consumer
+	case errors.As(err, &aerr) && aerr.Code() == errCodeResourceInUseException:
+		// The consumer is already registered.
+	default:
+		return "", err
+	}
+
+	ticker := time.NewTicker(consumerStatusInterval)
+	defer ticker.Stop()
+	for consumer == nil || aws.StringValue(consumer.ConsumerStatus) != consumerStatusActive {
+		if consumer != nil {
+			select {
+			case <-ticker.C:
+			case <-ts.ctx.Done():
+				return "", ts.ctx.Err()
+			}
+		}
+		described, err := ts.client.DescribeStreamConsumerWithContext(ts.ctx, &describeStreamConsumerInput{
+			StreamARN:    streamARN,
+			ConsumerName: consumerName,
+		})
+		if err != nil {
+			return "", err
+		}
+		consumer = described.ConsumerDescription
+	}
+	return aws.StringValue(consumer.ConsumerARN), nil
+}
+
+func (ts *TargetSyncer) getActiveTargets() []target.Target {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	result := make([]target.Target, 0, len(ts.targets))
+	for _, t := range ts.targets {
+		if !t.Ended() {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func (ts *TargetSyncer) getAllTargets() []target.Target {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	result := make([]target.Target, 0, len(ts.targets))
+	for _, t := range ts.targets {
+		result = append(result, t)
+	}
+	return result
+}
+
+// Stop stops consuming the shards of the stream.
+func (ts *TargetSyncer) Stop() {
+	ts.cancel()
+	ts.wg.Wait()
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	for id, t := range ts.targets {
+		t.Stop()
+		delete(ts.targets, id)
+	}
+	ts.handler.Stop()
+}
@@ -0,0 +1,365 @@
+package kinesis
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/clients/pkg/promtail/client/fake"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+// fakeKinesis is an in-memory stream, iterators are the shard id followed by the
+// index of the next record.
+type fakeKinesis struct {
+	mtx      sync.Mutex
+	shards   []*shard
+	records  map[string][]*record
+	closed   map[string]bool
+	consumer *streamConsumer
+}
+
+func newFakeKinesis() *fakeKinesis {
+	return &fakeKinesis{
+		records: map[string][]*record{},
+		closed:  map[string]bool{},
+	}
+}
+
+func (f *fakeKinesis) addShard(id string, parents ...string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	s := &shard{ShardId: aws.String(id)}
+	if len(parents) > 0 {
+		s.ParentShardId = aws.String(parents[0])
+	}
+	if len(parents) > 1 {
+		s.AdjacentParentShardId = aws.String(parents[1])
+	}
+	f.shards = append(f.shards, s)
+}
+
+func (f *fakeKinesis) put(shardID, partitionKey string, lines ...string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	for _, line := range lines {
+		f.records[shardID] = append(f.records[shardID], &record{
+			SequenceNumber:              aws.String(fmt.Sprintf("%s-%d", shardID, len(f.records[shardID]))),
+			ApproximateArrivalTimestamp: aws.Time(time.Unix(int64(len(f.records[shardID])), 0)),
+			Data:                        []byte(line),
+			PartitionKey:                aws.String(partitionKey),
+		})
+	}
+}
+
+func (f *fakeKinesis) close(shardID string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.closed[shardID] = true
+}
+
+func (f *fakeKinesis) ListShardsWithContext(_ context.Context, _ *listShardsInput) (*listShardsOutput, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return &listShardsOutput{Shards: append([]*shard(nil), f.shards...)}, nil
+}
+
+func (f *fakeKinesis) start(shardID, iteratorType string, sequenceNumber *string) int {
+	switch iteratorType {
+	case shardIteratorTypeLatest:
+		return len(f.records[shardID])
+	case shardIteratorTypeAfterSequenceNumber:
+		i, _ := strconv.Atoi(strings.TrimPrefix(aws.StringValue(sequenceNumber), shardID+"-"))
+		return i + 1
+	default:
+		return 0
+	}
+}
+
+func (f *fakeKinesis) GetShardIteratorWithContext(_ context.Context, in *getShardIteratorInput) (*getShardIteratorOutput, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	start := f.start(*in.ShardId, *in.ShardIteratorType, in.StartingSequenceNumber)
+	return &getShardIteratorOutput{ShardIterator: aws.String(fmt.Sprintf("%s/%d", *in.ShardId, start))}, nil
+}
+
+func (f *fakeKinesis) GetRecordsWithContext(_ context.Context, in *getRecordsInput) (*getRecordsOutput, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	parts := strings.Split(*in.ShardIterator, "/")
+	shardID := parts[0]
+	start, _ := strconv.Atoi(parts[1])
+	records := f.records[shardID][start:]
+	out := &getRecordsOutput{Records: records, MillisBehindLatest: aws.Int64(0)}
+	if !f.closed[shardID] {
+		out.NextShardIterator = aws.String(fmt.Sprintf("%s/%d", shardID, start+len(records)))
+	}
+	return out, nil
+}
+
+func (f *fakeKinesis) DescribeStreamSummaryWithContext(_ context.Context, in *describeStreamSummaryInput) (*describeStreamSummaryOutput, error) {
+	return &describeStreamSummaryOutput{StreamDescriptionSummary: &streamDescriptionSummary{
+		StreamARN: aws.String("arn:aws:kinesis:us-east-1:123456789012:stream/" + *in.StreamName),
+	}}, nil
+}
+
+func (f *fakeKinesis) RegisterStreamConsumerWithContext(_ context.Context, in *registerStreamConsumerInput) (*registerStreamConsumerOutput, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.consumer != nil {
+		return nil, awserr.New(errCodeResourceInUseException, "consumer already exists", nil)
+	}
+	f.consumer = &streamConsumer{
+		ConsumerARN:    aws.String(*in.StreamARN + "/consumer/" + *in.ConsumerName),
+		ConsumerStatus: aws.String("CREATING"),
+	}
+	return &registerStreamConsumerOutput{Consumer: f.consumer}, nil
+}
+
+func (f *fakeKinesis) DescribeStreamConsumerWithContext(_ context.Context, _ *describeStreamConsumerInput) (*describeStreamConsumerOutput, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	// The consumer becomes active once it has been described.
+	described := *f.consumer
+	f.consumer.ConsumerStatus = aws.String(consumerStatusActive)
+	return &describeStreamConsumerOutput{ConsumerDescription: &described}, nil
+}
+
+func (f *fakeKinesis) SubscribeToShardWithContext(_ context.Context, in *subscribeToShardInput) (subscription, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.consumer == nil || *in.ConsumerARN != *f.consumer.ConsumerARN {
+		return nil, awserr.New("ResourceNotFoundException", "unknown consumer", nil)
+	}
+	shardID := *in.ShardId
+	records := f.records[shardID][f.start(shardID, *in.StartingPosition.Type, in.StartingPosition.SequenceNumber):]
+	event := &subscribeToShardEvent{Records: records, MillisBehindLatest: aws.Int64(0)}
+	if !f.closed[shardID] {
+		// Before the first record, continue after the record -1.
+		event.ContinuationSequenceNumber = aws.String(fmt.Sprintf("%s--1", shardID))
+		if in.StartingPosition.SequenceNumber != nil {
+			event.ContinuationSequenceNumber = in.StartingPosition.SequenceNumber
+		}
+		if len(records) > 0 {
+			event.ContinuationSequenceNumber = records[len(records)-1].SequenceNumber
+		}
+	}
+	return &fakeSubscription{events: []*subscribeToShardEvent{event}}, nil
+}
+
+type fakeSubscription struct {
+	events []*subscribeToShardEvent
+}
+
+func (s *fakeSubscription) Next() (*subscribeToShardEvent, error) {
+	if len(s.events) == 0 {
+		// Let the subscription expire slowly.
+		time.Sleep(10 * time.Millisecond)
+		return nil, io.EOF
+	}
+	e := s.events[0]
+	s.events = s.events[1:]
+	return e, nil
+}
+
+func (s *fakeSubscription) Close() error { return nil }
+
+type memoryCheckpointer struct {
+	mtx         sync.Mutex
+	checkpoints map[string]string
+}
+
+func (c *memoryCheckpointer) get(_ context.Context, shardID string) (string, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.checkpoints[shardID], nil
+}
+
+func (c *memoryCheckpointer) set(_ context.Context, shardID, sequenceNumber string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.checkpoints[shardID] = sequenceNumber
+	return nil
+}
+
+func (c *memoryCheckpointer) checkpoint(shardID string) string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.checkpoints[shardID]
+}
+
+func lines(c *fake.Client) []string {
+	var res []string
+	for _, e := range c.Received() {
+		res = append(res, e.Line)
+	}
+	return res
+}
+
+func Test_TargetSyncer(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		consumerName string
+	}{
+		{name: "polling"},
+		{name: "enhanced fan-out", consumerName: "promtail"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			consumerStatusInterval = time.Millisecond
+			stream := newFakeKinesis()
+			stream.addShard("shard-0")
+			stream.put("shard-0", "a", "0-1", "0-2")
+			stream.close("shard-0")
+			stream.addShard("shard-1", "shard-0")
+			stream.put("shard-1", "b", "1-1")
+			stream.addShard("shard-2", "shard-0", "shard-3")
+			stream.put("shard-2", "b", "2-1")
+			// shard-3 has been consumed before a restart.
+			stream.addShard("shard-3")
+			stream.put("shard-3", "b", "3-1")
+			stream.close("shard-3")
+			checkpointer := &memoryCheckpointer{checkpoints: map[string]string{"shard-3": shardEnd}}
+
+			client := fake.New(func() {})
+			defer client.Stop()
+			cfg := scrapeconfig.Config{
+				JobName: "test",
+				RelabelConfigs: []*relabel.Config{
+					{
+						SourceLabels: model.LabelNames{labelKeyKinesisPartitionKey},
+						TargetLabel:  "key",
+						Replacement:  "$1",
+						Action:       relabel.Replace,
+						Regex:        relabel.MustNewRegexp("(.*)"),
+					},
+				},
+				KinesisConfig: &scrapeconfig.KinesisTargetConfig{
+					StreamName:     "logs",
+					Labels:         model.LabelSet{"job": "kinesis"},
+					PollInterval:   10 * time.Millisecond,
+					EnhancedFanOut: scrapeconfig.KinesisEnhancedFanOut{ConsumerName: tc.consumerName},
+				},
+			}
+			require.NoError(t, validateConfig(&cfg))
+			ts, err := newSyncer(NewMetrics(nil), log.NewNopLogger(), cfg, client, stream, checkpointer)
+			require.NoError(t, err)
+
+			require.Eventually(t, func() bool {
+				return len(client.Received()) == 4
+			}, 5*time.Second, 10*time.Millisecond)
+			received := lines(client)
+			// The records of the children are sent once the parent has been consumed.
+			require.Equal(t, []string{"0-1", "0-2"}, received[:2])
+			require.ElementsMatch(t, []string{"1-1", "2-1"}, received[2:])
+			require.Equal(t, model.LabelSet{"job": "kinesis", "key": "a"}, client.Received()[0].Labels)
+
+			require.Eventually(t, func() bool {
+				return checkpointer.checkpoint("shard-1") == "shard-1-0" && checkpointer.checkpoint("shard-2") == "shard-2-0"
+			}, 5*time.Second, 10*time.Millisecond)
+			require.Equal(t, shardEnd, checkpointer.checkpoint("shard-0"))
+			require.Len(t, ts.getActiveTargets(), 2)
+			require.Len(t, ts.getAllTargets(), 4)
+
+			// New records are read after the checkpoint.
+			stream.put("shard-1", "b", "1-2")
+			require.Eventually(t, func() bool {
+				return len(client.Received()) == 5
+			}, 5*time.Second, 10*time.Millisecond)
+			require.Equal(t, "1-2", client.Received()[4].Line)
+			ts.Stop()
+		})
+	}
+}
+
+func Test_validateConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      *scrapeconfig.Config
+		wantErr  bool
+		expected *scrapeconfig.Config
+	}{
+		{
+			"empty",
+			&scrapeconfig.Config{
+				KinesisConfig: nil,
+			},
+			true,
+			nil,
+		},
+		{
+			"missing stream name",
+			&scrapeconfig.Config{
+				KinesisConfig: &scrapeconfig.KinesisTargetConfig{},
+			},
+			true,
+			nil,
+		},
+		{
+			"invalid initial position",
+			&scrapeconfig.Config{
+				KinesisConfig: &scrapeconfig.KinesisTargetConfig{StreamName: "logs", InitialPosition: "earliest"},
+			},
+			true,
+			nil,
+		},
+		{
+			"too many records",
+			&scrapeconfig.Config{
+				KinesisConfig: &scrapeconfig.KinesisTargetConfig{StreamName: "logs", MaxRecords: 10001},
+			},
+			true,
+			nil,
+		},
+		{
+			"missing dynamodb table",
+			&scrapeconfig.Config{
+				KinesisConfig: &scrapeconfig.KinesisTargetConfig{
+					StreamName: "logs",
+					Checkpoint: scrapeconfig.KinesisCheckpoint{Type: scrapeconfig.KinesisCheckpointDynamoDB},
+				},
+			},
+			true,
+			nil,
+		},
+		{
+			"defaults",
+			&scrapeconfig.Config{
+				KinesisConfig: &scrapeconfig.KinesisTargetConfig{StreamName: "logs", InitialPosition: "2022-01-02T15:04:05Z"},
+			},
+			false,
+			&scrapeconfig.Config{
+				KinesisConfig: &scrapeconfig.KinesisTargetConfig{
+					StreamName:        "logs",
+					InitialPosition:   "2022-01-02T15:04:05Z",
+					PollInterval:      time.Second,
+					MaxRecords:        10000,
+					ShardSyncInterval: time.Minute,
+					Checkpoint:        scrapeconfig.KinesisCheckpoint{Type: scrapeconfig.KinesisCheckpointPositions},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				require.Equal(t, tt.expected, tt.cfg)
+			}
+		})
+	}
+}
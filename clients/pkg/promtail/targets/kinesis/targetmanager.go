@@ -0,0 +1,72 @@
+package kinesis
+
+import (
+	"github.com/go-kit/log"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+)
+
+// TargetManager manages a series of Kinesis targets.
+type TargetManager struct {
+	logger        log.Logger
+	targetSyncers map[string]*TargetSyncer
+}
+
+// NewTargetManager creates a new Kinesis manager.
+func NewTargetManager(
+	metrics *Metrics,
+	logger log.Logger,
+	positions positions.Positions,
+	pushClient api.EntryHandler,
+	scrapeConfigs []scrapeconfig.Config,
+) (*TargetManager, error) {
+	tm := &TargetManager{
+		logger:        logger,
+		targetSyncers: make(map[string]*TargetSyncer),
+	}
+	for _, cfg := range scrapeConfigs {
+		t, err := NewSyncer(metrics, logger, positions, cfg, pushClient)
+		if err != nil {
+			tm.Stop()
+			return nil, err
+		}
+		tm.targetSyncers[cfg.JobName] = t
+	}
+
+	return tm, nil
+}
+
+// Ready returns true if at least one shard is being consumed.
+func (tm *TargetManager) Ready() bool {
+	for _, t := range tm.targetSyncers {
+		if len(t.getActiveTargets()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (tm *TargetManager) Stop() {
+	for _, t := range tm.targetSyncers {
+		t.Stop()
+	}
+}
+
+func (tm *TargetManager) ActiveTargets() map[string][]target.Target {
+	result := make(map[string][]target.Target, len(tm.targetSyncers))
+	for k, v := range tm.targetSyncers {
+		result[k] = v.getActiveTargets()
+	}
+	return result
+}
+
+func (tm *TargetManager) AllTargets() map[string][]target.Target {
+	result := make(map[string][]target.Target, len(tm.targetSyncers))
+	for k, v := range tm.targetSyncers {
+		result[k] = v.getAllTargets()
+	}
+	return result
+}
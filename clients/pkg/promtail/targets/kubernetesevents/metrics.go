@@ -0,0 +1,34 @@
+package kubernetesevents
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds the metrics of the Kubernetes events targets.
+type Metrics struct {
+	// reg is the Registerer used to create this set of metrics.
+	reg prometheus.Registerer
+
+	entries *prometheus.CounterVec
+	errors  prometheus.Counter
+}
+
+// NewMetrics creates a new set of metrics. Metrics will be registered to reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	var m Metrics
+	m.reg = reg
+
+	m.entries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "kubernetes_events_target_entries_total",
+		Help:      "Total number of Kubernetes events pushed as log lines.",
+	}, []string{"namespace"})
+	m.errors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "kubernetes_events_target_errors_total",
+		Help:      "Total number of errors while listing or watching Kubernetes events.",
+	})
+
+	if reg != nil {
+		reg.MustRegister(m.entries, m.errors)
+	}
+	return &m
+}
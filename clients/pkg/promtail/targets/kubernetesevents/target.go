@@ -0,0 +1,423 @@
+package kubernetesevents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/go-logfmt/logfmt"
+	"github.com/grafana/dskit/backoff"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/util"
+)
+
+const (
+	labelKeyEventNamespace          = "__meta_kubernetes_event_namespace"
+	labelKeyEventType               = "__meta_kubernetes_event_type"
+	labelKeyEventReason             = "__meta_kubernetes_event_reason"
+	labelKeyEventInvolvedObjectKind = "__meta_kubernetes_event_involved_object_kind"
+	labelKeyEventInvolvedObjectName = "__meta_kubernetes_event_involved_object_name"
+	labelKeyEventSourceComponent    = "__meta_kubernetes_event_source_component"
+	labelKeyEventSourceHost         = "__meta_kubernetes_event_source_host"
+)
+
+var retryBackoff = backoff.Config{
+	MinBackoff: 1 * time.Second,
+	MaxBackoff: 60 * time.Second,
+}
+
+// Target watches the events of a Kubernetes cluster and pushes each event as a log line.
+// The resource version of the last event pushed is stored in the positions file, the
+// events already pushed are skipped when listing them again after a restart.
+type Target struct {
+	logger        log.Logger
+	metrics       *Metrics
+	handler       api.EntryHandler
+	positions     positions.Positions
+	jobName       string
+	relabelConfig []*relabel.Config
+	config        *scrapeconfig.KubernetesEventsTargetConfig
+	listWatch     func(namespace string) cache.ListerWatcher
+
+	mtx       sync.Mutex
+	watching  map[string]bool
+	events    int
+	lastError error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewTarget creates a Target watching the events of a scrape config.
+func NewTarget(
+	metrics *Metrics,
+	logger log.Logger,
+	handler api.EntryHandler,
+	positions positions.Positions,
+	jobName string,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.KubernetesEventsTargetConfig,
+) (*Target, error) {
+	if config == nil {
+		return nil, errors.New("Kubernetes events configuration is empty")
+	}
+	restConfig, err := clientcmd.BuildConfigFromFlags(config.APIServer, config.KubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error loading Kubernetes client configuration: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Kubernetes client: %w", err)
+	}
+	listWatch := func(namespace string) cache.ListerWatcher {
+		return cache.NewFilteredListWatchFromClient(client.CoreV1().RESTClient(), "events", namespace, func(options *metav1.ListOptions) {
+			options.FieldSelector = config.FieldSelector
+			options.LabelSelector = config.LabelSelector
+		})
+	}
+	return newTarget(metrics, logger, handler, positions, jobName, relabelConfig, config, listWatch), nil
+}
+
+func newTarget(
+	metrics *Metrics,
+	logger log.Logger,
+	handler api.EntryHandler,
+	positions positions.Positions,
+	jobName string,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.KubernetesEventsTargetConfig,
+	listWatch func(namespace string) cache.ListerWatcher,
+) *Target {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Target{
+		logger:        logger,
+		metrics:       metrics,
+		handler:       handler,
+		positions:     positions,
+		jobName:       jobName,
+		relabelConfig: relabelConfig,
+		config:        config,
+		listWatch:     listWatch,
+		watching:      make(map[string]bool),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	namespaces := config.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	for _, namespace := range namespaces {
+		t.wg.Add(1)
+		go t.run(namespace)
+	}
+	return t
+}
+
+// positionKey is the key of the resource version of the last event of a namespace pushed
+// in the positions file.
+func positionKey(jobName, namespace string) string {
+	if namespace == metav1.NamespaceAll {
+		return fmt.Sprintf("kubernetes-events-%s", jobName)
+	}
+	return fmt.Sprintf("kubernetes-events-%s-%s", jobName, namespace)
+}
+
+// run watches the events of a namespace until the target is stopped, the events are
+// listed again when the watch ends.
+func (t *Target) run(namespace string) {
+	defer t.wg.Done()
+	logger := log.With(t.logger, "namespace", namespace)
+	lw := t.listWatch(namespace)
+	b := backoff.New(t.ctx, retryBackoff)
+	for t.ctx.Err() == nil {
+		err := t.listAndWatch(namespace, lw)
+		t.setWatching(namespace, false)
+		if t.ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			b.Reset()
+			continue
+		}
+		level.Warn(logger).Log("msg", "error watching Kubernetes events, listing them again", "err", err)
+		t.metrics.errors.Inc()
+		t.mtx.Lock()
+		t.lastError = err
+		t.mtx.Unlock()
+		b.Wait()
+	}
+}
+
+// listAndWatch lists the events of a namespace, pushes those newer than the last event
+// pushed and watches the next ones. On the first start the events listed are skipped.
+func (t *Target) listAndWatch(namespace string, lw cache.ListerWatcher) error {
+	key := positionKey(t.jobName, namespace)
+	last, _ := strconv.ParseUint(t.positions.GetString(key), 10, 64)
+
+	obj, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	list, ok := obj.(*corev1.EventList)
+	if !ok {
+		return fmt.Errorf("unexpected list of type %T", obj)
+	}
+	if last > 0 {
+		items := list.Items
+		sort.Slice(items, func(i, j int) bool {
+			return resourceVersion(items[i].ResourceVersion) < resourceVersion(items[j].ResourceVersion)
+		})
+		for i := range items {
+			if resourceVersion(items[i].ResourceVersion) > last {
+				if !t.send(&items[i]) {
+					return nil
+				}
+			}
+		}
+	}
+	if rv := resourceVersion(list.ResourceVersion); rv > last {
+		last = rv
+		t.positions.PutString(key, list.ResourceVersion)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{ResourceVersion: list.ResourceVersion, AllowWatchBookmarks: true})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+	t.setWatching(namespace, true)
+	for {
+		select {
+		case <-t.ctx.Done():
+			return nil
+		case ev, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+			switch ev.Type {
+			case watch.Added, watch.Modified:
+				event, ok := ev.Object.(*corev1.Event)
+				if !ok {
+					continue
+				}
+				rv := resourceVersion(event.ResourceVersion)
+				if rv != 0 && rv <= last {
+					continue
+				}
+				if !t.send(event) {
+					return nil
+				}
+				if rv > last {
+					last = rv
+					t.positions.PutString(key, event.ResourceVersion)
+				}
+			case watch.Bookmark:
+				if event, ok := ev.Object.(*corev1.Event); ok {
+					if rv := resourceVersion(event.ResourceVersion); rv > last {
+						last = rv
+						t.positions.PutString(key, event.ResourceVersion)
+					}
+				}
+			case watch.Error:
+				return apierrors.FromObject(ev.Object)
+			}
+		}
+	}
+}
+
+// resourceVersion returns the resource version as a number, 0 if it isn't one. Resource
+// versions are opaque but those of the etcd backed API servers are increasing numbers.
+func resourceVersion(rv string) uint64 {
+	n, _ := strconv.ParseUint(rv, 10, 64)
+	return n
+}
+
+// send pushes an event, it returns false if the target has been stopped meanwhile.
+func (t *Target) send(event *corev1.Event) bool {
+	lbs := t.labels(event)
+	if len(lbs) == 0 {
+		return true
+	}
+	entry := api.Entry{
+		Labels: lbs,
+		Entry:  logproto.Entry{Timestamp: eventTime(event), Line: eventLine(event)},
+	}
+	select {
+	case t.handler.Chan() <- entry:
+	case <-t.ctx.Done():
+		return false
+	}
+	t.metrics.entries.WithLabelValues(event.Namespace).Inc()
+	t.mtx.Lock()
+	t.events++
+	t.lastError = nil
+	t.mtx.Unlock()
+	return true
+}
+
+func (t *Target) labels(event *corev1.Event) model.LabelSet {
+	builder := labels.NewBuilder(nil)
+	builder.Set(labelKeyEventNamespace, event.Namespace)
+	builder.Set(labelKeyEventType, event.Type)
+	builder.Set(labelKeyEventReason, event.Reason)
+	builder.Set(labelKeyEventInvolvedObjectKind, event.InvolvedObject.Kind)
+	builder.Set(labelKeyEventInvolvedObjectName, event.InvolvedObject.Name)
+	builder.Set(labelKeyEventSourceComponent, sourceComponent(event))
+	builder.Set(labelKeyEventSourceHost, event.Source.Host)
+	builder.Set("namespace", event.Namespace)
+	builder.Set("kind", event.InvolvedObject.Kind)
+	builder.Set("reason", event.Reason)
+	for name, value := range t.config.Labels {
+		builder.Set(string(name), string(value))
+	}
+	processed := relabel.Process(builder.Labels(), t.relabelConfig...)
+	out := model.LabelSet(util.LabelsToMetric(processed))
+	for name := range out {
+		if strings.HasPrefix(string(name), "__") {
+			delete(out, name)
+		}
+	}
+	return out
+}
+
+func sourceComponent(event *corev1.Event) string {
+	if event.Source.Component != "" {
+		return event.Source.Component
+	}
+	return event.ReportingController
+}
+
+// eventTime returns the last time an event occurred.
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	case !event.CreationTimestamp.IsZero():
+		return event.CreationTimestamp.Time
+	default:
+		return time.Now()
+	}
+}
+
+// eventLine formats an event as a logfmt line.
+func eventLine(event *corev1.Event) string {
+	count := event.Count
+	if event.Series != nil {
+		count = event.Series.Count
+	}
+	keyvals := []interface{}{
+		"type", event.Type,
+		"reason", event.Reason,
+		"object", event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name,
+	}
+	if source := sourceComponent(event); source != "" {
+		keyvals = append(keyvals, "source", source)
+	}
+	if event.Source.Host != "" {
+		keyvals = append(keyvals, "host", event.Source.Host)
+	}
+	if count > 1 {
+		keyvals = append(keyvals, "count", count)
+	}
+	keyvals = append(keyvals, "msg", strings.TrimSpace(event.Message))
+	line, err := logfmt.MarshalKeyvals(keyvals...)
+	if err != nil {
+		return event.Message
+	}
+	return string(line)
+}
+
+func (t *Target) setWatching(namespace string, watching bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.watching[namespace] = watching
+}
+
+// Stop stops watching the events.
+func (t *Target) Stop() {
+	t.cancel()
+	t.wg.Wait()
+	t.handler.Stop()
+}
+
+// Type implements target.Target.
+func (t *Target) Type() target.TargetType {
+	return target.KubernetesEventsTargetType
+}
+
+// Ready implements target.Target.
+func (t *Target) Ready() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for _, watching := range t.watching {
+		if watching {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscoveredLabels implements target.Target.
+func (t *Target) DiscoveredLabels() model.LabelSet {
+	return nil
+}
+
+// Labels implements target.Target.
+func (t *Target) Labels() model.LabelSet {
+	return t.config.Labels
+}
+
+// Details implements target.Target.
+func (t *Target) Details() interface{} {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	var watching []string
+	for namespace, ok := range t.watching {
+		if !ok {
+			continue
+		}
+		if namespace == metav1.NamespaceAll {
+			namespace = "*"
+		}
+		watching = append(watching, namespace)
+	}
+	sort.Strings(watching)
+	details := map[string]string{
+		"watching": strings.Join(watching, ","),
+		"events":   strconv.Itoa(t.events),
+	}
+	if t.lastError != nil {
+		details["error"] = t.lastError.Error()
+	}
+	return details
+}
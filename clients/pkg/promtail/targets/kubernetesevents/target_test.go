@@ -0,0 +1,145 @@
+package kubernetesevents
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/grafana/loki/clients/pkg/promtail/client/fake"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+// fakeAPIServer lists the events it holds and hands out watchers the test sends events to.
+type fakeAPIServer struct {
+	mtx      sync.Mutex
+	events   []corev1.Event
+	rv       string
+	watchers chan *watch.FakeWatcher
+	watches  []string
+}
+
+func newFakeAPIServer(rv string, events ...corev1.Event) *fakeAPIServer {
+	return &fakeAPIServer{events: events, rv: rv, watchers: make(chan *watch.FakeWatcher, 10)}
+}
+
+func (f *fakeAPIServer) listWatch(string) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(metav1.ListOptions) (runtime.Object, error) {
+			f.mtx.Lock()
+			defer f.mtx.Unlock()
+			return &corev1.EventList{ListMeta: metav1.ListMeta{ResourceVersion: f.rv}, Items: append([]corev1.Event(nil), f.events...)}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			f.mtx.Lock()
+			f.watches = append(f.watches, options.ResourceVersion)
+			f.mtx.Unlock()
+			w := watch.NewFakeWithChanSize(10, false)
+			f.watchers <- w
+			return w, nil
+		},
+	}
+}
+
+func event(rv, namespace, typ, reason, kind, name, message string, count int32, ts time.Time) corev1.Event {
+	return corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name + "." + rv, Namespace: namespace, ResourceVersion: rv},
+		InvolvedObject: corev1.ObjectReference{Kind: kind, Namespace: namespace, Name: name},
+		Type:           typ,
+		Reason:         reason,
+		Message:        message,
+		Count:          count,
+		Source:         corev1.EventSource{Component: "kubelet", Host: "node-1"},
+		LastTimestamp:  metav1.NewTime(ts),
+	}
+}
+
+func TestTarget(t *testing.T) {
+	ps, err := positions.New(log.NewNopLogger(), positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: filepath.Join(t.TempDir(), "positions.yml"),
+	})
+	require.NoError(t, err)
+	defer ps.Stop()
+
+	now := time.Unix(1600000000, 0).UTC()
+	config := &scrapeconfig.KubernetesEventsTargetConfig{Labels: model.LabelSet{"job": "events"}}
+	relabelConfig := []*relabel.Config{
+		{
+			SourceLabels: model.LabelNames{labelKeyEventReason},
+			Regex:        relabel.MustNewRegexp("Pulled"),
+			Action:       relabel.Drop,
+		},
+	}
+	newTestTarget := func(server *fakeAPIServer) (*Target, *fake.Client) {
+		client := fake.New(func() {})
+		return newTarget(NewMetrics(prometheus.NewRegistry()), log.NewNopLogger(), client, ps, "events", relabelConfig, config, server.listWatch), client
+	}
+
+	// the events listed on the first start are skipped.
+	server := newFakeAPIServer("12", event("10", "default", "Normal", "Scheduled", "Pod", "web-1", "Successfully assigned", 1, now))
+	target, client := newTestTarget(server)
+	w := <-server.watchers
+	require.Eventually(t, target.Ready, 5*time.Second, 10*time.Millisecond)
+	w.Add(ptr(event("13", "default", "Warning", "BackOff", "Pod", "web-1", "Back-off restarting failed container", 1, now.Add(time.Second))))
+	w.Add(ptr(event("14", "default", "Normal", "Pulled", "Pod", "web-1", "Container image pulled", 1, now.Add(2*time.Second))))
+	w.Modify(ptr(event("15", "default", "Warning", "BackOff", "Pod", "web-1", "Back-off restarting failed container", 3, now.Add(3*time.Second))))
+	require.Eventually(t, func() bool { return ps.GetString(positionKey("events", "")) == "15" }, 5*time.Second, 10*time.Millisecond)
+	target.Stop()
+
+	received := client.Received()
+	require.Len(t, received, 2)
+	require.Equal(t, model.LabelSet{"job": "events", "namespace": "default", "kind": "Pod", "reason": "BackOff"}, received[0].Labels)
+	require.Equal(t, `type=Warning reason=BackOff object=Pod/web-1 source=kubelet host=node-1 msg="Back-off restarting failed container"`, received[0].Line)
+	require.True(t, now.Add(time.Second).Equal(received[0].Timestamp))
+	require.Equal(t, `type=Warning reason=BackOff object=Pod/web-1 source=kubelet host=node-1 count=3 msg="Back-off restarting failed container"`, received[1].Line)
+
+	// after a restart only the events newer than the last one pushed are, also when the
+	// watch fails and events are listed again.
+	server = newFakeAPIServer("17",
+		event("10", "default", "Normal", "Scheduled", "Pod", "web-1", "Successfully assigned", 1, now),
+		event("16", "kube-system", "Warning", "Unhealthy", "Pod", "dns-1", "Readiness probe failed", 1, now.Add(4*time.Second)),
+		event("15", "default", "Warning", "BackOff", "Pod", "web-1", "Back-off restarting failed container", 3, now.Add(3*time.Second)),
+	)
+	target, client = newTestTarget(server)
+	defer target.Stop()
+	w = <-server.watchers
+	require.Eventually(t, func() bool { return len(client.Received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, model.LabelSet{"job": "events", "namespace": "kube-system", "kind": "Pod", "reason": "Unhealthy"}, client.Received()[0].Labels)
+
+	server.mtx.Lock()
+	server.events = append(server.events, event("18", "default", "Normal", "Killing", "Pod", "web-1", "Stopping container", 1, now.Add(5*time.Second)))
+	server.rv = "19"
+	server.mtx.Unlock()
+	w.Error(&metav1.Status{Status: metav1.StatusFailure, Code: 410, Reason: metav1.StatusReasonExpired, Message: "too old resource version"})
+	w = <-server.watchers
+	w.Add(ptr(event("20", "default", "Normal", "Started", "Pod", "web-1", "Started container", 1, now.Add(6*time.Second))))
+	require.Eventually(t, func() bool { return len(client.Received()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	var reasons []string
+	for _, e := range client.Received() {
+		reasons = append(reasons, string(e.Labels["reason"]))
+	}
+	sort.Strings(reasons)
+	require.Equal(t, []string{"Killing", "Started", "Unhealthy"}, reasons)
+	server.mtx.Lock()
+	require.Equal(t, []string{"17", "19"}, server.watches)
+	server.mtx.Unlock()
+	require.Eventually(t, func() bool { return ps.GetString(positionKey("events", "")) == "20" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func ptr(e corev1.Event) *corev1.Event {
+	return &e
+}
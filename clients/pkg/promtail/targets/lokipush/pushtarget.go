@@ -2,10 +2,14 @@ package lokipush
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
-	"sort"
 	"strings"
 	"time"
 
@@ -18,6 +22,7 @@ import (
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/pkg/relabel"
 	promql_parser "github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/util/strutil"
 	"github.com/weaveworks/common/server"
 
 	"github.com/grafana/loki/clients/pkg/promtail/api"
@@ -28,6 +33,17 @@ import (
 	"github.com/grafana/loki/pkg/logproto"
 )
 
+const (
+	labelKeyHTTPRemoteIP     = "__meta_http_remote_ip"
+	labelKeyHTTPHeaderPrefix = "__meta_http_header_"
+	labelKeyHTTPQueryPrefix  = "__meta_http_query_"
+
+	// RawFormatLines makes a log line of each non empty line of the plaintext bodies.
+	RawFormatLines = "lines"
+	// RawFormatJSON makes a log line of each JSON document of the plaintext bodies.
+	RawFormatJSON = "json"
+)
+
 type PushTarget struct {
 	logger        log.Logger
 	handler       api.EntryHandler
@@ -43,6 +59,14 @@ func NewPushTarget(logger log.Logger,
 	jobName string,
 	config *scrapeconfig.PushTargetConfig) (*PushTarget, error) {
 
+	switch config.RawFormat {
+	case "":
+		config.RawFormat = RawFormatLines
+	case RawFormatLines, RawFormatJSON:
+	default:
+		return nil, fmt.Errorf("unsupported raw format %q, must be %s or %s", config.RawFormat, RawFormatLines, RawFormatJSON)
+	}
+
 	pt := &PushTarget{
 		logger:        logger,
 		handler:       handler,
@@ -110,12 +134,13 @@ func (t *PushTarget) run() error {
 func (t *PushTarget) handleLoki(w http.ResponseWriter, r *http.Request) {
 	logger := util_log.WithContext(r.Context(), util_log.Logger)
 	userID, _ := tenant.TenantID(r.Context())
-	req, err := push.ParseRequest(logger, userID, r, nil)
+	req, err := push.ParseRequest(logger, userID, r, nil, push.BodyLimits{})
 	if err != nil {
 		level.Warn(t.logger).Log("msg", "failed to parse incoming push request", "err", err.Error())
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	meta := requestLabels(r)
 	var lastErr error
 	for _, stream := range req.Streams {
 		ls, err := promql_parser.ParseMetric(stream.Labels)
@@ -123,36 +148,19 @@ func (t *PushTarget) handleLoki(w http.ResponseWriter, r *http.Request) {
 			lastErr = err
 			continue
 		}
-		sort.Sort(ls)
-
-		lb := labels.NewBuilder(ls)
-
-		// Add configured labels
-		for k, v := range t.config.Labels {
-			lb.Set(string(k), string(v))
-		}
 
-		// Apply relabeling
-		processed := relabel.Process(lb.Labels(), t.relabelConfig...)
-		if processed == nil || len(processed) == 0 {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-
-		// Convert to model.LabelSet
-		filtered := model.LabelSet{}
-		for i := range processed {
-			if strings.HasPrefix(processed[i].Name, "__") {
-				continue
-			}
-			filtered[model.LabelName(processed[i].Name)] = model.LabelValue(processed[i].Value)
+		// Apply relabeling, the streams whose labels are dropped are dropped.
+		filtered, ok := t.labels(append(meta.Copy(), ls...))
+		if !ok {
+			continue
 		}
 
 		for _, entry := range stream.Entries {
 			e := api.Entry{
 				Labels: filtered.Clone(),
 				Entry: logproto.Entry{
-					Line: entry.Line,
+					Line:               entry.Line,
+					StructuredMetadata: entry.StructuredMetadata,
 				},
 			}
 			if t.config.KeepTimestamp {
@@ -173,38 +181,131 @@ func (t *PushTarget) handleLoki(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// handlePlaintext handles newline delimited input such as plaintext or NDJSON.
+// handlePlaintext handles newline delimited input such as plaintext or NDJSON, or JSON documents
+// with the json raw format.
 func (t *PushTarget) handlePlaintext(w http.ResponseWriter, r *http.Request) {
-	entries := t.handler.Chan()
 	defer r.Body.Close()
-	body := bufio.NewReader(r.Body)
-	for {
-		line, err := body.ReadString('\n')
-		if err != nil && err != io.EOF {
-			level.Warn(t.logger).Log("msg", "failed to read incoming push request", "err", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		line = strings.TrimRight(line, "\r\n")
-		if line == "" {
-			if err == io.EOF {
-				break
-			}
-			continue
-		}
+	lbs, ok := t.labels(requestLabels(r))
+	if !ok {
+		_, _ = io.Copy(ioutil.Discard, r.Body)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	entries := t.handler.Chan()
+	emit := func(line string) {
 		entries <- api.Entry{
-			Labels: t.Labels().Clone(),
+			Labels: lbs.Clone(),
 			Entry: logproto.Entry{
 				Timestamp: time.Now(),
 				Line:      line,
 			},
 		}
+	}
+	read := readLines
+	if t.config.RawFormat == RawFormatJSON {
+		read = readJSON
+	}
+	if err := read(r.Body, emit); err != nil {
+		level.Warn(t.logger).Log("msg", "failed to read incoming push request", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readLines calls emit with each non empty line of r.
+func readLines(r io.Reader, emit func(string)) error {
+	body := bufio.NewReader(r)
+	for {
+		line, err := body.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			emit(line)
+		}
 		if err == io.EOF {
-			break
+			return nil
 		}
 	}
+}
 
-	w.WriteHeader(http.StatusNoContent)
+// readJSON calls emit with each JSON document of r, compacted on a single line. Arrays
+// are split into their elements, string elements being emitted unquoted.
+func readJSON(r io.Reader, emit func(string)) error {
+	dec := json.NewDecoder(r)
+	for {
+		var doc json.RawMessage
+		if err := dec.Decode(&doc); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("invalid JSON body: %w", err)
+		}
+		docs := []json.RawMessage{doc}
+		if bytes.HasPrefix(doc, []byte("[")) {
+			docs = nil
+			if err := json.Unmarshal(doc, &docs); err != nil {
+				return fmt.Errorf("invalid JSON body: %w", err)
+			}
+		}
+		for _, d := range docs {
+			line, err := jsonLine(d)
+			if err != nil {
+				return err
+			}
+			emit(line)
+		}
+	}
+}
+
+func jsonLine(doc json.RawMessage) (string, error) {
+	if bytes.HasPrefix(doc, []byte(`"`)) {
+		var s string
+		err := json.Unmarshal(doc, &s)
+		return s, err
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// requestLabels returns the labels describing a request, available to the relabeling.
+func requestLabels(r *http.Request) labels.Labels {
+	var lbs labels.Labels
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		lbs = append(lbs, labels.Label{Name: labelKeyHTTPRemoteIP, Value: host})
+	}
+	for name, values := range r.Header {
+		lbs = append(lbs, labels.Label{Name: labelKeyHTTPHeaderPrefix + strutil.SanitizeLabelName(strings.ToLower(name)), Value: values[0]})
+	}
+	for name, values := range r.URL.Query() {
+		lbs = append(lbs, labels.Label{Name: labelKeyHTTPQueryPrefix + strutil.SanitizeLabelName(name), Value: values[0]})
+	}
+	return lbs
+}
+
+// labels adds the configured labels to lbs and relabels them, it returns false when they are
+// dropped by the relabeling.
+func (t *PushTarget) labels(lbs labels.Labels) (model.LabelSet, bool) {
+	lb := labels.NewBuilder(labels.New(lbs...))
+	for k, v := range t.config.Labels {
+		lb.Set(string(k), string(v))
+	}
+	processed := relabel.Process(lb.Labels(), t.relabelConfig...)
+	if len(processed) == 0 {
+		return nil, false
+	}
+	filtered := model.LabelSet{}
+	for i := range processed {
+		if strings.HasPrefix(processed[i].Name, "__") {
+			continue
+		}
+		filtered[model.LabelName(processed[i].Name)] = model.LabelValue(processed[i].Value)
+	}
+	return filtered, true
 }
 
 // Type returns PushTargetType.
@@ -199,3 +199,91 @@ func TestPlaintextPushTarget(t *testing.T) {
 	_ = pt.Stop()
 
 }
+
+func TestPlaintextPushTarget_JSON(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	//Create PushTarget
+	eh := fake.New(func() {})
+	defer eh.Stop()
+
+	// Get a randomly available port by open and closing a TCP socket
+	addr, err := net.ResolveTCPAddr("tcp", localhost+":0")
+	require.NoError(t, err)
+	l, err := net.ListenTCP("tcp", addr)
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	err = l.Close()
+	require.NoError(t, err)
+
+	// Adjust some of the defaults
+	defaults := server.Config{}
+	defaults.RegisterFlags(flag.NewFlagSet("empty", flag.ContinueOnError))
+	defaults.HTTPListenAddress = localhost
+	defaults.HTTPListenPort = port
+	defaults.GRPCListenAddress = localhost
+	defaults.GRPCListenPort = 0 // Not testing GRPC, a random port will be assigned
+
+	config := &scrapeconfig.PushTargetConfig{
+		Server:    defaults,
+		Labels:    model.LabelSet{"pushserver": "pushserver3"},
+		RawFormat: RawFormatJSON,
+	}
+
+	rlbl := []*relabel.Config{
+		{
+			SourceLabels: model.LabelNames{labelKeyHTTPQueryPrefix + "drop"},
+			Regex:        relabel.MustNewRegexp("true"),
+			Action:       relabel.Drop,
+		},
+		{
+			SourceLabels: model.LabelNames{labelKeyHTTPQueryPrefix + "service"},
+			TargetLabel:  "service",
+			Action:       relabel.Replace,
+			Regex:        relabel.MustNewRegexp("(.+)"),
+			Replacement:  "$1",
+		},
+		{
+			SourceLabels: model.LabelNames{labelKeyHTTPHeaderPrefix + "x_source"},
+			TargetLabel:  "source",
+			Action:       relabel.Replace,
+			Regex:        relabel.MustNewRegexp("(.+)"),
+			Replacement:  "$1",
+		},
+	}
+
+	pt, err := NewPushTarget(logger, eh, rlbl, "job3", config)
+	require.NoError(t, err)
+	defer func() { _ = pt.Stop() }()
+
+	url := fmt.Sprintf("http://%s:%d/promtail/api/v1/raw", localhost, port)
+	post := func(url, body string) int {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Source", "alertmanager")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusNoContent, post(url+"?service=alerts", `[{"alert": "HighLoad", "status": "firing"}, "plain"]
+{"alert": "DiskFull"}`))
+	require.Equal(t, http.StatusNoContent, post(url+"?drop=true", `{"alert": "Dropped"}`))
+	require.Equal(t, http.StatusBadRequest, post(url, `{"alert": `))
+
+	received := eh.Received()
+	lines := make([]string, 0, len(received))
+	for _, e := range received {
+		lines = append(lines, e.Line)
+	}
+	require.Equal(t, []string{`{"alert":"HighLoad","status":"firing"}`, "plain", `{"alert":"DiskFull"}`}, lines)
+	require.Equal(t, model.LabelSet{"pushserver": "pushserver3", "service": "alerts", "source": "alertmanager"}, received[0].Labels)
+}
+
+func TestNewPushTarget_RawFormat(t *testing.T) {
+	_, err := NewPushTarget(log.NewNopLogger(), fake.New(func() {}), nil, "job", &scrapeconfig.PushTargetConfig{RawFormat: "xml"})
+	require.Error(t, err)
+}
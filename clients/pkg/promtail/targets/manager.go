@@ -10,14 +10,21 @@ import (
 
 	"github.com/grafana/loki/clients/pkg/promtail/api"
 	"github.com/grafana/loki/clients/pkg/promtail/client"
+	"github.com/grafana/loki/clients/pkg/promtail/limits"
 	"github.com/grafana/loki/clients/pkg/promtail/positions"
 	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/amqp"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/docker"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/file"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/gcplog"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/gelf"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/journal"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/kafka"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/kinesis"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/kubernetesevents"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/lokipush"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/mqtt"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/s3"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/stdin"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/syslog"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
@@ -25,14 +32,20 @@ import (
 )
 
 const (
-	FileScrapeConfigs    = "fileScrapeConfigs"
-	JournalScrapeConfigs = "journalScrapeConfigs"
-	SyslogScrapeConfigs  = "syslogScrapeConfigs"
-	GcplogScrapeConfigs  = "gcplogScrapeConfigs"
-	PushScrapeConfigs    = "pushScrapeConfigs"
-	WindowsEventsConfigs = "windowsEventsConfigs"
-	KafkaConfigs         = "kafkaConfigs"
-	GelfConfigs          = "gelfConfigs"
+	FileScrapeConfigs       = "fileScrapeConfigs"
+	JournalScrapeConfigs    = "journalScrapeConfigs"
+	SyslogScrapeConfigs     = "syslogScrapeConfigs"
+	GcplogScrapeConfigs     = "gcplogScrapeConfigs"
+	PushScrapeConfigs       = "pushScrapeConfigs"
+	WindowsEventsConfigs    = "windowsEventsConfigs"
+	KafkaConfigs            = "kafkaConfigs"
+	GelfConfigs             = "gelfConfigs"
+	KinesisConfigs          = "kinesisConfigs"
+	S3Configs               = "s3Configs"
+	MQTTConfigs             = "mqttConfigs"
+	AMQPConfigs             = "amqpConfigs"
+	DockerConfigs           = "dockerConfigs"
+	KubernetesEventsConfigs = "kubernetesEventsConfigs"
 )
 
 type targetManager interface {
@@ -46,6 +59,8 @@ type targetManager interface {
 type TargetManagers struct {
 	targetManagers []targetManager
 	positions      positions.Positions
+	// handlers gate the entries of the targets per priority, see limits.Limiter.
+	handlers []api.EntryHandler
 }
 
 // NewTargetManagers makes a new TargetManagers
@@ -55,6 +70,7 @@ func NewTargetManagers(
 	logger log.Logger,
 	positionsConfig positions.Config,
 	client api.EntryHandler,
+	limiter *limits.Limiter,
 	scrapeConfigs []scrapeconfig.Config,
 	targetConfig *file.Config,
 	clientConfigs ...client.Config,
@@ -64,7 +80,11 @@ func NewTargetManagers(
 
 	if targetConfig.Stdin {
 		level.Debug(logger).Log("msg", "configured to read from stdin")
-		stdin, err := stdin.NewStdinTargetManager(reg, logger, app, client, scrapeConfigs)
+		stdin, err := stdin.NewStdinTargetManager(reg, logger, app, client, scrapeConfigs, stdin.Options{
+			Format:    targetConfig.StdinFormat,
+			Labels:    targetConfig.StdinLabels.LabelSet,
+			KeepAlive: targetConfig.StdinKeepAlive,
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -90,6 +110,18 @@ func NewTargetManagers(
 			targetScrapeConfigs[KafkaConfigs] = append(targetScrapeConfigs[KafkaConfigs], cfg)
 		case cfg.GelfConfig != nil:
 			targetScrapeConfigs[GelfConfigs] = append(targetScrapeConfigs[GelfConfigs], cfg)
+		case cfg.KinesisConfig != nil:
+			targetScrapeConfigs[KinesisConfigs] = append(targetScrapeConfigs[KinesisConfigs], cfg)
+		case cfg.S3Config != nil:
+			targetScrapeConfigs[S3Configs] = append(targetScrapeConfigs[S3Configs], cfg)
+		case cfg.MQTTConfig != nil:
+			targetScrapeConfigs[MQTTConfigs] = append(targetScrapeConfigs[MQTTConfigs], cfg)
+		case cfg.AMQPConfig != nil:
+			targetScrapeConfigs[AMQPConfigs] = append(targetScrapeConfigs[AMQPConfigs], cfg)
+		case cfg.DockerConfig != nil:
+			targetScrapeConfigs[DockerConfigs] = append(targetScrapeConfigs[DockerConfigs], cfg)
+		case cfg.KubernetesEventsConfig != nil:
+			targetScrapeConfigs[KubernetesEventsConfigs] = append(targetScrapeConfigs[KubernetesEventsConfigs], cfg)
 		default:
 			return nil, fmt.Errorf("no valid target scrape config defined for %q", cfg.JobName)
 		}
@@ -110,10 +142,16 @@ func NewTargetManagers(
 	}
 
 	var (
-		fileMetrics   *file.Metrics
-		syslogMetrics *syslog.Metrics
-		gcplogMetrics *gcplog.Metrics
-		gelfMetrics   *gelf.Metrics
+		fileMetrics             *file.Metrics
+		syslogMetrics           *syslog.Metrics
+		gcplogMetrics           *gcplog.Metrics
+		gelfMetrics             *gelf.Metrics
+		kinesisMetrics          *kinesis.Metrics
+		s3Metrics               *s3.Metrics
+		mqttMetrics             *mqtt.Metrics
+		amqpMetrics             *amqp.Metrics
+		dockerMetrics           *docker.Metrics
+		kubernetesEventsMetrics *kubernetesevents.Metrics
 	)
 	if len(targetScrapeConfigs[FileScrapeConfigs]) > 0 {
 		fileMetrics = file.NewMetrics(reg)
@@ -127,105 +165,194 @@ func NewTargetManagers(
 	if len(targetScrapeConfigs[GelfConfigs]) > 0 {
 		gelfMetrics = gelf.NewMetrics(reg)
 	}
+	if len(targetScrapeConfigs[KinesisConfigs]) > 0 {
+		kinesisMetrics = kinesis.NewMetrics(reg)
+	}
+	if len(targetScrapeConfigs[S3Configs]) > 0 {
+		s3Metrics = s3.NewMetrics(reg)
+	}
+	if len(targetScrapeConfigs[MQTTConfigs]) > 0 {
+		mqttMetrics = mqtt.NewMetrics(reg)
+	}
+	if len(targetScrapeConfigs[AMQPConfigs]) > 0 {
+		amqpMetrics = amqp.NewMetrics(reg)
+	}
+	if len(targetScrapeConfigs[DockerConfigs]) > 0 {
+		dockerMetrics = docker.NewMetrics(reg)
+	}
+	if len(targetScrapeConfigs[KubernetesEventsConfigs]) > 0 {
+		kubernetesEventsMetrics = kubernetesevents.NewMetrics(reg)
+	}
 
-	for target, scrapeConfigs := range targetScrapeConfigs {
-		switch target {
-		case FileScrapeConfigs:
-			pos, err := getPositionFile()
-			if err != nil {
-				return nil, err
-			}
-			fileTargetManager, err := file.NewFileTargetManager(
-				fileMetrics,
-				logger,
-				pos,
-				client,
-				scrapeConfigs,
-				targetConfig,
-			)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to make file target manager")
-			}
-			targetManagers = append(targetManagers, fileTargetManager)
-		case JournalScrapeConfigs:
-			pos, err := getPositionFile()
-			if err != nil {
-				return nil, err
-			}
-			journalTargetManager, err := journal.NewJournalTargetManager(
-				reg,
-				logger,
-				pos,
-				client,
-				scrapeConfigs,
-			)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to make journal target manager")
-			}
-			targetManagers = append(targetManagers, journalTargetManager)
-		case SyslogScrapeConfigs:
-			syslogTargetManager, err := syslog.NewSyslogTargetManager(
-				syslogMetrics,
-				logger,
-				client,
-				scrapeConfigs,
-			)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to make syslog target manager")
+	var handlers []api.EntryHandler
+	for target, configs := range targetScrapeConfigs {
+		for priority, scrapeConfigs := range groupByPriority(configs) {
+			client := client
+			if limiter != nil {
+				handler := limiter.Handler(priority, client)
+				handlers = append(handlers, handler)
+				client = handler
 			}
-			targetManagers = append(targetManagers, syslogTargetManager)
-		case GcplogScrapeConfigs:
-			pubsubTargetManager, err := gcplog.NewGcplogTargetManager(
-				gcplogMetrics,
-				logger,
-				client,
-				scrapeConfigs,
-			)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to make syslog target manager")
-			}
-			targetManagers = append(targetManagers, pubsubTargetManager)
-		case PushScrapeConfigs:
-			pushTargetManager, err := lokipush.NewPushTargetManager(
-				reg,
-				logger,
-				client,
-				scrapeConfigs,
-			)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to make Loki Push API target manager")
-			}
-			targetManagers = append(targetManagers, pushTargetManager)
-		case WindowsEventsConfigs:
-			windowsTargetManager, err := windows.NewTargetManager(reg, logger, client, scrapeConfigs)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to make windows target manager")
-			}
-			targetManagers = append(targetManagers, windowsTargetManager)
-		case KafkaConfigs:
-			kafkaTargetManager, err := kafka.NewTargetManager(reg, logger, client, scrapeConfigs)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to make kafka target manager")
-			}
-			targetManagers = append(targetManagers, kafkaTargetManager)
-		case GelfConfigs:
-			gelfTargetManager, err := gelf.NewTargetManager(gelfMetrics, logger, client, scrapeConfigs)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to make gelf target manager")
-			}
-			targetManagers = append(targetManagers, gelfTargetManager)
+			switch target {
+			case FileScrapeConfigs:
+				pos, err := getPositionFile()
+				if err != nil {
+					return nil, err
+				}
+				fileTargetManager, err := file.NewFileTargetManager(
+					fileMetrics,
+					logger,
+					pos,
+					client,
+					scrapeConfigs,
+					targetConfig,
+				)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make file target manager")
+				}
+				targetManagers = append(targetManagers, fileTargetManager)
+			case JournalScrapeConfigs:
+				pos, err := getPositionFile()
+				if err != nil {
+					return nil, err
+				}
+				journalTargetManager, err := journal.NewJournalTargetManager(
+					reg,
+					logger,
+					pos,
+					client,
+					scrapeConfigs,
+				)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make journal target manager")
+				}
+				targetManagers = append(targetManagers, journalTargetManager)
+			case SyslogScrapeConfigs:
+				syslogTargetManager, err := syslog.NewSyslogTargetManager(
+					syslogMetrics,
+					logger,
+					client,
+					scrapeConfigs,
+				)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make syslog target manager")
+				}
+				targetManagers = append(targetManagers, syslogTargetManager)
+			case GcplogScrapeConfigs:
+				pubsubTargetManager, err := gcplog.NewGcplogTargetManager(
+					gcplogMetrics,
+					logger,
+					client,
+					scrapeConfigs,
+				)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make syslog target manager")
+				}
+				targetManagers = append(targetManagers, pubsubTargetManager)
+			case PushScrapeConfigs:
+				pushTargetManager, err := lokipush.NewPushTargetManager(
+					reg,
+					logger,
+					client,
+					scrapeConfigs,
+				)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make Loki Push API target manager")
+				}
+				targetManagers = append(targetManagers, pushTargetManager)
+			case WindowsEventsConfigs:
+				pos, err := getPositionFile()
+				if err != nil {
+					return nil, err
+				}
+				windowsTargetManager, err := windows.NewTargetManager(reg, logger, pos, client, scrapeConfigs)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make windows target manager")
+				}
+				targetManagers = append(targetManagers, windowsTargetManager)
+			case KafkaConfigs:
+				kafkaTargetManager, err := kafka.NewTargetManager(reg, logger, client, scrapeConfigs)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make kafka target manager")
+				}
+				targetManagers = append(targetManagers, kafkaTargetManager)
+			case GelfConfigs:
+				gelfTargetManager, err := gelf.NewTargetManager(gelfMetrics, logger, client, scrapeConfigs)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make gelf target manager")
+				}
+				targetManagers = append(targetManagers, gelfTargetManager)
+			case KinesisConfigs:
+				pos, err := getPositionFile()
+				if err != nil {
+					return nil, err
+				}
+				kinesisTargetManager, err := kinesis.NewTargetManager(kinesisMetrics, logger, pos, client, scrapeConfigs)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make kinesis target manager")
+				}
+				targetManagers = append(targetManagers, kinesisTargetManager)
+			case S3Configs:
+				s3TargetManager, err := s3.NewTargetManager(s3Metrics, logger, client, scrapeConfigs)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make s3 target manager")
+				}
+				targetManagers = append(targetManagers, s3TargetManager)
+			case MQTTConfigs:
+				mqttTargetManager, err := mqtt.NewTargetManager(mqttMetrics, logger, client, scrapeConfigs)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make mqtt target manager")
+				}
+				targetManagers = append(targetManagers, mqttTargetManager)
+			case AMQPConfigs:
+				amqpTargetManager, err := amqp.NewTargetManager(amqpMetrics, logger, client, scrapeConfigs)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make amqp target manager")
+				}
+				targetManagers = append(targetManagers, amqpTargetManager)
+			case DockerConfigs:
+				pos, err := getPositionFile()
+				if err != nil {
+					return nil, err
+				}
+				dockerTargetManager, err := docker.NewTargetManager(dockerMetrics, logger, pos, client, scrapeConfigs)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make docker target manager")
+				}
+				targetManagers = append(targetManagers, dockerTargetManager)
+			case KubernetesEventsConfigs:
+				pos, err := getPositionFile()
+				if err != nil {
+					return nil, err
+				}
+				kubernetesEventsTargetManager, err := kubernetesevents.NewTargetManager(kubernetesEventsMetrics, logger, pos, client, scrapeConfigs)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to make kubernetes events target manager")
+				}
+				targetManagers = append(targetManagers, kubernetesEventsTargetManager)
 
-		default:
-			return nil, errors.New("unknown scrape config")
+			default:
+				return nil, errors.New("unknown scrape config")
+			}
 		}
 	}
 
 	return &TargetManagers{
 		targetManagers: targetManagers,
 		positions:      positionFile,
+		handlers:       handlers,
 	}, nil
 }
 
+// groupByPriority groups the scrape configs by their priority.
+func groupByPriority(scrapeConfigs []scrapeconfig.Config) map[int][]scrapeconfig.Config {
+	groups := make(map[int][]scrapeconfig.Config, 1)
+	for _, cfg := range scrapeConfigs {
+		groups[cfg.Priority] = append(groups[cfg.Priority], cfg)
+	}
+	return groups
+}
+
 // ActiveTargets returns active targets per jobs
 func (tm *TargetManagers) ActiveTargets() map[string][]target.Target {
 	result := map[string][]target.Target{}
@@ -263,6 +390,9 @@ func (tm *TargetManagers) Stop() {
 	for _, t := range tm.targetManagers {
 		t.Stop()
 	}
+	for _, h := range tm.handlers {
+		h.Stop()
+	}
 	if tm.positions != nil {
 		tm.positions.Stop()
 	}
@@ -0,0 +1,52 @@
+package mqtt
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds the metrics of the MQTT targets.
+type Metrics struct {
+	// reg is the Registerer used to create this set of metrics.
+	reg prometheus.Registerer
+
+	messages      *prometheus.CounterVec
+	messageBytes  *prometheus.CounterVec
+	connected     *prometheus.GaugeVec
+	reconnections *prometheus.CounterVec
+	errors        *prometheus.CounterVec
+}
+
+// NewMetrics creates a new set of metrics. Metrics will be registered to reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	var m Metrics
+	m.reg = reg
+
+	m.messages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "mqtt_target_messages_total",
+		Help:      "Total number of messages received from MQTT brokers.",
+	}, []string{"broker"})
+	m.messageBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "mqtt_target_message_bytes_total",
+		Help:      "Total number of bytes of the payloads of the messages received from MQTT brokers.",
+	}, []string{"broker"})
+	m.connected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "mqtt_target_connected",
+		Help:      "Whether the target is connected to the MQTT broker and subscribed to its topics.",
+	}, []string{"broker"})
+	m.reconnections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "mqtt_target_reconnections_total",
+		Help:      "Total number of connections to MQTT brokers after a connection was lost.",
+	}, []string{"broker"})
+	m.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "mqtt_target_errors_total",
+		Help:      "Total number of errors connecting to MQTT brokers or receiving their messages.",
+	}, []string{"broker"})
+
+	if reg != nil {
+		reg.MustRegister(m.messages, m.messageBytes, m.connected, m.reconnections, m.errors)
+	}
+	return &m
+}
@@ -0,0 +1,502 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The target speaks the subset of MQTT 3.1.1 and 5 a subscriber needs instead of using
+// a client library: QoS 1 messages are acknowledged in order only once their entries
+// are pushed, the unacknowledged messages are bounded by max_inflight_messages, and no
+// client is added to the vendored dependencies for the few packets used.
+
+// Control packet types.
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetSubscribe  = 8
+	packetSuback     = 9
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+)
+
+// Protocol levels sent in the CONNECT packet.
+const (
+	protocolLevel311 = 4
+	protocolLevel5   = 5
+)
+
+// Properties of MQTT 5 packets used by the target.
+const (
+	propertySessionExpiryInterval = 0x11
+	propertyAssignedClientID      = 0x12
+	propertyServerKeepAlive       = 0x13
+	propertyReasonString          = 0x1F
+	propertyReceiveMaximum        = 0x21
+	propertyUserProperty          = 0x26
+)
+
+// propertyTypes are the types of the values of MQTT 5 properties, needed to skip the
+// properties the target doesn't use.
+var propertyTypes = map[byte]byte{
+	0x01: typeByte, 0x02: typeUint32, 0x03: typeString, 0x08: typeString,
+	0x09: typeBinary, 0x0B: typeVarint, 0x11: typeUint32, 0x12: typeString,
+	0x13: typeUint16, 0x15: typeString, 0x16: typeBinary, 0x17: typeByte,
+	0x18: typeUint32, 0x19: typeByte, 0x1A: typeString, 0x1C: typeString,
+	0x1F: typeString, 0x21: typeUint16, 0x22: typeUint16, 0x23: typeUint16,
+	0x24: typeByte, 0x25: typeByte, 0x26: typeStringPair, 0x27: typeUint32,
+	0x28: typeByte, 0x29: typeByte, 0x2A: typeByte,
+}
+
+const (
+	typeByte = iota
+	typeUint16
+	typeUint32
+	typeVarint
+	typeString
+	typeBinary
+	typeStringPair
+)
+
+// maxPacketSize bounds the size of the packets read from the broker.
+const maxPacketSize = 256 << 20
+
+var errMalformedPacket = errors.New("malformed packet")
+
+// packet is a control packet read from the broker.
+type packet struct {
+	typ   byte
+	flags byte
+	body  []byte
+}
+
+// properties holds the MQTT 5 properties of a packet.
+type properties struct {
+	sessionPresent   bool
+	assignedClientID string
+	serverKeepAlive  int
+	reasonString     string
+	user             [][2]string
+}
+
+// message is an application message received in a PUBLISH packet.
+type message struct {
+	topic    string
+	id       uint16
+	qos      byte
+	retained bool
+	payload  []byte
+	user     [][2]string
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	length, err := readVarint(r)
+	if err != nil {
+		return packet{}, err
+	}
+	if length > maxPacketSize {
+		return packet{}, fmt.Errorf("packet of %d bytes exceeds the maximum size", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{typ: header >> 4, flags: header & 0x0F, body: body}, nil
+}
+
+func readVarint(r io.ByteReader) (int, error) {
+	var value, shift int
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value |= int(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return value, nil
+		}
+		shift += 7
+	}
+	return 0, errMalformedPacket
+}
+
+// encoder builds the body of a packet.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) byte(b byte) { e.buf = append(e.buf, b) }
+
+func (e *encoder) uint16(v uint16) { e.buf = append(e.buf, byte(v>>8), byte(v)) }
+
+func (e *encoder) uint32(v uint32) {
+	e.buf = append(e.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) string(s string) {
+	e.uint16(uint16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) varint(v int) {
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+		if v > 0 {
+			b |= 0x80
+		}
+		e.buf = append(e.buf, b)
+		if v == 0 {
+			return
+		}
+	}
+}
+
+// packet returns the packet with its fixed header.
+func (e *encoder) packet(typ, flags byte) []byte {
+	var out encoder
+	out.byte(typ<<4 | flags)
+	out.varint(len(e.buf))
+	return append(out.buf, e.buf...)
+}
+
+// decoder reads the body of a packet.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.buf) < n {
+		d.err = errMalformedPacket
+		return nil
+	}
+	out := d.buf[:n]
+	d.buf = d.buf[n:]
+	return out
+}
+
+func (d *decoder) byte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) varint() int {
+	if d.err != nil {
+		return 0
+	}
+	v, err := readVarint(d)
+	if err != nil {
+		d.err = errMalformedPacket
+	}
+	return v
+}
+
+// ReadByte implements io.ByteReader for readVarint.
+func (d *decoder) ReadByte() (byte, error) {
+	b := d.next(1)
+	if b == nil {
+		return 0, errMalformedPacket
+	}
+	return b[0], nil
+}
+
+func (d *decoder) binary() []byte {
+	return d.next(int(d.uint16()))
+}
+
+func (d *decoder) string() string {
+	return string(d.binary())
+}
+
+// properties reads the properties of an MQTT 5 packet.
+func (d *decoder) properties() properties {
+	var props properties
+	length := d.varint()
+	sub := decoder{buf: d.next(length), err: d.err}
+	for sub.err == nil && len(sub.buf) > 0 {
+		id := sub.byte()
+		typ, ok := propertyTypes[id]
+		if !ok {
+			sub.err = fmt.Errorf("unknown property 0x%02x: %w", id, errMalformedPacket)
+			break
+		}
+		switch id {
+		case propertyAssignedClientID:
+			props.assignedClientID = sub.string()
+		case propertyServerKeepAlive:
+			props.serverKeepAlive = int(sub.uint16())
+		case propertyReasonString:
+			props.reasonString = sub.string()
+		case propertyUserProperty:
+			props.user = append(props.user, [2]string{sub.string(), sub.string()})
+		default:
+			sub.skip(typ)
+		}
+	}
+	if d.err == nil {
+		d.err = sub.err
+	}
+	return props
+}
+
+func (d *decoder) skip(typ byte) {
+	switch typ {
+	case typeByte:
+		d.next(1)
+	case typeUint16:
+		d.next(2)
+	case typeUint32:
+		d.next(4)
+	case typeVarint:
+		d.varint()
+	case typeString, typeBinary:
+		d.binary()
+	case typeStringPair:
+		d.binary()
+		d.binary()
+	}
+}
+
+// connectOptions are the fields of a CONNECT packet.
+type connectOptions struct {
+	level          byte
+	clientID       string
+	username       string
+	password       string
+	cleanSession   bool
+	keepAlive      uint16
+	sessionExpiry  uint32
+	receiveMaximum uint16
+}
+
+func encodeConnect(o connectOptions) []byte {
+	var e encoder
+	e.string("MQTT")
+	e.byte(o.level)
+	var flags byte
+	if o.username != "" {
+		flags |= 0x80
+	}
+	if o.password != "" {
+		flags |= 0x40
+	}
+	if o.cleanSession {
+		flags |= 0x02
+	}
+	e.byte(flags)
+	e.uint16(o.keepAlive)
+	if o.level == protocolLevel5 {
+		var props encoder
+		if o.sessionExpiry > 0 {
+			props.byte(propertySessionExpiryInterval)
+			props.uint32(o.sessionExpiry)
+		}
+		if o.receiveMaximum > 0 {
+			props.byte(propertyReceiveMaximum)
+			props.uint16(o.receiveMaximum)
+		}
+		e.varint(len(props.buf))
+		e.buf = append(e.buf, props.buf...)
+	}
+	e.string(o.clientID)
+	if o.username != "" {
+		e.string(o.username)
+	}
+	if o.password != "" {
+		e.string(o.password)
+	}
+	return e.packet(packetConnect, 0)
+}
+
+// decodeConnack returns the properties of a CONNACK packet, or an error if the
+// connection was refused.
+func decodeConnack(p packet, level byte) (properties, error) {
+	if p.typ != packetConnack {
+		return properties{}, fmt.Errorf("expected CONNACK, got packet type %d", p.typ)
+	}
+	d := decoder{buf: p.body}
+	flags := d.byte()
+	code := d.byte()
+	var props properties
+	if level == protocolLevel5 {
+		props = d.properties()
+	}
+	if d.err != nil {
+		return properties{}, d.err
+	}
+	props.sessionPresent = flags&0x01 != 0
+	if code != 0 {
+		return properties{}, fmt.Errorf("connection refused: %s", reasonText(code, level, props.reasonString))
+	}
+	return props, nil
+}
+
+func encodeSubscribe(id uint16, level byte, topics []string, qos byte) []byte {
+	var e encoder
+	e.uint16(id)
+	if level == protocolLevel5 {
+		e.varint(0)
+	}
+	for _, topic := range topics {
+		e.string(topic)
+		e.byte(qos)
+	}
+	return e.packet(packetSubscribe, 0x02)
+}
+
+// decodeSuback returns an error if one of the subscriptions was refused.
+func decodeSuback(p packet, level byte, topics []string) error {
+	d := decoder{buf: p.body}
+	d.uint16()
+	var props properties
+	if level == protocolLevel5 {
+		props = d.properties()
+	}
+	codes := d.next(len(topics))
+	if d.err != nil {
+		return d.err
+	}
+	for i, code := range codes {
+		if code >= 0x80 {
+			return fmt.Errorf("subscription to %s refused: %s", topics[i], reasonText(code, level, props.reasonString))
+		}
+	}
+	return nil
+}
+
+func decodePublish(p packet, level byte) (message, error) {
+	d := decoder{buf: p.body}
+	m := message{
+		qos:      (p.flags >> 1) & 0x03,
+		retained: p.flags&0x01 != 0,
+	}
+	m.topic = d.string()
+	if m.qos > 0 {
+		m.id = d.uint16()
+	}
+	if level == protocolLevel5 {
+		m.user = d.properties().user
+	}
+	if d.err != nil {
+		return message{}, d.err
+	}
+	m.payload = d.buf
+	return m, nil
+}
+
+func encodePuback(id uint16) []byte {
+	var e encoder
+	e.uint16(id)
+	return e.packet(packetPuback, 0)
+}
+
+func encodePingreq() []byte {
+	return []byte{packetPingreq << 4, 0}
+}
+
+func encodeDisconnect() []byte {
+	return []byte{packetDisconnect << 4, 0}
+}
+
+// decodeDisconnect returns the reason of a DISCONNECT packet sent by an MQTT 5 broker.
+func decodeDisconnect(p packet, level byte) error {
+	if level != protocolLevel5 || len(p.body) == 0 {
+		return errors.New("disconnected by the broker")
+	}
+	d := decoder{buf: p.body}
+	code := d.byte()
+	var props properties
+	if len(d.buf) > 0 {
+		props = d.properties()
+	}
+	return fmt.Errorf("disconnected by the broker: %s", reasonText(code, level, props.reasonString))
+}
+
+// reasonText describes a return code of MQTT 3.1.1 or a reason code of MQTT 5.
+func reasonText(code, level byte, reason string) string {
+	var text string
+	if level == protocolLevel311 {
+		switch code {
+		case 0x01:
+			text = "unacceptable protocol version"
+		case 0x02:
+			text = "identifier rejected"
+		case 0x03:
+			text = "server unavailable"
+		case 0x04:
+			text = "bad user name or password"
+		case 0x05:
+			text = "not authorized"
+		case 0x80:
+			text = "failure"
+		}
+	} else {
+		switch code {
+		case 0x80:
+			text = "unspecified error"
+		case 0x84:
+			text = "unsupported protocol version"
+		case 0x85:
+			text = "client identifier not valid"
+		case 0x86:
+			text = "bad user name or password"
+		case 0x87:
+			text = "not authorized"
+		case 0x88:
+			text = "server unavailable"
+		case 0x89:
+			text = "server busy"
+		case 0x8B:
+			text = "server shutting down"
+		case 0x8E:
+			text = "session taken over"
+		case 0x8F:
+			text = "topic filter invalid"
+		case 0x97:
+			text = "quota exceeded"
+		case 0x9E:
+			text = "shared subscriptions not supported"
+		case 0xA1:
+			text = "subscription identifiers not supported"
+		case 0xA2:
+			text = "wildcard subscriptions not supported"
+		}
+	}
+	if text == "" {
+		text = "unknown error"
+	}
+	if reason != "" {
+		text += ": " + reason
+	}
+	return fmt.Sprintf("%s (0x%02x)", text, code)
+}
@@ -0,0 +1,87 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Varint(t *testing.T) {
+	for _, v := range []int{0, 127, 128, 16383, 16384, 2097151, 2097152, 268435455} {
+		var e encoder
+		e.varint(v)
+		d := decoder{buf: e.buf}
+		require.Equal(t, v, d.varint())
+		require.NoError(t, d.err)
+		require.Empty(t, d.buf)
+	}
+}
+
+func Test_ReadPacket(t *testing.T) {
+	payload := bytes.Repeat([]byte("a"), 300)
+	var e encoder
+	e.string("logs")
+	e.buf = append(e.buf, payload...)
+	raw := e.packet(packetPublish, 0x01)
+
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(raw)))
+	require.NoError(t, err)
+	require.Equal(t, byte(packetPublish), p.typ)
+
+	m, err := decodePublish(p, protocolLevel311)
+	require.NoError(t, err)
+	require.Equal(t, "logs", m.topic)
+	require.True(t, m.retained)
+	require.Equal(t, byte(0), m.qos)
+	require.Equal(t, payload, m.payload)
+
+	_, err = readPacket(bufio.NewReader(bytes.NewReader(raw[:10])))
+	require.Error(t, err)
+}
+
+func Test_DecodePublishMQTT5(t *testing.T) {
+	var e encoder
+	e.string("devices/1")
+	e.uint16(42)
+	var props encoder
+	props.byte(propertyReasonString)
+	props.string("ignored")
+	props.byte(propertyUserProperty)
+	props.string("region")
+	props.string("eu")
+	e.varint(len(props.buf))
+	e.buf = append(e.buf, props.buf...)
+	e.buf = append(e.buf, "payload"...)
+
+	m, err := decodePublish(packet{typ: packetPublish, flags: 1 << 1, body: e.buf}, protocolLevel5)
+	require.NoError(t, err)
+	require.Equal(t, uint16(42), m.id)
+	require.Equal(t, byte(1), m.qos)
+	require.Equal(t, [][2]string{{"region", "eu"}}, m.user)
+	require.Equal(t, "payload", string(m.payload))
+
+	_, err = decodePublish(packet{typ: packetPublish, body: []byte{0, 10, 'a'}}, protocolLevel311)
+	require.Error(t, err)
+}
+
+func Test_DecodeConnack(t *testing.T) {
+	_, err := decodeConnack(packet{typ: packetConnack, body: []byte{0, 0}}, protocolLevel311)
+	require.NoError(t, err)
+
+	_, err = decodeConnack(packet{typ: packetConnack, body: []byte{0, 5}}, protocolLevel311)
+	require.EqualError(t, err, "connection refused: not authorized (0x05)")
+
+	props, err := decodeConnack(packet{typ: packetConnack, body: []byte{1, 0, 3, propertyServerKeepAlive, 0, 10}}, protocolLevel5)
+	require.NoError(t, err)
+	require.True(t, props.sessionPresent)
+	require.Equal(t, 10, props.serverKeepAlive)
+}
+
+func Test_DecodeSuback(t *testing.T) {
+	topics := []string{"a", "b"}
+	require.NoError(t, decodeSuback(packet{typ: packetSuback, body: []byte{0, 1, 1, 0}}, protocolLevel311, topics))
+	require.Error(t, decodeSuback(packet{typ: packetSuback, body: []byte{0, 1, 1, 0x80}}, protocolLevel311, topics))
+	require.Error(t, decodeSuback(packet{typ: packetSuback, body: []byte{0, 1, 1}}, protocolLevel311, topics))
+}
@@ -0,0 +1,580 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
+	promconfig "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/prometheus/prometheus/util/strutil"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+	"github.com/grafana/loki/clients/pkg/promtail/tlspolicy"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/util"
+)
+
+const (
+	labelKeyMQTTTopic              = "__meta_mqtt_topic"
+	labelKeyMQTTTopicLevelPrefix   = "__meta_mqtt_topic_level_"
+	labelKeyMQTTQoS                = "__meta_mqtt_qos"
+	labelKeyMQTTRetained           = "__meta_mqtt_retained"
+	labelKeyMQTTUserPropertyPrefix = "__meta_mqtt_user_property_"
+
+	defaultQoS                 = 1
+	defaultKeepAlive           = 30 * time.Second
+	defaultConnectTimeout      = 10 * time.Second
+	defaultSessionExpiry       = time.Hour
+	defaultMaxInflightMessages = 100
+)
+
+var reconnectBackoff = backoff.Config{
+	MinBackoff: 1 * time.Second,
+	MaxBackoff: 60 * time.Second,
+}
+
+// Target subscribes to the topics of an MQTT broker and pushes the payload of each
+// message as a log line. QoS 1 messages are acknowledged to the broker, in the order
+// they were received, once their entry has been pushed to Loki.
+type Target struct {
+	logger        log.Logger
+	metrics       *Metrics
+	handler       api.EntryHandler
+	config        *scrapeconfig.MQTTTargetConfig
+	relabelConfig []*relabel.Config
+
+	address   string
+	tlsConfig *tls.Config
+	level     byte
+	qos       byte
+	clientID  string
+
+	mtx       sync.Mutex
+	connected bool
+	messages  int
+	lastError error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTarget creates a Target subscribing to the topics of a scrape config.
+func NewTarget(
+	metrics *Metrics,
+	logger log.Logger,
+	handler api.EntryHandler,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.MQTTTargetConfig,
+) (*Target, error) {
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+	address, useTLS, err := parseBroker(config.Broker)
+	if err != nil {
+		return nil, err
+	}
+	var tlsConfig *tls.Config
+	if useTLS {
+		tlsConfig, err = promconfig.NewTLSConfig(&config.TLSConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error creating TLS config: %w", err)
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(address)
+		}
+		tlspolicy.Apply(tlsConfig)
+	}
+	lvl := byte(protocolLevel311)
+	if config.ProtocolVersion == "5" {
+		lvl = protocolLevel5
+	}
+	clientID := config.ClientID
+	if clientID == "" {
+		clientID, err = randomClientID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Target{
+		logger:        log.With(logger, "broker", config.Broker),
+		metrics:       metrics,
+		handler:       handler,
+		config:        config,
+		relabelConfig: relabelConfig,
+		address:       address,
+		tlsConfig:     tlsConfig,
+		level:         lvl,
+		qos:           byte(*config.QoS),
+		clientID:      clientID,
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	go t.run()
+	return t, nil
+}
+
+func validateConfig(cfg *scrapeconfig.MQTTTargetConfig) error {
+	if cfg.Broker == "" {
+		return errors.New("no MQTT broker given")
+	}
+	if len(cfg.Topics) == 0 {
+		return errors.New("no MQTT topics given to subscribe to")
+	}
+	switch cfg.ProtocolVersion {
+	case "":
+		cfg.ProtocolVersion = "3.1.1"
+	case "3.1.1", "5":
+	default:
+		return fmt.Errorf("unsupported MQTT protocol version %s, must be 3.1.1 or 5", cfg.ProtocolVersion)
+	}
+	if cfg.QoS == nil {
+		qos := defaultQoS
+		cfg.QoS = &qos
+	}
+	if *cfg.QoS != 0 && *cfg.QoS != 1 {
+		return fmt.Errorf("unsupported QoS %d, must be 0 or 1", *cfg.QoS)
+	}
+	if cfg.CleanSession == nil {
+		clean := true
+		cfg.CleanSession = &clean
+	}
+	if !*cfg.CleanSession && cfg.ClientID == "" {
+		return errors.New("a client_id is required to keep the session when clean_session is false")
+	}
+	if cfg.SessionExpiry == 0 {
+		cfg.SessionExpiry = defaultSessionExpiry
+	}
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = defaultKeepAlive
+	}
+	if cfg.KeepAlive < time.Second || cfg.KeepAlive > 65535*time.Second {
+		return errors.New("keep alive must be between 1s and 65535s")
+	}
+	if cfg.ConnectTimeout == 0 {
+		cfg.ConnectTimeout = defaultConnectTimeout
+	}
+	if cfg.MaxInflightMessages == 0 {
+		cfg.MaxInflightMessages = defaultMaxInflightMessages
+	}
+	if cfg.MaxInflightMessages < 0 || cfg.MaxInflightMessages > 65535 {
+		return errors.New("max inflight messages must be between 1 and 65535")
+	}
+	return nil
+}
+
+// parseBroker returns the address of a broker and whether it is reached with TLS.
+func parseBroker(broker string) (string, bool, error) {
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+	u, err := url.Parse(broker)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid MQTT broker %s: %w", broker, err)
+	}
+	var useTLS bool
+	port := "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS = true
+		port = "8883"
+	default:
+		return "", false, fmt.Errorf("unsupported scheme %s for MQTT broker, must be tcp or ssl", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+func randomClientID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating MQTT client identifier: %w", err)
+	}
+	return "promtail-" + hex.EncodeToString(b), nil
+}
+
+func (t *Target) run() {
+	defer close(t.done)
+	b := backoff.New(t.ctx, reconnectBackoff)
+	var reconnect bool
+	for t.ctx.Err() == nil {
+		err := t.session(b, reconnect)
+		if t.ctx.Err() != nil {
+			return
+		}
+		reconnect = true
+		level.Warn(t.logger).Log("msg", "MQTT connection failed, reconnecting", "err", err)
+		t.setError(err)
+		b.Wait()
+	}
+}
+
+// session connects to the broker and consumes the messages of the topics until the
+// connection fails or the target is stopped.
+func (t *Target) session(b *backoff.Backoff, reconnect bool) error {
+	c, err := t.connect()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	b.Reset()
+	if reconnect {
+		t.metrics.reconnections.WithLabelValues(t.config.Broker).Inc()
+	}
+	level.Info(t.logger).Log("msg", "subscribed to MQTT topics", "topics", strings.Join(t.config.Topics, ","), "session_present", c.sessionPresent)
+	t.setConnected(true)
+	defer t.setConnected(false)
+	return t.consume(c)
+}
+
+// conn is a connection to a broker.
+type conn struct {
+	net.Conn
+	reader         *bufio.Reader
+	keepAlive      time.Duration
+	sessionPresent bool
+	// pending holds the messages received before the subscriptions were acknowledged.
+	pending []packet
+}
+
+func (c *conn) write(p []byte) error {
+	if err := c.SetWriteDeadline(time.Now().Add(c.keepAlive)); err != nil {
+		return err
+	}
+	_, err := c.Write(p)
+	return err
+}
+
+// close disconnects gracefully from the broker.
+func (c *conn) close() {
+	_ = c.write(encodeDisconnect())
+	_ = c.Close()
+}
+
+// connect opens a connection and subscribes to the topics.
+func (t *Target) connect() (*conn, error) {
+	deadline := time.Now().Add(t.config.ConnectTimeout)
+	dialer := &net.Dialer{Deadline: deadline}
+	var (
+		nc  net.Conn
+		err error
+	)
+	if t.tlsConfig != nil {
+		nc, err = tls.DialWithDialer(dialer, "tcp", t.address, t.tlsConfig)
+	} else {
+		nc, err = dialer.DialContext(t.ctx, "tcp", t.address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{Conn: nc, reader: bufio.NewReader(nc), keepAlive: t.config.KeepAlive}
+	if err := t.handshake(c, deadline); err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (t *Target) handshake(c *conn, deadline time.Time) error {
+	if err := c.SetDeadline(deadline); err != nil {
+		return err
+	}
+	opts := connectOptions{
+		level:        t.level,
+		clientID:     t.clientID,
+		username:     t.config.Username,
+		password:     t.config.Password.Value,
+		cleanSession: *t.config.CleanSession,
+		keepAlive:    uint16(t.config.KeepAlive / time.Second),
+	}
+	if t.level == protocolLevel5 {
+		opts.receiveMaximum = uint16(t.config.MaxInflightMessages)
+		if !opts.cleanSession {
+			opts.sessionExpiry = uint32(t.config.SessionExpiry / time.Second)
+		}
+	}
+	if err := c.write(encodeConnect(opts)); err != nil {
+		return err
+	}
+	p, err := readPacket(c.reader)
+	if err != nil {
+		return fmt.Errorf("error reading CONNACK: %w", err)
+	}
+	props, err := decodeConnack(p, t.level)
+	if err != nil {
+		return err
+	}
+	c.sessionPresent = props.sessionPresent
+	if props.serverKeepAlive > 0 {
+		c.keepAlive = time.Duration(props.serverKeepAlive) * time.Second
+	}
+
+	const subscribeID = 1
+	if err := c.write(encodeSubscribe(subscribeID, t.level, t.config.Topics, t.qos)); err != nil {
+		return err
+	}
+	for {
+		p, err := readPacket(c.reader)
+		if err != nil {
+			return fmt.Errorf("error reading SUBACK: %w", err)
+		}
+		switch p.typ {
+		case packetSuback:
+			if err := decodeSuback(p, t.level, t.config.Topics); err != nil {
+				return err
+			}
+			return c.SetDeadline(time.Time{})
+		case packetPublish:
+			// Messages of a kept session may be sent before the subscriptions are acknowledged,
+			// they are consumed once connected like the other messages.
+			c.pending = append(c.pending, p)
+		default:
+			return fmt.Errorf("expected SUBACK, got packet type %d", p.typ)
+		}
+	}
+}
+
+// inflightMessage is a QoS 1 message waiting for its entry to be pushed.
+type inflightMessage struct {
+	id    uint16
+	topic string
+	entry api.Entry
+	acked bool
+}
+
+type inflightAck struct {
+	message *inflightMessage
+	err     error
+}
+
+// consume sends the messages of the connection to the handler. At most
+// MaxInflightMessages QoS 1 messages wait for their acknowledgement, reading from the
+// connection pauses while the window is full.
+func (t *Target) consume(c *conn) error {
+	var (
+		packets  = make(chan packet)
+		readErr  = make(chan error, 1)
+		stopped  = make(chan struct{})
+		inflight []*inflightMessage
+		// acks is buffered to never block the acknowledgements once the session ended.
+		acks = make(chan inflightAck, t.config.MaxInflightMessages)
+	)
+	go func() {
+		for _, p := range c.pending {
+			select {
+			case packets <- p:
+			case <-stopped:
+				return
+			}
+		}
+		for {
+			p, err := readPacket(c.reader)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case packets <- p:
+			case <-stopped:
+				return
+			}
+		}
+	}()
+	defer close(stopped)
+
+	// Brokers not answering are detected by the TCP keep alive of the connection, the
+	// pings only keep the connection alive on the side of the broker.
+	ping := time.NewTicker(c.keepAlive)
+	defer ping.Stop()
+
+	for {
+		in := packets
+		if len(inflight) >= t.config.MaxInflightMessages {
+			in = nil
+		}
+		select {
+		case p := <-in:
+			switch p.typ {
+			case packetPublish:
+				m, err := decodePublish(p, t.level)
+				if err != nil {
+					return fmt.Errorf("error decoding PUBLISH: %w", err)
+				}
+				entry := t.entry(m)
+				if m.qos == 0 {
+					t.send(entry)
+					continue
+				}
+				im := &inflightMessage{id: m.id, topic: m.topic}
+				entry.Ack = func(err error) { acks <- inflightAck{message: im, err: err} }
+				im.entry = entry
+				inflight = append(inflight, im)
+				t.send(entry)
+			case packetDisconnect:
+				return decodeDisconnect(p, t.level)
+			}
+		case a := <-acks:
+			if a.err != nil {
+				level.Warn(t.logger).Log("msg", "sending message again, it could not be pushed", "topic", a.message.topic, "err", a.err)
+				t.send(a.message.entry)
+				continue
+			}
+			a.message.acked = true
+			for len(inflight) > 0 && inflight[0].acked {
+				if err := c.write(encodePuback(inflight[0].id)); err != nil {
+					return err
+				}
+				inflight = inflight[1:]
+			}
+		case <-ping.C:
+			if err := c.write(encodePingreq()); err != nil {
+				return err
+			}
+		case err := <-readErr:
+			return err
+		case <-t.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// entry returns the entry of a message.
+func (t *Target) entry(m message) api.Entry {
+	t.metrics.messages.WithLabelValues(t.config.Broker).Inc()
+	t.metrics.messageBytes.WithLabelValues(t.config.Broker).Add(float64(len(m.payload)))
+	t.mtx.Lock()
+	t.messages++
+	t.lastError = nil
+	t.mtx.Unlock()
+
+	return api.Entry{
+		Labels: t.labels(m),
+		Entry:  logproto.Entry{Timestamp: time.Now(), Line: string(m.payload)},
+	}
+}
+
+func (t *Target) send(e api.Entry) {
+	select {
+	case t.handler.Chan() <- e:
+	case <-t.ctx.Done():
+	}
+}
+
+func (t *Target) labels(m message) model.LabelSet {
+	lbs := labels.Labels{
+		{Name: labelKeyMQTTTopic, Value: m.topic},
+		{Name: labelKeyMQTTQoS, Value: strconv.Itoa(int(m.qos))},
+		{Name: labelKeyMQTTRetained, Value: strconv.FormatBool(m.retained)},
+	}
+	for i, topicLevel := range strings.Split(m.topic, "/") {
+		lbs = append(lbs, labels.Label{Name: labelKeyMQTTTopicLevelPrefix + strconv.Itoa(i), Value: topicLevel})
+	}
+	for _, p := range m.user {
+		lbs = append(lbs, labels.Label{Name: labelKeyMQTTUserPropertyPrefix + strutil.SanitizeLabelName(p[0]), Value: p[1]})
+	}
+	out := t.config.Labels.Clone()
+	if processed := format(lbs, t.relabelConfig); len(processed) > 0 {
+		out = out.Merge(processed)
+	}
+	return out
+}
+
+func format(lbs labels.Labels, cfg []*relabel.Config) model.LabelSet {
+	processed := relabel.Process(labels.New(lbs...), cfg...)
+	labelOut := model.LabelSet(util.LabelsToMetric(processed))
+	for k := range labelOut {
+		if strings.HasPrefix(string(k), "__") {
+			delete(labelOut, k)
+		}
+	}
+	return labelOut
+}
+
+func (t *Target) setConnected(connected bool) {
+	v := 0.
+	if connected {
+		v = 1
+	}
+	t.metrics.connected.WithLabelValues(t.config.Broker).Set(v)
+	t.mtx.Lock()
+	t.connected = connected
+	t.mtx.Unlock()
+}
+
+func (t *Target) setError(err error) {
+	t.metrics.errors.WithLabelValues(t.config.Broker).Inc()
+	t.mtx.Lock()
+	t.lastError = err
+	t.mtx.Unlock()
+}
+
+// Stop disconnects from the broker.
+func (t *Target) Stop() {
+	t.cancel()
+	<-t.done
+	t.handler.Stop()
+}
+
+// Type implements target.Target.
+func (t *Target) Type() target.TargetType {
+	return target.MQTTTargetType
+}
+
+// Ready implements target.Target.
+func (t *Target) Ready() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.connected
+}
+
+// DiscoveredLabels implements target.Target.
+func (t *Target) DiscoveredLabels() model.LabelSet {
+	return nil
+}
+
+// Labels implements target.Target.
+func (t *Target) Labels() model.LabelSet {
+	return t.config.Labels
+}
+
+// Details implements target.Target.
+func (t *Target) Details() interface{} {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	details := map[string]string{
+		"broker":    t.config.Broker,
+		"topics":    strings.Join(t.config.Topics, ","),
+		"client_id": t.clientID,
+		"connected": strconv.FormatBool(t.connected),
+		"messages":  fmt.Sprint(t.messages),
+	}
+	if t.lastError != nil {
+		details["error"] = t.lastError.Error()
+	}
+	return details
+}
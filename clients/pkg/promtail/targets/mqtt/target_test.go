@@ -0,0 +1,316 @@
+package mqtt
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/clients/pkg/promtail/client/fake"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+// fakeBroker accepts a single connection and records the packets it receives.
+type fakeBroker struct {
+	t        *testing.T
+	listener net.Listener
+	level    byte
+	conn     net.Conn
+	reader   *bufio.Reader
+	// beforeSuback is called before the subscriptions are acknowledged.
+	beforeSuback func()
+}
+
+func newFakeBroker(t *testing.T, level byte) *fakeBroker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	return &fakeBroker{t: t, listener: l, level: level}
+}
+
+// accept accepts the connection of the target and acknowledges its subscriptions.
+func (b *fakeBroker) accept() (connect packet, subscribe packet) {
+	conn, err := b.listener.Accept()
+	require.NoError(b.t, err)
+	b.t.Cleanup(func() { conn.Close() })
+	b.conn, b.reader = conn, bufio.NewReader(conn)
+
+	connect = b.read()
+	require.Equal(b.t, byte(packetConnect), connect.typ)
+	var connack encoder
+	connack.byte(0)
+	connack.byte(0)
+	if b.level == protocolLevel5 {
+		connack.varint(0)
+	}
+	b.write(connack.packet(packetConnack, 0))
+
+	subscribe = b.read()
+	require.Equal(b.t, byte(packetSubscribe), subscribe.typ)
+	if b.beforeSuback != nil {
+		b.beforeSuback()
+	}
+	var suback encoder
+	suback.buf = append(suback.buf, subscribe.body[:2]...)
+	if b.level == protocolLevel5 {
+		suback.varint(0)
+	}
+	suback.byte(1)
+	b.write(suback.packet(packetSuback, 0))
+	return connect, subscribe
+}
+
+func (b *fakeBroker) read() packet {
+	require.NoError(b.t, b.conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	p, err := readPacket(b.reader)
+	require.NoError(b.t, err)
+	return p
+}
+
+// readPuback returns the identifier of the next PUBACK, skipping the pings.
+func (b *fakeBroker) readPuback() uint16 {
+	for {
+		p := b.read()
+		if p.typ == packetPingreq {
+			continue
+		}
+		require.Equal(b.t, byte(packetPuback), p.typ)
+		d := decoder{buf: p.body}
+		return d.uint16()
+	}
+}
+
+func (b *fakeBroker) write(p []byte) {
+	_, err := b.conn.Write(p)
+	require.NoError(b.t, err)
+}
+
+func (b *fakeBroker) publish(topic string, id uint16, payload string, user ...[2]string) {
+	var e encoder
+	e.string(topic)
+	flags := byte(0)
+	if id > 0 {
+		e.uint16(id)
+		flags = 1 << 1
+	}
+	if b.level == protocolLevel5 {
+		var props encoder
+		for _, p := range user {
+			props.byte(propertyUserProperty)
+			props.string(p[0])
+			props.string(p[1])
+		}
+		e.varint(len(props.buf))
+		e.buf = append(e.buf, props.buf...)
+	}
+	e.buf = append(e.buf, payload...)
+	b.write(e.packet(packetPublish, flags))
+}
+
+func lines(c *fake.Client) []string {
+	var res []string
+	for _, e := range c.Received() {
+		res = append(res, e.Line)
+	}
+	return res
+}
+
+func TestTarget(t *testing.T) {
+	broker := newFakeBroker(t, protocolLevel311)
+	client := fake.New(func() {})
+	config := &scrapeconfig.MQTTTargetConfig{
+		Broker:   broker.listener.Addr().String(),
+		Topics:   []string{"sites/+/devices/#"},
+		ClientID: "promtail-test",
+		Username: "user",
+		Labels:   model.LabelSet{"job": "mqtt"},
+	}
+	relabelConfig := []*relabel.Config{{
+		SourceLabels: model.LabelNames{labelKeyMQTTTopicLevelPrefix + "1"},
+		TargetLabel:  "site",
+		Action:       relabel.Replace,
+		Regex:        relabel.MustNewRegexp("(.*)"),
+		Replacement:  "$1",
+	}}
+	target, err := NewTarget(NewMetrics(prometheus.NewRegistry()), log.NewNopLogger(), client, relabelConfig, config)
+	require.NoError(t, err)
+	defer target.Stop()
+
+	connect, subscribe := broker.accept()
+	d := decoder{buf: connect.body}
+	require.Equal(t, "MQTT", d.string())
+	require.Equal(t, byte(protocolLevel311), d.byte())
+	require.Equal(t, byte(0x82), d.byte(), "user name and clean session flags")
+	require.Equal(t, uint16(30), d.uint16())
+	require.Equal(t, "promtail-test", d.string())
+	require.Equal(t, "user", d.string())
+
+	d = decoder{buf: subscribe.body[2:]}
+	require.Equal(t, "sites/+/devices/#", d.string())
+	require.Equal(t, byte(1), d.byte())
+	require.Eventually(t, target.Ready, 5*time.Second, 10*time.Millisecond)
+
+	broker.publish("sites/paris/devices/1", 7, "boot")
+	broker.publish("sites/berlin/devices/2", 0, "reading")
+	broker.publish("sites/paris/devices/1", 8, "shutdown")
+	require.Equal(t, uint16(7), broker.readPuback())
+	require.Equal(t, uint16(8), broker.readPuback())
+
+	require.Eventually(t, func() bool { return len(client.Received()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"boot", "reading", "shutdown"}, lines(client))
+	require.Equal(t, model.LabelSet{"job": "mqtt", "site": "paris"}, client.Received()[0].Labels)
+	require.Equal(t, model.LabelSet{"job": "mqtt", "site": "berlin"}, client.Received()[1].Labels)
+	require.Equal(t, "3", target.Details().(map[string]string)["messages"])
+}
+
+func TestTarget_MQTT5(t *testing.T) {
+	broker := newFakeBroker(t, protocolLevel5)
+	client := fake.New(func() {})
+	clean := false
+	config := &scrapeconfig.MQTTTargetConfig{
+		Broker:          "tcp://" + broker.listener.Addr().String(),
+		ProtocolVersion: "5",
+		Topics:          []string{"devices/#"},
+		ClientID:        "promtail-test",
+		CleanSession:    &clean,
+	}
+	relabelConfig := []*relabel.Config{{
+		SourceLabels: model.LabelNames{labelKeyMQTTUserPropertyPrefix + "firmware_version"},
+		TargetLabel:  "firmware",
+		Action:       relabel.Replace,
+		Regex:        relabel.MustNewRegexp("(.*)"),
+		Replacement:  "$1",
+	}}
+	target, err := NewTarget(NewMetrics(prometheus.NewRegistry()), log.NewNopLogger(), client, relabelConfig, config)
+	require.NoError(t, err)
+	defer target.Stop()
+
+	connect, _ := broker.accept()
+	d := decoder{buf: connect.body}
+	require.Equal(t, "MQTT", d.string())
+	require.Equal(t, byte(protocolLevel5), d.byte())
+	require.Equal(t, byte(0), d.byte(), "session kept")
+	d.uint16()
+	require.Equal(t, 8, d.varint())
+	require.Equal(t, byte(propertySessionExpiryInterval), d.byte())
+	require.Equal(t, uint32(3600), d.uint32())
+	require.Equal(t, byte(propertyReceiveMaximum), d.byte())
+	require.Equal(t, uint16(defaultMaxInflightMessages), d.uint16())
+
+	broker.publish("devices/1", 1, "boot", [2]string{"firmware-version", "1.2"})
+	require.Equal(t, uint16(1), broker.readPuback())
+	require.Eventually(t, func() bool { return len(client.Received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, model.LabelSet{"firmware": "1.2"}, client.Received()[0].Labels)
+}
+
+func TestTarget_PublishBeforeSuback(t *testing.T) {
+	broker := newFakeBroker(t, protocolLevel311)
+	client := fake.New(func() {})
+	clean := false
+	config := &scrapeconfig.MQTTTargetConfig{
+		Broker:       broker.listener.Addr().String(),
+		Topics:       []string{"logs"},
+		ClientID:     "promtail-test",
+		CleanSession: &clean,
+	}
+	target, err := NewTarget(NewMetrics(prometheus.NewRegistry()), log.NewNopLogger(), client, nil, config)
+	require.NoError(t, err)
+	defer target.Stop()
+
+	// The broker resends the messages of the kept session before acknowledging the subscriptions.
+	broker.beforeSuback = func() { broker.publish("logs", 7, "kept") }
+	broker.accept()
+	require.Equal(t, uint16(7), broker.readPuback())
+	broker.publish("logs", 8, "new")
+	require.Equal(t, uint16(8), broker.readPuback())
+	require.Eventually(t, func() bool { return len(client.Received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"kept", "new"}, lines(client))
+}
+
+func TestTarget_Reconnect(t *testing.T) {
+	defer func(b time.Duration) { reconnectBackoff.MinBackoff = b }(reconnectBackoff.MinBackoff)
+	reconnectBackoff.MinBackoff = time.Millisecond
+
+	broker := newFakeBroker(t, protocolLevel311)
+	client := fake.New(func() {})
+	config := &scrapeconfig.MQTTTargetConfig{
+		Broker: broker.listener.Addr().String(),
+		Topics: []string{"logs"},
+	}
+	target, err := NewTarget(NewMetrics(prometheus.NewRegistry()), log.NewNopLogger(), client, nil, config)
+	require.NoError(t, err)
+	defer target.Stop()
+
+	broker.accept()
+	broker.publish("logs", 1, "first")
+	require.Equal(t, uint16(1), broker.readPuback())
+	require.NoError(t, broker.conn.Close())
+
+	broker.accept()
+	broker.publish("logs", 1, "second")
+	require.Equal(t, uint16(1), broker.readPuback())
+	require.Eventually(t, func() bool { return len(client.Received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"first", "second"}, lines(client))
+}
+
+func Test_validateConfig(t *testing.T) {
+	clean := false
+	for _, tc := range []struct {
+		name   string
+		config scrapeconfig.MQTTTargetConfig
+		err    bool
+	}{
+		{name: "no broker", config: scrapeconfig.MQTTTargetConfig{Topics: []string{"a"}}, err: true},
+		{name: "no topics", config: scrapeconfig.MQTTTargetConfig{Broker: "localhost"}, err: true},
+		{name: "defaults", config: scrapeconfig.MQTTTargetConfig{Broker: "localhost", Topics: []string{"a"}}},
+		{name: "unsupported version", config: scrapeconfig.MQTTTargetConfig{Broker: "localhost", Topics: []string{"a"}, ProtocolVersion: "3.1"}, err: true},
+		{name: "kept session without client id", config: scrapeconfig.MQTTTargetConfig{Broker: "localhost", Topics: []string{"a"}, CleanSession: &clean}, err: true},
+		{name: "keep alive too short", config: scrapeconfig.MQTTTargetConfig{Broker: "localhost", Topics: []string{"a"}, KeepAlive: time.Millisecond}, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateConfig(&tc.config)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "3.1.1", tc.config.ProtocolVersion)
+			require.Equal(t, defaultQoS, *tc.config.QoS)
+			require.True(t, *tc.config.CleanSession)
+			require.Equal(t, defaultKeepAlive, tc.config.KeepAlive)
+		})
+	}
+}
+
+func Test_parseBroker(t *testing.T) {
+	for _, tc := range []struct {
+		broker  string
+		address string
+		tls     bool
+		err     bool
+	}{
+		{broker: "localhost", address: "localhost:1883"},
+		{broker: "localhost:1884", address: "localhost:1884"},
+		{broker: "tcp://mqtt.example.com", address: "mqtt.example.com:1883"},
+		{broker: "ssl://mqtt.example.com", address: "mqtt.example.com:8883", tls: true},
+		{broker: "mqtts://mqtt.example.com:9883", address: "mqtt.example.com:9883", tls: true},
+		{broker: "ws://mqtt.example.com", err: true},
+	} {
+		t.Run(tc.broker, func(t *testing.T) {
+			address, useTLS, err := parseBroker(tc.broker)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.address, address)
+			require.Equal(t, tc.tls, useTLS)
+		})
+	}
+}
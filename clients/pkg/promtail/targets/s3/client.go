@@ -0,0 +1,107 @@
+package s3
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/query"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// The SQS API client of the AWS SDK isn't a dependency of Loki, sqsClient is a
+// client of the few operations used by the target, built on the SDK core the same way.
+// The shapes below only have the members needed by the target.
+
+// sqsAPI is the subset of the SQS API used by the target.
+type sqsAPI interface {
+	ReceiveMessageWithContext(ctx context.Context, input *receiveMessageInput) (*receiveMessageOutput, error)
+	DeleteMessageWithContext(ctx context.Context, input *deleteMessageInput) (*deleteMessageOutput, error)
+}
+
+// s3API is the subset of the S3 API used by the target.
+type s3API interface {
+	GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error)
+}
+
+type sqsClient struct {
+	*client.Client
+}
+
+func newSQSClient(p client.ConfigProvider, cfgs ...*aws.Config) *sqsClient {
+	c := p.ClientConfig("sqs", cfgs...)
+	sc := &sqsClient{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "sqs",
+				ServiceID:     "SQS",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				PartitionID:   c.PartitionID,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2012-11-05",
+			},
+			c.Handlers,
+		),
+	}
+	sc.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	sc.Handlers.Build.PushBackNamed(query.BuildHandler)
+	sc.Handlers.Unmarshal.PushBackNamed(query.UnmarshalHandler)
+	sc.Handlers.UnmarshalMeta.PushBackNamed(query.UnmarshalMetaHandler)
+	sc.Handlers.UnmarshalError.PushBackNamed(query.UnmarshalErrorHandler)
+	return sc
+}
+
+func (c *sqsClient) send(ctx context.Context, name string, input, output interface{}) error {
+	req := c.NewRequest(&request.Operation{Name: name, HTTPMethod: "POST", HTTPPath: "/"}, input, output)
+	req.SetContext(ctx)
+	return req.Send()
+}
+
+type message struct {
+	_ struct{} `type:"structure"`
+
+	MessageId     *string `type:"string"` //nolint:revive
+	ReceiptHandle *string `type:"string"`
+	Body          *string `type:"string"`
+}
+
+type receiveMessageInput struct {
+	_ struct{} `type:"structure"`
+
+	QueueUrl            *string `type:"string"` //nolint:revive
+	MaxNumberOfMessages *int64  `type:"integer"`
+	WaitTimeSeconds     *int64  `type:"integer"`
+	VisibilityTimeout   *int64  `type:"integer"`
+}
+
+type receiveMessageOutput struct {
+	_ struct{} `type:"structure"`
+
+	Messages []*message `locationNameList:"Message" type:"list" flattened:"true"`
+}
+
+func (c *sqsClient) ReceiveMessageWithContext(ctx context.Context, input *receiveMessageInput) (*receiveMessageOutput, error) {
+	output := &receiveMessageOutput{}
+	return output, c.send(ctx, "ReceiveMessage", input, output)
+}
+
+type deleteMessageInput struct {
+	_ struct{} `type:"structure"`
+
+	QueueUrl      *string `type:"string"` //nolint:revive
+	ReceiptHandle *string `type:"string"`
+}
+
+type deleteMessageOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+func (c *sqsClient) DeleteMessageWithContext(ctx context.Context, input *deleteMessageInput) (*deleteMessageOutput, error) {
+	output := &deleteMessageOutput{}
+	return output, c.send(ctx, "DeleteMessage", input, output)
+}
@@ -0,0 +1,70 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *sqsClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")).
+		WithMaxRetries(0))
+	require.NoError(t, err)
+	return newSQSClient(sess, aws.NewConfig().WithEndpoint(server.URL))
+}
+
+func Test_sqsClient_ReceiveMessage(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "ReceiveMessage", r.Form.Get("Action"))
+		require.Equal(t, "2012-11-05", r.Form.Get("Version"))
+		require.Equal(t, "queue", r.Form.Get("QueueUrl"))
+		require.Equal(t, "10", r.Form.Get("MaxNumberOfMessages"))
+		require.Equal(t, "20", r.Form.Get("WaitTimeSeconds"))
+		_, _ = io.WriteString(w, `<ReceiveMessageResponse>
+			<ReceiveMessageResult>
+				<Message><MessageId>1</MessageId><ReceiptHandle>handle-1</ReceiptHandle><Body>body 1</Body></Message>
+				<Message><MessageId>2</MessageId><ReceiptHandle>handle-2</ReceiptHandle><Body>body 2</Body></Message>
+			</ReceiveMessageResult>
+			<ResponseMetadata><RequestId>request</RequestId></ResponseMetadata>
+		</ReceiveMessageResponse>`)
+	})
+
+	out, err := c.ReceiveMessageWithContext(context.Background(), &receiveMessageInput{
+		QueueUrl:            aws.String("queue"),
+		MaxNumberOfMessages: aws.Int64(10),
+		WaitTimeSeconds:     aws.Int64(20),
+	})
+	require.NoError(t, err)
+	require.Equal(t, &receiveMessageOutput{Messages: []*message{
+		{MessageId: aws.String("1"), ReceiptHandle: aws.String("handle-1"), Body: aws.String("body 1")},
+		{MessageId: aws.String("2"), ReceiptHandle: aws.String("handle-2"), Body: aws.String("body 2")},
+	}}, out)
+}
+
+func Test_sqsClient_Error(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `<ErrorResponse>
+			<Error><Type>Sender</Type><Code>ReceiptHandleIsInvalid</Code><Message>invalid handle</Message></Error>
+			<RequestId>request</RequestId>
+		</ErrorResponse>`)
+	})
+
+	_, err := c.DeleteMessageWithContext(context.Background(), &deleteMessageInput{QueueUrl: aws.String("queue"), ReceiptHandle: aws.String("handle")})
+	var aerr awserr.Error
+	require.ErrorAs(t, err, &aerr)
+	require.Equal(t, "ReceiptHandleIsInvalid", aerr.Code())
+}
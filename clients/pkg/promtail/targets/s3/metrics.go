@@ -0,0 +1,46 @@
+package s3
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds the metrics of the S3 targets.
+type Metrics struct {
+	// reg is the Registerer used to create this set of metrics.
+	reg prometheus.Registerer
+
+	objects   *prometheus.CounterVec
+	lines     *prometheus.CounterVec
+	readBytes *prometheus.CounterVec
+	errors    *prometheus.CounterVec
+}
+
+// NewMetrics creates a new set of metrics. Metrics will be registered to reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	var m Metrics
+	m.reg = reg
+
+	m.objects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "s3_target_objects_total",
+		Help:      "Total number of S3 objects read.",
+	}, []string{"bucket"})
+	m.lines = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "s3_target_lines_total",
+		Help:      "Total number of lines read from S3 objects.",
+	}, []string{"bucket"})
+	m.readBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "s3_target_read_bytes_total",
+		Help:      "Total number of bytes read from S3 objects, after decompression.",
+	}, []string{"bucket"})
+	m.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "s3_target_errors_total",
+		Help:      "Total number of errors while receiving the notifications of a queue or reading the objects they notify.",
+	}, []string{"queue_url"})
+
+	if reg != nil {
+		reg.MustRegister(m.objects, m.lines, m.readBytes, m.errors)
+	}
+	return &m
+}
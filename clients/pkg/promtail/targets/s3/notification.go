@@ -0,0 +1,65 @@
+package s3
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// eventRecord is a record of an S3 event notification, with the members needed by the target.
+type eventRecord struct {
+	EventName string    `json:"eventName"`
+	EventTime time.Time `json:"eventTime"`
+	AWSRegion string    `json:"awsRegion"`
+	S3        struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key       string `json:"key"`
+			Size      int64  `json:"size"`
+			VersionID string `json:"versionId"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+type notification struct {
+	Records []eventRecord `json:"Records"`
+	// Event is only set by the test event sent when the notifications of a bucket are configured.
+	Event string `json:"Event"`
+
+	// Type and Message are set when the notification has been published to an SNS topic
+	// the queue is subscribed to.
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// parseNotification returns the records of the objects created notified by the body of
+// an SQS message, which is either an S3 event notification or an SNS notification of one.
+func parseNotification(body string) ([]eventRecord, error) {
+	var n notification
+	if err := json.Unmarshal([]byte(body), &n); err != nil {
+		return nil, fmt.Errorf("invalid S3 event notification: %w", err)
+	}
+	if n.Type == "Notification" {
+		if err := json.Unmarshal([]byte(n.Message), &n); err != nil {
+			return nil, fmt.Errorf("invalid S3 event notification in SNS message: %w", err)
+		}
+	}
+	records := make([]eventRecord, 0, len(n.Records))
+	for _, r := range n.Records {
+		if !strings.HasPrefix(r.EventName, "ObjectCreated:") {
+			continue
+		}
+		// Keys are URL encoded in notifications.
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object key %q: %w", r.S3.Object.Key, err)
+		}
+		r.S3.Object.Key = key
+		records = append(records, r)
+	}
+	return records, nil
+}
@@ -0,0 +1,385 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/util"
+)
+
+const (
+	labelKeyS3Bucket    = "__meta_s3_bucket"
+	labelKeyS3ObjectKey = "__meta_s3_object_key"
+	labelKeyS3Region    = "__meta_s3_region"
+
+	defaultMaxMessages = 10
+	defaultWaitTime    = 20 * time.Second
+)
+
+// errorBackoff is how long to wait before receiving notifications again after an error.
+var errorBackoff = 5 * time.Second
+
+// Target reads the objects notified by the S3 event notifications of an SQS queue. A
+// notification is deleted from the queue once all the lines of its objects have been
+// pushed to Loki, it is received again after its visibility timeout otherwise.
+type Target struct {
+	logger        log.Logger
+	metrics       *Metrics
+	handler       api.EntryHandler
+	sqs           sqsAPI
+	s3            s3API
+	config        *scrapeconfig.S3TargetConfig
+	relabelConfig []*relabel.Config
+
+	mtx       sync.Mutex
+	objects   int
+	lastError error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTarget creates a Target reading the objects notified to the queue of a scrape config.
+func NewTarget(
+	metrics *Metrics,
+	logger log.Logger,
+	handler api.EntryHandler,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.S3TargetConfig,
+) (*Target, error) {
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+	awsCfg := aws.NewConfig()
+	if config.Region != "" {
+		awsCfg = awsCfg.WithRegion(config.Region)
+	}
+	if config.AccessKeyID != "" {
+		awsCfg = awsCfg.WithCredentials(credentials.NewStaticCredentials(config.AccessKeyID, config.SecretAccessKey.Value, ""))
+	}
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AWS session: %w", err)
+	}
+	sqsCfg := aws.NewConfig()
+	if config.SQSEndpoint != "" {
+		sqsCfg = sqsCfg.WithEndpoint(config.SQSEndpoint)
+	}
+	s3Cfg := aws.NewConfig().WithS3ForcePathStyle(config.S3ForcePathStyle)
+	if config.S3Endpoint != "" {
+		s3Cfg = s3Cfg.WithEndpoint(config.S3Endpoint)
+	}
+	return newTarget(metrics, logger, handler, relabelConfig, config, newSQSClient(sess, sqsCfg), s3.New(sess, s3Cfg)), nil
+}
+
+func newTarget(
+	metrics *Metrics,
+	logger log.Logger,
+	handler api.EntryHandler,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.S3TargetConfig,
+	sqs sqsAPI,
+	s3 s3API,
+) *Target {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Target{
+		logger:        log.With(logger, "queue_url", config.QueueURL),
+		metrics:       metrics,
+		handler:       handler,
+		sqs:           sqs,
+		s3:            s3,
+		config:        config,
+		relabelConfig: relabelConfig,
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+func validateConfig(cfg *scrapeconfig.S3TargetConfig) error {
+	if cfg.QueueURL == "" {
+		return errors.New("no SQS queue URL given to receive S3 event notifications")
+	}
+	if cfg.MaxMessages < 0 || cfg.MaxMessages > defaultMaxMessages {
+		return fmt.Errorf("max messages must be between 1 and %d", defaultMaxMessages)
+	}
+	if cfg.MaxMessages == 0 {
+		cfg.MaxMessages = defaultMaxMessages
+	}
+	if cfg.WaitTime < 0 || cfg.WaitTime > defaultWaitTime {
+		return fmt.Errorf("wait time must be between 0s and %s", defaultWaitTime)
+	}
+	if cfg.WaitTime == 0 {
+		cfg.WaitTime = defaultWaitTime
+	}
+	if cfg.VisibilityTimeout < 0 {
+		return errors.New("visibility timeout must not be negative")
+	}
+	return nil
+}
+
+func (t *Target) run() {
+	defer close(t.done)
+	for t.ctx.Err() == nil {
+		if err := t.receive(); err != nil && t.ctx.Err() == nil {
+			level.Warn(t.logger).Log("msg", "error receiving S3 event notifications", "err", err)
+			t.setError(err)
+			t.wait(errorBackoff)
+		}
+	}
+}
+
+// receive receives a batch of notifications and reads the objects they notify.
+func (t *Target) receive() error {
+	input := &receiveMessageInput{
+		QueueUrl:            aws.String(t.config.QueueURL),
+		MaxNumberOfMessages: aws.Int64(int64(t.config.MaxMessages)),
+		WaitTimeSeconds:     aws.Int64(int64(t.config.WaitTime / time.Second)),
+	}
+	if t.config.VisibilityTimeout > 0 {
+		input.VisibilityTimeout = aws.Int64(int64(t.config.VisibilityTimeout / time.Second))
+	}
+	out, err := t.sqs.ReceiveMessageWithContext(t.ctx, input)
+	if err != nil {
+		return err
+	}
+	for _, m := range out.Messages {
+		if err := t.process(aws.StringValue(m.Body)); err != nil {
+			if t.ctx.Err() != nil {
+				return nil
+			}
+			// The notification is received again once its visibility timeout expires.
+			level.Warn(t.logger).Log("msg", "error reading S3 objects", "message_id", aws.StringValue(m.MessageId), "err", err)
+			t.setError(err)
+			continue
+		}
+		_, err := t.sqs.DeleteMessageWithContext(t.ctx, &deleteMessageInput{
+			QueueUrl:      aws.String(t.config.QueueURL),
+			ReceiptHandle: m.ReceiptHandle,
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting message %s: %w", aws.StringValue(m.MessageId), err)
+		}
+	}
+	return nil
+}
+
+// process reads the objects notified by the body of a message.
+func (t *Target) process(body string) error {
+	records, err := parseNotification(body)
+	if err != nil {
+		// The message would never be processed, it is dropped.
+		level.Warn(t.logger).Log("msg", "dropping message", "err", err)
+		t.metrics.errors.WithLabelValues(t.config.QueueURL).Inc()
+		return nil
+	}
+	for _, r := range records {
+		if err := t.read(r); err != nil {
+			return fmt.Errorf("error reading object %s of bucket %s: %w", r.S3.Object.Key, r.S3.Bucket.Name, err)
+		}
+		t.mtx.Lock()
+		t.objects++
+		t.lastError = nil
+		t.mtx.Unlock()
+	}
+	return nil
+}
+
+// read sends the lines of an object and waits until they have been pushed to Loki.
+// Objects compressed with gzip are decompressed.
+func (t *Target) read(r eventRecord) error {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(r.S3.Bucket.Name),
+		Key:    aws.String(r.S3.Object.Key),
+	}
+	if r.S3.Object.VersionID != "" {
+		input.VersionId = aws.String(r.S3.Object.VersionID)
+	}
+	out, err := t.s3.GetObjectWithContext(t.ctx, input)
+	if err != nil {
+		return err
+	}
+	defer out.Body.Close()
+
+	reader := bufio.NewReader(out.Body)
+	if magic, err := reader.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = bufio.NewReader(gz)
+	}
+
+	lbs := t.labels(r)
+	ts := time.Now()
+	if t.config.UseIncomingTimestamp && !r.EventTime.IsZero() {
+		ts = r.EventTime
+	}
+
+	var (
+		acks     sync.WaitGroup
+		ackMtx   sync.Mutex
+		firstErr error
+	)
+	ack := api.AckFunc(func(err error) {
+		ackMtx.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		ackMtx.Unlock()
+		acks.Done()
+	})
+	var lines, readBytes int
+	for {
+		line, err := reader.ReadString('\n')
+		readBytes += len(line)
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			acks.Add(1)
+			select {
+			case t.handler.Chan() <- api.Entry{
+				Labels: lbs.Clone(),
+				Entry:  logproto.Entry{Timestamp: ts, Line: line},
+				Ack:    ack,
+			}:
+				lines++
+			case <-t.ctx.Done():
+				return t.ctx.Err()
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	t.metrics.objects.WithLabelValues(r.S3.Bucket.Name).Inc()
+	t.metrics.lines.WithLabelValues(r.S3.Bucket.Name).Add(float64(lines))
+	t.metrics.readBytes.WithLabelValues(r.S3.Bucket.Name).Add(float64(readBytes))
+
+	acked := make(chan struct{})
+	go func() {
+		acks.Wait()
+		close(acked)
+	}()
+	select {
+	case <-acked:
+		if firstErr != nil {
+			return fmt.Errorf("lines could not be pushed: %w", firstErr)
+		}
+		return nil
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	}
+}
+
+func (t *Target) labels(r eventRecord) model.LabelSet {
+	lbs := labels.Labels{
+		{Name: labelKeyS3Bucket, Value: r.S3.Bucket.Name},
+		{Name: labelKeyS3ObjectKey, Value: r.S3.Object.Key},
+		{Name: labelKeyS3Region, Value: r.AWSRegion},
+	}
+	out := t.config.Labels.Clone()
+	if processed := format(lbs, t.relabelConfig); len(processed) > 0 {
+		out = out.Merge(processed)
+	}
+	return out
+}
+
+func format(lbs labels.Labels, cfg []*relabel.Config) model.LabelSet {
+	processed := relabel.Process(labels.New(lbs...), cfg...)
+	labelOut := model.LabelSet(util.LabelsToMetric(processed))
+	for k := range labelOut {
+		if strings.HasPrefix(string(k), "__") {
+			delete(labelOut, k)
+		}
+	}
+	return labelOut
+}
+
+func (t *Target) setError(err error) {
+	t.metrics.errors.WithLabelValues(t.config.QueueURL).Inc()
+	t.mtx.Lock()
+	t.lastError = err
+	t.mtx.Unlock()
+}
+
+// wait waits for d, it returns false if the target has been stopped meanwhile.
+func (t *Target) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-t.ctx.Done():
+		return false
+	}
+}
+
+// Stop stops receiving notifications.
+func (t *Target) Stop() {
+	t.cancel()
+	<-t.done
+	t.handler.Stop()
+}
+
+// Type implements target.Target.
+func (t *Target) Type() target.TargetType {
+	return target.S3TargetType
+}
+
+// Ready implements target.Target.
+func (t *Target) Ready() bool {
+	return t.ctx.Err() == nil
+}
+
+// DiscoveredLabels implements target.Target.
+func (t *Target) DiscoveredLabels() model.LabelSet {
+	return nil
+}
+
+// Labels implements target.Target.
+func (t *Target) Labels() model.LabelSet {
+	return t.config.Labels
+}
+
+// Details implements target.Target.
+func (t *Target) Details() interface{} {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	details := map[string]string{
+		"queue_url": t.config.QueueURL,
+		"objects":   fmt.Sprint(t.objects),
+	}
+	if t.lastError != nil {
+		details["error"] = t.lastError.Error()
+	}
+	return details
+}
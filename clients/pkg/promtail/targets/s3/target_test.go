@@ -0,0 +1,215 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/client/fake"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+)
+
+type fakeSQS struct {
+	mtx      sync.Mutex
+	sent     int
+	messages []*message
+	deleted  []string
+}
+
+func (f *fakeSQS) send(body string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	id := fmt.Sprint(f.sent)
+	f.sent++
+	f.messages = append(f.messages, &message{MessageId: aws.String(id), ReceiptHandle: aws.String("handle-" + id), Body: aws.String(body)})
+}
+
+func (f *fakeSQS) ReceiveMessageWithContext(ctx context.Context, input *receiveMessageInput) (*receiveMessageOutput, error) {
+	f.mtx.Lock()
+	messages := f.messages
+	f.messages = nil
+	f.mtx.Unlock()
+	if len(messages) == 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return &receiveMessageOutput{Messages: messages}, nil
+}
+
+func (f *fakeSQS) DeleteMessageWithContext(ctx context.Context, input *deleteMessageInput) (*deleteMessageOutput, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.deleted = append(f.deleted, aws.StringValue(input.ReceiptHandle))
+	return &deleteMessageOutput{}, nil
+}
+
+func (f *fakeSQS) getDeleted() []string {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return append([]string(nil), f.deleted...)
+}
+
+type fakeS3 map[string][]byte
+
+func (f fakeS3) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	data, ok := f[aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key)]
+	if !ok {
+		return nil, errors.New("NoSuchKey")
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func notificationOf(bucket, key, eventName string) string {
+	return fmt.Sprintf(`{"Records":[{"eventName":%q,"eventTime":"2022-01-02T03:04:05.000Z","awsRegion":"us-east-1","s3":{"bucket":{"name":%q},"object":{"key":%q}}}]}`, eventName, bucket, key)
+}
+
+func lines(c *fake.Client) []string {
+	var res []string
+	for _, e := range c.Received() {
+		res = append(res, e.Line)
+	}
+	return res
+}
+
+func TestTarget(t *testing.T) {
+	sqs := &fakeSQS{}
+	objects := fakeS3{
+		"logs/elb/2022/01/02/file.log.gz": gzipped(t, "elb 1\nelb 2\n"),
+		"logs/vpc flow.log":               []byte("vpc 1\r\nvpc 2"),
+	}
+	client := fake.New(func() {})
+	config := &scrapeconfig.S3TargetConfig{
+		QueueURL:             "https://sqs.us-east-1.amazonaws.com/123/queue",
+		Labels:               model.LabelSet{"job": "s3"},
+		UseIncomingTimestamp: true,
+	}
+	require.NoError(t, validateConfig(config))
+	relabelConfig := []*relabel.Config{{
+		SourceLabels: model.LabelNames{labelKeyS3Bucket},
+		TargetLabel:  "bucket",
+		Action:       relabel.Replace,
+		Regex:        relabel.MustNewRegexp("(.*)"),
+		Replacement:  "$1",
+	}}
+	target := newTarget(NewMetrics(prometheus.NewRegistry()), log.NewNopLogger(), client, relabelConfig, config, sqs, objects)
+	defer target.Stop()
+
+	sqs.send(`{"Service":"Amazon S3","Event":"s3:TestEvent"}`)
+	sqs.send(notificationOf("logs", "elb/2022/01/02/file.log.gz", "ObjectCreated:Put"))
+	sqs.send(notificationOf("logs", "vpc+flow.log", "ObjectCreated:CompleteMultipartUpload"))
+	sqs.send(notificationOf("logs", "removed.log", "ObjectRemoved:Delete"))
+	sqs.send(fmt.Sprintf(`{"Type":"Notification","Message":%q}`, notificationOf("logs", "elb/2022/01/02/file.log.gz", "ObjectCreated:Put")))
+
+	require.Eventually(t, func() bool {
+		return len(sqs.getDeleted()) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"elb 1", "elb 2", "vpc 1", "vpc 2", "elb 1", "elb 2"}, lines(client))
+	e := client.Received()[0]
+	require.Equal(t, model.LabelSet{"job": "s3", "bucket": "logs"}, e.Labels)
+	require.Equal(t, time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC), e.Timestamp)
+	require.Equal(t, "3", target.Details().(map[string]string)["objects"])
+}
+
+func TestTarget_Errors(t *testing.T) {
+	defer func(d time.Duration) { errorBackoff = d }(errorBackoff)
+	errorBackoff = time.Millisecond
+
+	sqs := &fakeSQS{}
+	client := fake.New(func() {})
+	config := &scrapeconfig.S3TargetConfig{QueueURL: "queue"}
+	require.NoError(t, validateConfig(config))
+	target := newTarget(NewMetrics(prometheus.NewRegistry()), log.NewNopLogger(), client, nil, config, sqs, fakeS3{"logs/a.log": []byte("a")})
+	defer target.Stop()
+
+	// Invalid messages are dropped, the messages of objects that can't be read are kept.
+	sqs.send("not json")
+	sqs.send(notificationOf("logs", "missing.log", "ObjectCreated:Put"))
+	sqs.send(notificationOf("logs", "a.log", "ObjectCreated:Put"))
+	require.Eventually(t, func() bool {
+		return len(sqs.getDeleted()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"handle-0", "handle-2"}, sqs.getDeleted())
+	require.Equal(t, []string{"a"}, lines(client))
+}
+
+type failingHandler struct {
+	entries chan api.Entry
+}
+
+func (h *failingHandler) Chan() chan<- api.Entry { return h.entries }
+func (h *failingHandler) Stop()                  {}
+
+func TestTarget_NotPushed(t *testing.T) {
+	sqs := &fakeSQS{}
+	handler := &failingHandler{entries: make(chan api.Entry)}
+	go func() {
+		for e := range handler.entries {
+			e.Ack.Done(errors.New("push failed"))
+		}
+	}()
+	config := &scrapeconfig.S3TargetConfig{QueueURL: "queue"}
+	require.NoError(t, validateConfig(config))
+	target := newTarget(NewMetrics(prometheus.NewRegistry()), log.NewNopLogger(), handler, nil, config, sqs, fakeS3{"logs/a.log": []byte("a\nb")})
+
+	sqs.send(notificationOf("logs", "a.log", "ObjectCreated:Put"))
+	require.Eventually(t, func() bool {
+		_, ok := target.Details().(map[string]string)["error"]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	target.Stop()
+	close(handler.entries)
+	require.Empty(t, sqs.getDeleted())
+}
+
+func Test_validateConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config scrapeconfig.S3TargetConfig
+		err    bool
+	}{
+		{name: "no queue", err: true},
+		{name: "defaults", config: scrapeconfig.S3TargetConfig{QueueURL: "queue"}},
+		{name: "too many messages", config: scrapeconfig.S3TargetConfig{QueueURL: "queue", MaxMessages: 11}, err: true},
+		{name: "wait time too long", config: scrapeconfig.S3TargetConfig{QueueURL: "queue", WaitTime: time.Minute}, err: true},
+		{name: "negative visibility timeout", config: scrapeconfig.S3TargetConfig{QueueURL: "queue", VisibilityTimeout: -time.Second}, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateConfig(&tc.config)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, defaultMaxMessages, tc.config.MaxMessages)
+			require.Equal(t, defaultWaitTime, tc.config.WaitTime)
+		})
+	}
+}
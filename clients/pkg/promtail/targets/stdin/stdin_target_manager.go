@@ -3,6 +3,7 @@ package stdin
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -26,6 +27,30 @@ import (
 // bufferSize is the size of the buffered reader
 const bufferSize = 8096
 
+const (
+	// FormatRaw reads one log line per line of input.
+	FormatRaw = "raw"
+	// FormatNDJSON reads one JSON record per line of input.
+	FormatNDJSON = "ndjson"
+)
+
+// Options configures how stdin is read.
+type Options struct {
+	// Format is either FormatRaw or FormatNDJSON, defaults to FormatRaw.
+	Format string
+	// Labels are added to every entry read.
+	Labels model.LabelSet
+	// KeepAlive keeps promtail running once stdin is fully read.
+	KeepAlive bool
+}
+
+// record is a single line of ndjson input.
+type record struct {
+	Line      string            `json:"line"`
+	Timestamp time.Time         `json:"timestamp"`
+	Labels    map[string]string `json:"labels"`
+}
+
 // file is an interface allowing us to abstract a file.
 type file interface {
 	Stat() (os.FileInfo, error)
@@ -55,17 +80,22 @@ type Shutdownable interface {
 // nolint:revive
 type StdinTargetManager struct {
 	*readerTarget
-	app Shutdownable
+	app       Shutdownable
+	keepAlive bool
 }
 
-func NewStdinTargetManager(reg prometheus.Registerer, log log.Logger, app Shutdownable, client api.EntryHandler, configs []scrapeconfig.Config) (*StdinTargetManager, error) {
-	reader, err := newReaderTarget(reg, log, stdIn, client, getStdinConfig(log, configs))
+func NewStdinTargetManager(reg prometheus.Registerer, log log.Logger, app Shutdownable, client api.EntryHandler, configs []scrapeconfig.Config, opts Options) (*StdinTargetManager, error) {
+	reader, err := newReaderTarget(reg, log, stdIn, client, getStdinConfig(log, configs), opts)
 	if err != nil {
 		return nil, err
 	}
 	stdinManager := &StdinTargetManager{
 		readerTarget: reader,
 		app:          app,
+		keepAlive:    opts.KeepAlive,
+	}
+	if opts.KeepAlive {
+		return stdinManager, nil
 	}
 	// when we're done flushing our stdin we can shutdown the app.
 	go func() {
@@ -88,7 +118,7 @@ func getStdinConfig(log log.Logger, configs []scrapeconfig.Config) scrapeconfig.
 }
 
 func (t *StdinTargetManager) Ready() bool {
-	return t.ctx.Err() == nil
+	return t.keepAlive || t.ctx.Err() == nil
 }
 func (t *StdinTargetManager) Stop()                                     { t.cancel() }
 func (t *StdinTargetManager) ActiveTargets() map[string][]target.Target { return nil }
@@ -98,13 +128,22 @@ type readerTarget struct {
 	in     *bufio.Reader
 	out    api.EntryHandler
 	lbs    model.LabelSet
+	ndjson bool
 	logger log.Logger
 
 	cancel context.CancelFunc
 	ctx    context.Context
 }
 
-func newReaderTarget(reg prometheus.Registerer, logger log.Logger, in io.Reader, client api.EntryHandler, cfg scrapeconfig.Config) (*readerTarget, error) {
+func newReaderTarget(reg prometheus.Registerer, logger log.Logger, in io.Reader, client api.EntryHandler, cfg scrapeconfig.Config, opts Options) (*readerTarget, error) {
+	switch opts.Format {
+	case "", FormatRaw, FormatNDJSON:
+	default:
+		return nil, fmt.Errorf("unknown stdin format %q, must be %q or %q", opts.Format, FormatRaw, FormatNDJSON)
+	}
+	if err := opts.Labels.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid stdin labels: %w", err)
+	}
 	pipeline, err := stages.NewPipeline(log.With(logger, "component", "pipeline"), cfg.PipelineStages, &cfg.JobName, reg)
 	if err != nil {
 		return nil, err
@@ -115,6 +154,7 @@ func newReaderTarget(reg prometheus.Registerer, logger log.Logger, in io.Reader,
 			lbs = lbs.Merge(static.Labels)
 		}
 	}
+	lbs = lbs.Merge(opts.Labels)
 	ctx, cancel := context.WithCancel(context.Background())
 	t := &readerTarget{
 		in:     bufio.NewReaderSize(in, bufferSize),
@@ -122,6 +162,7 @@ func newReaderTarget(reg prometheus.Registerer, logger log.Logger, in io.Reader,
 		cancel: cancel,
 		ctx:    ctx,
 		lbs:    lbs,
+		ndjson: opts.Format == FormatNDJSON,
 		logger: log.With(logger, "component", "reader"),
 	}
 	go t.read()
@@ -150,15 +191,50 @@ func (t *readerTarget) read() {
 			}
 			continue
 		}
-		entries <- api.Entry{
+		entry, ok := t.entry(line)
+		if ok {
+			entries <- entry
+		}
+		if err == io.EOF {
+			return
+		}
+	}
+}
+
+// entry converts a line of input into an entry, it returns false if the
+// line is not a valid ndjson record.
+func (t *readerTarget) entry(line string) (api.Entry, bool) {
+	if !t.ndjson {
+		return api.Entry{
 			Labels: t.lbs.Clone(),
 			Entry: logproto.Entry{
 				Timestamp: time.Now(),
 				Line:      line,
 			},
-		}
-		if err == io.EOF {
-			return
-		}
+		}, true
 	}
+
+	var r record
+	if err := json.Unmarshal([]byte(line), &r); err != nil {
+		level.Warn(t.logger).Log("msg", "skipping invalid ndjson record", "err", err)
+		return api.Entry{}, false
+	}
+	lbs := t.lbs.Clone()
+	for name, value := range r.Labels {
+		lbs[model.LabelName(name)] = model.LabelValue(value)
+	}
+	if err := lbs.Validate(); err != nil {
+		level.Warn(t.logger).Log("msg", "skipping ndjson record with invalid labels", "err", err)
+		return api.Entry{}, false
+	}
+	if r.Timestamp.IsZero() {
+		r.Timestamp = time.Now()
+	}
+	return api.Entry{
+		Labels: lbs,
+		Entry: logproto.Entry{
+			Timestamp: r.Timestamp,
+			Line:      r.Line,
+		},
+	}, true
 }
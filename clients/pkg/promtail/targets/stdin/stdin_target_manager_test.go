@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -26,6 +27,7 @@ func Test_newReaderTarget(t *testing.T) {
 		name    string
 		in      io.Reader
 		cfg     scrapeconfig.Config
+		opts    Options
 		want    []api.Entry
 		wantErr bool
 	}{
@@ -33,6 +35,7 @@ func Test_newReaderTarget(t *testing.T) {
 			"no newlines",
 			bytes.NewReader([]byte("bar")),
 			scrapeconfig.Config{},
+			Options{},
 			[]api.Entry{
 				{Labels: model.LabelSet{}, Entry: logproto.Entry{Line: "bar"}},
 			},
@@ -42,6 +45,7 @@ func Test_newReaderTarget(t *testing.T) {
 			"empty",
 			bytes.NewReader([]byte("")),
 			scrapeconfig.Config{},
+			Options{},
 			nil,
 			false,
 		},
@@ -49,6 +53,7 @@ func Test_newReaderTarget(t *testing.T) {
 			"newlines",
 			bytes.NewReader([]byte("\nfoo\r\nbar")),
 			scrapeconfig.Config{},
+			Options{},
 			[]api.Entry{
 				{Labels: model.LabelSet{}, Entry: logproto.Entry{Line: "foo"}},
 				{Labels: model.LabelSet{}, Entry: logproto.Entry{Line: "bar"}},
@@ -61,6 +66,7 @@ func Test_newReaderTarget(t *testing.T) {
 			scrapeconfig.Config{
 				PipelineStages: loadConfig(stagesConfig),
 			},
+			Options{},
 			[]api.Entry{
 				{Labels: model.LabelSet{"new_key": "hello world!"}, Entry: logproto.Entry{Line: "foo"}},
 				{Labels: model.LabelSet{"new_key": "hello world!"}, Entry: logproto.Entry{Line: "bar"}},
@@ -71,17 +77,50 @@ func Test_newReaderTarget(t *testing.T) {
 			"default config",
 			bytes.NewReader([]byte("\nfoo\r\nbar")),
 			defaultStdInCfg,
+			Options{},
 			[]api.Entry{
 				{Labels: model.LabelSet{"job": "stdin", "hostname": model.LabelValue(hostName)}, Entry: logproto.Entry{Line: "foo"}},
 				{Labels: model.LabelSet{"job": "stdin", "hostname": model.LabelValue(hostName)}, Entry: logproto.Entry{Line: "bar"}},
 			},
 			false,
 		},
+		{
+			"labels",
+			bytes.NewReader([]byte("foo")),
+			defaultStdInCfg,
+			Options{Labels: model.LabelSet{"job": "piped", "env": "dev"}},
+			[]api.Entry{
+				{Labels: model.LabelSet{"job": "piped", "env": "dev", "hostname": model.LabelValue(hostName)}, Entry: logproto.Entry{Line: "foo"}},
+			},
+			false,
+		},
+		{
+			"ndjson",
+			bytes.NewReader([]byte(`{"line":"foo","timestamp":"2021-01-02T03:04:05Z","labels":{"level":"info"}}
+not json
+{"line":"bar","labels":{"level":"warn","0invalid":"x"}}
+{"line":"baz","labels":{"env":"prod"}}`)),
+			scrapeconfig.Config{},
+			Options{Format: FormatNDJSON, Labels: model.LabelSet{"env": "dev"}},
+			[]api.Entry{
+				{Labels: model.LabelSet{"env": "dev", "level": "info"}, Entry: logproto.Entry{Line: "foo", Timestamp: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)}},
+				{Labels: model.LabelSet{"env": "prod"}, Entry: logproto.Entry{Line: "baz"}},
+			},
+			false,
+		},
+		{
+			"unknown format",
+			bytes.NewReader([]byte("foo")),
+			scrapeconfig.Config{},
+			Options{Format: "csv"},
+			nil,
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := fake.New(func() {})
-			got, err := newReaderTarget(prometheus.DefaultRegisterer, util_log.Logger, tt.in, c, tt.cfg)
+			got, err := newReaderTarget(prometheus.DefaultRegisterer, util_log.Logger, tt.in, c, tt.cfg, tt.opts)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("newReaderTarget() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -121,7 +160,7 @@ func Test_Shutdown(t *testing.T) {
 	stdIn = newFakeStdin("line")
 	appMock := &mockShutdownable{called: make(chan bool, 1)}
 	recorder := fake.New(func() {})
-	manager, err := NewStdinTargetManager(prometheus.DefaultRegisterer, util_log.Logger, appMock, recorder, []scrapeconfig.Config{{}})
+	manager, err := NewStdinTargetManager(prometheus.DefaultRegisterer, util_log.Logger, appMock, recorder, []scrapeconfig.Config{{}}, Options{})
 	require.NoError(t, err)
 	require.NotNil(t, manager)
 	require.Equal(t, true, <-appMock.called)
@@ -129,12 +168,33 @@ func Test_Shutdown(t *testing.T) {
 	compareEntries(t, []api.Entry{{Labels: model.LabelSet{}, Entry: logproto.Entry{Line: "line"}}}, recorder.Received())
 }
 
+func Test_KeepAlive(t *testing.T) {
+	stdIn = newFakeStdin("line")
+	appMock := &mockShutdownable{called: make(chan bool, 1)}
+	recorder := fake.New(func() {})
+	manager, err := NewStdinTargetManager(prometheus.DefaultRegisterer, util_log.Logger, appMock, recorder, []scrapeconfig.Config{{}}, Options{KeepAlive: true})
+	require.NoError(t, err)
+	<-manager.ctx.Done()
+	require.True(t, manager.Ready())
+	select {
+	case <-appMock.called:
+		t.Fatal("promtail should not be shut down in keep-alive mode")
+	case <-time.After(100 * time.Millisecond):
+	}
+	manager.Stop()
+	recorder.Stop()
+	compareEntries(t, []api.Entry{{Labels: model.LabelSet{}, Entry: logproto.Entry{Line: "line"}}}, recorder.Received())
+}
+
 func compareEntries(t *testing.T, expected, actual []api.Entry) {
 	t.Helper()
 	require.Equal(t, len(expected), len(actual))
 	for i := range expected {
 		require.Equal(t, expected[i].Entry.Line, actual[i].Entry.Line)
 		require.Equal(t, expected[i].Labels, actual[i].Labels)
+		if !expected[i].Entry.Timestamp.IsZero() {
+			require.True(t, expected[i].Entry.Timestamp.Equal(actual[i].Entry.Timestamp))
+		}
 	}
 }
 
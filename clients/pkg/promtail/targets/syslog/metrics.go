@@ -9,6 +9,8 @@ type Metrics struct {
 	syslogEntries       prometheus.Counter
 	syslogParsingErrors prometheus.Counter
 	syslogEmptyMessages prometheus.Counter
+
+	syslogRejectedClients prometheus.Counter
 }
 
 // NewMetrics creates a new set of syslog metrics. If reg is non-nil, the
@@ -32,12 +34,18 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 		Name:      "syslog_empty_messages_total",
 		Help:      "Total number of empty messages receiving from syslog",
 	})
+	m.syslogRejectedClients = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "syslog_target_rejected_client_certificates_total",
+		Help:      "Total number of TLS connections rejected as their client certificate is not allowed",
+	})
 
 	if reg != nil {
 		reg.MustRegister(
 			m.syslogEntries,
 			m.syslogParsingErrors,
 			m.syslogEmptyMessages,
+			m.syslogRejectedClients,
 		)
 	}
 
@@ -0,0 +1,201 @@
+package syslogparser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/go-syslog/v3"
+)
+
+// defaultRFC3164Priority is the priority of messages without priority, user-level notice.
+const defaultRFC3164Priority = 13
+
+// rfc3164TimestampLayouts are the timestamp layouts recognized at the start of RFC3164 messages,
+// the BSD one with its variants including milliseconds or a year, and RFC3339.
+var rfc3164TimestampLayouts = []string{
+	time.StampMicro,
+	time.StampMilli,
+	time.Stamp,
+	"Jan _2 2006 15:04:05.000",
+	"Jan _2 2006 15:04:05",
+}
+
+// ParseRFC3164Stream parses a stream of RFC3164 (BSD) syslog messages from the given Reader,
+// calling the callback function with the parsed messages. The messages are separated by new
+// lines, or framed with octet counting when the stream starts with a digit.
+// The function returns on EOF or unrecoverable errors.
+func ParseRFC3164Stream(r io.Reader, callback func(res *syslog.Result), maxMessageLength int) error {
+	buf := bufio.NewReader(r)
+
+	firstByte, err := buf.Peek(1)
+	if err != nil {
+		return err
+	}
+
+	if b := firstByte[0]; b >= '0' && b <= '9' {
+		return parseRFC3164OctetCounting(buf, callback, maxMessageLength)
+	}
+
+	scanner := bufio.NewScanner(buf)
+	scanner.Buffer(make([]byte, 0, 4096), maxMessageLength)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		callback(ParseRFC3164(line, time.Now()))
+	}
+	if err := scanner.Err(); err != nil {
+		callback(&syslog.Result{Error: err})
+	}
+	return nil
+}
+
+func parseRFC3164OctetCounting(r *bufio.Reader, callback func(res *syslog.Result), maxMessageLength int) error {
+	for {
+		length, err := r.ReadString(' ')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			callback(&syslog.Result{Error: err})
+			return nil
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(length[:len(length)-1]))
+		if err != nil {
+			callback(&syslog.Result{Error: fmt.Errorf("invalid octet count %q", length)})
+			return nil
+		}
+		if size > maxMessageLength {
+			callback(&syslog.Result{Error: fmt.Errorf("message too long to parse. was size %d, max length %d", size, maxMessageLength)})
+			return nil
+		}
+		msg := make([]byte, size)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			callback(&syslog.Result{Error: err})
+			return nil
+		}
+		callback(ParseRFC3164(msg, time.Now()))
+	}
+}
+
+// ParseRFC3164 parses a RFC3164 (BSD) syslog message:
+//
+//	<PRI>TIMESTAMP HOSTNAME TAG[PID]: MSG
+//
+// Parsing is best effort as devices rarely follow the RFC: the priority defaults to user-level notice,
+// the timestamp and hostname are optional, and timestamps without year are set in the year of now,
+// or in the previous year if that would be more than a day after now, in the location of now.
+// The tag is the app name of the message.
+func ParseRFC3164(msg []byte, now time.Time) *syslog.Result {
+	m := &syslog.Base{}
+	rest := strings.TrimRight(string(msg), "\r\n\x00")
+
+	priority := uint8(defaultRFC3164Priority)
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end < 2 || end > 4 {
+			return &syslog.Result{Error: fmt.Errorf("invalid priority in message %q", rest)}
+		}
+		p, err := strconv.ParseUint(rest[1:end], 10, 8)
+		if err != nil || p > 191 {
+			return &syslog.Result{Error: fmt.Errorf("invalid priority %q", rest[1:end])}
+		}
+		priority = uint8(p)
+		rest = rest[end+1:]
+	}
+	m.ComputeFromPriority(priority)
+
+	var ok bool
+	if m.Timestamp, rest, ok = parseRFC3164Timestamp(rest, now); ok {
+		// the hostname follows the timestamp, unless the next field ends with a colon as tags
+		// and the mnemonics of Cisco devices do.
+		if field, after := nextField(rest); field != "" && !strings.HasSuffix(field, ":") {
+			m.Hostname = &field
+			rest = after
+		}
+	}
+
+	if field, after := nextField(rest); isTag(field) {
+		tag := strings.TrimSuffix(field, ":")
+		if i := strings.IndexByte(tag, '['); i > 0 && strings.HasSuffix(tag, "]") {
+			procID := tag[i+1 : len(tag)-1]
+			m.ProcID = &procID
+			tag = tag[:i]
+		}
+		m.Appname = &tag
+		rest = after
+	}
+
+	if rest != "" {
+		m.Message = &rest
+	}
+	return &syslog.Result{Message: m}
+}
+
+// parseRFC3164Timestamp parses the timestamp at the start of a message, devices such as Cisco
+// ones prefix it with * or . depending on the state of their clock.
+func parseRFC3164Timestamp(s string, now time.Time) (*time.Time, string, bool) {
+	s = strings.TrimLeft(s, " ")
+	trimmed := strings.TrimLeft(s, "*.")
+
+	if field, after := nextField(trimmed); len(field) > 0 && field[0] >= '0' && field[0] <= '9' {
+		if ts, err := time.Parse(time.RFC3339Nano, field); err == nil {
+			return &ts, after, true
+		}
+		return nil, s, false
+	}
+
+	for _, layout := range rfc3164TimestampLayouts {
+		if len(trimmed) < len(layout) {
+			continue
+		}
+		ts, err := time.ParseInLocation(layout, trimmed[:len(layout)], now.Location())
+		if err != nil {
+			continue
+		}
+		if ts.Year() == 0 {
+			ts = ts.AddDate(now.Year(), 0, 0)
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+		}
+		rest := trimmed[len(layout):]
+		// some devices end the timestamp with a colon.
+		rest = strings.TrimPrefix(rest, ":")
+		return &ts, strings.TrimLeft(rest, " "), true
+	}
+	return nil, s, false
+}
+
+// nextField returns the next space separated field and what follows it.
+func nextField(s string) (string, string) {
+	s = strings.TrimLeft(s, " ")
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// isTag returns whether a field is a tag, alphanumeric characters optionally followed
+// by the process id in brackets and a colon.
+func isTag(field string) bool {
+	if !strings.HasSuffix(field, ":") || len(field) < 2 {
+		return false
+	}
+	tag := field[:len(field)-1]
+	if i := strings.IndexByte(tag, '['); i > 0 {
+		tag = tag[:i]
+	}
+	for _, r := range tag {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' || r == '/') {
+			return false
+		}
+	}
+	return tag != ""
+}
@@ -0,0 +1,109 @@
+package syslogparser_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/go-syslog/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/clients/pkg/promtail/targets/syslog/syslogparser"
+)
+
+func TestParseRFC3164(t *testing.T) {
+	now := time.Date(2022, 1, 2, 10, 0, 0, 0, time.UTC)
+	str := func(s string) *string { return &s }
+	tm := func(t time.Time) *time.Time { return &t }
+
+	for _, tc := range []struct {
+		msg                       string
+		priority                  uint8
+		timestamp                 *time.Time
+		hostname, appname, procID *string
+		message                   *string
+	}{
+		{
+			msg:       `<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8`,
+			priority:  34,
+			timestamp: tm(time.Date(2021, 10, 11, 22, 14, 15, 0, time.UTC)),
+			hostname:  str("mymachine"),
+			appname:   str("su"),
+			message:   str("'su root' failed for lonvick on /dev/pts/8"),
+		},
+		{
+			msg:       `<13>Jan  2 09:59:00 sshd[1234]: Accepted publickey` + "\n",
+			priority:  13,
+			timestamp: tm(time.Date(2022, 1, 2, 9, 59, 0, 0, time.UTC)),
+			appname:   str("sshd"),
+			procID:    str("1234"),
+			message:   str("Accepted publickey"),
+		},
+		{
+			msg:       `<189>*Jan  2 2022 09:59:00.123: %SYS-5-CONFIG_I: Configured from console`,
+			priority:  189,
+			timestamp: tm(time.Date(2022, 1, 2, 9, 59, 0, 123000000, time.UTC)),
+			message:   str("%SYS-5-CONFIG_I: Configured from console"),
+		},
+		{
+			msg:       `<14>2022-01-02T09:59:00+01:00 fw-1 kernel: DROP IN=eth0`,
+			priority:  14,
+			timestamp: tm(time.Date(2022, 1, 2, 8, 59, 0, 0, time.UTC)),
+			hostname:  str("fw-1"),
+			appname:   str("kernel"),
+			message:   str("DROP IN=eth0"),
+		},
+		{
+			msg:      `no priority nor timestamp`,
+			priority: 13,
+			message:  str("no priority nor timestamp"),
+		},
+	} {
+		res := syslogparser.ParseRFC3164([]byte(tc.msg), now)
+		require.NoError(t, res.Error, tc.msg)
+		m := res.Message.(*syslog.Base)
+		require.Equal(t, tc.priority, *m.Priority, tc.msg)
+		if tc.timestamp == nil {
+			require.Nil(t, m.Timestamp, tc.msg)
+		} else {
+			require.True(t, tc.timestamp.Equal(*m.Timestamp), "%s: %v", tc.msg, m.Timestamp)
+		}
+		require.Equal(t, tc.hostname, m.Hostname, tc.msg)
+		require.Equal(t, tc.appname, m.Appname, tc.msg)
+		require.Equal(t, tc.procID, m.ProcID, tc.msg)
+		require.Equal(t, tc.message, m.Message, tc.msg)
+	}
+
+	// timestamps in december received in january are from the previous year.
+	res := syslogparser.ParseRFC3164([]byte(`<13>Dec 31 23:59:59 host app: msg`), now)
+	require.Equal(t, 2021, res.Message.(*syslog.Base).Timestamp.Year())
+
+	res = syslogparser.ParseRFC3164([]byte(`<192>Dec 31 23:59:59 host app: msg`), now)
+	require.Error(t, res.Error)
+}
+
+func TestParseRFC3164Stream(t *testing.T) {
+	for name, stream := range map[string]string{
+		"newline":        "<13>Jan  2 09:59:00 host app: First\n\n<13>Jan  2 09:59:01 host app: Second\n",
+		"octet counting": "35 <13>Jan  2 09:59:00 host app: First36 <13>Jan  2 09:59:01 host app: Second",
+	} {
+		var results []*syslog.Result
+		err := syslogparser.ParseRFC3164Stream(strings.NewReader(stream), func(res *syslog.Result) {
+			results = append(results, res)
+		}, defaultMaxMessageLength)
+		require.NoError(t, err, name)
+		require.Len(t, results, 2, name)
+		require.NoError(t, results[0].Error, name)
+		require.Equal(t, "First", *results[0].Message.(*syslog.Base).Message, name)
+		require.NoError(t, results[1].Error, name)
+		require.Equal(t, "Second", *results[1].Message.(*syslog.Base).Message, name)
+	}
+
+	var results []*syslog.Result
+	err := syslogparser.ParseRFC3164Stream(strings.NewReader("8198 <13>host app: First"), func(res *syslog.Result) {
+		results = append(results, res)
+	}, defaultMaxMessageLength)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.EqualError(t, results[0].Error, "message too long to parse. was size 8198, max length 8192")
+}
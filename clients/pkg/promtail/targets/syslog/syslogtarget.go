@@ -1,6 +1,7 @@
 package syslog
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
@@ -26,6 +27,7 @@ import (
 	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/syslog/syslogparser"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+	"github.com/grafana/loki/clients/pkg/promtail/tlspolicy"
 
 	"github.com/grafana/loki/pkg/logproto"
 )
@@ -35,6 +37,18 @@ var (
 	defaultMaxMessageLength = 8192
 )
 
+const (
+	protocolTCP = "tcp"
+	protocolUDP = "udp"
+
+	formatRFC5424 = "rfc5424"
+	formatRFC3164 = "rfc3164"
+
+	// maxCachedConnectionLabels bounds the labels of the UDP senders kept to avoid a reverse
+	// lookup of their address for every message.
+	maxCachedConnectionLabels = 1024
+)
+
 // SyslogTarget listens to syslog messages.
 // nolint:revive
 type SyslogTarget struct {
@@ -44,8 +58,9 @@ type SyslogTarget struct {
 	config        *scrapeconfig.SyslogTargetConfig
 	relabelConfig []*relabel.Config
 
-	listener net.Listener
-	messages chan message
+	listener   net.Listener
+	packetConn net.PacketConn // set instead of listener when listening on udp.
+	messages   chan message
 
 	ctx             context.Context
 	ctxCancel       context.CancelFunc
@@ -66,6 +81,9 @@ func NewSyslogTarget(
 	relabel []*relabel.Config,
 	config *scrapeconfig.SyslogTargetConfig,
 ) (*SyslogTarget, error) {
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -88,7 +106,32 @@ func NewSyslogTarget(
 	return t, err
 }
 
+func validateConfig(config *scrapeconfig.SyslogTargetConfig) error {
+	switch config.ListenProtocol {
+	case "", protocolTCP:
+	case protocolUDP:
+		if config.TLSConfig.CertFile != "" || config.TLSConfig.KeyFile != "" || config.TLSConfig.CAFile != "" {
+			return fmt.Errorf("error setting up syslog target: TLS is not supported with the udp protocol")
+		}
+	default:
+		return fmt.Errorf("error setting up syslog target: unsupported protocol %q, must be tcp or udp", config.ListenProtocol)
+	}
+	switch config.SyslogFormat {
+	case "", formatRFC5424, formatRFC3164:
+	default:
+		return fmt.Errorf("error setting up syslog target: unsupported syslog format %q, must be rfc5424 or rfc3164", config.SyslogFormat)
+	}
+	if (len(config.AllowedClientCNs) > 0 || len(config.AllowedClientSANs) > 0) && config.TLSConfig.CAFile == "" {
+		return fmt.Errorf("error setting up syslog target: allowed_client_cns and allowed_client_sans require a tls_config ca_file to verify the client certificates")
+	}
+	return nil
+}
+
 func (t *SyslogTarget) run() error {
+	if t.config.ListenProtocol == protocolUDP {
+		return t.runUDP()
+	}
+
 	l, err := net.Listen("tcp", t.config.ListenAddress)
 	l = conntrack.NewListener(l, conntrack.TrackWithName("syslog_target/"+t.config.ListenAddress))
 	if err != nil {
@@ -101,6 +144,9 @@ func (t *SyslogTarget) run() error {
 		if err != nil {
 			return fmt.Errorf("error setting up syslog target: %w", err)
 		}
+		if len(t.config.AllowedClientCNs) > 0 || len(t.config.AllowedClientSANs) > 0 {
+			tlsConfig.VerifyPeerCertificate = t.verifyClientCertificate
+		}
 		l = tls.NewListener(l, tlsConfig)
 	}
 
@@ -113,6 +159,20 @@ func (t *SyslogTarget) run() error {
 	return nil
 }
 
+func (t *SyslogTarget) runUDP() error {
+	c, err := net.ListenPacket("udp", t.config.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("error setting up syslog target: %w", err)
+	}
+	t.packetConn = c
+	level.Info(t.logger).Log("msg", "syslog listening on address", "address", t.ListenAddress().String(), "protocol", protocolUDP)
+
+	t.openConnections.Add(1)
+	go t.acceptPackets()
+
+	return nil
+}
+
 func newTLSConfig(certFile string, keyFile string, caFile string) (*tls.Config, error) {
 	if certFile == "" || keyFile == "" {
 		return nil, fmt.Errorf("certificate and key files are required")
@@ -141,10 +201,48 @@ func newTLSConfig(certFile string, keyFile string, caFile string) (*tls.Config,
 		tlsConfig.ClientCAs = caCertPool
 		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
 	}
+	tlspolicy.Apply(tlsConfig)
 
 	return tlsConfig, nil
 }
 
+// verifyClientCertificate rejects the client certificates without an allowed common name
+// or subject alternative name, once they have been verified with the CA.
+func (t *SyslogTarget) verifyClientCertificate(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+		return errors.New("no verified client certificate")
+	}
+	cert := verifiedChains[0][0]
+	for _, cn := range t.config.AllowedClientCNs {
+		if cert.Subject.CommonName == cn {
+			return nil
+		}
+	}
+	for _, san := range certificateSANs(cert) {
+		for _, allowed := range t.config.AllowedClientSANs {
+			if san == allowed {
+				return nil
+			}
+		}
+	}
+	t.metrics.syslogRejectedClients.Inc()
+	return fmt.Errorf("client certificate %q is not allowed", cert.Subject.CommonName)
+}
+
+// certificateSANs returns the subject alternative names of a certificate.
+func certificateSANs(cert *x509.Certificate) []string {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.IPAddresses)+len(cert.URIs))
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
 func (t *SyslogTarget) acceptConnections() {
 	defer t.openConnections.Done()
 
@@ -192,9 +290,33 @@ func (t *SyslogTarget) handleConnection(cn net.Conn) {
 		_ = c.Close()
 	}()
 
-	connLabels := t.connectionLabels(c)
+	var clientCert *x509.Certificate
+	if tlsConn, ok := cn.(*tls.Conn); ok {
+		// the handshake happens before reading messages for the labels of the connection
+		// to hold the identity of the client.
+		_ = tlsConn.SetDeadline(time.Now().Add(t.idleTimeout()))
+		if err := tlsConn.Handshake(); err != nil {
+			level.Warn(t.logger).Log("msg", "TLS handshake failed", "remote_addr", cn.RemoteAddr().String(), "err", err)
+			return
+		}
+		if chains := tlsConn.ConnectionState().VerifiedChains; len(chains) > 0 && len(chains[0]) > 0 {
+			clientCert = chains[0][0]
+		}
+	}
 
-	err := syslogparser.ParseStream(c, func(msg *syslog.Result) {
+	connLabels := t.connectionLabels(c.RemoteAddr())
+	if clientCert != nil {
+		lb := labels.NewBuilder(connLabels)
+		lb.Set("__meta_syslog_tls_client_common_name", clientCert.Subject.CommonName)
+		lb.Set("__meta_syslog_tls_client_sans", strings.Join(certificateSANs(clientCert), ","))
+		connLabels = lb.Labels()
+	}
+
+	parseStream := syslogparser.ParseStream
+	if t.config.SyslogFormat == formatRFC3164 {
+		parseStream = syslogparser.ParseRFC3164Stream
+	}
+	err := parseStream(c, func(msg *syslog.Result) {
 		if err := msg.Error; err != nil {
 			t.handleMessageError(err)
 			return
@@ -207,6 +329,58 @@ func (t *SyslogTarget) handleConnection(cn net.Conn) {
 	}
 }
 
+// acceptPackets handles the messages received over udp, one per datagram.
+func (t *SyslogTarget) acceptPackets() {
+	defer t.openConnections.Done()
+
+	l := log.With(t.logger, "address", t.packetConn.LocalAddr().String())
+
+	var (
+		buf        = make([]byte, t.maxMessageLength())
+		parser     = rfc5424.NewParser()
+		connLabels = map[string]labels.Labels{}
+	)
+	for {
+		n, addr, err := t.packetConn.ReadFrom(buf)
+		if n > 0 {
+			ip := ipFromAddr(addr).String()
+			lbs, ok := connLabels[ip]
+			if !ok {
+				if len(connLabels) >= maxCachedConnectionLabels {
+					connLabels = map[string]labels.Labels{}
+				}
+				lbs = t.connectionLabels(addr)
+				connLabels[ip] = lbs
+			}
+
+			var res *syslog.Result
+			if t.config.SyslogFormat == formatRFC3164 {
+				res = syslogparser.ParseRFC3164(buf[:n], time.Now())
+			} else {
+				msg, err := parser.Parse(bytes.TrimRight(buf[:n], "\r\n"))
+				res = &syslog.Result{Message: msg, Error: err}
+			}
+			if res.Error != nil {
+				t.handleMessageError(res.Error)
+			} else {
+				t.handleMessage(lbs.Copy(), res.Message)
+			}
+		}
+		if err != nil {
+			if t.ctx.Err() != nil {
+				level.Info(l).Log("msg", "syslog server shutting down")
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				level.Warn(l).Log("msg", "failed to read syslog message", "err", err)
+				continue
+			}
+			level.Error(l).Log("msg", "failed to read syslog message. quiting", "err", err)
+			return
+		}
+	}
+}
+
 func (t *SyslogTarget) handleMessageError(err error) {
 	var ne net.Error
 	if errors.As(err, &ne) && ne.Timeout() {
@@ -218,35 +392,46 @@ func (t *SyslogTarget) handleMessageError(err error) {
 }
 
 func (t *SyslogTarget) handleMessage(connLabels labels.Labels, msg syslog.Message) {
-	rfc5424Msg := msg.(*rfc5424.SyslogMessage)
+	var (
+		base           *syslog.Base
+		structuredData *map[string]map[string]string
+	)
+	switch m := msg.(type) {
+	case *rfc5424.SyslogMessage:
+		base, structuredData = &m.Base, m.StructuredData
+	case *syslog.Base:
+		base = m
+	default:
+		return
+	}
 
-	if rfc5424Msg.Message == nil {
+	if base.Message == nil {
 		t.metrics.syslogEmptyMessages.Inc()
 		return
 	}
 
 	lb := labels.NewBuilder(connLabels)
-	if v := rfc5424Msg.SeverityLevel(); v != nil {
+	if v := base.SeverityLevel(); v != nil {
 		lb.Set("__syslog_message_severity", *v)
 	}
-	if v := rfc5424Msg.FacilityLevel(); v != nil {
+	if v := base.FacilityLevel(); v != nil {
 		lb.Set("__syslog_message_facility", *v)
 	}
-	if v := rfc5424Msg.Hostname; v != nil {
+	if v := base.Hostname; v != nil {
 		lb.Set("__syslog_message_hostname", *v)
 	}
-	if v := rfc5424Msg.Appname; v != nil {
+	if v := base.Appname; v != nil {
 		lb.Set("__syslog_message_app_name", *v)
 	}
-	if v := rfc5424Msg.ProcID; v != nil {
+	if v := base.ProcID; v != nil {
 		lb.Set("__syslog_message_proc_id", *v)
 	}
-	if v := rfc5424Msg.MsgID; v != nil {
+	if v := base.MsgID; v != nil {
 		lb.Set("__syslog_message_msg_id", *v)
 	}
 
-	if t.config.LabelStructuredData && rfc5424Msg.StructuredData != nil {
-		for id, params := range *rfc5424Msg.StructuredData {
+	if t.config.LabelStructuredData && structuredData != nil {
+		for id, params := range *structuredData {
 			id = strings.Replace(id, "@", "_", -1)
 			for name, value := range params {
 				key := "__syslog_message_sd_" + id + "_" + name
@@ -266,12 +451,12 @@ func (t *SyslogTarget) handleMessage(connLabels labels.Labels, msg syslog.Messag
 	}
 
 	var timestamp time.Time
-	if t.config.UseIncomingTimestamp && rfc5424Msg.Timestamp != nil {
-		timestamp = *rfc5424Msg.Timestamp
+	if t.config.UseIncomingTimestamp && base.Timestamp != nil {
+		timestamp = *base.Timestamp
 	} else {
 		timestamp = time.Now()
 	}
-	t.messages <- message{filtered, *rfc5424Msg.Message, timestamp}
+	t.messages <- message{filtered, *base.Message, timestamp}
 }
 
 func (t *SyslogTarget) messageSender(entries chan<- api.Entry) {
@@ -287,23 +472,25 @@ func (t *SyslogTarget) messageSender(entries chan<- api.Entry) {
 	}
 }
 
-func (t *SyslogTarget) connectionLabels(c net.Conn) labels.Labels {
+func (t *SyslogTarget) connectionLabels(addr net.Addr) labels.Labels {
 	lb := labels.NewBuilder(nil)
 	for k, v := range t.config.Labels {
 		lb.Set(string(k), string(v))
 	}
 
-	ip := ipFromConn(c).String()
+	ip := ipFromAddr(addr).String()
 	lb.Set("__syslog_connection_ip_address", ip)
 	lb.Set("__syslog_connection_hostname", lookupAddr(ip))
 
 	return lb.Labels()
 }
 
-func ipFromConn(c net.Conn) net.IP {
-	switch addr := c.RemoteAddr().(type) {
+func ipFromAddr(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
 	case *net.TCPAddr:
 		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
 	}
 
 	return nil
@@ -344,7 +531,12 @@ func (t *SyslogTarget) Details() interface{} {
 // Stop shuts down the SyslogTarget.
 func (t *SyslogTarget) Stop() error {
 	t.ctxCancel()
-	err := t.listener.Close()
+	var err error
+	if t.packetConn != nil {
+		err = t.packetConn.Close()
+	} else {
+		err = t.listener.Close()
+	}
 	t.openConnections.Wait()
 	close(t.messages)
 	t.handler.Stop()
@@ -353,6 +545,9 @@ func (t *SyslogTarget) Stop() error {
 
 // ListenAddress returns the address SyslogTarget is listening on.
 func (t *SyslogTarget) ListenAddress() net.Addr {
+	if t.packetConn != nil {
+		return t.packetConn.LocalAddr()
+	}
 	return t.listener.Addr()
 }
 
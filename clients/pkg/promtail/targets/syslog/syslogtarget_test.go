@@ -1,11 +1,17 @@
 package syslog
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"net"
 	"os"
 	"testing"
@@ -13,6 +19,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	promconfig "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/relabel"
@@ -291,6 +298,111 @@ func testSyslogTarget(t *testing.T, octetCounting bool) {
 	require.NotZero(t, client.Received()[0].Timestamp)
 }
 
+func TestSyslogTarget_UDP(t *testing.T) {
+	for _, format := range []string{"", "rfc3164"} {
+		format := format
+		t.Run("format="+format, func(t *testing.T) {
+			client := fake.New(func() {})
+			tgt, err := NewSyslogTarget(NewMetrics(nil), log.NewNopLogger(), client, relabelConfig(t), &scrapeconfig.SyslogTargetConfig{
+				ListenAddress:  "127.0.0.1:0",
+				ListenProtocol: "udp",
+				SyslogFormat:   format,
+				Labels:         model.LabelSet{"test": "syslog_target"},
+			})
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, tgt.Stop())
+			}()
+
+			c, err := net.Dial("udp", tgt.ListenAddress().String())
+			require.NoError(t, err)
+			defer c.Close()
+
+			messages := []string{
+				`<165>1 2018-10-11T22:14:15.003Z host5 e - id1 - An application event log entry...`,
+				`<165>1 2018-10-11T22:14:15.005Z host5 e - id2 - An application event log entry...` + "\n",
+			}
+			if format == "rfc3164" {
+				messages = []string{
+					`<165>Oct 11 22:14:15 host5 e[42]: An application event log entry...`,
+					`<165>Oct 11 22:14:15 host5 e[42]: An application event log entry...` + "\n",
+				}
+			}
+			for _, msg := range messages {
+				_, err := c.Write([]byte(msg))
+				require.NoError(t, err)
+			}
+
+			require.Eventuallyf(t, func() bool {
+				return len(client.Received()) == len(messages)
+			}, time.Second, time.Millisecond, "Expected to receive %d messages, got %d.", len(messages), len(client.Received()))
+
+			for _, entry := range client.Received() {
+				require.Equal(t, "syslog_target", string(entry.Labels["test"]))
+				require.Equal(t, "notice", string(entry.Labels["severity"]))
+				require.Equal(t, "local4", string(entry.Labels["facility"]))
+				require.Equal(t, "host5", string(entry.Labels["hostname"]))
+				require.Equal(t, "e", string(entry.Labels["app_name"]))
+				require.Equal(t, "An application event log entry...", entry.Line)
+			}
+		})
+	}
+}
+
+func TestSyslogTarget_RFC3164(t *testing.T) {
+	client := fake.New(func() {})
+	tgt, err := NewSyslogTarget(NewMetrics(nil), log.NewNopLogger(), client, relabelConfig(t), &scrapeconfig.SyslogTargetConfig{
+		ListenAddress:        "127.0.0.1:0",
+		SyslogFormat:         "rfc3164",
+		UseIncomingTimestamp: true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, tgt.Stop())
+	}()
+
+	c, err := net.Dial("tcp", tgt.ListenAddress().String())
+	require.NoError(t, err)
+	require.NoError(t, writeMessagesToStream(c, []string{
+		`<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8`,
+		`<13>2021-10-11T22:14:15.003Z router-1 %LINK-3-UPDOWN: Interface Gi0/1, changed state to down`,
+	}, false))
+	require.NoError(t, c.Close())
+
+	require.Eventuallyf(t, func() bool {
+		return len(client.Received()) == 2
+	}, time.Second, time.Millisecond, "Expected to receive 2 messages, got %d.", len(client.Received()))
+
+	require.Equal(t, model.LabelSet{
+		"severity": "critical",
+		"facility": "auth",
+		"hostname": "mymachine",
+		"app_name": "su",
+	}, client.Received()[0].Labels)
+	require.Equal(t, "'su root' failed for lonvick on /dev/pts/8", client.Received()[0].Line)
+	require.Equal(t, time.Month(10), client.Received()[0].Timestamp.Month())
+
+	require.Equal(t, model.LabelSet{
+		"severity": "notice",
+		"facility": "user",
+		"hostname": "router-1",
+	}, client.Received()[1].Labels)
+	require.Equal(t, "%LINK-3-UPDOWN: Interface Gi0/1, changed state to down", client.Received()[1].Line)
+	require.Equal(t, time.Date(2021, 10, 11, 22, 14, 15, 3000000, time.UTC), client.Received()[1].Timestamp.UTC())
+}
+
+func TestSyslogTarget_InvalidConfig(t *testing.T) {
+	for name, cfg := range map[string]*scrapeconfig.SyslogTargetConfig{
+		"protocol":                   {ListenAddress: "127.0.0.1:0", ListenProtocol: "sctp"},
+		"format":                     {ListenAddress: "127.0.0.1:0", SyslogFormat: "rfc3339"},
+		"udp tls":                    {ListenAddress: "127.0.0.1:0", ListenProtocol: "udp", TLSConfig: promconfig.TLSConfig{CertFile: "cert.pem"}},
+		"allowed clients without ca": {ListenAddress: "127.0.0.1:0", AllowedClientCNs: []string{"router-1"}},
+	} {
+		_, err := NewSyslogTarget(NewMetrics(nil), log.NewNopLogger(), fake.New(func() {}), nil, cfg)
+		require.Error(t, err, name)
+	}
+}
+
 func relabelConfig(t *testing.T) []*relabel.Config {
 	relabelCfg := `
 - source_labels: ['__syslog_message_severity']
@@ -694,3 +806,129 @@ func TestSyslogTarget_IdleTimeout(t *testing.T) {
 	_, err = c.Read(buf)
 	require.EqualError(t, err, "EOF")
 }
+
+// testCertificate is a certificate signed by the test CA, written to files.
+type testCertificate struct {
+	certFile, keyFile string
+	cert              tls.Certificate
+}
+
+func generateTestCertificate(t *testing.T, dir string, template *x509.Certificate, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (testCertificate, *x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if ca == nil {
+		ca, caKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	out := testCertificate{
+		certFile: fmt.Sprintf("%s/%s.crt", dir, template.Subject.CommonName),
+		keyFile:  fmt.Sprintf("%s/%s.key", dir, template.Subject.CommonName),
+	}
+	require.NoError(t, ioutil.WriteFile(out.certFile, certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(out.keyFile, keyPEM, 0600))
+	out.cert, err = tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	return out, cert, key
+}
+
+func TestSyslogTarget_TLSAllowedClients(t *testing.T) {
+	dir := t.TempDir()
+	caFiles, ca, caKey := generateTestCertificate(t, dir, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Promtail Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	server, _, _ := generateTestCertificate(t, dir, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "promtail.example.com"},
+		DNSNames:    []string{"promtail.example.com"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	clientCert := func(cn string, dnsNames ...string) tls.Certificate {
+		c, _, _ := generateTestCertificate(t, dir, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: cn},
+			DNSNames:    dnsNames,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, ca, caKey)
+		return c.cert
+	}
+
+	relabelCfg := `
+- source_labels: ['__meta_syslog_tls_client_common_name']
+  target_label: 'client'
+- source_labels: ['__meta_syslog_tls_client_sans']
+  target_label: 'client_sans'
+`
+	var relabels []*relabel.Config
+	require.NoError(t, yaml.Unmarshal([]byte(relabelCfg), &relabels))
+
+	client := fake.New(func() {})
+	metrics := NewMetrics(nil)
+	tgt, err := NewSyslogTarget(metrics, log.NewNopLogger(), client, relabels, &scrapeconfig.SyslogTargetConfig{
+		ListenAddress: "127.0.0.1:0",
+		TLSConfig: promconfig.TLSConfig{
+			CAFile:   caFiles.certFile,
+			CertFile: server.certFile,
+			KeyFile:  server.keyFile,
+		},
+		AllowedClientCNs:  []string{"router-1"},
+		AllowedClientSANs: []string{"firewall.example.com"},
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, tgt.Stop())
+	}()
+
+	caPool := x509.NewCertPool()
+	caPool.AddCert(ca)
+	send := func(cert tls.Certificate, msg string) error {
+		c, err := tls.Dial("tcp", tgt.ListenAddress().String(), &tls.Config{
+			RootCAs:      caPool,
+			ServerName:   "promtail.example.com",
+			Certificates: []tls.Certificate{cert},
+		})
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		if err := writeMessagesToStream(c, []string{msg}, true); err != nil {
+			return err
+		}
+		// the server closes the connection when the certificate is rejected.
+		require.NoError(t, c.SetReadDeadline(time.Now().Add(200*time.Millisecond)))
+		_, err = c.Read(make([]byte, 1))
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil
+		}
+		return err
+	}
+
+	require.NoError(t, send(clientCert("router-1"), `<165>1 2018-10-11T22:14:15.003Z host5 e - id1 - allowed by common name`))
+	require.NoError(t, send(clientCert("fw-a", "firewall.example.com"), `<165>1 2018-10-11T22:14:15.003Z host5 e - id2 - allowed by SAN`))
+	require.Error(t, send(clientCert("rogue", "rogue.example.com"), `<165>1 2018-10-11T22:14:15.003Z host5 e - id3 - rejected`))
+
+	require.Eventually(t, func() bool {
+		return len(client.Received()) == 2
+	}, time.Second, 10*time.Millisecond)
+	received := map[string]model.LabelSet{}
+	for _, e := range client.Received() {
+		received[e.Line] = e.Labels
+	}
+	require.Equal(t, map[string]model.LabelSet{
+		"allowed by common name": {"client": "router-1"},
+		"allowed by SAN":         {"client": "fw-a", "client_sans": "firewall.example.com"},
+	}, received)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.syslogRejectedClients))
+}
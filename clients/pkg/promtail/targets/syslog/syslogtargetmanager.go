@@ -8,6 +8,7 @@ import (
 	"github.com/grafana/loki/clients/pkg/logentry/stages"
 	"github.com/grafana/loki/clients/pkg/promtail/api"
 	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/diskbuffer"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
 )
 
@@ -16,6 +17,7 @@ import (
 type SyslogTargetManager struct {
 	logger  log.Logger
 	targets map[string]*SyslogTarget
+	buffers map[string]*diskbuffer.Buffer
 }
 
 // NewSyslogTargetManager creates a new SyslogTargetManager.
@@ -33,15 +35,30 @@ func NewSyslogTargetManager(
 	tm := &SyslogTargetManager{
 		logger:  logger,
 		targets: make(map[string]*SyslogTarget),
+		buffers: make(map[string]*diskbuffer.Buffer),
 	}
 
+	var bufferMetrics *diskbuffer.Metrics
 	for _, cfg := range scrapeConfigs {
 		pipeline, err := stages.NewPipeline(log.With(logger, "component", "syslog_pipeline"), cfg.PipelineStages, &cfg.JobName, reg)
 		if err != nil {
 			return nil, err
 		}
 
-		t, err := NewSyslogTarget(metrics, logger, pipeline.Wrap(client), cfg.RelabelConfigs, cfg.SyslogConfig)
+		next := client
+		if cfg.SyslogConfig.DiskBuffer != nil {
+			if bufferMetrics == nil {
+				bufferMetrics = diskbuffer.NewMetrics(reg)
+			}
+			buffer, err := diskbuffer.New(*cfg.SyslogConfig.DiskBuffer, cfg.JobName, client, bufferMetrics, logger)
+			if err != nil {
+				return nil, err
+			}
+			tm.buffers[cfg.JobName] = buffer
+			next = buffer
+		}
+
+		t, err := NewSyslogTarget(metrics, logger, pipeline.Wrap(next), cfg.RelabelConfigs, cfg.SyslogConfig)
 		if err != nil {
 			return nil, err
 		}
@@ -69,6 +86,9 @@ func (tm *SyslogTargetManager) Stop() {
 			level.Error(t.logger).Log("msg", "error stopping SyslogTarget", "err", err.Error())
 		}
 	}
+	for _, b := range tm.buffers {
+		b.Stop()
+	}
 }
 
 // ActiveTargets returns the list of SyslogTargets where syslog data
@@ -35,6 +35,24 @@ const (
 
 	// GelfTargetType is a gelf target
 	GelfTargetType = TargetType("gelf")
+
+	// KinesisTargetType is a Kinesis shard target
+	KinesisTargetType = TargetType("Kinesis")
+
+	// S3TargetType is an S3 objects target
+	S3TargetType = TargetType("S3")
+
+	// MQTTTargetType is an MQTT subscriber target
+	MQTTTargetType = TargetType("MQTT")
+
+	// AMQPTargetType is an AMQP queue consumer target
+	AMQPTargetType = TargetType("AMQP")
+
+	// DockerTargetType is a Docker container read through the Docker Engine API
+	DockerTargetType = TargetType("Docker")
+
+	// KubernetesEventsTargetType is a Kubernetes cluster whose events are watched
+	KubernetesEventsTargetType = TargetType("KubernetesEvents")
 )
 
 // Target is a promtail scrape target
@@ -51,6 +69,13 @@ type Target interface {
 	Details() interface{}
 }
 
+// LagTarget is implemented by the targets knowing how far behind the end of their sources they
+// are reading.
+type LagTarget interface {
+	// Lag returns the number of bytes not read yet per source.
+	Lag() map[string]int64
+}
+
 // IsDropped tells if a target has been dropped
 func IsDropped(t Target) bool {
 	return t.Type() == DroppedTargetType
@@ -6,9 +6,11 @@ package windows
 import (
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/spf13/afero"
 
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/windows/win_eventlog"
 )
 
@@ -17,6 +19,10 @@ type bookMark struct {
 	file   afero.File
 	isNew  bool
 
+	// positions and key are set instead of file when the bookmark is saved in the positions file.
+	positions positions.Positions
+	key       string
+
 	buf []byte
 }
 
@@ -70,12 +76,37 @@ func newBookMark(path string) (*bookMark, error) {
 	}, nil
 }
 
+// newPositionsBookMark creates a windows event bookmark saved in the positions file under the given key.
+func newPositionsBookMark(ps positions.Positions, key string) (*bookMark, error) {
+	saved := ps.GetString(key)
+	if saved != "" {
+		// the rendered bookmark ends with a null character which isn't saved in the positions file.
+		saved += "\x00"
+	}
+	bm, err := win_eventlog.CreateBookmark(saved)
+	if err != nil {
+		return nil, err
+	}
+	return &bookMark{
+		handle:    bm,
+		isNew:     saved == "",
+		positions: ps,
+		key:       key,
+		buf:       make([]byte, 16<<10),
+	}, nil
+}
+
 // save Saves the bookmark at the current event position.
 func (b *bookMark) save(event win_eventlog.EvtHandle) error {
 	newBookmark, err := win_eventlog.UpdateBookmark(b.handle, event, b.buf)
 	if err != nil {
 		return err
 	}
+	b.isNew = false
+	if b.file == nil {
+		b.positions.PutString(b.key, strings.TrimRight(newBookmark, "\x00"))
+		return nil
+	}
 	if err := b.file.Truncate(0); err != nil {
 		return err
 	}
@@ -88,5 +119,8 @@ func (b *bookMark) save(event win_eventlog.EvtHandle) error {
 
 // close closes the current bookmark file.
 func (b *bookMark) close() error {
+	if b.file == nil {
+		return nil
+	}
 	return b.file.Close()
 }
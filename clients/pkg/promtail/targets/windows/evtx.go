@@ -0,0 +1,145 @@
+//go:build windows
+// +build windows
+
+package windows
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/windows/win_eventlog"
+)
+
+// evtxReplay replays the events of archived evtx files. The record id of the last event
+// read from each file is saved in the positions file, so that files are not replayed twice.
+type evtxReplay struct {
+	positions positions.Positions
+	patterns  []string
+	fetcher   *win_eventlog.EventFetcher
+
+	// replayed holds the files read until their end since the target started.
+	replayed map[string]struct{}
+}
+
+func newEvtxReplay(positions positions.Positions, patterns []string) (*evtxReplay, error) {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid evtx_files pattern %q: %w", pattern, err)
+		}
+	}
+	return &evtxReplay{
+		positions: positions,
+		patterns:  patterns,
+		fetcher:   win_eventlog.NewEventFetcher(),
+		replayed:  map[string]struct{}{},
+	}, nil
+}
+
+// evtxPositionKey returns the key of the position of an evtx file in the positions file.
+func evtxPositionKey(path string) string {
+	return "windows-evtx-" + path
+}
+
+// files returns the files matching the patterns that have not been replayed yet, sorted by name.
+func (r *evtxReplay) files() ([]string, error) {
+	seen := map[string]struct{}{}
+	var files []string
+	for _, pattern := range r.patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			path, err := filepath.Abs(match)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := r.replayed[path]; ok {
+				continue
+			}
+			if _, ok := seen[path]; ok {
+				continue
+			}
+			seen[path] = struct{}{}
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// replayEvtx replays the evtx files which have not been replayed yet.
+func (t *Target) replayEvtx() {
+	files, err := t.evtx.files()
+	if err != nil {
+		t.err = err
+		level.Error(util_log.Logger).Log("msg", "error listing evtx files", "err", err)
+		return
+	}
+	for _, path := range files {
+		if t.stopping() {
+			return
+		}
+		if err := t.replayFile(path); err != nil {
+			t.err = err
+			level.Error(util_log.Logger).Log("msg", "error replaying evtx file", "path", path, "err", err)
+			continue
+		}
+		t.evtx.replayed[path] = struct{}{}
+	}
+}
+
+// replayFile sends the events of an evtx file newer than its saved position.
+func (t *Target) replayFile(path string) error {
+	key := evtxPositionKey(path)
+	last, err := t.evtx.positions.Get(key)
+	if err != nil {
+		return err
+	}
+	handle, err := win_eventlog.EvtQueryFile(path, t.cfg.Query)
+	if err != nil {
+		return err
+	}
+	defer win_eventlog.Close([]win_eventlog.EvtHandle{handle})
+
+	for !t.stopping() {
+		events, handles, err := t.evtx.fetcher.FetchEvents(handle, t.cfg.Locale)
+		if err == win_eventlog.ERROR_NO_MORE_ITEMS {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		win_eventlog.Close(handles)
+
+		newEvents := events[:0]
+		for _, event := range events {
+			if int64(event.EventRecordID) > last {
+				newEvents = append(newEvents, event)
+			}
+		}
+		if len(newEvents) == 0 {
+			continue
+		}
+		for _, entry := range t.renderEntries(newEvents) {
+			t.handler.Chan() <- entry
+		}
+		last = int64(newEvents[len(newEvents)-1].EventRecordID)
+		t.evtx.positions.Put(key, last)
+	}
+	return nil
+}
+
+func (t *Target) stopping() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
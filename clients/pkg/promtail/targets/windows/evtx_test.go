@@ -0,0 +1,57 @@
+//go:build windows
+// +build windows
+
+package windows
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+)
+
+func Test_evtxReplayFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"security-2.evtx", "security-1.evtx", "system-1.evtx", "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0600))
+	}
+	ps, err := positions.New(log.NewNopLogger(), positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: filepath.Join(dir, "positions.yml"),
+	})
+	require.NoError(t, err)
+	defer ps.Stop()
+
+	_, err = newEvtxReplay(ps, []string{filepath.Join(dir, "[")})
+	require.Error(t, err)
+
+	r, err := newEvtxReplay(ps, []string{filepath.Join(dir, "security-*.evtx"), filepath.Join(dir, "*.evtx")})
+	require.NoError(t, err)
+	files, err := r.files()
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "security-1.evtx"),
+		filepath.Join(dir, "security-2.evtx"),
+		filepath.Join(dir, "system-1.evtx"),
+	}, files)
+
+	// replayed files are not returned again.
+	r.replayed[filepath.Join(dir, "security-1.evtx")] = struct{}{}
+	files, err = r.files()
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "security-2.evtx"), filepath.Join(dir, "system-1.evtx")}, files)
+}
+
+func Test_rpcLoginFlag(t *testing.T) {
+	for _, auth := range []string{"", "default", "negotiate", "Kerberos", "ntlm"} {
+		_, err := rpcLoginFlag(auth)
+		require.NoError(t, err, auth)
+	}
+	_, err := rpcLoginFlag("basic")
+	require.Error(t, err)
+}
@@ -4,6 +4,7 @@
 package windows
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -15,11 +16,11 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/relabel"
 	"github.com/spf13/afero"
-	"golang.org/x/sys/windows"
 
 	"github.com/prometheus/prometheus/pkg/labels"
 
 	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
 	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/windows/win_eventlog"
@@ -28,14 +29,13 @@ import (
 var fs = afero.NewOsFs()
 
 type Target struct {
-	subscription  win_eventlog.EvtHandle
 	handler       api.EntryHandler
 	cfg           *scrapeconfig.WindowsEventsTargetConfig
 	relabelConfig []*relabel.Config
 	logger        log.Logger
 
-	bm      *bookMark // bookmark to save positions.
-	fetcher *win_eventlog.EventFetcher
+	subscriptions []*subscription
+	evtx          *evtxReplay // set instead of subscriptions when replaying evtx files.
 
 	ready bool
 	done  chan struct{}
@@ -43,49 +43,54 @@ type Target struct {
 	err   error
 }
 
+// subscription is a subscription to the event log of the local or of a remote computer.
+type subscription struct {
+	server  string                 // the remote computer, empty for the local computer.
+	session win_eventlog.EvtHandle // the session to the remote computer.
+	handle  win_eventlog.EvtHandle
+
+	bm      *bookMark // bookmark to save positions.
+	fetcher *win_eventlog.EventFetcher
+}
+
 // New create a new windows targets, that will fetch windows event logs and send them to Loki.
 func New(
 	logger log.Logger,
 	handler api.EntryHandler,
+	positions positions.Positions,
+	jobName string,
 	relabel []*relabel.Config,
 	cfg *scrapeconfig.WindowsEventsTargetConfig,
 ) (*Target, error) {
-	sigEvent, err := windows.CreateEvent(nil, 0, 0, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer windows.CloseHandle(sigEvent)
-
-	bm, err := newBookMark(cfg.BookmarkPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create bookmark using path=%s: %w", cfg.BookmarkPath, err)
+	if len(cfg.EvtxFiles) > 0 && cfg.Remote != nil {
+		return nil, errors.New("evtx_files and remote can't be used together")
 	}
 
 	t := &Target{
 		done:          make(chan struct{}),
 		cfg:           cfg,
-		bm:            bm,
 		relabelConfig: relabel,
 		logger:        logger,
 		handler:       handler,
-		fetcher:       win_eventlog.NewEventFetcher(),
 	}
 
 	if cfg.Query == "" {
 		cfg.Query = "*"
 	}
 
-	var subsHandle win_eventlog.EvtHandle
-	if bm.isNew {
-		subsHandle, err = win_eventlog.EvtSubscribe(cfg.EventlogName, cfg.Query)
-	} else {
-		subsHandle, err = win_eventlog.EvtSubscribeWithBookmark(cfg.EventlogName, cfg.Query, bm.handle)
+	var err error
+	switch {
+	case len(cfg.EvtxFiles) > 0:
+		t.evtx, err = newEvtxReplay(positions, cfg.EvtxFiles)
+	case cfg.Remote != nil:
+		err = t.subscribeRemote(positions, jobName)
+	default:
+		err = t.subscribeLocal()
 	}
-
 	if err != nil {
-		return nil, fmt.Errorf("error subscribing to windows events: %w", err)
+		t.closeSubscriptions()
+		return nil, err
 	}
-	t.subscription = subsHandle
 
 	if t.cfg.PollInterval == 0 {
 		t.cfg.PollInterval = 3 * time.Second
@@ -94,6 +99,83 @@ func New(
 	return t, nil
 }
 
+// subscribeLocal subscribes to the event log of the local computer, the bookmark is saved in the bookmark file.
+func (t *Target) subscribeLocal() error {
+	bm, err := newBookMark(t.cfg.BookmarkPath)
+	if err != nil {
+		return fmt.Errorf("failed to create bookmark using path=%s: %w", t.cfg.BookmarkPath, err)
+	}
+	s := &subscription{bm: bm, fetcher: win_eventlog.NewEventFetcher()}
+	t.subscriptions = append(t.subscriptions, s)
+	if err := s.subscribe(t.cfg); err != nil {
+		return fmt.Errorf("error subscribing to windows events: %w", err)
+	}
+	return nil
+}
+
+// subscribeRemote subscribes to the event logs of the remote computers, their bookmarks are saved in the positions file.
+func (t *Target) subscribeRemote(positions positions.Positions, jobName string) error {
+	remote := t.cfg.Remote
+	if len(remote.Servers) == 0 {
+		return errors.New("at least one remote server is required")
+	}
+	auth, err := rpcLoginFlag(remote.Authentication)
+	if err != nil {
+		return err
+	}
+	for _, server := range remote.Servers {
+		session, err := win_eventlog.EvtOpenRemoteSession(server, remote.Domain, remote.Username, remote.Password.Value, auth)
+		if err != nil {
+			return fmt.Errorf("error opening a session to %s: %w", server, err)
+		}
+		s := &subscription{server: server, session: session, fetcher: win_eventlog.NewSessionEventFetcher(session)}
+		t.subscriptions = append(t.subscriptions, s)
+		if s.bm, err = newPositionsBookMark(positions, remotePositionKey(jobName, server)); err != nil {
+			return fmt.Errorf("failed to create bookmark for %s: %w", server, err)
+		}
+		if err := s.subscribe(t.cfg); err != nil {
+			return fmt.Errorf("error subscribing to windows events of %s: %w", server, err)
+		}
+	}
+	return nil
+}
+
+// remotePositionKey returns the key of the bookmark of a remote computer in the positions file.
+func remotePositionKey(jobName, server string) string {
+	return fmt.Sprintf("windows-events-%s-%s", jobName, server)
+}
+
+func rpcLoginFlag(authentication string) (win_eventlog.EvtRPCLoginFlag, error) {
+	switch strings.ToLower(authentication) {
+	case "", "default":
+		return win_eventlog.EvtRPCLoginAuthDefault, nil
+	case "negotiate":
+		return win_eventlog.EvtRPCLoginAuthNegotiate, nil
+	case "kerberos":
+		return win_eventlog.EvtRPCLoginAuthKerberos, nil
+	case "ntlm":
+		return win_eventlog.EvtRPCLoginAuthNTLM, nil
+	default:
+		return 0, fmt.Errorf("unknown remote authentication %q, must be default, negotiate, kerberos or ntlm", authentication)
+	}
+}
+
+// subscribe subscribes to the events after the bookmark, or to future events when the bookmark is new.
+func (s *subscription) subscribe(cfg *scrapeconfig.WindowsEventsTargetConfig) error {
+	var err error
+	switch {
+	case s.session != 0 && s.bm.isNew:
+		s.handle, err = win_eventlog.EvtSubscribeSession(s.session, cfg.EventlogName, cfg.Query, 0)
+	case s.session != 0:
+		s.handle, err = win_eventlog.EvtSubscribeSession(s.session, cfg.EventlogName, cfg.Query, s.bm.handle)
+	case s.bm.isNew:
+		s.handle, err = win_eventlog.EvtSubscribe(cfg.EventlogName, cfg.Query)
+	default:
+		s.handle, err = win_eventlog.EvtSubscribeWithBookmark(cfg.EventlogName, cfg.Query, s.bm.handle)
+	}
+	return err
+}
+
 // loop fetches new events and send them to via the Loki client.
 func (t *Target) loop() {
 	t.ready = true
@@ -106,29 +188,11 @@ func (t *Target) loop() {
 	}()
 
 	for {
-
-	loop:
-		for {
-			// fetch events until there's no more.
-			events, handles, err := t.fetcher.FetchEvents(t.subscription, t.cfg.Locale)
-			if err != nil {
-				if err != win_eventlog.ERROR_NO_MORE_ITEMS {
-					t.err = err
-					level.Error(util_log.Logger).Log("msg", "error fetching events", "err", err)
-				}
-				break loop
-			}
-			t.err = nil
-			// we have received events to handle.
-			for i, entry := range t.renderEntries(events) {
-				t.handler.Chan() <- entry
-				if err := t.bm.save(handles[i]); err != nil {
-					t.err = err
-					level.Error(util_log.Logger).Log("msg", "error saving bookmark", "err", err)
-				}
-			}
-			win_eventlog.Close(handles)
-
+		if t.evtx != nil {
+			t.replayEvtx()
+		}
+		for _, s := range t.subscriptions {
+			t.fetch(s)
 		}
 		// no more messages we wait for next poll timer tick.
 		select {
@@ -139,6 +203,42 @@ func (t *Target) loop() {
 	}
 }
 
+// fetch fetches the events of a subscription until there's no more.
+func (t *Target) fetch(s *subscription) {
+	if s.handle == 0 {
+		// the connection to the remote computer was lost, subscribe again after the bookmark.
+		if err := s.subscribe(t.cfg); err != nil {
+			t.err = err
+			level.Error(util_log.Logger).Log("msg", "error subscribing to windows events", "server", s.server, "err", err)
+			return
+		}
+	}
+	for {
+		events, handles, err := s.fetcher.FetchEvents(s.handle, t.cfg.Locale)
+		if err != nil {
+			if err != win_eventlog.ERROR_NO_MORE_ITEMS {
+				t.err = err
+				level.Error(util_log.Logger).Log("msg", "error fetching events", "server", s.server, "err", err)
+				if s.session != 0 {
+					_ = win_eventlog.Close([]win_eventlog.EvtHandle{s.handle})
+					s.handle = 0
+				}
+			}
+			return
+		}
+		t.err = nil
+		// we have received events to handle.
+		for i, entry := range t.renderEntries(events) {
+			t.handler.Chan() <- entry
+			if err := s.bm.save(handles[i]); err != nil {
+				t.err = err
+				level.Error(util_log.Logger).Log("msg", "error saving bookmark", "err", err)
+			}
+		}
+		win_eventlog.Close(handles)
+	}
+}
+
 // renderEntries renders Loki entries from windows event logs
 func (t *Target) renderEntries(events []win_eventlog.Event) []api.Entry {
 	res := make([]api.Entry, 0, len(events))
@@ -226,8 +326,27 @@ func (t *Target) Stop() error {
 	close(t.done)
 	t.wg.Wait()
 	t.handler.Stop()
-	if err := t.bm.close(); err != nil {
+	if err := t.closeSubscriptions(); err != nil {
 		return err
 	}
 	return t.err
 }
+
+// closeSubscriptions closes the subscriptions, their sessions and bookmark files.
+func (t *Target) closeSubscriptions() error {
+	var firstErr error
+	for _, s := range t.subscriptions {
+		for _, h := range []win_eventlog.EvtHandle{s.handle, s.session} {
+			if h != 0 {
+				_ = win_eventlog.Close([]win_eventlog.EvtHandle{h})
+			}
+		}
+		if s.bm == nil {
+			continue
+		}
+		if err := s.bm.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/grafana/loki/clients/pkg/logentry/stages"
 	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
 	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
 )
@@ -24,6 +25,7 @@ type TargetManager struct {
 func NewTargetManager(
 	reg prometheus.Registerer,
 	logger log.Logger,
+	positions positions.Positions,
 	client api.EntryHandler,
 	scrapeConfigs []scrapeconfig.Config,
 ) (*TargetManager, error) {
@@ -38,7 +40,7 @@ func NewTargetManager(
 			return nil, err
 		}
 
-		t, err := New(logger, pipeline.Wrap(client), cfg.RelabelConfigs, cfg.WindowsConfig)
+		t, err := New(logger, pipeline.Wrap(client), positions, cfg.JobName, cfg.RelabelConfigs, cfg.WindowsConfig)
 		if err != nil {
 			return nil, err
 		}
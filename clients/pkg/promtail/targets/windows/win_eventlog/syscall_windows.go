@@ -50,6 +50,41 @@ const (
 	EvtSubscribeStartAfterBookmark EvtSubscribeFlag = 3
 )
 
+// EvtQueryFlag defines the possible values that specify where and in which order events are queried.
+type EvtQueryFlag uint32
+
+// EVT_QUERY_FLAGS enumeration
+// https://docs.microsoft.com/en-us/windows/win32/api/winevt/ne-winevt-evt_query_flags
+const (
+	EvtQueryFilePath         EvtQueryFlag = 0x2
+	EvtQueryForwardDirection EvtQueryFlag = 0x100
+)
+
+// EvtRPCLoginFlag defines the authentication method used to connect to a remote computer.
+type EvtRPCLoginFlag uint32
+
+// EVT_RPC_LOGIN_FLAGS enumeration
+// https://docs.microsoft.com/en-us/windows/win32/api/winevt/ne-winevt-evt_rpc_login_flags
+const (
+	EvtRPCLoginAuthDefault   EvtRPCLoginFlag = 0
+	EvtRPCLoginAuthNegotiate EvtRPCLoginFlag = 1
+	EvtRPCLoginAuthKerberos  EvtRPCLoginFlag = 2
+	EvtRPCLoginAuthNTLM      EvtRPCLoginFlag = 3
+)
+
+// evtRPCLogin is the login class to connect to a remote computer.
+const evtRPCLogin = 1
+
+// evtRPCLoginInfo mirrors the EVT_RPC_LOGIN structure.
+// https://docs.microsoft.com/en-us/windows/win32/api/winevt/ns-winevt-evt_rpc_login
+type evtRPCLoginInfo struct {
+	Server   *uint16
+	User     *uint16
+	Domain   *uint16
+	Password *uint16
+	Flags    EvtRPCLoginFlag
+}
+
 // EvtRenderFlag uint32
 type EvtRenderFlag uint32
 
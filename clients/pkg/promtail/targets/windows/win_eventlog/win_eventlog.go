@@ -343,6 +343,23 @@ func (w *WinEventLog) shouldExcludeEmptyField(field string, fieldType string, fi
 }
 
 func EvtSubscribe(logName, xquery string) (EvtHandle, error) {
+	return evtSubscribe(0, logName, xquery, 0, EvtSubscribeToFutureEvents)
+}
+
+func EvtSubscribeWithBookmark(logName, xquery string, bookMark EvtHandle) (EvtHandle, error) {
+	return evtSubscribe(0, logName, xquery, bookMark, EvtSubscribeStartAfterBookmark)
+}
+
+// EvtSubscribeSession subscribes to the events of the computer of a session opened with
+// EvtOpenRemoteSession, after the bookmark if it is not 0 or to future events otherwise.
+func EvtSubscribeSession(session EvtHandle, logName, xquery string, bookMark EvtHandle) (EvtHandle, error) {
+	if bookMark == 0 {
+		return evtSubscribe(session, logName, xquery, 0, EvtSubscribeToFutureEvents)
+	}
+	return evtSubscribe(session, logName, xquery, bookMark, EvtSubscribeStartAfterBookmark)
+}
+
+func evtSubscribe(session EvtHandle, logName, xquery string, bookMark EvtHandle, flags EvtSubscribeFlag) (EvtHandle, error) {
 	var logNamePtr, xqueryPtr *uint16
 
 	sigEvent, err := windows.CreateEvent(nil, 0, 0, nil)
@@ -361,8 +378,8 @@ func EvtSubscribe(logName, xquery string) (EvtHandle, error) {
 		return 0, err
 	}
 
-	subsHandle, err := _EvtSubscribe(0, uintptr(sigEvent), logNamePtr, xqueryPtr,
-		0, 0, 0, EvtSubscribeToFutureEvents)
+	subsHandle, err := _EvtSubscribe(session, uintptr(sigEvent), logNamePtr, xqueryPtr,
+		bookMark, 0, 0, flags)
 	if err != nil {
 		return 0, err
 	}
@@ -371,33 +388,42 @@ func EvtSubscribe(logName, xquery string) (EvtHandle, error) {
 	return subsHandle, nil
 }
 
-func EvtSubscribeWithBookmark(logName, xquery string, bookMark EvtHandle) (EvtHandle, error) {
-	var logNamePtr, xqueryPtr *uint16
-
-	sigEvent, err := windows.CreateEvent(nil, 0, 0, nil)
-	if err != nil {
-		return 0, err
-	}
-	defer windows.CloseHandle(sigEvent)
-
-	logNamePtr, err = syscall.UTF16PtrFromString(logName)
+// EvtQueryFile queries the events of an exported event log file, such as an .evtx file,
+// oldest first. The query must be an XPath query, structured XML queries aren't supported.
+func EvtQueryFile(path, xquery string) (EvtHandle, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
 	if err != nil {
 		return 0, err
 	}
-
-	xqueryPtr, err = syscall.UTF16PtrFromString(xquery)
+	xqueryPtr, err := syscall.UTF16PtrFromString(xquery)
 	if err != nil {
 		return 0, err
 	}
+	return _EvtQuery(0, pathPtr, xqueryPtr, EvtQueryFilePath|EvtQueryForwardDirection)
+}
 
-	subsHandle, err := _EvtSubscribe(0, uintptr(sigEvent), logNamePtr, xqueryPtr,
-		bookMark, 0, 0, EvtSubscribeStartAfterBookmark)
-	if err != nil {
-		return 0, err
+// EvtOpenRemoteSession opens a session to the event logs of a remote computer. The domain, user
+// and password can be empty to connect with the credentials of the current user.
+func EvtOpenRemoteSession(server, domain, user, password string, flags EvtRPCLoginFlag) (EvtHandle, error) {
+	login := evtRPCLoginInfo{Flags: flags}
+	var err error
+	for _, f := range []struct {
+		ptr   **uint16
+		value string
+	}{
+		{&login.Server, server},
+		{&login.Domain, domain},
+		{&login.User, user},
+		{&login.Password, password},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if *f.ptr, err = syscall.UTF16PtrFromString(f.value); err != nil {
+			return 0, err
+		}
 	}
-	level.Debug(util_log.Logger).Log("msg", "Subcribed with handle id", "id", subsHandle)
-
-	return subsHandle, nil
+	return _EvtOpenSession(evtRPCLogin, &login, 0, 0)
 }
 
 func fetchEventHandles(subsHandle EvtHandle) ([]EvtHandle, error) {
@@ -420,13 +446,20 @@ func fetchEventHandles(subsHandle EvtHandle) ([]EvtHandle, error) {
 }
 
 type EventFetcher struct {
-	buf []byte
+	buf     []byte
+	session EvtHandle
 }
 
 func NewEventFetcher() *EventFetcher {
 	return &EventFetcher{}
 }
 
+// NewSessionEventFetcher creates an EventFetcher rendering the events of a remote session
+// with the publisher metadata of the remote computer.
+func NewSessionEventFetcher(session EvtHandle) *EventFetcher {
+	return &EventFetcher{session: session}
+}
+
 func (w *EventFetcher) FetchEvents(subsHandle EvtHandle, lang uint32) ([]Event, []EvtHandle, error) {
 	if w.buf == nil {
 		w.buf = make([]byte, bufferSize)
@@ -481,7 +514,7 @@ func (w *EventFetcher) renderEvent(eventHandle EvtHandle, lang uint32) (Event, e
 		return event, nil
 	}
 
-	publisherHandle, err := openPublisherMetadata(0, event.Source.Name, lang)
+	publisherHandle, err := openPublisherMetadata(w.session, event.Source.Name, lang)
 	if err != nil {
 		return event, nil
 	}
@@ -0,0 +1,12 @@
+//go:build fips
+// +build fips
+
+package tlspolicy
+
+// Importing fipsonly restricts every TLS config of the program to the FIPS-approved
+// settings. The package only exists in Go toolchains built with BoringCrypto, so a FIPS
+// build can't silently fall back to the standard crypto.
+import _ "crypto/tls/fipsonly"
+
+// FIPSBuild is whether Promtail is built with the fips tag.
+const FIPSBuild = true
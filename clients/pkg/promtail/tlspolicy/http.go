@@ -0,0 +1,108 @@
+package tlspolicy
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	conntrack "github.com/mwitkow/go-conntrack"
+	"github.com/prometheus/common/config"
+	"golang.org/x/net/http2"
+)
+
+// HTTPClientOptions are the options of NewHTTPClient.
+type HTTPClientOptions struct {
+	HTTP2Disabled bool
+	// DialContext replaces the dialer of the client when set.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// NewHTTPClient returns the HTTP client of the config, as config.NewClientFromConfig does.
+// The TLS config of its transport can't be changed once created, so when a policy is set
+// the transport is built here the same way, with the policy applied.
+func NewHTTPClient(cfg config.HTTPClientConfig, name string, opts HTTPClientOptions) (*http.Client, error) {
+	if !Enabled() {
+		var clientOpts []config.HTTPClientOption
+		if opts.HTTP2Disabled {
+			clientOpts = append(clientOpts, config.WithHTTP2Disabled())
+		}
+		if opts.DialContext != nil {
+			clientOpts = append(clientOpts, config.WithDialContextFunc(opts.DialContext))
+		}
+		return config.NewClientFromConfig(cfg, name, clientOpts...)
+	}
+
+	rt, err := newRoundTripper(cfg, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: rt}
+	if !cfg.FollowRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return client, nil
+}
+
+func newRoundTripper(cfg config.HTTPClientConfig, name string, opts HTTPClientOptions) (http.RoundTripper, error) {
+	dialContext := conntrack.NewDialContextFunc(conntrack.DialWithTracing(), conntrack.DialWithName(name))
+	if opts.DialContext != nil {
+		dialContext = conntrack.NewDialContextFunc(
+			conntrack.DialWithDialContextFunc(opts.DialContext),
+			conntrack.DialWithTracing(),
+			conntrack.DialWithName(name))
+	}
+
+	newRT := func(tlsConfig *tls.Config) (http.RoundTripper, error) {
+		Apply(tlsConfig)
+		var rt http.RoundTripper = &http.Transport{
+			Proxy:                 http.ProxyURL(cfg.ProxyURL.URL),
+			MaxIdleConns:          20000,
+			MaxIdleConnsPerHost:   1000,
+			TLSClientConfig:       tlsConfig,
+			DisableCompression:    true,
+			IdleConnTimeout:       5 * time.Minute,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+			DialContext:           dialContext,
+		}
+		if !opts.HTTP2Disabled {
+			http2t, err := http2.ConfigureTransports(rt.(*http.Transport))
+			if err != nil {
+				return nil, err
+			}
+			http2t.ReadIdleTimeout = time.Minute
+		}
+
+		if cfg.Authorization != nil && len(cfg.Authorization.Credentials) > 0 {
+			rt = config.NewAuthorizationCredentialsRoundTripper(cfg.Authorization.Type, cfg.Authorization.Credentials, rt)
+		} else if cfg.Authorization != nil && len(cfg.Authorization.CredentialsFile) > 0 {
+			rt = config.NewAuthorizationCredentialsFileRoundTripper(cfg.Authorization.Type, cfg.Authorization.CredentialsFile, rt)
+		}
+		if len(cfg.BearerToken) > 0 {
+			rt = config.NewAuthorizationCredentialsRoundTripper("Bearer", cfg.BearerToken, rt)
+		} else if len(cfg.BearerTokenFile) > 0 {
+			rt = config.NewAuthorizationCredentialsFileRoundTripper("Bearer", cfg.BearerTokenFile, rt)
+		}
+		if cfg.BasicAuth != nil {
+			rt = config.NewBasicAuthRoundTripper(cfg.BasicAuth.Username, cfg.BasicAuth.Password, cfg.BasicAuth.PasswordFile, rt)
+		}
+		if cfg.OAuth2 != nil {
+			rt = config.NewOAuth2RoundTripper(cfg.OAuth2, rt)
+		}
+		return rt, nil
+	}
+
+	tlsConfig, err := config.NewTLSConfig(&cfg.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.TLSConfig.CAFile) == 0 {
+		return newRT(tlsConfig)
+	}
+	// The round tripper reloads the CA file when it changes, with a copy of the TLS config.
+	return config.NewTLSRoundTripper(tlsConfig, cfg.TLSConfig.CAFile, newRT)
+}
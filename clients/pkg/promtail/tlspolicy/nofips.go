@@ -0,0 +1,7 @@
+//go:build !fips
+// +build !fips
+
+package tlspolicy
+
+// FIPSBuild is whether Promtail is built with the fips tag.
+const FIPSBuild = false
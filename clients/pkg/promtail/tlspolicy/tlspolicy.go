@@ -0,0 +1,176 @@
+// Package tlspolicy enforces the TLS policy of Promtail, the minimum TLS version and the
+// cipher suites of every TLS connection it makes, to the clients it pushes to and the
+// sources it reads from.
+//
+// The policy is set once at startup with Set, before any client is created. The TLS
+// configs are then created as usual and passed to Apply.
+//
+// Promtail built with the fips tag, using a Go toolchain built with BoringCrypto, is
+// restricted to the FIPS-approved TLS settings, see FIPSBuild.
+package tlspolicy
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grafana/dskit/flagext"
+)
+
+// Config is the TLS policy of Promtail.
+type Config struct {
+	MinVersion   string                 `yaml:"min_version"`
+	CipherSuites flagext.StringSliceCSV `yaml:"cipher_suites"`
+	RequireFIPS  bool                   `yaml:"require_fips"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by
+// prefix. If prefix is a non-empty string, prefix should end with a period.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.MinVersion, prefix+"tls.min-version", "", "Minimum TLS version of the connections, one of TLS10, TLS11, TLS12 or TLS13. Empty keeps the default of each client.")
+	f.Var(&cfg.CipherSuites, prefix+"tls.cipher-suites", "Comma separated list of the cipher suites allowed up to TLS 1.2, by their Go name. Empty allows the default cipher suites.")
+	f.BoolVar(&cfg.RequireFIPS, prefix+"tls.require-fips", false, "Refuse to start unless Promtail is a FIPS build.")
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("", f)
+}
+
+var versions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// fipsCipherSuites are the FIPS-approved cipher suites.
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// policy is the parsed Config.
+type policy struct {
+	minVersion   uint16
+	cipherSuites []uint16
+}
+
+var (
+	mtx     sync.RWMutex
+	current policy
+)
+
+func parse(cfg Config, fips bool) (policy, error) {
+	var p policy
+	if cfg.RequireFIPS && !fips {
+		return p, errors.New("require_fips is set but Promtail is not a FIPS build, build it with the fips tag and a Go toolchain built with BoringCrypto")
+	}
+
+	if cfg.MinVersion != "" {
+		v, ok := versions[cfg.MinVersion]
+		if !ok {
+			return p, fmt.Errorf("unknown TLS version %q, must be one of TLS10, TLS11, TLS12 or TLS13", cfg.MinVersion)
+		}
+		p.minVersion = v
+	}
+
+	suites := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		suites[s.Name] = s.ID
+	}
+	for _, name := range cfg.CipherSuites {
+		id, ok := suites[name]
+		if !ok {
+			return p, fmt.Errorf("unknown or insecure cipher suite %q, must be one of %s", name, strings.Join(sortedNames(suites), ", "))
+		}
+		p.cipherSuites = append(p.cipherSuites, id)
+	}
+
+	if !fips {
+		return p, nil
+	}
+	// A FIPS build only allows the approved settings, which are also the defaults.
+	if p.minVersion == 0 {
+		p.minVersion = tls.VersionTLS12
+	}
+	if p.minVersion < tls.VersionTLS12 {
+		return p, fmt.Errorf("TLS version %s is not FIPS-approved, the minimum is TLS12", cfg.MinVersion)
+	}
+	if len(p.cipherSuites) == 0 {
+		p.cipherSuites = fipsCipherSuites
+	}
+	for i, id := range p.cipherSuites {
+		if !approved(id) {
+			return p, fmt.Errorf("cipher suite %s is not FIPS-approved", cfg.CipherSuites[i])
+		}
+	}
+	return p, nil
+}
+
+func approved(id uint16) bool {
+	for _, a := range fipsCipherSuites {
+		if a == id {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNames(suites map[string]uint16) []string {
+	names := make([]string, 0, len(suites))
+	for name := range suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set validates the config and sets the policy applied to the TLS connections made afterwards.
+func Set(cfg Config) error {
+	p, err := parse(cfg, FIPSBuild)
+	if err != nil {
+		return err
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	current = p
+	return nil
+}
+
+// Enabled returns whether a policy is set.
+func Enabled() bool {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	return current.minVersion != 0 || len(current.cipherSuites) > 0
+}
+
+// Apply restricts the TLS config to the policy. The minimum version of the config is only
+// raised, and its cipher suites are replaced by the ones of the policy when there are any.
+// As in Go, the cipher suites of TLS 1.3 are not configurable.
+func Apply(c *tls.Config) {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	if c.MinVersion < current.minVersion {
+		c.MinVersion = current.minVersion
+	}
+	if len(current.cipherSuites) > 0 {
+		c.CipherSuites = append([]uint16(nil), current.cipherSuites...)
+	}
+}
+
+// HostFIPSEnabled returns whether the kernel of the host runs in FIPS mode, in which case
+// Promtail is expected to be a FIPS build too.
+func HostFIPSEnabled() bool {
+	b, err := ioutil.ReadFile("/proc/sys/crypto/fips_enabled")
+	return err == nil && strings.TrimSpace(string(b)) == "1"
+}
@@ -0,0 +1,124 @@
+package tlspolicy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/common/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parse(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      Config
+		fips     bool
+		expected policy
+		err      string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:     "min version and cipher suites",
+			cfg:      Config{MinVersion: "TLS12", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}},
+			expected: policy{minVersion: tls.VersionTLS12, cipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}},
+		},
+		{
+			name: "unknown version",
+			cfg:  Config{MinVersion: "1.2"},
+			err:  `unknown TLS version "1.2", must be one of TLS10, TLS11, TLS12 or TLS13`,
+		},
+		{
+			name: "insecure cipher suite",
+			cfg:  Config{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			err:  `unknown or insecure cipher suite "TLS_RSA_WITH_RC4_128_SHA"`,
+		},
+		{
+			name: "fips required without fips build",
+			cfg:  Config{RequireFIPS: true},
+			err:  "require_fips is set but Promtail is not a FIPS build",
+		},
+		{
+			name:     "fips defaults",
+			cfg:      Config{RequireFIPS: true},
+			fips:     true,
+			expected: policy{minVersion: tls.VersionTLS12, cipherSuites: fipsCipherSuites},
+		},
+		{
+			name: "fips version",
+			cfg:  Config{MinVersion: "TLS11"},
+			fips: true,
+			err:  "TLS version TLS11 is not FIPS-approved, the minimum is TLS12",
+		},
+		{
+			name: "fips cipher suite",
+			cfg:  Config{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}},
+			fips: true,
+			err:  "cipher suite TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256 is not FIPS-approved",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := parse(tc.cfg, tc.fips)
+			if tc.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, p)
+		})
+	}
+}
+
+func setPolicy(t *testing.T, cfg Config) {
+	t.Helper()
+	require.NoError(t, Set(cfg))
+	t.Cleanup(func() { require.NoError(t, Set(Config{})) })
+}
+
+func TestApply(t *testing.T) {
+	c := &tls.Config{MinVersion: tls.VersionTLS13}
+	Apply(c)
+	require.Equal(t, &tls.Config{MinVersion: tls.VersionTLS13}, c)
+
+	setPolicy(t, Config{MinVersion: "TLS12", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"}})
+	require.True(t, Enabled())
+
+	// the minimum version is only raised.
+	Apply(c)
+	require.Equal(t, uint16(tls.VersionTLS13), c.MinVersion)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, c.CipherSuites)
+
+	c = &tls.Config{}
+	Apply(c)
+	require.Equal(t, uint16(tls.VersionTLS12), c.MinVersion)
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	cfg := config.HTTPClientConfig{TLSConfig: config.TLSConfig{InsecureSkipVerify: true}}
+	get := func() error {
+		client, err := NewHTTPClient(cfg, "test", HTTPClientOptions{})
+		require.NoError(t, err)
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	require.NoError(t, get())
+
+	// the server doesn't support the minimum version of the policy.
+	setPolicy(t, Config{MinVersion: "TLS13"})
+	require.Error(t, get())
+
+	setPolicy(t, Config{MinVersion: "TLS12", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}})
+	require.NoError(t, get())
+}
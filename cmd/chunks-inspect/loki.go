@@ -60,6 +60,7 @@ const (
 	chunkFormatV1
 	chunkFormatV2
 	chunkFormatV3
+	chunkFormatV4
 )
 
 type LokiChunk struct {
@@ -92,6 +93,8 @@ type LokiBlock struct {
 type LokiEntry struct {
 	timestamp int64
 	line      string
+	// metadata is the structured metadata of the entry (V4 chunks only), as name=value pairs.
+	metadata []string
 }
 
 func parseLokiChunk(chunkHeader *ChunkHeader, r io.Reader) (*LokiChunk, error) {
@@ -174,14 +177,14 @@ func parseLokiChunk(chunkHeader *ChunkHeader, r io.Reader) (*LokiChunk, error) {
 		block.rawData = data[block.dataOffset : block.dataOffset+dataLength]
 		block.storedChecksum = binary.BigEndian.Uint32(data[block.dataOffset+dataLength : block.dataOffset+dataLength+4])
 		block.computedChecksum = crc32.Checksum(block.rawData, castagnoliTable)
-		block.originalData, block.entries, err = parseLokiBlock(compression, block.rawData)
+		block.originalData, block.entries, err = parseLokiBlock(compression, f, block.rawData)
 		lokiChunk.blocks = append(lokiChunk.blocks, block)
 	}
 
 	return lokiChunk, nil
 }
 
-func parseLokiBlock(compression Encoding, data []byte) ([]byte, []LokiEntry, error) {
+func parseLokiBlock(compression Encoding, format byte, data []byte) ([]byte, []LokiEntry, error) {
 	r, err := compression.readerFn(bytes.NewReader(data))
 	if err != nil {
 		return nil, nil, err
@@ -208,17 +211,49 @@ func parseLokiBlock(compression Encoding, data []byte) ([]byte, []LokiEntry, err
 			return origDecompressed, nil, fmt.Errorf("not enough line data, need %d, got %d", lineLength, len(decompressed))
 		}
 
-		entries = append(entries, LokiEntry{
+		entry := LokiEntry{
 			timestamp: timestamp,
 			line:      string(decompressed[0:lineLength]),
-		})
-
+		}
 		decompressed = decompressed[lineLength:]
+
+		if format >= chunkFormatV4 {
+			entry.metadata, decompressed, err = readMetadata(decompressed)
+			if err != nil {
+				return origDecompressed, nil, err
+			}
+		}
+		entries = append(entries, entry)
 	}
 
 	return origDecompressed, entries, nil
 }
 
+// readMetadata reads the structured metadata following a line: the number of pairs, then the
+// length prefixed name and value of each pair.
+func readMetadata(buf []byte) ([]string, []byte, error) {
+	n, buf, err := readUvarint(nil, buf)
+	var metadata []string
+	for i := uint64(0); err == nil && i < n; i++ {
+		var name, value []byte
+		name, buf, err = readUvarintBytes(err, buf)
+		value, buf, err = readUvarintBytes(err, buf)
+		metadata = append(metadata, fmt.Sprintf("%s=%q", name, value))
+	}
+	return metadata, buf, err
+}
+
+func readUvarintBytes(prevErr error, buf []byte) ([]byte, []byte, error) {
+	l, buf, err := readUvarint(prevErr, buf)
+	if err != nil {
+		return nil, buf, err
+	}
+	if uint64(len(buf)) < l {
+		return nil, buf, fmt.Errorf("not enough metadata, need %d, got %d", l, len(buf))
+	}
+	return buf[:l], buf[l:], nil
+}
+
 func readVarint(prevErr error, buf []byte) (int64, []byte, error) {
 	if prevErr != nil {
 		return 0, buf, prevErr
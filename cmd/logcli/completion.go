@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/grafana/loki/pkg/logcli/client"
+	"github.com/grafana/loki/pkg/logcli/labelquery"
+)
+
+// The completion scripts call logcli with the hidden --completion-bash flag and
+// the words before the one being completed, and filter the candidates themselves:
+// kingpin would otherwise take a partially typed argument as already given.
+const bashCompletionScript = `_logcli_completion() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" candidate IFS=$'\n'
+    local args=("${COMP_WORDS[@]:1:COMP_CWORD-1}")
+    if [[ "$cur" == -* ]]; then
+        args+=("$cur")
+    fi
+    COMPREPLY=()
+    for candidate in $("${COMP_WORDS[0]}" --completion-bash "${args[@]}" 2>/dev/null); do
+        if [[ "$candidate" == "$cur"* ]]; then
+            COMPREPLY+=("$(printf '%q' "$candidate")")
+        fi
+    done
+}
+complete -o default -F _logcli_completion logcli
+`
+
+const zshCompletionScript = `autoload -U +X bashcompinit && bashcompinit
+` + bashCompletionScript
+
+const fishCompletionScript = `function __logcli_completion
+    set -l args (commandline -opc)
+    set -l cur (commandline -ct)
+    set -e args[1]
+    if string match -q -- '-*' $cur
+        set -a args $cur
+    end
+    logcli --completion-bash $args 2>/dev/null
+end
+complete -c logcli -a '(__logcli_completion)'
+`
+
+func completionScript(shell string) string {
+	switch shell {
+	case "zsh":
+		return zshCompletionScript
+	case "fish":
+		return fishCompletionScript
+	default:
+		return bashCompletionScript
+	}
+}
+
+// completionCacheDirectory returns the directory of the cache of the label queries,
+// logcli in the user cache directory unless set with --completion-cache-dir.
+func completionCacheDirectory() string {
+	if *completionCacheDir != "" {
+		return *completionCacheDir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "logcli")
+}
+
+// newLabelCache returns the cache of the label queries sent to the server, or
+// nil when reading logs from stdin.
+func newLabelCache() *labelquery.Cache {
+	c, ok := queryClient.(*client.DefaultClient)
+	if !ok || *stdin {
+		return nil
+	}
+	return &labelquery.Cache{
+		Dir:    completionCacheDirectory(),
+		TTL:    *completionCacheTTL,
+		Server: strings.TrimSuffix(c.Address, "/"),
+	}
+}
+
+// completionLabelQuery returns the label query used to complete the arguments
+// of a command looking back over since, or between from and to.
+func completionLabelQuery(since time.Duration, from, to string) *labelquery.LabelQuery {
+	end := time.Now()
+	return &labelquery.LabelQuery{
+		Start: mustParse(from, end.Add(-since)),
+		End:   mustParse(to, end),
+		Cache: newLabelCache(),
+	}
+}
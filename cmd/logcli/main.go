@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"math"
 	"net/url"
@@ -14,6 +15,8 @@ import (
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/grafana/loki/pkg/logcli/client"
+	"github.com/grafana/loki/pkg/logcli/diffquery"
+	"github.com/grafana/loki/pkg/logcli/importer"
 	"github.com/grafana/loki/pkg/logcli/labelquery"
 	"github.com/grafana/loki/pkg/logcli/output"
 	"github.com/grafana/loki/pkg/logcli/query"
@@ -31,6 +34,9 @@ var (
 	memProfile = app.Flag("memprofile", "Specify the location for writing a memory profile.").Default("").String()
 	stdin      = app.Flag("stdin", "Take input logs from stdin").Bool()
 
+	completionCacheDir = app.Flag("completion-cache-dir", "Directory where the label names and values used by shell completion are cached. Defaults to logcli in the user cache directory, e.g. ~/.cache/logcli.").Default("").String()
+	completionCacheTTL = app.Flag("completion-cache-ttl", "How long the label names and values used by shell completion are cached. 0 disables the cache.").Default("5m").Duration()
+
 	queryClient = newQueryClient(app)
 
 	queryCmd = app.Command("query", `Run a LogQL query.
@@ -109,6 +115,76 @@ Use the --analyze-labels flag to get a summary of the labels found in all stream
 This is helpful to find high cardinality labels.
 `)
 	seriesQuery = newSeriesQuery(seriesCmd)
+
+	importCmd = app.Command("import", `Push local files to Loki.
+
+The "import" command reads the given files line by line, runs the lines
+through the promtail pipeline stages of the config file and pushes them
+to Loki. It is useful for ad-hoc backfills without deploying promtail.
+
+The config file holds the labels added to every line, along with the
+"filename" label, and the pipeline_stages of a promtail scrape config:
+
+	labels:
+	  job: backfill
+	pipeline_stages:
+	  - regex:
+	      expression: '^(?P<ts>\S+) (?P<level>\S+)'
+	  - timestamp:
+	      source: ts
+	      format: RFC3339Nano
+	  - labels:
+	      level:
+	  - drop:
+	      source: level
+	      value: debug
+
+Lines dropped by the pipeline are not imported. Lines without a timestamp
+stage are stamped with the import time.
+
+Example:
+
+	logcli import --config=pipeline.yaml app.log app.log.1`)
+	importQuery = newImport(importCmd)
+
+	diffCmd = app.Command("diff", `Compare a query over two time ranges, or two queries.
+
+The "diff" command counts the entries of every stream and of every line
+pattern over the query range and over the compare range, and prints the
+differences, largest first. It is useful to find out what changed, e.g.
+after a deploy.
+
+The compare range is the range right before the query range unless
+--offset or --compare-from and --compare-to are given. When a second
+query is given, it is run over the compare range instead of the first
+one, which by default is the query range.
+
+Line patterns replace the tokens holding a digit by <_>. They are
+computed on the --limit most recent lines of each range and scaled
+to the entry counts.
+
+Example:
+
+	logcli diff --from="2021-01-19T10:00:00Z" --to="2021-01-19T11:00:00Z" '{app="api"}'
+	logcli diff --since=1h --offset=24h '{app="api"}'
+	logcli diff --since=1h '{app="api"} |= "error"' '{app="api-canary"} |= "error"'`)
+	diffQuery = newDiffQuery(diffCmd)
+
+	completionCmd = app.Command("completion", `Output a shell completion script.
+
+The completion scripts complete commands and flags, label names for the
+"labels" command and the --include-label and --exclude-label flags, and
+stream selectors for the "query", "instant-query", "series" and "diff"
+commands.
+Label names and values are queried over the --since window of the command
+being completed and cached for --completion-cache-ttl. The "labels" command
+refreshes the cache too.
+
+Example:
+
+	source <(logcli completion bash)
+	logcli completion fish > ~/.config/fish/completions/logcli.fish`)
+	completionShell = completionCmd.Arg("shell", "The shell to complete, one of bash, zsh or fish.").Required().Enum("bash", "zsh", "fish")
 )
 
 func main() {
@@ -213,6 +289,16 @@ func main() {
 		labelsQuery.DoLabels(queryClient)
 	case seriesCmd.FullCommand():
 		seriesQuery.DoSeries(queryClient)
+	case importCmd.FullCommand():
+		pusher, ok := queryClient.(importer.Pusher)
+		if !ok {
+			log.Fatal("import can't be used with --stdin")
+		}
+		importQuery.DoImport(pusher)
+	case diffCmd.FullCommand():
+		diffQuery.DoDiff(queryClient)
+	case completionCmd.FullCommand():
+		fmt.Print(completionScript(*completionShell))
 	}
 }
 
@@ -263,10 +349,13 @@ func newLabelQuery(cmd *kingpin.CmdClause) *labelquery.LabelQuery {
 		q.End = mustParse(to, defaultEnd)
 		q.LabelName = labelName
 		q.Quiet = *quiet
+		q.Cache = newLabelCache()
 		return nil
 	})
 
-	cmd.Arg("label", "The name of the label.").Default("").StringVar(&labelName)
+	cmd.Arg("label", "The name of the label.").Default("").HintAction(func() []string {
+		return completionLabelQuery(since, from, to).CompleteLabels(queryClient)
+	}).StringVar(&labelName)
 	cmd.Flag("since", "Lookback window.").Default("1h").DurationVar(&since)
 	cmd.Flag("from", "Start looking for labels at this absolute time (inclusive)").StringVar(&from)
 	cmd.Flag("to", "Stop looking for labels at this absolute time (exclusive)").StringVar(&to)
@@ -293,7 +382,9 @@ func newSeriesQuery(cmd *kingpin.CmdClause) *seriesquery.SeriesQuery {
 		return nil
 	})
 
-	cmd.Arg("matcher", "eg '{foo=\"bar\",baz=~\".*blip\"}'").Required().StringVar(&q.Matcher)
+	cmd.Arg("matcher", "eg '{foo=\"bar\",baz=~\".*blip\"}'").Required().HintAction(func() []string {
+		return completionLabelQuery(since, from, to).CompleteSelectors(queryClient)
+	}).StringVar(&q.Matcher)
 	cmd.Flag("since", "Lookback window.").Default("1h").DurationVar(&since)
 	cmd.Flag("from", "Start looking for logs at this absolute time (inclusive)").StringVar(&from)
 	cmd.Flag("to", "Stop looking for logs at this absolute time (exclusive)").StringVar(&to)
@@ -325,12 +416,26 @@ func newQuery(instant bool, cmd *kingpin.CmdClause) *query.Query {
 		return nil
 	})
 
+	// instant queries have no lookback window, complete them with the labels of the last hour.
+	completionQuery := func() *labelquery.LabelQuery {
+		if instant {
+			return completionLabelQuery(time.Hour, "", "")
+		}
+		return completionLabelQuery(since, from, to)
+	}
+	completeSelectors := func() []string {
+		return completionQuery().CompleteSelectors(queryClient)
+	}
+	completeLabelNames := func() []string {
+		return completionQuery().CompleteLabels(queryClient)
+	}
+
 	cmd.Flag("limit", "Limit on number of entries to print.").Default("30").IntVar(&q.Limit)
 	if instant {
-		cmd.Arg("query", "eg 'rate({foo=\"bar\"} |~ \".*error.*\" [5m])'").Required().StringVar(&q.QueryString)
+		cmd.Arg("query", "eg 'rate({foo=\"bar\"} |~ \".*error.*\" [5m])'").Required().HintAction(completeSelectors).StringVar(&q.QueryString)
 		cmd.Flag("now", "Time at which to execute the instant query.").StringVar(&now)
 	} else {
-		cmd.Arg("query", "eg '{foo=\"bar\",baz=~\".*blip\"} |~ \".*error.*\"'").Required().StringVar(&q.QueryString)
+		cmd.Arg("query", "eg '{foo=\"bar\",baz=~\".*blip\"} |~ \".*error.*\"'").Required().HintAction(completeSelectors).StringVar(&q.QueryString)
 		cmd.Flag("since", "Lookback window.").Default("1h").DurationVar(&since)
 		cmd.Flag("from", "Start looking for logs at this absolute time (inclusive)").StringVar(&from)
 		cmd.Flag("to", "Stop looking for logs at this absolute time (exclusive)").StringVar(&to)
@@ -342,8 +447,8 @@ func newQuery(instant bool, cmd *kingpin.CmdClause) *query.Query {
 
 	cmd.Flag("forward", "Scan forwards through logs.").Default("false").BoolVar(&q.Forward)
 	cmd.Flag("no-labels", "Do not print any labels").Default("false").BoolVar(&q.NoLabels)
-	cmd.Flag("exclude-label", "Exclude labels given the provided key during output.").StringsVar(&q.IgnoreLabelsKey)
-	cmd.Flag("include-label", "Include labels given the provided key during output.").StringsVar(&q.ShowLabelsKey)
+	cmd.Flag("exclude-label", "Exclude labels given the provided key during output.").HintAction(completeLabelNames).StringsVar(&q.IgnoreLabelsKey)
+	cmd.Flag("include-label", "Include labels given the provided key during output.").HintAction(completeLabelNames).StringsVar(&q.ShowLabelsKey)
 	cmd.Flag("labels-length", "Set a fixed padding to labels").Default("0").IntVar(&q.FixedLabelsLen)
 	cmd.Flag("store-config", "Execute the current query using a configured storage from a given Loki configuration file.").Default("").StringVar(&q.LocalConfig)
 	cmd.Flag("colored-output", "Show output with colored labels").Default("false").BoolVar(&q.ColoredOutput)
@@ -372,3 +477,67 @@ func defaultQueryRangeStep(start, end time.Time) time.Duration {
 	step := int(math.Max(math.Floor(end.Sub(start).Seconds()/250), 1))
 	return time.Duration(step) * time.Second
 }
+
+func newImport(cmd *kingpin.CmdClause) *importer.Import {
+	i := &importer.Import{}
+
+	// executed after all command flags are parsed
+	cmd.Action(func(c *kingpin.ParseContext) error {
+		i.Quiet = *quiet
+		return nil
+	})
+
+	cmd.Arg("file", "Files to import.").Required().ExistingFilesVar(&i.Files)
+	cmd.Flag("config", "Config file with the labels and the pipeline stages applied to the lines.").ExistingFileVar(&i.ConfigFile)
+	cmd.Flag("batch-size", "Maximum size in bytes of the lines pushed in a single request.").Default("1048576").IntVar(&i.BatchSize)
+
+	return i
+}
+
+func newDiffQuery(cmd *kingpin.CmdClause) *diffquery.DiffQuery {
+	var from, to, compareFrom, compareTo string
+	var since, offset time.Duration
+
+	q := &diffquery.DiffQuery{}
+
+	// executed after all command flags are parsed
+	cmd.Action(func(c *kingpin.ParseContext) error {
+
+		defaultEnd := time.Now()
+		defaultStart := defaultEnd.Add(-since)
+
+		q.Start = mustParse(from, defaultStart)
+		q.End = mustParse(to, defaultEnd)
+
+		switch {
+		case offset != 0:
+			q.CompareStart, q.CompareEnd = q.Start.Add(-offset), q.End.Add(-offset)
+		case compareFrom != "":
+			q.CompareStart = mustParse(compareFrom, time.Time{})
+			q.CompareEnd = mustParse(compareTo, q.CompareStart.Add(q.End.Sub(q.Start)))
+		case q.CompareQueryString != "":
+			q.CompareStart, q.CompareEnd = q.Start, q.End
+		default:
+			q.CompareStart, q.CompareEnd = q.Start.Add(-q.End.Sub(q.Start)), q.Start
+		}
+		q.Quiet = *quiet
+		return nil
+	})
+
+	completeSelectors := func() []string {
+		return completionLabelQuery(since, from, to).CompleteSelectors(queryClient)
+	}
+
+	cmd.Arg("query", "eg '{foo=\"bar\",baz=~\".*blip\"} |~ \".*error.*\"'").Required().HintAction(completeSelectors).StringVar(&q.QueryString)
+	cmd.Arg("compare-query", "Query run over the compare range instead of the first one.").HintAction(completeSelectors).StringVar(&q.CompareQueryString)
+	cmd.Flag("since", "Lookback window.").Default("1h").DurationVar(&since)
+	cmd.Flag("from", "Start looking for logs at this absolute time (inclusive)").StringVar(&from)
+	cmd.Flag("to", "Stop looking for logs at this absolute time (exclusive)").StringVar(&to)
+	cmd.Flag("offset", "Compare with the query range shifted back by this duration.").DurationVar(&offset)
+	cmd.Flag("compare-from", "Start of the compare range (inclusive)").StringVar(&compareFrom)
+	cmd.Flag("compare-to", "End of the compare range (exclusive), defaults to the length of the query range after --compare-from").StringVar(&compareTo)
+	cmd.Flag("limit", "Number of lines of each range the patterns are computed on.").Default("1000").IntVar(&q.Limit)
+	cmd.Flag("top", "Number of streams and patterns printed, all if 0.").Default("20").IntVar(&q.Top)
+
+	return q
+}
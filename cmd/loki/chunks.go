@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/loki/pkg/chunkenc"
+	"github.com/grafana/loki/pkg/loki"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
+	"github.com/grafana/loki/pkg/storage/chunk/storage"
+	"github.com/grafana/loki/pkg/util/cfg"
+)
+
+const chunksUsage = `Usage: loki chunks <command> [flags] <chunk>...
+
+Commands:
+  inspect   print the metadata, the blocks and the compression of chunks
+  cat       print the log lines of chunks
+  verify    validate the checksums and the blocks of chunks
+
+Chunks are local files, or object keys <tenant>/<fingerprint>:<from>:<through>:<checksum>
+read from the object store configured in -config.file. Run loki chunks <command> -h for
+the flags of a command.
+`
+
+const chunksTimeFormat = "2006-01-02 15:04:05.000000 MST"
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// runChunks runs the chunks subcommand, which inspects the chunks stored by Loki.
+func runChunks(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Fprint(stdout, chunksUsage)
+		return nil
+	}
+
+	var run func(*chunkObject) error
+	fs := flag.NewFlagSet("loki chunks "+args[0], flag.ContinueOnError)
+	var source chunkSource
+	source.RegisterFlags(fs)
+	switch args[0] {
+	case "inspect":
+		blocks := fs.Bool("b", false, "Print the details of the blocks.")
+		lines := fs.Bool("l", false, "Print the log lines.")
+		dump := fs.String("dump", "", "Directory to write the blocks to, both compressed as stored in the chunk and decompressed.")
+		run = func(c *chunkObject) error {
+			return inspectChunk(stdout, c, *blocks, *lines, *dump)
+		}
+	case "cat":
+		timestamps := fs.Bool("t", false, "Prefix the log lines with their timestamp.")
+		run = func(c *chunkObject) error {
+			return catChunk(stdout, c, *timestamps)
+		}
+	case "verify":
+		run = func(c *chunkObject) error {
+			return verifyChunk(stdout, c)
+		}
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], chunksUsage)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no chunk given\n\n%s", chunksUsage)
+	}
+	if err := source.init(); err != nil {
+		return err
+	}
+	defer source.stop()
+
+	failed := 0
+	for _, name := range fs.Args() {
+		c, err := source.read(context.Background(), name)
+		if err == nil {
+			err = run(c)
+		}
+		if err != nil {
+			fmt.Fprintf(stdout, "%s: %v\n", name, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d chunks failed", failed, fs.NArg())
+	}
+	return nil
+}
+
+// chunkSource reads the chunks from local files, or from the object store configured in a
+// Loki config file.
+type chunkSource struct {
+	configFile string
+	store      string
+
+	client     chunk.ObjectClient
+	keyEncoder objectclient.KeyEncoder
+}
+
+// RegisterFlags registers the flags selecting where chunks are read from.
+func (s *chunkSource) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&s.configFile, "config.file", "", "Loki config file of the object store to read the chunks from. When empty, the chunks are local files.")
+	f.StringVar(&s.store, "store", "", "Object store to read the chunks from, such as s3, gcs, azure or filesystem. Defaults to the object store of the last period of the schema config.")
+}
+
+func (s *chunkSource) init() error {
+	if s.configFile == "" {
+		return nil
+	}
+	var config loki.Config
+	if err := cfg.Unmarshal(&config, cfg.Defaults(flag.NewFlagSet("", flag.ContinueOnError)), cfg.YAML(s.configFile, true)); err != nil {
+		return fmt.Errorf("failed parsing config: %w", err)
+	}
+	if s.store == "" {
+		if len(config.SchemaConfig.Configs) == 0 {
+			return errors.New("-store is required when the config has no schema config")
+		}
+		period := config.SchemaConfig.Configs[len(config.SchemaConfig.Configs)-1]
+		s.store = period.ObjectType
+		if s.store == "" {
+			s.store = period.IndexType
+		}
+	}
+	client, err := storage.NewObjectClient(s.store, config.StorageConfig.Config)
+	if err != nil {
+		return err
+	}
+	s.client = client
+	// Like the chunk client, the filesystem object store names the chunks after their encoded key.
+	if s.store == storage.StorageTypeFileSystem {
+		s.keyEncoder = objectclient.Base64Encoder
+	}
+	return nil
+}
+
+func (s *chunkSource) stop() {
+	if s.client != nil {
+		s.client.Stop()
+	}
+}
+
+func (s *chunkSource) read(ctx context.Context, name string) (*chunkObject, error) {
+	if s.client == nil {
+		buf, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return decodeChunkObject(name, chunkKeyFromFilename(name), buf)
+	}
+
+	objectKey := name
+	if s.keyEncoder != nil {
+		objectKey = s.keyEncoder(name)
+	}
+	r, err := s.client.GetObject(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeChunkObject(name, name, buf)
+}
+
+// chunkKeyFromFilename returns the key of a chunk from the name of its file, which is either
+// the base64 encoded key in the directory of the filesystem object store, or the last part
+// of the key, <fingerprint>:<from>:<through>:<checksum>. It returns an empty string if the
+// name is not a chunk key.
+func chunkKeyFromFilename(name string) string {
+	base := filepath.Base(name)
+	if key, err := base64.StdEncoding.DecodeString(base); err == nil && strings.Contains(string(key), "/") {
+		return string(key)
+	}
+	if strings.Count(base, ":") == 3 {
+		return filepath.Base(filepath.Dir(name)) + "/" + base
+	}
+	return ""
+}
+
+// chunkObject is a chunk as stored in the object store: its metadata followed by the Loki
+// chunk data.
+type chunkObject struct {
+	name string
+	size int
+
+	chunk.Chunk
+	// checksumErr is the error verifying the checksum of the object against its key.
+	checksumErr error
+
+	info *chunkenc.ChunkInfo
+}
+
+// decodeChunkObject decodes the metadata of a chunk object, like chunk.Chunk.Decode, and
+// inspects its data.
+func decodeChunkObject(name, key string, buf []byte) (*chunkObject, error) {
+	c := &chunkObject{name: name, size: len(buf)}
+	if i := strings.Index(key, "/"); i > 0 {
+		expected, err := chunk.ParseExternalKey(key[:i], key)
+		if err == nil && expected.ChecksumSet {
+			if computed := crc32.Checksum(buf, castagnoliTable); computed != expected.Checksum {
+				c.checksumErr = fmt.Errorf("object checksum %08x does not match the checksum of its key %08x", computed, expected.Checksum)
+			}
+		}
+	}
+
+	r := bytes.NewReader(buf)
+	var metadataLen uint32
+	if err := binary.Read(r, binary.BigEndian, &metadataLen); err != nil {
+		return nil, errors.Wrap(err, "reading metadata length")
+	}
+	if err := jsoniter.ConfigFastest.NewDecoder(snappy.NewReader(r)).Decode(&c.Chunk); err != nil {
+		return nil, errors.Wrap(err, "decoding chunk metadata")
+	}
+	var dataLen uint32
+	if err := binary.Read(r, binary.BigEndian, &dataLen); err != nil {
+		return nil, errors.Wrap(err, "reading data length")
+	}
+	data := buf[len(buf)-r.Len():]
+	if int(dataLen) != len(data) {
+		return nil, fmt.Errorf("data length %d does not match the length in the chunk %d", len(data), dataLen)
+	}
+
+	info, err := chunkenc.Inspect(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding chunk data")
+	}
+	c.info = info
+	return c, nil
+}
+
+func inspectChunk(w io.Writer, c *chunkObject, blockDetails, printLines bool, dump string) error {
+	from, through := c.From.Time().UTC(), c.Through.Time().UTC()
+	fmt.Fprintln(w, "Chunk:", c.name)
+	fmt.Fprintln(w, "UserID:", c.UserID)
+	fmt.Fprintf(w, "Fingerprint: %016x\n", uint64(c.Fingerprint))
+	fmt.Fprintln(w, "From:", from.Format(chunksTimeFormat))
+	fmt.Fprintln(w, "Through:", through.Format(chunksTimeFormat), "("+through.Sub(from).String()+")")
+	fmt.Fprintln(w, "Labels:")
+	for _, l := range c.Metric {
+		fmt.Fprintln(w, "\t", l.Name, "=", l.Value)
+	}
+	fmt.Fprintln(w, "Format:", c.info.Format)
+	fmt.Fprintln(w, "Encoding:", c.info.Encoding)
+	fmt.Fprintln(w, "Blocks metadata checksum:", checksumStatus(c.info.MetadataChecksum, c.info.ComputedMetadataChecksum))
+	fmt.Fprintln(w, "Blocks:", len(c.info.Blocks))
+
+	compressed, uncompressed := 0, 0
+	for i, b := range c.info.Blocks {
+		compressed += len(b.Compressed)
+		uncompressed += len(b.Uncompressed)
+		if blockDetails {
+			fmt.Fprintf(w, "Block %4d: offset: %8d, entries: %6d, length: %8d (compressed: %8d, ratio: %.2f), minT: %s, maxT: %s, checksum: %s\n",
+				i, b.Offset, b.Entries, len(b.Uncompressed), len(b.Compressed), ratio(len(b.Uncompressed), len(b.Compressed)),
+				time.Unix(0, b.MinTime).UTC().Format(chunksTimeFormat), time.Unix(0, b.MaxTime).UTC().Format(chunksTimeFormat),
+				checksumStatus(b.Checksum, b.ComputedChecksum))
+			fmt.Fprintf(w, "Block %4d: digest compressed: %02x, uncompressed: %02x\n", i, sha256.Sum256(b.Compressed), sha256.Sum256(b.Uncompressed))
+			if b.Err != nil {
+				fmt.Fprintf(w, "Block %4d: error: %v\n", i, b.Err)
+			}
+		}
+		if printLines {
+			if err := c.info.Entries(i, func(ts int64, line string) {
+				fmt.Fprintf(w, "%s\t%s\n", time.Unix(0, ts).UTC().Format(chunksTimeFormat), line)
+			}); err != nil {
+				return errors.Wrapf(err, "block %d", i)
+			}
+		}
+		if dump != "" {
+			prefix := filepath.Join(dump, filepath.Base(c.name))
+			if err := ioutil.WriteFile(fmt.Sprintf("%s.block.%d", prefix, i), b.Compressed, 0644); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(fmt.Sprintf("%s.original.%d", prefix, i), b.Uncompressed, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	if len(c.info.Blocks) > 0 {
+		fmt.Fprintln(w, "Minimum time (from first block):", time.Unix(0, c.info.Blocks[0].MinTime).UTC().Format(chunksTimeFormat))
+		fmt.Fprintln(w, "Maximum time (from last block):", time.Unix(0, c.info.Blocks[len(c.info.Blocks)-1].MaxTime).UTC().Format(chunksTimeFormat))
+	}
+	fmt.Fprintf(w, "Total uncompressed: %d, compressed: %d (ratio: %.2f), object size: %d\n", uncompressed, compressed, ratio(uncompressed, compressed), c.size)
+	fmt.Fprintln(w)
+	return nil
+}
+
+func catChunk(w io.Writer, c *chunkObject, timestamps bool) error {
+	for i := range c.info.Blocks {
+		if err := c.info.Entries(i, func(ts int64, line string) {
+			if timestamps {
+				fmt.Fprintf(w, "%s\t", time.Unix(0, ts).UTC().Format(time.RFC3339Nano))
+			}
+			fmt.Fprintln(w, line)
+		}); err != nil {
+			return errors.Wrapf(err, "block %d", i)
+		}
+	}
+	return nil
+}
+
+// verifyChunk validates the checksums of the object, of the blocks metadata and of the
+// blocks, and that the blocks can be decoded.
+func verifyChunk(w io.Writer, c *chunkObject) error {
+	var problems []string
+	if c.checksumErr != nil {
+		problems = append(problems, c.checksumErr.Error())
+	}
+	if c.info.MetadataChecksum != c.info.ComputedMetadataChecksum {
+		problems = append(problems, "blocks metadata checksum "+checksumStatus(c.info.MetadataChecksum, c.info.ComputedMetadataChecksum))
+	}
+	for i, b := range c.info.Blocks {
+		switch {
+		case b.Checksum != b.ComputedChecksum:
+			problems = append(problems, fmt.Sprintf("block %d checksum %s", i, checksumStatus(b.Checksum, b.ComputedChecksum)))
+		case b.Err != nil:
+			problems = append(problems, fmt.Sprintf("block %d: %v", i, b.Err))
+		case b.DecodedEntries != b.Entries:
+			problems = append(problems, fmt.Sprintf("block %d: decoded %d entries, expected %d", i, b.DecodedEntries, b.Entries))
+		case b.OutOfBounds > 0:
+			problems = append(problems, fmt.Sprintf("block %d: %d entries outside of the block time range", i, b.OutOfBounds))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	fmt.Fprintf(w, "%s: OK\n", c.name)
+	return nil
+}
+
+func checksumStatus(stored, computed uint32) string {
+	if stored == computed {
+		return fmt.Sprintf("%08x OK", stored)
+	}
+	return fmt.Sprintf("%08x BAD (computed: %08x)", stored, computed)
+}
+
+func ratio(uncompressed, compressed int) float64 {
+	if compressed == 0 {
+		return 0
+	}
+	return float64(uncompressed) / float64(compressed)
+}
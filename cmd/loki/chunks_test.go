@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/chunkenc"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
+)
+
+func testChunkObject(t *testing.T) (string, []byte) {
+	t.Helper()
+	mc := chunkenc.NewMemChunk(chunkenc.EncSnappy, chunkenc.UnorderedHeadBlockFmt, 256, 0)
+	from := time.Unix(1600000000, 0)
+	for i := 0; i < 50; i++ {
+		require.NoError(t, mc.Append(&logproto.Entry{Timestamp: from.Add(time.Duration(i) * time.Second), Line: fmt.Sprintf("line %d", i)}))
+	}
+	require.NoError(t, mc.Close())
+
+	lbs := labels.Labels{{Name: "app", Value: "foo"}}
+	c := chunk.NewChunk("fake", model.Fingerprint(lbs.Hash()), lbs, chunkenc.NewFacade(mc, 0, 0), model.TimeFromUnixNano(from.UnixNano()), model.TimeFromUnixNano(from.Add(time.Minute).UnixNano()))
+	require.NoError(t, c.Encode())
+	buf, err := c.Encoded()
+	require.NoError(t, err)
+	return c.ExternalKey(), buf
+}
+
+func TestChunksCommand(t *testing.T) {
+	key, buf := testChunkObject(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, key)
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0750))
+	require.NoError(t, ioutil.WriteFile(file, buf, 0600))
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := runChunks(args, &out)
+		return out.String(), err
+	}
+
+	out, err := run("inspect", "-b", file)
+	require.NoError(t, err)
+	require.Contains(t, out, "UserID: fake")
+	require.Contains(t, out, "\t app = foo")
+	require.Contains(t, out, "Encoding: snappy")
+	require.Contains(t, out, "Block    0: offset:")
+	require.NotContains(t, out, "BAD")
+
+	out, err = run("cat", file)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 50)
+	require.Equal(t, "line 49", lines[49])
+
+	out, err = run("verify", file)
+	require.NoError(t, err)
+	require.Equal(t, file+": OK\n", out)
+
+	t.Run("filesystem object store", func(t *testing.T) {
+		storeDir := t.TempDir()
+		require.NoError(t, ioutil.WriteFile(filepath.Join(storeDir, objectclient.Base64Encoder(key)), buf, 0600))
+		configFile := filepath.Join(t.TempDir(), "loki.yaml")
+		require.NoError(t, ioutil.WriteFile(configFile, []byte(fmt.Sprintf("storage_config:\n  filesystem:\n    directory: %s\n", storeDir)), 0600))
+
+		out, err := run("verify", "-config.file", configFile, "-store", "filesystem", key)
+		require.NoError(t, err)
+		require.Equal(t, key+": OK\n", out)
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		corrupted := append([]byte{}, buf...)
+		corrupted[len(corrupted)-64] ^= 0xff
+		require.NoError(t, ioutil.WriteFile(file, corrupted, 0600))
+
+		out, err := run("verify", file)
+		require.EqualError(t, err, "1 of 1 chunks failed")
+		require.Contains(t, out, "does not match the checksum of its key")
+		require.Contains(t, out, "BAD")
+	})
+}
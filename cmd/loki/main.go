@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
 
@@ -21,6 +22,21 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "chunks" {
+		if err := runChunks(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rules" {
+		if err := runRules(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	var config loki.ConfigWrapper
 
 	if err := cfg.DynamicUnmarshal(&config, os.Args[1:], flag.CommandLine); err != nil {
@@ -90,9 +106,32 @@ func main() {
 	// Start Loki
 	t, err := loki.New(config.Config)
 	util_log.CheckFatal("initialising loki", err)
+	t.ConfigLoader = func() (loki.Config, error) {
+		return reloadConfig(os.Args[1:])
+	}
 
 	level.Info(util_log.Logger).Log("msg", "Starting Loki", "version", version.Info())
 
 	err = t.Run()
 	util_log.CheckFatal("running loki", err)
 }
+
+// reloadConfig parses and validates the configuration again for the /-/reload endpoint.
+func reloadConfig(args []string) (loki.Config, error) {
+	// Like on startup, limits missing from the config file default to their flag values.
+	var defaults validation.Limits
+	defaultsFS := flag.NewFlagSet("", flag.ContinueOnError)
+	defaults.RegisterFlags(defaultsFS)
+	validation.SetDefaultLimitsForYAMLUnmarshalling(defaults)
+
+	var config loki.ConfigWrapper
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := cfg.DynamicUnmarshal(&config, args, fs); err != nil {
+		return loki.Config{}, fmt.Errorf("failed parsing config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return loki.Config{}, fmt.Errorf("validating config: %w", err)
+	}
+	return config.Config, nil
+}
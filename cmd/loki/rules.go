@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/exemplar"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/ruler"
+)
+
+const rulesUsage = `Usage: loki rules <command> [flags] <file>...
+
+Commands:
+  test   run the unit tests of rule files against fixture log lines
+
+Run loki rules <command> -h for the flags of a command.
+`
+
+// rulesTestTenant is the tenant the rules are evaluated for.
+const rulesTestTenant = "fake"
+
+// runRules runs the rules subcommand, which works with rule files locally.
+func runRules(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Fprint(stdout, rulesUsage)
+		return nil
+	}
+
+	fs := flag.NewFlagSet("loki rules "+args[0], flag.ContinueOnError)
+	var run *regexp.Regexp
+	switch args[0] {
+	case "test":
+		fs.Func("run", "Only run the tests whose name matches the regular expression.", func(s string) (err error) {
+			run, err = regexp.Compile(s)
+			return err
+		})
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], rulesUsage)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no test file given\n\n%s", rulesUsage)
+	}
+
+	failed := 0
+	for _, name := range fs.Args() {
+		errs := runRulesTestFile(name, run)
+		if len(errs) == 0 {
+			fmt.Fprintf(stdout, "%s: SUCCESS\n", name)
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: FAILED\n", name)
+		for _, err := range errs {
+			fmt.Fprintf(stdout, "  %v\n", err)
+		}
+		failed++
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d test files failed", failed, fs.NArg())
+	}
+	return nil
+}
+
+// rulesTestFile is a unit test file of rules, in the format of the Prometheus ones with
+// input streams of log lines instead of input series.
+type rulesTestFile struct {
+	RuleFiles          []string        `yaml:"rule_files"`
+	EvaluationInterval model.Duration  `yaml:"evaluation_interval"`
+	Tests              []rulesTestCase `yaml:"tests"`
+}
+
+type rulesTestCase struct {
+	Name           string          `yaml:"name"`
+	InputStreams   []inputStream   `yaml:"input_streams"`
+	AlertRuleTests []alertRuleTest `yaml:"alert_rule_test"`
+	LogQLExprTests []logqlExprTest `yaml:"logql_expr_test"`
+}
+
+// inputStream is a stream of log lines, such as {app="foo"}, the rules are evaluated against.
+type inputStream struct {
+	Stream  string       `yaml:"stream"`
+	Entries []inputEntry `yaml:"entries"`
+}
+
+// inputEntry is a log line at the At offset from the start of the test, repeated Count
+// times Every apart.
+type inputEntry struct {
+	At    model.Duration `yaml:"at"`
+	Line  string         `yaml:"line"`
+	Count int            `yaml:"count"`
+	Every model.Duration `yaml:"every"`
+}
+
+type alertRuleTest struct {
+	EvalTime  model.Duration `yaml:"eval_time"`
+	Alertname string         `yaml:"alertname"`
+	ExpAlerts []expAlert     `yaml:"exp_alerts"`
+}
+
+type expAlert struct {
+	ExpLabels      map[string]string `yaml:"exp_labels"`
+	ExpAnnotations map[string]string `yaml:"exp_annotations"`
+}
+
+type logqlExprTest struct {
+	Expr       string         `yaml:"expr"`
+	EvalTime   model.Duration `yaml:"eval_time"`
+	ExpSamples []expSample    `yaml:"exp_samples"`
+}
+
+type expSample struct {
+	Labels string  `yaml:"labels"`
+	Value  float64 `yaml:"value"`
+}
+
+func runRulesTestFile(name string, run *regexp.Regexp) []error {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return []error{err}
+	}
+	tf := rulesTestFile{EvaluationInterval: model.Duration(time.Minute)}
+	if err := yaml.UnmarshalStrict(b, &tf); err != nil {
+		return []error{err}
+	}
+	if tf.EvaluationInterval <= 0 {
+		return []error{errors.New("evaluation_interval must be greater than 0")}
+	}
+	// the rule files are relative to the test file.
+	ruleFiles := make([]string, 0, len(tf.RuleFiles))
+	for _, f := range tf.RuleFiles {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(filepath.Dir(name), f)
+		}
+		ruleFiles = append(ruleFiles, f)
+	}
+
+	var errs []error
+	for i, tc := range tf.Tests {
+		if run != nil && !run.MatchString(tc.Name) {
+			continue
+		}
+		for _, err := range tc.run(ruleFiles, time.Duration(tf.EvaluationInterval)) {
+			if tc.Name != "" {
+				err = errors.Wrapf(err, "test %q", tc.Name)
+			} else {
+				err = errors.Wrapf(err, "test %d", i)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// run evaluates the rules from the start of the test up to the last eval_time, checking the
+// alerts and expressions at their eval_time.
+func (tc *rulesTestCase) run(ruleFiles []string, interval time.Duration) []error {
+	streams, err := tc.streams(interval)
+	if err != nil {
+		return []error{err}
+	}
+	engine := logql.NewEngine(logql.EngineOpts{}, logql.NewMockQuerier(0, streams), logql.NoLimits)
+	queryFunc := rulesTestQueryFunc(engine)
+	ctx := user.InjectOrgID(context.Background(), rulesTestTenant)
+
+	mgr := rules.NewManager(&rules.ManagerOptions{
+		QueryFunc:   queryFunc,
+		NotifyFunc:  func(context.Context, string, ...*rules.Alert) {},
+		Context:     ctx,
+		Appendable:  nopAppendable{},
+		ExternalURL: &url.URL{},
+		Logger:      log.NewNopLogger(),
+		GroupLoader: ruler.GroupLoader{},
+	})
+	groupsMap, errs := mgr.LoadGroups(interval, nil, "", ruleFiles...)
+	if errs != nil {
+		return errs
+	}
+	// the groups are evaluated in the same order on every run.
+	groups := make([]*rules.Group, 0, len(groupsMap))
+	for _, g := range groupsMap {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].File() != groups[j].File() {
+			return groups[i].File() < groups[j].File()
+		}
+		return groups[i].Name() < groups[j].Name()
+	})
+
+	start := time.Unix(0, 0).UTC()
+	var maxEvalTime time.Duration
+	for _, at := range tc.AlertRuleTests {
+		if d := time.Duration(at.EvalTime); d > maxEvalTime {
+			maxEvalTime = d
+		}
+	}
+
+	var testErrs []error
+	for ts := start; !ts.After(start.Add(maxEvalTime)); ts = ts.Add(interval) {
+		for _, g := range groups {
+			g.Eval(ctx, ts)
+			for _, r := range g.Rules() {
+				if err := r.LastError(); err != nil {
+					return append(testErrs, errors.Wrapf(err, "evaluating rule %s at %v", r.Name(), ts.Sub(start)))
+				}
+			}
+		}
+		for _, at := range tc.AlertRuleTests {
+			evalTime := start.Add(time.Duration(at.EvalTime))
+			if evalTime.Before(ts) || !evalTime.Before(ts.Add(interval)) {
+				continue
+			}
+			if err := at.check(groups); err != nil {
+				testErrs = append(testErrs, err)
+			}
+		}
+	}
+
+	for _, et := range tc.LogQLExprTests {
+		if err := et.check(ctx, queryFunc, start); err != nil {
+			testErrs = append(testErrs, err)
+		}
+	}
+	return testErrs
+}
+
+// streams returns the input streams, the entries of streams with the same labels merged.
+func (tc *rulesTestCase) streams(interval time.Duration) ([]logproto.Stream, error) {
+	byLabels := map[string]int{}
+	var result []logproto.Stream
+	for _, s := range tc.InputStreams {
+		lbs, err := logql.ParseLabels(s.Stream)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid input stream %q", s.Stream)
+		}
+		idx, ok := byLabels[lbs.String()]
+		if !ok {
+			idx = len(result)
+			result = append(result, logproto.Stream{Labels: lbs.String()})
+			byLabels[lbs.String()] = idx
+		}
+		stream := &result[idx]
+		for _, e := range s.Entries {
+			count, every := e.Count, time.Duration(e.Every)
+			if count == 0 {
+				count = 1
+			}
+			if every == 0 {
+				every = interval
+			}
+			for i := 0; i < count; i++ {
+				stream.Entries = append(stream.Entries, logproto.Entry{
+					Timestamp: time.Unix(0, 0).Add(time.Duration(e.At) + time.Duration(i)*every),
+					Line:      e.Line,
+				})
+			}
+		}
+	}
+	for i := range result {
+		entries := result[i].Entries
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+	}
+	return result, nil
+}
+
+// check compares the firing alerts of the alert rule with the expected ones.
+func (at *alertRuleTest) check(groups []*rules.Group) error {
+	var got []string
+	for _, g := range groups {
+		for _, ar := range g.AlertingRules() {
+			if ar.Name() != at.Alertname {
+				continue
+			}
+			for _, a := range ar.ActiveAlerts() {
+				if a.State == rules.StateFiring {
+					got = append(got, formatAlert(a.Labels, a.Annotations))
+				}
+			}
+		}
+	}
+
+	exp := make([]string, 0, len(at.ExpAlerts))
+	for _, a := range at.ExpAlerts {
+		// the alertname label is added to the alerts by the evaluation.
+		lbs := labels.NewBuilder(labels.FromMap(a.ExpLabels)).Set(labels.AlertName, at.Alertname).Labels()
+		exp = append(exp, formatAlert(lbs, labels.FromMap(a.ExpAnnotations)))
+	}
+
+	if !equalSorted(exp, got) {
+		return fmt.Errorf("alertname: %s, time: %v,\n    exp: %s,\n    got: %s", at.Alertname, at.EvalTime, formatList(exp), formatList(got))
+	}
+	return nil
+}
+
+func formatAlert(lbs, annotations labels.Labels) string {
+	return fmt.Sprintf("{labels: %s, annotations: %s}", lbs, annotations)
+}
+
+// check compares the result of the expression at its eval_time with the expected samples.
+func (et *logqlExprTest) check(ctx context.Context, queryFunc rules.QueryFunc, start time.Time) error {
+	vec, err := queryFunc(ctx, et.Expr, start.Add(time.Duration(et.EvalTime)))
+	if err != nil {
+		return errors.Wrapf(err, "expr: %q, time: %v", et.Expr, et.EvalTime)
+	}
+	got := make([]string, 0, len(vec))
+	for _, s := range vec {
+		got = append(got, formatSample(s.Metric, s.V))
+	}
+
+	exp := make([]string, 0, len(et.ExpSamples))
+	for _, s := range et.ExpSamples {
+		lbs := labels.Labels{}
+		if s.Labels != "" {
+			if lbs, err = logql.ParseLabels(s.Labels); err != nil {
+				return errors.Wrapf(err, "expr: %q, invalid labels %q", et.Expr, s.Labels)
+			}
+		}
+		exp = append(exp, formatSample(lbs, s.Value))
+	}
+
+	if !equalSorted(exp, got) {
+		return fmt.Errorf("expr: %q, time: %v,\n    exp: %s,\n    got: %s", et.Expr, et.EvalTime, formatList(exp), formatList(got))
+	}
+	return nil
+}
+
+func formatSample(lbs labels.Labels, v float64) string {
+	return fmt.Sprintf("%s %g", lbs, v)
+}
+
+func equalSorted(exp, got []string) bool {
+	sort.Strings(exp)
+	sort.Strings(got)
+	if len(exp) != len(got) {
+		return false
+	}
+	for i := range exp {
+		if exp[i] != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func formatList(l []string) string {
+	if len(l) == 0 {
+		return "[]"
+	}
+	return "[" + strings.Join(l, ", ") + "]"
+}
+
+// rulesTestQueryFunc evaluates the rule expressions as instant queries, as the ruler does.
+func rulesTestQueryFunc(engine *logql.Engine) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		params := logql.NewLiteralParams(qs, t, t, 0, 0, logproto.FORWARD, 0, nil)
+		res, err := engine.Query(params).Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch v := res.Data.(type) {
+		case promql.Vector:
+			return v, nil
+		case promql.Scalar:
+			return promql.Vector{promql.Sample{Point: promql.Point(v), Metric: labels.Labels{}}}, nil
+		default:
+			return nil, errors.New("rule result is not a vector or scalar")
+		}
+	}
+}
+
+// nopAppendable drops the samples of the recording rules and of the alerts.
+type nopAppendable struct{}
+
+func (nopAppendable) Appender(context.Context) storage.Appender { return nopAppender{} }
+
+type nopAppender struct{}
+
+func (nopAppender) Append(uint64, labels.Labels, int64, float64) (uint64, error) { return 0, nil }
+
+func (nopAppender) AppendExemplar(uint64, labels.Labels, exemplar.Exemplar) (uint64, error) {
+	return 0, nil
+}
+
+func (nopAppender) Commit() error   { return nil }
+func (nopAppender) Rollback() error { return nil }
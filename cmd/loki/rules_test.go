@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testRules = `
+groups:
+  - name: app
+    rules:
+      - alert: HighErrorRate
+        expr: sum by (app) (count_over_time({app="foo"} |= "error" [5m])) > 2
+        for: 2m
+        labels:
+          severity: page
+        annotations:
+          summary: "{{ $labels.app }} logged {{ $value }} errors"
+      - record: app:errors:count5m
+        expr: sum by (app) (count_over_time({app="foo"} |= "error" [5m]))
+`
+
+const testRulesTests = `
+rule_files:
+  - rules.yaml
+evaluation_interval: 1m
+tests:
+  - name: errors
+    input_streams:
+      - stream: '{app="foo", pod="a"}'
+        entries:
+          - at: 1m
+            line: level=error msg="request failed"
+            count: 10
+      - stream: '{app="foo", pod="b"}'
+        entries:
+          - line: level=info msg="request done"
+            count: 10
+    alert_rule_test:
+      - eval_time: 3m
+        alertname: HighErrorRate
+      - eval_time: 5m
+        alertname: HighErrorRate
+        exp_alerts:
+          - exp_labels:
+              app: foo
+              severity: page
+            exp_annotations:
+              summary: foo logged 5 errors
+    logql_expr_test:
+      - expr: sum by (pod) (count_over_time({app="foo"}[5m]))
+        eval_time: 4m
+        exp_samples:
+          - labels: '{pod="a"}'
+            value: 4
+          - labels: '{pod="b"}'
+            value: 5
+      - expr: sum(count_over_time({app="foo"} |= "error" [1m]))
+        eval_time: 20m
+  - name: quiet
+    input_streams:
+      - stream: '{app="foo"}'
+        entries:
+          - line: level=error
+            every: 2m
+            count: 5
+    alert_rule_test:
+      - eval_time: 10m
+        alertname: HighErrorRate
+`
+
+func TestRulesCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(file, []byte(content), 0600))
+		return file
+	}
+	write("rules.yaml", testRules)
+	tests := write("tests.yaml", testRulesTests)
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := runRules(args, &out)
+		return out.String(), err
+	}
+
+	out, err := run("test", tests)
+	require.NoError(t, err, out)
+	require.Equal(t, tests+": SUCCESS\n", out)
+
+	// failing expectations are reported with the alerts and samples returned by Loki.
+	failing := write("failing.yaml", `
+rule_files: [rules.yaml]
+tests:
+  - name: wrong
+    input_streams:
+      - stream: '{app="foo"}'
+        entries:
+          - line: error
+            count: 10
+    alert_rule_test:
+      - eval_time: 1m
+        alertname: HighErrorRate
+        exp_alerts:
+          - exp_labels:
+              app: foo
+    logql_expr_test:
+      - expr: count_over_time({app="foo"}[1m])
+        eval_time: 1m
+        exp_samples:
+          - labels: '{app="foo"}'
+            value: 3
+`)
+	out, err = run("test", failing, tests)
+	require.EqualError(t, err, "1 of 2 test files failed")
+	require.Contains(t, out, failing+": FAILED\n")
+	require.Contains(t, out, `test "wrong": alertname: HighErrorRate, time: 1m,
+    exp: [{labels: {alertname="HighErrorRate", app="foo"}, annotations: {}}],
+    got: []`)
+	require.Contains(t, out, `test "wrong": expr: "count_over_time({app=\"foo\"}[1m])", time: 1m,
+    exp: [{app="foo"} 3],
+    got: [{app="foo"} 1]`)
+	require.Contains(t, out, tests+": SUCCESS\n")
+
+	// -run selects the tests by name.
+	out, err = run("test", "-run", "^quiet$", failing)
+	require.NoError(t, err)
+	require.Equal(t, failing+": SUCCESS\n", out)
+
+	invalid := write("invalid.yaml", `
+rule_files: [missing.yaml]
+tests:
+  - name: missing
+`)
+	out, err = run("test", invalid)
+	require.Error(t, err)
+	require.Contains(t, out, "missing.yaml: open")
+
+	_, err = run("lint", tests)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `unknown command "lint"`)
+}
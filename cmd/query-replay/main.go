@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/grafana/loki/pkg/lokifrontend/querylog"
+)
+
+func main() {
+	var (
+		logFile = flag.String("log-file", "", "Query log recorded by the query frontend (-frontend.query-log-file).")
+		target  = flag.String("target", "http://localhost:3100", "Address of the Loki cluster to replay the queries against.")
+		timeout = flag.Duration("timeout", 5*time.Minute, "Timeout of every replayed query.")
+		cfg     querylog.ReplayConfig
+	)
+	flag.IntVar(&cfg.Concurrency, "concurrency", 10, "Maximum number of queries in flight.")
+	flag.Float64Var(&cfg.Speed, "speed", 1, "Multiplier of the pace at which the queries were recorded, 0 replays them as fast as possible.")
+	flag.BoolVar(&cfg.ShiftTime, "shift-time", true, "Shift the time range of every query by the time elapsed since it was recorded.")
+	flag.StringVar(&cfg.Tenant, "tenant", "", "Replay all queries as this tenant instead of the recorded one.")
+	flag.Parse()
+
+	if *logFile == "" {
+		fmt.Fprintln(os.Stderr, "-log-file is required")
+		os.Exit(1)
+	}
+	u, err := url.Parse(*target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid target %q: %v\n", *target, err)
+		os.Exit(1)
+	}
+	cfg.Target = u
+
+	f, err := os.Open(*logFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open query log: %v\n", err)
+		os.Exit(1)
+	}
+	entries, err := querylog.ReadEntries(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read query log: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	replayer := querylog.NewReplayer(cfg, &http.Client{Timeout: *timeout})
+	results := replayer.Replay(ctx, entries)
+	fmt.Print(querylog.Summarize(results))
+}
@@ -0,0 +1,140 @@
+package chunkenc
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// maxDictContentSize is the size of the content of the dictionaries, the end of the first block.
+const maxDictContentSize = 64 << 10
+
+// blockDict is the zstd dictionary the blocks of an EncZstdDict chunk are compressed with.
+// Dictionaries are not trained across chunks: the first block of the chunk is compressed without
+// dictionary and its uncompressed content is used as a raw dictionary, so that chunks remain
+// self-contained and each stream gets dictionaries from its own lines. Machine logs repeat the
+// same structure from one block to the next and the next blocks compress better.
+type blockDict struct {
+	// first is the compressed content of the first block of the chunk.
+	first []byte
+
+	once sync.Once
+	id   uint32
+	dict []byte
+	// decoder decodes the blocks of the chunk, it is safe for concurrent use.
+	decoder *zstd.Decoder
+	err     error
+}
+
+func newBlockDict(first []byte) *blockDict {
+	return &blockDict{first: first}
+}
+
+// get returns the dictionary and its ID, building them and the decoder on first use.
+func (d *blockDict) get() (uint32, []byte, error) {
+	d.once.Do(func() {
+		content, err := decodeFirstBlock(d.first)
+		if err != nil {
+			d.err = err
+			return
+		}
+		// zstd favors the end of the dictionary, which holds the most recent lines.
+		if len(content) > maxDictContentSize {
+			content = content[len(content)-maxDictContentSize:]
+		}
+		d.dict = content
+		d.id = dictID(content)
+		if d.decoder, d.err = zstd.NewReader(nil, zstd.WithDecoderDictRaw(d.id, d.dict), zstd.WithDecoderConcurrency(1)); d.err != nil {
+			return
+		}
+		runtime.SetFinalizer(d.decoder, (*zstd.Decoder).Close)
+	})
+	return d.id, d.dict, d.err
+}
+
+func decodeFirstBlock(b []byte) ([]byte, error) {
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	return decoder.DecodeAll(b, nil)
+}
+
+// zstdDictPool compresses and decompresses the blocks using the dictionary.
+// Decoders and encoders are bound to their dictionaries: blocks, including the first one,
+// are decoded at once with the decoder of the dictionary and encoders are created for every block.
+type zstdDictPool struct {
+	dict *blockDict
+}
+
+// GetReader gets or creates a new CompressionReader and reset it to read from src
+func (pool zstdDictPool) GetReader(src io.Reader) io.Reader {
+	if _, _, err := pool.dict.get(); err != nil {
+		return errReader{err}
+	}
+	compressed, err := ioutil.ReadAll(src)
+	if err != nil {
+		return errReader{err}
+	}
+	b, err := pool.dict.decoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return errReader{err}
+	}
+	return bytes.NewReader(b)
+}
+
+// PutReader is a no-op, blocks are decoded at once.
+func (pool zstdDictPool) PutReader(reader io.Reader) {}
+
+// GetWriter creates a new CompressionWriter writing to dst
+func (pool zstdDictPool) GetWriter(dst io.Writer) io.WriteCloser {
+	id, dict, err := pool.dict.get()
+	if err != nil {
+		return errWriter{err}
+	}
+	// the first level finds the most matches in the dictionary, the default one barely uses it.
+	w, err := zstd.NewWriter(dst, zstd.WithEncoderDictRaw(id, dict), zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedFastest))
+	if err != nil {
+		return errWriter{err}
+	}
+	return w
+}
+
+// PutWriter is a no-op, writers are closed once the block is written.
+func (pool zstdDictPool) PutWriter(writer io.WriteCloser) {}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(_ []byte) (int, error) {
+	return 0, r.err
+}
+
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write(_ []byte) (int, error) {
+	return 0, w.err
+}
+
+func (w errWriter) Close() error {
+	return w.err
+}
+
+// dictID returns the ID of the dictionary with the given content, frames record the ID of the
+// dictionary they are compressed with. Zero means no dictionary.
+func dictID(content []byte) uint32 {
+	id := crc32.ChecksumIEEE(content)
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
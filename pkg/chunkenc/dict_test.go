@@ -0,0 +1,82 @@
+package chunkenc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func TestZstdDict(t *testing.T) {
+	const (
+		blockSize  = 4 << 10
+		numEntries = 5000
+	)
+	line := func(i int) string {
+		return fmt.Sprintf(`level=info ts=2021-11-02T10:%02d:%02d.%03dZ caller=http.go:194 org_id=29 traceID=%x msg="GET /loki/api/v1/query_range (200) %dms"`, i/3600%60, i/60%60, i%1000, i*7919, i%250)
+	}
+	size := func(enc Encoding) (int, []byte) {
+		chk := NewMemChunk(enc, DefaultHeadBlockFmt, blockSize, 0)
+		for i := 0; i < numEntries; i++ {
+			require.NoError(t, chk.Append(logprotoEntry(int64(i), line(i))))
+		}
+		require.NoError(t, chk.Close())
+		require.Greater(t, len(chk.blocks), 10)
+		b, err := chk.Bytes()
+		require.NoError(t, err)
+		return chk.CompressedSize(), b
+	}
+
+	zstdSize, _ := size(EncZstd)
+	dictSize, b := size(EncZstdDict)
+	require.Less(t, dictSize, zstdSize)
+
+	chk, err := NewByteChunk(b, blockSize, 0)
+	require.NoError(t, err)
+	it, err := chk.Iterator(context.Background(), time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD, noopStreamPipeline)
+	require.NoError(t, err)
+	for i := 0; i < numEntries; i++ {
+		require.True(t, it.Next())
+		require.Equal(t, line(i), it.Entry().Line)
+	}
+	require.False(t, it.Next())
+	require.NoError(t, it.Error())
+}
+
+func TestZstdDictPool(t *testing.T) {
+	random := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(random)
+	input := []byte(strings.Repeat(`level=info msg="request" path=/loki/api/v1/push status=204 `, 100))
+
+	encoder, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	defer encoder.Close()
+	for _, content := range [][]byte{nil, []byte("a"), random, input} {
+		pool := zstdDictPool{dict: newBlockDict(encoder.EncodeAll(content, nil))}
+
+		var compressed bytes.Buffer
+		w := pool.GetWriter(&compressed)
+		_, err := w.Write(input)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		output, err := ioutil.ReadAll(pool.GetReader(&compressed))
+		require.NoError(t, err)
+		require.Equal(t, input, output)
+	}
+
+	// a first block which can't be decoded fails the writes instead of panicking.
+	pool := zstdDictPool{dict: newBlockDict([]byte("not zstd"))}
+	_, err = pool.GetWriter(&bytes.Buffer{}).Write(input)
+	require.Error(t, err)
+}
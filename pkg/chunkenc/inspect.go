@@ -0,0 +1,169 @@
+package chunkenc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/grafana/loki/pkg/logql/log"
+)
+
+// ChunkInfo describes the encoding and the blocks of a chunk, for inspecting chunks.
+// Unlike NewByteChunk, blocks with an invalid checksum are not skipped.
+type ChunkInfo struct {
+	Format   byte
+	Encoding Encoding
+
+	MetadataChecksum         uint32
+	ComputedMetadataChecksum uint32
+
+	Blocks []BlockInfo
+
+	// dict is the dictionary of the blocks of EncZstdDict chunks.
+	dict *blockDict
+}
+
+// BlockInfo describes a block of a chunk.
+type BlockInfo struct {
+	Offset           int
+	Entries          int
+	MinTime, MaxTime int64
+	// UncompressedSize is the size of the lines of the block, stored in the metadata of v3
+	// chunks and later, 0 before.
+	UncompressedSize int
+
+	Checksum         uint32
+	ComputedChecksum uint32
+
+	// Compressed is the block as stored in the chunk, Uncompressed its decompressed content.
+	Compressed   []byte
+	Uncompressed []byte
+
+	// DecodedEntries is the number of entries decoded from the block, OutOfBounds the
+	// number of them outside of the block time range.
+	DecodedEntries int
+	OutOfBounds    int
+	// Err is the error decoding the block, if any.
+	Err error
+}
+
+// Valid returns whether the checksums of the chunk and of its blocks match and its blocks
+// can be decoded.
+func (c *ChunkInfo) Valid() bool {
+	if c.MetadataChecksum != c.ComputedMetadataChecksum {
+		return false
+	}
+	for _, b := range c.Blocks {
+		if !b.Valid() {
+			return false
+		}
+	}
+	return true
+}
+
+// Valid returns whether the checksum of the block matches and its entries can be decoded.
+func (b *BlockInfo) Valid() bool {
+	return b.Checksum == b.ComputedChecksum && b.Err == nil && b.DecodedEntries == b.Entries && b.OutOfBounds == 0
+}
+
+// Entries calls f with the timestamp and the line of each entry of the i-th block, including
+// the entries decoded before an error if the block can't be decoded entirely.
+func (c *ChunkInfo) Entries(i int, f func(ts int64, line string)) error {
+	b := c.Blocks[i]
+	blk := encBlock{enc: c.Encoding, format: c.Format, block: block{b: b.Compressed, numEntries: b.Entries, mint: b.MinTime, maxt: b.MaxTime, offset: b.Offset}}
+	blk.dict = c.dict
+	it := blk.Iterator(context.Background(), log.NewNoopPipeline().ForStream(labels.Labels{}))
+	defer it.Close()
+	for it.Next() {
+		e := it.Entry()
+		f(e.Timestamp.UnixNano(), e.Line)
+	}
+	return it.Error()
+}
+
+// Inspect decodes the encoding and the blocks of a chunk, without skipping invalid blocks.
+func Inspect(b []byte) (*ChunkInfo, error) {
+	db := decbuf{b: b}
+	m, version := db.be32(), db.byte()
+	if db.err() != nil {
+		return nil, errors.Wrap(db.err(), "verifying header")
+	}
+	if m != magicNumber {
+		return nil, errors.Errorf("invalid magic number %x", m)
+	}
+	info := &ChunkInfo{Format: version}
+	switch version {
+	case chunkFormatV1:
+		info.Encoding = EncGZIP
+	case chunkFormatV2, chunkFormatV3, chunkFormatV4:
+		info.Encoding = Encoding(db.byte())
+		if db.err() != nil {
+			return nil, errors.Wrap(db.err(), "verifying encoding")
+		}
+	default:
+		return nil, errors.Errorf("invalid version %d", version)
+	}
+
+	header := len(b) - len(db.b)
+	if len(b) < header+8+4 {
+		return nil, errors.New("chunk too short")
+	}
+	metasOffset := binary.BigEndian.Uint64(b[len(b)-8:])
+	if metasOffset < uint64(header) || metasOffset > uint64(len(b)-(8+4)) {
+		return nil, errors.Errorf("invalid metadata offset %d", metasOffset)
+	}
+	mb := b[metasOffset : len(b)-(8+4)]
+	db = decbuf{b: mb}
+	info.MetadataChecksum = binary.BigEndian.Uint32(b[len(b)-(8+4):])
+	info.ComputedMetadataChecksum = db.crc32()
+
+	num := db.uvarint()
+	for i := 0; i < num; i++ {
+		var blk BlockInfo
+		blk.Entries = db.uvarint()
+		blk.MinTime = db.varint64()
+		blk.MaxTime = db.varint64()
+		blk.Offset = db.uvarint()
+		if version >= chunkFormatV3 {
+			blk.UncompressedSize = db.uvarint()
+		}
+		l := db.uvarint()
+		if db.err() != nil {
+			return nil, errors.Wrap(db.err(), "decoding block meta")
+		}
+		if blk.Offset < header || l < 0 || blk.Offset+l+4 > int(metasOffset) {
+			return nil, errors.Errorf("block %d out of the chunk: offset %d, length %d", i, blk.Offset, l)
+		}
+		blk.Compressed = b[blk.Offset : blk.Offset+l]
+		blk.Checksum = binary.BigEndian.Uint32(b[blk.Offset+l:])
+		blk.ComputedChecksum = crc32.Checksum(blk.Compressed, castagnoliTable)
+
+		info.Blocks = append(info.Blocks, blk)
+		if i == 0 && info.Encoding == EncZstdDict {
+			info.dict = newBlockDict(blk.Compressed)
+		}
+	}
+
+	for i := range info.Blocks {
+		blk := &info.Blocks[i]
+		pool := encBlock{enc: info.Encoding, block: block{dict: info.dict}}.readerPool()
+		r := pool.GetReader(bytes.NewReader(blk.Compressed))
+		blk.Uncompressed, blk.Err = ioutil.ReadAll(r)
+		pool.PutReader(r)
+		if blk.Err != nil {
+			continue
+		}
+		blk.Err = info.Entries(i, func(ts int64, _ string) {
+			blk.DecodedEntries++
+			if ts < blk.MinTime || ts > blk.MaxTime {
+				blk.OutOfBounds++
+			}
+		})
+	}
+	return info, nil
+}
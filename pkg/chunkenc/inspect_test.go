@@ -0,0 +1,71 @@
+package chunkenc
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	for _, enc := range testEncoding {
+		enc := enc
+		t.Run(enc.String(), func(t *testing.T) {
+			chk := NewMemChunk(enc, DefaultHeadBlockFmt, 1024, testTargetSize)
+			for i := 0; i < 200; i++ {
+				require.NoError(t, chk.Append(logprotoEntry(int64(i+1), fmt.Sprintf("line %d of the chunk", i))))
+			}
+			require.NoError(t, chk.Close())
+			b, err := chk.Bytes()
+			require.NoError(t, err)
+
+			info, err := Inspect(b)
+			require.NoError(t, err)
+			require.Equal(t, enc, info.Encoding)
+			require.Equal(t, DefaultChunkFormat, info.Format)
+			require.True(t, info.Valid())
+			require.Len(t, info.Blocks, chk.BlockCount())
+
+			var lines []string
+			for i, blk := range info.Blocks {
+				size := 0
+				require.NoError(t, info.Entries(i, func(ts int64, line string) {
+					lines = append(lines, line)
+					size += len(line)
+				}))
+				require.Equal(t, blk.UncompressedSize, size)
+				require.Greater(t, len(blk.Uncompressed), size)
+			}
+			require.Len(t, lines, 200)
+			require.Equal(t, "line 199 of the chunk", lines[199])
+
+			// A corrupted block is reported, not skipped.
+			blk := info.Blocks[len(info.Blocks)-1]
+			b[blk.Offset+len(blk.Compressed)/2] ^= 0xff
+			info, err = Inspect(b)
+			require.NoError(t, err)
+			require.Len(t, info.Blocks, chk.BlockCount())
+			require.False(t, info.Valid())
+			require.NotEqual(t, info.Blocks[len(info.Blocks)-1].Checksum, info.Blocks[len(info.Blocks)-1].ComputedChecksum)
+		})
+	}
+}
+
+func TestInspectInvalidChunks(t *testing.T) {
+	chk := NewMemChunk(EncSnappy, DefaultHeadBlockFmt, testBlockSize, testTargetSize)
+	require.NoError(t, chk.Append(logprotoEntry(1, "1")))
+	b, err := chk.Bytes()
+	require.NoError(t, err)
+
+	for name, corrupt := range map[string][]byte{
+		"empty":           {},
+		"magic number":    append([]byte{0, 0, 0, 0}, b[4:]...),
+		"truncated":       b[:len(b)/2],
+		"metadata offset": append(append([]byte{}, b[:len(b)-8]...), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Inspect(corrupt)
+			require.Error(t, err)
+		})
+	}
+}
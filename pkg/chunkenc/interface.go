@@ -43,6 +43,7 @@ const (
 	EncLZ4_4M
 	EncFlate
 	EncZstd
+	EncZstdDict
 )
 
 var supportedEncoding = []Encoding{
@@ -55,6 +56,7 @@ var supportedEncoding = []Encoding{
 	EncLZ4_4M,
 	EncFlate,
 	EncZstd,
+	EncZstdDict,
 }
 
 func (e Encoding) String() string {
@@ -79,6 +81,8 @@ func (e Encoding) String() string {
 		return "flate"
 	case EncZstd:
 		return "zstd"
+	case EncZstdDict:
+		return "zstd-dict"
 	default:
 		return "unknown"
 	}
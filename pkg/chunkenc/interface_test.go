@@ -13,6 +13,7 @@ func TestParseEncoding(t *testing.T) {
 		wantErr bool
 	}{
 		{"gzip", EncGZIP, false},
+		{"zstd-dict", EncZstdDict, false},
 		{"bad", 0, true},
 	}
 	for _, tt := range tests {
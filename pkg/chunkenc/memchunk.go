@@ -32,6 +32,8 @@ const (
 	chunkFormatV1
 	chunkFormatV2
 	chunkFormatV3
+	// chunkFormatV4 stores the structured metadata of the entries in the blocks, after their line.
+	chunkFormatV4
 
 	DefaultChunkFormat = chunkFormatV3 // the currently used chunk format
 
@@ -44,7 +46,7 @@ const (
 	defaultBlockSize = 256 * 1024
 )
 
-var HeadBlockFmts = []HeadBlockFmt{OrderedHeadBlockFmt, UnorderedHeadBlockFmt}
+var HeadBlockFmts = []HeadBlockFmt{OrderedHeadBlockFmt, UnorderedHeadBlockFmt, UnorderedWithStructuredMetadataHeadBlockFmt}
 
 type HeadBlockFmt byte
 
@@ -56,6 +58,8 @@ func (f HeadBlockFmt) String() string {
 		return "ordered"
 	case f == UnorderedHeadBlockFmt:
 		return "unordered"
+	case f == UnorderedWithStructuredMetadataHeadBlockFmt:
+		return "unordered with structured metadata"
 	default:
 		return fmt.Sprintf("unknown: %v", byte(f))
 	}
@@ -66,10 +70,18 @@ func (f HeadBlockFmt) NewBlock() HeadBlock {
 	case f < UnorderedHeadBlockFmt:
 		return &headBlock{}
 	default:
-		return newUnorderedHeadBlock()
+		return newUnorderedHeadBlock(f)
 	}
 }
 
+// chunkFormat returns the format of the chunks cut from head blocks of the format.
+func (f HeadBlockFmt) chunkFormat() byte {
+	if f == UnorderedWithStructuredMetadataHeadBlockFmt {
+		return chunkFormatV4
+	}
+	return DefaultChunkFormat
+}
+
 const (
 	_ HeadBlockFmt = iota
 	// placeholders to start splitting chunk formats vs head block
@@ -78,6 +90,9 @@ const (
 	_
 	OrderedHeadBlockFmt
 	UnorderedHeadBlockFmt
+	// UnorderedWithStructuredMetadataHeadBlockFmt is the unordered head block keeping the
+	// structured metadata of the entries, of chunks with the v4 format.
+	UnorderedWithStructuredMetadataHeadBlockFmt
 )
 
 var magicNumber = uint32(0x12EE56A)
@@ -127,6 +142,10 @@ type block struct {
 
 	offset           int // The offset of the block in the chunk.
 	uncompressedSize int // Total uncompressed size in bytes when the chunk is cut.
+
+	// dict is the dictionary of the blocks of EncZstdDict chunks, nil for other encodings.
+	// Every block but the first one is compressed with it.
+	dict *blockDict
 }
 
 // This block holds the un-compressed entries. Once it has enough data, this is
@@ -160,7 +179,8 @@ func (hb *headBlock) Reset() {
 
 func (hb *headBlock) Bounds() (int64, int64) { return hb.mint, hb.maxt }
 
-func (hb *headBlock) Append(ts int64, line string) error {
+// Append appends an entry, the ordered head block doesn't keep structured metadata.
+func (hb *headBlock) Append(ts int64, line string, _ []logproto.LabelPair) error {
 	if !hb.IsEmpty() && hb.maxt > ts {
 		return ErrOutOfOrder
 	}
@@ -316,10 +336,10 @@ func (hb *headBlock) Convert(version HeadBlockFmt) (HeadBlock, error) {
 	if version < UnorderedHeadBlockFmt {
 		return hb, nil
 	}
-	out := newUnorderedHeadBlock()
+	out := newUnorderedHeadBlock(version)
 
 	for _, e := range hb.entries {
-		if err := out.Append(e.t, e.s); err != nil {
+		if err := out.Append(e.t, e.s, nil); err != nil {
 			return nil, err
 		}
 	}
@@ -331,6 +351,31 @@ type entry struct {
 	s string
 }
 
+// metadataSize returns the size of the names and values of structured metadata.
+func metadataSize(metadata []logproto.LabelPair) int {
+	size := 0
+	for _, m := range metadata {
+		size += len(m.Name) + len(m.Value)
+	}
+	return size
+}
+
+// writeMetadata writes structured metadata as it is stored after the line of an entry in the
+// blocks of the v4 chunk format: the number of pairs, then the length prefixed name and value
+// of each pair.
+func writeMetadata(buf *bytes.Buffer, encBuf []byte, metadata []logproto.LabelPair) {
+	n := binary.PutUvarint(encBuf, uint64(len(metadata)))
+	buf.Write(encBuf[:n])
+	for _, m := range metadata {
+		n = binary.PutUvarint(encBuf, uint64(len(m.Name)))
+		buf.Write(encBuf[:n])
+		buf.WriteString(m.Name)
+		n = binary.PutUvarint(encBuf, uint64(len(m.Value)))
+		buf.Write(encBuf[:n])
+		buf.WriteString(m.Value)
+	}
+}
+
 // NewMemChunk returns a new in-mem chunk.
 func NewMemChunk(enc Encoding, head HeadBlockFmt, blockSize, targetSize int) *MemChunk {
 	return &MemChunk{
@@ -338,7 +383,7 @@ func NewMemChunk(enc Encoding, head HeadBlockFmt, blockSize, targetSize int) *Me
 		targetSize: targetSize, // Desired chunk size in compressed bytes
 		blocks:     []block{},
 
-		format: DefaultChunkFormat,
+		format: head.chunkFormat(),
 		head:   head.NewBlock(),
 
 		encoding: enc,
@@ -367,7 +412,7 @@ func NewByteChunk(b []byte, blockSize, targetSize int) (*MemChunk, error) {
 	switch version {
 	case chunkFormatV1:
 		bc.encoding = EncGZIP
-	case chunkFormatV2, chunkFormatV3:
+	case chunkFormatV2, chunkFormatV3, chunkFormatV4:
 		// format v2+ has a byte for block encoding.
 		enc := Encoding(db.byte())
 		if db.err() != nil {
@@ -390,6 +435,7 @@ func NewByteChunk(b []byte, blockSize, targetSize int) (*MemChunk, error) {
 	// Read the number of blocks.
 	num := db.uvarint()
 	bc.blocks = make([]block, 0, num)
+	var dict *blockDict
 
 	for i := 0; i < num; i++ {
 		var blk block
@@ -402,11 +448,17 @@ func NewByteChunk(b []byte, blockSize, targetSize int) (*MemChunk, error) {
 
 		// Read offset and length.
 		blk.offset = db.uvarint()
-		if version == chunkFormatV3 {
+		if version >= chunkFormatV3 {
 			blk.uncompressedSize = db.uvarint()
 		}
 		l := db.uvarint()
 		blk.b = b[blk.offset : blk.offset+l]
+		if bc.encoding == EncZstdDict {
+			if i == 0 {
+				dict = newBlockDict(blk.b)
+			}
+			blk.dict = dict
+		}
 
 		// Verify checksums.
 		expCRC := binary.BigEndian.Uint32(b[blk.offset+l:])
@@ -461,7 +513,7 @@ func (c *MemChunk) BytesSize() int {
 		size += binary.MaxVarintLen64 // mint
 		size += binary.MaxVarintLen64 // maxt
 		size += binary.MaxVarintLen32 // offset
-		if c.format == chunkFormatV3 {
+		if c.format >= chunkFormatV3 {
 			size += binary.MaxVarintLen32 // uncompressed size
 		}
 		size += binary.MaxVarintLen32 // len(b)
@@ -535,7 +587,7 @@ func (c *MemChunk) WriteTo(w io.Writer) (int64, error) {
 		eb.putVarint64(b.mint)
 		eb.putVarint64(b.maxt)
 		eb.putUvarint(b.offset)
-		if c.format == chunkFormatV3 {
+		if c.format >= chunkFormatV3 {
 			eb.putUvarint(b.uncompressedSize)
 		}
 		eb.putUvarint(len(b.b))
@@ -590,6 +642,7 @@ func MemchunkFromCheckpoint(chk, head []byte, desired HeadBlockFmt, blockSize in
 	if err != nil {
 		return nil, err
 	}
+	desired = mc.compatibleHeadFmt(desired)
 	h, err := HeadFromCheckpoint(head, desired)
 	if err != nil {
 		return nil, err
@@ -628,6 +681,9 @@ func (c *MemChunk) SpaceFor(e *logproto.Entry) bool {
 		// This is looking to see if the uncompressed lines will fit which is not
 		// a great check, but it will guarantee we are always under the target size
 		newHBSize := c.head.UncompressedSize() + len(e.Line)
+		if c.format >= chunkFormatV4 {
+			newHBSize += metadataSize(e.StructuredMetadata)
+		}
 		return (c.cutBlockSize + newHBSize) < c.targetSize
 	}
 	// if targetSize is not defined, default to the original behavior of fixed blocks per chunk
@@ -675,7 +731,7 @@ func (c *MemChunk) Append(entry *logproto.Entry) error {
 		return ErrOutOfOrder
 	}
 
-	if err := c.head.Append(entryTimestamp, entry.Line); err != nil {
+	if err := c.head.Append(entryTimestamp, entry.Line, entry.StructuredMetadata); err != nil {
 		return err
 	}
 
@@ -723,6 +779,7 @@ func (c *MemChunk) reorder() error {
 }
 
 func (c *MemChunk) ConvertHead(desired HeadBlockFmt) error {
+	desired = c.compatibleHeadFmt(desired)
 	if c.head != nil && c.head.Format() != desired {
 		newH, err := c.head.Convert(desired)
 		if err != nil {
@@ -735,16 +792,36 @@ func (c *MemChunk) ConvertHead(desired HeadBlockFmt) error {
 	return nil
 }
 
+// compatibleHeadFmt returns the head block format closest to desired whose blocks can be cut into
+// the chunk: only the head blocks keeping structured metadata write the blocks of v4 chunks.
+func (c *MemChunk) compatibleHeadFmt(desired HeadBlockFmt) HeadBlockFmt {
+	switch {
+	case c.format >= chunkFormatV4:
+		return UnorderedWithStructuredMetadataHeadBlockFmt
+	case desired == UnorderedWithStructuredMetadataHeadBlockFmt:
+		return UnorderedHeadBlockFmt
+	default:
+		return desired
+	}
+}
+
 // cut a new block and add it to finished blocks.
 func (c *MemChunk) cut() error {
 	if c.head.IsEmpty() {
 		return nil
 	}
 
-	b, err := c.head.Serialise(getWriterPool(c.encoding))
+	var dict *blockDict
+	if c.encoding == EncZstdDict && len(c.blocks) > 0 {
+		dict = c.blocks[0].dict
+	}
+	b, err := c.head.Serialise(blockWriterPool(c.encoding, dict))
 	if err != nil {
 		return err
 	}
+	if c.encoding == EncZstdDict && dict == nil {
+		dict = newBlockDict(b)
+	}
 
 	mint, maxt := c.head.Bounds()
 	c.blocks = append(c.blocks, block{
@@ -753,6 +830,7 @@ func (c *MemChunk) cut() error {
 		mint:             mint,
 		maxt:             maxt,
 		uncompressedSize: c.head.UncompressedSize(),
+		dict:             dict,
 	})
 
 	c.cutBlockSize += len(b)
@@ -798,7 +876,7 @@ func (c *MemChunk) Iterator(ctx context.Context, mintT, maxtT time.Time, directi
 		}
 		lastMax = b.maxt
 
-		blockItrs = append(blockItrs, encBlock{c.encoding, b}.Iterator(ctx, pipeline))
+		blockItrs = append(blockItrs, encBlock{c.encoding, c.format, b}.Iterator(ctx, pipeline))
 	}
 
 	if !c.head.IsEmpty() {
@@ -872,7 +950,7 @@ func (c *MemChunk) SampleIterator(ctx context.Context, from, through time.Time,
 			ordered = false
 		}
 		lastMax = b.maxt
-		its = append(its, encBlock{c.encoding, b}.SampleIterator(ctx, extractor))
+		its = append(its, encBlock{c.encoding, c.format, b}.SampleIterator(ctx, extractor))
 	}
 
 	if !c.head.IsEmpty() {
@@ -904,7 +982,7 @@ func (c *MemChunk) Blocks(mintT, maxtT time.Time) []Block {
 
 	for _, b := range c.blocks {
 		if maxt >= b.mint && b.maxt >= mint {
-			blocks = append(blocks, encBlock{c.encoding, b})
+			blocks = append(blocks, encBlock{c.encoding, c.format, b})
 		}
 	}
 	return blocks
@@ -919,15 +997,17 @@ func (c *MemChunk) Rebound(start, end time.Time) (Chunk, error) {
 	}
 
 	var newChunk *MemChunk
+	// Chunks read from bytes have no head block format, the one of v4 chunks keeps their structured metadata.
+	headFmt := c.compatibleHeadFmt(c.headFmt)
 	// as close as possible, respect the block/target sizes specified. However,
 	// if the blockSize is not set, use reasonable defaults.
 	if c.blockSize > 0 {
-		newChunk = NewMemChunk(c.Encoding(), c.headFmt, c.blockSize, c.targetSize)
+		newChunk = NewMemChunk(c.Encoding(), headFmt, c.blockSize, c.targetSize)
 	} else {
 		// Using defaultBlockSize for target block size.
 		// The alternative here could be going over all the blocks and using the size of the largest block as target block size but I(Sandeep) feel that it is not worth the complexity.
 		// For target chunk size I am using compressed size of original chunk since the newChunk should anyways be lower in size than that.
-		newChunk = NewMemChunk(c.Encoding(), c.headFmt, defaultBlockSize, c.CompressedSize())
+		newChunk = NewMemChunk(c.Encoding(), headFmt, defaultBlockSize, c.CompressedSize())
 	}
 
 	for itr.Next() {
@@ -954,6 +1034,8 @@ func (c *MemChunk) Rebound(start, end time.Time) (Chunk, error) {
 // chances of chunk<>block encoding drift in the codebase as the latter is parameterized by the former.
 type encBlock struct {
 	enc Encoding
+	// format is the format of the chunk of the block, which tells whether its entries have structured metadata.
+	format byte
 	block
 }
 
@@ -961,14 +1043,29 @@ func (b encBlock) Iterator(ctx context.Context, pipeline log.StreamPipeline) ite
 	if len(b.b) == 0 {
 		return iter.NoopIterator
 	}
-	return newEntryIterator(ctx, getReaderPool(b.enc), b.b, pipeline)
+	return newEntryIterator(ctx, b.readerPool(), b.b, b.format, pipeline)
 }
 
 func (b encBlock) SampleIterator(ctx context.Context, extractor log.StreamSampleExtractor) iter.SampleIterator {
 	if len(b.b) == 0 {
 		return iter.NoopIterator
 	}
-	return newSampleIterator(ctx, getReaderPool(b.enc), b.b, extractor)
+	return newSampleIterator(ctx, b.readerPool(), b.b, b.format, extractor)
+}
+
+func (b encBlock) readerPool() ReaderPool {
+	if b.dict != nil {
+		return zstdDictPool{dict: b.dict}
+	}
+	return getReaderPool(b.enc)
+}
+
+// blockWriterPool returns the pool to compress a block with, dict is nil for blocks compressed without dictionary.
+func blockWriterPool(enc Encoding, dict *blockDict) WriterPool {
+	if dict != nil {
+		return zstdDictPool{dict: dict}
+	}
+	return getWriterPool(enc)
 }
 
 func (b block) Offset() int {
@@ -1102,6 +1199,8 @@ func unsafeGetBytes(s string) []byte {
 type bufferedIterator struct {
 	origBytes []byte
 	stats     *stats.Context
+	// format is the chunk format of the block, the entries of v4 blocks have structured metadata.
+	format byte
 
 	bufReader *bufio.Reader
 	reader    io.Reader
@@ -1112,16 +1211,23 @@ type bufferedIterator struct {
 	buf      []byte // The buffer for a single entry.
 	currLine []byte // the current line, this is the same as the buffer but sliced the the line size.
 	currTs   int64
+	// currMetadata is the structured metadata of the current entry, allocated for every entry.
+	currMetadata []logproto.LabelPair
+
+	// filter skips the lines it doesn't match while they are still in the buffer of the reader,
+	// without copying them.
+	filter log.Filterer
 
 	closed bool
 }
 
-func newBufferedIterator(ctx context.Context, pool ReaderPool, b []byte) *bufferedIterator {
+func newBufferedIterator(ctx context.Context, pool ReaderPool, b []byte, format byte) *bufferedIterator {
 	stats := stats.FromContext(ctx)
 	stats.AddCompressedBytes(int64(len(b)))
 	return &bufferedIterator{
 		stats:     stats,
 		origBytes: b,
+		format:    format,
 		reader:    nil, // will be initialized later
 		bufReader: nil, // will be initialized later
 		pool:      pool,
@@ -1144,65 +1250,193 @@ func (si *bufferedIterator) Next() bool {
 		si.Close()
 		return false
 	}
+	metadata, metadataSize, ok := si.readMetadata(false)
+	if !ok {
+		si.Close()
+		return false
+	}
 	// we decode always the line length and ts as varint
-	si.stats.AddDecompressedBytes(int64(len(line)) + 2*binary.MaxVarintLen64)
+	si.stats.AddDecompressedBytes(int64(len(line)+metadataSize) + 2*binary.MaxVarintLen64)
 	si.stats.AddDecompressedLines(1)
 
 	si.currTs = ts
 	si.currLine = line
+	si.currMetadata = metadata
 	return true
 }
 
 // moveNext moves the buffer to the next entry
 func (si *bufferedIterator) moveNext() (int64, []byte, bool) {
+	for {
+		ts, lineSize, ok := si.readEntryHeader()
+		if !ok {
+			return 0, nil, false
+		}
+		skipped, ok := si.skipUnmatched(lineSize)
+		if !ok {
+			return 0, nil, false
+		}
+		if skipped {
+			continue
+		}
+		// If the buffer is not yet initialize or too small, we get a new one.
+		if si.buf == nil || lineSize > cap(si.buf) {
+			// in case of a replacement we replace back the buffer in the pool
+			if si.buf != nil {
+				BytesBufferPool.Put(si.buf)
+			}
+			si.buf = BytesBufferPool.Get(lineSize).([]byte)
+			if lineSize > cap(si.buf) {
+				si.err = fmt.Errorf("could not get a line buffer of size %d, actual %d", lineSize, cap(si.buf))
+				return 0, nil, false
+			}
+		}
+		// Then process reading the line.
+		n, err := si.bufReader.Read(si.buf[:lineSize])
+		if err != nil && err != io.EOF {
+			si.err = err
+			return 0, nil, false
+		}
+		for n < lineSize {
+			r, err := si.bufReader.Read(si.buf[n:lineSize])
+			if err != nil && err != io.EOF {
+				si.err = err
+				return 0, nil, false
+			}
+			n += r
+			// A corrupted block may end in the middle of a line.
+			if err == io.EOF && n < lineSize {
+				si.err = io.ErrUnexpectedEOF
+				return 0, nil, false
+			}
+		}
+		return ts, si.buf[:lineSize], true
+	}
+}
+
+// readEntryHeader reads the timestamp and the line size of the next entry.
+func (si *bufferedIterator) readEntryHeader() (int64, int, bool) {
 	ts, err := binary.ReadVarint(si.bufReader)
 	if err != nil {
 		if err != io.EOF {
 			si.err = err
 		}
-		return 0, nil, false
+		return 0, 0, false
 	}
 
 	l, err := binary.ReadUvarint(si.bufReader)
 	if err != nil {
 		if err != io.EOF {
 			si.err = err
-			return 0, nil, false
+			return 0, 0, false
 		}
 	}
 	lineSize := int(l)
 
 	if lineSize >= maxLineLength {
 		si.err = fmt.Errorf("line too long %d, maximum %d", lineSize, maxLineLength)
-		return 0, nil, false
+		return 0, 0, false
 	}
-	// If the buffer is not yet initialize or too small, we get a new one.
-	if si.buf == nil || lineSize > cap(si.buf) {
-		// in case of a replacement we replace back the buffer in the pool
-		if si.buf != nil {
-			BytesBufferPool.Put(si.buf)
-		}
-		si.buf = BytesBufferPool.Get(lineSize).([]byte)
-		if lineSize > cap(si.buf) {
-			si.err = fmt.Errorf("could not get a line buffer of size %d, actual %d", lineSize, cap(si.buf))
-			return 0, nil, false
+	return ts, lineSize, true
+}
+
+// skipUnmatched discards the next line if it is not matched by the filter. Only lines
+// fitting in the buffer of the reader are filtered, they are then never copied.
+func (si *bufferedIterator) skipUnmatched(lineSize int) (skipped bool, ok bool) {
+	if si.filter == nil || lineSize > si.bufReader.Size() {
+		return false, true
+	}
+	line, err := si.bufReader.Peek(lineSize)
+	if err != nil {
+		if err != io.EOF {
+			si.err = err
+			return false, false
 		}
+		// A truncated line is left to the regular read.
+		return false, true
+	}
+	if si.filter.Filter(line) {
+		return false, true
 	}
-	// Then process reading the line.
-	n, err := si.bufReader.Read(si.buf[:lineSize])
-	if err != nil && err != io.EOF {
+	if _, err := si.bufReader.Discard(lineSize); err != nil {
 		si.err = err
-		return 0, nil, false
+		return false, false
 	}
-	for n < lineSize {
-		r, err := si.bufReader.Read(si.buf[n:lineSize])
-		if err != nil && err != io.EOF {
-			si.err = err
-			return 0, nil, false
+	_, metadataSize, ok := si.readMetadata(true)
+	if !ok {
+		return false, false
+	}
+	// Skipped lines are decompressed all the same.
+	si.stats.AddDecompressedBytes(int64(lineSize+metadataSize) + 2*binary.MaxVarintLen64)
+	si.stats.AddDecompressedLines(1)
+	return true, true
+}
+
+// readMetadata reads the structured metadata following the line of the current entry in the
+// blocks of v4 chunks, it is discarded if skip is set. It returns the size of the names and values.
+func (si *bufferedIterator) readMetadata(skip bool) ([]logproto.LabelPair, int, bool) {
+	if si.format < chunkFormatV4 {
+		return nil, 0, true
+	}
+	n, err := binary.ReadUvarint(si.bufReader)
+	if err != nil {
+		si.err = unexpectedEOF(err)
+		return nil, 0, false
+	}
+	var (
+		metadata []logproto.LabelPair
+		size     int
+	)
+	for i := uint64(0); i < n; i++ {
+		name, nameSize, ok := si.readMetadataString(skip)
+		if !ok {
+			return nil, 0, false
+		}
+		value, valueSize, ok := si.readMetadataString(skip)
+		if !ok {
+			return nil, 0, false
+		}
+		size += nameSize + valueSize
+		if !skip {
+			metadata = append(metadata, logproto.LabelPair{Name: name, Value: value})
+		}
+	}
+	return metadata, size, true
+}
+
+// readMetadataString reads a length prefixed name or value of structured metadata and
+// returns it along with its size, it is discarded if skip is set.
+func (si *bufferedIterator) readMetadataString(skip bool) (string, int, bool) {
+	l, err := binary.ReadUvarint(si.bufReader)
+	if err != nil {
+		si.err = unexpectedEOF(err)
+		return "", 0, false
+	}
+	if l >= maxLineLength {
+		si.err = fmt.Errorf("structured metadata too long %d, maximum %d", l, maxLineLength)
+		return "", 0, false
+	}
+	if skip {
+		if _, err := si.bufReader.Discard(int(l)); err != nil {
+			si.err = unexpectedEOF(err)
+			return "", 0, false
 		}
-		n += r
+		return "", int(l), true
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(si.bufReader, b); err != nil {
+		si.err = unexpectedEOF(err)
+		return "", 0, false
+	}
+	return string(b), int(l), true
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF for io.EOF, an entry can't end in the middle of its metadata.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
 	}
-	return ts, si.buf[:lineSize], true
+	return err
 }
 
 func (si *bufferedIterator) Error() error { return si.err }
@@ -1232,11 +1466,13 @@ func (si *bufferedIterator) close() {
 	si.origBytes = nil
 }
 
-func newEntryIterator(ctx context.Context, pool ReaderPool, b []byte, pipeline log.StreamPipeline) iter.EntryIterator {
-	return &entryBufferedIterator{
-		bufferedIterator: newBufferedIterator(ctx, pool, b),
+func newEntryIterator(ctx context.Context, pool ReaderPool, b []byte, format byte, pipeline log.StreamPipeline) iter.EntryIterator {
+	it := &entryBufferedIterator{
+		bufferedIterator: newBufferedIterator(ctx, pool, b, format),
 		pipeline:         pipeline,
 	}
+	it.filter = lineFilter(pipeline)
+	return it
 }
 
 type entryBufferedIterator struct {
@@ -1261,20 +1497,30 @@ func (e *entryBufferedIterator) Next() bool {
 		}
 		e.cur.Timestamp = time.Unix(0, e.currTs)
 		e.cur.Line = string(newLine)
+		e.cur.StructuredMetadata = e.currMetadata
 		e.currLabels = lbs
 		return true
 	}
 	return false
 }
 
-func newSampleIterator(ctx context.Context, pool ReaderPool, b []byte, extractor log.StreamSampleExtractor) iter.SampleIterator {
+func newSampleIterator(ctx context.Context, pool ReaderPool, b []byte, format byte, extractor log.StreamSampleExtractor) iter.SampleIterator {
 	it := &sampleBufferedIterator{
-		bufferedIterator: newBufferedIterator(ctx, pool, b),
+		bufferedIterator: newBufferedIterator(ctx, pool, b, format),
 		extractor:        extractor,
 	}
+	it.filter = lineFilter(extractor)
 	return it
 }
 
+// lineFilter returns the line filters starting a pipeline or an extractor, nil if none.
+func lineFilter(p interface{}) log.Filterer {
+	if f, ok := p.(log.LineFilterer); ok {
+		return f.LineFilter()
+	}
+	return nil
+}
+
 type sampleBufferedIterator struct {
 	*bufferedIterator
 
@@ -38,6 +38,7 @@ var testEncoding = []Encoding{
 	EncSnappy,
 	EncFlate,
 	EncZstd,
+	EncZstdDict,
 }
 
 var (
@@ -218,8 +219,14 @@ func TestReadFormatV1(t *testing.T) {
 // 2) []byte loaded chunks <-> []byte loaded chunks
 func TestRoundtripV2(t *testing.T) {
 	for _, f := range HeadBlockFmts {
+		versions := []byte{chunkFormatV2, chunkFormatV3}
+		if f == UnorderedWithStructuredMetadataHeadBlockFmt {
+			// the structured metadata of the head is only cut into V4 blocks
+			versions = []byte{chunkFormatV4}
+		}
 		for _, enc := range testEncoding {
-			for _, version := range []byte{chunkFormatV2, chunkFormatV3} {
+			for _, version := range versions {
+				f, enc, version := f, enc, version
 				t.Run(enc.String(), func(t *testing.T) {
 					t.Parallel()
 
@@ -807,7 +814,7 @@ func BenchmarkHeadBlockIterator(b *testing.B) {
 			h := headBlock{}
 
 			for i := 0; i < j; i++ {
-				if err := h.Append(int64(i), "this is the append string"); err != nil {
+				if err := h.Append(int64(i), "this is the append string", nil); err != nil {
 					b.Fatal(err)
 				}
 			}
@@ -831,7 +838,7 @@ func BenchmarkHeadBlockSampleIterator(b *testing.B) {
 			h := headBlock{}
 
 			for i := 0; i < j; i++ {
-				if err := h.Append(int64(i), "this is the append string"); err != nil {
+				if err := h.Append(int64(i), "this is the append string", nil); err != nil {
 					b.Fatal(err)
 				}
 			}
@@ -1229,3 +1236,133 @@ func buildTestMemChunk(t *testing.T, from, through time.Time) *MemChunk {
 
 	return chk
 }
+
+func TestMemChunk_LineFilterPushdown(t *testing.T) {
+	c := NewMemChunk(EncSnappy, DefaultHeadBlockFmt, testBlockSize, testTargetSize)
+	var expected []string
+	for i := 0; i < 1000; i++ {
+		line := fmt.Sprintf(`level=info msg="ok" i=%d`, i)
+		switch {
+		case i%10 == 0:
+			line = fmt.Sprintf(`level=error msg="failed" i=%d`, i)
+		case i%100 == 1:
+			// Lines too long for the buffer of the reader are filtered after being read.
+			line = fmt.Sprintf(`level=info msg="%s" i=%d`, strings.Repeat("a", 5000), i)
+		case i%100 == 2:
+			line = fmt.Sprintf(`level=ERROR msg="%s" i=%d`, strings.Repeat("a", 5000), i)
+		}
+		if strings.Contains(strings.ToLower(line), "error") {
+			expected = append(expected, line)
+		}
+		require.NoError(t, c.Append(&logproto.Entry{Timestamp: time.Unix(0, int64(i)), Line: line}))
+	}
+	require.NoError(t, c.cut())
+
+	expr, err := logql.ParseLogSelector(`{app="foo"} |~ "(?i)error" | logfmt`, true)
+	require.NoError(t, err)
+	p, err := expr.Pipeline()
+	require.NoError(t, err)
+	statsCtx, ctx := stats.NewContext(context.Background())
+	it, err := c.Iterator(ctx, time.Unix(0, 0), time.Unix(0, 1000), logproto.FORWARD, p.ForStream(labels.Labels{}))
+	require.NoError(t, err)
+	var lines []string
+	for it.Next() {
+		lines = append(lines, it.Entry().Line)
+		lbs, err := logql.ParseLabels(it.Labels())
+		require.NoError(t, err)
+		require.Equal(t, "error", strings.ToLower(lbs.Get("level")))
+	}
+	require.NoError(t, it.Close())
+	require.Equal(t, expected, lines)
+	// Skipped lines are still decompressed.
+	require.Equal(t, int64(1000), statsCtx.Result(0).TotalDecompressedLines())
+
+	sampleExpr, err := logql.ParseSampleExpr(`count_over_time({app="foo"} |~ "(?i)error" [1m])`)
+	require.NoError(t, err)
+	ex, err := sampleExpr.Extractor()
+	require.NoError(t, err)
+	sit := c.SampleIterator(context.Background(), time.Unix(0, 0), time.Unix(0, 1000), ex.ForStream(labels.Labels{}))
+	var samples int
+	for sit.Next() {
+		samples++
+	}
+	require.NoError(t, sit.Close())
+	require.Equal(t, len(expected), samples)
+}
+
+func TestMemChunk_StructuredMetadata(t *testing.T) {
+	metadata := func(i int) []logproto.LabelPair {
+		if i%3 == 0 {
+			return nil
+		}
+		return []logproto.LabelPair{{Name: "trace_id", Value: fmt.Sprint(i)}, {Name: "user", Value: strings.Repeat("u", i)}}
+	}
+	c := NewMemChunk(EncSnappy, UnorderedWithStructuredMetadataHeadBlockFmt, testBlockSize, testTargetSize)
+	require.Equal(t, chunkFormatV4, c.format)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, c.Append(&logproto.Entry{Timestamp: time.Unix(0, int64(i)), Line: fmt.Sprintf("line %d", i), StructuredMetadata: metadata(i)}))
+		if i == 4 {
+			require.NoError(t, c.cut())
+		}
+	}
+
+	assertEntries := func(t *testing.T, c Chunk, query string, from int) {
+		expr, err := logql.ParseLogSelector(query, true)
+		require.NoError(t, err)
+		p, err := expr.Pipeline()
+		require.NoError(t, err)
+		it, err := c.Iterator(context.Background(), time.Unix(0, 0), time.Unix(0, 10), logproto.FORWARD, p.ForStream(labels.Labels{}))
+		require.NoError(t, err)
+		i := from
+		for it.Next() {
+			require.Equal(t, logproto.Entry{Timestamp: time.Unix(0, int64(i)), Line: fmt.Sprintf("line %d", i), StructuredMetadata: metadata(i)}, it.Entry())
+			i++
+		}
+		require.NoError(t, it.Close())
+		require.Equal(t, 10, i)
+	}
+
+	t.Run("head and blocks", func(t *testing.T) {
+		assertEntries(t, c, `{app="foo"}`, 0)
+		// the metadata of the lines skipped by the filter is skipped with them.
+		assertEntries(t, c, `{app="foo"} |~ "line [2-9]"`, 2)
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		require.NoError(t, c.Close())
+		b, err := c.Bytes()
+		require.NoError(t, err)
+		r, err := NewByteChunk(b, testBlockSize, testTargetSize)
+		require.NoError(t, err)
+		assertEntries(t, r, `{app="foo"}`, 0)
+		assertEntries(t, r, `{app="foo"} |~ "line [2-9]"`, 2)
+	})
+
+	t.Run("checkpoint", func(t *testing.T) {
+		c := NewMemChunk(EncSnappy, UnorderedWithStructuredMetadataHeadBlockFmt, testBlockSize, testTargetSize)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, c.Append(&logproto.Entry{Timestamp: time.Unix(0, int64(i)), Line: fmt.Sprintf("line %d", i), StructuredMetadata: metadata(i)}))
+			if i == 4 {
+				require.NoError(t, c.cut())
+			}
+		}
+		var chk, head bytes.Buffer
+		require.NoError(t, c.SerializeForCheckpointTo(&chk, &head))
+
+		// the head of a v4 chunk keeps its metadata whatever the desired head format.
+		cpy, err := MemchunkFromCheckpoint(chk.Bytes(), head.Bytes(), UnorderedHeadBlockFmt, testBlockSize, testTargetSize)
+		require.NoError(t, err)
+		require.Equal(t, c, cpy)
+		assertEntries(t, cpy, `{app="foo"}`, 0)
+	})
+
+	t.Run("dropped without the head format", func(t *testing.T) {
+		c := NewMemChunk(EncSnappy, UnorderedHeadBlockFmt, testBlockSize, testTargetSize)
+		require.NoError(t, c.Append(&logproto.Entry{Timestamp: time.Unix(0, 1), Line: "line", StructuredMetadata: metadata(1)}))
+		it, err := c.Iterator(context.Background(), time.Unix(0, 0), time.Unix(0, 10), logproto.FORWARD, noopStreamPipeline)
+		require.NoError(t, err)
+		require.True(t, it.Next())
+		require.Nil(t, it.Entry().StructuredMetadata)
+		require.NoError(t, it.Close())
+	})
+}
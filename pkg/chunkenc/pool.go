@@ -103,7 +103,8 @@ func getReaderPool(enc Encoding) ReaderPool {
 		return &Noop
 	case EncFlate:
 		return &Flate
-	case EncZstd:
+	case EncZstd, EncZstdDict:
+		// the first block of EncZstdDict chunks is compressed without dictionary.
 		return &Zstd
 	default:
 		panic("unknown encoding")
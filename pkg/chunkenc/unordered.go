@@ -34,7 +34,7 @@ type HeadBlock interface {
 	Entries() int
 	UncompressedSize() int
 	Convert(HeadBlockFmt) (HeadBlock, error)
-	Append(int64, string) error
+	Append(int64, string, []logproto.LabelPair) error
 	Iterator(
 		ctx context.Context,
 		direction logproto.Direction,
@@ -60,15 +60,20 @@ type unorderedHeadBlock struct {
 	lines      int   // number of entries
 	size       int   // size of uncompressed bytes.
 	mint, maxt int64 // upper and lower bounds
+
+	// format is UnorderedHeadBlockFmt, or UnorderedWithStructuredMetadataHeadBlockFmt for
+	// head blocks keeping the structured metadata of the entries.
+	format HeadBlockFmt
 }
 
-func newUnorderedHeadBlock() *unorderedHeadBlock {
+func newUnorderedHeadBlock(format HeadBlockFmt) *unorderedHeadBlock {
 	return &unorderedHeadBlock{
-		rt: rangetree.New(1),
+		rt:     rangetree.New(1),
+		format: format,
 	}
 }
 
-func (hb *unorderedHeadBlock) Format() HeadBlockFmt { return UnorderedHeadBlockFmt }
+func (hb *unorderedHeadBlock) Format() HeadBlockFmt { return hb.format }
 
 func (hb *unorderedHeadBlock) IsEmpty() bool {
 	return hb.size == 0
@@ -87,21 +92,31 @@ func (hb *unorderedHeadBlock) UncompressedSize() int {
 }
 
 func (hb *unorderedHeadBlock) Reset() {
-	x := newUnorderedHeadBlock()
+	x := newUnorderedHeadBlock(hb.format)
 	*hb = *x
 }
 
 // collection of entries belonging to the same nanosecond
 type nsEntries struct {
 	ts      int64
-	entries []string
+	entries []nsEntry
+}
+
+// nsEntry is an entry of a nsEntries, its metadata is only kept by head blocks with structured metadata.
+type nsEntry struct {
+	line     string
+	metadata []logproto.LabelPair
 }
 
 func (e *nsEntries) ValueAtDimension(_ uint64) int64 {
 	return e.ts
 }
 
-func (hb *unorderedHeadBlock) Append(ts int64, line string) error {
+func (hb *unorderedHeadBlock) Append(ts int64, line string, metadata []logproto.LabelPair) error {
+	if hb.format < UnorderedWithStructuredMetadataHeadBlockFmt {
+		metadata = nil
+	}
+
 	// This is an allocation hack. The rangetree lib does not
 	// support the ability to pass a "mutate" function during an insert
 	// and instead will displace any existing entry at the specified timestamp.
@@ -116,9 +131,9 @@ func (hb *unorderedHeadBlock) Append(ts int64, line string) error {
 	}
 	displaced := hb.rt.Add(e)
 	if displaced[0] != nil {
-		e.entries = append(displaced[0].(*nsEntries).entries, line)
+		e.entries = append(displaced[0].(*nsEntries).entries, nsEntry{line, metadata})
 	} else {
-		e.entries = []string{line}
+		e.entries = []nsEntry{{line, metadata}}
 	}
 
 	// Update hb metdata
@@ -130,7 +145,7 @@ func (hb *unorderedHeadBlock) Append(ts int64, line string) error {
 		hb.maxt = ts
 	}
 
-	hb.size += len(line)
+	hb.size += len(line) + metadataSize(metadata)
 	hb.lines++
 
 	return nil
@@ -153,7 +168,7 @@ func (hb *unorderedHeadBlock) forEntries(
 	direction logproto.Direction,
 	mint,
 	maxt int64,
-	entryFn func(int64, string) error, // returning an error exits early
+	entryFn func(int64, string, []logproto.LabelPair) error, // returning an error exits early
 ) (err error) {
 	if hb.IsEmpty() || (maxt < hb.mint || hb.maxt < mint) {
 		return
@@ -182,9 +197,9 @@ func (hb *unorderedHeadBlock) forEntries(
 		}
 
 		for ; i < len(es.entries) && i >= 0; next() {
-			line := es.entries[i]
-			chunkStats.AddHeadChunkBytes(int64(len(line)))
-			err = entryFn(es.ts, line)
+			e := es.entries[i]
+			chunkStats.AddHeadChunkBytes(int64(len(e.line) + metadataSize(e.metadata)))
+			err = entryFn(es.ts, e.line, e.metadata)
 
 		}
 	}
@@ -227,7 +242,7 @@ func (hb *unorderedHeadBlock) Iterator(
 		direction,
 		mint,
 		maxt,
-		func(ts int64, line string) error {
+		func(ts int64, line string, metadata []logproto.LabelPair) error {
 			newLine, parsedLbs, ok := pipeline.ProcessString(line)
 			if !ok {
 				return nil
@@ -243,8 +258,9 @@ func (hb *unorderedHeadBlock) Iterator(
 			}
 
 			stream.Entries = append(stream.Entries, logproto.Entry{
-				Timestamp: time.Unix(0, ts),
-				Line:      newLine,
+				Timestamp:          time.Unix(0, ts),
+				Line:               newLine,
+				StructuredMetadata: metadata,
 			})
 			return nil
 		},
@@ -275,7 +291,7 @@ func (hb *unorderedHeadBlock) SampleIterator(
 		logproto.FORWARD,
 		mint,
 		maxt,
-		func(ts int64, line string) error {
+		func(ts int64, line string, _ []logproto.LabelPair) error {
 			value, parsedLabels, ok := extractor.ProcessString(line)
 			if !ok {
 				return nil
@@ -335,7 +351,7 @@ func (hb *unorderedHeadBlock) Serialise(pool WriterPool) ([]byte, error) {
 		logproto.FORWARD,
 		0,
 		math.MaxInt64,
-		func(ts int64, line string) error {
+		func(ts int64, line string, metadata []logproto.LabelPair) error {
 			n := binary.PutVarint(encBuf, ts)
 			inBuf.Write(encBuf[:n])
 
@@ -343,6 +359,9 @@ func (hb *unorderedHeadBlock) Serialise(pool WriterPool) ([]byte, error) {
 			inBuf.Write(encBuf[:n])
 
 			inBuf.WriteString(line)
+			if hb.format >= UnorderedWithStructuredMetadataHeadBlockFmt {
+				writeMetadata(inBuf, encBuf, metadata)
+			}
 			return nil
 		},
 	)
@@ -358,7 +377,7 @@ func (hb *unorderedHeadBlock) Serialise(pool WriterPool) ([]byte, error) {
 }
 
 func (hb *unorderedHeadBlock) Convert(version HeadBlockFmt) (HeadBlock, error) {
-	if version > OrderedHeadBlockFmt {
+	if version == hb.format {
 		return hb, nil
 	}
 	out := version.NewBlock()
@@ -368,8 +387,8 @@ func (hb *unorderedHeadBlock) Convert(version HeadBlockFmt) (HeadBlock, error) {
 		logproto.FORWARD,
 		0,
 		math.MaxInt64,
-		func(ts int64, line string) error {
-			return out.Append(ts, line)
+		func(ts int64, line string, metadata []logproto.LabelPair) error {
+			return out.Append(ts, line, metadata)
 		},
 	)
 	return out, err
@@ -381,7 +400,11 @@ func (hb *unorderedHeadBlock) CheckpointSize() int {
 	size += binary.MaxVarintLen32 * 2                                  // total entries + total size
 	size += binary.MaxVarintLen64 * 2                                  // mint,maxt
 	size += (binary.MaxVarintLen64 + binary.MaxVarintLen32) * hb.lines // ts + len of log line.
-	size += hb.size                                                    // uncompressed bytes of lines
+	size += hb.size                                                    // uncompressed bytes of lines and metadata
+	if hb.format >= UnorderedWithStructuredMetadataHeadBlockFmt {
+		// number of metadata pairs + len of their names and values, counting a pair per line.
+		size += binary.MaxVarintLen32 * 3 * hb.lines
+	}
 	return size
 }
 
@@ -421,7 +444,7 @@ func (hb *unorderedHeadBlock) CheckpointTo(w io.Writer) error {
 		logproto.FORWARD,
 		0,
 		math.MaxInt64,
-		func(ts int64, line string) error {
+		func(ts int64, line string, metadata []logproto.LabelPair) error {
 			eb.putVarint64(ts)
 			eb.putUvarint(len(line))
 			_, err = w.Write(eb.get())
@@ -434,6 +457,22 @@ func (hb *unorderedHeadBlock) CheckpointTo(w io.Writer) error {
 			if err != nil {
 				return errors.Wrap(err, "write headblock entry line")
 			}
+			if hb.format < UnorderedWithStructuredMetadataHeadBlockFmt {
+				return nil
+			}
+
+			eb.putUvarint(len(metadata))
+			for _, m := range metadata {
+				eb.putUvarint(len(m.Name))
+				eb.b = append(eb.b, m.Name...)
+				eb.putUvarint(len(m.Value))
+				eb.b = append(eb.b, m.Value...)
+			}
+			_, err = w.Write(eb.get())
+			if err != nil {
+				return errors.Wrap(err, "write headblock entry metadata")
+			}
+			eb.reset()
 			return nil
 		},
 	)
@@ -443,7 +482,7 @@ func (hb *unorderedHeadBlock) CheckpointTo(w io.Writer) error {
 
 func (hb *unorderedHeadBlock) LoadBytes(b []byte) error {
 	// ensure it's empty
-	*hb = *newUnorderedHeadBlock()
+	*hb = *newUnorderedHeadBlock(hb.format)
 
 	if len(b) < 1 {
 		return nil
@@ -456,8 +495,11 @@ func (hb *unorderedHeadBlock) LoadBytes(b []byte) error {
 		return errors.Wrap(db.err(), "verifying headblock header")
 	}
 
-	if version != UnorderedHeadBlockFmt.Byte() {
-		return errors.Errorf("incompatible headBlock version (%v), only V4 is currently supported", version)
+	switch HeadBlockFmt(version) {
+	case UnorderedHeadBlockFmt, UnorderedWithStructuredMetadataHeadBlockFmt:
+		hb.format = HeadBlockFmt(version)
+	default:
+		return errors.Errorf("incompatible headBlock version (%v), only V4 and V5 are currently supported", version)
 	}
 
 	n := db.uvarint()
@@ -470,7 +512,16 @@ func (hb *unorderedHeadBlock) LoadBytes(b []byte) error {
 		ts := db.varint64()
 		lineLn := db.uvarint()
 		line := string(db.bytes(lineLn))
-		if err := hb.Append(ts, line); err != nil {
+		var metadata []logproto.LabelPair
+		if hb.format >= UnorderedWithStructuredMetadataHeadBlockFmt {
+			pairs := db.uvarint()
+			for j := 0; j < pairs && db.err() == nil; j++ {
+				name := string(db.bytes(db.uvarint()))
+				value := string(db.bytes(db.uvarint()))
+				metadata = append(metadata, logproto.LabelPair{Name: name, Value: value})
+			}
+		}
+		if err := hb.Append(ts, line, metadata); err != nil {
 			return err
 		}
 	}
@@ -497,7 +548,7 @@ func HeadFromCheckpoint(b []byte, desired HeadBlockFmt) (HeadBlock, error) {
 		return nil, errors.Wrap(db.err(), "verifying headblock header")
 	}
 	format := HeadBlockFmt(version)
-	if format > UnorderedHeadBlockFmt {
+	if format > UnorderedWithStructuredMetadataHeadBlockFmt {
 		return nil, fmt.Errorf("unexpected head block version: %v", format)
 	}
 
@@ -3,7 +3,9 @@ package distributor
 import (
 	"context"
 	"flag"
+	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	cortex_distributor "github.com/cortexproject/cortex/pkg/distributor"
@@ -18,12 +20,15 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/user"
 	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/grafana/loki/pkg/ingester/client"
+	"github.com/grafana/loki/pkg/loghttp/push"
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/runtime"
@@ -34,11 +39,23 @@ import (
 
 var maxLabelCacheSize = 100000
 
+// truncatedLineMarker ends lines truncated with max_line_size_truncate_annotate.
+const truncatedLineMarker = "...[truncated %d bytes]"
+
+// receivedAtLineMarker ends lines with a skewed timestamp when the ingestion timestamp policy is annotate.
+const receivedAtLineMarker = " [received at %s]"
+
 // Config for a Distributor.
 type Config struct {
 	// Distributors ring
 	DistributorRing cortex_distributor.RingConfig `yaml:"ring,omitempty"`
 
+	// Identification of the sources of the push API for their rate limits.
+	PushSourceLimits SourceLimitsConfig `yaml:"push_source_limits,omitempty"`
+
+	// Max sizes of the push request bodies, by Content-Encoding.
+	PushBodyLimits push.BodyLimits `yaml:"push_body_limits,omitempty"`
+
 	// For testing.
 	factory ring_client.PoolFactory `yaml:"-"`
 }
@@ -46,6 +63,8 @@ type Config struct {
 // RegisterFlags registers distributor-related flags.
 func (cfg *Config) RegisterFlags(fs *flag.FlagSet) {
 	cfg.DistributorRing.RegisterFlags(fs)
+	cfg.PushSourceLimits.RegisterFlags(fs)
+	cfg.PushBodyLimits.RegisterFlagsWithPrefix("distributor.push-", fs)
 }
 
 // Distributor coordinates replicates and distribution of log streams.
@@ -58,6 +77,7 @@ type Distributor struct {
 	tenantsRetention *retention.TenantsRetention
 	ingestersRing    ring.ReadRing
 	validator        *Validator
+	overrides        *validation.Overrides
 	pool             *ring_client.Pool
 
 	// The global rate limiter requires a distributors ring to count
@@ -68,9 +88,13 @@ type Distributor struct {
 	subservicesWatcher *services.FailureWatcher
 
 	// Per-user rate limiter.
-	ingestionRateLimiter *limiter.RateLimiter
+	ingestionRateLimiter *tenantRateLimiter
 	labelCache           *lru.Cache
 
+	// Per source IP and per principal rate limiters of the push API.
+	sourceIPRateLimiter  *sourceRateLimiter
+	principalRateLimiter *sourceRateLimiter
+
 	// metrics
 	ingesterAppends        *prometheus.CounterVec
 	ingesterAppendFailures *prometheus.CounterVec
@@ -114,6 +138,14 @@ func New(cfg Config, clientCfg client.Config, configs *runtime.TenantConfigs, in
 	if err != nil {
 		return nil, err
 	}
+	sourceIPRateLimiter, err := newSourceRateLimiter(overrides.IngestionRatePerSourceIP, cfg.PushSourceLimits.MaxTrackedSources)
+	if err != nil {
+		return nil, err
+	}
+	principalRateLimiter, err := newSourceRateLimiter(overrides.IngestionRatePerPrincipal, cfg.PushSourceLimits.MaxTrackedSources)
+	if err != nil {
+		return nil, err
+	}
 	d := Distributor{
 		cfg:                  cfg,
 		clientCfg:            clientCfg,
@@ -122,9 +154,12 @@ func New(cfg Config, clientCfg client.Config, configs *runtime.TenantConfigs, in
 		ingestersRing:        ingestersRing,
 		distributorsRing:     distributorsRing,
 		validator:            validator,
+		overrides:            overrides,
 		pool:                 cortex_distributor.NewPool(clientCfg.PoolConfig, ingestersRing, factory, util_log.Logger),
-		ingestionRateLimiter: limiter.NewRateLimiter(ingestionRateStrategy, 10*time.Second),
+		ingestionRateLimiter: newTenantRateLimiter(ingestionRateStrategy, 10*time.Second),
 		labelCache:           labelCache,
+		sourceIPRateLimiter:  sourceIPRateLimiter,
+		principalRateLimiter: principalRateLimiter,
 		ingesterAppends: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
 			Namespace: "loki",
 			Name:      "distributor_ingester_appends_total",
@@ -207,9 +242,15 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 
 	validationContext := d.validator.getValidationContextFor(userID)
 
-	for _, stream := range req.Streams {
+	pending := req.Streams
+	for i := 0; i < len(pending); i++ {
+		stream := pending[i]
 		// Truncate first so subsequent steps have consistent line lengths
-		d.truncateLines(validationContext, &stream)
+		if truncated, ok := d.truncateLines(validationContext, &stream); ok {
+			// the truncated lines are validated as a stream of their own, the request is not modified.
+			pending = append(pending[:len(pending):len(pending)], truncated)
+		}
+		d.applyTimestampPolicy(validationContext, &stream)
 
 		stream.Labels, err = d.parseStreamLabels(validationContext, stream.Labels, &stream)
 		if err != nil {
@@ -251,12 +292,28 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 	}
 
 	now := time.Now()
-	if !d.ingestionRateLimiter.AllowN(now, userID, validatedSamplesSize) {
+	reservation, ok := d.ingestionRateLimiter.reserveN(now, userID, validatedSamplesSize)
+	if !ok {
 		// Return a 429 to indicate to the client they are being rate limited
 		validation.DiscardedSamples.WithLabelValues(validation.RateLimited, userID).Add(float64(validatedSamplesCount))
 		validation.DiscardedBytes.WithLabelValues(validation.RateLimited, userID).Add(float64(validatedSamplesSize))
 		return nil, httpgrpc.Errorf(http.StatusTooManyRequests, validation.RateLimitedErrorMsg, int(d.ingestionRateLimiter.Limit(now, userID)), validatedSamplesCount, validatedSamplesSize)
 	}
+	// the ingestion rate limit of an org is shared by its sub-tenants.
+	reservations := []*rate.Reservation{reservation}
+	for _, org := range d.overrides.TenantHierarchy().Ancestors(userID) {
+		reservation, ok := d.ingestionRateLimiter.reserveN(now, org, validatedSamplesSize)
+		if !ok {
+			// The push isn't ingested, give its bytes back to the tenant and to the orgs checked so far.
+			for _, r := range reservations {
+				r.CancelAt(now)
+			}
+			validation.DiscardedSamples.WithLabelValues(validation.OrgRateLimited, userID).Add(float64(validatedSamplesCount))
+			validation.DiscardedBytes.WithLabelValues(validation.OrgRateLimited, userID).Add(float64(validatedSamplesSize))
+			return nil, httpgrpc.Errorf(http.StatusTooManyRequests, validation.OrgRateLimitedErrorMsg, org, int(d.ingestionRateLimiter.Limit(now, org)), validatedSamplesCount, validatedSamplesSize)
+		}
+		reservations = append(reservations, reservation)
+	}
 
 	const maxExpectedReplicationSet = 5 // typical replication factor 3 plus one for inactive plus one for luck
 	var descs [maxExpectedReplicationSet]ring.InstanceDesc
@@ -304,23 +361,138 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 	}
 }
 
-func (d *Distributor) truncateLines(vContext validationContext, stream *logproto.Stream) {
+// truncateLines truncates the lines longer than the max line size. When the truncate label is set,
+// the truncated lines are moved from the stream to the returned stream, which has the label added.
+func (d *Distributor) truncateLines(vContext validationContext, stream *logproto.Stream) (logproto.Stream, bool) {
 	if !vContext.maxLineSizeTruncate {
-		return
+		return logproto.Stream{}, false
 	}
 
-	var truncatedSamples, truncatedBytes int
+	var (
+		truncatedSamples, truncatedBytes int
+		truncated                        []logproto.Entry
+		// entries are the entries kept in the stream. They are copied on the first truncated line,
+		// the entries of the stream share their backing array with the request.
+		entries []logproto.Entry
+		split   = vContext.maxLineSizeLabel != ""
+	)
 	for i, e := range stream.Entries {
 		if maxSize := vContext.maxLineSize; maxSize != 0 && len(e.Line) > maxSize {
-			stream.Entries[i].Line = e.Line[:maxSize]
-
+			if entries == nil {
+				entries = make([]logproto.Entry, i, len(stream.Entries))
+				copy(entries, stream.Entries[:i])
+			}
+			line := truncateLine(e.Line, maxSize, vContext.maxLineSizeAnnotate)
 			truncatedSamples++
-			truncatedBytes = len(e.Line) - maxSize
+			truncatedBytes += len(e.Line) - len(line)
+
+			e.Line = line
+			if split {
+				truncated = append(truncated, e)
+				continue
+			}
+		}
+		if entries != nil {
+			entries = append(entries, e)
 		}
 	}
 
 	validation.MutatedSamples.WithLabelValues(validation.LineTooLong, vContext.userID).Add(float64(truncatedSamples))
 	validation.MutatedBytes.WithLabelValues(validation.LineTooLong, vContext.userID).Add(float64(truncatedBytes))
+
+	if entries == nil {
+		return logproto.Stream{}, false
+	}
+	stream.Entries = entries
+	if len(truncated) == 0 {
+		return logproto.Stream{}, false
+	}
+	ls, err := logql.ParseLabels(stream.Labels)
+	if err != nil {
+		// the stream is rejected along with its truncated lines.
+		stream.Entries = append(stream.Entries, truncated...)
+		return logproto.Stream{}, false
+	}
+	return logproto.Stream{
+		Labels:  labels.NewBuilder(ls).Set(vContext.maxLineSizeLabel, "true").Labels().String(),
+		Entries: truncated,
+	}, true
+}
+
+// applyTimestampPolicy clamps or annotates the entries whose timestamp is more than
+// the maximum skew away from their arrival time, according to the timestamp policy.
+// Annotated lines are cut to keep them within the max line size, and the clamped entries
+// are put back in order when the tenant doesn't accept out of order writes.
+func (d *Distributor) applyTimestampPolicy(vContext validationContext, stream *logproto.Stream) {
+	if vContext.timestampPolicy != validation.TimestampPolicyClamp && vContext.timestampPolicy != validation.TimestampPolicyAnnotate {
+		return
+	}
+
+	var (
+		skewedSamples, skewedBytes int
+		// entries are copied on the first skewed entry, the entries of the stream share their
+		// backing array with the request.
+		entries []logproto.Entry
+		marker  = fmt.Sprintf(receivedAtLineMarker, vContext.receivedAt.UTC().Format(time.RFC3339Nano))
+	)
+	for i, e := range stream.Entries {
+		skew := e.Timestamp.Sub(vContext.receivedAt)
+		if skew <= vContext.timestampMaxSkew && skew >= -vContext.timestampMaxSkew {
+			continue
+		}
+		if vContext.timestampPolicy == validation.TimestampPolicyClamp {
+			e.Timestamp = vContext.receivedAt
+		} else {
+			maxSize := vContext.maxLineSize
+			if maxSize != 0 && len(e.Line) <= maxSize && len(e.Line)+len(marker) > maxSize {
+				if len(marker) > maxSize {
+					// the marker doesn't fit, the line is ingested as is.
+					continue
+				}
+				e.Line = truncateUTF8(e.Line, maxSize-len(marker))
+			}
+			e.Line += marker
+		}
+		if entries == nil {
+			entries = append([]logproto.Entry(nil), stream.Entries...)
+		}
+		entries[i] = e
+
+		skewedSamples++
+		skewedBytes += len(stream.Entries[i].Line)
+	}
+	if entries != nil {
+		if vContext.timestampPolicy == validation.TimestampPolicyClamp && !vContext.unorderedWrites {
+			sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+		}
+		stream.Entries = entries
+	}
+
+	validation.MutatedSamples.WithLabelValues(validation.TimestampSkewed, vContext.userID).Add(float64(skewedSamples))
+	validation.MutatedBytes.WithLabelValues(validation.TimestampSkewed, vContext.userID).Add(float64(skewedBytes))
+}
+
+// truncateLine cuts the line to at most maxSize bytes, without splitting a character. When annotate is true
+// the end of the line is replaced by a marker with the number of bytes removed, the annotated line still
+// fits in maxSize bytes.
+func truncateLine(line string, maxSize int, annotate bool) string {
+	if !annotate {
+		return truncateUTF8(line, maxSize)
+	}
+	removed := len(line) - maxSize
+	for {
+		marker := fmt.Sprintf(truncatedLineMarker, removed)
+		keep := maxSize - len(marker)
+		if keep < 0 {
+			// the marker doesn't fit.
+			return truncateUTF8(line, maxSize)
+		}
+		kept := truncateUTF8(line, keep)
+		if len(line)-len(kept) == removed {
+			return kept + marker
+		}
+		removed = len(line) - len(kept)
+	}
 }
 
 // TODO taken from Cortex, see if we can refactor out an usable interface.
@@ -392,10 +564,15 @@ func (d *Distributor) parseStreamLabels(vContext validationContext, key string,
 		return "", httpgrpc.Errorf(http.StatusBadRequest, validation.InvalidLabelsErrorMsg, key, err)
 	}
 	// ensure labels are correctly sorted.
-	if err := d.validator.ValidateLabels(vContext, ls, *stream); err != nil {
+	shortened, err := d.validator.ValidateLabels(vContext, ls, *stream)
+	if err != nil {
 		return "", err
 	}
 	lsVal := ls.String()
-	d.labelCache.Add(key, lsVal)
+	// the cache is shared by the tenants, the labels shortened by the label value length policy
+	// of a tenant are not cached, nor their mutation metrics skipped.
+	if !shortened {
+		d.labelCache.Add(key, lsVal)
+	}
 	return lsVal, nil
 }
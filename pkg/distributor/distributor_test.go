@@ -10,6 +10,7 @@ import (
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/cortexproject/cortex/pkg/util/test"
 	"github.com/go-kit/log"
@@ -115,6 +116,33 @@ func Test_SortLabelsOnPush(t *testing.T) {
 	require.Equal(t, `{a="b", buzz="f"}`, ingester.pushed[0].Streams[0].Labels)
 }
 
+func Test_ShortenedLabelsAreNotCached(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.EnforceMetricName = false
+	limits.MaxLabelValueLength = 5
+	limits.LabelValueLengthPolicy = validation.LabelValueLengthPolicyTruncate
+	d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return &mockIngester{}, nil })
+	defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+	vCtx := d.validator.getValidationContextFor("123")
+
+	for _, tc := range []struct {
+		labels string
+		want   string
+		cached bool
+	}{
+		{`{app="api"}`, `{app="api"}`, true},
+		// the labels shortened for a tenant are not returned to the other tenants.
+		{`{app="gateway"}`, `{app="gatew"}`, false},
+	} {
+		stream := logproto.Stream{Labels: tc.labels}
+		got, err := d.parseStreamLabels(vCtx, tc.labels, &stream)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, got)
+		require.Equal(t, tc.cached, d.labelCache.Contains(tc.labels))
+	}
+}
+
 func Test_TruncateLogLines(t *testing.T) {
 	setup := func() (*validation.Limits, *mockIngester) {
 		limits := &validation.Limits{}
@@ -132,12 +160,166 @@ func Test_TruncateLogLines(t *testing.T) {
 		d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
 		defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
 
-		_, err := d.Push(ctx, makeWriteRequest(1, 10))
+		req := makeWriteRequest(1, 10)
+		_, err := d.Push(ctx, req)
 		require.NoError(t, err)
 		require.Len(t, ingester.pushed[0].Streams[0].Entries[0].Line, 5)
+		// the request is not modified.
+		require.Len(t, req.Streams[0].Entries[0].Line, 10)
+	})
+
+	t.Run("it annotates truncated lines when MaxLineSizeAnnotate is true", func(t *testing.T) {
+		limits, ingester := setup()
+		limits.MaxLineSize = 30
+		limits.MaxLineSizeAnnotate = true
+
+		d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
+		defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+
+		req := makeWriteRequest(1, 10)
+		req.Streams[0].Entries[0].Line = strings.Repeat("a", 100)
+		_, err := d.Push(ctx, req)
+		require.NoError(t, err)
+		require.Equal(t, "aaaaaaa...[truncated 93 bytes]", ingester.pushed[0].Streams[0].Entries[0].Line)
+	})
+
+	t.Run("it moves truncated lines to a labelled stream when MaxLineSizeLabel is set", func(t *testing.T) {
+		limits, ingester := setup()
+		limits.MaxLineSizeLabel = "truncated"
+
+		d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
+		defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+
+		req := makeWriteRequest(2, 10)
+		req.Streams[0].Entries[1].Line = "1"
+		_, err := d.Push(ctx, req)
+		require.NoError(t, err)
+
+		streams := map[string][]logproto.Entry{}
+		for _, r := range ingester.pushed {
+			for _, s := range r.Streams {
+				streams[s.Labels] = append(streams[s.Labels], s.Entries...)
+			}
+		}
+		// entries are pushed to every replica.
+		require.Len(t, streams, 2)
+		for _, e := range streams[`{foo="bar"}`] {
+			require.Equal(t, "1", e.Line)
+		}
+		for _, e := range streams[`{foo="bar", truncated="true"}`] {
+			require.Equal(t, "0    ", e.Line)
+		}
 	})
 }
 
+func Test_TimestampPolicy(t *testing.T) {
+	push := func(t *testing.T, policy string) logproto.Entry {
+		limits := &validation.Limits{}
+		flagext.DefaultValues(limits)
+		limits.EnforceMetricName = false
+		limits.TimestampPolicy = policy
+		ingester := &mockIngester{}
+
+		d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
+		defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+
+		req := makeWriteRequest(2, 10)
+		// The first entry comes from a client whose clock is 5 minutes ahead.
+		req.Streams[0].Entries[0].Timestamp = time.Now().Add(5 * time.Minute)
+		req.Streams[0].Entries[1].Timestamp = time.Now().Add(-time.Hour)
+		_, err := d.Push(ctx, req)
+		require.NoError(t, err)
+		require.Len(t, ingester.pushed[0].Streams[0].Entries, 2)
+		return ingester.pushed[0].Streams[0].Entries[1]
+	}
+
+	t.Run("trust keeps the timestamp", func(t *testing.T) {
+		entry := push(t, validation.TimestampPolicyTrust)
+		require.WithinDuration(t, time.Now().Add(-time.Hour), entry.Timestamp, time.Minute)
+		require.Equal(t, "1         ", entry.Line)
+	})
+
+	t.Run("clamp replaces the timestamp with the arrival time", func(t *testing.T) {
+		entry := push(t, validation.TimestampPolicyClamp)
+		require.WithinDuration(t, time.Now(), entry.Timestamp, time.Minute)
+		require.Equal(t, "1         ", entry.Line)
+	})
+
+	t.Run("annotate adds the arrival time to the line", func(t *testing.T) {
+		entry := push(t, validation.TimestampPolicyAnnotate)
+		require.WithinDuration(t, time.Now().Add(-time.Hour), entry.Timestamp, time.Minute)
+		require.Regexp(t, `^1          \[received at \S+Z\]$`, entry.Line)
+	})
+
+	t.Run("annotate keeps the lines within the max line size", func(t *testing.T) {
+		limits := &validation.Limits{}
+		flagext.DefaultValues(limits)
+		limits.EnforceMetricName = false
+		limits.TimestampPolicy = validation.TimestampPolicyAnnotate
+		limits.MaxLineSize = 100
+		ingester := &mockIngester{}
+
+		d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
+		defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+
+		req := makeWriteRequest(1, 100)
+		req.Streams[0].Entries[0].Timestamp = time.Now().Add(-time.Hour)
+		_, err := d.Push(ctx, req)
+		require.NoError(t, err)
+		require.Len(t, ingester.pushed[0].Streams[0].Entries, 1)
+		line := ingester.pushed[0].Streams[0].Entries[0].Line
+		require.Len(t, line, 100)
+		require.Regexp(t, `^0 +\[received at \S+Z\]$`, line)
+	})
+
+	t.Run("clamp keeps the entries in order without unordered writes", func(t *testing.T) {
+		limits := &validation.Limits{}
+		flagext.DefaultValues(limits)
+		limits.EnforceMetricName = false
+		limits.TimestampPolicy = validation.TimestampPolicyClamp
+		limits.UnorderedWrites = false
+		ingester := &mockIngester{}
+
+		d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
+		defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+
+		req := makeWriteRequest(2, 10)
+		req.Streams[0].Entries[0].Timestamp = time.Now().Add(5 * time.Minute)
+		req.Streams[0].Entries[1].Timestamp = time.Now().Add(-time.Hour)
+		_, err := d.Push(ctx, req)
+		require.NoError(t, err)
+		entries := ingester.pushed[0].Streams[0].Entries
+		require.Len(t, entries, 2)
+		require.Equal(t, "1         ", entries[0].Line)
+		require.Equal(t, "0         ", entries[1].Line)
+		require.True(t, entries[0].Timestamp.Before(entries[1].Timestamp))
+		// the request is not modified.
+		require.Equal(t, "0         ", req.Streams[0].Entries[0].Line)
+	})
+}
+
+func Test_truncateLine(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		maxSize  int
+		annotate bool
+		want     string
+	}{
+		{"foobar", 3, false, "foo"},
+		{"foobar", 3, true, "foo"},
+		{strings.Repeat("a", 30), 25, true, "aa...[truncated 28 bytes]"},
+		{strings.Repeat("a", 40), 25, true, "aa...[truncated 38 bytes]"},
+		{strings.Repeat("a", 1000), 40, true, strings.Repeat("a", 16) + "...[truncated 984 bytes]"},
+		{"fooé", 4, false, "foo"},
+		{strings.Repeat("é", 20), 26, true, "é...[truncated 38 bytes]"},
+	} {
+		got := truncateLine(tc.line, tc.maxSize, tc.annotate)
+		require.Equal(t, tc.want, got)
+		require.LessOrEqual(t, len(got), tc.maxSize)
+		require.True(t, utf8.ValidString(got))
+	}
+}
+
 func Benchmark_SortLabelsOnPush(b *testing.B) {
 	limits := &validation.Limits{}
 	flagext.DefaultValues(limits)
@@ -308,6 +490,35 @@ func TestDistributor_PushIngestionRateLimiter(t *testing.T) {
 	}
 }
 
+func TestDistributor_PushOrgIngestionRateLimiter(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.EnforceMetricName = false
+	limits.IngestionRateStrategy = validation.LocalIngestionRateStrategy
+	limits.IngestionRateMB = 10 * (1.0 / float64(bytesInMB))
+	limits.IngestionBurstSizeMB = 10 * (1.0 / float64(bytesInMB))
+
+	d := prepare(t, limits, nil, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+	d.overrides.SetTenantHierarchy(validation.TenantHierarchyConfig{Separator: "."})
+
+	// the sub-tenants of an org share its ingestion rate limit.
+	response, err := d.Push(user.InjectOrgID(context.Background(), "acme.payments"), makeWriteRequest(1, 6))
+	require.NoError(t, err)
+	require.Equal(t, success, response)
+
+	_, err = d.Push(user.InjectOrgID(context.Background(), "acme.search"), makeWriteRequest(1, 5))
+	require.Equal(t, httpgrpc.Errorf(http.StatusTooManyRequests, validation.OrgRateLimitedErrorMsg, "acme", 10, 1, 5), err)
+	// the push rejected by the org isn't charged to the sub-tenant.
+	_, ok := d.ingestionRateLimiter.reserveN(time.Now(), "acme.search", 10)
+	require.True(t, ok)
+
+	// tenants outside of the org aren't limited by it.
+	response, err = d.Push(user.InjectOrgID(context.Background(), "acmecorp"), makeWriteRequest(1, 5))
+	require.NoError(t, err)
+	require.Equal(t, success, response)
+}
+
 func prepare(t *testing.T, limits *validation.Limits, kvStore kv.Client, factory func(addr string) (ring_client.PoolClient, error)) *Distributor {
 	var (
 		distributorConfig Config
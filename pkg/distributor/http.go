@@ -1,8 +1,11 @@
 package distributor
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/cortexproject/cortex/pkg/tenant"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
@@ -10,22 +13,43 @@ import (
 	"github.com/weaveworks/common/httpgrpc"
 
 	"github.com/grafana/loki/pkg/loghttp/push"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/validation"
 )
 
 // PushHandler reads a snappy-compressed proto from the HTTP body.
 func (d *Distributor) PushHandler(w http.ResponseWriter, r *http.Request) {
 	logger := util_log.WithContext(r.Context(), util_log.Logger)
 	userID, _ := tenant.TenantID(r.Context())
-	req, err := push.ParseRequest(logger, userID, r, d.tenantsRetention)
+	// Advertise the supported encodings, so that clients can pick the cheapest one they support.
+	w.Header().Set("Accept-Encoding", push.SupportedEncodings)
+	req, err := push.ParseRequest(logger, userID, r, d.tenantsRetention, d.cfg.PushBodyLimits)
 	if err != nil {
+		code := http.StatusBadRequest
+		var encodingErr *push.UnsupportedEncodingError
+		if errors.As(err, &encodingErr) {
+			code = http.StatusUnsupportedMediaType
+		}
+		if d.tenantConfigs.LogPushRequest(userID) {
+			level.Debug(logger).Log(
+				"msg", "push request failed",
+				"code", code,
+				"err", err,
+			)
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	if err := d.checkSourceLimits(r, userID, req); err != nil {
 		if d.tenantConfigs.LogPushRequest(userID) {
 			level.Debug(logger).Log(
 				"msg", "push request failed",
-				"code", http.StatusBadRequest,
+				"code", http.StatusTooManyRequests,
 				"err", err,
 			)
 		}
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
 		return
 	}
 
@@ -73,3 +97,35 @@ func (d *Distributor) PushHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// checkSourceLimits enforces the rate limits of the source IP and of the principal
+// of a push request, before the request counts against the rate limit of the tenant.
+func (d *Distributor) checkSourceLimits(r *http.Request, userID string, req *logproto.PushRequest) error {
+	var lines, size int
+	for _, stream := range req.Streams {
+		for _, entry := range stream.Entries {
+			lines++
+			size += len(entry.Line)
+		}
+	}
+
+	now := time.Now()
+	ip := d.cfg.PushSourceLimits.sourceIP(r)
+	reservation, limit, ok := d.sourceIPRateLimiter.reserveN(now, userID, ip, size)
+	if !ok {
+		validation.DiscardedSamples.WithLabelValues(validation.SourceIPRateLimited, userID).Add(float64(lines))
+		validation.DiscardedBytes.WithLabelValues(validation.SourceIPRateLimited, userID).Add(float64(size))
+		return fmt.Errorf(validation.SourceIPRateLimitedErrorMsg, ip, int(limit), lines, size)
+	}
+	principal := d.cfg.PushSourceLimits.principal(r)
+	if _, limit, ok := d.principalRateLimiter.reserveN(now, userID, principal, size); !ok {
+		// The request isn't ingested, give its bytes back to the source IP.
+		if reservation != nil {
+			reservation.CancelAt(now)
+		}
+		validation.DiscardedSamples.WithLabelValues(validation.PrincipalRateLimited, userID).Add(float64(lines))
+		validation.DiscardedBytes.WithLabelValues(validation.PrincipalRateLimited, userID).Add(float64(size))
+		return fmt.Errorf(validation.PrincipalRateLimitedErrorMsg, principal, int(limit), lines, size)
+	}
+	return nil
+}
@@ -1 +1,172 @@
 package distributor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/loghttp/push"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+func TestDistributor_PushHandlerSourceLimits(t *testing.T) {
+	type testPush struct {
+		remoteAddr   string
+		forwardedFor string
+		user         string
+		bytes        int
+		expectedCode int
+	}
+
+	for name, tc := range map[string]struct {
+		sourceIPHeader string
+		limits         func(*validation.Limits)
+		pushes         []testPush
+	}{
+		"source IP": {
+			limits: func(l *validation.Limits) {
+				l.IngestionRatePerSourceIP = 10
+			},
+			pushes: []testPush{
+				{remoteAddr: "10.0.0.1:1234", bytes: 6, expectedCode: http.StatusNoContent},
+				{remoteAddr: "10.0.0.1:4321", bytes: 6, expectedCode: http.StatusTooManyRequests},
+				{remoteAddr: "10.0.0.2:1234", bytes: 6, expectedCode: http.StatusNoContent},
+				{remoteAddr: "10.0.0.1:1234", bytes: 4, expectedCode: http.StatusNoContent},
+			},
+		},
+		"source IP header": {
+			sourceIPHeader: "X-Forwarded-For",
+			limits: func(l *validation.Limits) {
+				l.IngestionRatePerSourceIP = 10
+			},
+			pushes: []testPush{
+				{remoteAddr: "10.0.0.1:1234", forwardedFor: "192.168.0.1", bytes: 6, expectedCode: http.StatusNoContent},
+				// The addresses sent by the client are ignored.
+				{remoteAddr: "10.0.0.1:1234", forwardedFor: "192.168.0.2, 192.168.0.1", bytes: 6, expectedCode: http.StatusTooManyRequests},
+				{remoteAddr: "10.0.0.1:1234", forwardedFor: "192.168.0.2", bytes: 6, expectedCode: http.StatusNoContent},
+			},
+		},
+		"principal": {
+			limits: func(l *validation.Limits) {
+				l.IngestionRatePerPrincipal = 10
+				l.IngestionBurstSizePerPrincipal = 12
+			},
+			pushes: []testPush{
+				{remoteAddr: "10.0.0.1:1234", user: "promtail", bytes: 12, expectedCode: http.StatusNoContent},
+				{remoteAddr: "10.0.0.2:1234", user: "promtail", bytes: 6, expectedCode: http.StatusTooManyRequests},
+				{remoteAddr: "10.0.0.1:1234", user: "fluentd", bytes: 6, expectedCode: http.StatusNoContent},
+				{remoteAddr: "10.0.0.1:1234", bytes: 20, expectedCode: http.StatusNoContent},
+			},
+		},
+		"principal limited after source IP": {
+			limits: func(l *validation.Limits) {
+				l.IngestionRatePerSourceIP = 10
+				l.IngestionRatePerPrincipal = 10
+			},
+			pushes: []testPush{
+				{remoteAddr: "10.0.0.1:1234", user: "promtail", bytes: 6, expectedCode: http.StatusNoContent},
+				{remoteAddr: "10.0.0.2:1234", user: "promtail", bytes: 6, expectedCode: http.StatusTooManyRequests},
+				// The bytes of the rejected push were given back to 10.0.0.2.
+				{remoteAddr: "10.0.0.2:1234", user: "fluentd", bytes: 10, expectedCode: http.StatusNoContent},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			limits := &validation.Limits{}
+			flagext.DefaultValues(limits)
+			limits.EnforceMetricName = false
+			tc.limits(limits)
+
+			d := prepare(t, limits, nil, nil)
+			defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+			d.cfg.PushSourceLimits.SourceIPHeader = tc.sourceIPHeader
+
+			for i, push := range tc.pushes {
+				body := fmt.Sprintf(`{"streams":[{"stream":{"foo":"bar"},"values":[["%d","%s"]]}]}`, time.Now().UnixNano(), strings.Repeat("a", push.bytes))
+				req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", strings.NewReader(body)).WithContext(ctx)
+				req.Header.Set("Content-Type", "application/json")
+				req.RemoteAddr = push.remoteAddr
+				if push.forwardedFor != "" {
+					req.Header.Set("X-Forwarded-For", push.forwardedFor)
+				}
+				if push.user != "" {
+					req.SetBasicAuth(push.user, "secret")
+				}
+				rec := httptest.NewRecorder()
+				d.PushHandler(rec, req)
+				require.Equal(t, push.expectedCode, rec.Code, "push %d: %s", i, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSourceLimitsConfig_principal(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", nil)
+	req.SetBasicAuth("promtail", "secret")
+	req.Header.Set("X-Forwarded-User", "fluentd")
+
+	require.Equal(t, "promtail", SourceLimitsConfig{}.principal(req))
+	require.Equal(t, "fluentd", SourceLimitsConfig{PrincipalHeader: "X-Forwarded-User"}.principal(req))
+	require.Equal(t, "", SourceLimitsConfig{PrincipalHeader: "X-Auth-Request-User"}.principal(req))
+}
+
+func TestDistributor_PushHandlerEncodings(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.EnforceMetricName = false
+	d := prepare(t, limits, nil, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+	d.cfg.PushBodyLimits.MaxUncompressedSize = 100
+
+	for _, tc := range []struct {
+		encoding     string
+		bytes        int
+		expectedCode int
+	}{
+		{bytes: 10, expectedCode: http.StatusNoContent},
+		{bytes: 100, expectedCode: http.StatusBadRequest},
+		{encoding: "br", bytes: 10, expectedCode: http.StatusUnsupportedMediaType},
+	} {
+		body := fmt.Sprintf(`{"streams":[{"stream":{"foo":"bar"},"values":[["%d","%s"]]}]}`, time.Now().UnixNano(), strings.Repeat("a", tc.bytes))
+		req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", strings.NewReader(body)).WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		if tc.encoding != "" {
+			req.Header.Set("Content-Encoding", tc.encoding)
+		}
+		rec := httptest.NewRecorder()
+		d.PushHandler(rec, req)
+		require.Equal(t, tc.expectedCode, rec.Code, rec.Body.String())
+		require.Equal(t, push.SupportedEncodings, rec.Header().Get("Accept-Encoding"))
+	}
+}
+
+func TestSourceIP(t *testing.T) {
+	for _, tc := range []struct {
+		forwardedFor   string
+		trustedProxies int
+		expected       string
+	}{
+		{"", 1, "10.0.0.1"},
+		{"192.168.0.1", 1, "192.168.0.1"},
+		{"spoofed, 192.168.0.1", 1, "192.168.0.1"},
+		{"spoofed, 192.168.0.1, 10.0.0.2", 2, "192.168.0.1"},
+		{"192.168.0.1", 2, "192.168.0.1"},
+	} {
+		cfg := SourceLimitsConfig{SourceIPHeader: "X-Forwarded-For", SourceIPTrustedProxies: tc.trustedProxies}
+		req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if tc.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+		}
+		require.Equal(t, tc.expected, cfg.sourceIP(req), tc.forwardedFor)
+	}
+}
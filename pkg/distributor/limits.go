@@ -6,10 +6,17 @@ import "time"
 type Limits interface {
 	MaxLineSize(userID string) int
 	MaxLineSizeTruncate(userID string) bool
+	MaxLineSizeAnnotate(userID string) bool
+	MaxLineSizeLabel(userID string) string
+	TimestampPolicy(userID string) string
+	TimestampMaxSkew(userID string) time.Duration
+	UnorderedWrites(userID string) bool
+	AllowStructuredMetadata(userID string) bool
 	EnforceMetricName(userID string) bool
 	MaxLabelNamesPerSeries(userID string) int
 	MaxLabelNameLength(userID string) int
 	MaxLabelValueLength(userID string) int
+	LabelValueLengthPolicy(userID string) string
 
 	CreationGracePeriod(userID string) time.Duration
 	RejectOldSamples(userID string) bool
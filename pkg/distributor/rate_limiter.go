@@ -0,0 +1,81 @@
+package distributor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/grafana/dskit/limiter"
+	"golang.org/x/time/rate"
+)
+
+// tenantRateLimiter is a multi-tenant rate limiter like the dskit one, whose tokens are
+// reserved rather than consumed: a push counts against the rate limit of its tenant and of
+// the orgs of the tenant, and the tokens reserved before one of them rejects the push are
+// given back.
+type tenantRateLimiter struct {
+	strategy      limiter.RateLimiterStrategy
+	recheckPeriod time.Duration
+
+	mtx     sync.Mutex
+	tenants map[string]*tenantLimiter
+}
+
+type tenantLimiter struct {
+	limiter   *rate.Limiter
+	recheckAt time.Time
+}
+
+func newTenantRateLimiter(strategy limiter.RateLimiterStrategy, recheckPeriod time.Duration) *tenantRateLimiter {
+	return &tenantRateLimiter{
+		strategy:      strategy,
+		recheckPeriod: recheckPeriod,
+		tenants:       map[string]*tenantLimiter{},
+	}
+}
+
+// reserveN reserves n tokens of a tenant. It returns false, without reserving anything,
+// when the tokens aren't available at time now.
+func (l *tenantRateLimiter) reserveN(now time.Time, tenantID string, n int) (*rate.Reservation, bool) {
+	r := l.tenantLimiter(now, tenantID).ReserveN(now, n)
+	if !r.OK() {
+		return nil, false
+	}
+	if r.DelayFrom(now) > 0 {
+		r.CancelAt(now)
+		return nil, false
+	}
+	return r, true
+}
+
+// Limit returns the currently configured maximum overall tokens rate of a tenant.
+func (l *tenantRateLimiter) Limit(now time.Time, tenantID string) float64 {
+	return float64(l.tenantLimiter(now, tenantID).Limit())
+}
+
+// tenantLimiter returns the limiter of a tenant, its limit and burst are updated from
+// the strategy every recheck period.
+func (l *tenantRateLimiter) tenantLimiter(now time.Time, tenantID string) *rate.Limiter {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	entry, ok := l.tenants[tenantID]
+	if !ok {
+		entry = &tenantLimiter{
+			limiter:   rate.NewLimiter(rate.Limit(l.strategy.Limit(tenantID)), l.strategy.Burst(tenantID)),
+			recheckAt: now.Add(l.recheckPeriod),
+		}
+		l.tenants[tenantID] = entry
+		return entry.limiter
+	}
+	if now.Before(entry.recheckAt) {
+		return entry.limiter
+	}
+	if limit := rate.Limit(l.strategy.Limit(tenantID)); entry.limiter.Limit() != limit {
+		entry.limiter.SetLimitAt(now, limit)
+	}
+	if burst := l.strategy.Burst(tenantID); entry.limiter.Burst() != burst {
+		entry.limiter.SetBurstAt(now, burst)
+	}
+	entry.recheckAt = now.Add(l.recheckPeriod)
+	return entry.limiter
+}
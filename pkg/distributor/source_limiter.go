@@ -0,0 +1,113 @@
+package distributor
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/loki/pkg/validation"
+)
+
+// SourceLimitsConfig configures how the sources of the push API are identified
+// for the per source IP and per principal rate limits.
+type SourceLimitsConfig struct {
+	SourceIPHeader         string `yaml:"source_ip_header"`
+	SourceIPTrustedProxies int    `yaml:"source_ip_trusted_proxies"`
+	PrincipalHeader        string `yaml:"principal_header"`
+	MaxTrackedSources      int    `yaml:"max_tracked_sources"`
+}
+
+// RegisterFlags registers the flags of the push sources.
+func (cfg *SourceLimitsConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.SourceIPHeader, "distributor.push-source-ip-header", "", "Header containing the IP of the client of push requests, e.g. X-Forwarded-For when Loki is behind a proxy. Default to the remote address of the connection.")
+	f.IntVar(&cfg.SourceIPTrustedProxies, "distributor.push-source-ip-trusted-proxies", 1, "Number of proxies in front of Loki appending the address of their client to the source IP header. The address appended by the farthest of them is used, as the addresses before it are sent by the client.")
+	f.StringVar(&cfg.PrincipalHeader, "distributor.push-principal-header", "", "Header containing the principal authenticated by the proxy in front of Loki, e.g. X-Forwarded-User. Default to the user name of the basic authentication.")
+	f.IntVar(&cfg.MaxTrackedSources, "distributor.push-max-tracked-sources", 10000, "Maximum number of source IPs and principals whose rate is tracked by each distributor. The least recently seen sources are forgotten first.")
+}
+
+// sourceIP returns the IP of the client of a push request.
+func (cfg SourceLimitsConfig) sourceIP(r *http.Request) string {
+	if cfg.SourceIPHeader != "" {
+		// The client controls the addresses before the ones appended by the trusted proxies.
+		addrs := strings.Split(r.Header.Get(cfg.SourceIPHeader), ",")
+		i := len(addrs) - cfg.SourceIPTrustedProxies
+		if cfg.SourceIPTrustedProxies < 1 {
+			i = len(addrs) - 1
+		}
+		if i < 0 {
+			i = 0
+		}
+		if ip := strings.TrimSpace(addrs[i]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// principal returns the authenticated principal of a push request, if any.
+func (cfg SourceLimitsConfig) principal(r *http.Request) string {
+	if cfg.PrincipalHeader != "" {
+		return r.Header.Get(cfg.PrincipalHeader)
+	}
+	username, _, _ := r.BasicAuth()
+	return username
+}
+
+// sourceRateLimiter rate limits the sources of a tenant, such as its source IPs,
+// with a token bucket per source. Only the most recently seen sources are tracked.
+type sourceRateLimiter struct {
+	limit    func(userID string) validation.RateLimit
+	limiters *lru.Cache
+}
+
+func newSourceRateLimiter(limit func(userID string) validation.RateLimit, size int) (*sourceRateLimiter, error) {
+	limiters, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &sourceRateLimiter{
+		limit:    limit,
+		limiters: limiters,
+	}, nil
+}
+
+// reserveN reserves n bytes for the source of a tenant. It returns false and the
+// limit of the source when its rate limit is exceeded. The reservation is nil when
+// the source isn't limited.
+func (l *sourceRateLimiter) reserveN(now time.Time, userID, source string, n int) (*rate.Reservation, rate.Limit, bool) {
+	rl := l.limit(userID)
+	if rl.Limit == rate.Inf || source == "" {
+		return nil, rl.Limit, true
+	}
+
+	key := userID + "/" + source
+	var lim *rate.Limiter
+	if v, ok := l.limiters.Get(key); ok {
+		lim = v.(*rate.Limiter)
+	}
+	if lim == nil || lim.Limit() != rl.Limit || lim.Burst() != rl.Burst {
+		// Like the stream rate limiters, a new limiter is created when the limits
+		// of the tenant change rather than altering the existing one.
+		lim = rate.NewLimiter(rl.Limit, rl.Burst)
+		l.limiters.Add(key, lim)
+	}
+
+	r := lim.ReserveN(now, n)
+	if !r.OK() {
+		return nil, rl.Limit, false
+	}
+	if r.DelayFrom(now) > 0 {
+		r.CancelAt(now)
+		return nil, rl.Limit, false
+	}
+	return r, rl.Limit, true
+}
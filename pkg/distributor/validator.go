@@ -2,9 +2,12 @@ package distributor
 
 import (
 	"errors"
+	"fmt"
+	"hash/fnv"
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/weaveworks/common/httpgrpc"
@@ -13,6 +16,9 @@ import (
 	"github.com/grafana/loki/pkg/validation"
 )
 
+// hashedLabelValueMarker ends label values shortened with the hash label value length policy.
+const hashedLabelValueMarker = "~%016x"
+
 type Validator struct {
 	Limits
 }
@@ -31,10 +37,21 @@ type validationContext struct {
 
 	maxLineSize         int
 	maxLineSizeTruncate bool
+	maxLineSizeAnnotate bool
+	maxLineSizeLabel    string
+
+	receivedAt       time.Time
+	timestampPolicy  string
+	timestampMaxSkew time.Duration
+	unorderedWrites  bool
+
+	// structuredMetadata is whether the entries may have structured metadata.
+	structuredMetadata bool
 
 	maxLabelNamesPerSeries int
 	maxLabelNameLength     int
 	maxLabelValueLength    int
+	labelValueLengthPolicy string
 
 	userID string
 }
@@ -48,9 +65,17 @@ func (v Validator) getValidationContextFor(userID string) validationContext {
 		creationGracePeriod:    now.Add(v.CreationGracePeriod(userID)).UnixNano(),
 		maxLineSize:            v.MaxLineSize(userID),
 		maxLineSizeTruncate:    v.MaxLineSizeTruncate(userID),
+		maxLineSizeAnnotate:    v.MaxLineSizeAnnotate(userID),
+		maxLineSizeLabel:       v.MaxLineSizeLabel(userID),
+		receivedAt:             now,
+		timestampPolicy:        v.TimestampPolicy(userID),
+		timestampMaxSkew:       v.TimestampMaxSkew(userID),
+		unorderedWrites:        v.UnorderedWrites(userID),
+		structuredMetadata:     v.AllowStructuredMetadata(userID),
 		maxLabelNamesPerSeries: v.MaxLabelNamesPerSeries(userID),
 		maxLabelNameLength:     v.MaxLabelNameLength(userID),
 		maxLabelValueLength:    v.MaxLabelValueLength(userID),
+		labelValueLengthPolicy: v.LabelValueLengthPolicy(userID),
 	}
 }
 
@@ -79,14 +104,22 @@ func (v Validator) ValidateEntry(ctx validationContext, labels string, entry log
 		return httpgrpc.Errorf(http.StatusBadRequest, validation.LineTooLongErrorMsg, maxSize, labels, len(entry.Line))
 	}
 
+	if len(entry.StructuredMetadata) > 0 && !ctx.structuredMetadata {
+		validation.DiscardedSamples.WithLabelValues(validation.DisallowedStructuredMetadata, ctx.userID).Inc()
+		validation.DiscardedBytes.WithLabelValues(validation.DisallowedStructuredMetadata, ctx.userID).Add(float64(len(entry.Line)))
+		return httpgrpc.Errorf(http.StatusBadRequest, validation.DisallowedStructuredMetadataErrorMsg, labels)
+	}
+
 	return nil
 }
 
-// Validate labels returns an error if the labels are invalid
-func (v Validator) ValidateLabels(ctx validationContext, ls labels.Labels, stream logproto.Stream) error {
+// Validate labels returns an error if the labels are invalid. Overlong label values are
+// shortened in place instead when the label value length policy allows it, shortened reports
+// whether any was.
+func (v Validator) ValidateLabels(ctx validationContext, ls labels.Labels, stream logproto.Stream) (shortened bool, err error) {
 	if len(ls) == 0 {
 		validation.DiscardedSamples.WithLabelValues(validation.MissingLabels, ctx.userID).Inc()
-		return httpgrpc.Errorf(http.StatusBadRequest, validation.MissingLabelsErrorMsg)
+		return false, httpgrpc.Errorf(http.StatusBadRequest, validation.MissingLabelsErrorMsg)
 	}
 	numLabelNames := len(ls)
 	if numLabelNames > ctx.maxLabelNamesPerSeries {
@@ -96,24 +129,63 @@ func (v Validator) ValidateLabels(ctx validationContext, ls labels.Labels, strea
 			bytes += len(e.Line)
 		}
 		validation.DiscardedBytes.WithLabelValues(validation.MaxLabelNamesPerSeries, ctx.userID).Add(float64(bytes))
-		return httpgrpc.Errorf(http.StatusBadRequest, validation.MaxLabelNamesPerSeriesErrorMsg, stream.Labels, numLabelNames, ctx.maxLabelNamesPerSeries)
+		validation.LabelLimitsExceeded.WithLabelValues(validation.MaxLabelNamesPerSeries, ctx.userID).Inc()
+		return false, httpgrpc.Errorf(http.StatusBadRequest, validation.MaxLabelNamesPerSeriesErrorMsg, stream.Labels, numLabelNames, ctx.maxLabelNamesPerSeries)
 	}
 
 	lastLabelName := ""
-	for _, l := range ls {
+	for i, l := range ls {
 		if len(l.Name) > ctx.maxLabelNameLength {
+			validation.LabelLimitsExceeded.WithLabelValues(validation.LabelNameTooLong, ctx.userID).Inc()
 			updateMetrics(validation.LabelNameTooLong, ctx.userID, stream)
-			return httpgrpc.Errorf(http.StatusBadRequest, validation.LabelNameTooLongErrorMsg, stream.Labels, l.Name)
+			return false, httpgrpc.Errorf(http.StatusBadRequest, validation.LabelNameTooLongErrorMsg, stream.Labels, l.Name)
 		} else if len(l.Value) > ctx.maxLabelValueLength {
-			updateMetrics(validation.LabelValueTooLong, ctx.userID, stream)
-			return httpgrpc.Errorf(http.StatusBadRequest, validation.LabelValueTooLongErrorMsg, stream.Labels, l.Value)
-		} else if cmp := strings.Compare(lastLabelName, l.Name); cmp == 0 {
+			validation.LabelLimitsExceeded.WithLabelValues(validation.LabelValueTooLong, ctx.userID).Inc()
+			if ctx.labelValueLengthPolicy != validation.LabelValueLengthPolicyTruncate && ctx.labelValueLengthPolicy != validation.LabelValueLengthPolicyHash {
+				updateMetrics(validation.LabelValueTooLong, ctx.userID, stream)
+				return false, httpgrpc.Errorf(http.StatusBadRequest, validation.LabelValueTooLongErrorMsg, stream.Labels, l.Value)
+			}
+			ls[i].Value = shortenLabelValue(l.Value, ctx.maxLabelValueLength, ctx.labelValueLengthPolicy == validation.LabelValueLengthPolicyHash)
+			shortened = true
+			validation.MutatedSamples.WithLabelValues(validation.LabelValueTooLong, ctx.userID).Add(float64(len(stream.Entries)))
+			validation.MutatedBytes.WithLabelValues(validation.LabelValueTooLong, ctx.userID).Add(float64(len(l.Value) - len(ls[i].Value)))
+		}
+		if cmp := strings.Compare(lastLabelName, l.Name); cmp == 0 {
 			updateMetrics(validation.DuplicateLabelNames, ctx.userID, stream)
-			return httpgrpc.Errorf(http.StatusBadRequest, validation.DuplicateLabelNamesErrorMsg, stream.Labels, l.Name)
+			return false, httpgrpc.Errorf(http.StatusBadRequest, validation.DuplicateLabelNamesErrorMsg, stream.Labels, l.Name)
 		}
 		lastLabelName = l.Name
 	}
-	return nil
+	return shortened, nil
+}
+
+// shortenLabelValue cuts the value to at most maxLength bytes, on a character boundary. When hash
+// is true the end of the value is replaced by a hash of the whole value, so that values sharing a
+// prefix remain distinct.
+func shortenLabelValue(value string, maxLength int, hash bool) string {
+	if !hash {
+		return truncateUTF8(value, maxLength)
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(value))
+	suffix := fmt.Sprintf(hashedLabelValueMarker, h.Sum64())
+	keep := maxLength - len(suffix)
+	if keep < 0 {
+		// the hash doesn't fit.
+		return suffix[len(suffix)-maxLength:]
+	}
+	return truncateUTF8(value, keep) + suffix
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
 }
 
 func updateMetrics(reason, userID string, stream logproto.Stream) {
@@ -2,6 +2,7 @@ package distributor
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -79,6 +80,25 @@ func TestValidator_ValidateEntry(t *testing.T) {
 			logproto.Entry{Timestamp: testTime, Line: "12345678901"},
 			httpgrpc.Errorf(http.StatusBadRequest, validation.LineTooLongErrorMsg, 10, testStreamLabels, 11),
 		},
+		{
+			"disallowed structured metadata",
+			"test",
+			nil,
+			logproto.Entry{Timestamp: testTime, Line: "test", StructuredMetadata: []logproto.LabelPair{{Name: "trace_id", Value: "abc"}}},
+			httpgrpc.Errorf(http.StatusBadRequest, validation.DisallowedStructuredMetadataErrorMsg, testStreamLabels),
+		},
+		{
+			"allowed structured metadata",
+			"test",
+			fakeLimits{
+				&validation.Limits{
+					UnorderedWrites:         true,
+					AllowStructuredMetadata: true,
+				},
+			},
+			logproto.Entry{Timestamp: testTime, Line: "test", StructuredMetadata: []logproto.LabelPair{{Name: "trace_id", Value: "abc"}}},
+			nil,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -190,12 +210,55 @@ func TestValidator_ValidateLabels(t *testing.T) {
 			v, err := NewValidator(o)
 			assert.NoError(t, err)
 
-			err = v.ValidateLabels(v.getValidationContextFor(tt.userID), mustParseLabels(tt.labels), logproto.Stream{Labels: tt.labels})
+			_, err = v.ValidateLabels(v.getValidationContextFor(tt.userID), mustParseLabels(tt.labels), logproto.Stream{Labels: tt.labels})
 			assert.Equal(t, tt.expected, err)
 		})
 	}
 }
 
+func TestValidator_ValidateLabels_LabelValueLengthPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy   string
+		expected string
+	}{
+		{validation.LabelValueLengthPolicyTruncate, `{app="api", path="/api/v1/push/tenant/"}`},
+		{validation.LabelValueLengthPolicyHash, `{app="api", path="/ap~aca4772ec1972d9e"}`},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			l := &validation.Limits{}
+			flagext.DefaultValues(l)
+			l.MaxLabelValueLength = 20
+			l.LabelValueLengthPolicy = tc.policy
+			o, err := validation.NewOverrides(*l, nil)
+			assert.NoError(t, err)
+			v, err := NewValidator(o)
+			assert.NoError(t, err)
+
+			stream := `{app="api", path="/api/v1/push/tenant/logs"}`
+			ls := mustParseLabels(stream)
+			shortened, err := v.ValidateLabels(v.getValidationContextFor("test"), ls, logproto.Stream{Labels: stream})
+			assert.NoError(t, err)
+			assert.True(t, shortened)
+			assert.Equal(t, tc.expected, ls.String())
+		})
+	}
+}
+
+func TestShortenLabelValue(t *testing.T) {
+	value := strings.Repeat("a", 40)
+	hashed := shortenLabelValue(value, 30, true)
+	assert.Len(t, hashed, 30)
+	assert.Equal(t, strings.Repeat("a", 13), hashed[:13])
+	assert.NotEqual(t, hashed, shortenLabelValue(value+"b", 30, true))
+	assert.Len(t, shortenLabelValue(value, 4, true), 4)
+	assert.Equal(t, "aaaa", shortenLabelValue(value, 4, false))
+
+	// the values are not cut in the middle of a character.
+	assert.Equal(t, "a", shortenLabelValue("aéb", 2, false))
+	assert.Equal(t, "aé", shortenLabelValue("aéb", 3, false))
+	assert.Equal(t, "a~", shortenLabelValue("aé"+value, 19, true)[:2])
+}
+
 func mustParseLabels(s string) labels.Labels {
 	ls, err := logql.ParseLabels(s)
 	if err != nil {
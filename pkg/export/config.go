@@ -0,0 +1,51 @@
+package export
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/grafana/loki/pkg/util/flagext"
+)
+
+// Config configures the exporter writing the logs of a query to object storage.
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// SharedStoreType is the object store the exports are written to.
+	SharedStoreType string `yaml:"shared_store"`
+	// Prefix is the path prefix of the exports in the object store.
+	Prefix        string           `yaml:"prefix"`
+	MaxConcurrent int              `yaml:"max_concurrent"`
+	MaxQueued     int              `yaml:"max_queued"`
+	MaxPartSize   flagext.ByteSize `yaml:"max_part_size"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "exporter.enabled", false, "Enable the export API, writing the logs of a query to object storage asynchronously.")
+	f.StringVar(&cfg.SharedStoreType, "exporter.shared-store", "", "Object store the exports are written to (aws, azure, gcs, swift, filesystem).")
+	f.StringVar(&cfg.Prefix, "exporter.prefix", "exports", "Path prefix of the exports in the object store.")
+	f.IntVar(&cfg.MaxConcurrent, "exporter.max-concurrent", 1, "Maximum number of exports run concurrently by an exporter.")
+	f.IntVar(&cfg.MaxQueued, "exporter.max-queued", 100, "Maximum number of exports waiting to be run by an exporter, new exports are rejected beyond.")
+	cfg.MaxPartSize = 64 << 20
+	f.Var(&cfg.MaxPartSize, "exporter.max-part-size", "Maximum compressed size of the objects an export is written to. The logs of a partition are split in several parts beyond.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.SharedStoreType == "" {
+		return errors.New("exporter.shared-store must be set when the exporter is enabled")
+	}
+	if cfg.MaxConcurrent <= 0 {
+		return errors.New("exporter.max-concurrent must be greater than 0")
+	}
+	if cfg.MaxQueued < 0 {
+		return errors.New("exporter.max-queued must not be negative")
+	}
+	if cfg.MaxPartSize == 0 {
+		return errors.New("exporter.max-part-size must be greater than 0")
+	}
+	return nil
+}
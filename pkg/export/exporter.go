@@ -0,0 +1,431 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+// State is the state of an export.
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+)
+
+const (
+	statusObject = "status.json"
+	day          = 24 * time.Hour
+)
+
+var (
+	// ErrQueueFull is returned when an exporter has too many exports waiting to be run.
+	ErrQueueFull = errors.New("too many exports waiting to be run")
+	// ErrNotFound is returned when an export doesn't exist.
+	ErrNotFound = errors.New("export not found")
+)
+
+// Request describes the logs to export.
+type Request struct {
+	Query string
+	Start time.Time
+	End   time.Time
+	// PartitionBy are the labels the logs of a day are partitioned by.
+	PartitionBy []string
+	// Format is the format of the exported logs, jsonl if empty.
+	Format Format
+}
+
+// Status is the status of an export, stored along with the exported logs.
+type Status struct {
+	ID          string    `json:"id"`
+	Query       string    `json:"query"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	PartitionBy []string  `json:"partition_by,omitempty"`
+	Format      Format    `json:"format"`
+	// Location is the prefix of the objects of the export.
+	Location  string    `json:"location"`
+	State     State     `json:"state"`
+	Error     string    `json:"error,omitempty"`
+	Lines     int64     `json:"lines"`
+	Bytes     int64     `json:"bytes"`
+	Objects   int       `json:"objects"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type metrics struct {
+	exports *prometheus.CounterVec
+	lines   prometheus.Counter
+	bytes   prometheus.Counter
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	return &metrics{
+		exports: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "exporter_exports_total",
+			Help:      "Total number of exports run, by final state.",
+		}, []string{"state"}),
+		lines: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "exporter_lines_total",
+			Help:      "Total number of log lines exported.",
+		}),
+		bytes: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "exporter_written_bytes_total",
+			Help:      "Total number of compressed bytes written to object storage.",
+		}),
+	}
+}
+
+type job struct {
+	tenant string
+	req    Request
+	status *Status
+}
+
+// Exporter runs exports in the background: the logs of a query are written to object
+// storage as gzipped JSON lines or Parquet files, partitioned by day and by the values of some labels.
+// The status of an export is stored in object storage too, it can be read from any exporter.
+type Exporter struct {
+	services.Service
+
+	cfg          Config
+	querier      logql.Querier
+	objectClient chunk.ObjectClient
+	metrics      *metrics
+	logger       log.Logger
+
+	queue chan *job
+}
+
+// New creates an Exporter.
+func New(cfg Config, querier logql.Querier, objectClient chunk.ObjectClient, logger log.Logger, reg prometheus.Registerer) *Exporter {
+	e := &Exporter{
+		cfg:          cfg,
+		querier:      querier,
+		objectClient: objectClient,
+		metrics:      newMetrics(reg),
+		logger:       logger,
+		queue:        make(chan *job, cfg.MaxQueued),
+	}
+	e.Service = services.NewBasicService(nil, e.running, nil)
+	return e
+}
+
+func (e *Exporter) running(ctx context.Context) error {
+	done := make(chan struct{})
+	for i := 0; i < e.cfg.MaxConcurrent; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-e.queue:
+					e.run(ctx, j)
+				}
+			}
+		}()
+	}
+	for i := 0; i < e.cfg.MaxConcurrent; i++ {
+		<-done
+	}
+	return nil
+}
+
+// Submit validates an export request and queues it, the returned status is the initial
+// status of the export.
+func (e *Exporter) Submit(ctx context.Context, tenant string, req Request) (*Status, error) {
+	if _, err := logql.ParseLogSelector(req.Query, true); err != nil {
+		return nil, err
+	}
+	if !req.End.After(req.Start) {
+		return nil, errors.New("the end of the export must be after its start")
+	}
+	if req.Format == "" {
+		req.Format = FormatJSONL
+	}
+	if err := req.Format.validate(); err != nil {
+		return nil, err
+	}
+	for _, name := range req.PartitionBy {
+		if !model.LabelName(name).IsValid() {
+			return nil, fmt.Errorf("invalid partition label name %q", name)
+		}
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	status := &Status{
+		ID:          id,
+		Query:       req.Query,
+		Start:       req.Start.UTC(),
+		End:         req.End.UTC(),
+		PartitionBy: req.PartitionBy,
+		Format:      req.Format,
+		Location:    e.location(tenant, id),
+		State:       StateQueued,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	// The status is written before the export is queued, not to overwrite the status of a running export.
+	if err := e.writeStatus(ctx, tenant, status); err != nil {
+		return nil, fmt.Errorf("error writing export status: %w", err)
+	}
+	initial := *status
+	select {
+	case e.queue <- &job{tenant: tenant, req: req, status: status}:
+	default:
+		initial.State = StateFailed
+		initial.Error = ErrQueueFull.Error()
+		if err := e.writeStatus(ctx, tenant, &initial); err != nil {
+			level.Warn(e.logger).Log("msg", "error writing export status", "export", id, "err", err)
+		}
+		return nil, ErrQueueFull
+	}
+	return &initial, nil
+}
+
+// Status returns the status of an export of a tenant.
+func (e *Exporter) Status(ctx context.Context, tenant, id string) (*Status, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	r, err := e.objectClient.GetObject(ctx, path.Join(e.location(tenant, id), statusObject))
+	if err != nil {
+		if e.objectClient.IsObjectNotFoundErr(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer r.Close()
+	var status Status
+	if err := json.NewDecoder(r).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (e *Exporter) location(tenant, id string) string {
+	return path.Join(e.cfg.Prefix, tenant, id)
+}
+
+func (e *Exporter) writeStatus(ctx context.Context, tenant string, status *Status) error {
+	status.UpdatedAt = time.Now().UTC()
+	b, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return e.objectClient.PutObject(ctx, path.Join(e.location(tenant, status.ID), statusObject), bytes.NewReader(b))
+}
+
+func (e *Exporter) run(ctx context.Context, j *job) {
+	logger := log.With(e.logger, "export", j.status.ID, "tenant", j.tenant)
+	level.Info(logger).Log("msg", "starting export", "query", j.req.Query, "start", j.req.Start, "end", j.req.End)
+	j.status.State = StateRunning
+	if err := e.writeStatus(ctx, j.tenant, j.status); err != nil {
+		level.Warn(logger).Log("msg", "error writing export status", "err", err)
+	}
+
+	err := e.export(user.InjectOrgID(ctx, j.tenant), j)
+	j.status.State = StateSucceeded
+	if err != nil {
+		j.status.State = StateFailed
+		j.status.Error = err.Error()
+		level.Error(logger).Log("msg", "export failed", "err", err)
+	} else {
+		level.Info(logger).Log("msg", "export succeeded", "lines", j.status.Lines, "objects", j.status.Objects)
+	}
+	e.metrics.exports.WithLabelValues(string(j.status.State)).Inc()
+	// The status is written even if the exporter is stopping.
+	if err := e.writeStatus(context.Background(), j.tenant, j.status); err != nil {
+		level.Warn(logger).Log("msg", "error writing export status", "err", err)
+	}
+}
+
+// export queries the logs day by day, the logs of a day are written once read.
+func (e *Exporter) export(ctx context.Context, j *job) error {
+	for from := j.req.Start; from.Before(j.req.End); {
+		through := from.Truncate(day).Add(day)
+		if through.After(j.req.End) {
+			through = j.req.End
+		}
+		if err := e.exportDay(ctx, j, from, through); err != nil {
+			return fmt.Errorf("error exporting logs from %s to %s: %w", from, through, err)
+		}
+		if err := e.writeStatus(ctx, j.tenant, j.status); err != nil {
+			return err
+		}
+		from = through
+	}
+	return nil
+}
+
+func (e *Exporter) exportDay(ctx context.Context, j *job, from, through time.Time) error {
+	it, err := e.querier.SelectLogs(ctx, logql.SelectLogParams{
+		QueryRequest: &logproto.QueryRequest{
+			Selector:  j.req.Query,
+			Start:     from,
+			End:       through,
+			Limit:     math.MaxUint32,
+			Direction: logproto.FORWARD,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	dayPrefix := path.Join(j.status.Location, "day="+from.UTC().Format("2006-01-02"))
+	// The partitions of the streams, by their labels.
+	streams := map[string]*partition{}
+	partitions := map[string]*partition{}
+	for it.Next() {
+		p, ok := streams[it.Labels()]
+		if !ok {
+			lbs, err := logql.ParseLabels(it.Labels())
+			if err != nil {
+				return err
+			}
+			key := partitionKey(dayPrefix, lbs, j.req.PartitionBy)
+			if p, ok = partitions[key]; !ok {
+				p = newPartition(key, j.req.Format)
+				partitions[key] = p
+			}
+			streams[it.Labels()] = p
+		}
+		if err := p.write(it.Labels(), it.Entry()); err != nil {
+			return err
+		}
+		j.status.Lines++
+		e.metrics.lines.Inc()
+		if p.size() >= e.cfg.MaxPartSize.Val() {
+			if err := e.flush(ctx, j, p); err != nil {
+				return err
+			}
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := e.flush(ctx, j, partitions[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) flush(ctx context.Context, j *job, p *partition) error {
+	if p.lines == 0 {
+		return nil
+	}
+	key, b, err := p.cut()
+	if err != nil {
+		return err
+	}
+	if err := e.objectClient.PutObject(ctx, key, bytes.NewReader(b)); err != nil {
+		return err
+	}
+	j.status.Objects++
+	j.status.Bytes += int64(len(b))
+	e.metrics.bytes.Add(float64(len(b)))
+	return nil
+}
+
+// partitionKey returns the prefix of the objects of a partition, e.g. day=2022-01-02/app=api.
+// Streams without a partition label are written with an empty value.
+func partitionKey(dayPrefix string, lbs labels.Labels, partitionBy []string) string {
+	elems := make([]string, 0, len(partitionBy)+1)
+	elems = append(elems, dayPrefix)
+	for _, name := range partitionBy {
+		elems = append(elems, name+"="+url.PathEscape(lbs.Get(name)))
+	}
+	return strings.Join(elems, "/")
+}
+
+// partition buffers the encoded lines of a part of a partition.
+type partition struct {
+	prefix string
+	format Format
+	part   int
+	lines  int
+	w      partWriter
+}
+
+func newPartition(prefix string, format Format) *partition {
+	return &partition{prefix: prefix, format: format, w: newPartWriter(format)}
+}
+
+func (p *partition) write(lbs string, e logproto.Entry) error {
+	p.lines++
+	return p.w.write(lbs, e)
+}
+
+// size returns the size of the encoded lines of the current part so far.
+func (p *partition) size() int {
+	return p.w.size()
+}
+
+// cut returns the object of the current part, and starts the next one.
+func (p *partition) cut() (string, []byte, error) {
+	b, err := p.w.flush()
+	if err != nil {
+		return "", nil, err
+	}
+	key := fmt.Sprintf("%s/part-%05d.%s", p.prefix, p.part, p.format.extension())
+	p.part++
+	p.lines = 0
+	return key, b, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func validID(id string) bool {
+	b, err := hex.DecodeString(id)
+	return err == nil && len(b) == 16
+}
@@ -0,0 +1,274 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+type fakeQuerier struct {
+	streams []logproto.Stream
+}
+
+func (q *fakeQuerier) SelectLogs(ctx context.Context, params logql.SelectLogParams) (iter.EntryIterator, error) {
+	var streams []logproto.Stream
+	for _, s := range q.streams {
+		var entries []logproto.Entry
+		for _, e := range s.Entries {
+			if !e.Timestamp.Before(params.Start) && e.Timestamp.Before(params.End) {
+				entries = append(entries, e)
+			}
+		}
+		if len(entries) > 0 {
+			streams = append(streams, logproto.Stream{Labels: s.Labels, Entries: entries})
+		}
+	}
+	return iter.NewStreamsIterator(ctx, streams, params.Direction), nil
+}
+
+func (q *fakeQuerier) SelectSamples(context.Context, logql.SelectSampleParams) (iter.SampleIterator, error) {
+	return nil, nil
+}
+
+func readLines(t *testing.T, storage *chunk.MockStorage, key string) []entry {
+	r, err := storage.GetObject(context.Background(), key)
+	require.NoError(t, err)
+	defer r.Close()
+	gz, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	var entries []entry
+	dec := json.NewDecoder(gz)
+	for dec.More() {
+		var e entry
+		require.NoError(t, dec.Decode(&e))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func readParquet(t *testing.T, storage *chunk.MockStorage, key string) []entry {
+	r, err := storage.GetObject(context.Background(), key)
+	require.NoError(t, err)
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	f, err := buffer.NewBufferFile(b)
+	require.NoError(t, err)
+	pr, err := reader.NewParquetReader(f, new(parquetEntry), 1)
+	require.NoError(t, err)
+	defer pr.ReadStop()
+	rows := make([]parquetEntry, pr.GetNumRows())
+	require.NoError(t, pr.Read(&rows))
+	entries := make([]entry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, entry{Timestamp: time.Unix(0, row.Timestamp).UTC(), Labels: row.Labels, Line: row.Line})
+	}
+	return entries
+}
+
+func newTestExporter(t *testing.T, cfg Config, querier logql.Querier) (*Exporter, *chunk.MockStorage) {
+	storage := chunk.NewMockStorage()
+	e := New(cfg, querier, storage, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), e))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), e))
+	})
+	return e, storage
+}
+
+func awaitStatus(t *testing.T, e *Exporter, id string) *Status {
+	var status *Status
+	require.Eventually(t, func() bool {
+		var err error
+		status, err = e.Status(context.Background(), "fake", id)
+		require.NoError(t, err)
+		return status.State == StateSucceeded || status.State == StateFailed
+	}, 5*time.Second, 10*time.Millisecond)
+	return status
+}
+
+func TestExporter(t *testing.T) {
+	day1 := time.Date(2022, 1, 2, 12, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	querier := &fakeQuerier{streams: []logproto.Stream{
+		{Labels: `{app="api", env="prod"}`, Entries: []logproto.Entry{{Timestamp: day1, Line: "api 1"}, {Timestamp: day2, Line: "api 2"}}},
+		{Labels: `{app="api", env="dev"}`, Entries: []logproto.Entry{{Timestamp: day1.Add(time.Second), Line: "api dev"}}},
+		{Labels: `{app="web/ui", env="prod"}`, Entries: []logproto.Entry{{Timestamp: day1, Line: "web 1"}}},
+	}}
+	e, storage := newTestExporter(t, Config{Prefix: "exports", MaxConcurrent: 1, MaxQueued: 1, MaxPartSize: 1 << 20}, querier)
+
+	status, err := e.Submit(context.Background(), "fake", Request{
+		Query:       `{env=~".+"}`,
+		Start:       day1.Add(-time.Hour),
+		End:         day2.Add(time.Hour),
+		PartitionBy: []string{"app"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, StateQueued, status.State)
+
+	status = awaitStatus(t, e, status.ID)
+	require.Equal(t, StateSucceeded, status.State, status.Error)
+	require.Equal(t, int64(4), status.Lines)
+	require.Equal(t, 3, status.Objects)
+
+	prefix := "exports/fake/" + status.ID
+	require.Equal(t, prefix, status.Location)
+	require.Equal(t, []entry{
+		{Timestamp: day1, Labels: `{app="api", env="prod"}`, Line: "api 1"},
+		{Timestamp: day1.Add(time.Second), Labels: `{app="api", env="dev"}`, Line: "api dev"},
+	}, readLines(t, storage, prefix+"/day=2022-01-02/app=api/part-00000.jsonl.gz"))
+	require.Equal(t, []entry{
+		{Timestamp: day1, Labels: `{app="web/ui", env="prod"}`, Line: "web 1"},
+	}, readLines(t, storage, prefix+"/day=2022-01-02/app=web%2Fui/part-00000.jsonl.gz"))
+	require.Equal(t, []entry{
+		{Timestamp: day2, Labels: `{app="api", env="prod"}`, Line: "api 2"},
+	}, readLines(t, storage, prefix+"/day=2022-01-03/app=api/part-00000.jsonl.gz"))
+}
+
+func TestExporter_Parts(t *testing.T) {
+	start := time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)
+	stream := logproto.Stream{Labels: `{app="api"}`}
+	for i := 0; i < 1000; i++ {
+		stream.Entries = append(stream.Entries, logproto.Entry{Timestamp: start.Add(time.Duration(i) * time.Second), Line: strings.Repeat("a", i)})
+	}
+	e, storage := newTestExporter(t, Config{Prefix: "exports", MaxConcurrent: 1, MaxQueued: 1, MaxPartSize: 1 << 10}, &fakeQuerier{streams: []logproto.Stream{stream}})
+
+	status, err := e.Submit(context.Background(), "fake", Request{Query: `{app="api"}`, Start: start, End: start.Add(time.Hour)})
+	require.NoError(t, err)
+	status = awaitStatus(t, e, status.ID)
+	require.Equal(t, StateSucceeded, status.State, status.Error)
+	require.Greater(t, status.Objects, 1)
+
+	objects, _, err := storage.List(context.Background(), status.Location+"/day=2022-01-02/", "")
+	require.NoError(t, err)
+	require.Len(t, objects, status.Objects)
+	var lines int
+	for _, o := range objects {
+		lines += len(readLines(t, storage, o.Key))
+	}
+	require.Equal(t, 1000, lines)
+}
+
+func TestExporter_Parquet(t *testing.T) {
+	start := time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)
+	streams := []logproto.Stream{{Labels: `{app="api", env="prod"}`}, {Labels: `{app="api", env="dev"}`}}
+	for i := 0; i < 1000; i++ {
+		s := &streams[i%2]
+		s.Entries = append(s.Entries, logproto.Entry{Timestamp: start.Add(time.Duration(i) * time.Millisecond), Line: fmt.Sprintf("line %d", i)})
+	}
+	e, storage := newTestExporter(t, Config{Prefix: "exports", MaxConcurrent: 1, MaxQueued: 1, MaxPartSize: 1 << 20}, &fakeQuerier{streams: streams})
+
+	status, err := e.Submit(context.Background(), "fake", Request{Query: `{app="api"}`, Start: start, End: start.Add(time.Hour), Format: FormatParquet})
+	require.NoError(t, err)
+	require.Equal(t, FormatParquet, status.Format)
+	status = awaitStatus(t, e, status.ID)
+	require.Equal(t, StateSucceeded, status.State, status.Error)
+	require.Equal(t, int64(1000), status.Lines)
+	require.Equal(t, 1, status.Objects)
+
+	entries := readParquet(t, storage, status.Location+"/day=2022-01-02/part-00000.parquet")
+	require.Len(t, entries, 1000)
+	// The lines are written in the order of the iterator, by timestamp.
+	for i, e := range entries {
+		require.Equal(t, entry{Timestamp: start.Add(time.Duration(i) * time.Millisecond), Labels: streams[i%2].Labels, Line: fmt.Sprintf("line %d", i)}, e)
+	}
+}
+
+func TestExporter_Submit(t *testing.T) {
+	e := New(Config{Prefix: "exports", MaxConcurrent: 1, MaxQueued: 1, MaxPartSize: 1 << 20}, &fakeQuerier{}, chunk.NewMockStorage(), log.NewNopLogger(), prometheus.NewRegistry())
+	now := time.Now()
+
+	for _, req := range []Request{
+		{Query: `sum(rate({app="api"}[1m]))`, Start: now.Add(-time.Hour), End: now},
+		{Query: `{app="api"}`, Start: now, End: now.Add(-time.Hour)},
+		{Query: `{app="api"}`, Start: now.Add(-time.Hour), End: now, PartitionBy: []string{"invalid-name"}},
+		{Query: `{app="api"}`, Start: now.Add(-time.Hour), End: now, Format: "csv"},
+	} {
+		_, err := e.Submit(context.Background(), "fake", req)
+		require.Error(t, err)
+	}
+
+	// The exporter isn't running, the queue can only hold one export.
+	_, err := e.Submit(context.Background(), "fake", Request{Query: `{app="api"}`, Start: now.Add(-time.Hour), End: now})
+	require.NoError(t, err)
+	_, err = e.Submit(context.Background(), "fake", Request{Query: `{app="api"}`, Start: now.Add(-time.Hour), End: now})
+	require.ErrorIs(t, err, ErrQueueFull)
+
+	_, err = e.Status(context.Background(), "fake", "unknown")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestExporter_Handlers(t *testing.T) {
+	day := time.Date(2022, 1, 2, 12, 0, 0, 0, time.UTC)
+	e, _ := newTestExporter(t, Config{Prefix: "exports", MaxConcurrent: 1, MaxQueued: 1, MaxPartSize: 1 << 20}, &fakeQuerier{streams: []logproto.Stream{
+		{Labels: `{app="api"}`, Entries: []logproto.Entry{{Timestamp: day, Line: "line"}}},
+	}})
+	router := mux.NewRouter()
+	router.Path("/loki/api/v1/export").Methods("POST").HandlerFunc(e.SubmitHandler)
+	router.Path("/loki/api/v1/export/{id}").Methods("GET").HandlerFunc(e.StatusHandler)
+
+	form := url.Values{
+		"query":        {`{app="api"}`},
+		"start":        {day.Add(-time.Hour).Format(time.RFC3339)},
+		"end":          {day.Add(time.Hour).Format(time.RFC3339)},
+		"partition_by": {"app"},
+	}
+	req := httptest.NewRequest("POST", "/loki/api/v1/export", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(user.InjectOrgID(req.Context(), "fake"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var status Status
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+	require.Equal(t, []string{"app"}, status.PartitionBy)
+
+	awaitStatus(t, e, status.ID)
+	req = httptest.NewRequest("GET", "/loki/api/v1/export/"+status.ID, nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "fake"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	body, err := ioutil.ReadAll(w.Body)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, &status))
+	require.Equal(t, StateSucceeded, status.State)
+	require.Equal(t, int64(1), status.Lines)
+
+	// Exports of other tenants aren't found.
+	req = httptest.NewRequest("GET", "/loki/api/v1/export/"+status.ID, nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "other"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest("POST", "/loki/api/v1/export", bytes.NewBufferString("query=%7Bapp%3D%22api%22%7D&format=csv"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(user.InjectOrgID(req.Context(), "fake"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,142 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/xitongsys/parquet-go/writer"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+// Format is the format the logs of an export are written in.
+type Format string
+
+const (
+	// FormatJSONL writes gzipped JSON lines.
+	FormatJSONL Format = "jsonl"
+	// FormatParquet writes Parquet files with a column per field of the lines, compressed with Snappy.
+	FormatParquet Format = "parquet"
+)
+
+func (f Format) validate() error {
+	switch f {
+	case FormatJSONL, FormatParquet:
+		return nil
+	default:
+		return fmt.Errorf("unsupported format %q, supported formats are %s and %s", f, FormatJSONL, FormatParquet)
+	}
+}
+
+// extension is the extension of the objects written in the format.
+func (f Format) extension() string {
+	if f == FormatParquet {
+		return "parquet"
+	}
+	return "jsonl.gz"
+}
+
+// partWriter encodes the lines of a part of a partition.
+type partWriter interface {
+	write(lbs string, e logproto.Entry) error
+	// size returns the size of the encoded lines so far.
+	size() int
+	// flush returns the object of the lines written so far, and resets the writer for the next part.
+	flush() ([]byte, error)
+}
+
+func newPartWriter(f Format) partWriter {
+	if f == FormatParquet {
+		return &parquetWriter{}
+	}
+	return newJSONLWriter()
+}
+
+// entry is an exported log line.
+type entry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Labels    string    `json:"labels"`
+	Line      string    `json:"line"`
+}
+
+type jsonlWriter struct {
+	buf bytes.Buffer
+	gz  *gzip.Writer
+	enc *json.Encoder
+}
+
+func newJSONLWriter() *jsonlWriter {
+	w := &jsonlWriter{}
+	w.gz = gzip.NewWriter(&w.buf)
+	w.enc = json.NewEncoder(w.gz)
+	return w
+}
+
+func (w *jsonlWriter) write(lbs string, e logproto.Entry) error {
+	return w.enc.Encode(entry{Timestamp: e.Timestamp.UTC(), Labels: lbs, Line: e.Line})
+}
+
+// size returns the size of the compressed lines flushed to the buffer so far.
+func (w *jsonlWriter) size() int {
+	return w.buf.Len()
+}
+
+func (w *jsonlWriter) flush() ([]byte, error) {
+	if err := w.gz.Close(); err != nil {
+		return nil, err
+	}
+	b := append([]byte(nil), w.buf.Bytes()...)
+	w.buf.Reset()
+	w.gz.Reset(&w.buf)
+	return b, nil
+}
+
+// parquetEntry is the schema of the exported Parquet files. The labels are dictionary encoded,
+// the lines of a stream sharing the same labels.
+type parquetEntry struct {
+	Timestamp int64  `parquet:"name=timestamp, type=INT64, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=NANOS"`
+	Labels    string `parquet:"name=labels, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Line      string `parquet:"name=line, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type parquetWriter struct {
+	buf bytes.Buffer
+	// pw is created on the first line of a part, the file of a part is closed once flushed.
+	pw *writer.ParquetWriter
+}
+
+func (w *parquetWriter) write(lbs string, e logproto.Entry) error {
+	if w.pw == nil {
+		pw, err := writer.NewParquetWriterFromWriter(&w.buf, new(parquetEntry), 1)
+		if err != nil {
+			return err
+		}
+		w.pw = pw
+	}
+	return w.pw.Write(parquetEntry{Timestamp: e.Timestamp.UnixNano(), Labels: lbs, Line: e.Line})
+}
+
+// size returns the size of the compressed pages buffered by the writer, the row groups are only
+// written to the buffer once the part is flushed.
+func (w *parquetWriter) size() int {
+	if w.pw == nil {
+		return w.buf.Len()
+	}
+	return w.buf.Len() + int(w.pw.Size)
+}
+
+func (w *parquetWriter) flush() ([]byte, error) {
+	if w.pw == nil {
+		return nil, nil
+	}
+	if err := w.pw.WriteStop(); err != nil {
+		return nil, err
+	}
+	b := append([]byte(nil), w.buf.Bytes()...)
+	w.buf.Reset()
+	w.pw = nil
+	return b, nil
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/gorilla/mux"
+
+	"github.com/grafana/loki/pkg/loghttp"
+)
+
+// SubmitHandler queues the export of the logs of a query. The query is a log selector,
+// the logs are partitioned by day and by the values of the labels of `partition_by`.
+func (e *Exporter) SubmitHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := r.Form.Get("query")
+	if query == "" {
+		http.Error(w, "query not set", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	start, err := loghttp.ParseTimestamp(r.Form.Get("start"), now.Add(-time.Hour))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end, err := loghttp.ParseTimestamp(r.Form.Get("end"), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var partitionBy []string
+	if p := r.Form.Get("partition_by"); p != "" {
+		partitionBy = strings.Split(p, ",")
+	}
+
+	status, err := e.Submit(r.Context(), userID, Request{
+		Query:       query,
+		Start:       start,
+		End:         end,
+		PartitionBy: partitionBy,
+		Format:      Format(r.Form.Get("format")),
+	})
+	if errors.Is(err, ErrQueueFull) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// StatusHandler returns the status of an export.
+func (e *Exporter) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status, err := e.Status(r.Context(), userID, mux.Vars(r)["id"])
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.WriteJSONResponse(w, status)
+}
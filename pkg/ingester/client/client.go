@@ -1,12 +1,15 @@
 package client
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"io"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/distributor"
 	"github.com/grafana/dskit/grpcclient"
+	"github.com/grafana/dskit/grpcencoding/snappy"
 	dsmiddleware "github.com/grafana/dskit/middleware"
 	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/opentracing/opentracing-go"
@@ -14,9 +17,19 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/middleware"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/stats"
 
 	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/util/grpcencoding/zstd"
+)
+
+const (
+	// PushPath is the path of the requests sent by the distributors to the ingesters.
+	PushPath = "push"
+	// QueryPath is the path of the requests sent by the queriers to the ingesters.
+	QueryPath = "query"
 )
 
 var ingesterClientRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
@@ -25,6 +38,17 @@ var ingesterClientRequestDuration = promauto.NewHistogramVec(prometheus.Histogra
 	Buckets: prometheus.ExponentialBuckets(0.001, 4, 6),
 }, []string{"operation", "status_code"})
 
+var (
+	ingesterClientMessageBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_ingester_client_message_bytes_total",
+		Help: "Total size of the messages exchanged with the ingesters, before compression.",
+	}, []string{"path", "direction"})
+	ingesterClientWireBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_ingester_client_wire_bytes_total",
+		Help: "Total size of the messages exchanged with the ingesters on the wire, after compression.",
+	}, []string{"path", "direction"})
+)
+
 type HealthAndIngesterClient interface {
 	logproto.IngesterClient
 	grpc_health_v1.HealthClient
@@ -46,21 +70,85 @@ type Config struct {
 	GRPCClientConfig             grpcclient.Config              `yaml:"grpc_client_config"`
 	GRPCUnaryClientInterceptors  []grpc.UnaryClientInterceptor  `yaml:"-"`
 	GRCPStreamClientInterceptors []grpc.StreamClientInterceptor `yaml:"-"`
+
+	Push  PathConfig `yaml:"push"`
+	Query PathConfig `yaml:"query"`
+
+	// Path is the path the client is used for, set by ForPath.
+	Path string `yaml:"-"`
+}
+
+// PathConfig overrides the gRPC client config for the requests of a single path.
+type PathConfig struct {
+	GRPCCompression string `yaml:"grpc_compression"`
+	MaxRecvMsgSize  int    `yaml:"max_recv_msg_size"`
+	MaxSendMsgSize  int    `yaml:"max_send_msg_size"`
+}
+
+// RegisterFlagsWithPrefix registers flags with the given prefix, description describes the path.
+func (cfg *PathConfig) RegisterFlagsWithPrefix(prefix, description string, f *flag.FlagSet) {
+	f.StringVar(&cfg.GRPCCompression, prefix+".grpc-compression", "", fmt.Sprintf("Compression of the %s. Supported values are: 'gzip', 'snappy', 'zstd'. Empty uses -ingester.client.grpc-compression.", description))
+	f.IntVar(&cfg.MaxRecvMsgSize, prefix+".grpc-max-recv-msg-size", 0, fmt.Sprintf("Max receive message size (bytes) of the %s. 0 uses -ingester.client.grpc-max-recv-msg-size.", description))
+	f.IntVar(&cfg.MaxSendMsgSize, prefix+".grpc-max-send-msg-size", 0, fmt.Sprintf("Max send message size (bytes) of the %s. 0 uses -ingester.client.grpc-max-send-msg-size.", description))
 }
 
 // RegisterFlags registers flags.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("ingester.client", f)
 	cfg.PoolConfig.RegisterFlags(f)
+	cfg.Push.RegisterFlagsWithPrefix("ingester.client.push", "requests sent by the distributors to the ingesters", f)
+	cfg.Query.RegisterFlagsWithPrefix("ingester.client.query", "requests sent by the queriers to the ingesters", f)
 
 	f.DurationVar(&cfg.PoolConfig.RemoteTimeout, "ingester.client.healthcheck-timeout", 1*time.Second, "Timeout for healthcheck rpcs.")
 	f.DurationVar(&cfg.RemoteTimeout, "ingester.client.timeout", 5*time.Second, "Timeout for ingester client RPCs.")
 }
 
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	for _, compression := range []string{cfg.GRPCClientConfig.GRPCCompression, cfg.Push.GRPCCompression, cfg.Query.GRPCCompression} {
+		switch compression {
+		case gzip.Name, snappy.Name, zstd.Name, "":
+		default:
+			return fmt.Errorf("unsupported compression type: %s", compression)
+		}
+	}
+	return nil
+}
+
+// ForPath returns the config of the clients used for the given path, with the overrides of the path applied.
+func (cfg Config) ForPath(path string) Config {
+	var override PathConfig
+	switch path {
+	case PushPath:
+		override = cfg.Push
+	case QueryPath:
+		override = cfg.Query
+	}
+	if override.GRPCCompression != "" {
+		cfg.GRPCClientConfig.GRPCCompression = override.GRPCCompression
+	}
+	if override.MaxRecvMsgSize > 0 {
+		cfg.GRPCClientConfig.MaxRecvMsgSize = override.MaxRecvMsgSize
+	}
+	if override.MaxSendMsgSize > 0 {
+		cfg.GRPCClientConfig.MaxSendMsgSize = override.MaxSendMsgSize
+	}
+	cfg.Path = path
+	return cfg
+}
+
 // New returns a new ingester client.
 func New(cfg Config, addr string) (HealthAndIngesterClient, error) {
+	path := cfg.Path
+	if path == "" {
+		path = "default"
+	}
+	if err := zstd.SetMaxDecompressedSize(cfg.GRPCClientConfig.MaxRecvMsgSize); err != nil {
+		return nil, err
+	}
 	opts := []grpc.DialOption{
 		grpc.WithDefaultCallOptions(cfg.GRPCClientConfig.CallOptions()...),
+		grpc.WithStatsHandler(&compressionStatsHandler{path: path}),
 	}
 
 	dialOpts, err := cfg.GRPCClientConfig.DialOption(instrumentation(&cfg))
@@ -100,3 +188,29 @@ func instrumentation(cfg *Config) ([]grpc.UnaryClientInterceptor, []grpc.StreamC
 
 	return unaryInterceptors, streamInterceptors
 }
+
+// compressionStatsHandler tracks the size of the messages before and after compression.
+type compressionStatsHandler struct {
+	path string
+}
+
+func (h *compressionStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *compressionStatsHandler) HandleRPC(_ context.Context, s stats.RPCStats) {
+	switch p := s.(type) {
+	case *stats.OutPayload:
+		ingesterClientMessageBytes.WithLabelValues(h.path, "sent").Add(float64(p.Length))
+		ingesterClientWireBytes.WithLabelValues(h.path, "sent").Add(float64(p.WireLength))
+	case *stats.InPayload:
+		ingesterClientMessageBytes.WithLabelValues(h.path, "received").Add(float64(p.Length))
+		ingesterClientWireBytes.WithLabelValues(h.path, "received").Add(float64(p.WireLength))
+	}
+}
+
+func (h *compressionStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *compressionStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/grafana/dskit/grpcclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ForPath(t *testing.T) {
+	cfg := Config{
+		GRPCClientConfig: grpcclient.Config{
+			GRPCCompression: "snappy",
+			MaxRecvMsgSize:  100,
+			MaxSendMsgSize:  10,
+		},
+		Push: PathConfig{
+			GRPCCompression: "zstd",
+			MaxSendMsgSize:  20,
+		},
+	}
+
+	push := cfg.ForPath(PushPath)
+	require.Equal(t, PushPath, push.Path)
+	require.Equal(t, "zstd", push.GRPCClientConfig.GRPCCompression)
+	require.Equal(t, 100, push.GRPCClientConfig.MaxRecvMsgSize)
+	require.Equal(t, 20, push.GRPCClientConfig.MaxSendMsgSize)
+
+	query := cfg.ForPath(QueryPath)
+	require.Equal(t, QueryPath, query.Path)
+	require.Equal(t, cfg.GRPCClientConfig, query.GRPCClientConfig)
+
+	// the original config is left untouched.
+	require.Equal(t, "snappy", cfg.GRPCClientConfig.GRPCCompression)
+	require.Empty(t, cfg.Path)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{"default", Config{}, ""},
+		{"zstd", Config{GRPCClientConfig: grpcclient.Config{GRPCCompression: "zstd"}, Query: PathConfig{GRPCCompression: "gzip"}}, ""},
+		{"invalid", Config{GRPCClientConfig: grpcclient.Config{GRPCCompression: "lz4"}}, "unsupported compression type: lz4"},
+		{"invalid override", Config{Push: PathConfig{GRPCCompression: "lz4"}}, "unsupported compression type: lz4"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
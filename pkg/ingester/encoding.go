@@ -24,10 +24,14 @@ const (
 	// WALRecordEntriesV2 is the type for the WAL record for samples with an
 	// additional counter value for use in replaying without the ordering constraint.
 	WALRecordEntriesV2
+	// WALRecordEntriesV3 is the type for the WAL record for samples with the
+	// structured metadata of the entries.
+	WALRecordEntriesV3
 )
 
 // The current type of Entries that this distribution writes.
 // Loki can read in a backwards compatible manner, but will write the newest variant.
+// Records with structured metadata are written as WALRecordEntriesV3, see entriesRecordType.
 const CurrentEntriesRec RecordType = WALRecordEntriesV2
 
 // WALRecord is a struct combining the series and samples record.
@@ -74,6 +78,20 @@ func (r *WALRecord) AddEntries(fp uint64, counter int64, entries ...logproto.Ent
 	})
 }
 
+// entriesRecordType returns the type the entries of the record are written as, the entries
+// are only written as WALRecordEntriesV3 when some have structured metadata so that the
+// WAL stays readable by previous versions otherwise.
+func (r *WALRecord) entriesRecordType() RecordType {
+	for _, ref := range r.RefEntries {
+		for _, e := range ref.Entries {
+			if len(e.StructuredMetadata) > 0 {
+				return WALRecordEntriesV3
+			}
+		}
+	}
+	return CurrentEntriesRec
+}
+
 type RefEntries struct {
 	Counter int64
 	Ref     uint64
@@ -130,6 +148,13 @@ outer:
 			buf.PutVarint64(s.Timestamp.UnixNano() - first)
 			buf.PutUvarint(len(s.Line))
 			buf.PutString(s.Line)
+			if version >= WALRecordEntriesV3 {
+				buf.PutUvarint(len(s.StructuredMetadata))
+				for _, m := range s.StructuredMetadata {
+					buf.PutUvarintStr(m.Name)
+					buf.PutUvarintStr(m.Value)
+				}
+			}
 		}
 	}
 	return buf.Get()
@@ -160,9 +185,21 @@ func decodeEntries(b []byte, version RecordType, rec *WALRecord) error {
 			lineLength := dec.Uvarint()
 			line := dec.Bytes(lineLength)
 
+			var metadata []logproto.LabelPair
+			if version >= WALRecordEntriesV3 {
+				nMetadata := dec.Uvarint()
+				for i := 0; dec.Err() == nil && i < nMetadata; i++ {
+					metadata = append(metadata, logproto.LabelPair{
+						Name:  dec.UvarintStr(),
+						Value: dec.UvarintStr(),
+					})
+				}
+			}
+
 			refEntries.Entries = append(refEntries.Entries, logproto.Entry{
-				Timestamp: time.Unix(0, baseTime+timeOffset),
-				Line:      string(line),
+				Timestamp:          time.Unix(0, baseTime+timeOffset),
+				Line:               string(line),
+				StructuredMetadata: metadata,
 			})
 		}
 
@@ -197,7 +234,7 @@ func decodeWALRecord(b []byte, walRec *WALRecord) (err error) {
 	case WALRecordSeries:
 		userID = decbuf.UvarintStr()
 		rSeries, err = dec.Series(decbuf.B, walRec.Series)
-	case WALRecordEntriesV1, WALRecordEntriesV2:
+	case WALRecordEntriesV1, WALRecordEntriesV2, WALRecordEntriesV3:
 		userID = decbuf.UvarintStr()
 		err = decodeEntries(decbuf.B, t, walRec)
 	default:
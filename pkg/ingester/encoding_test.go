@@ -130,6 +130,32 @@ func Test_Encoding_Entries(t *testing.T) {
 			},
 			version: WALRecordEntriesV2,
 		},
+		{
+			desc: "v3",
+			rec: &WALRecord{
+				entryIndexMap: make(map[uint64]int),
+				UserID:        "123",
+				RefEntries: []RefEntries{
+					{
+						Ref:     456,
+						Counter: 1,
+						Entries: []logproto.Entry{
+							{
+								Timestamp: time.Unix(1000, 0),
+								Line:      "first",
+								// v3 has the structured metadata of the entries
+								StructuredMetadata: []logproto.LabelPair{{Name: "trace_id", Value: "abc"}, {Name: "user", Value: ""}},
+							},
+							{
+								Timestamp: time.Unix(2000, 0),
+								Line:      "second",
+							},
+						},
+					},
+				},
+			},
+			version: WALRecordEntriesV3,
+		},
 	} {
 		decoded := recordPool.GetRecord()
 		buf := tc.rec.encodeEntries(tc.version, nil)
@@ -140,6 +166,15 @@ func Test_Encoding_Entries(t *testing.T) {
 	}
 }
 
+func Test_EntriesRecordType(t *testing.T) {
+	rec := &WALRecord{entryIndexMap: make(map[uint64]int)}
+	rec.AddEntries(1, 1, logproto.Entry{Timestamp: time.Unix(1, 0), Line: "first"})
+	require.Equal(t, CurrentEntriesRec, rec.entriesRecordType())
+
+	rec.AddEntries(2, 1, logproto.Entry{Timestamp: time.Unix(2, 0), Line: "second", StructuredMetadata: []logproto.LabelPair{{Name: "trace_id", Value: "abc"}}})
+	require.Equal(t, WALRecordEntriesV3, rec.entriesRecordType())
+}
+
 func Benchmark_EncodeEntries(b *testing.B) {
 	var entries []logproto.Entry
 	for i := int64(0); i < 10000; i++ {
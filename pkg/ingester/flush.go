@@ -103,11 +103,12 @@ const (
 	nameLabel = "__name__"
 	logsValue = "logs"
 
-	flushReasonIdle   = "idle"
-	flushReasonMaxAge = "max_age"
-	flushReasonForced = "forced"
-	flushReasonFull   = "full"
-	flushReasonSynced = "synced"
+	flushReasonIdle       = "idle"
+	flushReasonIdleStream = "idle_stream"
+	flushReasonMaxAge     = "max_age"
+	flushReasonForced     = "forced"
+	flushReasonFull       = "full"
+	flushReasonSynced     = "synced"
 )
 
 // Note: this is called both during the WAL replay (zero or more times)
@@ -177,6 +178,7 @@ func (i *Ingester) sweepInstance(instance *instance, immediate, mayRemoveStreams
 		i.sweepStream(instance, stream, immediate)
 		i.removeFlushedChunks(instance, stream, mayRemoveStreams)
 	}
+	instance.forgetEvictedStreams(time.Now().Add(-evictedStreamsRetention))
 }
 
 // must hold streamsMtx
@@ -297,6 +299,10 @@ func (i *Ingester) shouldFlushChunk(chunk *chunkDesc) (bool, string) {
 		return true, flushReasonIdle
 	}
 
+	if i.isIdleStreamChunk(chunk) {
+		return true, flushReasonIdleStream
+	}
+
 	if from, to := chunk.chunk.Bounds(); to.Sub(from) > i.cfg.MaxChunkAge {
 		return true, flushReasonMaxAge
 	}
@@ -311,9 +317,13 @@ func (i *Ingester) removeFlushedChunks(instance *instance, stream *stream, mayRe
 	stream.chunkMtx.Lock()
 	defer stream.chunkMtx.Unlock()
 	prevNumChunks := len(stream.chunks)
+
+	// Idle streams don't wait for the retain period, they are removed as soon as their chunks are flushed.
+	evict := mayRemoveStream && prevNumChunks > 0 && i.isIdleStreamChunk(&stream.chunks[prevNumChunks-1])
+
 	var subtracted int
 	for len(stream.chunks) > 0 {
-		if stream.chunks[0].flushed.IsZero() || now.Sub(stream.chunks[0].flushed) < i.cfg.RetainPeriod {
+		if stream.chunks[0].flushed.IsZero() || (!evict && now.Sub(stream.chunks[0].flushed) < i.cfg.RetainPeriod) {
 			break
 		}
 
@@ -327,10 +337,20 @@ func (i *Ingester) removeFlushedChunks(instance *instance, stream *stream, mayRe
 	i.replayController.Sub(int64(subtracted))
 
 	if mayRemoveStream && len(stream.chunks) == 0 {
+		if evict {
+			instance.evictStream(stream)
+			return
+		}
 		instance.removeStream(stream)
 	}
 }
 
+// isIdleStreamChunk returns true if the chunk is the last one of a stream which
+// has not received any entries for longer than the stream idle timeout.
+func (i *Ingester) isIdleStreamChunk(chunk *chunkDesc) bool {
+	return i.cfg.StreamIdleTimeout > 0 && time.Since(chunk.lastUpdated) > i.cfg.StreamIdleTimeout
+}
+
 func (i *Ingester) flushChunks(ctx context.Context, fp model.Fingerprint, labelPairs labels.Labels, cs []*chunkDesc, chunkMtx sync.Locker) error {
 	userID, err := tenant.TenantID(ctx)
 	if err != nil {
@@ -376,6 +396,18 @@ func (i *Ingester) flushChunks(ctx context.Context, fp model.Fingerprint, labelP
 		return err
 	}
 
+	// the chunks of the tenants with a compliance retention are locked by the object store until
+	// the end of the compliance retention of the last of them.
+	if period := i.limiter.limits.ComplianceRetentionPeriod(userID); period > 0 {
+		var through model.Time
+		for _, wc := range wireChunks {
+			if wc.Through > through {
+				through = wc.Through
+			}
+		}
+		ctx = chunk.WithRetainUntil(ctx, through.Time().Add(period))
+	}
+
 	if err := i.store.Put(ctx, wireChunks); err != nil {
 		return err
 	}
@@ -16,6 +16,7 @@ import (
 	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/stretchr/testify/require"
@@ -241,6 +242,45 @@ func TestFlushMaxAge(t *testing.T) {
 	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), ing))
 }
 
+func TestFlushIdleStreams(t *testing.T) {
+	cfg := defaultIngesterTestConfig(t)
+	cfg.FlushCheckPeriod = time.Millisecond * 100
+	cfg.StreamIdleTimeout = time.Millisecond * 200
+	cfg.RetainPeriod = time.Hour
+
+	store, ing := newTestStore(t, cfg, nil)
+	defer store.Stop()
+
+	const userID = "testUser"
+	ctx := user.InjectOrgID(context.Background(), userID)
+	streams := []logproto.Stream{
+		{Labels: model.LabelSet{"app": "l"}.String(), Entries: entries(5, time.Unix(0, 0))},
+	}
+
+	_, err := ing.Push(ctx, &logproto.PushRequest{Streams: streams})
+	require.NoError(t, err)
+	inst, ok := ing.getInstanceByID(userID)
+	require.True(t, ok)
+
+	// The stream is flushed and removed without waiting for the retain period.
+	require.Eventually(t, func() bool {
+		inst.streamsMtx.RLock()
+		defer inst.streamsMtx.RUnlock()
+		return len(inst.streams) == 0
+	}, 5*time.Second, cfg.FlushCheckPeriod)
+	store.checkData(t, map[string][]logproto.Stream{userID: streams})
+	require.Equal(t, float64(1), testutil.ToFloat64(inst.streamsEvictedTotal))
+
+	// The stream is recognized whatever the form of its labels.
+	_, err = ing.Push(ctx, &logproto.PushRequest{Streams: []logproto.Stream{
+		{Labels: `{ app = "l" }`, Entries: entries(5, time.Unix(10, 0))},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, float64(1), testutil.ToFloat64(inst.streamsRecreatedTotal))
+
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), ing))
+}
+
 type testStore struct {
 	mtx sync.Mutex
 	// Chunks keyed by userID.
@@ -21,6 +21,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/weaveworks/common/httpgrpc"
 	"google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/grafana/loki/pkg/chunkenc"
@@ -29,6 +30,7 @@ import (
 	"github.com/grafana/loki/pkg/iter"
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/logqlmodel/memory"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/pkg/runtime"
 	"github.com/grafana/loki/pkg/storage"
@@ -65,6 +67,7 @@ type Config struct {
 	ChunkEncoding       string            `yaml:"chunk_encoding"`
 	parsedEncoding      chunkenc.Encoding `yaml:"-"` // placeholder for validated encoding
 	MaxChunkAge         time.Duration     `yaml:"max_chunk_age"`
+	StreamIdleTimeout   time.Duration     `yaml:"stream_idle_timeout"`
 	AutoForgetUnhealthy bool              `yaml:"autoforget_unhealthy"`
 
 	// Synchronization settings. Used to make sure that ingesters cut their chunks at the same moments.
@@ -104,6 +107,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.Float64Var(&cfg.SyncMinUtilization, "ingester.sync-min-utilization", 0, "Minimum utilization of chunk when doing synchronization.")
 	f.IntVar(&cfg.MaxReturnedErrors, "ingester.max-ignored-stream-errors", 10, "Maximum number of ignored stream errors to return. 0 to return all errors.")
 	f.DurationVar(&cfg.MaxChunkAge, "ingester.max-chunk-age", time.Hour, "Maximum chunk age before flushing.")
+	f.DurationVar(&cfg.StreamIdleTimeout, "ingester.stream-idle-timeout", 0, "How long a stream can receive no entries before it is flushed and evicted from memory, regardless of the chunk idle and retain periods. 0 to disable.")
 	f.DurationVar(&cfg.QueryStoreMaxLookBackPeriod, "ingester.query-store-max-look-back-period", 0, "How far back should an ingester be allowed to query the store for data, for use only with boltdb-shipper index and filesystem object store. -1 for infinite.")
 	f.BoolVar(&cfg.AutoForgetUnhealthy, "ingester.autoforget-unhealthy", false, "Enable to remove unhealthy ingesters from the ring after `ring.kvstore.heartbeat_timeout`")
 	f.IntVar(&cfg.IndexShards, "ingester.index-shards", index.DefaultIndexShards, "Shard factor used in the ingesters for the in process reverse index. This MUST be evenly divisible by ALL schema shard factors or Loki will not start.")
@@ -173,6 +177,8 @@ type Ingester struct {
 	wal WAL
 
 	chunkFilter storage.RequestChunkFilterer
+
+	lookupTables logql.LookupTables
 }
 
 // ChunkStore is the interface we need to store chunks.
@@ -252,6 +258,11 @@ func (i *Ingester) SetChunkFilterer(chunkFilter storage.RequestChunkFilterer) {
 	i.chunkFilter = chunkFilter
 }
 
+// SetLookupTables sets the lookup tables used by the lookup stages of the queries.
+func (i *Ingester) SetLookupTables(tables logql.LookupTables) {
+	i.lookupTables = tables
+}
+
 // setupAutoForget looks for ring status if `AutoForgetUnhealthy` is enabled
 // when enabled, unhealthy ingesters that reach `ring.kvstore.heartbeat_timeout` are removed from the ring every `HeartbeatPeriod`
 func (i *Ingester) setupAutoForget() {
@@ -497,6 +508,46 @@ func (i *Ingester) ShutdownHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// LastEntriesHandler returns the timestamp of the newest entry received by each stream of the tenant
+// matching the selector, from the streams held in memory. With the older_than duration, only the
+// streams silent for longer are returned.
+func (i *Ingester) LastEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	instanceID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	matchers, err := logql.ParseMatchers(r.FormValue("selector"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var before time.Time
+	if olderThan := r.FormValue("older_than"); olderThan != "" {
+		d, err := model.ParseDuration(olderThan)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid older_than: %s", err), http.StatusBadRequest)
+			return
+		}
+		before = time.Now().Add(-time.Duration(d))
+	}
+
+	streams := []StreamLastEntry{}
+	if instance, ok := i.getInstanceByID(instanceID); ok {
+		found, err := instance.LastEntries(r.Context(), matchers, before)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if found != nil {
+			streams = found
+		}
+	}
+	util.WriteJSONResponse(w, struct {
+		Streams []StreamLastEntry `json:"streams"`
+	}{Streams: streams})
+}
+
 // Push implements logproto.Pusher.
 func (i *Ingester) Push(ctx context.Context, req *logproto.PushRequest) (*logproto.PushResponse, error) {
 	instanceID, err := tenant.TenantID(ctx)
@@ -531,12 +582,16 @@ func (i *Ingester) getOrCreateInstance(instanceID string) *instance {
 func (i *Ingester) Query(req *logproto.QueryRequest, queryServer logproto.Querier_QueryServer) error {
 	// initialize stats collection for ingester queries.
 	_, ctx := stats.NewContext(queryServer.Context())
+	ctx = logql.InjectLookupTables(ctx, i.lookupTables)
 
 	instanceID, err := tenant.TenantID(ctx)
 	if err != nil {
 		return err
 	}
 
+	memTracker, ctx := memory.NewContext(ctx, int64(i.limiter.limits.MaxQueryMemory(instanceID)))
+	defer memTracker.Close()
+
 	instance := i.getOrCreateInstance(instanceID)
 	itrs, err := instance.Query(ctx, logql.SelectLogParams{QueryRequest: req})
 	if err != nil {
@@ -564,19 +619,24 @@ func (i *Ingester) Query(req *logproto.QueryRequest, queryServer logproto.Querie
 
 	defer listutil.LogErrorWithContext(ctx, "closing iterator", heapItr.Close)
 
-	return sendBatches(ctx, heapItr, queryServer, req.Limit)
+	err = sendBatches(ctx, heapItr, queryServer, req.Limit)
+	return queryMemoryError(memTracker, err)
 }
 
 // QuerySample the ingesters for series from logs matching a set of matchers.
 func (i *Ingester) QuerySample(req *logproto.SampleQueryRequest, queryServer logproto.Querier_QuerySampleServer) error {
 	// initialize stats collection for ingester queries.
 	_, ctx := stats.NewContext(queryServer.Context())
+	ctx = logql.InjectLookupTables(ctx, i.lookupTables)
 
 	instanceID, err := tenant.TenantID(ctx)
 	if err != nil {
 		return err
 	}
 
+	memTracker, ctx := memory.NewContext(ctx, int64(i.limiter.limits.MaxQueryMemory(instanceID)))
+	defer memTracker.Close()
+
 	instance := i.getOrCreateInstance(instanceID)
 	itrs, err := instance.QuerySample(ctx, logql.SelectSampleParams{SampleQueryRequest: req})
 	if err != nil {
@@ -602,7 +662,17 @@ func (i *Ingester) QuerySample(req *logproto.SampleQueryRequest, queryServer log
 
 	defer listutil.LogErrorWithContext(ctx, "closing iterator", heapItr.Close)
 
-	return sendSampleBatches(ctx, heapItr, queryServer)
+	err = sendSampleBatches(ctx, heapItr, queryServer)
+	return queryMemoryError(memTracker, err)
+}
+
+// queryMemoryError returns the error of a query cancelled because it exceeded its memory budget,
+// as a 4xx so that it's not retried, err otherwise.
+func queryMemoryError(tracker *memory.Tracker, err error) error {
+	if memErr := tracker.Err(); memErr != nil {
+		return httpgrpc.Errorf(http.StatusBadRequest, memErr.Error())
+	}
+	return err
 }
 
 // boltdbShipperMaxLookBack returns a max look back period only if active index type is boltdb-shipper.
@@ -786,7 +856,8 @@ func (i *Ingester) Tail(req *logproto.TailRequest, queryServer logproto.Querier_
 	}
 
 	instance := i.getOrCreateInstance(instanceID)
-	tailer, err := newTailer(instanceID, req.Query, queryServer)
+	ctx := logql.InjectLookupTables(queryServer.Context(), i.lookupTables)
+	tailer, err := newTailer(ctx, instanceID, req.Query, queryServer)
 	if err != nil {
 		return err
 	}
@@ -1,8 +1,11 @@
 package ingester
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"sync"
 	"testing"
 	"time"
@@ -252,6 +255,72 @@ func TestIngesterStreamLimitExceeded(t *testing.T) {
 	}
 }
 
+func TestIngesterQueryMemoryLimitExceeded(t *testing.T) {
+	ingesterConfig := defaultIngesterTestConfig(t)
+	defaultLimits := defaultLimitsTestConfig()
+	defaultLimits.MaxQueryMemory = 20
+	overrides, err := validation.NewOverrides(defaultLimits, nil)
+	require.NoError(t, err)
+
+	store := &mockStore{
+		chunks: map[string][]chunk.Chunk{},
+	}
+
+	i, err := New(ingesterConfig, client.Config{}, store, overrides, runtime.DefaultTenantConfigs(), nil)
+	require.NoError(t, err)
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	req := logproto.PushRequest{
+		Streams: []logproto.Stream{
+			{
+				Labels: `{foo="bar",bar="baz1"}`,
+			},
+			{
+				Labels: `{foo="bar",bar="baz2"}`,
+			},
+		},
+	}
+	for i := 0; i < 10; i++ {
+		for j := range req.Streams {
+			req.Streams[j].Entries = append(req.Streams[j].Entries, logproto.Entry{
+				Timestamp: time.Unix(0, 0),
+				Line:      fmt.Sprintf("line %d", i),
+			})
+		}
+	}
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	_, err = i.Push(ctx, &req)
+	require.NoError(t, err)
+
+	// a single stream fits in the memory budget.
+	result := mockQuerierServer{
+		ctx: ctx,
+	}
+	err = i.Query(&logproto.QueryRequest{
+		Selector: `{foo="bar",bar="baz1"}`,
+		Limit:    100,
+		Start:    time.Unix(0, 0),
+		End:      time.Unix(1, 0),
+	}, &result)
+	require.NoError(t, err)
+	require.Len(t, result.resps, 1)
+
+	result = mockQuerierServer{
+		ctx: ctx,
+	}
+	err = i.Query(&logproto.QueryRequest{
+		Selector: `{foo="bar"}`,
+		Limit:    100,
+		Start:    time.Unix(0, 0),
+		End:      time.Unix(1, 0),
+	}, &result)
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusBadRequest), resp.Code)
+	require.Contains(t, string(resp.Body), "exceeded its memory budget")
+}
+
 type mockStore struct {
 	mtx    sync.Mutex
 	chunks map[string][]chunk.Chunk
@@ -559,3 +628,64 @@ func Test_InMemoryLabels(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, []string{"bar", "foo"}, res.Values)
 }
+
+func Test_LastEntriesHandler(t *testing.T) {
+	ingesterConfig := defaultIngesterTestConfig(t)
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+
+	store := &mockStore{
+		chunks: map[string][]chunk.Chunk{},
+	}
+
+	i, err := New(ingesterConfig, client.Config{}, store, limits, runtime.DefaultTenantConfigs(), nil)
+	require.NoError(t, err)
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	now := time.Now()
+	ctx := user.InjectOrgID(context.Background(), "test")
+	_, err = i.Push(ctx, &logproto.PushRequest{
+		Streams: []logproto.Stream{
+			{Labels: `{app="api"}`, Entries: entries(5, now.Add(-30*time.Second))},
+			{Labels: `{app="db"}`, Entries: entries(5, now.Add(-10*time.Minute))},
+			{Labels: `{app="web"}`, Entries: entries(5, now.Add(-time.Hour))},
+		},
+	})
+	require.NoError(t, err)
+
+	get := func(orgID, query string) (int, []StreamLastEntry) {
+		req := httptest.NewRequest(http.MethodGet, "/ingester/last_entries?"+query, nil)
+		req = req.WithContext(user.InjectOrgID(req.Context(), orgID))
+		rec := httptest.NewRecorder()
+		i.LastEntriesHandler(rec, req)
+		var resp struct {
+			Streams []StreamLastEntry `json:"streams"`
+		}
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		}
+		return rec.Code, resp.Streams
+	}
+
+	code, streams := get("test", url.Values{"selector": {`{app=~"api|db"}`}}.Encode())
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, streams, 2)
+	require.Equal(t, map[string]string{"app": "db"}, streams[0].Labels)
+	require.True(t, now.Add(-10*time.Minute+4*time.Nanosecond).Equal(streams[0].LastEntry))
+	require.Equal(t, map[string]string{"app": "api"}, streams[1].Labels)
+
+	code, streams = get("test", url.Values{"selector": {`{app=~".+"}`}, "older_than": {"5m"}}.Encode())
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, streams, 2)
+	require.Equal(t, map[string]string{"app": "web"}, streams[0].Labels)
+	require.Equal(t, map[string]string{"app": "db"}, streams[1].Labels)
+
+	code, streams = get("other", url.Values{"selector": {`{app="api"}`}}.Encode())
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, streams)
+
+	code, _ = get("test", url.Values{"selector": {`{app=`}}.Encode())
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("test", url.Values{"selector": {`{app="api"}`}, "older_than": {"soon"}}.Encode())
+	require.Equal(t, http.StatusBadRequest, code)
+}
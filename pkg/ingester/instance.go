@@ -4,8 +4,10 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"sort"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/cortexproject/cortex/pkg/cortexpb"
 	"github.com/cortexproject/cortex/pkg/querier/astmapper"
@@ -36,6 +38,9 @@ import (
 const (
 	queryBatchSize       = 128
 	queryBatchSampleSize = 512
+
+	// evictedStreamsRetention is how long evicted streams are remembered to count their re-creations.
+	evictedStreamsRetention = time.Hour
 )
 
 // Errors returned on Query.
@@ -59,6 +64,16 @@ var (
 		Name:      "ingester_streams_removed_total",
 		Help:      "The total number of streams removed per tenant.",
 	}, []string{"tenant"})
+	streamsEvictedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Name:      "ingester_streams_evicted_total",
+		Help:      "The total number of idle streams evicted per tenant.",
+	}, []string{"tenant"})
+	streamsRecreatedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Name:      "ingester_streams_recreated_total",
+		Help:      "The total number of streams created again within an hour of their eviction per tenant.",
+	}, []string{"tenant"})
 )
 
 type instance struct {
@@ -74,8 +89,13 @@ type instance struct {
 
 	instanceID string
 
-	streamsCreatedTotal prometheus.Counter
-	streamsRemovedTotal prometheus.Counter
+	streamsCreatedTotal   prometheus.Counter
+	streamsRemovedTotal   prometheus.Counter
+	streamsEvictedTotal   prometheus.Counter
+	streamsRecreatedTotal prometheus.Counter
+
+	// evictedStreams holds the eviction time of the recently evicted streams by labels. Must hold streamsMtx.
+	evictedStreams map[string]time.Time
 
 	tailers   map[uint32]*tailer
 	tailerMtx sync.RWMutex
@@ -103,8 +123,11 @@ func newInstance(cfg *Config, instanceID string, limiter *Limiter, configs *runt
 		index:       index.NewWithShards(uint32(cfg.IndexShards)),
 		instanceID:  instanceID,
 
-		streamsCreatedTotal: streamsCreatedTotal.WithLabelValues(instanceID),
-		streamsRemovedTotal: streamsRemovedTotal.WithLabelValues(instanceID),
+		streamsCreatedTotal:   streamsCreatedTotal.WithLabelValues(instanceID),
+		streamsRemovedTotal:   streamsRemovedTotal.WithLabelValues(instanceID),
+		streamsEvictedTotal:   streamsEvictedTotal.WithLabelValues(instanceID),
+		streamsRecreatedTotal: streamsRecreatedTotal.WithLabelValues(instanceID),
+		evictedStreams:        map[string]time.Time{},
 
 		tailers: map[uint32]*tailer{},
 		limiter: limiter,
@@ -132,7 +155,7 @@ func (i *instance) consumeChunk(ctx context.Context, ls labels.Labels, chunk *lo
 	if !ok {
 
 		sortedLabels := i.index.Add(cortexpb.FromLabelsToLabelAdapters(ls), fp)
-		stream = newStream(i.cfg, i.limiter, i.instanceID, fp, sortedLabels, i.limiter.UnorderedWrites(i.instanceID), i.metrics)
+		stream = newStream(i.cfg, i.limiter, i.instanceID, fp, sortedLabels, i.limiter.UnorderedWrites(i.instanceID), i.limiter.AllowStructuredMetadata(i.instanceID), i.metrics)
 		i.streamsByFP[fp] = stream
 		i.streams[stream.labelsString] = stream
 		i.streamsCreatedTotal.Inc()
@@ -243,7 +266,7 @@ func (i *instance) getOrCreateStream(pushReqStream logproto.Stream, lock bool, r
 	fp := i.getHashForLabels(labels)
 
 	sortedLabels := i.index.Add(cortexpb.FromLabelsToLabelAdapters(labels), fp)
-	stream = newStream(i.cfg, i.limiter, i.instanceID, fp, sortedLabels, i.limiter.UnorderedWrites(i.instanceID), i.metrics)
+	stream = newStream(i.cfg, i.limiter, i.instanceID, fp, sortedLabels, i.limiter.UnorderedWrites(i.instanceID), i.limiter.AllowStructuredMetadata(i.instanceID), i.metrics)
 	i.streams[pushReqStream.Labels] = stream
 	i.streamsByFP[fp] = stream
 
@@ -253,6 +276,11 @@ func (i *instance) getOrCreateStream(pushReqStream logproto.Stream, lock bool, r
 			Ref:    uint64(fp),
 			Labels: sortedLabels,
 		})
+		// Evicted streams are keyed by their sorted labels, whatever the form of the pushed labels.
+		if _, ok := i.evictedStreams[stream.labelsString]; ok {
+			delete(i.evictedStreams, stream.labelsString)
+			i.streamsRecreatedTotal.Inc()
+		}
 	} else {
 		// If the record is nil, this is a WAL recovery.
 		i.metrics.recoveredStreamsTotal.Inc()
@@ -282,6 +310,22 @@ func (i *instance) removeStream(s *stream) {
 	memoryStreams.WithLabelValues(i.instanceID).Dec()
 }
 
+// evictStream removes an idle stream and remembers it to count its re-creation. Must hold streamsMtx.
+func (i *instance) evictStream(s *stream) {
+	i.removeStream(s)
+	i.evictedStreams[s.labelsString] = time.Now()
+	i.streamsEvictedTotal.Inc()
+}
+
+// forgetEvictedStreams forgets the streams evicted before the given time. Must hold streamsMtx.
+func (i *instance) forgetEvictedStreams(before time.Time) {
+	for ls, evicted := range i.evictedStreams {
+		if evicted.Before(before) {
+			delete(i.evictedStreams, ls)
+		}
+	}
+}
+
 func (i *instance) getHashForLabels(ls labels.Labels) model.Fingerprint {
 	var fp uint64
 	fp, i.buf = ls.HashWithoutLabels(i.buf, []string(nil)...)
@@ -302,6 +346,9 @@ func (i *instance) Query(ctx context.Context, req logql.SelectLogParams) ([]iter
 	if err != nil {
 		return nil, err
 	}
+	if err := logql.ResolveLookups(ctx, expr); err != nil {
+		return nil, err
+	}
 	pipeline, err := expr.Pipeline()
 	if err != nil {
 		return nil, err
@@ -340,6 +387,9 @@ func (i *instance) QuerySample(ctx context.Context, req logql.SelectSampleParams
 	if err != nil {
 		return nil, err
 	}
+	if err := logql.ResolveLookups(ctx, expr); err != nil {
+		return nil, err
+	}
 	extractor, err := expr.Extractor()
 	if err != nil {
 		return nil, err
@@ -471,6 +521,33 @@ func (i *instance) numStreams() int {
 	return len(i.streams)
 }
 
+// StreamLastEntry holds the timestamp of the newest entry received by a stream.
+type StreamLastEntry struct {
+	Labels    map[string]string `json:"stream"`
+	LastEntry time.Time         `json:"last_entry"`
+}
+
+// LastEntries returns the timestamp of the newest entry of every stream matching the matchers,
+// only those whose newest entry is older than before are returned when before isn't zero.
+func (i *instance) LastEntries(ctx context.Context, matchers []*labels.Matcher, before time.Time) ([]StreamLastEntry, error) {
+	var result []StreamLastEntry
+	err := i.forMatchingStreams(ctx, matchers, nil, func(s *stream) error {
+		s.chunkMtx.RLock()
+		last := s.highestTs
+		s.chunkMtx.RUnlock()
+		if !before.IsZero() && !last.Before(before) {
+			return nil
+		}
+		result = append(result, StreamLastEntry{Labels: s.labels.Map(), LastEntry: last})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].LastEntry.Before(result[j].LastEntry) })
+	return result, nil
+}
+
 // forAllStreams will execute a function for all streams in the instance.
 // It uses a function in order to enable generic stream access without accidentally leaking streams under the mutex.
 func (i *instance) forAllStreams(ctx context.Context, fn func(*stream) error) error {
@@ -67,6 +67,15 @@ func (l *Limiter) UnorderedWrites(userID string) bool {
 	return l.limits.UnorderedWrites(userID)
 }
 
+func (l *Limiter) AllowStructuredMetadata(userID string) bool {
+	// Like the unordered writes, the structured metadata of ack'd writes
+	// is kept while the limiter is disabled for the WAL replay.
+	if l.disabled {
+		return true
+	}
+	return l.limits.AllowStructuredMetadata(userID)
+}
+
 // AssertMaxStreamsPerUser ensures limit has not been reached compared to the current
 // number of streams in input and returns an error if so.
 func (l *Limiter) AssertMaxStreamsPerUser(userID string, streams int) error {
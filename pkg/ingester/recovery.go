@@ -233,12 +233,16 @@ func (r *ingesterRecoverer) Close() {
 			// configuration disables them, convert all streams/head blocks
 			// to ensure unordered writes are disabled after the replay,
 			// but without dropping any previously accepted data.
+			// The same goes for the structured metadata of the entries.
 			isAllowed := r.ing.limiter.UnorderedWrites(s.tenant)
 			old := s.unorderedWrites
 			s.unorderedWrites = isAllowed
+			metadataAllowed := r.ing.limiter.AllowStructuredMetadata(s.tenant)
+			oldMetadata := s.structuredMetadata
+			s.structuredMetadata = metadataAllowed
 
-			if !isAllowed && old {
-				err := s.chunks[len(s.chunks)-1].chunk.ConvertHead(headBlockType(isAllowed))
+			if (!isAllowed && old) || (!metadataAllowed && oldMetadata) {
+				err := s.chunks[len(s.chunks)-1].chunk.ConvertHead(headBlockType(isAllowed, metadataAllowed))
 				if err != nil {
 					return err
 				}
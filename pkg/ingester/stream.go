@@ -97,6 +97,8 @@ type stream struct {
 	entryCt int64
 
 	unorderedWrites bool
+	// structuredMetadata is set when the structured metadata of the entries is kept in the chunks.
+	structuredMetadata bool
 }
 
 type chunkDesc struct {
@@ -113,17 +115,18 @@ type entryWithError struct {
 	e     error
 }
 
-func newStream(cfg *Config, limits RateLimiterStrategy, tenant string, fp model.Fingerprint, labels labels.Labels, unorderedWrites bool, metrics *ingesterMetrics) *stream {
+func newStream(cfg *Config, limits RateLimiterStrategy, tenant string, fp model.Fingerprint, labels labels.Labels, unorderedWrites, structuredMetadata bool, metrics *ingesterMetrics) *stream {
 	return &stream{
-		limiter:         NewStreamRateLimiter(limits, tenant, 10*time.Second),
-		cfg:             cfg,
-		fp:              fp,
-		labels:          labels,
-		labelsString:    labels.String(),
-		tailers:         map[uint32]*tailer{},
-		metrics:         metrics,
-		tenant:          tenant,
-		unorderedWrites: unorderedWrites,
+		limiter:            NewStreamRateLimiter(limits, tenant, 10*time.Second),
+		cfg:                cfg,
+		fp:                 fp,
+		labels:             labels,
+		labelsString:       labels.String(),
+		tailers:            map[uint32]*tailer{},
+		metrics:            metrics,
+		tenant:             tenant,
+		unorderedWrites:    unorderedWrites,
+		structuredMetadata: structuredMetadata,
 	}
 }
 
@@ -162,7 +165,7 @@ func (s *stream) setChunks(chunks []Chunk) (bytesAdded, entriesAdded int, err er
 }
 
 func (s *stream) NewChunk() *chunkenc.MemChunk {
-	return chunkenc.NewMemChunk(s.cfg.parsedEncoding, headBlockType(s.unorderedWrites), s.cfg.BlockSize, s.cfg.TargetChunkSize)
+	return chunkenc.NewMemChunk(s.cfg.parsedEncoding, headBlockType(s.unorderedWrites, s.structuredMetadata), s.cfg.BlockSize, s.cfg.TargetChunkSize)
 }
 
 func (s *stream) Push(
@@ -517,7 +520,10 @@ func (s *stream) resetCounter() {
 	s.entryCt = 0
 }
 
-func headBlockType(unorderedWrites bool) chunkenc.HeadBlockFmt {
+func headBlockType(unorderedWrites, structuredMetadata bool) chunkenc.HeadBlockFmt {
+	if unorderedWrites && structuredMetadata {
+		return chunkenc.UnorderedWithStructuredMetadataHeadBlockFmt
+	}
 	if unorderedWrites {
 		return chunkenc.UnorderedHeadBlockFmt
 	}
@@ -61,6 +61,7 @@ func TestMaxReturnedStreamsErrors(t *testing.T) {
 					{Name: "foo", Value: "bar"},
 				},
 				true,
+				false,
 				NilMetrics,
 			)
 
@@ -106,6 +107,7 @@ func TestPushDeduplication(t *testing.T) {
 			{Name: "foo", Value: "bar"},
 		},
 		true,
+		false,
 		NilMetrics,
 	)
 
@@ -135,6 +137,7 @@ func TestPushRejectOldCounter(t *testing.T) {
 			{Name: "foo", Value: "bar"},
 		},
 		true,
+		false,
 		NilMetrics,
 	)
 
@@ -229,6 +232,7 @@ func TestUnorderedPush(t *testing.T) {
 			{Name: "foo", Value: "bar"},
 		},
 		true,
+		false,
 		NilMetrics,
 	)
 
@@ -309,6 +313,60 @@ func TestUnorderedPush(t *testing.T) {
 	require.Equal(t, false, sItr.Next())
 }
 
+func TestPushStructuredMetadata(t *testing.T) {
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+	limiter := NewLimiter(limits, NilMetrics, &ringCountMock{count: 1}, 1)
+
+	for _, tc := range []struct {
+		desc               string
+		structuredMetadata bool
+	}{
+		{desc: "allowed", structuredMetadata: true},
+		{desc: "not allowed"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := newStream(
+				defaultConfig(),
+				limiter,
+				"fake",
+				model.Fingerprint(0),
+				labels.Labels{
+					{Name: "foo", Value: "bar"},
+				},
+				true,
+				tc.structuredMetadata,
+				NilMetrics,
+			)
+
+			metadata := []logproto.LabelPair{{Name: "trace_id", Value: "abc"}}
+			record := recordPool.GetRecord()
+			_, err := s.Push(context.Background(), []logproto.Entry{
+				{Timestamp: time.Unix(1, 0), Line: "1", StructuredMetadata: metadata},
+				{Timestamp: time.Unix(2, 0), Line: "2"},
+			}, record, 0)
+			require.NoError(t, err)
+			require.Equal(t, WALRecordEntriesV3, record.entriesRecordType())
+
+			expected := []logproto.Entry{
+				{Timestamp: time.Unix(1, 0), Line: "1"},
+				{Timestamp: time.Unix(2, 0), Line: "2"},
+			}
+			if tc.structuredMetadata {
+				expected[0].StructuredMetadata = metadata
+			}
+			it, err := s.Iterator(context.Background(), nil, time.Unix(0, 0), time.Unix(3, 0), logproto.FORWARD, log.NewNoopPipeline().ForStream(s.labels))
+			require.NoError(t, err)
+			var entries []logproto.Entry
+			for it.Next() {
+				entries = append(entries, it.Entry())
+			}
+			require.NoError(t, it.Close())
+			require.Equal(t, expected, entries)
+		})
+	}
+}
+
 func TestPushRateLimit(t *testing.T) {
 	l := validation.Limits{
 		PerStreamRateLimit:      10,
@@ -327,6 +385,7 @@ func TestPushRateLimit(t *testing.T) {
 			{Name: "foo", Value: "bar"},
 		},
 		true,
+		false,
 		NilMetrics,
 	)
 
@@ -356,6 +415,7 @@ func TestReplayAppendIgnoresValidityWindow(t *testing.T) {
 			{Name: "foo", Value: "bar"},
 		},
 		true,
+		false,
 		NilMetrics,
 	)
 
@@ -406,8 +466,8 @@ func Benchmark_PushStream(b *testing.B) {
 	require.NoError(b, err)
 	limiter := NewLimiter(limits, NilMetrics, &ringCountMock{count: 1}, 1)
 
-	s := newStream(&Config{MaxChunkAge: 24 * time.Hour}, limiter, "fake", model.Fingerprint(0), ls, true, NilMetrics)
-	t, err := newTailer("foo", `{namespace="loki-dev"}`, &fakeTailServer{})
+	s := newStream(&Config{MaxChunkAge: 24 * time.Hour}, limiter, "fake", model.Fingerprint(0), ls, true, false, NilMetrics)
+	t, err := newTailer(context.Background(), "foo", `{namespace="loki-dev"}`, &fakeTailServer{})
 	require.NoError(b, err)
 
 	go t.loop()
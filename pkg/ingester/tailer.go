@@ -46,11 +46,14 @@ type tailer struct {
 	conn TailServer
 }
 
-func newTailer(orgID, query string, conn TailServer) (*tailer, error) {
+func newTailer(ctx context.Context, orgID, query string, conn TailServer) (*tailer, error) {
 	expr, err := logql.ParseLogSelector(query, true)
 	if err != nil {
 		return nil, err
 	}
+	if err := logql.ResolveLookups(ctx, expr); err != nil {
+		return nil, err
+	}
 	pipeline, err := expr.Pipeline()
 	if err != nil {
 		return nil, err
@@ -163,8 +166,9 @@ func (t *tailer) processStream(stream logproto.Stream, lbs labels.Labels) []*log
 			streams[parsedLbs.Hash()] = stream
 		}
 		stream.Entries = append(stream.Entries, logproto.Entry{
-			Timestamp: e.Timestamp,
-			Line:      newLine,
+			Timestamp:          e.Timestamp,
+			Line:               newLine,
+			StructuredMetadata: e.StructuredMetadata,
 		})
 	}
 	streamsResult := make([]*logproto.Stream, 0, len(streams))
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logqlmodel/memory"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/pkg/util"
 )
@@ -144,6 +145,9 @@ type heapIterator struct {
 	is         []EntryIterator
 	prefetched bool
 	stats      *stats.Context
+	memory     *memory.Tracker
+	// held is the size of the entries held in the heap, accounted to the memory tracker.
+	held int64
 
 	tuples     []tuple
 	currEntry  logproto.Entry
@@ -154,7 +158,7 @@ type heapIterator struct {
 // NewHeapIterator returns a new iterator which uses a heap to merge together
 // entries for multiple interators.
 func NewHeapIterator(ctx context.Context, is []EntryIterator, direction logproto.Direction) HeapIterator {
-	result := &heapIterator{is: is, stats: stats.FromContext(ctx)}
+	result := &heapIterator{is: is, stats: stats.FromContext(ctx), memory: memory.FromContext(ctx)}
 	switch direction {
 	case logproto.BACKWARD:
 		result.heap = &iteratorMaxHeap{iteratorHeap: make([]EntryIterator, 0, len(is))}
@@ -195,6 +199,7 @@ func (i *heapIterator) prefetch() {
 func (i *heapIterator) requeue(ei EntryIterator, advanced bool) {
 	if advanced || ei.Next() {
 		heap.Push(i.heap, ei)
+		i.track(ei.Entry())
 		return
 	}
 
@@ -208,6 +213,21 @@ func (i *heapIterator) Push(ei EntryIterator) {
 	i.requeue(ei, false)
 }
 
+// track accounts an entry pushed to the heap to the memory tracker of the query.
+// An error exceeding the memory budget is returned by Error().
+func (i *heapIterator) track(e logproto.Entry) {
+	n := int64(len(e.Line))
+	i.held += n
+	_ = i.memory.Add(n)
+}
+
+// untrack releases an entry popped from the heap.
+func (i *heapIterator) untrack(e logproto.Entry) {
+	n := int64(len(e.Line))
+	i.held -= n
+	i.memory.Release(n)
+}
+
 type tuple struct {
 	logproto.Entry
 	EntryIterator
@@ -216,7 +236,7 @@ type tuple struct {
 func (i *heapIterator) Next() bool {
 	i.prefetch()
 
-	if i.heap.Len() == 0 {
+	if i.heap.Len() == 0 || i.memory.Err() != nil {
 		return false
 	}
 
@@ -224,8 +244,11 @@ func (i *heapIterator) Next() bool {
 	if i.heap.Len() == 1 {
 		i.currEntry = i.heap.Peek().Entry()
 		i.currLabels = i.heap.Peek().Labels()
+		i.untrack(i.currEntry)
 		if !i.heap.Peek().Next() {
 			i.heap.Pop()
+		} else {
+			i.track(i.heap.Peek().Entry())
 		}
 		return true
 	}
@@ -242,6 +265,7 @@ func (i *heapIterator) Next() bool {
 		}
 
 		heap.Pop(i.heap)
+		i.untrack(entry)
 		i.tuples = append(i.tuples, tuple{
 			Entry:         entry,
 			EntryIterator: next,
@@ -270,7 +294,7 @@ func (i *heapIterator) Next() bool {
 			continue
 		}
 		// we count as duplicates only if the tuple is not the one (t) used to fill the current entry
-		if i.tuples[j] != t {
+		if i.tuples[j].EntryIterator != t.EntryIterator {
 			i.stats.AddDuplicates(1)
 		}
 		i.requeue(i.tuples[j].EntryIterator, false)
@@ -288,6 +312,9 @@ func (i *heapIterator) Labels() string {
 }
 
 func (i *heapIterator) Error() error {
+	if err := i.memory.Err(); err != nil {
+		return err
+	}
 	switch len(i.errs) {
 	case 0:
 		return nil
@@ -299,6 +326,8 @@ func (i *heapIterator) Error() error {
 }
 
 func (i *heapIterator) Close() error {
+	i.memory.Release(i.held)
+	i.held = 0
 	for i.heap.Len() > 0 {
 		if err := i.heap.Pop().(EntryIterator).Close(); err != nil {
 			return err
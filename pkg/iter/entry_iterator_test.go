@@ -2,6 +2,7 @@ package iter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"testing"
@@ -12,6 +13,8 @@ import (
 	"go.uber.org/atomic"
 
 	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logqlmodel"
+	"github.com/grafana/loki/pkg/logqlmodel/memory"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
 )
 
@@ -296,6 +299,47 @@ func TestHeapIteratorDeduplication(t *testing.T) {
 	assertIt(it, true, len(foo.Entries))
 }
 
+func TestHeapIteratorMemory(t *testing.T) {
+	foo := logproto.Stream{
+		Labels: `{app="foo"}`,
+		Entries: []logproto.Entry{
+			{Timestamp: time.Unix(0, 1), Line: "1234"},
+			{Timestamp: time.Unix(0, 2), Line: "12345678"},
+		},
+	}
+	bar := logproto.Stream{
+		Labels:  `{app="bar"}`,
+		Entries: []logproto.Entry{{Timestamp: time.Unix(0, 1), Line: "12"}},
+	}
+
+	tracker, ctx := memory.NewContext(context.Background(), 0)
+	it := NewHeapIterator(ctx, []EntryIterator{NewStreamIterator(foo), NewStreamIterator(bar)}, logproto.FORWARD)
+	require.True(t, it.Next())
+	require.Equal(t, int64(4), tracker.Used())
+	require.True(t, it.Next())
+	require.Equal(t, int64(8), tracker.Used())
+	require.True(t, it.Next())
+	require.Equal(t, int64(0), tracker.Used())
+	require.False(t, it.Next())
+	require.NoError(t, it.Close())
+	require.Equal(t, int64(8), tracker.Peak())
+
+	// the memory is released on close.
+	_, ctx = memory.NewContext(context.Background(), 0)
+	it = NewHeapIterator(ctx, []EntryIterator{NewStreamIterator(foo), NewStreamIterator(bar)}, logproto.FORWARD)
+	require.True(t, it.Next())
+	require.NoError(t, it.Close())
+	require.Equal(t, int64(0), memory.FromContext(ctx).Used())
+
+	// the iteration stops when the memory budget is exceeded.
+	_, ctx = memory.NewContext(context.Background(), 5)
+	it = NewHeapIterator(ctx, []EntryIterator{NewStreamIterator(foo), NewStreamIterator(bar)}, logproto.FORWARD)
+	require.False(t, it.Next())
+	require.True(t, errors.Is(it.Error(), logqlmodel.ErrLimit))
+	require.Equal(t, context.Canceled, ctx.Err())
+	require.NoError(t, it.Close())
+}
+
 func mustReverseStreamIterator(it EntryIterator) EntryIterator {
 	reversed, err := NewReversedIter(it, 0, true)
 	if err != nil {
@@ -1,8 +1,10 @@
 package client
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -11,6 +13,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
 	"github.com/gorilla/websocket"
 	json "github.com/json-iterator/go"
 	"github.com/prometheus/common/config"
@@ -28,6 +32,7 @@ const (
 	labelValuesPath = "/loki/api/v1/label/%s/values"
 	seriesPath      = "/loki/api/v1/series"
 	tailPath        = "/loki/api/v1/tail"
+	pushPath        = "/loki/api/v1/push"
 )
 
 var userAgent = fmt.Sprintf("loki-logcli/%s", build.Version)
@@ -172,11 +177,58 @@ func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{
 		log.Print(us)
 	}
 
-	req, err := http.NewRequest("GET", us, nil)
+	req, err := c.newRequest("GET", us, nil)
 	if err != nil {
 		return err
 	}
 
+	client, err := c.httpClient()
+	if err != nil {
+		return err
+	}
+
+	var resp *http.Response
+	attempts := c.Retries + 1
+	success := false
+
+	for attempts > 0 {
+		attempts--
+
+		resp, err = client.Do(req)
+		if err != nil {
+			log.Println("error sending request", err)
+			continue
+		}
+		if resp.StatusCode/100 != 2 {
+			buf, _ := ioutil.ReadAll(resp.Body) // nolint
+			log.Printf("Error response from server: %s (%v) attempts remaining: %d", string(buf), err, attempts)
+			if err := resp.Body.Close(); err != nil {
+				log.Println("error closing body", err)
+			}
+			continue
+		}
+		success = true
+		break
+	}
+	if !success {
+		return fmt.Errorf("Run out of attempts while querying the server")
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Println("error closing body", err)
+		}
+	}()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// newRequest creates a request with the authentication and tenant headers set.
+func (c *DefaultClient) newRequest(method, us string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, us, body)
+	if err != nil {
+		return nil, err
+	}
+
 	req.SetBasicAuth(c.Username, c.Password)
 	req.Header.Set("User-Agent", userAgent)
 
@@ -185,11 +237,11 @@ func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{
 	}
 
 	if (c.Username != "" || c.Password != "") && (len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0) {
-		return fmt.Errorf("at most one of HTTP basic auth (username/password), bearer-token & bearer-token-file is allowed to be configured")
+		return nil, fmt.Errorf("at most one of HTTP basic auth (username/password), bearer-token & bearer-token-file is allowed to be configured")
 	}
 
 	if len(c.BearerToken) > 0 && len(c.BearerTokenFile) > 0 {
-		return fmt.Errorf("at most one of the options bearer-token & bearer-token-file is allowed to be configured")
+		return nil, fmt.Errorf("at most one of the options bearer-token & bearer-token-file is allowed to be configured")
 	}
 
 	if c.BearerToken != "" {
@@ -199,12 +251,15 @@ func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{
 	if c.BearerTokenFile != "" {
 		b, err := ioutil.ReadFile(c.BearerTokenFile)
 		if err != nil {
-			return fmt.Errorf("unable to read authorization credentials file %s: %s", c.BearerTokenFile, err)
+			return nil, fmt.Errorf("unable to read authorization credentials file %s: %s", c.BearerTokenFile, err)
 		}
 		bearerToken := strings.TrimSpace(string(b))
 		req.Header.Set("Authorization", "Bearer "+bearerToken)
 	}
+	return req, nil
+}
 
+func (c *DefaultClient) httpClient() (*http.Client, error) {
 	// Parse the URL to extract the host
 	clientConfig := config.HTTPClientConfig{
 		TLSConfig: c.TLSConfig,
@@ -212,45 +267,59 @@ func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{
 
 	client, err := config.NewClientFromConfig(clientConfig, "promtail", config.WithHTTP2Disabled())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if c.Tripperware != nil {
 		client.Transport = c.Tripperware(client.Transport)
 	}
+	return client, nil
+}
 
-	var resp *http.Response
-	attempts := c.Retries + 1
-	success := false
+// Push sends the streams to the /loki/api/v1/push endpoint.
+func (c *DefaultClient) Push(streams []logproto.Stream) error {
+	buf, err := proto.Marshal(&logproto.PushRequest{Streams: streams})
+	if err != nil {
+		return err
+	}
+	buf = snappy.Encode(nil, buf)
 
+	us, err := buildURL(c.Address, pushPath, "")
+	if err != nil {
+		return err
+	}
+	client, err := c.httpClient()
+	if err != nil {
+		return err
+	}
+
+	attempts := c.Retries + 1
 	for attempts > 0 {
 		attempts--
 
-		resp, err = client.Do(req)
+		req, err := c.newRequest("POST", us, bytes.NewReader(buf))
 		if err != nil {
-			log.Println("error sending request", err)
-			continue
+			return err
 		}
-		if resp.StatusCode/100 != 2 {
-			buf, _ := ioutil.ReadAll(resp.Body) // nolint
-			log.Printf("Error response from server: %s (%v) attempts remaining: %d", string(buf), err, attempts)
-			if err := resp.Body.Close(); err != nil {
-				log.Println("error closing body", err)
-			}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println("error sending request", err)
 			continue
 		}
-		success = true
-		break
-	}
-	if !success {
-		return fmt.Errorf("Run out of attempts while querying the server")
-	}
-
-	defer func() {
+		body, _ := ioutil.ReadAll(resp.Body) // nolint
 		if err := resp.Body.Close(); err != nil {
 			log.Println("error closing body", err)
 		}
-	}()
-	return json.NewDecoder(resp.Body).Decode(out)
+		if resp.StatusCode/100 == 2 {
+			return nil
+		}
+		if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+			return fmt.Errorf("Error response from server: %s (%d)", string(body), resp.StatusCode)
+		}
+		log.Printf("Error response from server: %s (%d) attempts remaining: %d", string(body), resp.StatusCode, attempts)
+	}
+	return fmt.Errorf("Run out of attempts while pushing to the server")
 }
 
 func (c *DefaultClient) wsConnect(path, query string, quiet bool) (*websocket.Conn, error) {
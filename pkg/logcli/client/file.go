@@ -192,6 +192,10 @@ func (l *limiter) MaxQuerySeries(userID string) int {
 	return l.n
 }
 
+func (l *limiter) MaxQueryMemory(userID string) int {
+	return 0
+}
+
 type querier struct {
 	r      io.Reader
 	labels labels.Labels
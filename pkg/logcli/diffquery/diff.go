@@ -0,0 +1,215 @@
+package diffquery
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/pkg/logcli/client"
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+)
+
+// DiffQuery contains all necessary fields to compare the results of a query over two
+// time ranges, or of two queries, and print out the differences.
+type DiffQuery struct {
+	QueryString string
+	// CompareQueryString is the query run over the compare range, QueryString if empty.
+	CompareQueryString string
+	Start              time.Time
+	End                time.Time
+	CompareStart       time.Time
+	CompareEnd         time.Time
+	// Limit is the number of lines of each range the patterns are computed on.
+	Limit int
+	// Top is the number of streams and patterns printed, all if 0.
+	Top   int
+	Quiet bool
+}
+
+// Counts holds the entries found by a query over a time range.
+type Counts struct {
+	Total   int64
+	Streams map[string]int64
+	// Patterns counts the lines by pattern, estimated from Sampled lines when the
+	// range holds more lines than the limit.
+	Patterns map[string]int64
+	Sampled  int
+}
+
+// Diff holds the entries found over the compare range, Before, and the query range, After.
+type Diff struct {
+	Before Counts
+	After  Counts
+}
+
+// DoDiff compares the queries and prints out the differences.
+func (q *DiffQuery) DoDiff(c client.Client) {
+	d, err := q.Diff(c)
+	if err != nil {
+		log.Fatalf("Query failed: %+v", err)
+	}
+	d.Print(os.Stdout, q.Top)
+}
+
+// Diff runs the queries over both ranges.
+func (q *DiffQuery) Diff(c client.Client) (*Diff, error) {
+	compareQuery := q.CompareQueryString
+	if compareQuery == "" {
+		compareQuery = q.QueryString
+	}
+	before, err := q.counts(c, compareQuery, q.CompareStart, q.CompareEnd)
+	if err != nil {
+		return nil, err
+	}
+	after, err := q.counts(c, q.QueryString, q.Start, q.End)
+	if err != nil {
+		return nil, err
+	}
+	return &Diff{Before: before, After: after}, nil
+}
+
+func (q *DiffQuery) counts(c client.Client, query string, start, end time.Time) (Counts, error) {
+	counts := Counts{Streams: map[string]int64{}, Patterns: map[string]int64{}}
+	if _, err := logql.ParseLogSelector(query, true); err != nil {
+		return counts, fmt.Errorf("diff only compares log queries: %w", err)
+	}
+	if !end.After(start) {
+		return counts, fmt.Errorf("invalid time range %s to %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	// count the entries of every stream with a single sample over the whole range. The
+	// range of the sample excludes its start and includes its end, evaluate it 1ns
+	// earlier to count the entries of [start, end) like the query of the lines.
+	countQuery := fmt.Sprintf("count_over_time(%s[%s])", query, model.Duration(end.Sub(start)))
+	resp, err := c.Query(countQuery, q.Limit, end.Add(-time.Nanosecond), logproto.BACKWARD, q.Quiet)
+	if err != nil {
+		return counts, err
+	}
+	vector, ok := resp.Data.Result.(loghttp.Vector)
+	if !ok {
+		return counts, fmt.Errorf("unexpected result type %s counting entries", resp.Data.ResultType)
+	}
+	for _, s := range vector {
+		n := int64(s.Value)
+		counts.Streams[s.Metric.String()] += n
+		counts.Total += n
+	}
+
+	resp, err = c.QueryRange(query, q.Limit, start, end, logproto.BACKWARD, 0, 0, q.Quiet)
+	if err != nil {
+		return counts, err
+	}
+	streams, ok := resp.Data.Result.(loghttp.Streams)
+	if !ok {
+		return counts, fmt.Errorf("unexpected result type %s sampling lines", resp.Data.ResultType)
+	}
+	for _, s := range streams {
+		for _, e := range s.Entries {
+			counts.Patterns[Pattern(e.Line)]++
+			counts.Sampled++
+		}
+	}
+	if counts.Sampled > 0 && int64(counts.Sampled) < counts.Total {
+		for p, n := range counts.Patterns {
+			counts.Patterns[p] = n * counts.Total / int64(counts.Sampled)
+		}
+	}
+	return counts, nil
+}
+
+// Pattern returns the pattern of a line: its whitespace separated tokens, with those
+// holding a digit, such as timestamps, ids and durations, replaced by <_>. Only the
+// value of key=value tokens is replaced.
+func Pattern(line string) string {
+	tokens := strings.Fields(line)
+	for i, token := range tokens {
+		key := ""
+		if eq := strings.IndexByte(token, '='); eq > 0 {
+			key, token = token[:eq+1], token[eq+1:]
+		}
+		if strings.IndexFunc(token, unicode.IsDigit) >= 0 {
+			tokens[i] = key + "<_>"
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// Print writes the differences of the entry counts and of the top streams and patterns.
+func (d *Diff) Print(w io.Writer, top int) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "\tBefore\tAfter\tDelta\n")
+	fmt.Fprintf(tw, "Entries\t%d\t%d\t%s\n", d.Before.Total, d.After.Total, delta(d.Before.Total, d.After.Total))
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	printCounts(w, "Stream", d.Before.Streams, d.After.Streams, top)
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Patterns of the %d lines before and %d lines after sampled, scaled to the entry counts.\n", d.Before.Sampled, d.After.Sampled)
+	printCounts(w, "Pattern", d.Before.Patterns, d.After.Patterns, top)
+}
+
+type row struct {
+	key           string
+	before, after int64
+}
+
+func printCounts(w io.Writer, name string, before, after map[string]int64, top int) {
+	rows := make([]row, 0, len(after))
+	for key, n := range after {
+		rows = append(rows, row{key: key, before: before[key], after: n})
+	}
+	for key, n := range before {
+		if _, ok := after[key]; !ok {
+			rows = append(rows, row{key: key, before: n})
+		}
+	}
+	// the largest changes first.
+	sort.Slice(rows, func(i, j int) bool {
+		di, dj := abs(rows[i].after-rows[i].before), abs(rows[j].after-rows[j].before)
+		if di != dj {
+			return di > dj
+		}
+		return rows[i].key < rows[j].key
+	})
+	if top > 0 && len(rows) > top {
+		rows = rows[:top]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tBefore\tAfter\tDelta\n", name)
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", r.key, r.before, r.after, delta(r.before, r.after))
+	}
+	tw.Flush()
+}
+
+func delta(before, after int64) string {
+	switch {
+	case before == after:
+		return "0"
+	case before == 0:
+		return fmt.Sprintf("%+d (new)", after)
+	case after == 0:
+		return fmt.Sprintf("%+d (gone)", -before)
+	default:
+		return fmt.Sprintf("%+d (%+.1f%%)", after-before, float64(after-before)*100/float64(before))
+	}
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,168 @@
+package diffquery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/util/marshal"
+)
+
+func TestPattern(t *testing.T) {
+	for line, pattern := range map[string]string{
+		"":                                  "",
+		"level=info msg=started":            "level=info msg=started",
+		"request took 12ms  status=500":     "request took <_> status=<_>",
+		"user 9f86d081 logged in from host": "user <_> logged in from host",
+	} {
+		require.Equal(t, pattern, Pattern(line), line)
+	}
+}
+
+func TestDiffQuery_Diff(t *testing.T) {
+	start := time.Unix(0, 0)
+	var api, db []logproto.Entry
+	// the api errors start failing after the first hour, the db stops logging.
+	for i := 0; i < 120; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		api = append(api, logproto.Entry{Timestamp: ts, Line: fmt.Sprintf("request %d ok", i)})
+		if i >= 60 && i%2 == 0 {
+			api = append(api, logproto.Entry{Timestamp: ts.Add(time.Second), Line: fmt.Sprintf("request %d failed: timeout", i)})
+		}
+		if i < 60 && i%3 == 0 {
+			db = append(db, logproto.Entry{Timestamp: ts, Line: "vacuum done"})
+		}
+	}
+	c := newTestQueryClient(
+		logproto.Stream{Labels: `{app="api"}`, Entries: api},
+		logproto.Stream{Labels: `{app="db"}`, Entries: db},
+	)
+
+	q := &DiffQuery{
+		QueryString:  `{app=~".+"}`,
+		CompareStart: start,
+		CompareEnd:   start.Add(time.Hour),
+		Start:        start.Add(time.Hour),
+		End:          start.Add(2 * time.Hour),
+		Limit:        1000,
+		Quiet:        true,
+	}
+	d, err := q.Diff(c)
+	require.NoError(t, err)
+	require.Equal(t, int64(80), d.Before.Total)
+	require.Equal(t, int64(90), d.After.Total)
+	require.Equal(t, map[string]int64{`{app="api"}`: 60, `{app="db"}`: 20}, d.Before.Streams)
+	require.Equal(t, map[string]int64{`{app="api"}`: 90}, d.After.Streams)
+	require.Equal(t, map[string]int64{"request <_> ok": 60, "vacuum done": 20}, d.Before.Patterns)
+	require.Equal(t, map[string]int64{"request <_> ok": 60, "request <_> failed: timeout": 30}, d.After.Patterns)
+
+	var buf bytes.Buffer
+	d.Print(&buf, 2)
+	require.Equal(t, `         Before  After  Delta
+Entries  80      90     +10 (+12.5%)
+
+Stream       Before  After  Delta
+{app="api"}  60      90     +30 (+50.0%)
+{app="db"}   20      0      -20 (gone)
+
+Patterns of the 80 lines before and 90 lines after sampled, scaled to the entry counts.
+Pattern                      Before  After  Delta
+request <_> failed: timeout  0       30     +30 (new)
+vacuum done                  20      0      -20 (gone)
+`, buf.String())
+
+	// sampled patterns are scaled to the entry counts.
+	q.Limit = 45
+	d, err = q.Diff(c)
+	require.NoError(t, err)
+	require.Equal(t, 45, d.After.Sampled)
+	var total int64
+	for _, n := range d.After.Patterns {
+		total += n
+	}
+	require.Equal(t, int64(90), total)
+
+	// two queries over the same range.
+	q = &DiffQuery{
+		QueryString:        `{app="api"} |= "failed"`,
+		CompareQueryString: `{app="api"}`,
+		CompareStart:       start,
+		CompareEnd:         start.Add(2 * time.Hour),
+		Start:              start,
+		End:                start.Add(2 * time.Hour),
+		Limit:              1000,
+		Quiet:              true,
+	}
+	d, err = q.Diff(c)
+	require.NoError(t, err)
+	require.Equal(t, int64(150), d.Before.Total)
+	require.Equal(t, int64(30), d.After.Total)
+
+	q.QueryString = `count_over_time({app="api"}[1m])`
+	_, err = q.Diff(c)
+	require.Error(t, err)
+}
+
+type testQueryClient struct {
+	engine *logql.Engine
+}
+
+func newTestQueryClient(testStreams ...logproto.Stream) *testQueryClient {
+	q := logql.NewMockQuerier(0, testStreams)
+	return &testQueryClient{engine: logql.NewEngine(logql.EngineOpts{}, q, logql.NoLimits)}
+}
+
+func (t *testQueryClient) Query(queryStr string, limit int, time time.Time, direction logproto.Direction, quiet bool) (*loghttp.QueryResponse, error) {
+	return t.exec(logql.NewLiteralParams(queryStr, time, time, 0, 0, direction, uint32(limit), nil))
+}
+
+func (t *testQueryClient) QueryRange(queryStr string, limit int, from, through time.Time, direction logproto.Direction, step, interval time.Duration, quiet bool) (*loghttp.QueryResponse, error) {
+	return t.exec(logql.NewLiteralParams(queryStr, from, through, step, interval, direction, uint32(limit), nil))
+}
+
+func (t *testQueryClient) exec(params logql.LiteralParams) (*loghttp.QueryResponse, error) {
+	v, err := t.engine.Query(params).Exec(user.InjectOrgID(context.Background(), "fake"))
+	if err != nil {
+		return nil, err
+	}
+	value, err := marshal.NewResultValue(v.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &loghttp.QueryResponse{
+		Status: "success",
+		Data: loghttp.QueryResponseData{
+			ResultType: value.Type(),
+			Result:     value,
+		},
+	}, nil
+}
+
+func (t *testQueryClient) ListLabelNames(quiet bool, from, through time.Time) (*loghttp.LabelResponse, error) {
+	panic("implement me")
+}
+
+func (t *testQueryClient) ListLabelValues(name string, quiet bool, from, through time.Time) (*loghttp.LabelResponse, error) {
+	panic("implement me")
+}
+
+func (t *testQueryClient) Series(matchers []string, from, through time.Time, quiet bool) (*loghttp.SeriesResponse, error) {
+	panic("implement me")
+}
+
+func (t *testQueryClient) LiveTailQueryConn(queryStr string, delayFor time.Duration, limit int, start time.Time, quiet bool) (*websocket.Conn, error) {
+	panic("implement me")
+}
+
+func (t *testQueryClient) GetOrgID() string {
+	return ""
+}
@@ -0,0 +1,211 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/clients/pkg/logentry/stages"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+const (
+	filenameLabel = "filename"
+	// maxLineSize is the maximum size of a line read from a file.
+	maxLineSize = 10 << 20
+)
+
+var errStopped = errors.New("import stopped")
+
+// Config is the pipeline applied to the imported lines.
+type Config struct {
+	// Labels are added to every line before the pipeline stages are run.
+	Labels         model.LabelSet        `yaml:"labels"`
+	PipelineStages stages.PipelineStages `yaml:"pipeline_stages"`
+}
+
+// Pusher pushes streams to Loki.
+type Pusher interface {
+	Push(streams []logproto.Stream) error
+}
+
+// Import contains all necessary fields to import files
+type Import struct {
+	ConfigFile string
+	Files      []string
+	BatchSize  int
+	Quiet      bool
+}
+
+// DoImport imports the files and exits on error.
+func (i *Import) DoImport(p Pusher) {
+	if err := i.Run(p); err != nil {
+		log.Fatalf("Error importing files: %+v", err)
+	}
+}
+
+// Run reads the files line by line, runs the lines through the pipeline and pushes them in batches.
+func (i *Import) Run(p Pusher) error {
+	cfg, err := loadConfig(i.ConfigFile)
+	if err != nil {
+		return err
+	}
+	jobName := "logcli-import"
+	pipeline, err := stages.NewPipeline(kitlog.NewNopLogger(), cfg.PipelineStages, &jobName, prometheus.NewRegistry())
+	if err != nil {
+		return err
+	}
+
+	in := make(chan stages.Entry)
+	out := pipeline.Run(in)
+	done := make(chan struct{})
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(in)
+		for _, name := range i.Files {
+			if err := i.readFile(name, cfg.Labels, in, done); err != nil {
+				readErr <- err
+				return
+			}
+		}
+		readErr <- nil
+	}()
+
+	b := newBatch()
+	var (
+		pushErr error
+		lines   int
+	)
+	for e := range out {
+		if pushErr != nil {
+			// keep draining the pipeline so that the reader doesn't block.
+			continue
+		}
+		b.add(e.Entry)
+		lines++
+		if b.size >= i.BatchSize {
+			if pushErr = b.push(p); pushErr != nil {
+				close(done)
+			}
+		}
+	}
+	if err := <-readErr; err != nil && err != errStopped {
+		return err
+	}
+	if pushErr != nil {
+		return pushErr
+	}
+	if err := b.push(p); err != nil {
+		return err
+	}
+	if !i.Quiet {
+		log.Printf("Imported %d lines from %d files", lines, len(i.Files))
+	}
+	return nil
+}
+
+func loadConfig(path string) (Config, error) {
+	var cfg Config
+	if path == "" {
+		return cfg, nil
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.UnmarshalStrict(buf, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (i *Import) readFile(name string, labels model.LabelSet, in chan<- stages.Entry, done <-chan struct{}) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if !i.Quiet {
+		log.Printf("Importing %s", name)
+	}
+	return readLines(f, labels.Merge(model.LabelSet{filenameLabel: model.LabelValue(name)}), in, done)
+}
+
+// readLines sends the lines to the pipeline until the reader is exhausted or done is closed.
+func readLines(r io.Reader, labels model.LabelSet, in chan<- stages.Entry, done <-chan struct{}) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		e := stages.Entry{
+			Extracted: map[string]interface{}{},
+			Entry: api.Entry{
+				Labels: labels.Clone(),
+				Entry: logproto.Entry{
+					// lines without a timestamp stage are stamped with the import time.
+					Timestamp: time.Now(),
+					Line:      scanner.Text(),
+				},
+			},
+		}
+		select {
+		case in <- e:
+		case <-done:
+			return errStopped
+		}
+	}
+	return scanner.Err()
+}
+
+// batch groups entries by stream until it's pushed.
+type batch struct {
+	streams map[string]*logproto.Stream
+	order   []string
+	size    int
+}
+
+func newBatch() *batch {
+	return &batch{streams: map[string]*logproto.Stream{}}
+}
+
+func (b *batch) add(e api.Entry) {
+	for name := range e.Labels {
+		// reserved labels such as __path__ are only meaningful within the pipeline.
+		if strings.HasPrefix(string(name), "__") {
+			delete(e.Labels, name)
+		}
+	}
+	key := e.Labels.String()
+	stream, ok := b.streams[key]
+	if !ok {
+		stream = &logproto.Stream{Labels: key}
+		b.streams[key] = stream
+		b.order = append(b.order, key)
+	}
+	stream.Entries = append(stream.Entries, e.Entry)
+	b.size += len(e.Line)
+}
+
+func (b *batch) push(p Pusher) error {
+	if len(b.order) == 0 {
+		return nil
+	}
+	streams := make([]logproto.Stream, 0, len(b.order))
+	for _, key := range b.order {
+		streams = append(streams, *b.streams[key])
+	}
+	*b = *newBatch()
+	return p.Push(streams)
+}
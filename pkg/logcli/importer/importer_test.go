@@ -0,0 +1,74 @@
+package importer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+type fakePusher struct {
+	pushes [][]logproto.Stream
+}
+
+func (p *fakePusher) Push(streams []logproto.Stream) error {
+	p.pushes = append(p.pushes, streams)
+	return nil
+}
+
+func TestImport_Run(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "pipeline.yaml")
+	require.NoError(t, ioutil.WriteFile(config, []byte(`
+labels:
+  job: backfill
+pipeline_stages:
+  - regex:
+      expression: '^(?P<ts>\S+) (?P<level>\S+)'
+  - timestamp:
+      source: ts
+      format: RFC3339
+  - labels:
+      level:
+  - drop:
+      source: level
+      value: debug
+`), 0o644))
+	logs := filepath.Join(dir, "app.log")
+	require.NoError(t, ioutil.WriteFile(logs, []byte(
+		"2021-01-01T00:00:00Z info started\n"+
+			"2021-01-01T00:00:01Z error failed\n"+
+			"2021-01-01T00:00:01Z debug retrying\n"+
+			"2021-01-01T00:00:02Z info stopped\n"), 0o644))
+
+	p := &fakePusher{}
+	i := &Import{ConfigFile: config, Files: []string{logs}, BatchSize: 1 << 20, Quiet: true}
+	require.NoError(t, i.Run(p))
+
+	require.Len(t, p.pushes, 1)
+	require.Equal(t, []logproto.Stream{
+		{
+			Labels: `{filename="` + logs + `", job="backfill", level="info"}`,
+			Entries: []logproto.Entry{
+				{Timestamp: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Line: "2021-01-01T00:00:00Z info started"},
+				{Timestamp: time.Date(2021, 1, 1, 0, 0, 2, 0, time.UTC), Line: "2021-01-01T00:00:02Z info stopped"},
+			},
+		},
+		{
+			Labels: `{filename="` + logs + `", job="backfill", level="error"}`,
+			Entries: []logproto.Entry{
+				{Timestamp: time.Date(2021, 1, 1, 0, 0, 1, 0, time.UTC), Line: "2021-01-01T00:00:01Z error failed"},
+			},
+		},
+	}, p.pushes[0])
+
+	// every line exceeds the batch size.
+	p = &fakePusher{}
+	i.BatchSize = 1
+	require.NoError(t, i.Run(p))
+	require.Len(t, p.pushes, 3)
+}
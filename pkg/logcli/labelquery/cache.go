@@ -0,0 +1,82 @@
+package labelquery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cache stores the results of label queries on disk, so that shell completion
+// does not query Loki on every key press. Entries are keyed by server, tenant,
+// label name and the length of the queried window, so --since is honoured.
+type Cache struct {
+	Dir    string
+	TTL    time.Duration
+	Server string
+}
+
+func (c *Cache) enabled() bool {
+	return c != nil && c.Dir != "" && c.TTL > 0
+}
+
+func (c *Cache) path(orgID string, q *LabelQuery) string {
+	window := q.End.Sub(q.Start).Truncate(time.Second)
+	h := sha256.Sum256([]byte(strings.Join([]string{c.Server, orgID, q.LabelName, window.String()}, "\x00")))
+	return filepath.Join(c.Dir, hex.EncodeToString(h[:])+".json")
+}
+
+// get returns the cached labels of q, if they are fresh.
+func (c *Cache) get(orgID string, q *LabelQuery) ([]string, bool) {
+	if !c.enabled() {
+		return nil, false
+	}
+	p := c.path(orgID, q)
+	fi, err := os.Stat(p)
+	if err != nil || time.Since(fi.ModTime()) > c.TTL {
+		return nil, false
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	var values []string
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, false
+	}
+	return values, true
+}
+
+// set caches the labels of q. The cache is best effort, errors are ignored.
+func (c *Cache) set(orgID string, q *LabelQuery, values []string) {
+	if !c.enabled() {
+		return
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return
+	}
+	// Write to a temporary file first, concurrent completions must never read a partial entry.
+	tmp, err := ioutil.TempFile(c.Dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), c.path(orgID, q)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,68 @@
+package labelquery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logcli/client"
+	"github.com/grafana/loki/pkg/loghttp"
+)
+
+type countingClient struct {
+	client.Client
+	calls int
+}
+
+func (c *countingClient) ListLabelNames(_ bool, _, _ time.Time) (*loghttp.LabelResponse, error) {
+	c.calls++
+	return &loghttp.LabelResponse{Data: []string{"job"}}, nil
+}
+
+func (c *countingClient) ListLabelValues(_ string, _ bool, _, _ time.Time) (*loghttp.LabelResponse, error) {
+	c.calls++
+	return &loghttp.LabelResponse{Data: []string{"api", "web app"}}, nil
+}
+
+func (c *countingClient) GetOrgID() string {
+	return "tenant"
+}
+
+func TestLabelQuery_CompleteSelectors(t *testing.T) {
+	c := &countingClient{}
+	cache := &Cache{Dir: t.TempDir(), TTL: time.Minute, Server: "http://localhost:3100"}
+	end := time.Now()
+	q := &LabelQuery{Start: end.Add(-time.Hour), End: end, Cache: cache}
+
+	expected := []string{`{job="api"}`, `{job="web app"}`}
+	require.Equal(t, expected, q.CompleteSelectors(c))
+	require.Equal(t, 2, c.calls)
+
+	// Later completions over the same window are served by the cache.
+	end = end.Add(time.Minute)
+	q = &LabelQuery{Start: end.Add(-time.Hour), End: end, Cache: cache}
+	require.Equal(t, expected, q.CompleteSelectors(c))
+	require.Equal(t, 2, c.calls)
+
+	// A different window is queried again.
+	q = &LabelQuery{Start: end.Add(-2 * time.Hour), End: end, Cache: cache}
+	require.Equal(t, []string{"job"}, q.CompleteLabels(c))
+	require.Equal(t, 3, c.calls)
+}
+
+func TestLabelQuery_ListLabelsRefreshesCache(t *testing.T) {
+	c := &countingClient{}
+	cache := &Cache{Dir: t.TempDir(), TTL: time.Minute}
+	end := time.Now()
+	q := &LabelQuery{LabelName: "job", Quiet: true, Start: end.Add(-time.Hour), End: end, Cache: cache}
+
+	require.Equal(t, []string{"api", "web app"}, q.ListLabels(c))
+	require.Equal(t, []string{"api", "web app"}, q.CompleteLabels(c))
+	require.Equal(t, 1, c.calls)
+
+	// A disabled cache always queries Loki.
+	cache.TTL = 0
+	require.Equal(t, []string{"api", "web app"}, q.CompleteLabels(c))
+	require.Equal(t, 2, c.calls)
+}
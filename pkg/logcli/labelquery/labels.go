@@ -3,6 +3,7 @@ package labelquery
 import (
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/grafana/loki/pkg/logcli/client"
@@ -15,6 +16,8 @@ type LabelQuery struct {
 	Quiet     bool
 	Start     time.Time
 	End       time.Time
+	// Cache, if set, is updated with the results of the query and used by shell completion.
+	Cache *Cache
 }
 
 // DoLabels prints out label results
@@ -28,15 +31,52 @@ func (q *LabelQuery) DoLabels(c client.Client) {
 
 // ListLabels returns an array of label strings
 func (q *LabelQuery) ListLabels(c client.Client) []string {
+	values, err := q.listLabels(c, q.Quiet)
+	if err != nil {
+		log.Fatalf("Error doing request: %+v", err)
+	}
+	q.Cache.set(c.GetOrgID(), q, values)
+	return values
+}
+
+// CompleteLabels returns the labels for shell completion, from the cache
+// when it is fresh. Errors yield no completions.
+func (q *LabelQuery) CompleteLabels(c client.Client) []string {
+	if values, ok := q.Cache.get(c.GetOrgID(), q); ok {
+		return values
+	}
+	// Completion output is parsed by the shell, the query must not print anything.
+	values, err := q.listLabels(c, true)
+	if err != nil {
+		return nil
+	}
+	q.Cache.set(c.GetOrgID(), q, values)
+	return values
+}
+
+// CompleteSelectors returns a stream selector for every label value, for shell completion.
+func (q *LabelQuery) CompleteSelectors(c client.Client) []string {
+	var selectors []string
+	for _, name := range q.CompleteLabels(c) {
+		vq := *q
+		vq.LabelName = name
+		for _, value := range vq.CompleteLabels(c) {
+			selectors = append(selectors, fmt.Sprintf("{%s=%s}", name, strconv.Quote(value)))
+		}
+	}
+	return selectors
+}
+
+func (q *LabelQuery) listLabels(c client.Client, quiet bool) ([]string, error) {
 	var labelResponse *loghttp.LabelResponse
 	var err error
 	if len(q.LabelName) > 0 {
-		labelResponse, err = c.ListLabelValues(q.LabelName, q.Quiet, q.Start, q.End)
+		labelResponse, err = c.ListLabelValues(q.LabelName, quiet, q.Start, q.End)
 	} else {
-		labelResponse, err = c.ListLabelNames(q.Quiet, q.Start, q.End)
+		labelResponse, err = c.ListLabelNames(quiet, q.Start, q.End)
 	}
 	if err != nil {
-		log.Fatalf("Error doing request: %+v", err)
+		return nil, err
 	}
-	return labelResponse.Data
+	return labelResponse.Data, nil
 }
@@ -1,6 +1,7 @@
 package loghttp
 
 import (
+	"sort"
 	"strconv"
 	"time"
 	"unsafe"
@@ -8,6 +9,8 @@ import (
 	"github.com/buger/jsonparser"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/modern-go/reflect2"
+
+	"github.com/grafana/loki/pkg/logproto"
 )
 
 func init() {
@@ -15,9 +18,13 @@ func init() {
 }
 
 // Entry represents a log entry.  It includes a log message and the time it occurred at.
+// Its layout must match the one of logproto.Entry, entries are converted without copies.
 type Entry struct {
 	Timestamp time.Time
 	Line      string
+	// StructuredMetadata is read from an optional third value of the entry array,
+	// an object of string values.
+	StructuredMetadata []logproto.LabelPair
 }
 
 func (e *Entry) UnmarshalJSON(data []byte) error {
@@ -41,6 +48,16 @@ func (e *Entry) UnmarshalJSON(data []byte) error {
 				return
 			}
 			e.Line = v
+		case 2: // structured metadata
+			parseError = jsonparser.ObjectEach(value, func(key, val []byte, _ jsonparser.ValueType, _ int) error {
+				v, err := jsonparser.ParseString(val)
+				if err != nil {
+					return err
+				}
+				e.StructuredMetadata = append(e.StructuredMetadata, logproto.LabelPair{Name: string(key), Value: v})
+				return nil
+			})
+			sortLabelPairs(e.StructuredMetadata)
 		}
 		i++
 	})
@@ -62,6 +79,7 @@ func (sliceEntryDecoder) Decode(ptr unsafe.Pointer, iter *jsoniter.Iterator) {
 		i := 0
 		var ts time.Time
 		var line string
+		var metadata []logproto.LabelPair
 		ok := iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
 			var ok bool
 			switch i {
@@ -76,15 +94,24 @@ func (sliceEntryDecoder) Decode(ptr unsafe.Pointer, iter *jsoniter.Iterator) {
 					return false
 				}
 				return true
+			case 2:
+				ok := iter.ReadMapCB(func(iter *jsoniter.Iterator, name string) bool {
+					metadata = append(metadata, logproto.LabelPair{Name: name, Value: iter.ReadString()})
+					return iter.Error == nil
+				})
+				sortLabelPairs(metadata)
+				i++
+				return ok
 			default:
-				iter.ReportError("error reading entry", "array must contains 2 values")
+				iter.ReportError("error reading entry", "array must contains 2 or 3 values")
 				return false
 			}
 		})
 		if ok {
 			*((*[]Entry)(ptr)) = append(*((*[]Entry)(ptr)), Entry{
-				Timestamp: ts,
-				Line:      line,
+				Timestamp:          ts,
+				Line:               line,
+				StructuredMetadata: metadata,
 			})
 			return true
 		}
@@ -106,6 +133,11 @@ func readTimestamp(iter *jsoniter.Iterator) (time.Time, bool) {
 	return time.Unix(0, t), true
 }
 
+// sortLabelPairs sorts the structured metadata of an entry by name, like the labels of a stream.
+func sortLabelPairs(pairs []logproto.LabelPair) {
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+}
+
 type entryEncoder struct{}
 
 func (entryEncoder) IsEmpty(ptr unsafe.Pointer) bool {
@@ -121,6 +153,18 @@ func (entryEncoder) Encode(ptr unsafe.Pointer, stream *jsoniter.Stream) {
 	stream.WriteRaw(`"`)
 	stream.WriteMore()
 	stream.WriteStringWithHTMLEscaped(e.Line)
+	if len(e.StructuredMetadata) > 0 {
+		stream.WriteMore()
+		stream.WriteObjectStart()
+		for i, m := range e.StructuredMetadata {
+			if i > 0 {
+				stream.WriteMore()
+			}
+			stream.WriteObjectField(m.Name)
+			stream.WriteStringWithHTMLEscaped(m.Value)
+		}
+		stream.WriteObjectEnd()
+	}
 	stream.WriteArrayEnd()
 }
 
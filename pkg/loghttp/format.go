@@ -0,0 +1,102 @@
+package loghttp
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ResponseFormat is the encoding of a query response, negotiated with the Accept header.
+type ResponseFormat string
+
+// ResponseFormat values
+const (
+	ResponseFormatJSON ResponseFormat = "application/json"
+	// ResponseFormatCompactJSON encodes the entries of a stream, and the samples of a series,
+	// as columns rather than as an array of pairs.
+	ResponseFormatCompactJSON ResponseFormat = "application/vnd.grafana.loki.compact+json"
+	// ResponseFormatProtobuf encodes responses with the protobuf messages exchanged by the
+	// query frontend and the queriers.
+	ResponseFormatProtobuf ResponseFormat = "application/x-protobuf"
+)
+
+// Optional parts of a query response, selected with the fields parameter.
+const (
+	FieldStats  = "stats"
+	FieldLabels = "labels"
+)
+
+// ResponseOptions select the encoding of a query response and its optional parts.
+type ResponseOptions struct {
+	Format ResponseFormat
+	// OmitStats omits the query statistics.
+	OmitStats bool
+	// OmitLabels omits the labels of every stream or series.
+	OmitLabels bool
+}
+
+// DefaultResponseOptions are the options of the responses of the requests setting none.
+var DefaultResponseOptions = ResponseOptions{Format: ResponseFormatJSON}
+
+// IsDefault returns whether the response is encoded as it always was.
+func (o ResponseOptions) IsDefault() bool {
+	return o == DefaultResponseOptions
+}
+
+// ParseResponseOptions parses the response options of a query request: the format is
+// the first supported one of the Accept header, JSON by default, and the fields
+// parameter is the comma separated list of the optional parts to include, all of
+// them by default.
+func ParseResponseOptions(r *http.Request) (ResponseOptions, error) {
+	opts := DefaultResponseOptions
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(accept)
+		if err != nil {
+			continue
+		}
+		if f := ResponseFormat(mediaType); f == ResponseFormatJSON || f == ResponseFormatCompactJSON || f == ResponseFormatProtobuf {
+			opts.Format = f
+			break
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return opts, err
+	}
+	values, ok := r.Form["fields"]
+	if !ok {
+		return opts, nil
+	}
+	opts.OmitStats, opts.OmitLabels = true, true
+	for _, v := range values {
+		for _, field := range strings.Split(v, ",") {
+			switch strings.TrimSpace(field) {
+			case FieldStats:
+				opts.OmitStats = false
+			case FieldLabels:
+				opts.OmitLabels = false
+			case "":
+			default:
+				return opts, fmt.Errorf("unknown response field %q, expected %s or %s", field, FieldStats, FieldLabels)
+			}
+		}
+	}
+	return opts, nil
+}
+
+type responseOptionsKey struct{}
+
+// InjectResponseOptions returns a derived context holding the response options.
+func InjectResponseOptions(ctx context.Context, opts ResponseOptions) context.Context {
+	return context.WithValue(ctx, responseOptionsKey{}, opts)
+}
+
+// ResponseOptionsFromContext returns the response options of the context, or the default ones.
+func ResponseOptionsFromContext(ctx context.Context) ResponseOptions {
+	if opts, ok := ctx.Value(responseOptionsKey{}).(ResponseOptions); ok {
+		return opts
+	}
+	return DefaultResponseOptions
+}
@@ -0,0 +1,41 @@
+package loghttp
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResponseOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		accept   string
+		query    string
+		expected ResponseOptions
+		err      bool
+	}{
+		{"default", "", "", DefaultResponseOptions, false},
+		{"browser", "text/html,application/xhtml+xml,*/*;q=0.8", "", DefaultResponseOptions, false},
+		{"protobuf", "application/x-protobuf", "", ResponseOptions{Format: ResponseFormatProtobuf}, false},
+		{"first supported format", "text/plain, application/vnd.grafana.loki.compact+json;q=0.9, application/json", "", ResponseOptions{Format: ResponseFormatCompactJSON}, false},
+		{"no optional field", "", "fields=", ResponseOptions{Format: ResponseFormatJSON, OmitStats: true, OmitLabels: true}, false},
+		{"labels only", "", "fields=labels", ResponseOptions{Format: ResponseFormatJSON, OmitStats: true}, false},
+		{"all fields", "", "fields=labels,stats", DefaultResponseOptions, false},
+		{"unknown field", "", "fields=labels,lines", ResponseOptions{}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/loki/api/v1/query_range?query={app=\"foo\"}&"+tc.query, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			opts, err := ParseResponseOptions(req)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, opts)
+		})
+	}
+}
@@ -98,6 +98,12 @@ func parseInt(value string, def int) (int, error) {
 	return strconv.Atoi(value)
 }
 
+// ParseTimestamp parses a timestamp query parameter, either a unix timestamp in seconds or nanoseconds, or a RFC3339Nano date.
+// If the value is empty it returns the default value passed as second parameter.
+func ParseTimestamp(value string, def time.Time) (time.Time, error) {
+	return parseTimestamp(value, def)
+}
+
 // parseUnixNano parses a ns unix timestamp from a string
 // if the value is empty it returns a default value passed as second parameter
 func parseTimestamp(value string, def time.Time) (time.Time, error) {
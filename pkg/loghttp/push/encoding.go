@@ -0,0 +1,171 @@
+package push
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content-Encodings of the push request bodies.
+const (
+	encodingIdentity     = ""
+	encodingSnappy       = "snappy"
+	encodingSnappyFramed = "x-snappy-framed"
+	encodingGzip         = "gzip"
+	encodingZstd         = "zstd"
+)
+
+// zstdMaxWindow is the default max window size of the zstd decoder.
+const zstdMaxWindow = 512 << 20
+
+// SupportedEncodings is the list of the supported Content-Encodings of push request bodies,
+// in the format of the Accept-Encoding header.
+var SupportedEncodings = strings.Join([]string{encodingSnappy, encodingSnappyFramed, encodingGzip, encodingZstd}, ", ")
+
+// UnsupportedEncodingError is returned for push requests whose body has an unsupported Content-Encoding.
+type UnsupportedEncodingError struct {
+	Encoding string
+}
+
+func (e *UnsupportedEncodingError) Error() string {
+	return fmt.Sprintf("Content-Encoding %q not supported, supported encodings: %s", e.Encoding, SupportedEncodings)
+}
+
+// BodyLimits are the max sizes of push request bodies once decoded, by Content-Encoding.
+// 0 means no limit.
+type BodyLimits struct {
+	MaxUncompressedSize int `yaml:"max_uncompressed_size"`
+	MaxSnappySize       int `yaml:"max_snappy_size"`
+	MaxSnappyFramedSize int `yaml:"max_snappy_framed_size"`
+	MaxGzipSize         int `yaml:"max_gzip_size"`
+	MaxZstdSize         int `yaml:"max_zstd_size"`
+}
+
+// RegisterFlagsWithPrefix registers the flags of the body limits with the given prefix.
+func (l *BodyLimits) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.IntVar(&l.MaxUncompressedSize, prefix+"max-uncompressed-body-size", 0, "Maximum size in bytes of uncompressed JSON push request bodies. 0 means no limit.")
+	f.IntVar(&l.MaxSnappySize, prefix+"max-snappy-body-size", 0, "Maximum size in bytes of snappy compressed push request bodies once decompressed, including protobuf bodies without Content-Encoding. 0 means no limit.")
+	f.IntVar(&l.MaxSnappyFramedSize, prefix+"max-snappy-framed-body-size", 0, "Maximum size in bytes of x-snappy-framed encoded push request bodies once decompressed. 0 means no limit.")
+	f.IntVar(&l.MaxGzipSize, prefix+"max-gzip-body-size", 0, "Maximum size in bytes of gzip encoded push request bodies once decompressed. 0 means no limit.")
+	f.IntVar(&l.MaxZstdSize, prefix+"max-zstd-body-size", 0, "Maximum size in bytes of zstd encoded push request bodies once decompressed. 0 means no limit.")
+}
+
+// maxSize returns the max decoded size of a body with the given encoding and content type.
+// Protobuf bodies are always snappy compressed when they have no Content-Encoding.
+func (l BodyLimits) maxSize(encoding string, json bool) int {
+	var max int
+	switch encoding {
+	case encodingIdentity:
+		max = l.MaxSnappySize
+		if json {
+			max = l.MaxUncompressedSize
+		}
+	case encodingSnappy:
+		max = l.MaxSnappySize
+	case encodingSnappyFramed:
+		max = l.MaxSnappyFramedSize
+	case encodingGzip:
+		max = l.MaxGzipSize
+	case encodingZstd:
+		max = l.MaxZstdSize
+	}
+	if max <= 0 {
+		return math.MaxInt32
+	}
+	return max
+}
+
+// decodedBody is a push request body with its Content-Encoding removed.
+type decodedBody struct {
+	io.Reader
+	// protoCompression is the compression of protobuf bodies once decoded. Protobuf bodies are
+	// snappy compressed within the identity, snappy and gzip encodings, for backward compatibility.
+	protoCompression util.CompressionType
+	// rawSnappy is true when JSON bodies must still be snappy decoded.
+	rawSnappy bool
+	close     func() error
+}
+
+func (b *decodedBody) Close() error {
+	if b.close == nil {
+		return nil
+	}
+	return b.close()
+}
+
+// decodeBody removes the Content-Encoding of a push request body. maxSize is the max decoded size
+// of the body, it bounds the memory the decoders allocate up front.
+func decodeBody(encoding string, body io.Reader, maxSize int) (*decodedBody, error) {
+	switch encoding {
+	case encodingIdentity:
+		return &decodedBody{Reader: body, protoCompression: util.RawSnappy}, nil
+	case encodingSnappy:
+		// Snappy-decoding is done by `util.ParseProtoReader(..., util.RawSnappy)` for protobuf bodies.
+		// Note: HTTP clients do not need to set this header, but they sometimes do. See #3407.
+		return &decodedBody{Reader: body, protoCompression: util.RawSnappy, rawSnappy: true}, nil
+	case encodingSnappyFramed:
+		return &decodedBody{Reader: snappy.NewReader(body), protoCompression: util.NoCompression}, nil
+	case encodingGzip:
+		r, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		return &decodedBody{Reader: r, protoCompression: util.RawSnappy, close: r.Close}, nil
+	case encodingZstd:
+		// The window declared by the frame header is allocated before anything is decoded,
+		// a window larger than the max decoded size is never needed.
+		window := uint64(maxSize)
+		if window < zstd.MinWindowSize {
+			window = zstd.MinWindowSize
+		}
+		if window > zstdMaxWindow {
+			window = zstdMaxWindow
+		}
+		r, err := zstd.NewReader(body,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderLowmem(true),
+			zstd.WithDecoderMaxWindow(window),
+			zstd.WithDecoderMaxMemory(uint64(maxSize)),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return &decodedBody{Reader: r, protoCompression: util.NoCompression, close: func() error {
+			r.Close()
+			return nil
+		}}, nil
+	default:
+		return nil, &UnsupportedEncodingError{Encoding: encoding}
+	}
+}
+
+// maxSizeReader fails once more than max bytes are read.
+type maxSizeReader struct {
+	r   io.Reader
+	n   int
+	max int
+}
+
+func newMaxSizeReader(r io.Reader, max int) *maxSizeReader {
+	return &maxSizeReader{r: io.LimitReader(r, int64(max)+1), max: max}
+}
+
+func (r *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	if r.n > r.max {
+		return n, bodyTooLargeError(r.max)
+	}
+	return n, err
+}
+
+func bodyTooLargeError(max int) error {
+	return fmt.Errorf("push request body larger than the max size of %d bytes", max)
+}
@@ -1,7 +1,7 @@
 package push
 
 import (
-	"compress/gzip"
+	"bytes"
 	"fmt"
 	"io"
 	"math"
@@ -13,6 +13,7 @@ import (
 	"github.com/dustin/go-humanize"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/golang/snappy"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/prometheus/pkg/labels"
@@ -46,30 +47,18 @@ type TenantsRetention interface {
 	RetentionPeriodFor(userID string, lbs labels.Labels) time.Duration
 }
 
-func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRetention TenantsRetention) (*logproto.PushRequest, error) {
-	// Body
-	var body io.Reader
+// ParseRequest parses the body of a push request, JSON or protobuf, with any of the SupportedEncodings.
+// The bodies larger than the limits of their encoding once decoded are rejected.
+func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRetention TenantsRetention, limits BodyLimits) (*logproto.PushRequest, error) {
 	// bodySize should always reflect the compressed size of the request body
 	bodySize := loki_util.NewSizeReader(r.Body)
 	contentEncoding := r.Header.Get(contentEnc)
-	switch contentEncoding {
-	case "":
-		body = bodySize
-	case "snappy":
-		// Snappy-decoding is done by `util.ParseProtoReader(..., util.RawSnappy)` below.
-		// Pass on body bytes. Note: HTTP clients do not need to set this header,
-		// but they sometimes do. See #3407.
-		body = bodySize
-	case "gzip":
-		gzipReader, err := gzip.NewReader(bodySize)
-		if err != nil {
-			return nil, err
-		}
-		defer gzipReader.Close()
-		body = gzipReader
-	default:
-		return nil, fmt.Errorf("Content-Encoding %q not supported", contentEncoding)
+	// The limits of JSON and protobuf bodies only differ without Content-Encoding, when there's nothing to decode.
+	body, err := decodeBody(contentEncoding, bodySize, limits.maxSize(contentEncoding, false))
+	if err != nil {
+		return nil, err
 	}
+	defer body.Close()
 
 	contentType := r.Header.Get(contentType)
 	var (
@@ -79,22 +68,27 @@ func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRete
 		req              logproto.PushRequest
 	)
 
-	contentType, _ /* params */, err := mime.ParseMediaType(contentType)
+	contentType, _ /* params */, err = mime.ParseMediaType(contentType)
 	if err != nil {
 		return nil, err
 	}
 
 	switch contentType {
 	case applicationJSON:
-
-		var err error
+		maxSize := limits.maxSize(contentEncoding, true)
+		var reader io.Reader = newMaxSizeReader(body, maxSize)
+		if body.rawSnappy {
+			if reader, err = decodeSnappy(body, maxSize); err != nil {
+				return nil, err
+			}
+		}
 
 		// todo once https://github.com/weaveworks/common/commit/73225442af7da93ec8f6a6e2f7c8aafaee3f8840 is in Loki.
 		// We can try to pass the body as bytes.buffer instead to avoid reading into another buffer.
 		if loghttp.GetVersion(r.RequestURI) == loghttp.VersionV1 {
-			err = unmarshal.DecodePushRequest(body, &req)
+			err = unmarshal.DecodePushRequest(reader, &req)
 		} else {
-			err = unmarshal2.DecodePushRequest(body, &req)
+			err = unmarshal2.DecodePushRequest(reader, &req)
 		}
 
 		if err != nil {
@@ -103,8 +97,13 @@ func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRete
 
 	default:
 		// When no content-type header is set or when it is set to
-		// `application/x-protobuf`: expect snappy compression.
-		if err := util.ParseProtoReader(r.Context(), body, int(r.ContentLength), math.MaxInt32, &req, util.RawSnappy); err != nil {
+		// `application/x-protobuf`: expect snappy compression, unless the
+		// Content-Encoding already compresses the body.
+		expectedSize := int(r.ContentLength)
+		if body.protoCompression == util.NoCompression {
+			expectedSize = 0
+		}
+		if err := util.ParseProtoReader(r.Context(), body, expectedSize, limits.maxSize(contentEncoding, false), &req, body.protoCompression); err != nil {
 			return nil, err
 		}
 	}
@@ -151,3 +150,23 @@ func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRete
 	)
 	return &req, nil
 }
+
+// decodeSnappy reads and decodes a raw snappy compressed body, rejecting it if it is larger than maxSize once decoded.
+func decodeSnappy(body io.Reader, maxSize int) (io.Reader, error) {
+	compressed, err := io.ReadAll(newMaxSizeReader(body, snappy.MaxEncodedLen(maxSize)))
+	if err != nil {
+		return nil, err
+	}
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxSize {
+		return nil, bodyTooLargeError(maxSize)
+	}
+	decoded, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(decoded), nil
+}
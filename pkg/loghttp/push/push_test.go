@@ -9,7 +9,12 @@ import (
 	"testing"
 
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logproto"
 )
 
 // GZip source string and return compressed string
@@ -104,7 +109,7 @@ func TestParseRequest(t *testing.T) {
 		if len(test.contentEncoding) > 0 {
 			request.Header.Add("Content-Encoding", test.contentEncoding)
 		}
-		data, err := ParseRequest(util_log.Logger, "", request, nil)
+		data, err := ParseRequest(util_log.Logger, "", request, nil, BodyLimits{})
 		if test.valid {
 			assert.Nil(t, err, "Should not give error for %d", index)
 			assert.NotNil(t, data, "Should give data for %d", index)
@@ -114,3 +119,90 @@ func TestParseRequest(t *testing.T) {
 		}
 	}
 }
+
+func TestParseRequest_Encodings(t *testing.T) {
+	const body = `{"streams": [{ "stream": { "foo": "bar" }, "values": [ [ "1570818238000000000", "fizzbuzz" ] ] }]}`
+	pushReq := logproto.PushRequest{Streams: []logproto.Stream{{Labels: `{foo="bar"}`, Entries: []logproto.Entry{{Line: "fizzbuzz"}}}}}
+	protoBody, err := pushReq.Marshal()
+	require.NoError(t, err)
+
+	snappyFramed := func(b []byte) string {
+		var buf bytes.Buffer
+		w := snappy.NewBufferedWriter(&buf)
+		_, err := w.Write(b)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return buf.String()
+	}
+	zstdString := func(b []byte) string {
+		enc, err := zstd.NewWriter(nil)
+		require.NoError(t, err)
+		defer enc.Close()
+		return string(enc.EncodeAll(b, nil))
+	}
+
+	for _, tt := range []struct {
+		name            string
+		body            string
+		contentType     string
+		contentEncoding string
+		limits          BodyLimits
+		wantErr         bool
+	}{
+		{name: "uncompressed JSON", body: body, contentType: "application/json"},
+		{name: "uncompressed JSON over the limit", body: body, contentType: "application/json", limits: BodyLimits{MaxUncompressedSize: 10}, wantErr: true},
+		{name: "snappy JSON", body: string(snappy.Encode(nil, []byte(body))), contentType: "application/json", contentEncoding: "snappy"},
+		{name: "snappy JSON over the limit", body: string(snappy.Encode(nil, []byte(body))), contentType: "application/json", contentEncoding: "snappy", limits: BodyLimits{MaxSnappySize: 10}, wantErr: true},
+		{name: "snappy framed JSON", body: snappyFramed([]byte(body)), contentType: "application/json", contentEncoding: "x-snappy-framed"},
+		{name: "snappy framed JSON over the limit", body: snappyFramed([]byte(body)), contentType: "application/json", contentEncoding: "x-snappy-framed", limits: BodyLimits{MaxSnappyFramedSize: 10}, wantErr: true},
+		{name: "gzip JSON", body: gzipString(body), contentType: "application/json", contentEncoding: "gzip"},
+		{name: "gzip JSON over the limit", body: gzipString(body), contentType: "application/json", contentEncoding: "gzip", limits: BodyLimits{MaxGzipSize: 10}, wantErr: true},
+		{name: "zstd JSON", body: zstdString([]byte(body)), contentType: "application/json", contentEncoding: "zstd"},
+		{name: "zstd JSON over the limit", body: zstdString([]byte(body)), contentType: "application/json", contentEncoding: "zstd", limits: BodyLimits{MaxZstdSize: 10}, wantErr: true},
+		{name: "snappy protobuf", body: string(snappy.Encode(nil, protoBody))},
+		{name: "snappy protobuf over the limit", body: string(snappy.Encode(nil, protoBody)), limits: BodyLimits{MaxSnappySize: 10}, wantErr: true},
+		{name: "snappy framed protobuf", body: snappyFramed(protoBody), contentEncoding: "x-snappy-framed"},
+		{name: "zstd protobuf", body: zstdString(protoBody), contentEncoding: "zstd"},
+		{name: "gzip snappy protobuf", body: gzipString(string(snappy.Encode(nil, protoBody))), contentEncoding: "gzip"},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest("POST", "/loki/api/v1/push", strings.NewReader(tt.body))
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/x-protobuf"
+			}
+			request.Header.Add("Content-Type", contentType)
+			if tt.contentEncoding != "" {
+				request.Header.Add("Content-Encoding", tt.contentEncoding)
+			}
+			data, err := ParseRequest(util_log.Logger, "", request, nil, tt.limits)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, data.Streams, 1)
+			require.Equal(t, `{foo="bar"}`, data.Streams[0].Labels)
+			require.Equal(t, "fizzbuzz", data.Streams[0].Entries[0].Line)
+		})
+	}
+}
+
+func TestParseRequest_ZstdWindowLargerThanLimit(t *testing.T) {
+	// A zstd frame declaring a 512MB window (window descriptor 0x98) with a single 1 byte raw block.
+	body := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x98, 0x09, 0x00, 0x00, 0x00}
+	request := httptest.NewRequest("POST", "/loki/api/v1/push", bytes.NewReader(body))
+	request.Header.Add("Content-Type", "application/x-protobuf")
+	request.Header.Add("Content-Encoding", "zstd")
+
+	_, err := ParseRequest(util_log.Logger, "", request, nil, BodyLimits{MaxZstdSize: 1 << 20})
+	require.ErrorIs(t, err, zstd.ErrWindowSizeExceeded)
+}
+
+func TestParseRequest_UnsupportedEncoding(t *testing.T) {
+	request := httptest.NewRequest("POST", "/loki/api/v1/push", strings.NewReader("body"))
+	request.Header.Add("Content-Encoding", "br")
+	_, err := ParseRequest(util_log.Logger, "", request, nil, BodyLimits{})
+	require.IsType(t, &UnsupportedEncodingError{}, err)
+}
@@ -530,8 +530,9 @@ func (m *StreamAdapter) GetEntries() []EntryAdapter {
 }
 
 type EntryAdapter struct {
-	Timestamp time.Time `protobuf:"bytes,1,opt,name=timestamp,proto3,stdtime" json:"ts"`
-	Line      string    `protobuf:"bytes,2,opt,name=line,proto3" json:"line"`
+	Timestamp          time.Time   `protobuf:"bytes,1,opt,name=timestamp,proto3,stdtime" json:"ts"`
+	Line               string      `protobuf:"bytes,2,opt,name=line,proto3" json:"line"`
+	StructuredMetadata []LabelPair `protobuf:"bytes,3,rep,name=structuredMetadata,proto3" json:"structuredMetadata,omitempty"`
 }
 
 func (m *EntryAdapter) Reset()      { *m = EntryAdapter{} }
@@ -580,6 +581,13 @@ func (m *EntryAdapter) GetLine() string {
 	return ""
 }
 
+func (m *EntryAdapter) GetStructuredMetadata() []LabelPair {
+	if m != nil {
+		return m.StructuredMetadata
+	}
+	return nil
+}
+
 type Sample struct {
 	Timestamp int64   `protobuf:"varint,1,opt,name=timestamp,proto3" json:"ts"`
 	Value     float64 `protobuf:"fixed64,2,opt,name=value,proto3" json:"value"`
@@ -1423,95 +1431,97 @@ func init() {
 func init() { proto.RegisterFile("pkg/logproto/logproto.proto", fileDescriptor_c28a5f14f1f4c79a) }
 
 var fileDescriptor_c28a5f14f1f4c79a = []byte{
-	// 1404 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xc4, 0x57, 0x49, 0x8f, 0x13, 0x47,
-	0x14, 0x76, 0x79, 0xe9, 0xb1, 0x9f, 0x17, 0xac, 0x9a, 0x61, 0xc6, 0x31, 0xd0, 0xb6, 0x4a, 0x08,
-	0xac, 0x40, 0xec, 0x30, 0xd9, 0x58, 0xb2, 0x68, 0xcc, 0x84, 0x30, 0x04, 0x05, 0x68, 0x90, 0x90,
-	0x90, 0x22, 0xd4, 0x63, 0xd7, 0xd8, 0xad, 0xb1, 0xdd, 0xa6, 0xab, 0x8c, 0x34, 0x52, 0xa4, 0xe4,
-	0x07, 0x24, 0x12, 0xb7, 0x1c, 0x72, 0xcd, 0x21, 0xca, 0x21, 0xbf, 0x83, 0xdc, 0x50, 0x4e, 0x28,
-	0x07, 0x27, 0x98, 0x4b, 0x34, 0xca, 0x81, 0x9f, 0x10, 0xd5, 0xd2, 0xed, 0xb2, 0x99, 0x49, 0x30,
-	0x97, 0x5c, 0xda, 0xf5, 0xaa, 0x5e, 0xbd, 0xe5, 0x7b, 0x5f, 0xbd, 0x2a, 0xc3, 0xb1, 0xe1, 0x6e,
-	0xa7, 0xd1, 0xf3, 0x3b, 0xc3, 0xc0, 0xe7, 0x7e, 0x34, 0xa8, 0xcb, 0x2f, 0x4e, 0x87, 0x72, 0xb9,
-	0xd2, 0xf1, 0xfd, 0x4e, 0x8f, 0x36, 0xa4, 0xb4, 0x3d, 0xda, 0x69, 0x70, 0xaf, 0x4f, 0x19, 0x77,
-	0xfb, 0x43, 0xa5, 0x5a, 0x7e, 0xab, 0xe3, 0xf1, 0xee, 0x68, 0xbb, 0xde, 0xf2, 0xfb, 0x8d, 0x8e,
-	0xdf, 0xf1, 0xa7, 0x9a, 0x42, 0x52, 0xd6, 0xc5, 0x48, 0xab, 0x57, 0xb5, 0xdb, 0x07, 0xbd, 0xbe,
-	0xdf, 0xa6, 0xbd, 0x06, 0xe3, 0x2e, 0x67, 0xea, 0xab, 0x34, 0xc8, 0x5d, 0xc8, 0xde, 0x1c, 0xb1,
-	0xae, 0x43, 0x1f, 0x8c, 0x28, 0xe3, 0xf8, 0x2a, 0x2c, 0x31, 0x1e, 0x50, 0xb7, 0xcf, 0x4a, 0xa8,
-	0x9a, 0xa8, 0x65, 0xd7, 0xd7, 0xea, 0x51, 0xb0, 0xb7, 0xe5, 0xc2, 0x46, 0xdb, 0x1d, 0x72, 0x1a,
-	0x34, 0x8f, 0xfe, 0x3e, 0xae, 0x58, 0x6a, 0x6a, 0x7f, 0x5c, 0x09, 0x77, 0x39, 0xe1, 0x80, 0x14,
-	0x20, 0xa7, 0x0c, 0xb3, 0xa1, 0x3f, 0x60, 0x94, 0xfc, 0x10, 0x87, 0xdc, 0xad, 0x11, 0x0d, 0xf6,
-	0x42, 0x57, 0x65, 0x48, 0x33, 0xda, 0xa3, 0x2d, 0xee, 0x07, 0x25, 0x54, 0x45, 0xb5, 0x8c, 0x13,
-	0xc9, 0x78, 0x05, 0x52, 0x3d, 0xaf, 0xef, 0xf1, 0x52, 0xbc, 0x8a, 0x6a, 0x79, 0x47, 0x09, 0xf8,
-	0x22, 0xa4, 0x18, 0x77, 0x03, 0x5e, 0x4a, 0x54, 0x51, 0x2d, 0xbb, 0x5e, 0xae, 0x2b, 0xb4, 0xea,
-	0x21, 0x06, 0xf5, 0x3b, 0x21, 0x5a, 0xcd, 0xf4, 0xe3, 0x71, 0x25, 0xf6, 0xe8, 0x8f, 0x0a, 0x72,
-	0xd4, 0x16, 0xfc, 0x3e, 0x24, 0xe8, 0xa0, 0x5d, 0x4a, 0x2e, 0xb0, 0x53, 0x6c, 0xc0, 0xe7, 0x20,
-	0xd3, 0xf6, 0x02, 0xda, 0xe2, 0x9e, 0x3f, 0x28, 0xa5, 0xaa, 0xa8, 0x56, 0x58, 0x5f, 0x9e, 0x42,
-	0xb2, 0x19, 0x2e, 0x39, 0x53, 0x2d, 0x7c, 0x16, 0x2c, 0xd6, 0x75, 0x83, 0x36, 0x2b, 0x2d, 0x55,
-	0x13, 0xb5, 0x4c, 0x73, 0x65, 0x7f, 0x5c, 0x29, 0xaa, 0x99, 0xb3, 0x7e, 0xdf, 0xe3, 0xb4, 0x3f,
-	0xe4, 0x7b, 0x8e, 0xd6, 0xb9, 0x96, 0x4c, 0x5b, 0xc5, 0x25, 0xf2, 0x1b, 0x02, 0x7c, 0xdb, 0xed,
-	0x0f, 0x7b, 0xf4, 0x95, 0x31, 0x8a, 0xd0, 0x88, 0xbf, 0x36, 0x1a, 0x89, 0x45, 0xd1, 0x98, 0xa6,
-	0x96, 0xfc, 0xef, 0xd4, 0xc8, 0xd7, 0x90, 0xd7, 0xd9, 0x28, 0x0e, 0xe0, 0x8d, 0x57, 0x66, 0x57,
-	0xe1, 0xf1, 0xb8, 0x82, 0xa6, 0x0c, 0x8b, 0x68, 0x85, 0xcf, 0xc8, 0xac, 0x39, 0xd3, 0x59, 0x1f,
-	0xa9, 0x2b, 0x32, 0x6f, 0x0d, 0x3a, 0x94, 0x89, 0x8d, 0x49, 0x11, 0xb0, 0xa3, 0x74, 0xc8, 0x57,
-	0xb0, 0x3c, 0x03, 0xaa, 0x0e, 0xe3, 0x3c, 0x58, 0x8c, 0x06, 0x1e, 0x0d, 0xa3, 0x28, 0x1a, 0x51,
-	0xc8, 0x79, 0xc3, 0xbd, 0x94, 0x1d, 0xad, 0xbf, 0x98, 0xf7, 0x5f, 0x10, 0xe4, 0xae, 0xbb, 0xdb,
-	0xb4, 0x17, 0x56, 0x13, 0x43, 0x72, 0xe0, 0xf6, 0xa9, 0xae, 0xa4, 0x1c, 0xe3, 0x55, 0xb0, 0x1e,
-	0xba, 0xbd, 0x11, 0x55, 0x26, 0xd3, 0x8e, 0x96, 0x16, 0xe5, 0x3a, 0x7a, 0x6d, 0xae, 0xa3, 0xa8,
-	0xba, 0xe4, 0x34, 0xe4, 0x75, 0xbc, 0x1a, 0xa8, 0x69, 0x70, 0x02, 0xa8, 0x4c, 0x18, 0x1c, 0x79,
-	0x08, 0xf9, 0x99, 0x72, 0x61, 0x02, 0x56, 0x4f, 0xec, 0x64, 0x2a, 0xb7, 0x26, 0xec, 0x8f, 0x2b,
-	0x7a, 0xc6, 0xd1, 0xbf, 0xa2, 0xf8, 0x74, 0xc0, 0x25, 0xec, 0x71, 0x09, 0xfb, 0xea, 0x14, 0xf6,
-	0x4f, 0x07, 0x3c, 0xd8, 0x0b, 0x6b, 0x7f, 0x44, 0x80, 0x28, 0x7a, 0x8a, 0x56, 0x77, 0xc2, 0x01,
-	0x79, 0x08, 0x39, 0x53, 0x13, 0x5f, 0x85, 0x4c, 0xd4, 0x20, 0xa5, 0xe7, 0x7f, 0x4f, 0xb7, 0xa0,
-	0x0d, 0xc7, 0x39, 0x93, 0x49, 0x4f, 0x37, 0xe3, 0xe3, 0x90, 0xec, 0x79, 0x03, 0x2a, 0x8b, 0x90,
-	0x69, 0xa6, 0xf7, 0xc7, 0x15, 0x29, 0x3b, 0xf2, 0x4b, 0xfa, 0x60, 0x29, 0x1e, 0xe1, 0x93, 0xf3,
-	0x1e, 0x13, 0x4d, 0x4b, 0x59, 0x34, 0xad, 0x55, 0x20, 0x25, 0x91, 0x92, 0xe6, 0x50, 0x33, 0xb3,
-	0x3f, 0xae, 0xa8, 0x09, 0x47, 0xfd, 0x08, 0x77, 0x5d, 0x97, 0x75, 0x65, 0x71, 0x93, 0xca, 0x9d,
-	0x90, 0x1d, 0xf9, 0x25, 0x1e, 0x68, 0xde, 0xbd, 0x12, 0xae, 0x97, 0x60, 0x89, 0xc9, 0xe0, 0x42,
-	0x5c, 0x4d, 0x3a, 0xcb, 0x85, 0x29, 0xa2, 0x5a, 0xd1, 0x09, 0x07, 0xe4, 0x7b, 0x04, 0xd9, 0x3b,
-	0xae, 0x17, 0x51, 0x74, 0x05, 0x52, 0x0f, 0xc4, 0x59, 0xd1, 0x1c, 0x55, 0x82, 0x68, 0x43, 0x6d,
-	0xda, 0x73, 0xf7, 0xae, 0xf8, 0x81, 0x0c, 0x39, 0xef, 0x44, 0xf2, 0xb4, 0x55, 0x27, 0x0f, 0x6c,
-	0xd5, 0xa9, 0x85, 0x9b, 0xd3, 0xb5, 0x64, 0x3a, 0x5e, 0x4c, 0x90, 0x6f, 0x11, 0xe4, 0x54, 0x64,
-	0x9a, 0x8c, 0x97, 0xc0, 0x52, 0x4d, 0x40, 0x57, 0xfa, 0xd0, 0xde, 0x01, 0x46, 0xdf, 0xd0, 0x5b,
-	0xf0, 0x27, 0x50, 0x68, 0x07, 0xfe, 0x70, 0x48, 0xdb, 0xb7, 0x75, 0x03, 0x8a, 0xcf, 0x37, 0xa0,
-	0x4d, 0x73, 0xdd, 0x99, 0x53, 0x27, 0xbf, 0x22, 0xc8, 0xeb, 0x66, 0xa0, 0xa1, 0x8a, 0x52, 0x44,
-	0xaf, 0xdd, 0x7f, 0xe3, 0x8b, 0xf6, 0xdf, 0x55, 0xb0, 0x3a, 0x81, 0x3f, 0x1a, 0xb2, 0x52, 0x42,
-	0x1d, 0x48, 0x25, 0x2d, 0xd8, 0x97, 0xaf, 0x41, 0x21, 0x4c, 0xe5, 0x90, 0x8e, 0x58, 0x9e, 0xef,
-	0x88, 0x5b, 0x6d, 0x3a, 0xe0, 0xde, 0x8e, 0x17, 0xf5, 0x38, 0xad, 0x4f, 0xbe, 0x43, 0x50, 0x9c,
-	0x57, 0xc1, 0x1f, 0x1b, 0xb4, 0x15, 0xe6, 0x4e, 0x1d, 0x6e, 0xae, 0x2e, 0x3b, 0x0e, 0x93, 0xc7,
-	0x3a, 0xa4, 0x74, 0xf9, 0x02, 0x64, 0x8d, 0x69, 0x5c, 0x84, 0xc4, 0x2e, 0x0d, 0x29, 0x29, 0x86,
-	0x82, 0x74, 0xd3, 0x03, 0x96, 0xd1, 0xa7, 0xea, 0x62, 0xfc, 0x3c, 0x12, 0x84, 0xce, 0xcf, 0x54,
-	0x12, 0x9f, 0x87, 0xe4, 0x4e, 0xe0, 0xf7, 0x17, 0x2a, 0x93, 0xdc, 0x81, 0xdf, 0x85, 0x38, 0xf7,
-	0x17, 0x2a, 0x52, 0x9c, 0xfb, 0xa2, 0x46, 0x3a, 0xf9, 0x84, 0x0c, 0x4e, 0x4b, 0xe4, 0x67, 0x04,
-	0x47, 0xc4, 0x1e, 0x85, 0xc0, 0xe5, 0xee, 0x68, 0xb0, 0x8b, 0x6b, 0x50, 0x14, 0x9e, 0xee, 0x7b,
-	0xfa, 0x02, 0xb9, 0xef, 0xb5, 0x75, 0x9a, 0x05, 0x31, 0x1f, 0xde, 0x2b, 0x5b, 0x6d, 0xbc, 0x06,
-	0x4b, 0x23, 0xa6, 0x14, 0x54, 0xce, 0x96, 0x10, 0xb7, 0xda, 0xf8, 0x8c, 0xe1, 0x4e, 0x60, 0x6d,
-	0xbc, 0x4e, 0x24, 0x86, 0x37, 0x5d, 0x2f, 0x88, 0x7a, 0xc5, 0x69, 0xb0, 0x5a, 0xc2, 0xb1, 0xe2,
-	0x89, 0xb8, 0xc0, 0x22, 0x65, 0x19, 0x90, 0xa3, 0x97, 0xc9, 0x7b, 0x90, 0x89, 0x76, 0x1f, 0x78,
-	0x6f, 0x1d, 0x58, 0x01, 0x72, 0x0c, 0x52, 0x2a, 0x31, 0x0c, 0xc9, 0xb6, 0xcb, 0x5d, 0xb9, 0x25,
-	0xe7, 0xc8, 0x31, 0x29, 0xc1, 0xea, 0x9d, 0xc0, 0x1d, 0xb0, 0x1d, 0x1a, 0x48, 0xa5, 0x88, 0x7e,
-	0xe4, 0x28, 0x2c, 0x8b, 0xa3, 0x4e, 0x03, 0x76, 0xd9, 0x1f, 0x0d, 0xb8, 0x3e, 0x61, 0xe4, 0x2c,
-	0xac, 0xcc, 0x4e, 0x6b, 0xb6, 0xae, 0x40, 0xaa, 0x25, 0x26, 0xa4, 0xf5, 0xbc, 0xa3, 0x04, 0xf2,
-	0x23, 0x02, 0xfc, 0x19, 0xe5, 0xd2, 0xf4, 0xd6, 0x26, 0x33, 0x9e, 0x50, 0x7d, 0x97, 0xb7, 0xba,
-	0x34, 0x60, 0xe1, 0x13, 0x2a, 0x94, 0xff, 0x8f, 0x27, 0x14, 0x39, 0x07, 0xcb, 0x33, 0x51, 0xea,
-	0x9c, 0xca, 0x90, 0x6e, 0xe9, 0x39, 0x7d, 0xd9, 0x46, 0xf2, 0x9b, 0xa7, 0x20, 0x13, 0x3d, 0x34,
-	0x71, 0x16, 0x96, 0xae, 0xdc, 0x70, 0xee, 0x6e, 0x38, 0x9b, 0xc5, 0x18, 0xce, 0x41, 0xba, 0xb9,
-	0x71, 0xf9, 0x73, 0x29, 0xa1, 0xf5, 0x0d, 0xb0, 0xc4, 0x93, 0x9b, 0x06, 0xf8, 0x03, 0x48, 0x8a,
-	0x11, 0x3e, 0x3a, 0xad, 0xaf, 0xf1, 0xca, 0x2f, 0xaf, 0xce, 0x4f, 0xeb, 0x3a, 0xc4, 0xd6, 0xff,
-	0x4e, 0xc0, 0x92, 0x78, 0x2c, 0x89, 0x53, 0xfc, 0x21, 0xa4, 0xe4, 0xbb, 0x09, 0x1b, 0xea, 0xe6,
-	0xeb, 0xb4, 0xbc, 0xf6, 0xd2, 0x7c, 0x68, 0xe7, 0x6d, 0x84, 0xbf, 0x80, 0xac, 0x9c, 0xd4, 0x17,
-	0xe7, 0xf1, 0xf9, 0x4b, 0x69, 0xc6, 0xd2, 0x89, 0x43, 0x56, 0x0d, 0x7b, 0x17, 0x21, 0x25, 0x19,
-	0x69, 0x46, 0x63, 0xbe, 0xae, 0xcc, 0x68, 0x66, 0x5e, 0x31, 0x24, 0x86, 0x2f, 0x40, 0x52, 0x10,
-	0xc9, 0x84, 0xc3, 0xb8, 0xf4, 0x4c, 0x38, 0xcc, 0x1b, 0x47, 0xba, 0xfd, 0x28, 0xba, 0x8b, 0xd7,
-	0xe6, 0x9b, 0x58, 0xb8, 0xbd, 0xf4, 0xf2, 0x42, 0xe4, 0xf9, 0x86, 0xba, 0xc4, 0x42, 0x0a, 0xe3,
-	0x13, 0xb3, 0xae, 0xe6, 0x18, 0x5f, 0xb6, 0x0f, 0x5b, 0x8e, 0x0c, 0x5e, 0x87, 0xac, 0x41, 0x1f,
-	0x13, 0xd6, 0x97, 0xb9, 0x6f, 0xc2, 0x7a, 0x00, 0xe7, 0x48, 0x6c, 0xfd, 0x4b, 0x48, 0x87, 0x3d,
-	0x06, 0xdf, 0x82, 0xc2, 0xec, 0xf1, 0xc4, 0x6f, 0x18, 0xd1, 0xcc, 0x36, 0xae, 0x72, 0xd5, 0x58,
-	0x3a, 0xf8, 0x4c, 0xc7, 0x6a, 0xa8, 0x79, 0xef, 0xc9, 0x33, 0x3b, 0xf6, 0xf4, 0x99, 0x1d, 0x7b,
-	0xf1, 0xcc, 0x46, 0xdf, 0x4c, 0x6c, 0xf4, 0xd3, 0xc4, 0x46, 0x8f, 0x27, 0x36, 0x7a, 0x32, 0xb1,
-	0xd1, 0x9f, 0x13, 0x1b, 0xfd, 0x35, 0xb1, 0x63, 0x2f, 0x26, 0x36, 0x7a, 0xf4, 0xdc, 0x8e, 0x3d,
-	0x79, 0x6e, 0xc7, 0x9e, 0x3e, 0xb7, 0x63, 0xf7, 0x4e, 0x9a, 0xff, 0x71, 0x03, 0x77, 0xc7, 0x1d,
-	0xb8, 0x8d, 0x9e, 0xbf, 0xeb, 0x35, 0xcc, 0xff, 0xd0, 0xdb, 0x96, 0xfc, 0x79, 0xe7, 0x9f, 0x00,
-	0x00, 0x00, 0xff, 0xff, 0x7b, 0x53, 0xc1, 0x06, 0x5a, 0x0f, 0x00, 0x00,
+	// 1432 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xc4, 0x57, 0x4b, 0x8f, 0x13, 0xc7,
+	0x13, 0x77, 0xfb, 0x31, 0x6b, 0x97, 0x1f, 0x58, 0xbd, 0xcb, 0xae, 0xff, 0x03, 0x8c, 0xad, 0x11,
+	0x02, 0xeb, 0x0f, 0xf1, 0x86, 0xcd, 0x8b, 0x47, 0x1e, 0x5a, 0xb3, 0x21, 0x2c, 0x21, 0x01, 0x06,
+	0x24, 0x24, 0xa4, 0x08, 0xcd, 0x7a, 0x7a, 0xbd, 0xa3, 0xb5, 0x3d, 0x66, 0xba, 0x8d, 0xb4, 0x52,
+	0xa4, 0xe4, 0x03, 0x24, 0x12, 0xb7, 0x1c, 0x72, 0xcd, 0x21, 0xca, 0x21, 0x9f, 0x83, 0xdc, 0x50,
+	0x4e, 0x28, 0x07, 0x27, 0x6b, 0x2e, 0xd1, 0x2a, 0x07, 0x3e, 0x42, 0xd4, 0x8f, 0x19, 0xb7, 0xbd,
+	0x6b, 0x81, 0xb9, 0xe4, 0x32, 0xee, 0xaa, 0xae, 0xaa, 0xae, 0xc7, 0xaf, 0xaa, 0xdb, 0x70, 0xa2,
+	0xbf, 0xdb, 0x5e, 0xed, 0x04, 0xed, 0x7e, 0x18, 0xb0, 0x20, 0x5e, 0x34, 0xc4, 0x17, 0x67, 0x23,
+	0xda, 0xac, 0xb6, 0x83, 0xa0, 0xdd, 0x21, 0xab, 0x82, 0xda, 0x1a, 0x6c, 0xaf, 0x32, 0xbf, 0x4b,
+	0x28, 0x73, 0xbb, 0x7d, 0x29, 0x6a, 0xbe, 0xd5, 0xf6, 0xd9, 0xce, 0x60, 0xab, 0xd1, 0x0a, 0xba,
+	0xab, 0xed, 0xa0, 0x1d, 0x8c, 0x25, 0x39, 0x25, 0xad, 0xf3, 0x95, 0x12, 0xaf, 0xa9, 0x63, 0x1f,
+	0x75, 0xba, 0x81, 0x47, 0x3a, 0xab, 0x94, 0xb9, 0x8c, 0xca, 0xaf, 0x94, 0xb0, 0xef, 0x43, 0xfe,
+	0xf6, 0x80, 0xee, 0x38, 0xe4, 0xd1, 0x80, 0x50, 0x86, 0xaf, 0xc3, 0x02, 0x65, 0x21, 0x71, 0xbb,
+	0xb4, 0x82, 0x6a, 0xa9, 0x7a, 0x7e, 0x6d, 0xa5, 0x11, 0x3b, 0x7b, 0x57, 0x6c, 0xac, 0x7b, 0x6e,
+	0x9f, 0x91, 0xb0, 0x79, 0xfc, 0x8f, 0x61, 0xd5, 0x90, 0xac, 0x83, 0x61, 0x35, 0xd2, 0x72, 0xa2,
+	0x85, 0x5d, 0x82, 0x82, 0x34, 0x4c, 0xfb, 0x41, 0x8f, 0x12, 0xfb, 0xc7, 0x24, 0x14, 0xee, 0x0c,
+	0x48, 0xb8, 0x17, 0x1d, 0x65, 0x42, 0x96, 0x92, 0x0e, 0x69, 0xb1, 0x20, 0xac, 0xa0, 0x1a, 0xaa,
+	0xe7, 0x9c, 0x98, 0xc6, 0x4b, 0x90, 0xe9, 0xf8, 0x5d, 0x9f, 0x55, 0x92, 0x35, 0x54, 0x2f, 0x3a,
+	0x92, 0xc0, 0x97, 0x21, 0x43, 0x99, 0x1b, 0xb2, 0x4a, 0xaa, 0x86, 0xea, 0xf9, 0x35, 0xb3, 0x21,
+	0xb3, 0xd5, 0x88, 0x72, 0xd0, 0xb8, 0x17, 0x65, 0xab, 0x99, 0x7d, 0x3a, 0xac, 0x26, 0x9e, 0xfc,
+	0x59, 0x45, 0x8e, 0x54, 0xc1, 0xef, 0x43, 0x8a, 0xf4, 0xbc, 0x4a, 0x7a, 0x0e, 0x4d, 0xae, 0x80,
+	0x2f, 0x40, 0xce, 0xf3, 0x43, 0xd2, 0x62, 0x7e, 0xd0, 0xab, 0x64, 0x6a, 0xa8, 0x5e, 0x5a, 0x5b,
+	0x1c, 0xa7, 0x64, 0x23, 0xda, 0x72, 0xc6, 0x52, 0xf8, 0x3c, 0x18, 0x74, 0xc7, 0x0d, 0x3d, 0x5a,
+	0x59, 0xa8, 0xa5, 0xea, 0xb9, 0xe6, 0xd2, 0xc1, 0xb0, 0x5a, 0x96, 0x9c, 0xf3, 0x41, 0xd7, 0x67,
+	0xa4, 0xdb, 0x67, 0x7b, 0x8e, 0x92, 0xb9, 0x91, 0xce, 0x1a, 0xe5, 0x05, 0xfb, 0x77, 0x04, 0xf8,
+	0xae, 0xdb, 0xed, 0x77, 0xc8, 0x6b, 0xe7, 0x28, 0xce, 0x46, 0xf2, 0x8d, 0xb3, 0x91, 0x9a, 0x37,
+	0x1b, 0xe3, 0xd0, 0xd2, 0xaf, 0x0e, 0xcd, 0xfe, 0x06, 0x8a, 0x2a, 0x1a, 0x89, 0x01, 0xbc, 0xfe,
+	0xda, 0xe8, 0x2a, 0x3d, 0x1d, 0x56, 0xd1, 0x18, 0x61, 0x31, 0xac, 0xf0, 0x39, 0x11, 0x35, 0xa3,
+	0x2a, 0xea, 0x63, 0x0d, 0x41, 0x35, 0x36, 0x7b, 0x6d, 0x42, 0xb9, 0x62, 0x9a, 0x3b, 0xec, 0x48,
+	0x19, 0xfb, 0x6b, 0x58, 0x9c, 0x48, 0xaa, 0x72, 0xe3, 0x22, 0x18, 0x94, 0x84, 0x3e, 0x89, 0xbc,
+	0x28, 0x6b, 0x5e, 0x08, 0xbe, 0x76, 0xbc, 0xa0, 0x1d, 0x25, 0x3f, 0xdf, 0xe9, 0xbf, 0x22, 0x28,
+	0xdc, 0x74, 0xb7, 0x48, 0x27, 0xaa, 0x26, 0x86, 0x74, 0xcf, 0xed, 0x12, 0x55, 0x49, 0xb1, 0xc6,
+	0xcb, 0x60, 0x3c, 0x76, 0x3b, 0x03, 0x22, 0x4d, 0x66, 0x1d, 0x45, 0xcd, 0x8b, 0x75, 0xf4, 0xc6,
+	0x58, 0x47, 0x71, 0x75, 0xed, 0xb3, 0x50, 0x54, 0xfe, 0xaa, 0x44, 0x8d, 0x9d, 0xe3, 0x89, 0xca,
+	0x45, 0xce, 0xd9, 0x8f, 0xa1, 0x38, 0x51, 0x2e, 0x6c, 0x83, 0xd1, 0xe1, 0x9a, 0x54, 0xc6, 0xd6,
+	0x84, 0x83, 0x61, 0x55, 0x71, 0x1c, 0xf5, 0xcb, 0x8b, 0x4f, 0x7a, 0x4c, 0xa4, 0x3d, 0x29, 0xd2,
+	0xbe, 0x3c, 0x4e, 0xfb, 0xa7, 0x3d, 0x16, 0xee, 0x45, 0xb5, 0x3f, 0xc6, 0x93, 0xc8, 0x67, 0x8a,
+	0x12, 0x77, 0xa2, 0x85, 0xbd, 0x8f, 0xa0, 0xa0, 0x8b, 0xe2, 0xeb, 0x90, 0x8b, 0x27, 0x64, 0x05,
+	0xbd, 0x32, 0xde, 0x92, 0xb2, 0x9c, 0x64, 0x54, 0x44, 0x3d, 0x56, 0xc6, 0x27, 0x21, 0xdd, 0xf1,
+	0x7b, 0x44, 0x54, 0x21, 0xd7, 0xcc, 0x1e, 0x0c, 0xab, 0x82, 0x76, 0xc4, 0x17, 0xfb, 0x80, 0x29,
+	0x0b, 0x07, 0x2d, 0x36, 0x08, 0x89, 0xf7, 0x05, 0x61, 0xae, 0xe7, 0x32, 0xb7, 0x92, 0x12, 0x61,
+	0x68, 0xe3, 0x40, 0x64, 0xef, 0xb6, 0xeb, 0x87, 0xcd, 0xd3, 0xea, 0xa4, 0x93, 0x87, 0xd5, 0xb4,
+	0x46, 0x39, 0xc2, 0xa8, 0xdd, 0x05, 0x43, 0x62, 0x16, 0x9f, 0x9e, 0x0e, 0x2e, 0xd5, 0x34, 0xa4,
+	0xf3, 0xba, 0xe3, 0x55, 0xc8, 0x88, 0xaa, 0x08, 0xcf, 0x51, 0x33, 0x77, 0x30, 0xac, 0x4a, 0x86,
+	0x23, 0x7f, 0x78, 0x64, 0x3b, 0x2e, 0xdd, 0x11, 0x40, 0x4a, 0xcb, 0xc8, 0x38, 0xed, 0x88, 0xaf,
+	0xed, 0x83, 0xc2, 0xf8, 0x6b, 0xd5, 0xf0, 0x0a, 0x2c, 0x50, 0xe1, 0x5c, 0x54, 0x43, 0xbd, 0x75,
+	0xc4, 0xc6, 0xb8, 0x7a, 0x4a, 0xd0, 0x89, 0x16, 0xf6, 0x0f, 0x08, 0xf2, 0xf7, 0x5c, 0x3f, 0x6e,
+	0x87, 0x25, 0xc8, 0x3c, 0xe2, 0x7d, 0xa9, 0xfa, 0x41, 0x12, 0x7c, 0xe4, 0x79, 0xa4, 0xe3, 0xee,
+	0x5d, 0x0b, 0x42, 0xe1, 0x72, 0xd1, 0x89, 0xe9, 0xf1, 0xb5, 0x90, 0x3e, 0xf2, 0x5a, 0xc8, 0xcc,
+	0x3d, 0x08, 0x6f, 0xa4, 0xb3, 0xc9, 0x72, 0xca, 0xfe, 0x0e, 0x41, 0x41, 0x7a, 0xa6, 0x80, 0x7f,
+	0x05, 0x0c, 0x39, 0x70, 0x14, 0xa8, 0x66, 0xce, 0x29, 0xd0, 0x66, 0x94, 0x52, 0xc1, 0x9f, 0x40,
+	0xc9, 0x0b, 0x83, 0x7e, 0x9f, 0x78, 0x77, 0xd5, 0xb0, 0x4b, 0x4e, 0x0f, 0xbb, 0x0d, 0x7d, 0xdf,
+	0x99, 0x12, 0xb7, 0x7f, 0x43, 0x50, 0x54, 0x83, 0x47, 0xa5, 0x2a, 0x0e, 0x11, 0xbd, 0xf1, 0xac,
+	0x4f, 0xce, 0x3b, 0xeb, 0x97, 0xc1, 0x68, 0x87, 0xc1, 0xa0, 0x4f, 0x05, 0xce, 0x73, 0x8e, 0xa2,
+	0xe6, 0xbc, 0x03, 0x6e, 0x40, 0x29, 0x0a, 0x65, 0xc6, 0xf4, 0x35, 0xa7, 0xa7, 0xef, 0xa6, 0x47,
+	0x7a, 0xcc, 0xdf, 0xf6, 0xe3, 0x79, 0xaa, 0xe4, 0xed, 0xef, 0x11, 0x94, 0xa7, 0x45, 0xf0, 0xc7,
+	0x1a, 0x6c, 0xb9, 0xb9, 0x33, 0xb3, 0xcd, 0xc9, 0xfe, 0xa4, 0x62, 0x82, 0x44, 0x90, 0x36, 0x2f,
+	0x41, 0x5e, 0x63, 0xe3, 0x32, 0xa4, 0x76, 0x49, 0x04, 0x49, 0xbe, 0xe4, 0xa0, 0x1b, 0x37, 0x58,
+	0x4e, 0x75, 0xd5, 0xe5, 0xe4, 0x45, 0xc4, 0x01, 0x5d, 0x9c, 0xa8, 0x24, 0xbe, 0x08, 0xe9, 0xed,
+	0x30, 0xe8, 0xce, 0x55, 0x26, 0xa1, 0x81, 0xdf, 0x85, 0x24, 0x0b, 0xe6, 0x2a, 0x52, 0x92, 0x05,
+	0xbc, 0x46, 0x2a, 0xf8, 0x94, 0x70, 0x4e, 0x51, 0xf6, 0x2f, 0x08, 0x8e, 0x71, 0x1d, 0x99, 0x81,
+	0xab, 0x3b, 0x83, 0xde, 0x2e, 0xae, 0x43, 0x99, 0x9f, 0xf4, 0xd0, 0x57, 0x97, 0xd5, 0x43, 0xdf,
+	0x53, 0x61, 0x96, 0x38, 0x3f, 0xba, 0xc3, 0x36, 0x3d, 0xbc, 0x02, 0x0b, 0x03, 0x2a, 0x05, 0x64,
+	0xcc, 0x06, 0x27, 0x37, 0x3d, 0x7c, 0x4e, 0x3b, 0x6e, 0xd6, 0xe8, 0x8b, 0x67, 0xc5, 0x59, 0x30,
+	0x5a, 0xfc, 0x60, 0x89, 0x13, 0x7e, 0x59, 0xc6, 0xc2, 0xc2, 0x21, 0x47, 0x6d, 0xdb, 0xef, 0x41,
+	0x2e, 0xd6, 0x3e, 0xf2, 0x8e, 0x3c, 0xb2, 0x02, 0xf6, 0x09, 0xc8, 0xc8, 0xc0, 0x30, 0xa4, 0xc5,
+	0x38, 0xe6, 0x2a, 0x05, 0x47, 0xac, 0xed, 0x0a, 0x2c, 0xdf, 0x0b, 0xdd, 0x1e, 0xdd, 0x26, 0xa1,
+	0x10, 0x8a, 0xe1, 0x67, 0x1f, 0x87, 0x45, 0xde, 0xea, 0x24, 0xa4, 0x57, 0x83, 0x41, 0x8f, 0xa9,
+	0x0e, 0xb3, 0xcf, 0xc3, 0xd2, 0x24, 0x5b, 0xa1, 0x75, 0x09, 0x32, 0x2d, 0xce, 0x10, 0xd6, 0x8b,
+	0x8e, 0x24, 0xec, 0x9f, 0x10, 0xe0, 0xcf, 0x08, 0x13, 0xa6, 0x37, 0x37, 0xa8, 0xf6, 0x5c, 0xeb,
+	0xba, 0xac, 0xb5, 0x43, 0x42, 0x1a, 0x3d, 0xd7, 0x22, 0xfa, 0xbf, 0x78, 0xae, 0xd9, 0x17, 0x60,
+	0x71, 0xc2, 0x4b, 0x15, 0x93, 0x09, 0xd9, 0x96, 0xe2, 0xa9, 0x8b, 0x3d, 0xa6, 0xff, 0x7f, 0x06,
+	0x72, 0xf1, 0xa3, 0x16, 0xe7, 0x61, 0xe1, 0xda, 0x2d, 0xe7, 0xfe, 0xba, 0xb3, 0x51, 0x4e, 0xe0,
+	0x02, 0x64, 0x9b, 0xeb, 0x57, 0x3f, 0x17, 0x14, 0x5a, 0x5b, 0x07, 0x83, 0x3f, 0xef, 0x49, 0x88,
+	0x3f, 0x80, 0x34, 0x5f, 0xe1, 0xe3, 0xe3, 0xfa, 0x6a, 0xff, 0x28, 0xcc, 0xe5, 0x69, 0xb6, 0xaa,
+	0x43, 0x62, 0xed, 0x9f, 0x14, 0x2c, 0xf0, 0x87, 0x19, 0xef, 0xe2, 0x0f, 0x21, 0x73, 0x47, 0x8c,
+	0x7f, 0x4d, 0x5c, 0x7f, 0x09, 0x9b, 0x2b, 0x87, 0xf8, 0x91, 0x9d, 0xb7, 0x11, 0xfe, 0x12, 0xf2,
+	0x82, 0xa9, 0x2e, 0xce, 0x93, 0xd3, 0x97, 0xd2, 0x84, 0xa5, 0x53, 0x33, 0x76, 0x35, 0x7b, 0x97,
+	0x21, 0x23, 0x10, 0xa9, 0x7b, 0xa3, 0xbf, 0xe4, 0xcc, 0x95, 0x43, 0xfc, 0x48, 0x1b, 0x5f, 0x82,
+	0x34, 0x07, 0x92, 0x9e, 0x0e, 0xed, 0xd2, 0x33, 0x97, 0xa7, 0xd9, 0xda, 0xb1, 0x1f, 0xc5, 0x77,
+	0xf1, 0xca, 0xf4, 0x10, 0x8b, 0xd4, 0x2b, 0x87, 0x37, 0xe2, 0x93, 0x6f, 0x41, 0x41, 0x87, 0x30,
+	0x3e, 0x35, 0x79, 0xd4, 0x14, 0xe2, 0x4d, 0x6b, 0xd6, 0x76, 0x6c, 0xf0, 0x26, 0xe4, 0x35, 0xf8,
+	0xe8, 0x69, 0x3d, 0x8c, 0x7d, 0xf3, 0xd4, 0x8c, 0xdd, 0xb8, 0xdc, 0x5f, 0x41, 0x36, 0x9a, 0x31,
+	0xf8, 0x0e, 0x94, 0x26, 0xdb, 0x13, 0xff, 0x4f, 0xf3, 0x66, 0x72, 0x70, 0x99, 0x35, 0x6d, 0xeb,
+	0xe8, 0x9e, 0x4e, 0xd4, 0x51, 0xf3, 0xc1, 0xb3, 0x7d, 0x2b, 0xf1, 0x7c, 0xdf, 0x4a, 0xbc, 0xdc,
+	0xb7, 0xd0, 0xb7, 0x23, 0x0b, 0xfd, 0x3c, 0xb2, 0xd0, 0xd3, 0x91, 0x85, 0x9e, 0x8d, 0x2c, 0xf4,
+	0xd7, 0xc8, 0x42, 0x7f, 0x8f, 0xac, 0xc4, 0xcb, 0x91, 0x85, 0x9e, 0xbc, 0xb0, 0x12, 0xcf, 0x5e,
+	0x58, 0x89, 0xe7, 0x2f, 0xac, 0xc4, 0x83, 0xd3, 0xfa, 0xff, 0xe9, 0xd0, 0xdd, 0x76, 0x7b, 0xee,
+	0x6a, 0x27, 0xd8, 0xf5, 0x57, 0xf5, 0xff, 0xeb, 0x5b, 0x86, 0xf8, 0x79, 0xe7, 0xdf, 0x01, 0x00,
+	0x33, 0xcd, 0x26, 0xd9, 0xc6, 0x0f, 0x00, 0x00,
 }
 
 func (x Direction) String() string {
@@ -1844,6 +1854,14 @@ func (this *EntryAdapter) Equal(that interface{}) bool {
 	if this.Line != that1.Line {
 		return false
 	}
+	if len(this.StructuredMetadata) != len(that1.StructuredMetadata) {
+		return false
+	}
+	for i := range this.StructuredMetadata {
+		if !this.StructuredMetadata[i].Equal(&that1.StructuredMetadata[i]) {
+			return false
+		}
+	}
 	return true
 }
 func (this *Sample) Equal(that interface{}) bool {
@@ -2440,10 +2458,17 @@ func (this *EntryAdapter) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 6)
+	s := make([]string, 0, 7)
 	s = append(s, "&logproto.EntryAdapter{")
 	s = append(s, "Timestamp: "+fmt.Sprintf("%#v", this.Timestamp)+",\n")
 	s = append(s, "Line: "+fmt.Sprintf("%#v", this.Line)+",\n")
+	if this.StructuredMetadata != nil {
+		vs := make([]*LabelPair, len(this.StructuredMetadata))
+		for i := range vs {
+			vs[i] = &this.StructuredMetadata[i]
+		}
+		s = append(s, "StructuredMetadata: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -3648,6 +3673,20 @@ func (m *EntryAdapter) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.StructuredMetadata) > 0 {
+		for iNdEx := len(m.StructuredMetadata) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.StructuredMetadata[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintLogproto(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
 	if len(m.Line) > 0 {
 		i -= len(m.Line)
 		copy(dAtA[i:], m.Line)
@@ -4503,6 +4542,12 @@ func (m *EntryAdapter) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovLogproto(uint64(l))
 	}
+	if len(m.StructuredMetadata) > 0 {
+		for _, e := range m.StructuredMetadata {
+			l = e.Size()
+			n += 1 + l + sovLogproto(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -4896,9 +4941,15 @@ func (this *EntryAdapter) String() string {
 	if this == nil {
 		return "nil"
 	}
+	repeatedStringForStructuredMetadata := "[]LabelPair{"
+	for _, f := range this.StructuredMetadata {
+		repeatedStringForStructuredMetadata += strings.Replace(strings.Replace(f.String(), "LabelPair", "LabelPair", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForStructuredMetadata += "}"
 	s := strings.Join([]string{`&EntryAdapter{`,
 		`Timestamp:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.Timestamp), "Timestamp", "types.Timestamp", 1), `&`, ``, 1) + `,`,
 		`Line:` + fmt.Sprintf("%v", this.Line) + `,`,
+		`StructuredMetadata:` + repeatedStringForStructuredMetadata + `,`,
 		`}`,
 	}, "")
 	return s
@@ -6381,6 +6432,40 @@ func (m *EntryAdapter) Unmarshal(dAtA []byte) error {
 			}
 			m.Line = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StructuredMetadata", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.StructuredMetadata = append(m.StructuredMetadata, LabelPair{})
+			if err := m.StructuredMetadata[len(m.StructuredMetadata)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipLogproto(dAtA[iNdEx:])
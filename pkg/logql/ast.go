@@ -7,6 +7,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	// Embeds the time zone database so date buckets don't depend on the host's.
+	_ "time/tzdata"
 
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/labels"
@@ -374,12 +376,14 @@ func (e *LineFilterExpr) Stage() (log.Stage, error) {
 	if err != nil {
 		return nil, err
 	}
-	return f.ToStage(), nil
+	return log.NewFilterStage(f), nil
 }
 
 type LabelParserExpr struct {
 	Op    string
 	Param string
+	// FirstMatchOnly allows captures with the same name in the regexp parser, the first one matching wins.
+	FirstMatchOnly bool
 	implicit
 }
 
@@ -390,6 +394,15 @@ func newLabelParserExpr(op, param string) *LabelParserExpr {
 	}
 }
 
+func mustNewRegexpParserExpr(param, option string) *LabelParserExpr {
+	if option != OpParserFirstMatchOnly {
+		panic(logqlmodel.NewParseError(fmt.Sprintf("unexpected regexp parser option %s, expecting %s", option, OpParserFirstMatchOnly), 0, 0))
+	}
+	e := newLabelParserExpr(OpParserTypeRegexp, param)
+	e.FirstMatchOnly = true
+	return e
+}
+
 func (e *LabelParserExpr) Shardable() bool { return true }
 
 func (e *LabelParserExpr) Walk(f WalkFn) { f(e) }
@@ -401,6 +414,9 @@ func (e *LabelParserExpr) Stage() (log.Stage, error) {
 	case OpParserTypeLogfmt:
 		return log.NewLogfmtParser(), nil
 	case OpParserTypeRegexp:
+		if e.FirstMatchOnly {
+			return log.NewFirstMatchRegexpParser(e.Param)
+		}
 		return log.NewRegexpParser(e.Param)
 	case OpParserTypeUnpack:
 		return log.NewUnpackParser(), nil
@@ -420,6 +436,10 @@ func (e *LabelParserExpr) String() string {
 		sb.WriteString(" ")
 		sb.WriteString(strconv.Quote(e.Param))
 	}
+	if e.FirstMatchOnly {
+		sb.WriteString(" ")
+		sb.WriteString(OpParserFirstMatchOnly)
+	}
 	return sb.String()
 }
 
@@ -511,6 +531,40 @@ func (e *LabelFmtExpr) String() string {
 	return sb.String()
 }
 
+// LookupExpr sets the label Name to the value of the lookup table Table for the value of the label Key.
+// The table is loaded before the pipeline is built, see ResolveLookups.
+type LookupExpr struct {
+	Name  string
+	Table string
+	Key   string
+
+	values map[string]string
+	implicit
+}
+
+func newLookupExpr(name, table, key string) *LookupExpr {
+	return &LookupExpr{
+		Name:  name,
+		Table: table,
+		Key:   key,
+	}
+}
+
+func (e *LookupExpr) Shardable() bool { return true }
+
+func (e *LookupExpr) Walk(f WalkFn) { f(e) }
+
+func (e *LookupExpr) Stage() (log.Stage, error) {
+	if e.values == nil {
+		return nil, fmt.Errorf("lookup table %s is not loaded", e.Table)
+	}
+	return log.NewLookup(e.Name, e.Key, e.values), nil
+}
+
+func (e *LookupExpr) String() string {
+	return fmt.Sprintf("%s %s %s=%s[%s]", OpPipe, OpLookup, e.Name, e.Table, e.Key)
+}
+
 type JSONExpressionParser struct {
 	Expressions []log.JSONExpression
 
@@ -655,6 +709,70 @@ func newOffsetExpr(offset time.Duration) *OffsetExpr {
 	}
 }
 
+// DateBucket aligns the range of a range aggregation on the calendar boundaries
+// of a time zone, so that a window never spans two hours, days, weeks, months or years.
+type DateBucket struct {
+	Unit     string
+	Location *time.Location
+}
+
+func mustNewDateBucket(unit, location string) *DateBucket {
+	switch unit {
+	case DateUnitHour, DateUnitDay, DateUnitWeek, DateUnitMonth, DateUnitYear:
+	default:
+		panic(logqlmodel.NewParseError(fmt.Sprintf("invalid date bucket unit: %s", unit), 0, 0))
+	}
+	loc, err := time.LoadLocation(location)
+	if err != nil || location == "Local" {
+		panic(logqlmodel.NewParseError(fmt.Sprintf("invalid time zone in date bucket: %s", location), 0, 0))
+	}
+	return &DateBucket{Unit: unit, Location: loc}
+}
+
+// start returns the start in nanoseconds of the bucket ending at or containing ts.
+// A timestamp falling exactly on a boundary closes the previous bucket.
+func (d *DateBucket) start(ts int64) int64 {
+	t := time.Unix(0, ts-1).In(d.Location)
+	year, month, day := t.Date()
+	switch d.Unit {
+	case DateUnitHour:
+		// Subtract within the hour instead of rebuilding the local time, which
+		// is ambiguous when clocks are set back.
+		t = t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	case DateUnitDay:
+		t = time.Date(year, month, day, 0, 0, 0, 0, d.Location)
+	case DateUnitWeek:
+		// Weeks start on Monday.
+		t = time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, d.Location)
+	case DateUnitMonth:
+		t = time.Date(year, month, 1, 0, 0, 0, 0, d.Location)
+	case DateUnitYear:
+		t = time.Date(year, time.January, 1, 0, 0, 0, 0, d.Location)
+	}
+	return t.UnixNano()
+}
+
+// maxLength returns the length of the longest bucket, including a daylight saving time shift.
+func (d *DateBucket) maxLength() time.Duration {
+	const day = 24 * time.Hour
+	switch d.Unit {
+	case DateUnitDay:
+		return day + time.Hour
+	case DateUnitWeek:
+		return 7*day + time.Hour
+	case DateUnitMonth:
+		return 31*day + time.Hour
+	case DateUnitYear:
+		return 366*day + time.Hour
+	default:
+		return time.Hour
+	}
+}
+
+func (d *DateBucket) String() string {
+	return fmt.Sprintf("%s(%s,%s)", OpDate, strconv.Quote(d.Unit), strconv.Quote(d.Location.String()))
+}
+
 const (
 	// vector ops
 	OpTypeSum     = "sum"
@@ -683,6 +801,9 @@ const (
 	OpRangeTypeLast      = "last_over_time"
 	OpRangeTypeAbsent    = "absent_over_time"
 
+	OpRangeTypeCountDistinct       = "count_distinct_over_time"
+	OpRangeTypeApproxCountDistinct = "approx_count_distinct_over_time"
+
 	// binops - logical/set
 	OpTypeOr     = "or"
 	OpTypeAnd    = "and"
@@ -711,9 +832,14 @@ const (
 	OpParserTypeUnpack  = "unpack"
 	OpParserTypePattern = "pattern"
 
+	// parser options
+	OpParserFirstMatchOnly = "first_match_only"
+
 	OpFmtLine  = "line_format"
 	OpFmtLabel = "label_format"
 
+	OpLookup = "lookup"
+
 	OpPipe   = "|"
 	OpUnwrap = "unwrap"
 	OpOffset = "offset"
@@ -730,6 +856,16 @@ const (
 	OpConvDurationSeconds = "duration_seconds"
 
 	OpLabelReplace = "label_replace"
+	OpLabelJoin    = "label_join"
+
+	OpDate = "date"
+
+	// date bucket units
+	DateUnitHour  = "hour"
+	DateUnitDay   = "day"
+	DateUnitWeek  = "week"
+	DateUnitMonth = "month"
+	DateUnitYear  = "year"
 
 	// function filters
 	OpFilterIP = "ip"
@@ -768,6 +904,7 @@ type RangeAggregationExpr struct {
 
 	Params   *float64
 	Grouping *Grouping
+	Date     *DateBucket
 	implicit
 }
 
@@ -801,14 +938,34 @@ func newRangeAggregationExpr(left *LogRange, operation string, gr *Grouping, str
 	return e
 }
 
+// withDateBucket aligns the range of a range aggregation on calendar boundaries.
+func withDateBucket(expr SampleExpr, d *DateBucket) SampleExpr {
+	e := expr.(*RangeAggregationExpr)
+	e.Date = d
+	if err := e.validate(); err != nil {
+		panic(logqlmodel.NewParseError(err.Error(), 0, 0))
+	}
+	return e
+}
+
 func (e *RangeAggregationExpr) Selector() LogSelectorExpr {
 	return e.Left.Left
 }
 
 func (e RangeAggregationExpr) validate() error {
+	if e.Date != nil {
+		switch e.Operation {
+		case OpRangeTypeRate, OpRangeTypeBytesRate:
+			return fmt.Errorf("date bucketing not allowed for %s aggregation", e.Operation)
+		}
+		if max := e.Date.maxLength(); e.Left.Interval < max {
+			return fmt.Errorf("range %s is shorter than the longest %s bucket, use a range of at least %s", model.Duration(e.Left.Interval), e.Date.Unit, model.Duration(max))
+		}
+	}
 	if e.Grouping != nil {
 		switch e.Operation {
-		case OpRangeTypeAvg, OpRangeTypeStddev, OpRangeTypeStdvar, OpRangeTypeQuantile, OpRangeTypeMax, OpRangeTypeMin, OpRangeTypeFirst, OpRangeTypeLast:
+		case OpRangeTypeAvg, OpRangeTypeStddev, OpRangeTypeStdvar, OpRangeTypeQuantile, OpRangeTypeMax, OpRangeTypeMin, OpRangeTypeFirst, OpRangeTypeLast,
+			OpRangeTypeCountDistinct, OpRangeTypeApproxCountDistinct:
 		default:
 			return fmt.Errorf("grouping not allowed for %s aggregation", e.Operation)
 		}
@@ -817,6 +974,12 @@ func (e RangeAggregationExpr) validate() error {
 		switch e.Operation {
 		case OpRangeTypeAvg, OpRangeTypeSum, OpRangeTypeMax, OpRangeTypeMin, OpRangeTypeStddev, OpRangeTypeStdvar, OpRangeTypeQuantile, OpRangeTypeRate, OpRangeTypeAbsent, OpRangeTypeFirst, OpRangeTypeLast:
 			return nil
+		case OpRangeTypeCountDistinct, OpRangeTypeApproxCountDistinct:
+			// the distinct values of the label are counted, they are not converted to numbers.
+			if e.Left.Unwrap.Operation != "" {
+				return fmt.Errorf("conversion function %s not allowed for %s aggregation", e.Left.Unwrap.Operation, e.Operation)
+			}
+			return nil
 		default:
 			return fmt.Errorf("invalid aggregation %s with unwrap", e.Operation)
 		}
@@ -839,6 +1002,10 @@ func (e *RangeAggregationExpr) String() string {
 		sb.WriteString(",")
 	}
 	sb.WriteString(e.Left.String())
+	if e.Date != nil {
+		sb.WriteString(",")
+		sb.WriteString(e.Date.String())
+	}
 	sb.WriteString(")")
 	if e.Grouping != nil {
 		sb.WriteString(e.Grouping.String())
@@ -1249,3 +1416,66 @@ func (e *LabelReplaceExpr) String() string {
 	sb.WriteString(")")
 	return sb.String()
 }
+
+type LabelJoinExpr struct {
+	Left      SampleExpr
+	Dst       string
+	Separator string
+	Src       []string
+
+	implicit
+}
+
+func mustNewLabelJoinExpr(left SampleExpr, dst, separator string, src []string) *LabelJoinExpr {
+	if !model.LabelName(dst).IsValid() {
+		panic(logqlmodel.NewParseError(fmt.Sprintf("invalid destination label name in label_join: %s", dst), 0, 0))
+	}
+	for _, name := range src {
+		if !model.LabelName(name).IsValid() {
+			panic(logqlmodel.NewParseError(fmt.Sprintf("invalid source label name in label_join: %s", name), 0, 0))
+		}
+	}
+	return &LabelJoinExpr{
+		Left:      left,
+		Dst:       dst,
+		Separator: separator,
+		Src:       src,
+	}
+}
+
+func (e *LabelJoinExpr) Selector() LogSelectorExpr {
+	return e.Left.Selector()
+}
+
+func (e *LabelJoinExpr) Extractor() (SampleExtractor, error) {
+	return e.Left.Extractor()
+}
+
+func (e *LabelJoinExpr) Shardable() bool {
+	return false
+}
+
+func (e *LabelJoinExpr) Walk(f WalkFn) {
+	f(e)
+	if e.Left == nil {
+		return
+	}
+	e.Left.Walk(f)
+}
+
+func (e *LabelJoinExpr) String() string {
+	var sb strings.Builder
+	sb.WriteString(OpLabelJoin)
+	sb.WriteString("(")
+	sb.WriteString(e.Left.String())
+	sb.WriteString(",")
+	sb.WriteString(strconv.Quote(e.Dst))
+	sb.WriteString(",")
+	sb.WriteString(strconv.Quote(e.Separator))
+	for _, src := range e.Src {
+		sb.WriteString(",")
+		sb.WriteString(strconv.Quote(src))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
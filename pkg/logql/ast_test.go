@@ -2,6 +2,7 @@ package logql
 
 import (
 	"testing"
+	"time"
 
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/stretchr/testify/assert"
@@ -36,6 +37,7 @@ func Test_logSelectorExpr_String(t *testing.T) {
 		{`{foo="bar"} |= "baz" |~ "blip" != "flip" !~ "flap" | logfmt | b=ip("127.0.0.1") | level="error"`, true},
 		{`{foo="bar"} |= "baz" |~ "blip" != "flip" !~ "flap" | logfmt | b=ip("127.0.0.1") | level="error" | c=ip("::1")`, true}, // chain inside label filters.
 		{`{foo="bar"} |= "baz" |~ "blip" != "flip" !~ "flap" | regexp "(?P<foo>foo|bar)"`, true},
+		{`{foo="bar"} |= "baz" |~ "blip" != "flip" !~ "flap" | regexp "(?P<foo>foo)|(?P<foo>bar)" first_match_only`, true},
 		{`{foo="bar"} |= "baz" |~ "blip" != "flip" !~ "flap" | regexp "(?P<foo>foo|bar)" | ( ( foo<5.01 , bar>20ms ) or foo="bar" ) | line_format "blip{{.boop}}bap" | label_format foo=bar,bar="blip{{.blop}}"`, true},
 	}
 
@@ -146,9 +148,19 @@ func Test_SampleExpr_String(t *testing.T) {
 			"(.*):.*"
 		)
 		`,
+		`sum by (job) (count_over_time({namespace="tns"}[25h], date("day", "Europe/Berlin")))`,
+		`quantile_over_time(0.99, {namespace="tns"} | unwrap latency [169h], date("week")) by (job)`,
+		`label_join(
+			sum by (job, service) (count_over_time({namespace="tns"} | logfmt [5m])),
+			"foo",
+			"-",
+			"job",
+			"service"
+		)`,
 		`10 / (5/2)`,
 		`10 / (count_over_time({job="postgres"}[5m])/2)`,
 		`{app="foo"} | json response_status="response.status.code", first_param="request.params[0]"`,
+		`sum by (owner) (count_over_time({app="foo"} | logfmt | lookup owner=service_owners[service] [5m]))`,
 		`label_replace(
 			sum by (job) (
 				sum_over_time(
@@ -484,3 +496,28 @@ func Test_canInjectVectorGrouping(t *testing.T) {
 		})
 	}
 }
+
+func Test_DateBucketStart(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		unit     string
+		ts       time.Time
+		expected time.Time
+	}{
+		{DateUnitHour, time.Date(2026, 10, 25, 2, 30, 0, 0, berlin), time.Date(2026, 10, 25, 2, 0, 0, 0, berlin)},
+		{DateUnitHour, time.Date(2026, 10, 25, 4, 0, 0, 0, berlin), time.Date(2026, 10, 25, 3, 0, 0, 0, berlin)},
+		{DateUnitDay, time.Date(2026, 10, 25, 23, 30, 0, 0, berlin), time.Date(2026, 10, 25, 0, 0, 0, 0, berlin)},
+		{DateUnitDay, time.Date(2026, 10, 26, 0, 0, 0, 0, berlin), time.Date(2026, 10, 25, 0, 0, 0, 0, berlin)},
+		{DateUnitWeek, time.Date(2026, 10, 25, 12, 0, 0, 0, berlin), time.Date(2026, 10, 19, 0, 0, 0, 0, berlin)},
+		{DateUnitWeek, time.Date(2026, 10, 26, 12, 0, 0, 0, berlin), time.Date(2026, 10, 26, 0, 0, 0, 0, berlin)},
+		{DateUnitMonth, time.Date(2026, 10, 25, 12, 0, 0, 0, berlin), time.Date(2026, 10, 1, 0, 0, 0, 0, berlin)},
+		{DateUnitYear, time.Date(2026, 10, 25, 12, 0, 0, 0, berlin), time.Date(2026, 1, 1, 0, 0, 0, 0, berlin)},
+	} {
+		t.Run(tc.unit+"/"+tc.ts.String(), func(t *testing.T) {
+			d := mustNewDateBucket(tc.unit, "Europe/Berlin")
+			require.Equal(t, tc.expected.UnixNano(), d.start(tc.ts.UnixNano()))
+		})
+	}
+}
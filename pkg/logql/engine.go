@@ -10,6 +10,7 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util/spanlogger"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -20,6 +21,7 @@ import (
 	"github.com/grafana/loki/pkg/iter"
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logqlmodel"
+	"github.com/grafana/loki/pkg/logqlmodel/memory"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/pkg/util"
 )
@@ -115,8 +117,14 @@ func (q *query) Exec(ctx context.Context) (logqlmodel.Result, error) {
 	// records query statistics
 	start := time.Now()
 	statsCtx, ctx := stats.NewContext(ctx)
+	memTracker, ctx := memory.NewContext(ctx, q.maxMemory(ctx))
+	defer memTracker.Close()
 
 	data, err := q.Eval(ctx)
+	if memErr := memTracker.Err(); memErr != nil {
+		// the query was cancelled because it exceeded its memory budget.
+		data, err = nil, memErr
+	}
 
 	statResult := statsCtx.Result(time.Since(start))
 	statResult.Log(level.Debug(log))
@@ -142,6 +150,15 @@ func (q *query) Exec(ctx context.Context) (logqlmodel.Result, error) {
 	}, err
 }
 
+// maxMemory returns the memory budget of the query, the smallest of its tenants.
+func (q *query) maxMemory(ctx context.Context) int64 {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return 0
+	}
+	return int64(validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, q.limits.MaxQueryMemory))
+}
+
 func (q *query) Eval(ctx context.Context) (promql_parser.Value, error) {
 	ctx, cancel := context.WithTimeout(ctx, q.timeout)
 	defer cancel()
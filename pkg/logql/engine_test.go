@@ -1683,6 +1683,37 @@ func TestEngine_RangeQuery(t *testing.T) {
 				},
 			},
 		},
+		{
+			`label_join(
+				sum by (app) (rate({app=~"foo|bar"} |~".+bar" [1m])),
+				"new",
+				"-",
+				"app",
+				"missing",
+				"app"
+			)
+			`,
+			time.Unix(60, 0), time.Unix(180, 0), 30 * time.Second, 0, logproto.FORWARD, 100,
+			[][]logproto.Series{
+				{
+					newSeries(testSize, factor(5, identity), `{app="foo"}`),
+					newSeries(testSize, factor(5, identity), `{app="bar"}`),
+				},
+			},
+			[]SelectSampleParams{
+				{&logproto.SampleQueryRequest{Start: time.Unix(0, 0), End: time.Unix(180, 0), Selector: `sum by (app) (rate({app=~"foo|bar"} |~".+bar" [1m]))`}},
+			},
+			promql.Matrix{
+				promql.Series{
+					Metric: labels.Labels{{Name: "app", Value: "bar"}, {Name: "new", Value: "bar--bar"}},
+					Points: []promql.Point{{T: 60 * 1000, V: 0.2}, {T: 90 * 1000, V: 0.2}, {T: 120 * 1000, V: 0.2}, {T: 150 * 1000, V: 0.2}, {T: 180 * 1000, V: 0.2}},
+				},
+				promql.Series{
+					Metric: labels.Labels{{Name: "app", Value: "foo"}, {Name: "new", Value: "foo--foo"}},
+					Points: []promql.Point{{T: 60 * 1000, V: 0.2}, {T: 90 * 1000, V: 0.2}, {T: 120 * 1000, V: 0.2}, {T: 150 * 1000, V: 0.2}, {T: 180 * 1000, V: 0.2}},
+				},
+			},
+		},
 		{
 			` sum (
 					sum by (app) (rate({app=~"foo|bar"} |~".+bar" [1m])) +
@@ -2128,6 +2159,35 @@ func TestEngine_MaxSeries(t *testing.T) {
 	}
 }
 
+func TestEngine_MaxMemory(t *testing.T) {
+	for _, test := range []struct {
+		maxMemory      int
+		expectLimitErr bool
+	}{
+		{0, false},
+		{1 << 20, false},
+		{5, true},
+	} {
+		eng := NewEngine(EngineOpts{}, getLocalQuerier(1000), &fakeLimits{maxSeries: math.MaxInt32, maxMemory: test.maxMemory})
+		q := eng.Query(LiteralParams{
+			qs:        `{app=~"foo|bar"}`,
+			start:     time.Unix(0, 0),
+			end:       time.Unix(1000, 0),
+			direction: logproto.FORWARD,
+			limit:     100,
+		})
+		res, err := q.Exec(user.InjectOrgID(context.Background(), "fake"))
+		if test.expectLimitErr {
+			require.True(t, errors.Is(err, logqlmodel.ErrLimit))
+			require.Contains(t, err.Error(), "exceeded its memory budget of 5 B")
+			require.Nil(t, res.Data)
+			continue
+		}
+		require.NoError(t, err)
+		require.Len(t, res.Data.(logqlmodel.Streams), 8)
+	}
+}
+
 // go test -mod=vendor ./pkg/logql/ -bench=.  -benchmem -memprofile memprofile.out -cpuprofile cpuprofile.out
 func BenchmarkRangeQuery100000(b *testing.B) {
 	benchmarkRangeQuery(int64(100000), b)
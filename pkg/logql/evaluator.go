@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -205,6 +206,8 @@ func (ev *DefaultEvaluator) StepEvaluator(
 		return binOpStepEvaluator(ctx, nextEv, e, q)
 	case *LabelReplaceExpr:
 		return labelReplaceEvaluator(ctx, nextEv, e, q)
+	case *LabelJoinExpr:
+		return labelJoinEvaluator(ctx, nextEv, e, q)
 	default:
 		return nil, EvaluatorUnsupportedType(e, ev)
 	}
@@ -419,6 +422,7 @@ func rangeAggEvaluator(
 		q.Step().Nanoseconds(),
 		q.Start().UnixNano(), q.End().UnixNano(), o.Nanoseconds(),
 	)
+	iter.date = expr.Date
 	if expr.Operation == OpRangeTypeAbsent {
 		return &absentRangeVectorEvaluator{
 			iter: iter,
@@ -1135,6 +1139,51 @@ func labelReplaceEvaluator(
 	}, nextEvaluator.Close, nextEvaluator.Error)
 }
 
+func labelJoinEvaluator(
+	ctx context.Context,
+	ev SampleEvaluator,
+	expr *LabelJoinExpr,
+	q Params,
+) (StepEvaluator, error) {
+	nextEvaluator, err := ev.StepEvaluator(ctx, ev, expr.Left, q)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, 1024)
+	values := make([]string, len(expr.Src))
+	var labelCache map[uint64]labels.Labels
+	return newStepEvaluator(func() (bool, int64, promql.Vector) {
+		next, ts, vec := nextEvaluator.Next()
+		if !next {
+			return false, 0, promql.Vector{}
+		}
+		if labelCache == nil {
+			labelCache = make(map[uint64]labels.Labels, len(vec))
+		}
+		var hash uint64
+		for i, s := range vec {
+			hash, buf = s.Metric.HashWithoutLabels(buf)
+			if labels, ok := labelCache[hash]; ok {
+				vec[i].Metric = labels
+				continue
+			}
+			for j, src := range expr.Src {
+				values[j] = s.Metric.Get(src)
+			}
+			res := strings.Join(values, expr.Separator)
+
+			lb := labels.NewBuilder(s.Metric).Del(expr.Dst)
+			if len(res) > 0 {
+				lb.Set(expr.Dst, res)
+			}
+			outLbs := lb.Labels()
+			labelCache[hash] = outLbs
+			vec[i].Metric = outLbs
+		}
+		return next, ts, vec
+	}, nextEvaluator.Close, nextEvaluator.Error)
+}
+
 // This is to replace missing timeseries during absent_over_time aggregation.
 func absentLabels(expr SampleExpr) labels.Labels {
 	m := labels.Labels{}
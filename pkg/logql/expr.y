@@ -12,7 +12,7 @@ import (
 %union{
   Expr                    Expr
   Filter                  labels.MatchType
-  Grouping                *grouping
+  Grouping                *Grouping
   Labels                  []string
   LogExpr                 LogSelectorExpr
   LogRangeExpr            *LogRange
@@ -28,6 +28,7 @@ import (
   FilterOp                string
   BinOpExpr               SampleExpr
   LabelReplaceExpr        SampleExpr
+  LabelJoinExpr           SampleExpr
   binOp                   string
   bytes                   uint64
   str                     string
@@ -49,6 +50,7 @@ import (
   IPLabelFilter           log.LabelFilterer
   LineFormatExpr          *LineFmtExpr
   LabelFormatExpr         *LabelFmtExpr
+  LookupExpr              *LookupExpr
   LabelFormat             log.LabelFmt
   LabelsFormat            []log.LabelFmt
   JSONExpressionParser    *JSONExpressionParser
@@ -56,6 +58,7 @@ import (
   JSONExpressionList      []log.JSONExpression
   UnwrapExpr              *UnwrapExpr
   OffsetExpr              *OffsetExpr
+  DateBucket              *DateBucket
 }
 
 %start root
@@ -79,6 +82,8 @@ import (
 %type <BinOpExpr>             binOpExpr
 %type <LiteralExpr>           literalExpr
 %type <LabelReplaceExpr>      labelReplaceExpr
+%type <LabelJoinExpr>         labelJoinExpr
+%type <Labels>                stringList
 %type <BinOpModifier>         binOpModifier
 %type <BoolModifier>          boolModifier
 %type <OnOrIgnoringModifier>  onOrIgnoringModifier
@@ -93,6 +98,7 @@ import (
 %type <LineFilter>            lineFilter
 %type <LineFormatExpr>        lineFormatExpr
 %type <LabelFormatExpr>       labelFormatExpr
+%type <LookupExpr>            lookupExpr
 %type <LabelFormat>           labelFormat
 %type <LabelsFormat>          labelsFormat
 %type <JSONExpressionParser>  jsonExpressionParser
@@ -102,6 +108,7 @@ import (
 %type <UnitFilter>            unitFilter
 %type <IPLabelFilter>         ipLabelFilter
 %type <OffsetExpr>            offsetExpr
+%type <DateBucket>            dateBucket
 
 %token <bytes> BYTES
 %token <str>      IDENTIFIER STRING NUMBER
@@ -110,7 +117,8 @@ import (
                   OPEN_PARENTHESIS CLOSE_PARENTHESIS BY WITHOUT COUNT_OVER_TIME RATE SUM AVG MAX MIN COUNT STDDEV STDVAR BOTTOMK TOPK
                   BYTES_OVER_TIME BYTES_RATE BOOL JSON REGEXP LOGFMT PIPE LINE_FMT LABEL_FMT UNWRAP AVG_OVER_TIME SUM_OVER_TIME MIN_OVER_TIME
                   MAX_OVER_TIME STDVAR_OVER_TIME STDDEV_OVER_TIME QUANTILE_OVER_TIME BYTES_CONV DURATION_CONV DURATION_SECONDS_CONV
-                  FIRST_OVER_TIME LAST_OVER_TIME ABSENT_OVER_TIME LABEL_REPLACE UNPACK OFFSET PATTERN IP ON IGNORING GROUP_LEFT GROUP_RIGHT
+                  FIRST_OVER_TIME LAST_OVER_TIME ABSENT_OVER_TIME LABEL_REPLACE LABEL_JOIN DATE UNPACK OFFSET PATTERN IP ON IGNORING GROUP_LEFT GROUP_RIGHT
+                  COUNT_DISTINCT_OVER_TIME APPROX_COUNT_DISTINCT_OVER_TIME LOOKUP
 
 // Operators are listed with increasing precedence.
 %left <binOp> OR
@@ -135,6 +143,7 @@ metricExpr:
     | binOpExpr                                     { $$ = $1 }
     | literalExpr                                   { $$ = $1 }
     | labelReplaceExpr                              { $$ = $1 }
+    | labelJoinExpr                                 { $$ = $1 }
     | OPEN_PARENTHESIS metricExpr CLOSE_PARENTHESIS { $$ = $2 }
     ;
 
@@ -190,6 +199,15 @@ rangeAggregationExpr:
     | rangeOp OPEN_PARENTHESIS NUMBER COMMA logRangeExpr CLOSE_PARENTHESIS           { $$ = newRangeAggregationExpr($5, $1, nil, &$3) }
     | rangeOp OPEN_PARENTHESIS logRangeExpr CLOSE_PARENTHESIS grouping               { $$ = newRangeAggregationExpr($3, $1, $5, nil) }
     | rangeOp OPEN_PARENTHESIS NUMBER COMMA logRangeExpr CLOSE_PARENTHESIS grouping  { $$ = newRangeAggregationExpr($5, $1, $7, &$3) }
+    | rangeOp OPEN_PARENTHESIS logRangeExpr COMMA dateBucket CLOSE_PARENTHESIS                         { $$ = withDateBucket(newRangeAggregationExpr($3, $1, nil, nil), $5) }
+    | rangeOp OPEN_PARENTHESIS NUMBER COMMA logRangeExpr COMMA dateBucket CLOSE_PARENTHESIS            { $$ = withDateBucket(newRangeAggregationExpr($5, $1, nil, &$3), $7) }
+    | rangeOp OPEN_PARENTHESIS logRangeExpr COMMA dateBucket CLOSE_PARENTHESIS grouping                { $$ = withDateBucket(newRangeAggregationExpr($3, $1, $7, nil), $5) }
+    | rangeOp OPEN_PARENTHESIS NUMBER COMMA logRangeExpr COMMA dateBucket CLOSE_PARENTHESIS grouping   { $$ = withDateBucket(newRangeAggregationExpr($5, $1, $9, &$3), $7) }
+    ;
+
+dateBucket:
+      DATE OPEN_PARENTHESIS STRING CLOSE_PARENTHESIS               { $$ = mustNewDateBucket($3, "UTC") }
+    | DATE OPEN_PARENTHESIS STRING COMMA STRING CLOSE_PARENTHESIS  { $$ = mustNewDateBucket($3, $5) }
     ;
 
 vectorAggregationExpr:
@@ -208,6 +226,18 @@ labelReplaceExpr:
       { $$ = mustNewLabelReplaceExpr($3, $5, $7, $9, $11)}
     ;
 
+labelJoinExpr:
+      LABEL_JOIN OPEN_PARENTHESIS metricExpr COMMA STRING COMMA STRING CLOSE_PARENTHESIS
+      { $$ = mustNewLabelJoinExpr($3, $5, $7, nil)}
+    | LABEL_JOIN OPEN_PARENTHESIS metricExpr COMMA STRING COMMA STRING COMMA stringList CLOSE_PARENTHESIS
+      { $$ = mustNewLabelJoinExpr($3, $5, $7, $9)}
+    ;
+
+stringList:
+      STRING                   { $$ = []string{ $1 } }
+    | stringList COMMA STRING  { $$ = append($1, $3) }
+    ;
+
 filter:
       PIPE_MATCH                       { $$ = labels.MatchRegexp }
     | PIPE_EXACT                       { $$ = labels.MatchEqual }
@@ -245,6 +275,7 @@ pipelineStage:
   | PIPE labelFilter             { $$ = &LabelFilterExpr{LabelFilterer: $2 }}
   | PIPE lineFormatExpr          { $$ = $2 }
   | PIPE labelFormatExpr         { $$ = $2 }
+  | PIPE lookupExpr              { $$ = $2 }
   ;
 
 filterOp:
@@ -265,6 +296,7 @@ labelParser:
     JSON           { $$ = newLabelParserExpr(OpParserTypeJSON, "") }
   | LOGFMT         { $$ = newLabelParserExpr(OpParserTypeLogfmt, "") }
   | REGEXP STRING  { $$ = newLabelParserExpr(OpParserTypeRegexp, $2) }
+  | REGEXP STRING IDENTIFIER { $$ = mustNewRegexpParserExpr($2, $3) }
   | UNPACK         { $$ = newLabelParserExpr(OpParserTypeUnpack, "") }
   | PATTERN STRING { $$ = newLabelParserExpr(OpParserTypePattern, $2) }
   ;
@@ -287,6 +319,8 @@ labelsFormat:
 
 labelFormatExpr: LABEL_FMT labelsFormat { $$ = newLabelFmtExpr($2) };
 
+lookupExpr: LOOKUP IDENTIFIER EQ IDENTIFIER OPEN_BRACKET IDENTIFIER CLOSE_BRACKET { $$ = newLookupExpr($2, $4, $6) };
+
 labelFilter:
       matcher                                        { $$ = log.NewStringLabelFilter($1) }
     | ipLabelFilter                                       { $$ = $1 }
@@ -468,6 +502,8 @@ rangeOp:
     | FIRST_OVER_TIME    { $$ = OpRangeTypeFirst }
     | LAST_OVER_TIME     { $$ = OpRangeTypeLast }
     | ABSENT_OVER_TIME   { $$ = OpRangeTypeAbsent }
+    | COUNT_DISTINCT_OVER_TIME        { $$ = OpRangeTypeCountDistinct }
+    | APPROX_COUNT_DISTINCT_OVER_TIME { $$ = OpRangeTypeApproxCountDistinct }
     ;
 
 offsetExpr:
@@ -479,9 +515,9 @@ labels:
     ;
 
 grouping:
-      BY OPEN_PARENTHESIS labels CLOSE_PARENTHESIS        { $$ = &grouping{ without: false , groups: $3 } }
-    | WITHOUT OPEN_PARENTHESIS labels CLOSE_PARENTHESIS   { $$ = &grouping{ without: true , groups: $3 } }
-    | BY OPEN_PARENTHESIS CLOSE_PARENTHESIS               { $$ = &grouping{ without: false , groups: nil } }
-    | WITHOUT OPEN_PARENTHESIS CLOSE_PARENTHESIS          { $$ = &grouping{ without: true , groups: nil } }
+      BY OPEN_PARENTHESIS labels CLOSE_PARENTHESIS        { $$ = &Grouping{ Without: false , Groups: $3 } }
+    | WITHOUT OPEN_PARENTHESIS labels CLOSE_PARENTHESIS   { $$ = &Grouping{ Without: true , Groups: $3 } }
+    | BY OPEN_PARENTHESIS CLOSE_PARENTHESIS               { $$ = &Grouping{ Without: false , Groups: nil } }
+    | WITHOUT OPEN_PARENTHESIS CLOSE_PARENTHESIS          { $$ = &Grouping{ Without: true , Groups: nil } }
     ;
 %%
@@ -3,6 +3,7 @@ package logql
 import (
 	"fmt"
 	"math"
+	"math/bits"
 	"sort"
 	"time"
 
@@ -71,6 +72,9 @@ func (r RangeAggregationExpr) extractor(override *Grouping) (log.SampleExtractor
 		default:
 			convOp = log.ConvertFloat
 		}
+		if r.Operation == OpRangeTypeCountDistinct || r.Operation == OpRangeTypeApproxCountDistinct {
+			convOp = log.ConvertHash
+		}
 
 		return log.LabelExtractorWithStages(
 			r.Left.Unwrap.Identifier,
@@ -117,6 +121,10 @@ func (r RangeAggregationExpr) aggregator() (RangeVectorAggregator, error) {
 		return last, nil
 	case OpRangeTypeAbsent:
 		return one, nil
+	case OpRangeTypeCountDistinct:
+		return countDistinctOverTime, nil
+	case OpRangeTypeApproxCountDistinct:
+		return approxCountDistinctOverTime, nil
 	default:
 		return nil, fmt.Errorf(unsupportedErr, r.Operation)
 	}
@@ -281,3 +289,59 @@ func last(samples []promql.Point) float64 {
 func one(samples []promql.Point) float64 {
 	return 1.0
 }
+
+// countDistinctOverTime counts the distinct values, which are the hashes of the unwrapped label values.
+func countDistinctOverTime(samples []promql.Point) float64 {
+	values := make(map[float64]struct{}, len(samples))
+	for _, v := range samples {
+		values[v.V] = struct{}{}
+	}
+	return float64(len(values))
+}
+
+const (
+	// hllPrecision is the number of bits of the hashes indexing the HyperLogLog registers,
+	// the standard error of the estimations is 1.04/sqrt(2^hllPrecision), about 1.6%.
+	hllPrecision = 12
+	hllRegisters = 1 << hllPrecision
+	// hllHashBits is the number of bits of the hashes of the unwrapped label values.
+	hllHashBits = 53
+)
+
+// approxCountDistinctOverTime estimates the number of distinct values, which are the hashes of the
+// unwrapped label values, with HyperLogLog. Unlike countDistinctOverTime, it doesn't build the set of the
+// distinct values, the samples of the range are held by the range vector iterator all the same.
+func approxCountDistinctOverTime(samples []promql.Point) float64 {
+	var registers [hllRegisters]uint8
+	for _, v := range samples {
+		hash := uint64(v.V)
+		// The first bits of the hash select the register, the position of the first set bit
+		// of the remaining ones is its rank.
+		index := hash >> (hllHashBits - hllPrecision)
+		rank := uint8(bits.LeadingZeros64(hash<<(64-hllHashBits+hllPrecision))) + 1
+		if max := uint8(hllHashBits - hllPrecision + 1); rank > max {
+			rank = max
+		}
+		if rank > registers[index] {
+			registers[index] = rank
+		}
+	}
+
+	var (
+		sum   float64
+		zeros int
+	)
+	for _, r := range registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	m := float64(hllRegisters)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// Use linear counting for the small cardinalities.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return math.Round(estimate)
+}
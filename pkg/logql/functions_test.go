@@ -1,9 +1,13 @@
 package logql
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/prometheus/prometheus/promql"
 	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logql/log"
 )
 
 func Test_Extractor(t *testing.T) {
@@ -24,6 +28,8 @@ func Test_Extractor(t *testing.T) {
 		`topk by (name)(10,sum(rate({region="us-east1"}[5m])))`,
 		`avg( rate( ( {job="nginx"} |= "GET" ) [10s] ) ) by (region)`,
 		`avg(min_over_time({job="nginx"} |= "GET" | unwrap foo[10s])) by (region)`,
+		`count_distinct_over_time({job="nginx"} | logfmt | unwrap user [5m]) by (region)`,
+		`approx_count_distinct_over_time({job="nginx"} | json | unwrap user [5m])`,
 		`sum by (cluster) (count_over_time({job="mysql"}[5m]))`,
 		`sum by (cluster) (count_over_time({job="mysql"}[5m])) / sum by (cluster) (count_over_time({job="postgres"}[5m])) `,
 		`
@@ -104,3 +110,33 @@ func Test_Extractor(t *testing.T) {
 		})
 	}
 }
+
+func Test_countDistinctOverTime(t *testing.T) {
+	t.Parallel()
+	hashes := func(n, distinct int) []promql.Point {
+		ex, err := log.LabelExtractorWithStages("user", log.ConvertHash, nil, false, false, []log.Stage{log.NewLogfmtParser()}, log.NoopStage)
+		require.NoError(t, err)
+		sex := ex.ForStream(nil)
+		points := make([]promql.Point, 0, n)
+		for i := 0; i < n; i++ {
+			v, _, ok := sex.ProcessString(fmt.Sprintf("user=%d", i%distinct))
+			require.True(t, ok)
+			points = append(points, promql.Point{T: int64(i), V: v})
+		}
+		return points
+	}
+
+	for _, tc := range []struct {
+		n, distinct int
+		tolerance   float64
+	}{
+		{n: 1, distinct: 1},
+		{n: 1000, distinct: 10},
+		{n: 100000, distinct: 1000, tolerance: 0.05},
+		{n: 100000, distinct: 50000, tolerance: 0.05},
+	} {
+		points := hashes(tc.n, tc.distinct)
+		require.Equal(t, float64(tc.distinct), countDistinctOverTime(points))
+		require.InEpsilon(t, float64(tc.distinct), approxCountDistinctOverTime(points), tc.tolerance+1e-9)
+	}
+}
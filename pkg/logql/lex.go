@@ -35,6 +35,7 @@ var tokens = map[string]int{
 	"[":            OPEN_BRACKET,
 	"]":            CLOSE_BRACKET,
 	OpLabelReplace: LABEL_REPLACE,
+	OpLabelJoin:    LABEL_JOIN,
 	OpOffset:       OFFSET,
 	OpOn:           ON,
 	OpIgnoring:     IGNORING,
@@ -91,6 +92,9 @@ var functionTokens = map[string]int{
 	OpRangeTypeLast:      LAST_OVER_TIME,
 	OpRangeTypeAbsent:    ABSENT_OVER_TIME,
 
+	OpRangeTypeCountDistinct:       COUNT_DISTINCT_OVER_TIME,
+	OpRangeTypeApproxCountDistinct: APPROX_COUNT_DISTINCT_OVER_TIME,
+
 	// vec ops
 	OpTypeSum:      SUM,
 	OpTypeAvg:      AVG,
@@ -102,6 +106,8 @@ var functionTokens = map[string]int{
 	OpTypeBottomK:  BOTTOMK,
 	OpTypeTopK:     TOPK,
 	OpLabelReplace: LABEL_REPLACE,
+	OpLabelJoin:    LABEL_JOIN,
+	OpDate:         DATE,
 
 	// conversion Op
 	OpConvBytes:           BYTES_CONV,
@@ -116,9 +122,20 @@ type lexer struct {
 	scanner.Scanner
 	errs    []logqlmodel.ParseError
 	builder strings.Builder
+
+	// lookup is set after a lookup token, the next '[' opens the key of the table instead of a range.
+	lookup bool
+	// prev is the last token returned.
+	prev int
 }
 
 func (l *lexer) Lex(lval *exprSymType) int {
+	tok := l.lex(lval)
+	l.prev = tok
+	return tok
+}
+
+func (l *lexer) lex(lval *exprSymType) int {
 	r := l.Scan()
 
 	switch r {
@@ -127,7 +144,7 @@ func (l *lexer) Lex(lval *exprSymType) int {
 		for next := l.Peek(); !(next == '\n' || next == scanner.EOF); next = l.Next() {
 		}
 
-		return l.Lex(lval)
+		return l.lex(lval)
 
 	case scanner.EOF:
 		return 0
@@ -165,6 +182,11 @@ func (l *lexer) Lex(lval *exprSymType) int {
 		return STRING
 	}
 
+	if r == '[' && l.lookup {
+		l.lookup = false
+		return OPEN_BRACKET
+	}
+
 	// scanning duration tokens
 	if r == '[' {
 		l.builder.Reset()
@@ -204,6 +226,12 @@ func (l *lexer) Lex(lval *exprSymType) int {
 		return tok
 	}
 
+	// lookup is only a keyword as a pipeline stage, it remains a valid label name elsewhere.
+	if tokenText == OpLookup && l.prev == PIPE && isLookup(l.Scanner) {
+		l.lookup = true
+		return LOOKUP
+	}
+
 	if tok, ok := tokens[tokenNext]; ok {
 		l.Next()
 		return tok
@@ -320,6 +348,14 @@ func isFunction(sc scanner.Scanner) bool {
 	return false
 }
 
+// isLookup checks if the next rune starts the destination label of a lookup stage. This allows
+// to dissociate the lookup stage and a label filter on a lookup label.
+func isLookup(sc scanner.Scanner) bool {
+	sc = trimSpace(sc)
+	r := sc.Peek()
+	return r == '_' || unicode.IsLetter(r)
+}
+
 func trimSpace(l scanner.Scanner) scanner.Scanner {
 	for n := l.Peek(); n != scanner.EOF; n = l.Peek() {
 		if unicode.IsSpace(n) {
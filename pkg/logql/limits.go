@@ -11,12 +11,18 @@ var (
 // Limits allow the engine to fetch limits for a given users.
 type Limits interface {
 	MaxQuerySeries(userID string) int
+	MaxQueryMemory(userID string) int
 }
 
 type fakeLimits struct {
 	maxSeries int
+	maxMemory int
 }
 
 func (f fakeLimits) MaxQuerySeries(userID string) int {
 	return f.maxSeries
 }
+
+func (f fakeLimits) MaxQueryMemory(userID string) int {
+	return f.maxMemory
+}
@@ -254,6 +254,9 @@ func (l *containsFilter) Filter(line []byte) bool {
 	if len(l.match) > len(line) {
 		return false
 	}
+	if c := l.match[0]; c < utf8.RuneSelf && c != 'i' && c != 'k' {
+		return containsLower(line, l.match)
+	}
 	j := 0
 	for len(line) > 0 {
 		// ascii fast case
@@ -287,6 +290,71 @@ func (l *containsFilter) Filter(line []byte) bool {
 	return false
 }
 
+// containsLower reports whether line contains the lowercase match, ignoring case. The
+// occurrences of the first byte of match in both cases are found by bytes.IndexByte,
+// which is vectorized. The first byte must be ASCII, and no non ASCII letter must lower
+// to it, unlike 'i' (U+0130) and 'k' (Kelvin sign).
+func containsLower(line, match []byte) bool {
+	lower, upper := match[0], match[0]
+	if 'a' <= lower && lower <= 'z' {
+		upper = lower - ('a' - 'A')
+	}
+	next := func(c byte, from int) int {
+		if i := bytes.IndexByte(line[from:], c); i >= 0 {
+			return from + i
+		}
+		return len(line)
+	}
+	// The next occurrences of both cases, so each is searched once.
+	li, ui := next(lower, 0), len(line)
+	if upper != lower {
+		ui = next(upper, 0)
+	}
+	for {
+		i := li
+		if ui < i {
+			i = ui
+		}
+		if i == len(line) {
+			return false
+		}
+		if hasLowerPrefix(line[i+1:], match[1:]) {
+			return true
+		}
+		if i == li {
+			li = next(lower, i+1)
+		} else {
+			ui = next(upper, i+1)
+		}
+	}
+}
+
+// hasLowerPrefix reports whether b starts with the lowercase prefix, ignoring case.
+func hasLowerPrefix(b, prefix []byte) bool {
+	for len(prefix) > 0 {
+		if len(b) == 0 {
+			return false
+		}
+		if c := b[0]; c < utf8.RuneSelf {
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			if c != prefix[0] {
+				return false
+			}
+			b, prefix = b[1:], prefix[1:]
+			continue
+		}
+		lr, lwid := utf8.DecodeRune(b)
+		mr, mwid := utf8.DecodeRune(prefix)
+		if lr != mr && mr != unicode.To(unicode.LowerCase, lr) {
+			return false
+		}
+		b, prefix = b[lwid:], prefix[mwid:]
+	}
+	return true
+}
+
 func (l containsFilter) ToStage() Stage {
 	return StageFunc{
 		process: func(line []byte, _ *LabelsBuilder) ([]byte, bool) {
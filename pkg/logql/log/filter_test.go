@@ -106,6 +106,32 @@ func allunicode() string {
 	return string(b)
 }
 
+func Test_ContainsFilterCaseInsensitive(t *testing.T) {
+	for _, test := range []struct {
+		match, line string
+		expected    bool
+	}{
+		{"aab", "AAAB", true},
+		{"aab", "xaAbx", true},
+		{"aab", "aa", false},
+		{"error", "level=ERROR", true},
+		{"error", "level=Error msg=failed", true},
+		{"error", "ERR0R", false},
+		{"error", "err", false},
+		{"`", "@", false},
+		{"foo, 世界", "FOO, 世界", true},
+		{"世界", "foo, 世界", true},
+		{"straße", "STRASSE", false},
+		{"straße", "STRAßE", true},
+		{"info", "\u0130NFO", true},
+		{"kelvin", "\u212aELVIN", true},
+		{"kelvin", "KELVI", false},
+	} {
+		f := newContainsFilter([]byte(test.match), true)
+		require.Equal(t, test.expected, f.Filter([]byte(test.line)), "%q in %q", test.match, test.line)
+	}
+}
+
 func Test_TrueFilter(t *testing.T) {
 	empty := []byte("")
 	for _, test := range []struct {
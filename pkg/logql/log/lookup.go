@@ -0,0 +1,35 @@
+package log
+
+// Lookup enriches log lines with a value of a lookup table, keyed by the value of a label.
+type Lookup struct {
+	name   string
+	key    string
+	values map[string]string
+}
+
+// NewLookup creates a new lookup stage setting the label name to the value of the table
+// for the value of the label key. Lines with no key label or no entry in the table are left untouched.
+func NewLookup(name, key string, values map[string]string) *Lookup {
+	return &Lookup{
+		name:   name,
+		key:    key,
+		values: values,
+	}
+}
+
+// Process implements Stage
+func (l *Lookup) Process(line []byte, lbs *LabelsBuilder) ([]byte, bool) {
+	k, ok := lbs.Get(l.key)
+	if !ok {
+		return line, true
+	}
+	if v, ok := l.values[k]; ok {
+		lbs.Set(l.name, v)
+	}
+	return line, true
+}
+
+// RequiredLabelNames implements Stage
+func (l *Lookup) RequiredLabelNames() []string {
+	return []string{l.key}
+}
@@ -0,0 +1,57 @@
+package log
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_lookup(t *testing.T) {
+	owners := map[string]string{"api": "team-a", "db": "team-b"}
+	tests := []struct {
+		name   string
+		lookup *Lookup
+
+		in   labels.Labels
+		want labels.Labels
+	}{
+		{
+			"found",
+			NewLookup("owner", "service", owners),
+			labels.Labels{{Name: "service", Value: "api"}},
+			labels.Labels{{Name: "service", Value: "api"}, {Name: "owner", Value: "team-a"}},
+		},
+		{
+			"overrides",
+			NewLookup("owner", "service", owners),
+			labels.Labels{{Name: "service", Value: "db"}, {Name: "owner", Value: "unknown"}},
+			labels.Labels{{Name: "service", Value: "db"}, {Name: "owner", Value: "team-b"}},
+		},
+		{
+			"not in the table",
+			NewLookup("owner", "service", owners),
+			labels.Labels{{Name: "service", Value: "cache"}},
+			labels.Labels{{Name: "service", Value: "cache"}},
+		},
+		{
+			"no key label",
+			NewLookup("owner", "service", owners),
+			labels.Labels{{Name: "app", Value: "api"}},
+			labels.Labels{{Name: "app", Value: "api"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewBaseLabelsBuilder().ForLabels(tt.in, tt.in.Hash())
+			builder.Reset()
+			line, ok := tt.lookup.Process([]byte("line"), builder)
+			require.True(t, ok)
+			require.Equal(t, []byte("line"), line)
+			sort.Sort(tt.want)
+			require.Equal(t, tt.want, builder.Labels())
+		})
+	}
+}
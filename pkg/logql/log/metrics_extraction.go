@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/pkg/errors"
 	"github.com/prometheus/prometheus/pkg/labels"
 
@@ -15,6 +16,8 @@ const (
 	ConvertBytes    = "bytes"
 	ConvertDuration = "duration"
 	ConvertFloat    = "float"
+	// ConvertHash converts the label values to their hash, to count their distinct values.
+	ConvertHash = "hash"
 )
 
 // LineExtractor extracts a float64 from a log line.
@@ -92,6 +95,8 @@ func (l *streamLineSampleExtractor) Process(line []byte) (float64, LabelsResult,
 	return l.LineExtractor(line), l.builder.GroupedLabels(), true
 }
 
+func (l *streamLineSampleExtractor) LineFilter() Filterer { return leadingFilter([]Stage{l.Stage}) }
+
 func (l *streamLineSampleExtractor) ProcessString(line string) (float64, LabelsResult, bool) {
 	// unsafe get bytes since we have the guarantee that the line won't be mutated.
 	return l.Process(unsafeGetBytes(line))
@@ -126,6 +131,8 @@ func LabelExtractorWithStages(
 		convFn = convertDuration
 	case ConvertFloat:
 		convFn = convertFloat
+	case ConvertHash:
+		convFn = convertHash
 	default:
 		return nil, errors.Errorf("unsupported conversion operation %s", conversion)
 	}
@@ -191,6 +198,10 @@ func (l *streamLabelSampleExtractor) Process(line []byte) (float64, LabelsResult
 	return v, l.builder.GroupedLabels(), true
 }
 
+func (l *streamLabelSampleExtractor) LineFilter() Filterer {
+	return leadingFilter([]Stage{l.preStage})
+}
+
 func (l *streamLabelSampleExtractor) ProcessString(line string) (float64, LabelsResult, bool) {
 	// unsafe get bytes since we have the guarantee that the line won't be mutated.
 	return l.Process(unsafeGetBytes(line))
@@ -200,6 +211,11 @@ func convertFloat(v string) (float64, error) {
 	return strconv.ParseFloat(v, 64)
 }
 
+// convertHash hashes the value on 53 bits, which are exactly represented by a float64.
+func convertHash(v string) (float64, error) {
+	return float64(xxhash.Sum64String(v) >> 11), nil
+}
+
 func convertDuration(v string) (float64, error) {
 	d, err := time.ParseDuration(v)
 	if err != nil {
@@ -186,15 +186,38 @@ func readValue(iter *jsoniter.Iterator) string {
 }
 
 type RegexpParser struct {
-	regex     *regexp.Regexp
-	nameIndex map[int]string
+	regex          *regexp.Regexp
+	literal        *literalMatcher
+	groups         []regexpGroup
+	firstMatchOnly bool
 
 	keys internedStringSet
 }
 
+// regexpGroup is a label extracted by the regexp parser from one or more named captures.
+type regexpGroup struct {
+	name    string
+	hint    regexpTypeHint
+	indices []int
+}
+
 // NewRegexpParser creates a new log stage that can extract labels from a log line using a regex expression.
 // The regex expression must contains at least one named match. If the regex doesn't match the line is not filtered out.
+//
+// A named capture can be suffixed with a type hint (`__int`, `__float` or `__duration`), in which case the
+// suffix is removed from the label name and the extracted value is normalized, durations are converted to seconds.
 func NewRegexpParser(re string) (*RegexpParser, error) {
+	return newRegexpParser(re, false)
+}
+
+// NewFirstMatchRegexpParser creates a regexp parser that allows the same name for multiple captures,
+// usually in different alternatives of the expression. The first capture that participated in the match
+// gives the label its value, labels of which no capture participated in the match are not extracted.
+func NewFirstMatchRegexpParser(re string) (*RegexpParser, error) {
+	return newRegexpParser(re, true)
+}
+
+func newRegexpParser(re string, firstMatchOnly bool) (*RegexpParser, error) {
 	regex, err := regexp.Compile(re)
 	if err != nil {
 		return nil, err
@@ -202,52 +225,98 @@ func NewRegexpParser(re string) (*RegexpParser, error) {
 	if regex.NumSubexp() == 0 {
 		return nil, errMissingCapture
 	}
-	nameIndex := map[int]string{}
-	uniqueNames := map[string]struct{}{}
+	var groups []regexpGroup
+	groupIndex := map[string]int{}
 	for i, n := range regex.SubexpNames() {
-		if n != "" {
-			if !model.LabelName(n).IsValid() {
-				return nil, fmt.Errorf("invalid extracted label name '%s'", n)
+		if n == "" {
+			continue
+		}
+		name, hint := parseRegexpTypeHint(n)
+		if !model.LabelName(name).IsValid() {
+			return nil, fmt.Errorf("invalid extracted label name '%s'", n)
+		}
+		if j, ok := groupIndex[name]; ok {
+			if !firstMatchOnly {
+				return nil, fmt.Errorf("duplicate extracted label name '%s'", name)
 			}
-			if _, ok := uniqueNames[n]; ok {
-				return nil, fmt.Errorf("duplicate extracted label name '%s'", n)
+			if groups[j].hint != hint {
+				return nil, fmt.Errorf("conflicting type hints for extracted label name '%s'", name)
 			}
-			nameIndex[i] = n
-			uniqueNames[n] = struct{}{}
+			groups[j].indices = append(groups[j].indices, i)
+			continue
 		}
+		groupIndex[name] = len(groups)
+		groups = append(groups, regexpGroup{name: name, hint: hint, indices: []int{i}})
 	}
-	if len(nameIndex) == 0 {
+	if len(groups) == 0 {
 		return nil, errMissingCapture
 	}
 	return &RegexpParser{
-		regex:     regex,
-		nameIndex: nameIndex,
-		keys:      internedStringSet{},
+		regex:          regex,
+		literal:        newLiteralMatcher(re, regex.NumSubexp()),
+		groups:         groups,
+		firstMatchOnly: firstMatchOnly,
+		keys:           internedStringSet{},
 	}, nil
 }
 
 func (r *RegexpParser) Process(line []byte, lbs *LabelsBuilder) ([]byte, bool) {
-	for i, value := range r.regex.FindSubmatch(line) {
-		if name, ok := r.nameIndex[i]; ok {
-			key, ok := r.keys.Get(unsafeGetBytes(name), func() (string, bool) {
-				sanitize := sanitizeLabelKey(name, true)
-				if len(sanitize) == 0 {
-					return "", false
-				}
-				if lbs.BaseHas(sanitize) {
-					sanitize = fmt.Sprintf("%s%s", sanitize, duplicateSuffix)
+	matches := r.match(line)
+	if matches == nil {
+		return line, true
+	}
+	for _, g := range r.groups {
+		value := matches[g.indices[0]]
+		if r.firstMatchOnly {
+			value = nil
+			for _, i := range g.indices {
+				if matches[i] != nil {
+					value = matches[i]
+					break
 				}
-				return sanitize, true
-			})
-			if !ok {
+			}
+			if value == nil {
 				continue
 			}
+		}
+		key, ok := r.keys.Get(unsafeGetBytes(g.name), func() (string, bool) {
+			sanitize := sanitizeLabelKey(g.name, true)
+			if len(sanitize) == 0 {
+				return "", false
+			}
+			if lbs.BaseHas(sanitize) {
+				sanitize = fmt.Sprintf("%s%s", sanitize, duplicateSuffix)
+			}
+			return sanitize, true
+		})
+		if !ok {
+			continue
+		}
+		if g.hint == regexpHintNone || len(value) == 0 {
 			lbs.Set(key, string(value))
+			continue
+		}
+		normalized, err := g.hint.normalize(string(value))
+		if err != nil {
+			lbs.SetErr(errRegexp)
+			lbs.Set(key, string(value))
+			continue
 		}
+		lbs.Set(key, normalized)
 	}
 	return line, true
 }
 
+// match returns the submatches of the line, using the literal matcher when the expression allows it.
+func (r *RegexpParser) match(line []byte) [][]byte {
+	if r.literal != nil {
+		if matches, ok := r.literal.match(line); ok {
+			return matches
+		}
+	}
+	return r.regex.FindSubmatch(line)
+}
+
 func (r *RegexpParser) RequiredLabelNames() []string { return []string{} }
 
 type LogfmtParser struct {
@@ -473,6 +473,9 @@ func TestNewRegexpParser(t *testing.T) {
 		{"named", "blah (.*) (?P<foo>foo)(?P<bar>barr)", false},
 		{"invalid name", "blah (.*) (?P<foo$>foo)(?P<bar>barr)", true},
 		{"duplicate", "blah (.*) (?P<foo>foo)(?P<foo>barr)", true},
+		{"duplicate after type hint", "(?P<foo__int>\\d+) (?P<foo>barr)", true},
+		{"type hints", "(?P<status__int>\\d+) (?P<latency__duration>\\S+) (?P<ratio__float>\\S+)", false},
+		{"type hint suffix only", "(?P<__int>\\d+)", false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -553,6 +556,87 @@ func Test_regexpParser_Parse(t *testing.T) {
 	}
 }
 
+func TestNewFirstMatchRegexpParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		re      string
+		wantErr bool
+	}{
+		{"duplicate", "(?P<foo>foo)|(?P<foo>barr)", false},
+		{"duplicate with same type hint", "(?P<foo__int>\\d+)s|(?P<foo__int>\\d+)ms", false},
+		{"duplicate with different type hints", "(?P<foo__int>\\d+)|(?P<foo__float>\\S+)", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFirstMatchRegexpParser(tt.re)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewFirstMatchRegexpParser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+		})
+	}
+}
+
+func Test_regexpParser_TypeHints(t *testing.T) {
+	tests := []struct {
+		name   string
+		parser Stage
+		line   []byte
+		want   labels.Labels
+	}{
+		{
+			"normalized values",
+			mustStage(NewRegexpParser(`status=(?P<status__int>\S+) latency=(?P<latency__duration>\S+) ratio=(?P<ratio__float>\S+)`)),
+			[]byte("status=0200 latency=1m500ms ratio=1e-2"),
+			labels.Labels{
+				{Name: "app", Value: "foo"},
+				{Name: "status", Value: "200"},
+				{Name: "latency", Value: "60.5"},
+				{Name: "ratio", Value: "0.01"},
+			},
+		},
+		{
+			"invalid value",
+			mustStage(NewRegexpParser(`status=(?P<status__int>\S+)`)),
+			[]byte("status=ok"),
+			labels.Labels{
+				{Name: "app", Value: "foo"},
+				{Name: "status", Value: "ok"},
+				{Name: logqlmodel.ErrorLabel, Value: errRegexp},
+			},
+		},
+		{
+			"first match only",
+			mustStage(NewFirstMatchRegexpParser(`took (?P<latency__duration>\S+)|latency=(?P<latency__duration>\S+) status=(?P<status>\d+)`)),
+			[]byte("request took 20ms"),
+			labels.Labels{
+				{Name: "app", Value: "foo"},
+				{Name: "latency", Value: "0.02"},
+			},
+		},
+		{
+			"first match only second alternative",
+			mustStage(NewFirstMatchRegexpParser(`took (?P<latency__duration>\S+)|latency=(?P<latency__duration>\S+) status=(?P<status>\d+)`)),
+			[]byte("latency=1s status=500"),
+			labels.Labels{
+				{Name: "app", Value: "foo"},
+				{Name: "latency", Value: "1"},
+				{Name: "status", Value: "500"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lbs := labels.Labels{{Name: "app", Value: "foo"}}
+			b := NewBaseLabelsBuilder().ForLabels(lbs, lbs.Hash())
+			b.Reset()
+			_, _ = tt.parser.Process(tt.line, b)
+			sort.Sort(tt.want)
+			require.Equal(t, tt.want, b.Labels())
+		})
+	}
+}
+
 func Test_logfmtParser_Parse(t *testing.T) {
 	tests := []struct {
 		name string
@@ -30,6 +30,14 @@ type Stage interface {
 	RequiredLabelNames() []string
 }
 
+// LineFilterer is implemented by the stream pipelines and sample extractors starting with
+// line filters. LineFilter returns these filters, nil if there are none, allowing readers
+// to skip lines they don't match before materializing them. Lines matching are still
+// processed by the whole pipeline.
+type LineFilterer interface {
+	LineFilter() Filterer
+}
+
 // NewNoopPipeline creates a pipelines that does not process anything and returns log streams as is.
 func NewNoopPipeline() Pipeline {
 	return &noopPipeline{
@@ -79,6 +87,7 @@ func (noopStage) RequiredLabelNames() []string { return []string{} }
 type StageFunc struct {
 	process        func(line []byte, lbs *LabelsBuilder) ([]byte, bool)
 	requiredLabels []string
+	filter         Filterer
 }
 
 func (fn StageFunc) Process(line []byte, lbs *LabelsBuilder) ([]byte, bool) {
@@ -92,10 +101,54 @@ func (fn StageFunc) RequiredLabelNames() []string {
 	return fn.requiredLabels
 }
 
+// filterStage is a stage filtering lines without modifying them or their labels.
+type filterStage struct {
+	Filterer
+}
+
+// NewFilterStage creates a stage filtering lines with the filter. Filter stages starting
+// a pipeline are exposed by the LineFilterer interface.
+func NewFilterStage(f Filterer) Stage {
+	if f == TrueFilter {
+		return NoopStage
+	}
+	return filterStage{Filterer: f}
+}
+
+func (f filterStage) Process(line []byte, _ *LabelsBuilder) ([]byte, bool) {
+	return line, f.Filter(line)
+}
+
+func (filterStage) RequiredLabelNames() []string { return []string{} }
+
+// leadingFilter returns the filter of the filter stages starting the stages, nil if none.
+func leadingFilter(stages []Stage) Filterer {
+	var filters []Filterer
+	for _, s := range stages {
+		if f, ok := s.(filterStage); ok {
+			filters = append(filters, f.Filterer)
+			continue
+		}
+		// A reduced stage starts with the filter stages it was reduced from.
+		if f, ok := s.(StageFunc); ok && f.filter != nil {
+			filters = append(filters, f.filter)
+		}
+		break
+	}
+	switch len(filters) {
+	case 0:
+		return nil
+	case 1:
+		return filters[0]
+	}
+	return NewAndFilters(filters)
+}
+
 // pipeline is a combinations of multiple stages.
 // It can also be reduced into a single stage for convenience.
 type pipeline struct {
 	stages      []Stage
+	filter      Filterer
 	baseBuilder *BaseLabelsBuilder
 
 	streamPipelines map[uint64]StreamPipeline
@@ -108,6 +161,7 @@ func NewPipeline(stages []Stage) Pipeline {
 	}
 	return &pipeline{
 		stages:          stages,
+		filter:          leadingFilter(stages),
 		baseBuilder:     NewBaseLabelsBuilder(),
 		streamPipelines: make(map[uint64]StreamPipeline),
 	}
@@ -115,6 +169,7 @@ func NewPipeline(stages []Stage) Pipeline {
 
 type streamPipeline struct {
 	stages  []Stage
+	filter  Filterer
 	builder *LabelsBuilder
 }
 
@@ -126,6 +181,7 @@ func (p *pipeline) ForStream(labels labels.Labels) StreamPipeline {
 
 	res := &streamPipeline{
 		stages:  p.stages,
+		filter:  p.filter,
 		builder: p.baseBuilder.ForLabels(labels, hash),
 	}
 	p.streamPipelines[hash] = res
@@ -144,6 +200,8 @@ func (p *streamPipeline) Process(line []byte) ([]byte, LabelsResult, bool) {
 	return line, p.builder.LabelsResult(), true
 }
 
+func (p *streamPipeline) LineFilter() Filterer { return p.filter }
+
 func (p *streamPipeline) ProcessString(line string) (string, LabelsResult, bool) {
 	// Stages only read from the line.
 	lb := unsafeGetBytes(line)
@@ -174,6 +232,7 @@ func ReduceStages(stages []Stage) Stage {
 			return line, true
 		},
 		requiredLabels: requiredLabelNames,
+		filter:         leadingFilter(stages),
 	}
 }
 
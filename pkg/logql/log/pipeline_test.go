@@ -228,3 +228,46 @@ func BenchmarkJSONExpressionParserInvalidLine(b *testing.B) {
 
 	invalidJSONBenchmark(b, parser)
 }
+
+func TestPipeline_LineFilter(t *testing.T) {
+	foo := mustFilter(NewFilter("foo", labels.MatchEqual))
+	bar := mustFilter(NewFilter("bar", labels.MatchNotEqual))
+	for _, test := range []struct {
+		name   string
+		stages []Stage
+		lines  map[string]bool
+	}{
+		{"no stages", nil, nil},
+		{"no leading filter", []Stage{NewLogfmtParser(), NewFilterStage(foo)}, nil},
+		{"leading filter", []Stage{NewFilterStage(foo), NewLogfmtParser()}, map[string]bool{"foo": true, "bar": false}},
+		{
+			"leading filters",
+			[]Stage{NewFilterStage(foo), NewFilterStage(bar), NewLogfmtParser(), NewFilterStage(bar)},
+			map[string]bool{"foo": true, "foo bar": false, "buzz": false},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var filters []Filterer
+			if f, ok := NewPipeline(test.stages).ForStream(labels.Labels{}).(LineFilterer); ok {
+				filters = append(filters, f.LineFilter())
+			}
+			ex, err := NewLineSampleExtractor(CountExtractor, test.stages, nil, false, false)
+			require.NoError(t, err)
+			filters = append(filters, ex.ForStream(labels.Labels{}).(LineFilterer).LineFilter())
+			ex, err = LabelExtractorWithStages("foo", ConvertFloat, nil, false, false, test.stages, NoopStage)
+			require.NoError(t, err)
+			filters = append(filters, ex.ForStream(labels.Labels{}).(LineFilterer).LineFilter())
+
+			for _, f := range filters {
+				if test.lines == nil {
+					require.Nil(t, f)
+					continue
+				}
+				require.NotNil(t, f)
+				for line, match := range test.lines {
+					require.Equal(t, match, f.Filter([]byte(line)), line)
+				}
+			}
+		})
+	}
+}
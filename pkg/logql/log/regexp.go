@@ -0,0 +1,142 @@
+package log
+
+import (
+	"bytes"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type regexpTypeHint int
+
+const (
+	regexpHintNone regexpTypeHint = iota
+	regexpHintInt
+	regexpHintFloat
+	regexpHintDuration
+)
+
+var regexpHintSuffixes = map[string]regexpTypeHint{
+	"__int":      regexpHintInt,
+	"__float":    regexpHintFloat,
+	"__duration": regexpHintDuration,
+}
+
+// parseRegexpTypeHint splits a capture name into the label name and its type hint.
+func parseRegexpTypeHint(name string) (string, regexpTypeHint) {
+	for suffix, hint := range regexpHintSuffixes {
+		if len(name) > len(suffix) && strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix), hint
+		}
+	}
+	return name, regexpHintNone
+}
+
+// normalize parses the value according to the hint and returns its canonical representation.
+func (h regexpTypeHint) normalize(v string) (string, error) {
+	switch h {
+	case regexpHintInt:
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(i, 10), nil
+	case regexpHintFloat:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case regexpHintDuration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(d.Seconds(), 'f', -1, 64), nil
+	default:
+		return v, nil
+	}
+}
+
+// literalMatcher matches expressions made only of literals and non-greedy captures of any character
+// but newline, such as `GET (?P<path>.*?) HTTP/(?P<version>.*?) `, using bytes.Index instead of the regexp engine.
+// Every capture must be followed by a literal, each capture then ends at the first occurrence of its literal.
+type literalMatcher struct {
+	prefix   []byte
+	captures []int
+	suffixes [][]byte
+
+	matches [][]byte
+}
+
+// newLiteralMatcher returns nil if the expression can't be matched using literals only.
+func newLiteralMatcher(re string, numSubexp int) *literalMatcher {
+	parsed, err := syntax.Parse(re, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	parsed = parsed.Simplify()
+	if parsed.Op != syntax.OpConcat {
+		return nil
+	}
+	subs := parsed.Sub
+	m := &literalMatcher{
+		matches: make([][]byte, numSubexp+1),
+	}
+	if isLiteral(subs[0]) {
+		m.prefix = []byte(string(subs[0].Rune))
+		subs = subs[1:]
+	}
+	if len(subs) == 0 || len(subs)%2 != 0 {
+		return nil
+	}
+	for i := 0; i < len(subs); i += 2 {
+		if !isLazyCapture(subs[i]) || !isLiteral(subs[i+1]) {
+			return nil
+		}
+		m.captures = append(m.captures, subs[i].Cap)
+		m.suffixes = append(m.suffixes, []byte(string(subs[i+1].Rune)))
+	}
+	return m
+}
+
+func isLiteral(re *syntax.Regexp) bool {
+	return re.Op == syntax.OpLiteral && re.Flags&syntax.FoldCase == 0
+}
+
+func isLazyCapture(re *syntax.Regexp) bool {
+	if re.Op != syntax.OpCapture {
+		return false
+	}
+	star := re.Sub[0]
+	return star.Op == syntax.OpStar && star.Flags&syntax.NonGreedy != 0 && star.Sub[0].Op == syntax.OpAnyCharNotNL
+}
+
+// match returns the submatches of the line, the returned slice is reused between calls.
+// ok is false when the literal matcher can't decide and the regexp must be used instead.
+func (m *literalMatcher) match(line []byte) (matches [][]byte, ok bool) {
+	start := 0
+	if len(m.prefix) > 0 {
+		start = bytes.Index(line, m.prefix)
+		if start < 0 {
+			return nil, true
+		}
+	}
+	pos := start + len(m.prefix)
+	for i, suffix := range m.suffixes {
+		end := bytes.Index(line[pos:], suffix)
+		if end < 0 {
+			return nil, true
+		}
+		value := line[pos : pos+end]
+		if bytes.IndexByte(value, '\n') >= 0 {
+			// captures can't contain a newline, a match might still start further in the line.
+			return nil, false
+		}
+		m.matches[m.captures[i]] = value
+		pos += end + len(suffix)
+	}
+	m.matches[0] = line[start:pos]
+	return m.matches, true
+}
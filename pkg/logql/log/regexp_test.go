@@ -0,0 +1,87 @@
+package log
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newLiteralMatcher(t *testing.T) {
+	for _, tt := range []struct {
+		re   string
+		want bool
+	}{
+		{`GET (?P<path>.*?) HTTP`, true},
+		{`(?P<ip>.*?) - (?P<user>.*?) \[`, true},
+		{`(?U)level=(?P<level>.*) `, true},
+		{`GET (?P<path>.*) HTTP`, false},
+		{`GET (?P<path>.*?)`, false},
+		{`(?i)GET (?P<path>.*?) HTTP`, false},
+		{`(?s)GET (?P<path>.*?) HTTP`, false},
+		{`GET (?P<path>\S+?) HTTP`, false},
+		{`^GET (?P<path>.*?) HTTP`, false},
+		{`(?P<method>GET|POST) (?P<path>.*?) HTTP`, false},
+	} {
+		t.Run(tt.re, func(t *testing.T) {
+			re := regexp.MustCompile(tt.re)
+			require.Equal(t, tt.want, newLiteralMatcher(tt.re, re.NumSubexp()) != nil)
+		})
+	}
+}
+
+func Test_literalMatcher_match(t *testing.T) {
+	for _, re := range []string{
+		`GET (?P<path>.*?) HTTP/(?P<version>.*?)"`,
+		`(?P<ip>.*?) - (?P<user>.*?) \[`,
+		`a(?P<a>.*?)aa`,
+	} {
+		regex := regexp.MustCompile(re)
+		m := newLiteralMatcher(re, regex.NumSubexp())
+		require.NotNil(t, m)
+		for _, line := range []string{
+			`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			`"GET /foo HTTP/1.1" "GET /bar HTTP/2.0"`,
+			`GET /foo`,
+			`127.0.0.1 - `,
+			"- GET /foo\n HTTP/1.1\" GET /bar HTTP/1.1\"",
+			"a\naa aaa",
+			"aaaa",
+			"aaa",
+			"",
+		} {
+			matches, ok := m.match([]byte(line))
+			if !ok {
+				continue
+			}
+			expected := regex.FindSubmatch([]byte(line))
+			require.Equal(t, len(expected) == 0, len(matches) == 0, "re: %s line: %q", re, line)
+			for i := range expected {
+				require.Equal(t, string(expected[i]), string(matches[i]), "re: %s line: %q", re, line)
+			}
+		}
+	}
+}
+
+func Benchmark_RegexpParser(b *testing.B) {
+	line := []byte(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`)
+	for _, bb := range []struct {
+		name string
+		re   string
+	}{
+		{"literal", `(?P<ip>.*?) - (?P<user>.*?) \[(?P<ts>.*?)\] "(?P<method>.*?) (?P<path>.*?) `},
+		{"regexp", `(?P<ip>\S+) - (?P<user>\S+) \[(?P<ts>[^\]]+)\] "(?P<method>\S+) (?P<path>\S+) `},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			p, err := NewRegexpParser(bb.re)
+			require.NoError(b, err)
+			lbs := NewBaseLabelsBuilder().ForLabels(nil, 0)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lbs.Reset()
+				_, _ = p.Process(line, lbs)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package logql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/loki/pkg/logqlmodel"
+)
+
+// LookupTables loads the lookup tables of the tenant of the context, for the lookup stages.
+type LookupTables interface {
+	Table(ctx context.Context, name string) (map[string]string, error)
+}
+
+type lookupCtxKeyType string
+
+const lookupCtxKey lookupCtxKeyType = "lookup-tables"
+
+// InjectLookupTables returns a context holding the lookup tables used by ResolveLookups.
+func InjectLookupTables(ctx context.Context, tables LookupTables) context.Context {
+	return context.WithValue(ctx, lookupCtxKey, tables)
+}
+
+// UsesLookupTables returns whether the expression has lookup stages.
+func UsesLookupTables(expr Expr) bool {
+	var found bool
+	expr.Walk(func(e interface{}) {
+		if _, ok := e.(*LookupExpr); ok {
+			found = true
+		}
+	})
+	return found
+}
+
+// ResolveLookups loads the tables of all the lookup stages of the expression from the lookup
+// tables of the context. It must be called before building the pipeline of an expression using lookups.
+func ResolveLookups(ctx context.Context, expr Expr) error {
+	var lookups []*LookupExpr
+	expr.Walk(func(e interface{}) {
+		if l, ok := e.(*LookupExpr); ok {
+			lookups = append(lookups, l)
+		}
+	})
+	if len(lookups) == 0 {
+		return nil
+	}
+	tables, ok := ctx.Value(lookupCtxKey).(LookupTables)
+	if !ok || tables == nil {
+		return logqlmodel.NewParseError(fmt.Sprintf("lookup tables are not enabled, can't use %s", OpLookup), 0, 0)
+	}
+	for _, l := range lookups {
+		values, err := tables.Table(ctx, l.Table)
+		if err != nil {
+			return err
+		}
+		l.values = values
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package logql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logqlmodel"
+)
+
+type fakeLookupTables map[string]map[string]string
+
+func (f fakeLookupTables) Table(_ context.Context, name string) (map[string]string, error) {
+	t, ok := f[name]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return t, nil
+}
+
+func TestResolveLookups(t *testing.T) {
+	tables := fakeLookupTables{"owners": {"api": "team-a"}}
+
+	expr, err := ParseSampleExpr(`count_over_time({app="foo"} | logfmt | lookup owner=owners[service] [1m])`)
+	require.NoError(t, err)
+	_, err = expr.Extractor()
+	require.Error(t, err)
+
+	require.NoError(t, ResolveLookups(InjectLookupTables(context.Background(), tables), expr))
+	extractor, err := expr.Extractor()
+	require.NoError(t, err)
+	_, lbs, ok := extractor.ForStream(labels.Labels{{Name: "app", Value: "foo"}}).Process([]byte("service=api"))
+	require.True(t, ok)
+	require.Equal(t, `{app="foo", owner="team-a", service="api"}`, lbs.String())
+
+	logExpr, err := ParseLogSelector(`{app="foo"} | lookup owner=regions[region]`, true)
+	require.NoError(t, err)
+	require.EqualError(t, ResolveLookups(InjectLookupTables(context.Background(), tables), logExpr), "not found")
+
+	err = ResolveLookups(context.Background(), logExpr)
+	require.True(t, errors.Is(err, logqlmodel.ErrParse))
+
+	// expressions without lookups don't need the tables.
+	logExpr, err = ParseLogSelector(`{app="foo"} | logfmt`, true)
+	require.NoError(t, err)
+	require.NoError(t, ResolveLookups(context.Background(), logExpr))
+}
@@ -53,6 +53,7 @@ type parser struct {
 
 func (p *parser) Parse() (Expr, error) {
 	p.lexer.errs = p.lexer.errs[:0]
+	p.lexer.lookup = false
 	p.lexer.Scanner.Error = func(_ *scanner.Scanner, msg string) {
 		p.lexer.Error(msg)
 	}
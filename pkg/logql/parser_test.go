@@ -340,6 +340,34 @@ func TestParse(t *testing.T) {
 			in:  `label_replace(rate({ foo = "bar" }[5m]),"foo","$1","bar","^^^^x43\\q")`,
 			err: logqlmodel.NewParseError("invalid regex in label_replace: error parsing regexp: invalid escape sequence: `\\q`", 0, 0),
 		},
+		{
+			in:  `label_join(rate({ foo = "bar" }[5m]),"foo")`,
+			err: logqlmodel.NewParseError(`syntax error: unexpected ), expecting ,`, 1, 43),
+		},
+		{
+			in:  `label_join(rate({ foo = "bar" }[5m]),"foo-bar","-","bar")`,
+			err: logqlmodel.NewParseError("invalid destination label name in label_join: foo-bar", 0, 0),
+		},
+		{
+			in:  `label_join(rate({ foo = "bar" }[5m]),"foo","-","bar","1buzz")`,
+			err: logqlmodel.NewParseError("invalid source label name in label_join: 1buzz", 0, 0),
+		},
+		{
+			in:  `count_over_time({ foo = "bar" }[25h], date("fortnight"))`,
+			err: logqlmodel.NewParseError("invalid date bucket unit: fortnight", 0, 0),
+		},
+		{
+			in:  `count_over_time({ foo = "bar" }[25h], date("day", "Mars/Olympus_Mons"))`,
+			err: logqlmodel.NewParseError("invalid time zone in date bucket: Mars/Olympus_Mons", 0, 0),
+		},
+		{
+			in:  `count_over_time({ foo = "bar" }[1d], date("day", "Europe/Berlin"))`,
+			err: logqlmodel.NewParseError("range 1d is shorter than the longest day bucket, use a range of at least 1d1h", 0, 0),
+		},
+		{
+			in:  `rate({ foo = "bar" }[25h], date("day"))`,
+			err: logqlmodel.NewParseError("date bucketing not allowed for rate aggregation", 0, 0),
+		},
 		{
 			in:  `rate({ foo = "bar" }[5)`,
 			err: logqlmodel.NewParseError("missing closing ']' in duration", 0, 21),
@@ -1403,6 +1431,19 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			in: `{app="foo"} | regexp "(?P<status>\\d+) OK|(?P<status>\\d+) ERROR" first_match_only`,
+			exp: &PipelineExpr{
+				Left: newMatcherExpr([]*labels.Matcher{{Type: labels.MatchEqual, Name: "app", Value: "foo"}}),
+				MultiStages: MultiStageExpr{
+					&LabelParserExpr{Op: OpParserTypeRegexp, Param: `(?P<status>\d+) OK|(?P<status>\d+) ERROR`, FirstMatchOnly: true},
+				},
+			},
+		},
+		{
+			in:  `{app="foo"} | regexp "(?P<status>\\d+)" last_match`,
+			err: logqlmodel.NewParseError("unexpected regexp parser option last_match, expecting first_match_only", 0, 0),
+		},
 		{
 			in: `{app="foo"} |= "bar" | json | ( status_code < 500 and status_code > 200) or latency >= 250ms `,
 			exp: &PipelineExpr{
@@ -1548,6 +1589,20 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			in: `{app="foo"} | logfmt | lookup owner = service_owners[service]`,
+			exp: &PipelineExpr{
+				Left: newMatcherExpr([]*labels.Matcher{{Type: labels.MatchEqual, Name: "app", Value: "foo"}}),
+				MultiStages: MultiStageExpr{
+					newLabelParserExpr(OpParserTypeLogfmt, ""),
+					newLookupExpr("owner", "service_owners", "service"),
+				},
+			},
+		},
+		{
+			in:  `{app="foo"} | lookup owner = service_owners`,
+			err: logqlmodel.NewParseError("syntax error: unexpected $end, expecting [", 1, 44),
+		},
 		{
 			in: `count_over_time({app="foo"} |= "bar" | json | latency >= 250ms or ( status_code < 500 and status_code > 200)
 			| line_format "blip{{ .foo }}blop {{.status_code}}" | label_format foo=bar,status_code="buzz{{.bar}}"[5m])`,
@@ -1596,6 +1651,46 @@ func TestParse(t *testing.T) {
 			exp: nil,
 			err: logqlmodel.NewParseError("invalid aggregation count_over_time with unwrap", 0, 0),
 		},
+		{
+			in: `count_distinct_over_time({app="foo"} | json | unwrap user [5m]) by (namespace)`,
+			exp: newRangeAggregationExpr(
+				newLogRange(&PipelineExpr{
+					Left: newMatcherExpr([]*labels.Matcher{{Type: labels.MatchEqual, Name: "app", Value: "foo"}}),
+					MultiStages: MultiStageExpr{
+						newLabelParserExpr(OpParserTypeJSON, ""),
+					},
+				},
+					5*time.Minute,
+					newUnwrapExpr("user", ""),
+					nil),
+				OpRangeTypeCountDistinct, &Grouping{Groups: []string{"namespace"}}, nil,
+			),
+		},
+		{
+			in: `approx_count_distinct_over_time({app="foo"} | logfmt | unwrap user [5m])`,
+			exp: newRangeAggregationExpr(
+				newLogRange(&PipelineExpr{
+					Left: newMatcherExpr([]*labels.Matcher{{Type: labels.MatchEqual, Name: "app", Value: "foo"}}),
+					MultiStages: MultiStageExpr{
+						newLabelParserExpr(OpParserTypeLogfmt, ""),
+					},
+				},
+					5*time.Minute,
+					newUnwrapExpr("user", ""),
+					nil),
+				OpRangeTypeApproxCountDistinct, nil, nil,
+			),
+		},
+		{
+			in:  `count_distinct_over_time({app="foo"} | json [5m])`,
+			exp: nil,
+			err: logqlmodel.NewParseError("invalid aggregation count_distinct_over_time without unwrap", 0, 0),
+		},
+		{
+			in:  `count_distinct_over_time({app="foo"} | json | unwrap bytes(size) [5m])`,
+			exp: nil,
+			err: logqlmodel.NewParseError("conversion function bytes not allowed for count_distinct_over_time aggregation", 0, 0),
+		},
 		{
 			in: `{app="foo"} |= "bar" | json |  status_code < 500 or status_code > 200 and size >= 2.5KiB `,
 			exp: &PipelineExpr{
@@ -2580,6 +2675,56 @@ func TestParse(t *testing.T) {
 				),
 			),
 		},
+		{
+			in: `sum by (app) (count_over_time({app="foo"}[25h], date("day", "Europe/Berlin")))`,
+			exp: mustNewVectorAggregationExpr(
+				withDateBucket(
+					newRangeAggregationExpr(
+						&LogRange{
+							Left:     newMatcherExpr([]*labels.Matcher{{Type: labels.MatchEqual, Name: "app", Value: "foo"}}),
+							Interval: 25 * time.Hour,
+						},
+						OpRangeTypeCount, nil, nil,
+					),
+					mustNewDateBucket(DateUnitDay, "Europe/Berlin"),
+				),
+				OpTypeSum,
+				&Grouping{Groups: []string{"app"}},
+				nil,
+			),
+		},
+		{
+			in: `quantile_over_time(0.99, {app="foo"} | unwrap latency [745h], date("month")) by (date)`,
+			exp: withDateBucket(
+				newRangeAggregationExpr(
+					&LogRange{
+						Left:     newMatcherExpr([]*labels.Matcher{{Type: labels.MatchEqual, Name: "app", Value: "foo"}}),
+						Interval: 745 * time.Hour,
+						Unwrap:   newUnwrapExpr("latency", ""),
+					},
+					OpRangeTypeQuantile, &Grouping{Groups: []string{"date"}}, NewStringLabelFilter("0.99"),
+				),
+				mustNewDateBucket(DateUnitMonth, "UTC"),
+			),
+		},
+		{
+			in: `label_join(sum by (app, machine) (count_over_time({app="foo"}[1m])), "instance", ":", "app", "machine")`,
+			exp: mustNewLabelJoinExpr(
+				mustNewVectorAggregationExpr(
+					newRangeAggregationExpr(
+						&LogRange{
+							Left:     newMatcherExpr([]*labels.Matcher{{Type: labels.MatchEqual, Name: "app", Value: "foo"}}),
+							Interval: 1 * time.Minute,
+						},
+						OpRangeTypeCount, nil, nil,
+					),
+					OpTypeSum,
+					&Grouping{Groups: []string{"app", "machine"}},
+					nil,
+				),
+				"instance", ":", []string{"app", "machine"},
+			),
+		},
 		{
 			in: `
 			label_replace(
@@ -2951,6 +3096,25 @@ func TestParse(t *testing.T) {
 	}
 }
 
+// lookup is only a keyword as a pipeline stage.
+func TestParse_LookupLabelName(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		exp string
+	}{
+		{`{lookup="x"}`, `{lookup="x"}`},
+		{`{app="foo"} | logfmt | lookup="nxdomain"`, `{app="foo"} | logfmt | lookup="nxdomain"`},
+		{`sum by (lookup) (count_over_time({app="foo"}[5m]))`, `sum by(lookup)(count_over_time({app="foo"}[5m]))`},
+		{`{app="foo"} | logfmt | lookup owner=service_owners[service] | lookup!=""`, `{app="foo"} | logfmt | lookup owner=service_owners[service] | lookup!=""`},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			expr, err := ParseExpr(tc.in)
+			require.NoError(t, err)
+			require.Equal(t, tc.exp, expr.String())
+		})
+	}
+}
+
 func TestParseMatchers(t *testing.T) {
 	tests := []struct {
 		input   string
@@ -30,6 +30,9 @@ type rangeVectorIterator struct {
 	window                               map[string]*promql.Series
 	metrics                              map[string]labels.Labels
 	at                                   []promql.Sample
+
+	// date clamps the range to the calendar bucket ending at or containing the current step.
+	date *DateBucket
 }
 
 func newRangeVectorIterator(
@@ -63,6 +66,11 @@ func (r *rangeVectorIterator) Next() bool {
 	}
 	rangeEnd := r.current
 	rangeStart := rangeEnd - r.selRange
+	if r.date != nil {
+		if start := r.date.start(rangeEnd); start > rangeStart {
+			rangeStart = start
+		}
+	}
 	// load samples
 	r.popBack(rangeStart)
 	r.load(rangeStart, rangeEnd)
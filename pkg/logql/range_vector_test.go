@@ -213,3 +213,38 @@ func Test_RangeVectorIteratorBadLabels(t *testing.T) {
 	case <-ctx.Done():
 	}
 }
+
+func Test_RangeVectorIteratorDateBucket(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// Clocks are set back on 2026-10-25 in Berlin, making that day 25 hours long.
+	it := iter.NewPeekingSampleIterator(iter.NewSeriesIterator(logproto.Series{
+		Labels: labelFoo.String(),
+		Samples: []logproto.Sample{
+			{Timestamp: time.Date(2026, 10, 24, 12, 0, 0, 0, berlin).UnixNano(), Hash: 1, Value: 1.},
+			{Timestamp: time.Date(2026, 10, 24, 23, 30, 0, 0, berlin).UnixNano(), Hash: 2, Value: 1.},
+			{Timestamp: time.Date(2026, 10, 25, 0, 30, 0, 0, berlin).UnixNano(), Hash: 3, Value: 1.},
+			{Timestamp: time.Date(2026, 10, 25, 23, 30, 0, 0, berlin).UnixNano(), Hash: 4, Value: 1.},
+			{Timestamp: time.Date(2026, 10, 26, 0, 30, 0, 0, berlin).UnixNano(), Hash: 5, Value: 1.},
+		},
+	}))
+	start := time.Date(2026, 10, 25, 0, 0, 0, 0, berlin)
+	rangeIt := newRangeVectorIterator(it, (25 * time.Hour).Nanoseconds(), (25 * time.Hour).Nanoseconds(),
+		start.UnixNano(), start.Add(50*time.Hour).UnixNano(), 0)
+	rangeIt.date = mustNewDateBucket(DateUnitDay, "Europe/Berlin")
+
+	expected := []promql.Vector{
+		{{Point: newPoint(start, 2), Metric: labelFoo}},
+		{{Point: newPoint(start.Add(25*time.Hour), 2), Metric: labelFoo}},
+		// the last step is at 01:00 on 2026-10-27, only the ongoing day is counted.
+		{},
+	}
+	i := 0
+	for rangeIt.Next() {
+		_, v := rangeIt.At(countOverTime)
+		require.ElementsMatch(t, expected[i], v)
+		i++
+	}
+	require.Equal(t, len(expected), i)
+}
@@ -36,9 +36,11 @@ func TestMappingEquivalence(t *testing.T) {
 		{`1 + 1`, false},
 		{`{a="1"}`, false},
 		{`{a="1"} |= "number: 10"`, false},
+		{`{a="1"} | pattern "line number: <n>" | n="10"`, false},
 		{`rate({a=~".+"}[1s])`, false},
 		{`sum by (a) (rate({a=~".+"}[1s]))`, false},
 		{`sum(rate({a=~".+"}[1s]))`, false},
+		{`sum by (a) (count_over_time({a=~".+"} | pattern "<_> number: <n>" [1s]))`, false},
 		{`max without (a) (rate({a=~".+"}[1s]))`, false},
 		{`count(rate({a=~".+"}[1s]))`, false},
 		{`avg(rate({a=~".+"}[1s]))`, true},
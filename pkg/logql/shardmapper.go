@@ -134,6 +134,8 @@ func (m ShardMapper) Map(expr Expr, r *shardRecorder) (Expr, error) {
 		return m.mapVectorAggregationExpr(e, r)
 	case *LabelReplaceExpr:
 		return m.mapLabelReplaceExpr(e, r)
+	case *LabelJoinExpr:
+		return m.mapLabelJoinExpr(e, r)
 	case *RangeAggregationExpr:
 		return m.mapRangeAggregationExpr(e, r), nil
 	case *BinOpExpr:
@@ -287,6 +289,16 @@ func (m ShardMapper) mapLabelReplaceExpr(expr *LabelReplaceExpr, r *shardRecorde
 	return &cpy, nil
 }
 
+func (m ShardMapper) mapLabelJoinExpr(expr *LabelJoinExpr, r *shardRecorder) (SampleExpr, error) {
+	subMapped, err := m.Map(expr.Left, r)
+	if err != nil {
+		return nil, err
+	}
+	cpy := *expr
+	cpy.Left = subMapped.(SampleExpr)
+	return &cpy, nil
+}
+
 func (m ShardMapper) mapRangeAggregationExpr(expr *RangeAggregationExpr, r *shardRecorder) SampleExpr {
 	if hasLabelModifier(expr) {
 		// if an expr can modify labels this means multiple shards can returns the same labelset.
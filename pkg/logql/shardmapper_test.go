@@ -170,6 +170,10 @@ func TestMappingStrings(t *testing.T) {
 			in:  `sum by (cluster) (sum_over_time({foo="bar"} |= "id=123" | logfmt | unwrap latency [5m]))`,
 			out: `sum by(cluster)(downstream<sum by(cluster)(sum_over_time({foo="bar"}|="id=123"| logfmt | unwrap latency[5m])), shard=0_of_2> ++ downstream<sum by(cluster)(sum_over_time({foo="bar"}|="id=123"| logfmt | unwrap latency[5m])), shard=1_of_2>)`,
 		},
+		{
+			in:  `sum by (method) (rate({foo="bar"} | pattern "<ip> - - <_> \"<method> <uri> <_>\"" [5m]))`,
+			out: `sum by(method)(downstream<sum by(method)(rate({foo="bar"} | pattern "<ip> - - <_> \"<method> <uri> <_>\""[5m])), shard=0_of_2> ++ downstream<sum by(method)(rate({foo="bar"} | pattern "<ip> - - <_> \"<method> <uri> <_>\""[5m])), shard=1_of_2>)`,
+		},
 		{
 			in:  `sum by (cluster) (stddev_over_time({foo="bar"} |= "id=123" | logfmt | unwrap latency [5m]))`,
 			out: `sum by (cluster) (stddev_over_time({foo="bar"} |= "id=123" | logfmt | unwrap latency [5m]))`,
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/dustin/go-humanize"
 	"github.com/prometheus/prometheus/pkg/labels"
 )
 
@@ -86,6 +87,13 @@ func NewSeriesLimitError(limit int) *LimitError {
 	}
 }
 
+// NewMemoryLimitError returns the error of a query cancelled because it exceeded its memory budget.
+func NewMemoryLimitError(limit int64) *LimitError {
+	return &LimitError{
+		error: fmt.Errorf("query cancelled: the query exceeded its memory budget of %s, reduce the time range or make the query more selective", humanize.IBytes(uint64(limit))),
+	}
+}
+
 // Is allows to use errors.Is(err,ErrLimit) on this error.
 func (e LimitError) Is(target error) bool {
 	return target == ErrLimit
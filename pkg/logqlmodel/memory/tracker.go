@@ -0,0 +1,106 @@
+/*
+Package memory provides a tracker accounting the memory used by a query across the query path,
+so that a query exceeding the memory budget of its tenant is cancelled before it can exhaust the
+memory of the node. The tracker is passed through the query context.
+To start tracking the memory of a query use:
+
+	tracker, ctx := memory.NewContext(ctx, limit)
+	defer tracker.Close()
+
+The iterators then account and release the memory they hold using:
+
+	memory.FromContext(ctx).Add(bytes)
+	memory.FromContext(ctx).Release(bytes)
+
+When the budget is exceeded the context is cancelled, and tracker.Err() returns the error to report.
+*/
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/atomic"
+
+	"github.com/grafana/loki/pkg/logqlmodel"
+)
+
+type ctxKeyType string
+
+const memoryKey ctxKeyType = "memory"
+
+// Tracker accounts the memory used by a query. It is safe for concurrent use.
+type Tracker struct {
+	limit  int64
+	used   atomic.Int64
+	peak   atomic.Int64
+	cancel context.CancelFunc
+
+	once sync.Once
+	err  atomic.Error
+}
+
+// NewContext creates a new memory tracker with the given limit in bytes, 0 meaning no limit.
+// The returned context is cancelled when the limit is exceeded, the tracker must be closed
+// once the query is done to release the context.
+func NewContext(ctx context.Context, limit int64) (*Tracker, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	t := &Tracker{limit: limit, cancel: cancel}
+	return t, context.WithValue(ctx, memoryKey, t)
+}
+
+// FromContext returns the memory tracker of the context, or a tracker without limit if there is none.
+func FromContext(ctx context.Context) *Tracker {
+	t, ok := ctx.Value(memoryKey).(*Tracker)
+	if !ok {
+		return &Tracker{}
+	}
+	return t
+}
+
+// Add accounts n bytes, and returns an error if the limit is exceeded.
+func (t *Tracker) Add(n int64) error {
+	used := t.used.Add(n)
+	for {
+		peak := t.peak.Load()
+		if used <= peak || t.peak.CAS(peak, used) {
+			break
+		}
+	}
+	if t.limit <= 0 || used <= t.limit {
+		return nil
+	}
+
+	t.once.Do(func() {
+		t.err.Store(logqlmodel.NewMemoryLimitError(t.limit))
+		t.cancel()
+	})
+	return t.err.Load()
+}
+
+// Release releases n bytes accounted previously.
+func (t *Tracker) Release(n int64) {
+	t.used.Sub(n)
+}
+
+// Close releases the context of the tracker.
+func (t *Tracker) Close() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+}
+
+// Err returns the error of the query if it exceeded the limit, nil otherwise.
+func (t *Tracker) Err() error {
+	return t.err.Load()
+}
+
+// Used returns the bytes currently accounted.
+func (t *Tracker) Used() int64 {
+	return t.used.Load()
+}
+
+// Peak returns the maximum of bytes accounted at once.
+func (t *Tracker) Peak() int64 {
+	return t.peak.Load()
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logqlmodel"
+)
+
+func TestTracker(t *testing.T) {
+	tracker, ctx := NewContext(context.Background(), 100)
+	require.Same(t, tracker, FromContext(ctx))
+
+	require.NoError(t, FromContext(ctx).Add(60))
+	FromContext(ctx).Release(20)
+	require.NoError(t, FromContext(ctx).Add(60))
+	require.Equal(t, int64(100), tracker.Used())
+	require.NoError(t, ctx.Err())
+	require.NoError(t, tracker.Err())
+
+	err := FromContext(ctx).Add(1)
+	require.True(t, errors.Is(err, logqlmodel.ErrLimit))
+	require.EqualError(t, err, "query cancelled: the query exceeded its memory budget of 100 B, reduce the time range or make the query more selective")
+	require.Equal(t, context.Canceled, ctx.Err())
+	require.Equal(t, err, tracker.Err())
+
+	FromContext(ctx).Release(101)
+	require.Equal(t, int64(0), tracker.Used())
+	require.Equal(t, int64(101), tracker.Peak())
+	// the query stays cancelled.
+	require.Equal(t, err, tracker.Err())
+}
+
+func TestTracker_NoLimit(t *testing.T) {
+	tracker, ctx := NewContext(context.Background(), 0)
+	require.NoError(t, tracker.Add(1<<40))
+	require.NoError(t, ctx.Err())
+
+	// closing the tracker releases its context, without error.
+	tracker.Close()
+	require.Equal(t, context.Canceled, ctx.Err())
+	require.NoError(t, tracker.Err())
+
+	// without a tracker in the context, the memory is not limited.
+	require.NoError(t, FromContext(context.Background()).Add(1<<40))
+	FromContext(context.Background()).Close()
+}
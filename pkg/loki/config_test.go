@@ -88,3 +88,19 @@ func TestCrossComponentValidation(t *testing.T) {
 		}
 	}
 }
+
+func TestImmutableRangeValidation(t *testing.T) {
+	cfg := &Config{}
+	cfg.RegisterFlags(flag.NewFlagSet("test", 0))
+	cfg.SchemaConfig.Configs = []chunk.PeriodConfig{{Schema: "v11", From: chunk.DayTime{Time: model.Now()}}}
+	cfg.Frontend.ImmutableRangeMaxAge = time.Hour
+	cfg.Querier.QueryIngestersWithin = 0
+	require.Error(t, cfg.Validate())
+
+	cfg.Frontend.ImmutableRangeAfter = 2 * time.Hour
+	require.NoError(t, cfg.Validate())
+
+	cfg.Frontend.ImmutableRangeAfter = 0
+	cfg.Querier.QueryIngestersWithin = 3 * time.Hour
+	require.NoError(t, cfg.Validate())
+}
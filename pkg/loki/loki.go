@@ -30,10 +30,12 @@ import (
 	"google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/grafana/loki/pkg/distributor"
+	"github.com/grafana/loki/pkg/export"
 	"github.com/grafana/loki/pkg/ingester"
 	"github.com/grafana/loki/pkg/ingester/client"
 	"github.com/grafana/loki/pkg/loki/common"
 	"github.com/grafana/loki/pkg/lokifrontend"
+	"github.com/grafana/loki/pkg/lookup"
 	"github.com/grafana/loki/pkg/querier"
 	"github.com/grafana/loki/pkg/querier/queryrange"
 	"github.com/grafana/loki/pkg/querier/worker"
@@ -50,30 +52,34 @@ import (
 
 // Config is the root config for Loki.
 type Config struct {
-	Target      flagext.StringSliceCSV `yaml:"target,omitempty"`
-	AuthEnabled bool                   `yaml:"auth_enabled,omitempty"`
-	HTTPPrefix  string                 `yaml:"http_prefix"`
-
-	Common           common.Config            `yaml:"common,omitempty"`
-	Server           server.Config            `yaml:"server,omitempty"`
-	Distributor      distributor.Config       `yaml:"distributor,omitempty"`
-	Querier          querier.Config           `yaml:"querier,omitempty"`
-	IngesterClient   client.Config            `yaml:"ingester_client,omitempty"`
-	Ingester         ingester.Config          `yaml:"ingester,omitempty"`
-	StorageConfig    storage.Config           `yaml:"storage_config,omitempty"`
-	ChunkStoreConfig storage.ChunkStoreConfig `yaml:"chunk_store_config,omitempty"`
-	SchemaConfig     storage.SchemaConfig     `yaml:"schema_config,omitempty"`
-	LimitsConfig     validation.Limits        `yaml:"limits_config,omitempty"`
-	TableManager     chunk.TableManagerConfig `yaml:"table_manager,omitempty"`
-	Worker           worker.Config            `yaml:"frontend_worker,omitempty"`
-	Frontend         lokifrontend.Config      `yaml:"frontend,omitempty"`
-	Ruler            ruler.Config             `yaml:"ruler,omitempty"`
-	QueryRange       queryrange.Config        `yaml:"query_range,omitempty"`
-	RuntimeConfig    runtimeconfig.Config     `yaml:"runtime_config,omitempty"`
-	MemberlistKV     memberlist.KVConfig      `yaml:"memberlist"`
-	Tracing          tracing.Config           `yaml:"tracing"`
-	CompactorConfig  compactor.Config         `yaml:"compactor,omitempty"`
-	QueryScheduler   scheduler.Config         `yaml:"query_scheduler"`
+	Target        flagext.StringSliceCSV         `yaml:"target,omitempty"`
+	AuthEnabled   bool                           `yaml:"auth_enabled,omitempty"`
+	Impersonation serverutil.ImpersonationConfig `yaml:"impersonation,omitempty"`
+	HTTPPrefix    string                         `yaml:"http_prefix"`
+
+	Common           common.Config                    `yaml:"common,omitempty"`
+	Server           server.Config                    `yaml:"server,omitempty"`
+	Distributor      distributor.Config               `yaml:"distributor,omitempty"`
+	Querier          querier.Config                   `yaml:"querier,omitempty"`
+	IngesterClient   client.Config                    `yaml:"ingester_client,omitempty"`
+	Ingester         ingester.Config                  `yaml:"ingester,omitempty"`
+	StorageConfig    storage.Config                   `yaml:"storage_config,omitempty"`
+	ChunkStoreConfig storage.ChunkStoreConfig         `yaml:"chunk_store_config,omitempty"`
+	SchemaConfig     storage.SchemaConfig             `yaml:"schema_config,omitempty"`
+	LimitsConfig     validation.Limits                `yaml:"limits_config,omitempty"`
+	TenantHierarchy  validation.TenantHierarchyConfig `yaml:"tenant_hierarchy,omitempty"`
+	TableManager     chunk.TableManagerConfig         `yaml:"table_manager,omitempty"`
+	Worker           worker.Config                    `yaml:"frontend_worker,omitempty"`
+	Frontend         lokifrontend.Config              `yaml:"frontend,omitempty"`
+	Ruler            ruler.Config                     `yaml:"ruler,omitempty"`
+	QueryRange       queryrange.Config                `yaml:"query_range,omitempty"`
+	RuntimeConfig    runtimeconfig.Config             `yaml:"runtime_config,omitempty"`
+	MemberlistKV     memberlist.KVConfig              `yaml:"memberlist"`
+	Tracing          tracing.Config                   `yaml:"tracing"`
+	CompactorConfig  compactor.Config                 `yaml:"compactor,omitempty"`
+	QueryScheduler   scheduler.Config                 `yaml:"query_scheduler"`
+	Exporter         export.Config                    `yaml:"exporter,omitempty"`
+	LookupTables     lookup.Config                    `yaml:"lookup_tables,omitempty"`
 }
 
 // RegisterFlags registers flag.
@@ -87,6 +93,7 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 		"The alias 'all' can be used in the list to load a number of core modules and will enable single-binary mode. "+
 		"The aliases 'read' and 'write' can be used to only run components related to the read path or write path, respectively.")
 	f.BoolVar(&c.AuthEnabled, "auth.enabled", true, "Set to false to disable auth.")
+	c.Impersonation.RegisterFlags(f)
 
 	c.registerServerFlagsWithChangedDefaultValues(f)
 	c.Common.RegisterFlags(f)
@@ -98,6 +105,7 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	c.ChunkStoreConfig.RegisterFlags(f)
 	c.SchemaConfig.RegisterFlags(f)
 	c.LimitsConfig.RegisterFlags(f)
+	c.TenantHierarchy.RegisterFlags(f)
 	c.TableManager.RegisterFlags(f)
 	c.Frontend.RegisterFlags(f)
 	c.Ruler.RegisterFlags(f)
@@ -108,6 +116,8 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	c.Tracing.RegisterFlags(f)
 	c.CompactorConfig.RegisterFlags(f)
 	c.QueryScheduler.RegisterFlags(f)
+	c.Exporter.RegisterFlags(f)
+	c.LookupTables.RegisterFlags(f)
 }
 
 func (c *Config) registerServerFlagsWithChangedDefaultValues(fs *flag.FlagSet) {
@@ -151,6 +161,9 @@ func (c *Config) Validate() error {
 	if err := c.QueryRange.Validate(); err != nil {
 		return errors.Wrap(err, "invalid queryrange config")
 	}
+	if err := c.Querier.Validate(); err != nil {
+		return errors.Wrap(err, "invalid querier config")
+	}
 	if err := c.TableManager.Validate(); err != nil {
 		return errors.Wrap(err, "invalid tablemanager config")
 	}
@@ -163,6 +176,12 @@ func (c *Config) Validate() error {
 	if err := c.LimitsConfig.Validate(); err != nil {
 		return errors.Wrap(err, "invalid limits config")
 	}
+	if err := c.TenantHierarchy.Validate(); err != nil {
+		return errors.Wrap(err, "invalid tenant hierarchy config")
+	}
+	if err := c.IngesterClient.Validate(); err != nil {
+		return errors.Wrap(err, "invalid ingester client config")
+	}
 	if err := c.Worker.Validate(util_log.Logger); err != nil {
 		return errors.Wrap(err, "invalid storage config")
 	}
@@ -172,6 +191,12 @@ func (c *Config) Validate() error {
 	if err := c.CompactorConfig.Validate(); err != nil {
 		return errors.Wrap(err, "invalid compactor config")
 	}
+	if err := c.Exporter.Validate(); err != nil {
+		return errors.Wrap(err, "invalid exporter config")
+	}
+	if err := c.LookupTables.Validate(); err != nil {
+		return errors.Wrap(err, "invalid lookup tables config")
+	}
 	if err := c.ChunkStoreConfig.Validate(util_log.Logger); err != nil {
 		return errors.Wrap(err, "invalid chunk store config")
 	}
@@ -190,6 +215,13 @@ func (c *Config) Validate() error {
 			)
 		}
 	}
+	if c.Frontend.HistoricalDownstreamURL != "" && c.Frontend.HistoricalQueriesAfter == 0 && c.Querier.QueryIngestersWithin == 0 {
+		return errors.New("invalid frontend config: historical_queries_after or querier query_ingesters_within must be set to use historical_downstream_url")
+	}
+	// Without cutoff every query ending in the past would be immutable, and cached.
+	if c.Frontend.ImmutableRangeMaxAge > 0 && c.Frontend.ImmutableRangeAfter == 0 && c.Querier.QueryIngestersWithin == 0 {
+		return errors.New("invalid frontend config: immutable_range_after or querier query_ingesters_within must be set to use immutable_range_max_age")
+	}
 	return nil
 }
 
@@ -221,6 +253,7 @@ type Loki struct {
 	Querier                  *querier.Querier
 	ingesterQuerier          *querier.IngesterQuerier
 	Store                    storage.Store
+	customIndexClients       *storage.CustomIndexClients
 	tableManager             *chunk.TableManager
 	frontend                 Frontend
 	ruler                    *cortex_ruler.Ruler
@@ -232,14 +265,22 @@ type Loki struct {
 	compactor                *compactor.Compactor
 	QueryFrontEndTripperware cortex_tripper.Tripperware
 	queryScheduler           *scheduler.Scheduler
+	exporter                 *export.Exporter
+	lookupTables             *lookup.Tables
 
 	HTTPAuthMiddleware middleware.Interface
+
+	// ConfigLoader enables the /-/reload endpoint when set.
+	ConfigLoader ConfigLoader
+	loadedCfg    Config
+	reloader     *configReloader
 }
 
 // New makes a new Loki.
 func New(cfg Config) (*Loki, error) {
 	loki := &Loki{
-		Cfg: cfg,
+		Cfg:       cfg,
+		loadedCfg: cfg,
 	}
 
 	loki.setupAuthMiddleware()
@@ -247,7 +288,7 @@ func New(cfg Config) (*Loki, error) {
 	if err := loki.setupModuleManager(); err != nil {
 		return nil, err
 	}
-	storage.RegisterCustomIndexClients(&loki.Cfg.StorageConfig, prometheus.DefaultRegisterer)
+	loki.customIndexClients = storage.RegisterCustomIndexClients(&loki.Cfg.StorageConfig, prometheus.DefaultRegisterer)
 
 	return loki, nil
 }
@@ -266,6 +307,13 @@ func (t *Loki) setupAuthMiddleware() {
 			"/schedulerpb.SchedulerForQuerier/QuerierLoop",
 			"/schedulerpb.SchedulerForQuerier/NotifyQuerierShutdown",
 		})
+
+	if len(t.Cfg.Impersonation.AdminTenants) > 0 {
+		t.HTTPAuthMiddleware = middleware.Merge(
+			t.HTTPAuthMiddleware,
+			serverutil.NewImpersonationMiddleware(t.Cfg.Impersonation, util_log.Logger, prometheus.DefaultRegisterer),
+		)
+	}
 }
 
 func (t *Loki) setupGRPCRecoveryMiddleware() {
@@ -312,6 +360,19 @@ func (t *Loki) Run() error {
 	// Each component serves its version.
 	t.Server.HTTP.Path("/loki/api/v1/status/buildinfo").Methods("GET").HandlerFunc(versionHandler())
 
+	if t.ConfigLoader != nil {
+		t.reloader = &configReloader{
+			load:      t.ConfigLoader,
+			overrides: func() *validation.Overrides { return t.overrides },
+			tenantLimits: func() *tenantLimitsFromRuntimeConfig {
+				l, _ := t.TenantLimits.(*tenantLimitsFromRuntimeConfig)
+				return l
+			},
+			current: t.loadedCfg,
+		}
+		t.Server.HTTP.Path("/-/reload").Methods("POST").HandlerFunc(t.reloadHandler)
+	}
+
 	t.Server.HTTP.Path("/debug/fgprof").Methods("GET", "POST").Handler(fgprof.Handler())
 
 	// Let's listen for events from this manager, and log them.
@@ -431,6 +492,8 @@ func (t *Loki) setupModuleManager() error {
 	mm.RegisterModule(Compactor, t.initCompactor)
 	mm.RegisterModule(IndexGateway, t.initIndexGateway)
 	mm.RegisterModule(QueryScheduler, t.initQueryScheduler)
+	mm.RegisterModule(Exporter, t.initExporter)
+	mm.RegisterModule(LookupTables, t.initLookupTables, modules.UserInvisibleModule)
 
 	mm.RegisterModule(All, nil)
 	mm.RegisterModule(Read, nil)
@@ -443,24 +506,25 @@ func (t *Loki) setupModuleManager() error {
 		OverridesExporter:        {Overrides, Server},
 		TenantConfigs:            {RuntimeConfig},
 		Distributor:              {Ring, Server, Overrides, TenantConfigs},
-		Store:                    {Overrides},
-		Ingester:                 {Store, Server, MemberlistKV, TenantConfigs},
-		Querier:                  {Store, Ring, Server, IngesterQuerier, TenantConfigs},
+		Store:                    {Overrides, TenantConfigs},
+		Ingester:                 {Store, Server, MemberlistKV, TenantConfigs, LookupTables},
+		Querier:                  {Store, Ring, Server, IngesterQuerier, TenantConfigs, LookupTables},
 		QueryFrontendTripperware: {Server, Overrides, TenantConfigs},
 		QueryFrontend:            {QueryFrontendTripperware},
 		QueryScheduler:           {Server, Overrides, MemberlistKV},
-		Ruler:                    {Ring, Server, Store, RulerStorage, IngesterQuerier, Overrides, TenantConfigs},
+		Ruler:                    {Ring, Server, Store, RulerStorage, IngesterQuerier, Overrides, TenantConfigs, LookupTables},
 		TableManager:             {Server},
 		Compactor:                {Server, Overrides, MemberlistKV},
-		IndexGateway:             {Server},
+		IndexGateway:             {Server, TenantConfigs},
+		Exporter:                 {Server, Store, IngesterQuerier, Overrides, TenantConfigs, LookupTables},
 		IngesterQuerier:          {Ring},
-		All:                      {QueryScheduler, QueryFrontend, Querier, Ingester, Distributor, Ruler, Compactor},
-		Read:                     {QueryScheduler, QueryFrontend, Querier, Ruler, Compactor},
+		All:                      {QueryScheduler, QueryFrontend, Querier, Ingester, Distributor, Ruler, Compactor, Exporter},
+		Read:                     {QueryScheduler, QueryFrontend, Querier, Ruler, Compactor, Exporter},
 		Write:                    {Ingester, Distributor},
 	}
 
 	// Add IngesterQuerier as a dependency for store when target is either ingester or querier.
-	if t.Cfg.isModuleEnabled(Querier) || t.Cfg.isModuleEnabled(Ruler) || t.Cfg.isModuleEnabled(Read) {
+	if t.Cfg.isModuleEnabled(Querier) || t.Cfg.isModuleEnabled(Ruler) || t.Cfg.isModuleEnabled(Exporter) || t.Cfg.isModuleEnabled(Read) {
 		deps[Store] = append(deps[Store], IngesterQuerier)
 	}
 
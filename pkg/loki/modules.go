@@ -16,6 +16,7 @@ import (
 	cortex_ruler "github.com/cortexproject/cortex/pkg/ruler"
 	"github.com/cortexproject/cortex/pkg/scheduler/schedulerpb"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/kv/codec"
 	"github.com/grafana/dskit/kv/memberlist"
@@ -30,11 +31,17 @@ import (
 	"github.com/weaveworks/common/user"
 
 	"github.com/grafana/loki/pkg/distributor"
+	"github.com/grafana/loki/pkg/export"
 	"github.com/grafana/loki/pkg/ingester"
+	"github.com/grafana/loki/pkg/ingester/client"
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/lokifrontend"
 	"github.com/grafana/loki/pkg/lokifrontend/frontend"
 	"github.com/grafana/loki/pkg/lokifrontend/frontend/transport"
+	"github.com/grafana/loki/pkg/lokifrontend/querylog"
+	"github.com/grafana/loki/pkg/lokifrontend/ui"
+	"github.com/grafana/loki/pkg/lookup"
 	"github.com/grafana/loki/pkg/querier"
 	"github.com/grafana/loki/pkg/querier/queryrange"
 	"github.com/grafana/loki/pkg/ruler"
@@ -51,6 +58,7 @@ import (
 	"github.com/grafana/loki/pkg/storage/stores/shipper/indexgateway"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/indexgateway/indexgatewaypb"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/uploads"
+	"github.com/grafana/loki/pkg/util/grpcencoding/zstd"
 	serverutil "github.com/grafana/loki/pkg/util/server"
 	"github.com/grafana/loki/pkg/validation"
 )
@@ -79,6 +87,8 @@ const (
 	Compactor                string = "compactor"
 	IndexGateway             string = "index-gateway"
 	QueryScheduler           string = "query-scheduler"
+	Exporter                 string = "exporter"
+	LookupTables             string = "lookup-tables"
 	All                      string = "all"
 	Read                     string = "read"
 	Write                    string = "write"
@@ -89,6 +99,10 @@ func (t *Loki) initServer() (services.Service, error) {
 
 	// Loki handles signals on its own.
 	DisableSignalHandling(&t.Cfg.Server)
+	// Let the zstd compressor decompress messages up to the max receive size of the server.
+	if err := zstd.SetMaxDecompressedSize(t.Cfg.Server.GPRCServerMaxRecvMsgSize); err != nil {
+		return nil, err
+	}
 	serv, err := server.New(t.Cfg.Server)
 	if err != nil {
 		return nil, err
@@ -146,21 +160,25 @@ func (t *Loki) initRuntimeConfig() (services.Service, error) {
 		return nil, nil
 	}
 
-	t.Cfg.RuntimeConfig.Loader = loadRuntimeConfig
+	t.Cfg.RuntimeConfig.Loader = runtimeConfigLoader(t.Cfg.TenantHierarchy)
 
 	// make sure to set default limits before we start loading configuration into memory
 	validation.SetDefaultLimitsForYAMLUnmarshalling(t.Cfg.LimitsConfig)
 
 	var err error
 	t.runtimeConfig, err = runtimeconfig.New(t.Cfg.RuntimeConfig, prometheus.WrapRegistererWithPrefix("loki_", prometheus.DefaultRegisterer), util_log.Logger)
-	t.TenantLimits = newtenantLimitsFromRuntimeConfig(t.runtimeConfig)
+	t.TenantLimits = newtenantLimitsFromRuntimeConfig(t.runtimeConfig, t.Cfg.RuntimeConfig, t.Cfg.TenantHierarchy)
 	return t.runtimeConfig, err
 }
 
 func (t *Loki) initOverrides() (_ services.Service, err error) {
 	t.overrides, err = validation.NewOverrides(t.Cfg.LimitsConfig, t.TenantLimits)
+	if err != nil {
+		return nil, err
+	}
+	t.overrides.SetTenantHierarchy(t.Cfg.TenantHierarchy)
 	// overrides are not a service, since they don't have any operational state.
-	return nil, err
+	return nil, nil
 }
 
 func (t *Loki) initOverridesExporter() (services.Service, error) {
@@ -187,7 +205,7 @@ func (t *Loki) initDistributor() (services.Service, error) {
 	t.Cfg.Distributor.DistributorRing.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.runtimeConfig)
 	t.Cfg.Distributor.DistributorRing.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
 	var err error
-	t.distributor, err = distributor.New(t.Cfg.Distributor, t.Cfg.IngesterClient, t.tenantConfigs, t.ring, t.overrides, prometheus.DefaultRegisterer)
+	t.distributor, err = distributor.New(t.Cfg.Distributor, t.Cfg.IngesterClient.ForPath(client.PushPath), t.tenantConfigs, t.ring, t.overrides, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, err
 	}
@@ -220,6 +238,9 @@ func (t *Loki) initQuerier() (services.Service, error) {
 	if err != nil {
 		return nil, err
 	}
+	if t.lookupTables != nil {
+		t.Querier.SetLookupTables(t.lookupTables)
+	}
 
 	querierWorkerServiceConfig := querier.WorkerServiceConfig{
 		AllEnabled:            t.Cfg.isModuleEnabled(All),
@@ -260,6 +281,11 @@ func (t *Loki) initQuerier() (services.Service, error) {
 		"/api/prom/tail":    http.HandlerFunc(t.Querier.TailHandler),
 	}
 
+	// Expose the sync status of the index downloaded by the boltdb-shipper, if the querier is not using an index gateway.
+	if boltDBShipper := t.customIndexClients.BoltDBShipper(); boltDBShipper != nil {
+		t.Server.HTTP.Path("/boltdb-shipper/sync_status").Methods("GET").Handler(http.HandlerFunc(boltDBShipper.SyncStatusHandler))
+	}
+
 	return querier.InitWorkerService(
 		querierWorkerServiceConfig, queryHandlers, alwaysExternalHandlers, t.Server.HTTP, t.Server.HTTPServer.Handler, t.HTTPAuthMiddleware,
 	)
@@ -274,6 +300,9 @@ func (t *Loki) initIngester() (_ services.Service, err error) {
 	if err != nil {
 		return
 	}
+	if t.lookupTables != nil {
+		t.Ingester.SetLookupTables(t.lookupTables)
+	}
 	logproto.RegisterPusherServer(t.Server.GRPC, t.Ingester)
 	logproto.RegisterQuerierServer(t.Server.GRPC, t.Ingester)
 	logproto.RegisterIngesterServer(t.Server.GRPC, t.Ingester)
@@ -283,6 +312,7 @@ func (t *Loki) initIngester() (_ services.Service, err error) {
 	)
 	t.Server.HTTP.Path("/flush").Methods("GET", "POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.Ingester.FlushHandler)))
 	t.Server.HTTP.Methods("POST").Path("/ingester/flush_shutdown").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.Ingester.ShutdownHandler)))
+	t.Server.HTTP.Methods("GET").Path("/ingester/last_entries").Handler(t.HTTPAuthMiddleware.Wrap(httpMiddleware.Wrap(http.HandlerFunc(t.Ingester.LastEntriesHandler))))
 
 	return t.Ingester, nil
 }
@@ -368,6 +398,8 @@ func (t *Loki) initStore() (_ services.Service, err error) {
 		}
 	}
 
+	t.Cfg.StorageConfig.Config.TenantS3Storage = t.tenantConfigs.S3Storage
+
 	chunkStore, err := chunk_storage.NewStore(t.Cfg.StorageConfig.Config, t.Cfg.ChunkStoreConfig.StoreConfig, t.Cfg.SchemaConfig.SchemaConfig, t.overrides, prometheus.DefaultRegisterer, nil, util_log.Logger)
 	if err != nil {
 		return
@@ -416,7 +448,7 @@ func (t *Loki) initStore() (_ services.Service, err error) {
 }
 
 func (t *Loki) initIngesterQuerier() (_ services.Service, err error) {
-	t.ingesterQuerier, err = querier.NewIngesterQuerier(t.Cfg.IngesterClient, t.ring, t.Cfg.Querier.ExtraQueryDelay)
+	t.ingesterQuerier, err = querier.NewIngesterQuerier(t.Cfg.IngesterClient.ForPath(client.QueryPath), t.ring, t.Cfg.Querier.ExtraQueryDelay)
 	if err != nil {
 		return nil, err
 	}
@@ -481,20 +513,55 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 		level.Debug(util_log.Logger).Log("msg", "no query frontend configured")
 	}
 
+	if t.Cfg.Frontend.HistoricalDownstreamURL != "" {
+		cutoff := t.Cfg.Frontend.HistoricalQueriesAfter
+		if cutoff == 0 {
+			cutoff = t.Cfg.Querier.QueryIngestersWithin
+		}
+		roundTripper, err = frontend.NewTimeRangeRoundTripper(roundTripper, t.Cfg.Frontend.HistoricalDownstreamURL, cutoff, prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	roundTripper = t.QueryFrontEndTripperware(roundTripper)
 
 	frontendHandler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, util_log.Logger, prometheus.DefaultRegisterer)
+	if t.Cfg.Frontend.ImmutableRangeMaxAge > 0 {
+		immutableAfter := t.Cfg.Frontend.ImmutableRangeAfter
+		if immutableAfter == 0 {
+			immutableAfter = t.Cfg.Querier.QueryIngestersWithin
+		}
+		frontendHandler = lokifrontend.CacheHeadersMiddleware(t.Cfg.Frontend.ImmutableRangeMaxAge, immutableAfter).Wrap(frontendHandler)
+	}
 	if t.Cfg.Frontend.CompressResponses {
 		frontendHandler = gziphandler.GzipHandler(frontendHandler)
 	}
 
-	frontendHandler = middleware.Merge(
+	middlewares := []middleware.Interface{
 		serverutil.RecoveryHTTPMiddleware,
 		t.HTTPAuthMiddleware,
-		queryrange.StatsHTTPMiddleware,
+	}
+	var recorder *querylog.Recorder
+	if t.Cfg.Frontend.QueryLogFile != "" {
+		recorder, err = querylog.NewRecorder(t.Cfg.Frontend.QueryLogFile, util_log.Logger)
+		if err != nil {
+			return nil, err
+		}
+		middlewares = append(middlewares, recorder.Middleware())
+	}
+	statsMiddleware := queryrange.StatsHTTPMiddleware
+	var slowQueries *queryrange.SlowQueries
+	if t.Cfg.QueryRange.SlowQueries.Enabled() {
+		slowQueries = queryrange.NewSlowQueries(t.Cfg.QueryRange.SlowQueries, util_log.Logger)
+		statsMiddleware = queryrange.NewStatsHTTPMiddleware(slowQueries)
+	}
+	middlewares = append(middlewares,
+		statsMiddleware,
 		serverutil.NewPrepopulateMiddleware(),
 		serverutil.ResponseJSONMiddleware(),
-	).Wrap(frontendHandler)
+	)
+	frontendHandler = middleware.Merge(middlewares...).Wrap(frontendHandler)
 
 	var defaultHandler http.Handler
 	// If this process also acts as a Querier we don't do any proxying of tail requests
@@ -529,6 +596,17 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 	t.Server.HTTP.Path("/api/prom/label").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/api/prom/label/{name}/values").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/api/prom/series").Methods("GET", "POST").Handler(frontendHandler)
+	if slowQueries != nil {
+		t.Server.HTTP.Path("/loki/api/v1/slow_queries").Methods("GET").Handler(
+			middleware.Merge(serverutil.RecoveryHTTPMiddleware, t.HTTPAuthMiddleware).Wrap(http.HandlerFunc(slowQueries.Handler)),
+		)
+	}
+
+	if t.Cfg.Frontend.UIEnabled {
+		// The redirect is relative to work behind a path prefix.
+		t.Server.HTTP.Path("/ui").Handler(http.RedirectHandler("ui/", http.StatusFound))
+		t.Server.HTTP.PathPrefix(ui.PathPrefix).Methods("GET").Handler(ui.Handler())
+	}
 
 	// Only register tailing requests if this process does not act as a Querier
 	// If this process is also a Querier the Querier will register the tail endpoints.
@@ -538,12 +616,22 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 		t.Server.HTTP.Path("/api/prom/tail").Methods("GET", "POST").Handler(defaultHandler)
 	}
 
+	closeRecorder := func() {
+		if recorder == nil {
+			return
+		}
+		if err := recorder.Close(); err != nil {
+			level.Warn(util_log.Logger).Log("msg", "failed to close query log", "err", err)
+		}
+	}
+
 	if t.frontend == nil {
 		return services.NewIdleService(nil, func(_ error) error {
 			if t.stopper != nil {
 				t.stopper.Stop()
 				t.stopper = nil
 			}
+			closeRecorder()
 			return nil
 		}), nil
 	}
@@ -560,6 +648,7 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 		if t.stopper != nil {
 			t.stopper.Stop()
 		}
+		closeRecorder()
 		return nil
 	}), nil
 }
@@ -605,6 +694,9 @@ func (t *Loki) initRuler() (_ services.Service, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if t.lookupTables != nil {
+		q.SetLookupTables(t.lookupTables)
+	}
 
 	engine := logql.NewEngine(t.Cfg.Querier.Engine, q, t.overrides)
 
@@ -714,11 +806,51 @@ func (t *Loki) initIndexGateway() (services.Service, error) {
 		return nil, err
 	}
 
-	gateway := indexgateway.NewIndexGateway(shipperIndexClient.(*shipper.Shipper))
+	t.Server.HTTP.Path("/boltdb-shipper/sync_status").Methods("GET").Handler(http.HandlerFunc(shipperIndexClient.(*shipper.Shipper).SyncStatusHandler))
+	shipperIndexClient = chunk_storage.NewTenantIndexClient(shipperIndexClient, t.tenantConfigs.S3Storage, loki_storage.NewTenantShipper(t.Cfg.StorageConfig.BoltDBShipperConfig))
+
+	gateway := indexgateway.NewIndexGateway(shipperIndexClient)
 	indexgatewaypb.RegisterIndexGatewayServer(t.Server.GRPC, gateway)
 	return gateway, nil
 }
 
+func (t *Loki) initExporter() (services.Service, error) {
+	if !t.Cfg.Exporter.Enabled {
+		return nil, nil
+	}
+
+	q, err := querier.New(t.Cfg.Querier, t.Store, t.ingesterQuerier, t.overrides)
+	if err != nil {
+		return nil, err
+	}
+	if t.lookupTables != nil {
+		q.SetLookupTables(t.lookupTables)
+	}
+	objectClient, err := storage.NewObjectClient(t.Cfg.Exporter.SharedStoreType, t.Cfg.StorageConfig.Config)
+	if err != nil {
+		return nil, err
+	}
+	t.exporter = export.New(t.Cfg.Exporter, q, objectClient, log.With(util_log.Logger, "component", "exporter"), prometheus.DefaultRegisterer)
+
+	t.Server.HTTP.Path("/loki/api/v1/export").Methods("POST").Handler(t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.exporter.SubmitHandler)))
+	t.Server.HTTP.Path("/loki/api/v1/export/{id}").Methods("GET").Handler(t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.exporter.StatusHandler)))
+
+	return t.exporter, nil
+}
+
+func (t *Loki) initLookupTables() (services.Service, error) {
+	if !t.Cfg.LookupTables.Enabled {
+		return nil, nil
+	}
+
+	objectClient, err := storage.NewObjectClient(t.Cfg.LookupTables.SharedStoreType, t.Cfg.StorageConfig.Config)
+	if err != nil {
+		return nil, err
+	}
+	t.lookupTables = lookup.New(t.Cfg.LookupTables, objectClient, log.With(util_log.Logger, "component", "lookup-tables"))
+	return nil, nil
+}
+
 func (t *Loki) initQueryScheduler() (services.Service, error) {
 	// Set some config sections from other config sections in the config struct
 	t.Cfg.QueryScheduler.SchedulerRing.ListenPort = t.Cfg.Server.GRPCListenPort
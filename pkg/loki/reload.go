@@ -0,0 +1,219 @@
+package loki
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+const (
+	limitsConfigSection      = "limits_config"
+	schemaConfigSection      = "schema_config"
+	storageConfigSection     = "storage_config"
+	objectStoreBudgetSection = storageConfigSection + ".object_store_budget"
+)
+
+// limitsDefaultsMtx serializes YAML decoding which depends on the global
+// default limits set with validation.SetDefaultLimitsForYAMLUnmarshalling,
+// so that a reload swapping them never races with a runtime config reload.
+var limitsDefaultsMtx sync.Mutex
+
+// limitsDefaultsGeneration is incremented, with limitsDefaultsMtx held, each time a reload
+// replaces the default limits, so that tenant limits decoded against older defaults are told apart.
+var limitsDefaultsGeneration uint64
+
+// ConfigLoader reads and validates the configuration Loki was started with again.
+// Limits in the returned config must be defaulted from flag values only, as on startup.
+type ConfigLoader func() (Config, error)
+
+// reloadResponse is returned by the /-/reload endpoint.
+type reloadResponse struct {
+	Reloaded        []string `json:"reloaded"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// configReloader applies configuration changes to a running Loki.
+// Limits, including the per-tenant overrides of the runtime config which are decoded
+// again against the new defaults, and the object store budget of the storage clients
+// are applied in place; new schema periods are validated but, like any other section, only take effect
+// after a restart. The stores, table manager, compactor and query sharding set up
+// their periods on startup and can't add one while running.
+type configReloader struct {
+	load      ConfigLoader
+	overrides func() *validation.Overrides
+	// tenantLimits returns the tenant limits of the runtime config, nil without one.
+	tenantLimits func() *tenantLimitsFromRuntimeConfig
+
+	// current is the configuration as it was loaded, before modules adjusted it.
+	current Config
+}
+
+func (r *configReloader) reload() (reloadResponse, error) {
+	limitsDefaultsMtx.Lock()
+	defer limitsDefaultsMtx.Unlock()
+
+	res, err := r.loadAndApply()
+	if err != nil {
+		// Roll back the YAML defaults the loader may have replaced.
+		validation.SetDefaultLimitsForYAMLUnmarshalling(r.current.LimitsConfig)
+		return reloadResponse{}, err
+	}
+	return res, nil
+}
+
+func (r *configReloader) loadAndApply() (reloadResponse, error) {
+	cfg, err := r.load()
+	if err != nil {
+		return reloadResponse{}, err
+	}
+	return r.apply(cfg)
+}
+
+// apply compares cfg against the current configuration and applies the
+// reloadable sections. Nothing is applied if cfg is rejected.
+func (r *configReloader) apply(cfg Config) (reloadResponse, error) {
+	changed, err := changedSections(r.current, cfg)
+	if err != nil {
+		return reloadResponse{}, err
+	}
+
+	var reloadBudget bool
+	res := reloadResponse{Reloaded: []string{}, RestartRequired: []string{}}
+	for _, section := range changed {
+		switch section {
+		case limitsConfigSection:
+			res.Reloaded = append(res.Reloaded, section)
+		case storageConfigSection:
+			reloadBudget = !reflect.DeepEqual(r.current.StorageConfig.ObjectStoreBudget, cfg.StorageConfig.ObjectStoreBudget)
+			if reloadBudget {
+				res.Reloaded = append(res.Reloaded, objectStoreBudgetSection)
+			}
+			restart, err := storageChangedBesidesBudget(r.current.StorageConfig, cfg.StorageConfig)
+			if err != nil {
+				return reloadResponse{}, err
+			}
+			if restart {
+				res.RestartRequired = append(res.RestartRequired, section)
+			}
+		case schemaConfigSection:
+			if err := validateSchemaChange(r.current.SchemaConfig.SchemaConfig, cfg.SchemaConfig.SchemaConfig, model.Now()); err != nil {
+				return reloadResponse{}, errors.Wrap(err, "invalid schema_config change")
+			}
+			res.RestartRequired = append(res.RestartRequired, section)
+		default:
+			res.RestartRequired = append(res.RestartRequired, section)
+		}
+	}
+
+	// The runtime overrides were defaulted from the previous limits, they are decoded
+	// again before anything is applied so that an override rejected with the new
+	// defaults fails the reload.
+	validation.SetDefaultLimitsForYAMLUnmarshalling(cfg.LimitsConfig)
+	if tenantLimits := r.tenantLimits(); tenantLimits != nil {
+		limitsDefaultsGeneration++
+		reloaded, err := tenantLimits.redecode()
+		if err != nil {
+			return reloadResponse{}, errors.Wrap(err, "invalid runtime config with the new default limits")
+		}
+		tenantLimits.setReloaded(reloaded)
+	}
+	if o := r.overrides(); o != nil {
+		o.SetDefaultLimits(cfg.LimitsConfig)
+	}
+	r.current.LimitsConfig = cfg.LimitsConfig
+
+	if reloadBudget {
+		objectclient.SetBudgetConfig(cfg.StorageConfig.ObjectStoreBudget)
+		r.current.StorageConfig.ObjectStoreBudget = cfg.StorageConfig.ObjectStoreBudget
+	}
+
+	return res, nil
+}
+
+// storageChangedBesidesBudget returns whether the storage configs differ by more than their object store budget.
+func storageChangedBesidesBudget(current, next storage.Config) (bool, error) {
+	next.ObjectStoreBudget = current.ObjectStoreBudget
+	cm, err := yamlMarshalUnmarshal(current)
+	if err != nil {
+		return false, err
+	}
+	nm, err := yamlMarshalUnmarshal(next)
+	if err != nil {
+		return false, err
+	}
+	return !reflect.DeepEqual(cm, nm), nil
+}
+
+// changedSections returns the sorted names of the top level configuration
+// sections which differ between a and b.
+func changedSections(a, b Config) ([]string, error) {
+	am, err := yamlMarshalUnmarshal(a)
+	if err != nil {
+		return nil, err
+	}
+	bm, err := yamlMarshalUnmarshal(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for k, v := range bm {
+		if !reflect.DeepEqual(am[k], v) {
+			changed = append(changed, fmt.Sprint(k))
+		}
+	}
+	for k := range am {
+		if _, ok := bm[k]; !ok {
+			changed = append(changed, fmt.Sprint(k))
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// validateSchemaChange ensures a schema change only appends periods starting
+// in the future, since existing periods describe data which is already written.
+func validateSchemaChange(current, next chunk.SchemaConfig, now model.Time) error {
+	if len(next.Configs) < len(current.Configs) {
+		return errors.New("schema periods cannot be removed")
+	}
+	for i, period := range current.Configs {
+		if !reflect.DeepEqual(period, next.Configs[i]) {
+			return fmt.Errorf("schema period starting %s cannot be changed", period.From.String())
+		}
+	}
+	for _, period := range next.Configs[len(current.Configs):] {
+		if !period.From.Time.After(now) {
+			return fmt.Errorf("new schema period starting %s must start in the future", period.From.String())
+		}
+	}
+	return nil
+}
+
+func (t *Loki) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := t.reloader.reload()
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "config reload failed, keeping current config", "err", err)
+		http.Error(w, fmt.Sprintf("config reload failed, keeping current config: %v", err), http.StatusBadRequest)
+		return
+	}
+	level.Info(util_log.Logger).Log("msg", "config reloaded", "reloaded", fmt.Sprint(res.Reloaded), "restart_required", fmt.Sprint(res.RestartRequired))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		level.Error(util_log.Logger).Log("msg", "error writing response", "err", err)
+	}
+}
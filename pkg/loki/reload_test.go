@@ -0,0 +1,160 @@
+package loki
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/runtimeconfig"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+func periodConfig(from string) chunk.PeriodConfig {
+	var d chunk.DayTime
+	t, _ := time.Parse("2006-01-02", from)
+	d.Time = model.TimeFromUnix(t.Unix())
+	return chunk.PeriodConfig{From: d, IndexType: "boltdb-shipper", ObjectType: "filesystem", Schema: "v11"}
+}
+
+func Test_validateSchemaChange(t *testing.T) {
+	now := model.TimeFromUnix(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC).Unix())
+	current := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{periodConfig("2020-01-01")}}
+
+	for _, tc := range []struct {
+		name    string
+		next    []chunk.PeriodConfig
+		wantErr string
+	}{
+		{"unchanged", []chunk.PeriodConfig{periodConfig("2020-01-01")}, ""},
+		{"future period added", []chunk.PeriodConfig{periodConfig("2020-01-01"), periodConfig("2021-07-01")}, ""},
+		{"past period added", []chunk.PeriodConfig{periodConfig("2020-01-01"), periodConfig("2021-05-01")}, "must start in the future"},
+		{"period removed", nil, "cannot be removed"},
+		{"period changed", []chunk.PeriodConfig{periodConfig("2020-02-01")}, "cannot be changed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateSchemaChange(current, chunk.SchemaConfig{Configs: tc.next}, now)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func Test_configReloader(t *testing.T) {
+	current := newDefaultConfig()
+	current.LimitsConfig.MaxLineSize = 10
+	current.SchemaConfig.Configs = []chunk.PeriodConfig{periodConfig("2020-01-01")}
+
+	validation.SetDefaultLimitsForYAMLUnmarshalling(current.LimitsConfig)
+	defer validation.SetDefaultLimitsForYAMLUnmarshalling(newDefaultConfig().LimitsConfig)
+
+	// Tenant a overrides its ingestion rate only, its max line size comes from the defaults.
+	runtimeCfg := runtimeconfig.Config{
+		ReloadPeriod: time.Hour,
+		Loader:       loadRuntimeConfig,
+		LoadPath:     filepath.Join(t.TempDir(), "runtime.yaml"),
+	}
+	require.NoError(t, ioutil.WriteFile(runtimeCfg.LoadPath, []byte("overrides:\n  a:\n    ingestion_rate_mb: 10\n"), 0o600))
+	runtimeConfig, err := runtimeconfig.New(runtimeCfg, prometheus.NewRegistry(), log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, runtimeConfig.StartAsync(context.Background()))
+	require.NoError(t, runtimeConfig.AwaitRunning(context.Background()))
+	defer func() {
+		runtimeConfig.StopAsync()
+		require.NoError(t, runtimeConfig.AwaitTerminated(context.Background()))
+	}()
+	tenantLimits := newtenantLimitsFromRuntimeConfig(runtimeConfig, runtimeCfg, validation.TenantHierarchyConfig{})
+
+	overrides, err := validation.NewOverrides(current.LimitsConfig, tenantLimits)
+	require.NoError(t, err)
+	require.Equal(t, 10, overrides.MaxLineSize("a"))
+
+	var (
+		next    Config
+		loadErr error
+	)
+	loki := &Loki{reloader: &configReloader{
+		load:      func() (Config, error) { return next, loadErr },
+		overrides:    func() *validation.Overrides { return overrides },
+		tenantLimits: func() *tenantLimitsFromRuntimeConfig { return tenantLimits },
+		current:      *current,
+	}}
+
+	reload := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		loki.reloadHandler(w, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+		return w
+	}
+
+	t.Run("limits and schema changes", func(t *testing.T) {
+		next = *newDefaultConfig()
+		next.LimitsConfig.MaxLineSize = 20
+		next.SchemaConfig.Configs = []chunk.PeriodConfig{periodConfig("2020-01-01"), periodConfig("2999-01-01")}
+		next.Server.HTTPListenPort = 1234
+
+		w := reload()
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `{"reloaded":["limits_config"],"restart_required":["schema_config","server"]}`, w.Body.String())
+		require.Equal(t, 20, overrides.MaxLineSize("user"))
+		require.Equal(t, 20, overrides.MaxLineSize("a"))
+		require.Equal(t, float64(10<<20), overrides.IngestionRateBytes("a"))
+	})
+
+	t.Run("invalid schema change is rolled back", func(t *testing.T) {
+		next = *newDefaultConfig()
+		next.LimitsConfig.MaxLineSize = 30
+		next.SchemaConfig.Configs = []chunk.PeriodConfig{periodConfig("2020-02-01")}
+
+		w := reload()
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.True(t, strings.Contains(w.Body.String(), "cannot be changed"))
+		require.Equal(t, 20, overrides.MaxLineSize("user"))
+		require.Equal(t, 20, overrides.MaxLineSize("a"))
+	})
+
+	t.Run("storage client tuning", func(t *testing.T) {
+		defer objectclient.SetBudgetConfig(objectclient.BudgetConfig{})
+
+		next = *newDefaultConfig()
+		next.LimitsConfig.MaxLineSize = 20
+		next.SchemaConfig.Configs = []chunk.PeriodConfig{periodConfig("2020-01-01")}
+		next.StorageConfig.ObjectStoreBudget.RequestsPerSecond = 100
+
+		w := reload()
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `{"reloaded":["storage_config.object_store_budget"],"restart_required":[]}`, w.Body.String())
+		require.Equal(t, float64(100), loki.reloader.current.StorageConfig.ObjectStoreBudget.RequestsPerSecond)
+
+		next.StorageConfig.ObjectStoreBudget.RequestsPerSecond = 200
+		next.StorageConfig.MaxChunkBatchSize = 10
+
+		w = reload()
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `{"reloaded":["storage_config.object_store_budget"],"restart_required":["storage_config"]}`, w.Body.String())
+	})
+
+	t.Run("parse error is rolled back", func(t *testing.T) {
+		loadErr = errors.New("yaml: line 1: did not find expected key")
+
+		w := reload()
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.True(t, strings.Contains(w.Body.String(), "keeping current config"))
+		require.Equal(t, 20, overrides.MaxLineSize("user"))
+	})
+}
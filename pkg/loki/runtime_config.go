@@ -1,8 +1,12 @@
 package loki
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"os"
+	"sort"
+	"sync"
 
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/go-kit/log/level"
@@ -22,6 +26,9 @@ type runtimeConfigValues struct {
 	TenantConfig map[string]*runtime.Config    `yaml:"configs"`
 
 	Multi kv.MultiRuntimeConfig `yaml:"multi_kv_config"`
+
+	// defaultsGeneration is the generation of the default limits the tenant limits were decoded with.
+	defaultsGeneration uint64
 }
 
 func (r runtimeConfigValues) validate() error {
@@ -39,9 +46,15 @@ func (r runtimeConfigValues) validate() error {
 }
 
 func loadRuntimeConfig(r io.Reader) (interface{}, error) {
-	overrides := &runtimeConfigValues{}
+	return runtimeConfigLoader(validation.TenantHierarchyConfig{})(r)
+}
 
-	decoder := yaml.NewDecoder(r)
+// decodeRuntimeConfig decodes and validates a runtime config document. It must be called
+// with limitsDefaultsMtx held.
+func decodeRuntimeConfig(doc []byte) (*runtimeConfigValues, error) {
+	overrides := &runtimeConfigValues{defaultsGeneration: limitsDefaultsGeneration}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(doc))
 	decoder.SetStrict(true)
 	if err := decoder.Decode(&overrides); err != nil {
 		return nil, err
@@ -49,11 +62,133 @@ func loadRuntimeConfig(r io.Reader) (interface{}, error) {
 	if err := overrides.validate(); err != nil {
 		return nil, err
 	}
+	if err := overrides.validateTenantStorage(doc); err != nil {
+		return nil, err
+	}
 	return overrides, nil
 }
 
+// validateTenantStorage rejects the retention overrides of the tenants storing their chunks and
+// index in their own S3 bucket, the compactor doesn't apply retention to these buckets.
+func (r runtimeConfigValues) validateTenantStorage(doc []byte) error {
+	var raw struct {
+		Overrides map[string]map[string]interface{} `yaml:"overrides"`
+	}
+	if err := yaml.Unmarshal(doc, &raw); err != nil {
+		return err
+	}
+	for tenantID, c := range r.TenantConfig {
+		if c == nil || c.S3Storage == nil {
+			continue
+		}
+		for _, key := range []string{"retention_period", "retention_stream"} {
+			if _, ok := raw.Overrides[tenantID][key]; ok {
+				return fmt.Errorf("invalid override for tenant %s: %s can't be set for a tenant with its own s3_storage, the compactor doesn't apply retention to its bucket", tenantID, key)
+			}
+		}
+	}
+	return nil
+}
+
+// runtimeConfigLoader returns the loader of the runtime config. With a tenant hierarchy,
+// the overrides of the sub-tenants are applied on top of the limits of their org.
+func runtimeConfigLoader(hierarchy validation.TenantHierarchyConfig) func(io.Reader) (interface{}, error) {
+	decode := runtimeConfigDecoder(hierarchy)
+	return func(r io.Reader) (interface{}, error) {
+		doc, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+
+		// Tenant limits are defaulted from the global default limits, which a
+		// config reload may be replacing.
+		limitsDefaultsMtx.Lock()
+		defer limitsDefaultsMtx.Unlock()
+
+		return decode(doc)
+	}
+}
+
+// runtimeConfigDecoder returns the decoder of a runtime config document, it must be called
+// with limitsDefaultsMtx held.
+func runtimeConfigDecoder(hierarchy validation.TenantHierarchyConfig) func([]byte) (*runtimeConfigValues, error) {
+	if !hierarchy.Enabled() {
+		return decodeRuntimeConfig
+	}
+	return func(doc []byte) (*runtimeConfigValues, error) {
+		overrides, err := decodeRuntimeConfig(doc)
+		if err != nil {
+			return nil, err
+		}
+		if err := overrides.inheritLimits(hierarchy, doc); err != nil {
+			return nil, err
+		}
+		return overrides, nil
+	}
+}
+
+// inheritLimits applies the overrides of the sub-tenants on top of the limits of their
+// closest org with overrides, orgs being resolved before their sub-tenants.
+func (r *runtimeConfigValues) inheritLimits(hierarchy validation.TenantHierarchyConfig, doc []byte) error {
+	var raw struct {
+		Overrides map[string]yaml.MapSlice `yaml:"overrides"`
+	}
+	if err := yaml.Unmarshal(doc, &raw); err != nil {
+		return err
+	}
+	tenants := make([]string, 0, len(raw.Overrides))
+	for tenantID := range raw.Overrides {
+		tenants = append(tenants, tenantID)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		di, dj := len(hierarchy.Ancestors(tenants[i])), len(hierarchy.Ancestors(tenants[j]))
+		if di != dj {
+			return di < dj
+		}
+		return tenants[i] < tenants[j]
+	})
+
+	for _, tenantID := range tenants {
+		var org *validation.Limits
+		for _, ancestor := range hierarchy.Ancestors(tenantID) {
+			if org = r.TenantLimits[ancestor]; org != nil {
+				break
+			}
+		}
+		if org == nil {
+			continue
+		}
+		overrides := []byte("{}")
+		if len(raw.Overrides[tenantID]) > 0 {
+			b, err := yaml.Marshal(raw.Overrides[tenantID])
+			if err != nil {
+				return err
+			}
+			overrides = b
+		}
+		limits, err := org.Inherit(overrides)
+		if err != nil {
+			return fmt.Errorf("invalid override for tenant %s: %w", tenantID, err)
+		}
+		if err := limits.Validate(); err != nil {
+			return fmt.Errorf("invalid override for tenant %s: %w", tenantID, err)
+		}
+		r.TenantLimits[tenantID] = limits
+	}
+	return nil
+}
+
 type tenantLimitsFromRuntimeConfig struct {
 	c *runtimeconfig.Manager
+
+	// loadPath and decode read the runtime config file again when the default limits change.
+	loadPath string
+	decode   func([]byte) (*runtimeConfigValues, error)
+
+	mtx sync.RWMutex
+	// reloaded is the runtime config decoded against the default limits of a config reload,
+	// it is used until the manager loads the file with these defaults too.
+	reloaded *runtimeConfigValues
 }
 
 func (t *tenantLimitsFromRuntimeConfig) AllByUserID() map[string]*validation.Limits {
@@ -62,7 +197,18 @@ func (t *tenantLimitsFromRuntimeConfig) AllByUserID() map[string]*validation.Lim
 	}
 
 	cfg, ok := t.c.GetConfig().(*runtimeConfigValues)
-	if cfg != nil && ok {
+	if !ok {
+		cfg = nil
+	}
+
+	t.mtx.RLock()
+	reloaded := t.reloaded
+	t.mtx.RUnlock()
+	if reloaded != nil && (cfg == nil || cfg.defaultsGeneration < reloaded.defaultsGeneration) {
+		cfg = reloaded
+	}
+
+	if cfg != nil {
 		return cfg.TenantLimits
 	}
 
@@ -78,8 +224,24 @@ func (t *tenantLimitsFromRuntimeConfig) TenantLimits(userID string) *validation.
 	return allByUserID[userID]
 }
 
-func newtenantLimitsFromRuntimeConfig(c *runtimeconfig.Manager) validation.TenantLimits {
-	return &tenantLimitsFromRuntimeConfig{c: c}
+// redecode decodes the runtime config file again against the current default limits. It must
+// be called with limitsDefaultsMtx held, the result is used once passed to setReloaded.
+func (t *tenantLimitsFromRuntimeConfig) redecode() (*runtimeConfigValues, error) {
+	doc, err := os.ReadFile(t.loadPath)
+	if err != nil {
+		return nil, err
+	}
+	return t.decode(doc)
+}
+
+func (t *tenantLimitsFromRuntimeConfig) setReloaded(cfg *runtimeConfigValues) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.reloaded = cfg
+}
+
+func newtenantLimitsFromRuntimeConfig(c *runtimeconfig.Manager, cfg runtimeconfig.Config, hierarchy validation.TenantHierarchyConfig) *tenantLimitsFromRuntimeConfig {
+	return &tenantLimitsFromRuntimeConfig{c: c, loadPath: cfg.LoadPath, decode: runtimeConfigDecoder(hierarchy)}
 }
 
 func tenantConfigFromRuntimeConfig(c *runtimeconfig.Manager) runtime.TenantConfig {
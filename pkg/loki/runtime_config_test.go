@@ -16,6 +16,7 @@ import (
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/loki/pkg/runtime"
 	"github.com/grafana/loki/pkg/validation"
 )
 
@@ -83,14 +84,88 @@ overrides:
 	require.Equal(t, "invalid override for tenant 29: retention period must be >= 24h was 5h", err.Error())
 }
 
+func Test_LoadTenantS3Storage(t *testing.T) {
+	cfg, err := loadRuntimeConfig(strings.NewReader(
+		`
+configs:
+    "byob":
+        s3_storage:
+            bucketnames: byob-bucket
+            region: eu-west-1
+            access_key_id: foo
+            secret_access_key: bar
+`))
+	require.NoError(t, err)
+
+	configs, err := runtime.NewTenantConfigs(func(userID string) *runtime.Config {
+		return cfg.(*runtimeConfigValues).TenantConfig[userID]
+	})
+	require.NoError(t, err)
+	require.Nil(t, configs.S3Storage("fake"))
+
+	s3 := configs.S3Storage("byob")
+	require.NotNil(t, s3)
+	require.Equal(t, "byob-bucket", s3.BucketNames)
+	require.Equal(t, "eu-west-1", s3.Region)
+	require.Equal(t, "foo", s3.AccessKeyID)
+	require.Equal(t, "bar", s3.SecretAccessKey)
+	// unset values are taken from the S3 flags defaults.
+	require.Equal(t, 90*time.Second, s3.HTTPConfig.IdleConnTimeout)
+
+	_, err = loadRuntimeConfig(strings.NewReader(
+		`
+overrides:
+    "byob":
+        retention_period: 48h
+configs:
+    "byob":
+        s3_storage:
+            bucketnames: byob-bucket
+`))
+	require.EqualError(t, err, "invalid override for tenant byob: retention_period can't be set for a tenant with its own s3_storage, the compactor doesn't apply retention to its bucket")
+}
+
+func Test_TenantHierarchyLimits(t *testing.T) {
+	hierarchy := validation.TenantHierarchyConfig{Separator: "."}
+	overrides := newTestOverridesWithHierarchy(t, hierarchy,
+		`
+overrides:
+    "acme":
+        ingestion_rate_mb: 20
+        retention_period: 744h
+    "acme.payments":
+        retention_period: 2160h
+    "acme.payments.eu":
+    "other":
+        max_query_parallelism: 8
+`)
+	// sub-tenants inherit the limits of their org they don't override.
+	require.Equal(t, float64(20<<20), overrides.IngestionRateBytes("acme.payments"))
+	require.Equal(t, 90*24*time.Hour, overrides.RetentionPeriod("acme.payments"))
+	require.Equal(t, float64(20<<20), overrides.IngestionRateBytes("acme.payments.eu"))
+	require.Equal(t, 90*24*time.Hour, overrides.RetentionPeriod("acme.payments.eu"))
+	// undeclared sub-tenants use the limits of their org.
+	require.Equal(t, 31*24*time.Hour, overrides.RetentionPeriod("acme.search"))
+	require.Equal(t, 8, overrides.MaxQueryParallelism("other.team"))
+	require.Equal(t, 32, overrides.MaxQueryParallelism("acme.payments"))
+	require.Equal(t, 32, overrides.MaxQueryParallelism("acmecorp"))
+	require.Equal(t, []string{"acme.payments", "acme.payments.eu"}, overrides.SubTenants("acme"))
+	require.Empty(t, overrides.SubTenants("acme.payments.eu"))
+}
+
 func newTestOverrides(t *testing.T, yaml string) *validation.Overrides {
+	t.Helper()
+	return newTestOverridesWithHierarchy(t, validation.TenantHierarchyConfig{}, yaml)
+}
+
+func newTestOverridesWithHierarchy(t *testing.T, hierarchy validation.TenantHierarchyConfig, yaml string) *validation.Overrides {
 	t.Helper()
 	f, err := ioutil.TempFile(t.TempDir(), "bar")
 	require.NoError(t, err)
 	path := f.Name()
 	// fake loader to load from string instead of file.
 	loader := func(_ io.Reader) (interface{}, error) {
-		return loadRuntimeConfig(strings.NewReader(yaml))
+		return runtimeConfigLoader(hierarchy)(strings.NewReader(yaml))
 	}
 	cfg := runtimeconfig.Config{
 		ReloadPeriod: 1 * time.Second,
@@ -103,7 +178,7 @@ func newTestOverrides(t *testing.T, yaml string) *validation.Overrides {
 	require.NoError(t, flagset.Parse(nil))
 	validation.SetDefaultLimitsForYAMLUnmarshalling(defaults)
 
-	runtimeConfig, err := runtimeconfig.New(cfg, prometheus.WrapRegistererWithPrefix("loki_", prometheus.DefaultRegisterer), log.NewNopLogger())
+	runtimeConfig, err := runtimeconfig.New(cfg, prometheus.WrapRegistererWithPrefix("loki_", prometheus.NewRegistry()), log.NewNopLogger())
 	require.NoError(t, err)
 
 	require.NoError(t, runtimeConfig.StartAsync(context.Background()))
@@ -113,8 +188,9 @@ func newTestOverrides(t *testing.T, yaml string) *validation.Overrides {
 		require.NoError(t, runtimeConfig.AwaitTerminated(context.Background()))
 	}()
 
-	overrides, err := validation.NewOverrides(defaults, newtenantLimitsFromRuntimeConfig(runtimeConfig))
+	overrides, err := validation.NewOverrides(defaults, newtenantLimitsFromRuntimeConfig(runtimeConfig, cfg, hierarchy))
 	require.NoError(t, err)
+	overrides.SetTenantHierarchy(hierarchy)
 	return overrides
 }
 
@@ -125,7 +201,7 @@ func Test_NoOverrides(t *testing.T) {
 	defaults.RegisterFlags(flagset)
 	require.NoError(t, flagset.Parse(nil))
 	validation.SetDefaultLimitsForYAMLUnmarshalling(defaults)
-	overrides, err := validation.NewOverrides(defaults, newtenantLimitsFromRuntimeConfig(nil))
+	overrides, err := validation.NewOverrides(defaults, newtenantLimitsFromRuntimeConfig(nil, runtimeconfig.Config{}, validation.TenantHierarchyConfig{}))
 	require.NoError(t, err)
 	require.Equal(t, time.Duration(defaults.QuerySplitDuration), overrides.QuerySplitDuration("foo"))
 }
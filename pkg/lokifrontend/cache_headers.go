@@ -0,0 +1,114 @@
+package lokifrontend
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/weaveworks/common/middleware"
+
+	"github.com/grafana/loki/pkg/loghttp"
+)
+
+// CacheHeadersMiddleware adds caching headers to the successful responses of the GET queries
+// of immutable ranges, the ones ending more than immutableAfter ago: a weak ETag hashing the
+// response, and a Cache-Control max age. Requests with a matching If-None-Match get a
+// 304 Not Modified response without body.
+//
+// The responses depend on the tenant and on the response format negotiated with the Accept
+// header, so they vary with the X-Scope-OrgID and Accept headers. Shared caches don't store
+// the responses of requests with an Authorization header.
+func CacheHeadersMiddleware(maxAge, immutableAfter time.Duration) middleware.Interface {
+	cacheControl := fmt.Sprintf("max-age=%d", int(maxAge.Seconds()))
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || !immutableRange(r, time.Now().Add(-immutableAfter)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bw := &bufferedResponseWriter{header: http.Header{}, status: http.StatusOK}
+			next.ServeHTTP(bw, r)
+
+			header := w.Header()
+			for name, values := range bw.header {
+				header[name] = values
+			}
+			if bw.status != http.StatusOK {
+				w.WriteHeader(bw.status)
+				_, _ = w.Write(bw.body.Bytes())
+				return
+			}
+
+			etag := fmt.Sprintf(`W/"%016x"`, xxhash.Sum64(bw.body.Bytes()))
+			header.Set("ETag", etag)
+			header.Set("Cache-Control", cacheControl)
+			header.Add("Vary", "X-Scope-OrgID")
+			header.Add("Vary", "Accept")
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				header.Del("Content-Type")
+				header.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(bw.body.Bytes())
+		})
+	})
+}
+
+// immutableRange returns whether a query ends before cutoff. Queries without end or time
+// end now.
+func immutableRange(r *http.Request, cutoff time.Time) bool {
+	params := r.URL.Query()
+	value := params.Get("end")
+	if value == "" {
+		value = params.Get("time")
+	}
+	if value == "" {
+		return false
+	}
+	end, err := loghttp.ParseTimestamp(value, time.Time{})
+	if err != nil {
+		return false
+	}
+	return end.Before(cutoff)
+}
+
+// etagMatches returns whether the If-None-Match header matches the etag, using the weak
+// comparison.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponseWriter holds a response until its ETag is known.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
@@ -0,0 +1,88 @@
+package lokifrontend
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheHeadersMiddleware(t *testing.T) {
+	status := http.StatusOK
+	handler := CacheHeadersMiddleware(time.Hour, 3*time.Hour).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"status":"success"}`))
+	}))
+
+	query := func(method string, params url.Values, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/loki/api/v1/query_range?"+params.Encode(), nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	historical := url.Values{
+		"query": []string{`{app="foo"}`},
+		"start": []string{fmt.Sprint(time.Now().Add(-48 * time.Hour).UnixNano())},
+		"end":   []string{fmt.Sprint(time.Now().Add(-24 * time.Hour).UnixNano())},
+	}
+
+	rec := query(http.MethodGet, historical, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, `{"status":"success"}`, rec.Body.String())
+	require.Equal(t, "max-age=3600", rec.Header().Get("Cache-Control"))
+	require.Equal(t, []string{"X-Scope-OrgID", "Accept"}, rec.Header().Values("Vary"))
+	etag := rec.Header().Get("ETag")
+	require.Regexp(t, `^W/"[0-9a-f]{16}"$`, etag)
+
+	t.Run("matching If-None-Match", func(t *testing.T) {
+		for _, ifNoneMatch := range []string{etag, `W/"0000000000000000", ` + etag, "*"} {
+			rec := query(http.MethodGet, historical, ifNoneMatch)
+			require.Equal(t, http.StatusNotModified, rec.Code)
+			require.Empty(t, rec.Body.String())
+			require.Equal(t, etag, rec.Header().Get("ETag"))
+		}
+	})
+
+	t.Run("other If-None-Match", func(t *testing.T) {
+		rec := query(http.MethodGet, historical, `W/"0000000000000000"`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, `{"status":"success"}`, rec.Body.String())
+	})
+
+	t.Run("recent range", func(t *testing.T) {
+		recent := url.Values{"query": []string{`{app="foo"}`}, "end": []string{fmt.Sprint(time.Now().Add(-time.Hour).UnixNano())}}
+		for _, params := range []url.Values{recent, {"query": []string{`{app="foo"}`}}} {
+			rec := query(http.MethodGet, params, etag)
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Empty(t, rec.Header().Get("ETag"))
+			require.Empty(t, rec.Header().Get("Cache-Control"))
+		}
+	})
+
+	t.Run("instant query", func(t *testing.T) {
+		rec := query(http.MethodGet, url.Values{"query": []string{`count_over_time({app="foo"}[1h])`}, "time": []string{time.Now().Add(-24 * time.Hour).Format(time.RFC3339)}}, "")
+		require.NotEmpty(t, rec.Header().Get("ETag"))
+	})
+
+	t.Run("POST", func(t *testing.T) {
+		rec := query(http.MethodPost, historical, "")
+		require.Empty(t, rec.Header().Get("ETag"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		status = http.StatusBadRequest
+		defer func() { status = http.StatusOK }()
+		rec := query(http.MethodGet, historical, etag)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Empty(t, rec.Header().Get("ETag"))
+		require.Equal(t, `{"status":"success"}`, rec.Body.String())
+	})
+}
@@ -2,6 +2,7 @@ package lokifrontend
 
 import (
 	"flag"
+	"time"
 
 	"github.com/grafana/loki/pkg/lokifrontend/frontend/transport"
 	v1 "github.com/grafana/loki/pkg/lokifrontend/frontend/v1"
@@ -16,7 +17,17 @@ type Config struct {
 	CompressResponses bool   `yaml:"compress_responses"`
 	DownstreamURL     string `yaml:"downstream_url"`
 
+	HistoricalDownstreamURL string        `yaml:"historical_downstream_url"`
+	HistoricalQueriesAfter  time.Duration `yaml:"historical_queries_after"`
+
 	TailProxyURL string `yaml:"tail_proxy_url"`
+
+	QueryLogFile string `yaml:"query_log_file"`
+
+	ImmutableRangeMaxAge time.Duration `yaml:"immutable_range_max_age"`
+	ImmutableRangeAfter  time.Duration `yaml:"immutable_range_after"`
+
+	UIEnabled bool `yaml:"ui_enabled"`
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet.
@@ -28,5 +39,15 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.CompressResponses, "querier.compress-http-responses", false, "Compress HTTP responses.")
 	f.StringVar(&cfg.DownstreamURL, "frontend.downstream-url", "", "URL of downstream Prometheus.")
 
+	f.StringVar(&cfg.HistoricalDownstreamURL, "frontend.historical-downstream-url", "", "URL of the queriers which serve (split) queries ending before the historical queries cutoff. These queries only read from the store, so these queriers can run on cheaper capacity. Empty sends all queries to the same queriers.")
+	f.DurationVar(&cfg.HistoricalQueriesAfter, "frontend.historical-queries-after", 0, "Queries ending longer than this ago are sent to the historical downstream URL. 0 means the value of -querier.query-ingesters-within is used.")
+
 	f.StringVar(&cfg.TailProxyURL, "frontend.tail-proxy-url", "", "URL of querier for tail proxy.")
+
+	f.StringVar(&cfg.QueryLogFile, "frontend.query-log-file", "", "File to record the queries received by the frontend to, so that they can be replayed with the query-replay tool. Empty disables recording.")
+
+	f.DurationVar(&cfg.ImmutableRangeMaxAge, "frontend.immutable-range-max-age", 0, "Max age of the Cache-Control header of the responses to GET queries of immutable ranges, which also get an ETag and honor If-None-Match, for browsers and CDNs to cache them. 0 disables caching headers.")
+	f.DurationVar(&cfg.ImmutableRangeAfter, "frontend.immutable-range-after", 0, "Queries ending longer than this ago are considered immutable. 0 means the value of -querier.query-ingesters-within is used, one of them must be set when -frontend.immutable-range-max-age is.")
+
+	f.BoolVar(&cfg.UIEnabled, "frontend.ui-enabled", false, "Serve a simple query UI under /ui/, to run and tail queries from a browser without Grafana.")
 }
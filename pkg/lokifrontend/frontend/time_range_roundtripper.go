@@ -0,0 +1,70 @@
+package frontend
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/loki/pkg/loghttp"
+)
+
+const (
+	recentPool     = "recent"
+	historicalPool = "historical"
+)
+
+// timeRangeRoundTripper sends requests which only cover data older than the
+// cutoff, and therefore never need the ingesters, to the historical queriers.
+// All other requests, including those without a time range, go to the recent ones.
+type timeRangeRoundTripper struct {
+	recent     http.RoundTripper
+	historical http.RoundTripper
+	cutoff     time.Duration
+	now        func() time.Time
+
+	requests *prometheus.CounterVec
+}
+
+// NewTimeRangeRoundTripper routes requests ending before now minus cutoff to historicalURL
+// and every other request to recent.
+func NewTimeRangeRoundTripper(recent http.RoundTripper, historicalURL string, cutoff time.Duration, reg prometheus.Registerer) (http.RoundTripper, error) {
+	historical, err := NewDownstreamRoundTripper(historicalURL, http.DefaultTransport)
+	if err != nil {
+		return nil, err
+	}
+	return &timeRangeRoundTripper{
+		recent:     recent,
+		historical: historical,
+		cutoff:     cutoff,
+		now:        time.Now,
+		requests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "frontend_downstream_requests_total",
+			Help:      "Total number of requests sent downstream, by querier pool.",
+		}, []string{"pool"}),
+	}, nil
+}
+
+func (t *timeRangeRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.isHistorical(r) {
+		t.requests.WithLabelValues(historicalPool).Inc()
+		return t.historical.RoundTrip(r)
+	}
+	t.requests.WithLabelValues(recentPool).Inc()
+	return t.recent.RoundTrip(r)
+}
+
+func (t *timeRangeRoundTripper) isHistorical(r *http.Request) bool {
+	end := r.URL.Query().Get("end")
+	if end == "" {
+		return false
+	}
+	ts, err := loghttp.ParseTimestamp(end, time.Time{})
+	if err != nil {
+		// Let the recent queriers report the invalid request.
+		return false
+	}
+	return ts.Before(t.now().Add(-t.cutoff))
+}
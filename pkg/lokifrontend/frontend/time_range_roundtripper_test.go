@@ -0,0 +1,52 @@
+package frontend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+type poolRoundTripper string
+
+func (p poolRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Pool": []string{string(p)}}}, nil
+}
+
+func TestTimeRangeRoundTripper(t *testing.T) {
+	historical := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/loki/api/v1/query_range", r.URL.Path)
+		w.Header().Set("Pool", historicalPool)
+	}))
+	defer historical.Close()
+
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	rt, err := NewTimeRangeRoundTripper(poolRoundTripper(recentPool), historical.URL, 3*time.Hour, prometheus.NewRegistry())
+	require.NoError(t, err)
+	rt.(*timeRangeRoundTripper).now = func() time.Time { return now }
+
+	for _, tc := range []struct {
+		name string
+		end  string
+		want string
+	}{
+		{"no time range", "", recentPool},
+		{"recent", strconv.FormatInt(now.Add(-time.Hour).UnixNano(), 10), recentPool},
+		{"historical", strconv.FormatInt(now.Add(-4*time.Hour).UnixNano(), 10), historicalPool},
+		{"historical rfc3339", now.Add(-24 * time.Hour).Format(time.RFC3339), historicalPool},
+		{"invalid", "yesterday", recentPool},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/loki/api/v1/query_range?query={app=\"foo\"}&end="+tc.end, nil)
+			require.NoError(t, err)
+
+			resp, err := rt.RoundTrip(req)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, resp.Header.Get("Pool"))
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package querylog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/weaveworks/common/middleware"
+)
+
+// Entry is a query recorded by the query frontend.
+type Entry struct {
+	Timestamp time.Time     `json:"ts"`
+	Tenant    string        `json:"tenant"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Params    url.Values    `json:"params"`
+	Status    int           `json:"status"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Recorder writes the queries received by the frontend to a file, one JSON encoded entry per line.
+type Recorder struct {
+	logger log.Logger
+
+	mtx  sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+// NewRecorder creates a recorder appending to the given file.
+func NewRecorder(path string, logger log.Logger) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	return &Recorder{
+		logger: logger,
+		file:   f,
+		w:      w,
+		enc:    json.NewEncoder(w),
+	}, nil
+}
+
+// Record writes the entry to the query log.
+func (r *Recorder) Record(e Entry) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if err := r.enc.Encode(e); err != nil {
+		level.Warn(r.logger).Log("msg", "failed to record query", "err", err)
+		return
+	}
+	// flush every entry so that the log can be replayed while the frontend is running.
+	if err := r.w.Flush(); err != nil {
+		level.Warn(r.logger).Log("msg", "failed to flush query log", "err", err)
+	}
+}
+
+// Close flushes and closes the query log.
+func (r *Recorder) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	return r.file.Close()
+}
+
+// Middleware records every request it serves. It must run after the authentication middleware.
+func (r *Recorder) Middleware() middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			// parse the form before the request body is consumed, this is a no-op for the next handlers.
+			if err := req.ParseForm(); err != nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+			tenantID, _ := tenant.TenantID(req.Context())
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, req)
+			r.Record(Entry{
+				Timestamp: start,
+				Tenant:    tenantID,
+				Method:    req.Method,
+				Path:      req.URL.Path,
+				Params:    req.Form,
+				Status:    sw.status,
+				Duration:  time.Since(start),
+			})
+		})
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// ReadEntries reads all entries of a query log.
+func ReadEntries(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	dec := json.NewDecoder(r)
+	for {
+		var e Entry
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
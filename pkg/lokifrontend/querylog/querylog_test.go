@@ -0,0 +1,52 @@
+package querylog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func Test_RecorderMiddleware(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.log")
+	r, err := NewRecorder(path, log.NewNopLogger())
+	require.NoError(t, err)
+
+	handler := r.Middleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.FormValue("query") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, `/loki/api/v1/query_range?query={app="foo"}&start=1&end=2`, nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "fake"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPost, "/loki/api/v1/query", strings.NewReader("limit=10"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.NoError(t, r.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	entries, err := ReadEntries(f)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	require.Equal(t, "fake", entries[0].Tenant)
+	require.Equal(t, "/loki/api/v1/query_range", entries[0].Path)
+	require.Equal(t, `{app="foo"}`, entries[0].Params.Get("query"))
+	require.Equal(t, http.StatusOK, entries[0].Status)
+
+	require.Equal(t, "", entries[1].Tenant)
+	require.Equal(t, http.MethodPost, entries[1].Method)
+	require.Equal(t, "10", entries[1].Params.Get("limit"))
+	require.Equal(t, http.StatusBadRequest, entries[1].Status)
+}
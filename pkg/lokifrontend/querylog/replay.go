@@ -0,0 +1,217 @@
+package querylog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/loki/pkg/loghttp"
+)
+
+// timestampParams are the query parameters shifted when replaying with ShiftTime.
+var timestampParams = []string{"start", "end", "time"}
+
+// ReplayConfig configures how a query log is replayed.
+type ReplayConfig struct {
+	// Target is the address of the Loki cluster to replay the queries against.
+	Target *url.URL
+	// Concurrency is the maximum number of queries in flight.
+	Concurrency int
+	// Speed multiplies the pace at which queries were recorded, 0 replays as fast as possible.
+	Speed float64
+	// ShiftTime moves the time range of every query by the time elapsed since it was recorded.
+	ShiftTime bool
+	// Tenant overrides the recorded tenant when not empty.
+	Tenant string
+}
+
+// Result is the outcome of a replayed query.
+type Result struct {
+	Entry    Entry
+	Status   int
+	Duration time.Duration
+	Err      error
+}
+
+// Replayer re-executes recorded queries against a Loki cluster.
+type Replayer struct {
+	cfg    ReplayConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewReplayer creates a new replayer.
+func NewReplayer(cfg ReplayConfig, client *http.Client) *Replayer {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	return &Replayer{
+		cfg:    cfg,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// Replay replays the entries in order and returns a result per entry.
+func (r *Replayer) Replay(ctx context.Context, entries []Entry) []Result {
+	results := make([]Result, len(entries))
+	if len(entries) == 0 {
+		return results
+	}
+	var (
+		wg    sync.WaitGroup
+		slots = make(chan struct{}, r.cfg.Concurrency)
+		start = r.now()
+		first = entries[0].Timestamp
+	)
+	// sent is the number of entries replayed, the entries after it are skipped once the context is done.
+	sent := 0
+loop:
+	for i, e := range entries {
+		if r.cfg.Speed > 0 {
+			at := start.Add(time.Duration(float64(e.Timestamp.Sub(first)) / r.cfg.Speed))
+			select {
+			case <-ctx.Done():
+				break loop
+			case <-time.After(at.Sub(r.now())):
+			}
+		}
+		select {
+		case <-ctx.Done():
+			break loop
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int, e Entry) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = r.do(ctx, e)
+		}(i, e)
+		sent++
+	}
+	// The results are written by the replaying goroutines until they are done.
+	wg.Wait()
+	return results[:sent]
+}
+
+func (r *Replayer) do(ctx context.Context, e Entry) Result {
+	res := Result{Entry: e}
+	params, err := r.params(e)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	u := *r.cfg.Target
+	u.Path = strings.TrimSuffix(u.Path, "/") + e.Path
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	tenantID := e.Tenant
+	if r.cfg.Tenant != "" {
+		tenantID = r.cfg.Tenant
+	}
+	if tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", tenantID)
+	}
+
+	start := r.now()
+	resp, err := r.client.Do(req)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer resp.Body.Close()
+	// read the whole response so that the duration includes the transfer.
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	res.Duration = r.now().Sub(start)
+	res.Status = resp.StatusCode
+	res.Err = err
+	return res
+}
+
+// params returns the query parameters of the entry, shifted in time when configured.
+func (r *Replayer) params(e Entry) (url.Values, error) {
+	params := url.Values{}
+	for k, v := range e.Params {
+		params[k] = append([]string(nil), v...)
+	}
+	if !r.cfg.ShiftTime {
+		return params, nil
+	}
+	offset := r.now().Sub(e.Timestamp)
+	for _, name := range timestampParams {
+		v := params.Get(name)
+		if v == "" {
+			// missing timestamps default to the time of the query.
+			continue
+		}
+		ts, err := loghttp.ParseTimestamp(v, time.Time{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter %q: %w", name, v, err)
+		}
+		params.Set(name, strconv.FormatInt(ts.Add(offset).UnixNano(), 10))
+	}
+	return params, nil
+}
+
+// Summary compares the replayed queries with the recorded ones.
+type Summary struct {
+	Queries          int
+	Errors           int
+	StatusMismatches int
+	// Recorded and Replayed are the 50th, 90th and 99th percentiles of the query durations.
+	Recorded [3]time.Duration
+	Replayed [3]time.Duration
+}
+
+// Summarize computes the summary of a replay.
+func Summarize(results []Result) Summary {
+	s := Summary{Queries: len(results)}
+	var recorded, replayed []time.Duration
+	for _, r := range results {
+		if r.Err != nil {
+			s.Errors++
+			continue
+		}
+		if r.Status != r.Entry.Status {
+			s.StatusMismatches++
+		}
+		recorded = append(recorded, r.Entry.Duration)
+		replayed = append(replayed, r.Duration)
+	}
+	s.Recorded = percentiles(recorded)
+	s.Replayed = percentiles(replayed)
+	return s
+}
+
+func percentiles(d []time.Duration) [3]time.Duration {
+	var res [3]time.Duration
+	if len(d) == 0 {
+		return res
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	for i, q := range []float64{0.5, 0.9, 0.99} {
+		res[i] = d[int(q*float64(len(d)-1))]
+	}
+	return res
+}
+
+func (s Summary) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "queries: %d, errors: %d, status mismatches: %d\n", s.Queries, s.Errors, s.StatusMismatches)
+	fmt.Fprintf(&sb, "recorded p50: %s p90: %s p99: %s\n", s.Recorded[0], s.Recorded[1], s.Recorded[2])
+	fmt.Fprintf(&sb, "replayed p50: %s p90: %s p99: %s\n", s.Replayed[0], s.Replayed[1], s.Replayed[2])
+	return sb.String()
+}
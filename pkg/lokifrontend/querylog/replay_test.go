@@ -0,0 +1,105 @@
+package querylog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Replay(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		requests []*http.Request
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mtx.Lock()
+		requests = append(requests, req)
+		mtx.Unlock()
+		if req.URL.Query().Get("query") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	target, err := url.Parse(srv.URL + "/prefix/")
+	require.NoError(t, err)
+
+	recorded := time.Unix(1000, 0)
+	now := recorded.Add(time.Hour)
+	entries := []Entry{
+		{
+			Timestamp: recorded,
+			Tenant:    "fake",
+			Path:      "/loki/api/v1/query_range",
+			Params:    url.Values{"query": {`{app="foo"}`}, "start": {"900"}, "end": {"1000000000000"}},
+			Status:    http.StatusOK,
+			Duration:  time.Second,
+		},
+		{
+			Timestamp: recorded.Add(time.Second),
+			Tenant:    "fake",
+			Path:      "/loki/api/v1/query",
+			Params:    url.Values{"time": {"1970-01-01T00:16:40Z"}},
+			Status:    http.StatusOK,
+			Duration:  time.Second,
+		},
+	}
+
+	r := NewReplayer(ReplayConfig{Target: target, Concurrency: 1, ShiftTime: true, Tenant: "replay"}, srv.Client())
+	r.now = func() time.Time { return now }
+	results := r.Replay(context.Background(), entries)
+	require.Len(t, results, 2)
+	require.Len(t, requests, 2)
+
+	require.Equal(t, "/prefix/loki/api/v1/query_range", requests[0].URL.Path)
+	require.Equal(t, "replay", requests[0].Header.Get("X-Scope-OrgID"))
+	require.Equal(t, strconv.FormatInt(time.Unix(900, 0).Add(time.Hour).UnixNano(), 10), requests[0].URL.Query().Get("start"))
+	require.Equal(t, strconv.FormatInt(time.Unix(1000, 0).Add(time.Hour).UnixNano(), 10), requests[0].URL.Query().Get("end"))
+	require.Equal(t, strconv.FormatInt(time.Unix(1000, 0).Add(time.Hour-time.Second).UnixNano(), 10), requests[1].URL.Query().Get("time"))
+
+	require.Equal(t, http.StatusOK, results[0].Status)
+	require.Equal(t, http.StatusBadRequest, results[1].Status)
+
+	s := Summarize(results)
+	require.Equal(t, 2, s.Queries)
+	require.Equal(t, 0, s.Errors)
+	require.Equal(t, 1, s.StatusMismatches)
+	require.Equal(t, time.Second, s.Recorded[0])
+}
+
+func Test_ReplayCancel(t *testing.T) {
+	received := make(chan struct{}, 3)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received <- struct{}{}
+		<-req.Context().Done()
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	entries := make([]Entry, 3)
+	for i := range entries {
+		entries[i] = Entry{Timestamp: time.Unix(1000, 0), Path: "/loki/api/v1/query", Params: url.Values{"query": {`{app="foo"}`}}}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-received
+		<-received
+		cancel()
+	}()
+
+	// The queries in flight are waited for, their results are complete once returned.
+	results := NewReplayer(ReplayConfig{Target: target, Concurrency: 2}, srv.Client()).Replay(ctx, entries)
+	require.Len(t, results, 2)
+	for _, res := range results {
+		require.Error(t, res.Err)
+		require.Equal(t, `{app="foo"}`, res.Entry.Params.Get("query"))
+	}
+}
@@ -0,0 +1,202 @@
+// Code generated by vfsgen; DO NOT EDIT.
+
+// +build !dev
+
+package ui
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	pathpkg "path"
+	"time"
+)
+
+// Assets statically implements the virtual filesystem provided to vfsgen.
+var Assets = func() http.FileSystem {
+	fs := vfsgen۰FS{
+		"/": &vfsgen۰DirInfo{
+			name:    "/",
+			modTime: time.Date(1970, 1, 1, 0, 0, 1, 0, time.UTC),
+		},
+		"/index.html": &vfsgen۰CompressedFileInfo{
+			name:             "index.html",
+			modTime:          time.Date(1970, 1, 1, 0, 0, 1, 0, time.UTC),
+			uncompressedSize: 1764,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x9c\x55\x4b\x6f\xdb\x38\x10\xbe\xe7\x57\x30\x73\xc9\xc9\x2b\x2b\xc9\x7a\xb3\x00\x49\x60\xb1\xe9\xa1\x40\x80\x14\x69\x50\xb4\x47\x4a\x1a\x5b\x8c\x29\x51\x25\x29\xa7\xee\xeb\xb7\x17\x43\x4a\x7e\xd5\x29\xdc\x5e\x6c\x72\x1e\xdf\x0c\xe7\x9b\xd1\xf0\xf3\xdb\xfb\xff\x1f\x3f\xbc\x79\xc5\xea\xd0\x18\x79\xc6\xe9\x8f\x19\xd5\x2e\x04\x60\x0b\x24\x40\x55\xc9\x33\xc6\x78\x83\x41\xb1\xb2\x56\xce\x63\x10\xd0\x87\xf9\xe4\x06\xa2\x22\xe8\x60\x50\xde\xd9\xa5\xe6\x59\x3a\x93\xd4\xe8\x76\xc9\x1c\x1a\x01\x3e\xac\x0d\xfa\x1a\x31\x00\xab\x1d\xce\x05\xf4\xfa\xaf\xd2\x7b\x42\xcf\x12\x3c\x2f\x6c\xb5\x8e\x6e\x74\x47\x47\x47\xba\xe4\x03\x6c\x9d\x0f\x12\xa3\x0a\x34\xf2\x11\x5b\xd5\x06\xc6\x75\xdb\xf5\x81\xe9\x4a\x40\x88\x12\x60\x61\xdd\x21\xdd\x3e\x05\x60\x9d\x51\x25\xd6\xd6\x54\xe8\x04\xbc\x9f\xbc\x2d\x6d\x87\x93\x7b\xb7\x78\x7d\x0b\xcc\xeb\xcf\x28\x20\x9f\x81\xe4\x59\xc2\xa4\xe0\xd9\x18\x9d\x2e\x1e\xcb\xa0\x6d\x1b\xe1\x8b\x5e\x13\x0c\xec\x65\x71\x47\xbf\x64\x67\xb0\x4c\x59\x44\xf9\xa4\x55\x0d\x82\xe4\xb6\x8b\xee\x2b\x65\x7a\x14\x00\x72\xc2\xb3\x24\x92\x3c\x4b\x3e\xbb\xb1\xd9\x11\x20\xdb\x0d\x01\x19\x1b\xd0\xa4\xd8\x80\x1c\x28\xce\x5f\xd4\x88\xef\x2f\xfa\x1c\x68\x36\x79\xed\xbe\xf2\x1d\xe5\x7f\x24\xb9\xf8\xae\x3f\x79\x66\xd1\x87\x30\x94\x55\x55\xd5\xa4\x51\xa1\xac\xd1\x8d\xd4\x25\x2d\xc8\xff\xaa\x8a\x05\xcb\x12\x82\x75\x3c\x4b\x8a\x44\xd3\x40\x4d\xe2\x69\x6e\x5d\x13\xd1\x3e\xf6\xe8\xd6\x13\xba\x8e\x3c\x51\x1f\x28\x87\x6a\xab\x06\xe6\xec\xb3\x17\x70\x05\xcc\x77\x68\x4c\x59\x63\xb9\x14\x30\x57\xc6\xe3\x7e\xc7\x5c\x7c\x79\xb2\x85\x80\x95\x72\xc6\x2e\x3c\x7c\x63\x5f\x05\x03\x74\xce\x3a\xb8\x90\x3c\x1b\xa1\x87\x48\x95\x5e\xb1\xd2\x28\xef\x05\x94\xb6\x0d\xce\x1a\xbf\xe5\x2e\xbd\xfe\x41\xb5\x0b\x1c\x44\xfb\x6c\x3b\xd2\x6c\xcc\x77\x18\x1a\xab\x7a\x35\x9d\x82\xfc\xbb\x39\xe4\xf1\x88\xe5\xbf\x64\x99\x9f\x64\x7a\x35\x9b\x4e\x61\x28\x30\x56\x32\xaf\x4f\xf0\xb9\xcc\xc9\x49\xce\x4e\xb1\xbd\x99\x5d\x93\xed\xe5\xf5\x29\xc6\xb3\xe9\xf5\x0d\x59\xff\x53\xfd\x6c\xbc\xdf\x96\x74\xdf\x69\xa7\xed\x34\xea\x46\xef\x7d\x12\x0c\x09\xc6\xb6\x6a\xfb\xa6\xa0\x26\x6b\x74\x2b\x20\x87\x31\x6c\x4e\x31\x8f\xe3\xdd\x6a\x97\xba\xec\x28\x65\xd5\xa8\xfd\x15\x6d\x85\x2a\x97\xcf\xca\x55\x20\xc7\xd3\x09\x95\x98\x5b\x97\x7c\x86\xc3\xef\xd7\x63\x67\xc0\x5c\xdf\x8e\x15\xf0\x7d\x41\xf5\x90\x0f\x7d\xcb\xe2\x28\xec\x8e\xd4\xa1\x5f\x50\xda\x1c\x4e\xe4\xa3\xd2\x66\xdf\x87\x67\x95\x5e\xa5\x89\xa4\xa9\x4b\xe3\x48\x93\x40\x10\x3e\xa8\xd0\x7b\x90\x5b\xa3\xa0\x0a\x83\x29\x2d\xf4\xbd\x09\x7e\x33\xa6\x71\x09\xf0\x2c\x8c\xbb\x86\x64\x71\x23\xf0\x2c\x6c\x36\x43\x16\xfd\x87\x6f\x73\xe9\x74\x17\x98\x77\x65\x5c\x26\x4f\x31\x4e\x12\xd2\x52\x49\x3e\x3c\x4b\x3b\xed\xc7\x00\x4c\x73\x45\x18\xe4\x06\x00\x00"),
+		},
+		"/ui.css": &vfsgen۰CompressedFileInfo{
+			name:             "ui.css",
+			modTime:          time.Date(1970, 1, 1, 0, 0, 1, 0, time.UTC),
+			uncompressedSize: 809,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x7c\x90\xd1\x6e\xdb\x30\x0c\x45\xdf\xf3\x15\x04\x82\xbd\x45\x46\x9c\x05\xd9\xa6\x7c\x0d\x2d\x51\x89\x56\x59\x14\x24\xa6\x71\x16\xec\xdf\x07\xc9\xf6\x56\xac\x45\x5f\x6c\x81\xe4\x25\xef\xb9\x03\xdb\x07\x3c\x37\x00\x8e\xa3\x28\x87\xa3\x0f\x0f\x0d\x05\x63\x51\x85\xb2\x77\xe7\xb5\x55\xfc\x2f\xd2\xd0\x1f\xd3\x54\x4b\x23\xe6\x8b\x8f\x1a\xf6\xd0\x9f\xd2\xd4\x3e\xe7\xcd\xef\xcd\xe6\x4a\x68\x29\xb7\x85\xd6\x97\x14\xf0\xa1\xc1\x05\x6a\x1a\x0c\xfe\x12\x95\x17\x1a\x8b\x06\x43\x51\x28\xd7\xf2\xcf\x5b\x11\xef\x1e\xca\x70\x14\x8a\xa2\xa1\x24\x34\xa4\x06\x92\x3b\x51\x6c\x5b\x0b\x19\xf1\x1c\x77\xe0\x38\x8f\x3b\xd8\x16\x41\xb9\x15\x78\xfe\x35\xa2\x06\x16\xe1\x51\xc3\xf7\xc5\x87\xd0\x24\x98\x09\xdb\xcc\xc0\x53\xb5\xef\xe3\x45\xc3\xc0\xd9\x52\x56\x03\x4f\xe7\xff\xa1\x47\x8e\xdc\x6e\xd7\xce\xdd\x5b\xb9\x6a\xe8\xf7\xfb\x2f\x6d\x61\x57\xed\x65\x0e\xe5\x63\xb6\x0b\x26\x0d\xfd\x21\x7d\x06\xba\x58\x15\x4e\x1a\x8e\x8b\xcf\x6d\xf0\xa3\x17\x78\xfe\xbb\x78\xa2\x71\xee\xcc\x90\x1d\xe5\xcc\x73\xa0\x86\x03\x67\x0d\x5b\x73\xec\x4f\x07\x9c\x31\x71\x08\xb4\x30\x36\x2e\xc3\x21\x60\x2a\xa4\x61\x7d\x7d\x04\x23\xd7\x1d\x88\x7d\xab\x5b\xf3\xeb\xd3\x04\x85\x83\xb7\xb0\xb5\xd6\x56\x6d\x42\x6b\x5b\x72\x87\x34\xc1\x69\x06\xac\xe9\xaa\x46\xa9\x21\x90\x93\x5a\x7b\xa5\x2c\xde\x60\x58\xeb\xc2\x69\xbe\x65\xe1\xf9\x49\xd2\x6d\xa2\x13\x3f\x52\xb5\xd4\x05\x1c\x68\xc9\xf8\x7e\xf5\x42\xaa\x8d\x69\x88\x7c\xcf\xb8\x2e\xec\x82\x8f\xf4\x7e\x26\x65\x52\xf3\x14\xc0\x9d\xb3\x55\x43\x26\x7c\xd1\xd0\x7e\x0a\x43\x68\xf2\xe1\x26\xc2\xb1\x43\x23\xfe\x75\x89\x0e\xcd\xcb\x25\xf3\x2d\x5a\x0d\xdb\xaf\xf6\x5b\x6f\x7f\x9c\xdf\xc4\xed\x9c\xab\xc2\x3f\x03\x00\xf9\xdb\x5a\xb7\x29\x03\x00\x00"),
+		},
+		"/ui.js": &vfsgen۰CompressedFileInfo{
+			name:             "ui.js",
+			modTime:          time.Date(1970, 1, 1, 0, 0, 1, 0, time.UTC),
+			uncompressedSize: 8011,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xb4\x19\x5d\x73\xe3\xb6\xf1\x5d\xbf\x62\x73\xe3\x06\x64\x4c\x53\x76\x3b\xd3\x07\x39\x3c\x4f\x73\xb9\x4c\xdc\x5c\xee\xd2\xf3\xa5\xd3\x89\xe3\xd4\x30\xb9\x96\x10\x93\x80\x0a\x40\xd6\x69\x14\xfd\xf7\xce\x02\x04\x09\xea\xc3\xbe\xb6\xe9\x8b\x44\x02\x8b\xc5\x7e\x7f\x71\x3c\x86\x0f\x33\x84\x1f\x2f\x41\x18\x30\xa8\x1f\xb1\x82\x85\xac\x50\xc3\x78\x21\xc6\x19\xd8\x19\xc2\x5f\x7e\xb8\x84\x39\xb7\x33\x03\x5c\x23\x68\xac\xb9\x15\x8f\x08\x46\x81\x9d\x71\x0b\xc2\xc2\x52\xe9\x07\x03\x77\x38\x13\xb2\x02\xee\x80\x61\xae\xf1\x5e\x7c\xcc\x47\xa5\x92\xc6\x02\x9f\x0b\x28\x80\xe5\xf9\xb8\x56\x0f\x62\xcc\xe7\x62\xfc\x78\x36\x66\xe7\xa3\x11\x51\xc0\x45\x2d\xe4\x14\xe6\xaa\xae\x8d\xbb\x52\x73\x39\x45\xf8\xd7\x02\xf5\x8a\xae\xcf\x76\x2f\x5b\x0a\x3b\x73\xa0\x16\x25\x97\x16\x66\xc8\x89\x6a\x2e\x2b\xb0\x33\xad\x16\x53\xbf\x7b\xaf\x95\xb4\x28\xab\x40\x87\xe5\xa2\xbe\x94\x16\xf5\x23\xaf\xa1\x80\x3f\x9e\x9e\x9e\x9e\x47\x5b\xdf\xf3\x8f\xef\xd5\xd2\x40\x01\x67\x6e\xa7\xdd\x3a\x82\x02\x12\x51\xa5\x50\xbc\x84\x4a\x95\x8b\x06\xa5\xcd\xa7\x68\x5f\xd7\x48\x8f\x5f\xad\x2e\x2b\xda\x3e\x1f\x8d\x6a\xf4\x88\x3e\x88\x06\x35\x14\x20\x17\x75\x7d\xde\xad\x7e\xa3\x55\xd3\x2d\x8e\xee\x17\xb2\xb4\x42\xc9\x96\x76\x93\xa4\xb0\x1e\x01\xb4\xd4\x78\xb6\x0a\x38\x4a\x98\x7f\x66\x69\xfe\xc8\xeb\x05\xe6\x56\x8b\x26\x49\xcf\x47\x00\x1a\xed\x42\xcb\x00\x7b\x01\x6b\x60\xff\x38\xb9\x2a\xd5\x1c\x4f\xde\xe9\xe9\xe5\xd7\x6c\x12\xf6\x36\x30\x81\xf5\xe6\x7c\xb4\x19\x8d\xb8\x59\xc9\x12\xba\xcb\xa7\x68\x13\x52\x58\x06\x73\xae\x79\x63\x62\x22\x16\x9a\x84\x44\xba\x3b\xf6\x4a\x3d\x86\xc4\x43\xc1\x05\xb0\x0b\x06\xc7\x20\x71\x09\x3f\xbe\x7f\x73\x85\x5c\x97\xb3\x1f\xdc\x5e\x12\x10\x4d\x80\xb1\xf4\xbc\xc3\xa6\x91\x04\xcb\x97\x5c\x58\xb8\x47\x5b\xce\x92\x85\xae\x33\x58\x07\xfe\x27\x91\x20\x36\xd1\xb9\x3b\x55\xad\xba\x83\x1a\x4d\x6e\xf1\xa3\xf5\x02\x10\xf7\x90\x7c\x46\x4b\xea\xc1\xd3\x0d\x40\xb2\x6e\xcc\x14\x0a\x77\xee\xdc\xad\x59\xbd\x6a\x77\xa1\xdd\xfb\xeb\xd5\xbb\xb7\xf9\x9c\x6b\x83\x09\x81\xa5\x39\x6a\xad\x34\xfc\xf6\x5b\x74\x6a\x03\x25\xb7\xe5\x0c\x12\x4c\xbb\xd3\xad\xbf\x38\x92\x84\x01\xa9\xac\x43\x95\x81\xb0\xf4\x6e\x66\x6a\x29\x81\x1b\x10\x26\xf7\x38\xdc\x2f\x59\xe4\xd2\x89\xea\x35\x5d\x93\xdc\x1e\xad\x89\x68\x63\xb9\x5d\x98\xcd\x04\x8e\xd6\x8d\x99\x6e\x6e\x1d\x4b\x9b\x5e\xaf\xdb\x44\x3a\xf5\x75\x8a\x33\x68\xaf\x1c\x82\xa4\x31\xd3\x0c\x84\x71\xb8\x3d\xa9\x47\x09\xf3\xc8\x59\xea\xa4\xf5\xca\x79\x01\x99\x53\x63\xa6\xe7\x5b\x00\x65\xcd\x8d\x79\xcb\x1b\x84\x22\x60\x21\xf5\x3a\x89\x30\xa7\xc4\xe1\xc5\x52\x2d\xdf\x72\xa9\x82\xbd\xb6\xc4\x7e\x25\xa6\x97\xd2\x26\x5f\x73\x8b\xb9\x54\xcb\x24\x4d\xe1\x0b\xe7\x45\xa7\xa7\xa7\x72\x88\xe0\x5e\xe9\x86\x5b\x8f\xc3\x0e\x0c\x4e\x92\x85\xb4\x98\xac\x89\x6c\xa0\xe2\x96\xc8\x23\x11\xd2\x0d\xc9\xdb\x45\x73\x87\x3a\x91\x06\xc6\xdd\x25\x69\xec\x13\x74\x20\xb7\xea\xf2\xea\xdd\x95\xd5\x42\x4e\x93\x34\xd7\x38\xaf\x79\x89\x09\xfb\x89\x65\x40\x47\xff\xd0\x1f\xcd\xad\xea\xe0\xe6\xbc\xba\xb2\x5c\xdb\xe4\xcf\x19\xb0\x53\x96\xc2\x31\xb0\x9f\x58\xba\x8f\x87\x37\xfc\x0e\x6b\x93\xd4\xee\x6f\x20\x0e\xb6\x26\xef\x78\x77\xf7\x2b\x96\x36\x7f\xc0\x55\x07\x94\x1b\xa5\x6d\x92\xe6\x0d\x9f\x27\xc9\x83\x0b\x29\xb7\x47\xeb\x87\x4d\xf1\xe2\x68\xed\x41\xae\x1f\x6e\x36\x2f\x6e\xd3\xfc\x57\x25\x64\xc2\x32\xf0\x14\x6c\xb6\xb4\x50\x62\x5d\x27\x5a\x2d\x33\xe8\xf4\x97\x01\xe9\x7a\x10\x45\x2a\x28\x40\xab\x65\x2e\xa4\x41\x6d\x5f\xd1\x19\x27\x25\x5b\x0d\xd4\xde\x3d\xb7\x7b\x43\x9b\xa1\xb7\x1d\xdb\x7b\xa5\xea\x45\x23\x4d\x22\x79\x83\x03\x1d\x92\x13\xfb\xb8\xa5\xd1\x2c\x6a\xeb\x6c\xf0\x5b\xe4\x15\xe1\xa6\xcd\x5c\x48\x89\xfa\xdb\x0f\xdf\xbf\xa1\x94\xc0\xa2\x00\xa1\x96\x50\x04\x10\xa2\xf7\x3d\xd9\x11\xed\xdf\x2b\x0d\x49\x6b\x22\x44\xb1\xba\x87\xe8\xde\x8e\xdb\x19\x14\x7d\x7c\x2e\x35\x72\x8b\x6d\x88\x4e\x98\x9d\xf9\x58\x44\xde\xb8\xc5\x9f\x6c\x19\x07\x27\x2a\x3e\x9f\xa3\xac\x5e\xcd\x44\x5d\x25\x76\xd6\xf9\xe4\x90\x9f\xaf\x54\x25\xd0\x5c\x9f\xde\xec\x30\xb3\x71\x29\xcd\x58\x8d\xbc\x71\xb9\xe4\xbe\xe6\xd6\xa2\xf4\x89\x0d\xa5\xd5\x02\x0d\x31\x40\xaf\x1e\xcc\x50\x7e\xd3\x16\x2b\xb8\x5b\x81\x15\x0d\x82\x90\x6e\x7b\x2a\x1e\x51\x42\x25\x34\x3a\xb1\xe7\x91\x02\x3a\xfc\x49\x87\xa3\x83\x8b\xb5\xa1\x7d\x3a\xbb\xbe\xd9\x12\xa3\x3f\x45\x74\xb4\xe7\x83\x28\x23\x98\x6b\x6b\x32\xa8\x85\xc4\x9b\x1e\xce\xe7\x20\xd3\x47\x44\xba\x20\x9f\x2f\xcc\x2c\x59\x83\x35\x93\xc8\x7b\x33\xf0\xf6\x3c\x09\x47\xfd\x9f\x47\xd9\x46\x78\x2f\xdb\xcd\xa8\xc5\xe3\x7c\x23\xe1\x19\xdc\x39\xc7\x48\x78\x6e\x0d\x7c\x09\x77\xf4\x77\x01\x27\x67\x30\x01\xb7\xf4\x32\x2c\xd1\xca\x69\xda\xe5\x82\x4e\x04\x50\x14\x05\xb0\x3b\x5e\x3e\x2c\xb9\xae\x58\x20\xd7\x5d\xa2\xf1\x11\x29\xa6\x6e\x87\x5b\xda\x1c\x9a\xb9\x37\x85\xab\x5e\xd6\x04\x12\x4b\xb7\x4d\x4d\x07\x6c\x63\x4b\xe4\x9a\xa4\xd8\x63\x18\x5a\x3d\x61\xda\xb6\x7a\x88\x9c\x9c\x91\x5d\xb0\x6c\x10\x3b\x75\x6e\x4d\xba\x0b\xe9\xa5\xce\xb2\x61\x8c\xd2\xb9\x5f\xdf\x77\x40\x48\x42\xad\x73\x7a\xe8\xa4\xb2\x9c\x89\x1a\xc1\x65\x9e\xdc\xc9\xad\x46\x39\xb5\x33\x78\x19\x57\x4a\x81\x15\x07\x55\x61\x8d\x16\x89\xfc\xd3\x16\xcb\x20\x66\xcc\xd4\xf2\xbd\x93\x52\x52\x71\xcb\x77\xec\xd5\x2c\x85\xcb\xb6\xb4\x99\x7b\x71\x7e\x58\xcd\xbb\xdc\x5b\x72\x83\xc0\x5a\x63\x65\x93\xce\xfe\xa2\x50\x74\xcd\x3e\x78\x21\xb1\x37\x41\x04\xec\x0d\xf1\x76\xd3\xf2\xbc\xe5\x14\x91\x13\x45\x97\xc6\x84\x85\x63\xfb\x0d\x21\xec\x46\xf6\x13\x84\x74\x0c\xcc\x99\xb9\x61\xe7\x51\x1d\xe0\x79\x68\xb8\xd5\xe2\xe3\x21\x16\x7a\xd2\x03\x33\x7f\x27\x8f\xdb\xe1\xe1\x79\xd3\xdb\xf2\x66\x83\x21\xf2\x44\xcc\xf6\x7e\xbc\xeb\xf9\xce\xd3\xbd\xeb\xbb\xa3\x3b\xae\xff\x69\x36\x0c\xb0\x6d\x6e\x7b\xed\xb3\xbd\xa3\x41\xab\x45\x99\x1e\x3a\xdb\x7a\x41\x57\x07\x58\xd3\x56\x19\xe9\x30\xd9\x1f\x3a\xef\x58\x60\x2d\x6f\x11\xd0\x66\x34\xfc\xef\xab\x88\x20\xa9\x58\xb3\x9e\xd6\x3d\xaa\x7d\xc4\xd2\x2a\xfd\xbc\x6a\x7f\x2f\x95\xf2\x66\x5e\xe3\x13\x2a\xfd\x34\xed\x3c\xaf\x1b\x77\xcf\x1e\xdd\xec\x91\x6c\x0b\xeb\x5e\xaf\xcf\x7a\x16\xff\x6b\xc9\x56\x78\xcf\x17\xb5\x9d\xec\x93\xe8\x8e\x1c\x89\x9e\xc3\x99\xba\xe3\x3d\xa2\xd7\x15\xd9\xc6\x99\x8d\xb8\x5f\xc5\x81\x20\xdd\x76\x70\xc6\xba\xa8\xb6\xd5\x44\xe9\x85\xfc\x1b\x35\xab\x6d\x49\x6c\xac\x9a\x53\x53\x9b\x44\x15\xac\x6f\x66\x9d\x76\xdd\xe3\x9e\x76\xce\x75\x33\x6e\xb3\xcb\x57\xee\xe2\x10\x91\x3d\x22\x94\x54\x5e\xf5\x45\x78\x7f\x85\xb1\x5c\x5b\x28\x1c\xc4\x49\x48\xc4\x24\x0d\xea\xa6\xc3\x7d\x51\x59\xee\x2b\xf3\x70\x3a\xca\x9d\x44\x64\xf7\x1a\x0e\x12\x64\xdf\x73\xb0\xf7\x0b\x29\x85\x9c\xe6\x79\xee\xeb\xaa\xbe\xc7\xda\xed\xf6\xa8\xc7\xf4\x5c\xff\xd3\xd3\x92\x75\x36\xea\x56\xb3\xf6\xc5\x31\x30\xf1\x7f\x51\x45\x1e\xb6\x51\x56\x13\xfa\xd9\xb3\x55\x8b\x46\xd8\x09\xd1\xed\x9e\x02\xcd\x61\xbb\x63\xc6\x2f\x84\xda\xa3\x15\xdb\xa2\x69\xb8\xd3\x4d\x94\xa2\xa8\x3f\xdb\x4e\x53\xf1\x21\xab\xd4\x03\x14\x10\xc0\x5c\x2f\x67\xe0\xf3\xcf\xb7\x56\xf2\x80\xfc\xe2\xc0\x46\x8e\x1f\xb1\xa4\x48\x0f\x93\x76\x2e\x00\x10\xcb\x39\x9c\x3f\x86\xc4\x5d\xf9\x59\xe1\xe7\x07\x70\x01\xb7\x20\x24\x1c\xad\x69\x39\xb7\xea\x1b\xf1\x11\xab\xe4\x4f\xe9\xc6\xdc\xfa\xc6\xdb\xa7\xe0\x9d\x1e\xb6\x47\x4d\x0e\x6d\x0c\x9f\x52\xdf\xa0\x17\x98\x1e\x32\x6e\x1a\xcb\x78\x6b\x8e\x2a\x9f\x4f\x30\xe7\xc3\xe6\xfa\x3b\xda\x4a\x18\xab\xfc\x7f\xcc\x65\x02\xec\x5e\x69\x57\x42\x0e\x2d\x87\x5c\xb5\x53\x68\x5f\xac\x38\xf5\x74\x45\x4a\x1f\x89\xb7\x07\x00\x4c\xc9\x7a\x05\xb5\x9a\x3a\x9e\x04\x1a\x28\xb9\x84\x3b\x74\xec\x60\xc5\xd2\x41\x6a\x39\x50\xb3\x6c\xdd\x9f\xf5\xb4\xb6\xc7\x9f\xaa\x5a\x1c\x03\x83\xaa\xee\x34\x22\xb7\x9f\x55\x11\xcc\x75\x0c\x78\x02\x67\x37\xb9\x35\x70\x0c\x67\x32\xa6\x32\x0a\x0d\xed\x3c\x2f\x03\x6a\x81\x0f\x45\xe3\xfd\xd5\xd2\x21\xa3\x1d\x04\xd4\xe7\x8c\x78\x3b\x6e\x12\xaf\xfd\x50\x2e\x78\x50\xc0\xdd\xef\x14\x84\x97\x1e\xd5\xc2\x26\xc1\xea\xb3\xc1\xcc\x70\x5f\x5d\x4b\x86\x18\xf9\x87\x8b\xe2\x07\xdc\x62\x7f\x5c\xff\xf4\x12\x36\x1e\x22\x76\x1e\xd5\xc7\xfa\x01\xa5\x83\xf1\x8b\xeb\x5f\x69\x3b\xcc\x7b\xde\x08\x63\x73\x5e\x55\x09\xe3\x25\x8d\x73\x59\x87\xff\x49\x59\x9c\x6e\x0f\xa1\x3a\xc5\x74\xbc\x1f\x96\x74\x59\x23\xd7\x01\x67\x07\x16\xba\xf2\x9d\xa9\x69\xd7\x77\xef\xd0\xad\xb1\x51\x8f\x18\x93\x3e\x18\xcd\x88\xba\xbe\xc2\x1a\x4b\x9b\x18\xf7\x97\x41\x5c\xb9\xfa\xb5\x61\xdf\xfe\xa5\x9a\xbb\xa3\x0e\xae\x78\xf1\xe2\xe5\xc9\x97\x63\xbf\xf4\x92\x6d\x75\x71\x8f\x54\x6e\x79\x7c\x34\x2e\xbc\xbe\x19\x76\x73\x2d\xa2\xc3\x73\x08\x0f\x10\x5c\xd4\xbf\x79\x1b\x81\x02\x1e\x07\xab\xc3\x29\xc5\x63\x30\x7d\x47\x7e\x3c\xa5\xf0\xe0\x07\xe3\x77\xad\x78\xd5\x16\x72\x9e\xd6\x67\xc3\x6f\x5b\x05\xb6\x34\x46\xf2\x3c\x6a\xf7\x4e\x68\x64\xc2\xd2\xac\x4b\x6a\xcf\x26\x1b\xf6\x8d\x8b\x6c\x60\x95\xa3\xc7\x8d\x37\xc2\x78\x80\xc2\xc4\xa7\x26\xa3\x8e\x19\x57\xfa\x0d\x67\xe6\xd2\x0f\xb3\xb6\x88\xec\xab\x17\xe7\x99\xb4\xd8\xcd\x3a\xf6\x70\xe6\xa0\x89\xb5\xeb\x9b\xbd\xc1\xe4\xd3\x84\x37\x76\x3c\xc9\x52\x55\xf8\xe3\xfb\xcb\x57\xaa\x99\x2b\x49\xea\xf7\xb7\x1f\x03\x1b\x7b\x13\x7a\x4a\xc6\x1d\x25\xff\xbb\x90\x5b\x83\x7d\x46\xd4\xe3\x31\xf0\xaa\xfa\x9e\xd0\xa3\xa6\x47\x3f\xb3\xf2\x16\x87\x15\x34\xed\x8e\x55\xd1\xf0\xaa\xdd\x56\xda\x07\x42\xfa\x8e\x43\x9b\x6d\xae\x1e\x8f\x41\x69\xf0\x2e\x10\x63\x53\x3a\x1a\x66\xf5\x97\xfe\x47\xea\x6c\xfd\xb1\x75\x9d\x6d\xb1\x3d\xa1\xf6\x7d\x55\x75\xe0\xad\xa0\x69\x2c\x01\x6f\x8e\xd6\x1d\x4a\x35\x0f\xf8\x36\x47\xeb\xad\x86\xc1\x2d\xa7\x9b\xdb\xe7\x2b\xfd\xf3\xe1\x65\x50\x78\xc8\xdc\xbd\x25\xe3\x5f\x7e\x36\x5f\xfc\xbc\x4e\xae\x7f\xd9\xdc\x7c\x91\xfe\xbc\x19\x77\x2d\x81\xdb\x1f\x46\x99\x96\x5a\xb2\x3c\xf7\x78\x7d\x76\xd3\x66\x98\x41\x61\x1b\x34\x53\xb4\xe3\xe8\xa4\x3b\x77\xd1\xa3\x38\x06\x4a\x32\xbe\x66\x84\xe3\xb0\x1e\x46\xce\x00\xb0\xc3\x48\x47\x79\x98\xa8\x7b\x1a\x4e\x6f\xb2\xee\xca\xd6\x54\xb1\x36\x08\xeb\x43\x48\x3c\x51\x83\x0b\x89\x48\x07\x46\x5f\x20\x9c\xb5\xfa\xb7\xee\x53\x92\x33\xd4\x80\xeb\x84\xba\x54\x96\x52\x1e\x7b\xfd\x88\xd2\x52\x72\x40\x89\x3a\x61\x66\x71\x47\x75\x5d\xe6\xdc\xa4\x78\xe9\x68\xc0\x7c\x4e\xa3\x3f\x69\xbf\xf6\x1d\xa5\x17\x56\xdf\xba\x9d\x8f\xa8\xba\x8b\xe8\xdc\x45\xfb\x80\xab\x4a\x2d\xe5\x10\x2f\xe9\x08\x69\xc6\xef\x87\x8e\xaf\x29\x01\x33\x6a\x05\x12\xcc\x4b\xab\xeb\xef\x70\x45\x99\xc2\xf5\xcf\xfc\x3b\x5c\x75\x55\xc0\x7e\x82\x86\x24\x39\x8e\x3d\x59\x6d\x1a\xdc\xa5\xaa\xac\x45\xf9\x40\x34\x39\x92\xa2\x04\x5c\xf4\xcd\x42\x5c\xa5\x4c\xa2\xb4\x9d\x7a\xdc\x03\x0f\xdb\x73\xc3\xac\x2d\xc5\xb7\xc2\xaf\x3f\xcc\xab\xea\xa4\xd5\xe2\x93\xf4\xf5\x7e\xde\x32\x14\xbe\x6b\x3e\x71\x61\xd2\x89\xb9\x56\x25\xaf\xaf\xac\xd2\x7c\x8a\xb9\x41\x7b\x69\xb1\x49\x18\x7d\x4e\x3e\x59\x88\x93\x16\x57\xb6\xfb\xbd\xd4\x09\x31\xce\x81\x5e\xcf\xa3\x1d\x40\x28\x86\x77\x4c\x0f\xdc\x91\x92\x36\x69\x16\x30\x44\xfa\xef\x01\x00\xc3\xf5\x35\x7e\x4b\x1f\x00\x00"),
+		},
+	}
+	fs["/"].(*vfsgen۰DirInfo).entries = []os.FileInfo{
+		fs["/index.html"].(os.FileInfo),
+		fs["/ui.css"].(os.FileInfo),
+		fs["/ui.js"].(os.FileInfo),
+	}
+
+	return fs
+}()
+
+type vfsgen۰FS map[string]interface{}
+
+func (fs vfsgen۰FS) Open(path string) (http.File, error) {
+	path = pathpkg.Clean("/" + path)
+	f, ok := fs[path]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+
+	switch f := f.(type) {
+	case *vfsgen۰CompressedFileInfo:
+		gr, err := gzip.NewReader(bytes.NewReader(f.compressedContent))
+		if err != nil {
+			// This should never happen because we generate the gzip bytes such that they are always valid.
+			panic("unexpected error reading own gzip compressed bytes: " + err.Error())
+		}
+		return &vfsgen۰CompressedFile{
+			vfsgen۰CompressedFileInfo: f,
+			gr:                        gr,
+		}, nil
+	case *vfsgen۰DirInfo:
+		return &vfsgen۰Dir{
+			vfsgen۰DirInfo: f,
+		}, nil
+	default:
+		// This should never happen because we generate only the above types.
+		panic(fmt.Sprintf("unexpected type %T", f))
+	}
+}
+
+// vfsgen۰CompressedFileInfo is a static definition of a gzip compressed file.
+type vfsgen۰CompressedFileInfo struct {
+	name              string
+	modTime           time.Time
+	compressedContent []byte
+	uncompressedSize  int64
+}
+
+func (f *vfsgen۰CompressedFileInfo) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, fmt.Errorf("cannot Readdir from file %s", f.name)
+}
+func (f *vfsgen۰CompressedFileInfo) Stat() (os.FileInfo, error) { return f, nil }
+
+func (f *vfsgen۰CompressedFileInfo) GzipBytes() []byte {
+	return f.compressedContent
+}
+
+func (f *vfsgen۰CompressedFileInfo) Name() string       { return f.name }
+func (f *vfsgen۰CompressedFileInfo) Size() int64        { return f.uncompressedSize }
+func (f *vfsgen۰CompressedFileInfo) Mode() os.FileMode  { return 0444 }
+func (f *vfsgen۰CompressedFileInfo) ModTime() time.Time { return f.modTime }
+func (f *vfsgen۰CompressedFileInfo) IsDir() bool        { return false }
+func (f *vfsgen۰CompressedFileInfo) Sys() interface{}   { return nil }
+
+// vfsgen۰CompressedFile is an opened compressedFile instance.
+type vfsgen۰CompressedFile struct {
+	*vfsgen۰CompressedFileInfo
+	gr      *gzip.Reader
+	grPos   int64 // Actual gr uncompressed position.
+	seekPos int64 // Seek uncompressed position.
+}
+
+func (f *vfsgen۰CompressedFile) Read(p []byte) (n int, err error) {
+	if f.grPos > f.seekPos {
+		// Rewind to beginning.
+		err = f.gr.Reset(bytes.NewReader(f.compressedContent))
+		if err != nil {
+			return 0, err
+		}
+		f.grPos = 0
+	}
+	if f.grPos < f.seekPos {
+		// Fast-forward.
+		_, err = io.CopyN(ioutil.Discard, f.gr, f.seekPos-f.grPos)
+		if err != nil {
+			return 0, err
+		}
+		f.grPos = f.seekPos
+	}
+	n, err = f.gr.Read(p)
+	f.grPos += int64(n)
+	f.seekPos = f.grPos
+	return n, err
+}
+func (f *vfsgen۰CompressedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		f.seekPos = 0 + offset
+	case io.SeekCurrent:
+		f.seekPos += offset
+	case io.SeekEnd:
+		f.seekPos = f.uncompressedSize + offset
+	default:
+		panic(fmt.Errorf("invalid whence value: %v", whence))
+	}
+	return f.seekPos, nil
+}
+func (f *vfsgen۰CompressedFile) Close() error {
+	return f.gr.Close()
+}
+
+// vfsgen۰DirInfo is a static definition of a directory.
+type vfsgen۰DirInfo struct {
+	name    string
+	modTime time.Time
+	entries []os.FileInfo
+}
+
+func (d *vfsgen۰DirInfo) Read([]byte) (int, error) {
+	return 0, fmt.Errorf("cannot Read from directory %s", d.name)
+}
+func (d *vfsgen۰DirInfo) Close() error               { return nil }
+func (d *vfsgen۰DirInfo) Stat() (os.FileInfo, error) { return d, nil }
+
+func (d *vfsgen۰DirInfo) Name() string       { return d.name }
+func (d *vfsgen۰DirInfo) Size() int64        { return 0 }
+func (d *vfsgen۰DirInfo) Mode() os.FileMode  { return 0755 | os.ModeDir }
+func (d *vfsgen۰DirInfo) ModTime() time.Time { return d.modTime }
+func (d *vfsgen۰DirInfo) IsDir() bool        { return true }
+func (d *vfsgen۰DirInfo) Sys() interface{}   { return nil }
+
+// vfsgen۰Dir is an opened dir instance.
+type vfsgen۰Dir struct {
+	*vfsgen۰DirInfo
+	pos int // Position within entries for Seek and Readdir.
+}
+
+func (d *vfsgen۰Dir) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		d.pos = 0
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unsupported Seek in directory %s", d.name)
+}
+
+func (d *vfsgen۰Dir) Readdir(count int) ([]os.FileInfo, error) {
+	if d.pos >= len(d.entries) && count > 0 {
+		return nil, io.EOF
+	}
+	if count <= 0 || count > len(d.entries)-d.pos {
+		count = len(d.entries) - d.pos
+	}
+	e := d.entries[d.pos : d.pos+count]
+	d.pos += count
+	return e, nil
+}
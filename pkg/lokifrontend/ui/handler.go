@@ -0,0 +1,14 @@
+package ui
+
+import (
+	"net/http"
+)
+
+// PathPrefix is the path the query UI is served under.
+const PathPrefix = "/ui/"
+
+// Handler serves the query UI. It is a single page querying the Loki HTTP API from the browser,
+// it has no server side logic.
+func Handler() http.Handler {
+	return http.StripPrefix(PathPrefix, http.FileServer(Assets))
+}
@@ -0,0 +1,31 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	h := Handler()
+	for _, tt := range []struct {
+		path        string
+		code        int
+		contentType string
+	}{
+		{"/ui/", http.StatusOK, "text/html"},
+		{"/ui/ui.js", http.StatusOK, "javascript"},
+		{"/ui/ui.css", http.StatusOK, "text/css"},
+		{"/ui/unknown.js", http.StatusNotFound, ""},
+		{"/unknown", http.StatusNotFound, ""},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		require.Equal(t, tt.code, rec.Code, tt.path)
+		if tt.contentType != "" {
+			require.Contains(t, rec.Header().Get("Content-Type"), tt.contentType, tt.path)
+		}
+	}
+}
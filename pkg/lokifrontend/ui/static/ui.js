@@ -0,0 +1,283 @@
+// The UI is served under /ui/, the API paths are relative so that it works behind a path prefix.
+const api = '../loki/api/v1/';
+
+// Tailing polls the range query API, so that it works with the tenant header and through the frontend.
+const tailInterval = 2000;
+const tailMaxRows = 1000;
+
+const $ = (id) => document.getElementById(id);
+
+let tailTimer = null;
+let tailFrom = null;
+
+function headers() {
+  const tenant = $('tenant').value.trim();
+  return tenant ? { 'X-Scope-OrgID': tenant } : {};
+}
+
+async function get(path, params) {
+  const url = api + path + (params ? '?' + new URLSearchParams(params) : '');
+  const res = await fetch(url, { headers: headers() });
+  const body = await res.text();
+  if (!res.ok) {
+    let msg = body;
+    try {
+      msg = JSON.parse(body).error || body;
+    } catch (e) {
+      // The body is not JSON, it is shown as is.
+    }
+    throw new Error(`${res.status}: ${msg}`);
+  }
+  return JSON.parse(body);
+}
+
+function setStatus(msg, isError) {
+  $('status').textContent = msg;
+  $('status').className = isError ? 'error' : '';
+}
+
+function nowNanos() {
+  return BigInt(Date.now()) * 1000000n;
+}
+
+function formatNanos(ts) {
+  const ns = BigInt(ts);
+  const date = new Date(Number(ns / 1000000n));
+  return date.toISOString().replace('Z', (ns % 1000000n).toString().padStart(6, '0') + 'Z');
+}
+
+function formatLabels(labels) {
+  return '{' + Object.keys(labels).sort().map((k) => `${k}="${labels[k]}"`).join(', ') + '}';
+}
+
+function cell(row, className, text) {
+  const td = row.insertCell();
+  td.className = className;
+  td.textContent = text;
+}
+
+function setColumns(names) {
+  const head = $('results').tHead;
+  head.innerHTML = '';
+  const row = head.insertRow();
+  for (const name of names) {
+    const th = document.createElement('th');
+    th.textContent = name;
+    row.appendChild(th);
+  }
+  $('results').tBodies[0].innerHTML = '';
+}
+
+// streamRows flattens the entries of the streams, sorted by time in the given direction.
+function streamRows(streams, direction) {
+  const rows = [];
+  for (const stream of streams) {
+    for (const [ts, line] of stream.values) {
+      rows.push({ ts: BigInt(ts), labels: stream.stream, line });
+    }
+  }
+  rows.sort((a, b) => (a.ts < b.ts ? -1 : a.ts > b.ts ? 1 : 0));
+  if (direction === 'backward') {
+    rows.reverse();
+  }
+  return rows;
+}
+
+function appendStreamRows(rows) {
+  const body = $('results').tBodies[0];
+  for (const r of rows) {
+    const row = body.insertRow();
+    cell(row, 'time', formatNanos(r.ts));
+    cell(row, 'labels', formatLabels(r.labels));
+    cell(row, 'line', r.line);
+  }
+  while (body.rows.length > tailMaxRows) {
+    body.deleteRow(0);
+  }
+}
+
+function showResult(data, direction) {
+  switch (data.resultType) {
+    case 'streams': {
+      setColumns(['Time', 'Labels', 'Line']);
+      const rows = streamRows(data.result, direction);
+      appendStreamRows(rows);
+      return rows.length + ' lines';
+    }
+    case 'matrix': {
+      setColumns(['Labels', 'Time', 'Value']);
+      const body = $('results').tBodies[0];
+      for (const series of data.result) {
+        for (const [ts, value] of series.values) {
+          const row = body.insertRow();
+          cell(row, 'labels', formatLabels(series.metric));
+          cell(row, 'time', new Date(ts * 1000).toISOString());
+          cell(row, 'value', value);
+        }
+      }
+      return data.result.length + ' series';
+    }
+    case 'vector': {
+      setColumns(['Labels', 'Value']);
+      const body = $('results').tBodies[0];
+      for (const sample of data.result) {
+        const row = body.insertRow();
+        cell(row, 'labels', formatLabels(sample.metric));
+        cell(row, 'value', sample.value[1]);
+      }
+      return data.result.length + ' series';
+    }
+    default:
+      setColumns(['Value']);
+      cell($('results').tBodies[0].insertRow(), 'value', JSON.stringify(data.result));
+      return '';
+  }
+}
+
+async function runQuery() {
+  stopTail();
+  const query = $('query').value.trim();
+  if (!query) {
+    return;
+  }
+  const end = nowNanos();
+  const start = end - BigInt($('range').value) * 1000000000n;
+  const direction = $('direction').value;
+  setStatus('Running...');
+  try {
+    const res = await get('query_range', {
+      query,
+      start: start.toString(),
+      end: end.toString(),
+      limit: $('limit').value,
+      direction,
+    });
+    const summary = showResult(res.data, direction);
+    const took = res.data.stats && res.data.stats.summary ? res.data.stats.summary.execTime : null;
+    setStatus(summary + (took !== null ? ` in ${took.toFixed(3)}s` : ''));
+  } catch (e) {
+    setStatus(e.message, true);
+  }
+}
+
+async function pollTail() {
+  const query = $('query').value.trim();
+  const end = nowNanos();
+  try {
+    const res = await get('query_range', {
+      query,
+      start: tailFrom.toString(),
+      end: end.toString(),
+      limit: $('limit').value,
+      direction: 'forward',
+    });
+    if (res.data.resultType !== 'streams') {
+      throw new Error('only log queries can be tailed');
+    }
+    const rows = streamRows(res.data.result, 'forward');
+    appendStreamRows(rows);
+    if (rows.length > 0) {
+      tailFrom = rows[rows.length - 1].ts + 1n;
+    }
+    setStatus('Tailing, ' + $('results').tBodies[0].rows.length + ' lines');
+  } catch (e) {
+    stopTail();
+    setStatus(e.message, true);
+    return;
+  }
+  if (tailTimer !== null) {
+    tailTimer = setTimeout(pollTail, tailInterval);
+  }
+}
+
+function startTail() {
+  if (!$('query').value.trim()) {
+    return;
+  }
+  setColumns(['Time', 'Labels', 'Line']);
+  tailFrom = nowNanos() - BigInt(tailInterval) * 1000000n;
+  $('tail').classList.add('active');
+  tailTimer = setTimeout(pollTail, 0);
+}
+
+function stopTail() {
+  if (tailTimer !== null) {
+    clearTimeout(tailTimer);
+    tailTimer = null;
+  }
+  $('tail').classList.remove('active');
+}
+
+function fillSelect(select, values) {
+  select.innerHTML = '<option value="">-</option>';
+  for (const v of values || []) {
+    const option = document.createElement('option');
+    option.value = v;
+    option.textContent = v;
+    select.appendChild(option);
+  }
+}
+
+async function loadLabels() {
+  try {
+    const res = await get('labels');
+    fillSelect($('label-name'), res.data);
+  } catch (e) {
+    setStatus('Failed to load the labels: ' + e.message, true);
+  }
+}
+
+async function loadLabelValues() {
+  const name = $('label-name').value;
+  if (!name) {
+    fillSelect($('label-value'), []);
+    return;
+  }
+  try {
+    const res = await get('label/' + encodeURIComponent(name) + '/values');
+    fillSelect($('label-value'), res.data);
+  } catch (e) {
+    setStatus('Failed to load the label values: ' + e.message, true);
+  }
+}
+
+// addMatcher adds the selected matcher to the stream selector starting the query,
+// or creates the selector.
+function addMatcher() {
+  const name = $('label-name').value;
+  const value = $('label-value').value;
+  if (!name) {
+    return;
+  }
+  const matcher = `${name}${$('label-op').value}${JSON.stringify(value)}`;
+  const query = $('query').value;
+  const match = query.match(/^\s*\{([^}]*)\}/);
+  if (match) {
+    const matchers = match[1].trim();
+    const selector = '{' + (matchers ? matchers + ', ' : '') + matcher + '}';
+    $('query').value = query.replace(match[0], selector);
+  } else {
+    $('query').value = '{' + matcher + '}' + (query ? ' ' + query : '');
+  }
+}
+
+$('query-form').addEventListener('submit', (e) => {
+  e.preventDefault();
+  runQuery();
+});
+$('query').addEventListener('keydown', (e) => {
+  if (e.key === 'Enter' && (e.ctrlKey || e.metaKey)) {
+    e.preventDefault();
+    runQuery();
+  }
+});
+$('tail').addEventListener('click', () => (tailTimer === null ? startTail() : stopTail()));
+$('label-name').addEventListener('change', loadLabelValues);
+$('add-matcher').addEventListener('click', addMatcher);
+$('tenant').addEventListener('change', () => {
+  localStorage.setItem('loki-ui-tenant', $('tenant').value);
+  loadLabels();
+});
+
+$('tenant').value = localStorage.getItem('loki-ui-tenant') || '';
+loadLabels();
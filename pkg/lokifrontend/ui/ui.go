@@ -0,0 +1,11 @@
+//go:build dev
+// +build dev
+
+package ui
+
+import (
+	"net/http"
+)
+
+// Assets contains the project's assets loaded from local file system when build with `-tags dev`
+var Assets http.FileSystem = http.Dir("./static")
@@ -0,0 +1,47 @@
+package lookup
+
+import (
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/grafana/loki/pkg/util/flagext"
+)
+
+// Config configures the lookup tables used by the lookup stages of the queries.
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// SharedStoreType is the object store the lookup tables are read from.
+	SharedStoreType string `yaml:"shared_store"`
+	// Prefix is the path prefix of the lookup tables in the object store.
+	Prefix          string           `yaml:"prefix"`
+	RefreshInterval time.Duration    `yaml:"refresh_interval"`
+	MaxTableSize    flagext.ByteSize `yaml:"max_table_size"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "lookup-tables.enabled", false, "Enable the lookup stage of LogQL, enriching the query results with per-tenant lookup tables read from object storage.")
+	f.StringVar(&cfg.SharedStoreType, "lookup-tables.shared-store", "", "Object store the lookup tables are read from (aws, azure, gcs, swift, filesystem).")
+	f.StringVar(&cfg.Prefix, "lookup-tables.prefix", "lookup_tables", "Path prefix of the lookup tables in the object store. The table <name> of a tenant is read from <prefix>/<tenant>/<name>.csv or <prefix>/<tenant>/<name>.json.")
+	f.DurationVar(&cfg.RefreshInterval, "lookup-tables.refresh-interval", time.Minute, "How long a lookup table is cached before being read again from the object store.")
+	cfg.MaxTableSize = 10 << 20
+	f.Var(&cfg.MaxTableSize, "lookup-tables.max-table-size", "Maximum size of a lookup table object, larger tables are rejected.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.SharedStoreType == "" {
+		return errors.New("lookup-tables.shared-store must be set when the lookup tables are enabled")
+	}
+	if cfg.RefreshInterval <= 0 {
+		return errors.New("lookup-tables.refresh-interval must be greater than 0")
+	}
+	if cfg.MaxTableSize == 0 {
+		return errors.New("lookup-tables.max-table-size must be greater than 0")
+	}
+	return nil
+}
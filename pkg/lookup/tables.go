@@ -0,0 +1,140 @@
+package lookup
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+type table struct {
+	values   map[string]string
+	loadedAt time.Time
+}
+
+// Tables reads the lookup tables of the tenants from object storage and caches them for the
+// refresh interval. A table is a CSV file with a header and two columns, the key and the value,
+// or a JSON object of strings.
+type Tables struct {
+	cfg    Config
+	client chunk.ObjectClient
+	logger log.Logger
+	now    func() time.Time
+
+	mtx    sync.Mutex
+	tables map[string]*table
+}
+
+// New creates lookup tables read from the given object client.
+func New(cfg Config, client chunk.ObjectClient, logger log.Logger) *Tables {
+	return &Tables{
+		cfg:    cfg,
+		client: client,
+		logger: logger,
+		now:    time.Now,
+		tables: map[string]*table{},
+	}
+}
+
+// Table returns the lookup table of the given name of the tenant of the context.
+// If the table can't be read again once cached, the cached table keeps being used.
+func (t *Tables) Table(ctx context.Context, name string) (map[string]string, error) {
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := path.Join(tenantID, name)
+
+	t.mtx.Lock()
+	cached := t.tables[key]
+	t.mtx.Unlock()
+	if cached != nil && t.now().Sub(cached.loadedAt) < t.cfg.RefreshInterval {
+		return cached.values, nil
+	}
+
+	values, err := t.load(ctx, key)
+	if err != nil {
+		if cached != nil && !isUserError(err) {
+			level.Warn(t.logger).Log("msg", "failed to refresh the lookup table, using the cached table", "tenant", tenantID, "table", name, "err", err)
+			return cached.values, nil
+		}
+		return nil, err
+	}
+
+	t.mtx.Lock()
+	t.tables[key] = &table{values: values, loadedAt: t.now()}
+	t.mtx.Unlock()
+	return values, nil
+}
+
+func (t *Tables) load(ctx context.Context, key string) (map[string]string, error) {
+	name := path.Base(key)
+	for _, ext := range []string{".csv", ".json"} {
+		b, err := t.read(ctx, path.Join(t.cfg.Prefix, key+ext))
+		if err != nil {
+			if t.client.IsObjectNotFoundErr(err) {
+				continue
+			}
+			return nil, err
+		}
+		if len(b) > int(t.cfg.MaxTableSize) {
+			return nil, httpgrpc.Errorf(http.StatusBadRequest, "lookup table %s is larger than the limit of %d bytes", name, t.cfg.MaxTableSize)
+		}
+		if ext == ".csv" {
+			return parseCSV(name, b)
+		}
+		return parseJSON(name, b)
+	}
+	return nil, httpgrpc.Errorf(http.StatusBadRequest, "lookup table %s not found", name)
+}
+
+// read reads an object, up to one byte more than the maximum table size.
+func (t *Tables) read(ctx context.Context, objectKey string) ([]byte, error) {
+	rc, err := t.client.GetObject(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(io.LimitReader(rc, int64(t.cfg.MaxTableSize)+1))
+}
+
+func parseCSV(name string, b []byte) (map[string]string, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.FieldsPerRecord = 2
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "invalid lookup table %s: %v", name, err)
+	}
+	values := make(map[string]string, len(records))
+	// the first record is the header.
+	for i := 1; i < len(records); i++ {
+		values[records[i][0]] = records[i][1]
+	}
+	return values, nil
+}
+
+func parseJSON(name string, b []byte) (map[string]string, error) {
+	values := map[string]string{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "invalid lookup table %s, expected an object of strings: %v", name, err)
+	}
+	return values, nil
+}
+
+func isUserError(err error) bool {
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	return ok && resp.Code/100 == 4
+}
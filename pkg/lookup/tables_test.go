@@ -0,0 +1,97 @@
+package lookup
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+func newTestTables(t *testing.T, objects map[string]string) (*Tables, *chunk.MockStorage, *time.Time) {
+	storage := chunk.NewMockStorage()
+	for k, v := range objects {
+		require.NoError(t, storage.PutObject(context.Background(), k, strings.NewReader(v)))
+	}
+	now := time.Unix(0, 0)
+	tables := New(Config{Prefix: "lookup_tables", RefreshInterval: time.Minute, MaxTableSize: 100}, storage, log.NewNopLogger())
+	tables.now = func() time.Time { return now }
+	return tables, storage, &now
+}
+
+func requireStatus(t *testing.T, code int32, err error) {
+	t.Helper()
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok, err)
+	require.Equal(t, code, resp.Code, string(resp.Body))
+}
+
+func TestTables(t *testing.T) {
+	tables, _, _ := newTestTables(t, map[string]string{
+		"lookup_tables/a/owners.csv":   "service,owner\napi,team-a\n\"db, main\",team-b\n",
+		"lookup_tables/a/regions.json": `{"eu-1": "europe", "us-1": "america"}`,
+		"lookup_tables/b/owners.csv":   "service,owner\napi,team-c\n",
+		"lookup_tables/a/invalid.csv":  "service,owner\napi\n",
+		"lookup_tables/a/big.json":     `{"key": "` + strings.Repeat("x", 100) + `"}`,
+	})
+	ctxA := user.InjectOrgID(context.Background(), "a")
+
+	owners, err := tables.Table(ctxA, "owners")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"api": "team-a", "db, main": "team-b"}, owners)
+
+	regions, err := tables.Table(ctxA, "regions")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"eu-1": "europe", "us-1": "america"}, regions)
+
+	owners, err = tables.Table(user.InjectOrgID(context.Background(), "b"), "owners")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"api": "team-c"}, owners)
+
+	_, err = tables.Table(user.InjectOrgID(context.Background(), "b"), "regions")
+	requireStatus(t, http.StatusBadRequest, err)
+	_, err = tables.Table(ctxA, "invalid")
+	requireStatus(t, http.StatusBadRequest, err)
+	_, err = tables.Table(ctxA, "big")
+	requireStatus(t, http.StatusBadRequest, err)
+
+	_, err = tables.Table(context.Background(), "owners")
+	require.Error(t, err)
+}
+
+func TestTables_Refresh(t *testing.T) {
+	tables, storage, now := newTestTables(t, map[string]string{
+		"lookup_tables/a/owners.csv": "service,owner\napi,team-a\n",
+	})
+	ctx := user.InjectOrgID(context.Background(), "a")
+
+	owners, err := tables.Table(ctx, "owners")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"api": "team-a"}, owners)
+
+	// cached until the refresh interval.
+	require.NoError(t, storage.PutObject(ctx, "lookup_tables/a/owners.csv", strings.NewReader("service,owner\napi,team-b\n")))
+	*now = now.Add(30 * time.Second)
+	owners, err = tables.Table(ctx, "owners")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"api": "team-a"}, owners)
+
+	*now = now.Add(time.Minute)
+	owners, err = tables.Table(ctx, "owners")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"api": "team-b"}, owners)
+
+	// the cached table is used when the object store fails.
+	storage.SetMode(chunk.MockStorageModeWriteOnly)
+	*now = now.Add(time.Minute)
+	owners, err = tables.Table(ctx, "owners")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"api": "team-b"}, owners)
+}
@@ -0,0 +1,169 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/storage"
+)
+
+const (
+	faultTargetStore    = "store"
+	faultTargetIngester = "ingester"
+
+	faultTypeDelay = "delay"
+	faultTypeError = "error"
+)
+
+var injectedFaults = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "loki",
+	Name:      "querier_injected_faults_total",
+	Help:      "Total number of faults injected in store and ingester calls, by target and type.",
+}, []string{"target", "type"})
+
+// FaultInjectionConfig configures the faults injected in the read path, to test how
+// retries and dashboards cope with slow or failing stores and ingesters.
+// It must only be enabled in testing environments. It is only read on startup.
+type FaultInjectionConfig struct {
+	Tenants  flagext.StringSliceCSV `yaml:"tenants"`
+	Store    FaultConfig            `yaml:"store"`
+	Ingester FaultConfig            `yaml:"ingester"`
+}
+
+// FaultConfig configures the faults injected in the calls to a backend.
+type FaultConfig struct {
+	DelayRatio float64       `yaml:"delay_ratio"`
+	Delay      time.Duration `yaml:"delay"`
+	ErrorRatio float64       `yaml:"error_ratio"`
+}
+
+// RegisterFlagsWithPrefix registers flags.
+func (cfg *FaultInjectionConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.Var(&cfg.Tenants, prefix+"tenants", "Comma separated list of tenants whose requests are subject to fault injection. Empty means all tenants.")
+	cfg.Store.RegisterFlagsWithPrefix(prefix+"store.", f)
+	cfg.Ingester.RegisterFlagsWithPrefix(prefix+"ingester.", f)
+}
+
+// RegisterFlagsWithPrefix registers flags.
+func (cfg *FaultConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.Float64Var(&cfg.DelayRatio, prefix+"delay-ratio", 0, "Ratio of calls delayed, between 0 and 1.")
+	f.DurationVar(&cfg.Delay, prefix+"delay", time.Second, "Delay added to delayed calls.")
+	f.Float64Var(&cfg.ErrorRatio, prefix+"error-ratio", 0, "Ratio of calls failed with a 500 error, between 0 and 1.")
+}
+
+// Validate validates the config.
+func (cfg *FaultInjectionConfig) Validate() error {
+	for _, c := range []FaultConfig{cfg.Store, cfg.Ingester} {
+		if c.DelayRatio < 0 || c.DelayRatio > 1 || c.ErrorRatio < 0 || c.ErrorRatio > 1 {
+			return errors.New("fault injection ratios must be between 0 and 1")
+		}
+	}
+	return nil
+}
+
+func (cfg *FaultInjectionConfig) enabled() bool {
+	return cfg.Store.enabled() || cfg.Ingester.enabled()
+}
+
+func (cfg *FaultConfig) enabled() bool {
+	return cfg.DelayRatio > 0 || cfg.ErrorRatio > 0
+}
+
+// faultInjector delays or fails a ratio of the calls made on behalf of the configured tenants.
+type faultInjector struct {
+	cfg    FaultInjectionConfig
+	random func() float64
+}
+
+func newFaultInjector(cfg FaultInjectionConfig) *faultInjector {
+	return &faultInjector{cfg: cfg, random: rand.Float64}
+}
+
+func (f *faultInjector) inject(ctx context.Context, target string) error {
+	if f == nil {
+		return nil
+	}
+	cfg := f.cfg.Store
+	if target == faultTargetIngester {
+		cfg = f.cfg.Ingester
+	}
+	if !cfg.enabled() {
+		return nil
+	}
+	if len(f.cfg.Tenants) > 0 {
+		userID, err := tenant.TenantID(ctx)
+		if err != nil || !util.StringsContain(f.cfg.Tenants, userID) {
+			return nil
+		}
+	}
+
+	if f.random() < cfg.DelayRatio {
+		injectedFaults.WithLabelValues(target, faultTypeDelay).Inc()
+		select {
+		case <-time.After(cfg.Delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if f.random() < cfg.ErrorRatio {
+		injectedFaults.WithLabelValues(target, faultTypeError).Inc()
+		return httpgrpc.Errorf(http.StatusInternalServerError, "injected %s fault", target)
+	}
+	return nil
+}
+
+// faultInjectingStore injects faults in the read calls made to a store.
+type faultInjectingStore struct {
+	storage.Store
+	faults *faultInjector
+}
+
+func (s *faultInjectingStore) SelectLogs(ctx context.Context, req logql.SelectLogParams) (iter.EntryIterator, error) {
+	if err := s.faults.inject(ctx, faultTargetStore); err != nil {
+		return nil, err
+	}
+	return s.Store.SelectLogs(ctx, req)
+}
+
+func (s *faultInjectingStore) SelectSamples(ctx context.Context, req logql.SelectSampleParams) (iter.SampleIterator, error) {
+	if err := s.faults.inject(ctx, faultTargetStore); err != nil {
+		return nil, err
+	}
+	return s.Store.SelectSamples(ctx, req)
+}
+
+func (s *faultInjectingStore) GetSeries(ctx context.Context, req logql.SelectLogParams) ([]logproto.SeriesIdentifier, error) {
+	if err := s.faults.inject(ctx, faultTargetStore); err != nil {
+		return nil, err
+	}
+	return s.Store.GetSeries(ctx, req)
+}
+
+func (s *faultInjectingStore) LabelValuesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, labelName string) ([]string, error) {
+	if err := s.faults.inject(ctx, faultTargetStore); err != nil {
+		return nil, err
+	}
+	return s.Store.LabelValuesForMetricName(ctx, userID, from, through, metricName, labelName)
+}
+
+func (s *faultInjectingStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string) ([]string, error) {
+	if err := s.faults.inject(ctx, faultTargetStore); err != nil {
+		return nil, err
+	}
+	return s.Store.LabelNamesForMetricName(ctx, userID, from, through, metricName)
+}
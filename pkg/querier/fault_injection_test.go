@@ -0,0 +1,49 @@
+package querier
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+)
+
+func TestFaultInjector(t *testing.T) {
+	faults := newFaultInjector(FaultInjectionConfig{
+		Tenants: []string{"chaos"},
+		Store:   FaultConfig{DelayRatio: 0.5, Delay: 50 * time.Millisecond, ErrorRatio: 0.5},
+	})
+	faults.random = func() float64 { return 0.2 }
+
+	// Only the configured tenants are subject to faults.
+	require.NoError(t, faults.inject(user.InjectOrgID(context.Background(), "other"), faultTargetStore))
+
+	ctx := user.InjectOrgID(context.Background(), "chaos")
+	start := time.Now()
+	err := faults.inject(ctx, faultTargetStore)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusInternalServerError), resp.Code)
+
+	// Ingester calls have no faults configured.
+	require.NoError(t, faults.inject(ctx, faultTargetIngester))
+
+	faults.random = func() float64 { return 0.7 }
+	require.NoError(t, faults.inject(ctx, faultTargetStore))
+
+	// A nil injector never injects faults.
+	var noFaults *faultInjector
+	require.NoError(t, noFaults.inject(ctx, faultTargetStore))
+}
+
+func TestFaultInjectionConfig_Validate(t *testing.T) {
+	cfg := FaultInjectionConfig{Ingester: FaultConfig{ErrorRatio: 0.1}}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Store.DelayRatio = 1.5
+	require.EqualError(t, cfg.Validate(), "fault injection ratios must be between 0 and 1")
+}
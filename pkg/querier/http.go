@@ -2,6 +2,7 @@ package querier
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"time"
 
@@ -17,9 +18,11 @@ import (
 	loghttp_legacy "github.com/grafana/loki/pkg/loghttp/legacy"
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/logqlmodel"
+	"github.com/grafana/loki/pkg/querier/queryrange"
 	"github.com/grafana/loki/pkg/util/marshal"
 	marshal_legacy "github.com/grafana/loki/pkg/util/marshal/legacy"
 	serverutil "github.com/grafana/loki/pkg/util/server"
+	"github.com/grafana/loki/pkg/validation"
 )
 
 const (
@@ -42,6 +45,11 @@ func (q *Querier) RangeQueryHandler(w http.ResponseWriter, r *http.Request) {
 		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, err.Error()), w)
 		return
 	}
+	opts, err := loghttp.ParseResponseOptions(r)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, err.Error()), w)
+		return
+	}
 
 	if err := q.validateEntriesLimits(ctx, request.Query, request.Limit); err != nil {
 		serverutil.WriteError(err, w)
@@ -64,7 +72,7 @@ func (q *Querier) RangeQueryHandler(w http.ResponseWriter, r *http.Request) {
 		serverutil.WriteError(err, w)
 		return
 	}
-	if err := marshal.WriteQueryResponseJSON(result, w); err != nil {
+	if err := writeQueryResponse(ctx, w, result, params, r.URL.Path, opts); err != nil {
 		serverutil.WriteError(err, w)
 		return
 	}
@@ -81,6 +89,11 @@ func (q *Querier) InstantQueryHandler(w http.ResponseWriter, r *http.Request) {
 		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, err.Error()), w)
 		return
 	}
+	opts, err := loghttp.ParseResponseOptions(r)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, err.Error()), w)
+		return
+	}
 
 	if err := q.validateEntriesLimits(ctx, request.Query, request.Limit); err != nil {
 		serverutil.WriteError(err, w)
@@ -104,12 +117,39 @@ func (q *Querier) InstantQueryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := marshal.WriteQueryResponseJSON(result, w); err != nil {
+	if err := writeQueryResponse(ctx, w, result, params, r.URL.Path, opts); err != nil {
 		serverutil.WriteError(err, w)
 		return
 	}
 }
 
+// writeQueryResponse writes the query result with the response options, encoding it
+// like the query frontend does.
+func writeQueryResponse(ctx context.Context, w http.ResponseWriter, result logqlmodel.Result, params logql.Params, path string, opts loghttp.ResponseOptions) error {
+	if opts.IsDefault() {
+		return marshal.WriteQueryResponseJSON(result, w)
+	}
+	if opts.Format != loghttp.ResponseFormatProtobuf {
+		w.Header().Set("Content-Type", string(opts.Format))
+		return marshal.WriteQueryResponse(result, opts, w)
+	}
+	if result.Data.Type() == parser.ValueTypeScalar {
+		return httpgrpc.Errorf(http.StatusNotAcceptable, "scalar results can't be encoded as %s", opts.Format)
+	}
+	res, err := queryrange.ResultToResponse(result, params, path)
+	if err != nil {
+		return err
+	}
+	resp, err := queryrange.LokiCodec.EncodeResponse(loghttp.InjectResponseOptions(ctx, opts), res)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
 // LogQueryHandler is a http.HandlerFunc for log only queries.
 func (q *Querier) LogQueryHandler(w http.ResponseWriter, r *http.Request) {
 	// Enforce the query timeout while querying backends
@@ -375,8 +415,7 @@ func (q *Querier) validateEntriesLimits(ctx context.Context, query string, limit
 
 	maxEntriesLimit := q.limits.MaxEntriesLimitPerQuery(userID)
 	if int(limit) > maxEntriesLimit && maxEntriesLimit != 0 {
-		return httpgrpc.Errorf(http.StatusBadRequest,
-			"max entries limit per query exceeded, limit > max_entries_limit (%d > %d)", limit, maxEntriesLimit)
+		return validation.NewEntriesLimitError(limit, maxEntriesLimit)
 	}
 	return nil
 }
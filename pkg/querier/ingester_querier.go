@@ -33,6 +33,7 @@ type IngesterQuerier struct {
 	ring            ring.ReadRing
 	pool            *ring_client.Pool
 	extraQueryDelay time.Duration
+	faults          *faultInjector
 }
 
 func NewIngesterQuerier(clientCfg client.Config, ring ring.ReadRing, extraQueryDelay time.Duration) (*IngesterQuerier, error) {
@@ -60,6 +61,13 @@ func newIngesterQuerier(clientCfg client.Config, ring ring.ReadRing, extraQueryD
 	return &iq, nil
 }
 
+// withFaults returns a copy of the IngesterQuerier which injects faults in the calls to ingesters.
+func (q *IngesterQuerier) withFaults(faults *faultInjector) *IngesterQuerier {
+	cpy := *q
+	cpy.faults = faults
+	return &cpy
+}
+
 // forAllIngesters runs f, in parallel, for all ingesters
 // TODO taken from Cortex, see if we can refactor out an usable interface.
 func (q *IngesterQuerier) forAllIngesters(ctx context.Context, f func(logproto.QuerierClient) (interface{}, error)) ([]responseFromIngesters, error) {
@@ -75,6 +83,10 @@ func (q *IngesterQuerier) forAllIngesters(ctx context.Context, f func(logproto.Q
 // TODO taken from Cortex, see if we can refactor out an usable interface.
 func (q *IngesterQuerier) forGivenIngesters(ctx context.Context, replicationSet ring.ReplicationSet, f func(logproto.QuerierClient) (interface{}, error)) ([]responseFromIngesters, error) {
 	results, err := replicationSet.Do(ctx, q.extraQueryDelay, func(ctx context.Context, ingester *ring.InstanceDesc) (interface{}, error) {
+		if err := q.faults.inject(ctx, faultTargetIngester); err != nil {
+			return nil, err
+		}
+
 		client, err := q.pool.GetClientFor(ingester.Addr)
 		if err != nil {
 			return nil, err
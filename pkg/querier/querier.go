@@ -8,11 +8,11 @@ import (
 
 	"github.com/prometheus/common/model"
 	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
 	"google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util/spanlogger"
-	cortex_validation "github.com/cortexproject/cortex/pkg/util/validation"
 	"github.com/go-kit/log/level"
 
 	"github.com/grafana/loki/pkg/iter"
@@ -39,14 +39,15 @@ type interval struct {
 
 // Config for a querier.
 type Config struct {
-	QueryTimeout                  time.Duration    `yaml:"query_timeout"`
-	TailMaxDuration               time.Duration    `yaml:"tail_max_duration"`
-	ExtraQueryDelay               time.Duration    `yaml:"extra_query_delay,omitempty"`
-	QueryIngestersWithin          time.Duration    `yaml:"query_ingesters_within,omitempty"`
-	IngesterQueryStoreMaxLookback time.Duration    `yaml:"-"`
-	Engine                        logql.EngineOpts `yaml:"engine,omitempty"`
-	MaxConcurrent                 int              `yaml:"max_concurrent"`
-	QueryStoreOnly                bool             `yaml:"query_store_only"`
+	QueryTimeout                  time.Duration        `yaml:"query_timeout"`
+	TailMaxDuration               time.Duration        `yaml:"tail_max_duration"`
+	ExtraQueryDelay               time.Duration        `yaml:"extra_query_delay,omitempty"`
+	QueryIngestersWithin          time.Duration        `yaml:"query_ingesters_within,omitempty"`
+	IngesterQueryStoreMaxLookback time.Duration        `yaml:"-"`
+	Engine                        logql.EngineOpts     `yaml:"engine,omitempty"`
+	MaxConcurrent                 int                  `yaml:"max_concurrent"`
+	QueryStoreOnly                bool                 `yaml:"query_store_only"`
+	FaultInjection                FaultInjectionConfig `yaml:"fault_injection"`
 }
 
 // RegisterFlags register flags.
@@ -58,6 +59,12 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.QueryIngestersWithin, "querier.query-ingesters-within", 0, "Maximum lookback beyond which queries are not sent to ingester. 0 means all queries are sent to ingester.")
 	f.IntVar(&cfg.MaxConcurrent, "querier.max-concurrent", 20, "The maximum number of concurrent queries.")
 	f.BoolVar(&cfg.QueryStoreOnly, "querier.query-store-only", false, "Queriers should only query the store and not try to query any ingesters")
+	cfg.FaultInjection.RegisterFlagsWithPrefix("querier.fault-injection.", f)
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	return cfg.FaultInjection.Validate()
 }
 
 // Querier handlers queries.
@@ -67,10 +74,19 @@ type Querier struct {
 	engine          *logql.Engine
 	limits          *validation.Overrides
 	ingesterQuerier *IngesterQuerier
+	lookupTables    logql.LookupTables
 }
 
 // New makes a new Querier.
 func New(cfg Config, store storage.Store, ingesterQuerier *IngesterQuerier, limits *validation.Overrides) (*Querier, error) {
+	if cfg.FaultInjection.enabled() {
+		faults := newFaultInjector(cfg.FaultInjection)
+		store = &faultInjectingStore{Store: store, faults: faults}
+		if ingesterQuerier != nil {
+			ingesterQuerier = ingesterQuerier.withFaults(faults)
+		}
+	}
+
 	querier := Querier{
 		cfg:             cfg,
 		store:           store,
@@ -87,9 +103,36 @@ func (q *Querier) SetQueryable(queryable logql.Querier) {
 	q.engine = logql.NewEngine(q.cfg.Engine, queryable, q.limits)
 }
 
+// SetLookupTables sets the lookup tables used by the lookup stages of the store queries.
+func (q *Querier) SetLookupTables(tables logql.LookupTables) {
+	q.lookupTables = tables
+}
+
 // Select Implements logql.Querier which select logs via matchers and regex filters.
 func (q *Querier) SelectLogs(ctx context.Context, params logql.SelectLogParams) (iter.EntryIterator, error) {
+	tenants, err := q.queryTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(tenants) == 1 {
+		return q.selectLogs(ctx, params)
+	}
+	iters := make([]iter.EntryIterator, 0, len(tenants))
+	for _, tenantID := range tenants {
+		req := *params.QueryRequest
+		it, err := q.selectLogs(user.InjectOrgID(ctx, tenantID), logql.SelectLogParams{QueryRequest: &req})
+		if err != nil {
+			closeEntryIterators(iters)
+			return nil, err
+		}
+		iters = append(iters, newTenantEntryIterator(it, tenantID))
+	}
+	return iter.NewHeapIterator(ctx, iters, params.Direction), nil
+}
+
+func (q *Querier) selectLogs(ctx context.Context, params logql.SelectLogParams) (iter.EntryIterator, error) {
 	var err error
+	ctx = logql.InjectLookupTables(ctx, q.lookupTables)
 	params.Start, params.End, err = q.validateQueryRequest(ctx, params)
 	if err != nil {
 		return nil, err
@@ -136,7 +179,29 @@ func (q *Querier) SelectLogs(ctx context.Context, params logql.SelectLogParams)
 }
 
 func (q *Querier) SelectSamples(ctx context.Context, params logql.SelectSampleParams) (iter.SampleIterator, error) {
+	tenants, err := q.queryTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(tenants) == 1 {
+		return q.selectSamples(ctx, params)
+	}
+	iters := make([]iter.SampleIterator, 0, len(tenants))
+	for _, tenantID := range tenants {
+		req := *params.SampleQueryRequest
+		it, err := q.selectSamples(user.InjectOrgID(ctx, tenantID), logql.SelectSampleParams{SampleQueryRequest: &req})
+		if err != nil {
+			closeSampleIterators(iters)
+			return nil, err
+		}
+		iters = append(iters, newTenantSampleIterator(it, tenantID))
+	}
+	return iter.NewHeapSampleIterator(ctx, iters), nil
+}
+
+func (q *Querier) selectSamples(ctx context.Context, params logql.SelectSampleParams) (iter.SampleIterator, error) {
 	var err error
+	ctx = logql.InjectLookupTables(ctx, q.lookupTables)
 	params.Start, params.End, err = q.validateQueryRequest(ctx, params)
 	if err != nil {
 		return nil, err
@@ -515,6 +580,9 @@ func validateQueryTimeRangeLimits(ctx context.Context, userID string, limits tim
 	if maxQueryLookback := limits.MaxQueryLookback(userID); maxQueryLookback > 0 && from.Before(now.Add(-maxQueryLookback)) {
 		origStartTime := from
 		from = now.Add(-maxQueryLookback)
+		if !through.Before(origStartTime) && through.Before(from) {
+			return time.Time{}, time.Time{}, validation.NewQueryLookbackError(through, from, maxQueryLookback)
+		}
 
 		level.Debug(spanlogger.FromContext(ctx)).Log(
 			"msg", "the start time of the query has been manipulated because of the 'max query lookback' setting",
@@ -523,7 +591,7 @@ func validateQueryTimeRangeLimits(ctx context.Context, userID string, limits tim
 
 	}
 	if maxQueryLength := limits.MaxQueryLength(userID); maxQueryLength > 0 && (through).Sub(from) > maxQueryLength {
-		return time.Time{}, time.Time{}, httpgrpc.Errorf(http.StatusBadRequest, cortex_validation.ErrQueryTooLong, (through).Sub(from), maxQueryLength)
+		return time.Time{}, time.Time{}, validation.NewQueryTooLongError(from, through, maxQueryLength)
 	}
 	if through.Before(from) {
 		return time.Time{}, time.Time{}, httpgrpc.Errorf(http.StatusBadRequest, "invalid query, through < from (%s < %s)", through, from)
@@ -211,7 +211,8 @@ func TestQuerier_validateQueryRequest(t *testing.T) {
 
 	request.Start = request.End.Add(-3 * time.Minute)
 	_, err = q.SelectLogs(ctx, logql.SelectLogParams{QueryRequest: &request})
-	require.Equal(t, httpgrpc.Errorf(http.StatusBadRequest, "the query time range exceeds the limit (query length: 3m0s, limit: 2m0s)"), err)
+	require.Equal(t, validation.NewQueryTooLongError(request.End.Add(-3*time.Minute), request.End, 2*time.Minute), err)
+	require.EqualError(t, err, "the query time range exceeds the limit (query length: 3m0s, limit: 2m0s), narrow the time range of the query to at most 2m0s")
 }
 
 func TestQuerier_SeriesAPI(t *testing.T) {
@@ -710,6 +711,7 @@ func Test_validateQueryTimeRangeLimits(t *testing.T) {
 		{"clamped to 24h", fakeTimeLimits{24 * time.Hour, 1000 * time.Hour}, now.Add(-48 * time.Hour), now, now.Add(-24 * time.Hour), now, false},
 		{"end before start", fakeTimeLimits{}, now, now.Add(-48 * time.Hour), time.Time{}, time.Time{}, true},
 		{"query too long", fakeTimeLimits{maxQueryLength: 24 * time.Hour}, now.Add(-48 * time.Hour), now, time.Time{}, time.Time{}, true},
+		{"before lookback", fakeTimeLimits{maxQueryLookback: 24 * time.Hour}, now.Add(-72 * time.Hour), now.Add(-48 * time.Hour), time.Time{}, time.Time{}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
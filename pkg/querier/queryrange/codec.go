@@ -360,7 +360,12 @@ type Buffer interface {
 func (Codec) DecodeResponse(ctx context.Context, r *http.Response, req queryrange.Request) (queryrange.Response, error) {
 	if r.StatusCode/100 != 2 {
 		body, _ := ioutil.ReadAll(r.Body)
-		return nil, httpgrpc.Errorf(r.StatusCode, string(body))
+		resp := &httpgrpc.HTTPResponse{Code: int32(r.StatusCode), Body: body}
+		// Keep the content type of structured errors, like the query limits ones.
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			resp.Headers = []*httpgrpc.Header{{Key: "Content-Type", Values: []string{contentType}}}
+		}
+		return nil, httpgrpc.ErrorFromHTTPResponse(resp)
 	}
 
 	sp, _ := opentracing.StartSpanFromContext(ctx, "codec.DecodeResponse")
@@ -476,8 +481,11 @@ func (Codec) DecodeResponse(ctx context.Context, r *http.Response, req queryrang
 }
 
 func (Codec) EncodeResponse(ctx context.Context, res queryrange.Response) (*http.Response, error) {
-	sp, _ := opentracing.StartSpanFromContext(ctx, "codec.EncodeResponse")
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "codec.EncodeResponse")
 	defer sp.Finish()
+	if opts := loghttp.ResponseOptionsFromContext(ctx); !opts.IsDefault() && supportsResponseOptions(res) {
+		return encodeResponseWithOptions(ctx, res, opts)
+	}
 	var buf bytes.Buffer
 
 	switch response := res.(type) {
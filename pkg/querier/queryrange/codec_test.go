@@ -1271,3 +1271,65 @@ func generateStream() (res []logproto.Stream) {
 	}
 	return res
 }
+
+func Test_codec_EncodeResponseWithOptions(t *testing.T) {
+	streams := &LokiResponse{
+		Status:    loghttp.QueryStatusSuccess,
+		Direction: logproto.FORWARD,
+		Limit:     100,
+		Version:   uint32(loghttp.VersionV1),
+		Data: LokiData{
+			ResultType: loghttp.ResultTypeStream,
+			Result:     logStreams,
+		},
+		Statistics: statsResult,
+	}
+
+	t.Run("protobuf", func(t *testing.T) {
+		ctx := loghttp.InjectResponseOptions(context.Background(), loghttp.ResponseOptions{Format: loghttp.ResponseFormatProtobuf, OmitLabels: true})
+		got, err := LokiCodec.EncodeResponse(ctx, streams)
+		require.NoError(t, err)
+		require.Equal(t, string(loghttp.ResponseFormatProtobuf), got.Header.Get("Content-Type"))
+		body, err := ioutil.ReadAll(got.Body)
+		require.NoError(t, err)
+
+		var decoded LokiResponse
+		require.NoError(t, decoded.Unmarshal(body))
+		require.Equal(t, statsResult, decoded.Statistics)
+		require.Len(t, decoded.Data.Result, 2)
+		for i, s := range decoded.Data.Result {
+			require.Empty(t, s.Labels)
+			require.Equal(t, logStreams[i].Entries, s.Entries)
+		}
+	})
+
+	t.Run("compact json", func(t *testing.T) {
+		ctx := loghttp.InjectResponseOptions(context.Background(), loghttp.ResponseOptions{Format: loghttp.ResponseFormatCompactJSON, OmitStats: true})
+		got, err := LokiCodec.EncodeResponse(ctx, streams)
+		require.NoError(t, err)
+		require.Equal(t, string(loghttp.ResponseFormatCompactJSON), got.Header.Get("Content-Type"))
+		body, err := ioutil.ReadAll(got.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"status": "success",
+			"data": {
+				"resultType": "streams",
+				"result": [
+					{"stream": {"test": "test"}, "start": "123456789012345", "deltas": [0], "lines": ["super line"]},
+					{"stream": {"test": "test2"}, "start": "123456789012346", "deltas": [0], "lines": ["super line2"]}
+				]
+			}
+		}`, string(body))
+	})
+
+	t.Run("legacy responses ignore options", func(t *testing.T) {
+		legacy := *streams
+		legacy.Version = uint32(loghttp.VersionLegacy)
+		ctx := loghttp.InjectResponseOptions(context.Background(), loghttp.ResponseOptions{Format: loghttp.ResponseFormatProtobuf})
+		got, err := LokiCodec.EncodeResponse(ctx, &legacy)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(got.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, streamsStringLegacy, string(body))
+	})
+}
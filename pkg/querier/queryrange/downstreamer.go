@@ -15,6 +15,7 @@ import (
 	"github.com/grafana/loki/pkg/loghttp"
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/logqlmodel"
+	"github.com/grafana/loki/pkg/util/marshal"
 )
 
 const (
@@ -186,6 +187,53 @@ func sampleStreamToVector(streams []queryrange.SampleStream) parser.Value {
 	return xs
 }
 
+// ResultToResponse converts the result of a query to the response of the query frontend.
+func ResultToResponse(res logqlmodel.Result, params logql.Params, path string) (queryrange.Response, error) {
+	value, err := marshal.NewResultValue(res.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch res.Data.Type() {
+	case parser.ValueTypeMatrix:
+		return &LokiPromResponse{
+			Response: &queryrange.PrometheusResponse{
+				Status: loghttp.QueryStatusSuccess,
+				Data: queryrange.PrometheusData{
+					ResultType: loghttp.ResultTypeMatrix,
+					Result:     toProtoMatrix(value.(loghttp.Matrix)),
+				},
+			},
+			Statistics: res.Statistics,
+		}, nil
+	case logqlmodel.ValueTypeStreams:
+		return &LokiResponse{
+			Status:     loghttp.QueryStatusSuccess,
+			Direction:  params.Direction(),
+			Limit:      params.Limit(),
+			Version:    uint32(loghttp.GetVersion(path)),
+			Statistics: res.Statistics,
+			Data: LokiData{
+				ResultType: loghttp.ResultTypeStream,
+				Result:     value.(loghttp.Streams).ToProto(),
+			},
+		}, nil
+	case parser.ValueTypeVector:
+		return &LokiPromResponse{
+			Statistics: res.Statistics,
+			Response: &queryrange.PrometheusResponse{
+				Status: loghttp.QueryStatusSuccess,
+				Data: queryrange.PrometheusData{
+					ResultType: loghttp.ResultTypeVector,
+					Result:     toProtoVector(value.(loghttp.Vector)),
+				},
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unexpected response type (%T)", res.Data.Type())
+	}
+}
+
 func ResponseToResult(resp queryrange.Response) (logqlmodel.Result, error) {
 	switch r := resp.(type) {
 	case *LokiResponse:
@@ -0,0 +1,100 @@
+package queryrange
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/gogo/protobuf/proto"
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/pkg/util/marshal"
+)
+
+// supportsResponseOptions returns whether the response can be encoded with other
+// response options than the default ones. Legacy responses can't.
+func supportsResponseOptions(res queryrange.Response) bool {
+	switch r := res.(type) {
+	case *LokiPromResponse:
+		return true
+	case *LokiResponse:
+		return loghttp.Version(r.Version) != loghttp.VersionLegacy
+	}
+	return false
+}
+
+// encodeResponseWithOptions encodes a query response in the format, and without
+// the parts, selected by the response options.
+func encodeResponseWithOptions(ctx context.Context, res queryrange.Response, opts loghttp.ResponseOptions) (*http.Response, error) {
+	var buf bytes.Buffer
+	if opts.Format == loghttp.ResponseFormatProtobuf {
+		b, err := proto.Marshal(withoutOmittedParts(res, opts))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	} else {
+		result, err := ResponseToResult(res)
+		if err != nil {
+			return nil, err
+		}
+		if err := marshal.WriteQueryResponse(result, opts, &buf); err != nil {
+			return nil, err
+		}
+	}
+
+	if sp := opentracing.SpanFromContext(ctx); sp != nil {
+		sp.LogFields(otlog.String("format", string(opts.Format)), otlog.Int("bytes", buf.Len()))
+	}
+	return &http.Response{
+		Header: http.Header{
+			"Content-Type": []string{string(opts.Format)},
+		},
+		Body:       ioutil.NopCloser(&buf),
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// withoutOmittedParts returns a copy of the response without the parts omitted by
+// the response options, nor the headers exchanged with the queriers.
+func withoutOmittedParts(res queryrange.Response, opts loghttp.ResponseOptions) queryrange.Response {
+	switch r := res.(type) {
+	case *LokiResponse:
+		out := *r
+		out.Headers = nil
+		if opts.OmitStats {
+			out.Statistics = stats.Result{}
+		}
+		if opts.OmitLabels {
+			out.Data.Result = make([]logproto.Stream, 0, len(r.Data.Result))
+			for _, s := range r.Data.Result {
+				out.Data.Result = append(out.Data.Result, logproto.Stream{Entries: s.Entries})
+			}
+		}
+		return &out
+	case *LokiPromResponse:
+		out := *r
+		if opts.OmitStats {
+			out.Statistics = stats.Result{}
+		}
+		if r.Response != nil {
+			prom := *r.Response
+			prom.Headers = nil
+			if opts.OmitLabels {
+				prom.Data.Result = make([]queryrange.SampleStream, 0, len(r.Response.Data.Result))
+				for _, s := range r.Response.Data.Result {
+					prom.Data.Result = append(prom.Data.Result, queryrange.SampleStream{Samples: s.Samples})
+				}
+			}
+			out.Response = &prom
+		}
+		return &out
+	}
+	return res
+}
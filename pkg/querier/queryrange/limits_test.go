@@ -13,6 +13,7 @@ import (
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/promql"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/user"
 	"go.uber.org/atomic"
 
@@ -20,6 +21,7 @@ import (
 	"github.com/grafana/loki/pkg/logqlmodel"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/util/marshal"
+	"github.com/grafana/loki/pkg/validation"
 )
 
 func TestLimits(t *testing.T) {
@@ -50,6 +52,38 @@ func TestLimits(t *testing.T) {
 	)
 }
 
+func Test_LimitsMiddleware(t *testing.T) {
+	now := time.Now()
+	var got queryrange.Request
+	next := queryrange.HandlerFunc(func(_ context.Context, r queryrange.Request) (queryrange.Response, error) {
+		got = r
+		return &LokiResponse{}, nil
+	})
+	handler := NewLimitsMiddleware(fakeLimits{maxQueryLookback: 24 * time.Hour}).Wrap(next)
+	ctx := user.InjectOrgID(context.Background(), "1")
+
+	// The start of the query is clamped to the max query lookback.
+	_, err := handler.Do(ctx, &LokiRequest{Query: `{app="foo"}`, StartTs: now.Add(-26 * time.Hour), EndTs: now.Add(-20 * time.Hour)})
+	require.NoError(t, err)
+	require.InDelta(t, now.Add(-24*time.Hour).UnixNano()/int64(time.Millisecond), got.GetStart(), float64(time.Second/time.Millisecond))
+
+	// The max query length of the fake limits is 7h.
+	_, err = handler.Do(ctx, &LokiRequest{Query: `{app="foo"}`, StartTs: now.Add(-8 * time.Hour), EndTs: now})
+	var limitErr *validation.QueryLimitError
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, validation.MaxQueryLength, limitErr.Limit)
+	require.Equal(t, "7h0m0s", limitErr.Max)
+	require.Equal(t, 7*time.Hour, limitErr.Suggested.End.Sub(*limitErr.Suggested.Start))
+
+	_, err = handler.Do(ctx, &LokiRequest{Query: `{app="foo"}`, StartTs: now.Add(-50 * time.Hour), EndTs: now.Add(-48 * time.Hour)})
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, validation.MaxQueryLookback, limitErr.Limit)
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusBadRequest), resp.Code)
+	require.Contains(t, string(resp.Body), `"limit":"max_query_lookback"`)
+}
+
 func Test_seriesLimiter(t *testing.T) {
 	cfg := testConfig
 	cfg.SplitQueriesByInterval = time.Hour
@@ -14,14 +14,10 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/weaveworks/common/httpgrpc"
 
-	"github.com/grafana/loki/pkg/loghttp"
 	"github.com/grafana/loki/pkg/logql"
-	"github.com/grafana/loki/pkg/logqlmodel"
 	"github.com/grafana/loki/pkg/storage/chunk"
-	"github.com/grafana/loki/pkg/util/marshal"
 )
 
 var errInvalidShardingRange = errors.New("Query does not fit in a single sharding configuration")
@@ -138,49 +134,7 @@ func (ast *astMapperware) Do(ctx context.Context, r queryrange.Request) (queryra
 		return nil, err
 	}
 
-	value, err := marshal.NewResultValue(res.Data)
-	if err != nil {
-		return nil, err
-	}
-
-	switch res.Data.Type() {
-	case parser.ValueTypeMatrix:
-		return &LokiPromResponse{
-			Response: &queryrange.PrometheusResponse{
-				Status: loghttp.QueryStatusSuccess,
-				Data: queryrange.PrometheusData{
-					ResultType: loghttp.ResultTypeMatrix,
-					Result:     toProtoMatrix(value.(loghttp.Matrix)),
-				},
-			},
-			Statistics: res.Statistics,
-		}, nil
-	case logqlmodel.ValueTypeStreams:
-		return &LokiResponse{
-			Status:     loghttp.QueryStatusSuccess,
-			Direction:  params.Direction(),
-			Limit:      params.Limit(),
-			Version:    uint32(loghttp.GetVersion(path)),
-			Statistics: res.Statistics,
-			Data: LokiData{
-				ResultType: loghttp.ResultTypeStream,
-				Result:     value.(loghttp.Streams).ToProto(),
-			},
-		}, nil
-	case parser.ValueTypeVector:
-		return &LokiPromResponse{
-			Statistics: res.Statistics,
-			Response: &queryrange.PrometheusResponse{
-				Status: loghttp.QueryStatusSuccess,
-				Data: queryrange.PrometheusData{
-					ResultType: loghttp.ResultTypeVector,
-					Result:     toProtoVector(value.(loghttp.Vector)),
-				},
-			},
-		}, nil
-	default:
-		return nil, fmt.Errorf("unexpected downstream response type (%T)", res.Data.Type())
-	}
+	return ResultToResponse(res, params, path)
 }
 
 // shardSplitter middleware will only shard appropriate requests that do not extend past the MinShardingLookback interval.
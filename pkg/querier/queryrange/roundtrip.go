@@ -18,16 +18,20 @@ import (
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/cache"
+	"github.com/grafana/loki/pkg/validation"
 )
 
 // Config is the configuration for the queryrange tripperware
 type Config struct {
 	queryrange.Config `yaml:",inline"`
+
+	SlowQueries SlowQueriesConfig `yaml:"slow_queries"`
 }
 
 // RegisterFlags adds the flags required to configure this flag set.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.Config.RegisterFlags(f)
+	cfg.SlowQueries.RegisterFlags(f)
 }
 
 // Stopper gracefully shutdown resources created
@@ -117,6 +121,9 @@ func (r roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 
 	switch op := getOperation(req.URL.Path); op {
 	case QueryRangeOp:
+		if req, err = withResponseOptions(req); err != nil {
+			return nil, err
+		}
 		rangeQuery, err := loghttp.ParseRangeQuery(req)
 		if err != nil {
 			return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
@@ -157,6 +164,9 @@ func (r roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		}
 		return r.labels.RoundTrip(req)
 	case InstantQueryOp:
+		if req, err = withResponseOptions(req); err != nil {
+			return nil, err
+		}
 		instantQuery, err := loghttp.ParseInstantQuery(req)
 		if err != nil {
 			return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
@@ -176,6 +186,16 @@ func (r roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 }
 
+// withResponseOptions returns the request with its response options in its
+// context, for the codec to encode the response accordingly.
+func withResponseOptions(req *http.Request) (*http.Request, error) {
+	opts, err := loghttp.ParseResponseOptions(req)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+	}
+	return req.WithContext(loghttp.InjectResponseOptions(req.Context(), opts)), nil
+}
+
 // transformRegexQuery backport the old regexp params into the v1 query format
 func transformRegexQuery(req *http.Request, expr logql.LogSelectorExpr) (logql.LogSelectorExpr, error) {
 	regexp := req.Form.Get("regexp")
@@ -204,8 +224,7 @@ func validateLimits(req *http.Request, reqLimit uint32, limits Limits) error {
 
 	maxEntriesLimit := limits.MaxEntriesLimitPerQuery(userID)
 	if int(reqLimit) > maxEntriesLimit && maxEntriesLimit != 0 {
-		return httpgrpc.Errorf(http.StatusBadRequest,
-			"max entries limit per query exceeded, limit > max_entries_limit (%d > %d)", reqLimit, maxEntriesLimit)
+		return validation.NewEntriesLimitError(reqLimit, maxEntriesLimit)
 	}
 	return nil
 }
@@ -245,7 +264,7 @@ func NewLogFilterTripperware(
 	shardingMetrics *logql.ShardingMetrics,
 	splitByMetrics *SplitByMetrics,
 ) (queryrange.Tripperware, error) {
-	queryRangeMiddleware := []queryrange.Middleware{StatsCollectorMiddleware(), queryrange.NewLimitsMiddleware(limits)}
+	queryRangeMiddleware := []queryrange.Middleware{StatsCollectorMiddleware(), NewLimitsMiddleware(limits)}
 	if cfg.SplitQueriesByInterval != 0 {
 		queryRangeMiddleware = append(queryRangeMiddleware, queryrange.InstrumentMiddleware("split_by_interval", instrumentMetrics), SplitByIntervalMiddleware(limits, codec, splitByTime, splitByMetrics))
 	}
@@ -370,7 +389,7 @@ func NewMetricTripperware(
 	splitByMetrics *SplitByMetrics,
 	registerer prometheus.Registerer,
 ) (queryrange.Tripperware, Stopper, error) {
-	queryRangeMiddleware := []queryrange.Middleware{StatsCollectorMiddleware(), queryrange.NewLimitsMiddleware(limits)}
+	queryRangeMiddleware := []queryrange.Middleware{StatsCollectorMiddleware(), NewLimitsMiddleware(limits)}
 	if cfg.AlignQueriesWithStep {
 		queryRangeMiddleware = append(
 			queryRangeMiddleware,
@@ -396,7 +415,7 @@ func NewMetricTripperware(
 			extractor,
 			nil,
 			func(r queryrange.Request) bool {
-				return !r.GetCachingOptions().Disabled
+				return !r.GetCachingOptions().Disabled && !usesLookupTables(r.GetQuery())
 			},
 			registerer,
 		)
@@ -407,7 +426,7 @@ func NewMetricTripperware(
 		queryRangeMiddleware = append(
 			queryRangeMiddleware,
 			queryrange.InstrumentMiddleware("results_cache", instrumentMetrics),
-			queryCacheMiddleware,
+			cacheHitsMiddleware(queryCacheMiddleware),
 		)
 	}
 
@@ -461,7 +480,7 @@ func NewInstantMetricTripperware(
 	shardingMetrics *logql.ShardingMetrics,
 	splitByMetrics *SplitByMetrics,
 ) (queryrange.Tripperware, error) {
-	queryRangeMiddleware := []queryrange.Middleware{StatsCollectorMiddleware(), queryrange.NewLimitsMiddleware(limits)}
+	queryRangeMiddleware := []queryrange.Middleware{StatsCollectorMiddleware(), NewLimitsMiddleware(limits)}
 
 	if cfg.ShardedQueries {
 		queryRangeMiddleware = append(queryRangeMiddleware,
@@ -490,3 +509,13 @@ func NewInstantMetricTripperware(
 		return next
 	}, nil
 }
+
+// usesLookupTables returns whether the query has lookup stages. Their results are not cached,
+// since the lookup tables can change at any time.
+func usesLookupTables(query string) bool {
+	expr, err := logql.ParseExpr(query)
+	if err != nil {
+		return false
+	}
+	return logql.UsesLookupTables(expr)
+}
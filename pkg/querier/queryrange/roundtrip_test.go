@@ -20,7 +20,6 @@ import (
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/stretchr/testify/require"
-	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/middleware"
 	"github.com/weaveworks/common/user"
 
@@ -28,11 +27,12 @@ import (
 	"github.com/grafana/loki/pkg/logqlmodel"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/util/marshal"
+	"github.com/grafana/loki/pkg/validation"
 )
 
 var (
 	testTime   = time.Date(2019, 12, 02, 11, 10, 10, 10, time.UTC)
-	testConfig = Config{queryrange.Config{
+	testConfig = Config{Config: queryrange.Config{
 		SplitQueriesByInterval: 4 * time.Hour,
 		AlignQueriesWithStep:   true,
 		MaxRetries:             3,
@@ -512,7 +512,7 @@ func TestEntriesLimitsTripperware(t *testing.T) {
 	require.NoError(t, err)
 
 	_, err = tpw(rt).RoundTrip(req)
-	require.Equal(t, httpgrpc.Errorf(http.StatusBadRequest, "max entries limit per query exceeded, limit > max_entries_limit (10000 > 5000)"), err)
+	require.Equal(t, validation.NewEntriesLimitError(10000, 5000), err)
 }
 
 func TestEntriesLimitWithZeroTripperware(t *testing.T) {
@@ -552,6 +552,7 @@ type fakeLimits struct {
 	maxSeries               int
 	splits                  map[string]time.Duration
 	minShardingLookback     time.Duration
+	maxQueryLookback        time.Duration
 }
 
 func (f fakeLimits) QuerySplitDuration(key string) time.Duration {
@@ -580,12 +581,16 @@ func (f fakeLimits) MaxQuerySeries(string) int {
 	return f.maxSeries
 }
 
+func (f fakeLimits) MaxQueryMemory(string) int {
+	return 0
+}
+
 func (f fakeLimits) MaxCacheFreshness(string) time.Duration {
 	return 1 * time.Minute
 }
 
 func (f fakeLimits) MaxQueryLookback(string) time.Duration {
-	return 0
+	return f.maxQueryLookback
 }
 
 func (f fakeLimits) MinShardingLookback(string) time.Duration {
@@ -686,3 +691,9 @@ func (s fakeRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
 func toMs(t time.Time) int64 {
 	return t.UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
 }
+
+func Test_usesLookupTables(t *testing.T) {
+	require.True(t, usesLookupTables(`{app="foo"} | logfmt | lookup owner=service_owners[service]`))
+	require.False(t, usesLookupTables(`{app="foo"} | logfmt | lookup="nxdomain"`))
+	require.False(t, usesLookupTables(`{app="foo"`))
+}
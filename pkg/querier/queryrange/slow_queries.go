@@ -0,0 +1,322 @@
+package queryrange
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/logql"
+)
+
+// SlowQueriesConfig configures the slow query log of the frontend.
+type SlowQueriesConfig struct {
+	Threshold       time.Duration `yaml:"threshold"`
+	Retention       time.Duration `yaml:"retention"`
+	MaxFingerprints int           `yaml:"max_fingerprints_per_tenant"`
+}
+
+// RegisterFlags registers the flags of the slow query log.
+func (cfg *SlowQueriesConfig) RegisterFlags(f *flag.FlagSet) {
+	f.DurationVar(&cfg.Threshold, "frontend.slow-queries.threshold", 0, "Log the queries slower than this, and aggregate them per tenant and fingerprint for the slow queries API. 0 disables the slow query log.")
+	f.DurationVar(&cfg.Retention, "frontend.slow-queries.retention", 24*time.Hour, "How long the slow queries are aggregated for the slow queries API.")
+	f.IntVar(&cfg.MaxFingerprints, "frontend.slow-queries.max-fingerprints-per-tenant", 1000, "Maximum number of query fingerprints aggregated per tenant and hour. The slow queries of other fingerprints are still logged.")
+}
+
+// Enabled returns true if the slow query log is enabled.
+func (cfg *SlowQueriesConfig) Enabled() bool {
+	return cfg.Threshold > 0
+}
+
+// FingerprintStats aggregates the slow queries sharing a fingerprint.
+type FingerprintStats struct {
+	Fingerprint          string  `json:"fingerprint"`
+	Query                string  `json:"query"`
+	Count                int64   `json:"count"`
+	TotalDurationSeconds float64 `json:"total_duration_seconds"`
+	MaxDurationSeconds   float64 `json:"max_duration_seconds"`
+	TotalBytes           int64   `json:"total_bytes"`
+	Splits               int64   `json:"splits"`
+	CacheHits            int64   `json:"cache_hits"`
+}
+
+func (s *FingerprintStats) add(o *FingerprintStats) {
+	s.Count += o.Count
+	s.TotalDurationSeconds += o.TotalDurationSeconds
+	if o.MaxDurationSeconds > s.MaxDurationSeconds {
+		s.MaxDurationSeconds = o.MaxDurationSeconds
+	}
+	s.TotalBytes += o.TotalBytes
+	s.Splits += o.Splits
+	s.CacheHits += o.CacheHits
+}
+
+// SlowQueries logs the queries slower than the threshold and aggregates them per tenant, hour
+// and fingerprint of the normalized query.
+type SlowQueries struct {
+	cfg    SlowQueriesConfig
+	logger log.Logger
+	now    func() time.Time
+
+	mtx sync.Mutex
+	// tenant -> start of the hour -> fingerprint.
+	tenants map[string]map[int64]map[string]*FingerprintStats
+}
+
+// NewSlowQueries creates the slow query log.
+func NewSlowQueries(cfg SlowQueriesConfig, logger log.Logger) *SlowQueries {
+	return &SlowQueries{
+		cfg:     cfg,
+		logger:  logger,
+		now:     time.Now,
+		tenants: map[string]map[int64]map[string]*FingerprintStats{},
+	}
+}
+
+// Record logs and aggregates the query if it is slow.
+func (s *SlowQueries) Record(data *queryData) {
+	if data.statistics == nil || data.params == nil {
+		return
+	}
+	duration := time.Duration(data.statistics.Summary.ExecTime * float64(time.Second))
+	if duration < s.cfg.Threshold {
+		return
+	}
+	tenantIDs, err := tenant.TenantIDs(data.ctx)
+	if err != nil {
+		return
+	}
+	userID := tenant.JoinTenantIDs(tenantIDs)
+
+	query := data.params.Query()
+	normalized, err := normalizeQuery(query)
+	if err != nil {
+		normalized = query
+	}
+	stats := &FingerprintStats{
+		Fingerprint:          fingerprint(normalized),
+		Query:                normalized,
+		Count:                1,
+		TotalDurationSeconds: duration.Seconds(),
+		MaxDurationSeconds:   duration.Seconds(),
+		TotalBytes:           data.statistics.Summary.TotalBytesProcessed,
+		Splits:               data.splits.Load(),
+		CacheHits:            data.cacheHits.Load(),
+	}
+
+	level.Info(util_log.WithContext(data.ctx, s.logger)).Log(
+		"msg", "slow query",
+		"fingerprint", stats.Fingerprint,
+		"normalized_query", stats.Query,
+		"query", query,
+		"range_type", logql.GetRangeType(data.params),
+		"length", data.params.End().Sub(data.params.Start()),
+		"duration", duration,
+		"status", data.status,
+		"bytes_scanned", stats.TotalBytes,
+		"splits", stats.Splits,
+		"cache_hits", stats.CacheHits,
+	)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	now := s.now()
+	s.prune(now)
+	hours, ok := s.tenants[userID]
+	if !ok {
+		hours = map[int64]map[string]*FingerprintStats{}
+		s.tenants[userID] = hours
+	}
+	hour := now.Truncate(time.Hour).Unix()
+	fingerprints, ok := hours[hour]
+	if !ok {
+		fingerprints = map[string]*FingerprintStats{}
+		hours[hour] = fingerprints
+	}
+	if current, ok := fingerprints[stats.Fingerprint]; ok {
+		current.add(stats)
+		return
+	}
+	if len(fingerprints) >= s.cfg.MaxFingerprints {
+		return
+	}
+	fingerprints[stats.Fingerprint] = stats
+}
+
+// prune drops the hours older than the retention.
+func (s *SlowQueries) prune(now time.Time) {
+	oldest := now.Add(-s.cfg.Retention).Truncate(time.Hour).Unix()
+	for userID, hours := range s.tenants {
+		for hour := range hours {
+			if hour < oldest {
+				delete(hours, hour)
+			}
+		}
+		if len(hours) == 0 {
+			delete(s.tenants, userID)
+		}
+	}
+}
+
+// Top returns the limit fingerprints of the tenant with the longest total duration of slow
+// queries in the last given hours, the current one included.
+func (s *SlowQueries) Top(userID string, hours, limit int) []FingerprintStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	since := s.now().Truncate(time.Hour).Add(-time.Duration(hours-1) * time.Hour).Unix()
+	merged := map[string]*FingerprintStats{}
+	for hour, fingerprints := range s.tenants[userID] {
+		if hour < since {
+			continue
+		}
+		for fp, stats := range fingerprints {
+			if current, ok := merged[fp]; ok {
+				current.add(stats)
+				continue
+			}
+			copied := *stats
+			merged[fp] = &copied
+		}
+	}
+
+	top := make([]FingerprintStats, 0, len(merged))
+	for _, stats := range merged {
+		top = append(top, *stats)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].TotalDurationSeconds != top[j].TotalDurationSeconds {
+			return top[i].TotalDurationSeconds > top[j].TotalDurationSeconds
+		}
+		return top[i].Fingerprint < top[j].Fingerprint
+	})
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
+	return top
+}
+
+// Handler serves the top fingerprints of slow queries of the tenant. The hours parameter
+// selects how many of the last hours are aggregated, and limit how many fingerprints are returned.
+func (s *SlowQueries) Handler(w http.ResponseWriter, r *http.Request) {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hours, err := intParam(r, "hours", 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if maxHours := int(s.cfg.Retention / time.Hour); hours < 1 || hours > maxHours {
+		http.Error(w, fmt.Sprintf("hours must be between 1 and %d", maxHours), http.StatusBadRequest)
+		return
+	}
+	limit, err := intParam(r, "limit", 10)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	util.WriteJSONResponse(w, struct {
+		Status string             `json:"status"`
+		Data   []FingerprintStats `json:"data"`
+	}{
+		Status: loghttp.QueryStatusSuccess,
+		Data:   s.Top(tenant.JoinTenantIDs(tenantIDs), hours, limit),
+	})
+}
+
+func intParam(r *http.Request, name string, def int) (int, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return i, nil
+}
+
+// normalizeQuery sorts the matchers of the stream selectors of the query and replaces its other
+// strings, like the values of the line filters, by "?", so that the queries only differing by
+// these values share a fingerprint.
+func normalizeQuery(query string) (string, error) {
+	expr, err := logql.ParseExpr(query)
+	if err != nil {
+		return "", err
+	}
+	expr.Walk(func(e interface{}) {
+		m, ok := e.(*logql.MatchersExpr)
+		if !ok {
+			return
+		}
+		matchers := m.Matchers()
+		sort.Slice(matchers, func(i, j int) bool {
+			return lessMatcher(matchers[i], matchers[j])
+		})
+	})
+
+	var (
+		s     = expr.String()
+		sb    strings.Builder
+		depth int
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"', '`':
+			end := i + 1
+			for ; end < len(s) && s[end] != c; end++ {
+				if c == '"' && s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				end = len(s) - 1
+			}
+			if depth == 0 {
+				sb.WriteByte('?')
+			} else {
+				sb.WriteString(s[i : end+1])
+			}
+			i = end
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String(), nil
+}
+
+func lessMatcher(a, b *labels.Matcher) bool {
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	return a.Value < b.Value
+}
+
+func fingerprint(normalized string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(normalized))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
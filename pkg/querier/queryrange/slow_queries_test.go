@@ -0,0 +1,156 @@
+package queryrange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/logqlmodel/stats"
+)
+
+func Test_normalizeQuery(t *testing.T) {
+	for _, tc := range []struct {
+		query, expected string
+	}{
+		{`{app="foo"}`, `{app="foo"}`},
+		{`{namespace="loki", app=~"foo|bar"} |= "error" != "timeout"`, `{app=~"foo|bar", namespace="loki"} |= ? != ?`},
+		{`{app="foo", namespace="loki"} |= "warn" != "retry"`, `{app="foo", namespace="loki"} |= ? != ?`},
+		{"{app=\"foo\"} |~ `\"err\"` | logfmt | status >= 500", `{app="foo"} |~ ? | logfmt | status>=500`},
+		{`sum by (app) (rate({namespace="loki", app="foo"} |= "level=\"error\"" [5m]))`, `sum by(app)(rate({app="foo", namespace="loki"} |= ?[5m]))`},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			normalized, err := normalizeQuery(tc.query)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, normalized)
+		})
+	}
+
+	a, err := normalizeQuery(`{b="2", a="1"} |= "foo"`)
+	require.NoError(t, err)
+	b, err := normalizeQuery(`{a="1", b="2"} |= "bar"`)
+	require.NoError(t, err)
+	require.Equal(t, fingerprint(a), fingerprint(b))
+
+	_, err = normalizeQuery(`{app="foo"`)
+	require.Error(t, err)
+}
+
+func slowQueryData(userID, query string, duration time.Duration) *queryData {
+	data := &queryData{
+		ctx:    user.InjectOrgID(context.Background(), userID),
+		params: &paramsRangeWrapper{LokiRequest: &LokiRequest{Query: query}},
+		statistics: &stats.Result{
+			Summary: stats.Summary{ExecTime: duration.Seconds(), TotalBytesProcessed: 1000},
+		},
+		status: "200",
+	}
+	data.splits.Add(4)
+	data.cacheHits.Add(3)
+	return data
+}
+
+func TestSlowQueries(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 30, 0, 0, time.UTC)
+	s := NewSlowQueries(SlowQueriesConfig{Threshold: time.Second, Retention: 3 * time.Hour, MaxFingerprints: 2}, util_log.Logger)
+	s.now = func() time.Time { return now }
+
+	// fast queries aren't recorded.
+	s.Record(slowQueryData("fake", `{app="foo"} |= "error"`, 500*time.Millisecond))
+	require.Empty(t, s.Top("fake", 1, 10))
+
+	s.Record(slowQueryData("fake", `{app="foo"} |= "error"`, 2*time.Second))
+	s.Record(slowQueryData("fake", `{app="foo"} |= "timeout"`, 3*time.Second))
+	s.Record(slowQueryData("fake", `{app="bar"}`, 4*time.Second))
+	s.Record(slowQueryData("other", `{app="baz"}`, 10*time.Second))
+
+	top := s.Top("fake", 1, 10)
+	require.Len(t, top, 2)
+	require.Equal(t, FingerprintStats{
+		Fingerprint:          fingerprint(`{app="foo"} |= ?`),
+		Query:                `{app="foo"} |= ?`,
+		Count:                2,
+		TotalDurationSeconds: 5,
+		MaxDurationSeconds:   3,
+		TotalBytes:           2000,
+		Splits:               8,
+		CacheHits:            6,
+	}, top[0])
+	require.Equal(t, `{app="bar"}`, top[1].Query)
+	require.Len(t, s.Top("fake", 1, 1), 1)
+
+	// the fingerprints beyond the max per hour aren't aggregated.
+	s.Record(slowQueryData("fake", `{app="qux"}`, 20*time.Second))
+	require.Len(t, s.Top("fake", 1, 10), 2)
+
+	// the hours are aggregated, the last given ones only.
+	now = now.Add(time.Hour)
+	s.Record(slowQueryData("fake", `{app="bar"}`, 10*time.Second))
+	top = s.Top("fake", 1, 10)
+	require.Len(t, top, 1)
+	require.Equal(t, int64(1), top[0].Count)
+	top = s.Top("fake", 2, 10)
+	require.Len(t, top, 2)
+	require.Equal(t, `{app="bar"}`, top[0].Query)
+	require.Equal(t, int64(2), top[0].Count)
+	require.Equal(t, float64(10), top[0].MaxDurationSeconds)
+
+	// the hours older than the retention are dropped.
+	now = now.Add(3 * time.Hour)
+	s.Record(slowQueryData("fake", `{app="bar"}`, 10*time.Second))
+	top = s.Top("fake", 3, 10)
+	require.Len(t, top, 1)
+	require.Equal(t, int64(1), top[0].Count)
+	require.NotContains(t, s.tenants, "other")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/loki/api/v1/slow_queries?hours=3&limit=1", nil)
+	s.Handler(rec, req.WithContext(user.InjectOrgID(req.Context(), "fake")))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"status":"success","data":[{
+		"fingerprint": "`+fingerprint(`{app="bar"}`)+`",
+		"query": "{app=\"bar\"}",
+		"count": 1,
+		"total_duration_seconds": 10,
+		"max_duration_seconds": 10,
+		"total_bytes": 1000,
+		"splits": 4,
+		"cache_hits": 3
+	}]}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/loki/api/v1/slow_queries?hours=4", nil)
+	s.Handler(rec, req.WithContext(user.InjectOrgID(req.Context(), "fake")))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func Test_cacheHitsMiddleware(t *testing.T) {
+	// a fake results cache answering the requests starting from 10 itself.
+	cache := queryrange.MiddlewareFunc(func(next queryrange.Handler) queryrange.Handler {
+		return queryrange.HandlerFunc(func(ctx context.Context, r queryrange.Request) (queryrange.Response, error) {
+			if r.GetStart() >= 10 {
+				return &LokiResponse{}, nil
+			}
+			return next.Do(ctx, r)
+		})
+	})
+	handler := cacheHitsMiddleware(cache).Wrap(queryrange.HandlerFunc(func(ctx context.Context, r queryrange.Request) (queryrange.Response, error) {
+		return &LokiResponse{}, nil
+	}))
+
+	data := &queryData{}
+	ctx := context.WithValue(context.Background(), ctxKey, data)
+	recordSplits(ctx, 3)
+	for _, start := range []int64{0, 10, 20} {
+		_, err := handler.Do(ctx, &LokiRequest{StartTs: time.Unix(0, start*int64(time.Millisecond))})
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(3), data.splits.Load())
+	require.Equal(t, int64(2), data.cacheHits.Load())
+}
@@ -172,6 +172,7 @@ func (h *splitByInterval) Do(ctx context.Context, r queryrange.Request) (queryra
 
 	intervals := h.splitter(r, interval)
 	h.metrics.splits.Observe(float64(len(intervals)))
+	recordSplits(ctx, len(intervals))
 
 	// no interval should not be processed by the frontend.
 	if len(intervals) == 0 {
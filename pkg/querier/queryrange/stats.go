@@ -14,6 +14,7 @@ import (
 	"github.com/go-kit/log/level"
 	promql_parser "github.com/prometheus/prometheus/promql/parser"
 	"github.com/weaveworks/common/middleware"
+	"go.uber.org/atomic"
 
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/logqlmodel"
@@ -32,6 +33,15 @@ var (
 	StatsHTTPMiddleware middleware.Interface = statsHTTPMiddleware(defaultMetricRecorder)
 )
 
+// NewStatsHTTPMiddleware returns an http middleware recording the stats of the queries like
+// StatsHTTPMiddleware, which also logs and aggregates the slow queries.
+func NewStatsHTTPMiddleware(slowQueries *SlowQueries) middleware.Interface {
+	return statsHTTPMiddleware(metricRecorderFn(func(data *queryData) {
+		defaultMetricRecorder.Record(data)
+		slowQueries.Record(data)
+	}))
+}
+
 type metricRecorder interface {
 	Record(data *queryData)
 }
@@ -48,6 +58,9 @@ type queryData struct {
 	statistics *stats.Result
 	result     promql_parser.Value
 	status     string
+	// splits and cacheHits count the split queries and those answered by the results cache.
+	splits    atomic.Int64
+	cacheHits atomic.Int64
 
 	recorded bool
 }
@@ -121,6 +134,38 @@ func StatsCollectorMiddleware() queryrange.Middleware {
 	})
 }
 
+// recordSplits adds the split queries of the request to its stats.
+func recordSplits(ctx context.Context, splits int) {
+	if data, ok := ctx.Value(ctxKey).(*queryData); ok {
+		data.splits.Add(int64(splits))
+	}
+}
+
+type cacheMissKeyType string
+
+const cacheMissKey cacheMissKeyType = "cache_miss"
+
+// cacheHitsMiddleware counts the requests the results cache middleware answers without
+// querying downstream in the stats of the query.
+func cacheHitsMiddleware(cacheMiddleware queryrange.Middleware) queryrange.Middleware {
+	return queryrange.MiddlewareFunc(func(next queryrange.Handler) queryrange.Handler {
+		cached := cacheMiddleware.Wrap(queryrange.HandlerFunc(func(ctx context.Context, req queryrange.Request) (queryrange.Response, error) {
+			if miss, ok := ctx.Value(cacheMissKey).(*atomic.Bool); ok {
+				miss.Store(true)
+			}
+			return next.Do(ctx, req)
+		}))
+		return queryrange.HandlerFunc(func(ctx context.Context, req queryrange.Request) (queryrange.Response, error) {
+			miss := atomic.NewBool(false)
+			resp, err := cached.Do(context.WithValue(ctx, cacheMissKey, miss), req)
+			if data, ok := ctx.Value(ctxKey).(*queryData); ok && err == nil && !miss.Load() {
+				data.cacheHits.Inc()
+			}
+			return resp, err
+		})
+	})
+}
+
 // interceptor implements WriteHeader to intercept status codes. WriteHeader
 // may not be called on success, so initialize statusCode with the status you
 // want to report on success, i.e. http.StatusOK.
@@ -0,0 +1,83 @@
+package querier
+
+import (
+	"context"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logql"
+)
+
+// TenantLabel holds the tenant of the series of the queries spanning the sub-tenants of an org.
+const TenantLabel = "__tenant_id__"
+
+// queryTenants returns the tenants a query runs on: the tenant of the request and, when
+// enabled, the sub-tenants of its org declared in the overrides.
+func (q *Querier) queryTenants(ctx context.Context) ([]string, error) {
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if q.limits == nil || !q.limits.TenantHierarchy().QuerySubTenants {
+		return []string{tenantID}, nil
+	}
+	return append([]string{tenantID}, q.limits.SubTenants(tenantID)...), nil
+}
+
+// tenantLabels adds the tenant label to the labels of the series of a tenant.
+type tenantLabels struct {
+	tenantID string
+	cache    map[string]string
+}
+
+func (t *tenantLabels) labels(lbs string) string {
+	if out, ok := t.cache[lbs]; ok {
+		return out
+	}
+	out := lbs
+	if parsed, err := logql.ParseLabels(lbs); err == nil {
+		out = labels.NewBuilder(parsed).Set(TenantLabel, t.tenantID).Labels().String()
+	}
+	t.cache[lbs] = out
+	return out
+}
+
+type tenantEntryIterator struct {
+	iter.EntryIterator
+	tenant tenantLabels
+}
+
+func newTenantEntryIterator(it iter.EntryIterator, tenantID string) iter.EntryIterator {
+	return &tenantEntryIterator{EntryIterator: it, tenant: tenantLabels{tenantID: tenantID, cache: map[string]string{}}}
+}
+
+func (it *tenantEntryIterator) Labels() string {
+	return it.tenant.labels(it.EntryIterator.Labels())
+}
+
+type tenantSampleIterator struct {
+	iter.SampleIterator
+	tenant tenantLabels
+}
+
+func newTenantSampleIterator(it iter.SampleIterator, tenantID string) iter.SampleIterator {
+	return &tenantSampleIterator{SampleIterator: it, tenant: tenantLabels{tenantID: tenantID, cache: map[string]string{}}}
+}
+
+func (it *tenantSampleIterator) Labels() string {
+	return it.tenant.labels(it.SampleIterator.Labels())
+}
+
+func closeEntryIterators(iters []iter.EntryIterator) {
+	for _, it := range iters {
+		_ = it.Close()
+	}
+}
+
+func closeSampleIterators(iters []iter.SampleIterator) {
+	for _, it := range iters {
+		_ = it.Close()
+	}
+}
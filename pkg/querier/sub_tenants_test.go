@@ -0,0 +1,85 @@
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+type tenantLimitsMock map[string]*validation.Limits
+
+func (m tenantLimitsMock) TenantLimits(userID string) *validation.Limits {
+	return m[userID]
+}
+
+func (m tenantLimitsMock) AllByUserID() map[string]*validation.Limits {
+	return m
+}
+
+func TestQuerier_SelectLogsSubTenants(t *testing.T) {
+	defaults := defaultLimitsTestConfig()
+	limits, err := validation.NewOverrides(defaults, tenantLimitsMock{
+		"acme":          &defaults,
+		"acme.payments": &defaults,
+		"acme.search":   &defaults,
+		"acmecorp":      &defaults,
+	})
+	require.NoError(t, err)
+	limits.SetTenantHierarchy(validation.TenantHierarchyConfig{Separator: ".", QuerySubTenants: true})
+
+	store := newStoreMock()
+	tenantIs := func(tenantID string) interface{} {
+		return mock.MatchedBy(func(ctx context.Context) bool {
+			id, err := user.ExtractOrgID(ctx)
+			return err == nil && id == tenantID
+		})
+	}
+	store.On("SelectLogs", tenantIs("acme"), mock.Anything).Return(iter.NewStreamIterator(mockStreamWithLabels(1, 1, `{app="gateway"}`)), nil).Once()
+	store.On("SelectLogs", tenantIs("acme.payments"), mock.Anything).Return(iter.NewStreamIterator(mockStreamWithLabels(2, 2, `{app="api"}`)), nil).Once()
+	store.On("SelectLogs", tenantIs("acme.search"), mock.Anything).Return(iter.NewStreamIterator(mockStreamWithLabels(4, 1, `{app="api"}`)), nil).Once()
+
+	cfg := mockQuerierConfig()
+	cfg.QueryStoreOnly = true
+	q, err := newQuerier(cfg, mockIngesterClientConfig(), newIngesterClientMockFactory(newQuerierClientMock()), mockReadRingWithOneActiveIngester(), store, limits)
+	require.NoError(t, err)
+
+	req := logproto.QueryRequest{
+		Selector:  `{app=~".+"}`,
+		Limit:     10,
+		Start:     time.Unix(0, 0),
+		End:       time.Unix(10, 0),
+		Direction: logproto.FORWARD,
+	}
+	it, err := q.SelectLogs(user.InjectOrgID(context.Background(), "acme"), logql.SelectLogParams{QueryRequest: &req})
+	require.NoError(t, err)
+	var got []string
+	for it.Next() {
+		got = append(got, it.Labels()+" "+it.Entry().Line)
+	}
+	require.NoError(t, it.Close())
+	require.Equal(t, []string{
+		`{__tenant_id__="acme", app="gateway"} line 1`,
+		`{__tenant_id__="acme.payments", app="api"} line 2`,
+		`{__tenant_id__="acme.payments", app="api"} line 3`,
+		`{__tenant_id__="acme.search", app="api"} line 4`,
+	}, got)
+	require.Equal(t, time.Unix(0, 0), req.Start)
+	store.AssertNumberOfCalls(t, "SelectLogs", 3)
+
+	// sub-tenants only query their own logs.
+	store.On("SelectLogs", tenantIs("acme.search"), mock.Anything).Return(iter.NewStreamIterator(mockStreamWithLabels(4, 1, `{app="api"}`)), nil)
+	it, err = q.SelectLogs(user.InjectOrgID(context.Background(), "acme.search"), logql.SelectLogParams{QueryRequest: &req})
+	require.NoError(t, err)
+	require.True(t, it.Next())
+	require.Equal(t, `{app="api"}`, it.Labels())
+	require.False(t, it.Next())
+}
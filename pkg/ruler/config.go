@@ -22,6 +22,10 @@ type Config struct {
 
 	WALCleaner  cleaner.Config    `yaml:"wal_cleaner,omitempty"`
 	RemoteWrite RemoteWriteConfig `yaml:"remote_write,omitempty"`
+
+	MaxConcurrentEvaluations int           `yaml:"max_concurrent_evaluations"`
+	EvaluationJitter         time.Duration `yaml:"evaluation_jitter"`
+	EvaluationLateThreshold  time.Duration `yaml:"evaluation_late_threshold"`
 }
 
 func (c *Config) RegisterFlags(f *flag.FlagSet) {
@@ -30,6 +34,10 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	c.WAL.RegisterFlags(f)
 	c.WALCleaner.RegisterFlags(f)
 
+	f.IntVar(&c.MaxConcurrentEvaluations, "ruler.max-concurrent-evaluations", 0, "Maximum number of rules of a tenant evaluated concurrently, the rule groups of a tenant are otherwise all evaluated in parallel. 0 to disable.")
+	f.DurationVar(&c.EvaluationJitter, "ruler.evaluation-jitter", 0, "Maximum random delay added to each evaluation of a rule group, to spread the evaluations of groups scheduled at the same time. It should be lower than the evaluation interval of the groups. 0 to disable.")
+	f.DurationVar(&c.EvaluationLateThreshold, "ruler.evaluation-late-threshold", time.Minute, "Rule evaluations starting this long after their scheduled time are logged and counted as late. 0 to disable.")
+
 	// TODO(owen-d, 3.0.0): remove deprecated experimental prefix in Cortex if they'll accept it.
 	f.BoolVar(&c.Config.EnableAPI, "ruler.enable-api", true, "Enable the ruler api")
 }
@@ -44,6 +52,13 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid ruler remote-write config: %w", err)
 	}
 
+	if c.MaxConcurrentEvaluations < 0 {
+		return errors.New("max concurrent evaluations must not be negative")
+	}
+	if c.EvaluationJitter < 0 {
+		return errors.New("evaluation jitter must not be negative")
+	}
+
 	return nil
 }
 
@@ -0,0 +1,123 @@
+package ruler
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+)
+
+type evaluationMetrics struct {
+	slotWait *prometheus.HistogramVec
+	late     *prometheus.CounterVec
+}
+
+func newEvaluationMetrics(r prometheus.Registerer) *evaluationMetrics {
+	return &evaluationMetrics{
+		slotWait: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "loki",
+			Name:      "ruler_evaluation_slot_wait_seconds",
+			Help:      "Time rule evaluations waited for one of the concurrent evaluation slots of their tenant.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 4, 8),
+		}, []string{"tenant"}),
+		late: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "ruler_evaluations_late_total",
+			Help:      "Total number of rule evaluations started later than the late evaluation threshold after their scheduled time.",
+		}, []string{"tenant"}),
+	}
+}
+
+// evaluationLimiter controls when the rules of a tenant are evaluated: it delays the
+// evaluations of a group by a jitter, bounds the number of concurrent evaluations,
+// and reports the evaluations starting late.
+type evaluationLimiter struct {
+	userID        string
+	slots         chan struct{}
+	jitter        time.Duration
+	lateThreshold time.Duration
+	metrics       *evaluationMetrics
+	logger        log.Logger
+}
+
+func newEvaluationLimiter(cfg Config, userID string, metrics *evaluationMetrics, logger log.Logger) *evaluationLimiter {
+	l := &evaluationLimiter{
+		userID:        userID,
+		jitter:        cfg.EvaluationJitter,
+		lateThreshold: cfg.EvaluationLateThreshold,
+		metrics:       metrics,
+		logger:        logger,
+	}
+	if cfg.MaxConcurrentEvaluations > 0 {
+		l.slots = make(chan struct{}, cfg.MaxConcurrentEvaluations)
+	}
+	return l
+}
+
+// wrap returns a query function evaluating the rules with the limits of the tenant.
+func (l *evaluationLimiter) wrap(next rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		group := ruleGroup(ctx)
+
+		// The rules of a group are evaluated in sequence with the same timestamp, only the first
+		// one waits for the jitter of the iteration.
+		delay := l.delay(group, t)
+		if wait := time.Until(t.Add(delay)); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+
+		if l.slots != nil {
+			start := time.Now()
+			select {
+			case l.slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			defer func() { <-l.slots }()
+			l.metrics.slotWait.WithLabelValues(l.userID).Observe(time.Since(start).Seconds())
+		}
+
+		if late := time.Since(t) - delay; l.lateThreshold > 0 && late > l.lateThreshold {
+			l.metrics.late.WithLabelValues(l.userID).Inc()
+			level.Warn(l.logger).Log("msg", "rule evaluation started late", "rule_group", group, "scheduled", t, "late", late)
+		}
+		return next(ctx, qs, t)
+	}
+}
+
+// delay returns the jitter of an iteration of a group, it is the same for every rule of
+// the iteration.
+func (l *evaluationLimiter) delay(group string, t time.Time) time.Duration {
+	if l.jitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(group))
+	_, _ = h.Write([]byte(t.String()))
+	return time.Duration(h.Sum64() % uint64(l.jitter))
+}
+
+// ruleGroup returns the key of the rule group evaluated, as set in the context by the rules manager.
+func ruleGroup(ctx context.Context) string {
+	origin, ok := ctx.Value(promql.QueryOrigin{}).(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	group, ok := origin["ruleGroup"].(map[string]string)
+	if !ok {
+		return ""
+	}
+	return rules.GroupKey(group["file"], group["name"])
+}
@@ -0,0 +1,102 @@
+package ruler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func groupContext(file, name string) context.Context {
+	return promql.NewOriginContext(context.Background(), map[string]interface{}{
+		"ruleGroup": map[string]string{"file": file, "name": name},
+	})
+}
+
+func Test_evaluationLimiter_MaxConcurrentEvaluations(t *testing.T) {
+	l := newEvaluationLimiter(Config{MaxConcurrentEvaluations: 2}, "fake", newEvaluationMetrics(prometheus.NewRegistry()), log.NewNopLogger())
+
+	var running, maxRunning atomic.Int32
+	queryFunc := l.wrap(func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		n := running.Inc()
+		for {
+			max := maxRunning.Load()
+			if n <= max || maxRunning.CAS(max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Dec()
+		return nil, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := queryFunc(groupContext("file", "group"), "", time.Now())
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(2), maxRunning.Load())
+}
+
+func Test_evaluationLimiter_Jitter(t *testing.T) {
+	l := newEvaluationLimiter(Config{EvaluationJitter: time.Minute}, "fake", newEvaluationMetrics(prometheus.NewRegistry()), log.NewNopLogger())
+	now := time.Now()
+
+	// The rules of an iteration of a group have the same delay.
+	require.Equal(t, l.delay("file;group", now), l.delay("file;group", now))
+	delays := map[time.Duration]struct{}{}
+	for i := 0; i < 10; i++ {
+		d := l.delay("file;group", now.Add(time.Duration(i)*time.Minute))
+		require.GreaterOrEqual(t, d, time.Duration(0))
+		require.Less(t, d, time.Minute)
+		delays[d] = struct{}{}
+	}
+	require.Greater(t, len(delays), 1)
+
+	// An evaluation waits for its delay.
+	l.jitter = 50 * time.Millisecond
+	ts := time.Now()
+	queryFunc := l.wrap(func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		return nil, nil
+	})
+	_, err := queryFunc(groupContext("file", "group"), "", ts)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(ts), l.delay("file;group", ts))
+
+	// Stopped evaluations don't wait.
+	ctx, cancel := context.WithCancel(groupContext("file", "group"))
+	cancel()
+	_, err = queryFunc(ctx, "", time.Now().Add(time.Hour))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_evaluationLimiter_Late(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	l := newEvaluationLimiter(Config{EvaluationLateThreshold: time.Minute}, "fake", newEvaluationMetrics(reg), log.NewNopLogger())
+	queryFunc := l.wrap(func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		return nil, nil
+	})
+
+	_, err := queryFunc(groupContext("file", "group"), "", time.Now())
+	require.NoError(t, err)
+	_, err = queryFunc(groupContext("file", "group"), "", time.Now().Add(-2*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, float64(1), testutil.ToFloat64(l.metrics.late.WithLabelValues("fake")))
+}
+
+func Test_ruleGroup(t *testing.T) {
+	require.Equal(t, "file;group", ruleGroup(groupContext("file", "group")))
+	require.Equal(t, "", ruleGroup(context.Background()))
+}
@@ -1,9 +1,31 @@
 package runtime
 
+import (
+	"github.com/grafana/dskit/flagext"
+
+	"github.com/grafana/loki/pkg/storage/chunk/aws"
+)
+
 type Config struct {
 	LogStreamCreation     bool `yaml:"log_stream_creation"`
 	LogPushRequest        bool `yaml:"log_push_request"`
 	LogPushRequestStreams bool `yaml:"log_push_request_streams"`
+
+	// S3Storage directs the chunks and the boltdb-shipper index of the tenant to its own
+	// S3 bucket instead of the stores configured for the schema period.
+	S3Storage *S3Config `yaml:"s3_storage"`
+}
+
+// S3Config is a S3 storage configuration which starts from the default values
+// of the S3 storage flags, so only the bucket and credentials need to be given.
+type S3Config struct {
+	aws.S3Config `yaml:",inline"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *S3Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	flagext.DefaultValues(&c.S3Config)
+	return unmarshal(&c.S3Config)
 }
 
 // TenantConfig is a function that returns configs for given tenant, or
@@ -54,3 +76,13 @@ func (o *TenantConfigs) LogPushRequest(userID string) bool {
 func (o *TenantConfigs) LogPushRequestStreams(userID string) bool {
 	return o.getOverridesForUser(userID).LogPushRequestStreams
 }
+
+// S3Storage returns the S3 configuration the chunks and index of the tenant are stored in,
+// or nil if the tenant uses the default stores.
+func (o *TenantConfigs) S3Storage(userID string) *aws.S3Config {
+	cfg := o.getOverridesForUser(userID).S3Storage
+	if cfg == nil {
+		return nil
+	}
+	return &cfg.S3Config
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/logql/log"
+	"github.com/grafana/loki/pkg/logqlmodel/memory"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/pkg/storage/chunk"
 )
@@ -85,6 +86,9 @@ type batchChunkIterator struct {
 	metrics         *ChunkMetrics
 	matchers        []*labels.Matcher
 	chunkFilterer   ChunkFilterer
+	memory          *memory.Tracker
+	// held is the size of the chunks of the batch being iterated, accounted to the memory tracker.
+	held int64
 
 	begun      bool
 	ctx        context.Context
@@ -119,6 +123,7 @@ func newBatchChunkIterator(
 		chunks:        lazyChunks{direction: direction, chunks: chunks},
 		next:          make(chan *chunkBatch),
 		chunkFilterer: chunkFilterer,
+		memory:        memory.FromContext(ctx),
 	}
 	sort.Sort(res.chunks)
 	return res
@@ -138,18 +143,32 @@ func (it *batchChunkIterator) loop() {
 			close(it.next)
 			return
 		}
+		batch := it.nextBatch()
 		select {
 		case <-it.ctx.Done():
+			it.memory.Release(batch.size)
 			close(it.next)
 			return
-		case it.next <- it.nextBatch():
+		case it.next <- batch:
 		}
 	}
 }
 
 func (it *batchChunkIterator) Next() *chunkBatch {
 	it.Start() // Ensure the iterator has started.
-	return <-it.next
+	// The chunks of the previous batch are not iterated anymore.
+	it.releaseBatch()
+	batch := <-it.next
+	if batch != nil {
+		it.held = batch.size
+	}
+	return batch
+}
+
+// releaseBatch releases the memory of the batch being iterated.
+func (it *batchChunkIterator) releaseBatch() {
+	it.memory.Release(it.held)
+	it.held = 0
 }
 
 func (it *batchChunkIterator) nextBatch() (res *chunkBatch) {
@@ -284,18 +303,41 @@ func (it *batchChunkIterator) nextBatch() (res *chunkBatch) {
 	if err != nil {
 		return &chunkBatch{err: err}
 	}
+	size := chunksSize(chksBySeries)
+	if err := it.memory.Add(size); err != nil {
+		it.memory.Release(size)
+		return &chunkBatch{err: err}
+	}
 	return &chunkBatch{
 		chunksBySeries: chksBySeries,
 		err:            err,
 		from:           from,
 		through:        through,
 		nextChunk:      nextChunk,
+		size:           size,
 	}
 }
 
+// chunksSize returns the size of the loaded chunks.
+func chunksSize(chksBySeries map[model.Fingerprint][][]*LazyChunk) int64 {
+	var size int64
+	for _, chks := range chksBySeries {
+		for _, overlapping := range chks {
+			for _, c := range overlapping {
+				if c.Chunk.Data != nil {
+					size += int64(c.Chunk.Data.Size())
+				}
+			}
+		}
+	}
+	return size
+}
+
 type chunkBatch struct {
 	chunksBySeries map[model.Fingerprint][][]*LazyChunk
 	err            error
+	// size is the size of the chunks of the batch, accounted to the memory tracker.
+	size int64
 
 	from, through time.Time
 	nextChunk     *LazyChunk
@@ -343,6 +385,10 @@ func (it *logBatchIterator) Error() error {
 		return it.curr.Error()
 	}
 	if it.ctx.Err() != nil {
+		// the query may have been cancelled because it exceeded its memory budget.
+		if err := it.memory.Err(); err != nil {
+			return err
+		}
 		return it.ctx.Err()
 	}
 	return nil
@@ -350,6 +396,7 @@ func (it *logBatchIterator) Error() error {
 
 func (it *logBatchIterator) Close() error {
 	it.cancel()
+	it.releaseBatch()
 	if it.curr != nil {
 		return it.curr.Close()
 	}
@@ -481,6 +528,10 @@ func (it *sampleBatchIterator) Error() error {
 		return it.curr.Error()
 	}
 	if it.ctx.Err() != nil {
+		// the query may have been cancelled because it exceeded its memory budget.
+		if err := it.memory.Err(); err != nil {
+			return err
+		}
 		return it.ctx.Err()
 	}
 	return nil
@@ -488,6 +539,7 @@ func (it *sampleBatchIterator) Error() error {
 
 func (it *sampleBatchIterator) Close() error {
 	it.cancel()
+	it.releaseBatch()
 	if it.curr != nil {
 		return it.curr.Close()
 	}
@@ -18,6 +18,8 @@ import (
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/logql/log"
+	"github.com/grafana/loki/pkg/logqlmodel"
+	"github.com/grafana/loki/pkg/logqlmodel/memory"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/pkg/storage/chunk"
 )
@@ -1632,6 +1634,47 @@ func TestBatchCancel(t *testing.T) {
 	require.Equal(t, context.Canceled, it.Error())
 }
 
+func TestBatchMemory(t *testing.T) {
+	chunk := func(from time.Time) *LazyChunk {
+		return newLazyChunk(logproto.Stream{
+			Labels: fooLabelsWithName,
+			Entries: []logproto.Entry{
+				{
+					Timestamp: from,
+					Line:      "1",
+				},
+				{
+					Timestamp: from.Add(time.Millisecond),
+					Line:      "2",
+				},
+			},
+		})
+	}
+	chunks := []*LazyChunk{
+		chunk(from), chunk(from.Add(10 * time.Millisecond)), chunk(from.Add(30 * time.Millisecond)),
+	}
+	chunkSize := int64(chunks[0].Chunk.Data.Size())
+
+	tracker, ctx := memory.NewContext(context.Background(), 0)
+	it, err := newLogBatchIterator(ctx, NilMetrics, chunks, 1, newMatchers(fooLabels), log.NewNoopPipeline(), logproto.FORWARD, from, time.Now(), nil)
+	require.NoError(t, err)
+	for it.Next() {
+	}
+	require.NoError(t, it.Error())
+	require.NoError(t, it.Close())
+	require.Equal(t, int64(0), tracker.Used())
+	require.GreaterOrEqual(t, tracker.Peak(), chunkSize)
+
+	// the query is cancelled when the chunks exceed the memory budget.
+	tracker, ctx = memory.NewContext(context.Background(), chunkSize-1)
+	it, err = newLogBatchIterator(ctx, NilMetrics, chunks, 1, newMatchers(fooLabels), log.NewNoopPipeline(), logproto.FORWARD, from, time.Now(), nil)
+	require.NoError(t, err)
+	require.False(t, it.Next())
+	require.True(t, errors.Is(it.Error(), logqlmodel.ErrLimit))
+	require.NoError(t, it.Close())
+	require.Equal(t, tracker.Err(), it.Error())
+}
+
 var entry logproto.Entry
 
 func Benchmark_store_OverlappingChunks(b *testing.B) {
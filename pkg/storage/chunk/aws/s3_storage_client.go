@@ -2,8 +2,10 @@ package aws
 
 import (
 	"context"
+	"crypto/md5"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"hash/fnv"
@@ -45,6 +47,8 @@ const (
 var (
 	supportedSignatureVersions     = []string{SignatureVersionV4, SignatureVersionV2}
 	errUnsupportedSignatureVersion = errors.New("unsupported signature version")
+	supportedObjectLockModes       = []string{s3.ObjectLockModeCompliance, s3.ObjectLockModeGovernance}
+	errUnsupportedObjectLockMode   = errors.New("unsupported object lock mode")
 )
 
 var s3RequestDuration = instrument.NewHistogramCollector(prometheus.NewHistogramVec(prometheus.HistogramOpts{
@@ -78,6 +82,7 @@ type S3Config struct {
 	SignatureVersion string              `yaml:"signature_version"`
 	SSEConfig        cortex_s3.SSEConfig `yaml:"sse"`
 	BackoffConfig    backoff.Config      `yaml:"backoff_config"`
+	ObjectLockMode   string              `yaml:"object_lock_mode"`
 
 	Inject InjectRequestMiddleware `yaml:"-"`
 }
@@ -122,6 +127,7 @@ func (cfg *S3Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.DurationVar(&cfg.BackoffConfig.MinBackoff, prefix+"s3.min-backoff", 100*time.Millisecond, "Minimum backoff time when s3 get Object")
 	f.DurationVar(&cfg.BackoffConfig.MaxBackoff, prefix+"s3.max-backoff", 3*time.Second, "Maximum backoff time when s3 get Object")
 	f.IntVar(&cfg.BackoffConfig.MaxRetries, prefix+"s3.max-retries", 5, "Maximum number of times to retry when s3 get Object")
+	f.StringVar(&cfg.ObjectLockMode, prefix+"s3.object-lock-mode", s3.ObjectLockModeCompliance, fmt.Sprintf("The Object Lock mode of the chunks of the tenants with a compliance retention period. The bucket must have Object Lock enabled. Supported values are: %s.", strings.Join(supportedObjectLockModes, ", ")))
 }
 
 // Validate config and returns error on failure
@@ -129,6 +135,9 @@ func (cfg *S3Config) Validate() error {
 	if !util.StringsContain(supportedSignatureVersions, cfg.SignatureVersion) {
 		return errUnsupportedSignatureVersion
 	}
+	if !util.StringsContain(supportedObjectLockModes, cfg.ObjectLockMode) {
+		return errUnsupportedObjectLockMode
+	}
 	return nil
 }
 
@@ -405,11 +414,33 @@ func (a *S3ObjectClient) PutObject(ctx context.Context, objectKey string, object
 			putObjectInput.SSEKMSEncryptionContext = a.sseConfig.KMSEncryptionContext
 		}
 
+		// the objects locked by the bucket must be uploaded with the MD5 of their content.
+		if until, ok := chunk.RetainUntilFromContext(ctx); ok {
+			md5, err := contentMD5(object)
+			if err != nil {
+				return err
+			}
+			putObjectInput.ContentMD5 = aws.String(md5)
+			putObjectInput.ObjectLockMode = aws.String(a.cfg.ObjectLockMode)
+			putObjectInput.ObjectLockRetainUntilDate = aws.Time(until)
+		}
+
 		_, err := a.S3.PutObjectWithContext(ctx, putObjectInput)
 		return err
 	})
 }
 
+func contentMD5(object io.ReadSeeker) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, object); err != nil {
+		return "", err
+	}
+	if _, err := object.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
 // List implements chunk.ObjectClient.
 func (a *S3ObjectClient) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
 	var storageObjects []chunk.StorageObject
@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
 )
 
 type RoundTripperFunc func(*http.Request) (*http.Response, error)
@@ -75,3 +79,38 @@ func TestRequestMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestPutObjectLock(t *testing.T) {
+	var headers http.Header
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	client, err := NewS3ObjectClient(S3Config{
+		Endpoint:         ts.URL,
+		BucketNames:      "buck-o",
+		S3ForcePathStyle: true,
+		Insecure:         true,
+		AccessKeyID:      "key",
+		SecretAccessKey:  "secret",
+		ObjectLockMode:   "GOVERNANCE",
+	})
+	require.NoError(t, err)
+
+	// the objects aren't locked by default.
+	require.NoError(t, client.PutObject(context.Background(), "key", strings.NewReader("chunk")))
+	require.Empty(t, headers.Get("x-amz-object-lock-mode"))
+	require.Empty(t, headers.Get("x-amz-object-lock-retain-until-date"))
+
+	until := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	ctx := chunk.WithRetainUntil(context.Background(), until)
+	require.NoError(t, client.PutObject(ctx, "key", strings.NewReader("chunk")))
+	require.Equal(t, "GOVERNANCE", headers.Get("x-amz-object-lock-mode"))
+	require.Equal(t, "2030-01-02T03:04:05Z", headers.Get("x-amz-object-lock-retain-until-date"))
+	// base64 of the MD5 of "chunk".
+	require.Equal(t, "Wo9Poq6rVDGIjuihjOO86g==", headers.Get("Content-MD5"))
+	require.Equal(t, "chunk", string(body))
+}
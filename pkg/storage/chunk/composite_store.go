@@ -251,7 +251,7 @@ func (c compositeStore) forStores(ctx context.Context, userID string, from, thro
 		}
 
 		end := min(through, nextSchemaStarts-1)
-		err := callback(ctx, start, end, c.stores[i].Store)
+		err := callback(InjectQueryThrough(ctx, end), start, end, c.stores[i].Store)
 		if err != nil {
 			return err
 		}
@@ -3,7 +3,9 @@ package gcp
 import (
 	"context"
 	"flag"
+	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -20,6 +22,9 @@ type GCSObjectClient struct {
 	cfg    GCSConfig
 	client *storage.Client
 	bucket *storage.BucketHandle
+
+	retentionMtx    sync.Mutex
+	retentionPeriod *time.Duration
 }
 
 // GCSConfig is config for the GCS Chunk Client.
@@ -112,6 +117,12 @@ func (s *GCSObjectClient) getObject(ctx context.Context, objectKey string) (rc i
 
 // PutObject puts the specified bytes into the configured GCS bucket at the provided key
 func (s *GCSObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	if until, ok := chunk.RetainUntilFromContext(ctx); ok {
+		if err := s.checkRetention(ctx, until); err != nil {
+			return err
+		}
+	}
+
 	writer := s.bucket.Object(objectKey).NewWriter(ctx)
 	// Default GCSChunkSize is 8M and for each call, 8M is allocated xD
 	// By setting it to 0, we just upload the object in a single a request
@@ -125,6 +136,30 @@ func (s *GCSObjectClient) PutObject(ctx context.Context, objectKey string, objec
 	return writer.Close()
 }
 
+// checkRetention returns an error if the retention policy of the bucket doesn't retain the objects
+// put now until the given time. GCS doesn't lock the objects one by one, but retains all of them
+// for the retention period of the bucket since their creation.
+func (s *GCSObjectClient) checkRetention(ctx context.Context, until time.Time) error {
+	s.retentionMtx.Lock()
+	defer s.retentionMtx.Unlock()
+
+	if s.retentionPeriod == nil {
+		attrs, err := s.bucket.Attrs(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to get the retention policy of the bucket")
+		}
+		var period time.Duration
+		if attrs.RetentionPolicy != nil {
+			period = attrs.RetentionPolicy.RetentionPeriod
+		}
+		s.retentionPeriod = &period
+	}
+	if required := time.Until(until); *s.retentionPeriod < required {
+		return fmt.Errorf("the retention period of the bucket %s is %s, the objects must be retained for %s", s.cfg.BucketName, *s.retentionPeriod, required.Round(time.Second))
+	}
+	return nil
+}
+
 // List implements chunk.ObjectClient.
 func (s *GCSObjectClient) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
 	var storageObjects []chunk.StorageObject
@@ -0,0 +1,21 @@
+package chunk
+
+import (
+	"context"
+	"time"
+)
+
+type retainUntilKey struct{}
+
+// WithRetainUntil returns a context for the objects put with it to be locked until the given
+// time, by the object clients supporting it, so that they can't be deleted before.
+func WithRetainUntil(ctx context.Context, until time.Time) context.Context {
+	return context.WithValue(ctx, retainUntilKey{}, until)
+}
+
+// RetainUntilFromContext returns the time until which the objects put with the context must be
+// locked, if any.
+func RetainUntilFromContext(ctx context.Context) (time.Time, bool) {
+	until, ok := ctx.Value(retainUntilKey{}).(time.Time)
+	return until, ok
+}
@@ -0,0 +1,228 @@
+package objectclient
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sony/gobreaker"
+	"github.com/weaveworks/common/httpgrpc"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+const (
+	shedReasonBudget  = "budget"
+	shedReasonCircuit = "circuit_open"
+)
+
+var (
+	shedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Name:      "object_store_shed_requests_total",
+		Help:      "Total number of object store requests shed, by backend and reason.",
+	}, []string{"backend", "reason"})
+	circuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "loki",
+		Name:      "object_store_circuit_breaker_state",
+		Help:      "State of the object store circuit breaker, by backend: 0 closed, 1 half-open, 2 open.",
+	}, []string{"backend"})
+)
+
+// BudgetConfig configures the request budget and circuit breaker guarding an object store backend.
+type BudgetConfig struct {
+	RequestsPerSecond float64       `yaml:"requests_per_second"`
+	Burst             int           `yaml:"burst"`
+	MaxWait           time.Duration `yaml:"max_wait"`
+
+	CBFailures uint          `yaml:"circuit_breaker_consecutive_failures"`
+	CBTimeout  time.Duration `yaml:"circuit_breaker_timeout"`  // remain open for this long after CBFailures errors
+	CBInterval time.Duration `yaml:"circuit_breaker_interval"` // reset error count after this long
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet.
+func (cfg *BudgetConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.Float64Var(&cfg.RequestsPerSecond, prefix+"requests-per-second", 0, "Maximum rate of requests sent to each object store backend. 0 disables the budget.")
+	f.IntVar(&cfg.Burst, prefix+"burst", 100, "Number of requests above the rate allowed in a burst.")
+	f.DurationVar(&cfg.MaxWait, prefix+"max-wait", time.Second, "Maximum time a request waits for the budget before being shed.")
+	f.UintVar(&cfg.CBFailures, prefix+"circuit-breaker-consecutive-failures", 0, "Trip the circuit-breaker of a backend after this number of consecutive failed requests (if zero then circuit-breaker is disabled).")
+	f.DurationVar(&cfg.CBTimeout, prefix+"circuit-breaker-timeout", 10*time.Second, "Duration circuit-breaker remains open after tripping (if zero then 60 seconds is used).")
+	f.DurationVar(&cfg.CBInterval, prefix+"circuit-breaker-interval", 10*time.Second, "Reset circuit-breaker counts after this long (if zero then never reset).")
+}
+
+// backendGuard is shared by every client of a backend, so that the budget
+// applies to the backend as a whole.
+type backendGuard struct {
+	backend string
+
+	// mtx guards the fields below, which are replaced when the config is reloaded.
+	mtx     sync.RWMutex
+	cfg     BudgetConfig
+	limiter *rate.Limiter
+	cb      *gobreaker.TwoStepCircuitBreaker
+}
+
+var (
+	guardsMtx sync.Mutex
+	guards    = map[string]*backendGuard{}
+	// reloaded is the config set by SetBudgetConfig, it overrides the config of the clients.
+	reloaded *BudgetConfig
+)
+
+func guardFor(backend string, cfg BudgetConfig) *backendGuard {
+	guardsMtx.Lock()
+	defer guardsMtx.Unlock()
+
+	if g, ok := guards[backend]; ok {
+		return g
+	}
+	if reloaded != nil {
+		cfg = *reloaded
+	}
+	g := &backendGuard{backend: backend}
+	g.setConfig(cfg)
+	guards[backend] = g
+	return g
+}
+
+// SetBudgetConfig applies cfg to the clients of every backend, replacing the config they were created with.
+// The state of the circuit-breakers is reset if their config changes.
+func SetBudgetConfig(cfg BudgetConfig) {
+	guardsMtx.Lock()
+	defer guardsMtx.Unlock()
+
+	reloaded = &cfg
+	for _, g := range guards {
+		g.setConfig(cfg)
+	}
+}
+
+func (g *backendGuard) setConfig(cfg BudgetConfig) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	switch {
+	case cfg.RequestsPerSecond <= 0:
+		g.limiter = nil
+	case g.limiter == nil:
+		g.limiter = rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), cfg.Burst)
+	default:
+		g.limiter.SetLimit(rate.Limit(cfg.RequestsPerSecond))
+		g.limiter.SetBurst(cfg.Burst)
+	}
+
+	switch {
+	case cfg.CBFailures == 0:
+		g.cb = nil
+	case g.cb == nil || cfg.CBFailures != g.cfg.CBFailures || cfg.CBTimeout != g.cfg.CBTimeout || cfg.CBInterval != g.cfg.CBInterval:
+		g.cb = gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+			Name:     g.backend,
+			Interval: cfg.CBInterval,
+			Timeout:  cfg.CBTimeout,
+			OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+				level.Info(util_log.Logger).Log("msg", "object store circuit-breaker state change", "backend", name, "from-state", from, "to-state", to)
+				circuitState.WithLabelValues(name).Set(float64(to))
+			},
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return uint(counts.ConsecutiveFailures) >= cfg.CBFailures
+			},
+		})
+		circuitState.WithLabelValues(g.backend).Set(float64(gobreaker.StateClosed))
+	}
+	g.cfg = cfg
+}
+
+func (g *backendGuard) get() (*rate.Limiter, time.Duration, *gobreaker.TwoStepCircuitBreaker) {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	return g.limiter, g.cfg.MaxWait, g.cb
+}
+
+// budgetedObjectClient sheds requests to an object store backend once its
+// request budget is exhausted or its circuit-breaker is open. Shed requests
+// fail with a 503 so that they are retried by the query frontend.
+type budgetedObjectClient struct {
+	chunk.ObjectClient
+	backend string
+	guard   *backendGuard
+}
+
+// NewBudgetedObjectClient wraps client with the request budget and circuit-breaker of backend.
+// Requests go through as is while neither is enabled, they can be enabled with SetBudgetConfig.
+func NewBudgetedObjectClient(backend string, client chunk.ObjectClient, cfg BudgetConfig) chunk.ObjectClient {
+	return &budgetedObjectClient{
+		ObjectClient: client,
+		backend:      backend,
+		guard:        guardFor(backend, cfg),
+	}
+}
+
+func (c *budgetedObjectClient) do(ctx context.Context, f func() error) error {
+	limiter, maxWait, cb := c.guard.get()
+	if limiter != nil {
+		waitCtx, cancel := context.WithTimeout(ctx, maxWait)
+		err := limiter.Wait(waitCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			shedRequests.WithLabelValues(c.backend, shedReasonBudget).Inc()
+			return httpgrpc.Errorf(http.StatusServiceUnavailable, "%s object store request budget exhausted", c.backend)
+		}
+	}
+
+	if cb == nil {
+		return f()
+	}
+	done, err := cb.Allow()
+	if err != nil {
+		shedRequests.WithLabelValues(c.backend, shedReasonCircuit).Inc()
+		return httpgrpc.Errorf(http.StatusServiceUnavailable, "%s object store circuit-breaker is open: %v", c.backend, err)
+	}
+	err = f()
+	// Missing objects and cancelled requests say nothing about the health of the backend.
+	done(err == nil || c.IsObjectNotFoundErr(err) || ctx.Err() != nil)
+	return err
+}
+
+func (c *budgetedObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	return c.do(ctx, func() error {
+		return c.ObjectClient.PutObject(ctx, objectKey, object)
+	})
+}
+
+func (c *budgetedObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	err := c.do(ctx, func() (err error) {
+		rc, err = c.ObjectClient.GetObject(ctx, objectKey)
+		return err
+	})
+	return rc, err
+}
+
+func (c *budgetedObjectClient) List(ctx context.Context, prefix string, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+	var (
+		objects  []chunk.StorageObject
+		prefixes []chunk.StorageCommonPrefix
+	)
+	err := c.do(ctx, func() (err error) {
+		objects, prefixes, err = c.ObjectClient.List(ctx, prefix, delimiter)
+		return err
+	})
+	return objects, prefixes, err
+}
+
+func (c *budgetedObjectClient) DeleteObject(ctx context.Context, objectKey string) error {
+	return c.do(ctx, func() error {
+		return c.ObjectClient.DeleteObject(ctx, objectKey)
+	})
+}
@@ -0,0 +1,116 @@
+package objectclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+type failingObjectClient struct {
+	chunk.ObjectClient
+	err   error
+	calls int
+}
+
+func (f *failingObjectClient) DeleteObject(_ context.Context, _ string) error {
+	f.calls++
+	return f.err
+}
+
+func requireShed(t *testing.T, err error) {
+	t.Helper()
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok, "expected a httpgrpc error, got %v", err)
+	require.Equal(t, int32(http.StatusServiceUnavailable), resp.Code)
+}
+
+func TestBudgetedObjectClient_Disabled(t *testing.T) {
+	inner := &failingObjectClient{ObjectClient: chunk.NewMockStorage(), err: errors.New("503 slow down")}
+	client := NewBudgetedObjectClient("disabled", inner, BudgetConfig{})
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		require.EqualError(t, client.DeleteObject(ctx, "a"), "503 slow down")
+	}
+	require.Equal(t, 10, inner.calls)
+}
+
+func TestBudgetedObjectClient_Budget(t *testing.T) {
+	client := NewBudgetedObjectClient("budget", chunk.NewMockStorage(), BudgetConfig{
+		RequestsPerSecond: 0.001,
+		Burst:             2,
+		MaxWait:           10 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	require.NoError(t, client.PutObject(ctx, "a", bytes.NewReader([]byte("a"))))
+	_, err := client.GetObject(ctx, "a")
+	require.NoError(t, err)
+	_, err = client.GetObject(ctx, "a")
+	requireShed(t, err)
+}
+
+func TestBudgetedObjectClient_CircuitBreaker(t *testing.T) {
+	inner := &failingObjectClient{ObjectClient: chunk.NewMockStorage(), err: errors.New("503 slow down")}
+	client := NewBudgetedObjectClient("circuit", inner, BudgetConfig{
+		CBFailures: 2,
+		CBTimeout:  time.Minute,
+	})
+	ctx := context.Background()
+
+	require.EqualError(t, client.DeleteObject(ctx, "a"), "503 slow down")
+	require.EqualError(t, client.DeleteObject(ctx, "a"), "503 slow down")
+
+	// The circuit is open, requests are shed without reaching the backend.
+	requireShed(t, client.DeleteObject(ctx, "a"))
+	require.Equal(t, 2, inner.calls)
+}
+
+func TestBudgetedObjectClient_NotFoundIsNotAFailure(t *testing.T) {
+	client := NewBudgetedObjectClient("not-found", chunk.NewMockStorage(), BudgetConfig{
+		CBFailures: 1,
+		CBTimeout:  time.Minute,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetObject(ctx, "missing")
+		require.True(t, client.IsObjectNotFoundErr(err))
+	}
+}
+
+func TestSetBudgetConfig(t *testing.T) {
+	defer func() {
+		guardsMtx.Lock()
+		reloaded = nil
+		guardsMtx.Unlock()
+	}()
+	inner := &failingObjectClient{ObjectClient: chunk.NewMockStorage(), err: errors.New("503 slow down")}
+	client := NewBudgetedObjectClient("reloaded", inner, BudgetConfig{})
+	ctx := context.Background()
+
+	require.EqualError(t, client.DeleteObject(ctx, "a"), "503 slow down")
+
+	// The circuit-breaker is enabled by the reload.
+	SetBudgetConfig(BudgetConfig{CBFailures: 1, CBTimeout: time.Minute})
+	require.EqualError(t, client.DeleteObject(ctx, "a"), "503 slow down")
+	requireShed(t, client.DeleteObject(ctx, "a"))
+
+	// Clients created after the reload use the reloaded config.
+	other := NewBudgetedObjectClient("reloaded-other", inner, BudgetConfig{})
+	require.EqualError(t, other.DeleteObject(ctx, "a"), "503 slow down")
+	requireShed(t, other.DeleteObject(ctx, "a"))
+
+	// The circuit-breaker is disabled again.
+	SetBudgetConfig(BudgetConfig{})
+	require.EqualError(t, client.DeleteObject(ctx, "a"), "503 slow down")
+	require.Equal(t, 4, inner.calls)
+}
@@ -0,0 +1,21 @@
+package chunk
+
+import (
+	"context"
+
+	"github.com/prometheus/common/model"
+)
+
+type queryThroughContextKey struct{}
+
+// InjectQueryThrough returns a derived context holding the end of the time range queried,
+// so that the index clients can tell which index files the query needs.
+func InjectQueryThrough(ctx context.Context, through model.Time) context.Context {
+	return context.WithValue(ctx, queryThroughContextKey{}, through)
+}
+
+// ExtractQueryThrough gets the end of the time range queried from the context.
+func ExtractQueryThrough(ctx context.Context) (model.Time, bool) {
+	through, ok := ctx.Value(queryThroughContextKey{}).(model.Time)
+	return through, ok
+}
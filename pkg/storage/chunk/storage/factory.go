@@ -95,6 +95,11 @@ type Config struct {
 	DisableBroadIndexQueries bool         `yaml:"disable_broad_index_queries"`
 
 	GrpcConfig grpc.Config `yaml:"grpc_store"`
+
+	ObjectStoreBudget objectclient.BudgetConfig `yaml:"object_store_budget"`
+
+	// TenantS3Storage optionally returns a per tenant S3 bucket to store chunks and index in.
+	TenantS3Storage TenantS3Storage `yaml:"-"`
 }
 
 // RegisterFlags adds the flags required to configure this flag set.
@@ -108,6 +113,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.FSConfig.RegisterFlags(f)
 	cfg.Swift.RegisterFlags(f)
 	cfg.GrpcConfig.RegisterFlags(f)
+	cfg.ObjectStoreBudget.RegisterFlagsWithPrefix("store.object-budget.", f)
 
 	f.StringVar(&cfg.Engine, "store.engine", "chunks", "The storage engine to use: chunks or blocks.")
 	cfg.IndexQueriesCacheConfig.RegisterFlagsWithPrefix("store.index-cache-read.", "Cache config for index entry reading.", f)
@@ -211,6 +217,9 @@ func NewStore(
 			return nil, errors.Wrap(err, "error creating object client")
 		}
 
+		if cfg.TenantS3Storage != nil {
+			chunks = newTenantChunkClient(chunks, cfg.TenantS3Storage)
+		}
 		chunks = newMetricsChunkClient(chunks, chunkMetrics)
 
 		err = stores.AddPeriod(storeCfg, s, index, chunks, limits, chunksCache, writeDedupeCache)
@@ -267,7 +276,7 @@ func NewChunkClient(name string, cfg Config, schemaCfg chunk.SchemaConfig, regis
 	case StorageTypeInMemory:
 		return chunk.NewMockStorage(), nil
 	case StorageTypeAWS, StorageTypeS3:
-		return newChunkClientFromStore(aws.NewS3ObjectClient(cfg.AWSStorageConfig.S3Config))
+		return newChunkClientFromStore(cfg.budgeted(StorageTypeS3)(aws.NewS3ObjectClient(cfg.AWSStorageConfig.S3Config)))
 	case StorageTypeAWSDynamo:
 		if cfg.AWSStorageConfig.DynamoDB.URL == nil {
 			return nil, fmt.Errorf("Must set -dynamodb.url in aws mode")
@@ -278,15 +287,15 @@ func NewChunkClient(name string, cfg Config, schemaCfg chunk.SchemaConfig, regis
 		}
 		return aws.NewDynamoDBChunkClient(cfg.AWSStorageConfig.DynamoDBConfig, schemaCfg, registerer)
 	case StorageTypeAzure:
-		return newChunkClientFromStore(azure.NewBlobStorage(&cfg.AzureStorageConfig))
+		return newChunkClientFromStore(cfg.budgeted(StorageTypeAzure)(azure.NewBlobStorage(&cfg.AzureStorageConfig)))
 	case StorageTypeGCP:
 		return gcp.NewBigtableObjectClient(context.Background(), cfg.GCPStorageConfig, schemaCfg)
 	case StorageTypeGCPColumnKey, StorageTypeBigTable, StorageTypeBigTableHashed:
 		return gcp.NewBigtableObjectClient(context.Background(), cfg.GCPStorageConfig, schemaCfg)
 	case StorageTypeGCS:
-		return newChunkClientFromStore(gcp.NewGCSObjectClient(context.Background(), cfg.GCSConfig))
+		return newChunkClientFromStore(cfg.budgeted(StorageTypeGCS)(gcp.NewGCSObjectClient(context.Background(), cfg.GCSConfig)))
 	case StorageTypeSwift:
-		return newChunkClientFromStore(openstack.NewSwiftObjectClient(cfg.Swift))
+		return newChunkClientFromStore(cfg.budgeted(StorageTypeSwift)(openstack.NewSwiftObjectClient(cfg.Swift)))
 	case StorageTypeCassandra:
 		return cassandra.NewObjectClient(cfg.CassandraStorageConfig, schemaCfg, registerer)
 	case StorageTypeFileSystem:
@@ -302,6 +311,17 @@ func NewChunkClient(name string, cfg Config, schemaCfg chunk.SchemaConfig, regis
 	}
 }
 
+// budgeted returns a function guarding the object client of a remote backend with
+// the object store request budget and circuit-breaker.
+func (cfg *Config) budgeted(backend string) func(chunk.ObjectClient, error) (chunk.ObjectClient, error) {
+	return func(client chunk.ObjectClient, err error) (chunk.ObjectClient, error) {
+		if err != nil {
+			return nil, err
+		}
+		return objectclient.NewBudgetedObjectClient(backend, client, cfg.ObjectStoreBudget), nil
+	}
+}
+
 func newChunkClientFromStore(store chunk.ObjectClient, err error) (chunk.Client, error) {
 	if err != nil {
 		return nil, err
@@ -355,13 +375,13 @@ func NewBucketClient(storageConfig Config) (chunk.BucketClient, error) {
 func NewObjectClient(name string, cfg Config) (chunk.ObjectClient, error) {
 	switch name {
 	case StorageTypeAWS, StorageTypeS3:
-		return aws.NewS3ObjectClient(cfg.AWSStorageConfig.S3Config)
+		return cfg.budgeted(StorageTypeS3)(aws.NewS3ObjectClient(cfg.AWSStorageConfig.S3Config))
 	case StorageTypeGCS:
-		return gcp.NewGCSObjectClient(context.Background(), cfg.GCSConfig)
+		return cfg.budgeted(StorageTypeGCS)(gcp.NewGCSObjectClient(context.Background(), cfg.GCSConfig))
 	case StorageTypeAzure:
-		return azure.NewBlobStorage(&cfg.AzureStorageConfig)
+		return cfg.budgeted(StorageTypeAzure)(azure.NewBlobStorage(&cfg.AzureStorageConfig))
 	case StorageTypeSwift:
-		return openstack.NewSwiftObjectClient(cfg.Swift)
+		return cfg.budgeted(StorageTypeSwift)(openstack.NewSwiftObjectClient(cfg.Swift))
 	case StorageTypeInMemory:
 		return chunk.NewMockStorage(), nil
 	case StorageTypeFileSystem:
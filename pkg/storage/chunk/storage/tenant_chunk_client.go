@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/aws"
+)
+
+// tenantChunkClient routes chunk operations of tenants bringing their own bucket to a
+// dedicated client, and all other tenants to the default client.
+type tenantChunkClient struct {
+	chunk.Client
+
+	tenants *tenantClients
+}
+
+func newTenantChunkClient(client chunk.Client, overrides TenantS3Storage) *tenantChunkClient {
+	return &tenantChunkClient{
+		Client: client,
+		tenants: newTenantClients(overrides, func(_ string, cfg aws.S3Config) (stopper, error) {
+			return newChunkClientFromStore(aws.NewS3ObjectClient(cfg))
+		}),
+	}
+}
+
+// clientFor returns the client to use for the given tenant, and the function to call once
+// the client is no longer used.
+func (c *tenantChunkClient) clientFor(userID string) (chunk.Client, func(), error) {
+	client, release, err := c.tenants.clientFor(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client == nil {
+		return c.Client, release, nil
+	}
+	return client.(chunk.Client), release, nil
+}
+
+// groupByClient splits chunks by the client they belong to. The returned function releases
+// the clients once the groups are processed.
+func (c *tenantChunkClient) groupByClient(chunks []chunk.Chunk) (map[chunk.Client][]chunk.Chunk, func(), error) {
+	var releases []func()
+	release := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	clients := map[string]chunk.Client{}
+	result := map[chunk.Client][]chunk.Chunk{}
+	for _, chk := range chunks {
+		client, ok := clients[chk.UserID]
+		if !ok {
+			var (
+				r   func()
+				err error
+			)
+			client, r, err = c.clientFor(chk.UserID)
+			if err != nil {
+				release()
+				return nil, nil, err
+			}
+			releases = append(releases, r)
+			clients[chk.UserID] = client
+		}
+		result[client] = append(result[client], chk)
+	}
+	return result, release, nil
+}
+
+func (c *tenantChunkClient) PutChunks(ctx context.Context, chunks []chunk.Chunk) error {
+	groups, release, err := c.groupByClient(chunks)
+	if err != nil {
+		return err
+	}
+	defer release()
+	for client, chks := range groups {
+		if err := client.PutChunks(ctx, chks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *tenantChunkClient) GetChunks(ctx context.Context, chunks []chunk.Chunk) ([]chunk.Chunk, error) {
+	groups, release, err := c.groupByClient(chunks)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if len(groups) == 1 {
+		for client, chks := range groups {
+			return client.GetChunks(ctx, chks)
+		}
+	}
+	result := make([]chunk.Chunk, 0, len(chunks))
+	for client, chks := range groups {
+		fetched, err := client.GetChunks(ctx, chks)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, fetched...)
+	}
+	return result, nil
+}
+
+func (c *tenantChunkClient) DeleteChunk(ctx context.Context, userID, chunkID string) error {
+	client, release, err := c.clientFor(userID)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return client.DeleteChunk(ctx, userID, chunkID)
+}
+
+func (c *tenantChunkClient) IsChunkNotFoundErr(err error) bool {
+	if c.Client.IsChunkNotFoundErr(err) {
+		return true
+	}
+	return c.tenants.anyClient(func(client stopper) bool {
+		return client.(chunk.Client).IsChunkNotFoundErr(err)
+	})
+}
+
+func (c *tenantChunkClient) Stop() {
+	c.tenants.Stop()
+	c.Client.Stop()
+}
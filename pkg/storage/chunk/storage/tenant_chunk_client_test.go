@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/aws"
+)
+
+type recordingChunkClient struct {
+	chunk.Client
+	put     []chunk.Chunk
+	deleted []string
+	stopped bool
+}
+
+func (r *recordingChunkClient) PutChunks(_ context.Context, chunks []chunk.Chunk) error {
+	r.put = append(r.put, chunks...)
+	return nil
+}
+
+func (r *recordingChunkClient) GetChunks(_ context.Context, chunks []chunk.Chunk) ([]chunk.Chunk, error) {
+	return chunks, nil
+}
+
+func (r *recordingChunkClient) DeleteChunk(_ context.Context, _, chunkID string) error {
+	r.deleted = append(r.deleted, chunkID)
+	return nil
+}
+
+func (r *recordingChunkClient) Stop() {
+	r.stopped = true
+}
+
+func Test_TenantChunkClient(t *testing.T) {
+	overrides := map[string]*aws.S3Config{
+		"byob": {BucketNames: "byob-bucket"},
+	}
+	defaultClient := &recordingChunkClient{}
+	tenantClients := map[string]*recordingChunkClient{}
+
+	c := newTenantChunkClient(defaultClient, func(userID string) *aws.S3Config {
+		return overrides[userID]
+	})
+	c.tenants.newClient = func(_ string, cfg aws.S3Config) (stopper, error) {
+		client := &recordingChunkClient{}
+		tenantClients[cfg.BucketNames] = client
+		return client, nil
+	}
+
+	chunks := []chunk.Chunk{
+		{UserID: "fake"},
+		{UserID: "byob"},
+		{UserID: "fake"},
+		{UserID: "byob"},
+	}
+	require.NoError(t, c.PutChunks(context.Background(), chunks))
+	require.Len(t, defaultClient.put, 2)
+	require.Len(t, tenantClients["byob-bucket"].put, 2)
+	for _, chk := range tenantClients["byob-bucket"].put {
+		require.Equal(t, "byob", chk.UserID)
+	}
+
+	fetched, err := c.GetChunks(context.Background(), chunks)
+	require.NoError(t, err)
+	require.Len(t, fetched, 4)
+
+	require.NoError(t, c.DeleteChunk(context.Background(), "byob", "foo"))
+	require.Equal(t, []string{"foo"}, tenantClients["byob-bucket"].deleted)
+	require.Empty(t, defaultClient.deleted)
+
+	// changing the tenant configuration re-creates its client.
+	overrides["byob"] = &aws.S3Config{BucketNames: "new-bucket"}
+	require.NoError(t, c.DeleteChunk(context.Background(), "byob", "bar"))
+	require.True(t, tenantClients["byob-bucket"].stopped)
+	require.Equal(t, []string{"bar"}, tenantClients["new-bucket"].deleted)
+
+	// removing the tenant configuration goes back to the default client.
+	delete(overrides, "byob")
+	require.NoError(t, c.DeleteChunk(context.Background(), "byob", "buzz"))
+	require.True(t, tenantClients["new-bucket"].stopped)
+	require.Equal(t, []string{"buzz"}, defaultClient.deleted)
+
+	// a client replaced while in use is stopped once released.
+	overrides["byob"] = &aws.S3Config{BucketNames: "byob-bucket"}
+	client, release, err := c.clientFor("byob")
+	require.NoError(t, err)
+	overrides["byob"] = &aws.S3Config{BucketNames: "new-bucket"}
+	_, releaseNew, err := c.clientFor("byob")
+	require.NoError(t, err)
+	releaseNew()
+	require.False(t, client.(*recordingChunkClient).stopped)
+	release()
+	require.True(t, client.(*recordingChunkClient).stopped)
+
+	c.Stop()
+	require.True(t, defaultClient.stopped)
+}
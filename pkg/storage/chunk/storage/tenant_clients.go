@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/grafana/loki/pkg/storage/chunk/aws"
+)
+
+// TenantS3Storage returns the S3 configuration the chunks and index of a tenant are stored in,
+// or nil if the tenant uses the stores configured for the schema period.
+type TenantS3Storage func(userID string) *aws.S3Config
+
+type stopper interface {
+	Stop()
+}
+
+// tenantClient is the client of a tenant. It is reference counted so that a client replaced
+// by a configuration change is only stopped once the calls using it are done.
+type tenantClient struct {
+	cfg    aws.S3Config
+	client stopper
+
+	// refs and retired are guarded by the mutex of the tenantClients.
+	refs    int
+	retired bool
+}
+
+// tenantClients holds the clients of the tenants bringing their own bucket.
+// Tenant clients are created lazily and re-created when the tenant configuration changes.
+type tenantClients struct {
+	overrides TenantS3Storage
+	newClient func(userID string, cfg aws.S3Config) (stopper, error)
+
+	mtx     sync.Mutex
+	tenants map[string]*tenantClient
+}
+
+func newTenantClients(overrides TenantS3Storage, newClient func(userID string, cfg aws.S3Config) (stopper, error)) *tenantClients {
+	return &tenantClients{
+		overrides: overrides,
+		newClient: newClient,
+		tenants:   map[string]*tenantClient{},
+	}
+}
+
+func noopRelease() {}
+
+// clientFor returns the client of the given tenant, or nil if the tenant uses the default client,
+// and the function to call once the client is no longer used.
+func (c *tenantClients) clientFor(userID string) (stopper, func(), error) {
+	cfg := c.overrides(userID)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	current, ok := c.tenants[userID]
+	if cfg == nil {
+		if ok {
+			c.retire(current)
+			delete(c.tenants, userID)
+		}
+		return nil, noopRelease, nil
+	}
+	if !ok || !reflect.DeepEqual(current.cfg, *cfg) {
+		client, err := c.newClient(userID, *cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			c.retire(current)
+		}
+		current = &tenantClient{cfg: *cfg, client: client}
+		c.tenants[userID] = current
+	}
+
+	current.refs++
+	return current.client, func() { c.release(current) }, nil
+}
+
+// retire stops the client once it is no longer used. It must be called with the mutex held.
+func (c *tenantClients) retire(t *tenantClient) {
+	t.retired = true
+	if t.refs == 0 {
+		t.client.Stop()
+	}
+}
+
+func (c *tenantClients) release(t *tenantClient) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	t.refs--
+	if t.refs == 0 && t.retired {
+		t.client.Stop()
+	}
+}
+
+// anyClient returns whether f returns true for one of the current tenant clients.
+func (c *tenantClients) anyClient(f func(stopper) bool) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, t := range c.tenants {
+		if f(t.client) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *tenantClients) Stop() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, t := range c.tenants {
+		t.client.Stop()
+	}
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/aws"
+)
+
+// tenantIndexClient routes index writes and queries of tenants bringing their own bucket to a
+// dedicated index client, and all other tenants to the default client.
+// The tenant is taken from the context of the calls.
+type tenantIndexClient struct {
+	chunk.IndexClient
+
+	tenants *tenantClients
+}
+
+// NewTenantIndexClient returns an index client routing the index of the tenants with a S3 bucket
+// in overrides to the index clients made by newClient.
+func NewTenantIndexClient(client chunk.IndexClient, overrides TenantS3Storage, newClient func(userID string, cfg aws.S3Config) (chunk.IndexClient, error)) chunk.IndexClient {
+	return &tenantIndexClient{
+		IndexClient: client,
+		tenants: newTenantClients(overrides, func(userID string, cfg aws.S3Config) (stopper, error) {
+			return newClient(userID, cfg)
+		}),
+	}
+}
+
+// clientFor returns the client to use for the tenant of the context, and the function to call
+// once the client is no longer used.
+func (c *tenantIndexClient) clientFor(ctx context.Context) (chunk.IndexClient, func(), error) {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, release, err := c.tenants.clientFor(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client == nil {
+		return c.IndexClient, release, nil
+	}
+	return client.(chunk.IndexClient), release, nil
+}
+
+func (c *tenantIndexClient) BatchWrite(ctx context.Context, batch chunk.WriteBatch) error {
+	client, release, err := c.clientFor(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return client.BatchWrite(ctx, batch)
+}
+
+func (c *tenantIndexClient) QueryPages(ctx context.Context, queries []chunk.IndexQuery, callback func(chunk.IndexQuery, chunk.ReadBatch) (shouldContinue bool)) error {
+	client, release, err := c.clientFor(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return client.QueryPages(ctx, queries, callback)
+}
+
+func (c *tenantIndexClient) Stop() {
+	c.tenants.Stop()
+	c.IndexClient.Stop()
+}
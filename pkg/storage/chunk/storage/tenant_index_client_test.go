@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/aws"
+)
+
+type recordingIndexClient struct {
+	chunk.IndexClient
+	writes  int
+	queries int
+	stopped bool
+}
+
+func (r *recordingIndexClient) BatchWrite(_ context.Context, _ chunk.WriteBatch) error {
+	r.writes++
+	return nil
+}
+
+func (r *recordingIndexClient) QueryPages(_ context.Context, _ []chunk.IndexQuery, _ func(chunk.IndexQuery, chunk.ReadBatch) bool) error {
+	r.queries++
+	return nil
+}
+
+func (r *recordingIndexClient) Stop() {
+	r.stopped = true
+}
+
+func Test_TenantIndexClient(t *testing.T) {
+	overrides := map[string]*aws.S3Config{
+		"byob": {BucketNames: "byob-bucket"},
+	}
+	defaultClient := &recordingIndexClient{}
+	tenantClients := map[string]*recordingIndexClient{}
+
+	c := NewTenantIndexClient(defaultClient, func(userID string) *aws.S3Config {
+		return overrides[userID]
+	}, func(userID string, cfg aws.S3Config) (chunk.IndexClient, error) {
+		require.Equal(t, "byob", userID)
+		client := &recordingIndexClient{}
+		tenantClients[cfg.BucketNames] = client
+		return client, nil
+	})
+
+	fake := user.InjectOrgID(context.Background(), "fake")
+	byob := user.InjectOrgID(context.Background(), "byob")
+
+	require.NoError(t, c.BatchWrite(fake, nil))
+	require.NoError(t, c.BatchWrite(byob, nil))
+	require.NoError(t, c.QueryPages(byob, nil, nil))
+	require.Equal(t, 1, defaultClient.writes)
+	require.Equal(t, 0, defaultClient.queries)
+	require.Equal(t, 1, tenantClients["byob-bucket"].writes)
+	require.Equal(t, 1, tenantClients["byob-bucket"].queries)
+
+	// the tenant must be known to pick the index.
+	require.Error(t, c.QueryPages(context.Background(), nil, nil))
+
+	// changing the tenant configuration re-creates its client.
+	overrides["byob"] = &aws.S3Config{BucketNames: "new-bucket"}
+	require.NoError(t, c.QueryPages(byob, nil, nil))
+	require.True(t, tenantClients["byob-bucket"].stopped)
+	require.Equal(t, 1, tenantClients["new-bucket"].queries)
+
+	// removing the tenant configuration goes back to the default client.
+	delete(overrides, "byob")
+	require.NoError(t, c.QueryPages(byob, nil, nil))
+	require.True(t, tenantClients["new-bucket"].stopped)
+	require.Equal(t, 1, defaultClient.queries)
+
+	c.Stop()
+	require.True(t, defaultClient.stopped)
+}
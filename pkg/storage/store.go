@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"path/filepath"
 	"sort"
 	"time"
 
@@ -21,6 +22,7 @@ import (
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/aws"
 	chunk_local "github.com/grafana/loki/pkg/storage/chunk/local"
 	"github.com/grafana/loki/pkg/storage/chunk/storage"
 	"github.com/grafana/loki/pkg/storage/stores/shipper"
@@ -344,6 +346,10 @@ func (s *store) SelectLogs(ctx context.Context, req logql.SelectLogParams) (iter
 		return nil, err
 	}
 
+	if err := logql.ResolveLookups(ctx, expr); err != nil {
+		return nil, err
+	}
+
 	pipeline, err := expr.Pipeline()
 	if err != nil {
 		return nil, err
@@ -371,6 +377,10 @@ func (s *store) SelectSamples(ctx context.Context, req logql.SelectSampleParams)
 		return nil, err
 	}
 
+	if err := logql.ResolveLookups(ctx, expr); err != nil {
+		return nil, err
+	}
+
 	extractor, err := expr.Extractor()
 	if err != nil {
 		return nil, err
@@ -407,7 +417,23 @@ func filterChunksByTime(from, through model.Time, chunks []chunk.Chunk) []chunk.
 	return filtered
 }
 
-func RegisterCustomIndexClients(cfg *Config, registerer prometheus.Registerer) {
+// CustomIndexClients are the index clients created by the index stores registered in RegisterCustomIndexClients,
+// once the store using them is created.
+type CustomIndexClients struct {
+	boltDBShipper *shipper.Shipper
+}
+
+// BoltDBShipper returns the boltdb-shipper index client used by the store, nil if the store didn't create one.
+func (c *CustomIndexClients) BoltDBShipper() *shipper.Shipper {
+	if c == nil {
+		return nil
+	}
+	return c.boltDBShipper
+}
+
+func RegisterCustomIndexClients(cfg *Config, registerer prometheus.Registerer) *CustomIndexClients {
+	clients := &CustomIndexClients{}
+
 	// BoltDB Shipper is supposed to be run as a singleton.
 	// This could also be done in NewBoltDBIndexClientWithShipper factory method but we are doing it here because that method is used
 	// in tests for creating multiple instances of it at a time.
@@ -434,8 +460,15 @@ func RegisterCustomIndexClients(cfg *Config, registerer prometheus.Registerer) {
 		}
 
 		boltDBIndexClientWithShipper, err = shipper.NewShipper(cfg.BoltDBShipperConfig, objectClient, registerer)
+		if err != nil {
+			return nil, err
+		}
 
-		return boltDBIndexClientWithShipper, err
+		clients.boltDBShipper = boltDBIndexClientWithShipper.(*shipper.Shipper)
+		if cfg.TenantS3Storage != nil {
+			boltDBIndexClientWithShipper = storage.NewTenantIndexClient(boltDBIndexClientWithShipper, cfg.TenantS3Storage, NewTenantShipper(cfg.BoltDBShipperConfig))
+		}
+		return boltDBIndexClientWithShipper, nil
 	}, func() (client chunk.TableClient, e error) {
 		objectClient, err := storage.NewObjectClient(cfg.BoltDBShipperConfig.SharedStoreType, cfg.Config)
 		if err != nil {
@@ -444,6 +477,30 @@ func RegisterCustomIndexClients(cfg *Config, registerer prometheus.Registerer) {
 
 		return shipper.NewBoltDBShipperTableClient(objectClient, cfg.BoltDBShipperConfig.SharedStoreKeyPrefix), nil
 	})
+	return clients
+}
+
+// NewTenantShipper returns a function making the boltdb-shipper of a tenant storing its index in
+// its own S3 bucket. The local files of the tenant are kept apart from the ones of the default
+// shipper, which would otherwise pick them up as tables.
+func NewTenantShipper(cfg shipper.Config) func(userID string, s3Cfg aws.S3Config) (chunk.IndexClient, error) {
+	return func(userID string, s3Cfg aws.S3Config) (chunk.IndexClient, error) {
+		objectClient, err := aws.NewS3ObjectClient(s3Cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		tenantCfg := cfg
+		if cfg.ActiveIndexDirectory != "" {
+			tenantCfg.ActiveIndexDirectory = filepath.Join(filepath.Clean(cfg.ActiveIndexDirectory)+"_tenants", userID)
+		}
+		if cfg.CacheLocation != "" {
+			tenantCfg.CacheLocation = filepath.Join(filepath.Clean(cfg.CacheLocation)+"_tenants", userID)
+		}
+
+		// The metrics of the tenant shippers are not registered, they would collide with the ones of the default shipper.
+		return shipper.NewShipper(tenantCfg, objectClient, nil)
+	}
 }
 
 // ActivePeriodConfig returns index of active PeriodicConfig which would be applicable to logs that would be pushed starting now.
@@ -209,10 +209,10 @@ func (c *Compactor) init(storageConfig storage.Config, schemaConfig loki_storage
 			return err
 		}
 
-		c.DeleteRequestsHandler = deletion.NewDeleteRequestHandler(c.deleteRequestsStore, time.Hour, r)
+		c.DeleteRequestsHandler = deletion.NewDeleteRequestHandler(c.deleteRequestsStore, time.Hour, limits, r)
 		c.deleteRequestsManager = deletion.NewDeleteRequestsManager(c.deleteRequestsStore, c.cfg.DeleteRequestCancelPeriod, r)
 
-		c.expirationChecker = newExpirationChecker(retention.NewExpirationChecker(limits), c.deleteRequestsManager)
+		c.expirationChecker = newExpirationChecker(retention.NewExpirationChecker(limits), c.deleteRequestsManager, limits)
 
 		c.tableMarker, err = retention.NewMarker(retentionWorkDir, schemaConfig, c.expirationChecker, chunkClient, r)
 		if err != nil {
@@ -519,13 +519,24 @@ func (c *Compactor) RunCompaction(ctx context.Context, applyRetention bool) erro
 type expirationChecker struct {
 	retentionExpiryChecker retention.ExpirationChecker
 	deletionExpiryChecker  retention.ExpirationChecker
+	limits                 retention.Limits
 }
 
-func newExpirationChecker(retentionExpiryChecker, deletionExpiryChecker retention.ExpirationChecker) retention.ExpirationChecker {
-	return &expirationChecker{retentionExpiryChecker, deletionExpiryChecker}
+func newExpirationChecker(retentionExpiryChecker, deletionExpiryChecker retention.ExpirationChecker, limits retention.Limits) retention.ExpirationChecker {
+	return &expirationChecker{retentionExpiryChecker, deletionExpiryChecker, limits}
+}
+
+// locked returns true if the chunks of the tenant ending at through are within its compliance
+// retention period, and must neither expire nor be deleted.
+func (e *expirationChecker) locked(userID []byte, through, now model.Time) bool {
+	period := e.limits.ComplianceRetentionPeriod(string(userID))
+	return period > 0 && now.Sub(through) <= period
 }
 
 func (e *expirationChecker) Expired(ref retention.ChunkEntry, now model.Time) (bool, []model.Interval) {
+	if e.locked(ref.UserID, ref.Through, now) {
+		return false, nil
+	}
 	if expired, nonDeletedIntervals := e.retentionExpiryChecker.Expired(ref, now); expired {
 		return expired, nonDeletedIntervals
 	}
@@ -553,6 +564,9 @@ func (e *expirationChecker) IntervalMayHaveExpiredChunks(interval model.Interval
 }
 
 func (e *expirationChecker) DropFromIndex(ref retention.ChunkEntry, tableEndTime model.Time, now model.Time) bool {
+	if e.locked(ref.UserID, tableEndTime, now) {
+		return false
+	}
 	return e.retentionExpiryChecker.DropFromIndex(ref, tableEndTime, now) || e.deletionExpiryChecker.DropFromIndex(ref, tableEndTime, now)
 }
 
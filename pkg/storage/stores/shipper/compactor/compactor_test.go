@@ -7,13 +7,16 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 
 	loki_storage "github.com/grafana/loki/pkg/storage"
 	"github.com/grafana/loki/pkg/storage/chunk/local"
 	"github.com/grafana/loki/pkg/storage/chunk/storage"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/testutil"
 	loki_net "github.com/grafana/loki/pkg/util/net"
 )
@@ -109,3 +112,44 @@ func TestCompactor_RunCompaction(t *testing.T) {
 		compareCompactedDB(t, filepath.Join(tablesPath, name, files[0].Name()), filepath.Join(tablesCopyPath, name))
 	}
 }
+
+type expireAllChecker struct {
+	retention.ExpirationChecker
+}
+
+func (expireAllChecker) Expired(_ retention.ChunkEntry, _ model.Time) (bool, []model.Interval) {
+	return true, nil
+}
+
+func (expireAllChecker) DropFromIndex(_ retention.ChunkEntry, _ model.Time, _ model.Time) bool {
+	return true
+}
+
+type complianceLimits struct {
+	retention.Limits
+	period map[string]time.Duration
+}
+
+func (l complianceLimits) ComplianceRetentionPeriod(userID string) time.Duration {
+	return l.period[userID]
+}
+
+func TestExpirationChecker_ComplianceRetention(t *testing.T) {
+	checker := newExpirationChecker(expireAllChecker{}, expireAllChecker{}, complianceLimits{period: map[string]time.Duration{"locked": 24 * time.Hour}})
+	now := model.Now()
+
+	for _, tc := range []struct {
+		userID  string
+		through model.Time
+		expired bool
+	}{
+		{userID: "other", through: now.Add(-time.Hour), expired: true},
+		{userID: "locked", through: now.Add(-time.Hour), expired: false},
+		{userID: "locked", through: now.Add(-25 * time.Hour), expired: true},
+	} {
+		ref := retention.ChunkEntry{ChunkRef: retention.ChunkRef{UserID: []byte(tc.userID), Through: tc.through}}
+		expired, _ := checker.Expired(ref, now)
+		require.Equal(t, tc.expired, expired, "%s through %s", tc.userID, tc.through)
+		require.Equal(t, tc.expired, checker.DropFromIndex(ref, tc.through, now), "%s through %s", tc.userID, tc.through)
+	}
+}
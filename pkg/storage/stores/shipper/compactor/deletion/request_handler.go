@@ -16,18 +16,25 @@ import (
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 )
 
+// Limits are the per tenant limits the delete requests are checked against.
+type Limits interface {
+	ComplianceRetentionPeriod(userID string) time.Duration
+}
+
 // DeleteRequestHandler provides handlers for delete requests
 type DeleteRequestHandler struct {
 	deleteRequestsStore       DeleteRequestsStore
 	metrics                   *deleteRequestHandlerMetrics
 	deleteRequestCancelPeriod time.Duration
+	limits                    Limits
 }
 
 // NewDeleteRequestHandler creates a DeleteRequestHandler
-func NewDeleteRequestHandler(deleteStore DeleteRequestsStore, deleteRequestCancelPeriod time.Duration, registerer prometheus.Registerer) *DeleteRequestHandler {
+func NewDeleteRequestHandler(deleteStore DeleteRequestsStore, deleteRequestCancelPeriod time.Duration, limits Limits, registerer prometheus.Registerer) *DeleteRequestHandler {
 	deleteMgr := DeleteRequestHandler{
 		deleteRequestsStore:       deleteStore,
 		deleteRequestCancelPeriod: deleteRequestCancelPeriod,
+		limits:                    limits,
 		metrics:                   newDeleteRequestHandlerMetrics(registerer),
 	}
 
@@ -89,6 +96,11 @@ func (dm *DeleteRequestHandler) AddDeleteRequestHandler(w http.ResponseWriter, r
 		return
 	}
 
+	if period := dm.limits.ComplianceRetentionPeriod(userID); period > 0 && model.Time(endTime).After(model.Now().Add(-period)) {
+		http.Error(w, fmt.Sprintf("deletes of logs within the compliance retention period of %s not allowed", model.Duration(period)), http.StatusForbidden)
+		return
+	}
+
 	if err := dm.deleteRequestsStore.AddDeleteRequest(ctx, userID, model.Time(startTime), model.Time(endTime), match); err != nil {
 		level.Error(util_log.Logger).Log("msg", "error adding delete request to the store", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -0,0 +1,61 @@
+package deletion
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+type complianceLimits map[string]time.Duration
+
+func (l complianceLimits) ComplianceRetentionPeriod(userID string) time.Duration {
+	return l[userID]
+}
+
+type addDeleteRequestsStore struct {
+	mockDeleteRequestsStore
+	added int
+}
+
+func (s *addDeleteRequestsStore) AddDeleteRequest(ctx context.Context, userID string, startTime, endTime model.Time, selectors []string) error {
+	s.added++
+	return nil
+}
+
+func TestAddDeleteRequestHandler_ComplianceRetention(t *testing.T) {
+	store := &addDeleteRequestsStore{}
+	handler := NewDeleteRequestHandler(store, time.Hour, complianceLimits{"locked": 24 * time.Hour}, nil)
+
+	for _, tc := range []struct {
+		name   string
+		userID string
+		end    time.Time
+		code   int
+	}{
+		{name: "no compliance retention", userID: testUserID, end: time.Now(), code: http.StatusNoContent},
+		{name: "within compliance retention", userID: "locked", end: time.Now().Add(-time.Hour), code: http.StatusForbidden},
+		{name: "after compliance retention", userID: "locked", end: time.Now().Add(-25 * time.Hour), code: http.StatusNoContent},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			params := url.Values{
+				"match[]": []string{`{foo="bar"}`},
+				"start":   []string{"0"},
+				"end":     []string{strconv.FormatInt(tc.end.Unix(), 10)},
+			}
+			req := httptest.NewRequest(http.MethodPost, "/loki/api/admin/delete?"+params.Encode(), nil)
+			req = req.WithContext(user.InjectOrgID(req.Context(), tc.userID))
+			rec := httptest.NewRecorder()
+			handler.AddDeleteRequestHandler(rec, req)
+			require.Equal(t, tc.code, rec.Code, rec.Body.String())
+		})
+	}
+	require.Equal(t, 2, store.added)
+}
@@ -29,6 +29,7 @@ type expirationChecker struct {
 
 type Limits interface {
 	RetentionPeriod(userID string) time.Duration
+	ComplianceRetentionPeriod(userID string) time.Duration
 	StreamRetention(userID string) []validation.StreamRetention
 	AllByUserID() map[string]*validation.Limits
 	DefaultLimits() *validation.Limits
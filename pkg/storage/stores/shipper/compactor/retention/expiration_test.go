@@ -12,14 +12,16 @@ import (
 )
 
 type retentionLimit struct {
-	retentionPeriod time.Duration
-	streamRetention []validation.StreamRetention
+	retentionPeriod           time.Duration
+	complianceRetentionPeriod time.Duration
+	streamRetention           []validation.StreamRetention
 }
 
 func (r retentionLimit) convertToValidationLimit() *validation.Limits {
 	return &validation.Limits{
-		RetentionPeriod: model.Duration(r.retentionPeriod),
-		StreamRetention: r.streamRetention,
+		RetentionPeriod:           model.Duration(r.retentionPeriod),
+		ComplianceRetentionPeriod: model.Duration(r.complianceRetentionPeriod),
+		StreamRetention:           r.streamRetention,
 	}
 }
 
@@ -32,6 +34,10 @@ func (f fakeLimits) RetentionPeriod(userID string) time.Duration {
 	return f.perTenant[userID].retentionPeriod
 }
 
+func (f fakeLimits) ComplianceRetentionPeriod(userID string) time.Duration {
+	return f.perTenant[userID].complianceRetentionPeriod
+}
+
 func (f fakeLimits) StreamRetention(userID string) []validation.StreamRetention {
 	return f.perTenant[userID].streamRetention
 }
@@ -55,7 +55,9 @@ type metrics struct {
 	tablesDownloadDurationSeconds *downloadTableDurationMetric
 	tablesDownloadSizeBytes       *downloadTableBytesMetric
 
-	tablesSyncOperationTotal *prometheus.CounterVec
+	tablesSyncOperationTotal       *prometheus.CounterVec
+	tablesLastSyncTimestampSeconds *prometheus.GaugeVec
+	staleTablesQueriesTotal        *prometheus.CounterVec
 }
 
 func newMetrics(r prometheus.Registerer) *metrics {
@@ -79,6 +81,16 @@ func newMetrics(r prometheus.Registerer) *metrics {
 			Name:      "tables_sync_operation_total",
 			Help:      "Total number of tables sync operations done by status",
 		}, []string{"status"}),
+		tablesLastSyncTimestampSeconds: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "table_last_successful_sync_timestamp_seconds",
+			Help:      "Unix timestamp of the point in time up to which files of a table were synced by the last successful sync operation",
+		}, []string{"table"}),
+		staleTablesQueriesTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "stale_tables_queries_total",
+			Help:      "Total number of queries hitting tables whose sync point is older than the max sync lag, by action taken",
+		}, []string{"action"}),
 	}
 
 	return m
@@ -59,6 +59,11 @@ type Table struct {
 
 	ready      chan struct{}      // helps with detecting initialization of table which downloads all the existing files.
 	cancelFunc context.CancelFunc // helps with cancellation of initialization if we are asked to stop.
+
+	syncMtx        sync.RWMutex
+	syncedAt       time.Time // time at which the files were listed by the last successful sync, all the files uploaded before it are downloaded.
+	lastModifiedAt time.Time // modification time of the newest file seen by the last successful sync.
+	syncErr        error     // error of the last sync, cleared by the next successful one.
 }
 
 func NewTable(spanCtx context.Context, name, cacheLocation string, storageClient StorageClient, boltDBIndexClient BoltDBIndexClient, metrics *metrics) *Table {
@@ -175,7 +180,12 @@ func LoadTable(ctx context.Context, name, cacheLocation string, storageClient St
 // init downloads all the db files for the table from object storage.
 // it assumes the locking of mutex is taken care of by the caller.
 func (t *Table) init(ctx context.Context, spanLogger log.Logger) (err error) {
+	startTime := time.Now()
+	var files []storage.IndexFile
+
 	defer func() {
+		t.recordSync(startTime, files, err)
+
 		status := statusSuccess
 		if err != nil {
 			status = statusFailure
@@ -193,10 +203,9 @@ func (t *Table) init(ctx context.Context, spanLogger log.Logger) (err error) {
 		t.metrics.tablesSyncOperationTotal.WithLabelValues(status).Inc()
 	}()
 
-	startTime := time.Now()
 	totalFilesSize := int64(0)
 
-	files, err := t.storageClient.ListFiles(ctx, t.name)
+	files, err = t.storageClient.ListFiles(ctx, t.name)
 	if err != nil {
 		return
 	}
@@ -362,10 +371,16 @@ func (t *Table) cleanupDB(fileName string) error {
 }
 
 // Sync downloads updated and new files from the storage relevant for the table and removes the deleted ones
-func (t *Table) Sync(ctx context.Context) error {
+func (t *Table) Sync(ctx context.Context) (err error) {
 	level.Debug(util_log.Logger).Log("msg", fmt.Sprintf("syncing files for table %s", t.name))
 
-	toDownload, toDelete, err := t.checkStorageForUpdates(ctx)
+	listedAt := time.Now()
+	var files []storage.IndexFile
+	defer func() {
+		t.recordSync(listedAt, files, err)
+	}()
+
+	files, toDownload, toDelete, err := t.checkStorageForUpdates(ctx)
 	if err != nil {
 		return err
 	}
@@ -393,10 +408,8 @@ func (t *Table) Sync(ctx context.Context) error {
 }
 
 // checkStorageForUpdates compares files from cache with storage and builds the list of files to be downloaded from storage and to be deleted from cache
-func (t *Table) checkStorageForUpdates(ctx context.Context) (toDownload []storage.IndexFile, toDelete []string, err error) {
+func (t *Table) checkStorageForUpdates(ctx context.Context) (files, toDownload []storage.IndexFile, toDelete []string, err error) {
 	// listing tables from store
-	var files []storage.IndexFile
-
 	files, err = t.storageClient.ListFiles(ctx, t.name)
 	if err != nil {
 		return
@@ -427,6 +440,34 @@ func (t *Table) checkStorageForUpdates(ctx context.Context) (toDownload []storag
 	return
 }
 
+// recordSync records the outcome of a sync which listed the files of the table at listedAt.
+func (t *Table) recordSync(listedAt time.Time, files []storage.IndexFile, err error) {
+	t.syncMtx.Lock()
+	defer t.syncMtx.Unlock()
+
+	t.syncErr = err
+	if err != nil {
+		return
+	}
+
+	t.syncedAt = listedAt
+	t.lastModifiedAt = time.Time{}
+	for _, file := range files {
+		if file.ModifiedAt.After(t.lastModifiedAt) {
+			t.lastModifiedAt = file.ModifiedAt
+		}
+	}
+	t.metrics.tablesLastSyncTimestampSeconds.WithLabelValues(t.name).Set(float64(listedAt.UnixNano()) / 1e9)
+}
+
+// SyncStatus returns the sync point of the table along with the error of the last sync if it failed.
+func (t *Table) SyncStatus() (syncedAt, lastModifiedAt time.Time, err error) {
+	t.syncMtx.RLock()
+	defer t.syncMtx.RUnlock()
+
+	return t.syncedAt, t.lastModifiedAt, t.syncErr
+}
+
 // downloadFile first downloads file to a temp location so that we can close the existing db(if already exists), replace it with new one and then reopen it.
 func (t *Table) downloadFile(ctx context.Context, file storage.IndexFile) error {
 	level.Info(util_log.Logger).Log("msg", fmt.Sprintf("downloading object from storage with key %s", file.Name))
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -26,11 +27,30 @@ const (
 	durationDay          = 24 * time.Hour
 )
 
+const (
+	staleActionFlagged  = "flagged"
+	staleActionRejected = "rejected"
+)
+
 type Config struct {
 	CacheDir          string
 	SyncInterval      time.Duration
 	CacheTTL          time.Duration
 	QueryReadyNumDays int
+	// MaxSyncLag is the duration after which a table still receiving files at its sync point is considered stale, 0 disables the check.
+	MaxSyncLag time.Duration
+	// RejectStaleQueries fails queries hitting stale tables instead of just flagging them.
+	RejectStaleQueries bool
+}
+
+// TableSyncStatus is the sync status of a table downloaded by the TableManager.
+type TableSyncStatus struct {
+	Table          string    `json:"table"`
+	SyncedAt       time.Time `json:"synced_at"`
+	LastModifiedAt time.Time `json:"last_modified_at"`
+	LagSeconds     float64   `json:"lag_seconds"`
+	Stale          bool      `json:"stale"`
+	Error          string    `json:"error,omitempty"`
 }
 
 type TableManager struct {
@@ -149,6 +169,10 @@ func (tm *TableManager) query(ctx context.Context, tableName string, queries []c
 
 	table := tm.getOrCreateTable(ctx, tableName)
 
+	if err := tm.checkStaleness(ctx, log, table); err != nil {
+		return err
+	}
+
 	err := util.DoParallelQueries(ctx, table, queries, callback)
 	if err != nil {
 		if table.Err() != nil {
@@ -166,6 +190,93 @@ func (tm *TableManager) query(ctx context.Context, tableName string, queries []c
 	return err
 }
 
+// checkStaleness flags or rejects the query of a table which might be missing index files uploaded after its sync point.
+func (tm *TableManager) checkStaleness(ctx context.Context, log *spanlogger.SpanLogger, table *Table) error {
+	if tm.cfg.MaxSyncLag == 0 {
+		return nil
+	}
+
+	// wait for the initial download of the table for knowing its sync point.
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-table.ready:
+	}
+
+	// queries without a time range are expected to reach the most recent files.
+	now := time.Now()
+	end := now
+	if through, ok := chunk.ExtractQueryThrough(ctx); ok && through.Time().Before(now) {
+		end = through.Time()
+	}
+
+	syncedAt, lastModifiedAt, syncErr := table.SyncStatus()
+	if !isStale(syncedAt, lastModifiedAt, end, now, tm.cfg.MaxSyncLag) {
+		return nil
+	}
+
+	if tm.cfg.RejectStaleQueries {
+		tm.metrics.staleTablesQueriesTotal.WithLabelValues(staleActionRejected).Inc()
+		return fmt.Errorf("index of table %s was last synced at %s, more than the max sync lag of %s ago, refusing to return possibly incomplete results", table.name, syncedAt.Format(time.RFC3339), tm.cfg.MaxSyncLag)
+	}
+
+	tm.metrics.staleTablesQueriesTotal.WithLabelValues(staleActionFlagged).Inc()
+	level.Warn(log).Log("msg", "querying stale table, results could be incomplete", "table-name", table.name, "synced-at", syncedAt, "sync-err", syncErr)
+	return nil
+}
+
+// isStale returns whether a query of a table up to end might need index files uploaded after its sync point,
+// which is when it was last synced more than maxSyncLag ago while it was still receiving files.
+// Queries ending maxSyncLag before the sync point only need files uploaded before it, and tables which did
+// not receive any file for maxSyncLag before their sync point are considered complete, while empty ones are
+// expected to receive files.
+func isStale(syncedAt, lastModifiedAt, end, now time.Time, maxSyncLag time.Duration) bool {
+	if maxSyncLag == 0 {
+		return false
+	}
+
+	if now.Sub(syncedAt) <= maxSyncLag {
+		return false
+	}
+
+	if end.Before(syncedAt.Add(-maxSyncLag)) {
+		return false
+	}
+
+	return lastModifiedAt.IsZero() || syncedAt.Sub(lastModifiedAt) < maxSyncLag
+}
+
+// SyncStatus returns the sync status of all the tables sorted by name.
+func (tm *TableManager) SyncStatus() []TableSyncStatus {
+	tm.tablesMtx.RLock()
+	defer tm.tablesMtx.RUnlock()
+
+	now := time.Now()
+	statuses := make([]TableSyncStatus, 0, len(tm.tables))
+	for name, table := range tm.tables {
+		syncedAt, lastModifiedAt, err := table.SyncStatus()
+		status := TableSyncStatus{
+			Table:          name,
+			SyncedAt:       syncedAt,
+			LastModifiedAt: lastModifiedAt,
+			Stale:          isStale(syncedAt, lastModifiedAt, now, now, tm.cfg.MaxSyncLag),
+		}
+		if !syncedAt.IsZero() {
+			status.LagSeconds = now.Sub(syncedAt).Seconds()
+		}
+		if err != nil {
+			status.Error = err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Table < statuses[j].Table
+	})
+
+	return statuses
+}
+
 func (tm *TableManager) getOrCreateTable(spanCtx context.Context, tableName string) *Table {
 	// if table is already there, use it.
 	tm.tablesMtx.RLock()
@@ -232,6 +343,7 @@ func (tm *TableManager) cleanupCache() error {
 			}
 
 			delete(tm.tables, name)
+			tm.metrics.tablesLastSyncTimestampSeconds.DeleteLabelValues(name)
 
 			// remove the directory where files for the table were downloaded.
 			err = os.RemoveAll(path.Join(tm.cfg.CacheDir, name))
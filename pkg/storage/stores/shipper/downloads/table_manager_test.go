@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/loki/pkg/storage/chunk"
@@ -135,6 +136,119 @@ func TestTableManager_cleanupCache(t *testing.T) {
 	require.True(t, ok)
 }
 
+func TestTableManager_StaleTables(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "table-manager-stale-tables")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	objectStoragePath := filepath.Join(tempDir, objectsStorageDirName)
+	testutil.SetupDBTablesAtPath(t, "table", objectStoragePath, map[string]testutil.DBRecords{
+		"db": {Start: 0, NumRecords: 10},
+	}, true)
+
+	tableManager, stopFunc := buildTestTableManager(t, tempDir)
+	defer stopFunc()
+	tableManager.cfg.MaxSyncLag = time.Minute
+
+	queries := []chunk.IndexQuery{{TableName: "table"}}
+	callback := func(query chunk.IndexQuery, batch chunk.ReadBatch) bool {
+		return true
+	}
+
+	// a freshly downloaded table is not stale.
+	require.NoError(t, tableManager.QueryPages(context.Background(), queries, callback))
+
+	statuses := tableManager.SyncStatus()
+	require.Len(t, statuses, 1)
+	require.Equal(t, "table", statuses[0].Table)
+	require.False(t, statuses[0].Stale)
+
+	// move the sync point back in time while the table was still receiving files.
+	table := tableManager.tables["table"]
+	table.syncedAt = time.Now().Add(-2 * time.Minute)
+	table.lastModifiedAt = table.syncedAt.Add(-time.Second)
+
+	require.True(t, tableManager.SyncStatus()[0].Stale)
+	require.InDelta(t, 120, tableManager.SyncStatus()[0].LagSeconds, 1)
+
+	// stale tables are only flagged by default.
+	require.NoError(t, tableManager.QueryPages(context.Background(), queries, callback))
+
+	tableManager.cfg.RejectStaleQueries = true
+	err = tableManager.QueryPages(context.Background(), queries, callback)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "index of table table was last synced at")
+
+	// queries ending before the sync point don't need the files uploaded after it.
+	through := model.TimeFromUnixNano(table.syncedAt.Add(-time.Hour).UnixNano())
+	require.NoError(t, tableManager.QueryPages(chunk.InjectQueryThrough(context.Background(), through), queries, callback))
+
+	// a table which stopped receiving files well before its sync point is complete.
+	table.lastModifiedAt = table.syncedAt.Add(-time.Hour)
+	require.NoError(t, tableManager.QueryPages(context.Background(), queries, callback))
+}
+
+func Test_isStale(t *testing.T) {
+	now := time.Now()
+	for name, tc := range map[string]struct {
+		syncedAt, lastModifiedAt time.Time
+		end                      time.Time
+		maxSyncLag               time.Duration
+		expected                 bool
+	}{
+		"disabled": {
+			syncedAt:       now.Add(-time.Hour),
+			lastModifiedAt: now.Add(-time.Hour),
+		},
+		"recently synced": {
+			syncedAt:       now.Add(-time.Minute),
+			lastModifiedAt: now.Add(-time.Minute),
+			maxSyncLag:     5 * time.Minute,
+		},
+		"lagging while receiving files": {
+			syncedAt:       now.Add(-time.Hour),
+			lastModifiedAt: now.Add(-time.Hour - time.Minute),
+			maxSyncLag:     5 * time.Minute,
+			expected:       true,
+		},
+		"lagging while empty": {
+			syncedAt:   now.Add(-time.Hour),
+			maxSyncLag: 5 * time.Minute,
+			expected:   true,
+		},
+		"lagging for a query ending before the sync point": {
+			syncedAt:       now.Add(-time.Hour),
+			lastModifiedAt: now.Add(-time.Hour - time.Minute),
+			end:            now.Add(-2 * time.Hour),
+			maxSyncLag:     5 * time.Minute,
+		},
+		"lagging for a query ending within the max sync lag of the sync point": {
+			syncedAt:       now.Add(-time.Hour),
+			lastModifiedAt: now.Add(-time.Hour - time.Minute),
+			end:            now.Add(-time.Hour - time.Minute),
+			maxSyncLag:     5 * time.Minute,
+			expected:       true,
+		},
+		"lagging after receiving its last file": {
+			syncedAt:       now.Add(-time.Hour),
+			lastModifiedAt: now.Add(-2 * time.Hour),
+			maxSyncLag:     5 * time.Minute,
+		},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			end := tc.end
+			if end.IsZero() {
+				end = now
+			}
+			require.Equal(t, tc.expected, isStale(tc.syncedAt, tc.lastModifiedAt, end, now, tc.maxSyncLag))
+		})
+	}
+}
+
 func TestTableManager_ensureQueryReadiness(t *testing.T) {
 	for _, tc := range []struct {
 		name                 string
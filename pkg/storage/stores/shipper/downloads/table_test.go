@@ -2,6 +2,7 @@ package downloads
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -225,6 +226,46 @@ func TestTable_LastUsedAt(t *testing.T) {
 	require.InDelta(t, time.Now().Unix(), table.LastUsedAt().Unix(), 1)
 }
 
+type failingStorageClient struct {
+	StorageClient
+}
+
+func (failingStorageClient) ListFiles(context.Context, string) ([]storage.IndexFile, error) {
+	return nil, errors.New("storage unavailable")
+}
+
+func TestTable_SyncStatus(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "table-sync-status")
+	require.NoError(t, err)
+
+	objectStoragePath := filepath.Join(tempDir, objectsStorageDirName)
+	testutil.SetupDBTablesAtPath(t, "test", objectStoragePath, map[string]testutil.DBRecords{
+		"db": {Start: 0, NumRecords: 10},
+	}, false)
+	fileInfo, err := os.Stat(filepath.Join(objectStoragePath, "test", "db"))
+	require.NoError(t, err)
+
+	table, _, stopFunc := buildTestTable(t, "test", tempDir)
+	defer func() {
+		stopFunc()
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	// the initial download should have set the sync point.
+	syncedAt, lastModifiedAt, err := table.SyncStatus()
+	require.NoError(t, err)
+	require.InDelta(t, time.Now().Unix(), syncedAt.Unix(), 1)
+	require.Equal(t, fileInfo.ModTime().Unix(), lastModifiedAt.Unix())
+
+	// a failed sync should keep the sync point while reporting the error.
+	table.storageClient = failingStorageClient{table.storageClient}
+	require.Error(t, table.Sync(context.Background()))
+
+	failedSyncedAt, _, err := table.SyncStatus()
+	require.EqualError(t, err, "storage unavailable")
+	require.Equal(t, syncedAt, failedSyncedAt)
+}
+
 func TestTable_doParallelDownload(t *testing.T) {
 	tempDir, err := ioutil.TempDir("", "table-parallel-download")
 	require.NoError(t, err)
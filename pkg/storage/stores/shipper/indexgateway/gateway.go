@@ -4,7 +4,6 @@ import (
 	"github.com/grafana/dskit/services"
 
 	"github.com/grafana/loki/pkg/storage/chunk"
-	"github.com/grafana/loki/pkg/storage/stores/shipper"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/indexgateway/indexgatewaypb"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/util"
 )
@@ -17,7 +16,7 @@ type gateway struct {
 	shipper chunk.IndexClient
 }
 
-func NewIndexGateway(shipperIndexClient *shipper.Shipper) *gateway {
+func NewIndexGateway(shipperIndexClient chunk.IndexClient) *gateway {
 	g := &gateway{
 		shipper: shipperIndexClient,
 	}
@@ -5,11 +5,13 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 	"sync"
 	"time"
 
+	"github.com/cortexproject/cortex/pkg/util"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/cortexproject/cortex/pkg/util/spanlogger"
 	"github.com/go-kit/log/level"
@@ -60,6 +62,8 @@ type Config struct {
 	CacheTTL                 time.Duration            `yaml:"cache_ttl"`
 	ResyncInterval           time.Duration            `yaml:"resync_interval"`
 	QueryReadyNumDays        int                      `yaml:"query_ready_num_days"`
+	MaxSyncLag               time.Duration            `yaml:"max_sync_lag"`
+	RejectStaleQueries       bool                     `yaml:"reject_stale_queries"`
 	IndexGatewayClientConfig IndexGatewayClientConfig `yaml:"index_gateway_client"`
 	IngesterName             string                   `yaml:"-"`
 	Mode                     int                      `yaml:"-"`
@@ -77,6 +81,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.CacheTTL, "boltdb.shipper.cache-ttl", 24*time.Hour, "TTL for boltDB files restored in cache for queries")
 	f.DurationVar(&cfg.ResyncInterval, "boltdb.shipper.resync-interval", 5*time.Minute, "Resync downloaded files with the storage")
 	f.IntVar(&cfg.QueryReadyNumDays, "boltdb.shipper.query-ready-num-days", 0, "Number of days of index to be kept downloaded for queries. Works only with tables created with 24h period.")
+	f.DurationVar(&cfg.MaxSyncLag, "boltdb.shipper.max-sync-lag", 0, "Maximum duration a downloaded table can go without a successful resync while it is still receiving index files. Queries hitting tables lagging further behind are flagged in the logs, or rejected when reject_stale_queries is enabled, unless they end more than max_sync_lag before the last sync. 0 disables the check.")
+	f.BoolVar(&cfg.RejectStaleQueries, "boltdb.shipper.reject-stale-queries", false, "Fail queries hitting tables lagging behind the storage by more than max_sync_lag instead of returning possibly incomplete results.")
 }
 
 func (cfg *Config) Validate() error {
@@ -148,10 +154,12 @@ func (s *Shipper) init(storageClient chunk.ObjectClient, registerer prometheus.R
 
 	if s.cfg.Mode != ModeWriteOnly {
 		cfg := downloads.Config{
-			CacheDir:          s.cfg.CacheLocation,
-			SyncInterval:      s.cfg.ResyncInterval,
-			CacheTTL:          s.cfg.CacheTTL,
-			QueryReadyNumDays: s.cfg.QueryReadyNumDays,
+			CacheDir:           s.cfg.CacheLocation,
+			SyncInterval:       s.cfg.ResyncInterval,
+			CacheTTL:           s.cfg.CacheTTL,
+			QueryReadyNumDays:  s.cfg.QueryReadyNumDays,
+			MaxSyncLag:         s.cfg.MaxSyncLag,
+			RejectStaleQueries: s.cfg.RejectStaleQueries,
 		}
 		downloadsManager, err := downloads.NewTableManager(cfg, s.boltDBIndexClient, indexStorageClient, registerer)
 		if err != nil {
@@ -210,6 +218,22 @@ func (s *Shipper) stop() {
 	s.boltDBIndexClient.Stop()
 }
 
+// SyncStatusHandler serves the sync status of the tables downloaded for queries.
+func (s *Shipper) SyncStatusHandler(w http.ResponseWriter, _ *http.Request) {
+	if s.downloadsManager == nil {
+		http.Error(w, "boltdb shipper is not downloading index in this mode", http.StatusNotFound)
+		return
+	}
+
+	util.WriteJSONResponse(w, struct {
+		MaxSyncLag string                      `json:"max_sync_lag"`
+		Tables     []downloads.TableSyncStatus `json:"tables"`
+	}{
+		MaxSyncLag: s.cfg.MaxSyncLag.String(),
+		Tables:     s.downloadsManager.SyncStatus(),
+	})
+}
+
 func (s *Shipper) NewWriteBatch() chunk.WriteBatch {
 	return s.boltDBIndexClient.NewWriteBatch()
 }
@@ -0,0 +1,123 @@
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// Name is the name registered for the zstd compressor.
+	Name = "zstd"
+
+	// defaultMaxDecompressedSize is the default max receive message size of gRPC.
+	defaultMaxDecompressedSize = 4 << 20
+)
+
+var registered *compressor
+
+func init() {
+	var err error
+	registered, err = newCompressor(defaultMaxDecompressedSize)
+	if err != nil {
+		panic(err)
+	}
+	encoding.RegisterCompressor(registered)
+}
+
+// SetMaxDecompressedSize raises the maximum size of the decompressed messages to the max
+// receive message size of a gRPC client or server, the decompression of larger messages stops
+// at that size and fails. The compressor is shared by all the gRPC clients and servers of
+// the process, so the largest size is kept.
+func SetMaxDecompressedSize(size int) error {
+	return registered.setMaxDecompressedSize(size)
+}
+
+// compressor compresses whole messages, gRPC buffers them in memory anyway.
+// EncodeAll and DecodeAll are safe for concurrent use so a single encoder and decoder are shared.
+type compressor struct {
+	encoder *zstd.Encoder
+
+	// mtx guards the decoder, which is replaced when the max decompressed size is raised.
+	mtx             sync.RWMutex
+	decoder         *zstd.Decoder
+	maxDecompressed int
+}
+
+func newCompressor(maxDecompressedSize int) (*compressor, error) {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	if err != nil {
+		return nil, err
+	}
+	c := &compressor{encoder: encoder}
+	if err := c.setMaxDecompressedSize(maxDecompressedSize); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *compressor) setMaxDecompressedSize(size int) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if size <= c.maxDecompressed {
+		return nil
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(size)))
+	if err != nil {
+		return err
+	}
+	if c.decoder != nil {
+		c.decoder.Close()
+	}
+	c.decoder = decoder
+	c.maxDecompressed = size
+	return nil
+}
+
+func (c *compressor) Name() string {
+	return Name
+}
+
+func (c *compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return &writeCloser{encoder: c.encoder, w: w}, nil
+}
+
+func (c *compressor) Decompress(r io.Reader) (io.Reader, error) {
+	compressed, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	c.mtx.RLock()
+	decompressed, err := c.decoder.DecodeAll(compressed, nil)
+	max := c.maxDecompressed
+	c.mtx.RUnlock()
+	if err == zstd.ErrDecoderSizeExceeded {
+		return nil, fmt.Errorf("decompressed message larger than max (%d)", max)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(decompressed), nil
+}
+
+type writeCloser struct {
+	encoder *zstd.Encoder
+	w       io.Writer
+	buf     []byte
+}
+
+func (w *writeCloser) Write(p []byte) (n int, err error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+func (w *writeCloser) Close() error {
+	_, err := w.w.Write(w.encoder.EncodeAll(w.buf, nil))
+	w.buf = nil
+	return err
+}
@@ -0,0 +1,63 @@
+package zstd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/encoding"
+)
+
+func TestCompressor(t *testing.T) {
+	c := encoding.GetCompressor(Name)
+	require.NotNil(t, c)
+
+	for _, input := range []string{
+		"",
+		"foo",
+		strings.Repeat(`level=info msg="request" path=/loki/api/v1/push status=204 `, 1000),
+	} {
+		var buf bytes.Buffer
+		w, err := c.Compress(&buf)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(input))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		r, err := c.Decompress(&buf)
+		require.NoError(t, err)
+		output, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, input, string(output))
+	}
+}
+
+func TestCompressor_MaxDecompressedSize(t *testing.T) {
+	c, err := newCompressor(1 << 10)
+	require.NoError(t, err)
+
+	compress := func(input string) *bytes.Buffer {
+		var buf bytes.Buffer
+		w, err := c.Compress(&buf)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(input))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return &buf
+	}
+	large := strings.Repeat("a", 2<<10)
+
+	_, err = c.Decompress(compress(large))
+	require.Error(t, err)
+
+	// a smaller size doesn't lower the limit.
+	require.NoError(t, c.setMaxDecompressedSize(1))
+	require.NoError(t, c.setMaxDecompressedSize(4<<10))
+	r, err := c.Decompress(compress(large))
+	require.NoError(t, err)
+	output, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, large, string(output))
+}
@@ -0,0 +1,144 @@
+package marshal
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/logqlmodel"
+	"github.com/grafana/loki/pkg/logqlmodel/stats"
+)
+
+// WriteQueryResponse marshals the query result to the JSON format of the response
+// options, without the parts they omit, and writes it to the provided io.Writer.
+// Protobuf responses are encoded by the query frontend codec.
+func WriteQueryResponse(v logqlmodel.Result, opts loghttp.ResponseOptions, w io.Writer) error {
+	if opts.IsDefault() {
+		return WriteQueryResponseJSON(v, w)
+	}
+	value, err := NewResultValue(v.Data)
+	if err != nil {
+		return err
+	}
+
+	data := queryResponseData{ResultType: value.Type()}
+	if !opts.OmitStats {
+		data.Statistics = &v.Statistics
+	}
+	switch opts.Format {
+	case loghttp.ResponseFormatJSON:
+		if opts.OmitLabels {
+			value = withoutLabels(value)
+		}
+		data.Result = value
+	case loghttp.ResponseFormatCompactJSON:
+		data.Result = newCompactResult(value, opts.OmitLabels)
+	default:
+		return fmt.Errorf("unsupported response format %s", opts.Format)
+	}
+
+	return jsoniter.NewEncoder(w).Encode(queryResponse{
+		Status: loghttp.QueryStatusSuccess,
+		Data:   data,
+	})
+}
+
+type queryResponse struct {
+	Status string            `json:"status"`
+	Data   queryResponseData `json:"data"`
+}
+
+type queryResponseData struct {
+	ResultType loghttp.ResultType `json:"resultType"`
+	Result     interface{}        `json:"result"`
+	Statistics *stats.Result      `json:"stats,omitempty"`
+}
+
+// withoutLabels returns the value with empty label sets, so that clients reading
+// the usual format can still decode it.
+func withoutLabels(value loghttp.ResultValue) loghttp.ResultValue {
+	switch v := value.(type) {
+	case loghttp.Streams:
+		for i := range v {
+			v[i].Labels = loghttp.LabelSet{}
+		}
+	case loghttp.Vector:
+		for i := range v {
+			v[i].Metric = model.Metric{}
+		}
+	case loghttp.Matrix:
+		for i := range v {
+			v[i].Metric = model.Metric{}
+		}
+	}
+	return value
+}
+
+// compactStream holds the entries of a stream as columns. Timestamps are nanosecond
+// deltas from the start timestamp, small enough to be exact JSON numbers.
+type compactStream struct {
+	Labels loghttp.LabelSet `json:"stream,omitempty"`
+	Start  string           `json:"start"`
+	Deltas []int64          `json:"deltas"`
+	Lines  []string         `json:"lines"`
+}
+
+// compactSeries holds the samples of a series as columns.
+type compactSeries struct {
+	Metric     model.Metric        `json:"metric,omitempty"`
+	Timestamps []model.Time        `json:"timestamps"`
+	Values     []model.SampleValue `json:"values"`
+}
+
+func newCompactResult(value loghttp.ResultValue, omitLabels bool) interface{} {
+	switch v := value.(type) {
+	case loghttp.Streams:
+		result := make([]compactStream, 0, len(v))
+		for _, s := range v {
+			cs := compactStream{
+				Deltas: make([]int64, 0, len(s.Entries)),
+				Lines:  make([]string, 0, len(s.Entries)),
+			}
+			if !omitLabels {
+				cs.Labels = s.Labels
+			}
+			var start int64
+			if len(s.Entries) > 0 {
+				start = s.Entries[0].Timestamp.UnixNano()
+			}
+			cs.Start = strconv.FormatInt(start, 10)
+			for _, e := range s.Entries {
+				cs.Deltas = append(cs.Deltas, e.Timestamp.UnixNano()-start)
+				cs.Lines = append(cs.Lines, e.Line)
+			}
+			result = append(result, cs)
+		}
+		return result
+	case loghttp.Matrix:
+		result := make([]compactSeries, 0, len(v))
+		for _, s := range v {
+			cs := compactSeries{
+				Timestamps: make([]model.Time, 0, len(s.Values)),
+				Values:     make([]model.SampleValue, 0, len(s.Values)),
+			}
+			if !omitLabels {
+				cs.Metric = s.Metric
+			}
+			for _, p := range s.Values {
+				cs.Timestamps = append(cs.Timestamps, p.Timestamp)
+				cs.Values = append(cs.Values, p.Value)
+			}
+			result = append(result, cs)
+		}
+		return result
+	}
+	// Vectors and scalars hold a single sample per series, they are already compact.
+	if omitLabels {
+		return withoutLabels(value)
+	}
+	return value
+}
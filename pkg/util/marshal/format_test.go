@@ -0,0 +1,44 @@
+package marshal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/logqlmodel"
+)
+
+func Test_WriteQueryResponse(t *testing.T) {
+	matrix := promql.Matrix{
+		{
+			Metric: labels.Labels{{Name: "app", Value: "foo"}},
+			Points: []promql.Point{{T: 1000, V: 1}, {T: 2000, V: 2.5}},
+		},
+	}
+	for _, tc := range []struct {
+		name     string
+		opts     loghttp.ResponseOptions
+		expected string
+	}{
+		{
+			"json without labels",
+			loghttp.ResponseOptions{Format: loghttp.ResponseFormatJSON, OmitLabels: true, OmitStats: true},
+			`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[1,"1"],[2,"2.5"]]}]}}`,
+		},
+		{
+			"compact json",
+			loghttp.ResponseOptions{Format: loghttp.ResponseFormatCompactJSON, OmitStats: true},
+			`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"app":"foo"},"timestamps":[1,2],"values":["1","2.5"]}]}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+			require.NoError(t, WriteQueryResponse(logqlmodel.Result{Data: matrix}, tc.opts, &b))
+			require.JSONEq(t, tc.expected, b.String())
+		})
+	}
+}
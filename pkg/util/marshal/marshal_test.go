@@ -452,6 +452,44 @@ func Test_QueryResponseMarshalLoop(t *testing.T) {
 	}
 }
 
+func Test_StructuredMetadataMarshalLoop(t *testing.T) {
+	streams, err := NewStreams(logqlmodel.Streams{
+		logproto.Stream{
+			Entries: []logproto.Entry{
+				{
+					Timestamp: time.Unix(0, 123456789012345),
+					Line:      "super line",
+				},
+				{
+					Timestamp:          time.Unix(0, 123456789012346),
+					Line:               "super line with metadata",
+					StructuredMetadata: []logproto.LabelPair{{Name: "trace_id", Value: "abc"}, {Name: "user", Value: "<me>"}},
+				},
+			},
+			Labels: `{test="test"}`,
+		},
+	})
+	require.NoError(t, err)
+
+	bytes, err := json.Marshal(streams)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{
+			"stream": {
+				"test": "test"
+			},
+			"values":[
+				[ "123456789012345", "super line" ],
+				[ "123456789012346", "super line with metadata", { "trace_id": "abc", "user": "<me>" } ]
+			]
+		}
+	]`, string(bytes))
+
+	var actual loghttp.Streams
+	require.NoError(t, json.Unmarshal(bytes, &actual))
+	require.Equal(t, streams, actual)
+}
+
 func Test_QueryResponseResultType(t *testing.T) {
 	for i, queryTest := range queryTests {
 		value, err := NewResultValue(queryTest.actual)
@@ -60,9 +60,28 @@ func WriteError(err error, w http.ResponseWriter) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 	default:
 		if grpcErr, ok := httpgrpc.HTTPResponseFromError(err); ok {
-			http.Error(w, string(grpcErr.Body), int(grpcErr.Code))
+			writeHTTPResponse(w, grpcErr)
 			return
 		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// writeHTTPResponse writes the response of an httpgrpc error. Its body is written as text
+// unless it has a content type, like the JSON body of the query limits errors.
+func writeHTTPResponse(w http.ResponseWriter, resp *httpgrpc.HTTPResponse) {
+	var contentType string
+	for _, h := range resp.Headers {
+		if http.CanonicalHeaderKey(h.Key) == "Content-Type" && len(h.Values) > 0 {
+			contentType = h.Values[0]
+		}
+	}
+	if contentType == "" {
+		http.Error(w, string(resp.Body), int(resp.Code))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(int(resp.Code))
+	_, _ = w.Write(resp.Body)
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/grafana/loki/pkg/logqlmodel"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/util"
+	"github.com/grafana/loki/pkg/validation"
 )
 
 func Test_writeError(t *testing.T) {
@@ -63,3 +64,28 @@ func Test_writeError(t *testing.T) {
 		})
 	}
 }
+
+func Test_writeQueryLimitError(t *testing.T) {
+	err := validation.NewEntriesLimitError(10000, 5000)
+	for name, err := range map[string]error{
+		"limit error": err,
+		// The error sent by a querier to the query frontend.
+		"httpgrpc": status.Convert(err).Err(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteError(err, rec)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			require.JSONEq(t, `{
+				"status": "error",
+				"errorType": "bad_data",
+				"error": "max entries limit per query exceeded, limit > max_entries_limit (10000 > 5000), lower the limit of the query to at most 5000",
+				"limit": "max_entries_limit_per_query",
+				"max": "5000",
+				"hint": "lower the limit of the query to at most 5000",
+				"suggested": {"limit": 5000}
+			}`, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+)
+
+// ImpersonateTenantHeader is the header admin tenants set to run a query request as another tenant.
+const ImpersonateTenantHeader = "X-Loki-Impersonate-Tenant"
+
+// ImpersonationConfig configures which tenants may impersonate other tenants.
+type ImpersonationConfig struct {
+	AdminTenants flagext.StringSliceCSV `yaml:"admin_tenants"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *ImpersonationConfig) RegisterFlags(f *flag.FlagSet) {
+	f.Var(&cfg.AdminTenants, "auth.impersonation.admin-tenants", "Comma separated list of tenants allowed to run query requests (queries, labels, series and tail) as another tenant using the "+ImpersonateTenantHeader+" header. Empty disables impersonation.")
+}
+
+// NewImpersonationMiddleware creates a middleware which lets admin tenants run query requests
+// as the tenant set in the ImpersonateTenantHeader header. It must run after the auth middleware
+// and logs every impersonated request to the audit logger.
+func NewImpersonationMiddleware(cfg ImpersonationConfig, logger log.Logger, reg prometheus.Registerer) middleware.Interface {
+	logger = log.With(logger, "component", "audit")
+	impersonations := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Name:      "tenant_impersonations_total",
+		Help:      "Total number of requests run by an admin tenant as another tenant.",
+	}, []string{"admin_tenant"})
+
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			target := req.Header.Get(ImpersonateTenantHeader)
+			if target == "" {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			admin, err := user.ExtractOrgID(req.Context())
+			if err != nil {
+				WriteError(httpgrpc.Errorf(http.StatusUnauthorized, err.Error()), w)
+				return
+			}
+			if !util.StringsContain(cfg.AdminTenants, admin) {
+				level.Warn(logger).Log("msg", "denied tenant impersonation", "admin_tenant", admin, "tenant", target, "method", req.Method, "path", req.URL.Path, "remote_addr", req.RemoteAddr)
+				WriteError(httpgrpc.Errorf(http.StatusForbidden, "tenant %s is not allowed to impersonate other tenants", admin), w)
+				return
+			}
+			if !isReadRequest(req) {
+				WriteError(httpgrpc.Errorf(http.StatusForbidden, "impersonation is only allowed for query requests"), w)
+				return
+			}
+
+			level.Info(logger).Log("msg", "tenant impersonation", "admin_tenant", admin, "tenant", target, "method", req.Method, "path", req.URL.Path, "query", req.URL.Query().Get("query"), "remote_addr", req.RemoteAddr)
+			impersonations.WithLabelValues(admin).Inc()
+
+			// Downstream components must see the impersonated tenant only.
+			req.Header.Del(ImpersonateTenantHeader)
+			req.Header.Set(user.OrgIDHeaderName, target)
+			next.ServeHTTP(w, req.WithContext(user.InjectOrgID(req.Context(), target)))
+		})
+	})
+}
+
+// readRoutes are the query endpoints which can be impersonated. They only read data,
+// whatever the method of the request. {} matches a single path segment.
+var readRoutes = [][]string{
+	splitRoute("/loki/api/v1/query_range"),
+	splitRoute("/loki/api/v1/query"),
+	splitRoute("/loki/api/v1/label"),
+	splitRoute("/loki/api/v1/labels"),
+	splitRoute("/loki/api/v1/label/{}/values"),
+	splitRoute("/loki/api/v1/series"),
+	splitRoute("/loki/api/v1/tail"),
+	splitRoute("/api/prom/query"),
+	splitRoute("/api/prom/label"),
+	splitRoute("/api/prom/label/{}/values"),
+	splitRoute("/api/prom/series"),
+	splitRoute("/api/prom/tail"),
+}
+
+func splitRoute(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func isReadRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+	default:
+		return false
+	}
+
+	segments := splitRoute(req.URL.Path)
+	for _, route := range readRoutes {
+		if matchRoute(route, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRoute(route, segments []string) bool {
+	if len(route) != len(segments) {
+		return false
+	}
+	for i, segment := range route {
+		if segment == "{}" {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+)
+
+func TestImpersonationMiddleware(t *testing.T) {
+	var audit bytes.Buffer
+	mw := middleware.Merge(
+		middleware.AuthenticateUser,
+		NewImpersonationMiddleware(ImpersonationConfig{AdminTenants: []string{"admin"}}, log.NewLogfmtLogger(&audit), prometheus.NewRegistry()),
+	)
+	handler := mw.Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		orgID, err := user.ExtractOrgID(req.Context())
+		require.NoError(t, err)
+		require.Empty(t, req.Header.Get(ImpersonateTenantHeader))
+		require.Equal(t, orgID, req.Header.Get(user.OrgIDHeaderName))
+		_, _ = w.Write([]byte(orgID))
+	}))
+
+	for _, tc := range []struct {
+		desc        string
+		method      string
+		path        string
+		orgID       string
+		impersonate string
+		status      int
+		tenant      string
+	}{
+		{"no impersonation", http.MethodGet, "/loki/api/v1/query_range", "team-a", "", http.StatusOK, "team-a"},
+		{"admin query", http.MethodGet, "/loki/api/v1/query_range", "admin", "team-a", http.StatusOK, "team-a"},
+		{"admin POST query", http.MethodPost, "/loki/api/v1/query", "admin", "team-a", http.StatusOK, "team-a"},
+		{"admin push", http.MethodPost, "/loki/api/v1/push", "admin", "team-a", http.StatusForbidden, ""},
+		{"admin label values", http.MethodGet, "/loki/api/v1/label/job/values", "admin", "team-a", http.StatusOK, "team-a"},
+		{"admin non api path", http.MethodGet, "/flush", "admin", "team-a", http.StatusForbidden, ""},
+		{"admin api path which is not a query", http.MethodGet, "/loki/api/admin/delete", "admin", "team-a", http.StatusForbidden, ""},
+		{"admin DELETE query path", http.MethodDelete, "/loki/api/v1/query", "admin", "team-a", http.StatusForbidden, ""},
+		{"admin rule group creation", http.MethodPost, "/loki/api/v1/rules/query", "admin", "team-a", http.StatusForbidden, ""},
+		{"admin legacy rule group creation", http.MethodPost, "/api/prom/rules/label", "admin", "team-a", http.StatusForbidden, ""},
+		{"not an admin", http.MethodGet, "/loki/api/v1/query_range", "team-b", "team-a", http.StatusForbidden, ""},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set(user.OrgIDHeaderName, tc.orgID)
+			if tc.impersonate != "" {
+				req.Header.Set(ImpersonateTenantHeader, tc.impersonate)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.status, rec.Code)
+			if tc.status == http.StatusOK {
+				require.Equal(t, tc.tenant, rec.Body.String())
+			}
+		})
+	}
+
+	require.Contains(t, audit.String(), `msg="tenant impersonation" admin_tenant=admin tenant=team-a method=GET path=/loki/api/v1/query_range`)
+	require.Contains(t, audit.String(), `msg="denied tenant impersonation" admin_tenant=team-b tenant=team-a`)
+}
@@ -0,0 +1,64 @@
+package validation
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TenantHierarchyConfig organises the tenants as orgs and their sub-tenants. The id of a
+// sub-tenant is the id of its org followed by the separator and its name, such as
+// acme.payments, sub-tenants can have sub-tenants themselves.
+type TenantHierarchyConfig struct {
+	Separator       string `yaml:"separator"`
+	QuerySubTenants bool   `yaml:"query_sub_tenants"`
+}
+
+// RegisterFlags adds the flags required to configure this to the given FlagSet.
+func (cfg *TenantHierarchyConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.Separator, "tenant-hierarchy.separator", "", "Separator between the id of an org and the name of its sub-tenants, such as '.' for acme.payments. Sub-tenants inherit the limits of their org and the ingestion rate of an org is shared by its sub-tenants. Disabled when empty.")
+	f.BoolVar(&cfg.QuerySubTenants, "tenant-hierarchy.query-sub-tenants", false, "Whether the queries of an org also query the sub-tenants declared in the runtime overrides, their series have a __tenant_id__ label.")
+}
+
+// Validate validates the config.
+func (cfg *TenantHierarchyConfig) Validate() error {
+	if strings.Contains(cfg.Separator, "|") {
+		return errors.New("the tenant hierarchy separator must not contain |, it separates the tenants of multi-tenant requests")
+	}
+	if cfg.QuerySubTenants && cfg.Separator == "" {
+		return errors.New("querying sub-tenants requires a tenant hierarchy separator")
+	}
+	return nil
+}
+
+// Enabled returns whether tenants are organised in a hierarchy.
+func (cfg TenantHierarchyConfig) Enabled() bool {
+	return cfg.Separator != ""
+}
+
+// Parent returns the org of a sub-tenant, false if the tenant isn't a sub-tenant.
+func (cfg TenantHierarchyConfig) Parent(tenantID string) (string, bool) {
+	if !cfg.Enabled() {
+		return "", false
+	}
+	i := strings.LastIndex(tenantID, cfg.Separator)
+	if i <= 0 {
+		return "", false
+	}
+	return tenantID[:i], true
+}
+
+// Ancestors returns the orgs a tenant belongs to, the closest first.
+func (cfg TenantHierarchyConfig) Ancestors(tenantID string) []string {
+	var ancestors []string
+	for parent, ok := cfg.Parent(tenantID); ok; parent, ok = cfg.Parent(parent) {
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors
+}
+
+// IsSubTenant returns whether a tenant is a sub-tenant of an org, directly or not.
+func (cfg TenantHierarchyConfig) IsSubTenant(tenantID, org string) bool {
+	return cfg.Enabled() && strings.HasPrefix(tenantID, org+cfg.Separator) && len(tenantID) > len(org)+len(cfg.Separator)
+}
@@ -0,0 +1,23 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantHierarchy(t *testing.T) {
+	cfg := TenantHierarchyConfig{Separator: "."}
+	require.NoError(t, cfg.Validate())
+
+	require.Equal(t, []string{"acme.payments", "acme"}, cfg.Ancestors("acme.payments.eu"))
+	require.Empty(t, cfg.Ancestors("acme"))
+	require.Empty(t, cfg.Ancestors(".hidden"))
+	require.True(t, cfg.IsSubTenant("acme.payments.eu", "acme"))
+	require.False(t, cfg.IsSubTenant("acmecorp", "acme"))
+	require.False(t, cfg.IsSubTenant("acme.", "acme"))
+
+	require.Empty(t, TenantHierarchyConfig{}.Ancestors("acme.payments"))
+	require.Error(t, (&TenantHierarchyConfig{Separator: "|"}).Validate())
+	require.Error(t, (&TenantHierarchyConfig{QuerySubTenants: true}).Validate())
+}
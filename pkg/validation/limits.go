@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"sort"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -29,6 +31,21 @@ const (
 
 	defaultPerStreamRateLimit  = 3 << 20 // 3MB
 	defaultPerStreamBurstLimit = 5 * defaultPerStreamRateLimit
+
+	// TimestampPolicyTrust ingests entries with their timestamp.
+	TimestampPolicyTrust = "trust"
+	// TimestampPolicyClamp ingests entries with a skewed timestamp with their arrival time.
+	TimestampPolicyClamp = "clamp"
+	// TimestampPolicyAnnotate ingests entries with a skewed timestamp with a marker containing their arrival time.
+	TimestampPolicyAnnotate = "annotate"
+
+	// LabelValueLengthPolicyReject rejects the streams with a label value longer than max_label_value_length.
+	LabelValueLengthPolicyReject = "reject"
+	// LabelValueLengthPolicyTruncate ingests the streams with their overlong label values truncated.
+	LabelValueLengthPolicyTruncate = "truncate"
+	// LabelValueLengthPolicyHash ingests the streams with their overlong label values truncated and
+	// ended with a hash of the whole value, so that distinct values remain distinct.
+	LabelValueLengthPolicyHash = "hash"
 )
 
 // Limits describe all the limits for users; can be used to describe global default
@@ -42,6 +59,7 @@ type Limits struct {
 	IngestionBurstSizeMB   float64          `yaml:"ingestion_burst_size_mb" json:"ingestion_burst_size_mb"`
 	MaxLabelNameLength     int              `yaml:"max_label_name_length" json:"max_label_name_length"`
 	MaxLabelValueLength    int              `yaml:"max_label_value_length" json:"max_label_value_length"`
+	LabelValueLengthPolicy string           `yaml:"max_label_value_length_policy" json:"max_label_value_length_policy"`
 	MaxLabelNamesPerSeries int              `yaml:"max_label_names_per_series" json:"max_label_names_per_series"`
 	RejectOldSamples       bool             `yaml:"reject_old_samples" json:"reject_old_samples"`
 	RejectOldSamplesMaxAge model.Duration   `yaml:"reject_old_samples_max_age" json:"reject_old_samples_max_age"`
@@ -49,6 +67,18 @@ type Limits struct {
 	EnforceMetricName      bool             `yaml:"enforce_metric_name" json:"enforce_metric_name"`
 	MaxLineSize            flagext.ByteSize `yaml:"max_line_size" json:"max_line_size"`
 	MaxLineSizeTruncate    bool             `yaml:"max_line_size_truncate" json:"max_line_size_truncate"`
+	MaxLineSizeAnnotate    bool             `yaml:"max_line_size_truncate_annotate" json:"max_line_size_truncate_annotate"`
+	MaxLineSizeLabel       string           `yaml:"max_line_size_truncate_label" json:"max_line_size_truncate_label"`
+	TimestampPolicy        string           `yaml:"ingestion_timestamp_policy" json:"ingestion_timestamp_policy"`
+	TimestampMaxSkew       model.Duration   `yaml:"ingestion_timestamp_max_skew" json:"ingestion_timestamp_max_skew"`
+	// AllowStructuredMetadata is also enforced by the ingesters, which store the structured
+	// metadata of the streams created while it is set.
+	AllowStructuredMetadata bool `yaml:"allow_structured_metadata" json:"allow_structured_metadata"`
+
+	IngestionRatePerSourceIP       flagext.ByteSize `yaml:"ingestion_rate_per_source_ip" json:"ingestion_rate_per_source_ip"`
+	IngestionBurstSizePerSourceIP  flagext.ByteSize `yaml:"ingestion_burst_size_per_source_ip" json:"ingestion_burst_size_per_source_ip"`
+	IngestionRatePerPrincipal      flagext.ByteSize `yaml:"ingestion_rate_per_principal" json:"ingestion_rate_per_principal"`
+	IngestionBurstSizePerPrincipal flagext.ByteSize `yaml:"ingestion_burst_size_per_principal" json:"ingestion_burst_size_per_principal"`
 
 	// Ingester enforced limits.
 	MaxLocalStreamsPerUser  int              `yaml:"max_streams_per_user" json:"max_streams_per_user"`
@@ -58,17 +88,18 @@ type Limits struct {
 	PerStreamRateLimitBurst flagext.ByteSize `yaml:"per_stream_rate_limit_burst" json:"per_stream_rate_limit_burst"`
 
 	// Querier enforced limits.
-	MaxChunksPerQuery          int            `yaml:"max_chunks_per_query" json:"max_chunks_per_query"`
-	MaxQuerySeries             int            `yaml:"max_query_series" json:"max_query_series"`
-	MaxQueryLookback           model.Duration `yaml:"max_query_lookback" json:"max_query_lookback"`
-	MaxQueryLength             model.Duration `yaml:"max_query_length" json:"max_query_length"`
-	MaxQueryParallelism        int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
-	CardinalityLimit           int            `yaml:"cardinality_limit" json:"cardinality_limit"`
-	MaxStreamsMatchersPerQuery int            `yaml:"max_streams_matchers_per_query" json:"max_streams_matchers_per_query"`
-	MaxConcurrentTailRequests  int            `yaml:"max_concurrent_tail_requests" json:"max_concurrent_tail_requests"`
-	MaxEntriesLimitPerQuery    int            `yaml:"max_entries_limit_per_query" json:"max_entries_limit_per_query"`
-	MaxCacheFreshness          model.Duration `yaml:"max_cache_freshness_per_query" json:"max_cache_freshness_per_query"`
-	MaxQueriersPerTenant       int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
+	MaxChunksPerQuery          int              `yaml:"max_chunks_per_query" json:"max_chunks_per_query"`
+	MaxQuerySeries             int              `yaml:"max_query_series" json:"max_query_series"`
+	MaxQueryMemory             flagext.ByteSize `yaml:"max_query_memory" json:"max_query_memory"`
+	MaxQueryLookback           model.Duration   `yaml:"max_query_lookback" json:"max_query_lookback"`
+	MaxQueryLength             model.Duration   `yaml:"max_query_length" json:"max_query_length"`
+	MaxQueryParallelism        int              `yaml:"max_query_parallelism" json:"max_query_parallelism"`
+	CardinalityLimit           int              `yaml:"cardinality_limit" json:"cardinality_limit"`
+	MaxStreamsMatchersPerQuery int              `yaml:"max_streams_matchers_per_query" json:"max_streams_matchers_per_query"`
+	MaxConcurrentTailRequests  int              `yaml:"max_concurrent_tail_requests" json:"max_concurrent_tail_requests"`
+	MaxEntriesLimitPerQuery    int              `yaml:"max_entries_limit_per_query" json:"max_entries_limit_per_query"`
+	MaxCacheFreshness          model.Duration   `yaml:"max_cache_freshness_per_query" json:"max_cache_freshness_per_query"`
+	MaxQueriersPerTenant       int              `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
 
 	// Query frontend enforced limits. The default is actually parameterized by the queryrange config.
 	QuerySplitDuration  model.Duration `yaml:"split_queries_by_interval" json:"split_queries_by_interval"`
@@ -102,6 +133,9 @@ type Limits struct {
 	RetentionPeriod model.Duration    `yaml:"retention_period" json:"retention_period"`
 	StreamRetention []StreamRetention `yaml:"retention_stream,omitempty" json:"retention_stream,omitempty"`
 
+	// Compliance (WORM) retention: the chunks are locked by the object store and can't be deleted before.
+	ComplianceRetentionPeriod model.Duration `yaml:"compliance_retention_period" json:"compliance_retention_period"`
+
 	// Config for overrides, convenient if it goes here.
 	PerTenantOverrideConfig string         `yaml:"per_tenant_override_config" json:"per_tenant_override_config"`
 	PerTenantOverridePeriod model.Duration `yaml:"per_tenant_override_period" json:"per_tenant_override_period"`
@@ -121,8 +155,19 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Float64Var(&l.IngestionBurstSizeMB, "distributor.ingestion-burst-size-mb", 6, "Per-user allowed ingestion burst size (in sample size). Units in MB.")
 	f.Var(&l.MaxLineSize, "distributor.max-line-size", "maximum line length allowed, i.e. 100mb. Default (0) means unlimited.")
 	f.BoolVar(&l.MaxLineSizeTruncate, "distributor.max-line-size-truncate", false, "Whether to truncate lines that exceed max_line_size")
+	f.BoolVar(&l.MaxLineSizeAnnotate, "distributor.max-line-size-truncate-annotate", false, "Whether truncated lines end with a marker containing the number of bytes removed, i.e. '...[truncated 42 bytes]'")
+	f.StringVar(&l.MaxLineSizeLabel, "distributor.max-line-size-truncate-label", "", "Label set to 'true' on the streams of the truncated lines, i.e. 'truncated'. Truncated lines are moved to a stream with the label added. Empty disables the label.")
+	f.StringVar(&l.TimestampPolicy, "distributor.ingestion-timestamp-policy", TimestampPolicyTrust, "How entries whose timestamp is more than ingestion_timestamp_max_skew away from their arrival time are ingested: with their timestamp (trust), with their arrival time (clamp), or with their timestamp and a marker containing their arrival time, i.e. '[received at 2021-06-01T12:00:00Z]' (annotate).")
+	_ = l.TimestampMaxSkew.Set("10m")
+	f.Var(&l.TimestampMaxSkew, "distributor.ingestion-timestamp-max-skew", "Maximum difference between the timestamp and the arrival time of entries before ingestion_timestamp_policy applies.")
+	f.BoolVar(&l.AllowStructuredMetadata, "validation.allow-structured-metadata", false, "Accept entries with structured metadata and store it in the chunks. Requires unordered writes.")
+	f.Var(&l.IngestionRatePerSourceIP, "distributor.ingestion-rate-limit-per-source-ip", "Per-user ingestion rate limit of each source IP of the push API, per distributor, in bytes per second (1MB, 256KB, etc). Default (0) means unlimited.")
+	f.Var(&l.IngestionBurstSizePerSourceIP, "distributor.ingestion-burst-size-per-source-ip", "Per-user allowed ingestion burst size of each source IP of the push API, per distributor. Default (0) means the rate limit.")
+	f.Var(&l.IngestionRatePerPrincipal, "distributor.ingestion-rate-limit-per-principal", "Per-user ingestion rate limit of each authenticated principal of the push API, per distributor, in bytes per second (1MB, 256KB, etc). Default (0) means unlimited.")
+	f.Var(&l.IngestionBurstSizePerPrincipal, "distributor.ingestion-burst-size-per-principal", "Per-user allowed ingestion burst size of each authenticated principal of the push API, per distributor. Default (0) means the rate limit.")
 	f.IntVar(&l.MaxLabelNameLength, "validation.max-length-label-name", 1024, "Maximum length accepted for label names")
 	f.IntVar(&l.MaxLabelValueLength, "validation.max-length-label-value", 2048, "Maximum length accepted for label value. This setting also applies to the metric name")
+	f.StringVar(&l.LabelValueLengthPolicy, "validation.max-length-label-value-policy", LabelValueLengthPolicyReject, "How streams with a label value longer than max_label_value_length are ingested: rejected (reject), with the value truncated (truncate), or with the value truncated and ended with a hash of the whole value (hash).")
 	f.IntVar(&l.MaxLabelNamesPerSeries, "validation.max-label-names-per-series", 30, "Maximum number of label names per series.")
 	f.BoolVar(&l.RejectOldSamples, "validation.reject-old-samples", true, "Reject old samples.")
 
@@ -147,6 +192,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	_ = l.MaxQueryLength.Set("721h")
 	f.Var(&l.MaxQueryLength, "store.max-query-length", "Limit to length of chunk store queries, 0 to disable.")
 	f.IntVar(&l.MaxQuerySeries, "querier.max-query-series", 500, "Limit the maximum of unique series returned by a metric query. When the limit is reached an error is returned.")
+	f.Var(&l.MaxQueryMemory, "querier.max-query-memory", "Maximum memory a single query can use in a querier or an ingester, accounting the chunks and the entries it holds, also expressible in human readable forms (1GB, 512MB, etc). When the limit is exceeded the query is cancelled. 0 to disable.")
 
 	_ = l.MaxQueryLookback.Set("0s")
 	f.Var(&l.MaxQueryLookback, "querier.max-query-lookback", "Limit how long back data (series and metadata) can be queried, up until <lookback> duration ago. This limit is enforced in the query-frontend, querier and ruler. If the requested time range is outside the allowed range, the request will not fail but will be manipulated to only query data within the allowed time range. 0 to disable.")
@@ -172,6 +218,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&l.PerTenantOverrideConfig, "limits.per-user-override-config", "", "File name of per-user overrides.")
 	_ = l.RetentionPeriod.Set("744h")
 	f.Var(&l.RetentionPeriod, "store.retention", "How long before chunks will be deleted from the store. (requires compactor retention enabled).")
+	f.Var(&l.ComplianceRetentionPeriod, "store.compliance-retention", "How long after their end the chunks are locked by the object store (S3 Object Lock, GCS bucket retention policy), and can't be deleted by the retention nor the delete API. 0 disables the compliance retention.")
 
 	_ = l.PerTenantOverridePeriod.Set("10s")
 	f.Var(&l.PerTenantOverridePeriod, "limits.per-user-override-period", "Period with this to reload the overrides.")
@@ -197,8 +244,42 @@ func (l *Limits) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return unmarshal((*plain)(l))
 }
 
+// Inherit returns the limits of a sub-tenant: its overrides, in YAML, applied on top of
+// the limits of its org.
+func (l *Limits) Inherit(overrides []byte) (*Limits, error) {
+	type plain Limits
+	b, err := yaml.Marshal(l)
+	if err != nil {
+		return nil, errors.Wrap(err, "cloning limits (marshaling)")
+	}
+	var inherited Limits
+	if err := yaml.Unmarshal(b, (*plain)(&inherited)); err != nil {
+		return nil, errors.Wrap(err, "cloning limits (unmarshaling)")
+	}
+	if err := yaml.UnmarshalStrict(overrides, (*plain)(&inherited)); err != nil {
+		return nil, err
+	}
+	return &inherited, nil
+}
+
 // Validate validates that this limits config is valid.
 func (l *Limits) Validate() error {
+	switch l.TimestampPolicy {
+	case "", TimestampPolicyTrust, TimestampPolicyClamp, TimestampPolicyAnnotate:
+	default:
+		return fmt.Errorf("invalid ingestion timestamp policy %q, must be one of %s, %s or %s", l.TimestampPolicy, TimestampPolicyTrust, TimestampPolicyClamp, TimestampPolicyAnnotate)
+	}
+	if l.MaxLineSizeLabel != "" && !model.LabelName(l.MaxLineSizeLabel).IsValid() {
+		return fmt.Errorf("invalid max line size truncate label %q", l.MaxLineSizeLabel)
+	}
+	if l.AllowStructuredMetadata && !l.UnorderedWrites {
+		return errors.New("allow_structured_metadata requires unordered_writes")
+	}
+	switch l.LabelValueLengthPolicy {
+	case "", LabelValueLengthPolicyReject, LabelValueLengthPolicyTruncate, LabelValueLengthPolicyHash:
+	default:
+		return fmt.Errorf("invalid label value length policy %q, must be one of %s, %s or %s", l.LabelValueLengthPolicy, LabelValueLengthPolicyReject, LabelValueLengthPolicyTruncate, LabelValueLengthPolicyHash)
+	}
 	if l.StreamRetention != nil {
 		for i, rule := range l.StreamRetention {
 			matchers, err := logql.ParseMatchers(rule.Selector)
@@ -239,16 +320,47 @@ type TenantLimits interface {
 // Overrides periodically fetch a set of per-user overrides, and provides convenience
 // functions for fetching the correct value.
 type Overrides struct {
-	defaultLimits *Limits
+	defaultLimits atomic.Value // *Limits
 	tenantLimits  TenantLimits
+	hierarchy     TenantHierarchyConfig
 }
 
 // NewOverrides makes a new Overrides.
 func NewOverrides(defaults Limits, tenantLimits TenantLimits) (*Overrides, error) {
-	return &Overrides{
-		tenantLimits:  tenantLimits,
-		defaultLimits: &defaults,
-	}, nil
+	o := &Overrides{
+		tenantLimits: tenantLimits,
+	}
+	o.SetDefaultLimits(defaults)
+	return o, nil
+}
+
+// SetDefaultLimits replaces the limits applied to tenants without overrides.
+// It is safe to call while the limits are being read.
+func (o *Overrides) SetDefaultLimits(defaults Limits) {
+	o.defaultLimits.Store(&defaults)
+}
+
+// SetTenantHierarchy organises the tenants in a hierarchy, the sub-tenants without
+// overrides get the limits of their org. It must be called before the limits are read.
+func (o *Overrides) SetTenantHierarchy(cfg TenantHierarchyConfig) {
+	o.hierarchy = cfg
+}
+
+// TenantHierarchy returns how the tenants are organised.
+func (o *Overrides) TenantHierarchy() TenantHierarchyConfig {
+	return o.hierarchy
+}
+
+// SubTenants returns the sub-tenants of an org declared in the overrides, sorted.
+func (o *Overrides) SubTenants(org string) []string {
+	var subTenants []string
+	for tenantID := range o.AllByUserID() {
+		if o.hierarchy.IsSubTenant(tenantID, org) {
+			subTenants = append(subTenants, tenantID)
+		}
+	}
+	sort.Strings(subTenants)
+	return subTenants
 }
 
 func (o *Overrides) AllByUserID() map[string]*Limits {
@@ -287,6 +399,11 @@ func (o *Overrides) MaxLabelValueLength(userID string) int {
 	return o.getOverridesForUser(userID).MaxLabelValueLength
 }
 
+// LabelValueLengthPolicy returns how streams with an overlong label value are ingested.
+func (o *Overrides) LabelValueLengthPolicy(userID string) string {
+	return o.getOverridesForUser(userID).LabelValueLengthPolicy
+}
+
 // MaxLabelNamesPerSeries returns maximum number of label/value pairs timeseries.
 func (o *Overrides) MaxLabelNamesPerSeries(userID string) int {
 	return o.getOverridesForUser(userID).MaxLabelNamesPerSeries
@@ -340,6 +457,11 @@ func (o *Overrides) MaxQuerySeries(userID string) int {
 	return o.getOverridesForUser(userID).MaxQuerySeries
 }
 
+// MaxQueryMemory returns the maximum memory in bytes a single query can use.
+func (o *Overrides) MaxQueryMemory(userID string) int {
+	return o.getOverridesForUser(userID).MaxQueryMemory.Val()
+}
+
 // MaxQueriersPerUser returns the maximum number of queriers that can handle requests for this user.
 func (o *Overrides) MaxQueriersPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxQueriersPerTenant
@@ -391,6 +513,31 @@ func (o *Overrides) MaxLineSizeTruncate(userID string) bool {
 	return o.getOverridesForUser(userID).MaxLineSizeTruncate
 }
 
+// MaxLineSizeAnnotate returns whether truncated lines should end with a truncation marker.
+func (o *Overrides) MaxLineSizeAnnotate(userID string) bool {
+	return o.getOverridesForUser(userID).MaxLineSizeAnnotate
+}
+
+// MaxLineSizeLabel returns the label added to the streams of truncated lines, empty if none.
+func (o *Overrides) MaxLineSizeLabel(userID string) string {
+	return o.getOverridesForUser(userID).MaxLineSizeLabel
+}
+
+// TimestampPolicy returns how entries with a skewed timestamp are ingested.
+func (o *Overrides) TimestampPolicy(userID string) string {
+	return o.getOverridesForUser(userID).TimestampPolicy
+}
+
+// TimestampMaxSkew returns the maximum difference between the timestamp and the arrival time of entries.
+func (o *Overrides) TimestampMaxSkew(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).TimestampMaxSkew)
+}
+
+// AllowStructuredMetadata returns whether the entries of the user may have structured metadata.
+func (o *Overrides) AllowStructuredMetadata(userID string) bool {
+	return o.getOverridesForUser(userID).AllowStructuredMetadata
+}
+
 // MaxEntriesLimitPerQuery returns the limit to number of entries the querier should return per query.
 func (o *Overrides) MaxEntriesLimitPerQuery(userID string) int {
 	return o.getOverridesForUser(userID).MaxEntriesLimitPerQuery
@@ -496,6 +643,11 @@ func (o *Overrides) RetentionPeriod(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).RetentionPeriod)
 }
 
+// ComplianceRetentionPeriod returns how long the chunks of a given user are locked after their end.
+func (o *Overrides) ComplianceRetentionPeriod(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).ComplianceRetentionPeriod)
+}
+
 // StreamRetention returns the retention period for a given user.
 func (o *Overrides) StreamRetention(userID string) []StreamRetention {
 	return o.getOverridesForUser(userID).StreamRetention
@@ -506,7 +658,8 @@ func (o *Overrides) UnorderedWrites(userID string) bool {
 }
 
 func (o *Overrides) DefaultLimits() *Limits {
-	return o.defaultLimits
+	l, _ := o.defaultLimits.Load().(*Limits)
+	return l
 }
 
 func (o *Overrides) PerStreamRateLimit(userID string) RateLimit {
@@ -518,14 +671,44 @@ func (o *Overrides) PerStreamRateLimit(userID string) RateLimit {
 	}
 }
 
+// IngestionRatePerSourceIP returns the ingestion rate limit of each source IP of the push API.
+func (o *Overrides) IngestionRatePerSourceIP(userID string) RateLimit {
+	user := o.getOverridesForUser(userID)
+	return sourceRateLimit(user.IngestionRatePerSourceIP, user.IngestionBurstSizePerSourceIP)
+}
+
+// IngestionRatePerPrincipal returns the ingestion rate limit of each principal of the push API.
+func (o *Overrides) IngestionRatePerPrincipal(userID string) RateLimit {
+	user := o.getOverridesForUser(userID)
+	return sourceRateLimit(user.IngestionRatePerPrincipal, user.IngestionBurstSizePerPrincipal)
+}
+
+func sourceRateLimit(limit, burst flagext.ByteSize) RateLimit {
+	if limit == 0 {
+		return Unlimited
+	}
+	if burst == 0 {
+		burst = limit
+	}
+	return RateLimit{
+		Limit: rate.Limit(float64(limit.Val())),
+		Burst: burst.Val(),
+	}
+}
+
 func (o *Overrides) getOverridesForUser(userID string) *Limits {
 	if o.tenantLimits != nil {
 		l := o.tenantLimits.TenantLimits(userID)
 		if l != nil {
 			return l
 		}
+		for _, org := range o.hierarchy.Ancestors(userID) {
+			if l := o.tenantLimits.TenantLimits(org); l != nil {
+				return l
+			}
+		}
 	}
-	return o.defaultLimits
+	return o.DefaultLimits()
 }
 
 // OverwriteMarshalingStringMap will overwrite the src map when unmarshaling
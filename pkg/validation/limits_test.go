@@ -239,3 +239,13 @@ reject_old_samples: true
 		})
 	}
 }
+
+func TestOverridesSetDefaultLimits(t *testing.T) {
+	o, err := NewOverrides(Limits{MaxLineSize: 10}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 10, o.MaxLineSize("user"))
+
+	o.SetDefaultLimits(Limits{MaxLineSize: 20})
+	require.Equal(t, 20, o.MaxLineSize("user"))
+	require.Equal(t, 20, o.DefaultLimits().MaxLineSize.Val())
+}
@@ -0,0 +1,119 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/weaveworks/common/httpgrpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MaxQueryLength is the limit of the time range of a query.
+	MaxQueryLength         = "max_query_length"
+	MaxQueryLengthErrorMsg = "the query time range exceeds the limit (query length: %s, limit: %s)"
+	MaxQueryLengthHint     = "narrow the time range of the query to at most %s"
+	// MaxEntriesLimitPerQuery is the limit of the number of entries returned by a log query.
+	MaxEntriesLimitPerQuery         = "max_entries_limit_per_query"
+	MaxEntriesLimitPerQueryErrorMsg = "max entries limit per query exceeded, limit > max_entries_limit (%d > %d)"
+	MaxEntriesLimitPerQueryHint     = "lower the limit of the query to at most %d"
+	// MaxQueryLookback is the limit of how far back in time data can be queried.
+	MaxQueryLookback         = "max_query_lookback"
+	MaxQueryLookbackErrorMsg = "the query time range is entirely before the max query lookback (end: %s, lookback: %s)"
+	MaxQueryLookbackHint     = "query data newer than %s"
+)
+
+// QueryLimitError is returned when a query exceeds a per-tenant query limit. Besides
+// the error message, it carries the limit, its allowed maximum and a narrowed query which
+// complies with it, so that clients like Grafana can surface them.
+//
+// It is written as a JSON body with a 400 status code, over HTTP as well as httpgrpc.
+type QueryLimitError struct {
+	// Limit is the name of the limit in the limits config.
+	Limit   string `json:"limit"`
+	Message string `json:"-"`
+	// Max is the allowed maximum, formatted like in the limits config.
+	Max  string `json:"max"`
+	Hint string `json:"hint"`
+	// Suggested is the narrowed query.
+	Suggested SuggestedQuery `json:"suggested"`
+}
+
+// SuggestedQuery holds the parameters of a query changed to comply with a limit.
+type SuggestedQuery struct {
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+	Limit uint32     `json:"limit,omitempty"`
+}
+
+// NewQueryTooLongError returns the error of a query whose time range exceeds the max query length.
+// The suggested query keeps the end of the time range and moves its start.
+func NewQueryTooLongError(from, through time.Time, maxQueryLength time.Duration) *QueryLimitError {
+	start := through.Add(-maxQueryLength)
+	return &QueryLimitError{
+		Limit:     MaxQueryLength,
+		Message:   fmt.Sprintf(MaxQueryLengthErrorMsg, through.Sub(from), maxQueryLength),
+		Max:       maxQueryLength.String(),
+		Hint:      fmt.Sprintf(MaxQueryLengthHint, maxQueryLength),
+		Suggested: SuggestedQuery{Start: &start, End: &through},
+	}
+}
+
+// NewEntriesLimitError returns the error of a log query whose limit exceeds the max entries limit.
+func NewEntriesLimitError(limit uint32, maxEntriesLimit int) *QueryLimitError {
+	return &QueryLimitError{
+		Limit:     MaxEntriesLimitPerQuery,
+		Message:   fmt.Sprintf(MaxEntriesLimitPerQueryErrorMsg, limit, maxEntriesLimit),
+		Max:       fmt.Sprint(maxEntriesLimit),
+		Hint:      fmt.Sprintf(MaxEntriesLimitPerQueryHint, maxEntriesLimit),
+		Suggested: SuggestedQuery{Limit: uint32(maxEntriesLimit)},
+	}
+}
+
+// NewQueryLookbackError returns the error of a query whose time range ends before the max query lookback.
+// The suggested query starts at the oldest time which can be queried.
+func NewQueryLookbackError(through, minStart time.Time, maxQueryLookback time.Duration) *QueryLimitError {
+	return &QueryLimitError{
+		Limit:     MaxQueryLookback,
+		Message:   fmt.Sprintf(MaxQueryLookbackErrorMsg, through.UTC().Format(time.RFC3339), maxQueryLookback),
+		Max:       maxQueryLookback.String(),
+		Hint:      fmt.Sprintf(MaxQueryLookbackHint, minStart.UTC().Format(time.RFC3339)),
+		Suggested: SuggestedQuery{Start: &minStart},
+	}
+}
+
+func (e *QueryLimitError) Error() string {
+	return e.Message + ", " + e.Hint
+}
+
+// MarshalJSON encodes the error like the errors of the Prometheus API, with the details of the limit.
+func (e *QueryLimitError) MarshalJSON() ([]byte, error) {
+	type limitError QueryLimitError
+	return json.Marshal(struct {
+		Status    string `json:"status"`
+		ErrorType string `json:"errorType"`
+		Error     string `json:"error"`
+		limitError
+	}{
+		Status:     "error",
+		ErrorType:  "bad_data",
+		Error:      e.Error(),
+		limitError: limitError(*e),
+	})
+}
+
+// GRPCStatus makes the error an httpgrpc error with a 400 status code and a JSON body,
+// so that it keeps its details when it is sent from the queriers to the query frontend.
+func (e *QueryLimitError) GRPCStatus() *status.Status {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return status.Convert(httpgrpc.Errorf(http.StatusBadRequest, "%s", e.Error()))
+	}
+	return status.Convert(httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{
+		Code:    http.StatusBadRequest,
+		Headers: []*httpgrpc.Header{{Key: "Content-Type", Values: []string{"application/json"}}},
+		Body:    body,
+	}))
+}
@@ -20,9 +20,23 @@ const (
 	// Declared here to avoid duplication in ingester and distributor.
 	RateLimited         = "rate_limited"
 	RateLimitedErrorMsg = "Ingestion rate limit exceeded (limit: %d bytes/sec) while attempting to ingest '%d' lines totaling '%d' bytes, reduce log volume or contact your Loki administrator to see if the limit can be increased"
+	// SourceIPRateLimited and PrincipalRateLimited are reasons for discarding lines when the
+	// rate limit of a single source IP or principal of the push API is hit.
+	SourceIPRateLimited         = "source_ip_rate_limited"
+	SourceIPRateLimitedErrorMsg = "Ingestion rate limit of source IP '%s' exceeded (limit: %d bytes/sec) while attempting to ingest '%d' lines totaling '%d' bytes, reduce log volume or contact your Loki administrator to see if the limit can be increased"
+	PrincipalRateLimited         = "principal_rate_limited"
+	PrincipalRateLimitedErrorMsg = "Ingestion rate limit of principal '%s' exceeded (limit: %d bytes/sec) while attempting to ingest '%d' lines totaling '%d' bytes, reduce log volume or contact your Loki administrator to see if the limit can be increased"
+	// OrgRateLimited is the reason for discarding the lines of a sub-tenant when the
+	// ingestion rate limit shared by the sub-tenants of its org is hit.
+	OrgRateLimited         = "org_rate_limited"
+	OrgRateLimitedErrorMsg = "Ingestion rate limit of org '%s' exceeded (limit: %d bytes/sec) while attempting to ingest '%d' lines totaling '%d' bytes, reduce log volume or contact your Loki administrator to see if the limit can be increased"
 	// LineTooLong is a reason for discarding too long log lines.
 	LineTooLong         = "line_too_long"
 	LineTooLongErrorMsg = "Max entry size '%d' bytes exceeded for stream '%s' while adding an entry with length '%d' bytes"
+	// DisallowedStructuredMetadata is a reason for discarding log lines with structured metadata
+	// when allow_structured_metadata is disabled.
+	DisallowedStructuredMetadata         = "disallowed_structured_metadata"
+	DisallowedStructuredMetadataErrorMsg = "entry for stream '%s' has structured metadata, which is not allowed"
 	// StreamLimit is a reason for discarding lines when we can't create a new stream
 	// because the limit of active streams has been reached.
 	StreamLimit         = "stream_limit"
@@ -50,6 +64,8 @@ const (
 	// DuplicateLabelNames is a reason for discarding a log line which has duplicate label names
 	DuplicateLabelNames         = "duplicate_label_names"
 	DuplicateLabelNamesErrorMsg = "stream '%s' has duplicate label name: '%s'"
+	// TimestampSkewed is a reason for mutating a log line whose timestamp is too far from its arrival time.
+	TimestampSkewed = "timestamp_skewed"
 )
 
 type ErrStreamRateLimit struct {
@@ -72,7 +88,7 @@ var MutatedSamples = prometheus.NewCounterVec(
 		Name:      "mutated_samples_total",
 		Help:      "The total number of samples that have been mutated.",
 	},
-	[]string{ReasonLabel, "truncated"},
+	[]string{ReasonLabel, "tenant"},
 )
 
 // MutatedBytes is a metric of the total mutated bytes, by reason.
@@ -82,7 +98,7 @@ var MutatedBytes = prometheus.NewCounterVec(
 		Name:      "mutated_bytes_total",
 		Help:      "The total number of bytes that have been mutated.",
 	},
-	[]string{ReasonLabel, "truncated"},
+	[]string{ReasonLabel, "tenant"},
 )
 
 // DiscardedBytes is a metric of the total discarded bytes, by reason.
@@ -105,6 +121,16 @@ var DiscardedSamples = prometheus.NewCounterVec(
 	[]string{ReasonLabel, "tenant"},
 )
 
+// LabelLimitsExceeded is a metric of the number of streams exceeding a label limit, by reason.
+var LabelLimitsExceeded = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "loki",
+		Name:      "label_limits_exceeded_total",
+		Help:      "The total number of streams that exceeded a label limit.",
+	},
+	[]string{ReasonLabel, "tenant"},
+)
+
 func init() {
-	prometheus.MustRegister(DiscardedSamples, DiscardedBytes)
+	prometheus.MustRegister(DiscardedSamples, DiscardedBytes, MutatedSamples, MutatedBytes, LabelLimitsExceeded)
 }